@@ -12,10 +12,12 @@ import (
 	"syscall"
 
 	"github.com/gorilla/mux"
+	"github.com/grafana/agent/pkg/limits"
 	"github.com/grafana/agent/pkg/logs"
 	"github.com/grafana/agent/pkg/metrics"
 	"github.com/grafana/agent/pkg/metrics/instance"
 	"github.com/grafana/agent/pkg/server"
+	"github.com/grafana/agent/pkg/supportbundle"
 	"github.com/grafana/agent/pkg/traces"
 	"github.com/grafana/agent/pkg/usagestats"
 	"github.com/oklog/run"
@@ -23,6 +25,7 @@ import (
 	"gopkg.in/yaml.v2"
 
 	"github.com/grafana/agent/pkg/config"
+	"github.com/grafana/agent/pkg/config/remote"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/weaveworks/common/signals"
 
@@ -39,6 +42,7 @@ type Entrypoint struct {
 	cfg config.Config
 
 	srv          *server.Server
+	limiter      *limits.Limiter
 	promMetrics  *metrics.Agent
 	lokiLogs     *logs.Logs
 	tempoTraces  *traces.Traces
@@ -47,6 +51,10 @@ type Entrypoint struct {
 
 	reloadListener net.Listener
 	reloadServer   *http.Server
+
+	// remotePoller checks the remote config for changes; nil if polling is
+	// disabled.
+	remotePoller *remote.Poller
 }
 
 // Reloader is any function that returns a new config.
@@ -67,17 +75,19 @@ func NewEntrypoint(logger *server.Logger, cfg *config.Config, reloader Reloader)
 		return nil, err
 	}
 
-	ep.promMetrics, err = metrics.New(prometheus.DefaultRegisterer, cfg.Metrics, logger)
+	ep.limiter = limits.New(prometheus.DefaultRegisterer, cfg.Limits)
+
+	ep.promMetrics, err = metrics.New(prometheus.DefaultRegisterer, cfg.Metrics, logger, ep.limiter)
 	if err != nil {
 		return nil, err
 	}
 
-	ep.lokiLogs, err = logs.New(prometheus.DefaultRegisterer, cfg.Logs, logger)
+	ep.lokiLogs, err = logs.New(prometheus.DefaultRegisterer, cfg.Logs, logger, ep.limiter)
 	if err != nil {
 		return nil, err
 	}
 
-	ep.tempoTraces, err = traces.New(ep.lokiLogs, ep.promMetrics.InstanceManager(), prometheus.DefaultRegisterer, cfg.Traces, cfg.Server.LogLevel.Logrus, cfg.Server.LogFormat)
+	ep.tempoTraces, err = traces.New(ep.lokiLogs, ep.promMetrics.InstanceManager(), ep.limiter, prometheus.DefaultRegisterer, cfg.Traces, cfg.Server.LogLevel.Logrus, cfg.Server.LogFormat)
 	if err != nil {
 		return nil, err
 	}
@@ -97,6 +107,11 @@ func NewEntrypoint(logger *server.Logger, cfg *config.Config, reloader Reloader)
 		return nil, err
 	}
 
+	ep.remotePoller, err = cfg.NewRemotePoller(logger, prometheus.DefaultRegisterer)
+	if err != nil {
+		return nil, err
+	}
+
 	ep.wire(ep.srv.HTTP, ep.srv.GRPC)
 
 	// Mostly everything should be up to date except for the server, which hasn't
@@ -151,6 +166,10 @@ func (ep *Entrypoint) ApplyConfig(cfg config.Config) error {
 		failed = true
 	}
 
+	// Limits are updated first so that components don't briefly run with
+	// the old limits.
+	ep.limiter.ApplyConfig(cfg.Limits)
+
 	// Go through each component and update it.
 	if err := ep.promMetrics.ApplyConfig(cfg.Metrics); err != nil {
 		level.Error(ep.log).Log("msg", "failed to update prometheus", "err", err)
@@ -228,6 +247,8 @@ func (ep *Entrypoint) wire(mux *mux.Router, grpc *grpc.Server) {
 	})
 
 	mux.HandleFunc("/-/reload", ep.reloadHandler).Methods("GET", "POST")
+
+	mux.Handle("/-/debug/snapshot", supportbundle.Handler(ep.log, ep.supportBundleSources)).Methods("GET")
 }
 
 func (ep *Entrypoint) reloadHandler(rw http.ResponseWriter, r *http.Request) {
@@ -330,6 +351,16 @@ func (ep *Entrypoint) Start() error {
 		})
 	}
 
+	if ep.remotePoller != nil {
+		pollContext, pollCancel := context.WithCancel(context.Background())
+		g.Add(func() error {
+			ep.remotePoller.Run(pollContext, func(_ []byte) { ep.TriggerReload() })
+			return nil
+		}, func(e error) {
+			pollCancel()
+		})
+	}
+
 	go func() {
 		for range notifier {
 			ep.TriggerReload()
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grafana/agent/pkg/supportbundle"
+	"github.com/grafana/agent/pkg/traces/automaticloggingprocessor"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/common/version"
+	"gopkg.in/yaml.v2"
+)
+
+// supportBundleSources returns the sources of the support bundle served by
+// /-/debug/snapshot.
+func (ep *Entrypoint) supportBundleSources() []supportbundle.Source {
+	ep.mut.Lock()
+	cfg := ep.cfg
+	ep.mut.Unlock()
+
+	return []supportbundle.Source{
+		{Name: "version.txt", Write: func(_ context.Context, w io.Writer) error {
+			_, err := fmt.Fprintln(w, version.Print("agent"))
+			return err
+		}},
+		{Name: "config.yaml", Write: func(_ context.Context, w io.Writer) error {
+			// Secrets are redacted when marshaling, but the config is still only
+			// included when it could be read through /-/config.
+			if !cfg.EnableConfigEndpoints {
+				return fmt.Errorf("config endpoints are disabled, pass -config.enable-read-api to include the config")
+			}
+			return yaml.NewEncoder(w).Encode(cfg)
+		}},
+		supportbundle.JSON("components.json", ep.componentGraph),
+		supportbundle.JSON("integrations.json", ep.integrationsHealth),
+		supportbundle.WAL("wal.json", ep.walDirectories),
+		{Name: "logs.txt", Write: func(_ context.Context, w io.Writer) error {
+			return ep.log.WriteRecentLogs(w)
+		}},
+		supportbundle.Metrics("metrics.txt", prometheus.DefaultGatherer),
+		supportbundle.Profile("goroutine.txt", "goroutine", 2),
+		supportbundle.Profile("heap.pb.gz", "heap", 0),
+	}
+}
+
+// bundleComponent is a node of the component graph of a support bundle.
+type bundleComponent struct {
+	ID        string   `json:"id"`
+	DependsOn []string `json:"depends_on,omitempty"`
+}
+
+// componentGraph returns the running metrics instances and the logs and
+// traces configs, along with the components they send data to.
+func (ep *Entrypoint) componentGraph() (interface{}, error) {
+	ep.mut.Lock()
+	cfg := ep.cfg
+	ep.mut.Unlock()
+
+	var components []bundleComponent
+
+	for name := range ep.promMetrics.InstanceManager().ListConfigs() {
+		components = append(components, bundleComponent{ID: "metrics/" + name})
+	}
+
+	if cfg.Logs != nil {
+		for _, c := range cfg.Logs.Configs {
+			components = append(components, bundleComponent{ID: "logs/" + c.Name})
+		}
+	}
+
+	for _, c := range cfg.Traces.Configs {
+		comp := bundleComponent{ID: "traces/" + c.Name}
+		if c.SpanMetrics != nil && c.SpanMetrics.MetricsInstance != "" {
+			comp.DependsOn = append(comp.DependsOn, "metrics/"+c.SpanMetrics.MetricsInstance)
+		}
+		if c.ServiceGraphs != nil && c.ServiceGraphs.MetricsInstance != "" {
+			comp.DependsOn = append(comp.DependsOn, "metrics/"+c.ServiceGraphs.MetricsInstance)
+		}
+		if c.AutomaticLogging != nil && c.AutomaticLogging.Backend == automaticloggingprocessor.BackendLogs {
+			comp.DependsOn = append(comp.DependsOn, "logs/"+c.AutomaticLogging.LogsName)
+		}
+		components = append(components, comp)
+	}
+
+	sort.Slice(components, func(i, j int) bool {
+		return components[i].ID < components[j].ID
+	})
+	return components, nil
+}
+
+// bundleIntegration describes the health of an integration in a support
+// bundle.
+type bundleIntegration struct {
+	Job        string    `json:"job"`
+	Instance   string    `json:"instance"`
+	State      string    `json:"state"`
+	LastScrape time.Time `json:"last_scrape"`
+	LastError  string    `json:"last_error,omitempty"`
+}
+
+// integrationsHealth returns the health of the scrape targets of
+// integrations, which report whether integrations are running and able to
+// collect metrics.
+func (ep *Entrypoint) integrationsHealth() (interface{}, error) {
+	var integrations []bundleIntegration
+
+	for _, inst := range ep.promMetrics.InstanceManager().ListInstances() {
+		for _, tgts := range inst.TargetsActive() {
+			for _, tgt := range tgts {
+				job := tgt.Labels().Get(model.JobLabel)
+				if !strings.HasPrefix(job, "integrations/") {
+					continue
+				}
+
+				var lastError string
+				if err := tgt.LastError(); err != nil {
+					lastError = err.Error()
+				}
+				integrations = append(integrations, bundleIntegration{
+					Job:        job,
+					Instance:   tgt.Labels().Get(model.InstanceLabel),
+					State:      string(tgt.Health()),
+					LastScrape: tgt.LastScrape(),
+					LastError:  lastError,
+				})
+			}
+		}
+	}
+
+	sort.Slice(integrations, func(i, j int) bool {
+		if integrations[i].Job != integrations[j].Job {
+			return integrations[i].Job < integrations[j].Job
+		}
+		return integrations[i].Instance < integrations[j].Instance
+	})
+	return integrations, nil
+}
+
+// walDirectories returns the WAL directories of metrics instances and logs
+// configs.
+func (ep *Entrypoint) walDirectories() map[string]string {
+	ep.mut.Lock()
+	cfg := ep.cfg
+	ep.mut.Unlock()
+
+	dirs := make(map[string]string)
+	for name, inst := range ep.promMetrics.InstanceManager().ListInstances() {
+		if dir := inst.StorageDirectory(); dir != "" {
+			dirs["metrics/"+name] = dir
+		}
+	}
+	if cfg.Logs != nil {
+		for _, c := range cfg.Logs.Configs {
+			if c.WAL.Enabled {
+				dirs["logs/"+c.Name] = c.WAL.Dir
+			}
+		}
+	}
+	return dirs
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	goruntime "runtime"
 	"sort"
@@ -16,15 +17,21 @@ import (
 
 	"github.com/grafana/agent/pkg/client/grafanacloud"
 	"github.com/grafana/agent/pkg/config"
+	"github.com/grafana/agent/pkg/logs"
 	"github.com/olekukonko/tablewriter"
+	"github.com/prometheus/common/model"
 	"github.com/prometheus/common/version"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/grafana/agent/pkg/agentctl"
 	"github.com/grafana/agent/pkg/client"
+	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
 	"github.com/spf13/cobra"
 
+	// Register Flow components
+	_ "github.com/grafana/agent/component/all"
+
 	// Register Prometheus SD components
 	_ "github.com/prometheus/prometheus/discovery/install"
 
@@ -60,12 +67,14 @@ func main() {
 	cmd.AddCommand(
 		configSyncCmd(),
 		configCheckCmd(),
+		configDiffCmd(),
 		walStatsCmd(),
 		targetStatsCmd(),
 		samplesCmd(),
 		operatorDetachCmd(),
 		cloudConfigCmd(),
 		templateDryRunCmd(),
+		logsBackfillCmd(),
 	)
 
 	_ = cmd.Execute()
@@ -146,6 +155,34 @@ the exit code will be 1.`,
 	return cmd
 }
 
+func configDiffCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config-diff [old Flow config file] [new Flow config file]",
+		Short: "Show the components changed between two Grafana Agent Flow config files",
+		Long: `config-diff evaluates both Grafana Agent Flow config files and reports the
+components which were added, removed, or whose evaluated arguments changed.
+Arguments referencing the exports of other components are compared by the
+values they evaluate to, so changes to a component are also reported for the
+components which depend on it. Secrets are masked.
+
+Components are run briefly while the files are evaluated. If either file fails
+to evaluate the exit code will be 1.`,
+		Args: cobra.ExactArgs(2),
+		Run: func(_ *cobra.Command, args []string) {
+			changed, err := agentctl.FlowConfigDiff(os.Stdout, args[0], args[1])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to diff configs: %s\n", err)
+				os.Exit(1)
+			}
+			if !changed {
+				fmt.Fprintln(os.Stdout, "no changes")
+			}
+		},
+	}
+
+	return cmd
+}
+
 func samplesCmd() *cobra.Command {
 	var selector string
 
@@ -517,6 +554,133 @@ func templateDryRunCmd() *cobra.Command {
 	return cmd
 }
 
+func logsBackfillCmd() *cobra.Command {
+	var (
+		expandEnv    bool
+		instanceName string
+		jobName      string
+		labels       map[string]string
+		rateLimit    float64
+		burst        int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "logs-backfill [config file] [log file]",
+		Short: "Send a historical log file to Loki",
+		Long: `logs-backfill reads a log file which predates the Agent's deployment and sends
+it to Loki using the clients of a logs instance from the given Agent
+configuration file.
+
+Each line is processed by the pipeline_stages of a scrape config of the
+instance, and labeled with the labels of its static_configs. Entries are sent
+with the timestamps extracted by the pipeline, so the pipeline should include
+a timestamp stage. Lines left without a timestamp by the pipeline inherit the
+timestamp of the previous line, and lines before the first timestamp are
+skipped.
+
+Entries are sent at most at the given rate. Loki must accept entries as old as
+those in the file; check its reject_old_samples_max_age limit.
+
+Examples:
+
+Backfill a file using the "varlogs" job of the "default" logs instance:
+
+$ agentctl logs-backfill -i default -j varlogs agent.yaml /var/log/old.log
+`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(_ *cobra.Command, args []string) error {
+			var cfg config.Config
+			if err := config.LoadFile(args[0], expandEnv, &cfg); err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			inst, sc, err := findBackfillConfig(cfg.Logs, instanceName, jobName)
+			if err != nil {
+				return err
+			}
+
+			f, err := os.Open(args[1])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			extraLabels := make(model.LabelSet, len(labels))
+			for name, value := range labels {
+				extraLabels[model.LabelName(name)] = model.LabelValue(value)
+			}
+
+			ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer cancel()
+
+			logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+			stats, err := agentctl.Backfill(ctx, f, args[1], agentctl.BackfillOptions{
+				Clients:      inst.ClientConfigs,
+				ScrapeConfig: sc,
+				Labels:       extraLabels,
+				Rate:         rateLimit,
+				Burst:        burst,
+				Logger:       logger,
+			})
+
+			fmt.Printf("Lines read:          %d\n", stats.Read)
+			fmt.Printf("Entries sent:        %d\n", stats.Sent)
+			fmt.Printf("Dropped by pipeline: %d\n", stats.Dropped)
+			fmt.Printf("Skipped (no time):   %d\n", stats.Skipped)
+			return err
+		},
+	}
+
+	cmd.Flags().BoolVarP(&expandEnv, "expand-env", "e", false, "expands ${var} in config according to the values of the environment variables")
+	cmd.Flags().StringVarP(&instanceName, "instance", "i", "", "name of the logs instance to use. May be omitted if there is only one instance")
+	cmd.Flags().StringVarP(&jobName, "job", "j", "", "job_name of the scrape config to use. May be omitted if the instance has only one scrape config")
+	cmd.Flags().StringToStringVarP(&labels, "label", "l", nil, "additional labels to add to entries, in the form name=value")
+	cmd.Flags().Float64Var(&rateLimit, "rate", 1000, "maximum number of entries to send per second")
+	cmd.Flags().IntVar(&burst, "burst", 1000, "maximum number of entries to send at once")
+	return cmd
+}
+
+// findBackfillConfig returns the logs instance and scrape config to use for
+// logs-backfill. Names may be empty if there is only one choice.
+func findBackfillConfig(cfg *logs.Config, instanceName, jobName string) (*logs.InstanceConfig, scrapeconfig.Config, error) {
+	if cfg == nil || len(cfg.Configs) == 0 {
+		return nil, scrapeconfig.Config{}, fmt.Errorf("no logs instances are configured")
+	}
+
+	var inst *logs.InstanceConfig
+	switch {
+	case instanceName != "":
+		for _, ic := range cfg.Configs {
+			if ic.Name == instanceName {
+				inst = ic
+			}
+		}
+		if inst == nil {
+			return nil, scrapeconfig.Config{}, fmt.Errorf("logs instance %q not found", instanceName)
+		}
+	case len(cfg.Configs) == 1:
+		inst = cfg.Configs[0]
+	default:
+		return nil, scrapeconfig.Config{}, fmt.Errorf("multiple logs instances are configured, one must be chosen with --instance")
+	}
+
+	switch {
+	case jobName != "":
+		for _, sc := range inst.ScrapeConfig {
+			if sc.JobName == jobName {
+				return inst, sc, nil
+			}
+		}
+		return nil, scrapeconfig.Config{}, fmt.Errorf("scrape config %q not found in logs instance %q", jobName, inst.Name)
+	case len(inst.ScrapeConfig) == 1:
+		return inst, inst.ScrapeConfig[0], nil
+	case len(inst.ScrapeConfig) == 0:
+		// Backfill without a pipeline or labels.
+		return inst, scrapeconfig.Config{}, nil
+	default:
+		return nil, scrapeconfig.Config{}, fmt.Errorf("logs instance %q has multiple scrape configs, one must be chosen with --job", inst.Name)
+	}
+}
+
 func must(err error) {
 	if err != nil {
 		panic(err)
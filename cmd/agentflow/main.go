@@ -9,19 +9,39 @@ import (
 	_ "net/http/pprof" // anonymous import to get the pprof handler registered
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
 	"sync"
+	"time"
 
+	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/gorilla/mux"
+	"github.com/grafana/agent/component/module/process"
+	"github.com/grafana/agent/pkg/cluster"
+	"github.com/grafana/agent/pkg/config/remote"
 	"github.com/grafana/agent/pkg/flow"
 	"github.com/grafana/agent/pkg/flow/logging"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rfratto/ckit/peer"
+	"google.golang.org/grpc"
 
 	// Install components
 	_ "github.com/grafana/agent/component/all"
 )
 
 func main() {
+	// The agent binary is re-executed to run the worker processes of
+	// module.process components.
+	if len(os.Args) > 1 && os.Args[1] == process.WorkerCommand {
+		if err := process.RunWorker(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	if err := run(); err != nil {
 		fmt.Fprintf(os.Stderr, "error: %s\n", err)
 		os.Exit(1)
@@ -39,12 +59,34 @@ func run() error {
 		httpListenAddr = "127.0.0.1:12345"
 		configFile     string
 		storagePath    = "data-agent/"
+		validateOnly   bool
+
+		restartBackoff = flow.DefaultComponentRestartBackoff
+
+		clusterEnabled    bool
+		clusterListenAddr = "0.0.0.0:12346"
+		clusterConfig     = cluster.DefaultGossipConfig
+
+		remoteConfig = remoteConfigFlags{Options: remote.DefaultOptions}
 	)
 
 	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
 	fs.StringVar(&httpListenAddr, "server.http-listen-addr", httpListenAddr, "address to listen for http traffic on")
-	fs.StringVar(&configFile, "config.file", configFile, "path to config file to load")
+	fs.StringVar(&configFile, "config.file", configFile, "path or http(s) URL of config file to load")
+	fs.DurationVar(&remoteConfig.Options.PollFrequency, "config.remote.poll-frequency", remoteConfig.Options.PollFrequency, "how often to check a config file loaded from a URL for changes")
+	fs.StringVar(&remoteConfig.PublicKeyFile, "config.remote.public-key-file", remoteConfig.PublicKeyFile, "path to a PEM-encoded ed25519 public key which config files loaded from a URL must be signed with")
+	fs.StringVar(&remoteConfig.BearerTokenFile, "config.remote.bearer-token-file", remoteConfig.BearerTokenFile, "path to a file holding the bearer token used to fetch config files from a URL")
 	fs.StringVar(&storagePath, "storage.path", storagePath, "Base directory where Flow components can store data")
+	fs.BoolVar(&validateOnly, "config.validate", validateOnly, "validate the config file, including its assert blocks, and exit")
+	fs.DurationVar(&restartBackoff.MinBackoff, "component.restart-min-backoff", restartBackoff.MinBackoff, "initial delay before restarting a component which exited with an error")
+	fs.DurationVar(&restartBackoff.MaxBackoff, "component.restart-max-backoff", restartBackoff.MaxBackoff, "maximum delay before restarting a component which exited with an error")
+	fs.IntVar(&restartBackoff.MaxRetries, "component.max-restarts", restartBackoff.MaxRetries, "maximum number of consecutive restarts of a component; 0 restarts forever and a negative value disables restarts")
+	fs.BoolVar(&clusterEnabled, "cluster.enabled", clusterEnabled, "join a cluster of agents which components can share work with")
+	fs.StringVar(&clusterListenAddr, "cluster.listen-addr", clusterListenAddr, "address to listen for gossip traffic from other agents of the cluster on")
+	fs.StringVar(&clusterConfig.NodeName, "cluster.node-name", clusterConfig.NodeName, "name of the agent within the cluster; must be unique and defaults to the hostname")
+	fs.StringVar(&clusterConfig.AdvertiseAddr, "cluster.advertise-addr", clusterConfig.AdvertiseAddr, "address other agents use to connect to this agent; inferred from the network interfaces if unset")
+	fs.Var(&clusterConfig.JoinPeers, "cluster.join-peer", "address of an agent to join when starting; may be given more than once")
+	fs.StringVar(&clusterConfig.DiscoverPeers, "cluster.discover-peers", clusterConfig.DiscoverPeers, "go-discover expression used to find agents to join; mutually exclusive with -cluster.join-peer")
 
 	if err := fs.Parse(os.Args[1:]); err != nil {
 		return fmt.Errorf("error parsing flags: %w", err)
@@ -60,15 +102,67 @@ func run() error {
 		return fmt.Errorf("building logger: %w", err)
 	}
 
-	f := flow.New(flow.Options{
-		Logger:   l,
-		DataPath: storagePath,
-	})
+	readConfig := func() (*flow.File, error) {
+		flowCfg, err := loadFlowFile(configFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading config file %q: %w", configFile, err)
+		}
+		return flowCfg, nil
+	}
+
+	var poller *remote.Poller
+	if isRemoteConfig(configFile) {
+		if !validateOnly {
+			remoteConfig.Options.CachePath = filepath.Join(storagePath, remoteConfigCacheFilename)
+			remoteConfig.Options.Registerer = prometheus.DefaultRegisterer
+		}
+		poller, err = newConfigPoller(l, configFile, remoteConfig)
+		if err != nil {
+			return err
+		}
+
+		readConfig = func() (*flow.File, error) {
+			bb, _, err := poller.Fetch(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("fetching config file %q: %w", configFile, err)
+			}
+			flowCfg, err := parseFlowFile(configFile, bb)
+			if err != nil {
+				return nil, fmt.Errorf("reading config file %q: %w", configFile, err)
+			}
+			return flowCfg, nil
+		}
+	}
+
+	if validateOnly {
+		return validate(l, configFile, readConfig)
+	}
+
+	var clusterNode *cluster.GossipNode
+	if clusterEnabled {
+		node, stop, err := startCluster(ctx, l, clusterListenAddr, &clusterConfig)
+		if err != nil {
+			return err
+		}
+		defer stop()
+		clusterNode = node
+	}
+
+	opts := flow.Options{
+		Logger:                  l,
+		DataPath:                storagePath,
+		Reg:                     prometheus.DefaultRegisterer,
+		ComponentRestartBackoff: restartBackoff,
+	}
+	if clusterNode != nil {
+		opts.Cluster = clusterNode
+	}
+	f := flow.New(opts)
 
 	reload := func() error {
-		flowCfg, err := loadFlowFile(configFile)
+		flowCfg, err := readConfig()
 		if err != nil {
-			return fmt.Errorf("reading config file %q: %w", configFile, err)
+			return err
 		}
 		if err := f.LoadFile(flowCfg); err != nil {
 			return fmt.Errorf("error during the initial gragent load: %w", err)
@@ -81,6 +175,18 @@ func run() error {
 		return err
 	}
 
+	if poller != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			poller.Run(ctx, func(_ []byte) {
+				if err := reload(); err != nil {
+					level.Error(l).Log("msg", "failed to apply remote config file", "err", err)
+				}
+			})
+		}()
+	}
+
 	// HTTP server
 	{
 		lis, err := net.Listen("tcp", httpListenAddr)
@@ -90,11 +196,22 @@ func run() error {
 
 		r := mux.NewRouter()
 		r.Handle("/-/config", f.ConfigHandler())
+		r.Handle("/-/config/last-failure", f.LoadFailureHandler())
 		r.Handle("/metrics", promhttp.Handler())
 		r.Handle("/debug/graph", f.GraphHandler())
+		r.PathPrefix("/component/").Handler(f.ComponentHandler())
+		r.PathPrefix("/-/components").Handler(f.ComponentInfoHandler("/-/components"))
+		r.Handle("/-/ui", f.UIHandler())
+		r.PathPrefix("/api/v0/component/").Handler(f.DebugStreamHandler("/api/v0/component/"))
 		r.PathPrefix("/debug/pprof").Handler(http.DefaultServeMux)
 
-		r.HandleFunc("/-/reload", func(w http.ResponseWriter, _ *http.Request) {
+		dryRun := f.DryRunHandler(readConfig)
+		r.HandleFunc("/-/reload", func(w http.ResponseWriter, r *http.Request) {
+			if ok, _ := strconv.ParseBool(r.URL.Query().Get("dry_run")); ok {
+				dryRun(w, r)
+				return
+			}
+
 			err := reload()
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusBadRequest)
@@ -120,15 +237,107 @@ func run() error {
 	}
 
 	<-ctx.Done()
+	if clusterNode != nil {
+		// Give other agents a chance to take over work from components before
+		// they're shut down.
+		leaveCtx, leaveCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := clusterNode.ChangeState(leaveCtx, peer.StateTerminating); err != nil {
+			level.Warn(l).Log("msg", "failed to mark agent as terminating in the cluster", "err", err)
+		}
+		leaveCancel()
+	}
 	return f.Close()
 }
 
+// startCluster joins the cluster configured by cfg, serving gossip traffic on
+// listenAddr. The returned function leaves the cluster.
+func startCluster(ctx context.Context, l *logging.Logger, listenAddr string, cfg *cluster.GossipConfig) (*cluster.GossipNode, func(), error) {
+	lis, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to listen on %s: %w", listenAddr, err)
+	}
+	_, portStr, err := net.SplitHostPort(lis.Addr().String())
+	if err != nil {
+		return nil, nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := cfg.ApplyDefaults(port); err != nil {
+		return nil, nil, fmt.Errorf("invalid cluster config: %w", err)
+	}
+
+	srv := grpc.NewServer()
+	node, err := cluster.NewGossipNode(log.With(l, "subsystem", "cluster"), srv, cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create cluster node: %w", err)
+	}
+	go func() {
+		level.Info(l).Log("msg", "now listening for cluster traffic", "addr", lis.Addr())
+		if err := srv.Serve(lis); err != nil {
+			level.Info(l).Log("msg", "cluster server closed", "err", err)
+		}
+	}()
+
+	if err := node.Start(); err != nil {
+		srv.Stop()
+		return nil, nil, fmt.Errorf("failed to join cluster: %w", err)
+	}
+	stop := func() {
+		if err := node.Stop(); err != nil {
+			level.Warn(l).Log("msg", "failed to leave cluster", "err", err)
+		}
+		srv.Stop()
+	}
+
+	// Agents only become owners of work once they participate in the
+	// cluster.
+	if err := node.ChangeState(ctx, peer.StateParticipant); err != nil {
+		stop()
+		return nil, nil, fmt.Errorf("failed to participate in cluster: %w", err)
+	}
+	level.Info(l).Log("msg", "joined cluster", "node", cfg.NodeName, "advertise_addr", cfg.AdvertiseAddr, "peers", len(node.Peers()))
+	return node, stop, nil
+}
+
+// validate loads the config file into a temporary controller to check that
+// all components evaluate and all assert blocks pass.
+func validate(l *logging.Logger, configFile string, readConfig func() (*flow.File, error)) error {
+	flowCfg, err := readConfig()
+	if err != nil {
+		return err
+	}
+
+	dataPath, err := os.MkdirTemp("", "agentflow-validate")
+	if err != nil {
+		return fmt.Errorf("creating temporary storage path: %w", err)
+	}
+	defer os.RemoveAll(dataPath)
+
+	f := flow.New(flow.Options{
+		Logger:   l,
+		DataPath: dataPath,
+	})
+	defer f.Close()
+
+	if err := f.LoadFile(flowCfg); err != nil {
+		return fmt.Errorf("config file %q is invalid: %w", configFile, err)
+	}
+	fmt.Fprintf(os.Stdout, "config file %q is valid\n", configFile)
+	return nil
+}
+
 func loadFlowFile(filename string) (*flow.File, error) {
 	bb, err := os.ReadFile(filename)
 	if err != nil {
 		return nil, err
 	}
+	return parseFlowFile(filename, bb)
+}
 
+func parseFlowFile(filename string, bb []byte) (*flow.File, error) {
 	f, diags := flow.ReadFile(filename, bb)
 	if !diags.HasErrors() {
 		return f, nil
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/pkg/config/remote"
+)
+
+// remoteConfigCacheFilename is the name of the file in the storage path which
+// caches the most recently fetched remote config file. It can't conflict with
+// the data directories of components, which are named after component IDs.
+const remoteConfigCacheFilename = "remote-config.cache"
+
+// remoteConfigFlags holds the flags used to poll a config file over HTTP.
+type remoteConfigFlags struct {
+	Options         remote.Options
+	PublicKeyFile   string
+	BearerTokenFile string
+}
+
+// isRemoteConfig returns true if path is an http or https URL.
+func isRemoteConfig(path string) bool {
+	u, err := url.Parse(path)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https")
+}
+
+// newConfigPoller creates a poller for the config file at rawURL.
+func newConfigPoller(l log.Logger, rawURL string, f remoteConfigFlags) (*remote.Poller, error) {
+	opts := f.Options
+	opts.URL = rawURL
+	opts.Logger = log.With(l, "subsystem", "remote_config")
+	opts.HTTPClientConfig.BearerTokenFile = f.BearerTokenFile
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current working directory: %w", err)
+	}
+	opts.HTTPClientConfig.SetDirectory(wd)
+
+	if f.PublicKeyFile != "" {
+		opts.PublicKey, err = remote.LoadPublicKey(f.PublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading config public key: %w", err)
+		}
+	}
+	return remote.New(opts)
+}
@@ -2,6 +2,26 @@
 package all
 
 import (
-	_ "github.com/grafana/agent/component/local/file"     // Import local.file
-	_ "github.com/grafana/agent/component/targets/mutate" // Import targets.mutate
+	_ "github.com/grafana/agent/component/discovery/kubernetes"           // Import discovery.kubernetes
+	_ "github.com/grafana/agent/component/faro/receiver"                  // Import faro.receiver
+	_ "github.com/grafana/agent/component/local/directory"                // Import local.directory
+	_ "github.com/grafana/agent/component/local/file"                     // Import local.file
+	_ "github.com/grafana/agent/component/local/filewrite"                // Import local.file_write
+	_ "github.com/grafana/agent/component/loki/encrypt"                   // Import loki.encrypt
+	_ "github.com/grafana/agent/component/loki/sourcefile"                // Import loki.source_file
+	_ "github.com/grafana/agent/component/loki/write"                     // Import loki.write
+	_ "github.com/grafana/agent/component/module/file"                    // Import module.file
+	_ "github.com/grafana/agent/component/module/foreach"                 // Import module.foreach
+	_ "github.com/grafana/agent/component/module/git"                     // Import module.git
+	_ "github.com/grafana/agent/component/module/process"                 // Import module.process
+	_ "github.com/grafana/agent/component/otelcol/exporter/loadbalancing" // Import otelcol.exporter_loadbalancing
+	_ "github.com/grafana/agent/component/otelcol/exporter/otlp"          // Import otelcol.exporter_otlp
+	_ "github.com/grafana/agent/component/otelcol/receiver/jaeger"        // Import otelcol.receiver_jaeger
+	_ "github.com/grafana/agent/component/otelcol/receiver/otlp"          // Import otelcol.receiver_otlp
+	_ "github.com/grafana/agent/component/prometheus/relabel"             // Import prometheus.relabel
+	_ "github.com/grafana/agent/component/prometheus/remotewrite"         // Import prometheus.remote_write
+	_ "github.com/grafana/agent/component/prometheus/scrape"              // Import prometheus.scrape
+	_ "github.com/grafana/agent/component/remote/http"                    // Import remote.http
+	_ "github.com/grafana/agent/component/targets/mutate"                 // Import targets.mutate
+	_ "github.com/grafana/agent/component/text/template"                  // Import text.template
 )
@@ -0,0 +1,143 @@
+// Package config contains types from github.com/prometheus/common/config,
+// but modified to be encoded as HCL.
+package config
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/grafana/agent/pkg/flow/hcltypes"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/prometheus/common/config"
+	"github.com/rfratto/gohcl"
+)
+
+// HTTPClientConfig mirrors config.HTTPClientConfig.
+type HTTPClientConfig struct {
+	BasicAuth       *BasicAuth      `hcl:"basic_auth,block"`
+	Authorization   *Authorization  `hcl:"authorization,block"`
+	BearerToken     hcltypes.Secret `hcl:"bearer_token,optional"`
+	BearerTokenFile string          `hcl:"bearer_token_file,optional"`
+	ProxyURL        string          `hcl:"proxy_url,optional"`
+	TLSConfig       *TLSConfig      `hcl:"tls_config,block"`
+	FollowRedirects bool            `hcl:"follow_redirects,optional"`
+	EnableHTTP2     bool            `hcl:"enable_http2,optional"`
+}
+
+// DefaultHTTPClientConfig holds the default settings for HTTPClientConfig.
+var DefaultHTTPClientConfig = HTTPClientConfig{
+	FollowRedirects: true,
+	EnableHTTP2:     true,
+}
+
+var _ gohcl.Decoder = (*HTTPClientConfig)(nil)
+
+// DecodeHCL implements gohcl.Decoder.
+func (h *HTTPClientConfig) DecodeHCL(body hcl.Body, ctx *hcl.EvalContext) error {
+	*h = DefaultHTTPClientConfig
+
+	type httpClientConfig HTTPClientConfig
+	if err := gohcl.DecodeBody(body, ctx, (*httpClientConfig)(h)); err != nil {
+		return err
+	}
+	return h.Validate()
+}
+
+// Validate returns an error if h is invalid.
+func (h *HTTPClientConfig) Validate() error {
+	cfg, err := h.Convert()
+	if err != nil {
+		return err
+	}
+	return cfg.Validate()
+}
+
+// Convert converts HTTPClientConfig to the native Prometheus type. An error is
+// returned if the proxy URL cannot be parsed.
+func (h *HTTPClientConfig) Convert() (*config.HTTPClientConfig, error) {
+	if h == nil {
+		cfg := config.DefaultHTTPClientConfig
+		return &cfg, nil
+	}
+
+	var proxyURL config.URL
+	if h.ProxyURL != "" {
+		u, err := url.Parse(h.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy_url: %w", err)
+		}
+		proxyURL.URL = u
+	}
+
+	return &config.HTTPClientConfig{
+		BasicAuth:       h.BasicAuth.Convert(),
+		Authorization:   h.Authorization.Convert(),
+		BearerToken:     config.Secret(h.BearerToken),
+		BearerTokenFile: h.BearerTokenFile,
+		ProxyURL:        proxyURL,
+		TLSConfig:       *h.TLSConfig.Convert(),
+		FollowRedirects: h.FollowRedirects,
+		EnableHTTP2:     h.EnableHTTP2,
+	}, nil
+}
+
+// BasicAuth configures Basic HTTP authentication credentials.
+type BasicAuth struct {
+	Username     string          `hcl:"username,optional"`
+	Password     hcltypes.Secret `hcl:"password,optional"`
+	PasswordFile string          `hcl:"password_file,optional"`
+}
+
+// Convert converts our type to the native prometheus type.
+func (b *BasicAuth) Convert() *config.BasicAuth {
+	if b == nil {
+		return nil
+	}
+	return &config.BasicAuth{
+		Username:     b.Username,
+		Password:     config.Secret(b.Password),
+		PasswordFile: b.PasswordFile,
+	}
+}
+
+// Authorization sets up HTTP authorization credentials.
+type Authorization struct {
+	Type            string          `hcl:"type,optional"`
+	Credentials     hcltypes.Secret `hcl:"credentials,optional"`
+	CredentialsFile string          `hcl:"credentials_file,optional"`
+}
+
+// Convert converts our type to the native prometheus type.
+func (a *Authorization) Convert() *config.Authorization {
+	if a == nil {
+		return nil
+	}
+	return &config.Authorization{
+		Type:            a.Type,
+		Credentials:     config.Secret(a.Credentials),
+		CredentialsFile: a.CredentialsFile,
+	}
+}
+
+// TLSConfig sets up options for TLS connections.
+type TLSConfig struct {
+	CAFile             string `hcl:"ca_file,optional"`
+	CertFile           string `hcl:"cert_file,optional"`
+	KeyFile            string `hcl:"key_file,optional"`
+	ServerName         string `hcl:"server_name,optional"`
+	InsecureSkipVerify bool   `hcl:"insecure_skip_verify,optional"`
+}
+
+// Convert converts our type to the native prometheus type.
+func (t *TLSConfig) Convert() *config.TLSConfig {
+	if t == nil {
+		return &config.TLSConfig{}
+	}
+	return &config.TLSConfig{
+		CAFile:             t.CAFile,
+		CertFile:           t.CertFile,
+		KeyFile:            t.KeyFile,
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+}
@@ -30,6 +30,8 @@
 //     * debug
 //
 // Default values for Arguments may be provided by implementing gohcl.Decoder.
+// Arguments may be checked before they're passed to a component by setting
+// Validate in the component's Registration.
 //
 // Mapping HCL strings to custom types
 //
@@ -51,7 +53,10 @@
 // creating a new one.
 package component
 
-import "context"
+import (
+	"context"
+	"net/http"
+)
 
 // The Arguments contains the input fields for a specific component, which is
 // unmarshaled from HCL.
@@ -104,3 +109,20 @@ type DebugComponent interface {
 	// DebugInfo must be safe for calling concurrently.
 	DebugInfo() interface{}
 }
+
+// HTTPComponent is an extension interface for components which serve their
+// own HTTP endpoints. The handler is exposed on the HTTP server of the
+// process under /component/<component id>/.
+type HTTPComponent interface {
+	Component
+
+	// Handler returns the HTTP handler for the component. Requests are passed
+	// to the handler with the /component/<component id> prefix trimmed from
+	// their path, so the handler should route requests as if it was mounted at
+	// the root. Use Options.HTTPPath to build absolute links to the
+	// component's endpoints.
+	//
+	// Handler is invoked for every request and must be safe for calling
+	// concurrently.
+	Handler() http.Handler
+}
@@ -0,0 +1,42 @@
+package component
+
+import "time"
+
+// DebugStream publishes live debug events of a component, such as reloads of
+// a watched file, to clients watching the component's debug stream. Unlike
+// DebugInfo, which reports a snapshot of a component's state, events describe
+// what a component is doing as it happens.
+//
+// Components opt into the debug stream by publishing events through
+// Options.DebugStream. Events are only delivered to clients which are
+// watching the stream when they're published.
+//
+// All methods of DebugStream are safe for calling concurrently.
+type DebugStream interface {
+	// Active returns true if any client is watching the stream. Components may
+	// use Active to avoid building events which are expensive to create.
+	Active() bool
+
+	// Publish sends e to every client watching the stream. Publish never
+	// blocks; events are dropped for clients which can't keep up. The Time of
+	// e is set to the current time if it's zero.
+	Publish(e DebugEvent)
+}
+
+// DebugEvent is a single event published to a DebugStream. DebugEvents are
+// encoded as JSON when sent to clients.
+type DebugEvent struct {
+	// Time the event happened.
+	Time time.Time `json:"time"`
+
+	// Type is a short, component-specific name for the kind of event, such as
+	// "read".
+	Type string `json:"type"`
+
+	// Message is a human-readable description of the event.
+	Message string `json:"message,omitempty"`
+
+	// Data optionally holds details about the event. Data must be encodable
+	// as JSON and must not hold secrets.
+	Data interface{} `json:"data,omitempty"`
+}
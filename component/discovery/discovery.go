@@ -0,0 +1,202 @@
+// Package discovery implements shared types and behavior for service
+// discovery components.
+package discovery
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/grafana/agent/component"
+	"github.com/prometheus/prometheus/discovery"
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+)
+
+// Target refers to a singular discovered endpoint, such as an HTTP or HTTPS
+// endpoint which can be scraped. Here, we're using a map[string]string
+// instead of labels.Labels; if the label ordering is important, we can change
+// to follow the upstream logic instead.
+type Target map[string]string
+
+// Exports holds values which are exported by all discovery components.
+type Exports struct {
+	Targets []Target `hcl:"targets,attr"`
+}
+
+// Discoverer is an alias for the Prometheus Discoverer interface, so
+// implementations don't need to import the Prometheus discovery package.
+type Discoverer = discovery.Discoverer
+
+// Creator creates a Discoverer from the arguments of a discovery component.
+type Creator func(component.Arguments) (Discoverer, error)
+
+// maxUpdateFrequency is the minimum time between exports of discovered
+// targets. Discoverers can send updates much more frequently, and exporting
+// each of them would cause the components consuming the targets to be
+// re-evaluated for every update.
+var maxUpdateFrequency = 5 * time.Second
+
+// Component is a generic discovery component which runs a Discoverer and
+// exports the targets it discovers. Discovery components are implemented by
+// wrapping Component with a Creator.
+type Component struct {
+	opts    component.Options
+	creator Creator
+
+	mut           sync.Mutex
+	latest        Discoverer
+	newDiscoverer chan struct{}
+}
+
+var _ component.Component = (*Component)(nil)
+
+// New creates a new discovery Component. The Discoverer is created by calling
+// creator with args.
+func New(o component.Options, args component.Arguments, creator Creator) (*Component, error) {
+	c := &Component{
+		opts:    o,
+		creator: creator,
+		// Buffered so Update can queue a new discoverer without blocking on
+		// Run.
+		newDiscoverer: make(chan struct{}, 1),
+	}
+
+	// Export an empty set of targets so consumers can be evaluated before the
+	// first targets are discovered.
+	o.OnStateChange(Exports{Targets: []Target{}})
+
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	stop := func() {}
+	defer func() { stop() }()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-c.newDiscoverer:
+			c.mut.Lock()
+			disc := c.latest
+			c.mut.Unlock()
+
+			// Stop the previous discoverer before starting the new one so
+			// their exports never interleave.
+			stop()
+			stop = c.startDiscovery(ctx, disc)
+		}
+	}
+}
+
+// startDiscovery runs d in the background. The returned function stops d and
+// waits for it to exit.
+func (c *Component) startDiscovery(ctx context.Context, d Discoverer) (stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		c.runDiscovery(ctx, d)
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	disc, err := c.creator(args)
+	if err != nil {
+		return err
+	}
+
+	c.mut.Lock()
+	c.latest = disc
+	c.mut.Unlock()
+
+	select {
+	case c.newDiscoverer <- struct{}{}:
+	default:
+		// A new discoverer is already queued; Run will pick up the latest one.
+	}
+	return nil
+}
+
+// runDiscovery runs d until ctx is canceled, exporting the discovered targets
+// at most once every maxUpdateFrequency.
+func (c *Component) runDiscovery(ctx context.Context, d Discoverer) {
+	ch := make(chan []*targetgroup.Group)
+
+	discDone := make(chan struct{})
+	go func() {
+		defer close(discDone)
+		d.Run(ctx, ch)
+	}()
+	defer func() { <-discDone }()
+
+	ticker := time.NewTicker(maxUpdateFrequency)
+	defer ticker.Stop()
+
+	var (
+		groups  = map[string]*targetgroup.Group{}
+		changed bool
+	)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if changed {
+				c.opts.OnStateChange(Exports{Targets: groupsToTargets(groups)})
+				changed = false
+			}
+		case update := <-ch:
+			for _, group := range update {
+				if group == nil {
+					continue
+				}
+				if len(group.Targets) == 0 {
+					delete(groups, group.Source)
+				} else {
+					groups[group.Source] = group
+				}
+			}
+			changed = true
+		}
+	}
+}
+
+// groupsToTargets flattens groups into a list of targets, with the labels of
+// a group applied to each of its targets. Targets are sorted by group source
+// so exports stay stable across updates.
+func groupsToTargets(groups map[string]*targetgroup.Group) []Target {
+	sources := make([]string, 0, len(groups))
+	for source := range groups {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	targets := []Target{}
+	for _, source := range sources {
+		group := groups[source]
+		for _, tgt := range group.Targets {
+			t := make(Target, len(group.Labels)+len(tgt))
+			for k, v := range group.Labels {
+				t[string(k)] = string(v)
+			}
+			for k, v := range tgt {
+				t[string(k)] = string(v)
+			}
+			targets = append(targets, t)
+		}
+	}
+	return targets
+}
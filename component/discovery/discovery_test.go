@@ -0,0 +1,100 @@
+package discovery
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComponent(t *testing.T) {
+	maxUpdateFrequency = 10 * time.Millisecond
+
+	exports := make(chan Exports, 10)
+	opts := component.Options{
+		ID:            "discovery.test",
+		Logger:        util.TestLogger(t),
+		OnStateChange: func(e component.Exports) { exports <- e.(Exports) },
+	}
+
+	groups := make(chan []*targetgroup.Group)
+	c, err := New(opts, groups, func(args component.Arguments) (Discoverer, error) {
+		return fakeDiscoverer(args.(chan []*targetgroup.Group)), nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, Exports{Targets: []Target{}}, <-exports)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = c.Run(ctx) }()
+
+	groups <- []*targetgroup.Group{{
+		Source:  "b",
+		Labels:  model.LabelSet{"job": "b"},
+		Targets: []model.LabelSet{{"__address__": "b:80"}},
+	}, {
+		Source: "a",
+		Labels: model.LabelSet{"job": "a"},
+		Targets: []model.LabelSet{
+			{"__address__": "a:80"},
+			{"__address__": "a:81", "job": "override"},
+		},
+	}}
+
+	require.Equal(t, Exports{Targets: []Target{
+		{"__address__": "a:80", "job": "a"},
+		{"__address__": "a:81", "job": "override"},
+		{"__address__": "b:80", "job": "b"},
+	}}, waitExports(t, exports))
+
+	// Groups without targets are removed.
+	groups <- []*targetgroup.Group{{Source: "a"}}
+	require.Equal(t, Exports{Targets: []Target{
+		{"__address__": "b:80", "job": "b"},
+	}}, waitExports(t, exports))
+
+	// Updating the component replaces the discoverer.
+	newGroups := make(chan []*targetgroup.Group)
+	require.NoError(t, c.Update(newGroups))
+	newGroups <- []*targetgroup.Group{{
+		Source:  "c",
+		Targets: []model.LabelSet{{"__address__": "c:80"}},
+	}}
+	require.Equal(t, Exports{Targets: []Target{
+		{"__address__": "c:80"},
+	}}, waitExports(t, exports))
+}
+
+func waitExports(t *testing.T, ch chan Exports) Exports {
+	t.Helper()
+	select {
+	case e := <-ch:
+		return e
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out waiting for exports")
+		return Exports{}
+	}
+}
+
+// fakeDiscoverer forwards groups sent to it until its context is canceled.
+type fakeDiscoverer chan []*targetgroup.Group
+
+func (d fakeDiscoverer) Run(ctx context.Context, up chan<- []*targetgroup.Group) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case groups := <-d:
+			select {
+			case up <- groups:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
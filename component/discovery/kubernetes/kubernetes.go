@@ -0,0 +1,187 @@
+// Package kubernetes implements the discovery.kubernetes component.
+package kubernetes
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/common/config"
+	"github.com/grafana/agent/component/discovery"
+	"github.com/hashicorp/hcl/v2"
+	prom_config "github.com/prometheus/common/config"
+	promk8s "github.com/prometheus/prometheus/discovery/kubernetes"
+	"github.com/rfratto/gohcl"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:    "discovery.kubernetes",
+		Args:    Arguments{},
+		Exports: discovery.Exports{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Arguments configures the discovery.kubernetes component.
+type Arguments struct {
+	// APIServer is the URL of the Kubernetes API server. When unset, the
+	// in-cluster configuration is used.
+	APIServer string `hcl:"api_server,optional"`
+	// Role is the type of Kubernetes resource to discover.
+	Role string `hcl:"role,attr"`
+	// KubeConfig is the path to a kubeconfig file to connect with.
+	KubeConfig string `hcl:"kubeconfig_file,optional"`
+
+	HTTPClientConfig   *config.HTTPClientConfig `hcl:"http_client_config,block"`
+	NamespaceDiscovery *NamespaceDiscovery      `hcl:"namespaces,block"`
+	Selectors          []SelectorConfig         `hcl:"selectors,block"`
+}
+
+// NamespaceDiscovery limits discovery to a set of namespaces.
+type NamespaceDiscovery struct {
+	IncludeOwnNamespace bool     `hcl:"own_namespace,optional"`
+	Names               []string `hcl:"names,optional"`
+}
+
+// SelectorConfig filters the resources of a role with label and field
+// selectors.
+type SelectorConfig struct {
+	Role  string `hcl:"role,attr"`
+	Label string `hcl:"label,optional"`
+	Field string `hcl:"field,optional"`
+}
+
+// selectorRoles holds the roles of selectors which can be used with each
+// discovery role.
+var selectorRoles = map[promk8s.Role][]promk8s.Role{
+	promk8s.RolePod:           {promk8s.RolePod},
+	promk8s.RoleService:       {promk8s.RoleService},
+	promk8s.RoleEndpointSlice: {promk8s.RolePod, promk8s.RoleService, promk8s.RoleEndpointSlice},
+	promk8s.RoleEndpoint:      {promk8s.RolePod, promk8s.RoleService, promk8s.RoleEndpoint},
+	promk8s.RoleNode:          {promk8s.RoleNode},
+	promk8s.RoleIngress:       {promk8s.RoleIngress},
+}
+
+var _ gohcl.Decoder = (*Arguments)(nil)
+
+// DecodeHCL implements gohcl.Decoder.
+func (args *Arguments) DecodeHCL(body hcl.Body, ctx *hcl.EvalContext) error {
+	*args = Arguments{}
+
+	type arguments Arguments
+	if err := gohcl.DecodeBody(body, ctx, (*arguments)(args)); err != nil {
+		return err
+	}
+	return args.Validate()
+}
+
+// Validate returns an error if args is invalid. It mirrors the validation
+// Prometheus performs when loading a kubernetes_sd_config.
+func (args *Arguments) Validate() error {
+	role := promk8s.Role(args.Role)
+	if _, ok := selectorRoles[role]; !ok {
+		return fmt.Errorf("invalid role %q, expected one of pod, service, endpoints, endpointslice, node, or ingress", args.Role)
+	}
+
+	if args.APIServer != "" && args.KubeConfig != "" {
+		return fmt.Errorf("cannot use kubeconfig_file and api_server simultaneously")
+	}
+	if args.HTTPClientConfig != nil {
+		if args.KubeConfig != "" {
+			return fmt.Errorf("cannot use http_client_config together with kubeconfig_file")
+		}
+		if args.APIServer == "" {
+			return fmt.Errorf("to use http_client_config, api_server must be provided")
+		}
+	}
+	if args.NamespaceDiscovery != nil && args.NamespaceDiscovery.IncludeOwnNamespace {
+		if args.APIServer != "" || args.KubeConfig != "" {
+			return fmt.Errorf("namespaces.own_namespace can only be used with the in-cluster configuration")
+		}
+	}
+
+	seen := make(map[string]struct{}, len(args.Selectors))
+	for _, s := range args.Selectors {
+		if _, ok := seen[s.Role]; ok {
+			return fmt.Errorf("duplicated selector role %q", s.Role)
+		}
+		seen[s.Role] = struct{}{}
+
+		var allowed bool
+		for _, r := range selectorRoles[role] {
+			if string(r) == s.Role {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("%s role does not support %q selectors", role, s.Role)
+		}
+
+		if _, err := fields.ParseSelector(s.Field); err != nil {
+			return fmt.Errorf("invalid field selector for role %s: %w", s.Role, err)
+		}
+		if _, err := labels.Parse(s.Label); err != nil {
+			return fmt.Errorf("invalid label selector for role %s: %w", s.Role, err)
+		}
+	}
+
+	return nil
+}
+
+// Convert converts Arguments into the Prometheus Kubernetes SD config.
+func (args *Arguments) Convert() (*promk8s.SDConfig, error) {
+	cfg := &promk8s.SDConfig{
+		Role:             promk8s.Role(args.Role),
+		KubeConfig:       args.KubeConfig,
+		HTTPClientConfig: prom_config.DefaultHTTPClientConfig,
+	}
+
+	if args.APIServer != "" {
+		u, err := url.Parse(args.APIServer)
+		if err != nil {
+			return nil, fmt.Errorf("invalid api_server: %w", err)
+		}
+		cfg.APIServer = prom_config.URL{URL: u}
+	}
+	if args.HTTPClientConfig != nil {
+		httpCfg, err := args.HTTPClientConfig.Convert()
+		if err != nil {
+			return nil, err
+		}
+		cfg.HTTPClientConfig = *httpCfg
+	}
+	if ns := args.NamespaceDiscovery; ns != nil {
+		cfg.NamespaceDiscovery = promk8s.NamespaceDiscovery{
+			IncludeOwnNamespace: ns.IncludeOwnNamespace,
+			Names:               ns.Names,
+		}
+	}
+	for _, s := range args.Selectors {
+		cfg.Selectors = append(cfg.Selectors, promk8s.SelectorConfig{
+			Role:  promk8s.Role(s.Role),
+			Label: s.Label,
+			Field: s.Field,
+		})
+	}
+
+	return cfg, nil
+}
+
+// New creates a new discovery.kubernetes component.
+func New(opts component.Options, args Arguments) (*discovery.Component, error) {
+	return discovery.New(opts, args, func(args component.Arguments) (discovery.Discoverer, error) {
+		newArgs := args.(Arguments)
+		cfg, err := newArgs.Convert()
+		if err != nil {
+			return nil, err
+		}
+		return promk8s.New(opts.Logger, cfg)
+	})
+}
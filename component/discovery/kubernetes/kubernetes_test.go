@@ -0,0 +1,116 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hclparse"
+	promk8s "github.com/prometheus/prometheus/discovery/kubernetes"
+	"github.com/rfratto/gohcl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArguments(t *testing.T) {
+	args, err := decodeArguments(`
+		api_server = "https://kubernetes.example.com"
+		role       = "endpoints"
+
+		http_client_config {
+			bearer_token = "token"
+		}
+
+		namespaces {
+			names = ["default", "monitoring"]
+		}
+
+		selectors {
+			role  = "pod"
+			label = "app=agent"
+		}
+	`)
+	require.NoError(t, err)
+
+	cfg, err := args.Convert()
+	require.NoError(t, err)
+	require.Equal(t, promk8s.RoleEndpoint, cfg.Role)
+	require.Equal(t, "https://kubernetes.example.com", cfg.APIServer.String())
+	require.Equal(t, "token", string(cfg.HTTPClientConfig.BearerToken))
+	require.True(t, cfg.HTTPClientConfig.FollowRedirects)
+	require.Equal(t, []string{"default", "monitoring"}, cfg.NamespaceDiscovery.Names)
+	require.Equal(t, []promk8s.SelectorConfig{{
+		Role:  promk8s.RolePod,
+		Label: "app=agent",
+	}}, cfg.Selectors)
+}
+
+func TestArguments_Invalid(t *testing.T) {
+	tt := []struct {
+		name, cfg, err string
+	}{
+		{
+			name: "unknown role",
+			cfg:  `role = "deployment"`,
+			err:  `invalid role "deployment", expected one of pod, service, endpoints, endpointslice, node, or ingress`,
+		},
+		{
+			name: "api_server and kubeconfig_file",
+			cfg: `
+				role            = "pod"
+				api_server      = "https://kubernetes.example.com"
+				kubeconfig_file = "/etc/kubeconfig"
+			`,
+			err: "cannot use kubeconfig_file and api_server simultaneously",
+		},
+		{
+			name: "http_client_config without api_server",
+			cfg: `
+				role = "pod"
+				http_client_config {
+					bearer_token = "token"
+				}
+			`,
+			err: "to use http_client_config, api_server must be provided",
+		},
+		{
+			name: "unsupported selector role",
+			cfg: `
+				role = "pod"
+				selectors {
+					role = "service"
+				}
+			`,
+			err: `pod role does not support "service" selectors`,
+		},
+		{
+			name: "invalid label selector",
+			cfg: `
+				role = "pod"
+				selectors {
+					role  = "pod"
+					label = "app in ("
+				}
+			`,
+			err: "invalid label selector for role pod",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := decodeArguments(tc.cfg)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.err)
+		})
+	}
+}
+
+func decodeArguments(cfg string) (Arguments, error) {
+	file, diags := hclparse.NewParser().ParseHCL([]byte(cfg), "test.flow")
+	if diags.HasErrors() {
+		return Arguments{}, diags
+	}
+
+	var args Arguments
+	if diags := gohcl.DecodeBody(file.Body, nil, &args); diags.HasErrors() {
+		return Arguments{}, diags
+	}
+	return args, nil
+}
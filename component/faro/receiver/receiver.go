@@ -0,0 +1,396 @@
+// Package receiver implements the faro.receiver component.
+package receiver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/gorilla/mux"
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/loki"
+	"github.com/grafana/agent/component/otelcol"
+	"github.com/grafana/agent/pkg/flow/hcltypes"
+	"github.com/grafana/agent/pkg/integrations/v2/app_agent_receiver"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/grafana/loki/clients/pkg/promtail/api"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/rfratto/gohcl"
+	"go.opentelemetry.io/collector/consumer"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name: "faro.receiver",
+		Args: Arguments{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Arguments holds values which are used to configure the faro.receiver
+// component.
+type Arguments struct {
+	Server     *ServerArguments     `hcl:"server,block"`
+	SourceMaps *SourceMapsArguments `hcl:"sourcemaps,block"`
+	// LogLabels are the labels of log entries. Labels with an empty value
+	// take the value of the field with the same name.
+	LogLabels map[string]string `hcl:"log_labels,optional"`
+
+	Output *OutputArguments `hcl:"output,block"`
+}
+
+// DefaultArguments holds default settings for Arguments.
+var DefaultArguments = Arguments{
+	LogLabels: map[string]string{
+		"app_name": "",
+		"kind":     "",
+	},
+}
+
+var _ gohcl.Decoder = (*Arguments)(nil)
+
+// DecodeHCL implements gohcl.Decoder.
+func (args *Arguments) DecodeHCL(body hcl.Body, ctx *hcl.EvalContext) error {
+	*args = DefaultArguments
+
+	type arguments Arguments
+	if err := gohcl.DecodeBody(body, ctx, (*arguments)(args)); err != nil {
+		return err
+	}
+	if args.Output == nil {
+		return fmt.Errorf("the output block must be provided")
+	}
+	return nil
+}
+
+// ServerArguments configures the HTTP server payloads are sent to.
+type ServerArguments struct {
+	ListenAddress         string          `hcl:"listen_address,optional"`
+	ListenPort            int             `hcl:"listen_port,optional"`
+	CORSAllowedOrigins    []string        `hcl:"cors_allowed_origins,optional"`
+	APIKey                hcltypes.Secret `hcl:"api_key,optional"`
+	MaxAllowedPayloadSize int64           `hcl:"max_allowed_payload_size,optional"`
+
+	RateLimiting *RateLimitingArguments `hcl:"rate_limiting,block"`
+}
+
+// DefaultServerArguments holds default settings for ServerArguments.
+var DefaultServerArguments = ServerArguments{
+	ListenAddress:         "127.0.0.1",
+	ListenPort:            12347,
+	MaxAllowedPayloadSize: app_agent_receiver.DefaultMaxPayloadSize,
+}
+
+var _ gohcl.Decoder = (*ServerArguments)(nil)
+
+// DecodeHCL implements gohcl.Decoder.
+func (args *ServerArguments) DecodeHCL(body hcl.Body, ctx *hcl.EvalContext) error {
+	*args = DefaultServerArguments
+
+	type arguments ServerArguments
+	return gohcl.DecodeBody(body, ctx, (*arguments)(args))
+}
+
+// RateLimitingArguments configures rate limiting of the HTTP server.
+type RateLimitingArguments struct {
+	Enabled    bool    `hcl:"enabled,optional"`
+	RPS        float64 `hcl:"rate,optional"`
+	Burstiness int     `hcl:"burst_size,optional"`
+}
+
+// DefaultRateLimitingArguments holds default settings for
+// RateLimitingArguments.
+var DefaultRateLimitingArguments = RateLimitingArguments{
+	Enabled:    true,
+	RPS:        app_agent_receiver.DefaultRateLimitingRPS,
+	Burstiness: app_agent_receiver.DefaultRateLimitingBurstiness,
+}
+
+var _ gohcl.Decoder = (*RateLimitingArguments)(nil)
+
+// DecodeHCL implements gohcl.Decoder.
+func (args *RateLimitingArguments) DecodeHCL(body hcl.Body, ctx *hcl.EvalContext) error {
+	*args = DefaultRateLimitingArguments
+
+	type arguments RateLimitingArguments
+	return gohcl.DecodeBody(body, ctx, (*arguments)(args))
+}
+
+// SourceMapsArguments configures how stack traces are resolved with
+// sourcemaps.
+type SourceMapsArguments struct {
+	Download            bool                `hcl:"download,optional"`
+	DownloadFromOrigins []string            `hcl:"download_from_origins,optional"`
+	DownloadTimeout     time.Duration       `hcl:"download_timeout,optional"`
+	Locations           []LocationArguments `hcl:"location,block"`
+}
+
+// DefaultSourceMapsArguments holds default settings for
+// SourceMapsArguments.
+var DefaultSourceMapsArguments = SourceMapsArguments{
+	Download:            true,
+	DownloadFromOrigins: []string{"*"},
+	DownloadTimeout:     time.Second,
+}
+
+var _ gohcl.Decoder = (*SourceMapsArguments)(nil)
+
+// DecodeHCL implements gohcl.Decoder.
+func (args *SourceMapsArguments) DecodeHCL(body hcl.Body, ctx *hcl.EvalContext) error {
+	*args = DefaultSourceMapsArguments
+
+	type arguments SourceMapsArguments
+	return gohcl.DecodeBody(body, ctx, (*arguments)(args))
+}
+
+// LocationArguments is a directory sourcemaps are read from.
+type LocationArguments struct {
+	Path               string `hcl:"path,attr"`
+	MinifiedPathPrefix string `hcl:"minified_path_prefix,attr"`
+}
+
+// OutputArguments holds the components received logs and traces are sent
+// to.
+type OutputArguments struct {
+	Logs   []*loki.LogsReceiver `hcl:"logs,optional"`
+	Traces []*otelcol.Consumer  `hcl:"traces,optional"`
+}
+
+// Component implements the faro.receiver component.
+type Component struct {
+	opts       component.Options
+	registerer *util.Unregisterer
+	logs       *logsSender
+	traces     *otelcol.Fanout
+
+	mut  sync.Mutex
+	args Arguments
+
+	// updated is written to when the arguments of the component change.
+	updated chan struct{}
+}
+
+var _ component.Component = (*Component)(nil)
+
+// New creates a new faro.receiver component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	c := &Component{
+		opts:       o,
+		registerer: util.WrapWithUnregisterer(o.Registerer),
+		logs:       &logsSender{},
+		traces:     otelcol.NewFanout(nil),
+		updated:    make(chan struct{}, 1),
+	}
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Run implements component.Component. The server is restarted whenever the
+// arguments of the component change.
+func (c *Component) Run(ctx context.Context) error {
+	defer c.registerer.UnregisterAll()
+
+	for {
+		c.mut.Lock()
+		args := c.args
+		c.mut.Unlock()
+
+		srv, errCh, err := c.startServer(args)
+		if err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return shutdownServer(srv)
+		case err := <-errCh:
+			return err
+		case <-c.updated:
+			if err := shutdownServer(srv); err != nil {
+				level.Warn(c.opts.Logger).Log("msg", "failed to stop server", "err", err)
+			}
+		}
+	}
+}
+
+// startServer starts an HTTP server receiving payloads configured by args.
+// Errors serving requests are sent to the returned channel.
+func (c *Component) startServer(args Arguments) (*http.Server, <-chan error, error) {
+	// Metrics of the previous server are replaced
+	c.registerer.UnregisterAll()
+
+	handler, sourcemapStore := c.newHandler(args)
+
+	r := mux.NewRouter()
+	r.Handle("/collect", handler.HTTPHandler(c.opts.Logger)).Methods("POST", "OPTIONS")
+	r.Handle("/collect/batch", handler.BatchHTTPHandler(c.opts.Logger)).Methods("POST", "OPTIONS")
+	if h := sourcemapStore.UploadHandler(); h != nil {
+		r.Handle("/sourcemaps/{app}/{release}/{path:.+}", h).Methods("PUT")
+	}
+
+	server := serverArguments(args)
+	lis, err := net.Listen("tcp", net.JoinHostPort(server.ListenAddress, strconv.Itoa(server.ListenPort)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to listen: %w", err)
+	}
+
+	srv := &http.Server{Handler: r}
+	errCh := make(chan error, 1)
+	go func() {
+		level.Info(c.opts.Logger).Log("msg", "starting server", "addr", lis.Addr())
+		if err := srv.Serve(lis); err != nil && err != http.ErrServerClosed {
+			errCh <- err
+		}
+	}()
+	return srv, errCh, nil
+}
+
+func shutdownServer(srv *http.Server) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return srv.Shutdown(ctx)
+}
+
+// newHandler builds the handler receiving payloads, which exports logs and
+// traces to the outputs of the component.
+func (c *Component) newHandler(args Arguments) (app_agent_receiver.AppAgentReceiverHandler, *app_agent_receiver.RealSourceMapStore) {
+	conf := receiverConfig(args)
+
+	sourcemapLogger := log.With(c.opts.Logger, "subcomponent", "sourcemaps")
+	sourcemapStore := app_agent_receiver.NewSourceMapStore(sourcemapLogger, conf.SourceMaps, c.registerer, nil, nil)
+
+	exporters := []app_agent_receiver.AppAgentReceiverExporter{
+		app_agent_receiver.NewReceiverMetricsExporter(c.registerer),
+		app_agent_receiver.NewLogsExporter(
+			c.opts.Logger,
+			app_agent_receiver.LogsExporterConfig{
+				GetLogsInstance: func(context.Context, string) (app_agent_receiver.LogsInstance, error) {
+					return c.logs, nil
+				},
+				Labels:           conf.LogsLabels,
+				SendEntryTimeout: conf.LogsSendTimeout,
+			},
+			sourcemapStore,
+		),
+		app_agent_receiver.NewTracesExporter(func(context.Context, app_agent_receiver.Payload) (consumer.Traces, error) {
+			return c.traces, nil
+		}, nil),
+	}
+	return app_agent_receiver.NewAppAgentReceiverHandler(conf, exporters, c.registerer), sourcemapStore
+}
+
+// serverArguments returns the server arguments of args, or their defaults.
+func serverArguments(args Arguments) ServerArguments {
+	if args.Server == nil {
+		return DefaultServerArguments
+	}
+	return *args.Server
+}
+
+// receiverConfig converts args into the configuration of the receiver of the
+// app_agent_receiver integration.
+func receiverConfig(args Arguments) *app_agent_receiver.Config {
+	conf := app_agent_receiver.DefaultConfig
+	conf.LogsLabels = args.LogLabels
+
+	server := serverArguments(args)
+	conf.Server.Host = server.ListenAddress
+	conf.Server.Port = server.ListenPort
+	conf.Server.CORSAllowedOrigins = server.CORSAllowedOrigins
+	conf.Server.APIKey = string(server.APIKey)
+	conf.Server.MaxAllowedPayloadSize = server.MaxAllowedPayloadSize
+
+	rateLimiting := DefaultRateLimitingArguments
+	if server.RateLimiting != nil {
+		rateLimiting = *server.RateLimiting
+	}
+	conf.Server.RateLimiting = app_agent_receiver.RateLimitingConfig{
+		Enabled:    rateLimiting.Enabled,
+		RPS:        rateLimiting.RPS,
+		Burstiness: rateLimiting.Burstiness,
+	}
+
+	sourceMaps := DefaultSourceMapsArguments
+	if args.SourceMaps != nil {
+		sourceMaps = *args.SourceMaps
+	}
+	conf.SourceMaps = app_agent_receiver.SourceMapConfig{
+		Download:            sourceMaps.Download,
+		DownloadFromOrigins: sourceMaps.DownloadFromOrigins,
+		DownloadTimeout:     sourceMaps.DownloadTimeout,
+		MaxDownloadSize:     app_agent_receiver.DefaultMaxSourceMapSize,
+	}
+	for _, loc := range sourceMaps.Locations {
+		conf.SourceMaps.FileSystem = append(conf.SourceMaps.FileSystem, app_agent_receiver.SourceMapFileLocation{
+			Path:               loc.Path,
+			MinifiedPathPrefix: loc.MinifiedPathPrefix,
+		})
+	}
+	return &conf
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	c.mut.Lock()
+	c.args = newArgs
+	c.mut.Unlock()
+
+	c.logs.SetReceivers(newArgs.Output.Logs)
+	c.traces.UpdateChildren(newArgs.Output.Traces)
+
+	select {
+	case c.updated <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// logsSender sends log entries to a set of logs receivers, which can be
+// changed at any time.
+type logsSender struct {
+	mut       sync.RWMutex
+	receivers []*loki.LogsReceiver
+}
+
+var _ app_agent_receiver.LogsInstance = (*logsSender)(nil)
+
+// SetReceivers changes the receivers log entries are sent to.
+func (s *logsSender) SetReceivers(receivers []*loki.LogsReceiver) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.receivers = receivers
+}
+
+// SendEntry implements app_agent_receiver.LogsInstance. It returns false if
+// the entry couldn't be sent to every receiver within dur.
+func (s *logsSender) SendEntry(entry api.Entry, dur time.Duration) bool {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), dur)
+	defer cancel()
+
+	sent := true
+	for _, r := range s.receivers {
+		if r == nil {
+			continue
+		}
+		if err := r.Send(ctx, entry); err != nil {
+			sent = false
+		}
+	}
+	return sent
+}
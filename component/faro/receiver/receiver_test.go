@@ -0,0 +1,126 @@
+package receiver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/loki"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/grafana/loki/clients/pkg/promtail/api"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/rfratto/gohcl"
+	"github.com/stretchr/testify/require"
+)
+
+const testPayload = `{
+  "logs": [{
+    "message": "opened cart",
+    "level": "info",
+    "timestamp": "2022-06-01T10:00:00Z"
+  }],
+  "meta": {
+    "app": {"name": "shop", "version": "1.0.0"}
+  }
+}`
+
+func TestComponent_Logs(t *testing.T) {
+	out := loki.NewLogsReceiver()
+
+	args := decodeArguments(t, `
+		server {
+			api_key = "secret"
+		}
+	`)
+	args.Output.Logs = []*loki.LogsReceiver{out}
+
+	c, err := New(component.Options{
+		ID:         "faro.receiver.test",
+		Logger:     util.TestLogger(t),
+		Registerer: prometheus.NewRegistry(),
+	}, args)
+	require.NoError(t, err)
+
+	handler, _ := c.newHandler(args)
+	srv := httptest.NewServer(handler.HTTPHandler(c.opts.Logger))
+	defer srv.Close()
+
+	send := func(apiKey string) int {
+		req, err := http.NewRequest("POST", srv.URL, strings.NewReader(testPayload))
+		require.NoError(t, err)
+		req.Header.Set("x-api-key", apiKey)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	require.Equal(t, http.StatusUnauthorized, send("wrong"))
+
+	// The log entry is sent while the request is handled.
+	status := make(chan int, 1)
+	go func() { status <- send("secret") }()
+
+	var received api.Entry
+	select {
+	case received = <-out.Chan():
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out waiting for entry")
+	}
+	require.Equal(t, http.StatusAccepted, <-status)
+
+	require.Equal(t, model.LabelSet{"app_name": "shop", "kind": "log"}, received.Labels)
+	require.Contains(t, received.Line, `message="opened cart"`)
+}
+
+func TestArguments(t *testing.T) {
+	args := decodeArguments(t, `
+		server {
+			listen_port = 8080
+			rate_limiting {
+				rate = 10
+			}
+		}
+		sourcemaps {
+			download = false
+			location {
+				path                 = "/var/www/static"
+				minified_path_prefix = "https://shop.example.com/static/"
+			}
+		}
+	`)
+
+	conf := receiverConfig(args)
+	require.Equal(t, "127.0.0.1", conf.Server.Host)
+	require.Equal(t, 8080, conf.Server.Port)
+	require.True(t, conf.Server.RateLimiting.Enabled)
+	require.Equal(t, 10.0, conf.Server.RateLimiting.RPS)
+	require.Equal(t, DefaultRateLimitingArguments.Burstiness, conf.Server.RateLimiting.Burstiness)
+	require.False(t, conf.SourceMaps.Download)
+	require.Len(t, conf.SourceMaps.FileSystem, 1)
+	require.Equal(t, "/var/www/static", conf.SourceMaps.FileSystem[0].Path)
+	require.Equal(t, DefaultArguments.LogLabels, conf.LogsLabels)
+
+	file, diags := hclparse.NewParser().ParseHCL([]byte(`log_labels = {}`), "test.flow")
+	require.False(t, diags.HasErrors(), diags.Error())
+	err := gohcl.DecodeBody(file.Body, nil, &Arguments{})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "the output block must be provided")
+}
+
+func decodeArguments(t *testing.T, cfg string) Arguments {
+	t.Helper()
+
+	file, diags := hclparse.NewParser().ParseHCL([]byte(cfg+"\noutput {}\n"), "test.flow")
+	require.False(t, diags.HasErrors(), diags.Error())
+
+	var args Arguments
+	diags = gohcl.DecodeBody(file.Body, nil, &args)
+	require.False(t, diags.HasErrors(), diags.Error())
+	return args
+}
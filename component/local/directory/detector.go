@@ -0,0 +1,141 @@
+package directory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+type fsNotify struct {
+	opts   fsNotifyOptions
+	cancel context.CancelFunc
+
+	// watcherMut is needed to prevent race conditions on Windows. This can be
+	// removed once fsnotify/fsnotify#454 is merged and included in a patch
+	// release.
+	watcherMut sync.Mutex
+	watcher    *fsnotify.Watcher
+}
+
+type fsNotifyOptions struct {
+	Logger        log.Logger
+	Path          string
+	Rescan        func()        // Callback to request a directory rescan.
+	PollFrequency time.Duration // How often to do fallback polling
+}
+
+// newFSNotify creates a new fsnotify detector which uses filesystem events to
+// detect that the contents of a directory have changed.
+func newFSNotify(opts fsNotifyOptions) (*fsNotify, error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := w.Add(opts.Path); err != nil {
+		// It's possible that the directory already got deleted by the time our
+		// fsnotify was created. We'll log the error and wait for our polling
+		// fallback for the directory to be recreated.
+		level.Warn(opts.Logger).Log("msg", "failed to watch directory", "err", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	wd := &fsNotify{
+		opts:    opts,
+		watcher: w,
+		cancel:  cancel,
+	}
+
+	go wd.wait(ctx)
+	return wd, nil
+}
+
+func (fsn *fsNotify) wait(ctx context.Context) {
+	pollTick := time.NewTicker(fsn.opts.PollFrequency)
+	defer pollTick.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-pollTick.C:
+			// Re-establish the watch in case it was stopped (i.e., the directory
+			// got deleted). This is a no-op if the watch is already active.
+			fsn.watcherMut.Lock()
+			err := fsn.watcher.Add(fsn.opts.Path)
+			fsn.watcherMut.Unlock()
+
+			if err != nil {
+				level.Warn(fsn.opts.Logger).Log("msg", "failed re-watch directory", "err", err)
+			}
+
+			fsn.opts.Rescan()
+
+		case err := <-fsn.watcher.Errors:
+			// We don't know if the error is related to the directory, so we always
+			// treat it as if the directory changed.
+			if err != nil {
+				level.Warn(fsn.opts.Logger).Log("msg", "got error from fsnotify watcher; treating as directory updated event", "err", err)
+				fsn.opts.Rescan()
+			}
+		case ev := <-fsn.watcher.Events:
+			level.Debug(fsn.opts.Logger).Log("msg", "got fsnotify event", "op", ev.Op.String(), "name", ev.Name)
+			fsn.opts.Rescan()
+		}
+	}
+}
+
+func (fsn *fsNotify) Close() error {
+	fsn.watcherMut.Lock()
+	defer fsn.watcherMut.Unlock()
+
+	fsn.cancel()
+	return fsn.watcher.Close()
+}
+
+type poller struct {
+	opts   pollerOptions
+	cancel context.CancelFunc
+}
+
+type pollerOptions struct {
+	Rescan        func() // Callback to request a directory rescan.
+	PollFrequency time.Duration
+}
+
+// newPoller creates a new poll-based directory update detector.
+func newPoller(opts pollerOptions) *poller {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	pw := &poller{
+		opts:   opts,
+		cancel: cancel,
+	}
+
+	go pw.run(ctx)
+	return pw
+}
+
+func (p *poller) run(ctx context.Context) {
+	t := time.NewTicker(p.opts.PollFrequency)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			p.opts.Rescan()
+		}
+	}
+}
+
+// Close terminates the poller.
+func (p *poller) Close() error {
+	p.cancel()
+	return nil
+}
@@ -0,0 +1,299 @@
+package directory
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/local/file"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/rfratto/gohcl"
+)
+
+// waitReadPeriod holds the time to wait before scanning the directory while
+// the local.directory component is running.
+//
+// This prevents local.directory from updating too frequently when many
+// files are changed at once.
+const waitReadPeriod time.Duration = 30 * time.Millisecond
+
+func init() {
+	component.Register(component.Registration{
+		Name:    "local.directory",
+		Args:    Arguments{},
+		Exports: Exports{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Arguments holds values which are used to configure the local.directory
+// component.
+type Arguments struct {
+	// Path indicates the directory to watch.
+	Path string `hcl:"path,attr"`
+	// Type indicates how to detect changes to the directory.
+	Type file.Detector `hcl:"detector,optional"`
+	// PollFrequency determines the frequency to check for changes when Type is
+	// DetectorPoll, and the fallback polling frequency for DetectorFSNotify.
+	PollFrequency time.Duration `hcl:"poll_frequency,optional"`
+}
+
+// DefaultArguments provides the default arguments for the local.directory
+// component.
+var DefaultArguments = Arguments{
+	Type:          file.DetectorFSNotify,
+	PollFrequency: time.Minute,
+}
+
+var _ gohcl.Decoder = (*Arguments)(nil)
+
+// DecodeHCL implements gohcl.Decoder.
+func (a *Arguments) DecodeHCL(body hcl.Body, ctx *hcl.EvalContext) error {
+	*a = DefaultArguments
+
+	type arguments Arguments
+	return gohcl.DecodeBody(body, ctx, (*arguments)(a))
+}
+
+// Exports holds values which are exported by the local.directory component.
+type Exports struct {
+	// Files in the directory, sorted by name.
+	Files []File `hcl:"files,attr"`
+}
+
+// File describes a single file found in the watched directory.
+type File struct {
+	Name    string    `hcl:"name,attr"`
+	Path    string    `hcl:"path,attr"`
+	Size    int64     `hcl:"size,attr"`
+	ModTime time.Time `hcl:"mod_time,attr"`
+}
+
+// Component implements the local.directory component.
+type Component struct {
+	opts component.Options
+
+	mut         sync.Mutex
+	args        Arguments
+	latestFiles []File
+	detector    io.Closer
+
+	healthMut sync.RWMutex
+	health    component.Health
+
+	// rescanCh is a buffered channel which is written to when the watched
+	// directory should be rescanned by the component.
+	rescanCh chan struct{}
+}
+
+var (
+	_ component.Component       = (*Component)(nil)
+	_ component.HealthComponent = (*Component)(nil)
+)
+
+// New creates a new local.directory component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	c := &Component{
+		opts: o,
+
+		rescanCh: make(chan struct{}, 1),
+	}
+
+	// Perform an update which will immediately set our exports to the initial
+	// contents of the directory.
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	defer func() {
+		c.mut.Lock()
+		defer c.mut.Unlock()
+
+		if err := c.detector.Close(); err != nil {
+			level.Error(c.opts.Logger).Log("msg", "failed to shut down detector", "err", err)
+		}
+		c.detector = nil
+	}()
+
+	// Run may be called again after a previous Run exited, so the detector
+	// must be recreated if it was closed.
+	c.mut.Lock()
+	_ = c.configureDetector()
+	c.mut.Unlock()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-c.rescanCh:
+			time.Sleep(waitReadPeriod)
+
+			// We ignore the error here from scanDirectory since scanDirectory will
+			// log errors and also report the error as the health of the component.
+			c.mut.Lock()
+			_ = c.scanDirectory()
+			c.mut.Unlock()
+		}
+	}
+}
+
+// scanDirectory lists the files in the watched directory and updates the
+// exports if the set of files changed. mut must be held when called.
+func (c *Component) scanDirectory() error {
+	files, err := listFiles(c.args.Path)
+	if err != nil {
+		c.setHealth(component.Health{
+			Health:     component.HealthTypeUnhealthy,
+			Message:    fmt.Sprintf("failed to scan directory: %s", err),
+			UpdateTime: time.Now(),
+		})
+		level.Error(c.opts.Logger).Log("msg", "failed to scan directory", "path", c.args.Path, "err", err)
+		return err
+	}
+
+	// Only inform the controller when something changed to avoid needlessly
+	// re-evaluating dependant components on every poll.
+	if c.latestFiles == nil || !reflect.DeepEqual(c.latestFiles, files) {
+		c.latestFiles = files
+		c.opts.OnStateChange(Exports{Files: files})
+	}
+
+	c.setHealth(component.Health{
+		Health:     component.HealthTypeHealthy,
+		Message:    "scanned directory",
+		UpdateTime: time.Now(),
+	})
+	return nil
+}
+
+// listFiles returns the regular files directly inside of dir, sorted by name.
+// Subdirectories are not traversed.
+func listFiles(dir string) ([]File, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]File, 0, len(entries))
+	for _, ent := range entries {
+		if !ent.Type().IsRegular() {
+			continue
+		}
+
+		fi, err := ent.Info()
+		if os.IsNotExist(err) {
+			// The file was removed between listing and stat; ignore it.
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		files = append(files, File{
+			Name:    fi.Name(),
+			Path:    filepath.Join(dir, fi.Name()),
+			Size:    fi.Size(),
+			ModTime: fi.ModTime(),
+		})
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files, nil
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	if newArgs.PollFrequency <= 0 {
+		return fmt.Errorf("poll_frequency must be greater than 0")
+	}
+	if newArgs.Type != file.DetectorFSNotify && newArgs.Type != file.DetectorPoll {
+		return fmt.Errorf("unsupported detector %s, expected fsnotify or poll", newArgs.Type)
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.args = newArgs
+
+	// Force an immediate scan of the directory to report any potential errors
+	// early.
+	if err := c.scanDirectory(); err != nil {
+		return fmt.Errorf("failed to scan directory: %w", err)
+	}
+
+	// Each detector is dedicated to a single path. Shut down the existing
+	// detector (if any) in case the path changed between calls to Update.
+	if c.detector != nil {
+		if err := c.detector.Close(); err != nil {
+			level.Error(c.opts.Logger).Log("msg", "failed to shut down old detector", "err", err)
+		}
+		c.detector = nil
+	}
+
+	return c.configureDetector()
+}
+
+// configureDetector configures the detector if one isn't set. mut must be held
+// when called.
+func (c *Component) configureDetector() error {
+	if c.detector != nil {
+		// Already have a detector; don't do anything.
+		return nil
+	}
+
+	var err error
+
+	rescan := func() {
+		select {
+		case c.rescanCh <- struct{}{}:
+		default:
+			// no-op: a rescan is already queued so we don't need to queue a second
+			// one.
+		}
+	}
+
+	switch c.args.Type {
+	case file.DetectorPoll:
+		c.detector = newPoller(pollerOptions{
+			Rescan:        rescan,
+			PollFrequency: c.args.PollFrequency,
+		})
+	case file.DetectorFSNotify:
+		c.detector, err = newFSNotify(fsNotifyOptions{
+			Logger:        c.opts.Logger,
+			Path:          c.args.Path,
+			Rescan:        rescan,
+			PollFrequency: c.args.PollFrequency,
+		})
+	}
+
+	return err
+}
+
+// CurrentHealth implements component.HealthComponent.
+func (c *Component) CurrentHealth() component.Health {
+	c.healthMut.RLock()
+	defer c.healthMut.RUnlock()
+	return c.health
+}
+
+func (c *Component) setHealth(h component.Health) {
+	c.healthMut.Lock()
+	defer c.healthMut.Unlock()
+	c.health = h
+}
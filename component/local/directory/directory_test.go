@@ -0,0 +1,99 @@
+package directory_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/component/local/directory"
+	"github.com/grafana/agent/component/local/file"
+	"github.com/grafana/agent/pkg/flow/componenttest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirectory(t *testing.T) {
+	t.Run("Polling change detector", func(t *testing.T) {
+		runDirectoryTests(t, file.DetectorPoll)
+	})
+
+	t.Run("Event change detector", func(t *testing.T) {
+		runDirectoryTests(t, file.DetectorFSNotify)
+	})
+}
+
+// runDirectoryTests will run a suite of tests with the configured detector.
+func runDirectoryTests(t *testing.T, ut file.Detector) {
+	newSuiteController := func(t *testing.T, dir string) *componenttest.Controller {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "a"), []byte("a"), 0664))
+
+		tc, err := componenttest.NewControllerFromID(nil, "local.directory")
+		require.NoError(t, err)
+		go func() {
+			err := tc.Run(componenttest.TestContext(t), directory.Arguments{
+				Path:          dir,
+				Type:          ut,
+				PollFrequency: 50 * time.Millisecond,
+			})
+			require.NoError(t, err)
+		}()
+
+		// Swallow the initial exports notification.
+		require.NoError(t, tc.WaitExports(time.Second))
+		require.Equal(t, []string{"a"}, fileNames(tc.Exports()))
+		return tc
+	}
+
+	t.Run("Created files are detected", func(t *testing.T) {
+		dir := t.TempDir()
+		sc := newSuiteController(t, dir)
+
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "b"), []byte("bb"), 0664))
+
+		require.NoError(t, sc.WaitExports(time.Second))
+		exports := sc.Exports().(directory.Exports)
+		require.Equal(t, []string{"a", "b"}, fileNames(exports))
+		require.Equal(t, filepath.Join(dir, "b"), exports.Files[1].Path)
+		require.Equal(t, int64(2), exports.Files[1].Size)
+	})
+
+	t.Run("Deleted files are detected", func(t *testing.T) {
+		dir := t.TempDir()
+		sc := newSuiteController(t, dir)
+
+		require.NoError(t, os.Remove(filepath.Join(dir, "a")))
+
+		require.NoError(t, sc.WaitExports(time.Second))
+		require.Empty(t, fileNames(sc.Exports()))
+	})
+}
+
+// TestDirectory_IgnoresSubdirectories ensures that only regular files are
+// exported.
+func TestDirectory_IgnoresSubdirectories(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "subdir"), 0775))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "file"), nil, 0664))
+
+	tc, err := componenttest.NewControllerFromID(nil, "local.directory")
+	require.NoError(t, err)
+	go func() {
+		err := tc.Run(componenttest.TestContext(t), directory.Arguments{
+			Path:          dir,
+			Type:          file.DetectorPoll,
+			PollFrequency: 1 * time.Hour,
+		})
+		require.NoError(t, err)
+	}()
+
+	require.NoError(t, tc.WaitExports(time.Second))
+	require.Equal(t, []string{"file"}, fileNames(tc.Exports()))
+}
+
+func fileNames(e interface{}) []string {
+	var names []string
+	for _, f := range e.(directory.Exports).Files {
+		names = append(names, f.Name)
+	}
+	return names
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding"
 	"fmt"
+	"path/filepath"
 	"sync"
 	"time"
 
@@ -22,6 +23,9 @@ const (
 	DetectorFSNotify
 	// DetectorPoll will re-read the file on an interval to detect changes.
 	DetectorPoll
+	// DetectorFSNotifyDir uses filesystem events on the parent directory of
+	// the file to detect when it is replaced, including through symlink swaps.
+	DetectorFSNotifyDir
 
 	// DetectorDefault holds the default UpdateType.
 	DetectorDefault = DetectorFSNotify
@@ -39,6 +43,8 @@ func (ut Detector) String() string {
 		return "fsnotify"
 	case DetectorPoll:
 		return "poll"
+	case DetectorFSNotifyDir:
+		return "fsnotify_dir"
 	default:
 		return fmt.Sprintf("Detector(%d)", ut)
 	}
@@ -58,8 +64,10 @@ func (ut *Detector) UnmarshalText(text []byte) error {
 		*ut = DetectorFSNotify
 	case "poll":
 		*ut = DetectorPoll
+	case "fsnotify_dir":
+		*ut = DetectorFSNotifyDir
 	default:
-		return fmt.Errorf("unrecognized detector %q, expected fsnotify or poll", string(text))
+		return fmt.Errorf("unrecognized detector %q, expected fsnotify, fsnotify_dir, or poll", string(text))
 	}
 	return nil
 }
@@ -158,6 +166,122 @@ func (fsn *fsNotify) Close() error {
 	return fsn.watcher.Close()
 }
 
+// fsNotifyDir watches the parent directory of a file rather than the file
+// itself. Kubernetes updates projected volumes (Secrets, ConfigMaps) by
+// atomically swapping a "..data" symlink in the parent directory; the file
+// itself is never written to, so watching it directly misses the update.
+//
+// Events in the directory only trigger a reload if they refer to the file or
+// if the path the file resolves to changed. The resolved file is also watched
+// so that symlinks pointing outside of the parent directory are followed.
+type fsNotifyDir struct {
+	opts   fsNotifyOptions
+	cancel context.CancelFunc
+
+	watcherMut sync.Mutex
+	watcher    *fsnotify.Watcher
+	resolved   string
+}
+
+// newFSNotifyDir creates a new fsnotify_dir detector.
+func newFSNotifyDir(opts fsNotifyOptions) (*fsNotifyDir, error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	wd := &fsNotifyDir{
+		opts:    opts,
+		watcher: w,
+		cancel:  cancel,
+	}
+	wd.watch()
+
+	go wd.wait(ctx)
+	return wd, nil
+}
+
+// watch (re-)establishes the watches for the parent directory and the
+// resolved file, and returns true if the resolved path of the file changed.
+// watcherMut must not be held when called.
+func (fsn *fsNotifyDir) watch() (changed bool) {
+	fsn.watcherMut.Lock()
+	defer fsn.watcherMut.Unlock()
+
+	if err := fsn.watcher.Add(filepath.Dir(fsn.opts.Filename)); err != nil {
+		level.Warn(fsn.opts.Logger).Log("msg", "failed to watch directory", "err", err)
+	}
+
+	// The resolved path may fail to be found while a swap is in progress;
+	// treat that as a change so the file gets reread.
+	resolved, err := filepath.EvalSymlinks(fsn.opts.Filename)
+	if err != nil {
+		resolved = ""
+	}
+	if resolved == fsn.resolved {
+		return false
+	}
+
+	if fsn.resolved != "" && fsn.resolved != fsn.opts.Filename {
+		_ = fsn.watcher.Remove(fsn.resolved)
+	}
+	if resolved != "" && resolved != fsn.opts.Filename {
+		if err := fsn.watcher.Add(resolved); err != nil {
+			level.Warn(fsn.opts.Logger).Log("msg", "failed to watch resolved file", "path", resolved, "err", err)
+		}
+	}
+	fsn.resolved = resolved
+	return true
+}
+
+func (fsn *fsNotifyDir) wait(ctx context.Context) {
+	pollTick := time.NewTicker(fsn.opts.PollFreqency)
+	defer pollTick.Stop()
+
+	filename := filepath.Clean(fsn.opts.Filename)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-pollTick.C:
+			// Re-establish the watches in case the directory was deleted and
+			// recreated.
+			fsn.watch()
+			fsn.opts.ReloadFile()
+
+		case err := <-fsn.watcher.Errors:
+			if err != nil {
+				level.Warn(fsn.opts.Logger).Log("msg", "got error from fsnotify watcher; treating as file updated event", "err", err)
+				fsn.opts.ReloadFile()
+			}
+		case ev := <-fsn.watcher.Events:
+			changed := fsn.watch()
+			if !changed && filepath.Clean(ev.Name) != filename && ev.Name != fsn.resolvedPath() {
+				continue
+			}
+			level.Debug(fsn.opts.Logger).Log("msg", "got fsnotify event", "op", ev.Op.String(), "name", ev.Name)
+			fsn.opts.ReloadFile()
+		}
+	}
+}
+
+func (fsn *fsNotifyDir) resolvedPath() string {
+	fsn.watcherMut.Lock()
+	defer fsn.watcherMut.Unlock()
+	return fsn.resolved
+}
+
+func (fsn *fsNotifyDir) Close() error {
+	fsn.watcherMut.Lock()
+	defer fsn.watcherMut.Unlock()
+
+	fsn.cancel()
+	return fsn.watcher.Close()
+}
+
 type poller struct {
 	opts   pollerOptions
 	cancel context.CancelFunc
@@ -0,0 +1,100 @@
+package file
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding"
+	"encoding/base64"
+	"fmt"
+	"io"
+)
+
+// Encoding is used to specify how the raw content of the file is encoded.
+type Encoding int
+
+const (
+	// EncodingNone reads the file content as-is.
+	EncodingNone Encoding = iota
+	// EncodingBase64 decodes the file content as standard base64.
+	EncodingBase64
+	// EncodingGzip decompresses the file content as gzip.
+	EncodingGzip
+)
+
+// MaxDecodedSize is the maximum size in bytes of decompressed gzip content.
+// It keeps a small compressed file from expanding into an unbounded amount of
+// memory.
+const MaxDecodedSize = 64 << 20
+
+var (
+	_ encoding.TextMarshaler   = Encoding(0)
+	_ encoding.TextUnmarshaler = (*Encoding)(nil)
+)
+
+// String returns the string representation of the Encoding.
+func (e Encoding) String() string {
+	switch e {
+	case EncodingNone:
+		return "none"
+	case EncodingBase64:
+		return "base64"
+	case EncodingGzip:
+		return "gzip"
+	default:
+		return fmt.Sprintf("Encoding(%d)", e)
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (e Encoding) MarshalText() (text []byte, err error) {
+	return []byte(e.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (e *Encoding) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "", "none":
+		*e = EncodingNone
+	case "base64":
+		*e = EncodingBase64
+	case "gzip":
+		*e = EncodingGzip
+	default:
+		return fmt.Errorf("unrecognized decode option %q, expected none, base64, or gzip", string(text))
+	}
+	return nil
+}
+
+// decodeEncoding returns the raw bytes of bb encoded with e.
+func decodeEncoding(e Encoding, bb []byte) ([]byte, error) {
+	switch e {
+	case EncodingNone:
+		return bb, nil
+	case EncodingBase64:
+		// Files written by secret managers commonly end with a newline, which
+		// is not valid base64.
+		bb = bytes.TrimSpace(bb)
+		out := make([]byte, base64.StdEncoding.DecodedLen(len(bb)))
+		n, err := base64.StdEncoding.Decode(out, bb)
+		if err != nil {
+			return nil, fmt.Errorf("decoding base64: %w", err)
+		}
+		return out[:n], nil
+	case EncodingGzip:
+		r, err := gzip.NewReader(bytes.NewReader(bb))
+		if err != nil {
+			return nil, fmt.Errorf("decoding gzip: %w", err)
+		}
+		defer r.Close()
+		out, err := io.ReadAll(io.LimitReader(r, MaxDecodedSize+1))
+		if err != nil {
+			return nil, fmt.Errorf("decoding gzip: %w", err)
+		}
+		if len(out) > MaxDecodedSize {
+			return nil, fmt.Errorf("decoding gzip: decompressed content exceeds %d bytes", MaxDecodedSize)
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("cannot decode content with %s", e)
+	}
+}
@@ -2,8 +2,10 @@ package file
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"sync"
 	"time"
@@ -13,21 +15,19 @@ import (
 	"github.com/grafana/agent/pkg/flow/hcltypes"
 	"github.com/hashicorp/hcl/v2"
 	"github.com/rfratto/gohcl"
+	"github.com/zclconf/go-cty/cty"
 )
 
-// waitReadPeriod holds the time to wait before reading a file while the
-// local.file component is running.
-//
-// This prevents local.file from updating too frequently and exporting partial
-// writes.
-const waitReadPeriod time.Duration = 30 * time.Millisecond
-
 func init() {
 	component.Register(component.Registration{
 		Name:    "local.file",
 		Args:    Arguments{},
 		Exports: Exports{},
 
+		Validate: func(args component.Arguments) error {
+			return args.(Arguments).Validate()
+		},
+
 		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
 			return New(opts, args.(Arguments))
 		},
@@ -46,6 +46,25 @@ type Arguments struct {
 	// IsSecret marks the file as holding a secret value which should not be
 	// displayed to the user.
 	IsSecret bool `hcl:"is_secret,optional"`
+	// Format indicates how the content of the file should be decoded into a
+	// structured value.
+	Format Format `hcl:"format,optional"`
+	// Decode indicates how the raw content of the file is encoded. Content is
+	// decoded before being exported or decoded by Format.
+	Decode Encoding `hcl:"decode,optional"`
+	// Debounce is the time to wait after the last detected change before
+	// reading the file. This prevents local.file from updating too frequently
+	// and exporting partial writes.
+	Debounce time.Duration `hcl:"debounce,optional"`
+	// StableFor is the minimum amount of time the size and modification time
+	// of the file must stay unchanged before it is read. Slow writers which
+	// pause between writes would otherwise cause partial content to be
+	// exported.
+	StableFor time.Duration `hcl:"stable_for,optional"`
+	// WaitForFile allows the file to not exist. Instead of failing, the
+	// component retries reading the file with an exponential backoff until it
+	// exists.
+	WaitForFile bool `hcl:"wait_for_file,optional"`
 }
 
 // DefaultArguments provides the default arguments for the local.file
@@ -53,6 +72,7 @@ type Arguments struct {
 var DefaultArguments = Arguments{
 	Type:          DetectorFSNotify,
 	PollFrequency: time.Minute,
+	Debounce:      30 * time.Millisecond,
 }
 
 var _ gohcl.Decoder = (*Arguments)(nil)
@@ -65,21 +85,71 @@ func (a *Arguments) DecodeHCL(body hcl.Body, ctx *hcl.EvalContext) error {
 	return gohcl.DecodeBody(body, ctx, (*arguments)(a))
 }
 
+// Validate returns an error if a is invalid.
+func (a Arguments) Validate() error {
+	var errs component.ArgumentErrors
+
+	if a.PollFrequency <= 0 {
+		errs = append(errs, component.ArgumentError{Name: "poll_freqency", Message: "must be greater than 0"})
+	}
+	if a.Debounce < 0 {
+		errs = append(errs, component.ArgumentError{Name: "debounce", Message: "must not be negative"})
+	} else if a.PollFrequency > 0 && a.Debounce >= a.PollFrequency {
+		// Every poll counts as a detected change; a longer debounce would
+		// prevent the file from ever being read.
+		errs = append(errs, component.ArgumentError{Name: "debounce", Message: "must be less than poll_frequency"})
+	}
+	if a.StableFor < 0 {
+		errs = append(errs, component.ArgumentError{Name: "stable_for", Message: "must not be negative"})
+	}
+	if a.IsSecret && a.Format != FormatNone {
+		errs = append(errs, component.ArgumentError{Name: "is_secret", Message: fmt.Sprintf("cannot be used with format %s", a.Format)})
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
 // Exports holds values which are exported by the local.file component.
 type Exports struct {
 	// Content of the file.
 	Content *hcltypes.OptionalSecret `hcl:"content,attr"`
+	// Value holds the decoded content of the file. Value is nil if Format is
+	// FormatNone.
+	Value *cty.Value `hcl:"value,attr"`
+	// Exists is true when the file existed as of the last read.
+	Exists bool `hcl:"exists,attr"`
 }
 
+const (
+	// minRetryBackoff is the initial time to wait before retrying to read a
+	// missing file when WaitForFile is set. The backoff doubles on every retry
+	// up to the poll frequency.
+	minRetryBackoff = 100 * time.Millisecond
+)
+
 // Component implements the local.file component.
 type Component struct {
-	opts component.Options
+	opts    component.Options
+	metrics *metrics
 
 	mut           sync.Mutex
 	args          Arguments
 	latestContent string
 	detector      io.Closer
 
+	// lastStat and stableSince track when the file was last seen changing
+	// for StableFor.
+	lastStat    fileStat
+	stableSince time.Time
+
+	// retryTimer and retryBackoff schedule reads of a missing file when
+	// WaitForFile is set.
+	retryTimer   *time.Timer
+	retryBackoff time.Duration
+
 	healthMut sync.RWMutex
 	health    component.Health
 
@@ -96,7 +166,8 @@ var (
 // New creates a new local.file component.
 func New(o component.Options, args Arguments) (*Component, error) {
 	c := &Component{
-		opts: o,
+		opts:    o,
+		metrics: newMetrics(),
 
 		reloadCh: make(chan struct{}, 1),
 	}
@@ -106,6 +177,12 @@ func New(o component.Options, args Arguments) (*Component, error) {
 	if err := c.Update(args); err != nil {
 		return nil, err
 	}
+
+	// Metrics are only registered once the component is built so a failed
+	// build doesn't leave them registered.
+	if err := c.metrics.register(o.Registerer); err != nil {
+		return nil, err
+	}
 	return c, nil
 }
 
@@ -115,6 +192,7 @@ func (c *Component) Run(ctx context.Context) error {
 		c.mut.Lock()
 		defer c.mut.Unlock()
 
+		c.stopRetry()
 		if err := c.detector.Close(); err != nil {
 			level.Error(c.opts.Logger).Log("msg", "failed to shut down detector", "err", err)
 		}
@@ -134,59 +212,233 @@ func (c *Component) Run(ctx context.Context) error {
 	_ = c.configureDetector()
 	c.mut.Unlock()
 
+	readTimer := time.NewTimer(0)
+	if !readTimer.Stop() {
+		<-readTimer.C
+	}
+	defer readTimer.Stop()
+
 	for {
 		select {
 		case <-ctx.Done():
 			return nil
 		case <-c.reloadCh:
-			time.Sleep(waitReadPeriod)
+			// Every detected change pushes back the read until no changes have
+			// been seen for the debounce period.
+			c.mut.Lock()
+			debounce := c.args.Debounce
+			c.mut.Unlock()
+
+			if !readTimer.Stop() {
+				select {
+				case <-readTimer.C:
+				default:
+				}
+			}
+			readTimer.Reset(debounce)
+		case <-readTimer.C:
+			c.mut.Lock()
+			if wait := c.untilStable(); wait > 0 {
+				readTimer.Reset(wait)
+				c.mut.Unlock()
+				continue
+			}
 
 			// We ignore the error here from readFile since readFile will log errors
 			// and also report the error as the health of the component.
-			c.mut.Lock()
 			_ = c.readFile()
 			c.mut.Unlock()
 		}
 	}
 }
 
+// fileStat is the subset of file information used to detect whether a file
+// is still being written to.
+type fileStat struct {
+	size    int64
+	modTime time.Time
+}
+
+// untilStable returns how much longer the file must stay unchanged before it
+// is considered stable for StableFor. A file which can't be stat'd is
+// returned as stable so that readFile reports the error. mut must be held
+// when called.
+func (c *Component) untilStable() time.Duration {
+	if c.args.StableFor <= 0 {
+		return 0
+	}
+
+	fi, err := os.Stat(c.args.Filename)
+	if err != nil {
+		return 0
+	}
+
+	now := time.Now()
+	stat := fileStat{size: fi.Size(), modTime: fi.ModTime()}
+	if stat.size != c.lastStat.size || !stat.modTime.Equal(c.lastStat.modTime) || c.stableSince.IsZero() {
+		c.lastStat = stat
+		c.stableSince = now
+	}
+	return c.args.StableFor - now.Sub(c.stableSince)
+}
+
 func (c *Component) readFile() error {
+	c.metrics.readsTotal.Inc()
+
 	// Force a re-load of the file outside of the update detection mechanism.
 	bb, err := os.ReadFile(c.args.Filename)
+	if errors.Is(err, fs.ErrNotExist) && c.args.WaitForFile {
+		c.metrics.readErrorsTotal.Inc()
+		c.waitForFile()
+		return nil
+	}
 	if err != nil {
+		c.metrics.readErrorsTotal.Inc()
 		c.setHealth(component.Health{
 			Health:     component.HealthTypeUnhealthy,
 			Message:    fmt.Sprintf("failed to read file: %s", err),
 			UpdateTime: time.Now(),
 		})
+		c.publishRead(0, err)
 		level.Error(c.opts.Logger).Log("msg", "failed to read file", "path", c.opts.DataPath, "err", err)
 		return err
 	}
+
+	c.metrics.fileSizeBytes.Set(float64(len(bb)))
+
+	bb, err = decodeEncoding(c.args.Decode, bb)
+	if err != nil {
+		c.metrics.readErrorsTotal.Inc()
+		c.setHealth(component.Health{
+			Health:     component.HealthTypeUnhealthy,
+			Message:    fmt.Sprintf("failed to decode file: %s", err),
+			UpdateTime: time.Now(),
+		})
+		c.publishRead(len(bb), err)
+		level.Error(c.opts.Logger).Log("msg", "failed to decode file", "path", c.args.Filename, "decode", c.args.Decode, "err", err)
+		return err
+	}
+
+	var value *cty.Value
+	if c.args.Format != FormatNone {
+		decoded, err := decodeContent(c.args.Format, bb)
+		if err != nil {
+			c.metrics.readErrorsTotal.Inc()
+			c.setHealth(component.Health{
+				Health:     component.HealthTypeUnhealthy,
+				Message:    fmt.Sprintf("failed to decode file: %s", err),
+				UpdateTime: time.Now(),
+			})
+			c.publishRead(len(bb), err)
+			level.Error(c.opts.Logger).Log("msg", "failed to decode file", "path", c.args.Filename, "format", c.args.Format, "err", err)
+			return err
+		}
+		value = &decoded
+	}
 	c.latestContent = string(bb)
+	c.stopRetry()
 
 	c.opts.OnStateChange(Exports{
 		Content: &hcltypes.OptionalSecret{
 			IsSecret: c.args.IsSecret,
 			Value:    c.latestContent,
 		},
+		Value:  value,
+		Exists: true,
 	})
 
+	c.metrics.lastReadTimestamp.SetToCurrentTime()
 	c.setHealth(component.Health{
 		Health:     component.HealthTypeHealthy,
 		Message:    "read file",
 		UpdateTime: time.Now(),
 	})
+	c.publishRead(len(bb), nil)
 	return nil
 }
 
+// readEvent holds the data of debug events published when the file is read.
+type readEvent struct {
+	Filename string `json:"filename"`
+	Size     int    `json:"size_bytes"`
+}
+
+// publishRead publishes a debug event for an attempt to read the file of
+// size bytes. err is nil if the file was read and exported successfully. mut
+// must be held when called.
+func (c *Component) publishRead(size int, err error) {
+	e := component.DebugEvent{
+		Type:    "read",
+		Message: "read file",
+		Data:    readEvent{Filename: c.args.Filename, Size: size},
+	}
+	if err != nil {
+		e.Type, e.Message = "read_failed", err.Error()
+	}
+	c.opts.DebugStream.Publish(e)
+}
+
+// waitForFile exports that the file doesn't exist and schedules another read
+// of the file after an exponential backoff. mut must be held when called.
+func (c *Component) waitForFile() {
+	c.latestContent = ""
+	c.opts.OnStateChange(Exports{
+		Content: &hcltypes.OptionalSecret{IsSecret: c.args.IsSecret},
+		Exists:  false,
+	})
+
+	switch {
+	case c.retryBackoff == 0:
+		c.retryBackoff = minRetryBackoff
+	case c.retryBackoff < c.args.PollFrequency:
+		c.retryBackoff *= 2
+	}
+	if c.retryBackoff > c.args.PollFrequency {
+		c.retryBackoff = c.args.PollFrequency
+	}
+
+	c.setHealth(component.Health{
+		Health:     component.HealthTypeUnhealthy,
+		Message:    fmt.Sprintf("waiting for file to exist; retrying in %s", c.retryBackoff),
+		UpdateTime: time.Now(),
+	})
+	level.Warn(c.opts.Logger).Log("msg", "file does not exist, waiting for it to be created", "path", c.args.Filename, "retry_in", c.retryBackoff)
+	c.opts.DebugStream.Publish(component.DebugEvent{
+		Type:    "waiting",
+		Message: fmt.Sprintf("file does not exist; retrying in %s", c.retryBackoff),
+		Data:    readEvent{Filename: c.args.Filename},
+	})
+
+	if c.retryTimer != nil {
+		c.retryTimer.Stop()
+	}
+	c.retryTimer = time.AfterFunc(c.retryBackoff, c.queueReload)
+}
+
+// stopRetry cancels any scheduled retry and resets the backoff. mut must be
+// held when called.
+func (c *Component) stopRetry() {
+	if c.retryTimer != nil {
+		c.retryTimer.Stop()
+		c.retryTimer = nil
+	}
+	c.retryBackoff = 0
+}
+
+// queueReload requests the file to be reread by Run.
+func (c *Component) queueReload() {
+	select {
+	case c.reloadCh <- struct{}{}:
+	default:
+		// no-op: a reload is already queued so we don't need to queue a second
+		// one.
+	}
+}
+
 // Update implements component.Compnoent.
 func (c *Component) Update(args component.Arguments) error {
 	newArgs := args.(Arguments)
 
-	if newArgs.PollFrequency <= 0 {
-		return fmt.Errorf("poll_freqency must be greater than 0")
-	}
-
 	c.mut.Lock()
 	defer c.mut.Unlock()
 	c.args = newArgs
@@ -219,15 +471,7 @@ func (c *Component) configureDetector() error {
 	}
 
 	var err error
-
-	reloadFile := func() {
-		select {
-		case c.reloadCh <- struct{}{}:
-		default:
-			// no-op: a reload is already queued so we don't need to queue a second
-			// one.
-		}
-	}
+	reloadFile := c.queueReload
 
 	switch c.args.Type {
 	case DetectorPoll:
@@ -243,6 +487,13 @@ func (c *Component) configureDetector() error {
 			ReloadFile:   reloadFile,
 			PollFreqency: c.args.PollFrequency,
 		})
+	case DetectorFSNotifyDir:
+		c.detector, err = newFSNotifyDir(fsNotifyOptions{
+			Logger:       c.opts.Logger,
+			Filename:     c.args.Filename,
+			ReloadFile:   reloadFile,
+			PollFreqency: c.args.PollFrequency,
+		})
 	}
 
 	return err
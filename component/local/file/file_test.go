@@ -1,17 +1,28 @@
 package file_test
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/component"
 	"github.com/grafana/agent/component/local/file"
 	"github.com/grafana/agent/pkg/flow/componenttest"
 	"github.com/grafana/agent/pkg/flow/hcltypes"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
 )
 
 func TestFile(t *testing.T) {
@@ -22,6 +33,10 @@ func TestFile(t *testing.T) {
 	t.Run("Event change detector", func(t *testing.T) {
 		runFileTests(t, file.DetectorFSNotify)
 	})
+
+	t.Run("Directory event change detector", func(t *testing.T) {
+		runFileTests(t, file.DetectorFSNotifyDir)
+	})
 }
 
 // runFileTests will run a suite of tests with the configured update type.
@@ -51,6 +66,7 @@ func runFileTests(t *testing.T, ut file.Detector) {
 				IsSecret: false,
 				Value:    "First load!",
 			},
+			Exists: true,
 		}, tc.Exports())
 		return tc
 	}
@@ -68,6 +84,7 @@ func runFileTests(t *testing.T, ut file.Detector) {
 				IsSecret: false,
 				Value:    "New content!",
 			},
+			Exists: true,
 		}, sc.Exports())
 	})
 
@@ -85,6 +102,7 @@ func runFileTests(t *testing.T, ut file.Detector) {
 				IsSecret: false,
 				Value:    "New content!",
 			},
+			Exists: true,
 		}, sc.Exports())
 	})
 }
@@ -112,6 +130,7 @@ func TestFile_ImmediateExports(t *testing.T) {
 			IsSecret: false,
 			Value:    "Hello, world!",
 		},
+		Exists: true,
 	}, tc.Exports())
 }
 
@@ -133,9 +152,402 @@ func TestFile_ExistOnLoad(t *testing.T) {
 	require.ErrorAs(t, err, &expectErr)
 }
 
+// TestFile_WaitForFile ensures that missing files are retried when
+// wait_for_file is set.
+func TestFile_WaitForFile(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "testfile")
+
+	tc, err := componenttest.NewControllerFromID(nil, "local.file")
+	require.NoError(t, err)
+	go func() {
+		err := tc.Run(componenttest.TestContext(t), file.Arguments{
+			Filename:      testFile,
+			Type:          file.DetectorPoll,
+			PollFrequency: 1 * time.Hour,
+			WaitForFile:   true,
+		})
+		require.NoError(t, err)
+	}()
+
+	require.NoError(t, tc.WaitExports(time.Second))
+	require.Equal(t, file.Exports{
+		Content: &hcltypes.OptionalSecret{},
+		Exists:  false,
+	}, tc.Exports())
+
+	// The poll frequency is too long to pick up the file, so it must be found
+	// by retrying.
+	require.NoError(t, os.WriteFile(testFile, []byte("Hello, world!"), 0664))
+
+	require.NoError(t, tc.WaitExports(2*time.Second))
+	require.Equal(t, file.Exports{
+		Content: &hcltypes.OptionalSecret{Value: "Hello, world!"},
+		Exists:  true,
+	}, tc.Exports())
+}
+
 // canceledContext creates a context which is already canceled.
 func canceledContext() context.Context {
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
 	return ctx
 }
+
+// TestFile_Format validates that file content is decoded into a structured
+// value when a format is set.
+func TestFile_Format(t *testing.T) {
+	expect := cty.ObjectVal(map[string]cty.Value{
+		"name":  cty.StringVal("agent"),
+		"ports": cty.TupleVal([]cty.Value{cty.NumberIntVal(80), cty.NumberIntVal(443)}),
+	})
+
+	tt := []struct {
+		format  file.Format
+		content string
+	}{
+		{file.FormatJSON, `{"name": "agent", "ports": [80, 443]}`},
+		{file.FormatYAML, "name: agent\nports: [80, 443]\n"},
+		{file.FormatTOML, "name = \"agent\"\nports = [80, 443]\n"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.format.String(), func(t *testing.T) {
+			testFile := filepath.Join(t.TempDir(), "testfile")
+			require.NoError(t, os.WriteFile(testFile, []byte(tc.content), 0664))
+
+			ctrl, err := componenttest.NewControllerFromID(nil, "local.file")
+			require.NoError(t, err)
+			go func() {
+				err := ctrl.Run(componenttest.TestContext(t), file.Arguments{
+					Filename:      testFile,
+					Type:          file.DetectorPoll,
+					PollFrequency: 1 * time.Hour,
+					Format:        tc.format,
+				})
+				require.NoError(t, err)
+			}()
+
+			require.NoError(t, ctrl.WaitExports(time.Second))
+			exports := ctrl.Exports().(file.Exports)
+			require.NotNil(t, exports.Value)
+			require.True(t, expect.Equals(*exports.Value).True(), "unexpected value %#v", *exports.Value)
+		})
+	}
+}
+
+// TestFile_FormatInvalid ensures that content which can't be decoded is
+// reported as an error.
+func TestFile_FormatInvalid(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "testfile")
+	require.NoError(t, os.WriteFile(testFile, []byte("{not json"), 0664))
+
+	tc, err := componenttest.NewControllerFromID(nil, "local.file")
+	require.NoError(t, err)
+
+	err = tc.Run(canceledContext(), file.Arguments{
+		Filename:      testFile,
+		Type:          file.DetectorPoll,
+		PollFrequency: 1 * time.Hour,
+		Format:        file.FormatJSON,
+	})
+	require.Error(t, err)
+}
+
+func TestFile_Decode(t *testing.T) {
+	var gzipped bytes.Buffer
+	gw := gzip.NewWriter(&gzipped)
+	_, err := gw.Write([]byte("-----BEGIN CERTIFICATE-----"))
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+
+	tt := []struct {
+		decode  file.Encoding
+		content []byte
+	}{
+		{file.EncodingBase64, []byte(base64.StdEncoding.EncodeToString([]byte("-----BEGIN CERTIFICATE-----")) + "\n")},
+		{file.EncodingGzip, gzipped.Bytes()},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.decode.String(), func(t *testing.T) {
+			testFile := filepath.Join(t.TempDir(), "testfile")
+			require.NoError(t, os.WriteFile(testFile, tc.content, 0664))
+
+			ctrl, err := componenttest.NewControllerFromID(nil, "local.file")
+			require.NoError(t, err)
+			go func() {
+				err := ctrl.Run(componenttest.TestContext(t), file.Arguments{
+					Filename:      testFile,
+					Type:          file.DetectorPoll,
+					PollFrequency: 1 * time.Hour,
+					IsSecret:      true,
+					Decode:        tc.decode,
+				})
+				require.NoError(t, err)
+			}()
+
+			require.NoError(t, ctrl.WaitExports(time.Second))
+			require.Equal(t, file.Exports{
+				Content: &hcltypes.OptionalSecret{IsSecret: true, Value: "-----BEGIN CERTIFICATE-----"},
+				Exists:  true,
+			}, ctrl.Exports())
+		})
+	}
+}
+
+// TestFile_DecodeInvalid ensures that content which isn't encoded as
+// expected is reported as an error.
+func TestFile_DecodeInvalid(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "testfile")
+	require.NoError(t, os.WriteFile(testFile, []byte("not gzip"), 0664))
+
+	tc, err := componenttest.NewControllerFromID(nil, "local.file")
+	require.NoError(t, err)
+
+	err = tc.Run(canceledContext(), file.Arguments{
+		Filename:      testFile,
+		Type:          file.DetectorPoll,
+		PollFrequency: 1 * time.Hour,
+		Decode:        file.EncodingGzip,
+	})
+	require.Error(t, err)
+}
+
+func TestFile_DecodeTooLarge(t *testing.T) {
+	var gzipped bytes.Buffer
+	gw := gzip.NewWriter(&gzipped)
+	_, err := io.CopyN(gw, zeroReader{}, file.MaxDecodedSize+1)
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+
+	testFile := filepath.Join(t.TempDir(), "testfile")
+	require.NoError(t, os.WriteFile(testFile, gzipped.Bytes(), 0664))
+
+	tc, err := componenttest.NewControllerFromID(nil, "local.file")
+	require.NoError(t, err)
+
+	err = tc.Run(canceledContext(), file.Arguments{
+		Filename:      testFile,
+		Type:          file.DetectorPoll,
+		PollFrequency: 1 * time.Hour,
+		Decode:        file.EncodingGzip,
+	})
+	require.ErrorContains(t, err, "decompressed content exceeds")
+}
+
+// zeroReader is an io.Reader of an infinite stream of zeros.
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+// TestFile_StableFor ensures that files which are still being written to are
+// not exported until they stop changing.
+func TestFile_StableFor(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "testfile")
+	require.NoError(t, os.WriteFile(testFile, []byte("First load!"), 0664))
+
+	tc, err := componenttest.NewControllerFromID(nil, "local.file")
+	require.NoError(t, err)
+	go func() {
+		err := tc.Run(componenttest.TestContext(t), file.Arguments{
+			Filename:      testFile,
+			Type:          file.DetectorPoll,
+			PollFrequency: 20 * time.Millisecond,
+			Debounce:      10 * time.Millisecond,
+			StableFor:     300 * time.Millisecond,
+		})
+		require.NoError(t, err)
+	}()
+	require.NoError(t, tc.WaitExports(time.Second))
+
+	// Simulate a slow writer which pauses between writes.
+	f, err := os.OpenFile(testFile, os.O_WRONLY|os.O_TRUNC, 0664)
+	require.NoError(t, err)
+	_, err = f.WriteString("Partial")
+	require.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+	_, err = f.WriteString(" content")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	require.NoError(t, tc.WaitExports(2*time.Second))
+	require.Equal(t, file.Exports{
+		Content: &hcltypes.OptionalSecret{Value: "Partial content"},
+		Exists:  true,
+	}, tc.Exports())
+}
+
+// TestFile_SymlinkSwap ensures that the fsnotify_dir detector picks up files
+// which are updated the way Kubernetes updates projected volumes: by
+// atomically swapping a ..data symlink to point to a new directory.
+func TestFile_SymlinkSwap(t *testing.T) {
+	dir := t.TempDir()
+
+	writeVersion := func(version, content string) {
+		require.NoError(t, os.Mkdir(filepath.Join(dir, version), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, version, "token"), []byte(content), 0664))
+	}
+	writeVersion("..v1", "First load!")
+	require.NoError(t, os.Symlink("..v1", filepath.Join(dir, "..data")))
+	require.NoError(t, os.Symlink(filepath.Join("..data", "token"), filepath.Join(dir, "token")))
+
+	tc, err := componenttest.NewControllerFromID(nil, "local.file")
+	require.NoError(t, err)
+	go func() {
+		err := tc.Run(componenttest.TestContext(t), file.Arguments{
+			Filename: filepath.Join(dir, "token"),
+			Type:     file.DetectorFSNotifyDir,
+
+			// Use a long polling frequency so that only filesystem events cause
+			// the file to be reread.
+			PollFrequency: 1 * time.Hour,
+		})
+		require.NoError(t, err)
+	}()
+	require.NoError(t, tc.WaitExports(time.Second))
+
+	// Swap the ..data symlink to point to a new version.
+	writeVersion("..v2", "New content!")
+	require.NoError(t, os.Symlink("..v2", filepath.Join(dir, "..data_tmp")))
+	require.NoError(t, os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")))
+	require.NoError(t, os.RemoveAll(filepath.Join(dir, "..v1")))
+
+	require.NoError(t, tc.WaitExports(time.Second))
+	require.Equal(t, file.Exports{
+		Content: &hcltypes.OptionalSecret{Value: "New content!"},
+		Exists:  true,
+	}, tc.Exports())
+}
+
+func TestFile_Metrics(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "testfile")
+	require.NoError(t, os.WriteFile(testFile, []byte("Hello, world!"), 0664))
+
+	tc, err := componenttest.NewControllerFromID(nil, "local.file")
+	require.NoError(t, err)
+	go func() {
+		err := tc.Run(componenttest.TestContext(t), file.Arguments{
+			Filename:      testFile,
+			Type:          file.DetectorPoll,
+			PollFrequency: 1 * time.Hour,
+		})
+		require.NoError(t, err)
+	}()
+	require.NoError(t, tc.WaitRunning(time.Second))
+
+	expect := `
+		# HELP local_file_read_errors_total Total number of times the file failed to be read or decoded.
+		# TYPE local_file_read_errors_total counter
+		local_file_read_errors_total 0
+		# HELP local_file_reads_total Total number of times the file was read.
+		# TYPE local_file_reads_total counter
+		local_file_reads_total 1
+		# HELP local_file_size_bytes Size of the file in bytes as of the last read, before decoding.
+		# TYPE local_file_size_bytes gauge
+		local_file_size_bytes 13
+	`
+	require.NoError(t, testutil.GatherAndCompare(tc.Registry(), strings.NewReader(expect),
+		"local_file_read_errors_total", "local_file_reads_total", "local_file_size_bytes"))
+
+	families, err := tc.Registry().Gather()
+	require.NoError(t, err)
+	var lastRead float64
+	for _, mf := range families {
+		if mf.GetName() == "local_file_last_successful_read_timestamp_seconds" {
+			lastRead = mf.GetMetric()[0].GetGauge().GetValue()
+		}
+	}
+	require.InDelta(t, float64(time.Now().Unix()), lastRead, 60)
+}
+
+// TestFile_DebugStream ensures that reads of the file are published to the
+// debug stream.
+func TestFile_DebugStream(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "testfile")
+
+	tc, err := componenttest.NewControllerFromID(nil, "local.file")
+	require.NoError(t, err)
+	events := tc.SubscribeDebugStream(componenttest.TestContext(t))
+
+	go func() {
+		err := tc.Run(componenttest.TestContext(t), file.Arguments{
+			Filename:      testFile,
+			Type:          file.DetectorPoll,
+			PollFrequency: 1 * time.Hour,
+			WaitForFile:   true,
+		})
+		require.NoError(t, err)
+	}()
+
+	e := <-events
+	require.Equal(t, "waiting", e.Type)
+	require.Contains(t, e.Message, "file does not exist")
+
+	require.NoError(t, os.WriteFile(testFile, []byte("Hello, world!"), 0664))
+
+	select {
+	case e = <-events:
+	case <-time.After(2 * time.Second):
+		require.FailNow(t, "timed out waiting for debug event")
+	}
+	require.Equal(t, "read", e.Type)
+	require.Equal(t, map[string]interface{}{"filename": testFile, "size_bytes": 13.0}, toMap(t, e.Data))
+}
+
+// toMap converts v to a map by encoding it as JSON.
+func toMap(t *testing.T, v interface{}) map[string]interface{} {
+	t.Helper()
+
+	bb, err := json.Marshal(v)
+	require.NoError(t, err)
+	var m map[string]interface{}
+	require.NoError(t, json.Unmarshal(bb, &m))
+	return m
+}
+
+// TestFile_MetricsAfterFailedBuild ensures that a failed build doesn't leave
+// metrics registered, which would prevent later builds from succeeding.
+func TestFile_MetricsAfterFailedBuild(t *testing.T) {
+	var (
+		testFile = filepath.Join(t.TempDir(), "testfile")
+		reg      = prometheus.NewRegistry()
+		opts     = component.Options{
+			ID:            "local.file.test",
+			Logger:        log.NewNopLogger(),
+			Registerer:    reg,
+			DebugStream:   nopDebugStream{},
+			OnStateChange: func(component.Exports) {},
+		}
+		args = file.Arguments{
+			Filename:      testFile,
+			Type:          file.DetectorPoll,
+			PollFrequency: 1 * time.Hour,
+		}
+	)
+
+	_, err := file.New(opts, args)
+	require.Error(t, err)
+
+	require.NoError(t, os.WriteFile(testFile, []byte("Hello, world!"), 0664))
+	_, err = file.New(opts, args)
+	require.NoError(t, err)
+}
+
+type nopDebugStream struct{}
+
+func (nopDebugStream) Active() bool                 { return false }
+func (nopDebugStream) Publish(component.DebugEvent) {}
+
+func TestArguments_Validate(t *testing.T) {
+	args := file.DefaultArguments
+	args.Filename = "/tmp/file"
+	require.NoError(t, args.Validate())
+
+	args.Debounce = args.PollFrequency
+	require.EqualError(t, args.Validate(), "debounce: must be less than poll_frequency")
+}
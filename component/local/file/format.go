@@ -0,0 +1,108 @@
+package file
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pelletier/go-toml"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is used to specify how the content of the file should be decoded.
+type Format int
+
+const (
+	// FormatNone exports the file content as a raw string only.
+	FormatNone Format = iota
+	// FormatJSON decodes the file content as JSON.
+	FormatJSON
+	// FormatYAML decodes the file content as YAML.
+	FormatYAML
+	// FormatTOML decodes the file content as TOML.
+	FormatTOML
+)
+
+var (
+	_ encoding.TextMarshaler   = Format(0)
+	_ encoding.TextUnmarshaler = (*Format)(nil)
+)
+
+// String returns the string representation of the Format.
+func (f Format) String() string {
+	switch f {
+	case FormatNone:
+		return "none"
+	case FormatJSON:
+		return "json"
+	case FormatYAML:
+		return "yaml"
+	case FormatTOML:
+		return "toml"
+	default:
+		return fmt.Sprintf("Format(%d)", f)
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (f Format) MarshalText() (text []byte, err error) {
+	return []byte(f.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (f *Format) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "", "none":
+		*f = FormatNone
+	case "json":
+		*f = FormatJSON
+	case "yaml":
+		*f = FormatYAML
+	case "toml":
+		*f = FormatTOML
+	default:
+		return fmt.Errorf("unrecognized format %q, expected none, json, yaml, or toml", string(text))
+	}
+	return nil
+}
+
+// decodeContent decodes bb into a structured value according to f. Documents
+// are converted to JSON before being mapped to HCL values so every format
+// produces the same types: objects, tuples, strings, numbers, and bools.
+func decodeContent(f Format, bb []byte) (cty.Value, error) {
+	var (
+		jsonBytes []byte
+		err       error
+	)
+
+	switch f {
+	case FormatJSON:
+		jsonBytes = bb
+	case FormatYAML:
+		var v interface{}
+		if err := yaml.Unmarshal(bb, &v); err != nil {
+			return cty.NilVal, fmt.Errorf("decoding yaml: %w", err)
+		}
+		jsonBytes, err = json.Marshal(v)
+	case FormatTOML:
+		var tree *toml.Tree
+		tree, err = toml.LoadBytes(bb)
+		if err != nil {
+			return cty.NilVal, fmt.Errorf("decoding toml: %w", err)
+		}
+		jsonBytes, err = json.Marshal(tree.ToMap())
+	default:
+		return cty.NilVal, fmt.Errorf("cannot decode content with format %s", f)
+	}
+	if err != nil {
+		return cty.NilVal, fmt.Errorf("converting %s to json: %w", f, err)
+	}
+
+	ty, err := ctyjson.ImpliedType(jsonBytes)
+	if err != nil {
+		return cty.NilVal, fmt.Errorf("decoding %s: %w", f, err)
+	}
+	return ctyjson.Unmarshal(jsonBytes, ty)
+}
@@ -0,0 +1,42 @@
+package file
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// metrics holds the metrics for a local.file component.
+type metrics struct {
+	readsTotal        prometheus.Counter
+	readErrorsTotal   prometheus.Counter
+	lastReadTimestamp prometheus.Gauge
+	fileSizeBytes     prometheus.Gauge
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		readsTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "local_file_reads_total",
+			Help: "Total number of times the file was read.",
+		}),
+		readErrorsTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "local_file_read_errors_total",
+			Help: "Total number of times the file failed to be read or decoded.",
+		}),
+		lastReadTimestamp: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "local_file_last_successful_read_timestamp_seconds",
+			Help: "Unix timestamp of the last successful read of the file.",
+		}),
+		fileSizeBytes: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "local_file_size_bytes",
+			Help: "Size of the file in bytes as of the last read, before decoding.",
+		}),
+	}
+}
+
+// register registers the metrics with reg.
+func (m *metrics) register(reg prometheus.Registerer) error {
+	for _, c := range []prometheus.Collector{m.readsTotal, m.readErrorsTotal, m.lastReadTimestamp, m.fileSizeBytes} {
+		if err := reg.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+// Package filewrite implements the local.file_write component.
+package filewrite
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/pkg/flow/hcltypes"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/rfratto/gohcl"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name: "local.file_write",
+		Args: Arguments{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Arguments holds values which are used to configure the local.file_write
+// component.
+type Arguments struct {
+	// Filename is the path of the file to write.
+	Filename string `hcl:"filename,attr"`
+	// Content is written to Filename. Content is a secret so it's never
+	// displayed to the user, regardless of where it came from.
+	Content hcltypes.Secret `hcl:"content,attr"`
+	// Mode is the octal permission mode of the written file.
+	Mode string `hcl:"mode,optional"`
+	// Owner is the user name or ID to change the owner of the file to.
+	Owner string `hcl:"owner,optional"`
+	// Group is the group name or ID to change the group of the file to.
+	Group string `hcl:"group,optional"`
+}
+
+// DefaultArguments provides the default arguments for the local.file_write
+// component.
+var DefaultArguments = Arguments{
+	Mode: "0600",
+}
+
+var _ gohcl.Decoder = (*Arguments)(nil)
+
+// DecodeHCL implements gohcl.Decoder.
+func (a *Arguments) DecodeHCL(body hcl.Body, ctx *hcl.EvalContext) error {
+	*a = DefaultArguments
+
+	type arguments Arguments
+	if err := gohcl.DecodeBody(body, ctx, (*arguments)(a)); err != nil {
+		return err
+	}
+	_, err := a.fileMode()
+	return err
+}
+
+// fileMode parses Mode as an octal permission mode.
+func (a *Arguments) fileMode() (os.FileMode, error) {
+	mode, err := strconv.ParseUint(a.Mode, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("mode %q must be an octal permission mode such as \"0640\"", a.Mode)
+	}
+	return os.FileMode(mode), nil
+}
+
+// Component implements the local.file_write component.
+type Component struct {
+	opts component.Options
+
+	mut     sync.Mutex
+	args    Arguments
+	written bool
+
+	healthMut sync.RWMutex
+	health    component.Health
+}
+
+var (
+	_ component.Component       = (*Component)(nil)
+	_ component.HealthComponent = (*Component)(nil)
+)
+
+// New creates a new local.file_write component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	c := &Component{opts: o}
+
+	// Perform an update which will immediately write the file.
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	if newArgs.Filename == "" {
+		return fmt.Errorf("filename must not be empty")
+	}
+	mode, err := newArgs.fileMode()
+	if err != nil {
+		return err
+	}
+	uid, gid, err := lookupOwnership(newArgs.Owner, newArgs.Group)
+	if err != nil {
+		return err
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	// Flow may re-evaluate the component with identical arguments; avoid
+	// rewriting the file when nothing changed.
+	if c.written && c.args == newArgs {
+		return nil
+	}
+
+	if err := writeFile(newArgs.Filename, []byte(newArgs.Content), mode, uid, gid); err != nil {
+		level.Error(c.opts.Logger).Log("msg", "failed to write file", "path", newArgs.Filename, "err", err)
+		c.written = false
+		c.setHealth(component.Health{
+			Health:     component.HealthTypeUnhealthy,
+			Message:    fmt.Sprintf("failed to write file: %s", err),
+			UpdateTime: time.Now(),
+		})
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	c.args = newArgs
+	c.written = true
+	c.setHealth(component.Health{
+		Health:     component.HealthTypeHealthy,
+		Message:    "wrote file",
+		UpdateTime: time.Now(),
+	})
+	return nil
+}
+
+// CurrentHealth implements component.HealthComponent.
+func (c *Component) CurrentHealth() component.Health {
+	c.healthMut.RLock()
+	defer c.healthMut.RUnlock()
+	return c.health
+}
+
+func (c *Component) setHealth(h component.Health) {
+	c.healthMut.Lock()
+	defer c.healthMut.Unlock()
+	c.health = h
+}
+
+// writeFile atomically writes bb to filename by writing to a temporary file
+// in the same directory and renaming it over filename. Readers never observe
+// a partially written file. uid and gid are left unchanged when -1.
+func writeFile(filename string, bb []byte, mode os.FileMode, uid, gid int) (err error) {
+	dir, base := filepath.Split(filename)
+	if dir == "" {
+		dir = "."
+	}
+
+	f, err := os.CreateTemp(dir, "."+base+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		}
+	}()
+
+	// Permissions and ownership are set before writing any content so the
+	// content is never readable by anyone it's not meant for.
+	if err := f.Chmod(mode); err != nil {
+		return err
+	}
+	if uid != -1 || gid != -1 {
+		if err := f.Chown(uid, gid); err != nil {
+			return err
+		}
+	}
+
+	if _, err := f.Write(bb); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filename)
+}
+
+// lookupOwnership resolves owner and group to numeric IDs. Empty values
+// resolve to -1, leaving the current value unchanged.
+func lookupOwnership(owner, group string) (uid, gid int, err error) {
+	uid, gid = -1, -1
+
+	if owner != "" {
+		id := owner
+		if _, err := strconv.Atoi(owner); err != nil {
+			u, err := user.Lookup(owner)
+			if err != nil {
+				return 0, 0, fmt.Errorf("looking up owner: %w", err)
+			}
+			id = u.Uid
+		}
+		if uid, err = strconv.Atoi(id); err != nil {
+			return 0, 0, fmt.Errorf("owner %q does not have a numeric ID", owner)
+		}
+	}
+
+	if group != "" {
+		id := group
+		if _, err := strconv.Atoi(group); err != nil {
+			g, err := user.LookupGroup(group)
+			if err != nil {
+				return 0, 0, fmt.Errorf("looking up group: %w", err)
+			}
+			id = g.Gid
+		}
+		if gid, err = strconv.Atoi(id); err != nil {
+			return 0, 0, fmt.Errorf("group %q does not have a numeric ID", group)
+		}
+	}
+
+	return uid, gid, nil
+}
@@ -0,0 +1,174 @@
+package filewrite_test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/local/filewrite"
+	"github.com/grafana/agent/pkg/flow/componenttest"
+	"github.com/grafana/agent/pkg/flow/hcltypes"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/rfratto/gohcl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileWrite(t *testing.T) {
+	dir := t.TempDir()
+	testFile := filepath.Join(dir, "creds")
+
+	ctrl, err := componenttest.NewControllerFromID(nil, "local.file_write")
+	require.NoError(t, err)
+	go func() {
+		err := ctrl.Run(componenttest.TestContext(t), filewrite.Arguments{
+			Filename: testFile,
+			Content:  hcltypes.Secret("password"),
+			Mode:     "0640",
+		})
+		require.NoError(t, err)
+	}()
+
+	require.Eventually(t, func() bool {
+		bb, err := os.ReadFile(testFile)
+		return err == nil && string(bb) == "password"
+	}, time.Second, 10*time.Millisecond)
+
+	if runtime.GOOS != "windows" {
+		fi, err := os.Stat(testFile)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0640), fi.Mode().Perm())
+	}
+
+	// No temporary files are left behind.
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
+
+func TestFileWrite_Update(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "config.yml")
+
+	ctrl, err := componenttest.NewControllerFromID(nil, "local.file_write")
+	require.NoError(t, err)
+	go func() {
+		err := ctrl.Run(componenttest.TestContext(t), filewrite.Arguments{
+			Filename: testFile,
+			Content:  hcltypes.Secret("a: 1"),
+			Mode:     "0600",
+		})
+		require.NoError(t, err)
+	}()
+	require.NoError(t, ctrl.WaitRunning(time.Second))
+
+	require.NoError(t, ctrl.Update(filewrite.Arguments{
+		Filename: testFile,
+		Content:  hcltypes.Secret("a: 2"),
+		Mode:     "0600",
+	}))
+
+	bb, err := os.ReadFile(testFile)
+	require.NoError(t, err)
+	require.Equal(t, "a: 2", string(bb))
+}
+
+// TestFileWrite_MissingDirectory ensures that the file must be writable on
+// the first load of local.file_write.
+func TestFileWrite_MissingDirectory(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "missing", "creds")
+
+	ctrl, err := componenttest.NewControllerFromID(nil, "local.file_write")
+	require.NoError(t, err)
+
+	err = ctrl.Run(componenttest.TestContext(t), filewrite.Arguments{
+		Filename: testFile,
+		Content:  hcltypes.Secret("password"),
+		Mode:     "0600",
+	})
+	require.Error(t, err)
+}
+
+func TestFileWrite_Health(t *testing.T) {
+	dir := t.TempDir()
+	args := filewrite.Arguments{
+		Filename: filepath.Join(dir, "creds"),
+		Content:  hcltypes.Secret("password"),
+		Mode:     "0600",
+	}
+
+	c, err := filewrite.New(component.Options{Logger: util.TestLogger(t)}, args)
+	require.NoError(t, err)
+	require.Equal(t, component.HealthTypeHealthy, c.CurrentHealth().Health)
+
+	args.Filename = filepath.Join(dir, "missing", "creds")
+	require.Error(t, c.Update(args))
+	require.Equal(t, component.HealthTypeUnhealthy, c.CurrentHealth().Health)
+	require.Contains(t, c.CurrentHealth().Message, "failed to write file")
+}
+
+func TestArguments_DecodeHCL(t *testing.T) {
+	tt := []struct {
+		name   string
+		in     string
+		expect filewrite.Arguments
+		err    bool
+	}{
+		{
+			name: "defaults",
+			in: `
+				filename = "/tmp/creds"
+				content  = "password"
+			`,
+			expect: filewrite.Arguments{
+				Filename: "/tmp/creds",
+				Content:  "password",
+				Mode:     "0600",
+			},
+		},
+		{
+			name: "ownership",
+			in: `
+				filename = "/tmp/creds"
+				content  = "password"
+				mode     = "0644"
+				owner    = "1000"
+				group    = "1000"
+			`,
+			expect: filewrite.Arguments{
+				Filename: "/tmp/creds",
+				Content:  "password",
+				Mode:     "0644",
+				Owner:    "1000",
+				Group:    "1000",
+			},
+		},
+		{
+			name: "invalid mode",
+			in: `
+				filename = "/tmp/creds"
+				content  = "password"
+				mode     = "rw-r--r--"
+			`,
+			err: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			file, diags := hclparse.NewParser().ParseHCL([]byte(tc.in), "agent-config.flow")
+			require.False(t, diags.HasErrors())
+
+			var args filewrite.Arguments
+			diags = gohcl.DecodeBody(file.Body, nil, &args)
+			if tc.err {
+				require.True(t, diags.HasErrors())
+				return
+			}
+			require.False(t, diags.HasErrors())
+			require.Equal(t, tc.expect, args)
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package encrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/grafana/regexp"
+)
+
+// ValuePrefix is the prefix of every encrypted value written to a log line.
+// The version allows changing the encryption scheme in the future.
+const ValuePrefix = "enc:v1:"
+
+// minKeyBits is the minimum size of accepted RSA keys.
+const minKeyBits = 2048
+
+// parsePublicKey parses a PEM-encoded RSA public key in either PKIX ("PUBLIC
+// KEY") or PKCS #1 ("RSA PUBLIC KEY") form.
+func parsePublicKey(s string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(s))
+	if block == nil {
+		return nil, fmt.Errorf("public_key must be PEM-encoded")
+	}
+
+	var key *rsa.PublicKey
+	switch block.Type {
+	case "PUBLIC KEY":
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid public_key: %w", err)
+		}
+		rsaKey, ok := pub.(*rsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("public_key must be an RSA key, got %T", pub)
+		}
+		key = rsaKey
+	case "RSA PUBLIC KEY":
+		rsaKey, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid public_key: %w", err)
+		}
+		key = rsaKey
+	default:
+		return nil, fmt.Errorf("unsupported public_key PEM block type %q", block.Type)
+	}
+
+	if key.N.BitLen() < minKeyBits {
+		return nil, fmt.Errorf("public_key must be at least %d bits, got %d", minKeyBits, key.N.BitLen())
+	}
+	return key, nil
+}
+
+// encryptor encrypts the values captured by a regular expression.
+type encryptor struct {
+	key    *rsa.PublicKey
+	re     *regexp.Regexp
+	groups []int // Sorted indexes of the capture groups to encrypt
+}
+
+// EncryptLine returns line with the values of all captured fields replaced
+// by their encrypted form, along with the number of values encrypted. Lines
+// which don't match are returned unmodified.
+func (e *encryptor) EncryptLine(line string) (string, int, error) {
+	matches := e.re.FindAllStringSubmatchIndex(line, -1)
+	if len(matches) == 0 {
+		return line, 0, nil
+	}
+
+	var (
+		sb        strings.Builder
+		last      int
+		encrypted int
+	)
+	for _, match := range matches {
+		for _, group := range e.groups {
+			start, end := match[2*group], match[2*group+1]
+			// Skip groups which didn't participate in the match or captured
+			// nothing, as well as groups nested in an already encrypted group.
+			if start < 0 || start == end || start < last {
+				continue
+			}
+
+			value, err := encryptValue(e.key, line[start:end])
+			if err != nil {
+				return "", 0, err
+			}
+			sb.WriteString(line[last:start])
+			sb.WriteString(value)
+			last = end
+			encrypted++
+		}
+	}
+	sb.WriteString(line[last:])
+	return sb.String(), encrypted, nil
+}
+
+// encryptValue encrypts value with a new random AES-256-GCM key, which is
+// itself encrypted with RSA-OAEP using SHA-256. The result is ValuePrefix
+// followed by the standard base64 encoding of the encrypted key, the nonce,
+// and the sealed value.
+func encryptValue(key *rsa.PublicKey, value string) (string, error) {
+	dataKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return "", err
+	}
+	encryptedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, key, dataKey, nil)
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	out := make([]byte, 0, len(encryptedKey)+len(nonce)+len(value)+gcm.Overhead())
+	out = append(out, encryptedKey...)
+	out = append(out, nonce...)
+	out = gcm.Seal(out, nonce, []byte(value), nil)
+	return ValuePrefix + base64.StdEncoding.EncodeToString(out), nil
+}
+
+// DecryptValue decrypts a single value encrypted by loki.encrypt with the
+// private key matching the component's public key.
+func DecryptValue(key *rsa.PrivateKey, value string) (string, error) {
+	if !strings.HasPrefix(value, ValuePrefix) {
+		return "", fmt.Errorf("value is missing the %q prefix", ValuePrefix)
+	}
+	raw, err := base64.StdEncoding.DecodeString(value[len(ValuePrefix):])
+	if err != nil {
+		return "", err
+	}
+
+	keySize := key.Size()
+	if len(raw) < keySize {
+		return "", fmt.Errorf("value is too short")
+	}
+	dataKey, err := rsa.DecryptOAEP(sha256.New(), nil, key, raw[:keySize], nil)
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return "", err
+	}
+	raw = raw[keySize:]
+	if len(raw) < gcm.NonceSize() {
+		return "", fmt.Errorf("value is too short")
+	}
+	plaintext, err := gcm.Open(nil, raw[:gcm.NonceSize()], raw[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,224 @@
+// Package encrypt implements the loki.encrypt component.
+package encrypt
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/loki"
+	"github.com/grafana/regexp"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rfratto/gohcl"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:    "loki.encrypt",
+		Args:    Arguments{},
+		Exports: Exports{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Arguments holds values which are used to configure the loki.encrypt
+// component.
+type Arguments struct {
+	// PublicKey is the PEM-encoded RSA public key values are encrypted with.
+	PublicKey string `hcl:"public_key,attr"`
+	// Expression is a regular expression whose named capture groups capture
+	// the fields to encrypt.
+	Expression string `hcl:"expression,attr"`
+	// Fields are the names of the capture groups to encrypt. All named capture
+	// groups are encrypted if empty.
+	Fields []string `hcl:"fields,optional"`
+
+	// ForwardTo receives the log entries with encrypted fields.
+	ForwardTo []*loki.LogsReceiver `hcl:"forward_to,attr"`
+}
+
+var _ gohcl.Decoder = (*Arguments)(nil)
+
+// DecodeHCL implements gohcl.Decoder.
+func (args *Arguments) DecodeHCL(body hcl.Body, ctx *hcl.EvalContext) error {
+	*args = Arguments{}
+
+	type arguments Arguments
+	if err := gohcl.DecodeBody(body, ctx, (*arguments)(args)); err != nil {
+		return err
+	}
+
+	// Build an encryptor so invalid keys and expressions are reported while
+	// the config is being loaded.
+	_, err := args.newEncryptor()
+	return err
+}
+
+// newEncryptor builds the encryptor described by args.
+func (args Arguments) newEncryptor() (*encryptor, error) {
+	key, err := parsePublicKey(args.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	re, err := regexp.Compile(args.Expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression: %w", err)
+	}
+	groups, err := fieldGroups(re, args.Fields)
+	if err != nil {
+		return nil, err
+	}
+	return &encryptor{key: key, re: re, groups: groups}, nil
+}
+
+// Exports holds values which are exported by the loki.encrypt component.
+type Exports struct {
+	Receiver *loki.LogsReceiver `hcl:"receiver,attr"`
+}
+
+// Component implements the loki.encrypt component.
+type Component struct {
+	opts     component.Options
+	metrics  *metrics
+	receiver *loki.LogsReceiver
+
+	mut       sync.RWMutex
+	encryptor *encryptor
+	receivers []*loki.LogsReceiver
+}
+
+var _ component.Component = (*Component)(nil)
+
+// New creates a new loki.encrypt component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	m, err := newMetrics(o.Registerer)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Component{
+		opts:     o,
+		metrics:  m,
+		receiver: loki.NewLogsReceiver(),
+	}
+
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+
+	// The receiver never changes, so it's only exported once.
+	o.OnStateChange(Exports{Receiver: c.receiver})
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case entry := <-c.receiver.Chan():
+			c.mut.RLock()
+			encryptor, receivers := c.encryptor, c.receivers
+			c.mut.RUnlock()
+
+			line, encrypted, err := encryptor.EncryptLine(entry.Line)
+			if err != nil {
+				// Entries are dropped rather than forwarded with their sensitive
+				// values in plaintext.
+				level.Error(c.opts.Logger).Log("msg", "failed to encrypt log entry, dropping it", "err", err)
+				c.metrics.entriesDropped.Inc()
+				continue
+			}
+			c.metrics.fieldsEncrypted.Add(float64(encrypted))
+			entry.Line = line
+
+			for _, r := range receivers {
+				if r == nil {
+					continue
+				}
+				_ = r.Send(ctx, entry)
+			}
+		}
+	}
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	encryptor, err := newArgs.newEncryptor()
+	if err != nil {
+		return err
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.encryptor = encryptor
+	c.receivers = newArgs.ForwardTo
+	return nil
+}
+
+// fieldGroups returns the indexes of the capture groups of re to encrypt.
+func fieldGroups(re *regexp.Regexp, fields []string) ([]int, error) {
+	names := make(map[string]int)
+	for i, name := range re.SubexpNames() {
+		if name != "" {
+			names[name] = i
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("expression must have at least one named capture group")
+	}
+
+	var groups []int
+	if len(fields) == 0 {
+		for _, i := range names {
+			groups = append(groups, i)
+		}
+	}
+	for _, f := range fields {
+		i, ok := names[f]
+		if !ok {
+			return nil, fmt.Errorf("field %q is not a named capture group of the expression", f)
+		}
+		groups = append(groups, i)
+	}
+
+	sort.Ints(groups)
+	return groups, nil
+}
+
+// metrics holds the metrics for a loki.encrypt component.
+type metrics struct {
+	fieldsEncrypted prometheus.Counter
+	entriesDropped  prometheus.Counter
+}
+
+func newMetrics(reg prometheus.Registerer) (*metrics, error) {
+	m := &metrics{
+		fieldsEncrypted: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "loki_encrypt_fields_encrypted_total",
+			Help: "Total number of captured field values encrypted.",
+		}),
+		entriesDropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "loki_encrypt_entries_dropped_total",
+			Help: "Total number of log entries dropped because their fields could not be encrypted.",
+		}),
+	}
+
+	for _, c := range []prometheus.Collector{m.fieldsEncrypted, m.entriesDropped} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
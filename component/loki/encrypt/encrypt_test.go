@@ -0,0 +1,245 @@
+package encrypt
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/loki"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/grafana/loki/clients/pkg/promtail/api"
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/rfratto/gohcl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComponent(t *testing.T) {
+	key, publicPEM := generateKey(t)
+	out := loki.NewLogsReceiver()
+
+	args := decodeArguments(t, fmt.Sprintf(`
+		public_key = %q
+		expression = "user=(?P<user>\\S+) ssn=(?P<ssn>\\S+)"
+		fields     = ["ssn"]
+	`, publicPEM))
+	args.ForwardTo = []*loki.LogsReceiver{out}
+
+	reg := prometheus.NewRegistry()
+	var exports Exports
+	c, err := New(component.Options{
+		ID:            "loki.encrypt.test",
+		Logger:        util.TestLogger(t),
+		Registerer:    reg,
+		OnStateChange: func(e component.Exports) { exports = e.(Exports) },
+	}, args)
+	require.NoError(t, err)
+	require.NotNil(t, exports.Receiver)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = c.Run(ctx) }()
+
+	entry := api.Entry{
+		Labels: model.LabelSet{"job": "test"},
+		Entry:  logproto.Entry{Timestamp: time.Now(), Line: "level=info user=bob ssn=123-45-6789 msg=done"},
+	}
+	require.NoError(t, exports.Receiver.Send(ctx, entry))
+
+	var received api.Entry
+	select {
+	case received = <-out.Chan():
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out waiting for entry")
+	}
+
+	require.Equal(t, entry.Labels, received.Labels)
+	require.True(t, strings.HasPrefix(received.Line, "level=info user=bob ssn="+ValuePrefix), "unexpected line %q", received.Line)
+	require.True(t, strings.HasSuffix(received.Line, " msg=done"), "unexpected line %q", received.Line)
+
+	value := strings.Fields(strings.TrimPrefix(received.Line, "level=info user=bob ssn="))[0]
+	plaintext, err := DecryptValue(key, value)
+	require.NoError(t, err)
+	require.Equal(t, "123-45-6789", plaintext)
+
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP loki_encrypt_fields_encrypted_total Total number of captured field values encrypted.
+		# TYPE loki_encrypt_fields_encrypted_total counter
+		loki_encrypt_fields_encrypted_total 1
+	`), "loki_encrypt_fields_encrypted_total"))
+}
+
+func TestEncryptLine(t *testing.T) {
+	key, publicPEM := generateKey(t)
+
+	tt := []struct {
+		name       string
+		expression string
+		fields     []string
+		line       string
+		expect     []string // Expected line with encrypted values decrypted and wrapped in brackets
+	}{
+		{
+			name:       "no match",
+			expression: `ssn=(?P<ssn>\S+)`,
+			line:       "level=info msg=done",
+			expect:     []string{"level=info msg=done"},
+		},
+		{
+			name:       "all groups",
+			expression: `user=(?P<user>\S+) ssn=(?P<ssn>\S+)`,
+			line:       "user=bob ssn=123 msg=done",
+			expect:     []string{"user=", "[bob]", " ssn=", "[123]", " msg=done"},
+		},
+		{
+			name:       "multiple matches",
+			expression: `"email":"(?P<email>[^"]*)"`,
+			line:       `{"email":"a@example.com","cc":{"email":"b@example.com"},"other":{"email":""}}`,
+			expect:     []string{`{"email":"`, "[a@example.com]", `","cc":{"email":"`, "[b@example.com]", `"},"other":{"email":""}}`},
+		},
+		{
+			name:       "nested groups",
+			expression: `card=(?P<card>\d{4}-(?P<last>\d{4}))`,
+			line:       "card=1234-5678",
+			expect:     []string{"card=", "[1234-5678]"},
+		},
+		{
+			name:       "optional group",
+			expression: `user=(?P<user>\S+)( ssn=(?P<ssn>\S+))?`,
+			fields:     []string{"ssn"},
+			line:       "user=bob msg=done",
+			expect:     []string{"user=bob msg=done"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			args := Arguments{PublicKey: publicPEM, Expression: tc.expression, Fields: tc.fields}
+			e, err := args.newEncryptor()
+			require.NoError(t, err)
+
+			line, _, err := e.EncryptLine(tc.line)
+			require.NoError(t, err)
+			require.Equal(t, strings.Join(tc.expect, ""), decryptLine(t, key, line))
+		})
+	}
+}
+
+func TestArguments_Invalid(t *testing.T) {
+	_, publicPEM := generateKey(t)
+
+	smallKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+
+	tt := []struct {
+		name, cfg, err string
+	}{
+		{
+			name: "invalid key",
+			cfg:  `public_key = "not a key"`,
+			err:  "public_key must be PEM-encoded",
+		},
+		{
+			name: "small key",
+			cfg:  fmt.Sprintf(`public_key = %q`, encodePublicKey(t, &smallKey.PublicKey)),
+			err:  "public_key must be at least 2048 bits, got 1024",
+		},
+		{
+			name: "no named groups",
+			cfg:  fmt.Sprintf(`public_key = %q`, publicPEM),
+			err:  "expression must have at least one named capture group",
+		},
+		{
+			name: "unknown field",
+			cfg: fmt.Sprintf(`
+				public_key = %q
+				expression = "ssn=(?P<ssn>\\S+)"
+				fields     = ["email"]
+			`, publicPEM),
+			err: `field "email" is not a named capture group of the expression`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := tc.cfg + "\nforward_to = []\n"
+			if !strings.Contains(cfg, "expression") {
+				cfg += "expression = \"(.*)\"\n"
+			}
+
+			file, diags := hclparse.NewParser().ParseHCL([]byte(cfg), "test.flow")
+			require.False(t, diags.HasErrors(), diags.Error())
+
+			var args Arguments
+			diags = gohcl.DecodeBody(file.Body, nil, &args)
+			require.True(t, diags.HasErrors())
+			require.Contains(t, diags.Error(), tc.err)
+		})
+	}
+}
+
+// decryptLine replaces all encrypted values in line with their decrypted
+// value wrapped in brackets.
+func decryptLine(t *testing.T, key *rsa.PrivateKey, line string) string {
+	t.Helper()
+
+	var sb strings.Builder
+	for {
+		idx := strings.Index(line, ValuePrefix)
+		if idx < 0 {
+			sb.WriteString(line)
+			return sb.String()
+		}
+		sb.WriteString(line[:idx])
+		line = line[idx:]
+
+		end := strings.IndexFunc(line, func(r rune) bool {
+			return r == ' ' || r == '"'
+		})
+		if end < 0 {
+			end = len(line)
+		}
+		plaintext, err := DecryptValue(key, line[:end])
+		require.NoError(t, err)
+		sb.WriteString("[" + plaintext + "]")
+		line = line[end:]
+	}
+}
+
+func generateKey(t *testing.T) (*rsa.PrivateKey, string) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return key, encodePublicKey(t, &key.PublicKey)
+}
+
+func encodePublicKey(t *testing.T, key *rsa.PublicKey) string {
+	t.Helper()
+
+	der, err := x509.MarshalPKIXPublicKey(key)
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func decodeArguments(t *testing.T, cfg string) Arguments {
+	t.Helper()
+
+	file, diags := hclparse.NewParser().ParseHCL([]byte(cfg+"\nforward_to = []\n"), "test.flow")
+	require.False(t, diags.HasErrors(), diags.Error())
+
+	var args Arguments
+	diags = gohcl.DecodeBody(file.Body, nil, &args)
+	require.False(t, diags.HasErrors(), diags.Error())
+	return args
+}
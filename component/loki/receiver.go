@@ -0,0 +1,71 @@
+// Package loki implements shared types for Loki components.
+package loki
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/grafana/loki/clients/pkg/promtail/api"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/rfratto/gohcl"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// LogsReceiver receives log entries. LogsReceivers are exported by components
+// which accept log entries, such as loki.write, and passed to components which
+// produce log entries, such as loki.source.file.
+//
+// LogsReceivers are passed by reference in HCL expressions, so the receiving
+// component keeps using the same LogsReceiver for as long as it's referenced.
+type LogsReceiver struct {
+	entries chan api.Entry
+}
+
+// NewLogsReceiver creates a new LogsReceiver. The component which created the
+// LogsReceiver must read entries from Chan.
+func NewLogsReceiver() *LogsReceiver {
+	return &LogsReceiver{entries: make(chan api.Entry)}
+}
+
+// Chan returns the channel entries sent to the LogsReceiver are delivered to.
+func (r *LogsReceiver) Chan() <-chan api.Entry {
+	return r.entries
+}
+
+// Send sends an entry to the LogsReceiver. Send blocks until the entry is
+// accepted or ctx is canceled, in which case the entry is dropped and the
+// context error is returned.
+func (r *LogsReceiver) Send(ctx context.Context, entry api.Entry) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case r.entries <- entry:
+		return nil
+	}
+}
+
+// LogsReceiverType is the HCL type of a *LogsReceiver.
+var LogsReceiverType cty.Type
+
+func init() {
+	LogsReceiverType = cty.CapsuleWithOps("LogsReceiver", reflect.TypeOf(LogsReceiver{}), &cty.CapsuleOps{
+		ExtensionData: func(key interface{}) interface{} {
+			switch key {
+			case gohcl.CapsuleTokenExtensionKey:
+				// LogsReceivers have no textual representation, so they're encoded
+				// as a placeholder when displaying components.
+				return gohcl.CapsuleTokenExtension(func(v cty.Value) hclwrite.Tokens {
+					return hclwrite.Tokens{
+						{Type: hclsyntax.TokenOParen, Bytes: []byte("(")},
+						{Type: hclsyntax.TokenIdent, Bytes: []byte("logs_receiver")},
+						{Type: hclsyntax.TokenCParen, Bytes: []byte(")")},
+					}
+				})
+			}
+			return nil
+		},
+	})
+
+	gohcl.RegisterCapsuleType(LogsReceiverType)
+}
@@ -0,0 +1,293 @@
+// Package sourcefile implements the loki.source_file component.
+package sourcefile
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/discovery"
+	"github.com/grafana/agent/component/loki"
+	"github.com/grafana/loki/clients/pkg/promtail/api"
+	"github.com/grafana/loki/clients/pkg/promtail/positions"
+	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
+	"github.com/grafana/loki/clients/pkg/promtail/targets/file"
+	"github.com/prometheus/common/model"
+	prom_discovery "github.com/prometheus/prometheus/discovery"
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name: "loki.source_file",
+		Args: Arguments{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+const (
+	// pathLabel is the label which holds the path (or glob) of the files to
+	// tail for a target.
+	pathLabel = "__path__"
+
+	// syncPeriod is how often targets check their glob for new or removed
+	// files, and how often read positions are saved to disk.
+	syncPeriod = 10 * time.Second
+)
+
+// Arguments holds values which are used to configure the loki.source_file
+// component.
+type Arguments struct {
+	// Targets to tail. Each target must have a __path__ label.
+	Targets []discovery.Target `hcl:"targets,attr"`
+	// ForwardTo receives log entries read from the tailed files.
+	ForwardTo []*loki.LogsReceiver `hcl:"forward_to,attr"`
+}
+
+// Component implements the loki.source_file component.
+type Component struct {
+	opts      component.Options
+	metrics   *file.Metrics
+	positions positions.Positions
+
+	// handler receives entries from all tailed files.
+	handler chan api.Entry
+
+	mut       sync.RWMutex
+	args      Arguments
+	receivers []*loki.LogsReceiver
+
+	// mgr is guarded by a separate mutex, since stopping it blocks until
+	// tailers have sent their pending entries to receivers.
+	mgrMut sync.RWMutex
+	mgr    *file.FileTargetManager
+
+	// reloadTargets is written to when the targets of the component change.
+	reloadTargets chan struct{}
+}
+
+var (
+	_ component.Component      = (*Component)(nil)
+	_ component.DebugComponent = (*Component)(nil)
+)
+
+// New creates a new loki.source_file component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	if err := os.MkdirAll(o.DataPath, 0750); err != nil {
+		return nil, fmt.Errorf("creating data directory: %w", err)
+	}
+	positionsFile, err := positions.New(o.Logger, positions.Config{
+		SyncPeriod:    syncPeriod,
+		PositionsFile: filepath.Join(o.DataPath, "positions.yml"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating positions file: %w", err)
+	}
+
+	c := &Component{
+		opts:      o,
+		metrics:   file.NewMetrics(o.Registerer),
+		positions: positionsFile,
+
+		handler:       make(chan api.Entry),
+		reloadTargets: make(chan struct{}, 1),
+	}
+
+	if err := c.Update(args); err != nil {
+		positionsFile.Stop()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	// Entries are forwarded in a separate goroutine so tailers can always make
+	// progress while the target manager is being stopped.
+	stopForwarding := make(chan struct{})
+	forwardingDone := make(chan struct{})
+	go func() {
+		defer close(forwardingDone)
+		c.forwardEntries(ctx, stopForwarding)
+	}()
+
+	defer func() {
+		c.mgrMut.Lock()
+		if c.mgr != nil {
+			c.mgr.Stop()
+			c.mgr = nil
+		}
+		c.mgrMut.Unlock()
+
+		close(stopForwarding)
+		<-forwardingDone
+		c.positions.Stop()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-c.reloadTargets:
+			if err := c.reloadManager(); err != nil {
+				level.Error(c.opts.Logger).Log("msg", "failed to start tailing targets", "err", err)
+			}
+		}
+	}
+}
+
+// forwardEntries sends entries from tailed files to the current set of
+// receivers until stop is closed. Entries are dropped once ctx is canceled.
+func (c *Component) forwardEntries(ctx context.Context, stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		case entry := <-c.handler:
+			c.mut.RLock()
+			receivers := c.receivers
+			c.mut.RUnlock()
+
+			for _, r := range receivers {
+				if r == nil {
+					continue
+				}
+				_ = r.Send(ctx, entry)
+			}
+		}
+	}
+}
+
+// reloadManager replaces the running target manager with one for the current
+// set of targets.
+func (c *Component) reloadManager() error {
+	c.mut.RLock()
+	targets := c.args.Targets
+	c.mut.RUnlock()
+
+	c.mgrMut.Lock()
+	defer c.mgrMut.Unlock()
+
+	if c.mgr != nil {
+		c.mgr.Stop()
+		c.mgr = nil
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+
+	mgr, err := file.NewFileTargetManager(
+		c.metrics,
+		c.opts.Logger,
+		c.positions,
+		api.NewEntryHandler(c.handler, func() {}),
+		[]scrapeconfig.Config{{
+			JobName: c.opts.ID,
+			ServiceDiscoveryConfig: scrapeconfig.ServiceDiscoveryConfig{
+				StaticConfigs: prom_discovery.StaticConfig{targetsToGroup(targets)},
+			},
+		}},
+		&file.Config{SyncPeriod: syncPeriod},
+	)
+	if mgr != nil {
+		// NewFileTargetManager may return a running manager alongside an error
+		// from applying the discovery config.
+		c.mgr = mgr
+	}
+	return err
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	for _, t := range newArgs.Targets {
+		if _, ok := t[pathLabel]; !ok {
+			return fmt.Errorf("target %v is missing the %s label", t, pathLabel)
+		}
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	targetsChanged := !reflect.DeepEqual(c.args.Targets, newArgs.Targets)
+	c.args = newArgs
+	c.receivers = newArgs.ForwardTo
+	if !targetsChanged {
+		return nil
+	}
+
+	select {
+	case c.reloadTargets <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func targetsToGroup(targets []discovery.Target) *targetgroup.Group {
+	group := &targetgroup.Group{
+		Targets: make([]model.LabelSet, 0, len(targets)),
+	}
+	for _, t := range targets {
+		ls := make(model.LabelSet, len(t))
+		for k, v := range t {
+			ls[model.LabelName(k)] = model.LabelValue(v)
+		}
+		group.Targets = append(group.Targets, ls)
+	}
+	return group
+}
+
+// DebugInfo implements component.DebugComponent.
+func (c *Component) DebugInfo() interface{} {
+	c.mgrMut.RLock()
+	defer c.mgrMut.RUnlock()
+
+	if c.mgr == nil {
+		return DebugInfo{}
+	}
+
+	var res []TargetInfo
+	for _, targets := range c.mgr.ActiveTargets() {
+		for _, t := range targets {
+			info := TargetInfo{
+				Path:      string(t.DiscoveredLabels()[pathLabel]),
+				Labels:    make(map[string]string, len(t.Labels())),
+				IsRunning: t.Ready(),
+			}
+			for k, v := range t.Labels() {
+				info.Labels[string(k)] = string(v)
+			}
+			if offsets, ok := t.Details().(map[string]int64); ok {
+				info.ReadOffsets = offsets
+			}
+			res = append(res, info)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].Path < res[j].Path })
+	return DebugInfo{TargetsInfo: res}
+}
+
+// DebugInfo reports the status of the component's targets.
+type DebugInfo struct {
+	TargetsInfo []TargetInfo `hcl:"target,block"`
+}
+
+// TargetInfo reports the status of a single target.
+type TargetInfo struct {
+	Path        string            `hcl:"path,attr"`
+	Labels      map[string]string `hcl:"labels,attr"`
+	IsRunning   bool              `hcl:"is_running,attr"`
+	ReadOffsets map[string]int64  `hcl:"read_offsets,optional"`
+}
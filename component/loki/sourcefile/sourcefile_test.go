@@ -0,0 +1,81 @@
+//go:build !race
+// +build !race
+
+package sourcefile
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/discovery"
+	"github.com/grafana/agent/component/loki"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComponent(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "test.log")
+	f, err := os.Create(logPath)
+	require.NoError(t, err)
+	defer f.Close()
+
+	receiver := loki.NewLogsReceiver()
+	c, err := New(component.Options{
+		ID:            "loki.source_file.test",
+		Logger:        util.TestLogger(t),
+		DataPath:      t.TempDir(),
+		Registerer:    prometheus.NewRegistry(),
+		OnStateChange: func(e component.Exports) {},
+	}, Arguments{
+		Targets: []discovery.Target{{
+			"__path__": logPath,
+			"job":      "test",
+		}},
+		ForwardTo: []*loki.LogsReceiver{receiver},
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = c.Run(ctx) }()
+
+	// Wait for the file to be tailed before writing to it.
+	require.Eventually(t, func() bool {
+		info := c.DebugInfo().(DebugInfo)
+		return len(info.TargetsInfo) == 1 && info.TargetsInfo[0].IsRunning
+	}, 30*time.Second, 10*time.Millisecond)
+
+	_, err = fmt.Fprintln(f, "Hello, world!")
+	require.NoError(t, err)
+
+	select {
+	case entry := <-receiver.Chan():
+		require.Equal(t, "Hello, world!", entry.Line)
+		require.Equal(t, model.LabelSet{
+			"filename": model.LabelValue(logPath),
+			"job":      "test",
+		}, entry.Labels)
+	case <-time.After(30 * time.Second):
+		require.FailNow(t, "timed out waiting for log entry")
+	}
+}
+
+func TestComponent_MissingPath(t *testing.T) {
+	_, err := New(component.Options{
+		ID:            "loki.source_file.test",
+		Logger:        util.TestLogger(t),
+		DataPath:      t.TempDir(),
+		Registerer:    prometheus.NewRegistry(),
+		OnStateChange: func(e component.Exports) {},
+	}, Arguments{
+		Targets: []discovery.Target{{"job": "test"}},
+	})
+	require.EqualError(t, err, "target map[job:test] is missing the __path__ label")
+}
@@ -0,0 +1,133 @@
+package write
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/grafana/agent/component/common/config"
+	"github.com/grafana/dskit/backoff"
+	"github.com/grafana/dskit/flagext"
+	"github.com/grafana/loki/clients/pkg/promtail/client"
+	lokiflag "github.com/grafana/loki/pkg/util/flagext"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/prometheus/common/model"
+	"github.com/rfratto/gohcl"
+)
+
+// DefaultEndpointOptions holds the default settings for EndpointOptions.
+var DefaultEndpointOptions = EndpointOptions{
+	BatchWait:         client.BatchWait,
+	BatchSize:         client.BatchSize,
+	RemoteTimeout:     client.Timeout,
+	MinBackoff:        client.MinBackoff,
+	MaxBackoff:        client.MaxBackoff,
+	MaxBackoffRetries: client.MaxRetries,
+}
+
+// Arguments holds values which are used to configure the loki.write
+// component.
+type Arguments struct {
+	Endpoints      []*EndpointOptions `hcl:"endpoint,block"`
+	ExternalLabels map[string]string  `hcl:"external_labels,optional"`
+}
+
+var _ gohcl.Decoder = (*Arguments)(nil)
+
+// DecodeHCL implements gohcl.Decoder.
+func (args *Arguments) DecodeHCL(body hcl.Body, ctx *hcl.EvalContext) error {
+	*args = Arguments{}
+
+	type arguments Arguments
+	if err := gohcl.DecodeBody(body, ctx, (*arguments)(args)); err != nil {
+		return err
+	}
+	if len(args.Endpoints) == 0 {
+		return fmt.Errorf("at least one endpoint block must be provided")
+	}
+	return nil
+}
+
+// EndpointOptions describes an individual location to send log entries to.
+type EndpointOptions struct {
+	Name              string                   `hcl:"name,optional"`
+	URL               string                   `hcl:"url,attr"`
+	BatchWait         time.Duration            `hcl:"batch_wait,optional"`
+	BatchSize         int                      `hcl:"batch_size,optional"`
+	RemoteTimeout     time.Duration            `hcl:"remote_timeout,optional"`
+	TenantID          string                   `hcl:"tenant_id,optional"`
+	MinBackoff        time.Duration            `hcl:"min_backoff_period,optional"`
+	MaxBackoff        time.Duration            `hcl:"max_backoff_period,optional"`
+	MaxBackoffRetries int                      `hcl:"max_backoff_retries,optional"`
+	HTTPClientConfig  *config.HTTPClientConfig `hcl:"http_client_config,block"`
+}
+
+var _ gohcl.Decoder = (*EndpointOptions)(nil)
+
+// DecodeHCL implements gohcl.Decoder.
+func (r *EndpointOptions) DecodeHCL(body hcl.Body, ctx *hcl.EvalContext) error {
+	*r = DefaultEndpointOptions
+
+	type endpointOptions EndpointOptions
+	if err := gohcl.DecodeBody(body, ctx, (*endpointOptions)(r)); err != nil {
+		return err
+	}
+
+	if _, err := url.Parse(r.URL); err != nil {
+		return fmt.Errorf("invalid url %q: %w", r.URL, err)
+	}
+	switch {
+	case r.BatchWait <= 0:
+		return fmt.Errorf("batch_wait must be greater than 0s")
+	case r.BatchSize <= 0:
+		return fmt.Errorf("batch_size must be greater than 0")
+	case r.RemoteTimeout <= 0:
+		return fmt.Errorf("remote_timeout must be greater than 0s")
+	case r.MinBackoff > r.MaxBackoff:
+		return fmt.Errorf("min_backoff_period must not be greater than max_backoff_period")
+	}
+	return nil
+}
+
+// convertClientConfigs converts the component's arguments into promtail
+// client configs.
+func convertClientConfigs(args Arguments) ([]client.Config, error) {
+	var res []client.Config
+
+	for _, ep := range args.Endpoints {
+		parsedURL, err := url.Parse(ep.URL)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse endpoint url %q: %w", ep.URL, err)
+		}
+		httpClientConfig, err := ep.HTTPClientConfig.Convert()
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, client.Config{
+			Name:      ep.Name,
+			URL:       flagext.URLValue{URL: parsedURL},
+			BatchWait: ep.BatchWait,
+			BatchSize: ep.BatchSize,
+			Client:    *httpClientConfig,
+			BackoffConfig: backoff.Config{
+				MinBackoff: ep.MinBackoff,
+				MaxBackoff: ep.MaxBackoff,
+				MaxRetries: ep.MaxBackoffRetries,
+			},
+			ExternalLabels: lokiflag.LabelSet{LabelSet: toLabelSet(args.ExternalLabels)},
+			Timeout:        ep.RemoteTimeout,
+			TenantID:       ep.TenantID,
+		})
+	}
+
+	return res, nil
+}
+
+func toLabelSet(m map[string]string) model.LabelSet {
+	res := make(model.LabelSet, len(m))
+	for k, v := range m {
+		res[model.LabelName(k)] = model.LabelValue(v)
+	}
+	return res
+}
@@ -0,0 +1,110 @@
+// Package write implements the loki.write component.
+package write
+
+import (
+	"context"
+	"sync"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/loki"
+	"github.com/grafana/loki/clients/pkg/promtail/client"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:    "loki.write",
+		Args:    Arguments{},
+		Exports: Exports{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Exports holds values which are exported by the loki.write component.
+type Exports struct {
+	Receiver *loki.LogsReceiver `hcl:"receiver,attr"`
+}
+
+// Component implements the loki.write component.
+type Component struct {
+	opts     component.Options
+	metrics  *client.Metrics
+	receiver *loki.LogsReceiver
+
+	mut    sync.RWMutex
+	client client.Client
+}
+
+var _ component.Component = (*Component)(nil)
+
+// New creates a new loki.write component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	c := &Component{
+		opts:     o,
+		metrics:  client.NewMetrics(o.Registerer, nil),
+		receiver: loki.NewLogsReceiver(),
+	}
+
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+
+	// The receiver never changes, so it's only exported once.
+	o.OnStateChange(Exports{Receiver: c.receiver})
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	defer func() {
+		c.mut.Lock()
+		defer c.mut.Unlock()
+		if c.client != nil {
+			// Stop flushes pending batches before returning.
+			c.client.Stop()
+			c.client = nil
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case entry := <-c.receiver.Chan():
+			// The read lock is held while sending so Update can't stop the
+			// client while an entry is being sent to it.
+			c.mut.RLock()
+			select {
+			case <-ctx.Done():
+			case c.client.Chan() <- entry:
+			}
+			c.mut.RUnlock()
+		}
+	}
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	cfgs, err := convertClientConfigs(newArgs)
+	if err != nil {
+		return err
+	}
+	newClient, err := client.NewMulti(c.metrics, nil, c.opts.Logger, cfgs...)
+	if err != nil {
+		return err
+	}
+
+	c.mut.Lock()
+	oldClient := c.client
+	c.client = newClient
+	c.mut.Unlock()
+
+	if oldClient != nil {
+		oldClient.Stop()
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package write
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/grafana/loki/clients/pkg/promtail/api"
+	"github.com/grafana/loki/pkg/loghttp/push"
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/rfratto/gohcl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComponent(t *testing.T) {
+	pushes := make(chan *logproto.PushRequest, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, err := push.ParseRequest(log.NewNopLogger(), "user_id", r, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		pushes <- req
+	}))
+	defer srv.Close()
+
+	args := decodeArguments(t, fmt.Sprintf(`
+		external_labels = { "cluster" = "local" }
+
+		endpoint {
+			url        = "%s/loki/api/v1/push"
+			batch_wait = "50ms"
+			batch_size = 1
+		}
+	`, srv.URL))
+
+	var exports Exports
+	c, err := New(component.Options{
+		ID:            "loki.write.test",
+		Logger:        util.TestLogger(t),
+		Registerer:    prometheus.NewRegistry(),
+		OnStateChange: func(e component.Exports) { exports = e.(Exports) },
+	}, args)
+	require.NoError(t, err)
+	require.NotNil(t, exports.Receiver)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = c.Run(ctx) }()
+
+	entry := api.Entry{
+		Labels: model.LabelSet{"job": "test"},
+		Entry:  logproto.Entry{Timestamp: time.Now(), Line: "Hello, world!"},
+	}
+	require.NoError(t, exports.Receiver.Send(ctx, entry))
+
+	select {
+	case req := <-pushes:
+		require.Len(t, req.Streams, 1)
+		require.Equal(t, `{cluster="local", job="test"}`, req.Streams[0].Labels)
+		require.Equal(t, "Hello, world!", req.Streams[0].Entries[0].Line)
+	case <-time.After(30 * time.Second):
+		require.FailNow(t, "timed out waiting for push")
+	}
+}
+
+func TestArguments_Invalid(t *testing.T) {
+	tt := []struct {
+		name, cfg, err string
+	}{
+		{
+			name: "no endpoints",
+			cfg:  `external_labels = { "cluster" = "local" }`,
+			err:  "at least one endpoint block must be provided",
+		},
+		{
+			name: "invalid backoff",
+			cfg: `
+				endpoint {
+					url                = "http://localhost:3100/loki/api/v1/push"
+					min_backoff_period = "10m"
+					max_backoff_period = "1m"
+				}
+			`,
+			err: "min_backoff_period must not be greater than max_backoff_period",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			file, diags := hclparse.NewParser().ParseHCL([]byte(tc.cfg), "test.flow")
+			require.False(t, diags.HasErrors())
+
+			var args Arguments
+			diags = gohcl.DecodeBody(file.Body, nil, &args)
+			require.True(t, diags.HasErrors())
+			require.Contains(t, diags.Error(), tc.err)
+		})
+	}
+}
+
+func decodeArguments(t *testing.T, cfg string) Arguments {
+	t.Helper()
+
+	file, diags := hclparse.NewParser().ParseHCL([]byte(cfg), "test.flow")
+	require.False(t, diags.HasErrors(), diags.Error())
+
+	var args Arguments
+	diags = gohcl.DecodeBody(file.Body, nil, &args)
+	require.False(t, diags.HasErrors(), diags.Error())
+	return args
+}
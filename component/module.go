@@ -0,0 +1,41 @@
+package component
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Module is a nested set of components loaded from a Flow config file and
+// run on behalf of a component. Modules allow components to load reusable
+// pipelines which declare their own arguments and exports.
+//
+// Components in a module may reference the value of the module's arguments
+// with argument.NAME.value. The values of the module's export blocks are
+// reported to the component whenever they change.
+type Module interface {
+	// LoadConfig parses config as a Flow config file and loads its components
+	// with the provided argument values. Loading a new config replaces the
+	// components of the previously loaded one.
+	//
+	// If config fails to load after a previous config loaded successfully, the
+	// module keeps running the previous config.
+	LoadConfig(config []byte, args map[string]cty.Value) error
+
+	// LoadBody is like LoadConfig, but loads the blocks of an already parsed
+	// HCL body, such as a template block of a component.
+	LoadBody(body hcl.Body, args map[string]cty.Value) error
+
+	// Run runs the components of the module until ctx is canceled.
+	Run(ctx context.Context) error
+
+	// ComponentHandler returns an http.Handler which serves the HTTP handlers
+	// of the module's components under /<component id>/.
+	ComponentHandler() http.Handler
+}
+
+// ModuleExportsFunc is invoked with the values of a module's export blocks
+// whenever they change.
+type ModuleExportsFunc func(exports map[string]cty.Value)
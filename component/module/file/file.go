@@ -0,0 +1,211 @@
+// Package file implements the module.file component.
+package file
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/local/file"
+	"github.com/grafana/agent/component/module"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/rfratto/gohcl"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:    "module.file",
+		Args:    Arguments{},
+		Exports: module.Exports{},
+
+		Validate: func(args component.Arguments) error {
+			return module.ValidateArguments(args.(Arguments).Arguments)
+		},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Arguments holds values which are used to configure the module.file
+// component.
+type Arguments struct {
+	// Filename of the module to load.
+	Filename string `hcl:"filename,attr"`
+	// Type indicates how to detect changes to the file.
+	Type file.Detector `hcl:"detector,optional"`
+	// PollFrequency determines the frequency to check for changes when Type is
+	// DetectorPoll.
+	PollFrequency time.Duration `hcl:"poll_frequency,optional"`
+	// IsSecret marks the file as holding a secret value which should not be
+	// displayed to the user.
+	IsSecret bool `hcl:"is_secret,optional"`
+
+	// Arguments to pass to the module.
+	Arguments cty.Value `hcl:"arguments,optional"`
+}
+
+// DefaultArguments provides the default arguments for the module.file
+// component.
+var DefaultArguments = Arguments{
+	Type:          file.DefaultArguments.Type,
+	PollFrequency: file.DefaultArguments.PollFrequency,
+}
+
+var _ gohcl.Decoder = (*Arguments)(nil)
+
+// DecodeHCL implements gohcl.Decoder.
+func (a *Arguments) DecodeHCL(body hcl.Body, ctx *hcl.EvalContext) error {
+	*a = DefaultArguments
+
+	type arguments Arguments
+	return gohcl.DecodeBody(body, ctx, (*arguments)(a))
+}
+
+// Component implements the module.file component.
+type Component struct {
+	opts component.Options
+	mod  *module.ModuleComponent
+	file *file.Component
+
+	mut      sync.Mutex
+	args     Arguments
+	content  string
+	updating bool
+}
+
+var (
+	_ component.Component       = (*Component)(nil)
+	_ component.HealthComponent = (*Component)(nil)
+	_ component.HTTPComponent   = (*Component)(nil)
+)
+
+// New creates a new module.file component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	m, err := module.NewModuleComponent(o)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Component{
+		opts: o,
+		mod:  m,
+
+		args:     args,
+		updating: true,
+	}
+
+	// The file is read by the local.file component. It shares our options so
+	// its metrics and logs are reported under our component ID, but its
+	// exports are intercepted to load the module.
+	fileOpts := o
+	fileOpts.OnStateChange = c.onContentChange
+	c.file, err = file.New(fileOpts, fileArguments(args))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.reload(); err != nil {
+		return nil, err
+	}
+
+	c.mut.Lock()
+	c.updating = false
+	c.mut.Unlock()
+	return c, nil
+}
+
+func fileArguments(args Arguments) file.Arguments {
+	fa := file.DefaultArguments
+	fa.Filename = args.Filename
+	fa.Type = args.Type
+	fa.PollFrequency = args.PollFrequency
+	fa.IsSecret = args.IsSecret
+	return fa
+}
+
+// onContentChange is invoked by the local.file component whenever the module
+// file is read.
+func (c *Component) onContentChange(e component.Exports) {
+	c.mut.Lock()
+	c.content = e.(file.Exports).Content.Value
+	updating := c.updating
+	c.mut.Unlock()
+
+	// Update and New load the module themselves so they can report the error.
+	if updating {
+		return
+	}
+	if err := c.reload(); err != nil {
+		level.Error(c.opts.Logger).Log("msg", "failed to reload module", "err", err)
+	}
+}
+
+// reload loads the latest content of the module file.
+func (c *Component) reload() error {
+	c.mut.Lock()
+	var (
+		args    = c.args.Arguments
+		content = c.content
+	)
+	c.mut.Unlock()
+
+	return c.mod.LoadFlowContent(args, content)
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// The module keeps running until the file watcher exits.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := c.mod.RunFlowController(ctx); err != nil {
+			level.Error(c.opts.Logger).Log("msg", "error running module", "err", err)
+		}
+	}()
+	defer wg.Wait()
+
+	err := c.file.Run(ctx)
+	cancel()
+	return err
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	c.mut.Lock()
+	c.args = newArgs
+	c.updating = true
+	c.mut.Unlock()
+
+	defer func() {
+		c.mut.Lock()
+		c.updating = false
+		c.mut.Unlock()
+	}()
+
+	if err := c.file.Update(fileArguments(newArgs)); err != nil {
+		return err
+	}
+	return c.reload()
+}
+
+// Handler implements component.HTTPComponent.
+func (c *Component) Handler() http.Handler {
+	return c.mod.HTTPHandler()
+}
+
+// CurrentHealth implements component.HealthComponent.
+func (c *Component) CurrentHealth() component.Health {
+	return module.LeastHealthy(c.file.CurrentHealth(), c.mod.CurrentHealth())
+}
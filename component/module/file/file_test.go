@@ -0,0 +1,134 @@
+package file_test
+
+import (
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/grafana/agent/component/local/file"
+	_ "github.com/grafana/agent/component/module/file"
+	"github.com/grafana/agent/pkg/flow"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+)
+
+// moduleFile reads the file passed as the path argument and exports its
+// content.
+const moduleFile = `
+	argument "path" {}
+
+	local "file" "x" {
+		filename = argument.path.value
+	}
+
+	export "content" {
+		value = local.file.x.content
+	}
+`
+
+func TestModuleFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "module.flow"), moduleFile)
+	writeFile(t, filepath.Join(dir, "input"), "hello")
+
+	reg := prometheus.NewRegistry()
+	f := flow.New(flow.Options{DataPath: t.TempDir(), Reg: reg})
+	t.Cleanup(func() { require.NoError(t, f.Close()) })
+
+	loadConfig(t, f, fmt.Sprintf(`
+		module "file" "m" {
+			filename  = %q
+			arguments = {
+				path = %q,
+			}
+		}
+
+		assert "exports" {
+			condition = lower(module.file.m.exports.content) == "hello"
+		}
+	`, filepath.Join(dir, "module.flow"), filepath.Join(dir, "input")))
+
+	// Components of the module are given IDs prefixed by the module's ID.
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	require.True(t, hasComponentID(families, "module.file.m/local.file.x"), "missing metrics of nested component")
+
+	// Changes to the module file cause the module to be reloaded.
+	writeFile(t, filepath.Join(dir, "other"), "goodbye")
+	writeFile(t, filepath.Join(dir, "module.flow"), `
+		local "file" "x" {
+			filename = "`+filepath.Join(dir, "other")+`"
+		}
+
+		export "content" {
+			value = local.file.x.content
+		}
+
+		argument "path" {}
+	`)
+	require.Eventually(t, func() bool {
+		return configContains(f, `"goodbye"`)
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestModuleFile_InvalidModule(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "module.flow"), moduleFile)
+
+	f := flow.New(flow.Options{DataPath: t.TempDir()})
+	t.Cleanup(func() { require.NoError(t, f.Close()) })
+
+	// The module requires the path argument.
+	ff, diags := flow.ReadFile("test", []byte(fmt.Sprintf(`
+		module "file" "m" {
+			filename = %q
+		}
+	`, filepath.Join(dir, "module.flow"))))
+	require.False(t, diags.HasErrors())
+	err := f.LoadFile(ff)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Missing required argument path")
+}
+
+func writeFile(t *testing.T, filename, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filename, []byte(content), 0664))
+}
+
+func loadConfig(t *testing.T, f *flow.Flow, config string) {
+	t.Helper()
+
+	ff, diags := flow.ReadFile("test", []byte(config))
+	require.False(t, diags.HasErrors(), diags.Error())
+	require.NoError(t, f.LoadFile(ff))
+}
+
+// configContains returns true if the debug view of the config of f, which
+// includes the exports of components, contains text.
+func configContains(f *flow.Flow, text string) bool {
+	rec := httptest.NewRecorder()
+	f.ConfigHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/?debug=1", nil))
+	body, _ := io.ReadAll(rec.Body)
+	return strings.Contains(string(body), text)
+}
+
+// hasComponentID returns true if any metric in families has a component_id
+// label set to id.
+func hasComponentID(families []*dto.MetricFamily, id string) bool {
+	for _, mf := range families {
+		for _, m := range mf.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "component_id" && l.GetValue() == id {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,500 @@
+// Package foreach implements the module.foreach component.
+package foreach
+
+import (
+	"context"
+	"encoding"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/module"
+	"github.com/grafana/regexp"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/rfratto/gohcl"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "module.foreach",
+		Args:      Arguments{},
+		Exports:   Exports{},
+		Templates: []string{"template"},
+
+		Validate: func(args component.Arguments) error {
+			return args.(Arguments).Validate()
+		},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// itemArgument is the name of the module argument which holds the element of
+// the collection an instance was created for.
+const itemArgument = "item"
+
+// Arguments holds values which are used to configure the module.foreach
+// component.
+type Arguments struct {
+	// Collection holds the elements to create an instance of the template for.
+	Collection cty.Value `hcl:"collection,attr"`
+
+	// Arguments to pass to every instance, in addition to the element of the
+	// collection.
+	Arguments cty.Value `hcl:"arguments,optional"`
+
+	// Template holds the module which is loaded for each element of the
+	// collection.
+	Template Template `hcl:"template,block"`
+}
+
+// Template is the body of a module. It's evaluated by the instances rather
+// than by the controller.
+type Template struct {
+	Body hcl.Body `hcl:",remain"`
+}
+
+var _ encoding.TextMarshaler = Template{}
+
+// MarshalText implements encoding.TextMarshaler. Templates can't be
+// represented as a value, so they're exposed as the location of their body.
+func (t Template) MarshalText() ([]byte, error) {
+	if t.Body == nil {
+		return nil, nil
+	}
+	return []byte(t.Body.MissingItemRange().String()), nil
+}
+
+var _ gohcl.Decoder = (*Arguments)(nil)
+
+// DecodeHCL implements gohcl.Decoder.
+func (a *Arguments) DecodeHCL(body hcl.Body, ctx *hcl.EvalContext) error {
+	*a = Arguments{}
+
+	type arguments Arguments
+	return gohcl.DecodeBody(body, ctx, (*arguments)(a))
+}
+
+// Validate returns an error if a is invalid.
+func (a Arguments) Validate() error {
+	if err := validateCollection(a.Collection); err != nil {
+		return err
+	}
+	if err := module.ValidateArguments(a.Arguments); err != nil {
+		return err
+	}
+	if !a.Arguments.IsNull() {
+		if _, ok := a.Arguments.AsValueMap()[itemArgument]; ok {
+			return fmt.Errorf("arguments must not contain %q, which is set to the element of the collection", itemArgument)
+		}
+	}
+	return nil
+}
+
+// validateCollection returns an error if v can't be iterated over.
+func validateCollection(v cty.Value) error {
+	if v.IsNull() {
+		return fmt.Errorf("collection must not be null")
+	}
+	if ty := v.Type(); !ty.IsListType() && !ty.IsSetType() && !ty.IsTupleType() {
+		return fmt.Errorf("collection must be a list, got %s", ty.FriendlyName())
+	}
+	if !v.IsWhollyKnown() {
+		return fmt.Errorf("collection must be known")
+	}
+	return nil
+}
+
+// Exports holds values which are exported by the module.foreach component.
+type Exports struct {
+	// Exports holds the values of the export blocks of each instance, keyed by
+	// the key of the instance.
+	Exports cty.Value `hcl:"exports,attr"`
+}
+
+// DebugInfo holds the debug information of the module.foreach component.
+type DebugInfo struct {
+	Instances []InstanceInfo `hcl:"instance,block"`
+}
+
+// InstanceInfo reports the status of a single instance of the template.
+type InstanceInfo struct {
+	Key    string `hcl:"key,attr"`
+	Loaded bool   `hcl:"loaded,attr"`
+	Error  string `hcl:"error,optional"`
+}
+
+// instance is a module created for a single element of the collection.
+type instance struct {
+	key string
+	mod component.Module
+
+	// Values last loaded into mod. body is nil if mod never loaded
+	// successfully.
+	body    hcl.Body
+	args    map[string]cty.Value
+	loadErr error
+
+	cancel context.CancelFunc // Set once the instance is running
+	exited chan struct{}
+
+	exports cty.Value // Protected by Component.exportsMut
+}
+
+// Component implements the module.foreach component.
+type Component struct {
+	opts component.Options
+
+	mut       sync.Mutex
+	instances map[string]*instance
+	runCtx    context.Context // Set while Run is running
+	wg        sync.WaitGroup
+
+	// exportsMut protects the instances whose exports are published. Modules
+	// may report exports while mut is held during a load, so it can't be
+	// protected by mut.
+	exportsMut sync.Mutex
+	published  map[string]*instance
+}
+
+var (
+	_ component.Component       = (*Component)(nil)
+	_ component.HealthComponent = (*Component)(nil)
+	_ component.HTTPComponent   = (*Component)(nil)
+	_ component.DebugComponent  = (*Component)(nil)
+)
+
+// New creates a new module.foreach component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	if o.NewModule == nil {
+		return nil, fmt.Errorf("modules are not supported by the controller running %s", o.ID)
+	}
+
+	c := &Component{
+		opts:      o,
+		instances: make(map[string]*instance),
+		published: make(map[string]*instance),
+	}
+	if err := c.Update(args); err != nil {
+		c.mut.Lock()
+		for _, inst := range c.instances {
+			c.stopInstance(inst)
+		}
+		c.mut.Unlock()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	c.mut.Lock()
+	c.runCtx = ctx
+	for _, inst := range c.instances {
+		c.startInstance(inst)
+	}
+	c.mut.Unlock()
+
+	<-ctx.Done()
+
+	c.mut.Lock()
+	c.runCtx = nil
+	c.mut.Unlock()
+
+	c.wg.Wait()
+	return nil
+}
+
+// startInstance runs inst until Run exits or inst is removed. mut must be
+// held when calling startInstance.
+func (c *Component) startInstance(inst *instance) {
+	ctx, cancel := context.WithCancel(c.runCtx)
+	inst.cancel = cancel
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		defer close(inst.exited)
+
+		if err := inst.mod.Run(ctx); err != nil {
+			level.Error(c.opts.Logger).Log("msg", "error running instance", "key", inst.key, "err", err)
+		}
+	}()
+}
+
+// stopInstance stops inst and waits for its components to exit. mut must be
+// held when calling stopInstance.
+func (c *Component) stopInstance(inst *instance) {
+	if inst.cancel == nil {
+		// The instance never ran. Running it with a canceled context releases
+		// the resources of its components.
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_ = inst.mod.Run(ctx)
+		return
+	}
+
+	inst.cancel()
+	<-inst.exited
+}
+
+// Update implements component.Component. Instances are created for new
+// elements of the collection and removed for elements which are gone.
+// Existing instances are only reloaded if their arguments or the template
+// changed.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	keys, items := instanceKeys(newArgs.Collection)
+
+	var shared map[string]cty.Value
+	if !newArgs.Arguments.IsNull() {
+		shared = newArgs.Arguments.AsValueMap()
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	// Remove instances first so that their metrics are unregistered before
+	// anything new is created.
+	for key, inst := range c.instances {
+		if _, ok := items[key]; ok {
+			continue
+		}
+		c.stopInstance(inst)
+		delete(c.instances, key)
+
+		c.exportsMut.Lock()
+		delete(c.published, key)
+		c.exportsMut.Unlock()
+	}
+
+	var errs []string
+	for _, key := range keys {
+		instArgs := make(map[string]cty.Value, len(shared)+1)
+		for name, v := range shared {
+			instArgs[name] = v
+		}
+		instArgs[itemArgument] = items[key]
+
+		inst, ok := c.instances[key]
+		if !ok {
+			var err error
+			inst, err = c.newInstance(key)
+			if err != nil {
+				return err
+			}
+		} else if inst.loadErr == nil && inst.body == newArgs.Template.Body && argsEqual(inst.args, instArgs) {
+			continue
+		}
+
+		if err := inst.mod.LoadBody(newArgs.Template.Body, instArgs); err != nil {
+			inst.loadErr = err
+			errs = append(errs, fmt.Sprintf("instance %s: %s", key, err))
+			continue
+		}
+		inst.body, inst.args, inst.loadErr = newArgs.Template.Body, instArgs, nil
+	}
+
+	c.publishExports()
+
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to load instances: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// newInstance creates and publishes a new instance with the given key. mut
+// must be held when calling newInstance.
+func (c *Component) newInstance(key string) (*instance, error) {
+	inst := &instance{
+		key:     key,
+		exited:  make(chan struct{}),
+		exports: cty.EmptyObjectVal,
+	}
+
+	mod, err := c.opts.NewModule(key, func(exports map[string]cty.Value) {
+		c.setInstanceExports(inst, exports)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating module for instance %s: %w", key, err)
+	}
+	inst.mod = mod
+	c.instances[key] = inst
+
+	c.exportsMut.Lock()
+	c.published[key] = inst
+	c.exportsMut.Unlock()
+
+	if c.runCtx != nil {
+		c.startInstance(inst)
+	}
+	return inst, nil
+}
+
+func (c *Component) setInstanceExports(inst *instance, exports map[string]cty.Value) {
+	c.exportsMut.Lock()
+	defer c.exportsMut.Unlock()
+
+	inst.exports = cty.ObjectVal(exports)
+
+	// Ignore late exports from removed instances.
+	if c.published[inst.key] == inst {
+		c.publishExportsLocked()
+	}
+}
+
+func (c *Component) publishExports() {
+	c.exportsMut.Lock()
+	defer c.exportsMut.Unlock()
+	c.publishExportsLocked()
+}
+
+// publishExportsLocked reports the exports of all published instances.
+// exportsMut must be held when calling publishExportsLocked.
+func (c *Component) publishExportsLocked() {
+	exports := make(map[string]cty.Value, len(c.published))
+	for key, inst := range c.published {
+		exports[key] = inst.exports
+	}
+	c.opts.OnStateChange(Exports{Exports: cty.ObjectVal(exports)})
+}
+
+// argsEqual returns true if a and b hold the same arguments.
+func argsEqual(a, b map[string]cty.Value) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, av := range a {
+		bv, ok := b[name]
+		if !ok || !av.RawEquals(bv) {
+			return false
+		}
+	}
+	return true
+}
+
+// keyRegexp matches string elements which are used as the key of their
+// instance as-is. Keys appear in component IDs and HTTP paths, so other
+// elements are keyed by a hash of their value.
+var keyRegexp = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// instanceKeys returns the keys of the elements of collection in order,
+// along with the element for each key. Duplicate elements share a key and
+// only create one instance.
+func instanceKeys(collection cty.Value) ([]string, map[string]cty.Value) {
+	var (
+		keys  []string
+		items = make(map[string]cty.Value)
+	)
+	for it := collection.ElementIterator(); it.Next(); {
+		_, item := it.Element()
+
+		key := instanceKey(item)
+		if _, ok := items[key]; ok {
+			continue
+		}
+		keys = append(keys, key)
+		items[key] = item
+	}
+	return keys, items
+}
+
+func instanceKey(item cty.Value) string {
+	if item.Type() == cty.String && !item.IsNull() && keyRegexp.MatchString(item.AsString()) {
+		return item.AsString()
+	}
+
+	h := fnv.New64a()
+	bb, err := ctyjson.Marshal(item, item.Type())
+	if err != nil {
+		// Values which can't be marshaled are hashed by their Go
+		// representation instead.
+		bb = []byte(item.GoString())
+	}
+	_, _ = h.Write(bb)
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// Handler implements component.HTTPComponent. The components of each
+// instance are served under /<instance key>/.
+func (c *Component) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, rest := strings.TrimPrefix(r.URL.Path, "/"), "/"
+		if idx := strings.Index(key, "/"); idx >= 0 {
+			key, rest = key[:idx], key[idx:]
+		}
+
+		c.mut.Lock()
+		inst, ok := c.instances[key]
+		c.mut.Unlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.URL = new(url.URL)
+		*r2.URL = *r.URL
+		r2.URL.Path = rest
+		r2.URL.RawPath = ""
+		inst.mod.ComponentHandler().ServeHTTP(w, r2)
+	})
+}
+
+// CurrentHealth implements component.HealthComponent.
+func (c *Component) CurrentHealth() component.Health {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	var failed []string
+	for key, inst := range c.instances {
+		if inst.loadErr != nil {
+			failed = append(failed, key)
+		}
+	}
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return component.Health{
+			Health:     component.HealthTypeUnhealthy,
+			Message:    fmt.Sprintf("failed to load instances: %s", strings.Join(failed, ", ")),
+			UpdateTime: time.Now(),
+		}
+	}
+	return component.Health{
+		Health:     component.HealthTypeHealthy,
+		Message:    fmt.Sprintf("%d instances loaded", len(c.instances)),
+		UpdateTime: time.Now(),
+	}
+}
+
+// DebugInfo implements component.DebugComponent.
+func (c *Component) DebugInfo() interface{} {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	var info DebugInfo
+	for key, inst := range c.instances {
+		ii := InstanceInfo{Key: key, Loaded: inst.body != nil}
+		if inst.loadErr != nil {
+			ii.Error = inst.loadErr.Error()
+		}
+		info.Instances = append(info.Instances, ii)
+	}
+	sort.Slice(info.Instances, func(i, j int) bool {
+		return info.Instances[i].Key < info.Instances[j].Key
+	})
+	return info
+}
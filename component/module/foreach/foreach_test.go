@@ -0,0 +1,168 @@
+package foreach_test
+
+import (
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/grafana/agent/component/local/file"
+	_ "github.com/grafana/agent/component/module/foreach"
+	"github.com/grafana/agent/pkg/flow"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForeach(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "list"), "a,b")
+	writeFile(t, filepath.Join(dir, "a"), "alpha")
+	writeFile(t, filepath.Join(dir, "b"), "beta")
+	writeFile(t, filepath.Join(dir, "c"), "gamma")
+
+	reg := prometheus.NewRegistry()
+	f := flow.New(flow.Options{DataPath: t.TempDir(), Reg: reg})
+	t.Cleanup(func() { require.NoError(t, f.Close()) })
+
+	loadConfig(t, f, fmt.Sprintf(`
+		local "file" "list" {
+			filename = %q
+		}
+
+		module "foreach" "m" {
+			collection = split(",", local.file.list.content)
+			arguments  = {
+				dir = %q,
+			}
+
+			template {
+				argument "item" {}
+				argument "dir" {}
+
+				local "file" "x" {
+					filename = "${argument.dir.value}/${argument.item.value}"
+				}
+
+				export "content" {
+					value = local.file.x.content
+				}
+			}
+		}
+
+		assert "exports" {
+			condition = lower(module.foreach.m.exports.a.content) == "alpha" && lower(module.foreach.m.exports.b.content) == "beta"
+		}
+	`, filepath.Join(dir, "list"), dir))
+
+	// Components of each instance are given IDs prefixed by the instance key.
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	require.True(t, hasComponentID(families, "module.foreach.m/a/local.file.x"))
+	require.True(t, hasComponentID(families, "module.foreach.m/b/local.file.x"))
+
+	// Instances are created and removed as the collection changes.
+	writeFile(t, filepath.Join(dir, "list"), "b,c")
+	require.Eventually(t, func() bool {
+		return configContains(f, `"gamma"`) && !configContains(f, `"alpha"`)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	families, err = reg.Gather()
+	require.NoError(t, err)
+	require.False(t, hasComponentID(families, "module.foreach.m/a/local.file.x"), "metrics of removed instance weren't unregistered")
+	require.True(t, hasComponentID(families, "module.foreach.m/c/local.file.x"))
+}
+
+func TestForeach_InvalidArguments(t *testing.T) {
+	tt := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{
+			name: "collection isn't a list",
+			config: `
+				module "foreach" "m" {
+					collection = "a"
+					template {}
+				}
+			`,
+			err: "collection must be a list, got string",
+		},
+		{
+			name: "arguments set item",
+			config: `
+				module "foreach" "m" {
+					collection = ["a"]
+					arguments  = { item = "b" }
+					template {}
+				}
+			`,
+			err: `arguments must not contain "item"`,
+		},
+		{
+			name: "template doesn't declare item",
+			config: `
+				module "foreach" "m" {
+					collection = ["a"]
+					template {}
+				}
+			`,
+			err: "Unsupported argument item",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			f := flow.New(flow.Options{DataPath: t.TempDir()})
+			t.Cleanup(func() { require.NoError(t, f.Close()) })
+
+			ff, diags := flow.ReadFile("test", []byte(tc.config))
+			require.False(t, diags.HasErrors(), diags.Error())
+			err := f.LoadFile(ff)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.err)
+		})
+	}
+}
+
+func writeFile(t *testing.T, filename, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filename, []byte(content), 0664))
+}
+
+func loadConfig(t *testing.T, f *flow.Flow, config string) {
+	t.Helper()
+
+	ff, diags := flow.ReadFile("test", []byte(config))
+	require.False(t, diags.HasErrors(), diags.Error())
+	require.NoError(t, f.LoadFile(ff))
+}
+
+// configContains returns true if the debug view of the config of f, which
+// includes the exports of components, contains text.
+func configContains(f *flow.Flow, text string) bool {
+	rec := httptest.NewRecorder()
+	f.ConfigHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/?debug=1", nil))
+	body, _ := io.ReadAll(rec.Body)
+	return strings.Contains(string(body), text)
+}
+
+// hasComponentID returns true if any metric in families has a component_id
+// label set to id.
+func hasComponentID(families []*dto.MetricFamily, id string) bool {
+	for _, mf := range families {
+		for _, m := range mf.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "component_id" && l.GetValue() == id {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
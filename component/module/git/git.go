@@ -0,0 +1,250 @@
+// Package git implements the module.git component.
+package git
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/module"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/rfratto/gohcl"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:    "module.git",
+		Args:    Arguments{},
+		Exports: module.Exports{},
+
+		Validate: func(args component.Arguments) error {
+			return args.(Arguments).Validate()
+		},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Arguments holds values which are used to configure the module.git
+// component.
+type Arguments struct {
+	// Repository is the URL of the git repository to load the module from.
+	Repository string `hcl:"repository,attr"`
+	// Revision is the branch, tag, or commit of the repository to use.
+	Revision string `hcl:"revision,optional"`
+	// Path is the path of the module file within the repository.
+	Path string `hcl:"path,attr"`
+	// PullFrequency determines how often the repository is pulled for
+	// changes.
+	PullFrequency time.Duration `hcl:"pull_frequency,optional"`
+
+	// Arguments to pass to the module.
+	Arguments cty.Value `hcl:"arguments,optional"`
+}
+
+// DefaultArguments provides the default arguments for the module.git
+// component.
+var DefaultArguments = Arguments{
+	Revision:      "HEAD",
+	PullFrequency: time.Minute,
+}
+
+var _ gohcl.Decoder = (*Arguments)(nil)
+
+// DecodeHCL implements gohcl.Decoder.
+func (a *Arguments) DecodeHCL(body hcl.Body, ctx *hcl.EvalContext) error {
+	*a = DefaultArguments
+
+	type arguments Arguments
+	return gohcl.DecodeBody(body, ctx, (*arguments)(a))
+}
+
+// Validate returns an error if a is invalid.
+func (a Arguments) Validate() error {
+	if a.PullFrequency <= 0 {
+		return component.ArgumentError{Name: "pull_frequency", Message: "must be greater than 0"}
+	}
+	return module.ValidateArguments(a.Arguments)
+}
+
+// Component implements the module.git component.
+type Component struct {
+	opts component.Options
+	mod  *module.ModuleComponent
+
+	mut        sync.Mutex
+	args       Arguments
+	repo       *repo
+	lastCommit string
+
+	healthMut sync.RWMutex
+	health    component.Health
+
+	// updateCh is a buffered channel which is written to when the component
+	// receives new arguments, so Run can pick up a new pull frequency.
+	updateCh chan struct{}
+}
+
+var (
+	_ component.Component       = (*Component)(nil)
+	_ component.HealthComponent = (*Component)(nil)
+	_ component.HTTPComponent   = (*Component)(nil)
+)
+
+// New creates a new module.git component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	m, err := module.NewModuleComponent(o)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Component{
+		opts: o,
+		mod:  m,
+
+		updateCh: make(chan struct{}, 1),
+	}
+
+	// Perform an update which will immediately clone the repository and load
+	// the module.
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := c.mod.RunFlowController(ctx); err != nil {
+			level.Error(c.opts.Logger).Log("msg", "error running module", "err", err)
+		}
+	}()
+	defer wg.Wait()
+
+	t := time.NewTicker(c.pullFrequency())
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-c.updateCh:
+			t.Reset(c.pullFrequency())
+		case <-t.C:
+			// We ignore the error here from pull since pull will log errors and
+			// also report the error as the health of the component.
+			_ = c.pull(ctx, false)
+		}
+	}
+}
+
+func (c *Component) pullFrequency() time.Duration {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.args.PullFrequency
+}
+
+// pull pulls the repository and loads the module if the revision changed
+// since the last load. If force is true, the module is always loaded.
+func (c *Component) pull(ctx context.Context, force bool) error {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if err := c.repo.Pull(ctx); err != nil {
+		c.setHealth(component.Health{
+			Health:     component.HealthTypeUnhealthy,
+			Message:    fmt.Sprintf("pulling repository failed: %s", err),
+			UpdateTime: time.Now(),
+		})
+		level.Error(c.opts.Logger).Log("msg", "failed to pull repository", "repository", c.args.Repository, "err", err)
+		return err
+	}
+
+	commit, content, err := c.repo.ReadFile(c.args.Revision, c.args.Path)
+	if err != nil {
+		c.setHealth(component.Health{
+			Health:     component.HealthTypeUnhealthy,
+			Message:    fmt.Sprintf("reading module failed: %s", err),
+			UpdateTime: time.Now(),
+		})
+		level.Error(c.opts.Logger).Log("msg", "failed to read module", "repository", c.args.Repository, "err", err)
+		return err
+	}
+
+	c.setHealth(component.Health{
+		Health:     component.HealthTypeHealthy,
+		Message:    "pulled repository",
+		UpdateTime: time.Now(),
+	})
+
+	if !force && commit.String() == c.lastCommit {
+		return nil
+	}
+	if err := c.mod.LoadFlowContent(c.args.Arguments, content); err != nil {
+		level.Error(c.opts.Logger).Log("msg", "failed to load module", "commit", commit, "err", err)
+		return err
+	}
+	c.lastCommit = commit.String()
+	return nil
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	c.mut.Lock()
+	if c.repo == nil || c.repo.url != newArgs.Repository {
+		r, err := openRepo(c.opts.DataPath, newArgs.Repository)
+		if err != nil {
+			c.mut.Unlock()
+			return err
+		}
+		c.repo = r
+	}
+	c.args = newArgs
+	c.mut.Unlock()
+
+	select {
+	case c.updateCh <- struct{}{}:
+	default:
+	}
+
+	// Force an immediate pull to report any potential errors early. The module
+	// is always reloaded, since its arguments may have changed.
+	if err := c.pull(context.Background(), true); err != nil {
+		return fmt.Errorf("failed to load module: %w", err)
+	}
+	return nil
+}
+
+// Handler implements component.HTTPComponent.
+func (c *Component) Handler() http.Handler {
+	return c.mod.HTTPHandler()
+}
+
+// CurrentHealth implements component.HealthComponent.
+func (c *Component) CurrentHealth() component.Health {
+	c.healthMut.RLock()
+	defer c.healthMut.RUnlock()
+	return module.LeastHealthy(c.health, c.mod.CurrentHealth())
+}
+
+func (c *Component) setHealth(h component.Health) {
+	c.healthMut.Lock()
+	defer c.healthMut.Unlock()
+	c.health = h
+}
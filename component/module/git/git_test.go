@@ -0,0 +1,114 @@
+package git_test
+
+import (
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	_ "github.com/grafana/agent/component/module/git"
+	"github.com/grafana/agent/pkg/flow"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModuleGit(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	require.NoError(t, err)
+
+	commitFile(t, repo, dir, "modules/greeting.flow", `
+		argument "name" {}
+
+		export "greeting" {
+			value = format("Hello, %s!", argument.name.value)
+		}
+	`)
+
+	f := flow.New(flow.Options{DataPath: t.TempDir()})
+	t.Cleanup(func() { require.NoError(t, f.Close()) })
+
+	ff, diags := flow.ReadFile("test", []byte(fmt.Sprintf(`
+		module "git" "m" {
+			repository     = %q
+			path           = "modules/greeting.flow"
+			pull_frequency = "50ms"
+
+			arguments = {
+				name = "world",
+			}
+		}
+
+		assert "exports" {
+			condition = module.git.m.exports.greeting == "Hello, world!"
+		}
+	`, dir)))
+	require.False(t, diags.HasErrors(), diags.Error())
+	require.NoError(t, f.LoadFile(ff))
+
+	// New commits are picked up on the next pull.
+	commitFile(t, repo, dir, "modules/greeting.flow", `
+		argument "name" {}
+
+		export "greeting" {
+			value = format("Goodbye, %s!", argument.name.value)
+		}
+	`)
+	require.Eventually(t, func() bool {
+		rec := httptest.NewRecorder()
+		f.ConfigHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/?debug=1", nil))
+		body, _ := io.ReadAll(rec.Body)
+		return strings.Contains(string(body), "Goodbye, world!")
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestModuleGit_Revision(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	require.NoError(t, err)
+
+	commitFile(t, repo, dir, "module.flow", `export "version" { value = "v1" }`)
+	head, err := repo.Head()
+	require.NoError(t, err)
+	_, err = repo.CreateTag("v1", head.Hash(), nil)
+	require.NoError(t, err)
+	commitFile(t, repo, dir, "module.flow", `export "version" { value = "v2" }`)
+
+	f := flow.New(flow.Options{DataPath: t.TempDir()})
+	t.Cleanup(func() { require.NoError(t, f.Close()) })
+
+	ff, diags := flow.ReadFile("test", []byte(fmt.Sprintf(`
+		module "git" "m" {
+			repository = %q
+			revision   = "v1"
+			path       = "module.flow"
+		}
+
+		assert "exports" {
+			condition = module.git.m.exports.version == "v1"
+		}
+	`, dir)))
+	require.False(t, diags.HasErrors(), diags.Error())
+	require.NoError(t, f.LoadFile(ff))
+}
+
+func commitFile(t *testing.T, repo *git.Repository, dir, path, content string) {
+	t.Helper()
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, path)), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, path), []byte(content), 0644))
+
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+	_, err = wt.Add(path)
+	require.NoError(t, err)
+	_, err = wt.Commit("update "+path, &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	require.NoError(t, err)
+}
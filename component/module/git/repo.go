@@ -0,0 +1,108 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// remoteName is the name of the remote that repositories are fetched from.
+const remoteName = "origin"
+
+// repo is a bare local mirror of a remote git repository.
+type repo struct {
+	url  string
+	repo *git.Repository
+}
+
+// openRepo opens the local mirror of url stored in dir, creating it if it
+// doesn't exist. An existing mirror of a different repository is removed.
+func openRepo(dir, url string) (*repo, error) {
+	r, err := git.PlainOpen(dir)
+	if err == nil {
+		remote, err := r.Remote(remoteName)
+		if err == nil && len(remote.Config().URLs) == 1 && remote.Config().URLs[0] == url {
+			return &repo{url: url, repo: r}, nil
+		}
+		// The mirror is for a different repository.
+		if err := os.RemoveAll(dir); err != nil {
+			return nil, fmt.Errorf("removing old repository: %w", err)
+		}
+	} else if !errors.Is(err, git.ErrRepositoryNotExists) {
+		return nil, fmt.Errorf("opening repository: %w", err)
+	}
+
+	r, err = git.PlainInit(dir, true)
+	if err != nil {
+		return nil, fmt.Errorf("creating repository: %w", err)
+	}
+	_, err = r.CreateRemote(&config.RemoteConfig{
+		Name: remoteName,
+		URLs: []string{url},
+		// Branches and tags are mirrored as local references so revisions are
+		// resolved against the remote's latest state.
+		Fetch: []config.RefSpec{
+			"+refs/heads/*:refs/heads/*",
+			"+refs/tags/*:refs/tags/*",
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating remote: %w", err)
+	}
+	return &repo{url: url, repo: r}, nil
+}
+
+// Pull fetches the latest branches and tags of the remote repository, and
+// points HEAD at the remote's HEAD.
+func (r *repo) Pull(ctx context.Context) error {
+	remote, err := r.repo.Remote(remoteName)
+	if err != nil {
+		return err
+	}
+
+	err = remote.FetchContext(ctx, &git.FetchOptions{Force: true, Tags: git.NoTags})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("fetching %s: %w", r.url, err)
+	}
+
+	refs, err := remote.ListContext(ctx, &git.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("listing references of %s: %w", r.url, err)
+	}
+	for _, ref := range refs {
+		if ref.Name() != plumbing.HEAD {
+			continue
+		}
+		if err := r.repo.Storer.SetReference(ref); err != nil {
+			return fmt.Errorf("updating HEAD: %w", err)
+		}
+	}
+	return nil
+}
+
+// ReadFile returns the commit which revision resolves to and the content of
+// the file at path in that commit.
+func (r *repo) ReadFile(revision, path string) (plumbing.Hash, string, error) {
+	hash, err := r.repo.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		return plumbing.ZeroHash, "", fmt.Errorf("resolving revision %q: %w", revision, err)
+	}
+	commit, err := r.repo.CommitObject(*hash)
+	if err != nil {
+		return plumbing.ZeroHash, "", fmt.Errorf("reading commit %s: %w", hash, err)
+	}
+	f, err := commit.File(path)
+	if err != nil {
+		return plumbing.ZeroHash, "", fmt.Errorf("reading %s at %s: %w", path, hash, err)
+	}
+	content, err := f.Contents()
+	if err != nil {
+		return plumbing.ZeroHash, "", fmt.Errorf("reading %s at %s: %w", path, hash, err)
+	}
+	return *hash, content, nil
+}
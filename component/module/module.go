@@ -0,0 +1,148 @@
+// Package module implements shared code for the module.* components, which
+// load a Flow config file as a module and expose its exports.
+package module
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/grafana/agent/component"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Exports holds values which are exported by module.* components.
+type Exports struct {
+	// Exports holds the values of the export blocks of the module, keyed by
+	// export name.
+	Exports cty.Value `hcl:"exports,attr"`
+}
+
+// ValidateArguments returns an error if args can't be passed as the
+// arguments of a module. args must be null or an object.
+func ValidateArguments(args cty.Value) error {
+	if args.IsNull() {
+		return nil
+	}
+	if ty := args.Type(); !ty.IsObjectType() && !ty.IsMapType() {
+		return fmt.Errorf("arguments must be an object, got %s", ty.FriendlyName())
+	}
+	if !args.IsWhollyKnown() {
+		return fmt.Errorf("arguments must be known")
+	}
+	return nil
+}
+
+// ModuleComponent runs a module on behalf of a module.* component. The
+// component is responsible for retrieving the content of the module and
+// calling LoadFlowContent whenever the content or arguments change.
+type ModuleComponent struct {
+	opts component.Options
+	mod  component.Module
+
+	healthMut sync.RWMutex
+	health    component.Health
+}
+
+// NewModuleComponent creates a new ModuleComponent. The exports of the
+// component are immediately set to an empty object.
+func NewModuleComponent(o component.Options) (*ModuleComponent, error) {
+	if o.NewModule == nil {
+		return nil, fmt.Errorf("modules are not supported by the controller running %s", o.ID)
+	}
+
+	c := &ModuleComponent{opts: o}
+	mod, err := o.NewModule("", c.setExports)
+	if err != nil {
+		return nil, fmt.Errorf("creating module: %w", err)
+	}
+	c.mod = mod
+
+	o.OnStateChange(Exports{Exports: cty.EmptyObjectVal})
+	return c, nil
+}
+
+func (c *ModuleComponent) setExports(exports map[string]cty.Value) {
+	c.opts.OnStateChange(Exports{Exports: cty.ObjectVal(exports)})
+}
+
+// LoadFlowContent loads content as the config of the module, passing args as
+// the module's arguments. args must have been validated with
+// ValidateArguments.
+//
+// If content fails to load, the module keeps running the last config which
+// loaded successfully.
+func (c *ModuleComponent) LoadFlowContent(args cty.Value, content string) error {
+	var argsMap map[string]cty.Value
+	if !args.IsNull() {
+		argsMap = args.AsValueMap()
+	}
+
+	if err := c.mod.LoadConfig([]byte(content), argsMap); err != nil {
+		c.setHealth(component.Health{
+			Health:     component.HealthTypeUnhealthy,
+			Message:    fmt.Sprintf("failed to load module: %s", err),
+			UpdateTime: time.Now(),
+		})
+		return err
+	}
+
+	c.setHealth(component.Health{
+		Health:     component.HealthTypeHealthy,
+		Message:    "module loaded",
+		UpdateTime: time.Now(),
+	})
+	return nil
+}
+
+// RunFlowController runs the components of the module until ctx is
+// canceled.
+func (c *ModuleComponent) RunFlowController(ctx context.Context) error {
+	return c.mod.Run(ctx)
+}
+
+// HTTPHandler returns the HTTP handler serving the components of the module.
+func (c *ModuleComponent) HTTPHandler() http.Handler {
+	return c.mod.ComponentHandler()
+}
+
+// CurrentHealth returns the health of the last load of the module.
+func (c *ModuleComponent) CurrentHealth() component.Health {
+	c.healthMut.RLock()
+	defer c.healthMut.RUnlock()
+	return c.health
+}
+
+func (c *ModuleComponent) setHealth(h component.Health) {
+	c.healthMut.Lock()
+	defer c.healthMut.Unlock()
+	c.health = h
+}
+
+// LeastHealthy returns the least healthy of the provided healths. Unknown
+// health is considered healthier than unhealthy but less healthy than
+// healthy.
+func LeastHealthy(h component.Health, hh ...component.Health) component.Health {
+	rank := func(ht component.HealthType) int {
+		switch ht {
+		case component.HealthTypeHealthy:
+			return 0
+		case component.HealthTypeUnknown:
+			return 1
+		case component.HealthTypeUnhealthy:
+			return 2
+		default:
+			return 3
+		}
+	}
+
+	least := h
+	for _, other := range hh {
+		if rank(other.Health) > rank(least.Health) {
+			least = other
+		}
+	}
+	return least
+}
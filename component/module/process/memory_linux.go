@@ -0,0 +1,29 @@
+//go:build linux
+// +build linux
+
+package process
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// processMemory returns the resident set size of the process with the given
+// PID in bytes.
+func processMemory(pid int) (uint64, error) {
+	bb, err := os.ReadFile(fmt.Sprintf("/proc/%d/statm", pid))
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(bb))
+	if len(fields) < 2 {
+		return 0, fmt.Errorf("unexpected content of /proc/%d/statm", pid)
+	}
+	pages, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return pages * uint64(os.Getpagesize()), nil
+}
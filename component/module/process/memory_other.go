@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package process
+
+import "errors"
+
+// processMemory returns the resident set size of the process with the given
+// PID in bytes. It's only supported on Linux; elsewhere memory limits are
+// only enforced by the Go runtime of the worker.
+func processMemory(pid int) (uint64, error) {
+	return 0, errors.New("reading the memory usage of a process is only supported on Linux")
+}
@@ -0,0 +1,614 @@
+// Package process implements the module.process component, which runs a
+// module in a child process of the agent so that crashes and memory leaks of
+// its components can't take down the rest of the agent.
+package process
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/module"
+	"github.com/grafana/agent/pkg/flow"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rfratto/gohcl"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:    "module.process",
+		Args:    Arguments{},
+		Exports: module.Exports{},
+
+		Validate: func(args component.Arguments) error {
+			return args.(Arguments).Validate()
+		},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+const (
+	// workerStopTimeout is how long a worker is given to exit after it's
+	// asked to stop before it's killed.
+	workerStopTimeout = 10 * time.Second
+	// loadTimeout is how long Update waits for a worker to load the module.
+	loadTimeout = time.Minute
+	// memoryCheckInterval is how often the memory usage of a worker is
+	// checked against its limit.
+	memoryCheckInterval = 5 * time.Second
+)
+
+// Arguments holds values which are used to configure the module.process
+// component.
+type Arguments struct {
+	// Content of the module to run.
+	Content string `hcl:"content,attr"`
+	// Arguments to pass to the module.
+	Arguments cty.Value `hcl:"arguments,optional"`
+
+	// MemoryLimitMiB is the memory limit of the worker process in MiB. No
+	// limit is enforced when 0.
+	MemoryLimitMiB uint64 `hcl:"memory_limit_mib,optional"`
+
+	// RestartMinBackoff and RestartMaxBackoff bound the delay before a worker
+	// which exited is restarted.
+	RestartMinBackoff time.Duration `hcl:"restart_min_backoff,optional"`
+	RestartMaxBackoff time.Duration `hcl:"restart_max_backoff,optional"`
+}
+
+// DefaultArguments provides the default arguments for the module.process
+// component.
+var DefaultArguments = Arguments{
+	RestartMinBackoff: time.Second,
+	RestartMaxBackoff: time.Minute,
+}
+
+var _ gohcl.Decoder = (*Arguments)(nil)
+
+// DecodeHCL implements gohcl.Decoder.
+func (a *Arguments) DecodeHCL(body hcl.Body, ctx *hcl.EvalContext) error {
+	*a = DefaultArguments
+
+	type arguments Arguments
+	return gohcl.DecodeBody(body, ctx, (*arguments)(a))
+}
+
+// Validate returns an error if a is invalid.
+func (a Arguments) Validate() error {
+	switch {
+	case a.RestartMinBackoff <= 0:
+		return component.ArgumentError{Name: "restart_min_backoff", Message: "must be greater than 0"}
+	case a.RestartMaxBackoff < a.RestartMinBackoff:
+		return component.ArgumentError{Name: "restart_max_backoff", Message: "must not be less than restart_min_backoff"}
+	}
+
+	// Syntax errors are reported right away rather than once the worker
+	// loads the module.
+	if _, diags := flow.ReadFile("module", []byte(a.Content)); diags.HasErrors() {
+		return component.ArgumentError{Name: "content", Message: diags.Error()}
+	}
+
+	if err := module.ValidateArguments(a.Arguments); err != nil {
+		return err
+	}
+	if _, err := a.marshalArguments(); err != nil {
+		return component.ArgumentError{Name: "arguments", Message: fmt.Sprintf("can't be sent to the worker process: %s", err)}
+	}
+	return nil
+}
+
+// marshalArguments encodes the arguments of the module to send them to a
+// worker. Arguments must have been validated with module.ValidateArguments.
+func (a Arguments) marshalArguments() (map[string]json.RawMessage, error) {
+	if a.Arguments.IsNull() {
+		return nil, nil
+	}
+	return marshalValues(a.Arguments.AsValueMap())
+}
+
+// Component implements the module.process component.
+type Component struct {
+	opts    component.Options
+	metrics *metrics
+	proxy   *httputil.ReverseProxy
+
+	mut    sync.Mutex
+	args   Arguments
+	worker *worker // Running worker; nil while no worker is running.
+
+	healthMut sync.RWMutex
+	health    [numHealthSources]component.Health
+
+	// restartCh is a buffered channel which is written to when the worker
+	// must be restarted to apply new arguments.
+	restartCh chan struct{}
+}
+
+// Sources of the health of the component. The least healthy one is
+// reported.
+const (
+	healthWorker = iota
+	healthLoad
+	healthExports
+	numHealthSources
+)
+
+var (
+	_ component.Component       = (*Component)(nil)
+	_ component.HealthComponent = (*Component)(nil)
+	_ component.HTTPComponent   = (*Component)(nil)
+)
+
+// New creates a new module.process component. The worker process is started
+// once the component runs.
+func New(o component.Options, args Arguments) (*Component, error) {
+	m, err := newMetrics(o.Registerer)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Component{
+		opts:      o,
+		metrics:   m,
+		args:      args,
+		restartCh: make(chan struct{}, 1),
+	}
+	c.proxy = &httputil.ReverseProxy{
+		Director: c.directToWorker,
+		ErrorHandler: func(w http.ResponseWriter, _ *http.Request, err error) {
+			http.Error(w, fmt.Sprintf("worker process unavailable: %s", err), http.StatusServiceUnavailable)
+		},
+	}
+
+	now := time.Now()
+	c.health[healthWorker] = component.Health{
+		Health:     component.HealthTypeUnknown,
+		Message:    "worker process not started",
+		UpdateTime: now,
+	}
+	c.health[healthLoad] = component.Health{
+		Health:     component.HealthTypeUnknown,
+		Message:    "module not loaded",
+		UpdateTime: now,
+	}
+	c.health[healthExports] = component.Health{
+		Health:     component.HealthTypeHealthy,
+		Message:    "no exports received",
+		UpdateTime: now,
+	}
+
+	o.OnStateChange(module.Exports{Exports: cty.EmptyObjectVal})
+	return c, nil
+}
+
+// Run implements component.Component. Workers which exit are restarted with
+// exponential backoff until ctx is canceled.
+func (c *Component) Run(ctx context.Context) error {
+	var delay time.Duration
+	for {
+		started := time.Now()
+		err := c.runWorker(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err == nil {
+			// The worker was stopped to apply new arguments.
+			delay = 0
+			continue
+		}
+
+		c.mut.Lock()
+		minBackoff, maxBackoff := c.args.RestartMinBackoff, c.args.RestartMaxBackoff
+		c.mut.Unlock()
+
+		// Workers which ran for longer than the maximum backoff are considered
+		// to have recovered.
+		switch {
+		case delay == 0 || time.Since(started) > maxBackoff:
+			delay = minBackoff
+		case delay*2 > maxBackoff:
+			delay = maxBackoff
+		default:
+			delay *= 2
+		}
+
+		c.metrics.restarts.Inc()
+		level.Error(c.opts.Logger).Log("msg", "worker process exited, restarting", "err", err, "backoff", delay)
+		c.setHealth(healthWorker, component.HealthTypeUnhealthy, fmt.Sprintf("worker process exited: %s", err))
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+	}
+}
+
+// runWorker starts a worker and runs it until it exits, ctx is canceled, or
+// it has to be restarted to apply new arguments. A nil error is returned
+// when the worker was stopped rather than exiting by itself.
+func (c *Component) runWorker(ctx context.Context) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("finding agent binary: %w", err)
+	}
+	if err := os.MkdirAll(c.opts.DataPath, 0770); err != nil {
+		return fmt.Errorf("creating data path: %w", err)
+	}
+
+	c.mut.Lock()
+	memoryLimit := c.args.MemoryLimitMiB * 1024 * 1024
+	c.mut.Unlock()
+
+	token, err := newWorkerToken()
+	if err != nil {
+		return fmt.Errorf("generating worker token: %w", err)
+	}
+
+	cmd := exec.Command(exe, WorkerCommand, "-id", c.opts.ID, "-storage.path", c.opts.DataPath)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), fmt.Sprintf("%s=%s", tokenEnv, token))
+	if memoryLimit > 0 {
+		// The Go runtime of the worker collects garbage more aggressively
+		// when approaching the limit, before it's enforced by killing the
+		// worker.
+		cmd.Env = append(cmd.Env, fmt.Sprintf("GOMEMLIMIT=%d", memoryLimit))
+	}
+
+	// Requests and events are exchanged over dedicated pipes rather than
+	// stdin and stdout, which the module's components may write to.
+	requestsR, requestsW, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	eventsR, eventsW, err := os.Pipe()
+	if err != nil {
+		_ = requestsR.Close()
+		_ = requestsW.Close()
+		return err
+	}
+	// The pipes become requestsFD and eventsFD in the worker.
+	cmd.ExtraFiles = []*os.File{requestsR, eventsW}
+
+	err = cmd.Start()
+	// The worker's ends of the pipes are closed so the supervisor sees the
+	// events pipe close when the worker exits.
+	_ = requestsR.Close()
+	_ = eventsW.Close()
+	if err != nil {
+		_ = requestsW.Close()
+		_ = eventsR.Close()
+		return fmt.Errorf("starting worker process: %w", err)
+	}
+
+	w := newWorker(cmd, requestsW, token)
+	go func() {
+		c.readEvents(w, eventsR)
+		_ = eventsR.Close()
+		w.exitErr = cmd.Wait()
+		_ = w.requests.Close()
+		close(w.exited)
+	}()
+
+	c.mut.Lock()
+	c.worker = w
+	args := c.args
+	c.mut.Unlock()
+
+	c.metrics.up.Set(1)
+	c.setHealth(healthWorker, component.HealthTypeHealthy, "worker process running")
+	defer func() {
+		c.mut.Lock()
+		c.worker = nil
+		c.mut.Unlock()
+
+		c.metrics.up.Set(0)
+		c.metrics.memory.Set(0)
+	}()
+
+	// The result of the initial load is reported through the health of the
+	// component.
+	if _, err := w.load(args); err != nil {
+		w.stop()
+		return fmt.Errorf("sending module to worker process: %w", err)
+	}
+
+	ticker := time.NewTicker(memoryCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.stop()
+			return nil
+		case <-c.restartCh:
+			w.stop()
+			return nil
+		case <-w.exited:
+			if w.exitErr != nil {
+				return w.exitErr
+			}
+			return errors.New("exited unexpectedly")
+		case <-ticker.C:
+			usage, err := processMemory(cmd.Process.Pid)
+			if err != nil {
+				continue
+			}
+			c.metrics.memory.Set(float64(usage))
+			if memoryLimit > 0 && usage > memoryLimit {
+				_ = cmd.Process.Kill()
+				<-w.exited
+				return fmt.Errorf("used %d bytes of memory, exceeding its limit of %d bytes", usage, memoryLimit)
+			}
+		}
+	}
+}
+
+// readEvents handles the events sent by w until its events pipe is closed.
+func (c *Component) readEvents(w *worker, r io.Reader) {
+	dec := json.NewDecoder(r)
+	for {
+		var ev workerEvent
+		if err := dec.Decode(&ev); err != nil {
+			return
+		}
+
+		switch {
+		case ev.HTTPAddr != "":
+			w.setHTTPAddr(ev.HTTPAddr)
+
+		case ev.Loaded != nil:
+			if ev.Loaded.Error != "" {
+				c.setHealth(healthLoad, component.HealthTypeUnhealthy, fmt.Sprintf("failed to load module: %s", ev.Loaded.Error))
+			} else {
+				c.setHealth(healthLoad, component.HealthTypeHealthy, "module loaded")
+			}
+			w.loaded(ev.Loaded)
+
+		case ev.Exports != nil:
+			values, err := unmarshalValues(ev.Exports.Values)
+			if err != nil {
+				c.setHealth(healthExports, component.HealthTypeUnhealthy, fmt.Sprintf("failed to decode exports: %s", err))
+				continue
+			}
+			c.opts.OnStateChange(module.Exports{Exports: cty.ObjectVal(values)})
+
+			if ev.Exports.Error != "" {
+				level.Error(c.opts.Logger).Log("msg", "failed to receive exports of module", "err", ev.Exports.Error)
+				c.setHealth(healthExports, component.HealthTypeUnhealthy, ev.Exports.Error)
+			} else {
+				c.setHealth(healthExports, component.HealthTypeHealthy, "exports received")
+			}
+		}
+	}
+}
+
+// Update implements component.Component. Changing the memory limit restarts
+// the worker; other changes are loaded by the running worker.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	c.mut.Lock()
+	restart := newArgs.MemoryLimitMiB != c.args.MemoryLimitMiB
+	c.args = newArgs
+	w := c.worker
+	c.mut.Unlock()
+
+	// Workers which aren't running load the latest arguments when they start.
+	if w == nil {
+		return nil
+	}
+	if restart {
+		select {
+		case c.restartCh <- struct{}{}:
+		default:
+		}
+		return nil
+	}
+
+	res, err := w.load(newArgs)
+	if err != nil {
+		return fmt.Errorf("sending module to worker process: %w", err)
+	}
+	select {
+	case err := <-res:
+		return err
+	case <-w.exited:
+		return errors.New("worker process exited while loading the module")
+	case <-time.After(loadTimeout):
+		return errors.New("timed out waiting for the worker process to load the module")
+	}
+}
+
+// directToWorker directs requests to the HTTP endpoints of the running
+// worker.
+func (c *Component) directToWorker(req *http.Request) {
+	c.mut.Lock()
+	w := c.worker
+	c.mut.Unlock()
+
+	req.URL.Scheme = "http"
+	req.URL.Host = ""
+	if w != nil {
+		req.URL.Host = w.HTTPAddr()
+		req.Header.Set("Authorization", "Bearer "+w.token)
+	}
+}
+
+// Handler implements component.HTTPComponent. Requests are proxied to the
+// worker, which serves the HTTP endpoints of the module's components and
+// their metrics under /metrics.
+func (c *Component) Handler() http.Handler {
+	return c.proxy
+}
+
+// CurrentHealth implements component.HealthComponent.
+func (c *Component) CurrentHealth() component.Health {
+	c.healthMut.RLock()
+	defer c.healthMut.RUnlock()
+	return module.LeastHealthy(c.health[0], c.health[1:]...)
+}
+
+func (c *Component) setHealth(source int, ht component.HealthType, msg string) {
+	c.healthMut.Lock()
+	defer c.healthMut.Unlock()
+	c.health[source] = component.Health{
+		Health:     ht,
+		Message:    msg,
+		UpdateTime: time.Now(),
+	}
+}
+
+// worker is a running worker process.
+type worker struct {
+	cmd      *exec.Cmd
+	requests io.WriteCloser
+	// token authenticates requests to the HTTP server of the worker.
+	token string
+
+	// exited is closed once the worker exits, after which exitErr holds the
+	// error it exited with.
+	exited  chan struct{}
+	exitErr error
+
+	mut      sync.Mutex
+	enc      *json.Encoder
+	nextID   uint64
+	pending  map[uint64]chan error
+	httpAddr string
+}
+
+func newWorker(cmd *exec.Cmd, requests io.WriteCloser, token string) *worker {
+	return &worker{
+		cmd:      cmd,
+		requests: requests,
+		token:    token,
+		exited:   make(chan struct{}),
+		enc:      json.NewEncoder(requests),
+		pending:  make(map[uint64]chan error),
+	}
+}
+
+// newWorkerToken returns a random token for a new worker.
+func newWorkerToken() (string, error) {
+	bb := make([]byte, 32)
+	if _, err := rand.Read(bb); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bb), nil
+}
+
+// load asks the worker to load the module with args. The returned channel
+// receives the result of the load.
+func (w *worker) load(args Arguments) (<-chan error, error) {
+	values, err := args.marshalArguments()
+	if err != nil {
+		return nil, err
+	}
+
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	w.nextID++
+	res := make(chan error, 1)
+	w.pending[w.nextID] = res
+
+	err = w.enc.Encode(loadRequest{ID: w.nextID, Content: args.Content, Arguments: values})
+	if err != nil {
+		delete(w.pending, w.nextID)
+		return nil, err
+	}
+	return res, nil
+}
+
+// loaded reports the result of a load to its caller.
+func (w *worker) loaded(res *loadResult) {
+	w.mut.Lock()
+	ch := w.pending[res.ID]
+	delete(w.pending, res.ID)
+	w.mut.Unlock()
+
+	if ch == nil {
+		return
+	}
+	if res.Error != "" {
+		ch <- errors.New(res.Error)
+	} else {
+		ch <- nil
+	}
+}
+
+func (w *worker) setHTTPAddr(addr string) {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	w.httpAddr = addr
+}
+
+// HTTPAddr returns the address the worker serves HTTP on, or an empty
+// string if it didn't report one yet.
+func (w *worker) HTTPAddr() string {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	return w.httpAddr
+}
+
+// stop asks the worker to exit by closing its requests pipe, killing it if
+// it doesn't exit in time.
+func (w *worker) stop() {
+	_ = w.requests.Close()
+
+	select {
+	case <-w.exited:
+	case <-time.After(workerStopTimeout):
+		_ = w.cmd.Process.Kill()
+		<-w.exited
+	}
+}
+
+type metrics struct {
+	up       prometheus.Gauge
+	restarts prometheus.Counter
+	memory   prometheus.Gauge
+}
+
+func newMetrics(reg prometheus.Registerer) (*metrics, error) {
+	m := &metrics{
+		up: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "module_process_worker_up",
+			Help: "1 while the worker process of the module is running.",
+		}),
+		restarts: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "module_process_worker_restarts_total",
+			Help: "Total number of times the worker process of the module was restarted after exiting.",
+		}),
+		memory: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "module_process_worker_memory_bytes",
+			Help: "Resident memory of the worker process of the module. Only reported on Linux.",
+		}),
+	}
+
+	for _, c := range []prometheus.Collector{m.up, m.restarts, m.memory} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
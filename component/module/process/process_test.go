@@ -0,0 +1,194 @@
+package process_test
+
+import (
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	_ "github.com/grafana/agent/component/local/file"
+	"github.com/grafana/agent/component/module/process"
+	"github.com/grafana/agent/pkg/flow"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMain runs the test binary as a worker process when it's re-executed by
+// module.process.
+func TestMain(m *testing.M) {
+	if len(os.Args) > 1 && os.Args[1] == process.WorkerCommand {
+		if err := process.RunWorker(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func TestModuleProcess(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "input"), "hello")
+
+	reg := prometheus.NewRegistry()
+	f := flow.New(flow.Options{DataPath: t.TempDir(), Reg: reg})
+	t.Cleanup(func() { require.NoError(t, f.Close()) })
+
+	loadConfig(t, f, fmt.Sprintf(`
+		module "process" "m" {
+			content = <<EOT
+				argument "path" {}
+				argument "prefix" {}
+
+				local "file" "x" {
+					filename = argument.path.value
+				}
+
+				export "content" {
+					value = "$${argument.prefix.value}-$${local.file.x.content}"
+				}
+			EOT
+
+			arguments = {
+				path   = %q,
+				prefix = "bridged",
+			}
+		}
+	`, filepath.Join(dir, "input")))
+
+	// Exports are bridged from the worker process.
+	require.Eventually(t, func() bool {
+		return configContains(f, `"bridged-hello"`)
+	}, 10*time.Second, 10*time.Millisecond)
+	require.Equal(t, 1.0, metricValue(t, reg, "module_process_worker_up"))
+
+	// The metrics of the module's components are proxied from the worker.
+	rec := httptest.NewRecorder()
+	f.ComponentHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/component/module.process.m/metrics", nil))
+	require.Equal(t, 200, rec.Code)
+	require.Contains(t, rec.Body.String(), `component_id="module.process.m/local.file.x"`)
+
+	// Workers which crash are restarted.
+	killWorkers(t)
+	require.Eventually(t, func() bool {
+		return metricValue(t, reg, "module_process_worker_restarts_total") == 1
+	}, 10*time.Second, 10*time.Millisecond)
+
+	writeFile(t, filepath.Join(dir, "input"), "again")
+	require.Eventually(t, func() bool {
+		return configContains(f, `"bridged-again"`)
+	}, 10*time.Second, 10*time.Millisecond)
+}
+
+func TestModuleProcess_InvalidArguments(t *testing.T) {
+	tt := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{
+			name: "invalid content",
+			config: `
+				module "process" "m" {
+					content = "local {"
+				}
+			`,
+			err: "content",
+		},
+		{
+			name: "invalid backoff",
+			config: `
+				module "process" "m" {
+					content             = ""
+					restart_min_backoff = "10s"
+					restart_max_backoff = "1s"
+				}
+			`,
+			err: "Invalid argument restart_max_backoff; must not be less than restart_min_backoff",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			f := flow.New(flow.Options{DataPath: t.TempDir()})
+			t.Cleanup(func() { require.NoError(t, f.Close()) })
+
+			ff, diags := flow.ReadFile("test", []byte(tc.config))
+			require.False(t, diags.HasErrors(), diags.Error())
+			err := f.LoadFile(ff)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.err)
+		})
+	}
+}
+
+// killWorkers kills the child processes of the test.
+func killWorkers(t *testing.T) {
+	t.Helper()
+
+	tasks, err := filepath.Glob(fmt.Sprintf("/proc/%d/task/*/children", os.Getpid()))
+	if err != nil || len(tasks) == 0 {
+		t.Skip("child processes can't be listed on this platform")
+	}
+
+	var killed int
+	for _, task := range tasks {
+		bb, err := os.ReadFile(task)
+		require.NoError(t, err)
+		for _, field := range strings.Fields(string(bb)) {
+			pid, err := strconv.Atoi(field)
+			require.NoError(t, err)
+			require.NoError(t, syscall.Kill(pid, syscall.SIGKILL))
+			killed++
+		}
+	}
+	require.NotZero(t, killed, "no worker process was found")
+}
+
+// metricValue returns the value of the gauge or counter registered in reg
+// with the given name, or 0 if it isn't registered.
+func metricValue(t *testing.T, reg *prometheus.Registry, name string) float64 {
+	t.Helper()
+
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	for _, mf := range families {
+		if mf.GetName() != name || len(mf.GetMetric()) == 0 {
+			continue
+		}
+		m := mf.GetMetric()[0]
+		if m.GetGauge() != nil {
+			return m.GetGauge().GetValue()
+		}
+		return m.GetCounter().GetValue()
+	}
+	return 0
+}
+
+func writeFile(t *testing.T, filename, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filename, []byte(content), 0664))
+}
+
+func loadConfig(t *testing.T, f *flow.Flow, config string) {
+	t.Helper()
+
+	ff, diags := flow.ReadFile("test", []byte(config))
+	require.False(t, diags.HasErrors(), diags.Error())
+	require.NoError(t, f.LoadFile(ff))
+}
+
+// configContains returns true if the debug view of the config of f, which
+// includes the exports of components, contains text.
+func configContains(f *flow.Flow, text string) bool {
+	rec := httptest.NewRecorder()
+	f.ConfigHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/?debug=1", nil))
+	body, _ := io.ReadAll(rec.Body)
+	return strings.Contains(string(body), text)
+}
@@ -0,0 +1,259 @@
+package process
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/agent/pkg/flow"
+	"github.com/grafana/agent/pkg/flow/hcltypes"
+	"github.com/grafana/agent/pkg/flow/logging"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// WorkerCommand is the first argument passed to the agent binary to run a
+// worker process of module.process. Binaries which install module.process
+// must call RunWorker when started with it.
+const WorkerCommand = "flow-worker"
+
+const (
+	// requestsFD and eventsFD are the file descriptors of the pipes a worker
+	// receives requests from and sends events to. They're passed to the
+	// worker through exec.Cmd.ExtraFiles so the stdout of its components
+	// can't corrupt the stream of events.
+	requestsFD = 3
+	eventsFD   = 4
+
+	// tokenEnv is the environment variable holding the token which requests
+	// to the HTTP server of a worker must be authenticated with.
+	tokenEnv = "AGENT_MODULE_PROCESS_TOKEN"
+)
+
+// loadRequest is sent by the supervisor to a worker over its requests pipe
+// to load the config of the module.
+type loadRequest struct {
+	ID      uint64 `json:"id"`
+	Content string `json:"content"`
+	// Arguments of the module, encoded with hcltypes.MarshalJSON.
+	Arguments map[string]json.RawMessage `json:"arguments,omitempty"`
+}
+
+// workerEvent is sent by a worker to the supervisor over its events pipe.
+// Exactly one of its fields is set.
+type workerEvent struct {
+	// Address the worker serves the HTTP endpoints of its components on.
+	// Sent once after the worker starts.
+	HTTPAddr string `json:"http_addr,omitempty"`
+
+	// Result of the load request with the given ID.
+	Loaded *loadResult `json:"loaded,omitempty"`
+
+	// New exports of the module.
+	Exports *exportsUpdate `json:"exports,omitempty"`
+}
+
+type loadResult struct {
+	ID    uint64 `json:"id"`
+	Error string `json:"error,omitempty"`
+}
+
+type exportsUpdate struct {
+	// Exports encoded with hcltypes.MarshalJSON, keyed by export name.
+	Values map[string]json.RawMessage `json:"values"`
+	// Error describes exports which couldn't be encoded and were left out.
+	Error string `json:"error,omitempty"`
+}
+
+// marshalValues encodes values with hcltypes.MarshalJSON. Values which can't
+// be encoded are left out and reported in the returned error.
+func marshalValues(values map[string]cty.Value) (map[string]json.RawMessage, error) {
+	var (
+		res  = make(map[string]json.RawMessage, len(values))
+		errs []string
+	)
+	for name, v := range values {
+		bb, err := hcltypes.MarshalJSON(v)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", name, err))
+			continue
+		}
+		res[name] = bb
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return res, errors.New(strings.Join(errs, "; "))
+	}
+	return res, nil
+}
+
+// unmarshalValues decodes values encoded with marshalValues.
+func unmarshalValues(values map[string]json.RawMessage) (map[string]cty.Value, error) {
+	res := make(map[string]cty.Value, len(values))
+	for name, bb := range values {
+		v, err := hcltypes.UnmarshalJSON(bb)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		res[name] = v
+	}
+	return res, nil
+}
+
+// RunWorker runs a worker process of module.process with the arguments
+// following WorkerCommand. The worker runs the module requested by the
+// supervisor over its requests pipe and reports back over its events pipe
+// until the requests pipe is closed.
+func RunWorker(args []string) error {
+	var (
+		id          string
+		storagePath string
+	)
+
+	fs := flag.NewFlagSet(WorkerCommand, flag.ContinueOnError)
+	fs.StringVar(&id, "id", id, "ID of the module.process component running the worker")
+	fs.StringVar(&storagePath, "storage.path", storagePath, "directory where the components of the module can store data")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("error parsing flags: %w", err)
+	}
+	if id == "" {
+		return fmt.Errorf("the -id flag is required")
+	}
+
+	token := os.Getenv(tokenEnv)
+	if token == "" {
+		return fmt.Errorf("%s must be set", tokenEnv)
+	}
+	// Processes started by the module's components don't need the token.
+	_ = os.Unsetenv(tokenEnv)
+
+	// The pipes aren't inherited by processes started by the module's
+	// components, which would keep them open after the worker exits.
+	syscall.CloseOnExec(requestsFD)
+	syscall.CloseOnExec(eventsFD)
+	var (
+		in  = os.NewFile(requestsFD, "requests")
+		out = os.NewFile(eventsFD, "events")
+	)
+	defer in.Close()
+	defer out.Close()
+
+	// Interrupts sent to the agent's process group are left to the
+	// supervisor, which stops the worker by closing its requests pipe.
+	signal.Ignore(os.Interrupt)
+
+	l, err := logging.New(os.Stderr, logging.DefaultOptions)
+	if err != nil {
+		return fmt.Errorf("building logger: %w", err)
+	}
+	return runWorker(l, id, storagePath, token, in, out)
+}
+
+func runWorker(l *logging.Logger, id, storagePath, token string, in io.Reader, out io.Writer) error {
+	var (
+		encMut sync.Mutex
+		enc    = json.NewEncoder(out)
+	)
+	send := func(ev workerEvent) {
+		encMut.Lock()
+		defer encMut.Unlock()
+		if err := enc.Encode(ev); err != nil {
+			level.Error(l).Log("msg", "failed to send event to supervisor", "err", err)
+		}
+	}
+
+	reg := prometheus.NewRegistry()
+	mod := flow.NewModule(flow.Options{
+		Logger:   l,
+		DataPath: storagePath,
+		Reg:      reg,
+	}, id, func(exports map[string]cty.Value) {
+		values, err := marshalValues(exports)
+		update := &exportsUpdate{Values: values}
+		if err != nil {
+			update.Error = fmt.Sprintf("exports can't be sent to the supervisor: %s", err)
+		}
+		send(workerEvent{Exports: update})
+	})
+
+	// The HTTP endpoints of the components and their metrics are served on
+	// the loopback interface for the supervisor to proxy. Other local
+	// processes can reach the interface too, so requests must carry the
+	// token of the worker.
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return fmt.Errorf("failed to listen for http traffic: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	mux.Handle("/", mod.ComponentHandler())
+	srv := &http.Server{Handler: requireToken(token, mux)}
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Close()
+
+	send(workerEvent{HTTPAddr: lis.Addr().String()})
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := mod.Run(ctx); err != nil {
+			level.Error(l).Log("msg", "error running module", "err", err)
+		}
+	}()
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	dec := json.NewDecoder(in)
+	for {
+		var req loadRequest
+		if err := dec.Decode(&req); err != nil {
+			// The supervisor closes the requests pipe to stop the worker.
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("reading request from supervisor: %w", err)
+		}
+
+		args, err := unmarshalValues(req.Arguments)
+		if err == nil {
+			err = mod.LoadConfig([]byte(req.Content), args)
+		}
+		res := &loadResult{ID: req.ID}
+		if err != nil {
+			res.Error = err.Error()
+		}
+		send(workerEvent{Loaded: res})
+	}
+}
+
+// requireToken wraps next so that only requests authenticated with token as
+// a bearer token are served.
+func requireToken(token string, next http.Handler) http.Handler {
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,36 @@
+package process
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequireToken(t *testing.T) {
+	h := requireToken("secret", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tt := []struct {
+		name   string
+		header string
+		expect int
+	}{
+		{name: "valid token", header: "Bearer secret", expect: http.StatusNoContent},
+		{name: "invalid token", header: "Bearer wrong", expect: http.StatusUnauthorized},
+		{name: "missing token", header: "", expect: http.StatusUnauthorized},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/metrics", nil)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			require.Equal(t, tc.expect, rec.Code)
+		})
+	}
+}
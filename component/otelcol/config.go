@@ -0,0 +1,301 @@
+package otelcol
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/rfratto/gohcl"
+	"go.opentelemetry.io/collector/config/configcompression"
+	"go.opentelemetry.io/collector/config/configgrpc"
+	"go.opentelemetry.io/collector/config/confighttp"
+	"go.opentelemetry.io/collector/config/confignet"
+	"go.opentelemetry.io/collector/config/configtls"
+	"go.opentelemetry.io/collector/exporter/exporterhelper"
+)
+
+// GRPCServerArguments holds shared settings for components which launch gRPC
+// servers. Components should provide their own defaults by wrapping the type
+// and implementing gohcl.Decoder.
+type GRPCServerArguments struct {
+	Endpoint  string `hcl:"endpoint,optional"`
+	Transport string `hcl:"transport,optional"`
+
+	TLS *TLSServerArguments `hcl:"tls,block"`
+
+	MaxRecvMsgSizeMiB    uint64 `hcl:"max_recv_msg_size_mib,optional"`
+	MaxConcurrentStreams uint32 `hcl:"max_concurrent_streams,optional"`
+	ReadBufferSize       int    `hcl:"read_buffer_size,optional"`
+	WriteBufferSize      int    `hcl:"write_buffer_size,optional"`
+	IncludeMetadata      bool   `hcl:"include_metadata,optional"`
+}
+
+// Convert converts args into the upstream type. Convert returns nil if args
+// is nil.
+func (args *GRPCServerArguments) Convert() *configgrpc.GRPCServerSettings {
+	if args == nil {
+		return nil
+	}
+
+	return &configgrpc.GRPCServerSettings{
+		NetAddr: confignet.NetAddr{
+			Endpoint:  args.Endpoint,
+			Transport: args.Transport,
+		},
+		TLSSetting:           args.TLS.Convert(),
+		MaxRecvMsgSizeMiB:    args.MaxRecvMsgSizeMiB,
+		MaxConcurrentStreams: args.MaxConcurrentStreams,
+		ReadBufferSize:       args.ReadBufferSize,
+		WriteBufferSize:      args.WriteBufferSize,
+		IncludeMetadata:      args.IncludeMetadata,
+	}
+}
+
+// HTTPServerArguments holds shared settings for components which launch HTTP
+// servers. Components should provide their own defaults by wrapping the type
+// and implementing gohcl.Decoder.
+type HTTPServerArguments struct {
+	Endpoint string `hcl:"endpoint,optional"`
+
+	TLS  *TLSServerArguments `hcl:"tls,block"`
+	CORS *CORSArguments      `hcl:"cors,block"`
+
+	MaxRequestBodySize int64 `hcl:"max_request_body_size,optional"`
+	IncludeMetadata    bool  `hcl:"include_metadata,optional"`
+}
+
+// Convert converts args into the upstream type. Convert returns nil if args
+// is nil.
+func (args *HTTPServerArguments) Convert() *confighttp.HTTPServerSettings {
+	if args == nil {
+		return nil
+	}
+
+	return &confighttp.HTTPServerSettings{
+		Endpoint:           args.Endpoint,
+		TLSSetting:         args.TLS.Convert(),
+		CORS:               args.CORS.Convert(),
+		MaxRequestBodySize: args.MaxRequestBodySize,
+		IncludeMetadata:    args.IncludeMetadata,
+	}
+}
+
+// CORSArguments configures cross-origin resource sharing for an HTTP server.
+type CORSArguments struct {
+	AllowedOrigins []string `hcl:"allowed_origins,optional"`
+	AllowedHeaders []string `hcl:"allowed_headers,optional"`
+	MaxAge         int      `hcl:"max_age,optional"`
+}
+
+// Convert converts args into the upstream type. Convert returns nil if args
+// is nil.
+func (args *CORSArguments) Convert() *confighttp.CORSSettings {
+	if args == nil {
+		return nil
+	}
+
+	return &confighttp.CORSSettings{
+		AllowedOrigins: args.AllowedOrigins,
+		AllowedHeaders: args.AllowedHeaders,
+		MaxAge:         args.MaxAge,
+	}
+}
+
+// GRPCClientArguments holds shared settings for components which send data
+// to a gRPC server. Components should provide their own defaults by wrapping
+// the type and implementing gohcl.Decoder.
+type GRPCClientArguments struct {
+	Endpoint string `hcl:"endpoint,optional"`
+
+	Compression string              `hcl:"compression,optional"`
+	TLS         *TLSClientArguments `hcl:"tls,block"`
+
+	ReadBufferSize  int               `hcl:"read_buffer_size,optional"`
+	WriteBufferSize int               `hcl:"write_buffer_size,optional"`
+	WaitForReady    bool              `hcl:"wait_for_ready,optional"`
+	Headers         map[string]string `hcl:"headers,optional"`
+	BalancerName    string            `hcl:"balancer_name,optional"`
+}
+
+// Convert converts args into the upstream type. An error is returned if the
+// compression type is unsupported.
+func (args *GRPCClientArguments) Convert() (configgrpc.GRPCClientSettings, error) {
+	var compression configcompression.CompressionType
+	if err := compression.UnmarshalText([]byte(args.Compression)); err != nil {
+		return configgrpc.GRPCClientSettings{}, err
+	}
+
+	return configgrpc.GRPCClientSettings{
+		Endpoint:        args.Endpoint,
+		Compression:     compression,
+		TLSSetting:      args.TLS.Convert(),
+		ReadBufferSize:  args.ReadBufferSize,
+		WriteBufferSize: args.WriteBufferSize,
+		WaitForReady:    args.WaitForReady,
+		Headers:         args.Headers,
+		BalancerName:    args.BalancerName,
+	}, nil
+}
+
+// TLSServerArguments configures TLS for a server.
+type TLSServerArguments struct {
+	CAFile         string        `hcl:"ca_file,optional"`
+	CertFile       string        `hcl:"cert_file,optional"`
+	KeyFile        string        `hcl:"key_file,optional"`
+	MinVersion     string        `hcl:"min_version,optional"`
+	MaxVersion     string        `hcl:"max_version,optional"`
+	ReloadInterval time.Duration `hcl:"reload_interval,optional"`
+
+	ClientCAFile string `hcl:"client_ca_file,optional"`
+}
+
+// Convert converts args into the upstream type. Convert returns nil if args
+// is nil, which disables TLS.
+func (args *TLSServerArguments) Convert() *configtls.TLSServerSetting {
+	if args == nil {
+		return nil
+	}
+
+	return &configtls.TLSServerSetting{
+		TLSSetting: configtls.TLSSetting{
+			CAFile:         args.CAFile,
+			CertFile:       args.CertFile,
+			KeyFile:        args.KeyFile,
+			MinVersion:     args.MinVersion,
+			MaxVersion:     args.MaxVersion,
+			ReloadInterval: args.ReloadInterval,
+		},
+		ClientCAFile: args.ClientCAFile,
+	}
+}
+
+// TLSClientArguments configures TLS for a client.
+type TLSClientArguments struct {
+	CAFile         string        `hcl:"ca_file,optional"`
+	CertFile       string        `hcl:"cert_file,optional"`
+	KeyFile        string        `hcl:"key_file,optional"`
+	MinVersion     string        `hcl:"min_version,optional"`
+	MaxVersion     string        `hcl:"max_version,optional"`
+	ReloadInterval time.Duration `hcl:"reload_interval,optional"`
+
+	Insecure           bool   `hcl:"insecure,optional"`
+	InsecureSkipVerify bool   `hcl:"insecure_skip_verify,optional"`
+	ServerName         string `hcl:"server_name,optional"`
+}
+
+// Convert converts args into the upstream type. A nil args converts to the
+// upstream defaults, which use TLS with the system root CAs.
+func (args *TLSClientArguments) Convert() configtls.TLSClientSetting {
+	if args == nil {
+		return configtls.TLSClientSetting{}
+	}
+
+	return configtls.TLSClientSetting{
+		TLSSetting: configtls.TLSSetting{
+			CAFile:         args.CAFile,
+			CertFile:       args.CertFile,
+			KeyFile:        args.KeyFile,
+			MinVersion:     args.MinVersion,
+			MaxVersion:     args.MaxVersion,
+			ReloadInterval: args.ReloadInterval,
+		},
+		Insecure:           args.Insecure,
+		InsecureSkipVerify: args.InsecureSkipVerify,
+		ServerName:         args.ServerName,
+	}
+}
+
+// QueueArguments configures the queue used by exporters to buffer data
+// before it is sent.
+type QueueArguments struct {
+	Enabled      bool `hcl:"enabled,optional"`
+	NumConsumers int  `hcl:"num_consumers,optional"`
+	QueueSize    int  `hcl:"queue_size,optional"`
+}
+
+// DefaultQueueArguments holds the default settings for QueueArguments.
+var DefaultQueueArguments = QueueArguments{
+	Enabled:      true,
+	NumConsumers: 10,
+	QueueSize:    5000,
+}
+
+var _ gohcl.Decoder = (*QueueArguments)(nil)
+
+// DecodeHCL implements gohcl.Decoder.
+func (args *QueueArguments) DecodeHCL(body hcl.Body, ctx *hcl.EvalContext) error {
+	*args = DefaultQueueArguments
+
+	type queueArguments QueueArguments
+	if err := gohcl.DecodeBody(body, ctx, (*queueArguments)(args)); err != nil {
+		return err
+	}
+	return args.Validate()
+}
+
+// Convert converts args into the upstream type. A nil args converts to
+// DefaultQueueArguments.
+func (args *QueueArguments) Convert() exporterhelper.QueueSettings {
+	if args == nil {
+		args = &DefaultQueueArguments
+	}
+
+	return exporterhelper.QueueSettings{
+		Enabled:      args.Enabled,
+		NumConsumers: args.NumConsumers,
+		QueueSize:    args.QueueSize,
+	}
+}
+
+// Validate returns an error if args is invalid.
+func (args *QueueArguments) Validate() error {
+	if args == nil || !args.Enabled {
+		return nil
+	}
+	if args.QueueSize <= 0 {
+		return fmt.Errorf("queue_size must be greater than 0")
+	}
+	return nil
+}
+
+// RetryArguments configures how exporters retry sending data after a
+// failure.
+type RetryArguments struct {
+	Enabled         bool          `hcl:"enabled,optional"`
+	InitialInterval time.Duration `hcl:"initial_interval,optional"`
+	MaxInterval     time.Duration `hcl:"max_interval,optional"`
+	MaxElapsedTime  time.Duration `hcl:"max_elapsed_time,optional"`
+}
+
+// DefaultRetryArguments holds the default settings for RetryArguments.
+var DefaultRetryArguments = RetryArguments{
+	Enabled:         true,
+	InitialInterval: 5 * time.Second,
+	MaxInterval:     30 * time.Second,
+	MaxElapsedTime:  5 * time.Minute,
+}
+
+var _ gohcl.Decoder = (*RetryArguments)(nil)
+
+// DecodeHCL implements gohcl.Decoder.
+func (args *RetryArguments) DecodeHCL(body hcl.Body, ctx *hcl.EvalContext) error {
+	*args = DefaultRetryArguments
+
+	type retryArguments RetryArguments
+	return gohcl.DecodeBody(body, ctx, (*retryArguments)(args))
+}
+
+// Convert converts args into the upstream type. A nil args converts to
+// DefaultRetryArguments.
+func (args *RetryArguments) Convert() exporterhelper.RetrySettings {
+	if args == nil {
+		args = &DefaultRetryArguments
+	}
+
+	return exporterhelper.RetrySettings{
+		Enabled:         args.Enabled,
+		InitialInterval: args.InitialInterval,
+		MaxInterval:     args.MaxInterval,
+		MaxElapsedTime:  args.MaxElapsedTime,
+	}
+}
@@ -0,0 +1,48 @@
+// Package otelcol implements shared types for OpenTelemetry Collector
+// components.
+package otelcol
+
+import (
+	"reflect"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/rfratto/gohcl"
+	"github.com/zclconf/go-cty/cty"
+	"go.opentelemetry.io/collector/consumer"
+)
+
+// Consumer receives traces. Consumers are exported by components which accept
+// traces, such as otelcol.exporter_otlp, and passed to components which
+// produce traces, such as otelcol.receiver_otlp.
+//
+// Consumers are passed by reference in HCL expressions, so the receiving
+// component keeps using the same Consumer for as long as it's referenced.
+type Consumer struct {
+	consumer.Traces
+}
+
+// ConsumerType is the HCL type of a *Consumer.
+var ConsumerType cty.Type
+
+func init() {
+	ConsumerType = cty.CapsuleWithOps("TracesConsumer", reflect.TypeOf(Consumer{}), &cty.CapsuleOps{
+		ExtensionData: func(key interface{}) interface{} {
+			switch key {
+			case gohcl.CapsuleTokenExtensionKey:
+				// Consumers have no textual representation, so they're encoded as a
+				// placeholder when displaying components.
+				return gohcl.CapsuleTokenExtension(func(v cty.Value) hclwrite.Tokens {
+					return hclwrite.Tokens{
+						{Type: hclsyntax.TokenOParen, Bytes: []byte("(")},
+						{Type: hclsyntax.TokenIdent, Bytes: []byte("traces_consumer")},
+						{Type: hclsyntax.TokenCParen, Bytes: []byte(")")},
+					}
+				})
+			}
+			return nil
+		},
+	})
+
+	gohcl.RegisterCapsuleType(ConsumerType)
+}
@@ -0,0 +1,179 @@
+// Package exporter implements a Flow component which wraps an OpenTelemetry
+// Collector traces exporter. Packages for specific exporters build on top of
+// it.
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/otelcol"
+	"github.com/grafana/agent/component/otelcol/internal/collector"
+	otelcomponent "go.opentelemetry.io/collector/component"
+	otelconfig "go.opentelemetry.io/collector/config"
+	"go.opentelemetry.io/collector/consumer"
+	otelpdata "go.opentelemetry.io/collector/model/pdata"
+)
+
+// Arguments is implemented by the Arguments of components which wrap an
+// OpenTelemetry Collector exporter.
+type Arguments interface {
+	component.Arguments
+
+	// Convert converts the Arguments into the configuration of the upstream
+	// exporter.
+	Convert() (otelconfig.Exporter, error)
+}
+
+// Exports holds values which are exported by exporter components.
+type Exports struct {
+	Receiver *otelcol.Consumer `hcl:"receiver,attr"`
+}
+
+// Exporter is a Flow component which runs an OpenTelemetry Collector traces
+// exporter. The upstream exporter is recreated whenever its configuration
+// changes.
+type Exporter struct {
+	opts    component.Options
+	factory otelcomponent.ExporterFactory
+
+	mut      sync.RWMutex
+	cfg      otelconfig.Exporter
+	exporter otelcomponent.TracesExporter
+
+	healthMut sync.RWMutex
+	health    component.Health
+}
+
+var (
+	_ component.Component       = (*Exporter)(nil)
+	_ component.HealthComponent = (*Exporter)(nil)
+	_ consumer.Traces           = (*Exporter)(nil)
+)
+
+// New creates a new Exporter which runs exporters created by f.
+func New(opts component.Options, f otelcomponent.ExporterFactory, args Arguments) (*Exporter, error) {
+	e := &Exporter{
+		opts:    opts,
+		factory: f,
+	}
+	if err := e.Update(args); err != nil {
+		return nil, err
+	}
+
+	// The Exporter forwards traces to whichever upstream exporter is
+	// currently running, so it's only exported once.
+	opts.OnStateChange(Exports{Receiver: &otelcol.Consumer{Traces: e}})
+	return e, nil
+}
+
+// Run implements component.Component.
+func (e *Exporter) Run(ctx context.Context) error {
+	<-ctx.Done()
+
+	e.mut.Lock()
+	defer e.mut.Unlock()
+	if e.exporter == nil {
+		return nil
+	}
+	err := e.exporter.Shutdown(context.Background())
+	e.exporter = nil
+	return err
+}
+
+// Update implements component.Component.
+func (e *Exporter) Update(args component.Arguments) error {
+	eargs := args.(Arguments)
+
+	cfg, err := eargs.Convert()
+	if err != nil {
+		return err
+	}
+	cfg.SetIDName(e.opts.ID)
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	e.mut.RLock()
+	unchanged := e.exporter != nil && reflect.DeepEqual(cfg, e.cfg)
+	e.mut.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	settings := otelcomponent.ExporterCreateSettings{
+		TelemetrySettings: collector.TelemetrySettings(e.opts.Logger),
+		BuildInfo:         collector.BuildInfo(),
+	}
+	exp, err := e.factory.CreateTracesExporter(context.Background(), settings, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create exporter: %w", err)
+	}
+
+	host := collector.NewHost(log.With(e.opts.Logger, "subcomponent", "host"), e.reportFatal)
+	if err := exp.Start(context.Background(), host); err != nil {
+		return fmt.Errorf("failed to start exporter: %w", err)
+	}
+
+	e.mut.Lock()
+	oldExporter := e.exporter
+	e.exporter, e.cfg = exp, cfg
+	e.mut.Unlock()
+
+	// The old exporter is stopped after it's swapped out so traces keep
+	// flowing while its queue is drained.
+	if oldExporter != nil {
+		if err := oldExporter.Shutdown(context.Background()); err != nil {
+			return fmt.Errorf("failed to stop old exporter: %w", err)
+		}
+	}
+
+	e.setHealth(component.Health{
+		Health:     component.HealthTypeHealthy,
+		Message:    "exporter started",
+		UpdateTime: time.Now(),
+	})
+	return nil
+}
+
+// Capabilities implements consumer.Traces.
+func (e *Exporter) Capabilities() consumer.Capabilities {
+	return consumer.Capabilities{MutatesData: false}
+}
+
+// ConsumeTraces implements consumer.Traces.
+func (e *Exporter) ConsumeTraces(ctx context.Context, td otelpdata.Traces) error {
+	e.mut.RLock()
+	defer e.mut.RUnlock()
+
+	if e.exporter == nil {
+		return fmt.Errorf("exporter %s is not running", e.opts.ID)
+	}
+	return e.exporter.ConsumeTraces(ctx, td)
+}
+
+func (e *Exporter) reportFatal(err error) {
+	e.setHealth(component.Health{
+		Health:     component.HealthTypeUnhealthy,
+		Message:    err.Error(),
+		UpdateTime: time.Now(),
+	})
+}
+
+func (e *Exporter) setHealth(h component.Health) {
+	e.healthMut.Lock()
+	defer e.healthMut.Unlock()
+	e.health = h
+}
+
+// CurrentHealth implements component.HealthComponent.
+func (e *Exporter) CurrentHealth() component.Health {
+	e.healthMut.RLock()
+	defer e.healthMut.RUnlock()
+	return e.health
+}
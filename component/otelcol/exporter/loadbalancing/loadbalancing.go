@@ -0,0 +1,135 @@
+// Package loadbalancing implements the otelcol.exporter_loadbalancing
+// component.
+package loadbalancing
+
+import (
+	"fmt"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/otelcol/exporter"
+	"github.com/grafana/agent/component/otelcol/exporter/otlp"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+	"github.com/rfratto/gohcl"
+	otelconfig "go.opentelemetry.io/collector/config"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:    "otelcol.exporter_loadbalancing",
+		Args:    Arguments{},
+		Exports: exporter.Exports{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return exporter.New(opts, loadbalancingexporter.NewFactory(), args.(Arguments))
+		},
+	})
+}
+
+// Arguments holds values which are used to configure the
+// otelcol.exporter_loadbalancing component.
+type Arguments struct {
+	Protocol Protocol         `hcl:"protocol,block"`
+	Resolver ResolverSettings `hcl:"resolver,block"`
+}
+
+var _ exporter.Arguments = Arguments{}
+
+// Convert implements exporter.Arguments.
+func (args Arguments) Convert() (otelconfig.Exporter, error) {
+	otlpConfig, err := otlp.Arguments(args.Protocol.OTLP).ConvertOTLP()
+	if err != nil {
+		return nil, err
+	}
+
+	return &loadbalancingexporter.Config{
+		ExporterSettings: otelconfig.NewExporterSettings(otelconfig.NewComponentID("loadbalancing")),
+		Protocol: loadbalancingexporter.Protocol{
+			OTLP: *otlpConfig,
+		},
+		Resolver: args.Resolver.Convert(),
+	}, nil
+}
+
+// Protocol configures how traces are sent to the backends.
+type Protocol struct {
+	OTLP OTLPArguments `hcl:"otlp,block"`
+}
+
+// OTLPArguments configures the OTLP exporters used to send traces to each
+// backend. It accepts the same settings as otelcol.exporter_otlp, but the
+// endpoint of the client is ignored in favor of the endpoints found by the
+// resolver.
+type OTLPArguments otlp.Arguments
+
+var _ gohcl.Decoder = (*OTLPArguments)(nil)
+
+// DecodeHCL implements gohcl.Decoder.
+func (args *OTLPArguments) DecodeHCL(body hcl.Body, ctx *hcl.EvalContext) error {
+	*args = OTLPArguments(otlp.DefaultArguments)
+
+	type arguments OTLPArguments
+	return gohcl.DecodeBody(body, ctx, (*arguments)(args))
+}
+
+// ResolverSettings configures how backends are discovered. Exactly one of
+// static or dns must be provided.
+type ResolverSettings struct {
+	Static *StaticResolver `hcl:"static,block"`
+	DNS    *DNSResolver    `hcl:"dns,block"`
+}
+
+var _ gohcl.Decoder = (*ResolverSettings)(nil)
+
+// DecodeHCL implements gohcl.Decoder.
+func (r *ResolverSettings) DecodeHCL(body hcl.Body, ctx *hcl.EvalContext) error {
+	*r = ResolverSettings{}
+
+	type resolverSettings ResolverSettings
+	if err := gohcl.DecodeBody(body, ctx, (*resolverSettings)(r)); err != nil {
+		return err
+	}
+	if (r.Static == nil) == (r.DNS == nil) {
+		return fmt.Errorf("exactly one of the static or dns blocks must be provided")
+	}
+	return nil
+}
+
+// Convert converts r into the upstream type.
+func (r ResolverSettings) Convert() loadbalancingexporter.ResolverSettings {
+	var res loadbalancingexporter.ResolverSettings
+	if r.Static != nil {
+		res.Static = &loadbalancingexporter.StaticResolver{Hostnames: r.Static.Hostnames}
+	}
+	if r.DNS != nil {
+		res.DNS = &loadbalancingexporter.DNSResolver{Hostname: r.DNS.Hostname, Port: r.DNS.Port}
+	}
+	return res
+}
+
+// StaticResolver sends traces to a fixed list of backends.
+type StaticResolver struct {
+	Hostnames []string `hcl:"hostnames,attr"`
+}
+
+// DNSResolver sends traces to the backends found by periodically resolving
+// a hostname.
+type DNSResolver struct {
+	Hostname string `hcl:"hostname,attr"`
+	Port     string `hcl:"port,optional"`
+}
+
+// DefaultDNSResolver holds the default settings for DNSResolver.
+var DefaultDNSResolver = DNSResolver{
+	Port: "4317",
+}
+
+var _ gohcl.Decoder = (*DNSResolver)(nil)
+
+// DecodeHCL implements gohcl.Decoder.
+func (r *DNSResolver) DecodeHCL(body hcl.Body, ctx *hcl.EvalContext) error {
+	*r = DefaultDNSResolver
+
+	type dnsResolver DNSResolver
+	return gohcl.DecodeBody(body, ctx, (*dnsResolver)(r))
+}
@@ -0,0 +1,94 @@
+package loadbalancing
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+	"github.com/rfratto/gohcl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArguments(t *testing.T) {
+	var args Arguments
+	diags := decodeArguments(t, `
+		protocol {
+			otlp {
+				timeout = "10s"
+
+				client {
+					tls {
+						insecure = true
+					}
+				}
+			}
+		}
+
+		resolver {
+			dns {
+				hostname = "tempo.example.com"
+			}
+		}
+	`, &args)
+	require.False(t, diags.HasErrors(), diags.Error())
+
+	cfg, err := args.Convert()
+	require.NoError(t, err)
+	lbConfig := cfg.(*loadbalancingexporter.Config)
+
+	require.Equal(t, &loadbalancingexporter.DNSResolver{
+		Hostname: "tempo.example.com",
+		Port:     "4317",
+	}, lbConfig.Resolver.DNS)
+	require.Nil(t, lbConfig.Resolver.Static)
+	require.True(t, lbConfig.Protocol.OTLP.TLSSetting.Insecure)
+	require.Equal(t, "10s", lbConfig.Protocol.OTLP.Timeout.String())
+	require.True(t, lbConfig.Protocol.OTLP.QueueSettings.Enabled)
+}
+
+func TestArguments_InvalidResolver(t *testing.T) {
+	tt := []struct {
+		name, resolver string
+	}{
+		{name: "no resolvers", resolver: ``},
+		{
+			name: "multiple resolvers",
+			resolver: `
+				static {
+					hostnames = ["tempo-1:4317"]
+				}
+				dns {
+					hostname = "tempo.example.com"
+				}
+			`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var args Arguments
+			diags := decodeArguments(t, `
+				protocol {
+					otlp {
+						client {}
+					}
+				}
+
+				resolver {
+					`+tc.resolver+`
+				}
+			`, &args)
+			require.True(t, diags.HasErrors())
+			require.Contains(t, diags.Error(), "exactly one of the static or dns blocks must be provided")
+		})
+	}
+}
+
+func decodeArguments(t *testing.T, cfg string, args *Arguments) hcl.Diagnostics {
+	t.Helper()
+
+	file, diags := hclparse.NewParser().ParseHCL([]byte(cfg), "test.flow")
+	require.False(t, diags.HasErrors(), diags.Error())
+	return gohcl.DecodeBody(file.Body, nil, args)
+}
@@ -0,0 +1,111 @@
+// Package otlp implements the otelcol.exporter_otlp component.
+package otlp
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/otelcol"
+	"github.com/grafana/agent/component/otelcol/exporter"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/rfratto/gohcl"
+	otelconfig "go.opentelemetry.io/collector/config"
+	"go.opentelemetry.io/collector/exporter/otlpexporter"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:    "otelcol.exporter_otlp",
+		Args:    Arguments{},
+		Exports: exporter.Exports{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return exporter.New(opts, otlpexporter.NewFactory(), args.(Arguments))
+		},
+	})
+}
+
+// Arguments holds values which are used to configure the
+// otelcol.exporter_otlp component.
+type Arguments struct {
+	Timeout time.Duration `hcl:"timeout,optional"`
+
+	Queue  *otelcol.QueueArguments `hcl:"sending_queue,block"`
+	Retry  *otelcol.RetryArguments `hcl:"retry_on_failure,block"`
+	Client GRPCClientArguments     `hcl:"client,block"`
+}
+
+// DefaultArguments holds the default settings for Arguments.
+var DefaultArguments = Arguments{
+	Timeout: 5 * time.Second,
+}
+
+var (
+	_ exporter.Arguments = Arguments{}
+	_ gohcl.Decoder      = (*Arguments)(nil)
+)
+
+// DecodeHCL implements gohcl.Decoder.
+func (args *Arguments) DecodeHCL(body hcl.Body, ctx *hcl.EvalContext) error {
+	*args = DefaultArguments
+
+	type arguments Arguments
+	if err := gohcl.DecodeBody(body, ctx, (*arguments)(args)); err != nil {
+		return err
+	}
+	if args.Client.Endpoint == "" {
+		return fmt.Errorf("client endpoint must be set")
+	}
+	return nil
+}
+
+// Convert implements exporter.Arguments.
+func (args Arguments) Convert() (otelconfig.Exporter, error) {
+	return args.ConvertOTLP()
+}
+
+// ConvertOTLP converts args into the configuration of the upstream OTLP
+// exporter.
+func (args Arguments) ConvertOTLP() (*otlpexporter.Config, error) {
+	client, err := (*otelcol.GRPCClientArguments)(&args.Client).Convert()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &otlpexporter.Config{
+		ExporterSettings:   otelconfig.NewExporterSettings(otelconfig.NewComponentID("otlp")),
+		QueueSettings:      args.Queue.Convert(),
+		RetrySettings:      args.Retry.Convert(),
+		GRPCClientSettings: client,
+	}
+	cfg.Timeout = args.Timeout
+	return cfg, nil
+}
+
+// GRPCClientArguments configures the gRPC client used to send traces.
+type GRPCClientArguments otelcol.GRPCClientArguments
+
+// DefaultGRPCClientArguments holds the default settings for
+// GRPCClientArguments.
+var DefaultGRPCClientArguments = GRPCClientArguments{
+	Compression:     "gzip",
+	WriteBufferSize: 512 * 1024,
+}
+
+var _ gohcl.Decoder = (*GRPCClientArguments)(nil)
+
+// DecodeHCL implements gohcl.Decoder.
+func (args *GRPCClientArguments) DecodeHCL(body hcl.Body, ctx *hcl.EvalContext) error {
+	*args = DefaultGRPCClientArguments
+
+	type arguments GRPCClientArguments
+	if err := gohcl.DecodeBody(body, ctx, (*arguments)(args)); err != nil {
+		return err
+	}
+
+	// Check the compression type early so errors are reported while the config
+	// is being loaded.
+	_, err := (*otelcol.GRPCClientArguments)(args).Convert()
+	return err
+}
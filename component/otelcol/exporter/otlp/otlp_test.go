@@ -0,0 +1,142 @@
+package otlp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/otelcol"
+	"github.com/grafana/agent/component/otelcol/exporter"
+	"github.com/grafana/agent/component/otelcol/receiver"
+	otlpreceiver "github.com/grafana/agent/component/otelcol/receiver/otlp"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/rfratto/gohcl"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/exporter/otlpexporter"
+	otelpdata "go.opentelemetry.io/collector/model/pdata"
+	upstreamotlpreceiver "go.opentelemetry.io/collector/receiver/otlpreceiver"
+)
+
+// TestExporter sends traces through otelcol.exporter_otlp to an
+// otelcol.receiver_otlp.
+func TestExporter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	addr := freeAddress(t)
+
+	var sink consumertest.TracesSink
+	grpcArgs := otlpreceiver.DefaultGRPCServerArguments
+	grpcArgs.Endpoint = addr
+	recvArgs := otlpreceiver.Arguments{
+		GRPC:      &grpcArgs,
+		ForwardTo: []*otelcol.Consumer{{Traces: &sink}},
+	}
+
+	recv, err := receiver.New(component.Options{
+		ID:     "otelcol.receiver_otlp.test",
+		Logger: util.TestLogger(t),
+	}, upstreamotlpreceiver.NewFactory(), recvArgs)
+	require.NoError(t, err)
+	go func() { _ = recv.Run(ctx) }()
+
+	var exportArgs Arguments
+	decodeHCL(t, fmt.Sprintf(`
+		client {
+			endpoint = "%s"
+
+			tls {
+				insecure = true
+			}
+		}
+	`, addr), &exportArgs)
+
+	var exports exporter.Exports
+	exp, err := exporter.New(component.Options{
+		ID:            "otelcol.exporter_otlp.test",
+		Logger:        util.TestLogger(t),
+		OnStateChange: func(e component.Exports) { exports = e.(exporter.Exports) },
+	}, otlpexporter.NewFactory(), exportArgs)
+	require.NoError(t, err)
+	require.NotNil(t, exports.Receiver)
+	go func() { _ = exp.Run(ctx) }()
+
+	require.NoError(t, exports.Receiver.ConsumeTraces(ctx, newTestTraces()))
+
+	require.Eventually(t, func() bool {
+		return sink.SpanCount() == 1
+	}, 30*time.Second, 50*time.Millisecond, "span was never received")
+}
+
+func TestArguments_Invalid(t *testing.T) {
+	tt := []struct {
+		name, cfg, err string
+	}{
+		{
+			name: "missing client",
+			cfg:  `timeout = "10s"`,
+			err:  `Missing client block`,
+		},
+		{
+			name: "missing endpoint",
+			cfg:  `client {}`,
+			err:  "client endpoint must be set",
+		},
+		{
+			name: "invalid compression",
+			cfg: `
+				client {
+					endpoint    = "localhost:4317"
+					compression = "lz4"
+				}
+			`,
+			err: `unsupported compression type "lz4"`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			file, diags := hclparse.NewParser().ParseHCL([]byte(tc.cfg), "test.flow")
+			require.False(t, diags.HasErrors(), diags.Error())
+
+			var args Arguments
+			diags = gohcl.DecodeBody(file.Body, nil, &args)
+			require.True(t, diags.HasErrors())
+			require.Contains(t, diags.Error(), tc.err)
+		})
+	}
+}
+
+func decodeHCL(t *testing.T, cfg string, v interface{}) {
+	t.Helper()
+
+	file, diags := hclparse.NewParser().ParseHCL([]byte(cfg), "test.flow")
+	require.False(t, diags.HasErrors(), diags.Error())
+
+	diags = gohcl.DecodeBody(file.Body, nil, v)
+	require.False(t, diags.HasErrors(), diags.Error())
+}
+
+// freeAddress returns a local address which nothing is listening on.
+func freeAddress(t *testing.T) string {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer lis.Close()
+	return lis.Addr().String()
+}
+
+func newTestTraces() otelpdata.Traces {
+	traces := otelpdata.NewTraces()
+	span := traces.ResourceSpans().AppendEmpty().InstrumentationLibrarySpans().AppendEmpty().Spans().AppendEmpty()
+	span.SetName("test")
+	span.SetTraceID(otelpdata.NewTraceID([16]byte{1, 2, 3, 4}))
+	span.SetSpanID(otelpdata.NewSpanID([8]byte{1, 2}))
+	return traces
+}
@@ -0,0 +1,59 @@
+package otelcol
+
+import (
+	"context"
+	"sync"
+
+	"github.com/hashicorp/go-multierror"
+	"go.opentelemetry.io/collector/consumer"
+	otelpdata "go.opentelemetry.io/collector/model/pdata"
+)
+
+// Fanout is a consumer.Traces which forwards traces to a set of Consumers.
+// The set of Consumers can be changed at any time.
+type Fanout struct {
+	mut      sync.RWMutex
+	children []*Consumer
+}
+
+var _ consumer.Traces = (*Fanout)(nil)
+
+// NewFanout creates a Fanout which forwards traces to children.
+func NewFanout(children []*Consumer) *Fanout {
+	return &Fanout{children: children}
+}
+
+// UpdateChildren changes the Consumers traces are forwarded to.
+func (f *Fanout) UpdateChildren(children []*Consumer) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	f.children = children
+}
+
+// Capabilities implements consumer.Traces. Children which mutate data are
+// given their own copy of the traces, so Fanout never mutates its input.
+func (f *Fanout) Capabilities() consumer.Capabilities {
+	return consumer.Capabilities{MutatesData: false}
+}
+
+// ConsumeTraces implements consumer.Traces.
+func (f *Fanout) ConsumeTraces(ctx context.Context, td otelpdata.Traces) error {
+	f.mut.RLock()
+	defer f.mut.RUnlock()
+
+	var errs error
+	for _, child := range f.children {
+		if child == nil || child.Traces == nil {
+			continue
+		}
+
+		data := td
+		if child.Capabilities().MutatesData {
+			data = td.Clone()
+		}
+		if err := child.ConsumeTraces(ctx, data); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,72 @@
+// Package collector holds utilities for running OpenTelemetry Collector
+// receivers and exporters inside of Flow components.
+package collector
+
+import (
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/agent/pkg/build"
+	otelcomponent "go.opentelemetry.io/collector/component"
+	otelconfig "go.opentelemetry.io/collector/config"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TelemetrySettings returns settings for OpenTelemetry Collector components
+// which log to l.
+func TelemetrySettings(l log.Logger) otelcomponent.TelemetrySettings {
+	return otelcomponent.TelemetrySettings{
+		Logger:         NewLogger(l),
+		TracerProvider: trace.NewNoopTracerProvider(),
+		MeterProvider:  metric.NewNoopMeterProvider(),
+	}
+}
+
+// BuildInfo returns the build information passed to OpenTelemetry Collector
+// components.
+func BuildInfo() otelcomponent.BuildInfo {
+	return otelcomponent.BuildInfo{
+		Command:     "agent",
+		Description: "agent",
+		Version:     build.Version,
+	}
+}
+
+// Host implements otelcomponent.Host for a single OpenTelemetry Collector
+// component. Flow components don't share factories, extensions, or
+// exporters with each other, so Host only handles fatal errors.
+type Host struct {
+	log     log.Logger
+	onFatal func(err error)
+}
+
+var _ otelcomponent.Host = (*Host)(nil)
+
+// NewHost creates a new Host. onFatal is invoked when the hosted component
+// reports a fatal error.
+func NewHost(l log.Logger, onFatal func(err error)) *Host {
+	return &Host{log: l, onFatal: onFatal}
+}
+
+// ReportFatalError implements otelcomponent.Host.
+func (h *Host) ReportFatalError(err error) {
+	level.Error(h.log).Log("msg", "fatal error reported by component", "err", err)
+	if h.onFatal != nil {
+		h.onFatal(err)
+	}
+}
+
+// GetFactory implements otelcomponent.Host. It always returns nil.
+func (h *Host) GetFactory(otelcomponent.Kind, otelconfig.Type) otelcomponent.Factory {
+	return nil
+}
+
+// GetExtensions implements otelcomponent.Host. It always returns nil.
+func (h *Host) GetExtensions() map[otelconfig.ComponentID]otelcomponent.Extension {
+	return nil
+}
+
+// GetExporters implements otelcomponent.Host. It always returns nil.
+func (h *Host) GetExporters() map[otelconfig.DataType]map[otelconfig.ComponentID]otelcomponent.Exporter {
+	return nil
+}
@@ -0,0 +1,71 @@
+package collector
+
+import (
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// NewLogger returns a *zap.Logger which writes logs to l. Level filtering is
+// left to l.
+func NewLogger(l log.Logger) *zap.Logger {
+	return zap.New(&loggerCore{inner: l})
+}
+
+// loggerCore is a zapcore.Core which writes entries to a go-kit logger.
+type loggerCore struct {
+	inner log.Logger
+}
+
+var _ zapcore.Core = (*loggerCore)(nil)
+
+// Enabled implements zapcore.Core. All levels are enabled, as filtering is
+// done by the go-kit logger.
+func (lc *loggerCore) Enabled(zapcore.Level) bool { return true }
+
+// With implements zapcore.Core.
+func (lc *loggerCore) With(ff []zapcore.Field) zapcore.Core {
+	return &loggerCore{inner: log.With(lc.inner, fieldsToKeyvals(ff)...)}
+}
+
+// Check implements zapcore.Core.
+func (lc *loggerCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return ce.AddCore(e, lc)
+}
+
+// Write implements zapcore.Core.
+func (lc *loggerCore) Write(e zapcore.Entry, ff []zapcore.Field) error {
+	keyvals := append([]interface{}{"msg", e.Message}, fieldsToKeyvals(ff)...)
+	return levelLogger(lc.inner, e.Level).Log(keyvals...)
+}
+
+// Sync implements zapcore.Core.
+func (lc *loggerCore) Sync() error { return nil }
+
+func levelLogger(l log.Logger, lvl zapcore.Level) log.Logger {
+	switch lvl {
+	case zapcore.DebugLevel:
+		return level.Debug(l)
+	case zapcore.InfoLevel:
+		return level.Info(l)
+	case zapcore.WarnLevel:
+		return level.Warn(l)
+	default:
+		return level.Error(l)
+	}
+}
+
+// fieldsToKeyvals converts zap fields into go-kit key/value pairs, keeping
+// the order of ff.
+func fieldsToKeyvals(ff []zapcore.Field) []interface{} {
+	keyvals := make([]interface{}, 0, len(ff)*2)
+	for _, f := range ff {
+		enc := zapcore.NewMapObjectEncoder()
+		f.AddTo(enc)
+		for k, v := range enc.Fields {
+			keyvals = append(keyvals, k, v)
+		}
+	}
+	return keyvals
+}
@@ -0,0 +1,176 @@
+// Package jaeger implements the otelcol.receiver_jaeger component.
+package jaeger
+
+import (
+	"fmt"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/otelcol"
+	"github.com/grafana/agent/component/otelcol/receiver"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/receiver/jaegerreceiver"
+	"github.com/rfratto/gohcl"
+	otelconfig "go.opentelemetry.io/collector/config"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name: "otelcol.receiver_jaeger",
+		Args: Arguments{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return receiver.New(opts, jaegerreceiver.NewFactory(), args.(Arguments))
+		},
+	})
+}
+
+// Arguments holds values which are used to configure the
+// otelcol.receiver_jaeger component.
+type Arguments struct {
+	GRPC          *GRPCServerArguments `hcl:"grpc,block"`
+	ThriftHTTP    *HTTPServerArguments `hcl:"thrift_http,block"`
+	ThriftBinary  *ThriftBinary        `hcl:"thrift_binary,block"`
+	ThriftCompact *ThriftCompact       `hcl:"thrift_compact,block"`
+
+	ForwardTo []*otelcol.Consumer `hcl:"forward_to,attr"`
+}
+
+var (
+	_ receiver.Arguments = Arguments{}
+	_ gohcl.Decoder      = (*Arguments)(nil)
+)
+
+// DecodeHCL implements gohcl.Decoder.
+func (args *Arguments) DecodeHCL(body hcl.Body, ctx *hcl.EvalContext) error {
+	*args = Arguments{}
+
+	type arguments Arguments
+	if err := gohcl.DecodeBody(body, ctx, (*arguments)(args)); err != nil {
+		return err
+	}
+	if args.GRPC == nil && args.ThriftHTTP == nil && args.ThriftBinary == nil && args.ThriftCompact == nil {
+		return fmt.Errorf("at least one of the grpc, thrift_http, thrift_binary, or thrift_compact blocks must be provided")
+	}
+	return nil
+}
+
+// Convert implements receiver.Arguments.
+func (args Arguments) Convert() (otelconfig.Receiver, error) {
+	return &jaegerreceiver.Config{
+		ReceiverSettings: otelconfig.NewReceiverSettings(otelconfig.NewComponentID("jaeger")),
+		Protocols: jaegerreceiver.Protocols{
+			GRPC:          (*otelcol.GRPCServerArguments)(args.GRPC).Convert(),
+			ThriftHTTP:    (*otelcol.HTTPServerArguments)(args.ThriftHTTP).Convert(),
+			ThriftBinary:  (*UDPServerArguments)(args.ThriftBinary).Convert(),
+			ThriftCompact: (*UDPServerArguments)(args.ThriftCompact).Convert(),
+		},
+	}, nil
+}
+
+// NextConsumers implements receiver.Arguments.
+func (args Arguments) NextConsumers() []*otelcol.Consumer {
+	return args.ForwardTo
+}
+
+// GRPCServerArguments configures the gRPC server of the receiver.
+type GRPCServerArguments otelcol.GRPCServerArguments
+
+// DefaultGRPCServerArguments holds the default settings for
+// GRPCServerArguments.
+var DefaultGRPCServerArguments = GRPCServerArguments{
+	Endpoint:  "0.0.0.0:14250",
+	Transport: "tcp",
+}
+
+var _ gohcl.Decoder = (*GRPCServerArguments)(nil)
+
+// DecodeHCL implements gohcl.Decoder.
+func (args *GRPCServerArguments) DecodeHCL(body hcl.Body, ctx *hcl.EvalContext) error {
+	*args = DefaultGRPCServerArguments
+
+	type arguments GRPCServerArguments
+	return gohcl.DecodeBody(body, ctx, (*arguments)(args))
+}
+
+// HTTPServerArguments configures the Thrift HTTP server of the receiver.
+type HTTPServerArguments otelcol.HTTPServerArguments
+
+// DefaultHTTPServerArguments holds the default settings for
+// HTTPServerArguments.
+var DefaultHTTPServerArguments = HTTPServerArguments{
+	Endpoint: "0.0.0.0:14268",
+}
+
+var _ gohcl.Decoder = (*HTTPServerArguments)(nil)
+
+// DecodeHCL implements gohcl.Decoder.
+func (args *HTTPServerArguments) DecodeHCL(body hcl.Body, ctx *hcl.EvalContext) error {
+	*args = DefaultHTTPServerArguments
+
+	type arguments HTTPServerArguments
+	return gohcl.DecodeBody(body, ctx, (*arguments)(args))
+}
+
+// UDPServerArguments configures a UDP server of the receiver.
+type UDPServerArguments struct {
+	Endpoint         string `hcl:"endpoint,optional"`
+	QueueSize        int    `hcl:"queue_size,optional"`
+	MaxPacketSize    int    `hcl:"max_packet_size,optional"`
+	Workers          int    `hcl:"workers,optional"`
+	SocketBufferSize int    `hcl:"socket_buffer_size,optional"`
+}
+
+// Convert converts args into the upstream type. Convert returns nil if args
+// is nil.
+func (args *UDPServerArguments) Convert() *jaegerreceiver.ProtocolUDP {
+	if args == nil {
+		return nil
+	}
+
+	return &jaegerreceiver.ProtocolUDP{
+		Endpoint: args.Endpoint,
+		ServerConfigUDP: jaegerreceiver.ServerConfigUDP{
+			QueueSize:        args.QueueSize,
+			MaxPacketSize:    args.MaxPacketSize,
+			Workers:          args.Workers,
+			SocketBufferSize: args.SocketBufferSize,
+		},
+	}
+}
+
+// defaultUDPServerArguments returns the default UDP server settings for a
+// server listening on endpoint.
+func defaultUDPServerArguments(endpoint string) UDPServerArguments {
+	return UDPServerArguments{
+		Endpoint:      endpoint,
+		QueueSize:     1000,
+		MaxPacketSize: 65000,
+		Workers:       10,
+	}
+}
+
+// ThriftBinary configures the Thrift binary UDP server of the receiver.
+type ThriftBinary UDPServerArguments
+
+var _ gohcl.Decoder = (*ThriftBinary)(nil)
+
+// DecodeHCL implements gohcl.Decoder.
+func (args *ThriftBinary) DecodeHCL(body hcl.Body, ctx *hcl.EvalContext) error {
+	*args = ThriftBinary(defaultUDPServerArguments("0.0.0.0:6832"))
+
+	type arguments ThriftBinary
+	return gohcl.DecodeBody(body, ctx, (*arguments)(args))
+}
+
+// ThriftCompact configures the Thrift compact UDP server of the receiver.
+type ThriftCompact UDPServerArguments
+
+var _ gohcl.Decoder = (*ThriftCompact)(nil)
+
+// DecodeHCL implements gohcl.Decoder.
+func (args *ThriftCompact) DecodeHCL(body hcl.Body, ctx *hcl.EvalContext) error {
+	*args = ThriftCompact(defaultUDPServerArguments("0.0.0.0:6831"))
+
+	type arguments ThriftCompact
+	return gohcl.DecodeBody(body, ctx, (*arguments)(args))
+}
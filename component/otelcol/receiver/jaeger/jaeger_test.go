@@ -0,0 +1,53 @@
+package jaeger
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/receiver/jaegerreceiver"
+	"github.com/rfratto/gohcl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArguments(t *testing.T) {
+	var args Arguments
+	diags := decodeArguments(t, `
+		thrift_compact {}
+
+		thrift_http {
+			endpoint = "127.0.0.1:14268"
+		}
+
+		forward_to = []
+	`, &args)
+	require.False(t, diags.HasErrors(), diags.Error())
+
+	cfg, err := args.Convert()
+	require.NoError(t, err)
+	jaegerConfig := cfg.(*jaegerreceiver.Config)
+
+	require.Nil(t, jaegerConfig.GRPC)
+	require.Nil(t, jaegerConfig.ThriftBinary)
+	require.Equal(t, "127.0.0.1:14268", jaegerConfig.ThriftHTTP.Endpoint)
+	require.Equal(t, &jaegerreceiver.ProtocolUDP{
+		Endpoint:        "0.0.0.0:6831",
+		ServerConfigUDP: jaegerreceiver.DefaultServerConfigUDP(),
+	}, jaegerConfig.ThriftCompact)
+	require.NoError(t, jaegerConfig.Validate())
+}
+
+func TestArguments_NoProtocols(t *testing.T) {
+	var args Arguments
+	diags := decodeArguments(t, `forward_to = []`, &args)
+	require.True(t, diags.HasErrors())
+	require.Contains(t, diags.Error(), "at least one of the grpc, thrift_http, thrift_binary, or thrift_compact blocks must be provided")
+}
+
+func decodeArguments(t *testing.T, cfg string, args *Arguments) hcl.Diagnostics {
+	t.Helper()
+
+	file, diags := hclparse.NewParser().ParseHCL([]byte(cfg), "test.flow")
+	require.False(t, diags.HasErrors(), diags.Error())
+	return gohcl.DecodeBody(file.Body, nil, args)
+}
@@ -0,0 +1,109 @@
+// Package otlp implements the otelcol.receiver_otlp component.
+package otlp
+
+import (
+	"fmt"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/otelcol"
+	"github.com/grafana/agent/component/otelcol/receiver"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/rfratto/gohcl"
+	otelconfig "go.opentelemetry.io/collector/config"
+	"go.opentelemetry.io/collector/receiver/otlpreceiver"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name: "otelcol.receiver_otlp",
+		Args: Arguments{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return receiver.New(opts, otlpreceiver.NewFactory(), args.(Arguments))
+		},
+	})
+}
+
+// Arguments holds values which are used to configure the
+// otelcol.receiver_otlp component.
+type Arguments struct {
+	GRPC *GRPCServerArguments `hcl:"grpc,block"`
+	HTTP *HTTPServerArguments `hcl:"http,block"`
+
+	ForwardTo []*otelcol.Consumer `hcl:"forward_to,attr"`
+}
+
+var (
+	_ receiver.Arguments = Arguments{}
+	_ gohcl.Decoder      = (*Arguments)(nil)
+)
+
+// DecodeHCL implements gohcl.Decoder.
+func (args *Arguments) DecodeHCL(body hcl.Body, ctx *hcl.EvalContext) error {
+	*args = Arguments{}
+
+	type arguments Arguments
+	if err := gohcl.DecodeBody(body, ctx, (*arguments)(args)); err != nil {
+		return err
+	}
+	if args.GRPC == nil && args.HTTP == nil {
+		return fmt.Errorf("at least one of the grpc or http blocks must be provided")
+	}
+	return nil
+}
+
+// Convert implements receiver.Arguments.
+func (args Arguments) Convert() (otelconfig.Receiver, error) {
+	return &otlpreceiver.Config{
+		ReceiverSettings: otelconfig.NewReceiverSettings(otelconfig.NewComponentID("otlp")),
+		Protocols: otlpreceiver.Protocols{
+			GRPC: (*otelcol.GRPCServerArguments)(args.GRPC).Convert(),
+			HTTP: (*otelcol.HTTPServerArguments)(args.HTTP).Convert(),
+		},
+	}, nil
+}
+
+// NextConsumers implements receiver.Arguments.
+func (args Arguments) NextConsumers() []*otelcol.Consumer {
+	return args.ForwardTo
+}
+
+// GRPCServerArguments configures the gRPC server of the receiver.
+type GRPCServerArguments otelcol.GRPCServerArguments
+
+// DefaultGRPCServerArguments holds the default settings for
+// GRPCServerArguments.
+var DefaultGRPCServerArguments = GRPCServerArguments{
+	Endpoint:       "0.0.0.0:4317",
+	Transport:      "tcp",
+	ReadBufferSize: 512 * 1024,
+}
+
+var _ gohcl.Decoder = (*GRPCServerArguments)(nil)
+
+// DecodeHCL implements gohcl.Decoder.
+func (args *GRPCServerArguments) DecodeHCL(body hcl.Body, ctx *hcl.EvalContext) error {
+	*args = DefaultGRPCServerArguments
+
+	type arguments GRPCServerArguments
+	return gohcl.DecodeBody(body, ctx, (*arguments)(args))
+}
+
+// HTTPServerArguments configures the HTTP server of the receiver.
+type HTTPServerArguments otelcol.HTTPServerArguments
+
+// DefaultHTTPServerArguments holds the default settings for
+// HTTPServerArguments.
+var DefaultHTTPServerArguments = HTTPServerArguments{
+	Endpoint: "0.0.0.0:4318",
+}
+
+var _ gohcl.Decoder = (*HTTPServerArguments)(nil)
+
+// DecodeHCL implements gohcl.Decoder.
+func (args *HTTPServerArguments) DecodeHCL(body hcl.Body, ctx *hcl.EvalContext) error {
+	*args = DefaultHTTPServerArguments
+
+	type arguments HTTPServerArguments
+	return gohcl.DecodeBody(body, ctx, (*arguments)(args))
+}
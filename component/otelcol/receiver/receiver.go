@@ -0,0 +1,158 @@
+// Package receiver implements a Flow component which wraps an OpenTelemetry
+// Collector traces receiver. Packages for specific receivers build on top of
+// it.
+package receiver
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/otelcol"
+	"github.com/grafana/agent/component/otelcol/internal/collector"
+	otelcomponent "go.opentelemetry.io/collector/component"
+	otelconfig "go.opentelemetry.io/collector/config"
+)
+
+// Arguments is implemented by the Arguments of components which wrap an
+// OpenTelemetry Collector receiver.
+type Arguments interface {
+	component.Arguments
+
+	// Convert converts the Arguments into the configuration of the upstream
+	// receiver.
+	Convert() (otelconfig.Receiver, error)
+
+	// NextConsumers returns the Consumers received traces are forwarded to.
+	NextConsumers() []*otelcol.Consumer
+}
+
+// Receiver is a Flow component which runs an OpenTelemetry Collector traces
+// receiver. The upstream receiver is recreated whenever its configuration
+// changes.
+type Receiver struct {
+	opts    component.Options
+	factory otelcomponent.ReceiverFactory
+	fanout  *otelcol.Fanout
+
+	mut      sync.Mutex
+	cfg      otelconfig.Receiver
+	receiver otelcomponent.TracesReceiver
+
+	healthMut sync.RWMutex
+	health    component.Health
+}
+
+var (
+	_ component.Component       = (*Receiver)(nil)
+	_ component.HealthComponent = (*Receiver)(nil)
+)
+
+// New creates a new Receiver which runs receivers created by f.
+func New(opts component.Options, f otelcomponent.ReceiverFactory, args Arguments) (*Receiver, error) {
+	r := &Receiver{
+		opts:    opts,
+		factory: f,
+		fanout:  otelcol.NewFanout(nil),
+	}
+	if err := r.Update(args); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Run implements component.Component.
+func (r *Receiver) Run(ctx context.Context) error {
+	<-ctx.Done()
+
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	if r.receiver == nil {
+		return nil
+	}
+	err := r.receiver.Shutdown(context.Background())
+	r.receiver = nil
+	return err
+}
+
+// Update implements component.Component.
+func (r *Receiver) Update(args component.Arguments) error {
+	rargs := args.(Arguments)
+
+	cfg, err := rargs.Convert()
+	if err != nil {
+		return err
+	}
+	cfg.SetIDName(r.opts.ID)
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	r.fanout.UpdateChildren(rargs.NextConsumers())
+
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	// Only the set of Consumers changed; the running receiver is already
+	// forwarding to them through the fanout.
+	if r.receiver != nil && reflect.DeepEqual(cfg, r.cfg) {
+		return nil
+	}
+
+	// Receivers listen on fixed addresses, so the old receiver must be stopped
+	// before the new one can start.
+	if r.receiver != nil {
+		if err := r.receiver.Shutdown(context.Background()); err != nil {
+			return fmt.Errorf("failed to stop receiver: %w", err)
+		}
+		r.receiver = nil
+	}
+
+	settings := otelcomponent.ReceiverCreateSettings{
+		TelemetrySettings: collector.TelemetrySettings(r.opts.Logger),
+		BuildInfo:         collector.BuildInfo(),
+	}
+	recv, err := r.factory.CreateTracesReceiver(context.Background(), settings, cfg, r.fanout)
+	if err != nil {
+		return fmt.Errorf("failed to create receiver: %w", err)
+	}
+
+	host := collector.NewHost(log.With(r.opts.Logger, "subcomponent", "host"), r.reportFatal)
+	if err := recv.Start(context.Background(), host); err != nil {
+		return fmt.Errorf("failed to start receiver: %w", err)
+	}
+	r.receiver = recv
+	r.cfg = cfg
+
+	r.setHealth(component.Health{
+		Health:     component.HealthTypeHealthy,
+		Message:    "receiver started",
+		UpdateTime: time.Now(),
+	})
+	return nil
+}
+
+func (r *Receiver) reportFatal(err error) {
+	r.setHealth(component.Health{
+		Health:     component.HealthTypeUnhealthy,
+		Message:    err.Error(),
+		UpdateTime: time.Now(),
+	})
+}
+
+func (r *Receiver) setHealth(h component.Health) {
+	r.healthMut.Lock()
+	defer r.healthMut.Unlock()
+	r.health = h
+}
+
+// CurrentHealth implements component.HealthComponent.
+func (r *Receiver) CurrentHealth() component.Health {
+	r.healthMut.RLock()
+	defer r.healthMut.RUnlock()
+	return r.health
+}
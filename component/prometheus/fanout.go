@@ -0,0 +1,100 @@
+package prometheus
+
+import (
+	"context"
+	"sync"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+)
+
+// Fanout is a storage.Appendable which forwards samples to a set of
+// Receivers. The set of Receivers can be changed at any time; appenders
+// which are already open keep forwarding to the Receivers they were opened
+// with.
+type Fanout struct {
+	mut      sync.RWMutex
+	children []*Receiver
+}
+
+var _ storage.Appendable = (*Fanout)(nil)
+
+// NewFanout creates a Fanout which forwards samples to children.
+func NewFanout(children []*Receiver) *Fanout {
+	return &Fanout{children: children}
+}
+
+// UpdateChildren changes the Receivers samples are forwarded to.
+func (f *Fanout) UpdateChildren(children []*Receiver) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	f.children = children
+}
+
+// Appender implements storage.Appendable.
+func (f *Fanout) Appender(ctx context.Context) storage.Appender {
+	f.mut.RLock()
+	defer f.mut.RUnlock()
+
+	app := &appender{children: make([]storage.Appender, 0, len(f.children))}
+	for _, child := range f.children {
+		if child == nil || child.Appendable == nil {
+			continue
+		}
+		app.children = append(app.children, child.Appender(ctx))
+	}
+	return app
+}
+
+type appender struct {
+	children []storage.Appender
+}
+
+var _ storage.Appender = (*appender)(nil)
+
+// Append implements storage.Appender. Series references are specific to each
+// child, so the returned reference is always 0.
+func (a *appender) Append(_ storage.SeriesRef, l labels.Labels, t int64, v float64) (storage.SeriesRef, error) {
+	var errs error
+	for _, child := range a.children {
+		if _, err := child.Append(0, l, t, v); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+	return 0, errs
+}
+
+// AppendExemplar implements storage.Appender.
+func (a *appender) AppendExemplar(_ storage.SeriesRef, l labels.Labels, e exemplar.Exemplar) (storage.SeriesRef, error) {
+	var errs error
+	for _, child := range a.children {
+		if _, err := child.AppendExemplar(0, l, e); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+	return 0, errs
+}
+
+// Commit implements storage.Appender.
+func (a *appender) Commit() error {
+	var errs error
+	for _, child := range a.children {
+		if err := child.Commit(); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+	return errs
+}
+
+// Rollback implements storage.Appender.
+func (a *appender) Rollback() error {
+	var errs error
+	for _, child := range a.children {
+		if err := child.Rollback(); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,47 @@
+// Package prometheus implements shared types for Prometheus components.
+package prometheus
+
+import (
+	"reflect"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/rfratto/gohcl"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Receiver receives metric samples. Receivers are exported by components
+// which accept samples, such as prometheus.remote_write, and passed to
+// components which produce samples, such as prometheus.scrape.
+//
+// Receivers are passed by reference in HCL expressions, so the receiving
+// component keeps using the same Receiver for as long as it's referenced.
+type Receiver struct {
+	storage.Appendable
+}
+
+// ReceiverType is the HCL type of a *Receiver.
+var ReceiverType cty.Type
+
+func init() {
+	ReceiverType = cty.CapsuleWithOps("PrometheusReceiver", reflect.TypeOf(Receiver{}), &cty.CapsuleOps{
+		ExtensionData: func(key interface{}) interface{} {
+			switch key {
+			case gohcl.CapsuleTokenExtensionKey:
+				// Receivers have no textual representation, so they're encoded
+				// as a placeholder when displaying components.
+				return gohcl.CapsuleTokenExtension(func(v cty.Value) hclwrite.Tokens {
+					return hclwrite.Tokens{
+						{Type: hclsyntax.TokenOParen, Bytes: []byte("(")},
+						{Type: hclsyntax.TokenIdent, Bytes: []byte("receiver")},
+						{Type: hclsyntax.TokenCParen, Bytes: []byte(")")},
+					}
+				})
+			}
+			return nil
+		},
+	})
+
+	gohcl.RegisterCapsuleType(ReceiverType)
+}
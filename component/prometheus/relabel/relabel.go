@@ -0,0 +1,182 @@
+// Package relabel implements the prometheus.relabel component.
+package relabel
+
+import (
+	"context"
+	"sync"
+
+	"github.com/grafana/agent/component"
+	flow_relabel "github.com/grafana/agent/component/common/relabel"
+	"github.com/grafana/agent/component/prometheus"
+	prometheus_client "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/relabel"
+	"github.com/prometheus/prometheus/storage"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:    "prometheus.relabel",
+		Args:    Arguments{},
+		Exports: Exports{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Arguments holds values which are used to configure the prometheus.relabel
+// component.
+type Arguments struct {
+	// ForwardTo receives the relabeled samples.
+	ForwardTo []*prometheus.Receiver `hcl:"forward_to,attr"`
+
+	// The relabelling steps to apply to each sample's label set.
+	RelabelConfigs []*flow_relabel.Config `hcl:"relabel_config,block"`
+}
+
+// Exports holds values which are exported by the prometheus.relabel
+// component.
+type Exports struct {
+	Receiver *prometheus.Receiver `hcl:"receiver,attr"`
+}
+
+// Component implements the prometheus.relabel component.
+type Component struct {
+	opts    component.Options
+	fanout  *prometheus.Fanout
+	metrics *metrics
+
+	mut            sync.RWMutex
+	relabelConfigs []*relabel.Config
+}
+
+var (
+	_ component.Component = (*Component)(nil)
+	_ storage.Appendable  = (*Component)(nil)
+)
+
+// New creates a new prometheus.relabel component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	m, err := newMetrics(o.Registerer)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Component{
+		opts:    o,
+		fanout:  prometheus.NewFanout(nil),
+		metrics: m,
+	}
+
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+
+	// The receiver never changes, so it's only exported once.
+	o.OnStateChange(Exports{Receiver: &prometheus.Receiver{Appendable: c}})
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	c.mut.Lock()
+	c.relabelConfigs = flow_relabel.ComponentToPromRelabelConfigs(newArgs.RelabelConfigs)
+	c.mut.Unlock()
+
+	c.fanout.UpdateChildren(newArgs.ForwardTo)
+	return nil
+}
+
+// Appender implements storage.Appendable. Samples appended to the returned
+// appender are relabeled before being forwarded.
+func (c *Component) Appender(ctx context.Context) storage.Appender {
+	c.mut.RLock()
+	relabelConfigs := c.relabelConfigs
+	c.mut.RUnlock()
+
+	return &appender{
+		next:           c.fanout.Appender(ctx),
+		relabelConfigs: relabelConfigs,
+		metrics:        c.metrics,
+	}
+}
+
+type appender struct {
+	next           storage.Appender
+	relabelConfigs []*relabel.Config
+	metrics        *metrics
+}
+
+var _ storage.Appender = (*appender)(nil)
+
+// Append implements storage.Appender. Samples dropped by relabeling are
+// silently discarded.
+//
+// The series a reference points to depends on the relabel rules, which may
+// change at any time, so references are never passed along and the returned
+// reference is always 0.
+func (a *appender) Append(_ storage.SeriesRef, l labels.Labels, t int64, v float64) (storage.SeriesRef, error) {
+	a.metrics.samplesProcessed.Inc()
+
+	l = relabel.Process(l, a.relabelConfigs...)
+	if l == nil {
+		return 0, nil
+	}
+
+	a.metrics.samplesWritten.Inc()
+	_, err := a.next.Append(0, l, t, v)
+	return 0, err
+}
+
+// AppendExemplar implements storage.Appender.
+func (a *appender) AppendExemplar(_ storage.SeriesRef, l labels.Labels, e exemplar.Exemplar) (storage.SeriesRef, error) {
+	l = relabel.Process(l, a.relabelConfigs...)
+	if l == nil {
+		return 0, nil
+	}
+	_, err := a.next.AppendExemplar(0, l, e)
+	return 0, err
+}
+
+// Commit implements storage.Appender.
+func (a *appender) Commit() error { return a.next.Commit() }
+
+// Rollback implements storage.Appender.
+func (a *appender) Rollback() error { return a.next.Rollback() }
+
+// metrics holds the metrics for a prometheus.relabel component.
+type metrics struct {
+	samplesProcessed prometheus_client.Counter
+	samplesWritten   prometheus_client.Counter
+}
+
+func newMetrics(reg prometheus_client.Registerer) (*metrics, error) {
+	m := &metrics{
+		samplesProcessed: prometheus_client.NewCounter(prometheus_client.CounterOpts{
+			Name: "prometheus_relabel_samples_processed_total",
+			Help: "Total number of samples processed by relabeling.",
+		}),
+		samplesWritten: prometheus_client.NewCounter(prometheus_client.CounterOpts{
+			Name: "prometheus_relabel_samples_written_total",
+			Help: "Total number of samples forwarded after relabeling.",
+		}),
+	}
+
+	for _, c := range []prometheus_client.Collector{m.samplesProcessed, m.samplesWritten} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
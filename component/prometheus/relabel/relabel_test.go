@@ -0,0 +1,104 @@
+package relabel
+
+import (
+	"context"
+	"testing"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/prometheus"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	prometheus_client "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/rfratto/gohcl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComponent(t *testing.T) {
+	args := decodeArguments(t, `
+		forward_to = []
+
+		relabel_config {
+			source_labels = ["__name__"]
+			regex         = "go_.*"
+			action        = "drop"
+		}
+
+		relabel_config {
+			source_labels = ["instance"]
+			regex         = "(.*):\\d+"
+			target_label  = "host"
+		}
+	`)
+
+	var received []labels.Labels
+	args.ForwardTo = []*prometheus.Receiver{{Appendable: &testAppendable{samples: &received}}}
+
+	var exports Exports
+	c, err := New(component.Options{
+		ID:            "prometheus.relabel.test",
+		Logger:        util.TestLogger(t),
+		Registerer:    prometheus_client.NewRegistry(),
+		OnStateChange: func(e component.Exports) { exports = e.(Exports) },
+	}, args)
+	require.NoError(t, err)
+	require.NotNil(t, exports.Receiver)
+
+	app := exports.Receiver.Appender(context.Background())
+	for _, l := range []labels.Labels{
+		labels.FromStrings("__name__", "up", "instance", "localhost:9090"),
+		labels.FromStrings("__name__", "go_goroutines", "instance", "localhost:9090"),
+	} {
+		ref, err := app.Append(1, l, 0, 1)
+		require.NoError(t, err)
+		require.Equal(t, storage.SeriesRef(0), ref)
+	}
+	require.NoError(t, app.Commit())
+
+	require.Equal(t, []labels.Labels{
+		labels.FromStrings("__name__", "up", "host", "localhost", "instance", "localhost:9090"),
+	}, received)
+
+	// Updated rules apply to new appenders.
+	received = nil
+	require.NoError(t, c.Update(Arguments{ForwardTo: args.ForwardTo}))
+
+	app = exports.Receiver.Appender(context.Background())
+	_, err = app.Append(0, labels.FromStrings("__name__", "go_goroutines"), 0, 1)
+	require.NoError(t, err)
+	require.Equal(t, []labels.Labels{labels.FromStrings("__name__", "go_goroutines")}, received)
+}
+
+type testAppendable struct {
+	samples *[]labels.Labels
+}
+
+func (a *testAppendable) Appender(context.Context) storage.Appender { return &testAppender{a} }
+
+type testAppender struct{ parent *testAppendable }
+
+func (a *testAppender) Append(_ storage.SeriesRef, l labels.Labels, _ int64, _ float64) (storage.SeriesRef, error) {
+	*a.parent.samples = append(*a.parent.samples, l)
+	return 0, nil
+}
+
+func (a *testAppender) AppendExemplar(_ storage.SeriesRef, _ labels.Labels, _ exemplar.Exemplar) (storage.SeriesRef, error) {
+	return 0, nil
+}
+
+func (a *testAppender) Commit() error   { return nil }
+func (a *testAppender) Rollback() error { return nil }
+
+func decodeArguments(t *testing.T, cfg string) Arguments {
+	t.Helper()
+
+	file, diags := hclparse.NewParser().ParseHCL([]byte(cfg), "test.flow")
+	require.False(t, diags.HasErrors(), diags.Error())
+
+	var args Arguments
+	diags = gohcl.DecodeBody(file.Body, nil, &args)
+	require.False(t, diags.HasErrors(), diags.Error())
+	return args
+}
@@ -0,0 +1,170 @@
+// Package remotewrite implements the prometheus.remote_write component.
+package remotewrite
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/prometheus"
+	"github.com/grafana/agent/pkg/build"
+	"github.com/grafana/agent/pkg/metrics/wal"
+	"github.com/prometheus/prometheus/model/timestamp"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/storage/remote"
+)
+
+func init() {
+	remote.UserAgent = fmt.Sprintf("GrafanaAgent/%s", build.Version)
+
+	component.Register(component.Registration{
+		Name:    "prometheus.remote_write",
+		Args:    Arguments{},
+		Exports: Exports{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return NewComponent(opts, args.(Arguments))
+		},
+	})
+}
+
+// remoteFlushDeadline is how long to wait for pending samples to be sent to
+// remote_write endpoints when the component exits.
+const remoteFlushDeadline = 1 * time.Minute
+
+// Exports are the set of fields exposed by the prometheus.remote_write
+// component.
+type Exports struct {
+	Receiver *prometheus.Receiver `hcl:"receiver,attr"`
+}
+
+// Component is the prometheus.remote_write component.
+type Component struct {
+	log  log.Logger
+	opts component.Options
+
+	walStore    *wal.Storage
+	remoteStore *remote.Storage
+	storage     storage.Storage
+
+	mut sync.RWMutex
+	cfg Arguments
+}
+
+var _ component.Component = (*Component)(nil)
+
+// NewComponent creates a new prometheus.remote_write component.
+func NewComponent(o component.Options, c Arguments) (*Component, error) {
+	if err := os.MkdirAll(o.DataPath, 0750); err != nil {
+		return nil, fmt.Errorf("creating data directory: %w", err)
+	}
+
+	walStorage, err := wal.NewStorage(o.Logger, o.Registerer, o.DataPath)
+	if err != nil {
+		return nil, err
+	}
+
+	remoteLogger := log.With(o.Logger, "subcomponent", "rw")
+	remoteStore := remote.NewStorage(remoteLogger, o.Registerer, walStorage.StartTime, walStorage.Directory(), remoteFlushDeadline, nil)
+
+	res := &Component{
+		log:         o.Logger,
+		opts:        o,
+		walStore:    walStorage,
+		remoteStore: remoteStore,
+		storage:     storage.NewFanout(o.Logger, walStorage, remoteStore),
+	}
+
+	if err := res.Update(c); err != nil {
+		_ = res.storage.Close()
+		return nil, err
+	}
+
+	// The receiver never changes, so it's only exported once.
+	o.OnStateChange(Exports{Receiver: &prometheus.Receiver{Appendable: res.storage}})
+	return res, nil
+}
+
+// Run implements Component.
+func (c *Component) Run(ctx context.Context) error {
+	defer func() {
+		level.Debug(c.log).Log("msg", "closing storage")
+		err := c.storage.Close()
+		level.Debug(c.log).Log("msg", "storage closed")
+		if err != nil {
+			level.Error(c.log).Log("msg", "error when closing storage", "err", err)
+		}
+	}()
+
+	// Track the last timestamp we truncated for to prevent segments from getting
+	// deleted until at least some new data has been sent.
+	var lastTs = int64(math.MinInt64)
+
+	for {
+		c.mut.RLock()
+		walOptions := c.walOptions()
+		c.mut.RUnlock()
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(walOptions.TruncateFrequency):
+			// We retain series and samples until they're at least
+			// min_keepalive_time old, and delete them when they're older than
+			// max_keepalive_time, even if they haven't been sent yet.
+			ts := c.remoteStore.LowestSentTimestamp() - walOptions.MinKeepaliveTime.Milliseconds()
+			if ts < 0 {
+				ts = 0
+			}
+			if maxTS := timestamp.FromTime(time.Now().Add(-walOptions.MaxKeepaliveTime)); ts < maxTS {
+				ts = maxTS
+			}
+
+			if ts == lastTs {
+				level.Debug(c.log).Log("msg", "not truncating the WAL, remote_write timestamp is unchanged", "ts", ts)
+				continue
+			}
+			lastTs = ts
+
+			level.Debug(c.log).Log("msg", "truncating the WAL", "ts", ts)
+			if err := c.walStore.Truncate(ts); err != nil {
+				// The only issue here is larger disk usage and a greater replay time,
+				// so we'll only log this as a warning.
+				level.Warn(c.log).Log("msg", "could not truncate WAL", "err", err)
+			}
+		}
+	}
+}
+
+// walOptions returns the WAL options to use. c.mut must be held when calling
+// walOptions.
+func (c *Component) walOptions() WALOptions {
+	if c.cfg.WALOptions == nil {
+		return DefaultWALOptions
+	}
+	return *c.cfg.WALOptions
+}
+
+// Update implements Component.
+func (c *Component) Update(newConfig component.Arguments) error {
+	cfg := newConfig.(Arguments)
+
+	convertedConfig, err := convertConfigs(c.opts.ID, cfg)
+	if err != nil {
+		return err
+	}
+	if err := c.remoteStore.ApplyConfig(convertedConfig); err != nil {
+		return err
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.cfg = cfg
+	return nil
+}
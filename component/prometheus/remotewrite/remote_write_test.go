@@ -0,0 +1,157 @@
+package remotewrite
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	prometheus_client "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/prometheus/prometheus/storage/remote"
+	"github.com/rfratto/gohcl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComponent(t *testing.T) {
+	writes := make(chan *prompb.WriteRequest, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, err := remote.DecodeWriteRequest(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writes <- req
+	}))
+	defer srv.Close()
+
+	args := decodeArguments(t, fmt.Sprintf(`
+		external_labels = { "cluster" = "local" }
+
+		endpoint {
+			url = "%s/api/v1/write"
+
+			queue_config {
+				batch_send_deadline = "100ms"
+			}
+		}
+	`, srv.URL))
+
+	var exports Exports
+	c, err := NewComponent(component.Options{
+		ID:            "prometheus.remote_write.test",
+		Logger:        util.TestLogger(t),
+		DataPath:      t.TempDir(),
+		Registerer:    prometheus_client.NewRegistry(),
+		OnStateChange: func(e component.Exports) { exports = e.(Exports) },
+	}, args)
+	require.NoError(t, err)
+	require.NotNil(t, exports.Receiver)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = c.Run(ctx) }()
+
+	// The WAL watcher only sends samples newer than the time it started.
+	now := time.Now().Add(time.Second).UnixMilli()
+	app := exports.Receiver.Appender(ctx)
+	_, err = app.Append(0, labels.FromStrings("__name__", "test_metric"), now, 42)
+	require.NoError(t, err)
+	require.NoError(t, app.Commit())
+
+	select {
+	case req := <-writes:
+		require.Len(t, req.Timeseries, 1)
+		require.Equal(t, []prompb.Label{
+			{Name: "__name__", Value: "test_metric"},
+			{Name: "cluster", Value: "local"},
+		}, req.Timeseries[0].Labels)
+		require.Equal(t, []prompb.Sample{{Value: 42, Timestamp: now}}, req.Timeseries[0].Samples)
+	case <-time.After(30 * time.Second):
+		require.FailNow(t, "timed out waiting for remote write")
+	}
+}
+
+func TestArguments_Invalid(t *testing.T) {
+	tt := []struct {
+		name, cfg, err string
+	}{
+		{
+			name: "min_shards greater than max_shards",
+			cfg: `
+				endpoint {
+					url = "http://localhost:9009/api/prom/push"
+					queue_config {
+						min_shards = 10
+						max_shards = 5
+					}
+				}
+			`,
+			err: "min_shards must not be greater than max_shards",
+		},
+		{
+			name: "invalid keepalive times",
+			cfg: `
+				wal {
+					min_keepalive_time = "1h"
+					max_keepalive_time = "30m"
+				}
+			`,
+			err: "min_keepalive_time must be smaller than max_keepalive_time",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			file, diags := hclparse.NewParser().ParseHCL([]byte(tc.cfg), "test.flow")
+			require.False(t, diags.HasErrors())
+
+			var args Arguments
+			diags = gohcl.DecodeBody(file.Body, nil, &args)
+			require.True(t, diags.HasErrors())
+			require.Contains(t, diags.Error(), tc.err)
+		})
+	}
+}
+
+func TestConvertConfigs_Names(t *testing.T) {
+	args := decodeArguments(t, `
+		endpoint {
+			url = "http://localhost:9009/api/prom/push"
+		}
+		endpoint {
+			name = "named"
+			url  = "http://localhost:9010/api/prom/push"
+		}
+	`)
+
+	cfg, err := convertConfigs("prometheus.remote_write.default", args)
+	require.NoError(t, err)
+	require.Len(t, cfg.RemoteWriteConfigs, 2)
+	require.Regexp(t, `^prometheus\.remote_write\.default-[0-9a-f]{6}$`, cfg.RemoteWriteConfigs[0].Name)
+	require.Equal(t, "named", cfg.RemoteWriteConfigs[1].Name)
+	require.False(t, cfg.RemoteWriteConfigs[0].MetadataConfig.Send)
+	require.Equal(t, DefaultQueueOptions.MaxShards, cfg.RemoteWriteConfigs[0].QueueConfig.MaxShards)
+
+	args.Endpoints[0].Name = "named"
+	_, err = convertConfigs("prometheus.remote_write.default", args)
+	require.EqualError(t, err, `found duplicate endpoint name "named"`)
+}
+
+func decodeArguments(t *testing.T, cfg string) Arguments {
+	t.Helper()
+
+	file, diags := hclparse.NewParser().ParseHCL([]byte(cfg), "test.flow")
+	require.False(t, diags.HasErrors(), diags.Error())
+
+	var args Arguments
+	diags = gohcl.DecodeBody(file.Body, nil, &args)
+	require.False(t, diags.HasErrors(), diags.Error())
+	return args
+}
@@ -0,0 +1,223 @@
+package remotewrite
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	component_config "github.com/grafana/agent/component/common/config"
+	"github.com/hashicorp/hcl/v2"
+	common "github.com/prometheus/common/config"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/rfratto/gohcl"
+)
+
+// Defaults for config blocks.
+var (
+	DefaultEndpointOptions = EndpointOptions{
+		RemoteTimeout: 30 * time.Second,
+		SendExemplars: true,
+	}
+
+	DefaultQueueOptions = QueueOptions{
+		Capacity:          2500,
+		MaxShards:         200,
+		MinShards:         1,
+		MaxSamplesPerSend: 500,
+		BatchSendDeadline: 5 * time.Second,
+		MinBackoff:        30 * time.Millisecond,
+		MaxBackoff:        5 * time.Second,
+	}
+
+	DefaultWALOptions = WALOptions{
+		TruncateFrequency: 60 * time.Minute,
+		MinKeepaliveTime:  5 * time.Minute,
+		MaxKeepaliveTime:  4 * time.Hour,
+	}
+)
+
+// Arguments represents the input state of the prometheus.remote_write
+// component.
+type Arguments struct {
+	ExternalLabels map[string]string  `hcl:"external_labels,optional"`
+	Endpoints      []*EndpointOptions `hcl:"endpoint,block"`
+	WALOptions     *WALOptions        `hcl:"wal,block"`
+}
+
+// EndpointOptions describes an individual location for where metrics in the
+// WAL should be delivered to using the remote_write protocol.
+type EndpointOptions struct {
+	Name             string                             `hcl:"name,optional"`
+	URL              string                             `hcl:"url,attr"`
+	RemoteTimeout    time.Duration                      `hcl:"remote_timeout,optional"`
+	Headers          map[string]string                  `hcl:"headers,optional"`
+	SendExemplars    bool                               `hcl:"send_exemplars,optional"`
+	HTTPClientConfig *component_config.HTTPClientConfig `hcl:"http_client_config,block"`
+	QueueOptions     *QueueOptions                      `hcl:"queue_config,block"`
+}
+
+var _ gohcl.Decoder = (*EndpointOptions)(nil)
+
+// DecodeHCL implements gohcl.Decoder.
+func (r *EndpointOptions) DecodeHCL(body hcl.Body, ctx *hcl.EvalContext) error {
+	*r = DefaultEndpointOptions
+
+	type endpointOptions EndpointOptions
+	if err := gohcl.DecodeBody(body, ctx, (*endpointOptions)(r)); err != nil {
+		return err
+	}
+
+	if _, err := url.Parse(r.URL); err != nil {
+		return fmt.Errorf("invalid url %q: %w", r.URL, err)
+	}
+	if r.RemoteTimeout <= 0 {
+		return fmt.Errorf("remote_timeout must be greater than 0s")
+	}
+	return nil
+}
+
+// QueueOptions handles the low level queue config options for a remote_write
+// endpoint.
+type QueueOptions struct {
+	Capacity          int           `hcl:"capacity,optional"`
+	MaxShards         int           `hcl:"max_shards,optional"`
+	MinShards         int           `hcl:"min_shards,optional"`
+	MaxSamplesPerSend int           `hcl:"max_samples_per_send,optional"`
+	BatchSendDeadline time.Duration `hcl:"batch_send_deadline,optional"`
+	MinBackoff        time.Duration `hcl:"min_backoff,optional"`
+	MaxBackoff        time.Duration `hcl:"max_backoff,optional"`
+	RetryOnHTTP429    bool          `hcl:"retry_on_http_429,optional"`
+}
+
+var _ gohcl.Decoder = (*QueueOptions)(nil)
+
+// DecodeHCL implements gohcl.Decoder.
+func (r *QueueOptions) DecodeHCL(body hcl.Body, ctx *hcl.EvalContext) error {
+	*r = DefaultQueueOptions
+
+	type queueOptions QueueOptions
+	if err := gohcl.DecodeBody(body, ctx, (*queueOptions)(r)); err != nil {
+		return err
+	}
+
+	if r.MinShards > r.MaxShards {
+		return fmt.Errorf("min_shards must not be greater than max_shards")
+	}
+	if r.MinBackoff > r.MaxBackoff {
+		return fmt.Errorf("min_backoff must not be greater than max_backoff")
+	}
+	return nil
+}
+
+func (r *QueueOptions) toPrometheusType() config.QueueConfig {
+	if r == nil {
+		res := DefaultQueueOptions
+		return res.toPrometheusType()
+	}
+
+	return config.QueueConfig{
+		Capacity:          r.Capacity,
+		MaxShards:         r.MaxShards,
+		MinShards:         r.MinShards,
+		MaxSamplesPerSend: r.MaxSamplesPerSend,
+		BatchSendDeadline: model.Duration(r.BatchSendDeadline),
+		MinBackoff:        model.Duration(r.MinBackoff),
+		MaxBackoff:        model.Duration(r.MaxBackoff),
+		RetryOnRateLimit:  r.RetryOnHTTP429,
+	}
+}
+
+// WALOptions configures behavior within the WAL.
+type WALOptions struct {
+	TruncateFrequency time.Duration `hcl:"truncate_frequency,optional"`
+	MinKeepaliveTime  time.Duration `hcl:"min_keepalive_time,optional"`
+	MaxKeepaliveTime  time.Duration `hcl:"max_keepalive_time,optional"`
+}
+
+var _ gohcl.Decoder = (*WALOptions)(nil)
+
+// DecodeHCL implements gohcl.Decoder.
+func (o *WALOptions) DecodeHCL(body hcl.Body, ctx *hcl.EvalContext) error {
+	*o = DefaultWALOptions
+
+	type walOptions WALOptions
+	if err := gohcl.DecodeBody(body, ctx, (*walOptions)(o)); err != nil {
+		return err
+	}
+
+	switch {
+	case o.TruncateFrequency == 0:
+		return fmt.Errorf("truncate_frequency must not be 0")
+	case o.MaxKeepaliveTime <= o.MinKeepaliveTime:
+		return fmt.Errorf("min_keepalive_time must be smaller than max_keepalive_time")
+	}
+	return nil
+}
+
+// convertConfigs converts the component's arguments into a Prometheus config
+// for remote storage. Endpoints without a name are given a name derived from
+// the component ID and a hash of their config.
+func convertConfigs(id string, args Arguments) (*config.Config, error) {
+	var rwConfigs []*config.RemoteWriteConfig
+	names := map[string]struct{}{}
+
+	for _, rw := range args.Endpoints {
+		parsedURL, err := url.Parse(rw.URL)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse remote_write url %q: %w", rw.URL, err)
+		}
+		httpClientConfig, err := rw.HTTPClientConfig.Convert()
+		if err != nil {
+			return nil, err
+		}
+
+		rwc := &config.RemoteWriteConfig{
+			URL:              &common.URL{URL: parsedURL},
+			RemoteTimeout:    model.Duration(rw.RemoteTimeout),
+			Headers:          rw.Headers,
+			Name:             rw.Name,
+			SendExemplars:    rw.SendExemplars,
+			HTTPClientConfig: *httpClientConfig,
+			QueueConfig:      rw.QueueOptions.toPrometheusType(),
+
+			// Metadata is read from a scrape manager, which
+			// prometheus.remote_write doesn't have access to.
+			MetadataConfig: config.MetadataConfig{Send: false},
+		}
+
+		if rwc.Name == "" {
+			hash, err := getHash(rwc)
+			if err != nil {
+				return nil, err
+			}
+			rwc.Name = id + "-" + hash[:6]
+		}
+		if _, exists := names[rwc.Name]; exists {
+			return nil, fmt.Errorf("found duplicate endpoint name %q", rwc.Name)
+		}
+		names[rwc.Name] = struct{}{}
+
+		rwConfigs = append(rwConfigs, rwc)
+	}
+
+	return &config.Config{
+		GlobalConfig: config.GlobalConfig{
+			ExternalLabels: labels.FromMap(args.ExternalLabels),
+		},
+		RemoteWriteConfigs: rwConfigs,
+	}, nil
+}
+
+func getHash(data interface{}) (string, error) {
+	bytes, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	hash := md5.Sum(bytes)
+	return hex.EncodeToString(hash[:]), nil
+}
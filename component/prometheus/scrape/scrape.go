@@ -0,0 +1,376 @@
+// Package scrape implements the prometheus.scrape component.
+package scrape
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/agent/component"
+	component_config "github.com/grafana/agent/component/common/config"
+	"github.com/grafana/agent/component/discovery"
+	"github.com/grafana/agent/component/prometheus"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+	"github.com/prometheus/prometheus/scrape"
+	"github.com/rfratto/ckit"
+	"github.com/rfratto/ckit/peer"
+	"github.com/rfratto/ckit/shard"
+	"github.com/rfratto/gohcl"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name: "prometheus.scrape",
+		Args: Arguments{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Arguments holds values which are used to configure the prometheus.scrape
+// component.
+type Arguments struct {
+	// Targets to scrape, usually exported by a discovery component.
+	Targets []discovery.Target `hcl:"targets,attr"`
+	// ForwardTo receives the scraped samples.
+	ForwardTo []*prometheus.Receiver `hcl:"forward_to,attr"`
+
+	// JobName is the default value of the job label of scraped targets.
+	// Defaults to the component ID.
+	JobName         string              `hcl:"job_name,optional"`
+	HonorLabels     bool                `hcl:"honor_labels,optional"`
+	HonorTimestamps bool                `hcl:"honor_timestamps,optional"`
+	Params          map[string][]string `hcl:"params,optional"`
+	ScrapeInterval  time.Duration       `hcl:"scrape_interval,optional"`
+	ScrapeTimeout   time.Duration       `hcl:"scrape_timeout,optional"`
+	MetricsPath     string              `hcl:"metrics_path,optional"`
+	Scheme          string              `hcl:"scheme,optional"`
+	SampleLimit     uint                `hcl:"sample_limit,optional"`
+	LabelLimit      uint                `hcl:"label_limit,optional"`
+
+	HTTPClientConfig *component_config.HTTPClientConfig `hcl:"http_client_config,block"`
+
+	// Clustering configures sharding targets between the agents of a
+	// cluster.
+	Clustering *Clustering `hcl:"clustering,block"`
+}
+
+// Clustering configures sharding targets between the agents of a cluster.
+// When enabled, each target is only scraped by the agent which owns it, so
+// components with the same targets on every agent scrape each target once.
+type Clustering struct {
+	Enabled bool `hcl:"enabled,attr"`
+}
+
+// DefaultArguments holds default settings for Arguments.
+var DefaultArguments = Arguments{
+	HonorTimestamps: true,
+	ScrapeInterval:  1 * time.Minute,
+	ScrapeTimeout:   10 * time.Second,
+	MetricsPath:     "/metrics",
+	Scheme:          "http",
+}
+
+var _ gohcl.Decoder = (*Arguments)(nil)
+
+// DecodeHCL implements gohcl.Decoder.
+func (args *Arguments) DecodeHCL(body hcl.Body, ctx *hcl.EvalContext) error {
+	*args = DefaultArguments
+
+	type arguments Arguments
+	if err := gohcl.DecodeBody(body, ctx, (*arguments)(args)); err != nil {
+		return err
+	}
+	return args.Validate()
+}
+
+// Validate returns an error if args is invalid.
+func (args *Arguments) Validate() error {
+	if args.ScrapeInterval <= 0 {
+		return fmt.Errorf("scrape_interval must be greater than 0")
+	}
+	if args.ScrapeTimeout <= 0 {
+		return fmt.Errorf("scrape_timeout must be greater than 0")
+	}
+	if args.ScrapeTimeout > args.ScrapeInterval {
+		return fmt.Errorf("scrape_timeout must not be greater than scrape_interval")
+	}
+	if args.Scheme != "http" && args.Scheme != "https" {
+		return fmt.Errorf("scheme must be http or https, got %q", args.Scheme)
+	}
+	return nil
+}
+
+// scrapeConfig converts args into a Prometheus scrape config.
+func (args *Arguments) scrapeConfig(jobName string) (*config.ScrapeConfig, error) {
+	httpClientConfig, err := args.HTTPClientConfig.Convert()
+	if err != nil {
+		return nil, err
+	}
+
+	return &config.ScrapeConfig{
+		JobName:          jobName,
+		HonorLabels:      args.HonorLabels,
+		HonorTimestamps:  args.HonorTimestamps,
+		Params:           url.Values(args.Params),
+		ScrapeInterval:   model.Duration(args.ScrapeInterval),
+		ScrapeTimeout:    model.Duration(args.ScrapeTimeout),
+		MetricsPath:      args.MetricsPath,
+		Scheme:           args.Scheme,
+		SampleLimit:      args.SampleLimit,
+		LabelLimit:       args.LabelLimit,
+		HTTPClientConfig: *httpClientConfig,
+	}, nil
+}
+
+// Component implements the prometheus.scrape component.
+type Component struct {
+	opts component.Options
+
+	fanout *prometheus.Fanout
+	mgr    *scrape.Manager
+
+	mut        sync.Mutex
+	jobName    string
+	targets    *targetgroup.Group
+	clustering bool
+
+	// reloadTargets is written to when the targets of the component change.
+	reloadTargets chan struct{}
+}
+
+var (
+	_ component.Component       = (*Component)(nil)
+	_ component.DebugComponent  = (*Component)(nil)
+	_ component.HealthComponent = (*Component)(nil)
+)
+
+// New creates a new prometheus.scrape component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	fanout := prometheus.NewFanout(nil)
+
+	c := &Component{
+		opts:          o,
+		fanout:        fanout,
+		mgr:           scrape.NewManager(&scrape.Options{}, o.Logger, fanout),
+		reloadTargets: make(chan struct{}, 1),
+	}
+
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	defer c.mgr.Stop()
+
+	targetSets := make(chan map[string][]*targetgroup.Group)
+	go func() {
+		err := c.mgr.Run(targetSets)
+		level.Info(c.opts.Logger).Log("msg", "scrape manager stopped", "err", err)
+	}()
+
+	if c.opts.Cluster != nil {
+		// Targets are redistributed whenever agents start or stop
+		// participating in the cluster. Observers can't be removed, so the
+		// observer unregisters itself on the first change after Run exits.
+		c.opts.Cluster.Observe(ckit.ParticipantObserver(ckit.FuncObserver(func(_ []peer.Peer) (reregister bool) {
+			if ctx.Err() != nil {
+				return false
+			}
+			c.mut.Lock()
+			clustering := c.clustering
+			c.mut.Unlock()
+			if clustering {
+				c.signalReload()
+			}
+			return true
+		})))
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-c.reloadTargets:
+			tsets := map[string][]*targetgroup.Group{}
+
+			c.mut.Lock()
+			tsets[c.jobName] = []*targetgroup.Group{c.ownedTargets()}
+			c.mut.Unlock()
+
+			select {
+			case targetSets <- tsets:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	}
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	jobName := newArgs.JobName
+	if jobName == "" {
+		jobName = c.opts.ID
+	}
+
+	sc, err := newArgs.scrapeConfig(jobName)
+	if err != nil {
+		return err
+	}
+	if err := c.mgr.ApplyConfig(&config.Config{ScrapeConfigs: []*config.ScrapeConfig{sc}}); err != nil {
+		return fmt.Errorf("applying scrape config: %w", err)
+	}
+	c.fanout.UpdateChildren(newArgs.ForwardTo)
+
+	c.mut.Lock()
+	c.jobName = jobName
+	c.targets = targetsToGroup(c.opts.ID, newArgs.Targets)
+	c.clustering = newArgs.Clustering != nil && newArgs.Clustering.Enabled
+	c.mut.Unlock()
+
+	c.signalReload()
+	return nil
+}
+
+func (c *Component) signalReload() {
+	select {
+	case c.reloadTargets <- struct{}{}:
+	default:
+	}
+}
+
+// ownedTargets returns the group of targets which the local agent should
+// scrape. All targets are owned when clustering is disabled or the agent
+// isn't part of a cluster. c.mut must be held when calling ownedTargets.
+//
+// If the owner of a target can't be determined, such as while the local
+// agent is still joining the cluster, all targets are scraped; scraping
+// targets twice is preferable to not scraping them at all.
+func (c *Component) ownedTargets() *targetgroup.Group {
+	if !c.clustering || c.opts.Cluster == nil {
+		return c.targets
+	}
+
+	owned := &targetgroup.Group{
+		Source:  c.targets.Source,
+		Targets: make([]model.LabelSet, 0, len(c.targets.Targets)),
+	}
+	for _, t := range c.targets.Targets {
+		peers, err := c.opts.Cluster.Lookup(shard.Key(t.Fingerprint()), 1, shard.OpReadWrite)
+		if err != nil {
+			level.Warn(c.opts.Logger).Log("msg", "failed to determine owners of targets, scraping all targets", "err", err)
+			return c.targets
+		}
+		if len(peers) > 0 && peers[0].Self {
+			owned.Targets = append(owned.Targets, t)
+		}
+	}
+	return owned
+}
+
+func targetsToGroup(source string, targets []discovery.Target) *targetgroup.Group {
+	group := &targetgroup.Group{
+		Source:  source,
+		Targets: make([]model.LabelSet, 0, len(targets)),
+	}
+	for _, t := range targets {
+		ls := make(model.LabelSet, len(t))
+		for k, v := range t {
+			ls[model.LabelName(k)] = model.LabelValue(v)
+		}
+		group.Targets = append(group.Targets, ls)
+	}
+	return group
+}
+
+// CurrentHealth implements component.HealthComponent. The component is
+// unhealthy if any of its targets failed its last scrape.
+func (c *Component) CurrentHealth() component.Health {
+	var (
+		unhealthy  int
+		lastUpdate time.Time
+		lastErr    error
+	)
+	for _, targets := range c.mgr.TargetsActive() {
+		for _, t := range targets {
+			if t.LastScrape().After(lastUpdate) {
+				lastUpdate = t.LastScrape()
+			}
+			if t.Health() == scrape.HealthBad {
+				unhealthy++
+				lastErr = t.LastError()
+			}
+		}
+	}
+
+	if unhealthy > 0 {
+		return component.Health{
+			Health:     component.HealthTypeUnhealthy,
+			Message:    fmt.Sprintf("%d targets failed to be scraped, last error: %s", unhealthy, lastErr),
+			UpdateTime: lastUpdate,
+		}
+	}
+	return component.Health{
+		Health:     component.HealthTypeHealthy,
+		Message:    "all targets are being scraped",
+		UpdateTime: lastUpdate,
+	}
+}
+
+// DebugInfo implements component.DebugComponent.
+func (c *Component) DebugInfo() interface{} {
+	var res []TargetStatus
+
+	for job, targets := range c.mgr.TargetsActive() {
+		for _, t := range targets {
+			var lastError string
+			if err := t.LastError(); err != nil {
+				lastError = err.Error()
+			}
+
+			res = append(res, TargetStatus{
+				JobName:            job,
+				URL:                t.URL().String(),
+				Health:             string(t.Health()),
+				Labels:             t.Labels().Map(),
+				LastError:          lastError,
+				LastScrape:         t.LastScrape(),
+				LastScrapeDuration: t.LastScrapeDuration(),
+			})
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].URL < res[j].URL })
+	return ScraperStatus{TargetStatus: res}
+}
+
+// ScraperStatus reports the status of the scraper's targets.
+type ScraperStatus struct {
+	TargetStatus []TargetStatus `hcl:"target,block"`
+}
+
+// TargetStatus reports the status of a single target.
+type TargetStatus struct {
+	JobName            string            `hcl:"job,attr"`
+	URL                string            `hcl:"url,attr"`
+	Health             string            `hcl:"health,attr"`
+	Labels             map[string]string `hcl:"labels,attr"`
+	LastError          string            `hcl:"last_error,optional"`
+	LastScrape         time.Time         `hcl:"last_scrape,attr"`
+	LastScrapeDuration time.Duration     `hcl:"last_scrape_duration,optional"`
+}
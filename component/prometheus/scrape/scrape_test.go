@@ -0,0 +1,254 @@
+package scrape
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/component/discovery"
+	"github.com/grafana/agent/component/prometheus"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/rfratto/ckit"
+	"github.com/rfratto/ckit/peer"
+	"github.com/rfratto/ckit/shard"
+	"github.com/rfratto/gohcl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArguments(t *testing.T) {
+	args, err := decodeArguments(`
+		targets    = []
+		forward_to = []
+
+		scrape_interval = "30s"
+		params          = { "module" = ["http_2xx"] }
+	`)
+	require.NoError(t, err)
+
+	sc, err := args.scrapeConfig("job")
+	require.NoError(t, err)
+	require.Equal(t, "job", sc.JobName)
+	require.Equal(t, 30*time.Second, time.Duration(sc.ScrapeInterval))
+	require.Equal(t, 10*time.Second, time.Duration(sc.ScrapeTimeout))
+	require.Equal(t, "/metrics", sc.MetricsPath)
+	require.True(t, sc.HonorTimestamps)
+	require.Equal(t, url.Values{"module": {"http_2xx"}}, sc.Params)
+}
+
+func TestArguments_Invalid(t *testing.T) {
+	_, err := decodeArguments(`
+		targets    = []
+		forward_to = []
+
+		scrape_interval = "5s"
+		scrape_timeout  = "10s"
+	`)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "scrape_timeout must not be greater than scrape_interval")
+}
+
+func TestComponent(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprintln(w, "test_metric 42")
+	}))
+	defer srv.Close()
+
+	srvURL, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	app := newTestAppendable()
+
+	args := DefaultArguments
+	args.ScrapeInterval = 100 * time.Millisecond
+	args.ScrapeTimeout = 50 * time.Millisecond
+	args.Targets = []discovery.Target{{"__address__": srvURL.Host}}
+	args.ForwardTo = []*prometheus.Receiver{{Appendable: app}}
+
+	c, err := New(component.Options{
+		ID:            "prometheus.scrape.test",
+		Logger:        util.TestLogger(t),
+		OnStateChange: func(e component.Exports) {},
+	}, args)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = c.Run(ctx) }()
+
+	// Targets are applied by the scrape manager at most every 5 seconds.
+	select {
+	case s := <-app.samples:
+		require.Equal(t, "test_metric", s.Get(labels.MetricName))
+		require.Equal(t, "prometheus.scrape.test", s.Get("job"))
+		require.Equal(t, srvURL.Host, s.Get("instance"))
+	case <-time.After(15 * time.Second):
+		require.FailNow(t, "timed out waiting for samples")
+	}
+
+	status := c.DebugInfo().(ScraperStatus)
+	require.Len(t, status.TargetStatus, 1)
+	require.Equal(t, srv.URL+"/metrics", status.TargetStatus[0].URL)
+}
+
+func TestComponent_Clustering(t *testing.T) {
+	args, err := decodeArguments(`
+		targets = [
+			{ "__address__" = "a:80" },
+			{ "__address__" = "b:80" },
+			{ "__address__" = "c:80" },
+			{ "__address__" = "d:80" },
+		]
+		forward_to = []
+
+		clustering {
+			enabled = true
+		}
+	`)
+	require.NoError(t, err)
+	require.True(t, args.Clustering.Enabled)
+
+	// Each node of the fake cluster owns every other target.
+	var owned [2][]model.LabelSet
+	for i := range owned {
+		c, err := New(component.Options{
+			ID:            "prometheus.scrape.test",
+			Logger:        util.TestLogger(t),
+			Cluster:       &testNode{self: i},
+			OnStateChange: func(e component.Exports) {},
+		}, args)
+		require.NoError(t, err)
+
+		c.mut.Lock()
+		owned[i] = c.ownedTargets().Targets
+		c.mut.Unlock()
+	}
+	require.Len(t, append(owned[0], owned[1]...), len(args.Targets))
+	for _, t0 := range owned[0] {
+		require.NotContains(t, owned[1], t0)
+	}
+
+	t.Run("all targets are owned when the lookup fails", func(t *testing.T) {
+		c, err := New(component.Options{
+			ID:            "prometheus.scrape.test",
+			Logger:        util.TestLogger(t),
+			Cluster:       &testNode{self: -1},
+			OnStateChange: func(e component.Exports) {},
+		}, args)
+		require.NoError(t, err)
+
+		c.mut.Lock()
+		defer c.mut.Unlock()
+		require.Len(t, c.ownedTargets().Targets, len(args.Targets))
+	})
+
+	t.Run("all targets are owned when clustering is disabled", func(t *testing.T) {
+		args := args
+		args.Clustering = nil
+
+		c, err := New(component.Options{
+			ID:            "prometheus.scrape.test",
+			Logger:        util.TestLogger(t),
+			Cluster:       &testNode{self: 0},
+			OnStateChange: func(e component.Exports) {},
+		}, args)
+		require.NoError(t, err)
+
+		c.mut.Lock()
+		defer c.mut.Unlock()
+		require.Len(t, c.ownedTargets().Targets, len(args.Targets))
+	})
+}
+
+// testNode is a two-node cluster.Node which assigns keys by their parity.
+// Lookups fail if self is negative.
+type testNode struct{ self int }
+
+func (n *testNode) Lookup(key shard.Key, _ int, _ shard.Op) ([]peer.Peer, error) {
+	if n.self < 0 {
+		return nil, fmt.Errorf("no participants")
+	}
+	owner := int(key % 2)
+	return []peer.Peer{{Name: fmt.Sprint(owner), Self: owner == n.self, State: peer.StateParticipant}}, nil
+}
+
+func (n *testNode) Observe(ckit.Observer) {}
+func (n *testNode) Peers() []peer.Peer    { return nil }
+
+type testAppendable struct {
+	samples chan labels.Labels
+}
+
+func newTestAppendable() *testAppendable {
+	return &testAppendable{samples: make(chan labels.Labels, 100)}
+}
+
+func (a *testAppendable) Appender(context.Context) storage.Appender {
+	return &testAppender{parent: a}
+}
+
+type testAppender struct {
+	parent *testAppendable
+
+	mut     sync.Mutex
+	pending []labels.Labels
+}
+
+func (a *testAppender) Append(_ storage.SeriesRef, l labels.Labels, _ int64, _ float64) (storage.SeriesRef, error) {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	a.pending = append(a.pending, l)
+	return 0, nil
+}
+
+func (a *testAppender) AppendExemplar(_ storage.SeriesRef, _ labels.Labels, _ exemplar.Exemplar) (storage.SeriesRef, error) {
+	return 0, nil
+}
+
+func (a *testAppender) Commit() error {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	for _, l := range a.pending {
+		// Only forward metrics from the target, skipping the synthetic
+		// up/scrape_* series.
+		if l.Get(labels.MetricName) != "test_metric" {
+			continue
+		}
+		select {
+		case a.parent.samples <- l:
+		default:
+		}
+	}
+	a.pending = nil
+	return nil
+}
+
+func (a *testAppender) Rollback() error {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	a.pending = nil
+	return nil
+}
+
+func decodeArguments(cfg string) (Arguments, error) {
+	file, diags := hclparse.NewParser().ParseHCL([]byte(cfg), "test.flow")
+	if diags.HasErrors() {
+		return Arguments{}, diags
+	}
+
+	var args Arguments
+	if diags := gohcl.DecodeBody(file.Body, nil, &args); diags.HasErrors() {
+		return Arguments{}, diags
+	}
+	return args, nil
+}
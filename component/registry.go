@@ -6,8 +6,10 @@ import (
 	"strings"
 
 	"github.com/go-kit/log"
+	"github.com/grafana/agent/pkg/cluster"
 	"github.com/grafana/regexp"
 	"github.com/hashicorp/hcl/v2"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 // The parsedName of a component is the parts of its name ("remote.http") split
@@ -42,6 +44,41 @@ type Options struct {
 	// should create the directory if needed.
 	DataPath string
 
+	// Registerer allows components to add their own metrics. The registerer
+	// comes pre-wrapped with a component_id label. Metrics registered through
+	// it are unregistered automatically when the component is removed.
+	Registerer prometheus.Registerer
+
+	// Store is a persistent key-value store for the component. Stores of
+	// different components never share keys.
+	Store Store
+
+	// HTTPPath is the absolute path which the HTTP handler of the component is
+	// served under, ending in a trailing slash. It is only used by components
+	// which implement HTTPComponent.
+	HTTPPath string
+
+	// NewModule creates a new Module owned by the component. Components of the
+	// module are given IDs prefixed by the ID of the owning component and id.
+	// A component which creates more than one Module must give each a unique,
+	// non-empty id. The module's components don't run until the Module is run.
+	//
+	// NewModule is nil if the controller running the component doesn't
+	// support modules.
+	NewModule func(id string, onExportsChange ModuleExportsFunc) (Module, error)
+
+	// DebugStream publishes live debug events of the component. It's never
+	// nil; publishing events is optional.
+	DebugStream DebugStream
+
+	// Cluster is the cluster of agents the component runs in. Components may
+	// use it to share work, such as targets to scrape, with the other agents
+	// of the cluster by only handling keys owned by the local agent.
+	//
+	// Cluster is nil if the agent isn't part of a cluster, in which case
+	// components should handle all keys themselves.
+	Cluster cluster.Node
+
 	// OnStateChange may be invoked at any time by a component whose Export value
 	// changes. The Flow controller then will queue re-processing components
 	// which depend on the changed component.
@@ -97,6 +134,22 @@ type Registration struct {
 	// A component which does not expose exports must leave this set to nil.
 	Exports Exports
 
+	// Validate optionally checks Arguments before they're passed to Build or
+	// Update, such as to enforce invariants between several arguments.
+	// Components are never built or updated with Arguments for which Validate
+	// returns an error.
+	//
+	// Validate may return an ArgumentError or ArgumentErrors to report which
+	// arguments are invalid.
+	Validate func(args Arguments) error
+
+	// Templates optionally lists blocks of the component's Arguments which
+	// hold a template of components rather than values, such as a module body
+	// decoded into an hcl.Body. Expressions in template blocks are evaluated
+	// by the component itself and are never treated as references to other
+	// components.
+	Templates []string
+
 	// Build should construct a new component from an initial Arguments and set
 	// of options.
 	Build func(opts Options, args Arguments) (Component, error)
@@ -0,0 +1,278 @@
+// Package http implements the remote.http component.
+package http
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/agent/component"
+	common_config "github.com/grafana/agent/component/common/config"
+	"github.com/grafana/agent/pkg/build"
+	"github.com/grafana/agent/pkg/flow/hcltypes"
+	"github.com/hashicorp/hcl/v2"
+	prom_config "github.com/prometheus/common/config"
+	"github.com/rfratto/gohcl"
+)
+
+var userAgent = fmt.Sprintf("GrafanaAgent/%s", build.Version)
+
+func init() {
+	component.Register(component.Registration{
+		Name:    "remote.http",
+		Args:    Arguments{},
+		Exports: Exports{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Arguments control the remote.http component.
+type Arguments struct {
+	// URL to poll.
+	URL string `hcl:"url,attr"`
+	// PollFrequency determines how often the URL is requested.
+	PollFrequency time.Duration `hcl:"poll_frequency,optional"`
+	// PollTimeout is the timeout for a single request to the URL.
+	PollTimeout time.Duration `hcl:"poll_timeout,optional"`
+	// IsSecret marks the response body as holding a secret value which should
+	// not be displayed to the user.
+	IsSecret bool `hcl:"is_secret,optional"`
+
+	// Method is the HTTP method to use for requests.
+	Method string `hcl:"method,optional"`
+	// Headers are extra headers to send with each request.
+	Headers map[string]string `hcl:"headers,optional"`
+
+	// Client configures the HTTP client used for requests.
+	Client *common_config.HTTPClientConfig `hcl:"client,block"`
+}
+
+// DefaultArguments holds default settings for Arguments.
+var DefaultArguments = Arguments{
+	PollFrequency: 1 * time.Minute,
+	PollTimeout:   10 * time.Second,
+	Method:        http.MethodGet,
+}
+
+var _ gohcl.Decoder = (*Arguments)(nil)
+
+// DecodeHCL implements gohcl.Decoder.
+func (args *Arguments) DecodeHCL(body hcl.Body, ctx *hcl.EvalContext) error {
+	*args = DefaultArguments
+
+	type arguments Arguments
+	if err := gohcl.DecodeBody(body, ctx, (*arguments)(args)); err != nil {
+		return err
+	}
+	return args.Validate()
+}
+
+// Validate returns an error if args is invalid.
+func (args *Arguments) Validate() error {
+	if args.PollFrequency <= 0 {
+		return fmt.Errorf("poll_frequency must be greater than 0")
+	}
+	if args.PollTimeout <= 0 {
+		return fmt.Errorf("poll_timeout must be greater than 0")
+	}
+	if args.PollTimeout >= args.PollFrequency {
+		return fmt.Errorf("poll_timeout must be less than poll_frequency")
+	}
+
+	switch args.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut:
+	default:
+		return fmt.Errorf("unsupported method %q, expected one of GET, HEAD, POST, or PUT", args.Method)
+	}
+	return nil
+}
+
+// Exports holds settings exported by remote.http.
+type Exports struct {
+	// Content of the most recent successful response body.
+	Content *hcltypes.OptionalSecret `hcl:"content,attr"`
+}
+
+// Component implements the remote.http component.
+type Component struct {
+	opts component.Options
+
+	mut  sync.Mutex
+	args Arguments
+	cli  *http.Client
+
+	healthMut sync.RWMutex
+	health    component.Health
+
+	// updateCh is a buffered channel which is written to when the component
+	// receives new arguments, so Run can pick up a new poll frequency.
+	updateCh chan struct{}
+}
+
+var (
+	_ component.Component       = (*Component)(nil)
+	_ component.HealthComponent = (*Component)(nil)
+)
+
+// New creates a new remote.http component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	c := &Component{
+		opts: o,
+
+		updateCh: make(chan struct{}, 1),
+	}
+
+	// Perform an update which will immediately set our exports to the initial
+	// response body.
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	t := time.NewTicker(c.pollFrequency())
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-c.updateCh:
+			t.Reset(c.pollFrequency())
+		case <-t.C:
+			// We ignore the error here from poll since poll will log errors and
+			// also report the error as the health of the component.
+			_ = c.poll(ctx)
+		}
+	}
+}
+
+func (c *Component) pollFrequency() time.Duration {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.args.PollFrequency
+}
+
+// poll requests the URL and exports the response body.
+func (c *Component) poll(ctx context.Context) error {
+	c.mut.Lock()
+	var (
+		args = c.args
+		cli  = c.cli
+	)
+	c.mut.Unlock()
+
+	content, err := fetch(ctx, cli, args)
+	if err != nil {
+		c.setHealth(component.Health{
+			Health:     component.HealthTypeUnhealthy,
+			Message:    fmt.Sprintf("polling failed: %s", err),
+			UpdateTime: time.Now(),
+		})
+		level.Error(c.opts.Logger).Log("msg", "failed to poll url", "url", args.URL, "err", err)
+		return err
+	}
+
+	c.opts.OnStateChange(Exports{
+		Content: &hcltypes.OptionalSecret{
+			IsSecret: args.IsSecret,
+			Value:    content,
+		},
+	})
+
+	c.setHealth(component.Health{
+		Health:     component.HealthTypeHealthy,
+		Message:    "polled url",
+		UpdateTime: time.Now(),
+	})
+	return nil
+}
+
+// fetch performs a single request against the URL in args and returns the
+// response body.
+func fetch(ctx context.Context, cli *http.Client, args Arguments) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, args.PollTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, args.Method, args.URL, nil)
+	if err != nil {
+		return "", fmt.Errorf("building request: %w", err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	for name, value := range args.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := cli.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		// Drain the body so the connection can be reused.
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return "", fmt.Errorf("unexpected status code %s", resp.Status)
+	}
+
+	bb, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("reading response body: %w", err)
+	}
+	return string(bb), nil
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+	if err := newArgs.Validate(); err != nil {
+		return err
+	}
+
+	clientConfig, err := newArgs.Client.Convert()
+	if err != nil {
+		return err
+	}
+	cli, err := prom_config.NewClientFromConfig(*clientConfig, c.opts.ID)
+	if err != nil {
+		return fmt.Errorf("building http client: %w", err)
+	}
+
+	c.mut.Lock()
+	c.args = newArgs
+	c.cli = cli
+	c.mut.Unlock()
+
+	select {
+	case c.updateCh <- struct{}{}:
+	default:
+	}
+
+	// Force an immediate poll to report any potential errors early.
+	if err := c.poll(context.Background()); err != nil {
+		return fmt.Errorf("failed to poll url: %w", err)
+	}
+	return nil
+}
+
+// CurrentHealth implements component.HealthComponent.
+func (c *Component) CurrentHealth() component.Health {
+	c.healthMut.RLock()
+	defer c.healthMut.RUnlock()
+	return c.health
+}
+
+func (c *Component) setHealth(h component.Health) {
+	c.healthMut.Lock()
+	defer c.healthMut.Unlock()
+	c.health = h
+}
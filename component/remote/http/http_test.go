@@ -0,0 +1,111 @@
+package http_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	http_component "github.com/grafana/agent/component/remote/http"
+	"github.com/grafana/agent/pkg/flow/componenttest"
+	"github.com/grafana/agent/pkg/flow/hcltypes"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/rfratto/gohcl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTP(t *testing.T) {
+	var (
+		mut      sync.Mutex
+		response = "Hello, world!"
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Test") != "value" {
+			http.Error(w, "missing header", http.StatusBadRequest)
+			return
+		}
+
+		mut.Lock()
+		defer mut.Unlock()
+		_, _ = w.Write([]byte(response))
+	}))
+	defer srv.Close()
+
+	tc, err := componenttest.NewControllerFromID(nil, "remote.http")
+	require.NoError(t, err)
+	go func() {
+		err := tc.Run(componenttest.TestContext(t), http_component.Arguments{
+			URL:           srv.URL,
+			Method:        http.MethodGet,
+			Headers:       map[string]string{"X-Test": "value"},
+			PollFrequency: 50 * time.Millisecond,
+			PollTimeout:   25 * time.Millisecond,
+			IsSecret:      true,
+		})
+		require.NoError(t, err)
+	}()
+
+	require.NoError(t, tc.WaitExports(time.Second))
+	require.Equal(t, http_component.Exports{
+		Content: &hcltypes.OptionalSecret{IsSecret: true, Value: "Hello, world!"},
+	}, tc.Exports())
+
+	mut.Lock()
+	response = "New content!"
+	mut.Unlock()
+
+	require.NoError(t, tc.WaitExports(time.Second))
+	require.Equal(t, http_component.Exports{
+		Content: &hcltypes.OptionalSecret{IsSecret: true, Value: "New content!"},
+	}, tc.Exports())
+}
+
+// TestHTTP_FailedInitialPoll ensures that the URL must be reachable on the
+// first load of remote.http.
+func TestHTTP_FailedInitialPoll(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	tc, err := componenttest.NewControllerFromID(nil, "remote.http")
+	require.NoError(t, err)
+
+	err = tc.Run(componenttest.TestContext(t), http_component.Arguments{
+		URL:           srv.URL,
+		Method:        http.MethodGet,
+		PollFrequency: time.Hour,
+		PollTimeout:   time.Second,
+	})
+	require.ErrorContains(t, err, "404")
+}
+
+func TestArguments_DecodeHCL(t *testing.T) {
+	in := `
+		url            = "http://localhost:8080/config"
+		poll_frequency = "30s"
+		headers        = { "X-Scope-OrgID" = "tenant" }
+
+		client {
+			bearer_token = "secret"
+			tls_config {
+				insecure_skip_verify = true
+			}
+		}
+	`
+	file, diags := hclparse.NewParser().ParseHCL([]byte(in), "agent-config.flow")
+	require.False(t, diags.HasErrors())
+
+	var args http_component.Arguments
+	diags = gohcl.DecodeBody(file.Body, nil, &args)
+	require.False(t, diags.HasErrors())
+
+	require.Equal(t, 30*time.Second, args.PollFrequency)
+	require.Equal(t, http_component.DefaultArguments.PollTimeout, args.PollTimeout)
+	require.Equal(t, http.MethodGet, args.Method)
+	require.Equal(t, "tenant", args.Headers["X-Scope-OrgID"])
+	require.Equal(t, hcltypes.Secret("secret"), args.Client.BearerToken)
+	require.True(t, args.Client.TLSConfig.InsecureSkipVerify)
+	require.True(t, args.Client.FollowRedirects)
+}
@@ -0,0 +1,24 @@
+package component
+
+// Store is a persistent key-value store scoped to a single component.
+// Components may use a Store to keep small amounts of state, such as read
+// positions, across restarts of the process.
+//
+// Values written to a Store are kept after the component is removed from the
+// config file, and are visible again if a component with the same ID is
+// created later.
+//
+// All methods of Store are safe for calling concurrently.
+type Store interface {
+	// Get returns the value of key. found is false if key doesn't exist.
+	Get(key string) (value []byte, found bool, err error)
+
+	// Set sets the value of key, replacing any existing value.
+	Set(key string, value []byte) error
+
+	// Delete removes key. Deleting a key which doesn't exist is not an error.
+	Delete(key string) error
+
+	// Keys returns all keys in the Store in lexicographical order.
+	Keys() ([]string, error)
+}
@@ -0,0 +1,236 @@
+// Package template implements the text.template component.
+package template
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	gotemplate "text/template"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/pkg/flow/hcltypes"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/rfratto/gohcl"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:    "text.template",
+		Args:    Arguments{},
+		Exports: Exports{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Arguments holds values which are used to configure the text.template
+// component.
+type Arguments struct {
+	// Template is the Go text/template to render.
+	Template string `hcl:"template,attr"`
+	// Data is passed to the template as its data (referred to with "."). It can
+	// hold any value, including the exports of other components.
+	//
+	// Data is kept as an expression so that secrets it references are never
+	// written back out when displaying the config; it's evaluated in DecodeHCL.
+	// It has no hcl tag because expressions can't be converted to cty values,
+	// which the Flow controller does with the arguments of every component.
+	Data hcl.Expression
+	// IsSecret marks the rendered content as a secret, even if Data doesn't
+	// hold any secrets.
+	IsSecret bool `hcl:"is_secret,optional"`
+
+	// dataValue holds the evaluated value of Data.
+	dataValue cty.Value
+}
+
+var _ gohcl.Decoder = (*Arguments)(nil)
+
+// DecodeHCL implements gohcl.Decoder.
+func (a *Arguments) DecodeHCL(body hcl.Body, ctx *hcl.EvalContext) error {
+	*a = Arguments{}
+
+	var raw struct {
+		Template string         `hcl:"template,attr"`
+		Data     hcl.Expression `hcl:"data,optional"`
+		IsSecret bool           `hcl:"is_secret,optional"`
+	}
+	if diags := gohcl.DecodeBody(body, ctx, &raw); diags.HasErrors() {
+		return diags
+	}
+	a.Template, a.Data, a.IsSecret = raw.Template, raw.Data, raw.IsSecret
+
+	a.dataValue = cty.NilVal
+	if a.Data != nil {
+		val, diags := a.Data.Value(ctx)
+		if diags.HasErrors() {
+			return diags
+		}
+		a.dataValue = val
+	}
+	return nil
+}
+
+// Exports holds values which are exported by the text.template component.
+type Exports struct {
+	// Content is the rendered template. Content is a secret if any secret was
+	// passed in Data or if IsSecret is set.
+	Content *hcltypes.OptionalSecret `hcl:"content,attr"`
+}
+
+// Component implements the text.template component.
+type Component struct {
+	opts component.Options
+
+	mut  sync.Mutex
+	args Arguments
+}
+
+var _ component.Component = (*Component)(nil)
+
+// New creates a new text.template component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	c := &Component{opts: o}
+
+	// Perform an update which will immediately set our exports to the rendered
+	// template.
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Run implements component.Component. Templates are rendered on Update, so
+// Run waits until ctx is canceled.
+func (c *Component) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	tmpl, err := gotemplate.New("template").Funcs(templateFuncs).Option("missingkey=error").Parse(newArgs.Template)
+	if err != nil {
+		return fmt.Errorf("parsing template: %w", err)
+	}
+
+	var dc dataConverter
+	data, err := dc.convert(newArgs.dataValue)
+	if err != nil {
+		return fmt.Errorf("converting data: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return fmt.Errorf("rendering template: %w", err)
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.args = newArgs
+
+	c.opts.OnStateChange(Exports{
+		Content: &hcltypes.OptionalSecret{
+			IsSecret: newArgs.IsSecret || dc.sawSecret,
+			Value:    buf.String(),
+		},
+	})
+	return nil
+}
+
+// templateFuncs are the functions available to templates in addition to the
+// text/template builtins.
+var templateFuncs = gotemplate.FuncMap{
+	"toJson": func(v interface{}) (string, error) {
+		bb, err := json.Marshal(v)
+		return string(bb), err
+	},
+	"b64enc": func(s string) string {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	},
+	"b64dec": func(s string) (string, error) {
+		bb, err := base64.StdEncoding.DecodeString(s)
+		return string(bb), err
+	},
+	"trimSpace": strings.TrimSpace,
+}
+
+// dataConverter converts HCL values into Go values usable by templates. It
+// tracks whether any secrets were converted.
+type dataConverter struct {
+	sawSecret bool
+}
+
+func (dc *dataConverter) convert(v cty.Value) (interface{}, error) {
+	if v == cty.NilVal || v.IsNull() {
+		return nil, nil
+	}
+	if !v.IsKnown() {
+		return nil, fmt.Errorf("value is not known")
+	}
+
+	ty := v.Type()
+	switch {
+	case ty == cty.String:
+		return v.AsString(), nil
+	case ty == cty.Number:
+		bf := v.AsBigFloat()
+		if bf.IsInt() {
+			if i, acc := bf.Int64(); acc == 0 {
+				return i, nil
+			}
+		}
+		f, _ := bf.Float64()
+		return f, nil
+	case ty == cty.Bool:
+		return v.True(), nil
+
+	case ty.IsCapsuleType():
+		switch val := v.EncapsulatedValue().(type) {
+		case *hcltypes.Secret:
+			dc.sawSecret = true
+			return string(*val), nil
+		case *hcltypes.OptionalSecret:
+			dc.sawSecret = dc.sawSecret || val.IsSecret
+			return val.Value, nil
+		default:
+			return nil, fmt.Errorf("unsupported value of type %s", ty.FriendlyName())
+		}
+
+	case ty.IsListType() || ty.IsSetType() || ty.IsTupleType():
+		res := make([]interface{}, 0, v.LengthInt())
+		for it := v.ElementIterator(); it.Next(); {
+			_, ev := it.Element()
+			conv, err := dc.convert(ev)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, conv)
+		}
+		return res, nil
+
+	case ty.IsMapType() || ty.IsObjectType():
+		res := make(map[string]interface{}, v.LengthInt())
+		for it := v.ElementIterator(); it.Next(); {
+			k, ev := it.Element()
+			conv, err := dc.convert(ev)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", k.AsString(), err)
+			}
+			res[k.AsString()] = conv
+		}
+		return res, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported value of type %s", ty.FriendlyName())
+	}
+}
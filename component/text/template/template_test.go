@@ -0,0 +1,132 @@
+package template_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/component/text/template"
+	"github.com/grafana/agent/pkg/flow/componenttest"
+	"github.com/grafana/agent/pkg/flow/hcltypes"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/rfratto/gohcl"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestTemplate(t *testing.T) {
+	tt := []struct {
+		name   string
+		in     string
+		expect hcltypes.OptionalSecret
+	}{
+		{
+			name: "plain values",
+			in: `
+				template = "{{ .name }} has {{ .count }} items: {{ range .items }}{{ . }} {{ end }}"
+				data     = { name = "bucket", count = 3, items = ["a", "b", "c"] }
+			`,
+			expect: hcltypes.OptionalSecret{Value: "bucket has 3 items: a b c "},
+		},
+		{
+			name: "secrets",
+			in: `
+				template = "{{ toJson . }}"
+				data     = { username = "admin", password = password }
+			`,
+			expect: hcltypes.OptionalSecret{IsSecret: true, Value: `{"password":"hunter2","username":"admin"}`},
+		},
+		{
+			name: "is_secret",
+			in: `
+				template  = "{{ b64enc \"admin:admin\" }}"
+				is_secret = true
+			`,
+			expect: hcltypes.OptionalSecret{IsSecret: true, Value: "YWRtaW46YWRtaW4="},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			args := decodeArguments(t, tc.in)
+
+			ctrl, err := componenttest.NewControllerFromID(nil, "text.template")
+			require.NoError(t, err)
+			go func() {
+				err := ctrl.Run(componenttest.TestContext(t), args)
+				require.NoError(t, err)
+			}()
+
+			require.NoError(t, ctrl.WaitExports(time.Second))
+			require.Equal(t, template.Exports{Content: &tc.expect}, ctrl.Exports())
+		})
+	}
+}
+
+func TestTemplate_Invalid(t *testing.T) {
+	tt := []struct {
+		name string
+		in   string
+	}{
+		{
+			name: "bad syntax",
+			in:   `template = "{{ .name "`,
+		},
+		{
+			name: "missing key",
+			in: `
+				template = "{{ .missing }}"
+				data     = { name = "bucket" }
+			`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			args := decodeArguments(t, tc.in)
+
+			ctrl, err := componenttest.NewControllerFromID(nil, "text.template")
+			require.NoError(t, err)
+
+			err = ctrl.Run(componenttest.TestContext(t), args)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestArguments_CtyValue(t *testing.T) {
+	args := decodeArguments(t, `
+		template = "{{ .name }}"
+		data     = { name = "bucket" }
+	`)
+
+	// The Flow controller converts the arguments of every component into cty
+	// values.
+	ty, err := gohcl.ImpliedType(args)
+	require.NoError(t, err)
+	_, err = gohcl.ToCtyValue(args, ty)
+	require.NoError(t, err)
+}
+
+func decodeArguments(t *testing.T, in string) template.Arguments {
+	t.Helper()
+
+	file, diags := hclparse.NewParser().ParseHCL([]byte(in), "agent-config.flow")
+	require.False(t, diags.HasErrors(), diags.Error())
+
+	// Pass a secret the same way the exports of another component would be.
+	password := hcltypes.Secret("hunter2")
+	passwordTy, err := gohcl.ImpliedType(password)
+	require.NoError(t, err)
+	passwordVal, err := gohcl.ToCtyValue(&password, passwordTy)
+	require.NoError(t, err)
+
+	ectx := &hcl.EvalContext{
+		Variables: map[string]cty.Value{"password": passwordVal},
+	}
+
+	var args template.Arguments
+	diags = gohcl.DecodeBody(file.Body, ectx, &args)
+	require.False(t, diags.HasErrors(), diags.Error())
+	return args
+}
@@ -0,0 +1,34 @@
+package component
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ArgumentError reports an invalid argument of a component. When returned by
+// Registration.Validate, the error is reported at the location of the
+// argument in the config file.
+type ArgumentError struct {
+	// Name of the top-level attribute or block which holds the invalid value.
+	Name string
+	// Message describes why the value is invalid.
+	Message string
+}
+
+// Error implements error.
+func (e ArgumentError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Name, e.Message)
+}
+
+// ArgumentErrors is a list of ArgumentError, used by Registration.Validate to
+// report several invalid arguments at once.
+type ArgumentErrors []ArgumentError
+
+// Error implements error.
+func (errs ArgumentErrors) Error() string {
+	msgs := make([]string, 0, len(errs))
+	for _, e := range errs {
+		msgs = append(msgs, e.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
@@ -0,0 +1,174 @@
+package agentctl
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/loki/clients/pkg/logentry/stages"
+	"github.com/grafana/loki/clients/pkg/promtail/api"
+	"github.com/grafana/loki/clients/pkg/promtail/client"
+	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"golang.org/x/time/rate"
+)
+
+// filenameLabel is the label holding the path of the file being read,
+// matching the label set by Promtail when tailing files.
+const filenameLabel = "filename"
+
+// BackfillOptions configures how Backfill sends a log file to Loki.
+type BackfillOptions struct {
+	// Clients to send log entries to.
+	Clients []client.Config
+	// ScrapeConfig holds the pipeline stages to run over each line and the
+	// static labels to attach to entries. Targets are not discovered.
+	ScrapeConfig scrapeconfig.Config
+	// Labels are added to every entry, overriding labels from ScrapeConfig.
+	Labels model.LabelSet
+
+	// Rate is the maximum number of entries sent per second, with bursts of up
+	// to Burst entries.
+	Rate  float64
+	Burst int
+
+	Logger log.Logger
+}
+
+// BackfillStats reports how many lines of a file were processed by Backfill.
+type BackfillStats struct {
+	// Read is the number of lines read from the file.
+	Read int
+	// Dropped is the number of lines dropped by pipeline stages.
+	Dropped int
+	// Skipped is the number of lines which were skipped because no timestamp
+	// was found for them.
+	Skipped int
+	// Sent is the number of entries sent to the clients.
+	Sent int
+}
+
+// Backfill reads lines from r, runs them through the pipeline of the scrape
+// config, and sends them to Loki with the timestamps extracted by the
+// pipeline. Backfill returns once all entries have been sent, or ctx is
+// canceled.
+//
+// Lines left without a timestamp by the pipeline inherit the timestamp of the
+// previous line, so multiline entries stay in order. Lines before the first
+// timestamp are skipped, since sending them with the current time would
+// misplace them.
+func Backfill(ctx context.Context, r io.Reader, filename string, opts BackfillOptions) (BackfillStats, error) {
+	var stats BackfillStats
+
+	if opts.Logger == nil {
+		opts.Logger = log.NewNopLogger()
+	}
+	if len(opts.Clients) == 0 {
+		return stats, fmt.Errorf("no clients configured")
+	}
+	if opts.Rate <= 0 || opts.Burst <= 0 {
+		return stats, fmt.Errorf("rate and burst must be greater than 0")
+	}
+
+	reg := prometheus.NewRegistry()
+	pipeline, err := stages.NewPipeline(opts.Logger, opts.ScrapeConfig.PipelineStages, &opts.ScrapeConfig.JobName, reg)
+	if err != nil {
+		return stats, fmt.Errorf("failed to create pipeline: %w", err)
+	}
+	c, err := client.NewMulti(client.NewMetrics(reg, nil), nil, opts.Logger, opts.Clients...)
+	if err != nil {
+		return stats, fmt.Errorf("failed to create clients: %w", err)
+	}
+	defer c.Stop()
+
+	limiter := rate.NewLimiter(rate.Limit(opts.Rate), opts.Burst)
+
+	// Entries leaving the pipeline are handled by a single goroutine, which
+	// owns lastTimestamp, Skipped, and Sent until the handler is stopped.
+	var (
+		entries       = make(chan api.Entry)
+		done          = make(chan struct{})
+		lastTimestamp time.Time
+	)
+	go func() {
+		defer close(done)
+		for e := range entries {
+			switch {
+			case !e.Timestamp.IsZero():
+				lastTimestamp = e.Timestamp
+			case lastTimestamp.IsZero():
+				stats.Skipped++
+				continue
+			default:
+				e.Timestamp = lastTimestamp
+			}
+
+			if err := limiter.Wait(ctx); err != nil {
+				continue
+			}
+			select {
+			case c.Chan() <- e:
+				stats.Sent++
+			case <-ctx.Done():
+			}
+		}
+	}()
+	handler := pipeline.Wrap(api.NewEntryHandler(entries, func() {
+		close(entries)
+		<-done
+	}))
+
+	labels := backfillLabels(opts, filename)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() && ctx.Err() == nil {
+		stats.Read++
+		handler.Chan() <- api.Entry{
+			Labels: labels.Clone(),
+			Entry:  logproto.Entry{Line: scanner.Text()},
+		}
+	}
+	handler.Stop()
+
+	stats.Dropped = stats.Read - stats.Skipped - stats.Sent
+	if ctx.Err() != nil {
+		// Entries which weren't sent were not dropped by the pipeline.
+		stats.Dropped = 0
+	}
+	if stats.Skipped > 0 {
+		level.Warn(opts.Logger).Log("msg", "skipped lines with no timestamp", "count", stats.Skipped)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return stats, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+	return stats, ctx.Err()
+}
+
+// backfillLabels returns the labels to attach to entries read from filename.
+// Labels with a reserved "__" prefix, such as __path__, are ignored.
+func backfillLabels(opts BackfillOptions, filename string) model.LabelSet {
+	labels := model.LabelSet{}
+	for _, group := range opts.ScrapeConfig.ServiceDiscoveryConfig.StaticConfigs {
+		for _, target := range group.Targets {
+			labels = labels.Merge(target)
+		}
+		labels = labels.Merge(group.Labels)
+	}
+	labels = labels.Merge(opts.Labels)
+	labels[filenameLabel] = model.LabelValue(filename)
+
+	for name := range labels {
+		if strings.HasPrefix(string(name), model.ReservedLabelPrefix) {
+			delete(labels, name)
+		}
+	}
+	return labels
+}
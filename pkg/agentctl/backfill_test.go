@@ -0,0 +1,102 @@
+package agentctl
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/pkg/logs"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/grafana/loki/pkg/loghttp/push"
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestBackfill(t *testing.T) {
+	var (
+		mut     sync.Mutex
+		streams []logproto.Stream
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		req, err := push.ParseRequest(log.NewNopLogger(), "user_id", r, nil)
+		require.NoError(t, err)
+
+		mut.Lock()
+		defer mut.Unlock()
+		streams = append(streams, req.Streams...)
+	}))
+	defer srv.Close()
+
+	cfgText := util.Untab(`
+name: default
+clients:
+- url: ` + srv.URL + `/loki/api/v1/push
+scrape_configs:
+- job_name: backfill
+  static_configs:
+  - labels:
+      job: old-logs
+      __path__: /var/log/*.log
+  pipeline_stages:
+  - regex:
+      expression: '^(?P<ts>\S+) level=(?P<level>\S+)'
+  - timestamp:
+      source: ts
+      format: RFC3339
+  - drop:
+      source: level
+      value: debug
+`)
+	var cfg logs.InstanceConfig
+	require.NoError(t, yaml.UnmarshalStrict([]byte(cfgText), &cfg))
+
+	input := strings.Join([]string{
+		"  preamble without a timestamp",
+		"2022-01-01T00:00:00Z level=info msg=first",
+		"  continuation of first",
+		"2022-01-01T00:00:01Z level=debug msg=dropped",
+		"2022-01-01T00:00:02Z level=info msg=second",
+	}, "\n")
+
+	stats, err := Backfill(context.Background(), strings.NewReader(input), "/var/log/old.log", BackfillOptions{
+		Clients:      cfg.ClientConfigs,
+		ScrapeConfig: cfg.ScrapeConfig[0],
+		Labels:       model.LabelSet{"env": "prod"},
+		Rate:         1000,
+		Burst:        1000,
+		Logger:       util.TestLogger(t),
+	})
+	require.NoError(t, err)
+	require.Equal(t, BackfillStats{Read: 5, Dropped: 1, Skipped: 1, Sent: 3}, stats)
+
+	mut.Lock()
+	defer mut.Unlock()
+
+	var entries []logproto.Entry
+	for _, s := range streams {
+		require.Equal(t, `{env="prod", filename="/var/log/old.log", job="old-logs"}`, s.Labels)
+		entries = append(entries, s.Entries...)
+	}
+
+	first := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.Len(t, entries, 3)
+	require.Equal(t, "2022-01-01T00:00:00Z level=info msg=first", entries[0].Line)
+	require.True(t, first.Equal(entries[0].Timestamp))
+	// The timestamp stage fudges the timestamps of lines it can't parse.
+	require.Equal(t, "  continuation of first", entries[1].Line)
+	require.True(t, first.Add(time.Nanosecond).Equal(entries[1].Timestamp))
+	require.Equal(t, "2022-01-01T00:00:02Z level=info msg=second", entries[2].Line)
+	require.True(t, first.Add(2*time.Second).Equal(entries[2].Timestamp))
+}
+
+func TestBackfill_NoClients(t *testing.T) {
+	_, err := Backfill(context.Background(), strings.NewReader(""), "test.log", BackfillOptions{Rate: 1, Burst: 1})
+	require.EqualError(t, err, "no clients configured")
+}
@@ -0,0 +1,80 @@
+package agentctl
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/grafana/agent/pkg/flow"
+)
+
+// FlowConfigDiff evaluates the Flow config files at oldPath and newPath and
+// writes the components which were added, removed, or whose arguments
+// changed to w. Secrets are masked. Returns true if any component differs.
+func FlowConfigDiff(w io.Writer, oldPath, newPath string) (bool, error) {
+	oldFile, err := readFlowFile(oldPath)
+	if err != nil {
+		return false, err
+	}
+	newFile, err := readFlowFile(newPath)
+	if err != nil {
+		return false, err
+	}
+
+	diffs, err := flow.Diff(oldFile, newFile)
+	if err != nil {
+		return false, err
+	}
+	return len(diffs) > 0, WriteFlowDiff(w, diffs)
+}
+
+func readFlowFile(path string) (*flow.File, error) {
+	bb, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f, diags := flow.ReadFile(path, bb)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	return f, nil
+}
+
+// WriteFlowDiff writes diffs to w. Each component is prefixed by +, -, or ~
+// if it was added, removed, or changed, followed by its differing arguments.
+func WriteFlowDiff(w io.Writer, diffs []flow.ComponentDiff) error {
+	var sb strings.Builder
+	for _, d := range diffs {
+		switch d.Change {
+		case flow.ComponentAdded:
+			fmt.Fprintf(&sb, "+ %s\n", d.ID)
+			for _, arg := range d.Arguments {
+				fmt.Fprintf(&sb, "    %s = %s\n", arg.Path, indentValue(arg.New))
+			}
+		case flow.ComponentRemoved:
+			fmt.Fprintf(&sb, "- %s\n", d.ID)
+			for _, arg := range d.Arguments {
+				fmt.Fprintf(&sb, "    %s = %s\n", arg.Path, indentValue(arg.Old))
+			}
+		default:
+			fmt.Fprintf(&sb, "~ %s\n", d.ID)
+			for _, arg := range d.Arguments {
+				fmt.Fprintf(&sb, "    %s: %s -> %s\n", arg.Path, indentValue(arg.Old), indentValue(arg.New))
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// indentValue indents all but the first line of a rendered value so that it
+// lines up with the arguments it's written under. Unset values are rendered
+// as (unset).
+func indentValue(v string) string {
+	if v == "" {
+		return "(unset)"
+	}
+	return strings.ReplaceAll(v, "\n", "\n    ")
+}
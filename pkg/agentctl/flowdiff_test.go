@@ -0,0 +1,51 @@
+package agentctl
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/grafana/agent/pkg/flow"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFlowDiff(t *testing.T) {
+	diffs := []flow.ComponentDiff{
+		{
+			ID:     "local.file.added",
+			Change: flow.ComponentAdded,
+			Arguments: []flow.ArgumentDiff{
+				{Path: "filename", New: `"/etc/new"`},
+			},
+		},
+		{
+			ID:     "local.file.changed",
+			Change: flow.ComponentChanged,
+			Arguments: []flow.ArgumentDiff{
+				{Path: "is_secret", Old: "true"},
+				{Path: "poll_frequency", Old: `"1m"`, New: `"5m"`},
+			},
+		},
+		{
+			ID:     "remote.http.removed",
+			Change: flow.ComponentRemoved,
+			Arguments: []flow.ArgumentDiff{
+				{Path: "headers", Old: "{\n  token = (secret)\n}"},
+			},
+		},
+	}
+
+	var sb strings.Builder
+	require.NoError(t, WriteFlowDiff(&sb, diffs))
+
+	expect := `+ local.file.added
+    filename = "/etc/new"
+~ local.file.changed
+    is_secret: true -> (unset)
+    poll_frequency: "1m" -> "5m"
+- remote.http.removed
+    headers = {
+      token = (secret)
+    }
+`
+	require.Equal(t, expect, sb.String())
+}
@@ -10,18 +10,22 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 	"unicode"
 
 	"github.com/drone/envsubst/v2"
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/grafana/agent/pkg/config/features"
+	"github.com/grafana/agent/pkg/config/remote"
+	"github.com/grafana/agent/pkg/limits"
 	"github.com/grafana/agent/pkg/logs"
 	"github.com/grafana/agent/pkg/metrics"
 	"github.com/grafana/agent/pkg/server"
 	"github.com/grafana/agent/pkg/traces"
 	"github.com/grafana/agent/pkg/util"
 	"github.com/grafana/dskit/kv/consul"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/config"
 	"github.com/prometheus/common/version"
 	"github.com/stretchr/testify/require"
@@ -55,6 +59,7 @@ var DefaultConfig = Config{
 	Server:                server.DefaultConfig,
 	Metrics:               metrics.DefaultConfig,
 	Integrations:          DefaultVersionedIntegrations,
+	Limits:                limits.DefaultConfig,
 	EnableConfigEndpoints: false,
 	EnableUsageReport:     true,
 }
@@ -66,13 +71,20 @@ type Config struct {
 	Integrations VersionedIntegrations `yaml:"integrations,omitempty"`
 	Traces       traces.Config         `yaml:"traces,omitempty"`
 	Logs         *logs.Config          `yaml:"logs,omitempty"`
+	Limits       limits.Config         `yaml:"limits,omitempty"`
 
 	// Deprecated fields user has used. Generated during UnmarshalYAML.
 	Deprecations []string `yaml:"-"`
 
 	// Remote config options
-	BasicAuthUser     string `yaml:"-"`
-	BasicAuthPassFile string `yaml:"-"`
+	BasicAuthUser     string        `yaml:"-"`
+	BasicAuthPassFile string        `yaml:"-"`
+	PollFrequency     time.Duration `yaml:"-"`
+	PublicKeyFile     string        `yaml:"-"`
+
+	// RemoteURL is the URL the config was loaded from. Empty if the config
+	// was loaded from a file.
+	RemoteURL string `yaml:"-"`
 
 	// Toggle for config endpoint(s)
 	EnableConfigEndpoints bool `yaml:"-"`
@@ -211,6 +223,8 @@ func (c *Config) Validate(fs *flag.FlagSet) error {
 	deps := []features.Dependency{
 		{Flag: "config.url.basic-auth-user", Feature: featRemoteConfigs},
 		{Flag: "config.url.basic-auth-password-file", Feature: featRemoteConfigs},
+		{Flag: "config.url.poll-frequency", Feature: featRemoteConfigs},
+		{Flag: "config.url.public-key-file", Feature: featRemoteConfigs},
 	}
 	return features.Validate(fs, deps)
 }
@@ -224,6 +238,10 @@ func (c *Config) RegisterFlags(f *flag.FlagSet) {
 		"basic auth username for fetching remote config. (requires remote-configs experiment to be enabled")
 	f.StringVar(&c.BasicAuthPassFile, "config.url.basic-auth-password-file", "",
 		"path to file containing basic auth password for fetching remote config. (requires remote-configs experiment to be enabled")
+	f.DurationVar(&c.PollFrequency, "config.url.poll-frequency", 0,
+		"how often to check the remote config for changes, reloading it when it changes. 0 disables polling. (requires remote-configs experiment to be enabled")
+	f.StringVar(&c.PublicKeyFile, "config.url.public-key-file", "",
+		"path to a PEM-encoded ed25519 public key which the remote config must be signed with. (requires remote-configs experiment to be enabled")
 
 	f.BoolVar(&c.EnableConfigEndpoints, "config.enable-read-api", false, "Enables the /-/config and /agent/api/v1/configs/{name} APIs. Be aware that secrets could be exposed by enabling these endpoints!")
 }
@@ -240,21 +258,17 @@ func LoadFile(filename string, expandEnvVars bool, c *Config) error {
 // LoadRemote reads a config from url
 func LoadRemote(url string, expandEnvVars bool, c *Config) error {
 	remoteOpts := &remoteOpts{}
-	if c.BasicAuthUser != "" && c.BasicAuthPassFile != "" {
-		remoteOpts.HTTPClientConfig = &config.HTTPClientConfig{
-			BasicAuth: &config.BasicAuth{
-				Username:     c.BasicAuthUser,
-				PasswordFile: c.BasicAuthPassFile,
-			},
-		}
+	httpClientConfig, err := c.remoteHTTPClientConfig()
+	if err != nil {
+		return err
 	}
+	remoteOpts.HTTPClientConfig = httpClientConfig
 
-	if remoteOpts.HTTPClientConfig != nil {
-		dir, err := os.Getwd()
+	if c.PublicKeyFile != "" {
+		remoteOpts.PublicKey, err = remote.LoadPublicKey(c.PublicKeyFile)
 		if err != nil {
-			return fmt.Errorf("failed to get current working directory: %w", err)
+			return fmt.Errorf("error loading remote config public key: %w", err)
 		}
-		remoteOpts.HTTPClientConfig.SetDirectory(dir)
 	}
 
 	rc, err := newRemoteConfig(url, remoteOpts)
@@ -269,7 +283,62 @@ func LoadRemote(url string, expandEnvVars bool, c *Config) error {
 	if err != nil {
 		return fmt.Errorf("error retrieving remote config: %w", err)
 	}
-	return LoadBytes(bb, expandEnvVars, c)
+	if err := LoadBytes(bb, expandEnvVars, c); err != nil {
+		return err
+	}
+	c.RemoteURL = url
+	return nil
+}
+
+// remoteHTTPClientConfig returns the HTTP client config used to fetch the
+// remote config. Returns nil if no authentication is configured.
+func (c *Config) remoteHTTPClientConfig() (*config.HTTPClientConfig, error) {
+	if c.BasicAuthUser == "" || c.BasicAuthPassFile == "" {
+		return nil, nil
+	}
+
+	httpClientConfig := &config.HTTPClientConfig{
+		BasicAuth: &config.BasicAuth{
+			Username:     c.BasicAuthUser,
+			PasswordFile: c.BasicAuthPassFile,
+		},
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current working directory: %w", err)
+	}
+	httpClientConfig.SetDirectory(dir)
+	return httpClientConfig, nil
+}
+
+// NewRemotePoller returns a poller which checks the remote config c was
+// loaded from for changes. Returns nil if c wasn't loaded from a remote
+// config or polling is disabled.
+func (c *Config) NewRemotePoller(l log.Logger, reg prometheus.Registerer) (*remote.Poller, error) {
+	if c.RemoteURL == "" || c.PollFrequency <= 0 {
+		return nil, nil
+	}
+
+	opts := remote.DefaultOptions
+	opts.URL = c.RemoteURL
+	opts.PollFrequency = c.PollFrequency
+	opts.Logger = log.With(l, "component", "remote_config")
+	opts.Registerer = reg
+
+	httpClientConfig, err := c.remoteHTTPClientConfig()
+	if err != nil {
+		return nil, err
+	} else if httpClientConfig != nil {
+		opts.HTTPClientConfig = *httpClientConfig
+	}
+
+	if c.PublicKeyFile != "" {
+		opts.PublicKey, err = remote.LoadPublicKey(c.PublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading remote config public key: %w", err)
+		}
+	}
+	return remote.New(opts)
 }
 
 // LoadDynamicConfiguration is used to load configuration from a variety of sources using
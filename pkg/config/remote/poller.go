@@ -0,0 +1,272 @@
+// Package remote implements polling an HTTP endpoint for the config file of
+// an agent, allowing fleets of agents to be managed from a central API.
+package remote
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/config"
+)
+
+// DefaultOptions holds default polling settings.
+var DefaultOptions = Options{
+	PollFrequency: time.Minute,
+	Jitter:        0.1,
+}
+
+// Options configures a Poller.
+type Options struct {
+	// URL of the config file. Must be an http or https URL.
+	URL string
+
+	// HTTPClientConfig configures the client used to fetch the config.
+	HTTPClientConfig config.HTTPClientConfig
+
+	// PollFrequency is how often Run checks for a new config. Each wait is
+	// randomly lengthened or shortened by up to Jitter times PollFrequency so
+	// that fleets of agents don't poll in lockstep.
+	PollFrequency time.Duration
+	Jitter        float64
+
+	// PublicKey, if set, is the key which configs must be signed with. Configs
+	// with a missing or invalid signature are rejected.
+	PublicKey ed25519.PublicKey
+
+	// CachePath, if set, is a file where the most recently fetched config is
+	// stored. The cached config is used if the config can't be fetched when
+	// the Poller is first used, allowing agents to start while the API is
+	// unavailable.
+	CachePath string
+
+	Logger     log.Logger
+	Registerer prometheus.Registerer // May be nil
+}
+
+// Poller fetches a config file over HTTP. Poller sends the ETag of the last
+// config it fetched with each request, so unchanged configs aren't
+// downloaded again.
+type Poller struct {
+	opts   Options
+	log    log.Logger
+	client *http.Client
+
+	fetches     *prometheus.CounterVec
+	lastSuccess prometheus.Gauge
+
+	mut    sync.Mutex
+	etag   string
+	config []byte // Most recently fetched config; nil if none was fetched yet
+}
+
+// New creates a new Poller.
+func New(opts Options) (*Poller, error) {
+	if opts.Logger == nil {
+		opts.Logger = log.NewNopLogger()
+	}
+	if opts.PollFrequency <= 0 {
+		return nil, fmt.Errorf("poll frequency must be greater than 0")
+	}
+	if opts.Jitter < 0 || opts.Jitter >= 1 {
+		return nil, fmt.Errorf("jitter must be at least 0 and less than 1")
+	}
+	if err := opts.HTTPClientConfig.Validate(); err != nil {
+		return nil, err
+	}
+	client, err := config.NewClientFromConfig(opts.HTTPClientConfig, "remote-config")
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Poller{
+		opts:   opts,
+		log:    opts.Logger,
+		client: client,
+
+		fetches: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "agent_remote_config_fetches_total",
+			Help: "Total number of attempts to fetch the remote config file, by result (changed, unchanged, or failed)",
+		}, []string{"result"}),
+		lastSuccess: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "agent_remote_config_last_fetch_success_timestamp_seconds",
+			Help: "Timestamp of the last successful fetch of the remote config file",
+		}),
+	}
+	if opts.Registerer != nil {
+		if err := opts.Registerer.Register(p.fetches); err != nil {
+			return nil, err
+		}
+		if err := opts.Registerer.Register(p.lastSuccess); err != nil {
+			opts.Registerer.Unregister(p.fetches)
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Fetch returns the current config. changed reports whether the config
+// differs from the config returned by the previous call to Fetch; the first
+// successful call always reports a change.
+//
+// If the config can't be fetched before any call succeeded, the config
+// stored at CachePath is returned instead, if any.
+func (p *Poller) Fetch(ctx context.Context) (cfg []byte, changed bool, err error) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	cfg, etag, err := p.fetch(ctx)
+	if err != nil {
+		p.fetches.WithLabelValues("failed").Inc()
+		if p.config != nil {
+			return nil, false, err
+		}
+
+		cached, cacheErr := p.readCache()
+		if cacheErr != nil {
+			return nil, false, err
+		}
+		level.Warn(p.log).Log("msg", "failed to fetch remote config, using cached config", "err", err, "cache", p.opts.CachePath)
+		p.config = cached
+		return cached, true, nil
+	}
+	p.lastSuccess.SetToCurrentTime()
+
+	if cfg == nil || bytes.Equal(cfg, p.config) {
+		// The server reported the config didn't change, or it doesn't support
+		// ETags and sent the same config again.
+		p.fetches.WithLabelValues("unchanged").Inc()
+		if etag != "" {
+			p.etag = etag
+		}
+		return p.config, false, nil
+	}
+
+	p.fetches.WithLabelValues("changed").Inc()
+	p.config, p.etag = cfg, etag
+	if err := p.writeCache(cfg); err != nil {
+		level.Warn(p.log).Log("msg", "failed to cache remote config", "err", err, "cache", p.opts.CachePath)
+	}
+	return cfg, true, nil
+}
+
+// fetch requests the config from the server. fetch returns a nil config if
+// the server reported the config is unchanged.
+func (p *Poller) fetch(ctx context.Context) (cfg []byte, etag string, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.opts.URL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if p.etag != "" && p.config != nil {
+		req.Header.Set("If-None-Match", p.etag)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		return nil, resp.Header.Get("ETag"), nil
+	case resp.StatusCode/100 != 2:
+		return nil, "", fmt.Errorf("error fetching config: status code: %d", resp.StatusCode)
+	}
+
+	cfg, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("reading config: %w", err)
+	}
+	if p.opts.PublicKey != nil {
+		if err := VerifySignature(p.opts.PublicKey, cfg, resp.Header.Get(SignatureHeader)); err != nil {
+			return nil, "", err
+		}
+	}
+	return cfg, resp.Header.Get("ETag"), nil
+}
+
+func (p *Poller) readCache() ([]byte, error) {
+	if p.opts.CachePath == "" {
+		return nil, fmt.Errorf("no cache configured")
+	}
+	return os.ReadFile(p.opts.CachePath)
+}
+
+// writeCache atomically replaces the cached config with cfg.
+func (p *Poller) writeCache(cfg []byte) error {
+	if p.opts.CachePath == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(p.opts.CachePath), 0770); err != nil {
+		return err
+	}
+	tmp := p.opts.CachePath + ".tmp"
+	if err := os.WriteFile(tmp, cfg, 0660); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p.opts.CachePath)
+}
+
+// Run polls the config until ctx is canceled, invoking onChange with the new
+// config whenever it changes. Errors fetching the config are logged, and the
+// last config stays in effect. If Fetch wasn't called before Run, the first
+// config fetched by Run is treated as the current config rather than a
+// change.
+func (p *Poller) Run(ctx context.Context, onChange func(cfg []byte)) {
+	p.mut.Lock()
+	hasConfig := p.config != nil
+	p.mut.Unlock()
+
+	if !hasConfig {
+		if _, _, err := p.Fetch(ctx); err != nil {
+			level.Error(p.log).Log("msg", "failed to fetch remote config", "err", err)
+		}
+	}
+
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+	for {
+		t := time.NewTimer(jitter(p.opts.PollFrequency, p.opts.Jitter, rnd.Float64()))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+
+		cfg, changed, err := p.Fetch(ctx)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			level.Error(p.log).Log("msg", "failed to fetch remote config", "err", err)
+		case changed:
+			level.Info(p.log).Log("msg", "remote config changed")
+			onChange(cfg)
+		}
+	}
+}
+
+// jitter scales d by a random factor between 1-j and 1+j, where r is a
+// random number in [0, 1).
+func jitter(d time.Duration, j float64, r float64) time.Duration {
+	return time.Duration(float64(d) * (1 + j*(2*r-1)))
+}
+
+// Unregister unregisters the metrics of p.
+func (p *Poller) Unregister() {
+	if p.opts.Registerer == nil {
+		return
+	}
+	p.opts.Registerer.Unregister(p.fetches)
+	p.opts.Registerer.Unregister(p.lastSuccess)
+}
@@ -0,0 +1,211 @@
+package remote
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testServer serves a config with an ETag derived from its version.
+type testServer struct {
+	mut         sync.Mutex
+	config      string
+	version     int
+	key         ed25519.PrivateKey // Signs configs if set
+	notModified int                // Number of 304 responses
+	fail        bool
+}
+
+func (s *testServer) set(config string) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.config = config
+	s.version++
+}
+
+func (s *testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if s.fail {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	etag := fmt.Sprintf(`"v%d"`, s.version)
+	if r.Header.Get("If-None-Match") == etag {
+		s.notModified++
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	if s.key != nil {
+		w.Header().Set(SignatureHeader, Sign(s.key, []byte(s.config)))
+	}
+	_, _ = w.Write([]byte(s.config))
+}
+
+func newTestPoller(t *testing.T, url string, mod func(*Options)) *Poller {
+	t.Helper()
+
+	opts := DefaultOptions
+	opts.URL = url
+	if mod != nil {
+		mod(&opts)
+	}
+	p, err := New(opts)
+	require.NoError(t, err)
+	return p
+}
+
+func TestPoller_Fetch(t *testing.T) {
+	ts := &testServer{}
+	ts.set("config-a")
+	srv := httptest.NewServer(ts)
+	defer srv.Close()
+
+	p := newTestPoller(t, srv.URL, nil)
+
+	cfg, changed, err := p.Fetch(context.Background())
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, "config-a", string(cfg))
+
+	// The ETag should be sent to avoid downloading the config again.
+	cfg, changed, err = p.Fetch(context.Background())
+	require.NoError(t, err)
+	require.False(t, changed)
+	require.Equal(t, "config-a", string(cfg))
+	require.Equal(t, 1, ts.notModified)
+
+	ts.set("config-b")
+	cfg, changed, err = p.Fetch(context.Background())
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, "config-b", string(cfg))
+}
+
+func TestPoller_Signature(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	_, otherPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	tt := []struct {
+		name   string
+		key    ed25519.PrivateKey
+		expect string
+	}{
+		{name: "valid signature", key: priv},
+		{name: "wrong key", key: otherPriv, expect: "config signature is invalid"},
+		{name: "missing signature", expect: "config signature is invalid: missing X-Agent-Config-Signature header"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := &testServer{key: tc.key}
+			ts.set("config")
+			srv := httptest.NewServer(ts)
+			defer srv.Close()
+
+			p := newTestPoller(t, srv.URL, func(o *Options) { o.PublicKey = pub })
+			cfg, _, err := p.Fetch(context.Background())
+			if tc.expect != "" {
+				require.EqualError(t, err, tc.expect)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, "config", string(cfg))
+		})
+	}
+}
+
+func TestPoller_Cache(t *testing.T) {
+	ts := &testServer{}
+	ts.set("config")
+	srv := httptest.NewServer(ts)
+	defer srv.Close()
+
+	cachePath := filepath.Join(t.TempDir(), "cache", "config")
+
+	p := newTestPoller(t, srv.URL, func(o *Options) { o.CachePath = cachePath })
+	_, _, err := p.Fetch(context.Background())
+	require.NoError(t, err)
+
+	// A new poller should fall back to the cache while the server is
+	// failing.
+	ts.mut.Lock()
+	ts.fail = true
+	ts.mut.Unlock()
+
+	p = newTestPoller(t, srv.URL, func(o *Options) { o.CachePath = cachePath })
+	cfg, changed, err := p.Fetch(context.Background())
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, "config", string(cfg))
+
+	// Once a config was loaded, failures are reported.
+	_, _, err = p.Fetch(context.Background())
+	require.EqualError(t, err, "error fetching config: status code: 500")
+}
+
+func TestPoller_Run(t *testing.T) {
+	ts := &testServer{}
+	ts.set("config-a")
+	srv := httptest.NewServer(ts)
+	defer srv.Close()
+
+	p := newTestPoller(t, srv.URL, func(o *Options) { o.PollFrequency = 10 * time.Millisecond })
+
+	changes := make(chan string, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.Run(ctx, func(cfg []byte) { changes <- string(cfg) })
+
+	// The first config is treated as the current config rather than a
+	// change.
+	require.Eventually(t, func() bool {
+		ts.mut.Lock()
+		defer ts.mut.Unlock()
+		return ts.notModified > 0
+	}, time.Second, 10*time.Millisecond)
+	require.Len(t, changes, 0)
+
+	ts.set("config-b")
+	select {
+	case cfg := <-changes:
+		require.Equal(t, "config-b", cfg)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out waiting for config change")
+	}
+}
+
+func Test_jitter(t *testing.T) {
+	require.Equal(t, 90*time.Second, jitter(100*time.Second, 0.1, 0))
+	require.Equal(t, 100*time.Second, jitter(100*time.Second, 0.1, 0.5))
+	require.Equal(t, 100*time.Second, jitter(100*time.Second, 0, 0.9))
+}
+
+func TestParsePublicKey(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	require.NoError(t, err)
+
+	parsed, err := ParsePublicKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	require.NoError(t, err)
+	require.Equal(t, pub, parsed)
+
+	_, err = ParsePublicKey([]byte("not a key"))
+	require.EqualError(t, err, "expected a PEM-encoded PUBLIC KEY block")
+}
@@ -0,0 +1,67 @@
+package remote
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// SignatureHeader is the response header holding the signature of a config.
+// The signature is the base64-encoded ed25519 signature of the response body.
+const SignatureHeader = "X-Agent-Config-Signature"
+
+// ErrInvalidSignature is returned when a config isn't signed by the expected
+// key.
+var ErrInvalidSignature = errors.New("config signature is invalid")
+
+// LoadPublicKey reads a PEM-encoded ed25519 public key from filename, such as
+// one created by `openssl pkey -pubout`.
+func LoadPublicKey(filename string) (ed25519.PublicKey, error) {
+	bb, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePublicKey(bb)
+}
+
+// ParsePublicKey parses a PEM-encoded ed25519 public key.
+func ParsePublicKey(bb []byte) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode(bb)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("expected a PEM-encoded PUBLIC KEY block")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("expected an ed25519 public key, got %T", key)
+	}
+	return pub, nil
+}
+
+// Sign returns the value of SignatureHeader for config.
+func Sign(key ed25519.PrivateKey, config []byte) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(key, config))
+}
+
+// VerifySignature checks that signature, the value of SignatureHeader, is a
+// valid signature of config by key.
+func VerifySignature(key ed25519.PublicKey, config []byte, signature string) error {
+	if signature == "" {
+		return fmt.Errorf("%w: missing %s header", ErrInvalidSignature, SignatureHeader)
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidSignature, err)
+	}
+	if !ed25519.Verify(key, config, sig) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -1,11 +1,13 @@
 package config
 
 import (
+	"crypto/ed25519"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 
+	"github.com/grafana/agent/pkg/config/remote"
 	"github.com/prometheus/common/config"
 )
 
@@ -19,6 +21,7 @@ const (
 type remoteOpts struct {
 	url              *url.URL
 	HTTPClientConfig *config.HTTPClientConfig
+	PublicKey        ed25519.PublicKey // Key the config must be signed with; may be nil
 }
 
 // remoteProvider interface should be implemented by config providers
@@ -59,6 +62,7 @@ func newRemoteConfig(rawURL string, opts *remoteOpts) (remoteProvider, error) {
 type httpProvider struct {
 	myURL      *url.URL
 	httpClient *http.Client
+	publicKey  ed25519.PublicKey
 }
 
 // newHTTPProvider constructs an new httpProvider
@@ -78,6 +82,7 @@ func newHTTPProvider(opts *remoteOpts) (*httpProvider, error) {
 	return &httpProvider{
 		myURL:      opts.url,
 		httpClient: httpClient,
+		publicKey:  opts.PublicKey,
 	}, nil
 }
 
@@ -96,5 +101,10 @@ func (p httpProvider) retrieve() ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	if p.publicKey != nil {
+		if err := remote.VerifySignature(p.publicKey, bb, response.Header.Get(remote.SignatureHeader)); err != nil {
+			return nil, err
+		}
+	}
 	return bb, nil
 }
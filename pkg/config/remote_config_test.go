@@ -1,12 +1,15 @@
 package config
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"testing"
 
+	"github.com/grafana/agent/pkg/config/remote"
 	"github.com/prometheus/common/config"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -127,3 +130,44 @@ metrics:
 		})
 	}
 }
+
+func TestRemoteConfigHTTP_Signature(t *testing.T) {
+	testCfg := []byte("metrics: {}\n")
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	_, otherPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		key     ed25519.PrivateKey
+		wantErr bool
+	}{
+		{name: "signed config", key: priv},
+		{name: "config signed with another key", key: otherPriv, wantErr: true},
+		{name: "unsigned config", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.key != nil {
+					w.Header().Set(remote.SignatureHeader, remote.Sign(tt.key, testCfg))
+				}
+				_, _ = w.Write(testCfg)
+			}))
+			defer svr.Close()
+
+			rc, err := newRemoteConfig(svr.URL, &remoteOpts{PublicKey: pub})
+			require.NoError(t, err)
+			bb, err := rc.retrieve()
+			if tt.wantErr {
+				assert.ErrorIs(t, err, remote.ErrInvalidSignature)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, string(testCfg), string(bb))
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package flow
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/rfratto/gohcl"
+)
+
+// assertBlockSchema is the schema of assert blocks. An assert block checks an
+// invariant of the config file when it is loaded:
+//
+//	assert "has_targets" {
+//	  condition = length(discovery.kubernetes.pods.targets) > 0
+//	  message   = "no pods were discovered"
+//	}
+var assertBlockSchema = hcl.BlockHeaderSchema{
+	Type:       "assert",
+	LabelNames: []string{"name"},
+}
+
+type assertBlock struct {
+	Condition bool   `hcl:"condition,attr"`
+	Message   string `hcl:"message,optional"`
+}
+
+// evaluateAsserts evaluates assert blocks against ectx, returning an error
+// diagnostic for each assert which failed or couldn't be evaluated.
+func evaluateAsserts(ectx *hcl.EvalContext, blocks hcl.Blocks) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+
+	for _, block := range blocks {
+		var assert assertBlock
+		if decodeDiags := gohcl.DecodeBody(block.Body, ectx, &assert); decodeDiags.HasErrors() {
+			diags = diags.Extend(decodeDiags)
+			continue
+		}
+		if assert.Condition {
+			continue
+		}
+
+		detail := assert.Message
+		if detail == "" {
+			detail = "The condition evaluated to false."
+		}
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  fmt.Sprintf("Assert %s failed", block.Labels[0]),
+			Detail:   detail,
+			Subject:  block.DefRange.Ptr(),
+		})
+	}
+
+	return diags
+}
@@ -5,18 +5,24 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"reflect"
 	"sync"
 	"time"
 
 	"github.com/go-kit/log"
 	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/pkg/flow/internal/controller"
+	"github.com/grafana/agent/pkg/flow/internal/kvstore"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 // A Controller is a testing controller which controls a single component.
 type Controller struct {
-	reg component.Registration
-	log log.Logger
+	reg      component.Registration
+	log      log.Logger
+	registry *prometheus.Registry
+	debug    *controller.DebugStream
 
 	onRun   sync.Once
 	running chan struct{}
@@ -42,8 +48,10 @@ func NewControllerFromID(l log.Logger, componentName string) (*Controller, error
 	}
 
 	return &Controller{
-		reg: reg,
-		log: l,
+		reg:      reg,
+		log:      l,
+		registry: prometheus.NewRegistry(),
+		debug:    controller.NewDebugStream(),
 
 		running:   make(chan struct{}, 1),
 		exportsCh: make(chan struct{}, 1),
@@ -95,6 +103,19 @@ func (c *Controller) Exports() component.Exports {
 	return c.exports
 }
 
+// Registry returns the registry which metrics from the component are
+// registered to.
+func (c *Controller) Registry() *prometheus.Registry {
+	return c.registry
+}
+
+// SubscribeDebugStream returns a channel which receives debug events
+// published by the component after SubscribeDebugStream is called. The
+// channel is closed once ctx is canceled.
+func (c *Controller) SubscribeDebugStream(ctx context.Context) <-chan component.DebugEvent {
+	return c.debug.Subscribe(ctx)
+}
+
 // Run starts the controller, building and running the component. Run blocks
 // until ctx is canceled, the component exits, or if there was an error.
 //
@@ -108,7 +129,12 @@ func (c *Controller) Run(ctx context.Context, args component.Arguments) error {
 		_ = os.RemoveAll(dataPath)
 	}()
 
-	run, err := c.buildComponent(dataPath, args)
+	kv := kvstore.New(filepath.Join(dataPath, "component-kv.db"))
+	defer func() {
+		_ = kv.Close()
+	}()
+
+	run, err := c.buildComponent(dataPath, kv, args)
 	if err != nil {
 		return err
 	}
@@ -117,17 +143,28 @@ func (c *Controller) Run(ctx context.Context, args component.Arguments) error {
 	return run.Run(ctx)
 }
 
-func (c *Controller) buildComponent(dataPath string, args component.Arguments) (component.Component, error) {
+func (c *Controller) buildComponent(dataPath string, kv *kvstore.DB, args component.Arguments) (component.Component, error) {
 	c.innerMut.Lock()
 	defer c.innerMut.Unlock()
 
+	id := c.reg.Name + ".test"
 	opts := component.Options{
-		ID:            c.reg.Name + ".test",
+		ID:            id,
 		Logger:        c.log,
 		DataPath:      dataPath,
+		Registerer:    c.registry,
+		Store:         kv.Store(id),
+		HTTPPath:      "/component/" + id + "/",
+		DebugStream:   c.debug,
 		OnStateChange: c.onStateChange,
 	}
 
+	if c.reg.Validate != nil {
+		if err := c.reg.Validate(args); err != nil {
+			return nil, err
+		}
+	}
+
 	inner, err := c.reg.Build(opts, args)
 	if err != nil {
 		return nil, err
@@ -145,5 +182,10 @@ func (c *Controller) Update(args component.Arguments) error {
 	if c.inner == nil {
 		return fmt.Errorf("component is not running")
 	}
+	if c.reg.Validate != nil {
+		if err := c.reg.Validate(args); err != nil {
+			return err
+		}
+	}
 	return c.inner.Update(args)
 }
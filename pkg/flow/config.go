@@ -1,6 +1,9 @@
 package flow
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/grafana/agent/component"
 	"github.com/grafana/agent/pkg/flow/logging"
 	"github.com/hashicorp/hcl/v2"
@@ -18,6 +21,15 @@ type File struct {
 	// Components holds the list of raw HCL blocks describing components. The
 	// Flow controller can interpret this block.
 	Components hcl.Blocks
+
+	// Asserts holds the list of raw HCL assert blocks, which are evaluated by
+	// the Flow controller after components are loaded.
+	Asserts hcl.Blocks
+
+	// Arguments and Exports hold the list of raw HCL argument and export
+	// blocks, which declare the arguments and exports of a module.
+	Arguments hcl.Blocks
+	Exports   hcl.Blocks
 }
 
 // ReadFile parses the HCL file specified by bb into a File. name should be the
@@ -28,20 +40,47 @@ func ReadFile(name string, bb []byte) (*File, hcl.Diagnostics) {
 		return nil, diags
 	}
 
+	f, readDiags := readHCLFile(name, file)
+	return f, diags.Extend(readDiags)
+}
+
+// readHCLFile reads the blocks of an already parsed HCL file into a File.
+// file.Bytes may be empty if the body of file was taken from another file.
+func readHCLFile(name string, file *hcl.File) (*File, hcl.Diagnostics) {
 	var root rootBlock
-	decodeDiags := gohcl.DecodeBody(file.Body, nil, &root)
-	diags = diags.Extend(decodeDiags)
+	diags := gohcl.DecodeBody(file.Body, nil, &root)
 	if diags.HasErrors() {
 		return nil, diags
 	}
 
 	blockSchema := component.RegistrySchema()
+	blockSchema.Blocks = append(blockSchema.Blocks, assertBlockSchema, argumentBlockSchema, exportBlockSchema)
 	content, remainDiags := root.Remain.Content(blockSchema)
 	diags = diags.Extend(remainDiags)
 	if diags.HasErrors() {
 		return nil, diags
 	}
 
+	var components, asserts, arguments, exports hcl.Blocks
+	for _, block := range content.Blocks {
+		switch block.Type {
+		case assertBlockSchema.Type:
+			asserts = append(asserts, block)
+		case argumentBlockSchema.Type:
+			arguments = append(arguments, block)
+		case exportBlockSchema.Type:
+			exports = append(exports, block)
+		default:
+			components = append(components, block)
+		}
+	}
+	diags = diags.Extend(validateBlockNames(asserts))
+	diags = diags.Extend(validateBlockNames(arguments))
+	diags = diags.Extend(validateBlockNames(exports))
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
 	if root.Logger == nil {
 		defaults := logging.DefaultOptions
 		root.Logger = &defaults
@@ -51,10 +90,37 @@ func ReadFile(name string, bb []byte) (*File, hcl.Diagnostics) {
 		Name:       name,
 		HCL:        file,
 		Logging:    *root.Logger,
-		Components: content.Blocks,
+		Components: components,
+		Asserts:    asserts,
+		Arguments:  arguments,
+		Exports:    exports,
 	}, nil
 }
 
+// validateBlockNames ensures that the names of blocks, which must all have
+// the same type and a single label, are unique.
+func validateBlockNames(blocks hcl.Blocks) hcl.Diagnostics {
+	var (
+		diags hcl.Diagnostics
+		names = make(map[string]*hcl.Block, len(blocks))
+	)
+	for _, block := range blocks {
+		name := block.Labels[0]
+		if orig, redefined := names[name]; redefined {
+			kind := block.Type
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  fmt.Sprintf("%s %s redeclared", strings.ToUpper(kind[:1])+kind[1:], name),
+				Detail:   fmt.Sprintf("%s: %s %s originally declared here", orig.DefRange.String(), kind, name),
+				Subject:  block.DefRange.Ptr(),
+			})
+			continue
+		}
+		names[name] = block
+	}
+	return diags
+}
+
 type rootBlock struct {
 	Logger *logging.Options `hcl:"logging,block"`
 
@@ -70,3 +70,20 @@ func getBlockID(b *hcl.Block) string {
 	parts = append(parts, b.Labels...)
 	return strings.Join(parts, ".")
 }
+
+func TestReadFile_DuplicateAsserts(t *testing.T) {
+	content := `
+		assert "check" {
+			condition = true
+		}
+
+		assert "check" {
+			condition = true
+		}
+	`
+
+	f, diags := flow.ReadFile(t.Name(), []byte(content))
+	require.Nil(t, f)
+	require.True(t, diags.HasErrors())
+	require.Equal(t, "Assert check redeclared", diags[0].Summary)
+}
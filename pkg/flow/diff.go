@@ -0,0 +1,145 @@
+package flow
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/pkg/flow/internal/controller"
+	"github.com/hashicorp/hcl/v2"
+)
+
+// ChangeType describes how a component changed between two config files.
+type ChangeType int
+
+const (
+	ComponentAdded   ChangeType = iota // Component only exists in the new file.
+	ComponentRemoved                   // Component only exists in the old file.
+	ComponentChanged                   // Arguments of the component changed.
+)
+
+// String returns a human-readable name for the change.
+func (ct ChangeType) String() string {
+	switch ct {
+	case ComponentAdded:
+		return "added"
+	case ComponentRemoved:
+		return "removed"
+	case ComponentChanged:
+		return "changed"
+	default:
+		return fmt.Sprintf("ChangeType(%d)", int(ct))
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (ct ChangeType) MarshalText() ([]byte, error) {
+	return []byte(ct.String()), nil
+}
+
+// ComponentDiff describes how a component differs between two config files.
+type ComponentDiff struct {
+	ID     string     `json:"id"`
+	Change ChangeType `json:"change"`
+
+	// Arguments holds the evaluated arguments which differ, sorted by path.
+	// Added and removed components report each of their arguments.
+	Arguments []ArgumentDiff `json:"arguments"`
+}
+
+// ArgumentDiff describes an argument of a component which differs between
+// two config files.
+type ArgumentDiff struct {
+	// Path to the argument, such as `targets[0].labels`.
+	Path string `json:"path"`
+	// Old and New hold the evaluated values rendered as HCL, with secrets
+	// redacted. They're empty if the argument isn't set in that file.
+	Old string `json:"old,omitempty"`
+	New string `json:"new,omitempty"`
+}
+
+// Diff evaluates the components of oldFile and newFile and returns the
+// components which were added, removed, or whose arguments changed, sorted
+// by component ID.
+//
+// Each file is loaded into its own temporary controller, so expressions
+// referencing the exports of other components are compared by the values
+// they evaluate to rather than by their text. An error is returned if either
+// file fails to load.
+func Diff(oldFile, newFile *File) ([]ComponentDiff, error) {
+	oldArgs, err := evaluateArguments(oldFile)
+	if err != nil {
+		return nil, fmt.Errorf("evaluating %s: %w", oldFile.Name, err)
+	}
+	newArgs, err := evaluateArguments(newFile)
+	if err != nil {
+		return nil, fmt.Errorf("evaluating %s: %w", newFile.Name, err)
+	}
+	return diffArgumentSets(oldArgs, newArgs), nil
+}
+
+// diffArgumentSets compares the arguments of two sets of components by
+// component ID.
+func diffArgumentSets(oldArgs, newArgs map[string]component.Arguments) []ComponentDiff {
+	var diffs []ComponentDiff
+	for id, args := range oldArgs {
+		updated, exists := newArgs[id]
+		if !exists {
+			diffs = append(diffs, ComponentDiff{ID: id, Change: ComponentRemoved, Arguments: diffArguments(args, nil)})
+		} else if argDiffs := diffArguments(args, updated); len(argDiffs) > 0 {
+			diffs = append(diffs, ComponentDiff{ID: id, Change: ComponentChanged, Arguments: argDiffs})
+		}
+	}
+	for id, args := range newArgs {
+		if _, exists := oldArgs[id]; !exists {
+			diffs = append(diffs, ComponentDiff{ID: id, Change: ComponentAdded, Arguments: diffArguments(nil, args)})
+		}
+	}
+
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].ID < diffs[j].ID })
+	return diffs
+}
+
+// evaluateArguments loads f into a temporary controller and returns the
+// evaluated arguments of its components by component ID. If loading f only
+// reported warnings, the arguments are returned along with the warnings as
+// the error.
+func evaluateArguments(f *File) (map[string]component.Arguments, error) {
+	dataPath, err := os.MkdirTemp("", "agent-flow-diff")
+	if err != nil {
+		return nil, fmt.Errorf("creating temporary data path: %w", err)
+	}
+	defer os.RemoveAll(dataPath)
+
+	c := New(Options{DataPath: dataPath})
+	defer c.Close()
+
+	err = c.LoadFile(f)
+	var diags hcl.Diagnostics
+	if err != nil && (!errors.As(err, &diags) || diags.HasErrors()) {
+		return nil, err
+	}
+	return componentArguments(c), err
+}
+
+// componentArguments returns the current arguments of the components loaded
+// by c by component ID.
+func componentArguments(c *Flow) map[string]component.Arguments {
+	args := make(map[string]component.Arguments)
+	for _, cn := range c.loader.Components() {
+		args[cn.NodeID()] = cn.Arguments()
+	}
+	return args
+}
+
+func diffArguments(oldArgs, newArgs component.Arguments) []ArgumentDiff {
+	valueDiffs := controller.DiffValues(oldArgs, newArgs)
+
+	res := make([]ArgumentDiff, 0, len(valueDiffs))
+	for _, d := range valueDiffs {
+		res = append(res, ArgumentDiff{Path: d.Path, Old: d.Old, New: d.New})
+	}
+	return res
+}
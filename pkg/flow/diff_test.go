@@ -0,0 +1,87 @@
+package flow
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	oldFile, diags := ReadFile("old", []byte(`
+		testcomponents "passthrough" "static" {
+			input = "hello"
+		}
+
+		testcomponents "passthrough" "forwarded" {
+			input = testcomponents.passthrough.static.output
+		}
+
+		testcomponents "passthrough" "removed" {
+			input = "bye"
+		}
+
+		testcomponents "passthrough" "unchanged" {
+			input = "same"
+		}
+	`))
+	require.False(t, diags.HasErrors(), diags.Error())
+
+	newFile, diags := ReadFile("new", []byte(`
+		testcomponents "passthrough" "static" {
+			input = "hello, world!"
+		}
+
+		testcomponents "passthrough" "forwarded" {
+			input = testcomponents.passthrough.static.output
+		}
+
+		testcomponents "passthrough" "unchanged" {
+			input = "same"
+		}
+
+		testcomponents "passthrough" "added" {
+			input = "hi"
+		}
+	`))
+	require.False(t, diags.HasErrors(), diags.Error())
+
+	diffs, err := Diff(oldFile, newFile)
+	require.NoError(t, err)
+
+	// The forwarded component changed because the value it references changed,
+	// even though its expression is the same.
+	require.Equal(t, []ComponentDiff{
+		{
+			ID:        "testcomponents.passthrough.added",
+			Change:    ComponentAdded,
+			Arguments: []ArgumentDiff{{Path: "input", New: `"hi"`}},
+		},
+		{
+			ID:        "testcomponents.passthrough.forwarded",
+			Change:    ComponentChanged,
+			Arguments: []ArgumentDiff{{Path: "input", Old: `"hello"`, New: `"hello, world!"`}},
+		},
+		{
+			ID:        "testcomponents.passthrough.removed",
+			Change:    ComponentRemoved,
+			Arguments: []ArgumentDiff{{Path: "input", Old: `"bye"`}},
+		},
+		{
+			ID:        "testcomponents.passthrough.static",
+			Change:    ComponentChanged,
+			Arguments: []ArgumentDiff{{Path: "input", Old: `"hello"`, New: `"hello, world!"`}},
+		},
+	}, diffs)
+}
+
+func TestDiff_InvalidFile(t *testing.T) {
+	f, diags := ReadFile("invalid", []byte(`
+		testcomponents "passthrough" "static" {
+			input = testcomponents.passthrough.missing.output
+		}
+	`))
+	require.False(t, diags.HasErrors(), diags.Error())
+
+	_, err := Diff(f, f)
+	require.ErrorContains(t, err, "evaluating invalid")
+}
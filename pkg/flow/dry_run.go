@@ -0,0 +1,107 @@
+package flow
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-kit/log/level"
+	"github.com/hashicorp/hcl/v2"
+)
+
+// DryRunResult describes how loading a config file would change the running
+// components of a controller.
+type DryRunResult struct {
+	// Valid is true if the file loaded without errors.
+	Valid bool `json:"valid"`
+	// Diagnostics holds the errors and warnings reported while loading the
+	// file.
+	Diagnostics []Diagnostic `json:"diagnostics"`
+	// Changes holds the components which would be added, removed, or updated,
+	// sorted by component ID. Changes is empty if the file isn't valid.
+	Changes []ComponentDiff `json:"changes"`
+}
+
+// Diagnostic is an error or warning reported while loading a config file.
+type Diagnostic struct {
+	// Severity is either "error" or "warning".
+	Severity string `json:"severity"`
+	Summary  string `json:"summary"`
+	Detail   string `json:"detail,omitempty"`
+	// Range of the config file the diagnostic refers to, if any.
+	Range string `json:"range,omitempty"`
+}
+
+// DryRun evaluates f in a temporary controller and reports how loading f
+// would change the components running in c, without applying it. The
+// arguments of components are compared by the values they evaluate to.
+//
+// Components of the temporary controller aren't run, but are built, so
+// components which have side effects when built will perform them.
+func (c *Flow) DryRun(f *File) *DryRunResult {
+	// Hold loadMut so that the running components aren't compared while a
+	// different file is being loaded.
+	c.loadMut.RLock()
+	defer c.loadMut.RUnlock()
+
+	newArgs, err := evaluateArguments(f)
+	res := &DryRunResult{Diagnostics: diagnosticsOf(err), Changes: []ComponentDiff{}}
+	if newArgs == nil {
+		return res
+	}
+
+	res.Valid = true
+	if diffs := diffArgumentSets(componentArguments(c), newArgs); diffs != nil {
+		res.Changes = diffs
+	}
+	return res
+}
+
+// diagnosticsOf converts err into diagnostics. An error which doesn't wrap
+// hcl.Diagnostics is reported as a single error.
+func diagnosticsOf(err error) []Diagnostic {
+	if err == nil {
+		return []Diagnostic{}
+	}
+
+	var diags hcl.Diagnostics
+	if !errors.As(err, &diags) {
+		return []Diagnostic{{Severity: "error", Summary: err.Error()}}
+	}
+
+	res := make([]Diagnostic, 0, len(diags))
+	for _, d := range diags {
+		diag := Diagnostic{Severity: "error", Summary: d.Summary, Detail: d.Detail}
+		if d.Severity == hcl.DiagWarning {
+			diag.Severity = "warning"
+		}
+		if d.Subject != nil {
+			diag.Range = d.Subject.String()
+		}
+		res = append(res, diag)
+	}
+	return res
+}
+
+// DryRunHandler returns an http.HandlerFunc which reads a config file by
+// calling load, performs a dry run of it with DryRun, and writes the result
+// as JSON. If load fails, its error is reported as the diagnostics of an
+// invalid file. A 400 is returned if the file isn't valid.
+func (f *Flow) DryRunHandler(load func() (*File, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		var res *DryRunResult
+		if file, err := load(); err != nil {
+			res = &DryRunResult{Diagnostics: diagnosticsOf(err), Changes: []ComponentDiff{}}
+		} else {
+			res = f.DryRun(file)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if !res.Valid {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			level.Error(f.log).Log("msg", "failed to write dry run result", "err", err)
+		}
+	}
+}
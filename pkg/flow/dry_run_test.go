@@ -0,0 +1,125 @@
+package flow
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grafana/agent/pkg/flow/internal/testcomponents"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlow_DryRun(t *testing.T) {
+	running, diags := ReadFile("running", []byte(`
+		testcomponents "passthrough" "static" {
+			input = "hello"
+		}
+
+		testcomponents "passthrough" "removed" {
+			input = "bye"
+		}
+	`))
+	require.False(t, diags.HasErrors(), diags.Error())
+
+	f, _ := newFlow(testOptions(t))
+	require.NoError(t, f.LoadFile(running))
+
+	t.Run("valid", func(t *testing.T) {
+		file, diags := ReadFile("new", []byte(`
+			testcomponents "passthrough" "static" {
+				input = "hello, world!"
+			}
+
+			testcomponents "passthrough" "added" {
+				input = "hi"
+			}
+		`))
+		require.False(t, diags.HasErrors(), diags.Error())
+
+		res := f.DryRun(file)
+		require.True(t, res.Valid)
+		require.Empty(t, res.Diagnostics)
+		require.Equal(t, []ComponentDiff{
+			{
+				ID:        "testcomponents.passthrough.added",
+				Change:    ComponentAdded,
+				Arguments: []ArgumentDiff{{Path: "input", New: `"hi"`}},
+			},
+			{
+				ID:        "testcomponents.passthrough.removed",
+				Change:    ComponentRemoved,
+				Arguments: []ArgumentDiff{{Path: "input", Old: `"bye"`}},
+			},
+			{
+				ID:        "testcomponents.passthrough.static",
+				Change:    ComponentChanged,
+				Arguments: []ArgumentDiff{{Path: "input", Old: `"hello"`, New: `"hello, world!"`}},
+			},
+		}, res.Changes)
+
+		// The dry run must not have changed the running components.
+		args := f.loader.Component("testcomponents.passthrough.static").Arguments()
+		require.Equal(t, testcomponents.PassthroughConfig{Input: "hello"}, args)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		file, diags := ReadFile("new", []byte(`
+			testcomponents "passthrough" "static" {
+				input = testcomponents.passthrough.missing.output
+			}
+		`))
+		require.False(t, diags.HasErrors(), diags.Error())
+
+		res := f.DryRun(file)
+		require.False(t, res.Valid)
+		require.Empty(t, res.Changes)
+		require.NotEmpty(t, res.Diagnostics)
+		require.Equal(t, "error", res.Diagnostics[0].Severity)
+		require.Contains(t, res.Diagnostics[0].Range, "new:")
+	})
+}
+
+func TestDryRunHandler(t *testing.T) {
+	f, _ := newFlow(testOptions(t))
+
+	t.Run("valid", func(t *testing.T) {
+		handler := f.DryRunHandler(func() (*File, error) {
+			file, diags := ReadFile("new", []byte(`
+				testcomponents "passthrough" "static" {
+					input = "hello"
+				}
+			`))
+			return file, diagsOrNil(diags)
+		})
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/-/reload?dry_run=true", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.JSONEq(t, `{
+			"valid": true,
+			"diagnostics": [],
+			"changes": [{
+				"id": "testcomponents.passthrough.static",
+				"change": "added",
+				"arguments": [{"path": "input", "new": "\"hello\""}]
+			}]
+		}`, rec.Body.String())
+	})
+
+	t.Run("load failure", func(t *testing.T) {
+		handler := f.DryRunHandler(func() (*File, error) {
+			return nil, errors.New("file not found")
+		})
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/-/reload?dry_run=true", nil))
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+
+		var res DryRunResult
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		require.False(t, res.Valid)
+		require.Equal(t, []Diagnostic{{Severity: "error", Summary: "file not found"}}, res.Diagnostics)
+	})
+}
@@ -43,20 +43,52 @@
 // state if a component shuts down or is given an invalid config. This prevents
 // a domino effect of a single failed component taking down other components
 // which are otherwise healthy.
+//
+// Components which exit with an error are restarted with exponential backoff,
+// and are reported as unhealthy while waiting to be restarted. A component
+// which exhausts its restarts stays shut down until the next call to LoadFile.
+//
+// Clustering
+//
+// When Options.Cluster is set, components may use it to share work with other
+// agents of the cluster. For example, prometheus.scrape components with
+// clustering enabled only scrape the targets owned by the local agent, and
+// redistribute targets as agents join and leave the cluster.
+//
+// Modules
+//
+// Components may load other config files as modules through
+// component.Options.NewModule. Each module runs its components in a nested
+// controller. A module declares its inputs with argument blocks, which its
+// components reference as argument.NAME.value, and its outputs with export
+// blocks, which are reported to the component that loaded the module.
 package flow
 
 import (
 	"context"
 	"fmt"
 	"io"
+	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/go-kit/log/level"
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/pkg/cluster"
 	"github.com/grafana/agent/pkg/flow/internal/controller"
+	"github.com/grafana/agent/pkg/flow/internal/kvstore"
 	"github.com/grafana/agent/pkg/flow/logging"
+	"github.com/grafana/dskit/backoff"
 	"github.com/hashicorp/hcl/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/zclconf/go-cty/cty"
 )
 
+// kvFilename is the name of the file in the data path which holds the stores
+// of all components. It can't conflict with the data directories of
+// components, which are named after component IDs.
+const kvFilename = "component-kv.db"
+
 // Options holds static options for a flow controller.
 type Options struct {
 	// Logger for components to use. A no-op logger will be created if this is
@@ -66,23 +98,72 @@ type Options struct {
 	// Directory where components can write data. Components will create
 	// subdirectories for component-specific data.
 	DataPath string
+
+	// Reg is the Prometheus registerer to use for component metrics. Metrics
+	// from components will not be registered if Reg is nil.
+	Reg prometheus.Registerer
+
+	// ComponentRestartBackoff configures restarting components which exit
+	// with an error. MaxRetries limits the number of consecutive restarts;
+	// zero restarts forever, and a negative value disables restarts.
+	// DefaultComponentRestartBackoff is used if unset.
+	ComponentRestartBackoff backoff.Config
+
+	// Cluster is the cluster of agents which components may use to share
+	// work with other agents. Components handle all work themselves if
+	// Cluster is nil.
+	Cluster cluster.Node
 }
 
+// DefaultComponentRestartBackoff is the default backoff for restarting
+// components which exit with an error.
+var DefaultComponentRestartBackoff = controller.DefaultRestartBackoff
+
 // Flow is the Flow system.
 type Flow struct {
 	log  *logging.Logger
 	opts Options
+	id   string // ID of the module; empty for the root controller
 
 	updateQueue *controller.Queue
 	sched       *controller.Scheduler
 	loader      *controller.Loader
+	kv          *kvstore.DB
 
 	cancel       context.CancelFunc
 	exited       chan struct{}
 	loadFinished chan struct{}
 
-	loadMut    sync.RWMutex
-	loadedOnce bool
+	loadMut      sync.RWMutex
+	loadedOnce   bool
+	lastGood     *File
+	lastGoodArgs map[string]cty.Value
+	lastFailure  *LoadFailure
+
+	// onExportsChange is invoked when the values of the export blocks of the
+	// loaded file change. It is nil for the root controller.
+	onExportsChange component.ModuleExportsFunc
+
+	// evalMut protects the state used to evaluate components and exports
+	// outside of loading a file.
+	evalMut      sync.RWMutex
+	evalContext  *hcl.EvalContext
+	exportBlocks hcl.Blocks
+	exports      map[string]cty.Value
+}
+
+// LoadFailure describes a config file which failed to apply and was rolled
+// back to the last known good config.
+type LoadFailure struct {
+	// Name of the file which failed to apply. The contents of the file aren't
+	// kept, since they may hold secrets.
+	Name string `json:"name"`
+	// Time the failure occurred.
+	Time time.Time `json:"time"`
+	// Reason the file failed to apply.
+	Reason string `json:"reason"`
+	// RolledBackTo is the name of the file which was restored.
+	RolledBackTo string `json:"rolled_back_to"`
 }
 
 // New creates and starts a new Flow controller. Call Close to stop
@@ -96,22 +177,48 @@ func New(o Options) *Flow {
 func newFlow(o Options) (*Flow, context.Context) {
 	ctx, cancel := context.WithCancel(context.Background())
 
-	log := o.Logger
-	if log == nil {
+	if o.Logger == nil {
 		var err error
-		log, err = logging.New(io.Discard, logging.DefaultOptions)
+		o.Logger, err = logging.New(io.Discard, logging.DefaultOptions)
 		if err != nil {
 			// This shouldn't happen unless there's a bug
 			panic(err)
 		}
 	}
 
+	c := newController(controllerOptions{
+		Options: o,
+		KV:      kvstore.New(filepath.Join(o.DataPath, kvFilename)),
+	})
+	c.cancel = cancel
+	return c, ctx
+}
+
+// controllerOptions holds the options of a single controller, which is either
+// the root controller or the controller of a module.
+type controllerOptions struct {
+	Options
+
+	ID              string                      // ID of the module; empty for the root controller
+	KV              *kvstore.DB                 // Database for component stores
+	OnExportsChange component.ModuleExportsFunc // Invoked when exports change; may be nil
+}
+
+func newController(o controllerOptions) *Flow {
 	var (
 		queue  = controller.NewQueue()
-		sched  = controller.NewScheduler()
+		sched  = newScheduler(o.Options)
 		loader = controller.NewLoader(controller.ComponentGlobals{
-			Logger:   log,
-			DataPath: o.DataPath,
+			Logger:         o.Logger,
+			DataPath:       o.DataPath,
+			Registerer:     o.Reg,
+			HTTPPathPrefix: componentHTTPPathPrefix,
+			KV:             o.KV,
+			ControllerID:   o.ID,
+			Cluster:        o.Cluster,
+			NewModule: func(id string, onExportsChange component.ModuleExportsFunc) (component.Module, error) {
+				return newModule(o, id, onExportsChange), nil
+			},
 			OnExportsChange: func(cn *controller.ComponentNode) {
 				// Changed components should be queued for reevaluation.
 				queue.Enqueue(cn)
@@ -120,17 +227,31 @@ func newFlow(o Options) (*Flow, context.Context) {
 	)
 
 	return &Flow{
-		log:  log,
-		opts: o,
+		log:  o.Logger,
+		opts: o.Options,
+		id:   o.ID,
 
 		updateQueue: queue,
 		sched:       sched,
 		loader:      loader,
+		kv:          o.KV,
 
-		cancel:       cancel,
 		exited:       make(chan struct{}, 1),
 		loadFinished: make(chan struct{}, 1),
-	}, ctx
+
+		onExportsChange: o.OnExportsChange,
+		evalContext:     rootEvalContext,
+	}
+}
+
+func newScheduler(o Options) *controller.Scheduler {
+	restartBackoff := o.ComponentRestartBackoff
+	if restartBackoff == (backoff.Config{}) {
+		restartBackoff = DefaultComponentRestartBackoff
+	}
+	return controller.NewSchedulerWithOptions(controller.SchedulerOptions{
+		RestartBackoff: restartBackoff,
+	})
 }
 
 func (c *Flow) run(ctx context.Context) {
@@ -149,7 +270,15 @@ func (c *Flow) run(ctx context.Context) {
 			updated := c.updateQueue.TryDequeue()
 			if updated != nil {
 				level.Debug(c.log).Log("msg", "handling component with updated state", "node_id", updated.NodeID())
-				c.loader.EvaluateDependencies(rootEvalContext, updated)
+
+				c.evalMut.RLock()
+				ectx := c.evalContext
+				c.evalMut.RUnlock()
+
+				c.loader.EvaluateDependencies(ectx, updated)
+				if diags := c.updateExports(); diags.HasErrors() {
+					level.Error(c.log).Log("msg", "failed to evaluate exports", "err", diags)
+				}
 			}
 
 		case <-c.loadFinished:
@@ -173,7 +302,14 @@ func (c *Flow) run(ctx context.Context) {
 // error encountered during Load.
 //
 // The controller will only start running components after Load is called once
-// without any configuration errors.
+// without any configuration errors. Assert blocks in f are evaluated after all
+// components are evaluated; a failing assert is treated as a configuration
+// error. No argument values are given to the root config file, so all argument
+// blocks in f must be optional.
+//
+// If f fails to apply after a previous call to LoadFile succeeded, the
+// controller rolls back to the most recent config which applied without errors.
+// The failure is recorded and may be retrieved by calling LastLoadFailure.
 //
 // LoadFile will return an error value of hcl.Diagnostics. hcl.Diagnostics is
 // used to report both warnings and configuration errors.
@@ -185,8 +321,13 @@ func (c *Flow) LoadFile(f *File) error {
 	if err != nil {
 		return fmt.Errorf("error updating logger: %w", err)
 	}
+	return c.load(f, nil)
+}
 
-	diags := c.loader.Apply(rootEvalContext, f.Components)
+// load applies f using args as the values of the arguments declared in f.
+// loadMut must be held when calling load.
+func (c *Flow) load(f *File, args map[string]cty.Value) error {
+	diags := c.apply(f, args)
 	if !c.loadedOnce && diags.HasErrors() {
 		// The first call to Load should not run any components if there were
 		// errors in the coniguration file.
@@ -194,6 +335,12 @@ func (c *Flow) LoadFile(f *File) error {
 	}
 	c.loadedOnce = true
 
+	if !diags.HasErrors() {
+		c.lastGood, c.lastGoodArgs = f, args
+	} else if c.lastGood != nil {
+		c.rollback(f, diags)
+	}
+
 	select {
 	case c.loadFinished <- struct{}{}:
 	default:
@@ -202,6 +349,86 @@ func (c *Flow) LoadFile(f *File) error {
 	return diagsOrNil(diags)
 }
 
+// apply loads the components of f and evaluates its asserts and exports.
+// loadMut must be held when calling apply.
+func (c *Flow) apply(f *File, args map[string]cty.Value) hcl.Diagnostics {
+	ectx, diags := argumentsEvalContext(rootEvalContext, f.Arguments, args)
+	if diags.HasErrors() {
+		return diags
+	}
+
+	c.evalMut.Lock()
+	c.evalContext = ectx
+	c.exportBlocks = f.Exports
+	c.evalMut.Unlock()
+
+	diags = diags.Extend(c.loader.Apply(ectx, f.Components))
+	if diags.HasErrors() {
+		return diags
+	}
+
+	// Asserts and exports may reference any component, so they're only
+	// evaluated once all components were evaluated successfully.
+	diags = diags.Extend(evaluateAsserts(c.loader.EvalContext(ectx), f.Asserts))
+	if diags.HasErrors() {
+		return diags
+	}
+	return diags.Extend(c.updateExports())
+}
+
+// updateExports evaluates the current export blocks and reports their values
+// to onExportsChange if they changed.
+func (c *Flow) updateExports() hcl.Diagnostics {
+	c.evalMut.Lock()
+	defer c.evalMut.Unlock()
+
+	exports, diags := evaluateExports(c.loader.EvalContext(c.evalContext), c.exportBlocks)
+	if diags.HasErrors() || exportsEqual(c.exports, exports) {
+		return diags
+	}
+	c.exports = exports
+
+	if c.onExportsChange != nil {
+		c.onExportsChange(exports)
+	}
+	return diags
+}
+
+// rollback re-applies the last known good config after failed could not be
+// applied. loadMut must be held when calling rollback.
+func (c *Flow) rollback(failed *File, diags hcl.Diagnostics) {
+	level.Error(c.log).Log("msg", "failed to apply config, rolling back to last known good config", "file", failed.Name, "rollback_file", c.lastGood.Name, "err", diags)
+
+	c.lastFailure = &LoadFailure{
+		Name:         failed.Name,
+		Time:         time.Now(),
+		Reason:       diags.Error(),
+		RolledBackTo: c.lastGood.Name,
+	}
+
+	// The logger is shared with modules, so only the root controller may
+	// configure it.
+	if c.id == "" {
+		if err := c.log.Update(c.lastGood.Logging); err != nil {
+			level.Error(c.log).Log("msg", "failed to restore logger during rollback", "err", err)
+		}
+	}
+	if rollbackDiags := c.apply(c.lastGood, c.lastGoodArgs); rollbackDiags.HasErrors() {
+		// The last known good config applied without errors before, so this
+		// should only happen if a component can no longer accept its old
+		// arguments. There's nothing else to fall back to, so we just log it.
+		level.Error(c.log).Log("msg", "failed to roll back to last known good config", "err", rollbackDiags)
+	}
+}
+
+// LastLoadFailure returns the most recent config file which failed to apply
+// and was rolled back. Returns nil if no rollback has happened.
+func (c *Flow) LastLoadFailure() *LoadFailure {
+	c.loadMut.RLock()
+	defer c.loadMut.RUnlock()
+	return c.lastFailure
+}
+
 func diagsOrNil(d hcl.Diagnostics) error {
 	if len(d) > 0 {
 		return d
@@ -213,5 +440,11 @@ func diagsOrNil(d hcl.Diagnostics) error {
 func (c *Flow) Close() error {
 	c.cancel()
 	<-c.exited
-	return c.sched.Close()
+	err := c.sched.Close()
+
+	// Stores may only be closed once no components are running anymore.
+	if kvErr := c.kv.Close(); err == nil {
+		err = kvErr
+	}
+	return err
 }
@@ -2,9 +2,12 @@ package flow
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/go-kit/log/level"
 	"github.com/grafana/agent/pkg/flow/internal/controller"
@@ -34,6 +37,54 @@ func (f *Flow) GraphHandler() http.HandlerFunc {
 	}
 }
 
+// componentHTTPPathPrefix is the path prefix which ComponentHandler serves
+// the HTTP handlers of components under.
+const componentHTTPPathPrefix = "/component/"
+
+// ComponentHandler returns an http.Handler which passes requests for
+// /component/<component id>/* to the HTTP handler of the component with that
+// ID, trimming the /component/<component id> prefix from the request path. The
+// handler must be mounted at /component/. A 404 is returned if the component
+// doesn't exist or doesn't implement component.HTTPComponent.
+func (f *Flow) ComponentHandler() http.Handler {
+	return f.componentHandler(componentHTTPPathPrefix)
+}
+
+// componentHandler implements ComponentHandler for requests to
+// <prefix><component id>/*.
+func (f *Flow) componentHandler(prefix string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, prefix) {
+			http.NotFound(w, r)
+			return
+		}
+
+		id, rest := r.URL.Path[len(prefix):], "/"
+		if idx := strings.Index(id, "/"); idx >= 0 {
+			id, rest = id[:idx], id[idx:]
+		}
+
+		cn := f.loader.Component(id)
+		if cn == nil {
+			http.NotFound(w, r)
+			return
+		}
+		handler := cn.HTTPHandler()
+		if handler == nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.URL = new(url.URL)
+		*r2.URL = *r.URL
+		r2.URL.Path = rest
+		r2.URL.RawPath = ""
+		handler.ServeHTTP(w, r2)
+	})
+}
+
 // ConfigHandler returns an http.HandlerFunc which will render the most
 // recently loaded configuration file as HCL.
 func (f *Flow) ConfigHandler() http.HandlerFunc {
@@ -43,6 +94,107 @@ func (f *Flow) ConfigHandler() http.HandlerFunc {
 	}
 }
 
+// LoadFailureHandler returns an http.HandlerFunc which will render the name
+// and diagnostics of the most recent config file which failed to apply and was
+// rolled back as JSON. A 404 is returned if no rollback has happened.
+func (f *Flow) LoadFailureHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		failure := f.LastLoadFailure()
+		if failure == nil {
+			http.Error(w, "no failed config loads", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(failure); err != nil {
+			level.Error(f.log).Log("msg", "failed to write load failure", "err", err)
+		}
+	}
+}
+
+// ComponentInfoHandler returns an http.Handler which serves information about
+// the loaded components as JSON, including their health and their
+// dependencies in the graph. The handler must be mounted at prefix.
+//
+// A request to prefix lists all components. A request to
+// prefix/<component id> also returns the current arguments, exports, and
+// debug information of the component, with secrets redacted. A 404 is
+// returned if the component doesn't exist.
+func (f *Flow) ComponentInfoHandler(prefix string) http.Handler {
+	prefix = strings.TrimSuffix(prefix, "/")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var resp interface{}
+
+		switch id := strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/"); id {
+		case "":
+			resp = f.loader.ComponentInfos(false)
+		default:
+			info, ok := f.loader.ComponentInfo(id)
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			resp = info
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			level.Error(f.log).Log("msg", "failed to write component info", "err", err)
+		}
+	})
+}
+
+// debugStreamSuffix is the path suffix which DebugStreamHandler serves the
+// debug stream of a component under.
+const debugStreamSuffix = "/debug/stream"
+
+// DebugStreamHandler returns an http.Handler which streams the live debug
+// events of a component as newline-delimited JSON. The handler must be mounted
+// at prefix, and serves the stream of each component at
+// prefix/<component id>/debug/stream. A 404 is returned if the component
+// doesn't exist.
+//
+// The stream only includes events published after the request was made, and
+// ends when the client disconnects. Components which don't publish debug
+// events produce an empty stream.
+func (f *Flow) DebugStreamHandler(prefix string) http.Handler {
+	prefix = strings.TrimSuffix(prefix, "/") + "/"
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, prefix) || !strings.HasSuffix(r.URL.Path, debugStreamSuffix) {
+			http.NotFound(w, r)
+			return
+		}
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, prefix), debugStreamSuffix)
+
+		cn := f.loader.Component(id)
+		if cn == nil {
+			http.NotFound(w, r)
+			return
+		}
+		events := cn.DebugStream().Subscribe(r.Context())
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+		flusher, _ := w.(http.Flusher)
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		enc := json.NewEncoder(w)
+		for e := range events {
+			if err := enc.Encode(e); err != nil {
+				level.Debug(f.log).Log("msg", "failed to write debug event", "component", id, "err", err)
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	})
+}
+
 // configBytes dumps the current state of the flow config as HCL.
 func (f *Flow) configBytes(w io.Writer, debugInfo bool) (n int64, err error) {
 	file := hclwrite.NewFile()
@@ -2,8 +2,15 @@ package flow
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/pkg/flow/internal/controller"
 	_ "github.com/grafana/agent/pkg/flow/internal/testcomponents" // Import testcomponents
 	"github.com/stretchr/testify/require"
 )
@@ -53,3 +60,163 @@ testcomponents "passthrough" "static" {
 
 	require.Equal(t, expect, actual)
 }
+
+func TestComponentHandler(t *testing.T) {
+	configFile := `
+		testcomponents "tick" "ticker-a" {
+			frequency = "1s"
+		}
+
+		testcomponents "passthrough" "static" {
+			input = "hello, world!"
+		}
+	`
+
+	file, diags := ReadFile(t.Name(), []byte(configFile))
+	require.NotNil(t, file)
+	require.False(t, diags.HasErrors(), "Found errors when loading file")
+
+	f, _ := newFlow(testOptions(t))
+	require.NoError(t, f.LoadFile(file))
+
+	tt := []struct {
+		path       string
+		expectCode int
+		expectBody string
+	}{
+		{path: "/component/testcomponents.passthrough.static/output", expectCode: http.StatusOK, expectBody: "hello, world!"},
+		{path: "/component/testcomponents.passthrough.static/missing", expectCode: http.StatusNotFound},
+		{path: "/component/testcomponents.passthrough.static", expectCode: http.StatusNotFound},
+		{path: "/component/testcomponents.tick.ticker-a/output", expectCode: http.StatusNotFound},
+		{path: "/component/testcomponents.passthrough.missing/output", expectCode: http.StatusNotFound},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			f.ComponentHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			require.Equal(t, tc.expectCode, rec.Code)
+			if tc.expectBody != "" {
+				require.Equal(t, tc.expectBody, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestComponentInfoHandler(t *testing.T) {
+	configFile := `
+		testcomponents "passthrough" "static" {
+			input = "hello, world!"
+		}
+
+		testcomponents "passthrough" "downstream" {
+			input = testcomponents.passthrough.static.output
+		}
+	`
+
+	file, diags := ReadFile(t.Name(), []byte(configFile))
+	require.NotNil(t, file)
+	require.False(t, diags.HasErrors(), "Found errors when loading file")
+
+	f, _ := newFlow(testOptions(t))
+	require.NoError(t, f.LoadFile(file))
+
+	handler := f.ComponentInfoHandler("/-/components")
+
+	t.Run("list", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/-/components", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var infos []controller.ComponentInfo
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &infos))
+		require.Len(t, infos, 2)
+		require.Equal(t, "testcomponents.passthrough.downstream", infos[0].ID)
+		require.Equal(t, []string{"testcomponents.passthrough.static"}, infos[0].Dependencies)
+		require.Equal(t, []string{"testcomponents.passthrough.downstream"}, infos[1].Dependants)
+		require.Nil(t, infos[0].Arguments, "values should only be included for a single component")
+	})
+
+	t.Run("component", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/-/components/testcomponents.passthrough.downstream", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var info controller.ComponentInfo
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &info))
+		require.Equal(t, map[string]interface{}{"input": "hello, world!"}, info.Arguments)
+		require.Equal(t, map[string]interface{}{"output": "hello, world!"}, info.Exports)
+	})
+
+	t.Run("missing component", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/-/components/testcomponents.passthrough.missing", nil))
+		require.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("ui", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		f.UIHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/-/ui?id=testcomponents.passthrough.static", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Contains(t, rec.Body.String(), "hello, world!")
+		require.Contains(t, rec.Body.String(), `<a href="?id=testcomponents.passthrough.downstream">`)
+	})
+}
+
+func TestDebugStreamHandler(t *testing.T) {
+	configFile := `
+		testcomponents "passthrough" "static" {
+			input = "hello, world!"
+		}
+	`
+
+	file, diags := ReadFile(t.Name(), []byte(configFile))
+	require.NotNil(t, file)
+	require.False(t, diags.HasErrors(), "Found errors when loading file")
+
+	f, _ := newFlow(testOptions(t))
+	require.NoError(t, f.LoadFile(file))
+
+	srv := httptest.NewServer(f.DebugStreamHandler("/api/v0/component/"))
+	defer srv.Close()
+
+	t.Run("missing component", func(t *testing.T) {
+		resp, err := http.Get(srv.URL + "/api/v0/component/testcomponents.passthrough.missing/debug/stream")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/api/v0/component/testcomponents.passthrough.static/debug/stream", nil)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		stream := f.loader.Component("testcomponents.passthrough.static").DebugStream()
+		require.Eventually(t, stream.Active, time.Second, 10*time.Millisecond)
+
+		stream.Publish(component.DebugEvent{Type: "first", Message: "hello"})
+		stream.Publish(component.DebugEvent{Type: "second", Data: map[string]int{"count": 2}})
+
+		dec := json.NewDecoder(resp.Body)
+		var e component.DebugEvent
+		require.NoError(t, dec.Decode(&e))
+		require.Equal(t, "first", e.Type)
+		require.Equal(t, "hello", e.Message)
+		require.False(t, e.Time.IsZero())
+
+		require.NoError(t, dec.Decode(&e))
+		require.Equal(t, "second", e.Type)
+		require.Equal(t, map[string]interface{}{"count": 2.0}, e.Data)
+
+		// Disconnecting unsubscribes from the stream.
+		cancel()
+		require.Eventually(t, func() bool { return !stream.Active() }, time.Second, 10*time.Millisecond)
+	})
+}
@@ -70,3 +70,91 @@ func testOptions(t *testing.T) Options {
 		DataPath: t.TempDir(),
 	}
 }
+
+func TestController_LoadFile_Rollback(t *testing.T) {
+	ctrl, _ := newFlow(testOptions(t))
+
+	good, diags := ReadFile("good.flow", []byte(testFile))
+	require.False(t, diags.HasErrors())
+	require.NoError(t, ctrl.LoadFile(good))
+	require.Nil(t, ctrl.LastLoadFailure())
+
+	// Reference a component which doesn't exist so the file fails to apply.
+	bad, diags := ReadFile("bad.flow", []byte(`
+		testcomponents "passthrough" "static" {
+			input = testcomponents.passthrough.missing.output
+		}
+	`))
+	require.False(t, diags.HasErrors())
+	require.Error(t, ctrl.LoadFile(bad))
+
+	// The controller should have rolled back to the good file.
+	require.Len(t, ctrl.loader.Components(), 4)
+	in, _ := getFields(t, ctrl.loader.Graph(), "testcomponents.passthrough.static")
+	require.Equal(t, "hello, world!", in.(testcomponents.PassthroughConfig).Input)
+
+	failure := ctrl.LastLoadFailure()
+	require.NotNil(t, failure)
+	require.Equal(t, "bad.flow", failure.Name)
+	require.Equal(t, "good.flow", failure.RolledBackTo)
+	require.Contains(t, failure.Reason, "testcomponents.passthrough.missing")
+}
+
+func TestController_LoadFile_Asserts(t *testing.T) {
+	ctrl, _ := newFlow(testOptions(t))
+
+	f, diags := ReadFile(t.Name(), []byte(`
+		testcomponents "passthrough" "static" {
+			input = "hello, world!"
+		}
+
+		assert "output" {
+			condition = testcomponents.passthrough.static.output == "hello, world!"
+		}
+
+		assert "not_empty" {
+			condition = testcomponents.passthrough.static.output != ""
+			message   = "output must not be empty"
+		}
+	`))
+	require.False(t, diags.HasErrors())
+	require.Len(t, f.Components, 1)
+	require.Len(t, f.Asserts, 2)
+	require.NoError(t, ctrl.LoadFile(f))
+
+	// A failing assert rolls back to the last good file.
+	f, diags = ReadFile("failing.flow", []byte(`
+		testcomponents "passthrough" "static" {
+			input = ""
+		}
+
+		assert "not_empty" {
+			condition = testcomponents.passthrough.static.output != ""
+			message   = "output must not be empty"
+		}
+	`))
+	require.False(t, diags.HasErrors())
+
+	err := ctrl.LoadFile(f)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Assert not_empty failed; output must not be empty")
+
+	in, _ := getFields(t, ctrl.loader.Graph(), "testcomponents.passthrough.static")
+	require.Equal(t, "hello, world!", in.(testcomponents.PassthroughConfig).Input)
+}
+
+func TestController_LoadFile_FailingAssertOnFirstLoad(t *testing.T) {
+	ctrl, _ := newFlow(testOptions(t))
+
+	f, diags := ReadFile(t.Name(), []byte(`
+		assert "always_fails" {
+			condition = false
+		}
+	`))
+	require.False(t, diags.HasErrors())
+
+	err := ctrl.LoadFile(f)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Assert always_fails failed; The condition evaluated to false.")
+	require.False(t, ctrl.loadedOnce)
+}
@@ -0,0 +1,99 @@
+package flow
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/agent/pkg/flow/internal/controller"
+)
+
+// uiRefreshSeconds is how often pages of the UI reload themselves.
+const uiRefreshSeconds = 5
+
+var uiTemplate = template.Must(template.New("ui").Funcs(template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		bb, err := json.MarshalIndent(v, "", "  ")
+		return string(bb), err
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="{{ .Refresh }}">
+<title>Grafana Agent Flow{{ with .Component }} - {{ .ID }}{{ end }}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+pre { background: #f4f4f4; padding: 0.6em; overflow-x: auto; }
+.healthy { color: #1a7f37; }
+.unhealthy, .exited { color: #cf222e; }
+.unknown { color: #9a6700; }
+</style>
+</head>
+<body>
+{{- with .Component }}
+<p><a href="?">All components</a></p>
+<h1>{{ .ID }}</h1>
+<p>Health: <span class="{{ .Health.State }}">{{ .Health.State }}</span> {{ .Health.Message }} (updated {{ .Health.UpdateTime.Format "2006-01-02T15:04:05Z07:00" }})</p>
+<p>Dependencies: {{ range .Dependencies }}<a href="?id={{ . }}">{{ . }}</a> {{ else }}none{{ end }}</p>
+<p>Dependants: {{ range .Dependants }}<a href="?id={{ . }}">{{ . }}</a> {{ else }}none{{ end }}</p>
+<h2>Arguments</h2>
+<pre>{{ json .Arguments }}</pre>
+<h2>Exports</h2>
+<pre>{{ json .Exports }}</pre>
+{{- if .DebugInfo }}
+<h2>Debug info</h2>
+<pre>{{ json .DebugInfo }}</pre>
+{{- end }}
+{{- else }}
+<h1>Components</h1>
+<table>
+<tr><th>ID</th><th>Health</th><th>Message</th><th>Dependencies</th></tr>
+{{- range .Components }}
+<tr>
+<td><a href="?id={{ .ID }}">{{ .ID }}</a></td>
+<td class="{{ .Health.State }}">{{ .Health.State }}</td>
+<td>{{ .Health.Message }}</td>
+<td>{{ range .Dependencies }}<a href="?id={{ . }}">{{ . }}</a><br>{{ end }}</td>
+</tr>
+{{- end }}
+</table>
+{{- end }}
+</body>
+</html>
+`))
+
+type uiPage struct {
+	Refresh    int
+	Components []controller.ComponentInfo
+	Component  *controller.ComponentInfo
+}
+
+// UIHandler returns an http.HandlerFunc which renders a web page listing the
+// loaded components and their health. Passing a component ID as the id query
+// parameter renders the current arguments and exports of that component, with
+// secrets redacted. Pages reload themselves periodically.
+func (f *Flow) UIHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		page := uiPage{Refresh: uiRefreshSeconds}
+
+		if id := r.URL.Query().Get("id"); id != "" {
+			info, ok := f.loader.ComponentInfo(id)
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			page.Component = &info
+		} else {
+			page.Components = f.loader.ComponentInfos(false)
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := uiTemplate.Execute(w, page); err != nil {
+			level.Error(f.log).Log("msg", "failed to render UI", "err", err)
+		}
+	}
+}
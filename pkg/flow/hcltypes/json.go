@@ -0,0 +1,342 @@
+package hcltypes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// encodedValue is the JSON encoding of a value along with its type.
+type encodedValue struct {
+	Type  interface{} `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+// MarshalJSON encodes v and its type as JSON so it can be sent to another
+// process and decoded with UnmarshalJSON. Unlike the cty JSON encoding,
+// Secrets and OptionalSecrets are supported and decoded as secrets again.
+//
+// Other capsule values, such as receivers, are references to objects of the
+// current process and can't be encoded. Unknown values can't be encoded
+// either.
+func MarshalJSON(v cty.Value) ([]byte, error) {
+	ty, err := marshalType(v.Type())
+	if err != nil {
+		return nil, err
+	}
+	val, err := marshalValue(v, nil)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(encodedValue{Type: ty, Value: val})
+}
+
+func marshalType(ty cty.Type) (interface{}, error) {
+	switch {
+	case ty == cty.String:
+		return "string", nil
+	case ty == cty.Number:
+		return "number", nil
+	case ty == cty.Bool:
+		return "bool", nil
+	case ty == cty.DynamicPseudoType:
+		return "dynamic", nil
+	case ty.Equals(secretTy):
+		return "secret", nil
+	case ty.Equals(optionalSecretTy):
+		return "optional_secret", nil
+
+	case ty.IsListType(), ty.IsSetType(), ty.IsMapType():
+		ety, err := marshalType(ty.ElementType())
+		if err != nil {
+			return nil, err
+		}
+		kind := "list"
+		switch {
+		case ty.IsSetType():
+			kind = "set"
+		case ty.IsMapType():
+			kind = "map"
+		}
+		return []interface{}{kind, ety}, nil
+
+	case ty.IsObjectType():
+		attrs := make(map[string]interface{}, len(ty.AttributeTypes()))
+		for name, aty := range ty.AttributeTypes() {
+			enc, err := marshalType(aty)
+			if err != nil {
+				return nil, err
+			}
+			attrs[name] = enc
+		}
+		return []interface{}{"object", attrs}, nil
+
+	case ty.IsTupleType():
+		elems := make([]interface{}, 0, len(ty.TupleElementTypes()))
+		for _, ety := range ty.TupleElementTypes() {
+			enc, err := marshalType(ety)
+			if err != nil {
+				return nil, err
+			}
+			elems = append(elems, enc)
+		}
+		return []interface{}{"tuple", elems}, nil
+	}
+
+	return nil, fmt.Errorf("values of type %s can't be encoded", ty.FriendlyName())
+}
+
+func marshalValue(v cty.Value, path cty.Path) (interface{}, error) {
+	if !v.IsKnown() {
+		return nil, path.NewErrorf("unknown values can't be encoded")
+	}
+	if v.IsNull() {
+		return nil, nil
+	}
+
+	ty := v.Type()
+	switch {
+	case ty == cty.String:
+		return v.AsString(), nil
+	case ty == cty.Number:
+		return json.Number(v.AsBigFloat().Text('f', -1)), nil
+	case ty == cty.Bool:
+		return v.True(), nil
+	case ty.Equals(secretTy):
+		return string(*v.EncapsulatedValue().(*Secret)), nil
+	case ty.Equals(optionalSecretTy):
+		os := v.EncapsulatedValue().(*OptionalSecret)
+		return map[string]interface{}{"is_secret": os.IsSecret, "value": os.Value}, nil
+
+	case ty.IsObjectType(), ty.IsMapType():
+		res := make(map[string]interface{}, v.LengthInt())
+		for it := v.ElementIterator(); it.Next(); {
+			k, ev := it.Element()
+			enc, err := marshalValue(ev, path.Index(k))
+			if err != nil {
+				return nil, err
+			}
+			res[k.AsString()] = enc
+		}
+		return res, nil
+
+	case ty.IsListType(), ty.IsSetType(), ty.IsTupleType():
+		res := make([]interface{}, 0, v.LengthInt())
+		for it := v.ElementIterator(); it.Next(); {
+			k, ev := it.Element()
+			enc, err := marshalValue(ev, path.Index(k))
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, enc)
+		}
+		return res, nil
+	}
+
+	return nil, path.NewErrorf("values of type %s can't be encoded", ty.FriendlyName())
+}
+
+// UnmarshalJSON decodes a value encoded with MarshalJSON.
+func UnmarshalJSON(bb []byte) (cty.Value, error) {
+	var enc encodedValue
+	dec := json.NewDecoder(bytes.NewReader(bb))
+	dec.UseNumber()
+	if err := dec.Decode(&enc); err != nil {
+		return cty.NilVal, err
+	}
+
+	ty, err := unmarshalType(enc.Type)
+	if err != nil {
+		return cty.NilVal, err
+	}
+	return unmarshalValue(enc.Value, ty, nil)
+}
+
+func unmarshalType(enc interface{}) (cty.Type, error) {
+	switch enc := enc.(type) {
+	case string:
+		switch enc {
+		case "string":
+			return cty.String, nil
+		case "number":
+			return cty.Number, nil
+		case "bool":
+			return cty.Bool, nil
+		case "dynamic":
+			return cty.DynamicPseudoType, nil
+		case "secret":
+			return secretTy, nil
+		case "optional_secret":
+			return optionalSecretTy, nil
+		}
+
+	case []interface{}:
+		if len(enc) != 2 {
+			break
+		}
+		kind, _ := enc[0].(string)
+		switch kind {
+		case "list", "set", "map":
+			ety, err := unmarshalType(enc[1])
+			if err != nil {
+				return cty.NilType, err
+			}
+			switch kind {
+			case "list":
+				return cty.List(ety), nil
+			case "set":
+				return cty.Set(ety), nil
+			default:
+				return cty.Map(ety), nil
+			}
+
+		case "object":
+			attrs, ok := enc[1].(map[string]interface{})
+			if !ok {
+				break
+			}
+			atys := make(map[string]cty.Type, len(attrs))
+			for name, aenc := range attrs {
+				aty, err := unmarshalType(aenc)
+				if err != nil {
+					return cty.NilType, err
+				}
+				atys[name] = aty
+			}
+			return cty.Object(atys), nil
+
+		case "tuple":
+			elems, ok := enc[1].([]interface{})
+			if !ok {
+				break
+			}
+			etys := make([]cty.Type, 0, len(elems))
+			for _, eenc := range elems {
+				ety, err := unmarshalType(eenc)
+				if err != nil {
+					return cty.NilType, err
+				}
+				etys = append(etys, ety)
+			}
+			return cty.Tuple(etys), nil
+		}
+	}
+
+	return cty.NilType, fmt.Errorf("invalid encoded type %v", enc)
+}
+
+func unmarshalValue(enc interface{}, ty cty.Type, path cty.Path) (cty.Value, error) {
+	if enc == nil {
+		return cty.NullVal(ty), nil
+	}
+	invalid := func() (cty.Value, error) {
+		return cty.NilVal, path.NewErrorf("invalid encoded value for type %s", ty.FriendlyName())
+	}
+
+	switch {
+	case ty == cty.String:
+		if s, ok := enc.(string); ok {
+			return cty.StringVal(s), nil
+		}
+	case ty == cty.Number:
+		if n, ok := enc.(json.Number); ok {
+			v, err := cty.ParseNumberVal(string(n))
+			if err != nil {
+				return cty.NilVal, path.NewError(err)
+			}
+			return v, nil
+		}
+	case ty == cty.Bool:
+		if b, ok := enc.(bool); ok {
+			return cty.BoolVal(b), nil
+		}
+	case ty.Equals(secretTy):
+		if s, ok := enc.(string); ok {
+			secret := Secret(s)
+			return cty.CapsuleVal(secretTy, &secret), nil
+		}
+	case ty.Equals(optionalSecretTy):
+		obj, ok := enc.(map[string]interface{})
+		if !ok {
+			return invalid()
+		}
+		isSecret, ok1 := obj["is_secret"].(bool)
+		value, ok2 := obj["value"].(string)
+		if ok1 && ok2 {
+			return cty.CapsuleVal(optionalSecretTy, &OptionalSecret{IsSecret: isSecret, Value: value}), nil
+		}
+
+	case ty.IsObjectType():
+		obj, ok := enc.(map[string]interface{})
+		if !ok || len(obj) != len(ty.AttributeTypes()) {
+			return invalid()
+		}
+		attrs := make(map[string]cty.Value, len(obj))
+		for name, aty := range ty.AttributeTypes() {
+			aenc, ok := obj[name]
+			if !ok {
+				return invalid()
+			}
+			av, err := unmarshalValue(aenc, aty, path.GetAttr(name))
+			if err != nil {
+				return cty.NilVal, err
+			}
+			attrs[name] = av
+		}
+		return cty.ObjectVal(attrs), nil
+
+	case ty.IsMapType():
+		obj, ok := enc.(map[string]interface{})
+		if !ok {
+			return invalid()
+		}
+		if len(obj) == 0 {
+			return cty.MapValEmpty(ty.ElementType()), nil
+		}
+		elems := make(map[string]cty.Value, len(obj))
+		for k, eenc := range obj {
+			ev, err := unmarshalValue(eenc, ty.ElementType(), path.Index(cty.StringVal(k)))
+			if err != nil {
+				return cty.NilVal, err
+			}
+			elems[k] = ev
+		}
+		return cty.MapVal(elems), nil
+
+	case ty.IsListType(), ty.IsSetType(), ty.IsTupleType():
+		arr, ok := enc.([]interface{})
+		if !ok || (ty.IsTupleType() && len(arr) != len(ty.TupleElementTypes())) {
+			return invalid()
+		}
+		elems := make([]cty.Value, 0, len(arr))
+		for i, eenc := range arr {
+			var ety cty.Type
+			if ty.IsTupleType() {
+				ety = ty.TupleElementTypes()[i]
+			} else {
+				ety = ty.ElementType()
+			}
+			ev, err := unmarshalValue(eenc, ety, path.Index(cty.NumberIntVal(int64(i))))
+			if err != nil {
+				return cty.NilVal, err
+			}
+			elems = append(elems, ev)
+		}
+		switch {
+		case ty.IsTupleType():
+			return cty.TupleVal(elems), nil
+		case len(elems) == 0 && ty.IsListType():
+			return cty.ListValEmpty(ty.ElementType()), nil
+		case len(elems) == 0:
+			return cty.SetValEmpty(ty.ElementType()), nil
+		case ty.IsListType():
+			return cty.ListVal(elems), nil
+		default:
+			return cty.SetVal(elems), nil
+		}
+	}
+
+	return invalid()
+}
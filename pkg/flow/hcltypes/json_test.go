@@ -0,0 +1,79 @@
+package hcltypes
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	secret := Secret("hunter2")
+	public := OptionalSecret{IsSecret: false, Value: "admin"}
+	private := OptionalSecret{IsSecret: true, Value: "hunter2"}
+
+	tt := []struct {
+		name string
+		in   cty.Value
+	}{
+		{"string", cty.StringVal("hello")},
+		{"number", cty.NumberFloatVal(1.5)},
+		{"bool", cty.True},
+		{"null", cty.NullVal(cty.DynamicPseudoType)},
+		{"typed null", cty.NullVal(cty.List(cty.String))},
+		{"secret", cty.CapsuleVal(secretTy, &secret)},
+		{"optional secret", cty.CapsuleVal(optionalSecretTy, &private)},
+		{"empty list", cty.ListValEmpty(secretTy)},
+		{"empty map", cty.MapValEmpty(cty.Number)},
+		{"nested", cty.ObjectVal(map[string]cty.Value{
+			"username": cty.CapsuleVal(optionalSecretTy, &public),
+			"passwords": cty.ListVal([]cty.Value{
+				cty.CapsuleVal(secretTy, &secret),
+			}),
+			"targets": cty.TupleVal([]cty.Value{
+				cty.MapVal(map[string]cty.Value{"__address__": cty.StringVal("localhost:9090")}),
+				cty.SetVal([]cty.Value{cty.NumberIntVal(1), cty.NumberIntVal(2)}),
+			}),
+		})},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			bb, err := MarshalJSON(tc.in)
+			require.NoError(t, err)
+
+			out, err := UnmarshalJSON(bb)
+			require.NoError(t, err)
+			require.True(t, tc.in.Type().Equals(out.Type()), "expected type %s, got %s", tc.in.Type().GoString(), out.Type().GoString())
+
+			// Capsule values are compared by pointer, so compare the values they
+			// hold instead.
+			inRevealed, inSecret := Reveal(tc.in)
+			outRevealed, outSecret := Reveal(out)
+			require.Equal(t, inSecret, outSecret)
+			require.True(t, inRevealed.RawEquals(outRevealed), "expected %#v, got %#v", inRevealed, outRevealed)
+		})
+	}
+
+	t.Run("optional secrets keep whether they're secret", func(t *testing.T) {
+		bb, err := MarshalJSON(cty.CapsuleVal(optionalSecretTy, &public))
+		require.NoError(t, err)
+		out, err := UnmarshalJSON(bb)
+		require.NoError(t, err)
+		require.Equal(t, &public, out.EncapsulatedValue())
+	})
+}
+
+func TestMarshalJSON_Unsupported(t *testing.T) {
+	type receiver struct{}
+	receiverTy := cty.Capsule("receiver", reflect.TypeOf(receiver{}))
+
+	_, err := MarshalJSON(cty.ObjectVal(map[string]cty.Value{
+		"receiver": cty.CapsuleVal(receiverTy, &receiver{}),
+	}))
+	require.EqualError(t, err, "values of type receiver can't be encoded")
+
+	_, err = MarshalJSON(cty.ListVal([]cty.Value{cty.UnknownVal(cty.String)}))
+	require.EqualError(t, err, "unknown values can't be encoded")
+}
@@ -0,0 +1,234 @@
+package hcltypes
+
+import (
+	"fmt"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Reveal replaces Secrets and OptionalSecrets in v with their string values.
+// secret reports whether any of the replaced values were secrets. Values
+// which may hold secrets but aren't known yet are treated as secrets.
+func Reveal(v cty.Value) (revealed cty.Value, secret bool) {
+	ty := v.Type()
+	if !containsSecretType(ty) {
+		return v, false
+	}
+	switch {
+	case !v.IsKnown():
+		return cty.UnknownVal(revealType(ty)), true
+	case v.IsNull():
+		return cty.NullVal(revealType(ty)), false
+	}
+
+	switch {
+	case ty.Equals(secretTy):
+		return cty.StringVal(string(*v.EncapsulatedValue().(*Secret))), true
+	case ty.Equals(optionalSecretTy):
+		os := v.EncapsulatedValue().(*OptionalSecret)
+		return cty.StringVal(os.Value), os.IsSecret
+
+	case ty.IsObjectType():
+		attrs := make(map[string]cty.Value, len(ty.AttributeTypes()))
+		for name := range ty.AttributeTypes() {
+			av, s := Reveal(v.GetAttr(name))
+			attrs[name], secret = av, secret || s
+		}
+		return cty.ObjectVal(attrs), secret
+
+	case ty.IsMapType():
+		if v.LengthInt() == 0 {
+			return cty.MapValEmpty(revealType(ty.ElementType())), false
+		}
+		elems := make(map[string]cty.Value, v.LengthInt())
+		for it := v.ElementIterator(); it.Next(); {
+			k, ev := it.Element()
+			rv, s := Reveal(ev)
+			elems[k.AsString()], secret = rv, secret || s
+		}
+		return cty.MapVal(elems), secret
+
+	default: // list, set, or tuple
+		elems := make([]cty.Value, 0, v.LengthInt())
+		for it := v.ElementIterator(); it.Next(); {
+			_, ev := it.Element()
+			rv, s := Reveal(ev)
+			elems, secret = append(elems, rv), secret || s
+		}
+		switch {
+		case ty.IsTupleType():
+			return cty.TupleVal(elems), secret
+		case len(elems) == 0 && ty.IsListType():
+			return cty.ListValEmpty(revealType(ty.ElementType())), false
+		case len(elems) == 0:
+			return cty.SetValEmpty(revealType(ty.ElementType())), false
+		case ty.IsListType():
+			return cty.ListVal(elems), secret
+		default:
+			return cty.SetVal(elems), secret
+		}
+	}
+}
+
+// containsSecretType reports whether values of ty may hold secrets.
+func containsSecretType(ty cty.Type) bool {
+	switch {
+	case ty.Equals(secretTy), ty.Equals(optionalSecretTy):
+		return true
+	case ty.IsListType(), ty.IsSetType(), ty.IsMapType():
+		return containsSecretType(ty.ElementType())
+	case ty.IsObjectType():
+		for _, aty := range ty.AttributeTypes() {
+			if containsSecretType(aty) {
+				return true
+			}
+		}
+	case ty.IsTupleType():
+		for _, ety := range ty.TupleElementTypes() {
+			if containsSecretType(ety) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// revealType returns the type Reveal converts values of ty into.
+func revealType(ty cty.Type) cty.Type {
+	res, _ := mapPrimitiveTypes(ty, func(ty cty.Type) (cty.Type, error) {
+		if ty.Equals(secretTy) || ty.Equals(optionalSecretTy) {
+			return cty.String, nil
+		}
+		return ty, nil
+	})
+	return res
+}
+
+// Conceal converts the strings in v into Secrets. An error is returned if v
+// holds numbers or bools, which can't be secrets.
+func Conceal(v cty.Value) (cty.Value, error) {
+	ty := v.Type()
+	concealedTy, err := ConcealType(ty)
+	if err != nil {
+		return cty.NilVal, err
+	}
+	switch {
+	case !v.IsKnown():
+		return cty.UnknownVal(concealedTy), nil
+	case v.IsNull():
+		return cty.NullVal(concealedTy), nil
+	case concealedTy.Equals(ty):
+		return v, nil
+	}
+
+	switch {
+	case ty == cty.String:
+		s := Secret(v.AsString())
+		return cty.CapsuleVal(secretTy, &s), nil
+
+	case ty.IsObjectType():
+		attrs := make(map[string]cty.Value, len(ty.AttributeTypes()))
+		for name := range ty.AttributeTypes() {
+			if attrs[name], err = Conceal(v.GetAttr(name)); err != nil {
+				return cty.NilVal, err
+			}
+		}
+		return cty.ObjectVal(attrs), nil
+
+	case ty.IsMapType():
+		if v.LengthInt() == 0 {
+			return cty.MapValEmpty(concealedTy.ElementType()), nil
+		}
+		elems := make(map[string]cty.Value, v.LengthInt())
+		for it := v.ElementIterator(); it.Next(); {
+			k, ev := it.Element()
+			if elems[k.AsString()], err = Conceal(ev); err != nil {
+				return cty.NilVal, err
+			}
+		}
+		return cty.MapVal(elems), nil
+
+	default: // list, set, or tuple
+		elems := make([]cty.Value, 0, v.LengthInt())
+		for it := v.ElementIterator(); it.Next(); {
+			_, ev := it.Element()
+			cv, err := Conceal(ev)
+			if err != nil {
+				return cty.NilVal, err
+			}
+			elems = append(elems, cv)
+		}
+		switch {
+		case ty.IsTupleType():
+			return cty.TupleVal(elems), nil
+		case len(elems) == 0 && ty.IsListType():
+			return cty.ListValEmpty(concealedTy.ElementType()), nil
+		case len(elems) == 0:
+			return cty.SetValEmpty(concealedTy.ElementType()), nil
+		case ty.IsListType():
+			return cty.ListVal(elems), nil
+		default:
+			return cty.SetVal(elems), nil
+		}
+	}
+}
+
+// ConcealType returns the type Conceal converts values of ty into. An error
+// is returned if values of ty can't be concealed.
+func ConcealType(ty cty.Type) (cty.Type, error) {
+	return mapPrimitiveTypes(ty, func(ty cty.Type) (cty.Type, error) {
+		switch {
+		case ty == cty.String:
+			return secretTy, nil
+		case ty == cty.Number, ty == cty.Bool:
+			return cty.NilType, fmt.Errorf("a %s derived from a secret can't be used as a secret", ty.FriendlyName())
+		default:
+			return ty, nil
+		}
+	})
+}
+
+// mapPrimitiveTypes returns ty with each primitive and capsule type replaced
+// by the result of fn.
+func mapPrimitiveTypes(ty cty.Type, fn func(cty.Type) (cty.Type, error)) (cty.Type, error) {
+	switch {
+	case ty.IsListType(), ty.IsSetType(), ty.IsMapType():
+		ety, err := mapPrimitiveTypes(ty.ElementType(), fn)
+		if err != nil {
+			return cty.NilType, err
+		}
+		switch {
+		case ty.IsListType():
+			return cty.List(ety), nil
+		case ty.IsSetType():
+			return cty.Set(ety), nil
+		default:
+			return cty.Map(ety), nil
+		}
+
+	case ty.IsObjectType():
+		atys := make(map[string]cty.Type, len(ty.AttributeTypes()))
+		for name, aty := range ty.AttributeTypes() {
+			mapped, err := mapPrimitiveTypes(aty, fn)
+			if err != nil {
+				return cty.NilType, err
+			}
+			atys[name] = mapped
+		}
+		return cty.Object(atys), nil
+
+	case ty.IsTupleType():
+		etys := make([]cty.Type, 0, len(ty.TupleElementTypes()))
+		for _, ety := range ty.TupleElementTypes() {
+			mapped, err := mapPrimitiveTypes(ety, fn)
+			if err != nil {
+				return cty.NilType, err
+			}
+			etys = append(etys, mapped)
+		}
+		return cty.Tuple(etys), nil
+
+	default:
+		return fn(ty)
+	}
+}
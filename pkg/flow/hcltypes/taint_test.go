@@ -0,0 +1,80 @@
+package hcltypes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestReveal(t *testing.T) {
+	secret := Secret("hunter2")
+	public := OptionalSecret{IsSecret: false, Value: "admin"}
+	private := OptionalSecret{IsSecret: true, Value: "hunter2"}
+
+	t.Run("secret", func(t *testing.T) {
+		res, secret := Reveal(cty.CapsuleVal(secretTy, &secret))
+		require.True(t, secret)
+		require.Equal(t, cty.StringVal("hunter2"), res)
+	})
+
+	t.Run("optional secret", func(t *testing.T) {
+		res, secret := Reveal(cty.CapsuleVal(optionalSecretTy, &public))
+		require.False(t, secret)
+		require.Equal(t, cty.StringVal("admin"), res)
+
+		res, secret = Reveal(cty.CapsuleVal(optionalSecretTy, &private))
+		require.True(t, secret)
+		require.Equal(t, cty.StringVal("hunter2"), res)
+	})
+
+	t.Run("nested", func(t *testing.T) {
+		res, secret := Reveal(cty.ObjectVal(map[string]cty.Value{
+			"username": cty.CapsuleVal(optionalSecretTy, &public),
+			"passwords": cty.ListVal([]cty.Value{
+				cty.CapsuleVal(secretTy, &secret),
+			}),
+		}))
+		require.True(t, secret)
+		require.Equal(t, cty.ObjectVal(map[string]cty.Value{
+			"username":  cty.StringVal("admin"),
+			"passwords": cty.ListVal([]cty.Value{cty.StringVal("hunter2")}),
+		}), res)
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		res, secret := Reveal(cty.UnknownVal(cty.List(secretTy)))
+		require.True(t, secret)
+		require.Equal(t, cty.UnknownVal(cty.List(cty.String)), res)
+	})
+
+	t.Run("no secrets", func(t *testing.T) {
+		in := cty.MapVal(map[string]cty.Value{"a": cty.NumberIntVal(1)})
+		res, secret := Reveal(in)
+		require.False(t, secret)
+		require.Equal(t, in, res)
+	})
+}
+
+func TestConceal(t *testing.T) {
+	t.Run("strings", func(t *testing.T) {
+		res, err := Conceal(cty.TupleVal([]cty.Value{
+			cty.StringVal("hunter2"),
+			cty.NullVal(cty.String),
+		}))
+		require.NoError(t, err)
+		require.Equal(t, cty.Tuple([]cty.Type{secretTy, secretTy}), res.Type())
+		require.Equal(t, Secret("hunter2"), *res.Index(cty.NumberIntVal(0)).EncapsulatedValue().(*Secret))
+		require.True(t, res.Index(cty.NumberIntVal(1)).IsNull())
+	})
+
+	t.Run("numbers are rejected", func(t *testing.T) {
+		_, err := Conceal(cty.NumberIntVal(7))
+		require.EqualError(t, err, "a number derived from a secret can't be used as a secret")
+	})
+
+	t.Run("bools are rejected", func(t *testing.T) {
+		_, err := ConcealType(cty.Map(cty.Bool))
+		require.EqualError(t, err, "a bool derived from a secret can't be used as a secret")
+	})
+}
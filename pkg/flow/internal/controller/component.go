@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
+	"path"
 	"path/filepath"
 	"reflect"
 	"strings"
@@ -13,8 +15,13 @@ import (
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/pkg/cluster"
 	"github.com/grafana/agent/pkg/flow/internal/dag"
+	"github.com/grafana/agent/pkg/flow/internal/kvstore"
+	"github.com/grafana/agent/pkg/util"
 	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rfratto/gohcl"
 	"go.uber.org/atomic"
 )
@@ -56,8 +63,18 @@ type ComponentGlobals struct {
 	Logger          log.Logger              // Logger shared between all managed components.
 	DataPath        string                  // Shared directory where component data may be stored
 	OnExportsChange func(cn *ComponentNode) // Invoked when the managed component updated its exports
+	Registerer      prometheus.Registerer   // Registerer for component metrics; may be nil
+	HTTPPathPrefix  string                  // Path prefix which component HTTP handlers are served under
+	KV              *kvstore.DB             // Database for component stores; may be nil
+	ControllerID    string                  // ID of the module owning the components; empty for the root controller
+	NewModule       ModuleFunc              // Creates modules for components; may be nil
+	Cluster         cluster.Node            // Cluster shared by components; may be nil
 }
 
+// ModuleFunc creates a new module owned by the component with the given
+// global ID.
+type ModuleFunc func(id string, onExportsChange component.ModuleExportsFunc) (component.Module, error)
+
 // ComponentNode is a controller node which manages a user-defined component.
 //
 // ComponentNode manages the underlying component and caches its current
@@ -70,6 +87,8 @@ type ComponentNode struct {
 	managedOpts     component.Options
 	exportsType     reflect.Type
 	onExportsChange func(cn *ComponentNode) // Informs controller that we changed our exports
+	registry        *util.Unregisterer      // Tracks metrics registered by the managed component
+	debugStream     *DebugStream            // Debug events published by the managed component
 
 	mut     sync.RWMutex
 	block   *hcl.Block          // Current HCL block to derive args from
@@ -91,7 +110,8 @@ type ComponentNode struct {
 }
 
 var (
-	_ dag.Node = (*ComponentNode)(nil)
+	_ dag.Node        = (*ComponentNode)(nil)
+	_ RestartObserver = (*ComponentNode)(nil)
 )
 
 // NewComponentNode creates a new ComponentNode from an initial hcl.Block. The
@@ -131,6 +151,8 @@ func NewComponentNode(globals ComponentGlobals, b *hcl.Block) *ComponentNode {
 
 		evalHealth: initHealth,
 		runHealth:  initHealth,
+
+		debugStream: NewDebugStream(),
 	}
 	cn.managedOpts = getManagedOptions(globals, cn)
 
@@ -153,10 +175,39 @@ func getRegistration(id ComponentID) (component.Registration, bool) {
 }
 
 func getManagedOptions(globals ComponentGlobals, cn *ComponentNode) component.Options {
+	// Components of modules are prefixed by the ID of the module so that IDs
+	// are unique across all controllers.
+	globalID := cn.nodeID
+	if globals.ControllerID != "" {
+		globalID = path.Join(globals.ControllerID, cn.nodeID)
+	}
+
+	cn.registry = util.WrapWithUnregisterer(prometheus.WrapRegistererWith(prometheus.Labels{
+		"component_id": globalID,
+	}, globals.Registerer))
+
+	var store component.Store
+	if globals.KV != nil {
+		store = globals.KV.Store(globalID)
+	}
+
+	var newModule func(string, component.ModuleExportsFunc) (component.Module, error)
+	if globals.NewModule != nil {
+		newModule = func(id string, onExportsChange component.ModuleExportsFunc) (component.Module, error) {
+			return globals.NewModule(path.Join(globalID, id), onExportsChange)
+		}
+	}
+
 	return component.Options{
-		ID:            cn.nodeID,
-		Logger:        log.With(globals.Logger, "component", cn.nodeID),
-		DataPath:      filepath.Join(globals.DataPath, cn.nodeID),
+		ID:            globalID,
+		Logger:        log.With(globals.Logger, "component", globalID),
+		DataPath:      filepath.Join(globals.DataPath, globalID),
+		Registerer:    cn.registry,
+		Store:         store,
+		HTTPPath:      path.Join(globals.HTTPPathPrefix, globalID) + "/",
+		NewModule:     newModule,
+		DebugStream:   cn.debugStream,
+		Cluster:       globals.Cluster,
 		OnStateChange: cn.setExports,
 	}
 }
@@ -168,6 +219,10 @@ func getExportsType(reg component.Registration) reflect.Type {
 	return nil
 }
 
+// DebugStream returns the stream of debug events published by the managed
+// component.
+func (cn *ComponentNode) DebugStream() *DebugStream { return cn.debugStream }
+
 // ID returns the component ID of the managed component from its HCL block.
 func (cn *ComponentNode) ID() ComponentID { return cn.id }
 
@@ -212,10 +267,18 @@ func (cn *ComponentNode) Evaluate(ectx *hcl.EvalContext) error {
 	return err
 }
 
-func (cn *ComponentNode) evaluate(ectx *hcl.EvalContext) error {
+func (cn *ComponentNode) evaluate(ectx *hcl.EvalContext) (err error) {
 	cn.mut.Lock()
 	defer cn.mut.Unlock()
 
+	// Building or updating a component may panic if it has a bug. Recover from
+	// it here so a single bad component can't take down the whole process.
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic while evaluating component: %v", r)
+		}
+	}()
+
 	cn.doingEval.Store(true)
 	defer cn.doingEval.Store(false)
 
@@ -229,10 +292,19 @@ func (cn *ComponentNode) evaluate(ectx *hcl.EvalContext) error {
 	// components expect a non-pointer.
 	argsCopy := reflect.ValueOf(args).Elem().Interface()
 
+	if cn.reg.Validate != nil {
+		if err := cn.reg.Validate(argsCopy); err != nil {
+			return ValidationError{Diags: validationDiags(cn.block, err)}
+		}
+	}
+
 	if cn.managed == nil {
 		// We haven't built the managed component successfully yet.
 		managed, err := cn.reg.Build(cn.managedOpts, argsCopy)
 		if err != nil {
+			// Metrics registered by the failed build would otherwise make every
+			// later build fail with an AlreadyRegisteredError.
+			cn.registry.UnregisterAll()
 			return fmt.Errorf("building component: %w", err)
 		}
 		cn.managed = managed
@@ -257,6 +329,71 @@ func (cn *ComponentNode) evaluate(ectx *hcl.EvalContext) error {
 	return nil
 }
 
+// ValidationError is returned by Evaluate when the arguments of a component
+// are rejected by the Validate function of its registration.
+type ValidationError struct {
+	// Diags holds a diagnostic for every invalid argument.
+	Diags hcl.Diagnostics
+}
+
+// Error implements error.
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("validating arguments: %s", e.Diags.Error())
+}
+
+// validationDiags converts an error returned by a Validate function into
+// diagnostics. Diagnostics for component.ArgumentErrors point at the invalid
+// argument in b; other errors point at b itself.
+func validationDiags(b *hcl.Block, err error) hcl.Diagnostics {
+	var (
+		argErr  component.ArgumentError
+		argErrs component.ArgumentErrors
+	)
+	switch {
+	case errors.As(err, &argErrs):
+	case errors.As(err, &argErr):
+		argErrs = component.ArgumentErrors{argErr}
+	default:
+		return hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid component arguments",
+			Detail:   err.Error(),
+			Subject:  b.DefRange.Ptr(),
+		}}
+	}
+
+	diags := make(hcl.Diagnostics, 0, len(argErrs))
+	for _, e := range argErrs {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  fmt.Sprintf("Invalid argument %s", e.Name),
+			Detail:   e.Message,
+			Subject:  argumentRange(b, e.Name).Ptr(),
+		})
+	}
+	return diags
+}
+
+// argumentRange returns the range of the value of the attribute name in b,
+// or the definition of the first block named name. The definition of b is
+// returned if neither exists.
+func argumentRange(b *hcl.Block, name string) hcl.Range {
+	body, ok := b.Body.(*hclsyntax.Body)
+	if !ok {
+		return b.DefRange
+	}
+
+	if attr, ok := body.Attributes[name]; ok {
+		return attr.Expr.Range()
+	}
+	for _, inner := range body.Blocks {
+		if inner.Type == name {
+			return inner.DefRange()
+		}
+	}
+	return b.DefRange
+}
+
 // Run runs the managed component in the calling goroutine until ctx is
 // canceled. Evaluate must have been called at least once without retuning an
 // error before calling Run.
@@ -289,6 +426,25 @@ func (cn *ComponentNode) Run(ctx context.Context) error {
 	return err
 }
 
+// Restarting implements RestartObserver, reporting the component as unhealthy
+// until it is restarted.
+func (cn *ComponentNode) Restarting(err error, attempt int, delay time.Duration) {
+	level.Warn(cn.managedOpts.Logger).Log("msg", "restarting component after error", "attempt", attempt, "delay", delay)
+	cn.setRunHealth(component.HealthTypeUnhealthy, fmt.Sprintf("component crashed, restarting in %s (restart %d): %s", delay.Round(time.Millisecond), attempt, err))
+}
+
+// RestartsExhausted implements RestartObserver.
+func (cn *ComponentNode) RestartsExhausted(err error, restarts int) {
+	level.Error(cn.managedOpts.Logger).Log("msg", "component exhausted its restarts and won't be restarted until the config is reloaded", "restarts", restarts)
+	cn.setRunHealth(component.HealthTypeExited, fmt.Sprintf("component shut down with error after %d restarts: %s", restarts, err))
+}
+
+// unregisterMetrics unregisters all metrics registered by the managed
+// component. It is called once the component has been removed from the graph.
+func (cn *ComponentNode) unregisterMetrics() {
+	cn.registry.UnregisterAll()
+}
+
 // ErrUnevaluated is returned if ComponentNode.Run is called before a managed
 // component is built.
 var ErrUnevaluated = errors.New("managed component not built")
@@ -355,7 +511,7 @@ func (cn *ComponentNode) setExports(e component.Exports) {
 // The health of a ComponentNode is tracked from three parts, in descending
 // precedence order:
 //
-//     1. Exited health from a call to Run()
+//     1. Exited or restarting health from a call to Run()
 //     2. Unhealthy status from last call to Evaluate
 //     3. Health reported by the managed component (if any)
 //     4. Latest health from Run() or Evaluate(), if the managed component does not
@@ -364,9 +520,9 @@ func (cn *ComponentNode) CurrentHealth() component.Health {
 	cn.healthMut.RLock()
 	defer cn.healthMut.RUnlock()
 
-	// A component which stopped running takes precedence over all other health
-	// states
-	if cn.runHealth.Health == component.HealthTypeExited {
+	// A component which stopped running or is waiting to be restarted takes
+	// precedence over all other health states
+	if cn.runHealth.Health == component.HealthTypeExited || cn.runHealth.Health == component.HealthTypeUnhealthy {
 		return cn.runHealth
 	}
 
@@ -401,6 +557,19 @@ func (cn *ComponentNode) DebugInfo() interface{} {
 	return nil
 }
 
+// HTTPHandler returns the HTTP handler of the managed component. Returns nil
+// if the managed component doesn't implement component.HTTPComponent or
+// hasn't been built yet.
+func (cn *ComponentNode) HTTPHandler() http.Handler {
+	cn.mut.RLock()
+	defer cn.mut.RUnlock()
+
+	if hc, ok := cn.managed.(component.HTTPComponent); ok {
+		return hc.Handler()
+	}
+	return nil
+}
+
 // setEvalHealth sets the internal health from a call to Evaluate. See Health
 // for information on how overall health is calculated.
 func (cn *ComponentNode) setEvalHealth(t component.HealthType, msg string) {
@@ -0,0 +1,171 @@
+package controller
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rfratto/gohcl"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// ComponentInfo describes the current state of a component for debugging.
+type ComponentInfo struct {
+	ID     string     `json:"id"`
+	Health HealthInfo `json:"health"`
+
+	// IDs of components referenced by the component, and of components which
+	// reference it.
+	Dependencies []string `json:"dependencies"`
+	Dependants   []string `json:"dependants"`
+
+	// Current values of the component, with secrets redacted. Only set when
+	// details are requested.
+	Arguments interface{} `json:"arguments,omitempty"`
+	Exports   interface{} `json:"exports,omitempty"`
+	DebugInfo interface{} `json:"debug_info,omitempty"`
+}
+
+// HealthInfo describes the health of a component.
+type HealthInfo struct {
+	State      string    `json:"state"`
+	Message    string    `json:"message"`
+	UpdateTime time.Time `json:"update_time"`
+}
+
+// ComponentInfos returns information about all loaded components, sorted by
+// ID. The values of components are only included when details is true.
+func (l *Loader) ComponentInfos(details bool) []ComponentInfo {
+	l.mut.RLock()
+	defer l.mut.RUnlock()
+
+	infos := make([]ComponentInfo, 0, len(l.components))
+	for _, cn := range l.components {
+		infos = append(infos, l.componentInfo(cn, details))
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
+
+// ComponentInfo returns information about the loaded component with the
+// given ID, including its values. Returns false if no such component is
+// loaded.
+func (l *Loader) ComponentInfo(id string) (ComponentInfo, bool) {
+	l.mut.RLock()
+	defer l.mut.RUnlock()
+
+	n := l.graph.GetByID(id)
+	if n == nil {
+		return ComponentInfo{}, false
+	}
+	return l.componentInfo(n.(*ComponentNode), true), true
+}
+
+// componentInfo returns information about cn. l.mut must be held when calling
+// componentInfo.
+func (l *Loader) componentInfo(cn *ComponentNode, details bool) ComponentInfo {
+	health := cn.CurrentHealth()
+
+	info := ComponentInfo{
+		ID: cn.NodeID(),
+		Health: HealthInfo{
+			State:      health.Health.String(),
+			Message:    health.Message,
+			UpdateTime: health.UpdateTime,
+		},
+		Dependencies: []string{},
+		Dependants:   []string{},
+	}
+	for _, n := range l.graph.Dependencies(cn) {
+		info.Dependencies = append(info.Dependencies, n.NodeID())
+	}
+	for _, n := range l.graph.Dependants(cn) {
+		info.Dependants = append(info.Dependants, n.NodeID())
+	}
+	sort.Strings(info.Dependencies)
+	sort.Strings(info.Dependants)
+
+	if details {
+		info.Arguments = jsonValueOf(cn.Arguments())
+		info.Exports = jsonValueOf(cn.Exports())
+		info.DebugInfo = jsonValueOf(cn.DebugInfo())
+	}
+	return info
+}
+
+// jsonValueOf converts the Go value v, which must be encodable to HCL, into a
+// value which can be marshaled as JSON. Secrets are redacted. Returns nil if
+// v is nil or can't be converted.
+func jsonValueOf(v interface{}) interface{} {
+	val := ctyValueOf(v)
+	if val == cty.NilVal {
+		return nil
+	}
+	return jsonValue(val)
+}
+
+// ctyValueOf converts the Go value v, which must be encodable to HCL, into a
+// cty.Value. Returns cty.NilVal if v is nil or can't be converted.
+func ctyValueOf(v interface{}) cty.Value {
+	if v == nil {
+		return cty.NilVal
+	}
+
+	v = addressable(v)
+	ty, err := gohcl.ImpliedType(v)
+	if err != nil {
+		return cty.NilVal
+	}
+	val, err := gohcl.ToCtyValue(v, ty)
+	if err != nil {
+		return cty.NilVal
+	}
+	return val
+}
+
+// jsonValue converts val into a value which can be marshaled as JSON.
+// Capsule values are rendered the same way they're rendered as HCL, so
+// secrets are redacted.
+func jsonValue(val cty.Value) interface{} {
+	if val.IsNull() || !val.IsKnown() {
+		return nil
+	}
+
+	ty := val.Type()
+	switch {
+	case ty == cty.String:
+		return val.AsString()
+	case ty == cty.Number:
+		f, _ := val.AsBigFloat().Float64()
+		return f
+	case ty == cty.Bool:
+		return val.True()
+
+	case ty.IsCapsuleType():
+		text := strings.TrimSpace(string(tokensForValue(val).Bytes()))
+		if text == "null" {
+			// The capsule type can't be rendered.
+			return nil
+		} else if s, err := strconv.Unquote(text); err == nil {
+			return s
+		}
+		return text
+
+	case ty.IsObjectType() || ty.IsMapType():
+		res := make(map[string]interface{}, val.LengthInt())
+		for it := val.ElementIterator(); it.Next(); {
+			k, v := it.Element()
+			res[k.AsString()] = jsonValue(v)
+		}
+		return res
+
+	default: // list, set, or tuple
+		res := make([]interface{}, 0, val.LengthInt())
+		for it := val.ElementIterator(); it.Next(); {
+			_, v := it.Element()
+			res = append(res, jsonValue(v))
+		}
+		return res
+	}
+}
@@ -0,0 +1,37 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/grafana/agent/pkg/flow/hcltypes"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONValueOf_Secrets(t *testing.T) {
+	type args struct {
+		Name     string                  `hcl:"name,attr"`
+		Password hcltypes.Secret         `hcl:"password,attr"`
+		Token    hcltypes.OptionalSecret `hcl:"token,attr"`
+		Content  hcltypes.OptionalSecret `hcl:"content,attr"`
+		Count    int                     `hcl:"count,attr"`
+		Labels   map[string]string       `hcl:"labels,attr"`
+	}
+
+	actual := jsonValueOf(args{
+		Name:     "example",
+		Password: "hunter2",
+		Token:    hcltypes.OptionalSecret{IsSecret: true, Value: "abc"},
+		Content:  hcltypes.OptionalSecret{Value: "visible"},
+		Count:    3,
+		Labels:   map[string]string{"a": "b"},
+	})
+
+	require.Equal(t, map[string]interface{}{
+		"name":     "example",
+		"password": "(secret)",
+		"token":    "(secret)",
+		"content":  "visible",
+		"count":    3.0,
+		"labels":   map[string]interface{}{"a": "b"},
+	}, actual)
+}
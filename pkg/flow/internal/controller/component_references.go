@@ -18,8 +18,9 @@ type Reference struct {
 }
 
 // ComponentReferences returns the list of references a component is making to
-// other components.
-func ComponentReferences(cn *ComponentNode, g *dag.Graph) ([]Reference, hcl.Diagnostics) {
+// other components. References to variables defined by parent, such as the
+// arguments of a module, aren't references to components and are ignored.
+func ComponentReferences(cn *ComponentNode, g *dag.Graph, parent *hcl.EvalContext) ([]Reference, hcl.Diagnostics) {
 	var (
 		traversals = componentTraversals(cn)
 
@@ -28,6 +29,9 @@ func ComponentReferences(cn *ComponentNode, g *dag.Graph) ([]Reference, hcl.Diag
 
 	refs := make([]Reference, 0, len(traversals))
 	for _, t := range traversals {
+		if isVariable(parent, t.RootName()) {
+			continue
+		}
 		ref, refDiags := resolveTraversal(t, g)
 		diags = diags.Extend(refDiags)
 		if refDiags.HasErrors() {
@@ -39,11 +43,41 @@ func ComponentReferences(cn *ComponentNode, g *dag.Graph) ([]Reference, hcl.Diag
 	return refs, diags
 }
 
+// isVariable returns true if name is a variable of ectx or any of its
+// parents.
+func isVariable(ectx *hcl.EvalContext, name string) bool {
+	for ; ectx != nil; ectx = ectx.Parent() {
+		if _, ok := ectx.Variables[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
 // componentTraversals gets the set of hcl.Traverals for a given component.
+// Template blocks of the component are ignored, since the component evaluates
+// them itself.
 func componentTraversals(cn *ComponentNode) []hcl.Traversal {
 	cn.mut.RLock()
 	defer cn.mut.RUnlock()
-	return expressionsFromSyntaxBody(cn.block.Body.(*hclsyntax.Body))
+
+	var (
+		body  = cn.block.Body.(*hclsyntax.Body)
+		exprs []hcl.Traversal
+	)
+	for _, attrib := range body.Attributes {
+		exprs = append(exprs, attrib.Expr.Variables()...)
+	}
+Blocks:
+	for _, block := range body.Blocks {
+		for _, template := range cn.reg.Templates {
+			if block.Type == template {
+				continue Blocks
+			}
+		}
+		exprs = append(exprs, expressionsFromSyntaxBody(block.Body)...)
+	}
+	return exprs
 }
 
 // expressionsFromSyntaxBody recurses through body and finds all variable
@@ -0,0 +1,71 @@
+package controller_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/pkg/flow/internal/controller"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name: "testcomponents.failing_build",
+		Args: failingBuildArgs{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			if err := opts.Registerer.Register(prometheus.NewCounter(prometheus.CounterOpts{
+				Name: "failing_build_total",
+			})); err != nil {
+				return nil, err
+			}
+			if args.(failingBuildArgs).Fail {
+				return nil, fmt.Errorf("build failed")
+			}
+			return failingBuild{}, nil
+		},
+	})
+}
+
+type failingBuildArgs struct {
+	Fail bool `hcl:"fail,attr"`
+}
+
+type failingBuild struct{}
+
+func (failingBuild) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (failingBuild) Update(component.Arguments) error { return nil }
+
+// TestComponentNode_FailedBuildMetrics ensures that metrics registered by a
+// failed build don't prevent the component from being built later.
+func TestComponentNode_FailedBuildMetrics(t *testing.T) {
+	globals := controller.ComponentGlobals{
+		Logger:          log.NewNopLogger(),
+		DataPath:        t.TempDir(),
+		OnExportsChange: func(cn *controller.ComponentNode) { /* no-op */ },
+		Registerer:      prometheus.NewRegistry(),
+	}
+	l := controller.NewLoader(globals)
+
+	diags := applyFromContent(t, l, []byte(`
+		testcomponents "failing_build" "example" {
+			fail = true
+		}
+	`))
+	require.True(t, diags.HasErrors())
+
+	diags = applyFromContent(t, l, []byte(`
+		testcomponents "failing_build" "example" {
+			fail = false
+		}
+	`))
+	require.False(t, diags.HasErrors(), diags.Error())
+}
@@ -2,10 +2,12 @@ package controller
 
 import (
 	"reflect"
+	"strings"
 
 	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/hashicorp/hcl/v2/hclwrite"
 	"github.com/rfratto/gohcl"
+	"github.com/zclconf/go-cty/cty"
 )
 
 // WriteComponent generates an hclwrite Block from a component. Health and
@@ -21,7 +23,7 @@ func WriteComponent(cn *ComponentNode, debugInfo bool) *hclwrite.Block {
 	b := hclwrite.NewBlock(blockName, labels)
 
 	if args := cn.Arguments(); args != nil {
-		gohcl.EncodeIntoBody(args, b.Body())
+		encodeIntoBody(args, b.Body())
 	}
 
 	// We ignore zero value exports since the zero values for fields don't get
@@ -31,7 +33,7 @@ func WriteComponent(cn *ComponentNode, debugInfo bool) *hclwrite.Block {
 			{Type: hclsyntax.TokenNewline, Bytes: []byte("\n")},
 			{Type: hclsyntax.TokenComment, Bytes: []byte("// Exported fields:\n")},
 		})
-		gohcl.EncodeIntoBody(exports, b.Body())
+		encodeIntoBody(exports, b.Body())
 	}
 
 	if debugInfo {
@@ -53,3 +55,106 @@ func WriteComponent(cn *ComponentNode, debugInfo bool) *hclwrite.Block {
 func exportsZeroValue(v interface{}) bool {
 	return reflect.ValueOf(v).IsZero()
 }
+
+var valueType = reflect.TypeOf(cty.Value{})
+
+// encodeIntoBody encodes the struct v into body. Attributes holding a
+// cty.Value are written separately from the rest of v, since gohcl can't
+// encode capsule values (such as secrets) nested inside of a cty.Value.
+func encodeIntoBody(v interface{}, body *hclwrite.Body) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		gohcl.EncodeIntoBody(v, body)
+		return
+	}
+
+	// Encode a copy of v where cty.Value attributes are replaced with null,
+	// and then overwrite the null values. This retains the order of
+	// attributes.
+	var (
+		copied = reflect.New(rv.Type()).Elem()
+		values = make(map[string]cty.Value)
+	)
+	copied.Set(rv)
+	for i := 0; i < rv.NumField(); i++ {
+		field := rv.Type().Field(i)
+		tag := strings.Split(field.Tag.Get("hcl"), ",")
+		if len(tag) != 2 || (tag[1] != "attr" && tag[1] != "optional") {
+			continue
+		}
+
+		switch {
+		case field.Type == valueType:
+			values[tag[0]] = rv.Field(i).Interface().(cty.Value)
+			copied.Field(i).Set(reflect.ValueOf(cty.NullVal(cty.DynamicPseudoType)))
+		case field.Type == reflect.PtrTo(valueType) && !rv.Field(i).IsNil():
+			values[tag[0]] = rv.Field(i).Elem().Interface().(cty.Value)
+			copied.Field(i).Set(reflect.Zero(field.Type))
+		}
+	}
+
+	gohcl.EncodeIntoBody(copied.Addr().Interface(), body)
+	for name, val := range values {
+		// Attributes may have been omitted for being optional.
+		if body.GetAttribute(name) == nil {
+			continue
+		}
+		body.SetAttributeRaw(name, tokensForValue(val))
+	}
+}
+
+// tokensForValue returns the tokens for val, using the token extension of
+// capsule types for any capsule values found in val.
+func tokensForValue(val cty.Value) hclwrite.Tokens {
+	if !val.IsWhollyKnown() {
+		return hclwrite.TokensForValue(cty.NullVal(cty.DynamicPseudoType))
+	}
+	if val.IsNull() || !containsCapsule(val) {
+		return hclwrite.TokensForValue(val)
+	}
+
+	ty := val.Type()
+	switch {
+	case ty.IsCapsuleType():
+		if f, ok := ty.CapsuleExtensionData(gohcl.CapsuleTokenExtensionKey).(gohcl.CapsuleTokenExtension); ok {
+			return f(val)
+		}
+		return hclwrite.TokensForValue(cty.NullVal(cty.DynamicPseudoType))
+
+	case ty.IsObjectType() || ty.IsMapType():
+		var attrs []hclwrite.ObjectAttrTokens
+		for it := val.ElementIterator(); it.Next(); {
+			k, v := it.Element()
+
+			name := hclwrite.TokensForValue(k)
+			if hclsyntax.ValidIdentifier(k.AsString()) {
+				name = hclwrite.TokensForIdentifier(k.AsString())
+			}
+			attrs = append(attrs, hclwrite.ObjectAttrTokens{Name: name, Value: tokensForValue(v)})
+		}
+		return hclwrite.TokensForObject(attrs)
+
+	default: // list, set, or tuple
+		var elems []hclwrite.Tokens
+		for it := val.ElementIterator(); it.Next(); {
+			_, v := it.Element()
+			elems = append(elems, tokensForValue(v))
+		}
+		return hclwrite.TokensForTuple(elems)
+	}
+}
+
+// containsCapsule returns true if val or any value nested in val is a
+// capsule.
+func containsCapsule(val cty.Value) (found bool) {
+	_ = cty.Walk(val, func(_ cty.Path, v cty.Value) (bool, error) {
+		if v.Type().IsCapsuleType() {
+			found = true
+		}
+		return !found, nil
+	})
+	return found
+}
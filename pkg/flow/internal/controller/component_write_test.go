@@ -8,11 +8,14 @@ import (
 
 	"github.com/go-kit/log"
 	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/pkg/flow/hcltypes"
 	_ "github.com/grafana/agent/pkg/flow/internal/testcomponents" // Import test components
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/rfratto/gohcl"
 	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
 )
 
 func TestWriteComponent(t *testing.T) {
@@ -99,6 +102,37 @@ testcomponents "passthrough" "example" {
 	require.Equal(t, expect, actual)
 }
 
+func TestEncodeIntoBody_CapsuleValues(t *testing.T) {
+	secret := hcltypes.Secret("hunter2")
+	secretTy, err := gohcl.ImpliedType(secret)
+	require.NoError(t, err)
+	secretVal, err := gohcl.ToCtyValue(&secret, secretTy)
+	require.NoError(t, err)
+
+	// Capsules nested in a cty.Value can't be encoded by gohcl directly.
+	v := struct {
+		Name   string    `hcl:"name,attr"`
+		Values cty.Value `hcl:"values,attr"`
+	}{
+		Name: "example",
+		Values: cty.ObjectVal(map[string]cty.Value{
+			"password": secretVal,
+			"list":     cty.TupleVal([]cty.Value{cty.StringVal("a"), secretVal}),
+		}),
+	}
+
+	f := hclwrite.NewFile()
+	encodeIntoBody(&v, f.Body())
+
+	expect := `
+name = "example"
+values = {
+  list     = ["a", (secret)]
+  password = (secret)
+}`
+	require.Equal(t, strings.TrimSpace(expect), strings.TrimSpace(string(f.Bytes())))
+}
+
 func loadFile(t *testing.T, bb []byte) hcl.Blocks {
 	file, diags := hclsyntax.ParseConfig(bb, t.Name(), hcl.InitialPos)
 	if diags.HasErrors() {
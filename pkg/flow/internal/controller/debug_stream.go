@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/grafana/agent/component"
+)
+
+// debugStreamBuffer is the number of events buffered for each subscriber of
+// a DebugStream before events are dropped.
+const debugStreamBuffer = 100
+
+// DebugStream implements component.DebugStream by broadcasting published
+// events to subscribers.
+type DebugStream struct {
+	mut         sync.RWMutex
+	subscribers map[chan component.DebugEvent]struct{}
+}
+
+var _ component.DebugStream = (*DebugStream)(nil)
+
+// NewDebugStream creates a new DebugStream with no subscribers.
+func NewDebugStream() *DebugStream {
+	return &DebugStream{
+		subscribers: make(map[chan component.DebugEvent]struct{}),
+	}
+}
+
+// Active implements component.DebugStream.
+func (ds *DebugStream) Active() bool {
+	ds.mut.RLock()
+	defer ds.mut.RUnlock()
+	return len(ds.subscribers) > 0
+}
+
+// Publish implements component.DebugStream.
+func (ds *DebugStream) Publish(e component.DebugEvent) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	ds.mut.RLock()
+	defer ds.mut.RUnlock()
+
+	for ch := range ds.subscribers {
+		select {
+		case ch <- e:
+		default:
+			// The subscriber isn't keeping up; drop the event.
+		}
+	}
+}
+
+// Subscribe returns a channel which receives events published after
+// Subscribe is called. The channel is closed once ctx is canceled.
+func (ds *DebugStream) Subscribe(ctx context.Context) <-chan component.DebugEvent {
+	ch := make(chan component.DebugEvent, debugStreamBuffer)
+
+	ds.mut.Lock()
+	ds.subscribers[ch] = struct{}{}
+	ds.mut.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		ds.mut.Lock()
+		delete(ds.subscribers, ch)
+		ds.mut.Unlock()
+		close(ch)
+	}()
+
+	return ch
+}
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 
@@ -61,7 +62,7 @@ func (l *Loader) Apply(parentContext *hcl.EvalContext, blocks hcl.Blocks) hcl.Di
 	populateDiags := l.populateGraph(&newGraph, blocks)
 	diags = diags.Extend(populateDiags)
 
-	wireDiags := l.wireGraphEdges(&newGraph)
+	wireDiags := l.wireGraphEdges(parentContext, &newGraph)
 	diags = diags.Extend(wireDiags)
 
 	// Validate graph to detect cycles
@@ -89,7 +90,13 @@ func (l *Loader) Apply(parentContext *hcl.EvalContext, blocks hcl.Blocks) hcl.Di
 		// We cache both arguments and exports during an initial load in case the
 		// component is new; we want to make sure that all fields are available
 		// before the component updates its exports for the first time.
-		if err := l.evaluate(parentContext, n.(*ComponentNode), true, true); err != nil {
+		err := l.evaluate(parentContext, n.(*ComponentNode), true, true)
+
+		var validationErr ValidationError
+		if errors.As(err, &validationErr) {
+			// Invalid arguments are reported where they're defined.
+			diags = diags.Extend(validationErr.Diags)
+		} else if err != nil {
 			diags = diags.Append(&hcl.Diagnostic{
 				Severity: hcl.DiagError,
 				Summary:  "Failed to build component",
@@ -100,6 +107,14 @@ func (l *Loader) Apply(parentContext *hcl.EvalContext, blocks hcl.Blocks) hcl.Di
 		return nil
 	})
 
+	// Components which were dropped from the graph will never run again, so
+	// their metrics can be removed.
+	for _, c := range l.components {
+		if newGraph.GetByID(c.NodeID()) == nil {
+			c.unregisterMetrics()
+		}
+	}
+
 	l.components = components
 	l.graph = &newGraph
 	l.cache.SyncIDs(componentIDs)
@@ -107,6 +122,16 @@ func (l *Loader) Apply(parentContext *hcl.EvalContext, blocks hcl.Blocks) hcl.Di
 	return diags
 }
 
+// UnregisterMetrics unregisters the metrics of all loaded components. It must
+// only be called once the components stopped running.
+func (l *Loader) UnregisterMetrics() {
+	l.mut.RLock()
+	defer l.mut.RUnlock()
+	for _, c := range l.components {
+		c.unregisterMetrics()
+	}
+}
+
 func (l *Loader) populateGraph(g *dag.Graph, blocks hcl.Blocks) hcl.Diagnostics {
 	// Fill our graph with components.
 	var (
@@ -143,11 +168,11 @@ func (l *Loader) populateGraph(g *dag.Graph, blocks hcl.Blocks) hcl.Diagnostics
 	return diags
 }
 
-func (l *Loader) wireGraphEdges(g *dag.Graph) hcl.Diagnostics {
+func (l *Loader) wireGraphEdges(parentContext *hcl.EvalContext, g *dag.Graph) hcl.Diagnostics {
 	var diags hcl.Diagnostics
 
 	for _, n := range g.Nodes() {
-		refs, nodeDiags := ComponentReferences(n.(*ComponentNode), g)
+		refs, nodeDiags := ComponentReferences(n.(*ComponentNode), g, parentContext)
 		for _, ref := range refs {
 			g.AddEdge(dag.Edge{From: n, To: ref.Target})
 		}
@@ -164,6 +189,24 @@ func (l *Loader) Components() []*ComponentNode {
 	return l.components
 }
 
+// Component returns the loaded component with the given ID. Returns nil if no
+// such component is loaded.
+func (l *Loader) Component(id string) *ComponentNode {
+	l.mut.RLock()
+	defer l.mut.RUnlock()
+
+	cn, _ := l.graph.GetByID(id).(*ComponentNode)
+	return cn
+}
+
+// EvalContext returns a child of parentContext which exposes the current
+// arguments and exports of all loaded components.
+func (l *Loader) EvalContext(parentContext *hcl.EvalContext) *hcl.EvalContext {
+	l.mut.RLock()
+	defer l.mut.RUnlock()
+	return l.cache.BuildContext(parentContext)
+}
+
 // Graph returns a copy of the DAG managed by the Loader.
 func (l *Loader) Graph() *dag.Graph {
 	l.mut.RLock()
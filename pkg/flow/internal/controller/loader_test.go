@@ -1,6 +1,7 @@
 package controller_test
 
 import (
+	"context"
 	"testing"
 
 	"github.com/go-kit/log"
@@ -114,6 +115,30 @@ func TestLoader(t *testing.T) {
 		})
 	})
 
+	t.Run("Invalid arguments", func(t *testing.T) {
+		invalidFile := `
+			testcomponents "tick" "ticker" {
+				frequency = "-1s"
+			}
+		`
+		l := controller.NewLoader(globals)
+		diags := applyFromContent(t, l, []byte(invalidFile))
+		require.True(t, diags.HasErrors())
+		require.Len(t, diags, 1)
+
+		// The diagnostic must point at the invalid value.
+		require.Equal(t, "Invalid argument frequency", diags[0].Summary)
+		require.Equal(t, "must not be negative", diags[0].Detail)
+		require.Equal(t, 3, diags[0].Subject.Start.Line)
+		require.Equal(t, `"-1s"`, string(diags[0].Subject.SliceBytes([]byte(invalidFile))))
+
+		// Components with invalid arguments are never built.
+		cn := l.Graph().GetByID("testcomponents.tick.ticker").(*controller.ComponentNode)
+		require.Equal(t, component.HealthTypeUnhealthy, cn.CurrentHealth().Health)
+		require.Contains(t, cn.CurrentHealth().Message, "validating arguments")
+		require.ErrorIs(t, cn.Run(context.Background()), controller.ErrUnevaluated)
+	})
+
 	t.Run("File has cycles", func(t *testing.T) {
 		invalidFile := `
 			testcomponents "tick" "ticker" {
@@ -4,6 +4,9 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
+
+	"github.com/grafana/dskit/backoff"
 )
 
 // RunnableNode is any dag.Node which can also be ran.
@@ -12,8 +15,40 @@ type RunnableNode interface {
 	Run(ctx context.Context) error
 }
 
+// RestartObserver is implemented by RunnableNodes which want to be informed
+// when the Scheduler restarts them.
+type RestartObserver interface {
+	// Restarting is invoked when Run returned err and will be invoked again
+	// after delay. attempt counts consecutive restarts, starting from 1.
+	Restarting(err error, attempt int, delay time.Duration)
+
+	// RestartsExhausted is invoked when Run returned err after restarts
+	// consecutive restarts, and won't be restarted until the next call to
+	// Synchronize.
+	RestartsExhausted(err error, restarts int)
+}
+
+// DefaultRestartBackoff is the default backoff for restarting RunnableNodes
+// whose Run method returns an error.
+var DefaultRestartBackoff = backoff.Config{
+	MinBackoff: time.Second,
+	MaxBackoff: time.Minute,
+	MaxRetries: 10,
+}
+
+// SchedulerOptions holds optional settings for creating a Scheduler.
+type SchedulerOptions struct {
+	// RestartBackoff configures restarting RunnableNodes whose Run method
+	// returns an error. MaxRetries limits the number of consecutive restarts;
+	// zero restarts forever, and a negative value disables restarts. A
+	// RunnableNode which ran for at least MaxBackoff before failing is
+	// considered to have recovered, and its count of restarts is reset.
+	RestartBackoff backoff.Config
+}
+
 // Scheduler runs components.
 type Scheduler struct {
+	opts    SchedulerOptions
 	ctx     context.Context
 	cancel  context.CancelFunc
 	running sync.WaitGroup
@@ -22,13 +57,21 @@ type Scheduler struct {
 	tasks    map[string]*task
 }
 
-// NewScheduler creates a new Scheduler. Call Synchronize to manage the set of
-// components which are running.
+// NewScheduler creates a new Scheduler which restarts failed components with
+// DefaultRestartBackoff. Call Synchronize to manage the set of components
+// which are running.
 //
 // Call Close to stop the Scheduler and all running components.
 func NewScheduler() *Scheduler {
+	return NewSchedulerWithOptions(SchedulerOptions{RestartBackoff: DefaultRestartBackoff})
+}
+
+// NewSchedulerWithOptions creates a new Scheduler with the provided
+// SchedulerOptions.
+func NewSchedulerWithOptions(opts SchedulerOptions) *Scheduler {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &Scheduler{
+		opts:   opts,
 		ctx:    ctx,
 		cancel: cancel,
 
@@ -42,8 +85,9 @@ func NewScheduler() *Scheduler {
 // managed by Scheduler will be kept running, while running RunnableNodes that
 // are not in rr will be shut down and removed.
 //
-// Existing components will be restarted if they stopped since the previous
-// call to Synchronize.
+// RunnableNodes whose Run method returns an error are restarted with backoff
+// until they exhaust their restarts. Existing components will be restarted if
+// they stopped since the previous call to Synchronize.
 func (s *Scheduler) Synchronize(rr []RunnableNode) error {
 	s.tasksMut.Lock()
 	defer s.tasksMut.Unlock()
@@ -83,8 +127,9 @@ func (s *Scheduler) Synchronize(rr []RunnableNode) error {
 		)
 
 		opts := taskOptions{
-			Context:  s.ctx,
-			Runnable: newRunnable,
+			Context:        s.ctx,
+			Runnable:       newRunnable,
+			RestartBackoff: s.opts.RestartBackoff,
 			OnDone: func() {
 				defer s.running.Done()
 
@@ -119,9 +164,10 @@ type task struct {
 }
 
 type taskOptions struct {
-	Context  context.Context
-	Runnable RunnableNode
-	OnDone   func()
+	Context        context.Context
+	Runnable       RunnableNode
+	RestartBackoff backoff.Config
+	OnDone         func()
 }
 
 // newTask creates and starts a new task.
@@ -137,11 +183,48 @@ func newTask(opts taskOptions) *task {
 	go func() {
 		defer opts.OnDone()
 		defer close(t.exited)
-		_ = opts.Runnable.Run(t.ctx)
+		t.supervise(opts)
 	}()
 	return t
 }
 
+// supervise runs the runnable until it exits without an error, the task is
+// stopped, or the runnable exhausts its restarts.
+func (t *task) supervise(opts taskOptions) {
+	var (
+		restarts    = backoff.New(t.ctx, opts.RestartBackoff)
+		observer, _ = opts.Runnable.(RestartObserver)
+	)
+
+	for {
+		started := time.Now()
+		err := opts.Runnable.Run(t.ctx)
+		if err == nil || t.ctx.Err() != nil {
+			return
+		}
+
+		if time.Since(started) >= opts.RestartBackoff.MaxBackoff {
+			restarts.Reset()
+		}
+		if !restarts.Ongoing() {
+			if observer != nil {
+				observer.RestartsExhausted(err, restarts.NumRetries())
+			}
+			return
+		}
+
+		delay := restarts.NextDelay()
+		if observer != nil {
+			observer.Restarting(err, restarts.NumRetries(), delay)
+		}
+		select {
+		case <-t.ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
 func (t *task) Stop() {
 	t.cancel()
 	<-t.exited
@@ -2,12 +2,16 @@ package controller_test
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/grafana/agent/component"
 	"github.com/grafana/agent/pkg/flow/internal/controller"
+	"github.com/grafana/dskit/backoff"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
 )
 
 func TestScheduler_Synchronize(t *testing.T) {
@@ -86,6 +90,68 @@ func TestScheduler_Synchronize(t *testing.T) {
 	})
 }
 
+func TestScheduler_Restarts(t *testing.T) {
+	restartBackoff := backoff.Config{
+		MinBackoff: time.Millisecond,
+		MaxBackoff: time.Hour,
+		MaxRetries: 3,
+	}
+
+	t.Run("Restarts failed jobs until restarts are exhausted", func(t *testing.T) {
+		var runs atomic.Int64
+		runFunc := func(ctx context.Context) error {
+			runs.Inc()
+			return fmt.Errorf("failed")
+		}
+
+		r := &observingRunnable{
+			fakeRunnable: fakeRunnable{ID: "component-a", Component: mockComponent{RunFunc: runFunc}},
+			exhausted:    make(chan int, 1),
+		}
+		sched := controller.NewSchedulerWithOptions(controller.SchedulerOptions{RestartBackoff: restartBackoff})
+		require.NoError(t, sched.Synchronize([]controller.RunnableNode{r}))
+
+		require.Equal(t, 3, <-r.exhausted)
+		require.Equal(t, int64(4), runs.Load())
+		require.Equal(t, []int{1, 2, 3}, r.Attempts())
+		require.NoError(t, sched.Close())
+	})
+
+	t.Run("Doesn't restart jobs which exit normally", func(t *testing.T) {
+		var runs atomic.Int64
+		runFunc := func(ctx context.Context) error {
+			runs.Inc()
+			return nil
+		}
+
+		sched := controller.NewSchedulerWithOptions(controller.SchedulerOptions{RestartBackoff: restartBackoff})
+		require.NoError(t, sched.Synchronize([]controller.RunnableNode{
+			fakeRunnable{ID: "component-a", Component: mockComponent{RunFunc: runFunc}},
+		}))
+		require.NoError(t, sched.Close())
+		require.Equal(t, int64(1), runs.Load())
+	})
+
+	t.Run("Negative max retries disables restarts", func(t *testing.T) {
+		runFunc := func(ctx context.Context) error {
+			return fmt.Errorf("failed")
+		}
+
+		r := &observingRunnable{
+			fakeRunnable: fakeRunnable{ID: "component-a", Component: mockComponent{RunFunc: runFunc}},
+			exhausted:    make(chan int, 1),
+		}
+		sched := controller.NewSchedulerWithOptions(controller.SchedulerOptions{
+			RestartBackoff: backoff.Config{MaxRetries: -1},
+		})
+		require.NoError(t, sched.Synchronize([]controller.RunnableNode{r}))
+
+		require.Equal(t, 0, <-r.exhausted)
+		require.Empty(t, r.Attempts())
+		require.NoError(t, sched.Close())
+	})
+}
+
 type fakeRunnable struct {
 	ID        string
 	Component component.Component
@@ -105,3 +171,29 @@ var _ component.Component = (*mockComponent)(nil)
 
 func (mc mockComponent) Run(ctx context.Context) error              { return mc.RunFunc(ctx) }
 func (mc mockComponent) Update(newConfig component.Arguments) error { return mc.UpdateFunc(newConfig) }
+
+type observingRunnable struct {
+	fakeRunnable
+
+	mut       sync.Mutex
+	attempts  []int
+	exhausted chan int
+}
+
+var _ controller.RestartObserver = (*observingRunnable)(nil)
+
+func (or *observingRunnable) Restarting(_ error, attempt int, _ time.Duration) {
+	or.mut.Lock()
+	defer or.mut.Unlock()
+	or.attempts = append(or.attempts, attempt)
+}
+
+func (or *observingRunnable) RestartsExhausted(_ error, restarts int) {
+	or.exhausted <- restarts
+}
+
+func (or *observingRunnable) Attempts() []int {
+	or.mut.Lock()
+	defer or.mut.Unlock()
+	return or.attempts
+}
@@ -2,6 +2,7 @@ package controller
 
 import (
 	"fmt"
+	"reflect"
 	"sync"
 
 	"github.com/grafana/agent/component"
@@ -41,11 +42,12 @@ func (vc *valueCache) CacheArguments(id ComponentID, args component.Arguments) {
 
 	argsVal := cty.EmptyObjectVal
 	if args != nil {
-		ty, err := gohcl.ImpliedType(args)
+		v := addressable(args)
+		ty, err := gohcl.ImpliedType(v)
 		if err != nil {
 			panic(err)
 		}
-		cv, err := gohcl.ToCtyValue(args, ty)
+		cv, err := gohcl.ToCtyValue(v, ty)
 		if err != nil {
 			panic(err)
 		}
@@ -66,11 +68,12 @@ func (vc *valueCache) CacheExports(id ComponentID, exports component.Exports) {
 
 	exportsVal := cty.EmptyObjectVal
 	if exports != nil {
-		ty, err := gohcl.ImpliedType(exports)
+		v := addressable(exports)
+		ty, err := gohcl.ImpliedType(v)
 		if err != nil {
 			panic(err)
 		}
-		cv, err := gohcl.ToCtyValue(exports, ty)
+		cv, err := gohcl.ToCtyValue(v, ty)
 		if err != nil {
 			panic(err)
 		}
@@ -80,6 +83,19 @@ func (vc *valueCache) CacheExports(id ComponentID, exports component.Exports) {
 	vc.exports[nodeID] = exportsVal
 }
 
+// addressable returns a pointer to a copy of v if v isn't a pointer. Capsule
+// values, such as secrets, can only be converted into cty.Values from
+// addressable Go values.
+func addressable(v interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		return v
+	}
+	ptr := reflect.New(rv.Type())
+	ptr.Elem().Set(rv)
+	return ptr.Interface()
+}
+
 // SyncIDs will removed any cached values for any Component ID which is not in
 // ids. SyncIDs should be called with the current set of components after the
 // graph is updated.
@@ -107,7 +123,7 @@ func (vc *valueCache) SyncIDs(ids []ComponentID) {
 // object.
 func (vc *valueCache) BuildContext(parent *hcl.EvalContext) *hcl.EvalContext {
 	var ectx *hcl.EvalContext
-	if parent == nil {
+	if parent != nil {
 		ectx = parent.NewChild()
 	} else {
 		ectx = &hcl.EvalContext{}
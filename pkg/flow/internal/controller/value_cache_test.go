@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/grafana/agent/pkg/flow/hcltypes"
 	"github.com/stretchr/testify/require"
 	"github.com/zclconf/go-cty/cty"
 )
@@ -88,6 +89,24 @@ func TestValueCache(t *testing.T) {
 	requireCtyEqual(t, expectBar, res.Variables["bar"])
 }
 
+func TestValueCache_Secrets(t *testing.T) {
+	vc := newValueCache()
+
+	type fooArgs struct {
+		Password hcltypes.Secret `hcl:"password,attr"`
+	}
+
+	// Arguments holding capsule values must be cacheable when they're not
+	// passed as pointers.
+	require.NotPanics(t, func() {
+		vc.CacheArguments(ComponentID{"foo"}, fooArgs{Password: "hunter2"})
+	})
+
+	res := vc.BuildContext(nil)
+	password := res.Variables["foo"].GetAttr("password")
+	require.Equal(t, hcltypes.Secret("hunter2"), *password.EncapsulatedValue().(*hcltypes.Secret))
+}
+
 // requireCtyEqual requires a and b to be equal.
 func requireCtyEqual(t *testing.T, expect, actual cty.Value) {
 	t.Helper()
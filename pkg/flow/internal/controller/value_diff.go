@@ -0,0 +1,137 @@
+package controller
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// ValueDiff describes a difference between two values.
+type ValueDiff struct {
+	// Path to the differing value, such as `targets[0].labels`.
+	Path string
+	// Old and New hold the values rendered as HCL, with secrets redacted.
+	// They're empty if the value doesn't exist on that side.
+	Old, New string
+}
+
+// DiffValues compares the Go values a and b, which must be encodable to HCL
+// or nil, and returns the differences between them sorted by path. Nested
+// objects, maps, and lists are compared element by element. If one of the
+// values is nil, every attribute of the other value is reported.
+func DiffValues(a, b interface{}) []ValueDiff {
+	aVal, bVal := ctyValueOf(a), ctyValueOf(b)
+	if aVal == cty.NilVal {
+		aVal = cty.EmptyObjectVal
+	}
+	if bVal == cty.NilVal {
+		bVal = cty.EmptyObjectVal
+	}
+
+	var diffs []ValueDiff
+	diffValues(&diffs, "", aVal, bVal)
+	sort.SliceStable(diffs, func(i, j int) bool { return diffs[i].Path < diffs[j].Path })
+	return diffs
+}
+
+func diffValues(diffs *[]ValueDiff, path string, a, b cty.Value) {
+	switch {
+	case a == cty.NilVal && b == cty.NilVal:
+		return
+	case a == cty.NilVal || b == cty.NilVal:
+		*diffs = append(*diffs, ValueDiff{Path: path, Old: renderValue(a), New: renderValue(b)})
+		return
+	case valuesEqual(a, b):
+		return
+	}
+
+	aTy, bTy := a.Type(), b.Type()
+	switch {
+	case a.IsNull() || b.IsNull() || !a.IsKnown() || !b.IsKnown():
+		// Fall through to reporting the whole value.
+
+	case isMapLike(aTy) && isMapLike(bTy):
+		aElems, bElems := a.AsValueMap(), b.AsValueMap()
+		for k, av := range aElems {
+			diffValues(diffs, joinKey(path, k), av, bElems[k])
+		}
+		for k, bv := range bElems {
+			if _, ok := aElems[k]; !ok {
+				diffValues(diffs, joinKey(path, k), cty.NilVal, bv)
+			}
+		}
+		return
+
+	case isListLike(aTy) && isListLike(bTy):
+		aElems, bElems := a.AsValueSlice(), b.AsValueSlice()
+		for i := 0; i < len(aElems) || i < len(bElems); i++ {
+			av, bv := cty.NilVal, cty.NilVal
+			if i < len(aElems) {
+				av = aElems[i]
+			}
+			if i < len(bElems) {
+				bv = bElems[i]
+			}
+			diffValues(diffs, path+"["+strconv.Itoa(i)+"]", av, bv)
+		}
+		return
+	}
+
+	*diffs = append(*diffs, ValueDiff{Path: path, Old: renderValue(a), New: renderValue(b)})
+}
+
+// valuesEqual returns true if a and b are equal. Capsule values are equal if
+// the values they encapsulate are deeply equal.
+func valuesEqual(a, b cty.Value) bool {
+	if !a.Type().Equals(b.Type()) || a.IsNull() != b.IsNull() || a.IsKnown() != b.IsKnown() {
+		return false
+	} else if a.IsNull() || !a.IsKnown() {
+		return true
+	}
+
+	ty := a.Type()
+	switch {
+	case ty.IsCapsuleType():
+		return reflect.DeepEqual(a.EncapsulatedValue(), b.EncapsulatedValue())
+	case ty.IsPrimitiveType():
+		return a.RawEquals(b)
+	}
+
+	if a.LengthInt() != b.LengthInt() {
+		return false
+	}
+	for ait, bit := a.ElementIterator(), b.ElementIterator(); ait.Next() && bit.Next(); {
+		ak, av := ait.Element()
+		bk, bv := bit.Element()
+		if !valuesEqual(ak, bk) || !valuesEqual(av, bv) {
+			return false
+		}
+	}
+	return true
+}
+
+func isMapLike(ty cty.Type) bool  { return ty.IsObjectType() || ty.IsMapType() }
+func isListLike(ty cty.Type) bool { return ty.IsListType() || ty.IsTupleType() }
+
+// joinKey appends the object key k to path.
+func joinKey(path, k string) string {
+	if !hclsyntax.ValidIdentifier(k) {
+		return path + "[" + strconv.Quote(k) + "]"
+	} else if path == "" {
+		return k
+	}
+	return path + "." + k
+}
+
+// renderValue renders val as HCL with secrets redacted. An empty string is
+// returned for cty.NilVal.
+func renderValue(val cty.Value) string {
+	if val == cty.NilVal {
+		return ""
+	}
+	return strings.TrimSpace(string(tokensForValue(val).Bytes()))
+}
@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/grafana/agent/pkg/flow/hcltypes"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffValues(t *testing.T) {
+	type target struct {
+		Address string            `hcl:"address,attr"`
+		Labels  map[string]string `hcl:"labels,optional"`
+	}
+	type args struct {
+		Name     string          `hcl:"name,attr"`
+		Password hcltypes.Secret `hcl:"password,optional"`
+		Targets  []target        `hcl:"target,block"`
+	}
+
+	old := args{
+		Name:     "example",
+		Password: "hunter2",
+		Targets: []target{
+			{Address: "a:80", Labels: map[string]string{"env": "prod"}},
+			{Address: "b:80"},
+		},
+	}
+
+	t.Run("equal", func(t *testing.T) {
+		require.Empty(t, DiffValues(old, old))
+	})
+
+	t.Run("nested changes", func(t *testing.T) {
+		updated := args{
+			Name:     "example",
+			Password: "hunter2",
+			Targets: []target{
+				{Address: "a:80", Labels: map[string]string{"env": "dev", "team.name": "x"}},
+			},
+		}
+
+		require.Equal(t, []ValueDiff{
+			{Path: "target[0].labels.env", Old: `"prod"`, New: `"dev"`},
+			{Path: `target[0].labels["team.name"]`, New: `"x"`},
+			{Path: "target[1]", Old: `{
+  address = "b:80"
+  labels  = null
+}`},
+		}, DiffValues(old, updated))
+	})
+
+	t.Run("secrets are redacted", func(t *testing.T) {
+		updated := old
+		updated.Password = "hunter3"
+
+		require.Equal(t, []ValueDiff{
+			{Path: "password", Old: "(secret)", New: "(secret)"},
+		}, DiffValues(old, updated))
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		require.Equal(t, []ValueDiff{
+			{Path: "name", New: `"example"`},
+			{Path: "password", New: "(secret)"},
+			{Path: "target", New: `[{
+  address = "a:80"
+  labels = {
+    env = "prod"
+  }
+  }, {
+  address = "b:80"
+  labels  = null
+}]`},
+		}, DiffValues(nil, old))
+	})
+}
@@ -0,0 +1,79 @@
+package funcs
+
+import (
+	"encoding/base64"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/json"
+	"sigs.k8s.io/yaml"
+)
+
+// YAMLDecodeFunc parses a YAML string and returns the value it represents.
+// Like json_decode, the result consists only of primitive, object, and tuple
+// types.
+var YAMLDecodeFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{
+			Name: "str",
+			Type: cty.String,
+		},
+	},
+	Type: func(args []cty.Value) (cty.Type, error) {
+		str := args[0]
+		if !str.IsKnown() {
+			return cty.DynamicPseudoType, nil
+		}
+
+		buf, err := yaml.YAMLToJSON([]byte(str.AsString()))
+		if err != nil {
+			return cty.NilType, function.NewArgError(0, err)
+		}
+		return json.ImpliedType(buf)
+	},
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		buf, err := yaml.YAMLToJSON([]byte(args[0].AsString()))
+		if err != nil {
+			return cty.NilVal, function.NewArgError(0, err)
+		}
+		return json.Unmarshal(buf, retType)
+	},
+})
+
+// Base64EncodeFunc returns the standard base64 encoding of a string.
+var Base64EncodeFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{
+			Name: "str",
+			Type: cty.String,
+		},
+	},
+	Type: function.StaticReturnType(cty.String),
+	Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+		return cty.StringVal(base64.StdEncoding.EncodeToString([]byte(args[0].AsString()))), nil
+	},
+})
+
+// Base64DecodeFunc decodes a string encoded with standard base64 encoding.
+// The decoded bytes must be valid UTF-8.
+var Base64DecodeFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{
+			Name: "str",
+			Type: cty.String,
+		},
+	},
+	Type: function.StaticReturnType(cty.String),
+	Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+		buf, err := base64.StdEncoding.DecodeString(args[0].AsString())
+		if err != nil {
+			return cty.NilVal, function.NewArgError(0, fmt.Errorf("failed to decode base64: %w", err))
+		}
+		if !utf8.Valid(buf) {
+			return cty.NilVal, function.NewArgError(0, fmt.Errorf("decoded base64 is not valid UTF-8"))
+		}
+		return cty.StringVal(string(buf)), nil
+	},
+})
@@ -1,6 +1,8 @@
 package funcs_test
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/grafana/agent/pkg/flow/internal/funcs"
@@ -15,3 +17,54 @@ func TestEnvFunc(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, "HELLO_WORLD", res.AsString())
 }
+
+func TestYAMLDecodeFunc(t *testing.T) {
+	res, err := funcs.YAMLDecodeFunc.Call([]cty.Value{cty.StringVal(`
+name: agent
+ports: [80, 443]
+tls: true
+`)})
+	require.NoError(t, err)
+	expect := cty.ObjectVal(map[string]cty.Value{
+		"name":  cty.StringVal("agent"),
+		"ports": cty.TupleVal([]cty.Value{cty.NumberIntVal(80), cty.NumberIntVal(443)}),
+		"tls":   cty.True,
+	})
+	require.True(t, expect.Equals(res).True(), "unexpected result %#v", res)
+
+	_, err = funcs.YAMLDecodeFunc.Call([]cty.Value{cty.StringVal("a: [")})
+	require.Error(t, err)
+}
+
+func TestBase64Funcs(t *testing.T) {
+	enc, err := funcs.Base64EncodeFunc.Call([]cty.Value{cty.StringVal("hello, world")})
+	require.NoError(t, err)
+	require.Equal(t, "aGVsbG8sIHdvcmxk", enc.AsString())
+
+	dec, err := funcs.Base64DecodeFunc.Call([]cty.Value{enc})
+	require.NoError(t, err)
+	require.Equal(t, "hello, world", dec.AsString())
+
+	_, err = funcs.Base64DecodeFunc.Call([]cty.Value{cty.StringVal("not base64!")})
+	require.Error(t, err)
+	_, err = funcs.Base64DecodeFunc.Call([]cty.Value{cty.StringVal("/w==")})
+	require.EqualError(t, err, "decoded base64 is not valid UTF-8")
+}
+
+func TestSHA256Funcs(t *testing.T) {
+	const helloSum = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+	res, err := funcs.SHA256Func.Call([]cty.Value{cty.StringVal("hello")})
+	require.NoError(t, err)
+	require.Equal(t, helloSum, res.AsString())
+
+	path := filepath.Join(t.TempDir(), "hello.txt")
+	require.NoError(t, os.WriteFile(path, []byte("hello"), 0644))
+
+	res, err = funcs.FileSHA256Func.Call([]cty.Value{cty.StringVal(path)})
+	require.NoError(t, err)
+	require.Equal(t, helloSum, res.AsString())
+
+	_, err = funcs.FileSHA256Func.Call([]cty.Value{cty.StringVal(filepath.Join(t.TempDir(), "missing"))})
+	require.Error(t, err)
+}
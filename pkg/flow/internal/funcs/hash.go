@@ -0,0 +1,46 @@
+package funcs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// SHA256Func returns the hex-encoded SHA-256 hash of a string.
+var SHA256Func = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{
+			Name: "str",
+			Type: cty.String,
+		},
+	},
+	Type: function.StaticReturnType(cty.String),
+	Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+		sum := sha256.Sum256([]byte(args[0].AsString()))
+		return cty.StringVal(hex.EncodeToString(sum[:])), nil
+	},
+})
+
+// FileSHA256Func returns the hex-encoded SHA-256 hash of the contents of a
+// file. The file is read each time the expression calling it is evaluated.
+var FileSHA256Func = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{
+			Name: "path",
+			Type: cty.String,
+		},
+	},
+	Type: function.StaticReturnType(cty.String),
+	Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+		buf, err := os.ReadFile(args[0].AsString())
+		if err != nil {
+			return cty.NilVal, function.NewArgError(0, fmt.Errorf("failed to read file: %w", err))
+		}
+		sum := sha256.Sum256(buf)
+		return cty.StringVal(hex.EncodeToString(sum[:])), nil
+	},
+})
@@ -0,0 +1,119 @@
+package funcs
+
+import (
+	"fmt"
+
+	"github.com/grafana/agent/pkg/flow/hcltypes"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// PropagateSecrets wraps f so that it can be called with secrets. Secrets
+// passed to f are revealed before calling f, and strings in the result of
+// such calls are converted into secrets. This prevents functions from
+// leaking secrets into values which aren't secrets.
+//
+// Calls with secrets which would return numbers or bools fail, since their
+// results can't be secrets and could be used to guess the value of a secret.
+func PropagateSecrets(f function.Function) function.Function {
+	spec := &function.Spec{
+		Params: make([]function.Parameter, 0, len(f.Params())),
+		Type: func(args []cty.Value) (cty.Type, error) {
+			revealed, secret, err := revealArgs(f, args)
+			if err != nil {
+				return cty.NilType, err
+			}
+			ty, err := f.ReturnTypeForValues(revealed)
+			if err != nil || !secret {
+				return ty, err
+			}
+			return concealType(ty)
+		},
+		Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+			revealed, secret, err := revealArgs(f, args)
+			if err != nil {
+				return cty.NilVal, err
+			}
+			res, err := f.Call(revealed)
+			if err != nil || !secret {
+				return res, err
+			}
+			return hcltypes.Conceal(res)
+		},
+	}
+
+	// Secrets can't be converted into the types of most parameters, so
+	// parameters accept any value and are converted after revealing secrets.
+	for _, p := range f.Params() {
+		spec.Params = append(spec.Params, dynamicParam(p))
+	}
+	if vp := f.VarParam(); vp != nil {
+		p := dynamicParam(*vp)
+		spec.VarParam = &p
+	}
+	return function.New(spec)
+}
+
+func dynamicParam(p function.Parameter) function.Parameter {
+	return function.Parameter{
+		Name:             p.Name,
+		Type:             cty.DynamicPseudoType,
+		AllowNull:        true,
+		AllowUnknown:     true,
+		AllowDynamicType: true,
+		AllowMarked:      p.AllowMarked,
+	}
+}
+
+// revealArgs reveals the secrets in args and converts them into the types
+// of the parameters of f. secret reports whether any args held secrets.
+func revealArgs(f function.Function, args []cty.Value) (revealed []cty.Value, secret bool, err error) {
+	revealed = make([]cty.Value, 0, len(args))
+	for i, arg := range args {
+		v, s := hcltypes.Reveal(arg)
+		secret = secret || s
+
+		p := f.VarParam()
+		if i < len(f.Params()) {
+			p = &f.Params()[i]
+		}
+		if p != nil && p.Type != cty.DynamicPseudoType {
+			if v, err = convert.Convert(v, p.Type); err != nil {
+				return nil, false, function.NewArgError(i, err)
+			}
+		}
+		revealed = append(revealed, v)
+	}
+	return revealed, secret, nil
+}
+
+func concealType(ty cty.Type) (cty.Type, error) {
+	res, err := hcltypes.ConcealType(ty)
+	if err != nil {
+		return cty.NilType, fmt.Errorf("%w; use nonsensitive() to reveal the secret first", err)
+	}
+	return res, nil
+}
+
+// NonsensitiveFunc reveals the secrets in its argument, allowing them to be
+// used where secrets aren't accepted.
+var NonsensitiveFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{
+			Name:             "value",
+			Type:             cty.DynamicPseudoType,
+			AllowNull:        true,
+			AllowUnknown:     true,
+			AllowDynamicType: true,
+		},
+	},
+	Type: func(args []cty.Value) (cty.Type, error) {
+		v, _ := hcltypes.Reveal(args[0])
+		return v.Type(), nil
+	},
+	Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+		v, _ := hcltypes.Reveal(args[0])
+		return v, nil
+	},
+})
@@ -0,0 +1,53 @@
+package funcs_test
+
+import (
+	"testing"
+
+	"github.com/grafana/agent/pkg/flow/hcltypes"
+	"github.com/grafana/agent/pkg/flow/internal/funcs"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+)
+
+func secretVal(t *testing.T, s string) cty.Value {
+	t.Helper()
+	v, err := hcltypes.Conceal(cty.StringVal(s))
+	require.NoError(t, err)
+	return v
+}
+
+func TestPropagateSecrets(t *testing.T) {
+	format := funcs.PropagateSecrets(stdlib.FormatFunc)
+
+	t.Run("without secrets", func(t *testing.T) {
+		res, err := format.Call([]cty.Value{cty.StringVal("%s!"), cty.StringVal("hello")})
+		require.NoError(t, err)
+		require.Equal(t, cty.StringVal("hello!"), res)
+	})
+
+	t.Run("with secrets", func(t *testing.T) {
+		res, err := format.Call([]cty.Value{cty.StringVal("%v"), secretVal(t, "hunter2")})
+		require.NoError(t, err)
+
+		_, err = convert.Convert(res, cty.String)
+		require.Error(t, err, "result must not be usable as a string")
+
+		revealed, secret := hcltypes.Reveal(res)
+		require.True(t, secret)
+		require.Equal(t, cty.StringVal("hunter2"), revealed)
+	})
+
+	t.Run("numbers derived from secrets", func(t *testing.T) {
+		strlen := funcs.PropagateSecrets(stdlib.StrlenFunc)
+		_, err := strlen.Call([]cty.Value{secretVal(t, "hunter2")})
+		require.ErrorContains(t, err, "nonsensitive()")
+	})
+}
+
+func TestNonsensitiveFunc(t *testing.T) {
+	res, err := funcs.NonsensitiveFunc.Call([]cty.Value{secretVal(t, "hunter2")})
+	require.NoError(t, err)
+	require.Equal(t, cty.StringVal("hunter2"), res)
+}
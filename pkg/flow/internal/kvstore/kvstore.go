@@ -0,0 +1,155 @@
+// Package kvstore implements component.Store on top of a bbolt database which
+// is shared by all components of a Flow controller. Each component is given
+// its own bucket in the database, named after its ID.
+package kvstore
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/grafana/agent/component"
+	bolt "go.etcd.io/bbolt"
+)
+
+// openTimeout is how long to wait for the lock on the database file before
+// giving up, such as when another process is using the same data path.
+const openTimeout = 5 * time.Second
+
+// DB is a bbolt database holding the stores of all components. The database
+// file isn't created until a store is used for the first time.
+type DB struct {
+	path string
+
+	mut sync.Mutex
+	db  *bolt.DB
+}
+
+// New returns a new DB which stores data in the file at path.
+func New(path string) *DB {
+	return &DB{path: path}
+}
+
+// Store returns the store for the component with the given ID.
+func (d *DB) Store(id string) component.Store {
+	return &store{db: d, bucket: []byte(id)}
+}
+
+// get returns the underlying database, opening it if it isn't open yet.
+func (d *DB) get() (*bolt.DB, error) {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	if d.db != nil {
+		return d.db, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(d.path), 0770); err != nil {
+		return nil, fmt.Errorf("creating directory for component store: %w", err)
+	}
+	db, err := bolt.Open(d.path, 0660, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("opening component store %s: %w", d.path, err)
+	}
+	d.db = db
+	return db, nil
+}
+
+// Close closes the database if it was opened. Stores may not be used after
+// calling Close.
+func (d *DB) Close() error {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	if d.db == nil {
+		return nil
+	}
+	err := d.db.Close()
+	d.db = nil
+	return err
+}
+
+// store implements component.Store for a single bucket.
+type store struct {
+	db     *DB
+	bucket []byte
+}
+
+var _ component.Store = (*store)(nil)
+
+// Get implements component.Store.
+func (s *store) Get(key string) (value []byte, found bool, err error) {
+	db, err := s.db.get()
+	if err != nil {
+		return nil, false, err
+	}
+
+	err = db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(s.bucket)
+		if b == nil {
+			return nil
+		}
+		// Values are only valid for the lifetime of the transaction, so they
+		// must be copied.
+		if v := b.Get([]byte(key)); v != nil {
+			value, found = append([]byte{}, v...), true
+		}
+		return nil
+	})
+	return value, found, err
+}
+
+// Set implements component.Store.
+func (s *store) Set(key string, value []byte) error {
+	db, err := s.db.get()
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(s.bucket)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(key), value)
+	})
+}
+
+// Delete implements component.Store.
+func (s *store) Delete(key string) error {
+	db, err := s.db.get()
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(s.bucket)
+		if b == nil {
+			return nil
+		}
+		return b.Delete([]byte(key))
+	})
+}
+
+// Keys implements component.Store.
+func (s *store) Keys() ([]string, error) {
+	db, err := s.db.get()
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	err = db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(s.bucket)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, _ []byte) error {
+			keys = append(keys, string(k))
+			return nil
+		})
+	})
+	return keys, err
+}
@@ -0,0 +1,83 @@
+package kvstore
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	db := New(filepath.Join(t.TempDir(), "kv.db"))
+	defer db.Close()
+
+	s := db.Store("local.file.a")
+
+	_, found, err := s.Get("position")
+	require.NoError(t, err)
+	require.False(t, found, "key should not exist before being set")
+
+	require.NoError(t, s.Set("position", []byte("10")))
+	require.NoError(t, s.Set("empty", nil))
+
+	value, found, err := s.Get("position")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, []byte("10"), value)
+
+	value, found, err = s.Get("empty")
+	require.NoError(t, err)
+	require.True(t, found, "keys with empty values should exist")
+	require.Empty(t, value)
+
+	keys, err := s.Keys()
+	require.NoError(t, err)
+	require.Equal(t, []string{"empty", "position"}, keys)
+
+	require.NoError(t, s.Delete("position"))
+	require.NoError(t, s.Delete("missing"))
+	_, found, err = s.Get("position")
+	require.NoError(t, err)
+	require.False(t, found, "key should not exist after being deleted")
+}
+
+func TestStore_Isolated(t *testing.T) {
+	db := New(filepath.Join(t.TempDir(), "kv.db"))
+	defer db.Close()
+
+	a, b := db.Store("local.file.a"), db.Store("local.file.b")
+	require.NoError(t, a.Set("position", []byte("10")))
+
+	_, found, err := b.Get("position")
+	require.NoError(t, err)
+	require.False(t, found, "stores should not share keys")
+
+	keys, err := b.Keys()
+	require.NoError(t, err)
+	require.Empty(t, keys)
+	require.NoError(t, b.Delete("position"))
+}
+
+func TestStore_Persisted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "kv.db")
+
+	db := New(path)
+	require.NoError(t, db.Store("local.file.a").Set("position", []byte("10")))
+	require.NoError(t, db.Close())
+
+	db = New(path)
+	defer db.Close()
+
+	value, found, err := db.Store("local.file.a").Get("position")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, []byte("10"), value)
+}
+
+func TestDB_CloseUnused(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kv.db")
+
+	db := New(path)
+	require.NoError(t, db.Close())
+	require.NoFileExists(t, path, "database should only be created once used")
+}
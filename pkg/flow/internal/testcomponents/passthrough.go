@@ -2,10 +2,13 @@ package testcomponents
 
 import (
 	"context"
+	"fmt"
+	"net/http"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/grafana/agent/component"
+	"go.uber.org/atomic"
 )
 
 func init() {
@@ -34,8 +37,9 @@ type PassthroughExports struct {
 // Passthrough implements the testcomponents.passthrough component, where it
 // always emits its input as an output.
 type Passthrough struct {
-	opts component.Options
-	log  log.Logger
+	opts   component.Options
+	log    log.Logger
+	output atomic.String
 }
 
 // NewPassthrough creates a new passthrough component.
@@ -50,6 +54,7 @@ func NewPassthrough(o component.Options, cfg PassthroughConfig) (*Passthrough, e
 var (
 	_ component.Component      = (*Passthrough)(nil)
 	_ component.DebugComponent = (*Passthrough)(nil)
+	_ component.HTTPComponent  = (*Passthrough)(nil)
 )
 
 // Run implements Component.
@@ -63,6 +68,7 @@ func (t *Passthrough) Update(args component.Arguments) error {
 	c := args.(PassthroughConfig)
 
 	level.Info(t.log).Log("msg", "passing through value", "value", c.Input)
+	t.output.Store(c.Input)
 	t.opts.OnStateChange(PassthroughExports{Output: c.Input})
 	return nil
 }
@@ -78,6 +84,16 @@ func (t *Passthrough) DebugInfo() interface{} {
 	}
 }
 
+// Handler implements HTTPComponent. GET /output returns the current output of
+// the component.
+func (t *Passthrough) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/output", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, t.output.Load())
+	})
+	return mux
+}
+
 type passthroughDebugInfo struct {
 	ComponentVersion string `hcl:"component_version"`
 }
@@ -17,6 +17,13 @@ func init() {
 		Args:    TickConfig{},
 		Exports: TickExports{},
 
+		Validate: func(args component.Arguments) error {
+			if args.(TickConfig).Frequency < 0 {
+				return component.ArgumentError{Name: "frequency", Message: "must not be negative"}
+			}
+			return nil
+		},
+
 		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
 			return NewTick(opts, args.(TickConfig))
 		},
@@ -0,0 +1,264 @@
+package flow
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"sort"
+
+	"github.com/grafana/agent/component"
+	"github.com/grafana/agent/pkg/flow/internal/kvstore"
+	"github.com/grafana/agent/pkg/flow/logging"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/rfratto/gohcl"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// argumentBlockSchema is the schema of argument blocks. An argument block
+// declares an argument of a module, which components of the module may
+// reference with argument.NAME.value:
+//
+//	argument "receivers" {
+//	  comment = "Where to send processed logs."
+//	}
+//
+//	argument "job" {
+//	  optional = true
+//	  default  = "integrations/module"
+//	}
+var argumentBlockSchema = hcl.BlockHeaderSchema{
+	Type:       "argument",
+	LabelNames: []string{"name"},
+}
+
+// argumentBlock and exportBlock hold expressions rather than values, since
+// gohcl can't decode capsule values (such as secrets) into a cty.Value.
+type argumentBlock struct {
+	Optional bool           `hcl:"optional,optional"`
+	Default  hcl.Expression `hcl:"default,optional"`
+	Comment  string         `hcl:"comment,optional"`
+}
+
+// exportBlockSchema is the schema of export blocks. An export block exposes a
+// value to the component which loaded the module:
+//
+//	export "receiver" {
+//	  value = loki.encrypt.default.receiver
+//	}
+var exportBlockSchema = hcl.BlockHeaderSchema{
+	Type:       "export",
+	LabelNames: []string{"name"},
+}
+
+type exportBlock struct {
+	Value hcl.Expression `hcl:"value,attr"`
+}
+
+// argumentsEvalContext returns a child of parent which exposes the values of
+// the arguments declared by blocks as argument.NAME.value. Optional arguments
+// missing from args are set to their default value.
+func argumentsEvalContext(parent *hcl.EvalContext, blocks hcl.Blocks, args map[string]cty.Value) (*hcl.EvalContext, hcl.Diagnostics) {
+	var (
+		diags    hcl.Diagnostics
+		values   = make(map[string]cty.Value, len(blocks))
+		declared = make(map[string]struct{}, len(blocks))
+	)
+
+	for _, block := range blocks {
+		name := block.Labels[0]
+		declared[name] = struct{}{}
+
+		// Defaults may use functions but can't reference components.
+		var arg argumentBlock
+		if decodeDiags := gohcl.DecodeBody(block.Body, parent, &arg); decodeDiags.HasErrors() {
+			diags = diags.Extend(decodeDiags)
+			continue
+		}
+
+		value, ok := args[name]
+		switch {
+		case ok:
+		case arg.Optional:
+			var valueDiags hcl.Diagnostics
+			value, valueDiags = arg.Default.Value(parent)
+			if valueDiags.HasErrors() {
+				diags = diags.Extend(valueDiags)
+				continue
+			}
+		default:
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  fmt.Sprintf("Missing required argument %s", name),
+				Detail:   fmt.Sprintf("A value must be provided for the argument %s of the module.", name),
+				Subject:  block.DefRange.Ptr(),
+			})
+			continue
+		}
+		if value == cty.NilVal {
+			value = cty.NullVal(cty.DynamicPseudoType)
+		}
+		values[name] = cty.ObjectVal(map[string]cty.Value{"value": value})
+	}
+
+	// Sort unsupported arguments so diagnostics are reported in a stable order.
+	var unsupported []string
+	for name := range args {
+		if _, ok := declared[name]; !ok {
+			unsupported = append(unsupported, name)
+		}
+	}
+	sort.Strings(unsupported)
+	for _, name := range unsupported {
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  fmt.Sprintf("Unsupported argument %s", name),
+			Detail:   fmt.Sprintf("The module doesn't declare an argument named %s.", name),
+		})
+	}
+
+	ectx := parent.NewChild()
+	ectx.Variables = map[string]cty.Value{
+		argumentBlockSchema.Type: cty.ObjectVal(values),
+	}
+	return ectx, diags
+}
+
+// evaluateExports evaluates export blocks against ectx, returning the value
+// of each export by name.
+func evaluateExports(ectx *hcl.EvalContext, blocks hcl.Blocks) (map[string]cty.Value, hcl.Diagnostics) {
+	var (
+		diags   hcl.Diagnostics
+		exports = make(map[string]cty.Value, len(blocks))
+	)
+
+	for _, block := range blocks {
+		var export exportBlock
+		if decodeDiags := gohcl.DecodeBody(block.Body, ectx, &export); decodeDiags.HasErrors() {
+			diags = diags.Extend(decodeDiags)
+			continue
+		}
+		value, valueDiags := export.Value.Value(ectx)
+		if valueDiags.HasErrors() {
+			diags = diags.Extend(valueDiags)
+			continue
+		}
+		exports[block.Labels[0]] = value
+	}
+	return exports, diags
+}
+
+// exportsEqual returns true if a and b hold the same exports.
+func exportsEqual(a, b map[string]cty.Value) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, av := range a {
+		bv, ok := b[name]
+		if !ok || !av.RawEquals(bv) {
+			return false
+		}
+	}
+	return true
+}
+
+// module implements component.Module by running a nested controller.
+type module struct {
+	id   string
+	ctrl *Flow
+}
+
+var _ component.Module = (*module)(nil)
+
+// newModule creates a new module with the given ID. The components of the
+// module share the logger, metrics, data directory, and component stores of
+// parent.
+func newModule(parent controllerOptions, id string, onExportsChange component.ModuleExportsFunc) *module {
+	return &module{
+		id: id,
+		ctrl: newController(controllerOptions{
+			Options:         parent.Options,
+			ID:              id,
+			KV:              parent.KV,
+			OnExportsChange: onExportsChange,
+		}),
+	}
+}
+
+// LoadConfig implements component.Module. The logging block of config is
+// ignored, since the logger is shared with the root controller.
+func (m *module) LoadConfig(config []byte, args map[string]cty.Value) error {
+	f, diags := ReadFile(m.id, config)
+	if diags.HasErrors() {
+		return diags
+	}
+
+	m.ctrl.loadMut.Lock()
+	defer m.ctrl.loadMut.Unlock()
+	return m.ctrl.load(f, args)
+}
+
+// LoadBody implements component.Module.
+func (m *module) LoadBody(body hcl.Body, args map[string]cty.Value) error {
+	f, diags := readHCLFile(m.id, &hcl.File{Body: body})
+	if diags.HasErrors() {
+		return diags
+	}
+
+	m.ctrl.loadMut.Lock()
+	defer m.ctrl.loadMut.Unlock()
+	return m.ctrl.load(f, args)
+}
+
+// Run implements component.Module. The metrics of the module's components are
+// unregistered once they stop running, so that a module with the same ID may
+// be created again.
+func (m *module) Run(ctx context.Context) error {
+	m.ctrl.run(ctx)
+	err := m.ctrl.sched.Close()
+	m.ctrl.loader.UnregisterMetrics()
+	return err
+}
+
+// ComponentHandler implements component.Module.
+func (m *module) ComponentHandler() http.Handler {
+	return m.ctrl.componentHandler("/")
+}
+
+// NewModule creates a module which runs outside of a parent controller, such
+// as in a worker process of the module.process component. Its components are
+// given IDs prefixed by id, as if the module was created by the component
+// with that ID. The stores of its components are kept in the data path of o.
+func NewModule(o Options, id string, onExportsChange component.ModuleExportsFunc) component.Module {
+	if o.Logger == nil {
+		var err error
+		o.Logger, err = logging.New(io.Discard, logging.DefaultOptions)
+		if err != nil {
+			// This shouldn't happen unless there's a bug
+			panic(err)
+		}
+	}
+
+	kv := kvstore.New(filepath.Join(o.DataPath, kvFilename))
+	return &standaloneModule{
+		module: newModule(controllerOptions{Options: o, KV: kv}, id, onExportsChange),
+		kv:     kv,
+	}
+}
+
+// standaloneModule is a module which owns the stores of its components.
+type standaloneModule struct {
+	*module
+	kv *kvstore.DB
+}
+
+// Run implements component.Module. Stores are closed once the components of
+// the module stop running.
+func (m *standaloneModule) Run(ctx context.Context) error {
+	err := m.module.Run(ctx)
+	if kvErr := m.kv.Close(); err == nil {
+		err = kvErr
+	}
+	return err
+}
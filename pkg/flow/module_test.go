@@ -0,0 +1,143 @@
+package flow
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/pkg/flow/internal/testcomponents"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+var moduleFile = `
+	argument "input" {
+		comment = "Value to pass through."
+	}
+
+	argument "suffix" {
+		optional = true
+		default  = "!"
+	}
+
+	testcomponents "passthrough" "input" {
+		input = format("%s%s", argument.input.value, argument.suffix.value)
+	}
+
+	export "output" {
+		value = testcomponents.passthrough.input.output
+	}
+`
+
+func TestModule(t *testing.T) {
+	exports := make(chan map[string]cty.Value, 10)
+	m := newTestModule(t, func(e map[string]cty.Value) { exports <- e })
+
+	err := m.LoadConfig([]byte(moduleFile), map[string]cty.Value{
+		"input": cty.StringVal("hello"),
+	})
+	require.NoError(t, err)
+	requireExport(t, exports, "output", cty.StringVal("hello!"))
+
+	// Components of the module are reachable through the module's handler.
+	rec := httptest.NewRecorder()
+	m.ComponentHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/testcomponents.passthrough.input/output", nil))
+	body, _ := io.ReadAll(rec.Body)
+	require.Equal(t, "hello!", string(body))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = m.Run(ctx) }()
+
+	err = m.LoadConfig([]byte(moduleFile), map[string]cty.Value{
+		"input":  cty.StringVal("bye"),
+		"suffix": cty.StringVal("?"),
+	})
+	require.NoError(t, err)
+	requireExport(t, exports, "output", cty.StringVal("bye?"))
+
+	// Invalid arguments roll the module back to its previous config.
+	err = m.LoadConfig([]byte(moduleFile), nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Missing required argument input")
+	_, out := getFields(t, m.ctrl.loader.Graph(), "testcomponents.passthrough.input")
+	require.Equal(t, "bye?", out.(testcomponents.PassthroughExports).Output)
+}
+
+func TestModule_ExportsUpdate(t *testing.T) {
+	exports := make(chan map[string]cty.Value, 10)
+	m := newTestModule(t, func(e map[string]cty.Value) { exports <- e })
+
+	err := m.LoadConfig([]byte(`
+		testcomponents "tick" "ticker" {
+			frequency = "10ms"
+		}
+
+		export "tick_time" {
+			value = testcomponents.tick.ticker.tick_time
+		}
+	`), nil)
+	require.NoError(t, err)
+	first := <-exports
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = m.Run(ctx) }()
+
+	// Exports are reevaluated whenever components of the module update their
+	// exports.
+	select {
+	case e := <-exports:
+		require.False(t, e["tick_time"].RawEquals(first["tick_time"]))
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out waiting for exports to change")
+	}
+}
+
+func TestModule_Arguments(t *testing.T) {
+	tt := []struct {
+		name string
+		args map[string]cty.Value
+		err  string
+	}{
+		{
+			name: "missing required argument",
+			args: map[string]cty.Value{"suffix": cty.StringVal("?")},
+			err:  "Missing required argument input",
+		},
+		{
+			name: "unsupported argument",
+			args: map[string]cty.Value{"input": cty.StringVal("hello"), "other": cty.True},
+			err:  "Unsupported argument other",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			m := newTestModule(t, nil)
+			err := m.LoadConfig([]byte(moduleFile), tc.args)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.err)
+		})
+	}
+}
+
+func newTestModule(t *testing.T, onExportsChange func(map[string]cty.Value)) *module {
+	t.Helper()
+
+	root, _ := newFlow(testOptions(t))
+	return newModule(controllerOptions{Options: root.opts, KV: root.kv}, "module.test", onExportsChange)
+}
+
+func requireExport(t *testing.T, exports chan map[string]cty.Value, name string, expect cty.Value) {
+	t.Helper()
+
+	select {
+	case e := <-exports:
+		require.True(t, expect.RawEquals(e[name]), "expected %#v, got %#v", expect, e[name])
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out waiting for exports")
+	}
+}
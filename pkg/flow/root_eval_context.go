@@ -18,6 +18,8 @@ var rootEvalContext = &hcl.EvalContext{
 
 	Functions: map[string]function.Function{
 		"abs":           stdlib.AbsoluteFunc,
+		"base64_decode": funcs.Base64DecodeFunc,
+		"base64_encode": funcs.Base64EncodeFunc,
 		"ceil":          stdlib.CeilFunc,
 		"chomp":         stdlib.ChompFunc,
 		"coalesce_list": stdlib.CoalesceListFunc,
@@ -29,6 +31,7 @@ var rootEvalContext = &hcl.EvalContext{
 		"element":       stdlib.ElementFunc,
 		"env":           funcs.EnvFunc,
 		"chunk_list":    stdlib.ChunklistFunc,
+		"file_sha256":   funcs.FileSHA256Func,
 		"flatten":       stdlib.FlattenFunc,
 		"floor":         stdlib.FloorFunc,
 		"format":        stdlib.FormatFunc,
@@ -42,6 +45,7 @@ var rootEvalContext = &hcl.EvalContext{
 		"json_decode":      stdlib.JSONDecodeFunc,
 		"json_encode":      stdlib.JSONEncodeFunc,
 		"keys":             stdlib.KeysFunc,
+		"length":           stdlib.LengthFunc,
 		"log":              stdlib.LogFunc,
 		"lower":            stdlib.LowerFunc,
 		"max":              stdlib.MaxFunc,
@@ -52,11 +56,14 @@ var rootEvalContext = &hcl.EvalContext{
 		"range":            stdlib.RangeFunc,
 		"regex":            stdlib.RegexFunc,
 		"regex_all":        stdlib.RegexAllFunc,
+		"regex_replace":    stdlib.RegexReplaceFunc,
+		"replace":          stdlib.ReplaceFunc,
 		"reverse":          stdlib.ReverseListFunc,
 		"set_intersection": stdlib.SetIntersectionFunc,
 		"set_product":      stdlib.SetProductFunc,
 		"set_subtract":     stdlib.SetSubtractFunc,
 		"set_union":        stdlib.SetUnionFunc,
+		"sha256":           funcs.SHA256Func,
 		"number_sign":      stdlib.SignumFunc,
 		"slice":            stdlib.SliceFunc,
 		"sort":             stdlib.SortFunc,
@@ -71,6 +78,17 @@ var rootEvalContext = &hcl.EvalContext{
 		"trim_suffix":      stdlib.TrimSuffixFunc,
 		"upper":            stdlib.UpperFunc,
 		"values":           stdlib.ValuesFunc,
+		"yaml_decode":      funcs.YAMLDecodeFunc,
 		"zipmap":           stdlib.ZipmapFunc,
 	},
 }
+
+func init() {
+	// Functions may be called with secrets, so results derived from secrets
+	// must be secrets themselves. nonsensitive is the only function which may
+	// reveal secrets.
+	for name, f := range rootEvalContext.Functions {
+		rootEvalContext.Functions[name] = funcs.PropagateSecrets(f)
+	}
+	rootEvalContext.Functions["nonsensitive"] = funcs.NonsensitiveFunc
+}
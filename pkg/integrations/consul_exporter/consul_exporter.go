@@ -11,6 +11,7 @@ import (
 	integrations_v2 "github.com/grafana/agent/pkg/integrations/v2"
 	"github.com/grafana/agent/pkg/integrations/v2/metricsutils"
 	consul_api "github.com/hashicorp/consul/api"
+	config_util "github.com/prometheus/common/config"
 	"github.com/prometheus/consul_exporter/pkg/exporter"
 )
 
@@ -36,6 +37,10 @@ type Config struct {
 	AllowStale         bool          `yaml:"allow_stale,omitempty"`
 	RequireConsistent  bool          `yaml:"require_consistent,omitempty"`
 
+	// ACLToken is sent with every request to a Consul server with ACLs
+	// enabled.
+	ACLToken config_util.Secret `yaml:"acl_token,omitempty"`
+
 	KVPrefix      string `yaml:"kv_prefix,omitempty"`
 	KVFilter      string `yaml:"kv_filter,omitempty"`
 	HealthSummary bool   `yaml:"generate_health_summary,omitempty"`
@@ -90,6 +95,7 @@ func New(log log.Logger, c *Config) (integrations.Integration, error) {
 		queryOptions = consul_api.QueryOptions{
 			AllowStale:        c.AllowStale,
 			RequireConsistent: c.RequireConsistent,
+			Token:             string(c.ACLToken),
 		}
 	)
 
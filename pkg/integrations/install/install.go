@@ -33,6 +33,14 @@ import (
 	_ "github.com/grafana/agent/pkg/integrations/v2/agent" // register agent
 	_ "github.com/grafana/agent/pkg/integrations/v2/apache_http"
 	_ "github.com/grafana/agent/pkg/integrations/v2/app_agent_receiver" // register app_agent_receiver
+	_ "github.com/grafana/agent/pkg/integrations/v2/blackbox_exporter"  // register blackbox_exporter
+	_ "github.com/grafana/agent/pkg/integrations/v2/ceph"               // register ceph
+	_ "github.com/grafana/agent/pkg/integrations/v2/cloudwatch"         // register cloudwatch
 	_ "github.com/grafana/agent/pkg/integrations/v2/eventhandler"
+	_ "github.com/grafana/agent/pkg/integrations/v2/nomad"    // register nomad
+	_ "github.com/grafana/agent/pkg/integrations/v2/oracledb" // register oracledb
+	_ "github.com/grafana/agent/pkg/integrations/v2/plugin"   // register plugin
+	_ "github.com/grafana/agent/pkg/integrations/v2/redfish"  // register redfish
 	_ "github.com/grafana/agent/pkg/integrations/v2/snmp_exporter"
+	_ "github.com/grafana/agent/pkg/integrations/v2/zfs" // register zfs
 )
@@ -0,0 +1,177 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	snmp_config "github.com/prometheus/snmp_exporter/config"
+	"gopkg.in/yaml.v2"
+)
+
+// modulesPollInterval is how often the config file is reread, in case
+// filesystem events are missed.
+var modulesPollInterval = time.Minute
+
+// Modules holds the SNMP modules used to walk targets. Modules are read from
+// a config file generated by the snmp_exporter generator, or from the
+// embedded config when no file is given.
+type Modules struct {
+	log  log.Logger
+	path string
+
+	mut     sync.RWMutex
+	modules snmp_config.Config
+	content []byte // Content of the config file the modules were read from
+}
+
+// LoadModules loads the modules of the config file at path. The embedded
+// modules are loaded if path is empty.
+func LoadModules(l log.Logger, path string) (*Modules, error) {
+	m := &Modules{log: l, path: path}
+	if path == "" {
+		modules, err := LoadEmbeddedConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load embedded snmp config: %w", err)
+		}
+		m.modules = *modules
+		return m, nil
+	}
+
+	if _, err := m.Reload(); err != nil {
+		return nil, fmt.Errorf("failed to load snmp config from file %v: %w", path, err)
+	}
+	return m, nil
+}
+
+// Get returns a copy of the module name, which may be modified.
+func (m *Modules) Get(name string) (*snmp_config.Module, bool) {
+	m.mut.RLock()
+	defer m.mut.RUnlock()
+
+	module, ok := m.modules[name]
+	if !ok {
+		return nil, false
+	}
+	cp := *module
+	return &cp, true
+}
+
+// Reload rereads the config file. It returns true if the modules changed.
+// The previous modules are kept if the file is invalid.
+func (m *Modules) Reload() (bool, error) {
+	if m.path == "" {
+		return false, nil
+	}
+
+	content, err := os.ReadFile(m.path)
+	if err != nil {
+		return false, err
+	}
+
+	m.mut.RLock()
+	unchanged := m.modules != nil && bytes.Equal(content, m.content)
+	m.mut.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	modules := snmp_config.Config{}
+	if err := yaml.UnmarshalStrict(content, &modules); err != nil {
+		return false, err
+	}
+
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	m.modules, m.content = modules, content
+	return true, nil
+}
+
+// Watch reloads the config file when it changes until ctx is canceled. The
+// parent directory of the file is watched so that files replaced by
+// swapping symlinks, such as mounted Kubernetes ConfigMaps, are reloaded.
+func (m *Modules) Watch(ctx context.Context) {
+	if m.path == "" {
+		<-ctx.Done()
+		return
+	}
+
+	var (
+		events <-chan fsnotify.Event
+		errs   <-chan error
+	)
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		level.Warn(m.log).Log("msg", "failed to watch snmp config file, falling back to polling", "err", err)
+	} else {
+		defer w.Close()
+		if err := w.Add(filepath.Dir(m.path)); err != nil {
+			level.Warn(m.log).Log("msg", "failed to watch snmp config file, falling back to polling", "err", err)
+		}
+		events, errs = w.Events, w.Errors
+	}
+
+	t := time.NewTicker(modulesPollInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-events:
+		case err := <-errs:
+			// The error may be related to the file, so it's reread anyway.
+			level.Warn(m.log).Log("msg", "error watching snmp config file", "err", err)
+		case <-t.C:
+		}
+
+		changed, err := m.Reload()
+		if err != nil {
+			level.Error(m.log).Log("msg", "failed to reload snmp config file, keeping the previous modules", "file", m.path, "err", err)
+		} else if changed {
+			level.Info(m.log).Log("msg", "reloaded snmp config file", "file", m.path)
+		}
+	}
+}
+
+// WalkLimiter limits the number of concurrent walks of SNMP targets. A nil
+// WalkLimiter doesn't limit walks.
+type WalkLimiter chan struct{}
+
+// NewWalkLimiter creates a WalkLimiter allowing max concurrent walks. Walks
+// aren't limited if max isn't positive.
+func NewWalkLimiter(max int) WalkLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return make(WalkLimiter, max)
+}
+
+// Acquire waits until a walk may start, or until ctx is canceled. Release
+// must be called when the walk finishes if Acquire succeeded.
+func (l WalkLimiter) Acquire(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	select {
+	case l <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Release marks a walk as finished.
+func (l WalkLimiter) Release() {
+	if l == nil {
+		return
+	}
+	<-l
+}
@@ -0,0 +1,81 @@
+package common
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+)
+
+const testModules = `
+test_mib:
+  walk: [1.3.6.1.2.1.2]
+`
+
+func TestModules_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snmp.yml")
+	require.NoError(t, os.WriteFile(path, []byte(testModules), 0600))
+
+	m, err := LoadModules(log.NewNopLogger(), path)
+	require.NoError(t, err)
+	_, ok := m.Get("test_mib")
+	require.True(t, ok)
+
+	changed, err := m.Reload()
+	require.NoError(t, err)
+	require.False(t, changed, "unchanged file should not be reloaded")
+
+	require.NoError(t, os.WriteFile(path, []byte("new_mib:\n  walk: [1.3.6.1.2.1.31]\n"), 0600))
+	changed, err = m.Reload()
+	require.NoError(t, err)
+	require.True(t, changed)
+	_, ok = m.Get("test_mib")
+	require.False(t, ok)
+	_, ok = m.Get("new_mib")
+	require.True(t, ok)
+
+	// Invalid files keep the previous modules.
+	require.NoError(t, os.WriteFile(path, []byte("new_mib: [invalid"), 0600))
+	_, err = m.Reload()
+	require.Error(t, err)
+	_, ok = m.Get("new_mib")
+	require.True(t, ok)
+}
+
+func TestModules_Embedded(t *testing.T) {
+	m, err := LoadModules(log.NewNopLogger(), "")
+	require.NoError(t, err)
+
+	// Modules are copied so that walk params overridden by a scrape don't
+	// leak into other scrapes.
+	module, ok := m.Get("if_mib")
+	require.True(t, ok)
+	module.WalkParams.Retries = 42
+
+	module, ok = m.Get("if_mib")
+	require.True(t, ok)
+	require.NotEqual(t, 42, module.WalkParams.Retries)
+}
+
+func TestWalkLimiter(t *testing.T) {
+	l := NewWalkLimiter(1)
+	require.NoError(t, l.Acquire(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, l.Acquire(ctx), context.DeadlineExceeded)
+
+	l.Release()
+	require.NoError(t, l.Acquire(context.Background()))
+	l.Release()
+
+	// A nil limiter never blocks.
+	var unlimited WalkLimiter = NewWalkLimiter(0)
+	require.Nil(t, unlimited)
+	require.NoError(t, unlimited.Acquire(ctx))
+	unlimited.Release()
+}
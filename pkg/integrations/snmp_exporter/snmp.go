@@ -7,15 +7,16 @@ import (
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	snmp_common "github.com/grafana/agent/pkg/integrations/snmp_exporter/common"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/prometheus/snmp_exporter/collector"
-	snmp_config "github.com/prometheus/snmp_exporter/config"
 )
 
 type snmpHandler struct {
 	cfg     *Config
-	modules *snmp_config.Config
+	modules *snmp_common.Modules
+	walks   snmp_common.WalkLimiter
 	log     log.Logger
 }
 
@@ -52,7 +53,7 @@ func (sh *snmpHandler) handler(w http.ResponseWriter, r *http.Request) {
 		moduleName = "if_mib"
 	}
 
-	module, ok := (*sh.modules)[moduleName]
+	module, ok := sh.modules.Get(moduleName)
 	if !ok {
 		http.Error(w, fmt.Sprintf("Unknown module '%s'", moduleName), 400)
 		return
@@ -91,6 +92,12 @@ func (sh *snmpHandler) handler(w http.ResponseWriter, r *http.Request) {
 	}
 	level.Debug(logger).Log("msg", "Starting scrape")
 
+	if err := sh.walks.Acquire(r.Context()); err != nil {
+		http.Error(w, "timed out waiting for other SNMP walks to finish", http.StatusServiceUnavailable)
+		return
+	}
+	defer sh.walks.Release()
+
 	start := time.Now()
 	registry := prometheus.NewRegistry()
 	c := collector.New(r.Context(), target, module, logger)
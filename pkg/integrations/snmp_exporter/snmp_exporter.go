@@ -34,6 +34,9 @@ type Config struct {
 	WalkParams     map[string]snmp_config.WalkParams `yaml:"walk_params,omitempty"`
 	SnmpConfigFile string                            `yaml:"config_file,omitempty"`
 	SnmpTargets    []SNMPTarget                      `yaml:"snmp_targets"`
+	// MaxConcurrentWalks limits the number of targets walked at the same
+	// time. Walks aren't limited when zero.
+	MaxConcurrentWalks int `yaml:"max_concurrent_walks,omitempty"`
 }
 
 // UnmarshalYAML implements yaml.Unmarshaler for Config.
@@ -65,18 +68,9 @@ func init() {
 
 // New creates a new snmp_exporter integration
 func New(log log.Logger, c *Config) (integrations.Integration, error) {
-	var modules *snmp_config.Config
-	var err error
-	if c.SnmpConfigFile != "" {
-		modules, err = snmp_config.LoadFile(c.SnmpConfigFile)
-		if err != nil {
-			return nil, fmt.Errorf("failed to load snmp config from file %v: %w", c.SnmpConfigFile, err)
-		}
-	} else {
-		modules, err = snmp_common.LoadEmbeddedConfig()
-		if err != nil {
-			return nil, fmt.Errorf("failed to load embedded snmp config: %w", err)
-		}
+	modules, err := snmp_common.LoadModules(log, c.SnmpConfigFile)
+	if err != nil {
+		return nil, err
 	}
 
 	// The `name` and `address` fields are mandatory for the SNMP targets are mandatory.
@@ -90,6 +84,7 @@ func New(log log.Logger, c *Config) (integrations.Integration, error) {
 	sh := &snmpHandler{
 		cfg:     c,
 		modules: modules,
+		walks:   snmp_common.NewWalkLimiter(c.MaxConcurrentWalks),
 		log:     log,
 	}
 	integration := &Integration{
@@ -110,11 +105,10 @@ func (i *Integration) MetricsHandler() (http.Handler, error) {
 	return i.sh, nil
 }
 
-// Run satisfies Integration.Run.
+// Run satisfies Integration.Run. The SNMP config file is reloaded when it
+// changes.
 func (i *Integration) Run(ctx context.Context) error {
-	// We don't need to do anything here, so we can just wait for the context to
-	// finish.
-	<-ctx.Done()
+	i.sh.modules.Watch(ctx)
 	return ctx.Err()
 }
 
@@ -3,7 +3,9 @@ package app_agent_receiver //nolint:golint
 import (
 	"context"
 	"fmt"
+	"io"
 	"net/http"
+	"path"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
@@ -22,6 +24,7 @@ import (
 type appAgentReceiverIntegration struct {
 	integrations.MetricsIntegration
 	appAgentReceiverHandler AppAgentReceiverHandler
+	sourcemapStore          *RealSourceMapStore
 	logger                  log.Logger
 	conf                    *Config
 	reg                     prometheus.Registerer
@@ -47,59 +50,161 @@ func (c *Config) NewIntegration(l log.Logger, globals integrations.Globals) (int
 
 	receiverMetricsExporter := NewReceiverMetricsExporter(reg)
 
-	var exp = []appAgentReceiverExporter{
+	var exp = []AppAgentReceiverExporter{
 		receiverMetricsExporter,
 	}
 
-	if len(c.LogsInstance) > 0 {
-		getLogsInstance := func() (logsInstance, error) {
-			instance := globals.Logs.Instance(c.LogsInstance)
+	if c.MeasurementMetrics != nil {
+		exp = append(exp, NewMeasurementMetricsExporter(*c.MeasurementMetrics, reg))
+	}
+
+	// Exporters appended below send payloads to other services and are
+	// queued on disk if enabled
+	downstream := len(exp)
+
+	var appLogsInstances, appTracesInstances bool
+	for _, app := range c.Apps {
+		appLogsInstances = appLogsInstances || app.LogsInstance != ""
+		appTracesInstances = appTracesInstances || app.TracesInstance != ""
+	}
+
+	var typeLogsInstances []string
+	if c.PayloadTypes != nil {
+		typeLogsInstances = c.PayloadTypes.LogsInstances()
+	}
+
+	if len(c.LogsInstance) > 0 || appLogsInstances || len(typeLogsInstances) > 0 {
+		getNamedLogsInstance := func(name string) (LogsInstance, error) {
+			instance := globals.Logs.Instance(name)
 			if instance == nil {
-				return nil, fmt.Errorf("logs instance \"%s\" not found", c.LogsInstance)
+				return nil, fmt.Errorf("logs instance \"%s\" not found", name)
 			}
 			return instance, nil
 		}
+		getLogsInstance := func(ctx context.Context, payloadType string) (LogsInstance, error) {
+			name := c.LogsInstance
+			if c.PayloadTypes != nil {
+				if typeName := c.PayloadTypes.LogsInstance(payloadType); typeName != "" {
+					name = typeName
+				}
+			}
+			if app := appFromContext(ctx); app != nil && app.LogsInstance != "" {
+				name = app.LogsInstance
+			}
+			if name == "" {
+				return nil, nil
+			}
+			return getNamedLogsInstance(name)
+		}
 
-		if _, err := getLogsInstance(); err != nil {
-			return nil, err
+		if len(c.LogsInstance) > 0 {
+			if _, err := getNamedLogsInstance(c.LogsInstance); err != nil {
+				return nil, err
+			}
+		}
+		for _, app := range c.Apps {
+			if app.LogsInstance == "" {
+				continue
+			}
+			if _, err := getNamedLogsInstance(app.LogsInstance); err != nil {
+				return nil, err
+			}
+		}
+		for _, name := range typeLogsInstances {
+			if _, err := getNamedLogsInstance(name); err != nil {
+				return nil, err
+			}
 		}
 
 		lokiExporter := NewLogsExporter(
 			l,
 			LogsExporterConfig{
-				GetLogsInstance:  getLogsInstance,
-				Labels:           c.LogsLabels,
-				SendEntryTimeout: c.LogsSendTimeout,
+				GetLogsInstance:   getLogsInstance,
+				Labels:            c.LogsLabels,
+				SendEntryTimeout:  c.LogsSendTimeout,
+				ExceptionGrouping: c.ExceptionGrouping,
 			},
 			sourcemapStore,
 		)
 		exp = append(exp, lokiExporter)
 	}
 
-	if len(c.TracesInstance) > 0 {
-		getTracesConsumer := func() (consumer.Traces, error) {
-			tracesInstance := globals.Tracing.Instance(c.TracesInstance)
+	if len(c.TracesInstance) > 0 || len(c.TracesRoutes) > 0 || appTracesInstances {
+		getTracesConsumer := func(name string) (consumer.Traces, error) {
+			tracesInstance := globals.Tracing.Instance(name)
 			if tracesInstance == nil {
-				return nil, fmt.Errorf("traces instance \"%s\" not found", c.TracesInstance)
+				return nil, fmt.Errorf("traces instance \"%s\" not found", name)
 			}
 			factory := tracesInstance.GetFactory(component.KindReceiver, pushreceiver.TypeStr)
 			if factory == nil {
-				return nil, fmt.Errorf("push receiver factory not found for traces instance \"%s\"", c.TracesInstance)
+				return nil, fmt.Errorf("push receiver factory not found for traces instance \"%s\"", name)
 			}
 			consumer := factory.(*pushreceiver.Factory).Consumer
 			if consumer == nil {
-				return nil, fmt.Errorf("consumer not set for push receiver factory on traces instance \"%s\"", c.TracesInstance)
+				return nil, fmt.Errorf("consumer not set for push receiver factory on traces instance \"%s\"", name)
 			}
 			return consumer, nil
 		}
-		if _, err := getTracesConsumer(); err != nil {
-			return nil, err
+		router := &tracesRouter{
+			routes:          c.TracesRoutes,
+			apps:            c.Apps,
+			defaultInstance: c.TracesInstance,
+			getConsumer:     getTracesConsumer,
 		}
-		tracesExporter := NewTracesExporter(getTracesConsumer)
+		for _, name := range router.Instances() {
+			if _, err := getTracesConsumer(name); err != nil {
+				return nil, err
+			}
+		}
+		tracesExporter := NewTracesExporter(router.Consumer, c.ErrorSampling)
 		exp = append(exp, tracesExporter)
 	}
 
+	if c.OTLP != nil {
+		otlpExporter, err := NewOTLPExporter(l, *c.OTLP, sourcemapStore, c.ExceptionGrouping)
+		if err != nil {
+			return nil, err
+		}
+		exp = append(exp, otlpExporter)
+	}
+
+	if c.Kafka != nil {
+		kafkaExporter, err := NewKafkaExporter(l, *c.Kafka, sourcemapStore, c.ExceptionGrouping)
+		if err != nil {
+			return nil, err
+		}
+		exp = append(exp, kafkaExporter)
+	}
+
+	if c.Queue != nil {
+		metrics := newQueueMetrics(reg)
+		for idx := downstream; idx < len(exp); idx++ {
+			queued, err := newQueuedExporter(l, exp[idx], *c.Queue, c.Apps, metrics)
+			if err != nil {
+				return nil, err
+			}
+			exp[idx] = queued
+		}
+	}
+
 	handler := NewAppAgentReceiverHandler(c, exp, reg)
+	if c.Scrubbing != nil {
+		scrubber, err := newScrubber(*c.Scrubbing)
+		if err != nil {
+			return nil, err
+		}
+		handler.scrubber = scrubber
+	}
+	if c.GeoIP != nil {
+		geo, err := newGeoIPEnricher(l, *c.GeoIP, reg)
+		if err != nil {
+			return nil, err
+		}
+		handler.geo = geo
+	}
+	if c.UserAgent != nil {
+		handler.userAgent = newUserAgentEnricher(*c.UserAgent)
+	}
 
 	metricsIntegration, err := metricsutils.NewMetricsHandlerIntegration(l, c, c.Common, globals, promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
 	if err != nil {
@@ -136,6 +241,7 @@ func (c *Config) NewIntegration(l log.Logger, globals integrations.Globals) (int
 	return &appAgentReceiverIntegration{
 		MetricsIntegration:      metricsIntegration,
 		appAgentReceiverHandler: handler,
+		sourcemapStore:          sourcemapStore,
 		logger:                  l,
 		conf:                    c,
 		reg:                     reg,
@@ -147,10 +253,28 @@ func (c *Config) NewIntegration(l log.Logger, globals integrations.Globals) (int
 	}, nil
 }
 
+// Handler implements HTTPIntegration. Besides metrics, payload statistics
+// are served under prefix/stats when they are enabled
+func (i *appAgentReceiverIntegration) Handler(prefix string) (http.Handler, error) {
+	metricsHandler, err := i.MetricsIntegration.Handler(prefix)
+	if err != nil || i.appAgentReceiverHandler.stats == nil {
+		return metricsHandler, err
+	}
+
+	r := mux.NewRouter()
+	r.Handle(path.Join(prefix, "stats"), i.appAgentReceiverHandler.stats).Methods("GET")
+	r.PathPrefix(prefix).Handler(metricsHandler)
+	return r, nil
+}
+
 // RunIntegration implements Integration
 func (i *appAgentReceiverIntegration) RunIntegration(ctx context.Context) error {
 	r := mux.NewRouter()
 	r.Handle("/collect", i.appAgentReceiverHandler.HTTPHandler(i.logger)).Methods("POST", "OPTIONS")
+	r.Handle("/collect/batch", i.appAgentReceiverHandler.BatchHTTPHandler(i.logger)).Methods("POST", "OPTIONS")
+	if h := i.sourcemapStore.UploadHandler(); h != nil {
+		r.Handle("/sourcemaps/{app}/{release}/{path:.+}", h).Methods("PUT")
+	}
 
 	mw := middleware.Instrument{
 		RouteMatcher:     r,
@@ -160,6 +284,19 @@ func (i *appAgentReceiverIntegration) RunIntegration(ctx context.Context) error
 		InflightRequests: i.inflightRequestsCollector,
 	}
 
+	defer i.closeExporters()
+
+	if geo := i.appAgentReceiverHandler.geo; geo != nil {
+		defer geo.Close()
+		go geo.Run(ctx)
+	}
+
+	for _, exp := range i.appAgentReceiverHandler.exporters {
+		if q, ok := exp.(*queuedExporter); ok {
+			go q.Run(ctx)
+		}
+	}
+
 	srv := &http.Server{
 		Addr:    fmt.Sprintf("%s:%d", i.conf.Server.Host, i.conf.Server.Port),
 		Handler: mw.Wrap(r),
@@ -186,6 +323,17 @@ func (i *appAgentReceiverIntegration) RunIntegration(ctx context.Context) error
 	return nil
 }
 
+// closeExporters closes the exporters which hold connections
+func (i *appAgentReceiverIntegration) closeExporters() {
+	for _, exp := range i.appAgentReceiverHandler.exporters {
+		if c, ok := exp.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				level.Warn(i.logger).Log("msg", "failed to close exporter", "exporter", exp.Name(), "err", err)
+			}
+		}
+	}
+}
+
 func init() {
 	integrations.Register(&Config{}, integrations.TypeMultiplex)
 }
@@ -0,0 +1,63 @@
+package app_agent_receiver
+
+import (
+	"context"
+	"crypto/subtle"
+	"strings"
+)
+
+// appContextKey is the context key of the app configuration matching the API
+// key a payload was sent with
+type appContextKey struct{}
+
+// contextWithApp returns a copy of ctx holding app
+func contextWithApp(ctx context.Context, app *AppConfig) context.Context {
+	return context.WithValue(ctx, appContextKey{}, app)
+}
+
+// appFromContext returns the app configuration held by ctx, or nil
+func appFromContext(ctx context.Context) *AppConfig {
+	app, _ := ctx.Value(appContextKey{}).(*AppConfig)
+	return app
+}
+
+// appForAPIKey returns the configuration of the app with the API key key, or
+// nil if no app uses it. Every key is compared in constant time
+func appForAPIKey(apps []AppConfig, key string) *AppConfig {
+	var app *AppConfig
+	for i := range apps {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(apps[i].APIKey)) == 1 {
+			app = &apps[i]
+		}
+	}
+	return app
+}
+
+// originAllowed returns true if origin matches one of allowed. Allowed
+// origins may be "*" or contain a single "*" wildcard, like
+// "https://*.example.com"
+func originAllowed(origin string, allowed []string) bool {
+	origin = strings.ToLower(origin)
+	for _, a := range allowed {
+		a = strings.ToLower(a)
+		if a == "*" || a == origin {
+			return true
+		}
+		if i := strings.IndexByte(a, '*'); i >= 0 && origin != "" {
+			prefix, suffix := a[:i], a[i+1:]
+			if len(origin) >= len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// corsAllowedOrigins returns the origins allowed by the server and by any app
+func corsAllowedOrigins(conf *Config) []string {
+	origins := append([]string(nil), conf.Server.CORSAllowedOrigins...)
+	for _, app := range conf.Apps {
+		origins = append(origins, app.CORSAllowedOrigins...)
+	}
+	return origins
+}
@@ -0,0 +1,120 @@
+package app_agent_receiver
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestOriginAllowed(t *testing.T) {
+	allowed := []string{"https://shop.example.com", "https://*.example.org"}
+
+	require.True(t, originAllowed("https://shop.example.com", allowed))
+	require.True(t, originAllowed("https://Blog.example.org", allowed))
+	require.False(t, originAllowed("https://example.org", allowed))
+	require.False(t, originAllowed("https://evil.com", allowed))
+	require.False(t, originAllowed("", allowed))
+	require.True(t, originAllowed("https://evil.com", []string{"*"}))
+}
+
+func TestHandler_Apps(t *testing.T) {
+	payload := loadTestData(t, "payload.json") // Sent by the app "testapp"
+
+	conf := &Config{
+		Server: ServerConfig{APIKey: "main-key", MaxAllowedPayloadSize: 1e6},
+		Apps: []AppConfig{
+			{Name: "testapp", APIKey: "testapp-key", CORSAllowedOrigins: []string{"https://testapp.example.com"}},
+			{Name: "shop", APIKey: "shop-key"},
+			{Name: "tiny", APIKey: "tiny-key", MaxAllowedPayloadSize: 10},
+		},
+	}
+	exporter := &TestExporter{name: "exporter"}
+	fr := NewAppAgentReceiverHandler(conf, []AppAgentReceiverExporter{exporter}, prometheus.NewRegistry())
+	handler := fr.HTTPHandler(nil)
+
+	send := func(apiKey, origin string) int {
+		req := httptest.NewRequest("POST", "/collect", bytes.NewBuffer(payload))
+		req.Header.Set(apiKeyHeader, apiKey)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Result().StatusCode
+	}
+
+	require.Equal(t, http.StatusAccepted, send("testapp-key", "https://testapp.example.com"))
+	require.Equal(t, http.StatusAccepted, send("main-key", "https://testapp.example.com"))
+	require.Equal(t, http.StatusForbidden, send("testapp-key", "https://evil.com"))
+	require.Equal(t, http.StatusForbidden, send("testapp-key", ""))
+	// The key of another app can't be used to send payloads of testapp.
+	require.Equal(t, http.StatusForbidden, send("shop-key", ""))
+	require.Equal(t, http.StatusRequestEntityTooLarge, send("tiny-key", ""))
+	require.Equal(t, http.StatusUnauthorized, send("other-key", ""))
+
+	require.Len(t, exporter.payloads, 2)
+}
+
+func TestHandler_AppsCORS(t *testing.T) {
+	conf := &Config{
+		Apps: []AppConfig{
+			{Name: "testapp", APIKey: "testapp-key", CORSAllowedOrigins: []string{"https://testapp.example.com"}},
+		},
+	}
+	fr := NewAppAgentReceiverHandler(conf, nil, prometheus.NewRegistry())
+
+	req := httptest.NewRequest("OPTIONS", "/collect", nil)
+	req.Header.Set("Origin", "https://testapp.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	rr := httptest.NewRecorder()
+	fr.HTTPHandler(nil).ServeHTTP(rr, req)
+
+	require.Equal(t, "https://testapp.example.com", rr.Result().Header.Get("Access-Control-Allow-Origin"))
+}
+
+func TestTracesRouter_Apps(t *testing.T) {
+	router, consumers := newTestTracesRouter("default")
+	router.apps = []AppConfig{{Name: "cart", APIKey: "cart-key", TracesInstance: "partner"}}
+
+	ctx := contextWithApp(context.Background(), &router.apps[0])
+	c, err := router.Consumer(ctx, Payload{Meta: Meta{App: App{Name: "cart"}}})
+	require.NoError(t, err)
+	require.Same(t, consumers["partner"], c)
+	require.Equal(t, []string{"default", "checkout", "partner", "partner"}, router.Instances())
+}
+
+func TestConfig_Apps(t *testing.T) {
+	var cfg Config
+	cb := `
+apps:
+  - name: shop
+    api_key: shop-key
+    cors_allowed_origins: [https://shop.example.com]
+    logs_instance: shop`
+	require.NoError(t, yaml.Unmarshal([]byte(cb), &cfg))
+	require.Equal(t, []AppConfig{{
+		Name:               "shop",
+		APIKey:             "shop-key",
+		CORSAllowedOrigins: []string{"https://shop.example.com"},
+		LogsInstance:       "shop",
+	}}, cfg.Apps)
+
+	cb = `
+apps:
+  - name: shop`
+	require.EqualError(t, yaml.Unmarshal([]byte(cb), &cfg), "apps[0]: name and api_key must be set")
+
+	cb = `
+apps:
+  - name: shop
+    api_key: key
+  - name: blog
+    api_key: key`
+	require.EqualError(t, yaml.Unmarshal([]byte(cb), &cfg), `apps[1]: api_key of app "blog" is used by another app`)
+}
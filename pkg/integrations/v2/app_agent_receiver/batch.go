@@ -0,0 +1,79 @@
+package app_agent_receiver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-kit/log"
+)
+
+// batchResponse is the response of the batch endpoint, holding the result
+// of every payload of the batch in order
+type batchResponse struct {
+	Results []payloadResult `json:"results"`
+}
+
+// BatchHTTPHandler is the http.Handler of the batch endpoint, which accepts
+// a JSON array of payloads or newline delimited JSON payloads in a single
+// request. The request is checked like requests to HTTPHandler, then every
+// payload is processed on its own. It responds with 200 and the status of
+// every payload, so that a payload which is rejected doesn't fail the batch
+func (ar *AppAgentReceiverHandler) BatchHTTPHandler(logger log.Logger) http.Handler {
+	return ar.withCORS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, ok := ar.readRequest(w, r)
+		if !ok {
+			return
+		}
+
+		items, err := splitBatch(req.body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if max := ar.config.Server.MaxBatchSize; max > 0 && len(items) > max {
+			http.Error(w, fmt.Sprintf("batch of %d payloads exceeds the max of %d", len(items), max), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		res := batchResponse{Results: make([]payloadResult, 0, len(items))}
+		for _, item := range items {
+			res.Results = append(res.Results, ar.processPayload(req, logger, item))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(res)
+	}))
+}
+
+// splitBatch splits body into the raw payloads of a batch. body is either a
+// JSON array of payloads or newline delimited JSON payloads. Payloads of
+// newline delimited batches are not validated, so that an invalid payload
+// only fails itself
+func splitBatch(body []byte) ([][]byte, error) {
+	body = bytes.TrimSpace(body)
+
+	var items [][]byte
+	if bytes.HasPrefix(body, []byte("[")) {
+		var array []json.RawMessage
+		if err := json.Unmarshal(body, &array); err != nil {
+			return nil, fmt.Errorf("invalid batch: %w", err)
+		}
+		for _, item := range array {
+			items = append(items, item)
+		}
+	} else {
+		for _, line := range bytes.Split(body, []byte("\n")) {
+			if line = bytes.TrimSpace(line); len(line) > 0 {
+				items = append(items, line)
+			}
+		}
+	}
+
+	if len(items) == 0 {
+		return nil, fmt.Errorf("batch contains no payloads")
+	}
+	return items, nil
+}
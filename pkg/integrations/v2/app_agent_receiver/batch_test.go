@@ -0,0 +1,75 @@
+package app_agent_receiver
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitBatch(t *testing.T) {
+	items, err := splitBatch([]byte(` [{"logs": []}, {"exceptions": []}] `))
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte(`{"logs": []}`), []byte(`{"exceptions": []}`)}, items)
+
+	items, err = splitBatch([]byte("{\"logs\": []}\n\n{invalid}\r\n"))
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte(`{"logs": []}`), []byte(`{invalid}`)}, items)
+
+	_, err = splitBatch([]byte(`[{"logs": []}`))
+	require.Error(t, err)
+	_, err = splitBatch([]byte("\n"))
+	require.EqualError(t, err, "batch contains no payloads")
+}
+
+func TestBatchHTTPHandler(t *testing.T) {
+	var payload bytes.Buffer
+	require.NoError(t, json.Compact(&payload, loadTestData(t, "payload.json")))
+
+	conf := &Config{
+		Server: ServerConfig{MaxBatchSize: 3},
+		Apps:   []AppConfig{{Name: "shop", APIKey: "shop-key"}},
+	}
+	exporter := &TestExporter{name: "exporter"}
+	fr := NewAppAgentReceiverHandler(conf, []AppAgentReceiverExporter{exporter}, prometheus.NewRegistry())
+	handler := fr.BatchHTTPHandler(nil)
+
+	send := func(apiKey string, items ...string) *http.Response {
+		req := httptest.NewRequest("POST", "/collect/batch", strings.NewReader(strings.Join(items, "\n")))
+		req.Header.Set(apiKeyHeader, apiKey)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Result()
+	}
+
+	resp := send("", payload.String(), "{invalid", `{"meta": {}}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var res batchResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+	require.Len(t, res.Results, 3)
+	require.Equal(t, http.StatusAccepted, res.Results[0].Status)
+	require.Equal(t, http.StatusBadRequest, res.Results[1].Status)
+	require.NotEmpty(t, res.Results[1].Error)
+	require.Equal(t, http.StatusAccepted, res.Results[2].Status)
+	require.Len(t, exporter.payloads, 2)
+
+	// The payload of testapp is rejected with the key of shop, the other
+	// payload is attributed to shop.
+	resp = send("shop-key", payload.String(), `{"meta": {}}`)
+	res = batchResponse{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+	require.Equal(t, []payloadResult{
+		{Status: http.StatusForbidden, Error: "api key not valid for app"},
+		{Status: http.StatusAccepted},
+	}, res.Results)
+	require.Equal(t, "shop", exporter.payloads[2].Meta.App.Name)
+
+	resp = send("", "{}", "{}", "{}", "{}")
+	require.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+}
@@ -1,10 +1,14 @@
 package app_agent_receiver
 
 import (
+	"fmt"
+	"path/filepath"
+	"text/template"
 	"time"
 
 	"github.com/grafana/agent/pkg/integrations/v2"
 	"github.com/grafana/agent/pkg/integrations/v2/common"
+	"github.com/prometheus/common/config"
 )
 
 const (
@@ -16,6 +20,11 @@ const (
 	DefaultRateLimitingBurstiness = 50
 	// DefaultMaxPayloadSize is the max paylad size in bytes
 	DefaultMaxPayloadSize = 5e6
+	// DefaultMaxSourceMapSize is the max size in bytes of downloaded
+	// sourcemaps and compiled sources
+	DefaultMaxSourceMapSize = 20 << 20
+	// DefaultMaxBatchSize is the max number of payloads in a batch
+	DefaultMaxBatchSize = 100
 )
 
 // DefaultConfig holds the default configuration of the receiver
@@ -31,12 +40,14 @@ var DefaultConfig = Config{
 			Burstiness: DefaultRateLimitingBurstiness,
 		},
 		MaxAllowedPayloadSize: DefaultMaxPayloadSize,
+		MaxBatchSize:          DefaultMaxBatchSize,
 	},
 	LogsLabels:      map[string]string{},
 	LogsSendTimeout: time.Second * 2,
 	SourceMaps: SourceMapConfig{
 		DownloadFromOrigins: []string{"*"},
 		DownloadTimeout:     time.Second,
+		MaxDownloadSize:     DefaultMaxSourceMapSize,
 	},
 }
 
@@ -48,6 +59,9 @@ type ServerConfig struct {
 	RateLimiting          RateLimitingConfig `yaml:"rate_limiting,omitempty"`
 	APIKey                string             `yaml:"api_key,omitempty"`
 	MaxAllowedPayloadSize int64              `yaml:"max_allowed_payload_size,omitempty"`
+	// MaxBatchSize is the max number of payloads in a request to the batch
+	// endpoint. Batches are not limited if 0
+	MaxBatchSize int `yaml:"max_batch_size,omitempty"`
 }
 
 // RateLimitingConfig holds the configuration of the rate limiter
@@ -55,6 +69,74 @@ type RateLimitingConfig struct {
 	Enabled    bool    `yaml:"enabled,omitempty"`
 	RPS        float64 `yaml:"rps,omitempty"`
 	Burstiness int     `yaml:"burstiness,omitempty"`
+
+	// PerClient limits each client individually, in addition to the limit
+	// shared by all clients. Clients are not limited individually if nil
+	PerClient *ClientRateLimitingConfig `yaml:"per_client,omitempty"`
+}
+
+// ClientKey identifies the client of a request for rate limiting
+type ClientKey string
+
+// Supported client keys
+const (
+	// ClientKeyIP identifies clients by their IP address
+	ClientKeyIP ClientKey = "ip"
+	// ClientKeyAPIKey identifies clients by the API key they send, falling
+	// back to their IP address for requests without a known API key
+	ClientKeyAPIKey ClientKey = "api_key"
+)
+
+// DefaultClientRateLimitingConfig holds the default values of a
+// ClientRateLimitingConfig
+var DefaultClientRateLimitingConfig = ClientRateLimitingConfig{
+	Key:        ClientKeyIP,
+	RPS:        10,
+	Burstiness: 20,
+	MaxClients: 10000,
+}
+
+// ClientRateLimitingConfig holds the rate limits of a single client. A zero
+// RPS or BytesPerSecond means that dimension is unlimited
+type ClientRateLimitingConfig struct {
+	Key ClientKey `yaml:"key,omitempty"`
+	// TrustForwardedFor uses the last address of the X-Forwarded-For header
+	// as the IP address of the client, for receivers behind a proxy
+	TrustForwardedFor bool `yaml:"trust_forwarded_for,omitempty"`
+
+	RPS        float64 `yaml:"rps,omitempty"`
+	Burstiness int     `yaml:"burstiness,omitempty"`
+
+	// BytesPerSecond limits the payload bytes received from the client.
+	// BytesBurstiness defaults to BytesPerSecond
+	BytesPerSecond  float64 `yaml:"bytes_per_second,omitempty"`
+	BytesBurstiness int     `yaml:"bytes_burstiness,omitempty"`
+
+	// MaxClients is the number of clients tracked at once. The least recently
+	// seen clients are forgotten once more clients are seen
+	MaxClients int `yaml:"max_clients,omitempty"`
+}
+
+// UnmarshalYAML implements the Unmarshaler interface
+func (c *ClientRateLimitingConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultClientRateLimitingConfig
+	type plain ClientRateLimitingConfig
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	switch c.Key {
+	case ClientKeyIP, ClientKeyAPIKey:
+	default:
+		return fmt.Errorf("invalid per_client key %q, expected ip or api_key", c.Key)
+	}
+	if c.RPS < 0 || c.BytesPerSecond < 0 {
+		return fmt.Errorf("rps and bytes_per_second must not be negative")
+	}
+	if c.MaxClients <= 0 {
+		return fmt.Errorf("max_clients must be greater than 0")
+	}
+	return nil
 }
 
 // SourceMapFileLocation holds sourcemap location on file system
@@ -69,6 +151,219 @@ type SourceMapConfig struct {
 	DownloadFromOrigins []string                `yaml:"download_origins,omitempty"`
 	DownloadTimeout     time.Duration           `yaml:"download_timeout,omitempty"`
 	FileSystem          []SourceMapFileLocation `yaml:"filesystem,omitempty"`
+	Remote              []SourceMapURLLocation  `yaml:"remote,omitempty"`
+	// MaxDownloadSize limits the size in bytes of downloaded sourcemaps and
+	// compiled sources. Downloads are not limited if 0
+	MaxDownloadSize int64 `yaml:"max_download_size,omitempty"`
+
+	// Upload enables the sourcemap upload API if set
+	Upload *SourceMapUploadConfig `yaml:"upload,omitempty"`
+}
+
+// SourceMapUploadConfig configures the API for uploading sourcemaps, which
+// stores sourcemaps under Path keyed by app name and release. Path defaults to
+// a subdirectory of the data path of the integration
+type SourceMapUploadConfig struct {
+	Path    string `yaml:"path"`
+	APIKey  string `yaml:"api_key"`
+	MaxSize int64  `yaml:"max_size,omitempty"`
+}
+
+// UnmarshalYAML implements the Unmarshaler interface
+func (c *SourceMapUploadConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = SourceMapUploadConfig{MaxSize: DefaultMaxSourceMapSize}
+	type plain SourceMapUploadConfig
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	// The upload API must not be usable with the API key of the collect
+	// endpoint, which is embedded in frontend apps
+	if c.APIKey == "" {
+		return fmt.Errorf("sourcemap upload api_key must not be empty")
+	}
+	return nil
+}
+
+// SourceMapURLLocation holds sourcemap location on an HTTP server, such as an
+// artifact store
+type SourceMapURLLocation struct {
+	URL                string `yaml:"url"`
+	MinifiedPathPrefix string `yaml:"minified_path_prefix,omitempty"`
+
+	HTTPClientConfig config.HTTPClientConfig `yaml:",inline"`
+}
+
+// UnmarshalYAML implements the Unmarshaler interface
+func (l *SourceMapURLLocation) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*l = SourceMapURLLocation{HTTPClientConfig: config.DefaultHTTPClientConfig}
+	type plain SourceMapURLLocation
+	if err := unmarshal((*plain)(l)); err != nil {
+		return err
+	}
+
+	if l.URL == "" {
+		return fmt.Errorf("sourcemap remote location url must not be empty")
+	}
+	if _, err := template.New(l.URL).Parse(l.URL); err != nil {
+		return fmt.Errorf("invalid sourcemap remote location url: %w", err)
+	}
+	return l.HTTPClientConfig.Validate()
+}
+
+// BudgetPeriod is the window over which an ingest budget is tracked
+type BudgetPeriod string
+
+// Supported budget periods
+const (
+	BudgetPeriodHourly BudgetPeriod = "hourly"
+	BudgetPeriodDaily  BudgetPeriod = "daily"
+)
+
+// OverBudgetAction is the action taken on payloads received after a budget is
+// exhausted
+type OverBudgetAction string
+
+// Supported over budget actions
+const (
+	// OverBudgetDrop accepts the payload but does not export it
+	OverBudgetDrop OverBudgetAction = "drop"
+	// OverBudgetSample exports only a fraction of payloads
+	OverBudgetSample OverBudgetAction = "sample"
+	// OverBudgetReject responds with 429 Too Many Requests
+	OverBudgetReject OverBudgetAction = "reject"
+)
+
+// DefaultBudgetConfig holds the default values of a BudgetConfig
+var DefaultBudgetConfig = BudgetConfig{
+	Period:               BudgetPeriodDaily,
+	OverBudgetAction:     OverBudgetDrop,
+	OverBudgetSampleRate: 0.1,
+}
+
+// BudgetConfig holds the ingest budget of a single app. A zero MaxBytes or
+// MaxEvents means that dimension is unlimited
+type BudgetConfig struct {
+	Period               BudgetPeriod     `yaml:"period,omitempty"`
+	MaxBytes             int64            `yaml:"max_bytes,omitempty"`
+	MaxEvents            int64            `yaml:"max_events,omitempty"`
+	OverBudgetAction     OverBudgetAction `yaml:"over_budget_action,omitempty"`
+	OverBudgetSampleRate float64          `yaml:"over_budget_sample_rate,omitempty"`
+}
+
+// UnmarshalYAML implements the Unmarshaler interface
+func (b *BudgetConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*b = DefaultBudgetConfig
+	type plain BudgetConfig
+	if err := unmarshal((*plain)(b)); err != nil {
+		return err
+	}
+
+	switch b.Period {
+	case BudgetPeriodHourly, BudgetPeriodDaily:
+	default:
+		return fmt.Errorf("invalid budget period %q, expected hourly or daily", b.Period)
+	}
+	switch b.OverBudgetAction {
+	case OverBudgetDrop, OverBudgetReject:
+	case OverBudgetSample:
+		if b.OverBudgetSampleRate <= 0 || b.OverBudgetSampleRate > 1 {
+			return fmt.Errorf("over_budget_sample_rate must be in the range (0, 1]")
+		}
+	default:
+		return fmt.Errorf("invalid over_budget_action %q, expected drop, sample or reject", b.OverBudgetAction)
+	}
+	if b.MaxBytes < 0 || b.MaxEvents < 0 {
+		return fmt.Errorf("max_bytes and max_events must not be negative")
+	}
+	return nil
+}
+
+// QuotaConfig holds ingest budgets. Budgets are picked by API key: Apps
+// holds the budgets of the apps in Config.Apps by name, and apply to payloads
+// sent with the API key of the app
+type QuotaConfig struct {
+	// Default is applied to every app in Config.Apps without its own entry in
+	// Apps, and is shared by all API keys which don't belong to an app. If
+	// nil, such payloads are not limited.
+	Default *BudgetConfig           `yaml:"default,omitempty"`
+	Apps    map[string]BudgetConfig `yaml:"apps,omitempty"`
+}
+
+// DefaultOTLPExporterConfig holds the default values of an OTLPExporterConfig
+var DefaultOTLPExporterConfig = OTLPExporterConfig{
+	Timeout: 5 * time.Second,
+}
+
+// UnmarshalYAML implements the Unmarshaler interface
+func (c *OTLPExporterConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultOTLPExporterConfig
+	type plain OTLPExporterConfig
+	return unmarshal((*plain)(c))
+}
+
+// DefaultErrorSamplingConfig holds the default values of an
+// ErrorSamplingConfig
+var DefaultErrorSamplingConfig = ErrorSamplingConfig{
+	Attribute: "app_agent_receiver.error",
+	Window:    30 * time.Second,
+}
+
+// ErrorSamplingConfig configures marking the spans of traces which contain
+// frontend exceptions, so that a tail sampling policy on the attribute can
+// always sample them
+type ErrorSamplingConfig struct {
+	// Attribute is set to "true" on every span of a trace with an exception
+	Attribute string `yaml:"attribute,omitempty"`
+	// Window is how long the IDs of traces with exceptions are remembered,
+	// so that spans of the same trace received later are marked too
+	Window time.Duration `yaml:"window,omitempty"`
+}
+
+// UnmarshalYAML implements the Unmarshaler interface
+func (c *ErrorSamplingConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultErrorSamplingConfig
+	type plain ErrorSamplingConfig
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+	if c.Attribute == "" {
+		return fmt.Errorf("error sampling attribute must be set")
+	}
+	if c.Window <= 0 {
+		return fmt.Errorf("error sampling window must be greater than 0s")
+	}
+	return nil
+}
+
+// DefaultPayloadStatsConfig holds the default values of a PayloadStatsConfig
+var DefaultPayloadStatsConfig = PayloadStatsConfig{
+	MaxUnknownFields: 100,
+	MaxSDKVersions:   100,
+}
+
+// PayloadStatsConfig configures tracking which payload fields and SDK
+// versions are used by apps. Unknown fields and SDK versions are reported by
+// clients, so the number of distinct values tracked is limited
+type PayloadStatsConfig struct {
+	// MaxUnknownFields is the number of distinct unknown fields tracked
+	MaxUnknownFields int `yaml:"max_unknown_fields,omitempty"`
+	// MaxSDKVersions is the number of distinct app, SDK name and SDK version
+	// combinations tracked
+	MaxSDKVersions int `yaml:"max_sdk_versions,omitempty"`
+}
+
+// UnmarshalYAML implements the Unmarshaler interface
+func (c *PayloadStatsConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultPayloadStatsConfig
+	type plain PayloadStatsConfig
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+	if c.MaxUnknownFields < 0 || c.MaxSDKVersions < 0 {
+		return fmt.Errorf("max_unknown_fields and max_sdk_versions must not be negative")
+	}
+	return nil
 }
 
 // Config is the configuration struct of the
@@ -77,10 +372,26 @@ type Config struct {
 	Common          common.MetricsConfig `yaml:",inline"`
 	Server          ServerConfig         `yaml:"server,omitempty"`
 	TracesInstance  string               `yaml:"traces_instance,omitempty"`
+	TracesRoutes    []TracesRouteConfig  `yaml:"traces_routes,omitempty"`
+	Apps            []AppConfig          `yaml:"apps,omitempty"`
+	ErrorSampling   *ErrorSamplingConfig `yaml:"traces_error_sampling,omitempty"`
 	LogsInstance    string               `yaml:"logs_instance,omitempty"`
 	LogsLabels      map[string]string    `yaml:"logs_labels,omitempty"`
 	LogsSendTimeout time.Duration        `yaml:"logs_send_timeout,omitempty"`
 	SourceMaps      SourceMapConfig      `yaml:"sourcemaps,omitempty"`
+	Quotas          QuotaConfig          `yaml:"quotas,omitempty"`
+	OTLP            *OTLPExporterConfig  `yaml:"otlp,omitempty"`
+	Kafka           *KafkaExporterConfig `yaml:"kafka,omitempty"`
+	Queue           *QueueConfig         `yaml:"queue,omitempty"`
+	PayloadStats    *PayloadStatsConfig  `yaml:"payload_stats,omitempty"`
+	PayloadTypes    *PayloadTypesConfig  `yaml:"payload_types,omitempty"`
+
+	ExceptionGrouping  *ExceptionGroupingConfig  `yaml:"exception_grouping,omitempty"`
+	MeasurementMetrics *MeasurementMetricsConfig `yaml:"measurement_metrics,omitempty"`
+	Scrubbing          *ScrubbingConfig          `yaml:"scrubbing,omitempty"`
+	SessionSampling    *SessionSamplingConfig    `yaml:"session_sampling,omitempty"`
+	GeoIP              *GeoIPConfig              `yaml:"geoip,omitempty"`
+	UserAgent          *UserAgentConfig          `yaml:"user_agent,omitempty"`
 }
 
 // UnmarshalYAML implements the Unmarshaler interface
@@ -88,7 +399,61 @@ func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	*c = DefaultConfig
 	c.LogsLabels = make(map[string]string)
 	type plain Config
-	return unmarshal((*plain)(c))
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+	if c.OTLP != nil && c.OTLP.Endpoint == "" {
+		return fmt.Errorf("otlp endpoint must be set")
+	}
+	for i, route := range c.TracesRoutes {
+		if route.TracesInstance == "" {
+			return fmt.Errorf("traces_routes[%d]: traces_instance must be set", i)
+		}
+		if len(route.Apps) == 0 && len(route.APIKeys) == 0 {
+			return fmt.Errorf("traces_routes[%d]: at least one of apps or api_keys must be set", i)
+		}
+	}
+	names := make(map[string]struct{}, len(c.Apps))
+	keys := make(map[string]struct{}, len(c.Apps))
+	for i, app := range c.Apps {
+		if app.Name == "" || app.APIKey == "" {
+			return fmt.Errorf("apps[%d]: name and api_key must be set", i)
+		}
+		if _, ok := names[app.Name]; ok {
+			return fmt.Errorf("apps[%d]: duplicate app %q", i, app.Name)
+		}
+		if _, ok := keys[app.APIKey]; ok {
+			return fmt.Errorf("apps[%d]: api_key of app %q is used by another app", i, app.Name)
+		}
+		names[app.Name] = struct{}{}
+		keys[app.APIKey] = struct{}{}
+	}
+	return nil
+}
+
+// AppConfig configures an app which sends payloads with its own API key.
+// Payloads sent with APIKey must be sent from one of CORSAllowedOrigins, if
+// set, and by the app Name
+type AppConfig struct {
+	Name               string   `yaml:"name"`
+	APIKey             string   `yaml:"api_key"`
+	CORSAllowedOrigins []string `yaml:"cors_allowed_origins,omitempty"`
+	// MaxAllowedPayloadSize overrides the max payload size of the server if
+	// greater than 0
+	MaxAllowedPayloadSize int64 `yaml:"max_allowed_payload_size,omitempty"`
+	// LogsInstance and TracesInstance override the instances payloads of the
+	// app are sent to if set
+	LogsInstance   string `yaml:"logs_instance,omitempty"`
+	TracesInstance string `yaml:"traces_instance,omitempty"`
+}
+
+// TracesRouteConfig sends the traces of some apps to a traces instance other
+// than TracesInstance. Traces match the route if they are sent by one of
+// Apps, according to meta.app.name, or with one of APIKeys
+type TracesRouteConfig struct {
+	Apps           []string `yaml:"apps,omitempty"`
+	APIKeys        []string `yaml:"api_keys,omitempty"`
+	TracesInstance string   `yaml:"traces_instance"`
 }
 
 // IntegrationName is the name of this integration
@@ -97,6 +462,15 @@ var IntegrationName = "app_agent_receiver"
 // ApplyDefaults applies runtime-specific defaults to c.
 func (c *Config) ApplyDefaults(globals integrations.Globals) error {
 	c.Common.ApplyDefaults(globals.SubsystemOpts.Metrics.Autoscrape)
+	if upload := c.SourceMaps.Upload; upload != nil && upload.Path == "" {
+		// Instances share the data path of the integration unless they're
+		// given an instance key, which keeps their uploads apart
+		dir := filepath.Join(globals.SubsystemOpts.DataPath, IntegrationName)
+		if c.Common.InstanceKey != nil {
+			dir = filepath.Join(dir, *c.Common.InstanceKey)
+		}
+		upload.Path = filepath.Join(dir, "sourcemaps")
+	}
 	if id, err := c.Identifier(globals); err == nil {
 		c.Common.InstanceKey = &id
 	}
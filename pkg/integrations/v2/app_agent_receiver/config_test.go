@@ -1,8 +1,11 @@
 package app_agent_receiver
 
 import (
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/grafana/agent/pkg/integrations/v2"
 	"github.com/stretchr/testify/require"
 	"gopkg.in/yaml.v2"
 )
@@ -19,6 +22,32 @@ test-conf: test-val`
 	require.Equal(t, true, cfg.Server.RateLimiting.Enabled)
 }
 
+func TestConfig_DefaultUploadPath(t *testing.T) {
+	globals := integrations.Globals{
+		AgentIdentifier: "agent:12345",
+		SubsystemOpts:   integrations.SubsystemOptions{DataPath: "/var/lib/agent"},
+	}
+
+	var cfg Config
+	cb := `
+sourcemaps:
+  upload:
+    api_key: secret`
+	require.NoError(t, yaml.Unmarshal([]byte(cb), &cfg))
+	require.NoError(t, cfg.ApplyDefaults(globals))
+	require.Equal(t, filepath.Join("/var/lib/agent", "app_agent_receiver", "sourcemaps"), cfg.SourceMaps.Upload.Path)
+
+	var keyed Config
+	cb = `
+instance: frontend
+sourcemaps:
+  upload:
+    api_key: secret`
+	require.NoError(t, yaml.Unmarshal([]byte(cb), &keyed))
+	require.NoError(t, keyed.ApplyDefaults(globals))
+	require.Equal(t, filepath.Join("/var/lib/agent", "app_agent_receiver", "frontend", "sourcemaps"), keyed.SourceMaps.Upload.Path)
+}
+
 func TestConfig_EnableRateLimitNoRPS(t *testing.T) {
 	var cfg Config
 	cb := `
@@ -77,3 +106,22 @@ logs_labels:
 	}, cfg2.LogsLabels)
 	require.Equal(t, []string{"*"}, cfg2.SourceMaps.DownloadFromOrigins)
 }
+
+func TestConfig_ErrorSampling(t *testing.T) {
+	var cfg Config
+	cb := `
+traces_error_sampling:
+  window: 1m`
+	err := yaml.UnmarshalStrict([]byte(cb), &cfg)
+	require.NoError(t, err)
+	require.Equal(t, &ErrorSamplingConfig{
+		Attribute: "app_agent_receiver.error",
+		Window:    time.Minute,
+	}, cfg.ErrorSampling)
+
+	cb = `
+traces_error_sampling:
+  attribute: ""`
+	err = yaml.UnmarshalStrict([]byte(cb), &cfg)
+	require.EqualError(t, err, "error sampling attribute must be set")
+}
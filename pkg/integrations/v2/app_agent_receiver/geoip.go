@@ -0,0 +1,247 @@
+package app_agent_receiver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/oschwald/geoip2-golang"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Span attributes set from the location of the client
+const (
+	GeoCountryISOCodeAttribute = "geo.country.iso_code"
+	GeoCountryNameAttribute    = "geo.country.name"
+	GeoRegionISOCodeAttribute  = "geo.region.iso_code"
+	GeoRegionNameAttribute     = "geo.region.name"
+)
+
+// DefaultGeoIPConfig holds the default values of a GeoIPConfig
+var DefaultGeoIPConfig = GeoIPConfig{
+	ReloadInterval: time.Minute,
+}
+
+// GeoIPConfig configures looking up the location of clients in a MaxMind
+// database
+type GeoIPConfig struct {
+	// DBPath is the path of a GeoIP2 or GeoLite2 City or Country database.
+	// The database is reloaded when the file changes
+	DBPath string `yaml:"db_path"`
+	// TrustForwardedFor uses the last address of the X-Forwarded-For header
+	// as the address of the client
+	TrustForwardedFor bool `yaml:"trust_forwarded_for,omitempty"`
+	// ReloadInterval is how often the database file is checked for changes
+	ReloadInterval time.Duration `yaml:"reload_interval,omitempty"`
+}
+
+// UnmarshalYAML implements the Unmarshaler interface
+func (c *GeoIPConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultGeoIPConfig
+	type plain GeoIPConfig
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+	if c.DBPath == "" {
+		return fmt.Errorf("geoip db_path must be set")
+	}
+	if c.ReloadInterval <= 0 {
+		return fmt.Errorf("geoip reload_interval must be greater than 0s")
+	}
+	return nil
+}
+
+// geoDB is a MaxMind database
+type geoDB interface {
+	Lookup(ip net.IP) (Geo, error)
+	Close() error
+}
+
+// maxmindDB looks up locations in a City or Country database
+type maxmindDB struct {
+	reader *geoip2.Reader
+	city   bool
+}
+
+func openMaxmindDB(path string) (geoDB, error) {
+	reader, err := geoip2.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &maxmindDB{
+		reader: reader,
+		city:   strings.Contains(reader.Metadata().DatabaseType, "City"),
+	}, nil
+}
+
+func (db *maxmindDB) Lookup(ip net.IP) (Geo, error) {
+	if !db.city {
+		rec, err := db.reader.Country(ip)
+		if err != nil {
+			return Geo{}, err
+		}
+		return Geo{
+			CountryISOCode: rec.Country.IsoCode,
+			CountryName:    rec.Country.Names["en"],
+		}, nil
+	}
+
+	rec, err := db.reader.City(ip)
+	if err != nil {
+		return Geo{}, err
+	}
+	geo := Geo{
+		CountryISOCode: rec.Country.IsoCode,
+		CountryName:    rec.Country.Names["en"],
+	}
+	if len(rec.Subdivisions) > 0 {
+		geo.RegionISOCode = rec.Subdivisions[0].IsoCode
+		geo.RegionName = rec.Subdivisions[0].Names["en"]
+	}
+	return geo, nil
+}
+
+func (db *maxmindDB) Close() error {
+	return db.reader.Close()
+}
+
+// geoIPEnricher sets the location of the client on payloads. The database
+// is reloaded by Run when its file changes
+type geoIPEnricher struct {
+	conf    GeoIPConfig
+	logger  log.Logger
+	open    func(path string) (geoDB, error)
+	reloads *prometheus.CounterVec
+
+	mut     sync.RWMutex
+	db      geoDB
+	modTime time.Time
+}
+
+// newGeoIPEnricher creates a geoIPEnricher, failing if the database can't be
+// opened
+func newGeoIPEnricher(logger log.Logger, conf GeoIPConfig, reg prometheus.Registerer) (*geoIPEnricher, error) {
+	return newGeoIPEnricherWithOpener(logger, conf, reg, openMaxmindDB)
+}
+
+func newGeoIPEnricherWithOpener(logger log.Logger, conf GeoIPConfig, reg prometheus.Registerer, open func(string) (geoDB, error)) (*geoIPEnricher, error) {
+	e := &geoIPEnricher{
+		conf:   conf,
+		logger: log.With(logger, "subcomponent", "geoip"),
+		open:   open,
+		reloads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "app_agent_receiver_geoip_reloads_total",
+			Help: "Total number of reloads of the geoip database, by status",
+		}, []string{"status"}),
+	}
+	if err := e.reload(); err != nil {
+		return nil, fmt.Errorf("failed to open geoip database: %w", err)
+	}
+	reg.MustRegister(e.reloads)
+	return e, nil
+}
+
+// ClientIP returns the address of the client of r
+func (e *geoIPEnricher) ClientIP(r *http.Request) string {
+	return clientIP(r, e.conf.TrustForwardedFor)
+}
+
+// Enrich sets the location of ip on the metadata and spans of p. p is left
+// unchanged if ip isn't found in the database
+func (e *geoIPEnricher) Enrich(p *Payload, ip string) {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return
+	}
+
+	e.mut.RLock()
+	geo, err := e.db.Lookup(addr)
+	e.mut.RUnlock()
+	if err != nil || geo == (Geo{}) {
+		return
+	}
+
+	p.Meta.Geo = geo
+	if p.Traces == nil {
+		return
+	}
+	attrs := [][2]string{
+		{GeoCountryISOCodeAttribute, geo.CountryISOCode},
+		{GeoCountryNameAttribute, geo.CountryName},
+		{GeoRegionISOCodeAttribute, geo.RegionISOCode},
+		{GeoRegionNameAttribute, geo.RegionName},
+	}
+	for _, s := range p.Traces.SpanSlice() {
+		for _, attr := range attrs {
+			if attr[1] != "" {
+				s.Attributes().UpsertString(attr[0], attr[1])
+			}
+		}
+	}
+}
+
+// Run reloads the database when its file changes until ctx is done
+func (e *geoIPEnricher) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.conf.ReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := e.reload(); err != nil {
+				e.reloads.WithLabelValues("failure").Inc()
+				level.Warn(e.logger).Log("msg", "failed to reload geoip database, keeping the previous one", "err", err)
+			}
+		}
+	}
+}
+
+// reload opens the database again if its file was modified since it was
+// last opened
+func (e *geoIPEnricher) reload() error {
+	info, err := os.Stat(e.conf.DBPath)
+	if err != nil {
+		return err
+	}
+
+	e.mut.RLock()
+	unchanged := e.db != nil && info.ModTime().Equal(e.modTime)
+	e.mut.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	db, err := e.open(e.conf.DBPath)
+	if err != nil {
+		return err
+	}
+
+	e.mut.Lock()
+	prev := e.db
+	e.db = db
+	e.modTime = info.ModTime()
+	e.mut.Unlock()
+
+	if prev != nil {
+		_ = prev.Close()
+		e.reloads.WithLabelValues("success").Inc()
+		level.Info(e.logger).Log("msg", "reloaded geoip database", "path", e.conf.DBPath)
+	}
+	return nil
+}
+
+// Close closes the database
+func (e *geoIPEnricher) Close() error {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+	return e.db.Close()
+}
@@ -0,0 +1,157 @@
+package app_agent_receiver
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+// testGeoDB locates addresses from a map, and is opened from files holding
+// the name of the database
+type testGeoDB struct {
+	name      string
+	locations map[string]Geo
+	closed    bool
+}
+
+func (db *testGeoDB) Lookup(ip net.IP) (Geo, error) {
+	geo, ok := db.locations[ip.String()]
+	if !ok {
+		return Geo{}, errors.New("address not found")
+	}
+	return geo, nil
+}
+
+func (db *testGeoDB) Close() error {
+	db.closed = true
+	return nil
+}
+
+var testGeoLocations = map[string]Geo{
+	"81.2.69.142": {CountryISOCode: "GB", CountryName: "United Kingdom", RegionISOCode: "ENG", RegionName: "England"},
+}
+
+func newTestGeoIPEnricher(t *testing.T, conf GeoIPConfig) (*geoIPEnricher, *[]*testGeoDB) {
+	t.Helper()
+
+	var opened []*testGeoDB
+	open := func(path string) (geoDB, error) {
+		name, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if string(name) == "corrupt" {
+			return nil, errors.New("invalid database")
+		}
+		db := &testGeoDB{name: string(name), locations: testGeoLocations}
+		opened = append(opened, db)
+		return db, nil
+	}
+
+	e, err := newGeoIPEnricherWithOpener(log.NewNopLogger(), conf, prometheus.NewRegistry(), open)
+	require.NoError(t, err)
+	return e, &opened
+}
+
+func TestGeoIPEnricher_Enrich(t *testing.T) {
+	conf := DefaultGeoIPConfig
+	conf.DBPath = filepath.Join(t.TempDir(), "city.mmdb")
+	require.NoError(t, os.WriteFile(conf.DBPath, []byte("v1"), 0600))
+	e, _ := newTestGeoIPEnricher(t, conf)
+
+	p := loadTestPayload(t)
+	e.Enrich(&p, "81.2.69.142")
+	require.Equal(t, testGeoLocations["81.2.69.142"], p.Meta.Geo)
+	country, _ := p.Meta.KeyVal().Get("geo_country_iso_code")
+	require.Equal(t, "GB", country)
+
+	for _, s := range p.Traces.SpanSlice() {
+		v, ok := s.Attributes().Get(GeoRegionNameAttribute)
+		require.True(t, ok)
+		require.Equal(t, "England", v.StringVal())
+	}
+
+	// Unknown and invalid addresses are not located.
+	p = loadTestPayload(t)
+	e.Enrich(&p, "10.0.0.1")
+	e.Enrich(&p, "not an address")
+	require.Equal(t, Geo{}, p.Meta.Geo)
+}
+
+func TestGeoIPEnricher_Reload(t *testing.T) {
+	conf := DefaultGeoIPConfig
+	conf.DBPath = filepath.Join(t.TempDir(), "city.mmdb")
+	require.NoError(t, os.WriteFile(conf.DBPath, []byte("v1"), 0600))
+	e, opened := newTestGeoIPEnricher(t, conf)
+
+	// The database isn't reopened while its file is unchanged.
+	require.NoError(t, e.reload())
+	require.Len(t, *opened, 1)
+
+	update := func(content string) {
+		require.NoError(t, os.WriteFile(conf.DBPath, []byte(content), 0600))
+		mtime := e.modTime.Add(time.Second)
+		require.NoError(t, os.Chtimes(conf.DBPath, mtime, mtime))
+	}
+
+	update("v2")
+	require.NoError(t, e.reload())
+	require.Len(t, *opened, 2)
+	require.True(t, (*opened)[0].closed)
+	require.Equal(t, "v2", e.db.(*testGeoDB).name)
+	require.Equal(t, 1.0, testutil.ToFloat64(e.reloads.WithLabelValues("success")))
+
+	// The previous database is kept if the new one can't be opened.
+	update("corrupt")
+	require.Error(t, e.reload())
+	require.Equal(t, "v2", e.db.(*testGeoDB).name)
+}
+
+func TestHandler_GeoIP(t *testing.T) {
+	conf := DefaultGeoIPConfig
+	conf.DBPath = filepath.Join(t.TempDir(), "city.mmdb")
+	conf.TrustForwardedFor = true
+	require.NoError(t, os.WriteFile(conf.DBPath, []byte("v1"), 0600))
+	e, _ := newTestGeoIPEnricher(t, conf)
+
+	exporter := &TestExporter{name: "exporter"}
+	fr := NewAppAgentReceiverHandler(&Config{}, []AppAgentReceiverExporter{exporter}, prometheus.NewRegistry())
+	fr.geo = e
+
+	req := httptest.NewRequest("POST", "/collect", bytes.NewBuffer(loadTestData(t, "payload.json")))
+	req.Header.Set("X-Forwarded-For", "10.0.0.1, 81.2.69.142")
+	rr := httptest.NewRecorder()
+	fr.HTTPHandler(nil).ServeHTTP(rr, req)
+
+	require.Equal(t, 202, rr.Result().StatusCode)
+	require.Len(t, exporter.payloads, 1)
+	require.Equal(t, "GB", exporter.payloads[0].Meta.Geo.CountryISOCode)
+}
+
+func TestConfig_GeoIP(t *testing.T) {
+	var cfg Config
+	cb := `
+geoip:
+  db_path: /var/lib/GeoIP/GeoLite2-City.mmdb`
+	require.NoError(t, yaml.Unmarshal([]byte(cb), &cfg))
+	require.Equal(t, GeoIPConfig{
+		DBPath:         "/var/lib/GeoIP/GeoLite2-City.mmdb",
+		ReloadInterval: time.Minute,
+	}, *cfg.GeoIP)
+
+	cb = `
+geoip:
+  trust_forwarded_for: true`
+	require.EqualError(t, yaml.Unmarshal([]byte(cb), &cfg), "geoip db_path must be set")
+}
@@ -0,0 +1,76 @@
+package app_agent_receiver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+)
+
+// DefaultExceptionGroupingConfig holds the default values of an
+// ExceptionGroupingConfig
+var DefaultExceptionGroupingConfig = ExceptionGroupingConfig{
+	Frames: 5,
+}
+
+// ExceptionGroupingConfig configures how exceptions are fingerprinted. The
+// fingerprint of an exception is a hash of its type and its top Frames
+// frames, after they are symbolicated using sourcemaps
+type ExceptionGroupingConfig struct {
+	Frames int `yaml:"frames,omitempty"`
+	// IncludeLineNumbers adds the line and column of frames to the
+	// fingerprint. Line numbers are always used for frames without a
+	// function name
+	IncludeLineNumbers bool `yaml:"include_line_numbers,omitempty"`
+}
+
+// UnmarshalYAML implements the Unmarshaler interface
+func (c *ExceptionGroupingConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultExceptionGroupingConfig
+	type plain ExceptionGroupingConfig
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+	if c.Frames < 0 {
+		return fmt.Errorf("exception grouping frames must not be negative")
+	}
+	return nil
+}
+
+// Fingerprint returns the group hash of ex. Exceptions of the same type
+// thrown from the same place have the same fingerprint, regardless of their
+// message
+func (c ExceptionGroupingConfig) Fingerprint(ex *Exception) string {
+	h := sha256.New()
+	_, _ = h.Write([]byte(ex.Type))
+
+	if ex.Stacktrace != nil {
+		frames := ex.Stacktrace.Frames
+		if len(frames) > c.Frames {
+			frames = frames[:c.Frames]
+		}
+		for _, frame := range frames {
+			_, _ = h.Write([]byte{0})
+			_, _ = h.Write([]byte(frame.Filename))
+			_, _ = h.Write([]byte{0})
+			_, _ = h.Write([]byte(frame.Function))
+
+			// go-sourcemap often can't determine the original function name,
+			// in which case the line number tells frames apart
+			if c.IncludeLineNumbers || frame.Function == "" || frame.Function == "?" {
+				_, _ = h.Write([]byte{0})
+				_, _ = h.Write([]byte(strconv.Itoa(frame.Lineno) + ":" + strconv.Itoa(frame.Colno)))
+			}
+		}
+	}
+
+	// 16 bytes are plenty to avoid collisions between groups of an app
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// groupException sets the fingerprint of ex if grouping is configured
+func groupException(conf *ExceptionGroupingConfig, ex *Exception) {
+	if conf != nil {
+		ex.Fingerprint = conf.Fingerprint(ex)
+	}
+}
@@ -0,0 +1,92 @@
+package app_agent_receiver
+
+import (
+	"context"
+	"testing"
+
+	kitlog "github.com/go-kit/log"
+	"github.com/grafana/loki/clients/pkg/promtail/api"
+	prommodel "github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func groupingTestException(value string, frames ...Frame) *Exception {
+	return &Exception{
+		Type:       "TypeError",
+		Value:      value,
+		Stacktrace: &Stacktrace{Frames: frames},
+	}
+}
+
+func TestExceptionGrouping_Fingerprint(t *testing.T) {
+	conf := ExceptionGroupingConfig{Frames: 2}
+
+	a := Frame{Filename: "src/cart.ts", Function: "addItem", Lineno: 10, Colno: 2}
+	b := Frame{Filename: "src/cart.ts", Function: "render", Lineno: 20, Colno: 4}
+	c := Frame{Filename: "src/index.ts", Function: "main", Lineno: 1, Colno: 1}
+
+	base := conf.Fingerprint(groupingTestException("x is undefined", a, b, c))
+	require.Len(t, base, 32)
+
+	// The message and frames past the limit don't affect the group.
+	require.Equal(t, base, conf.Fingerprint(groupingTestException("y is undefined", a, b)))
+
+	// Line numbers are ignored for frames with a function name by default.
+	moved := a
+	moved.Lineno = 11
+	require.Equal(t, base, conf.Fingerprint(groupingTestException("", moved, b)))
+	withLines := ExceptionGroupingConfig{Frames: 2, IncludeLineNumbers: true}
+	require.NotEqual(t, withLines.Fingerprint(groupingTestException("", a, b)), withLines.Fingerprint(groupingTestException("", moved, b)))
+
+	// Frames without a function name are told apart by line.
+	unnamed := Frame{Filename: "src/cart.ts", Function: "?", Lineno: 10}
+	unnamedMoved := Frame{Filename: "src/cart.ts", Function: "?", Lineno: 11}
+	require.NotEqual(t, conf.Fingerprint(groupingTestException("", unnamed)), conf.Fingerprint(groupingTestException("", unnamedMoved)))
+
+	require.NotEqual(t, base, conf.Fingerprint(groupingTestException("", b, a)))
+
+	other := groupingTestException("", a, b)
+	other.Type = "RangeError"
+	require.NotEqual(t, base, conf.Fingerprint(other))
+}
+
+func TestConfig_ExceptionGrouping(t *testing.T) {
+	var cfg Config
+	require.NoError(t, yaml.Unmarshal([]byte("exception_grouping: {}"), &cfg))
+	require.Equal(t, &DefaultExceptionGroupingConfig, cfg.ExceptionGrouping)
+
+	require.EqualError(t, yaml.Unmarshal([]byte("exception_grouping: {frames: -1}"), &cfg), "exception grouping frames must not be negative")
+}
+
+func TestExportLogs_ExceptionFingerprint(t *testing.T) {
+	inst := &testLogsInstance{
+		Entries: []api.Entry{},
+	}
+	grouping := DefaultExceptionGroupingConfig
+
+	logsExporter := NewLogsExporter(
+		kitlog.NewNopLogger(),
+		LogsExporterConfig{
+			GetLogsInstance: func(context.Context, string) (LogsInstance, error) { return inst, nil },
+			Labels: map[string]string{
+				"kind":        "",
+				"fingerprint": "",
+			},
+			SendEntryTimeout:  100,
+			ExceptionGrouping: &grouping,
+		},
+		&MockSourceMapStore{},
+	)
+
+	exception := groupingTestException("x is undefined", Frame{Filename: "src/cart.ts", Function: "addItem"})
+	require.NoError(t, logsExporter.Export(context.Background(), Payload{Exceptions: []Exception{*exception}}))
+
+	require.Len(t, inst.Entries, 1)
+	fingerprint := grouping.Fingerprint(exception)
+	require.Equal(t, prommodel.LabelSet{
+		"kind":        "exception",
+		"fingerprint": prommodel.LabelValue(fingerprint),
+	}, inst.Entries[0].Labels)
+	require.Contains(t, inst.Entries[0].Line, "fingerprint="+fingerprint)
+}
@@ -1,11 +1,15 @@
 package app_agent_receiver
 
 import (
+	"bytes"
 	"context"
+	"io"
+	"math"
+	"strconv"
 	"sync"
+	"time"
 
 	"crypto/subtle"
-	"encoding/json"
 	"net/http"
 
 	"github.com/go-kit/log"
@@ -17,21 +21,30 @@ import (
 
 const apiKeyHeader = "x-api-key"
 
-type appAgentReceiverExporter interface {
+// AppAgentReceiverExporter exports the payloads received by the receiver
+type AppAgentReceiverExporter interface {
 	Name() string
 	Export(ctx context.Context, payload Payload) error
 }
 
 // AppAgentReceiverHandler struct controls the data ingestion http handler of the receiver
 type AppAgentReceiverHandler struct {
-	exporters               []appAgentReceiverExporter
+	exporters               []AppAgentReceiverExporter
 	config                  *Config
 	rateLimiter             *rate.Limiter
+	clientLimiter           *clientRateLimiter // nil if clients are not limited individually
+	quotas                  *quotaTracker
+	stats                   *payloadStats      // nil if payload statistics are disabled
+	scrubber                *scrubber          // nil if payloads are not scrubbed
+	sampler                 *sessionSampler    // nil if sessions are not sampled
+	geo                     *geoIPEnricher     // nil if payloads are not enriched with locations
+	userAgent               *userAgentEnricher // nil if payloads are not enriched from the User-Agent header
 	exporterErrorsCollector *prometheus.CounterVec
+	rateLimitedCollector    *prometheus.CounterVec
 }
 
 // NewAppAgentReceiverHandler creates a new AppReceiver instance based on the given configuration
-func NewAppAgentReceiverHandler(conf *Config, exporters []appAgentReceiverExporter, reg prometheus.Registerer) AppAgentReceiverHandler {
+func NewAppAgentReceiverHandler(conf *Config, exporters []AppAgentReceiverExporter, reg prometheus.Registerer) AppAgentReceiverHandler {
 	var rateLimiter *rate.Limiter
 	if conf.Server.RateLimiting.Enabled {
 		var rps float64
@@ -46,81 +59,290 @@ func NewAppAgentReceiverHandler(conf *Config, exporters []appAgentReceiverExport
 		rateLimiter = rate.NewLimiter(rate.Limit(rps), b)
 	}
 
+	var clientLimiter *clientRateLimiter
+	if conf.Server.RateLimiting.Enabled && conf.Server.RateLimiting.PerClient != nil {
+		clientLimiter = newClientRateLimiter(*conf.Server.RateLimiting.PerClient)
+	}
+
+	rateLimitedCollector := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "app_agent_receiver_rate_limited_requests_total",
+		Help: "Total number of requests rejected by a rate limit, by the limit which was exceeded",
+	}, []string{"limit"})
+
 	exporterErrorsCollector := prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: "app_agent_receiver_exporter_errors_total",
 		Help: "Total number of errors produced by a receiver exporter",
 	}, []string{"exporter"})
 
-	reg.MustRegister(exporterErrorsCollector)
+	reg.MustRegister(exporterErrorsCollector, rateLimitedCollector)
+
+	var stats *payloadStats
+	if conf.PayloadStats != nil {
+		stats = newPayloadStats(*conf.PayloadStats, reg)
+	}
+
+	var sampler *sessionSampler
+	if conf.SessionSampling != nil {
+		sampler = newSessionSampler(*conf.SessionSampling, reg)
+	}
 
 	return AppAgentReceiverHandler{
 		exporters:               exporters,
 		config:                  conf,
 		rateLimiter:             rateLimiter,
+		clientLimiter:           clientLimiter,
+		quotas:                  newQuotaTracker(conf.Quotas, reg),
+		stats:                   stats,
+		sampler:                 sampler,
 		exporterErrorsCollector: exporterErrorsCollector,
+		rateLimitedCollector:    rateLimitedCollector,
 	}
 }
 
 // HTTPHandler is the http.Handler for the receiver. It will do the following
 // 0. Enable CORS for the configured hosts
-// 1. Check if the request should be rate limited
-// 2. Verify that the payload size is within limits
-// 3. Start two go routines for exporters processing and exporting data respectively
-// 4. Respond with 202 once all the work is done
+// 1. Check if the request should be rate limited, globally and per client
+// 2. Check the API key, and the origin of apps with their own key
+// 3. Verify that the payload size is within limits
+// 4. Check the payload size against the byte rate of its client
+// 5. Process the payload with processPayload
+// 6. Respond with 202 once all the work is done
 func (ar *AppAgentReceiverHandler) HTTPHandler(logger log.Logger) http.Handler {
-	var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Check rate limiting state
-		if ar.config.Server.RateLimiting.Enabled {
-			if rsv := ar.rateLimiter.Reserve(); !rsv.OK() {
-				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
-				return
-			}
+	return ar.withCORS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, ok := ar.readRequest(w, r)
+		if !ok {
+			return
 		}
 
-		// check API key if one is provided
-		if len(ar.config.Server.APIKey) > 0 && subtle.ConstantTimeCompare([]byte(r.Header.Get(apiKeyHeader)), []byte(ar.config.Server.APIKey)) == 0 {
-			http.Error(w, "api key not provided or incorrect", http.StatusUnauthorized)
+		res := ar.processPayload(req, logger, req.body)
+		if res.Status != http.StatusAccepted {
+			if res.retryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(res.retryAfter.Seconds()))))
+			}
+			http.Error(w, res.Error, res.Status)
 			return
 		}
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte("ok"))
+	}))
+}
 
-		// Verify content length. We trust net/http to give us the correct number
-		if ar.config.Server.MaxAllowedPayloadSize > 0 && r.ContentLength > ar.config.Server.MaxAllowedPayloadSize {
-			http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
-			return
+// receiverRequest is a request accepted by readRequest
+type receiverRequest struct {
+	ctx           context.Context
+	apiKey        string
+	app           *AppConfig // nil if the request isn't sent with the key of an app
+	body          []byte
+	versionHeader string
+	clientIP      string // Only set if payloads are enriched with locations
+	userAgent     string
+}
+
+// readRequest applies the checks shared by all ingestion endpoints to r and
+// reads its body. If r is rejected, readRequest writes the response and
+// returns false
+func (ar *AppAgentReceiverHandler) readRequest(w http.ResponseWriter, r *http.Request) (receiverRequest, bool) {
+	// Check rate limiting state
+	if ar.config.Server.RateLimiting.Enabled {
+		if ok, retryAfter := allow(ar.rateLimiter, time.Now(), 1); !ok {
+			rejectRateLimited(w, retryAfter, "global", ar.rateLimitedCollector)
+			return receiverRequest{}, false
 		}
+	}
 
-		var p Payload
-		err := json.NewDecoder(r.Body).Decode(&p)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
+	var client string
+	if ar.clientLimiter != nil {
+		client = ar.clientLimiter.ClientKey(r, ar.knownAPIKey)
+		if ok, retryAfter := ar.clientLimiter.AllowRequest(client); !ok {
+			rejectRateLimited(w, retryAfter, "client_requests", ar.rateLimitedCollector)
+			return receiverRequest{}, false
+		}
+	}
+
+	// check API key if one is provided
+	apiKey := r.Header.Get(apiKeyHeader)
+	app := appForAPIKey(ar.config.Apps, apiKey)
+	if app == nil && !ar.validAPIKey(apiKey) {
+		http.Error(w, "api key not provided or incorrect", http.StatusUnauthorized)
+		return receiverRequest{}, false
+	}
+	ctx := contextWithAPIKey(r.Context(), apiKey)
+
+	maxPayloadSize := ar.config.Server.MaxAllowedPayloadSize
+	if app != nil {
+		if len(app.CORSAllowedOrigins) > 0 && !originAllowed(r.Header.Get("Origin"), app.CORSAllowedOrigins) {
+			http.Error(w, "origin not allowed for app", http.StatusForbidden)
+			return receiverRequest{}, false
+		}
+		if app.MaxAllowedPayloadSize > 0 {
+			maxPayloadSize = app.MaxAllowedPayloadSize
 		}
+		ctx = contextWithApp(ctx, app)
+	}
+
+	// Verify content length. We trust net/http to give us the correct number
+	if maxPayloadSize > 0 && r.ContentLength > maxPayloadSize {
+		http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+		return receiverRequest{}, false
+	}
 
-		var wg sync.WaitGroup
-
-		for _, exporter := range ar.exporters {
-			wg.Add(1)
-			go func(exp appAgentReceiverExporter) {
-				defer wg.Done()
-				if err := exp.Export(r.Context(), p); err != nil {
-					level.Error(logger).Log("msg", "exporter error", "exporter", exp.Name(), "error", err)
-					ar.exporterErrorsCollector.WithLabelValues(exp.Name()).Inc()
-				}
-			}(exporter)
+	var body io.Reader = r.Body
+	if maxPayloadSize > 0 {
+		// Bodies without a content length are limited while they're read
+		body = io.LimitReader(r.Body, maxPayloadSize+1)
+	}
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return receiverRequest{}, false
+	}
+	if maxPayloadSize > 0 && int64(len(raw)) > maxPayloadSize {
+		http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+		return receiverRequest{}, false
+	}
+
+	if ar.clientLimiter != nil {
+		if ok, retryAfter := ar.clientLimiter.AllowBytes(client, int64(len(raw))); !ok {
+			rejectRateLimited(w, retryAfter, "client_bytes", ar.rateLimitedCollector)
+			return receiverRequest{}, false
 		}
+	}
 
-		wg.Wait()
-		w.WriteHeader(http.StatusAccepted)
-		_, _ = w.Write([]byte("ok"))
-	})
+	req := receiverRequest{
+		ctx:           ctx,
+		apiKey:        apiKey,
+		app:           app,
+		body:          raw,
+		versionHeader: r.Header.Get(payloadVersionHeader),
+		userAgent:     r.UserAgent(),
+	}
+	if ar.geo != nil {
+		req.clientIP = ar.geo.ClientIP(r)
+	}
+	return req, true
+}
+
+// payloadResult is the outcome of processing a single payload
+type payloadResult struct {
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
 
-	if len(ar.config.Server.CORSAllowedOrigins) > 0 {
+	retryAfter time.Duration // Set if the payload may be sent again later
+}
+
+// processPayload processes the payload raw of req. It will do the following
+// 1. Decode the payload and check the app name of apps with their own key
+// 2. Record payload statistics, if enabled
+// 3. Drop the data of disabled payload types
+// 4. Drop the payload if its session is not sampled
+// 5. Check the payload against the ingest budget of its API key
+// 6. Set the location and browser of the client on the payload, if enabled
+// 7. Scrub personally identifiable information from the payload, if enabled
+// 8. Start two go routines for exporters processing and exporting data respectively
+func (ar *AppAgentReceiverHandler) processPayload(req receiverRequest, logger log.Logger, raw []byte) payloadResult {
+	p, version, err := decodePayload(bytes.NewReader(raw), req.versionHeader)
+	if err != nil {
+		return payloadResult{Status: http.StatusBadRequest, Error: err.Error()}
+	}
+
+	if req.app != nil {
+		// Apps may only send their own payloads with their API key
+		if p.Meta.App.Name == "" {
+			p.Meta.App.Name = req.app.Name
+		} else if p.Meta.App.Name != req.app.Name {
+			return payloadResult{Status: http.StatusForbidden, Error: "api key not valid for app"}
+		}
+	}
+
+	if ar.stats != nil {
+		ar.stats.Observe(raw, version, p)
+	}
+
+	if ar.config.PayloadTypes != nil {
+		ar.config.PayloadTypes.Filter(&p)
+	}
+
+	if ar.sampler != nil && !ar.sampler.Keep(p) {
+		return payloadResult{Status: http.StatusAccepted}
+	}
+
+	switch ar.quotas.Check(req.app, int64(len(raw)), payloadEvents(p)) {
+	case quotaReject:
+		return payloadResult{
+			Status:     http.StatusTooManyRequests,
+			Error:      "ingest budget exhausted",
+			retryAfter: ar.quotas.RetryAfter(req.app),
+		}
+	case quotaDrop:
+		return payloadResult{Status: http.StatusAccepted}
+	}
+
+	// Locations are only set by the receiver, never by clients
+	p.Meta.Geo = Geo{}
+	if ar.geo != nil {
+		ar.geo.Enrich(&p, req.clientIP)
+	}
+	if ar.userAgent != nil {
+		ar.userAgent.Enrich(&p, req.userAgent)
+	}
+
+	if ar.scrubber != nil {
+		ar.scrubber.Scrub(&p)
+	}
+
+	var wg sync.WaitGroup
+
+	for _, exporter := range ar.exporters {
+		wg.Add(1)
+		go func(exp AppAgentReceiverExporter) {
+			defer wg.Done()
+			if err := exp.Export(req.ctx, p); err != nil {
+				level.Error(logger).Log("msg", "exporter error", "exporter", exp.Name(), "error", err)
+				ar.exporterErrorsCollector.WithLabelValues(exp.Name()).Inc()
+			}
+		}(exporter)
+	}
+
+	wg.Wait()
+	return payloadResult{Status: http.StatusAccepted}
+}
+
+// withCORS enables CORS on handler for the origins allowed by the server
+// and by apps
+func (ar *AppAgentReceiverHandler) withCORS(handler http.Handler) http.Handler {
+	if origins := corsAllowedOrigins(ar.config); len(origins) > 0 {
 		c := cors.New(cors.Options{
-			AllowedOrigins: ar.config.Server.CORSAllowedOrigins,
-			AllowedHeaders: []string{apiKeyHeader, "content-type"},
+			AllowedOrigins: origins,
+			AllowedHeaders: []string{apiKeyHeader, payloadVersionHeader, "content-type"},
 		})
 		handler = c.Handler(handler)
 	}
-
 	return handler
 }
+
+// knownAPIKey returns true if key is the API key of an app, or a configured
+// API key which requests are accepted with. Any key is accepted when no API
+// key is configured, but none of them is known
+func (ar *AppAgentReceiverHandler) knownAPIKey(key string) bool {
+	if appForAPIKey(ar.config.Apps, key) != nil {
+		return true
+	}
+	return len(ar.config.Server.APIKey) > 0 && ar.validAPIKey(key)
+}
+
+// validAPIKey returns true if requests with key are accepted. If an API key
+// is configured, the API keys of traces routes are accepted too
+func (ar *AppAgentReceiverHandler) validAPIKey(key string) bool {
+	if len(ar.config.Server.APIKey) == 0 {
+		return true
+	}
+	if subtle.ConstantTimeCompare([]byte(key), []byte(ar.config.Server.APIKey)) == 1 {
+		return true
+	}
+	for _, route := range ar.config.TracesRoutes {
+		if matchesAPIKey(key, route.APIKeys) {
+			return true
+		}
+	}
+	return false
+}
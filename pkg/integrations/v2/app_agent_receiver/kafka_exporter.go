@@ -0,0 +1,251 @@
+package app_agent_receiver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	kitlog "github.com/go-kit/log"
+	config_util "github.com/prometheus/common/config"
+)
+
+// Formats of messages published by the Kafka exporter
+const (
+	// KafkaFormatPayload publishes every payload as a single JSON message
+	KafkaFormatPayload = "payload"
+	// KafkaFormatEvents publishes a JSON message for every log, exception
+	// and measurement, with the same fields as the logs exporter
+	KafkaFormatEvents = "events"
+)
+
+// Keys messages published by the Kafka exporter are partitioned by
+const (
+	KafkaPartitionBySession = "session"
+	KafkaPartitionByApp     = "app"
+	KafkaPartitionByNone    = "none"
+)
+
+// DefaultKafkaExporterConfig holds the default values of a
+// KafkaExporterConfig
+var DefaultKafkaExporterConfig = KafkaExporterConfig{
+	Format:      KafkaFormatPayload,
+	PartitionBy: KafkaPartitionBySession,
+	Version:     sarama.V2_0_0_0.String(),
+	Timeout:     10 * time.Second,
+}
+
+// KafkaExporterConfig holds the configuration of the Kafka exporter
+type KafkaExporterConfig struct {
+	Brokers []string `yaml:"brokers"`
+	Topic   string   `yaml:"topic"`
+	Format  string   `yaml:"format,omitempty"`
+	// PartitionBy is the key of messages. Messages with the same key are
+	// published to the same partition, which keeps them in order
+	PartitionBy string `yaml:"partition_by,omitempty"`
+	// Version is the Kafka protocol version used
+	Version string        `yaml:"version,omitempty"`
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+
+	// TLSConfig enables TLS if set
+	TLSConfig *config_util.TLSConfig `yaml:"tls_config,omitempty"`
+	// SASL/PLAIN authentication is used if SASLUsername is set
+	SASLUsername string             `yaml:"sasl_username,omitempty"`
+	SASLPassword config_util.Secret `yaml:"sasl_password,omitempty"`
+}
+
+// UnmarshalYAML implements the Unmarshaler interface
+func (c *KafkaExporterConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultKafkaExporterConfig
+	type plain KafkaExporterConfig
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	if len(c.Brokers) == 0 {
+		return fmt.Errorf("kafka brokers must be set")
+	}
+	if c.Topic == "" {
+		return fmt.Errorf("kafka topic must be set")
+	}
+	switch c.Format {
+	case KafkaFormatPayload, KafkaFormatEvents:
+	default:
+		return fmt.Errorf("unsupported kafka format %q, expected payload or events", c.Format)
+	}
+	switch c.PartitionBy {
+	case KafkaPartitionBySession, KafkaPartitionByApp, KafkaPartitionByNone:
+	default:
+		return fmt.Errorf("unsupported kafka partition_by %q, expected session, app or none", c.PartitionBy)
+	}
+	if _, err := sarama.ParseKafkaVersion(c.Version); err != nil {
+		return fmt.Errorf("invalid kafka version: %w", err)
+	}
+	return nil
+}
+
+// saramaConfig returns the configuration of the producer of the exporter
+func (c KafkaExporterConfig) saramaConfig() (*sarama.Config, error) {
+	sc := sarama.NewConfig()
+	sc.ClientID = "grafana-agent-app-agent-receiver"
+	// Successes must be returned by sync producers
+	sc.Producer.Return.Successes = true
+	if c.Timeout > 0 {
+		sc.Producer.Timeout = c.Timeout
+		sc.Net.DialTimeout = c.Timeout
+	}
+
+	version, err := sarama.ParseKafkaVersion(c.Version)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kafka version: %w", err)
+	}
+	sc.Version = version
+
+	if c.TLSConfig != nil {
+		tlsConfig, err := config_util.NewTLSConfig(c.TLSConfig)
+		if err != nil {
+			return nil, fmt.Errorf("invalid kafka tls_config: %w", err)
+		}
+		sc.Net.TLS.Enable = true
+		sc.Net.TLS.Config = tlsConfig
+	}
+	if c.SASLUsername != "" {
+		sc.Net.SASL.Enable = true
+		sc.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+		sc.Net.SASL.User = c.SASLUsername
+		sc.Net.SASL.Password = string(c.SASLPassword)
+	}
+	return sc, sc.Validate()
+}
+
+// KafkaExporter publishes payloads, or the events they hold, to a Kafka
+// topic. The producer connects to the brokers when the first payload is
+// exported, so that the receiver starts even if Kafka is unavailable
+type KafkaExporter struct {
+	logger         kitlog.Logger
+	conf           KafkaExporterConfig
+	sourceMapStore SourceMapStore
+	grouping       *ExceptionGroupingConfig
+
+	mut         sync.Mutex
+	producer    sarama.SyncProducer
+	newProducer func() (sarama.SyncProducer, error)
+}
+
+// NewKafkaExporter creates a new Kafka exporter with the given
+// configuration. Exceptions are fingerprinted if grouping is not nil
+func NewKafkaExporter(logger kitlog.Logger, conf KafkaExporterConfig, sourceMapStore SourceMapStore, grouping *ExceptionGroupingConfig) (*KafkaExporter, error) {
+	sc, err := conf.saramaConfig()
+	if err != nil {
+		return nil, err
+	}
+	return &KafkaExporter{
+		logger:         logger,
+		conf:           conf,
+		sourceMapStore: sourceMapStore,
+		grouping:       grouping,
+		newProducer: func() (sarama.SyncProducer, error) {
+			return sarama.NewSyncProducer(conf.Brokers, sc)
+		},
+	}, nil
+}
+
+// Name of the exporter, for logging purposes
+func (ke *KafkaExporter) Name() string {
+	return "kafka exporter"
+}
+
+// Export implements the AppDataExporter interface
+func (ke *KafkaExporter) Export(ctx context.Context, payload Payload) error {
+	msgs, err := ke.messages(payload)
+	if err != nil || len(msgs) == 0 {
+		return err
+	}
+
+	producer, err := ke.getProducer()
+	if err != nil {
+		return err
+	}
+	return producer.SendMessages(msgs)
+}
+
+// messages converts payload into the messages to publish
+func (ke *KafkaExporter) messages(payload Payload) ([]*sarama.ProducerMessage, error) {
+	var key sarama.Encoder
+	switch ke.conf.PartitionBy {
+	case KafkaPartitionBySession:
+		if id := payload.Meta.Session.ID; id != "" {
+			key = sarama.StringEncoder(id)
+		}
+	case KafkaPartitionByApp:
+		if name := payload.Meta.App.Name; name != "" {
+			key = sarama.StringEncoder(name)
+		}
+	}
+
+	var values []interface{}
+	if ke.conf.Format == KafkaFormatPayload {
+		values = append(values, payload)
+	} else {
+		meta := payload.Meta.KeyVal()
+		addEvent := func(kv *KeyVal) {
+			MergeKeyVal(kv, meta)
+			values = append(values, KeyValToInterfaceMap(kv))
+		}
+		for _, logItem := range payload.Logs {
+			addEvent(logItem.KeyVal())
+		}
+		for _, exception := range payload.Exceptions {
+			transformedException := TransformException(ke.sourceMapStore, ke.logger, &exception, payload.Meta.App.Name, payload.Meta.App.Release)
+			groupException(ke.grouping, transformedException)
+			addEvent(transformedException.KeyVal())
+		}
+		for _, measurement := range payload.Measurements {
+			addEvent(measurement.KeyVal())
+		}
+	}
+
+	msgs := make([]*sarama.ProducerMessage, 0, len(values))
+	for _, v := range values {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, &sarama.ProducerMessage{
+			Topic: ke.conf.Topic,
+			Key:   key,
+			Value: sarama.ByteEncoder(b),
+		})
+	}
+	return msgs, nil
+}
+
+// getProducer returns the producer of the exporter, connecting it if needed
+func (ke *KafkaExporter) getProducer() (sarama.SyncProducer, error) {
+	ke.mut.Lock()
+	defer ke.mut.Unlock()
+
+	if ke.producer == nil {
+		producer, err := ke.newProducer()
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to kafka: %w", err)
+		}
+		ke.producer = producer
+	}
+	return ke.producer, nil
+}
+
+// Close closes the producer of the exporter, if it is connected
+func (ke *KafkaExporter) Close() error {
+	ke.mut.Lock()
+	defer ke.mut.Unlock()
+
+	if ke.producer == nil {
+		return nil
+	}
+	err := ke.producer.Close()
+	ke.producer = nil
+	return err
+}
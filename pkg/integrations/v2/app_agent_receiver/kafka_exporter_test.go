@@ -0,0 +1,119 @@
+package app_agent_receiver
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+type mockSyncProducer struct {
+	sent   []*sarama.ProducerMessage
+	closed bool
+}
+
+func (p *mockSyncProducer) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	p.sent = append(p.sent, msg)
+	return 0, int64(len(p.sent)), nil
+}
+
+func (p *mockSyncProducer) SendMessages(msgs []*sarama.ProducerMessage) error {
+	p.sent = append(p.sent, msgs...)
+	return nil
+}
+
+func (p *mockSyncProducer) Close() error {
+	p.closed = true
+	return nil
+}
+
+func newTestKafkaExporter(t *testing.T, conf KafkaExporterConfig) (*KafkaExporter, *mockSyncProducer) {
+	t.Helper()
+	exporter, err := NewKafkaExporter(log.NewNopLogger(), conf, nil, nil)
+	require.NoError(t, err)
+
+	producer := &mockSyncProducer{}
+	exporter.newProducer = func() (sarama.SyncProducer, error) { return producer, nil }
+	return exporter, producer
+}
+
+func TestKafkaExporter_Payload(t *testing.T) {
+	conf := DefaultKafkaExporterConfig
+	conf.Brokers = []string{"localhost:9092"}
+	conf.Topic = "rum"
+	exporter, producer := newTestKafkaExporter(t, conf)
+
+	var payload Payload
+	require.NoError(t, json.Unmarshal(loadTestData(t, "payload.json"), &payload))
+	require.NoError(t, exporter.Export(context.Background(), payload))
+
+	require.Len(t, producer.sent, 1)
+	msg := producer.sent[0]
+	require.Equal(t, "rum", msg.Topic)
+	require.Equal(t, sarama.StringEncoder("abcd"), msg.Key)
+
+	value, err := msg.Value.Encode()
+	require.NoError(t, err)
+	var sent Payload
+	require.NoError(t, json.Unmarshal(value, &sent))
+	require.Equal(t, payload.Meta, sent.Meta)
+	require.Len(t, sent.Exceptions, len(payload.Exceptions))
+
+	require.NoError(t, exporter.Close())
+	require.True(t, producer.closed)
+}
+
+func TestKafkaExporter_Events(t *testing.T) {
+	conf := DefaultKafkaExporterConfig
+	conf.Brokers = []string{"localhost:9092"}
+	conf.Topic = "rum"
+	conf.Format = KafkaFormatEvents
+	conf.PartitionBy = KafkaPartitionByApp
+	exporter, producer := newTestKafkaExporter(t, conf)
+
+	payload := Payload{
+		Logs:         []Log{{Message: "hello", LogLevel: "info"}},
+		Measurements: []Measurement{{Values: map[string]float64{"ttfb": 120}}},
+		Meta:         Meta{App: App{Name: "shop"}},
+	}
+	require.NoError(t, exporter.Export(context.Background(), payload))
+
+	require.Len(t, producer.sent, 2)
+	for _, msg := range producer.sent {
+		require.Equal(t, sarama.StringEncoder("shop"), msg.Key)
+	}
+
+	value, err := producer.sent[0].Value.Encode()
+	require.NoError(t, err)
+	var event map[string]interface{}
+	require.NoError(t, json.Unmarshal(value, &event))
+	require.Equal(t, "log", event["kind"])
+	require.Equal(t, "hello", event["message"])
+	require.Equal(t, "shop", event["app_name"])
+
+	// Payloads without events aren't published.
+	require.NoError(t, exporter.Export(context.Background(), Payload{}))
+	require.Len(t, producer.sent, 2)
+}
+
+func TestConfig_Kafka(t *testing.T) {
+	var cfg Config
+	cb := `
+kafka:
+  brokers: [localhost:9092]
+  topic: rum`
+	require.NoError(t, yaml.Unmarshal([]byte(cb), &cfg))
+	require.Equal(t, KafkaFormatPayload, cfg.Kafka.Format)
+	require.Equal(t, KafkaPartitionBySession, cfg.Kafka.PartitionBy)
+
+	cb = `
+kafka:
+  brokers: [localhost:9092]
+  topic: rum
+  partition_by: user`
+	require.EqualError(t, yaml.Unmarshal([]byte(cb), &cfg), `unsupported kafka partition_by "user", expected session, app or none`)
+}
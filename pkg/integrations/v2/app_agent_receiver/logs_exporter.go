@@ -14,19 +14,24 @@ import (
 	prommodel "github.com/prometheus/common/model"
 )
 
-// logsInstance is an interface with capability to send log entries
-type logsInstance interface {
+// LogsInstance is an interface with capability to send log entries
+type LogsInstance interface {
 	SendEntry(entry api.Entry, dur time.Duration) bool
 }
 
-// logsInstanceGetter is a function that returns a LogsInstance to send log entries to
-type logsInstanceGetter func() (logsInstance, error)
+// logsInstanceGetter is a function that returns a LogsInstance to send the
+// log entries of a payload type (PayloadTypeLogs, PayloadTypeExceptions or
+// PayloadTypeMeasurements) to. Entries of the type are dropped if the
+// instance is nil
+type logsInstanceGetter func(ctx context.Context, payloadType string) (LogsInstance, error)
 
 // LogsExporterConfig holds the configuration of the logs exporter
 type LogsExporterConfig struct {
 	SendEntryTimeout time.Duration
 	GetLogsInstance  logsInstanceGetter
 	Labels           map[string]string
+	// ExceptionGrouping adds a fingerprint to exceptions if set
+	ExceptionGrouping *ExceptionGroupingConfig
 }
 
 // LogsExporter will send logs & errors to loki
@@ -36,17 +41,19 @@ type LogsExporter struct {
 	logger           kitlog.Logger
 	labels           map[string]string
 	sourceMapStore   SourceMapStore
+	grouping         *ExceptionGroupingConfig
 }
 
 // NewLogsExporter creates a new logs exporter with the given
 // configuration
-func NewLogsExporter(logger kitlog.Logger, conf LogsExporterConfig, sourceMapStore SourceMapStore) appAgentReceiverExporter {
+func NewLogsExporter(logger kitlog.Logger, conf LogsExporterConfig, sourceMapStore SourceMapStore) AppAgentReceiverExporter {
 	return &LogsExporter{
 		logger:           logger,
 		getLogsInstance:  conf.GetLogsInstance,
 		sendEntryTimeout: conf.SendEntryTimeout,
 		labels:           conf.Labels,
 		sourceMapStore:   sourceMapStore,
+		grouping:         conf.ExceptionGrouping,
 	}
 }
 
@@ -62,40 +69,58 @@ func (le *LogsExporter) Export(ctx context.Context, payload Payload) error {
 	var err error
 
 	// log events
-	for _, logItem := range payload.Logs {
-		kv := logItem.KeyVal()
-		MergeKeyVal(kv, meta)
-		err = le.sendKeyValsToLogsPipeline(kv)
+	if len(payload.Logs) > 0 {
+		instance, ierr := le.getLogsInstance(ctx, PayloadTypeLogs)
+		if ierr != nil {
+			err = ierr
+		} else if instance != nil {
+			for _, logItem := range payload.Logs {
+				kv := logItem.KeyVal()
+				MergeKeyVal(kv, meta)
+				err = le.sendKeyValsToLogsPipeline(instance, kv)
+			}
+		}
 	}
 
 	// exceptions
-	for _, exception := range payload.Exceptions {
-		transformedException := TransformException(le.sourceMapStore, le.logger, &exception, payload.Meta.App.Release)
-		kv := transformedException.KeyVal()
-		MergeKeyVal(kv, meta)
-		err = le.sendKeyValsToLogsPipeline(kv)
+	if len(payload.Exceptions) > 0 {
+		instance, ierr := le.getLogsInstance(ctx, PayloadTypeExceptions)
+		if ierr != nil {
+			err = ierr
+		} else if instance != nil {
+			for _, exception := range payload.Exceptions {
+				transformedException := TransformException(le.sourceMapStore, le.logger, &exception, payload.Meta.App.Name, payload.Meta.App.Release)
+				groupException(le.grouping, transformedException)
+				kv := transformedException.KeyVal()
+				MergeKeyVal(kv, meta)
+				err = le.sendKeyValsToLogsPipeline(instance, kv)
+			}
+		}
 	}
 
 	// measurements
-	for _, measurement := range payload.Measurements {
-		kv := measurement.KeyVal()
-		MergeKeyVal(kv, meta)
-		err = le.sendKeyValsToLogsPipeline(kv)
+	if len(payload.Measurements) > 0 {
+		instance, ierr := le.getLogsInstance(ctx, PayloadTypeMeasurements)
+		if ierr != nil {
+			err = ierr
+		} else if instance != nil {
+			for _, measurement := range payload.Measurements {
+				kv := measurement.KeyVal()
+				MergeKeyVal(kv, meta)
+				err = le.sendKeyValsToLogsPipeline(instance, kv)
+			}
+		}
 	}
 
 	return err
 }
 
-func (le *LogsExporter) sendKeyValsToLogsPipeline(kv *KeyVal) error {
+func (le *LogsExporter) sendKeyValsToLogsPipeline(instance LogsInstance, kv *KeyVal) error {
 	line, err := logfmt.MarshalKeyvals(KeyValToInterfaceSlice(kv)...)
 	if err != nil {
 		level.Error(le.logger).Log("msg", "failed to logfmt a frontend log event", "err", err)
 		return err
 	}
-	instance, err := le.getLogsInstance()
-	if err != nil {
-		return err
-	}
 	sent := instance.SendEntry(api.Entry{
 		Labels: le.labelSet(kv),
 		Entry: logproto.Entry{
@@ -128,6 +153,6 @@ func (le *LogsExporter) labelSet(kv *KeyVal) prommodel.LabelSet {
 
 // Static typecheck tests
 var (
-	_ appAgentReceiverExporter = (*LogsExporter)(nil)
-	_ logsInstance             = (*logs.Instance)(nil)
+	_ AppAgentReceiverExporter = (*LogsExporter)(nil)
+	_ LogsInstance             = (*logs.Instance)(nil)
 )
@@ -38,7 +38,7 @@ func (i *testLogsInstance) SendEntry(entry api.Entry, dur time.Duration) bool {
 
 type MockSourceMapStore struct{}
 
-func (store *MockSourceMapStore) GetSourceMap(sourceURL string, release string) (*SourceMap, error) {
+func (store *MockSourceMapStore) GetSourceMap(sourceURL string, app string, release string) (*SourceMap, error) {
 	return nil, nil
 }
 
@@ -53,7 +53,7 @@ func TestExportLogs(t *testing.T) {
 	logsExporter := NewLogsExporter(
 		logger,
 		LogsExporterConfig{
-			GetLogsInstance: func() (logsInstance, error) { return inst, nil },
+			GetLogsInstance: func(context.Context, string) (LogsInstance, error) { return inst, nil },
 			Labels: map[string]string{
 				"app":  "frontend",
 				"kind": "",
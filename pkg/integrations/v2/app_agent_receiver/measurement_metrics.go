@@ -0,0 +1,165 @@
+package app_agent_receiver
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const measurementMetricPrefix = "app_agent_receiver_measurement_"
+
+// DefaultMeasurementMetricsConfig holds the default values of a
+// MeasurementMetricsConfig. Most measurements sent by frontend agents are
+// timings in milliseconds, except for the cumulative layout shift (cls)
+// score of Web Vitals
+var DefaultMeasurementMetricsConfig = MeasurementMetricsConfig{
+	DefaultBuckets: []float64{50, 100, 250, 500, 1000, 2500, 5000, 10000},
+	Buckets: map[string][]float64{
+		"cls": {0.01, 0.05, 0.1, 0.15, 0.25, 0.5, 1},
+	},
+	MaxNames: 100,
+}
+
+// MeasurementMetricsConfig configures the conversion of measurements, such as
+// Web Vitals and custom timings, into histograms
+type MeasurementMetricsConfig struct {
+	// DefaultBuckets are the buckets of measurements without an entry in
+	// Buckets
+	DefaultBuckets []float64            `yaml:"default_buckets,omitempty"`
+	Buckets        map[string][]float64 `yaml:"buckets,omitempty"`
+
+	// MaxNames is the number of distinct measurement names which are
+	// converted. Measurement names are chosen by apps, so they are limited to
+	// bound the number of metrics
+	MaxNames int `yaml:"max_names,omitempty"`
+}
+
+// UnmarshalYAML implements the Unmarshaler interface
+func (c *MeasurementMetricsConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultMeasurementMetricsConfig
+	// Copy the default buckets so configured buckets aren't added to them
+	c.Buckets = make(map[string][]float64, len(DefaultMeasurementMetricsConfig.Buckets))
+	for name, buckets := range DefaultMeasurementMetricsConfig.Buckets {
+		c.Buckets[name] = buckets
+	}
+
+	type plain MeasurementMetricsConfig
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	if c.MaxNames <= 0 {
+		return fmt.Errorf("max_names must be greater than 0")
+	}
+	if err := validateBuckets(c.DefaultBuckets); err != nil {
+		return fmt.Errorf("invalid default_buckets: %w", err)
+	}
+	for name, buckets := range c.Buckets {
+		if err := validateBuckets(buckets); err != nil {
+			return fmt.Errorf("invalid buckets for measurement %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func validateBuckets(buckets []float64) error {
+	if len(buckets) == 0 {
+		return fmt.Errorf("at least one bucket is required")
+	}
+	if !sort.Float64sAreSorted(buckets) {
+		return fmt.Errorf("buckets must be in increasing order")
+	}
+	return nil
+}
+
+// MeasurementMetricsExporter converts the values of measurements into
+// histograms named after the value, labeled by app
+type MeasurementMetricsExporter struct {
+	conf MeasurementMetricsConfig
+	reg  prometheus.Registerer
+
+	mut        sync.Mutex
+	histograms map[string]*prometheus.HistogramVec // By metric name
+
+	untracked prometheus.Counter
+}
+
+// NewMeasurementMetricsExporter creates a new MeasurementMetricsExporter
+// which registers histograms to reg
+func NewMeasurementMetricsExporter(conf MeasurementMetricsConfig, reg prometheus.Registerer) AppAgentReceiverExporter {
+	exp := &MeasurementMetricsExporter{
+		conf:       conf,
+		reg:        reg,
+		histograms: make(map[string]*prometheus.HistogramVec),
+
+		untracked: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "app_agent_receiver_measurement_values_untracked_total",
+			Help: "Total number of measurement values which were not converted to metrics because max_names was reached",
+		}),
+	}
+	reg.MustRegister(exp.untracked)
+	return exp
+}
+
+// Name of the exporter, for logging purposes
+func (me *MeasurementMetricsExporter) Name() string {
+	return "measurement metrics exporter"
+}
+
+// Export implements the AppDataExporter interface
+func (me *MeasurementMetricsExporter) Export(ctx context.Context, payload Payload) error {
+	me.mut.Lock()
+	defer me.mut.Unlock()
+
+	for _, measurement := range payload.Measurements {
+		for name, value := range measurement.Values {
+			h := me.histogram(name)
+			if h == nil {
+				me.untracked.Inc()
+				continue
+			}
+			h.WithLabelValues(payload.Meta.App.Name).Observe(value)
+		}
+	}
+	return nil
+}
+
+// histogram returns the histogram of the measurement name, creating it if
+// needed. histogram returns nil if the histogram can't be created.
+func (me *MeasurementMetricsExporter) histogram(name string) *prometheus.HistogramVec {
+	metricName := measurementMetricPrefix + sanitizeMetricName(name)
+	if h, ok := me.histograms[metricName]; ok {
+		return h
+	}
+	if len(me.histograms) >= me.conf.MaxNames {
+		return nil
+	}
+
+	buckets, ok := me.conf.Buckets[name]
+	if !ok {
+		buckets = me.conf.DefaultBuckets
+	}
+	h := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    metricName,
+		Help:    fmt.Sprintf("Values of the %s measurement sent by apps", name),
+		Buckets: buckets,
+	}, []string{"app"})
+	if err := me.reg.Register(h); err != nil {
+		return nil
+	}
+	me.histograms[metricName] = h
+	return h
+}
+
+var reInvalidMetricChars = regexp.MustCompile("[^a-zA-Z0-9_]")
+
+// sanitizeMetricName replaces characters of name which aren't valid in
+// metric names
+func sanitizeMetricName(name string) string {
+	return reInvalidMetricChars.ReplaceAllString(strings.ToLower(name), "_")
+}
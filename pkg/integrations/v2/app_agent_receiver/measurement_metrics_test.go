@@ -0,0 +1,84 @@
+package app_agent_receiver
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestMeasurementMetricsExporter(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	conf := MeasurementMetricsConfig{
+		DefaultBuckets: []float64{100, 1000},
+		Buckets:        map[string][]float64{"cls": {0.1, 1}},
+		MaxNames:       2,
+	}
+	exporter := NewMeasurementMetricsExporter(conf, reg)
+
+	payload := Payload{
+		Measurements: []Measurement{
+			{Values: map[string]float64{"cls": 0.05, "ttfb": 120}},
+			{Values: map[string]float64{"cls": 0.2}},
+		},
+		Meta: Meta{App: App{Name: "shop"}},
+	}
+	require.NoError(t, exporter.Export(context.Background(), payload))
+
+	// The limit of measurement names was reached.
+	payload = Payload{
+		Measurements: []Measurement{{Values: map[string]float64{"my.timing": 10}}},
+		Meta:         Meta{App: App{Name: "shop"}},
+	}
+	require.NoError(t, exporter.Export(context.Background(), payload))
+
+	expect := `
+# HELP app_agent_receiver_measurement_cls Values of the cls measurement sent by apps
+# TYPE app_agent_receiver_measurement_cls histogram
+app_agent_receiver_measurement_cls_bucket{app="shop",le="0.1"} 1
+app_agent_receiver_measurement_cls_bucket{app="shop",le="1"} 2
+app_agent_receiver_measurement_cls_bucket{app="shop",le="+Inf"} 2
+app_agent_receiver_measurement_cls_sum{app="shop"} 0.25
+app_agent_receiver_measurement_cls_count{app="shop"} 2
+# HELP app_agent_receiver_measurement_ttfb Values of the ttfb measurement sent by apps
+# TYPE app_agent_receiver_measurement_ttfb histogram
+app_agent_receiver_measurement_ttfb_bucket{app="shop",le="100"} 0
+app_agent_receiver_measurement_ttfb_bucket{app="shop",le="1000"} 1
+app_agent_receiver_measurement_ttfb_bucket{app="shop",le="+Inf"} 1
+app_agent_receiver_measurement_ttfb_sum{app="shop"} 120
+app_agent_receiver_measurement_ttfb_count{app="shop"} 1
+# HELP app_agent_receiver_measurement_values_untracked_total Total number of measurement values which were not converted to metrics because max_names was reached
+# TYPE app_agent_receiver_measurement_values_untracked_total counter
+app_agent_receiver_measurement_values_untracked_total 1
+`
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expect)))
+}
+
+func TestSanitizeMetricName(t *testing.T) {
+	require.Equal(t, "my_custom_timing", sanitizeMetricName("My.Custom-Timing"))
+}
+
+func TestConfig_MeasurementMetrics(t *testing.T) {
+	var cfg Config
+	cb := `
+measurement_metrics:
+  buckets:
+    lcp: [1000, 2500, 4000]`
+	require.NoError(t, yaml.Unmarshal([]byte(cb), &cfg))
+
+	require.Equal(t, []float64{1000, 2500, 4000}, cfg.MeasurementMetrics.Buckets["lcp"])
+	require.Equal(t, DefaultMeasurementMetricsConfig.Buckets["cls"], cfg.MeasurementMetrics.Buckets["cls"])
+	require.Equal(t, DefaultMeasurementMetricsConfig.DefaultBuckets, cfg.MeasurementMetrics.DefaultBuckets)
+	// The defaults must not be modified.
+	require.NotContains(t, DefaultMeasurementMetricsConfig.Buckets, "lcp")
+
+	cb = `
+measurement_metrics:
+  buckets:
+    lcp: [4000, 1000]`
+	require.EqualError(t, yaml.Unmarshal([]byte(cb), &cfg), `invalid buckets for measurement "lcp": buckets must be in increasing order`)
+}
@@ -0,0 +1,295 @@
+package app_agent_receiver
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	kitlog "github.com/go-kit/log"
+	config_util "github.com/prometheus/common/config"
+	otlp "go.opentelemetry.io/collector/model/otlp"
+	otelpdata "go.opentelemetry.io/collector/model/pdata"
+)
+
+// instrumentationName is the instrumentation library name set on data
+// converted by the OTLP exporter
+const instrumentationName = "app_agent_receiver"
+
+// OTLPExporterConfig holds the configuration of the OTLP exporter
+type OTLPExporterConfig struct {
+	// Endpoint is the base URL of an OTLP/HTTP receiver. Signals are sent to
+	// the /v1/logs, /v1/metrics and /v1/traces paths below it.
+	Endpoint  string                        `yaml:"endpoint"`
+	Headers   map[string]config_util.Secret `yaml:"headers,omitempty"`
+	TLSConfig config_util.TLSConfig         `yaml:"tls_config,omitempty"`
+	Timeout   time.Duration                 `yaml:"timeout,omitempty"`
+}
+
+// OTLPExporter converts all payload kinds into OTLP logs, metrics and traces
+// and sends them to an OTLP/HTTP endpoint
+type OTLPExporter struct {
+	logger         kitlog.Logger
+	client         *http.Client
+	endpoint       string
+	headers        map[string]config_util.Secret
+	timeout        time.Duration
+	sourceMapStore SourceMapStore
+	grouping       *ExceptionGroupingConfig
+}
+
+// NewOTLPExporter creates a new OTLP exporter with the given configuration.
+// Exceptions are fingerprinted if grouping is not nil
+func NewOTLPExporter(logger kitlog.Logger, conf OTLPExporterConfig, sourceMapStore SourceMapStore, grouping *ExceptionGroupingConfig) (AppAgentReceiverExporter, error) {
+	tlsConfig, err := config_util.NewTLSConfig(&conf.TLSConfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid otlp tls_config: %w", err)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return &OTLPExporter{
+		logger:         logger,
+		client:         &http.Client{Transport: transport},
+		endpoint:       strings.TrimSuffix(conf.Endpoint, "/"),
+		headers:        conf.Headers,
+		timeout:        conf.Timeout,
+		sourceMapStore: sourceMapStore,
+		grouping:       grouping,
+	}, nil
+}
+
+// Name of the exporter, for logging purposes
+func (oe *OTLPExporter) Name() string {
+	return "otlp exporter"
+}
+
+// Export implements the AppDataExporter interface
+func (oe *OTLPExporter) Export(ctx context.Context, payload Payload) error {
+	var firstErr error
+	setErr := func(err error) {
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	if len(payload.Logs) > 0 || len(payload.Exceptions) > 0 {
+		body, err := otlp.NewProtobufLogsMarshaler().MarshalLogs(oe.payloadToLogs(payload))
+		if err == nil {
+			err = oe.send(ctx, "/v1/logs", body)
+		}
+		setErr(err)
+	}
+
+	if len(payload.Measurements) > 0 {
+		body, err := otlp.NewProtobufMetricsMarshaler().MarshalMetrics(payloadToMetrics(payload))
+		if err == nil {
+			err = oe.send(ctx, "/v1/metrics", body)
+		}
+		setErr(err)
+	}
+
+	if payload.Traces != nil && payload.Traces.SpanCount() > 0 {
+		body, err := otlp.NewProtobufTracesMarshaler().MarshalTraces(payload.Traces.Traces)
+		if err == nil {
+			err = oe.send(ctx, "/v1/traces", body)
+		}
+		setErr(err)
+	}
+
+	return firstErr
+}
+
+func (oe *OTLPExporter) send(ctx context.Context, path string, body []byte) error {
+	if oe.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, oe.timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, oe.endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	for k, v := range oe.headers {
+		req.Header.Set(k, string(v))
+	}
+
+	resp, err := oe.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("otlp endpoint %s responded with status %s", path, resp.Status)
+	}
+	return nil
+}
+
+// payloadToLogs converts logs and exceptions of a payload into OTLP logs.
+// Exceptions are recorded following the OpenTelemetry semantic conventions for
+// exceptions.
+func (oe *OTLPExporter) payloadToLogs(payload Payload) otelpdata.Logs {
+	ld := otelpdata.NewLogs()
+	rl := ld.ResourceLogs().AppendEmpty()
+	metaToResource(payload.Meta, rl.Resource())
+	ill := rl.InstrumentationLibraryLogs().AppendEmpty()
+	ill.InstrumentationLibrary().SetName(instrumentationName)
+	records := ill.LogRecords()
+
+	for _, logItem := range payload.Logs {
+		lr := records.AppendEmpty()
+		lr.SetTimestamp(otelpdata.NewTimestampFromTime(logItem.Timestamp))
+		lr.SetSeverityText(string(logItem.LogLevel))
+		lr.SetSeverityNumber(logLevelToSeverity(logItem.LogLevel))
+		lr.Body().SetStringVal(logItem.Message)
+		setTraceContext(lr, logItem.Trace)
+
+		attrs := lr.Attributes()
+		attrs.InsertString("kind", "log")
+		for k, v := range logItem.Context {
+			attrs.InsertString("context."+k, v)
+		}
+	}
+
+	for _, exception := range payload.Exceptions {
+		transformed := TransformException(oe.sourceMapStore, oe.logger, &exception, payload.Meta.App.Name, payload.Meta.App.Release)
+		groupException(oe.grouping, transformed)
+
+		lr := records.AppendEmpty()
+		lr.SetTimestamp(otelpdata.NewTimestampFromTime(transformed.Timestamp))
+		lr.SetSeverityText(string(LogLevelError))
+		lr.SetSeverityNumber(otelpdata.SeverityNumberERROR)
+		lr.Body().SetStringVal(transformed.Message())
+		setTraceContext(lr, transformed.Trace)
+
+		attrs := lr.Attributes()
+		attrs.InsertString("kind", "exception")
+		attrs.InsertString("exception.type", transformed.Type)
+		attrs.InsertString("exception.message", transformed.Value)
+		attrs.InsertString("exception.stacktrace", transformed.String())
+		if transformed.Fingerprint != "" {
+			attrs.InsertString("exception.fingerprint", transformed.Fingerprint)
+		}
+	}
+
+	return ld
+}
+
+// payloadToMetrics converts measurements of a payload into OTLP gauges, one
+// per measurement value.
+func payloadToMetrics(payload Payload) otelpdata.Metrics {
+	md := otelpdata.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	metaToResource(payload.Meta, rm.Resource())
+	ilm := rm.InstrumentationLibraryMetrics().AppendEmpty()
+	ilm.InstrumentationLibrary().SetName(instrumentationName)
+
+	for _, measurement := range payload.Measurements {
+		for name, value := range measurement.Values {
+			m := ilm.Metrics().AppendEmpty()
+			m.SetName(name)
+			m.SetDataType(otelpdata.MetricDataTypeGauge)
+
+			dp := m.Gauge().DataPoints().AppendEmpty()
+			dp.SetTimestamp(otelpdata.NewTimestampFromTime(measurement.Timestamp))
+			dp.SetDoubleVal(value)
+			if measurement.Trace.TraceID != "" {
+				dp.Attributes().InsertString("trace_id", measurement.Trace.TraceID)
+			}
+			if measurement.Trace.SpanID != "" {
+				dp.Attributes().InsertString("span_id", measurement.Trace.SpanID)
+			}
+		}
+	}
+
+	return md
+}
+
+// metaToResource sets attributes on res from the payload metadata, using the
+// OpenTelemetry semantic conventions where one exists.
+func metaToResource(meta Meta, res otelpdata.Resource) {
+	attrs := res.Attributes()
+	insert := func(k, v string) {
+		if v != "" {
+			attrs.InsertString(k, v)
+		}
+	}
+
+	insert("service.name", meta.App.Name)
+	insert("service.version", meta.App.Version)
+	insert("app.release", meta.App.Release)
+	insert("deployment.environment", meta.App.Environment)
+
+	insert("telemetry.sdk.name", meta.SDK.Name)
+	insert("telemetry.sdk.version", meta.SDK.Version)
+
+	insert("enduser.id", meta.User.ID)
+	insert("user.email", meta.User.Email)
+	insert("user.username", meta.User.Username)
+	for k, v := range meta.User.Attributes {
+		insert("user.attr."+k, v)
+	}
+
+	insert("session.id", meta.Session.ID)
+	for k, v := range meta.Session.Attributes {
+		insert("session.attr."+k, v)
+	}
+
+	insert("page.id", meta.Page.ID)
+	insert("page.url", meta.Page.URL)
+	for k, v := range meta.Page.Attributes {
+		insert("page.attr."+k, v)
+	}
+
+	insert("browser.name", meta.Browser.Name)
+	insert("browser.version", meta.Browser.Version)
+	insert("browser.os", meta.Browser.OS)
+	if meta.Browser.Name != "" {
+		attrs.InsertBool("browser.mobile", meta.Browser.Mobile)
+	}
+	insert("browser.device_type", meta.Browser.DeviceType)
+	insert("browser.device_model", meta.Browser.DeviceModel)
+
+	insert("view.name", meta.View.Name)
+}
+
+// setTraceContext links lr to the trace and span in tc. Invalid IDs are
+// ignored.
+func setTraceContext(lr otelpdata.LogRecord, tc TraceContext) {
+	var traceID [16]byte
+	if b, err := hex.DecodeString(tc.TraceID); err == nil && len(b) == len(traceID) {
+		copy(traceID[:], b)
+		lr.SetTraceID(otelpdata.NewTraceID(traceID))
+	}
+
+	var spanID [8]byte
+	if b, err := hex.DecodeString(tc.SpanID); err == nil && len(b) == len(spanID) {
+		copy(spanID[:], b)
+		lr.SetSpanID(otelpdata.NewSpanID(spanID))
+	}
+}
+
+func logLevelToSeverity(l LogLevel) otelpdata.SeverityNumber {
+	switch l {
+	case LogLevelTrace:
+		return otelpdata.SeverityNumberTRACE
+	case LogLevelDebug:
+		return otelpdata.SeverityNumberDEBUG
+	case LogLevelInfo:
+		return otelpdata.SeverityNumberINFO
+	case LogLevelWarning:
+		return otelpdata.SeverityNumberWARN
+	case LogLevelError:
+		return otelpdata.SeverityNumberERROR
+	default:
+		return otelpdata.SeverityNumberUNDEFINED
+	}
+}
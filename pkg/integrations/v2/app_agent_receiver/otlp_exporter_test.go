@@ -0,0 +1,98 @@
+package app_agent_receiver
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	kitlog "github.com/go-kit/log"
+	config_util "github.com/prometheus/common/config"
+	"github.com/stretchr/testify/require"
+	otlp "go.opentelemetry.io/collector/model/otlp"
+	otelpdata "go.opentelemetry.io/collector/model/pdata"
+)
+
+func TestExportOTLP(t *testing.T) {
+	var (
+		mut    sync.Mutex
+		bodies = map[string][]byte{}
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+		require.Equal(t, "secret", r.Header.Get("Authorization"))
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		mut.Lock()
+		bodies[r.URL.Path] = body
+		mut.Unlock()
+	}))
+	defer srv.Close()
+
+	exporter, err := NewOTLPExporter(kitlog.NewNopLogger(), OTLPExporterConfig{
+		Endpoint: srv.URL + "/",
+		Headers:  map[string]config_util.Secret{"Authorization": "secret"},
+	}, &MockSourceMapStore{}, nil)
+	require.NoError(t, err)
+
+	payload := loadTestPayload(t)
+	require.NoError(t, exporter.Export(context.Background(), payload))
+
+	// Logs and exceptions
+	ld, err := otlp.NewProtobufLogsUnmarshaler().UnmarshalLogs(bodies["/v1/logs"])
+	require.NoError(t, err)
+	require.Equal(t, 3, ld.LogRecordCount())
+
+	rl := ld.ResourceLogs().At(0)
+	serviceName, _ := rl.Resource().Attributes().Get("service.name")
+	require.Equal(t, "testapp", serviceName.StringVal())
+	sessionID, _ := rl.Resource().Attributes().Get("session.id")
+	require.Equal(t, "abcd", sessionID.StringVal())
+
+	records := rl.InstrumentationLibraryLogs().At(0).LogRecords()
+	require.Equal(t, "opened pricing page", records.At(0).Body().StringVal())
+	require.Equal(t, otelpdata.SeverityNumberINFO, records.At(0).SeverityNumber())
+
+	exception := records.At(2)
+	require.Equal(t, otelpdata.SeverityNumberERROR, exception.SeverityNumber())
+	exceptionType, _ := exception.Attributes().Get("exception.type")
+	require.Equal(t, payload.Exceptions[0].Type, exceptionType.StringVal())
+
+	// Measurements
+	md, err := otlp.NewProtobufMetricsUnmarshaler().UnmarshalMetrics(bodies["/v1/metrics"])
+	require.NoError(t, err)
+	require.Equal(t, 3, md.MetricCount())
+
+	metrics := md.ResourceMetrics().At(0).InstrumentationLibraryMetrics().At(0).Metrics()
+	values := map[string]float64{}
+	for i := 0; i < metrics.Len(); i++ {
+		m := metrics.At(i)
+		require.Equal(t, otelpdata.MetricDataTypeGauge, m.DataType())
+		values[m.Name()] = m.Gauge().DataPoints().At(0).DoubleVal()
+	}
+	require.Equal(t, payload.Measurements[0].Values, values)
+
+	// Traces
+	td, err := otlp.NewProtobufTracesUnmarshaler().UnmarshalTraces(bodies["/v1/traces"])
+	require.NoError(t, err)
+	require.Equal(t, payload.Traces.SpanCount(), td.SpanCount())
+}
+
+func TestExportOTLP_Error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	exporter, err := NewOTLPExporter(kitlog.NewNopLogger(), OTLPExporterConfig{
+		Endpoint: srv.URL,
+	}, &MockSourceMapStore{}, nil)
+	require.NoError(t, err)
+
+	err = exporter.Export(context.Background(), loadTestPayload(t))
+	require.Error(t, err)
+}
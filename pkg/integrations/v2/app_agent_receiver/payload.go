@@ -49,6 +49,10 @@ type Exception struct {
 	Stacktrace *Stacktrace  `json:"stacktrace,omitempty"`
 	Timestamp  time.Time    `json:"timestamp"`
 	Trace      TraceContext `json:"trace,omitempty"`
+
+	// Fingerprint is the group hash computed by the receiver, if exception
+	// grouping is enabled
+	Fingerprint string `json:"-"`
 }
 
 // Message string is concatenating of the Exception.Type and Exception.Value
@@ -75,6 +79,7 @@ func (e Exception) KeyVal() *KeyVal {
 	KeyValAdd(kv, "type", e.Type)
 	KeyValAdd(kv, "value", e.Value)
 	KeyValAdd(kv, "stacktrace", e.String())
+	KeyValAdd(kv, "fingerprint", e.Fingerprint)
 	MergeKeyVal(kv, e.Trace.KeyVal())
 	return kv
 }
@@ -284,6 +289,9 @@ type Meta struct {
 	Session Session `json:"session,omitempty"`
 	Page    Page    `json:"page,omitempty"`
 	Browser Browser `json:"browser,omitempty"`
+	View    View    `json:"view,omitempty"`
+	// Geo is set by the receiver from the IP address of the client
+	Geo Geo `json:"geo,omitempty"`
 }
 
 // KeyVal produces key->value representation of the app event metadatga
@@ -295,6 +303,8 @@ func (m Meta) KeyVal() *KeyVal {
 	MergeKeyValWithPrefix(kv, m.Session.KeyVal(), "session_")
 	MergeKeyValWithPrefix(kv, m.Page.KeyVal(), "page_")
 	MergeKeyValWithPrefix(kv, m.Browser.KeyVal(), "browser_")
+	MergeKeyValWithPrefix(kv, m.View.KeyVal(), "view_")
+	MergeKeyValWithPrefix(kv, m.Geo.KeyVal(), "geo_")
 	return kv
 }
 
@@ -352,6 +362,11 @@ type Browser struct {
 	Version string `json:"version,omitempty"`
 	OS      string `json:"os,omitempty"`
 	Mobile  bool   `json:"mobile,omitempty"`
+
+	// DeviceType and DeviceModel are set by the receiver from the
+	// User-Agent header
+	DeviceType  string `json:"device_type,omitempty"`
+	DeviceModel string `json:"device_model,omitempty"`
 }
 
 // KeyVal produces key->value representation of the Browser metadata
@@ -361,5 +376,38 @@ func (b Browser) KeyVal() *KeyVal {
 	KeyValAdd(kv, "version", b.Version)
 	KeyValAdd(kv, "os", b.OS)
 	KeyValAdd(kv, "mobile", fmt.Sprintf("%v", b.Mobile))
+	KeyValAdd(kv, "device_type", b.DeviceType)
+	KeyValAdd(kv, "device_model", b.DeviceModel)
+	return kv
+}
+
+// View holds metadata about the view of the app an event originates from,
+// such as the route of a single page application
+type View struct {
+	Name string `json:"name,omitempty"`
+}
+
+// KeyVal produces key->value representation of the View metadata
+func (v View) KeyVal() *KeyVal {
+	kv := NewKeyVal()
+	KeyValAdd(kv, "name", v.Name)
+	return kv
+}
+
+// Geo holds the location of a client, looked up from its IP address
+type Geo struct {
+	CountryISOCode string `json:"country_iso_code,omitempty"`
+	CountryName    string `json:"country_name,omitempty"`
+	RegionISOCode  string `json:"region_iso_code,omitempty"`
+	RegionName     string `json:"region_name,omitempty"`
+}
+
+// KeyVal produces key->value representation of the Geo metadata
+func (g Geo) KeyVal() *KeyVal {
+	kv := NewKeyVal()
+	KeyValAdd(kv, "country_iso_code", g.CountryISOCode)
+	KeyValAdd(kv, "country_name", g.CountryName)
+	KeyValAdd(kv, "region_iso_code", g.RegionISOCode)
+	KeyValAdd(kv, "region_name", g.RegionName)
 	return kv
 }
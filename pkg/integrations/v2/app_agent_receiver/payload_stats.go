@@ -0,0 +1,305 @@
+package app_agent_receiver
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// payloadSchemas describe the fields known to the receiver for each payload
+// version
+var payloadSchemas = map[PayloadVersion]*schemaNode{
+	PayloadV1: newSchemaNode(reflect.TypeOf(payloadV1{})),
+	PayloadV2: newSchemaNode(reflect.TypeOf(payloadV2{})),
+}
+
+var (
+	timeType             = reflect.TypeOf(time.Time{})
+	jsonUnmarshalerType  = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	untrackedUnknownKind = "unknown_field"
+	untrackedSDKKind     = "sdk_version"
+)
+
+// schemaNode describes the JSON fields of a payload type. fields is nil for
+// values whose contents are not checked, such as maps of user provided keys
+type schemaNode struct {
+	fields map[string]*schemaNode
+}
+
+func newSchemaNode(t reflect.Type) *schemaNode {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == timeType || reflect.PtrTo(t).Implements(jsonUnmarshalerType) {
+		return &schemaNode{}
+	}
+
+	node := &schemaNode{fields: make(map[string]*schemaNode)}
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" && field.Anonymous {
+			embedded = append(embedded, field.Type)
+			continue
+		}
+		if name == "" || name == "-" {
+			continue
+		}
+		node.fields[name] = newSchemaNode(field.Type)
+	}
+
+	// Fields of embedded structs are promoted unless a field of t with the
+	// same name shadows them, like encoding/json does
+	for _, et := range embedded {
+		for name, child := range newSchemaNode(et).fields {
+			if _, shadowed := node.fields[name]; !shadowed {
+				node.fields[name] = child
+			}
+		}
+	}
+	return node
+}
+
+// walk records the paths of the fields in raw, a value decoded from JSON, which
+// are populated in populated and the paths of fields which are not part of
+// the schema in unknown. Elements of arrays share the path of the array
+func (n *schemaNode) walk(path string, raw interface{}, populated, unknown map[string]struct{}) {
+	switch v := raw.(type) {
+	case []interface{}:
+		for _, elem := range v {
+			n.walk(path, elem, populated, unknown)
+		}
+	case map[string]interface{}:
+		if n.fields == nil {
+			return
+		}
+		for key, value := range v {
+			fieldPath := key
+			if path != "" {
+				fieldPath = path + "." + key
+			}
+
+			child, ok := n.fields[key]
+			if !ok {
+				unknown[fieldPath] = struct{}{}
+				continue
+			}
+			if isPopulated(value) {
+				populated[fieldPath] = struct{}{}
+				child.walk(fieldPath, value, populated, unknown)
+			}
+		}
+	}
+}
+
+// isPopulated returns false for JSON values which are null or empty, which
+// omitempty would leave out
+func isPopulated(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case string:
+		return v != ""
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case []interface{}:
+		return len(v) > 0
+	case map[string]interface{}:
+		return len(v) > 0
+	default:
+		return true
+	}
+}
+
+// sdkVersion identifies an SDK used by an app
+type sdkVersion struct {
+	App     string
+	Name    string
+	Version string
+}
+
+// SDKVersionStats describes the payloads an app sent with a version of an
+// SDK
+type SDKVersionStats struct {
+	App       string    `json:"app"`
+	SDKName   string    `json:"sdk_name"`
+	Version   string    `json:"sdk_version"`
+	Payloads  int64     `json:"payloads"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// PayloadStats holds the usage statistics of payload fields and SDK versions
+// since the receiver started
+type PayloadStats struct {
+	Since    time.Time `json:"since"`
+	Payloads int64     `json:"payloads"`
+	// Fields holds the number of payloads in which each known field was
+	// populated. Fields of arrays are counted once per payload
+	Fields map[string]int64 `json:"fields"`
+	// UnknownFields holds the number of payloads in which each field which is
+	// not part of the payload schema was sent
+	UnknownFields map[string]int64  `json:"unknown_fields"`
+	SDKVersions   []SDKVersionStats `json:"sdk_versions"`
+	// Untracked holds the number of unknown fields and SDK versions which
+	// were not tracked because the configured limit was reached
+	Untracked map[string]int64 `json:"untracked"`
+}
+
+// payloadStats tracks which payload fields and SDK versions are used by apps
+type payloadStats struct {
+	conf PayloadStatsConfig
+	now  func() time.Time
+
+	mut           sync.Mutex
+	since         time.Time
+	payloads      int64
+	fields        map[string]int64
+	unknownFields map[string]int64
+	sdkVersions   map[sdkVersion]*SDKVersionStats
+	untracked     map[string]int64
+
+	payloadsTotal      prometheus.Counter
+	fieldsTotal        *prometheus.CounterVec
+	unknownFieldsTotal *prometheus.CounterVec
+	sdkPayloadsTotal   *prometheus.CounterVec
+	untrackedTotal     *prometheus.CounterVec
+}
+
+func newPayloadStats(conf PayloadStatsConfig, reg prometheus.Registerer) *payloadStats {
+	ps := &payloadStats{
+		conf:          conf,
+		now:           time.Now,
+		since:         time.Now(),
+		fields:        make(map[string]int64),
+		unknownFields: make(map[string]int64),
+		sdkVersions:   make(map[sdkVersion]*SDKVersionStats),
+		untracked:     make(map[string]int64),
+
+		payloadsTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "app_agent_receiver_payload_stats_payloads_total",
+			Help: "Total number of payloads included in payload statistics",
+		}),
+		fieldsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "app_agent_receiver_payload_fields_total",
+			Help: "Total number of payloads in which a field was populated",
+		}, []string{"field"}),
+		unknownFieldsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "app_agent_receiver_payload_unknown_fields_total",
+			Help: "Total number of payloads in which a field which is not part of the payload schema was sent",
+		}, []string{"field"}),
+		sdkPayloadsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "app_agent_receiver_sdk_payloads_total",
+			Help: "Total number of payloads sent by an app with a version of an SDK",
+		}, []string{"app", "sdk_name", "sdk_version"}),
+		untrackedTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "app_agent_receiver_payload_stats_untracked_total",
+			Help: "Total number of unknown fields and SDK versions which were not tracked because the configured limit was reached",
+		}, []string{"kind"}),
+	}
+
+	reg.MustRegister(ps.payloadsTotal, ps.fieldsTotal, ps.unknownFieldsTotal, ps.sdkPayloadsTotal, ps.untrackedTotal)
+	return ps
+}
+
+// Observe records the fields and SDK version of a payload. body is the raw
+// JSON p was decoded from, using the schema of version
+func (ps *payloadStats) Observe(body []byte, version PayloadVersion, p Payload) {
+	var raw interface{}
+	if err := json.NewDecoder(bytes.NewReader(body)).Decode(&raw); err != nil {
+		return
+	}
+	populated, unknown := make(map[string]struct{}), make(map[string]struct{})
+	payloadSchemas[version].walk("", raw, populated, unknown)
+
+	ps.mut.Lock()
+	defer ps.mut.Unlock()
+
+	ps.payloads++
+	ps.payloadsTotal.Inc()
+
+	for field := range populated {
+		ps.fields[field]++
+		ps.fieldsTotal.WithLabelValues(field).Inc()
+	}
+
+	for field := range unknown {
+		if _, tracked := ps.unknownFields[field]; !tracked && len(ps.unknownFields) >= ps.conf.MaxUnknownFields {
+			ps.untracked[untrackedUnknownKind]++
+			ps.untrackedTotal.WithLabelValues(untrackedUnknownKind).Inc()
+			continue
+		}
+		ps.unknownFields[field]++
+		ps.unknownFieldsTotal.WithLabelValues(field).Inc()
+	}
+
+	key := sdkVersion{App: p.Meta.App.Name, Name: p.Meta.SDK.Name, Version: p.Meta.SDK.Version}
+	stats, tracked := ps.sdkVersions[key]
+	if !tracked {
+		if len(ps.sdkVersions) >= ps.conf.MaxSDKVersions {
+			ps.untracked[untrackedSDKKind]++
+			ps.untrackedTotal.WithLabelValues(untrackedSDKKind).Inc()
+			return
+		}
+		stats = &SDKVersionStats{App: key.App, SDKName: key.Name, Version: key.Version, FirstSeen: ps.now()}
+		ps.sdkVersions[key] = stats
+	}
+	stats.Payloads++
+	stats.LastSeen = ps.now()
+	ps.sdkPayloadsTotal.WithLabelValues(key.App, key.Name, key.Version).Inc()
+}
+
+// Stats returns the statistics recorded so far. SDK versions are sorted by
+// app, SDK name and version
+func (ps *payloadStats) Stats() PayloadStats {
+	ps.mut.Lock()
+	defer ps.mut.Unlock()
+
+	res := PayloadStats{
+		Since:         ps.since,
+		Payloads:      ps.payloads,
+		Fields:        make(map[string]int64, len(ps.fields)),
+		UnknownFields: make(map[string]int64, len(ps.unknownFields)),
+		SDKVersions:   make([]SDKVersionStats, 0, len(ps.sdkVersions)),
+		Untracked:     make(map[string]int64, len(ps.untracked)),
+	}
+	for k, v := range ps.fields {
+		res.Fields[k] = v
+	}
+	for k, v := range ps.unknownFields {
+		res.UnknownFields[k] = v
+	}
+	for k, v := range ps.untracked {
+		res.Untracked[k] = v
+	}
+	for _, v := range ps.sdkVersions {
+		res.SDKVersions = append(res.SDKVersions, *v)
+	}
+	sort.Slice(res.SDKVersions, func(i, j int) bool {
+		a, b := res.SDKVersions[i], res.SDKVersions[j]
+		if a.App != b.App {
+			return a.App < b.App
+		}
+		if a.SDKName != b.SDKName {
+			return a.SDKName < b.SDKName
+		}
+		return a.Version < b.Version
+	})
+	return res
+}
+
+// ServeHTTP serves the recorded statistics as JSON
+func (ps *payloadStats) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(ps.Stats())
+}
@@ -0,0 +1,161 @@
+package app_agent_receiver
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestPayloadStats_Fields(t *testing.T) {
+	ps := newPayloadStats(DefaultPayloadStatsConfig, prometheus.NewRegistry())
+
+	observeJSON(t, ps, `{
+		"logs": [
+			{"message": "hello", "context": {"component": "cart"}},
+			{"message": "", "level": "info", "fingerprint": "abc"}
+		],
+		"traces": {"resourceSpans": []},
+		"meta": {
+			"sdk": {"name": "grafana-frontend-agent", "version": "0.4.0"},
+			"app": {"name": "shop", "release": ""},
+			"user": {"email": "user@example.com", "attributes": {"plan": "free"}},
+			"browser": {"mobile": false},
+			"device": {"model": "x"}
+		}
+	}`)
+
+	stats := ps.Stats()
+	require.Equal(t, int64(1), stats.Payloads)
+	require.Equal(t, map[string]int64{
+		"logs":                 1,
+		"logs.message":         1,
+		"logs.context":         1,
+		"logs.level":           1,
+		"traces":               1,
+		"meta":                 1,
+		"meta.sdk":             1,
+		"meta.sdk.name":        1,
+		"meta.sdk.version":     1,
+		"meta.app":             1,
+		"meta.app.name":        1,
+		"meta.browser":         1,
+		"meta.user":            1,
+		"meta.user.email":      1,
+		"meta.user.attributes": 1,
+	}, stats.Fields)
+	require.Equal(t, map[string]int64{
+		"logs.fingerprint": 1,
+		"meta.device":      1,
+	}, stats.UnknownFields)
+}
+
+func TestPayloadStats_Versions(t *testing.T) {
+	ps := newPayloadStats(DefaultPayloadStatsConfig, prometheus.NewRegistry())
+
+	// Fields are checked against the schema of the version of the payload.
+	observeJSON(t, ps, `{"version": "v1", "meta": {"browser": {"os": "linux", "operating_system": "linux"}}}`)
+	observeJSON(t, ps, `{"version": "v2", "meta": {"browser": {"os": "linux", "operating_system": "linux"}}}`)
+
+	stats := ps.Stats()
+	require.Equal(t, map[string]int64{
+		"version":                       2,
+		"meta":                          2,
+		"meta.browser":                  2,
+		"meta.browser.os":               1,
+		"meta.browser.operating_system": 1,
+	}, stats.Fields)
+	require.Equal(t, map[string]int64{
+		"meta.browser.operating_system": 1,
+		"meta.browser.os":               1,
+	}, stats.UnknownFields)
+}
+
+func TestPayloadStats_SDKVersions(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	ps := newPayloadStats(PayloadStatsConfig{MaxUnknownFields: 1, MaxSDKVersions: 2}, reg)
+
+	now := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	ps.now = func() time.Time { return now }
+
+	observeJSON(t, ps, `{"meta": {"app": {"name": "shop"}, "sdk": {"name": "agent", "version": "0.3.0"}}, "a": 1}`)
+	now = now.Add(time.Hour)
+	observeJSON(t, ps, `{"meta": {"app": {"name": "shop"}, "sdk": {"name": "agent", "version": "0.3.0"}}, "a": 1}`)
+	observeJSON(t, ps, `{"meta": {"app": {"name": "cart"}, "sdk": {"name": "agent", "version": "0.4.0"}}, "b": 1}`)
+
+	// The limit of SDK versions was reached.
+	observeJSON(t, ps, `{"meta": {"app": {"name": "shop"}, "sdk": {"name": "agent", "version": "0.4.0"}}}`)
+
+	stats := ps.Stats()
+	require.Equal(t, []SDKVersionStats{
+		{App: "cart", SDKName: "agent", Version: "0.4.0", Payloads: 1, FirstSeen: now, LastSeen: now},
+		{App: "shop", SDKName: "agent", Version: "0.3.0", Payloads: 2, FirstSeen: now.Add(-time.Hour), LastSeen: now},
+	}, stats.SDKVersions)
+	require.Equal(t, map[string]int64{"a": 2}, stats.UnknownFields)
+	require.Equal(t, map[string]int64{"unknown_field": 1, "sdk_version": 1}, stats.Untracked)
+
+	expect := `
+		# HELP app_agent_receiver_sdk_payloads_total Total number of payloads sent by an app with a version of an SDK
+		# TYPE app_agent_receiver_sdk_payloads_total counter
+		app_agent_receiver_sdk_payloads_total{app="cart",sdk_name="agent",sdk_version="0.4.0"} 1
+		app_agent_receiver_sdk_payloads_total{app="shop",sdk_name="agent",sdk_version="0.3.0"} 2
+		# HELP app_agent_receiver_payload_stats_untracked_total Total number of unknown fields and SDK versions which were not tracked because the configured limit was reached
+		# TYPE app_agent_receiver_payload_stats_untracked_total counter
+		app_agent_receiver_payload_stats_untracked_total{kind="sdk_version"} 1
+		app_agent_receiver_payload_stats_untracked_total{kind="unknown_field"} 1
+	`
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expect),
+		"app_agent_receiver_sdk_payloads_total", "app_agent_receiver_payload_stats_untracked_total"))
+}
+
+func TestConfig_PayloadStats(t *testing.T) {
+	var cfg Config
+	require.NoError(t, yaml.Unmarshal([]byte(`payload_stats: {max_sdk_versions: 10}`), &cfg))
+	require.Equal(t, PayloadStatsConfig{MaxUnknownFields: 100, MaxSDKVersions: 10}, *cfg.PayloadStats)
+
+	require.NoError(t, yaml.Unmarshal([]byte(`server: {port: 1234}`), &cfg))
+	require.Nil(t, cfg.PayloadStats)
+}
+
+func TestHandler_PayloadStats(t *testing.T) {
+	exporter := TestExporter{name: "exporter"}
+
+	conf := &Config{PayloadStats: &DefaultPayloadStatsConfig}
+	fr := NewAppAgentReceiverHandler(conf, []AppAgentReceiverExporter{&exporter}, prometheus.NewRegistry())
+	handler := fr.HTTPHandler(log.NewNopLogger())
+
+	payload := `{"logs": [{"message": "hello"}], "meta": {"app": {"name": "frontend"}, "sdk": {"version": "1.0.0"}}}`
+	req, err := http.NewRequest("POST", "/collect", bytes.NewBufferString(payload))
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusAccepted, rr.Result().StatusCode)
+	require.Len(t, exporter.payloads, 1)
+
+	rr = httptest.NewRecorder()
+	fr.stats.ServeHTTP(rr, httptest.NewRequest("GET", "/stats", nil))
+
+	var stats PayloadStats
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &stats))
+	require.Equal(t, int64(1), stats.Payloads)
+	require.Equal(t, int64(1), stats.Fields["logs.message"])
+	require.Len(t, stats.SDKVersions, 1)
+	require.Equal(t, "1.0.0", stats.SDKVersions[0].Version)
+}
+
+func observeJSON(t *testing.T, ps *payloadStats, body string) {
+	t.Helper()
+
+	p, version, err := decodePayload(strings.NewReader(body), "")
+	require.NoError(t, err)
+	ps.Observe([]byte(body), version, p)
+}
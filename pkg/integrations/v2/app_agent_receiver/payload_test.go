@@ -1,9 +1,11 @@
 package app_agent_receiver
 
 import (
+	"bytes"
 	"encoding/json"
 	"io/ioutil"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -95,3 +97,59 @@ func TestUnmarshalPayloadJSON(t *testing.T) {
 		},
 	}, payload.Logs)
 }
+
+func TestDecodePayload_Versions(t *testing.T) {
+	expect := Meta{
+		App:     App{Name: "testapp"},
+		Browser: Browser{Name: "chrome", OS: "linux"},
+		View:    View{Name: "pricing"},
+	}
+
+	tt := []struct {
+		name    string
+		header  string
+		payload string
+	}{
+		{
+			name:    "no version",
+			payload: `{"meta": {"app": {"name": "testapp"}, "browser": {"name": "chrome", "os": "linux"}, "view": {"name": "pricing"}}}`,
+		},
+		{
+			name:    "v1 field",
+			payload: `{"version": "v1", "meta": {"app": {"name": "testapp"}, "browser": {"name": "chrome", "os": "linux"}, "view": {"name": "pricing"}}}`,
+		},
+		{
+			name:    "v2 field",
+			payload: `{"version": "v2", "meta": {"app": {"name": "testapp"}, "browser": {"name": "chrome", "operating_system": "linux"}, "view": {"name": "pricing"}}}`,
+		},
+		{
+			name:    "v2 header",
+			header:  "v2",
+			payload: `{"meta": {"app": {"name": "testapp"}, "browser": {"name": "chrome", "operating_system": "linux"}, "view": {"name": "pricing"}}}`,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			p, _, err := decodePayload(strings.NewReader(tc.payload), tc.header)
+			require.NoError(t, err)
+			require.Equal(t, expect, p.Meta)
+		})
+	}
+
+	_, _, err := decodePayload(strings.NewReader(`{"version": "v3"}`), "")
+	require.EqualError(t, err, `unsupported payload version "v3"`)
+}
+
+func TestDecodePayload_V2Payload(t *testing.T) {
+	content := loadTestData(t, "payload.json")
+	v1, _, err := decodePayload(bytes.NewReader(content), "")
+	require.NoError(t, err)
+
+	// v2 renamed the browser OS field, so it is the only field of the v1
+	// payload which isn't decoded as v2.
+	v2, _, err := decodePayload(bytes.NewReader(content), string(PayloadV2))
+	require.NoError(t, err)
+	require.Empty(t, v2.Meta.Browser.OS)
+	v2.Meta.Browser.OS = v1.Meta.Browser.OS
+	require.Equal(t, v1, v2)
+}
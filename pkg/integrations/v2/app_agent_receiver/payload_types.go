@@ -0,0 +1,106 @@
+package app_agent_receiver
+
+import "fmt"
+
+// Types of the data held by payloads
+const (
+	PayloadTypeLogs         = "logs"
+	PayloadTypeExceptions   = "exceptions"
+	PayloadTypeMeasurements = "measurements"
+	PayloadTypeTraces       = "traces"
+)
+
+// DefaultPayloadTypeConfig holds the default values of a PayloadTypeConfig
+var DefaultPayloadTypeConfig = PayloadTypeConfig{
+	Enabled: true,
+}
+
+// PayloadTypeConfig configures the data of one type held by payloads
+type PayloadTypeConfig struct {
+	// Enabled is false if data of this type is dropped when it's received
+	Enabled bool `yaml:"enabled"`
+	// LogsInstance is the logs instance data of this type is sent to,
+	// overriding logs_instance. Apps with their own logs instance still send
+	// data of this type to it
+	LogsInstance string `yaml:"logs_instance,omitempty"`
+}
+
+// UnmarshalYAML implements the Unmarshaler interface
+func (c *PayloadTypeConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultPayloadTypeConfig
+	type plain PayloadTypeConfig
+	return unmarshal((*plain)(c))
+}
+
+// DefaultPayloadTypesConfig holds the default values of a PayloadTypesConfig
+var DefaultPayloadTypesConfig = PayloadTypesConfig{
+	Logs:         DefaultPayloadTypeConfig,
+	Exceptions:   DefaultPayloadTypeConfig,
+	Measurements: DefaultPayloadTypeConfig,
+	Traces:       DefaultPayloadTypeConfig,
+}
+
+// PayloadTypesConfig enables and routes the data of each type held by
+// payloads
+type PayloadTypesConfig struct {
+	Logs         PayloadTypeConfig `yaml:"logs,omitempty"`
+	Exceptions   PayloadTypeConfig `yaml:"exceptions,omitempty"`
+	Measurements PayloadTypeConfig `yaml:"measurements,omitempty"`
+	// Traces can only be enabled or disabled, traces are routed with
+	// traces_routes
+	Traces PayloadTypeConfig `yaml:"traces,omitempty"`
+}
+
+// UnmarshalYAML implements the Unmarshaler interface
+func (c *PayloadTypesConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultPayloadTypesConfig
+	type plain PayloadTypesConfig
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+	if c.Traces.LogsInstance != "" {
+		return fmt.Errorf("payload_types.traces.logs_instance is not supported, use traces_routes to route traces")
+	}
+	return nil
+}
+
+// Filter removes the data of disabled types from p
+func (c *PayloadTypesConfig) Filter(p *Payload) {
+	if !c.Logs.Enabled {
+		p.Logs = nil
+	}
+	if !c.Exceptions.Enabled {
+		p.Exceptions = nil
+	}
+	if !c.Measurements.Enabled {
+		p.Measurements = nil
+	}
+	if !c.Traces.Enabled {
+		p.Traces = nil
+	}
+}
+
+// LogsInstance returns the logs instance configured for payloadType, or an
+// empty string if there's none
+func (c *PayloadTypesConfig) LogsInstance(payloadType string) string {
+	switch payloadType {
+	case PayloadTypeLogs:
+		return c.Logs.LogsInstance
+	case PayloadTypeExceptions:
+		return c.Exceptions.LogsInstance
+	case PayloadTypeMeasurements:
+		return c.Measurements.LogsInstance
+	}
+	return ""
+}
+
+// LogsInstances returns the logs instances configured for payload types
+func (c *PayloadTypesConfig) LogsInstances() []string {
+	var names []string
+	for _, name := range []string{c.Logs.LogsInstance, c.Exceptions.LogsInstance, c.Measurements.LogsInstance} {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
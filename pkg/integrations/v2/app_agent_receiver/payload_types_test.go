@@ -0,0 +1,104 @@
+package app_agent_receiver
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	kitlog "github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestPayloadTypesConfig_Filter(t *testing.T) {
+	conf := DefaultPayloadTypesConfig
+	conf.Exceptions.Enabled = false
+	conf.Traces.Enabled = false
+
+	p := loadTestPayload(t)
+	logs, measurements := len(p.Logs), len(p.Measurements)
+	conf.Filter(&p)
+
+	require.Empty(t, p.Exceptions)
+	require.Nil(t, p.Traces)
+	require.Len(t, p.Logs, logs)
+	require.Len(t, p.Measurements, measurements)
+}
+
+func TestExportLogs_PayloadTypes(t *testing.T) {
+	instances := map[string]*testLogsInstance{
+		"default":      {},
+		"measurements": {},
+	}
+	conf := DefaultPayloadTypesConfig
+	conf.Measurements.LogsInstance = "measurements"
+
+	logsExporter := NewLogsExporter(
+		kitlog.NewNopLogger(),
+		LogsExporterConfig{
+			GetLogsInstance: func(_ context.Context, payloadType string) (LogsInstance, error) {
+				if payloadType == PayloadTypeExceptions {
+					// Exceptions are dropped.
+					return nil, nil
+				}
+				name := "default"
+				if typeName := conf.LogsInstance(payloadType); typeName != "" {
+					name = typeName
+				}
+				return instances[name], nil
+			},
+			SendEntryTimeout: 100,
+		},
+		&MockSourceMapStore{},
+	)
+
+	p := loadTestPayload(t)
+	require.NoError(t, logsExporter.Export(context.Background(), p))
+
+	require.Len(t, instances["default"].Entries, len(p.Logs))
+	require.Len(t, instances["measurements"].Entries, len(p.Measurements))
+	require.Contains(t, instances["measurements"].Entries[0].Line, "kind=measurement")
+}
+
+func TestHandler_PayloadTypes(t *testing.T) {
+	types := DefaultPayloadTypesConfig
+	types.Logs.Enabled = false
+
+	exporter := &TestExporter{name: "exporter"}
+	fr := NewAppAgentReceiverHandler(&Config{PayloadTypes: &types}, []AppAgentReceiverExporter{exporter}, prometheus.NewRegistry())
+
+	req := httptest.NewRequest("POST", "/collect", bytes.NewBuffer(loadTestData(t, "payload.json")))
+	rr := httptest.NewRecorder()
+	fr.HTTPHandler(nil).ServeHTTP(rr, req)
+
+	require.Equal(t, 202, rr.Result().StatusCode)
+	require.Len(t, exporter.payloads, 1)
+	require.Empty(t, exporter.payloads[0].Logs)
+	require.NotEmpty(t, exporter.payloads[0].Exceptions)
+}
+
+func TestConfig_PayloadTypes(t *testing.T) {
+	var cfg Config
+	cb := `
+payload_types:
+  measurements:
+    logs_instance: metrics-tenant
+  traces:
+    enabled: false`
+	require.NoError(t, yaml.Unmarshal([]byte(cb), &cfg))
+	require.Equal(t, PayloadTypesConfig{
+		Logs:         PayloadTypeConfig{Enabled: true},
+		Exceptions:   PayloadTypeConfig{Enabled: true},
+		Measurements: PayloadTypeConfig{Enabled: true, LogsInstance: "metrics-tenant"},
+		Traces:       PayloadTypeConfig{Enabled: false},
+	}, *cfg.PayloadTypes)
+	require.Equal(t, []string{"metrics-tenant"}, cfg.PayloadTypes.LogsInstances())
+
+	cb = `
+payload_types:
+  traces:
+    logs_instance: default`
+	require.EqualError(t, yaml.Unmarshal([]byte(cb), &cfg), "payload_types.traces.logs_instance is not supported, use traces_routes to route traces")
+}
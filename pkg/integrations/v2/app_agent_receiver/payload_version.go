@@ -0,0 +1,101 @@
+package app_agent_receiver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// payloadVersionHeader is the request header which selects the version of
+// the payload schema. It takes precedence over the version field of the
+// payload
+const payloadVersionHeader = "x-payload-version"
+
+// PayloadVersion is a version of the payload schema sent by frontend SDKs.
+// Payloads of every version are converted to Payload, so exporters don't
+// depend on the version sent by an SDK
+type PayloadVersion string
+
+// Supported payload versions
+const (
+	// PayloadV1 is the original schema, which Payload is decoded from as is.
+	// Payloads without a version use PayloadV1
+	PayloadV1 PayloadVersion = "v1"
+	// PayloadV2 renames meta.browser.os to meta.browser.operating_system
+	PayloadV2 PayloadVersion = "v2"
+)
+
+// decodePayload decodes the payload read from r, returning it with the
+// version of its schema. The version is taken from header if set, and from
+// the version field of the payload otherwise
+func decodePayload(r io.Reader, header string) (Payload, PayloadVersion, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return Payload{}, "", err
+	}
+
+	version := PayloadVersion(header)
+	if version == "" {
+		var v struct {
+			Version PayloadVersion `json:"version"`
+		}
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return Payload{}, "", err
+		}
+		version = v.Version
+	}
+
+	switch version {
+	case "", PayloadV1:
+		var p payloadV1
+		err := json.Unmarshal(raw, &p)
+		return p.Payload, PayloadV1, err
+	case PayloadV2:
+		var p payloadV2
+		if err := json.Unmarshal(raw, &p); err != nil {
+			return Payload{}, "", err
+		}
+		return p.toPayload(), PayloadV2, nil
+	default:
+		return Payload{}, "", fmt.Errorf("unsupported payload version %q", version)
+	}
+}
+
+// payloadV1 is the PayloadV1 schema
+type payloadV1 struct {
+	Version PayloadVersion `json:"version,omitempty"`
+	Payload
+}
+
+// payloadV2 is the PayloadV2 schema. Only the fields which differ from
+// PayloadV1 are redeclared; they shadow the fields of the embedded types
+type payloadV2 struct {
+	Version PayloadVersion `json:"version,omitempty"`
+	Payload
+	Meta metaV2 `json:"meta,omitempty"`
+}
+
+type metaV2 struct {
+	Meta
+	Browser browserV2 `json:"browser,omitempty"`
+}
+
+type browserV2 struct {
+	Name            string `json:"name,omitempty"`
+	Version         string `json:"version,omitempty"`
+	OperatingSystem string `json:"operating_system,omitempty"`
+	Mobile          bool   `json:"mobile,omitempty"`
+}
+
+// toPayload converts p to a Payload
+func (p payloadV2) toPayload() Payload {
+	out := p.Payload
+	out.Meta = p.Meta.Meta
+	out.Meta.Browser = Browser{
+		Name:    p.Meta.Browser.Name,
+		Version: p.Meta.Browser.Version,
+		OS:      p.Meta.Browser.OperatingSystem,
+		Mobile:  p.Meta.Browser.Mobile,
+	}
+	return out
+}
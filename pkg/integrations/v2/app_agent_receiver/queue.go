@@ -0,0 +1,321 @@
+package app_agent_receiver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/atomic"
+)
+
+// DefaultQueueConfig holds the default values of a QueueConfig
+var DefaultQueueConfig = QueueConfig{
+	MaxSize:       512 << 20,
+	MaxAge:        24 * time.Hour,
+	RetryInterval: 30 * time.Second,
+}
+
+// QueueConfig configures queueing payloads on disk when an exporter fails,
+// so that they are exported later instead of being dropped
+type QueueConfig struct {
+	// Directory holds a subdirectory with the queue of every exporter
+	Directory string `yaml:"directory"`
+	// MaxSize is the max size in bytes of the queue of an exporter. Payloads
+	// are dropped when the queue is full
+	MaxSize int64 `yaml:"max_size,omitempty"`
+	// MaxAge is how long queued payloads are kept before they're dropped
+	MaxAge time.Duration `yaml:"max_age,omitempty"`
+	// RetryInterval is how often queued payloads are exported again
+	RetryInterval time.Duration `yaml:"retry_interval,omitempty"`
+}
+
+// UnmarshalYAML implements the Unmarshaler interface
+func (c *QueueConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultQueueConfig
+	type plain QueueConfig
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+	if c.Directory == "" {
+		return fmt.Errorf("queue directory must be set")
+	}
+	if c.MaxSize <= 0 {
+		return fmt.Errorf("queue max_size must be greater than 0")
+	}
+	if c.MaxAge <= 0 || c.RetryInterval <= 0 {
+		return fmt.Errorf("queue max_age and retry_interval must be greater than 0s")
+	}
+	return nil
+}
+
+// queueEntry is a payload in the queue of an exporter
+type queueEntry struct {
+	// APIKey is the key the payload was sent with, which selects the
+	// destinations of the payload
+	APIKey  string  `json:"api_key,omitempty"`
+	Payload Payload `json:"payload"`
+}
+
+// queueMetrics are the metrics of the queues of all exporters
+type queueMetrics struct {
+	queued   *prometheus.CounterVec
+	replayed *prometheus.CounterVec
+	dropped  *prometheus.CounterVec
+	size     *prometheus.GaugeVec
+}
+
+func newQueueMetrics(reg prometheus.Registerer) *queueMetrics {
+	m := &queueMetrics{
+		queued: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "app_agent_receiver_queue_payloads_queued_total",
+			Help: "Total number of payloads queued on disk because an exporter failed",
+		}, []string{"exporter"}),
+		replayed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "app_agent_receiver_queue_payloads_replayed_total",
+			Help: "Total number of queued payloads which were exported",
+		}, []string{"exporter"}),
+		dropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "app_agent_receiver_queue_payloads_dropped_total",
+			Help: "Total number of payloads which could not be queued or were removed from the queue without being exported, by reason",
+		}, []string{"exporter", "reason"}),
+		size: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "app_agent_receiver_queue_size_bytes",
+			Help: "Size in bytes of the queued payloads of an exporter",
+		}, []string{"exporter"}),
+	}
+	reg.MustRegister(m.queued, m.replayed, m.dropped, m.size)
+	return m
+}
+
+// queuedExporter wraps an exporter, queueing payloads on disk when the
+// exporter fails. Queued payloads are exported again by Run
+type queuedExporter struct {
+	exporter AppAgentReceiverExporter
+	conf     QueueConfig
+	apps     []AppConfig
+	dir      string
+	logger   log.Logger
+	metrics  *queueMetrics
+	now      func() time.Time
+
+	seq  atomic.Uint64
+	mut  sync.Mutex
+	size int64 // Total size of the files in dir
+}
+
+// newQueuedExporter creates a queuedExporter wrapping exporter. Payloads
+// queued by a previous run of the receiver are kept
+func newQueuedExporter(logger log.Logger, exporter AppAgentReceiverExporter, conf QueueConfig, apps []AppConfig, metrics *queueMetrics) (*queuedExporter, error) {
+	dir := filepath.Join(conf.Directory, strings.ReplaceAll(exporter.Name(), " ", "_"))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create queue directory: %w", err)
+	}
+
+	q := &queuedExporter{
+		exporter: exporter,
+		conf:     conf,
+		apps:     apps,
+		dir:      dir,
+		logger:   log.With(logger, "exporter", exporter.Name()),
+		metrics:  metrics,
+		now:      time.Now,
+	}
+
+	files, err := q.files()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		q.size += f.size
+	}
+	q.metrics.size.WithLabelValues(exporter.Name()).Set(float64(q.size))
+	return q, nil
+}
+
+// Name of the exporter, for logging purposes
+func (q *queuedExporter) Name() string {
+	return q.exporter.Name()
+}
+
+// Export implements the AppDataExporter interface. If the wrapped exporter
+// fails, the payload is queued and no error is returned
+func (q *queuedExporter) Export(ctx context.Context, payload Payload) error {
+	err := q.exporter.Export(ctx, payload)
+	if err == nil {
+		return nil
+	}
+
+	if qerr := q.enqueue(queueEntry{APIKey: apiKeyFromContext(ctx), Payload: payload}); qerr != nil {
+		level.Warn(q.logger).Log("msg", "failed to queue payload", "err", qerr)
+		return err
+	}
+	level.Debug(q.logger).Log("msg", "queued payload after exporter error", "err", err)
+	return nil
+}
+
+func (q *queuedExporter) enqueue(entry queueEntry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	q.mut.Lock()
+	if q.size+int64(len(b)) > q.conf.MaxSize {
+		q.mut.Unlock()
+		q.metrics.dropped.WithLabelValues(q.Name(), "full").Inc()
+		return fmt.Errorf("queue is full")
+	}
+	q.size += int64(len(b))
+	q.mut.Unlock()
+
+	// Files are named after the time they're queued, so they sort in order
+	name := fmt.Sprintf("%020d-%010d.json", q.now().UnixNano(), q.seq.Inc())
+	if err := writeFileAtomic(filepath.Join(q.dir, name), b); err != nil {
+		q.addSize(-int64(len(b)))
+		q.metrics.dropped.WithLabelValues(q.Name(), "write_error").Inc()
+		return err
+	}
+	q.metrics.queued.WithLabelValues(q.Name()).Inc()
+	q.metrics.size.WithLabelValues(q.Name()).Set(float64(q.getSize()))
+	return nil
+}
+
+// Run exports queued payloads every retry interval until ctx is done
+func (q *queuedExporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(q.conf.RetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := q.replay(ctx); err != nil {
+				level.Debug(q.logger).Log("msg", "failed to export queued payloads", "err", err)
+			}
+		}
+	}
+}
+
+// replay exports queued payloads in the order they were queued, dropping
+// payloads older than the max age. It stops at the first payload which
+// fails to be exported, as the exporter is likely still failing
+func (q *queuedExporter) replay(ctx context.Context) error {
+	files, err := q.files()
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if q.now().Sub(f.queuedAt) > q.conf.MaxAge {
+			q.remove(f)
+			q.metrics.dropped.WithLabelValues(q.Name(), "expired").Inc()
+			continue
+		}
+
+		var entry queueEntry
+		b, err := os.ReadFile(f.path)
+		if err == nil {
+			err = json.Unmarshal(b, &entry)
+		}
+		if err != nil {
+			level.Warn(q.logger).Log("msg", "dropping unreadable queued payload", "file", f.path, "err", err)
+			q.remove(f)
+			q.metrics.dropped.WithLabelValues(q.Name(), "corrupt").Inc()
+			continue
+		}
+
+		exportCtx := contextWithAPIKey(ctx, entry.APIKey)
+		if app := appForAPIKey(q.apps, entry.APIKey); app != nil {
+			exportCtx = contextWithApp(exportCtx, app)
+		}
+		if err := q.exporter.Export(exportCtx, entry.Payload); err != nil {
+			return err
+		}
+		q.remove(f)
+		q.metrics.replayed.WithLabelValues(q.Name()).Inc()
+	}
+	return nil
+}
+
+// queueFile is a file of the queue
+type queueFile struct {
+	path     string
+	size     int64
+	queuedAt time.Time
+}
+
+// files returns the files of the queue, oldest first
+func (q *queuedExporter) files() ([]queueFile, error) {
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []queueFile
+	for _, e := range entries {
+		// Skips temporary files of payloads being queued
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		nanos, err := strconv.ParseInt(strings.SplitN(e.Name(), "-", 2)[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, queueFile{
+			path:     filepath.Join(q.dir, e.Name()),
+			size:     info.Size(),
+			queuedAt: time.Unix(0, nanos),
+		})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].path < files[j].path })
+	return files, nil
+}
+
+func (q *queuedExporter) remove(f queueFile) {
+	if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+		level.Warn(q.logger).Log("msg", "failed to remove queued payload", "file", f.path, "err", err)
+		return
+	}
+	q.addSize(-f.size)
+	q.metrics.size.WithLabelValues(q.Name()).Set(float64(q.getSize()))
+}
+
+func (q *queuedExporter) addSize(n int64) {
+	q.mut.Lock()
+	defer q.mut.Unlock()
+	q.size += n
+}
+
+func (q *queuedExporter) getSize() int64 {
+	q.mut.Lock()
+	defer q.mut.Unlock()
+	return q.size
+}
+
+// Close closes the wrapped exporter if it holds connections
+func (q *queuedExporter) Close() error {
+	if c, ok := q.exporter.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package app_agent_receiver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+// failingExporter fails to export payloads while down is true
+type failingExporter struct {
+	down     bool
+	payloads []Payload
+	apiKeys  []string
+	apps     []*AppConfig
+}
+
+func (fe *failingExporter) Name() string {
+	return "test exporter"
+}
+
+func (fe *failingExporter) Export(ctx context.Context, payload Payload) error {
+	if fe.down {
+		return errors.New("backend unavailable")
+	}
+	fe.payloads = append(fe.payloads, payload)
+	fe.apiKeys = append(fe.apiKeys, apiKeyFromContext(ctx))
+	fe.apps = append(fe.apps, appFromContext(ctx))
+	return nil
+}
+
+func newTestQueuedExporter(t *testing.T, conf QueueConfig, apps []AppConfig) (*queuedExporter, *failingExporter, *queueMetrics) {
+	t.Helper()
+	exporter := &failingExporter{down: true}
+	metrics := newQueueMetrics(prometheus.NewRegistry())
+	q, err := newQueuedExporter(log.NewNopLogger(), exporter, conf, apps, metrics)
+	require.NoError(t, err)
+	return q, exporter, metrics
+}
+
+func TestQueuedExporter_Replay(t *testing.T) {
+	conf := DefaultQueueConfig
+	conf.Directory = t.TempDir()
+	apps := []AppConfig{{Name: "shop", APIKey: "shop-key", LogsInstance: "shop"}}
+	q, exporter, metrics := newTestQueuedExporter(t, conf, apps)
+
+	ctx := contextWithAPIKey(context.Background(), "shop-key")
+	for _, name := range []string{"first", "second"} {
+		require.NoError(t, q.Export(ctx, Payload{Meta: Meta{App: App{Name: name}}}))
+	}
+	require.Equal(t, 2.0, testutil.ToFloat64(metrics.queued.WithLabelValues("test exporter")))
+	require.Greater(t, q.getSize(), int64(0))
+
+	// Replaying stops while the exporter still fails.
+	require.Error(t, q.replay(context.Background()))
+	require.Empty(t, exporter.payloads)
+
+	exporter.down = false
+	require.NoError(t, q.replay(context.Background()))
+	require.Len(t, exporter.payloads, 2)
+	require.Equal(t, "first", exporter.payloads[0].Meta.App.Name)
+	require.Equal(t, "second", exporter.payloads[1].Meta.App.Name)
+	// Payloads are exported to the destinations of the key they were sent with.
+	require.Equal(t, []string{"shop-key", "shop-key"}, exporter.apiKeys)
+	require.Equal(t, "shop", exporter.apps[0].LogsInstance)
+
+	require.Equal(t, int64(0), q.getSize())
+	files, err := q.files()
+	require.NoError(t, err)
+	require.Empty(t, files)
+}
+
+func TestQueuedExporter_Restart(t *testing.T) {
+	conf := DefaultQueueConfig
+	conf.Directory = t.TempDir()
+	q, _, _ := newTestQueuedExporter(t, conf, nil)
+	require.NoError(t, q.Export(context.Background(), Payload{}))
+
+	// Payloads queued before a restart are replayed.
+	q, exporter, _ := newTestQueuedExporter(t, conf, nil)
+	require.Greater(t, q.getSize(), int64(0))
+	exporter.down = false
+	require.NoError(t, q.replay(context.Background()))
+	require.Len(t, exporter.payloads, 1)
+}
+
+func TestQueuedExporter_Limits(t *testing.T) {
+	conf := DefaultQueueConfig
+	conf.Directory = t.TempDir()
+	conf.MaxSize = 200
+	q, exporter, metrics := newTestQueuedExporter(t, conf, nil)
+
+	now := time.Now()
+	q.now = func() time.Time { return now }
+
+	require.NoError(t, q.Export(context.Background(), Payload{}))
+	big := Payload{Logs: make([]Log, 10)}
+	require.EqualError(t, q.Export(context.Background(), big), "backend unavailable")
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.dropped.WithLabelValues("test exporter", "full")))
+
+	now = now.Add(conf.MaxAge + time.Second)
+
+	// Corrupt files are dropped.
+	corrupt := fmt.Sprintf("%020d-%010d.json", now.UnixNano(), 0)
+	require.NoError(t, os.WriteFile(filepath.Join(q.dir, corrupt), []byte("{"), 0600))
+
+	exporter.down = false
+	require.NoError(t, q.replay(context.Background()))
+	require.Empty(t, exporter.payloads)
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.dropped.WithLabelValues("test exporter", "expired")))
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.dropped.WithLabelValues("test exporter", "corrupt")))
+}
+
+func TestConfig_Queue(t *testing.T) {
+	var cfg Config
+	cb := `
+queue:
+  directory: /var/lib/agent/app-agent-receiver-queue`
+	require.NoError(t, yaml.Unmarshal([]byte(cb), &cfg))
+	require.Equal(t, DefaultQueueConfig.MaxAge, cfg.Queue.MaxAge)
+
+	cb = `
+queue:
+  max_age: 1h`
+	require.EqualError(t, yaml.Unmarshal([]byte(cb), &cfg), "queue directory must be set")
+}
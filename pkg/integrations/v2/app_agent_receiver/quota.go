@@ -0,0 +1,201 @@
+package app_agent_receiver
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// quotaDecision is the outcome of checking a payload against an app budget
+type quotaDecision int
+
+const (
+	quotaAllow quotaDecision = iota
+	quotaDrop
+	quotaReject
+)
+
+// budgetUsage is the consumption of a single app in the current window
+type budgetUsage struct {
+	windowStart time.Time
+	bytes       int64
+	events      int64
+}
+
+// quotaTracker tracks ingest consumption against configured budgets. Every
+// configured app has its own usage, while payloads sent with any other API
+// key share a single usage against the default budget, so clients can't reset
+// their budget by rotating keys or evict the usage of apps by sending many
+// distinct keys
+type quotaTracker struct {
+	conf QuotaConfig
+	now  func() time.Time
+	rand func() float64
+
+	mut sync.Mutex
+	// apps holds the usage of configured apps by name. unknown is the usage
+	// shared by payloads not sent with the API key of an app
+	apps    map[string]*budgetUsage
+	unknown *budgetUsage
+
+	consumedBytes  *prometheus.CounterVec
+	consumedEvents *prometheus.CounterVec
+	overBudget     *prometheus.CounterVec
+	budgetUsed     *prometheus.GaugeVec
+}
+
+func newQuotaTracker(conf QuotaConfig, reg prometheus.Registerer) *quotaTracker {
+	qt := &quotaTracker{
+		conf: conf,
+		now:  time.Now,
+		rand: rand.Float64,
+		apps: make(map[string]*budgetUsage),
+
+		consumedBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "app_agent_receiver_quota_consumed_bytes_total",
+			Help: "Total number of payload bytes counted against app budgets",
+		}, []string{"app"}),
+		consumedEvents: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "app_agent_receiver_quota_consumed_events_total",
+			Help: "Total number of events counted against app budgets",
+		}, []string{"app"}),
+		overBudget: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "app_agent_receiver_quota_over_budget_payloads_total",
+			Help: "Total number of payloads received after an app budget was exhausted, by the action taken",
+		}, []string{"app", "action"}),
+		budgetUsed: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "app_agent_receiver_quota_budget_used_ratio",
+			Help: "Ratio of the app budget consumed in the current window",
+		}, []string{"app", "resource"}),
+	}
+
+	reg.MustRegister(qt.consumedBytes, qt.consumedEvents, qt.overBudget, qt.budgetUsed)
+	return qt
+}
+
+// budgetFor returns the budget configured for app, if any. app is nil for
+// requests which weren't sent with the API key of an app
+func (qt *quotaTracker) budgetFor(app *AppConfig) (BudgetConfig, bool) {
+	if app != nil {
+		if b, ok := qt.conf.Apps[app.Name]; ok {
+			return b, true
+		}
+	}
+	if qt.conf.Default != nil {
+		return *qt.conf.Default, true
+	}
+	return BudgetConfig{}, false
+}
+
+// Check records a payload of the given size and returns what should happen
+// to it. app is the app whose API key the payload was sent with, or nil if
+// it wasn't sent with the key of an app. Payloads which are not allowed do
+// not consume budget.
+//
+// Budgets are picked by API key rather than by meta.app.name, which is set
+// by clients and can't be trusted.
+func (qt *quotaTracker) Check(app *AppConfig, bytes, events int64) quotaDecision {
+	budget, ok := qt.budgetFor(app)
+	if !ok {
+		return quotaAllow
+	}
+	label := quotaLabel(app)
+
+	qt.mut.Lock()
+	defer qt.mut.Unlock()
+
+	now := qt.now()
+	windowStart := budgetWindowStart(budget.Period, now)
+
+	usage := qt.unknown
+	if app != nil {
+		usage = qt.apps[app.Name]
+	}
+	if usage == nil || !usage.windowStart.Equal(windowStart) {
+		usage = &budgetUsage{windowStart: windowStart}
+		if app != nil {
+			qt.apps[app.Name] = usage
+		} else {
+			qt.unknown = usage
+		}
+	}
+
+	overBytes := budget.MaxBytes > 0 && usage.bytes+bytes > budget.MaxBytes
+	overEvents := budget.MaxEvents > 0 && usage.events+events > budget.MaxEvents
+
+	if overBytes || overEvents {
+		qt.overBudget.WithLabelValues(label, string(budget.OverBudgetAction)).Inc()
+
+		switch budget.OverBudgetAction {
+		case OverBudgetReject:
+			return quotaReject
+		case OverBudgetSample:
+			if qt.rand() >= budget.OverBudgetSampleRate {
+				return quotaDrop
+			}
+			// Sampled payloads are exported but not counted against the budget,
+			// which is already exhausted.
+			return quotaAllow
+		default:
+			return quotaDrop
+		}
+	}
+
+	usage.bytes += bytes
+	usage.events += events
+	qt.consumedBytes.WithLabelValues(label).Add(float64(bytes))
+	qt.consumedEvents.WithLabelValues(label).Add(float64(events))
+
+	if budget.MaxBytes > 0 {
+		qt.budgetUsed.WithLabelValues(label, "bytes").Set(float64(usage.bytes) / float64(budget.MaxBytes))
+	}
+	if budget.MaxEvents > 0 {
+		qt.budgetUsed.WithLabelValues(label, "events").Set(float64(usage.events) / float64(budget.MaxEvents))
+	}
+	return quotaAllow
+}
+
+// quotaLabel returns the app label of the metrics of payloads sent by app.
+// Payloads not sent with the API key of an app are counted against the
+// default budget and have an empty label, keeping the label bounded by the
+// configured apps
+func quotaLabel(app *AppConfig) string {
+	if app == nil {
+		return ""
+	}
+	return app.Name
+}
+
+// RetryAfter returns the time remaining until the budget window of app resets
+func (qt *quotaTracker) RetryAfter(app *AppConfig) time.Duration {
+	budget, ok := qt.budgetFor(app)
+	if !ok {
+		return 0
+	}
+	now := qt.now()
+	return budgetWindowStart(budget.Period, now).Add(budgetPeriodDuration(budget.Period)).Sub(now)
+}
+
+// budgetWindowStart returns the start of the budget window containing t.
+// Windows are aligned to UTC hours and days.
+func budgetWindowStart(period BudgetPeriod, t time.Time) time.Time {
+	return t.UTC().Truncate(budgetPeriodDuration(period))
+}
+
+func budgetPeriodDuration(period BudgetPeriod) time.Duration {
+	if period == BudgetPeriodHourly {
+		return time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// payloadEvents returns the number of events contained in a payload
+func payloadEvents(p Payload) int64 {
+	events := len(p.Exceptions) + len(p.Logs) + len(p.Measurements)
+	if p.Traces != nil {
+		events += p.Traces.SpanCount()
+	}
+	return int64(events)
+}
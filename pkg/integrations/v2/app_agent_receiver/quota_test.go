@@ -0,0 +1,215 @@
+package app_agent_receiver
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestQuotaTracker_Events(t *testing.T) {
+	now := time.Date(2022, time.June, 1, 10, 30, 0, 0, time.UTC)
+
+	qt := newQuotaTracker(QuotaConfig{
+		Apps: map[string]BudgetConfig{
+			"frontend": {Period: BudgetPeriodHourly, MaxEvents: 3, OverBudgetAction: OverBudgetDrop},
+		},
+	}, prometheus.NewRegistry())
+	qt.now = func() time.Time { return now }
+
+	var (
+		frontend = &AppConfig{Name: "frontend", APIKey: "frontend-key"}
+		backend  = &AppConfig{Name: "backend", APIKey: "backend-key"}
+	)
+
+	require.Equal(t, quotaAllow, qt.Check(frontend, 10, 2))
+	require.Equal(t, quotaAllow, qt.Check(frontend, 10, 1))
+	require.Equal(t, quotaDrop, qt.Check(frontend, 10, 1))
+
+	// Apps without a budget are never limited.
+	require.Equal(t, quotaAllow, qt.Check(backend, 10, 100))
+
+	// The budgets of apps only apply to their own API key.
+	require.Equal(t, quotaAllow, qt.Check(nil, 10, 100))
+
+	// The budget resets at the start of the next hour.
+	now = now.Add(30 * time.Minute)
+	require.Equal(t, quotaAllow, qt.Check(frontend, 10, 3))
+}
+
+func TestQuotaTracker_BytesDefaultBudget(t *testing.T) {
+	now := time.Date(2022, time.June, 1, 10, 30, 0, 0, time.UTC)
+
+	qt := newQuotaTracker(QuotaConfig{
+		Default: &BudgetConfig{Period: BudgetPeriodDaily, MaxBytes: 100, OverBudgetAction: OverBudgetReject},
+	}, prometheus.NewRegistry())
+	qt.now = func() time.Time { return now }
+
+	var (
+		frontend = &AppConfig{Name: "frontend", APIKey: "frontend-key"}
+		backend  = &AppConfig{Name: "backend", APIKey: "backend-key"}
+	)
+
+	// Payloads not sent with the key of an app share the default budget.
+	require.Equal(t, quotaAllow, qt.Check(nil, 100, 1))
+	require.Equal(t, quotaReject, qt.Check(nil, 1, 1))
+	require.Equal(t, 13*time.Hour+30*time.Minute, qt.RetryAfter(nil))
+
+	// Apps without a budget of their own get their own usage of the default
+	// budget.
+	require.Equal(t, quotaAllow, qt.Check(frontend, 100, 1))
+	require.Equal(t, quotaReject, qt.Check(frontend, 1, 1))
+	require.Equal(t, quotaAllow, qt.Check(backend, 100, 1))
+}
+
+func TestQuotaTracker_Sample(t *testing.T) {
+	qt := newQuotaTracker(QuotaConfig{
+		Default: &BudgetConfig{
+			Period:               BudgetPeriodDaily,
+			MaxEvents:            1,
+			OverBudgetAction:     OverBudgetSample,
+			OverBudgetSampleRate: 0.5,
+		},
+	}, prometheus.NewRegistry())
+
+	require.Equal(t, quotaAllow, qt.Check(nil, 1, 1))
+
+	qt.rand = func() float64 { return 0.25 }
+	require.Equal(t, quotaAllow, qt.Check(nil, 1, 1))
+
+	qt.rand = func() float64 { return 0.75 }
+	require.Equal(t, quotaDrop, qt.Check(nil, 1, 1))
+}
+
+func TestConfig_Quotas(t *testing.T) {
+	var cfg Config
+	cb := `
+quotas:
+  default:
+    max_events: 1000
+  apps:
+    frontend:
+      period: hourly
+      max_bytes: 5000
+      over_budget_action: reject`
+	require.NoError(t, yaml.Unmarshal([]byte(cb), &cfg))
+
+	require.Equal(t, BudgetConfig{
+		Period:               BudgetPeriodDaily,
+		MaxEvents:            1000,
+		OverBudgetAction:     OverBudgetDrop,
+		OverBudgetSampleRate: 0.1,
+	}, *cfg.Quotas.Default)
+	require.Equal(t, BudgetPeriodHourly, cfg.Quotas.Apps["frontend"].Period)
+	require.Equal(t, OverBudgetReject, cfg.Quotas.Apps["frontend"].OverBudgetAction)
+
+	cb = `
+quotas:
+  default:
+    over_budget_action: explode`
+	require.Error(t, yaml.Unmarshal([]byte(cb), &cfg))
+}
+
+func TestHandler_QuotaReject(t *testing.T) {
+	exporter := TestExporter{name: "exporter"}
+
+	conf := &Config{
+		Quotas: QuotaConfig{
+			Default: &BudgetConfig{Period: BudgetPeriodDaily, MaxEvents: 1, OverBudgetAction: OverBudgetReject},
+		},
+	}
+	fr := NewAppAgentReceiverHandler(conf, []AppAgentReceiverExporter{&exporter}, prometheus.NewRegistry())
+	handler := fr.HTTPHandler(log.NewNopLogger())
+
+	payload := `{"logs": [{"message": "hello"}], "meta": {"app": {"name": "frontend"}}}`
+
+	req, err := http.NewRequest("POST", "/collect", bytes.NewBufferString(payload))
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusAccepted, rr.Result().StatusCode)
+
+	req, err = http.NewRequest("POST", "/collect", bytes.NewBufferString(payload))
+	require.NoError(t, err)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusTooManyRequests, rr.Result().StatusCode)
+	require.NotEmpty(t, rr.Result().Header.Get("Retry-After"))
+
+	require.Len(t, exporter.payloads, 1)
+}
+
+// TestHandler_QuotaAppName ensures that clients can't use the budget of
+// another app by setting its name in the payload.
+func TestHandler_QuotaAppName(t *testing.T) {
+	exporter := TestExporter{name: "exporter"}
+
+	conf := &Config{
+		Apps: []AppConfig{{Name: "frontend", APIKey: "frontend-key"}},
+		Quotas: QuotaConfig{
+			Apps: map[string]BudgetConfig{
+				"frontend": {Period: BudgetPeriodDaily, MaxEvents: 1, OverBudgetAction: OverBudgetReject},
+			},
+		},
+	}
+	fr := NewAppAgentReceiverHandler(conf, []AppAgentReceiverExporter{&exporter}, prometheus.NewRegistry())
+	handler := fr.HTTPHandler(log.NewNopLogger())
+
+	payload := `{"logs": [{"message": "hello"}], "meta": {"app": {"name": "frontend"}}}`
+	send := func(apiKey string) int {
+		req, err := http.NewRequest("POST", "/collect", bytes.NewBufferString(payload))
+		require.NoError(t, err)
+		req.Header.Set(apiKeyHeader, apiKey)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Result().StatusCode
+	}
+
+	// Payloads sent without the key of the app don't count against its
+	// budget.
+	require.Equal(t, http.StatusAccepted, send(""))
+	require.Equal(t, http.StatusAccepted, send(""))
+
+	require.Equal(t, http.StatusAccepted, send("frontend-key"))
+	require.Equal(t, http.StatusTooManyRequests, send("frontend-key"))
+}
+
+// TestHandler_QuotaUnknownKeys ensures that clients can't reset the default
+// budget by rotating API keys, or reset the budget of an app by sending many
+// distinct keys.
+func TestHandler_QuotaUnknownKeys(t *testing.T) {
+	exporter := TestExporter{name: "exporter"}
+
+	conf := &Config{
+		Apps: []AppConfig{{Name: "frontend", APIKey: "frontend-key"}},
+		Quotas: QuotaConfig{
+			Default: &BudgetConfig{Period: BudgetPeriodDaily, MaxEvents: 1, OverBudgetAction: OverBudgetReject},
+		},
+	}
+	fr := NewAppAgentReceiverHandler(conf, []AppAgentReceiverExporter{&exporter}, prometheus.NewRegistry())
+	handler := fr.HTTPHandler(log.NewNopLogger())
+
+	payload := `{"logs": [{"message": "hello"}], "meta": {"app": {"name": "frontend"}}}`
+	send := func(apiKey string) int {
+		req, err := http.NewRequest("POST", "/collect", bytes.NewBufferString(payload))
+		require.NoError(t, err)
+		req.Header.Set(apiKeyHeader, apiKey)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Result().StatusCode
+	}
+
+	require.Equal(t, http.StatusAccepted, send("frontend-key"))
+	require.Equal(t, http.StatusAccepted, send("random-0"))
+	for i := 1; i < 100; i++ {
+		require.Equal(t, http.StatusTooManyRequests, send(fmt.Sprintf("random-%d", i)))
+	}
+	require.Equal(t, http.StatusTooManyRequests, send("frontend-key"))
+}
@@ -0,0 +1,147 @@
+package app_agent_receiver
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+)
+
+// clientLimiters holds the limiters of a single client. Either limiter is
+// nil if that dimension is unlimited
+type clientLimiters struct {
+	requests *rate.Limiter
+	bytes    *rate.Limiter
+}
+
+// clientRateLimiter rate limits requests and payload bytes per client. Only
+// the most recently seen clients are tracked, bounding memory use when many
+// distinct clients send requests
+type clientRateLimiter struct {
+	conf    ClientRateLimitingConfig
+	now     func() time.Time
+	clients *lru.Cache
+}
+
+func newClientRateLimiter(conf ClientRateLimitingConfig) *clientRateLimiter {
+	// lru.New only fails for non-positive sizes, which config validation
+	// rejects
+	clients, err := lru.New(conf.MaxClients)
+	if err != nil {
+		panic(err)
+	}
+	return &clientRateLimiter{
+		conf:    conf,
+		now:     time.Now,
+		clients: clients,
+	}
+}
+
+// ClientKey returns the key identifying the client of r. Clients are only
+// identified by their API key if knownKey returns true for it, so rotating
+// unknown keys doesn't give a client a new limit
+func (cl *clientRateLimiter) ClientKey(r *http.Request, knownKey func(string) bool) string {
+	if cl.conf.Key == ClientKeyAPIKey {
+		if key := r.Header.Get(apiKeyHeader); key != "" && knownKey(key) {
+			return "key:" + key
+		}
+	}
+	return "ip:" + clientIP(r, cl.conf.TrustForwardedFor)
+}
+
+func (cl *clientRateLimiter) limiters(client string) *clientLimiters {
+	if v, ok := cl.clients.Get(client); ok {
+		return v.(*clientLimiters)
+	}
+
+	var l clientLimiters
+	if cl.conf.RPS > 0 {
+		l.requests = rate.NewLimiter(rate.Limit(cl.conf.RPS), cl.conf.Burstiness)
+	}
+	if cl.conf.BytesPerSecond > 0 {
+		burst := cl.conf.BytesBurstiness
+		if burst <= 0 {
+			burst = int(math.Ceil(cl.conf.BytesPerSecond))
+		}
+		l.bytes = rate.NewLimiter(rate.Limit(cl.conf.BytesPerSecond), burst)
+	}
+
+	// Another request of the same client may have added limiters in the
+	// meantime, in which case those are used instead
+	if prev, ok, _ := cl.clients.PeekOrAdd(client, &l); ok {
+		return prev.(*clientLimiters)
+	}
+	return &l
+}
+
+// AllowRequest checks a request of client against its request rate. If the
+// request is not allowed, AllowRequest returns how long the client should
+// wait before retrying
+func (cl *clientRateLimiter) AllowRequest(client string) (bool, time.Duration) {
+	return allow(cl.limiters(client).requests, cl.now(), 1)
+}
+
+// AllowBytes checks a payload of n bytes sent by client against its byte
+// rate. Payloads larger than the burst are counted as a full burst, so that
+// they can eventually be accepted
+func (cl *clientRateLimiter) AllowBytes(client string, n int64) (bool, time.Duration) {
+	l := cl.limiters(client).bytes
+	if l != nil && n > int64(l.Burst()) {
+		n = int64(l.Burst())
+	}
+	return allow(l, cl.now(), int(n))
+}
+
+// allow reserves n tokens of l at now, returning false and the time until
+// the tokens are available if they can't be taken immediately. A nil
+// limiter allows everything
+func allow(l *rate.Limiter, now time.Time, n int) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+	rsv := l.ReserveN(now, n)
+	if !rsv.OK() {
+		return false, 0
+	}
+	if delay := rsv.DelayFrom(now); delay > 0 {
+		rsv.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// clientIP returns the IP address of the client of r. If trustForwardedFor
+// is set, the last address of the X-Forwarded-For header is used if present.
+// The last address is the one added by the proxy in front of the receiver;
+// earlier addresses are set by the client and can't be trusted
+func clientIP(r *http.Request, trustForwardedFor bool) string {
+	if trustForwardedFor {
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			addrs := strings.Split(fwd, ",")
+			if last := strings.TrimSpace(addrs[len(addrs)-1]); last != "" {
+				return last
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// rejectRateLimited responds to a rate limited request with 429. Retry-After
+// is set to retryAfter rounded up to whole seconds, if known
+func rejectRateLimited(w http.ResponseWriter, retryAfter time.Duration, limit string, counter *prometheus.CounterVec) {
+	counter.WithLabelValues(limit).Inc()
+	if retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	}
+	http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+}
@@ -0,0 +1,174 @@
+package app_agent_receiver
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestClientRateLimiter_Requests(t *testing.T) {
+	now := time.Date(2022, time.June, 1, 10, 30, 0, 0, time.UTC)
+
+	conf := DefaultClientRateLimitingConfig
+	conf.RPS, conf.Burstiness = 1, 2
+	cl := newClientRateLimiter(conf)
+	cl.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		ok, _ := cl.AllowRequest("a")
+		require.True(t, ok)
+	}
+	ok, retryAfter := cl.AllowRequest("a")
+	require.False(t, ok)
+	require.Equal(t, time.Second, retryAfter)
+
+	// Other clients have their own limits.
+	ok, _ = cl.AllowRequest("b")
+	require.True(t, ok)
+
+	now = now.Add(time.Second)
+	ok, _ = cl.AllowRequest("a")
+	require.True(t, ok)
+}
+
+func TestClientRateLimiter_Bytes(t *testing.T) {
+	now := time.Date(2022, time.June, 1, 10, 30, 0, 0, time.UTC)
+
+	conf := DefaultClientRateLimitingConfig
+	conf.RPS = 0
+	conf.BytesPerSecond = 100
+	cl := newClientRateLimiter(conf)
+	cl.now = func() time.Time { return now }
+
+	ok, _ := cl.AllowBytes("a", 60)
+	require.True(t, ok)
+	ok, retryAfter := cl.AllowBytes("a", 60)
+	require.False(t, ok)
+	require.Equal(t, 200*time.Millisecond, retryAfter)
+
+	// Payloads larger than the burst count as a full burst.
+	now = now.Add(time.Second)
+	ok, _ = cl.AllowBytes("a", 1000)
+	require.True(t, ok)
+
+	// Requests are unlimited with a zero rps.
+	for i := 0; i < 100; i++ {
+		ok, _ := cl.AllowRequest("a")
+		require.True(t, ok)
+	}
+}
+
+func TestClientRateLimiter_MaxClients(t *testing.T) {
+	conf := DefaultClientRateLimitingConfig
+	conf.RPS, conf.Burstiness, conf.MaxClients = 1, 1, 1
+	cl := newClientRateLimiter(conf)
+	cl.now = func() time.Time { return time.Date(2022, time.June, 1, 10, 30, 0, 0, time.UTC) }
+
+	ok, _ := cl.AllowRequest("a")
+	require.True(t, ok)
+	ok, _ = cl.AllowRequest("b")
+	require.True(t, ok)
+
+	// a was forgotten once b was seen, so it starts with a full burst again.
+	ok, _ = cl.AllowRequest("a")
+	require.True(t, ok)
+}
+
+func TestClientRateLimiter_ClientKey(t *testing.T) {
+	req := httptest.NewRequest("POST", "/collect", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "192.168.0.1, 10.0.0.2")
+
+	tt := []struct {
+		name              string
+		key               ClientKey
+		trustForwardedFor bool
+		apiKey            string
+		expect            string
+	}{
+		{name: "ip", key: ClientKeyIP, expect: "ip:10.0.0.1"},
+		{name: "forwarded ip", key: ClientKeyIP, trustForwardedFor: true, expect: "ip:10.0.0.2"},
+		{name: "api key", key: ClientKeyAPIKey, apiKey: "secret", expect: "key:secret"},
+		{name: "unknown api key", key: ClientKeyAPIKey, apiKey: "random", expect: "ip:10.0.0.1"},
+		{name: "missing api key", key: ClientKeyAPIKey, expect: "ip:10.0.0.1"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			conf := DefaultClientRateLimitingConfig
+			conf.Key, conf.TrustForwardedFor = tc.key, tc.trustForwardedFor
+			cl := newClientRateLimiter(conf)
+
+			r := req.Clone(req.Context())
+			if tc.apiKey != "" {
+				r.Header.Set(apiKeyHeader, tc.apiKey)
+			}
+			knownKey := func(key string) bool { return key == "secret" }
+			require.Equal(t, tc.expect, cl.ClientKey(r, knownKey))
+		})
+	}
+}
+
+func TestConfig_PerClientRateLimiting(t *testing.T) {
+	var cfg Config
+	cb := `
+server:
+  rate_limiting:
+    per_client:
+      key: api_key
+      bytes_per_second: 1024`
+	require.NoError(t, yaml.Unmarshal([]byte(cb), &cfg))
+
+	expect := DefaultClientRateLimitingConfig
+	expect.Key = ClientKeyAPIKey
+	expect.BytesPerSecond = 1024
+	require.Equal(t, &expect, cfg.Server.RateLimiting.PerClient)
+
+	cb = `
+server:
+  rate_limiting:
+    per_client:
+      key: user`
+	require.EqualError(t, yaml.Unmarshal([]byte(cb), &cfg), `invalid per_client key "user", expected ip or api_key`)
+}
+
+func TestHandler_PerClientRateLimit(t *testing.T) {
+	conf := &Config{
+		Server: ServerConfig{
+			RateLimiting: RateLimitingConfig{
+				Enabled:    true,
+				RPS:        100,
+				Burstiness: 100,
+				PerClient: &ClientRateLimitingConfig{
+					Key:        ClientKeyIP,
+					RPS:        1,
+					Burstiness: 1,
+					MaxClients: 10,
+				},
+			},
+		},
+	}
+	fr := NewAppAgentReceiverHandler(conf, nil, prometheus.NewRegistry())
+	handler := fr.HTTPHandler(nil)
+
+	send := func(addr string) *http.Response {
+		req := httptest.NewRequest("POST", "/collect", bytes.NewBuffer([]byte(PAYLOAD)))
+		req.RemoteAddr = addr
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Result()
+	}
+
+	require.Equal(t, http.StatusAccepted, send("10.0.0.1:1234").StatusCode)
+
+	resp := send("10.0.0.1:1235")
+	require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	require.Equal(t, "1", resp.Header.Get("Retry-After"))
+
+	require.Equal(t, http.StatusAccepted, send("10.0.0.2:1234").StatusCode)
+}
@@ -0,0 +1,205 @@
+package app_agent_receiver
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Built-in scrubbing patterns
+const (
+	ScrubEmail      = "email"
+	ScrubIP         = "ip"
+	ScrubCreditCard = "credit_card"
+)
+
+// Fields which can be scrubbed entirely
+const (
+	ScrubFieldUserEmail    = "meta.user.email"
+	ScrubFieldUserID       = "meta.user.id"
+	ScrubFieldUserUsername = "meta.user.username"
+	ScrubFieldPageURL      = "meta.page.url"
+)
+
+var builtinScrubPatterns = map[string]*regexp.Regexp{
+	ScrubEmail: regexp.MustCompile(`[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}`),
+	ScrubIP:    regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4][0-9]|1[0-9][0-9]|[1-9]?[0-9])\.){3}(?:25[0-5]|2[0-4][0-9]|1[0-9][0-9]|[1-9]?[0-9])\b`),
+	// Candidates are checked with the Luhn algorithm before they are
+	// scrubbed, so other long numbers are kept
+	ScrubCreditCard: regexp.MustCompile(`\b[0-9](?:[ -]?[0-9]){12,18}\b`),
+}
+
+// DefaultScrubbingConfig holds the default values of a ScrubbingConfig
+var DefaultScrubbingConfig = ScrubbingConfig{
+	BuiltinPatterns: []string{ScrubEmail, ScrubIP, ScrubCreditCard},
+	Replacement:     "[REDACTED]",
+}
+
+// ScrubbingConfig configures the removal of personally identifiable
+// information from payloads before they are exported
+type ScrubbingConfig struct {
+	// BuiltinPatterns are the names of built-in patterns to scrub
+	BuiltinPatterns []string           `yaml:"builtin_patterns"`
+	Patterns        []ScrubbingPattern `yaml:"patterns,omitempty"`
+
+	// Keys are the keys of log context and attributes whose values are
+	// replaced entirely
+	Keys []string `yaml:"keys,omitempty"`
+	// Fields are the metadata fields which are replaced entirely
+	Fields []string `yaml:"fields,omitempty"`
+
+	Replacement string `yaml:"replacement,omitempty"`
+}
+
+// ScrubbingPattern is a regular expression whose matches are scrubbed
+type ScrubbingPattern struct {
+	Name  string `yaml:"name"`
+	Regex string `yaml:"regex"`
+}
+
+// UnmarshalYAML implements the Unmarshaler interface
+func (c *ScrubbingConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultScrubbingConfig
+	type plain ScrubbingConfig
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	for _, name := range c.BuiltinPatterns {
+		if _, ok := builtinScrubPatterns[name]; !ok {
+			return fmt.Errorf("unknown builtin scrubbing pattern %q, expected one of email, ip or credit_card", name)
+		}
+	}
+	for _, p := range c.Patterns {
+		if _, err := regexp.Compile(p.Regex); err != nil {
+			return fmt.Errorf("invalid regex of scrubbing pattern %q: %w", p.Name, err)
+		}
+	}
+	for _, f := range c.Fields {
+		switch f {
+		case ScrubFieldUserEmail, ScrubFieldUserID, ScrubFieldUserUsername, ScrubFieldPageURL:
+		default:
+			return fmt.Errorf("unsupported scrubbing field %q", f)
+		}
+	}
+	return nil
+}
+
+// scrubber removes personally identifiable information from payloads
+type scrubber struct {
+	replacement string
+	patterns    []*regexp.Regexp
+	creditCard  bool // Whether to scrub credit card numbers
+	keys        map[string]struct{}
+	fields      map[string]struct{}
+}
+
+func newScrubber(conf ScrubbingConfig) (*scrubber, error) {
+	s := &scrubber{
+		replacement: conf.Replacement,
+		keys:        make(map[string]struct{}, len(conf.Keys)),
+		fields:      make(map[string]struct{}, len(conf.Fields)),
+	}
+	for _, name := range conf.BuiltinPatterns {
+		if name == ScrubCreditCard {
+			s.creditCard = true
+			continue
+		}
+		s.patterns = append(s.patterns, builtinScrubPatterns[name])
+	}
+	for _, p := range conf.Patterns {
+		re, err := regexp.Compile(p.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex of scrubbing pattern %q: %w", p.Name, err)
+		}
+		s.patterns = append(s.patterns, re)
+	}
+	for _, k := range conf.Keys {
+		s.keys[k] = struct{}{}
+	}
+	for _, f := range conf.Fields {
+		s.fields[f] = struct{}{}
+	}
+	return s, nil
+}
+
+// Scrub removes personally identifiable information from exception
+// messages, logs, log context and metadata of p. Traces are not scrubbed.
+func (s *scrubber) Scrub(p *Payload) {
+	for i := range p.Exceptions {
+		p.Exceptions[i].Value = s.scrubString(p.Exceptions[i].Value)
+	}
+	for i := range p.Logs {
+		p.Logs[i].Message = s.scrubString(p.Logs[i].Message)
+		s.scrubMap(p.Logs[i].Context)
+	}
+
+	meta := &p.Meta
+	meta.User.Email = s.scrubField(ScrubFieldUserEmail, meta.User.Email)
+	meta.User.ID = s.scrubField(ScrubFieldUserID, meta.User.ID)
+	meta.User.Username = s.scrubField(ScrubFieldUserUsername, meta.User.Username)
+	meta.Page.URL = s.scrubField(ScrubFieldPageURL, meta.Page.URL)
+	s.scrubMap(meta.User.Attributes)
+	s.scrubMap(meta.Session.Attributes)
+	s.scrubMap(meta.Page.Attributes)
+}
+
+// scrubField replaces v entirely if field is configured to be scrubbed, and
+// scrubs its matches otherwise
+func (s *scrubber) scrubField(field string, v string) string {
+	if _, ok := s.fields[field]; ok && v != "" {
+		return s.replacement
+	}
+	return s.scrubString(v)
+}
+
+// scrubMap scrubs the values of m in place. Values of configured keys are
+// replaced entirely
+func (s *scrubber) scrubMap(m map[string]string) {
+	for k, v := range m {
+		if _, ok := s.keys[k]; ok {
+			m[k] = s.replacement
+			continue
+		}
+		m[k] = s.scrubString(v)
+	}
+}
+
+func (s *scrubber) scrubString(v string) string {
+	if v == "" {
+		return v
+	}
+	for _, re := range s.patterns {
+		v = re.ReplaceAllString(v, s.replacement)
+	}
+	if s.creditCard {
+		v = builtinScrubPatterns[ScrubCreditCard].ReplaceAllStringFunc(v, func(match string) string {
+			if luhnValid(match) {
+				return s.replacement
+			}
+			return match
+		})
+	}
+	return v
+}
+
+// luhnValid returns true if the digits of number, ignoring other characters,
+// pass the Luhn checksum used by credit card numbers
+func luhnValid(number string) bool {
+	var sum, n int
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n > 0 && sum%10 == 0
+}
@@ -0,0 +1,72 @@
+package app_agent_receiver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestScrubber(t *testing.T) {
+	conf := DefaultScrubbingConfig
+	conf.Patterns = []ScrubbingPattern{{Name: "ssn", Regex: `[0-9]{3}-[0-9]{2}-[0-9]{4}`}}
+	conf.Keys = []string{"password"}
+	conf.Fields = []string{ScrubFieldUserEmail}
+	s, err := newScrubber(conf)
+	require.NoError(t, err)
+
+	p := Payload{
+		Exceptions: []Exception{{Type: "Error", Value: "no account for jane@example.com"}},
+		Logs: []Log{{
+			Message: "payment with 4111 1111 1111 1111 failed, order 1234567890123",
+			Context: LogContext{"password": "hunter2", "client": "10.0.0.1", "ssn": "123-45-6789"},
+		}},
+		Meta: Meta{
+			User: User{Email: "jane", Username: "jane", Attributes: map[string]string{"contact": "jane@example.com"}},
+			Page: Page{URL: "https://example.com/?email=jane@example.com"},
+		},
+	}
+	s.Scrub(&p)
+
+	require.Equal(t, "no account for [REDACTED]", p.Exceptions[0].Value)
+	// Numbers which fail the Luhn check are kept.
+	require.Equal(t, "payment with [REDACTED] failed, order 1234567890123", p.Logs[0].Message)
+	require.Equal(t, LogContext{"password": "[REDACTED]", "client": "[REDACTED]", "ssn": "[REDACTED]"}, p.Logs[0].Context)
+	require.Equal(t, "[REDACTED]", p.Meta.User.Email)
+	require.Equal(t, "jane", p.Meta.User.Username)
+	require.Equal(t, "[REDACTED]", p.Meta.User.Attributes["contact"])
+	require.Equal(t, "https://example.com/?email=[REDACTED]", p.Meta.Page.URL)
+}
+
+func TestLuhnValid(t *testing.T) {
+	require.True(t, luhnValid("4111-1111-1111-1111"))
+	require.True(t, luhnValid("5500 0000 0000 0004"))
+	require.False(t, luhnValid("4111 1111 1111 1112"))
+}
+
+func TestConfig_Scrubbing(t *testing.T) {
+	var cfg Config
+	cb := `
+scrubbing:
+  keys: [password]`
+	require.NoError(t, yaml.Unmarshal([]byte(cb), &cfg))
+	require.Equal(t, DefaultScrubbingConfig.BuiltinPatterns, cfg.Scrubbing.BuiltinPatterns)
+	require.Equal(t, "[REDACTED]", cfg.Scrubbing.Replacement)
+
+	cb = `
+scrubbing:
+  builtin_patterns: [phone]`
+	require.EqualError(t, yaml.Unmarshal([]byte(cb), &cfg), `unknown builtin scrubbing pattern "phone", expected one of email, ip or credit_card`)
+
+	cb = `
+scrubbing:
+  patterns:
+    - name: broken
+      regex: "[a-z"`
+	require.Error(t, yaml.Unmarshal([]byte(cb), &cfg))
+
+	cb = `
+scrubbing:
+  fields: [meta.user.name]`
+	require.EqualError(t, yaml.Unmarshal([]byte(cb), &cfg), `unsupported scrubbing field "meta.user.name"`)
+}
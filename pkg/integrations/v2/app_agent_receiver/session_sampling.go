@@ -0,0 +1,113 @@
+package app_agent_receiver
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultSessionSamplingConfig holds the default values of a
+// SessionSamplingConfig
+var DefaultSessionSamplingConfig = SessionSamplingConfig{
+	Rate:                       1,
+	KeepSessionsWithExceptions: true,
+	MaxExceptionSessions:       10000,
+}
+
+// SessionSamplingConfig configures sampling payloads by session, so that
+// either all or none of the payloads of a session are kept
+type SessionSamplingConfig struct {
+	// Rate is the fraction of sessions which are kept, between 0 and 1
+	Rate float64 `yaml:"rate"`
+	// KeepSessionsWithExceptions keeps sessions which sent an exception,
+	// from the first payload with an exception on
+	KeepSessionsWithExceptions bool `yaml:"keep_sessions_with_exceptions"`
+	// MaxExceptionSessions is the number of sessions with exceptions which
+	// are remembered
+	MaxExceptionSessions int `yaml:"max_exception_sessions,omitempty"`
+}
+
+// UnmarshalYAML implements the Unmarshaler interface
+func (c *SessionSamplingConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultSessionSamplingConfig
+	type plain SessionSamplingConfig
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+	if c.Rate < 0 || c.Rate > 1 {
+		return fmt.Errorf("session sampling rate must be between 0 and 1")
+	}
+	if c.KeepSessionsWithExceptions && c.MaxExceptionSessions <= 0 {
+		return fmt.Errorf("max_exception_sessions must be greater than 0")
+	}
+	return nil
+}
+
+// sessionSampler decides whether the payloads of a session are kept. The
+// decision only depends on the session ID, so it is the same for every
+// payload of a session and across agents
+type sessionSampler struct {
+	conf SessionSamplingConfig
+	// exceptionSessions holds the IDs of recent sessions with exceptions.
+	// nil if sessions with exceptions aren't always kept
+	exceptionSessions *lru.Cache
+
+	sampledOut prometheus.Counter
+}
+
+func newSessionSampler(conf SessionSamplingConfig, reg prometheus.Registerer) *sessionSampler {
+	s := &sessionSampler{
+		conf: conf,
+		sampledOut: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "app_agent_receiver_sampled_out_payloads_total",
+			Help: "Total number of payloads dropped because their session was not sampled",
+		}),
+	}
+	if conf.KeepSessionsWithExceptions {
+		// lru.New only fails for non-positive sizes, which config validation
+		// rejects
+		sessions, err := lru.New(conf.MaxExceptionSessions)
+		if err != nil {
+			panic(err)
+		}
+		s.exceptionSessions = sessions
+	}
+	reg.MustRegister(s.sampledOut)
+	return s
+}
+
+// Keep returns true if p should be exported. Payloads without a session ID
+// are always kept
+func (s *sessionSampler) Keep(p Payload) bool {
+	id := p.Meta.Session.ID
+	if id == "" || sessionSampled(id, s.conf.Rate) {
+		return true
+	}
+
+	if s.exceptionSessions != nil {
+		if len(p.Exceptions) > 0 {
+			s.exceptionSessions.Add(id, struct{}{})
+			return true
+		}
+		if s.exceptionSessions.Contains(id) {
+			return true
+		}
+	}
+
+	s.sampledOut.Inc()
+	return false
+}
+
+// sessionSampled returns true if the session id is part of the sampled
+// fraction rate of sessions
+func sessionSampled(id string, rate float64) bool {
+	if rate >= 1 {
+		return true
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(id))
+	return float64(h.Sum64()) < rate*math.MaxUint64
+}
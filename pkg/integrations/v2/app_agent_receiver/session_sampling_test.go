@@ -0,0 +1,91 @@
+package app_agent_receiver
+
+import (
+	"bytes"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestSessionSampled(t *testing.T) {
+	var kept int
+	for i := 0; i < 10000; i++ {
+		id := fmt.Sprintf("session-%d", i)
+		if sessionSampled(id, 0.25) {
+			kept++
+			// The decision is the same for every payload of a session.
+			require.True(t, sessionSampled(id, 0.25))
+		}
+	}
+	require.InDelta(t, 2500, kept, 250)
+
+	require.True(t, sessionSampled("abcd", 1))
+	require.False(t, sessionSampled("abcd", 0))
+}
+
+func TestSessionSampler(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	s := newSessionSampler(SessionSamplingConfig{
+		Rate:                       0,
+		KeepSessionsWithExceptions: true,
+		MaxExceptionSessions:       10,
+	}, reg)
+
+	session := func(id string, exceptions int) Payload {
+		return Payload{
+			Exceptions: make([]Exception, exceptions),
+			Meta:       Meta{Session: Session{ID: id}},
+		}
+	}
+
+	require.True(t, s.Keep(session("", 0)))
+	require.False(t, s.Keep(session("abcd", 0)))
+	// Once a session sent an exception, its payloads are kept.
+	require.True(t, s.Keep(session("abcd", 1)))
+	require.True(t, s.Keep(session("abcd", 0)))
+	require.False(t, s.Keep(session("efgh", 0)))
+
+	expect := `
+# HELP app_agent_receiver_sampled_out_payloads_total Total number of payloads dropped because their session was not sampled
+# TYPE app_agent_receiver_sampled_out_payloads_total counter
+app_agent_receiver_sampled_out_payloads_total 2
+`
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expect)))
+}
+
+func TestHandler_SessionSampling(t *testing.T) {
+	conf := &Config{SessionSampling: &SessionSamplingConfig{Rate: 0}}
+	exporter := &TestExporter{name: "exporter"}
+	fr := NewAppAgentReceiverHandler(conf, []AppAgentReceiverExporter{exporter}, prometheus.NewRegistry())
+
+	req := httptest.NewRequest("POST", "/collect", bytes.NewBuffer(loadTestData(t, "payload.json")))
+	rr := httptest.NewRecorder()
+	fr.HTTPHandler(nil).ServeHTTP(rr, req)
+
+	require.Equal(t, 202, rr.Result().StatusCode)
+	require.Empty(t, exporter.payloads)
+}
+
+func TestConfig_SessionSampling(t *testing.T) {
+	var cfg Config
+	cb := `
+session_sampling:
+  rate: 0.1`
+	require.NoError(t, yaml.Unmarshal([]byte(cb), &cfg))
+	require.Equal(t, SessionSamplingConfig{
+		Rate:                       0.1,
+		KeepSessionsWithExceptions: true,
+		MaxExceptionSessions:       10000,
+	}, *cfg.SessionSampling)
+
+	cb = `
+session_sampling:
+  rate: 1.5`
+	require.EqualError(t, yaml.Unmarshal([]byte(cb), &cfg), "session sampling rate must be between 0 and 1")
+}
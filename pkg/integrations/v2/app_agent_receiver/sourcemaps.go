@@ -2,6 +2,7 @@ package app_agent_receiver
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
@@ -18,13 +19,14 @@ import (
 	"github.com/go-kit/log/level"
 	"github.com/go-sourcemap/sourcemap"
 	"github.com/prometheus/client_golang/prometheus"
+	promconfig "github.com/prometheus/common/config"
 	"github.com/vincent-petithory/dataurl"
 )
 
 // SourceMapStore is interface for a sourcemap service capable of transforming
 // minified source locations to original source location
 type SourceMapStore interface {
-	GetSourceMap(sourceURL string, release string) (*SourceMap, error)
+	GetSourceMap(sourceURL string, app string, release string) (*SourceMap, error)
 }
 
 type httpClient interface {
@@ -48,6 +50,9 @@ func (s *osFileService) ReadFile(name string) ([]byte, error) {
 	return os.ReadFile(name)
 }
 
+// errFileNotFound is returned when a download responds with 404
+var errFileNotFound = errors.New("file not found")
+
 var reSourceMap = "//[#@]\\s(source(?:Mapping)?URL)=\\s*(?P<url>\\S+)\r?\n?$"
 
 // SourceMap is a wrapper for go-sourcemap consumer
@@ -61,11 +66,23 @@ type sourceMapMetrics struct {
 	fileReads *prometheus.CounterVec
 }
 
+type sourceMapCacheKey struct {
+	sourceURL string
+	app       string
+	release   string
+}
+
 type sourcemapFileLocation struct {
 	SourceMapFileLocation
 	pathTemplate *template.Template
 }
 
+type sourcemapURLLocation struct {
+	SourceMapURLLocation
+	urlTemplate *template.Template
+	httpClient  httpClient
+}
+
 // RealSourceMapStore is an implementation of SourceMapStore
 // that can download source maps or read them from file system
 type RealSourceMapStore struct {
@@ -74,14 +91,15 @@ type RealSourceMapStore struct {
 	httpClient    httpClient
 	fileService   fileService
 	config        SourceMapConfig
-	cache         map[string]*SourceMap
+	cache         map[sourceMapCacheKey]*SourceMap
 	fileLocations []*sourcemapFileLocation
+	urlLocations  []*sourcemapURLLocation
 	metrics       *sourceMapMetrics
 }
 
 // NewSourceMapStore creates an instance of SourceMapStore.
 // httpClient and fileService will be instantiated to defaults if nil is provided
-func NewSourceMapStore(l log.Logger, config SourceMapConfig, reg prometheus.Registerer, httpClient httpClient, fileService fileService) SourceMapStore {
+func NewSourceMapStore(l log.Logger, config SourceMapConfig, reg prometheus.Registerer, httpClient httpClient, fileService fileService) *RealSourceMapStore {
 	if httpClient == nil {
 		httpClient = &http.Client{
 			Timeout: config.DownloadTimeout,
@@ -122,39 +140,75 @@ func NewSourceMapStore(l log.Logger, config SourceMapConfig, reg prometheus.Regi
 		})
 	}
 
+	urlLocations := []*sourcemapURLLocation{}
+
+	for _, configLocation := range config.Remote {
+		tpl, err := template.New(configLocation.URL).Parse(configLocation.URL)
+		if err != nil {
+			panic(err)
+		}
+
+		client, err := promconfig.NewClientFromConfig(configLocation.HTTPClientConfig, "app_agent_receiver_sourcemaps")
+		if err != nil {
+			level.Error(l).Log("msg", "failed to create http client for sourcemap location, ignoring location", "url", configLocation.URL, "err", err)
+			continue
+		}
+		client.Timeout = config.DownloadTimeout
+
+		urlLocations = append(urlLocations, &sourcemapURLLocation{
+			SourceMapURLLocation: configLocation,
+			urlTemplate:          tpl,
+			httpClient:           client,
+		})
+	}
+
 	return &RealSourceMapStore{
 		l:             l,
 		httpClient:    httpClient,
 		fileService:   fileService,
 		config:        config,
-		cache:         make(map[string]*SourceMap),
+		cache:         make(map[sourceMapCacheKey]*SourceMap),
 		metrics:       metrics,
 		fileLocations: fileLocations,
+		urlLocations:  urlLocations,
 	}
 }
 
-func (store *RealSourceMapStore) downloadFileContents(url string) ([]byte, error) {
-	resp, err := store.httpClient.Get(url)
+func (store *RealSourceMapStore) downloadFileContents(client httpClient, url string) ([]byte, error) {
+	resp, err := client.Get(url)
 	if err != nil {
 		store.metrics.downloads.WithLabelValues(getOrigin(url), "?").Inc()
 		return nil, err
 	}
 	defer resp.Body.Close()
 	store.metrics.downloads.WithLabelValues(getOrigin(url), fmt.Sprint(resp.StatusCode)).Inc()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errFileNotFound
+	}
 	if resp.StatusCode != 200 {
 		return nil, fmt.Errorf("unexpected status %v", resp.StatusCode)
 	}
-	body, err := io.ReadAll(resp.Body)
+	maxSize := store.config.MaxDownloadSize
+	if maxSize <= 0 {
+		return io.ReadAll(resp.Body)
+	}
+	if resp.ContentLength > maxSize {
+		return nil, fmt.Errorf("file size %d exceeds maximum download size of %d bytes", resp.ContentLength, maxSize)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
 	if err != nil {
 		return nil, err
 	}
+	if int64(len(body)) > maxSize {
+		return nil, fmt.Errorf("file exceeds maximum download size of %d bytes", maxSize)
+	}
 	return body, nil
 }
 
 func (store *RealSourceMapStore) downloadSourceMapContent(sourceURL string) (content []byte, resolvedSourceMapURL string, err error) {
 	level.Debug(store.l).Log("msg", "attempting to download source file", "url", sourceURL)
 
-	result, err := store.downloadFileContents(sourceURL)
+	result, err := store.downloadFileContents(store.httpClient, sourceURL)
 	if err != nil {
 		level.Debug(store.l).Log("msg", "failed to download source file", "url", sourceURL, "err", err)
 		return nil, "", err
@@ -197,7 +251,7 @@ func (store *RealSourceMapStore) downloadSourceMapContent(sourceURL string) (con
 		level.Debug(store.l).Log("msg", "resolved absolute soure map url", "url", sourceURL, "sourceMapURL", resolvedSourceMapURL)
 	}
 	level.Debug(store.l).Log("msg", "attempting to download sourcemap file", "url", resolvedSourceMapURL)
-	result, err = store.downloadFileContents(resolvedSourceMapURL)
+	result, err = store.downloadFileContents(store.httpClient, resolvedSourceMapURL)
 	if err != nil {
 		level.Debug(store.l).Log("failed to download source map file", "url", resolvedSourceMapURL, "err", err)
 		return nil, "", err
@@ -217,12 +271,7 @@ func (store *RealSourceMapStore) getSourceMapFromFileSystem(sourceURL string, re
 		return nil, "", err
 	}
 
-	pathParts := []string{rootPath.String()}
-	for _, part := range strings.Split(strings.TrimPrefix(strings.Split(sourceURL, "?")[0], fileconf.MinifiedPathPrefix), "/") {
-		if len(part) > 0 && part != "." && part != ".." {
-			pathParts = append(pathParts, part)
-		}
-	}
+	pathParts := append([]string{rootPath.String()}, sourcePathParts(sourceURL, fileconf.MinifiedPathPrefix)...)
 	mapFilePath := filepath.Join(pathParts...) + ".map"
 
 	if _, err := store.fileService.Stat(mapFilePath); err != nil {
@@ -241,7 +290,46 @@ func (store *RealSourceMapStore) getSourceMapFromFileSystem(sourceURL string, re
 	return content, sourceURL, err
 }
 
-func (store *RealSourceMapStore) getSourceMapContent(sourceURL string, release string) (content []byte, sourceMapURL string, err error) {
+func (store *RealSourceMapStore) getSourceMapFromURL(sourceURL string, release string, urlconf *sourcemapURLLocation) (content []byte, sourceMapURL string, err error) {
+	if len(sourceURL) == 0 || !strings.HasPrefix(sourceURL, urlconf.MinifiedPathPrefix) || strings.HasSuffix(sourceURL, "/") {
+		return nil, "", nil
+	}
+
+	var rootURL bytes.Buffer
+
+	err = urlconf.urlTemplate.Execute(&rootURL, struct{ Release string }{Release: url.PathEscape(cleanFilePathPart(release))})
+	if err != nil {
+		return nil, "", err
+	}
+
+	pathParts := sourcePathParts(sourceURL, urlconf.MinifiedPathPrefix)
+	for i, part := range pathParts {
+		pathParts[i] = url.PathEscape(part)
+	}
+	mapURL := strings.TrimSuffix(rootURL.String(), "/") + "/" + strings.Join(pathParts, "/") + ".map"
+
+	level.Debug(store.l).Log("msg", "attempting to download sourcemap from remote location", "url", sourceURL, "sourcemap_url", mapURL)
+	content, err = store.downloadFileContents(urlconf.httpClient, mapURL)
+	if errors.Is(err, errFileNotFound) {
+		level.Debug(store.l).Log("msg", "sourcemap not found in remote location", "url", sourceURL, "sourcemap_url", mapURL)
+		return nil, "", nil
+	}
+	if err != nil {
+		level.Debug(store.l).Log("msg", "failed to download sourcemap from remote location", "url", sourceURL, "sourcemap_url", mapURL, "err", err)
+		return nil, "", err
+	}
+	return content, sourceURL, nil
+}
+
+func (store *RealSourceMapStore) getSourceMapContent(sourceURL string, app string, release string) (content []byte, sourceMapURL string, err error) {
+	//attempt to find in uploaded sourcemaps
+	if store.config.Upload != nil {
+		content, sourceMapURL, err = store.getUploadedSourceMap(sourceURL, app, release)
+		if content != nil || err != nil {
+			return content, sourceMapURL, err
+		}
+	}
+
 	//attempt to find in fs
 	for _, fileconf := range store.fileLocations {
 		content, sourceMapURL, err = store.getSourceMapFromFileSystem(sourceURL, release, fileconf)
@@ -250,6 +338,14 @@ func (store *RealSourceMapStore) getSourceMapContent(sourceURL string, release s
 		}
 	}
 
+	//attempt to find in remote locations
+	for _, urlconf := range store.urlLocations {
+		content, sourceMapURL, err = store.getSourceMapFromURL(sourceURL, release, urlconf)
+		if content != nil || err != nil {
+			return content, sourceMapURL, err
+		}
+	}
+
 	//attempt to download
 	if strings.HasPrefix(sourceURL, "http") && urlMatchesOrigins(sourceURL, store.config.DownloadFromOrigins) {
 		return store.downloadSourceMapContent(sourceURL)
@@ -258,16 +354,16 @@ func (store *RealSourceMapStore) getSourceMapContent(sourceURL string, release s
 }
 
 // GetSourceMap returns sourcemap for a given source url
-func (store *RealSourceMapStore) GetSourceMap(sourceURL string, release string) (*SourceMap, error) {
+func (store *RealSourceMapStore) GetSourceMap(sourceURL string, app string, release string) (*SourceMap, error) {
 	store.Lock()
 	defer store.Unlock()
 
-	cacheKey := fmt.Sprintf("%s__%s", sourceURL, release)
+	cacheKey := sourceMapCacheKey{sourceURL: sourceURL, app: app, release: release}
 
 	if smap, ok := store.cache[cacheKey]; ok {
 		return smap, nil
 	}
-	content, sourceMapURL, err := store.getSourceMapContent(sourceURL, release)
+	content, sourceMapURL, err := store.getSourceMapContent(sourceURL, app, release)
 	if err != nil || content == nil {
 		store.cache[cacheKey] = nil
 		return nil, err
@@ -291,8 +387,8 @@ func (store *RealSourceMapStore) GetSourceMap(sourceURL string, release string)
 }
 
 // ResolveSourceLocation resolves minified source location to original source location
-func ResolveSourceLocation(store SourceMapStore, frame *Frame, release string) (*Frame, error) {
-	smap, err := store.GetSourceMap(frame.Filename, release)
+func ResolveSourceLocation(store SourceMapStore, frame *Frame, app string, release string) (*Frame, error) {
+	smap, err := store.GetSourceMap(frame.Filename, app, release)
 	if err != nil {
 		return nil, err
 	}
@@ -318,14 +414,14 @@ func ResolveSourceLocation(store SourceMapStore, frame *Frame, release string) (
 }
 
 // TransformException will attempt to resolved all monified source locations in the stacktrace with original source locations
-func TransformException(store SourceMapStore, log log.Logger, ex *Exception, release string) *Exception {
+func TransformException(store SourceMapStore, log log.Logger, ex *Exception, app string, release string) *Exception {
 	if ex.Stacktrace == nil {
 		return ex
 	}
 	frames := []Frame{}
 
 	for _, frame := range ex.Stacktrace.Frames {
-		mappedFrame, err := ResolveSourceLocation(store, &frame, release)
+		mappedFrame, err := ResolveSourceLocation(store, &frame, app, release)
 		if err != nil {
 			level.Error(log).Log("msg", "Error resolving stack trace frame source location", "err", err)
 			frames = append(frames, frame)
@@ -344,6 +440,18 @@ func TransformException(store SourceMapStore, log log.Logger, ex *Exception, rel
 	}
 }
 
+// sourcePathParts returns the path segments of sourceURL after prefix,
+// omitting query parameters and relative segments
+func sourcePathParts(sourceURL string, prefix string) []string {
+	var parts []string
+	for _, part := range strings.Split(strings.TrimPrefix(strings.Split(sourceURL, "?")[0], prefix), "/") {
+		if len(part) > 0 && part != "." && part != ".." {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
 func cleanFilePathPart(x string) string {
 	return strings.TrimLeft(strings.ReplaceAll(strings.ReplaceAll(x, "\\", ""), "/", ""), ".")
 }
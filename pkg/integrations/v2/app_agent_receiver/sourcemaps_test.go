@@ -3,16 +3,19 @@ package app_agent_receiver
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"io"
 	"io/fs"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"path/filepath"
 	"testing"
 
 	"github.com/go-kit/log"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
 )
 
 type mockHTTPClient struct {
@@ -106,7 +109,7 @@ func Test_RealSourceMapStore_DownloadSuccess(t *testing.T) {
 
 	exception := mockException()
 
-	transformed := TransformException(sourceMapStore, logger, exception, "123")
+	transformed := TransformException(sourceMapStore, logger, exception, "testapp", "123")
 
 	require.Equal(t, []string{"http://localhost:1234/foo.js", "http://localhost:1234/foo.js.map"}, httpClient.requests)
 
@@ -158,7 +161,7 @@ func Test_RealSourceMapStore_DownloadError(t *testing.T) {
 
 	exception := mockException()
 
-	transformed := TransformException(sourceMapStore, logger, exception, "123")
+	transformed := TransformException(sourceMapStore, logger, exception, "testapp", "123")
 
 	require.Equal(t, []string{"http://localhost:1234/foo.js"}, httpClient.requests)
 	require.Equal(t, exception, transformed)
@@ -203,7 +206,7 @@ func Test_RealSourceMapStore_DownloadHTTPOriginFiltering(t *testing.T) {
 		},
 	}
 
-	transformed := TransformException(sourceMapStore, logger, exception, "123")
+	transformed := TransformException(sourceMapStore, logger, exception, "testapp", "123")
 
 	require.Equal(t, []string{"http://bar.com/foo.js", "http://bar.com/foo.js.map"}, httpClient.requests)
 
@@ -288,7 +291,7 @@ func Test_RealSourceMapStore_ReadFromFileSystem(t *testing.T) {
 		},
 	}
 
-	transformed := TransformException(sourceMapStore, logger, exception, "123")
+	transformed := TransformException(sourceMapStore, logger, exception, "testapp", "123")
 
 	require.Equal(t, []string{
 		filepath.FromSlash("/var/build/latest/foo.js.map"),
@@ -387,7 +390,7 @@ func Test_RealSourceMapStore_ReadFromFileSystemAndDownload(t *testing.T) {
 		},
 	}
 
-	transformed := TransformException(sourceMapStore, logger, exception, "123")
+	transformed := TransformException(sourceMapStore, logger, exception, "testapp", "123")
 
 	require.Equal(t, []string{filepath.FromSlash("/var/build/latest/foo.js.map")}, fileService.stats)
 	require.Equal(t, []string{filepath.FromSlash("/var/build/latest/foo.js.map")}, fileService.reads)
@@ -445,7 +448,7 @@ func Test_RealSourceMapStore_FilepathSanitized(t *testing.T) {
 		},
 	}
 
-	transformed := TransformException(sourceMapStore, logger, exception, "123")
+	transformed := TransformException(sourceMapStore, logger, exception, "testapp", "123")
 
 	require.Equal(t, []string{
 		filepath.FromSlash("/var/build/latest/etc/passwd.map"),
@@ -485,7 +488,7 @@ func Test_RealSourceMapStore_FilepathQueryParamsOmitted(t *testing.T) {
 		},
 	}
 
-	transformed := TransformException(sourceMapStore, logger, exception, "123")
+	transformed := TransformException(sourceMapStore, logger, exception, "testapp", "123")
 
 	require.Equal(t, []string{
 		filepath.FromSlash("/var/build/latest/static/foo.js.map"),
@@ -494,3 +497,114 @@ func Test_RealSourceMapStore_FilepathQueryParamsOmitted(t *testing.T) {
 
 	require.Equal(t, *exception, *transformed)
 }
+
+func Test_RealSourceMapStore_DownloadFromRemoteLocation(t *testing.T) {
+	mapFile := loadTestData(t, "foo.js.map")
+
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/artifacts/123/static/foo.js.map" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(mapFile)
+	}))
+	defer srv.Close()
+
+	var loc SourceMapURLLocation
+	require.NoError(t, yaml.Unmarshal([]byte(fmt.Sprintf(`
+url: %s/artifacts/{{ .Release }}/static
+minified_path_prefix: http://foo.com/
+authorization:
+  credentials: secret`, srv.URL)), &loc))
+
+	conf := SourceMapConfig{
+		Remote:          []SourceMapURLLocation{loc},
+		MaxDownloadSize: DefaultMaxSourceMapSize,
+	}
+
+	logger := log.NewNopLogger()
+	sourceMapStore := NewSourceMapStore(logger, conf, prometheus.NewRegistry(), &mockHTTPClient{}, &mockFileService{})
+
+	exception := &Exception{
+		Stacktrace: &Stacktrace{
+			Frames: []Frame{
+				{
+					Colno:    6,
+					Filename: "http://foo.com/foo.js?v=2",
+					Function: "eval",
+					Lineno:   5,
+				},
+				{
+					Colno:    6,
+					Filename: "http://foo.com/bar.js",
+					Function: "eval",
+					Lineno:   5,
+				},
+				{
+					Colno:    5,
+					Filename: "http://bar.com/foo.js",
+					Function: "callUndefined",
+					Lineno:   6,
+				},
+			},
+		},
+	}
+
+	transformed := TransformException(sourceMapStore, logger, exception, "testapp", "123")
+
+	// bar.js isn't found and bar.com doesn't match the location, so only
+	// foo.js is resolved.
+	require.Equal(t, []string{
+		"/artifacts/123/static/foo.js.map",
+		"/artifacts/123/static/bar.js.map",
+	}, requests)
+
+	expected := &Exception{
+		Stacktrace: &Stacktrace{
+			Frames: []Frame{
+				{
+					Colno:    37,
+					Filename: "/__parcel_source_root/demo/src/actions.ts",
+					Function: "?",
+					Lineno:   6,
+				},
+				exception.Stacktrace.Frames[1],
+				exception.Stacktrace.Frames[2],
+			},
+		},
+	}
+	require.Equal(t, *expected, *transformed)
+
+	// Sourcemaps are cached.
+	TransformException(sourceMapStore, logger, exception, "testapp", "123")
+	require.Len(t, requests, 2)
+}
+
+func Test_RealSourceMapStore_MaxDownloadSize(t *testing.T) {
+	conf := SourceMapConfig{
+		Download:            true,
+		DownloadFromOrigins: []string{"*"},
+		MaxDownloadSize:     10,
+	}
+
+	httpClient := &mockHTTPClient{
+		responses: []struct {
+			*http.Response
+			error
+		}{
+			{newResponseFromTestData(t, "foo.js"), nil},
+		},
+	}
+
+	logger := log.NewNopLogger()
+	sourceMapStore := NewSourceMapStore(logger, conf, prometheus.NewRegistry(), httpClient, &mockFileService{})
+
+	_, err := sourceMapStore.GetSourceMap("http://localhost:1234/foo.js", "testapp", "123")
+	require.EqualError(t, err, "file exceeds maximum download size of 10 bytes")
+}
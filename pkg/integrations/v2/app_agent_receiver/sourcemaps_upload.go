@@ -0,0 +1,152 @@
+package app_agent_receiver
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-kit/log/level"
+	"github.com/go-sourcemap/sourcemap"
+	"github.com/gorilla/mux"
+)
+
+// uploadedSourceMapPath returns the file an uploaded sourcemap of the
+// minified file at urlPath is stored in. ok is false if app, release or
+// urlPath can't be used as part of a file path
+func (store *RealSourceMapStore) uploadedSourceMapPath(app string, release string, urlPath string) (path string, ok bool) {
+	app, release = cleanFilePathPart(app), cleanFilePathPart(release)
+	parts := sourcePathParts(urlPath, "")
+	if app == "" || release == "" || len(parts) == 0 {
+		return "", false
+	}
+	pathParts := append([]string{store.config.Upload.Path, app, release}, parts...)
+	return filepath.Join(pathParts...) + ".map", true
+}
+
+// getUploadedSourceMap looks up the uploaded sourcemap of sourceURL by the
+// path of the URL
+func (store *RealSourceMapStore) getUploadedSourceMap(sourceURL string, app string, release string) (content []byte, sourceMapURL string, err error) {
+	u, err := url.Parse(sourceURL)
+	if err != nil || strings.HasSuffix(u.Path, "/") {
+		return nil, "", nil
+	}
+	mapFilePath, ok := store.uploadedSourceMapPath(app, release, u.Path)
+	if !ok {
+		return nil, "", nil
+	}
+
+	if _, err := store.fileService.Stat(mapFilePath); err != nil {
+		level.Debug(store.l).Log("msg", "uploaded sourcemap not found", "url", sourceURL, "file_path", mapFilePath)
+		return nil, "", nil
+	}
+
+	content, err = store.fileService.ReadFile(mapFilePath)
+	if err != nil {
+		store.metrics.fileReads.WithLabelValues(getOrigin(sourceURL), "error").Inc()
+	} else {
+		store.metrics.fileReads.WithLabelValues(getOrigin(sourceURL), "ok").Inc()
+	}
+	return content, sourceURL, err
+}
+
+// invalidate removes the cached sourcemaps of a release, including the
+// sourcemaps which were not found
+func (store *RealSourceMapStore) invalidate(app string, release string) {
+	store.Lock()
+	defer store.Unlock()
+
+	for key := range store.cache {
+		if key.app == app && key.release == release {
+			delete(store.cache, key)
+		}
+	}
+}
+
+// UploadHandler returns the handler of the sourcemap upload API, or nil if
+// uploads are not enabled. The handler expects the app, release and path of
+// the minified file as the app, release and path route variables.
+//
+// Sourcemaps are stored as files, replacing the previously uploaded
+// sourcemap of the same minified file.
+func (store *RealSourceMapStore) UploadHandler() http.Handler {
+	conf := store.config.Upload
+	if conf == nil {
+		return nil
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get(apiKeyHeader)), []byte(conf.APIKey)) == 0 {
+			http.Error(w, "api key not provided or incorrect", http.StatusUnauthorized)
+			return
+		}
+
+		vars := mux.Vars(r)
+		app, release := vars["app"], vars["release"]
+		mapFilePath, ok := store.uploadedSourceMapPath(app, release, vars["path"])
+		if !ok {
+			http.Error(w, "invalid app, release or path", http.StatusBadRequest)
+			return
+		}
+
+		var body io.Reader = r.Body
+		if conf.MaxSize > 0 {
+			if r.ContentLength > conf.MaxSize {
+				http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+				return
+			}
+			body = io.LimitReader(r.Body, conf.MaxSize+1)
+		}
+		content, err := io.ReadAll(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if conf.MaxSize > 0 && int64(len(content)) > conf.MaxSize {
+			http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		if _, err := sourcemap.Parse(mapFilePath, content); err != nil {
+			http.Error(w, fmt.Sprintf("invalid sourcemap: %s", err), http.StatusBadRequest)
+			return
+		}
+
+		if err := writeFileAtomic(mapFilePath, content); err != nil {
+			level.Error(store.l).Log("msg", "failed to store uploaded sourcemap", "file_path", mapFilePath, "err", err)
+			http.Error(w, "failed to store sourcemap", http.StatusInternalServerError)
+			return
+		}
+		store.invalidate(app, release)
+
+		level.Info(store.l).Log("msg", "stored uploaded sourcemap", "app", app, "release", release, "file_path", mapFilePath)
+		w.WriteHeader(http.StatusCreated)
+	})
+}
+
+// writeFileAtomic replaces the file at path with content, creating its
+// directory if needed
+func writeFileAtomic(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0770); err != nil {
+		return err
+	}
+	// Concurrent uploads of the same file each write their own temporary file
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,88 @@
+package app_agent_receiver
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func newUploadTestStore(t *testing.T, maxSize int64) (*RealSourceMapStore, http.Handler) {
+	t.Helper()
+
+	conf := SourceMapConfig{
+		Upload: &SourceMapUploadConfig{
+			Path:    t.TempDir(),
+			APIKey:  "upload-key",
+			MaxSize: maxSize,
+		},
+	}
+	store := NewSourceMapStore(log.NewNopLogger(), conf, prometheus.NewRegistry(), &mockHTTPClient{}, nil)
+
+	r := mux.NewRouter()
+	r.Handle("/sourcemaps/{app}/{release}/{path:.+}", store.UploadHandler()).Methods("PUT")
+	return store, r
+}
+
+func upload(handler http.Handler, path string, apiKey string, body []byte) int {
+	req := httptest.NewRequest("PUT", path, bytes.NewReader(body))
+	req.Header.Set(apiKeyHeader, apiKey)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr.Result().StatusCode
+}
+
+func Test_RealSourceMapStore_Upload(t *testing.T) {
+	store, handler := newUploadTestStore(t, DefaultMaxSourceMapSize)
+	mapFile := loadTestData(t, "foo.js.map")
+
+	// Not found before the upload.
+	smap, err := store.GetSourceMap("http://localhost:1234/static/foo.js", "testapp", "123")
+	require.NoError(t, err)
+	require.Nil(t, smap)
+
+	require.Equal(t, http.StatusCreated, upload(handler, "/sourcemaps/testapp/123/static/foo.js", "upload-key", mapFile))
+	require.FileExists(t, filepath.Join(store.config.Upload.Path, "testapp", "123", "static", "foo.js.map"))
+
+	// The upload invalidates the cached lookup.
+	smap, err = store.GetSourceMap("http://localhost:1234/static/foo.js?v=1", "testapp", "123")
+	require.NoError(t, err)
+	require.NotNil(t, smap)
+
+	// Sourcemaps are keyed by app and release.
+	smap, err = store.GetSourceMap("http://localhost:1234/static/foo.js", "otherapp", "123")
+	require.NoError(t, err)
+	require.Nil(t, smap)
+	smap, err = store.GetSourceMap("http://localhost:1234/static/foo.js", "testapp", "124")
+	require.NoError(t, err)
+	require.Nil(t, smap)
+}
+
+func Test_RealSourceMapStore_UploadRejected(t *testing.T) {
+	_, handler := newUploadTestStore(t, 1000)
+	mapFile := loadTestData(t, "foo.js.map")
+
+	tt := []struct {
+		name   string
+		path   string
+		apiKey string
+		body   []byte
+		expect int
+	}{
+		{name: "wrong api key", path: "/sourcemaps/testapp/123/foo.js", apiKey: "wrong", body: mapFile, expect: http.StatusUnauthorized},
+		{name: "invalid sourcemap", path: "/sourcemaps/testapp/123/foo.js", apiKey: "upload-key", body: []byte("{"), expect: http.StatusBadRequest},
+		{name: "too large", path: "/sourcemaps/testapp/123/foo.js", apiKey: "upload-key", body: bytes.Repeat([]byte(" "), 1001), expect: http.StatusRequestEntityTooLarge},
+		{name: "invalid release", path: "/sourcemaps/testapp/.../foo.js", apiKey: "upload-key", body: mapFile, expect: http.StatusBadRequest},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expect, upload(handler, tc.path, tc.apiKey, tc.body))
+		})
+	}
+}
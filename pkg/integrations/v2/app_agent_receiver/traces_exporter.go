@@ -2,20 +2,41 @@ package app_agent_receiver
 
 import (
 	"context"
+	"strings"
+	"sync"
+	"time"
 
 	"go.opentelemetry.io/collector/consumer"
+	otelpdata "go.opentelemetry.io/collector/model/pdata"
 )
 
-type tracesConsumerGetter func() (consumer.Traces, error)
+// tracesConsumerGetter returns the consumer of the traces of payload, which
+// was received with ctx. A nil consumer drops the traces
+type tracesConsumerGetter func(ctx context.Context, payload Payload) (consumer.Traces, error)
+
+// exceptionEventName is the name of span events which record exceptions, as
+// defined by the OpenTelemetry semantic conventions.
+const exceptionEventName = "exception"
 
 // TracesExporter will send traces to a traces instance
 type TracesExporter struct {
 	getTracesConsumer tracesConsumerGetter
+	errorSampling     *ErrorSamplingConfig
+	errorTraces       *errorTraceSet
 }
 
-// NewTracesExporter creates a trace exporter for the app agent receiver.
-func NewTracesExporter(getTracesConsumer tracesConsumerGetter) appAgentReceiverExporter {
-	return &TracesExporter{getTracesConsumer}
+// NewTracesExporter creates a trace exporter for the app agent receiver. If
+// errorSampling is not nil, spans of traces with exceptions are marked with
+// the configured attribute before they are exported.
+func NewTracesExporter(getTracesConsumer tracesConsumerGetter, errorSampling *ErrorSamplingConfig) AppAgentReceiverExporter {
+	te := &TracesExporter{
+		getTracesConsumer: getTracesConsumer,
+		errorSampling:     errorSampling,
+	}
+	if errorSampling != nil {
+		te.errorTraces = newErrorTraceSet(errorSampling.Window)
+	}
+	return te
 }
 
 // Name of the exporter, for logging purposes
@@ -25,12 +46,120 @@ func (te *TracesExporter) Name() string {
 
 // Export implements the AppDataExporter interface
 func (te *TracesExporter) Export(ctx context.Context, payload Payload) error {
+	if te.errorSampling != nil {
+		te.trackErrorTraces(payload)
+	}
 	if payload.Traces == nil {
 		return nil
 	}
-	consumer, err := te.getTracesConsumer()
-	if err != nil {
+	consumer, err := te.getTracesConsumer(ctx, payload)
+	if err != nil || consumer == nil {
 		return err
 	}
-	return consumer.ConsumeTraces(ctx, payload.Traces.Traces)
+
+	traces := payload.Traces.Traces
+	if te.errorSampling != nil {
+		traces = te.markErrorTraces(traces)
+	}
+	return consumer.ConsumeTraces(ctx, traces)
+}
+
+// trackErrorTraces remembers the IDs of traces which have an exception in
+// payload, either reported as an exception or recorded on a span.
+func (te *TracesExporter) trackErrorTraces(payload Payload) {
+	var traceIDs []string
+	for _, e := range payload.Exceptions {
+		if e.Trace.TraceID != "" {
+			// Span trace IDs are compared as lowercase hex strings.
+			traceIDs = append(traceIDs, strings.ToLower(e.Trace.TraceID))
+		}
+	}
+	if payload.Traces != nil {
+		for _, s := range payload.Traces.SpanSlice() {
+			if !s.TraceID().IsEmpty() && spanHasError(s) {
+				traceIDs = append(traceIDs, s.TraceID().HexString())
+			}
+		}
+	}
+	te.errorTraces.Add(traceIDs, time.Now())
+}
+
+// markErrorTraces returns traces with the error sampling attribute set on all
+// spans of traces with exceptions. traces is shared with other exporters, so
+// spans are only modified on a copy.
+func (te *TracesExporter) markErrorTraces(traces otelpdata.Traces) otelpdata.Traces {
+	now := time.Now()
+
+	var marked bool
+	for _, s := range (Traces{traces}).SpanSlice() {
+		if te.errorTraces.Has(s.TraceID().HexString(), now) {
+			marked = true
+			break
+		}
+	}
+	if !marked {
+		return traces
+	}
+
+	res := traces.Clone()
+	for _, s := range (Traces{res}).SpanSlice() {
+		if te.errorTraces.Has(s.TraceID().HexString(), now) {
+			s.Attributes().UpsertString(te.errorSampling.Attribute, "true")
+		}
+	}
+	return res
+}
+
+func spanHasError(s otelpdata.Span) bool {
+	if s.Status().Code() == otelpdata.StatusCodeError {
+		return true
+	}
+	events := s.Events()
+	for i := 0; i < events.Len(); i++ {
+		if events.At(i).Name() == exceptionEventName {
+			return true
+		}
+	}
+	return false
+}
+
+// errorTraceSet holds the IDs of traces with exceptions which were seen within
+// a time window.
+type errorTraceSet struct {
+	window time.Duration
+
+	mut      sync.Mutex
+	traceIDs map[string]time.Time // Trace ID -> time it was last seen
+}
+
+func newErrorTraceSet(window time.Duration) *errorTraceSet {
+	return &errorTraceSet{
+		window:   window,
+		traceIDs: make(map[string]time.Time),
+	}
+}
+
+// Add adds traceIDs to the set, and removes trace IDs which weren't seen
+// within the window.
+func (s *errorTraceSet) Add(traceIDs []string, now time.Time) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	for id, seen := range s.traceIDs {
+		if now.Sub(seen) > s.window {
+			delete(s.traceIDs, id)
+		}
+	}
+	for _, id := range traceIDs {
+		s.traceIDs[id] = now
+	}
+}
+
+// Has returns true if traceID was added within the window.
+func (s *errorTraceSet) Has(traceID string, now time.Time) bool {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	seen, ok := s.traceIDs[traceID]
+	return ok && now.Sub(seen) <= s.window
 }
@@ -0,0 +1,87 @@
+package app_agent_receiver
+
+import (
+	"context"
+	"crypto/subtle"
+
+	"go.opentelemetry.io/collector/consumer"
+)
+
+// apiKeyContextKey is the context key of the API key a payload was sent with
+type apiKeyContextKey struct{}
+
+// contextWithAPIKey returns a copy of ctx holding key
+func contextWithAPIKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, apiKeyContextKey{}, key)
+}
+
+// apiKeyFromContext returns the API key held by ctx, if any
+func apiKeyFromContext(ctx context.Context) string {
+	key, _ := ctx.Value(apiKeyContextKey{}).(string)
+	return key
+}
+
+// tracesRouter picks the traces instance which receives the traces of a
+// payload
+type tracesRouter struct {
+	routes          []TracesRouteConfig
+	apps            []AppConfig // Apps with their own traces instance take precedence over routes
+	defaultInstance string      // May be empty to drop traces matching no route
+	getConsumer     func(instance string) (consumer.Traces, error)
+}
+
+// Consumer returns the consumer of the traces instance for payload, or nil
+// if its traces match no route and there is no default instance
+func (tr *tracesRouter) Consumer(ctx context.Context, payload Payload) (consumer.Traces, error) {
+	if app := appFromContext(ctx); app != nil && app.TracesInstance != "" {
+		return tr.getConsumer(app.TracesInstance)
+	}
+	instance := tr.instance(payload.Meta.App.Name, apiKeyFromContext(ctx))
+	if instance == "" {
+		return nil, nil
+	}
+	return tr.getConsumer(instance)
+}
+
+// instance returns the traces instance of the first route matching app or
+// apiKey
+func (tr *tracesRouter) instance(app string, apiKey string) string {
+	for _, route := range tr.routes {
+		for _, routeApp := range route.Apps {
+			if app != "" && app == routeApp {
+				return route.TracesInstance
+			}
+		}
+		if apiKey != "" && matchesAPIKey(apiKey, route.APIKeys) {
+			return route.TracesInstance
+		}
+	}
+	return tr.defaultInstance
+}
+
+// Instances returns the names of all traces instances traces may be sent to
+func (tr *tracesRouter) Instances() []string {
+	var instances []string
+	if tr.defaultInstance != "" {
+		instances = append(instances, tr.defaultInstance)
+	}
+	for _, route := range tr.routes {
+		instances = append(instances, route.TracesInstance)
+	}
+	for _, app := range tr.apps {
+		if app.TracesInstance != "" {
+			instances = append(instances, app.TracesInstance)
+		}
+	}
+	return instances
+}
+
+// matchesAPIKey returns true if key is one of keys, comparing keys in
+// constant time
+func matchesAPIKey(key string, keys []string) bool {
+	var match int
+	for _, k := range keys {
+		match |= subtle.ConstantTimeCompare([]byte(key), []byte(k))
+	}
+	return match == 1
+}
@@ -0,0 +1,100 @@
+package app_agent_receiver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer"
+	"gopkg.in/yaml.v2"
+)
+
+func newTestTracesRouter(defaultInstance string) (*tracesRouter, map[string]*mockTracesConsumer) {
+	consumers := map[string]*mockTracesConsumer{
+		"default":  {},
+		"checkout": {},
+		"partner":  {},
+	}
+	return &tracesRouter{
+		routes: []TracesRouteConfig{
+			{Apps: []string{"cart", "checkout"}, TracesInstance: "checkout"},
+			{APIKeys: []string{"partner-key"}, TracesInstance: "partner"},
+		},
+		defaultInstance: defaultInstance,
+		getConsumer: func(instance string) (consumer.Traces, error) {
+			c, ok := consumers[instance]
+			if !ok {
+				return nil, fmt.Errorf("traces instance %q not found", instance)
+			}
+			return c, nil
+		},
+	}, consumers
+}
+
+func TestTracesRouter(t *testing.T) {
+	router, _ := newTestTracesRouter("default")
+
+	require.Equal(t, "checkout", router.instance("cart", ""))
+	require.Equal(t, "checkout", router.instance("checkout", "partner-key"))
+	require.Equal(t, "partner", router.instance("shop", "partner-key"))
+	require.Equal(t, "default", router.instance("shop", "other-key"))
+	require.Equal(t, "default", router.instance("", ""))
+	require.Equal(t, []string{"default", "checkout", "partner"}, router.Instances())
+
+	// Without a default instance, traces matching no route are dropped.
+	router, _ = newTestTracesRouter("")
+	c, err := router.Consumer(context.Background(), Payload{Meta: Meta{App: App{Name: "shop"}}})
+	require.NoError(t, err)
+	require.Nil(t, c)
+}
+
+func TestHandler_TracesRoutes(t *testing.T) {
+	router, consumers := newTestTracesRouter("default")
+
+	conf := &Config{
+		Server: ServerConfig{APIKey: "main-key"},
+		TracesRoutes: []TracesRouteConfig{
+			{APIKeys: []string{"partner-key"}, TracesInstance: "partner"},
+		},
+	}
+	exporter := NewTracesExporter(router.Consumer, nil)
+	fr := NewAppAgentReceiverHandler(conf, []AppAgentReceiverExporter{exporter}, prometheus.NewRegistry())
+	handler := fr.HTTPHandler(nil)
+
+	send := func(apiKey string) int {
+		req := httptest.NewRequest("POST", "/collect", bytes.NewBuffer(loadTestData(t, "payload.json")))
+		req.Header.Set(apiKeyHeader, apiKey)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Result().StatusCode
+	}
+
+	// Keys of traces routes are accepted besides the configured key.
+	require.Equal(t, http.StatusAccepted, send("main-key"))
+	require.Equal(t, http.StatusAccepted, send("partner-key"))
+	require.Equal(t, http.StatusUnauthorized, send("other-key"))
+
+	require.Len(t, consumers["default"].consumed, 1)
+	require.Len(t, consumers["partner"].consumed, 1)
+}
+
+func TestConfig_TracesRoutes(t *testing.T) {
+	var cfg Config
+	cb := `
+traces_instance: default
+traces_routes:
+  - apps: [checkout]
+    traces_instance: checkout`
+	require.NoError(t, yaml.Unmarshal([]byte(cb), &cfg))
+	require.Equal(t, []TracesRouteConfig{{Apps: []string{"checkout"}, TracesInstance: "checkout"}}, cfg.TracesRoutes)
+
+	cb = `
+traces_routes:
+  - traces_instance: checkout`
+	require.EqualError(t, yaml.Unmarshal([]byte(cb), &cfg), "traces_routes[0]: at least one of apps or api_keys must be set")
+}
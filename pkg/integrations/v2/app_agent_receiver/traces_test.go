@@ -26,7 +26,7 @@ func (c *mockTracesConsumer) ConsumeTraces(ctx context.Context, td pdata.Traces)
 func Test_exportTraces_success(t *testing.T) {
 	ctx := context.Background()
 	tracesConsumer := &mockTracesConsumer{}
-	exporter := NewTracesExporter(func() (consumer.Traces, error) { return tracesConsumer, nil })
+	exporter := NewTracesExporter(func(context.Context, Payload) (consumer.Traces, error) { return tracesConsumer, nil }, nil)
 	payload := loadTestPayload(t)
 	err := exporter.Export(ctx, payload)
 	require.NoError(t, err)
@@ -36,7 +36,7 @@ func Test_exportTraces_success(t *testing.T) {
 func Test_exportTraces_noTracesInpayload(t *testing.T) {
 	ctx := context.Background()
 	tracesConsumer := &mockTracesConsumer{consumed: nil}
-	exporter := NewTracesExporter(func() (consumer.Traces, error) { return tracesConsumer, nil })
+	exporter := NewTracesExporter(func(context.Context, Payload) (consumer.Traces, error) { return tracesConsumer, nil }, nil)
 	payload := loadTestPayload(t)
 	payload.Traces = nil
 	err := exporter.Export(ctx, payload)
@@ -46,8 +46,69 @@ func Test_exportTraces_noTracesInpayload(t *testing.T) {
 
 func Test_exportTraces_noConsumer(t *testing.T) {
 	ctx := context.Background()
-	exporter := NewTracesExporter(func() (consumer.Traces, error) { return nil, errors.New("it dont work") })
+	exporter := NewTracesExporter(func(context.Context, Payload) (consumer.Traces, error) { return nil, errors.New("it dont work") }, nil)
 	payload := loadTestPayload(t)
 	err := exporter.Export(ctx, payload)
 	require.Error(t, err, "it don't work")
 }
+
+func newTestSpan(traces pdata.Traces, traceID [16]byte, name string) pdata.Span {
+	span := traces.ResourceSpans().AppendEmpty().InstrumentationLibrarySpans().AppendEmpty().Spans().AppendEmpty()
+	span.SetTraceID(pdata.NewTraceID(traceID))
+	span.SetName(name)
+	return span
+}
+
+func Test_exportTraces_errorSampling(t *testing.T) {
+	var (
+		ctx            = context.Background()
+		tracesConsumer = &mockTracesConsumer{}
+		exporter       = NewTracesExporter(
+			func(context.Context, Payload) (consumer.Traces, error) { return tracesConsumer, nil },
+			&DefaultErrorSamplingConfig,
+		)
+
+		exceptionTrace = [16]byte{1}
+		eventTrace     = [16]byte{2}
+		okTrace        = [16]byte{3}
+	)
+
+	// The first payload only reports an exception. Spans of its trace received
+	// later must still be marked.
+	err := exporter.Export(ctx, Payload{
+		Exceptions: []Exception{{
+			Type:  "Error",
+			Value: "Cannot read property 'foo' of undefined",
+			Trace: TraceContext{TraceID: pdata.NewTraceID(exceptionTrace).HexString()},
+		}},
+	})
+	require.NoError(t, err)
+	require.Len(t, tracesConsumer.consumed, 0)
+
+	traces := pdata.NewTraces()
+	newTestSpan(traces, exceptionTrace, "fetch")
+	newTestSpan(traces, eventTrace, "click").Events().AppendEmpty().SetName("exception")
+	newTestSpan(traces, eventTrace, "render")
+	newTestSpan(traces, okTrace, "load")
+
+	err = exporter.Export(ctx, Payload{Traces: &Traces{traces}})
+	require.NoError(t, err)
+	require.Len(t, tracesConsumer.consumed, 1)
+
+	marked := map[string]bool{}
+	for _, s := range (Traces{tracesConsumer.consumed[0]}).SpanSlice() {
+		_, marked[s.Name()] = s.Attributes().Get(DefaultErrorSamplingConfig.Attribute)
+	}
+	require.Equal(t, map[string]bool{
+		"fetch":  true,
+		"click":  true,
+		"render": true,
+		"load":   false,
+	}, marked)
+
+	// The payload is shared with other exporters and must not be modified.
+	for _, s := range (Traces{traces}).SpanSlice() {
+		_, ok := s.Attributes().Get(DefaultErrorSamplingConfig.Attribute)
+		require.False(t, ok)
+	}
+}
@@ -0,0 +1,138 @@
+package app_agent_receiver
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mssola/user_agent"
+)
+
+// Fields of meta.browser which can be set from the User-Agent header
+const (
+	UserAgentFieldName        = "name"
+	UserAgentFieldVersion     = "version"
+	UserAgentFieldOS          = "os"
+	UserAgentFieldMobile      = "mobile"
+	UserAgentFieldDeviceType  = "device_type"
+	UserAgentFieldDeviceModel = "device_model"
+)
+
+// Device types set from the User-Agent header
+const (
+	DeviceTypeDesktop = "desktop"
+	DeviceTypeMobile  = "mobile"
+	DeviceTypeTablet  = "tablet"
+	DeviceTypeBot     = "bot"
+)
+
+var userAgentFields = []string{
+	UserAgentFieldName,
+	UserAgentFieldVersion,
+	UserAgentFieldOS,
+	UserAgentFieldMobile,
+	UserAgentFieldDeviceType,
+	UserAgentFieldDeviceModel,
+}
+
+// DefaultUserAgentConfig holds the default values of a UserAgentConfig
+var DefaultUserAgentConfig = UserAgentConfig{
+	Fields: userAgentFields,
+}
+
+// UserAgentConfig configures setting the browser metadata of payloads from
+// the User-Agent header of requests
+type UserAgentConfig struct {
+	// Fields are the fields of meta.browser which are set
+	Fields []string `yaml:"fields"`
+	// Override replaces the values sent by the SDK. Otherwise, only fields
+	// the SDK left empty are set
+	Override bool `yaml:"override,omitempty"`
+}
+
+// UnmarshalYAML implements the Unmarshaler interface
+func (c *UserAgentConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultUserAgentConfig
+	type plain UserAgentConfig
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+	for _, f := range c.Fields {
+		if !isUserAgentField(f) {
+			return fmt.Errorf("unknown user agent field %q, expected one of %s", f, strings.Join(userAgentFields, ", "))
+		}
+	}
+	return nil
+}
+
+func isUserAgentField(field string) bool {
+	for _, f := range userAgentFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// userAgentEnricher sets the browser metadata of payloads from the
+// User-Agent header
+type userAgentEnricher struct {
+	fields   map[string]bool
+	override bool
+}
+
+func newUserAgentEnricher(conf UserAgentConfig) *userAgentEnricher {
+	fields := make(map[string]bool, len(conf.Fields))
+	for _, f := range conf.Fields {
+		fields[f] = true
+	}
+	return &userAgentEnricher{fields: fields, override: conf.Override}
+}
+
+// Enrich sets the configured fields of p.Meta.Browser from the user agent ua
+func (e *userAgentEnricher) Enrich(p *Payload, ua string) {
+	if ua == "" {
+		return
+	}
+	parsed := user_agent.New(ua)
+	b := &p.Meta.Browser
+
+	name, version := parsed.Browser()
+	e.set(UserAgentFieldName, &b.Name, name)
+	e.set(UserAgentFieldVersion, &b.Version, version)
+	e.set(UserAgentFieldOS, &b.OS, strings.TrimSpace(parsed.OSInfo().FullName))
+	e.set(UserAgentFieldDeviceType, &b.DeviceType, deviceType(parsed, ua))
+	e.set(UserAgentFieldDeviceModel, &b.DeviceModel, parsed.Model())
+
+	if e.fields[UserAgentFieldMobile] {
+		if e.override {
+			b.Mobile = parsed.Mobile()
+		} else {
+			b.Mobile = b.Mobile || parsed.Mobile()
+		}
+	}
+}
+
+// set sets *dst to value if field is enabled, and either dst is empty or
+// values are overridden
+func (e *userAgentEnricher) set(field string, dst *string, value string) {
+	if !e.fields[field] || value == "" {
+		return
+	}
+	if *dst == "" || e.override {
+		*dst = value
+	}
+}
+
+func deviceType(parsed *user_agent.UserAgent, ua string) string {
+	switch {
+	case parsed.Bot():
+		return DeviceTypeBot
+	case strings.Contains(ua, "iPad") || strings.Contains(ua, "Tablet") ||
+		(strings.Contains(ua, "Android") && !strings.Contains(ua, "Mobile")):
+		return DeviceTypeTablet
+	case parsed.Mobile():
+		return DeviceTypeMobile
+	default:
+		return DeviceTypeDesktop
+	}
+}
@@ -0,0 +1,89 @@
+package app_agent_receiver
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	testDesktopUA = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/104.0.0.0 Safari/537.36"
+	testMobileUA  = "Mozilla/5.0 (Linux; Android 12; Pixel 6) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/104.0.5112.69 Mobile Safari/537.36"
+	testBotUA     = "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"
+)
+
+func TestUserAgentEnricher(t *testing.T) {
+	e := newUserAgentEnricher(DefaultUserAgentConfig)
+
+	var p Payload
+	e.Enrich(&p, testMobileUA)
+	require.Equal(t, Browser{
+		Name:        "Chrome",
+		Version:     "104.0.5112.69",
+		OS:          "Android 12",
+		Mobile:      true,
+		DeviceType:  DeviceTypeMobile,
+		DeviceModel: "Pixel 6",
+	}, p.Meta.Browser)
+
+	p = Payload{}
+	e.Enrich(&p, testDesktopUA)
+	require.Equal(t, DeviceTypeDesktop, p.Meta.Browser.DeviceType)
+	require.False(t, p.Meta.Browser.Mobile)
+
+	p = Payload{}
+	e.Enrich(&p, testBotUA)
+	require.Equal(t, DeviceTypeBot, p.Meta.Browser.DeviceType)
+
+	p = Payload{}
+	e.Enrich(&p, "")
+	require.Equal(t, Browser{}, p.Meta.Browser)
+}
+
+func TestUserAgentEnricher_Fields(t *testing.T) {
+	sdk := Browser{Name: "chrome", Version: "104", OS: "Android"}
+
+	// Fields sent by the SDK are kept unless they're overridden.
+	e := newUserAgentEnricher(UserAgentConfig{Fields: []string{UserAgentFieldOS, UserAgentFieldDeviceType}})
+	p := Payload{Meta: Meta{Browser: sdk}}
+	e.Enrich(&p, testMobileUA)
+	require.Equal(t, Browser{Name: "chrome", Version: "104", OS: "Android", DeviceType: DeviceTypeMobile}, p.Meta.Browser)
+
+	e = newUserAgentEnricher(UserAgentConfig{Fields: []string{UserAgentFieldOS}, Override: true})
+	p = Payload{Meta: Meta{Browser: sdk}}
+	e.Enrich(&p, testMobileUA)
+	require.Equal(t, Browser{Name: "chrome", Version: "104", OS: "Android 12"}, p.Meta.Browser)
+}
+
+func TestHandler_UserAgent(t *testing.T) {
+	exporter := &TestExporter{name: "exporter"}
+	fr := NewAppAgentReceiverHandler(&Config{}, []AppAgentReceiverExporter{exporter}, prometheus.NewRegistry())
+	fr.userAgent = newUserAgentEnricher(DefaultUserAgentConfig)
+
+	req := httptest.NewRequest("POST", "/collect", bytes.NewBuffer(loadTestData(t, "payload.json")))
+	req.Header.Set("User-Agent", testMobileUA)
+	rr := httptest.NewRecorder()
+	fr.HTTPHandler(nil).ServeHTTP(rr, req)
+
+	require.Equal(t, 202, rr.Result().StatusCode)
+	require.Len(t, exporter.payloads, 1)
+	require.Equal(t, DeviceTypeMobile, exporter.payloads[0].Meta.Browser.DeviceType)
+}
+
+func TestConfig_UserAgent(t *testing.T) {
+	var cfg Config
+	cb := `
+user_agent:
+  override: true`
+	require.NoError(t, yaml.Unmarshal([]byte(cb), &cfg))
+	require.Equal(t, UserAgentConfig{Fields: userAgentFields, Override: true}, *cfg.UserAgent)
+
+	cb = `
+user_agent:
+  fields: [name, engine]`
+	require.EqualError(t, yaml.Unmarshal([]byte(cb), &cfg), `unknown user agent field "engine", expected one of name, version, os, mobile, device_type, device_model`)
+}
@@ -0,0 +1,107 @@
+package integrations
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/agent/pkg/integrations/v2/autodiscovery"
+	"github.com/grafana/agent/pkg/util"
+)
+
+// IntegrationsAutodiscoveryEndpoint is the API endpoint where integrations
+// enabled by autodiscovery are exposed.
+const IntegrationsAutodiscoveryEndpoint = "/agent/api/v1/integrations/autodiscovery"
+
+// discoveredIntegration is an integration enabled by autodiscovery.
+type discoveredIntegration struct {
+	Integration string `json:"integration"`
+	Source      string `json:"source"`
+	// Service is the name of the systemd unit or container of the service.
+	Service string `json:"service,omitempty"`
+	Address string `json:"address"`
+
+	cfg Config
+}
+
+// discoveredIntegrations converts matches into integrations. Matches of
+// integrations which are explicitly configured in explicit are skipped.
+func discoveredIntegrations(matches []autodiscovery.Match, explicit Configs) ([]discoveredIntegration, error) {
+	configured := make(map[string]struct{}, len(explicit))
+	for _, c := range explicit {
+		configured[c.Name()] = struct{}{}
+	}
+
+	var (
+		res      []discoveredIntegration
+		firstErr error
+	)
+	for _, m := range matches {
+		if _, ok := configured[m.Integration]; ok {
+			continue
+		}
+		ref, ok := integrationByName[m.Integration]
+		if !ok {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("integration %q not registered", m.Integration)
+			}
+			continue
+		}
+		cfg, err := deferredConfigUnmarshal(util.RawYAML(m.Config), ref)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("invalid config for discovered integration %q: %w", m.Integration, err)
+			}
+			continue
+		}
+
+		res = append(res, discoveredIntegration{
+			Integration: m.Integration,
+			Source:      m.Service.Source,
+			Service:     m.Service.Name,
+			Address:     m.Service.Address(),
+			cfg:         cfg,
+		})
+	}
+	return res, firstErr
+}
+
+// sameMatches returns true if a and b match the same services with the same
+// integrations.
+func sameMatches(a, b []autodiscovery.Match) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Integration != b[i].Integration || a[i].Service.Address() != b[i].Service.Address() || a[i].Config != b[i].Config {
+			return false
+		}
+	}
+	return true
+}
+
+// runAutodiscovery periodically discovers integrations until ctx is
+// canceled, calling update with the discovered integrations.
+func runAutodiscovery(ctx context.Context, l log.Logger, cfg autodiscovery.Config, discover func(context.Context) ([]autodiscovery.Match, error), update func([]autodiscovery.Match)) {
+	t := time.NewTicker(cfg.RefreshInterval)
+	defer t.Stop()
+
+	for {
+		matches, err := discover(ctx)
+		if err != nil {
+			level.Warn(l).Log("msg", "autodiscovery failed", "err", err)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		update(matches)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,317 @@
+// Package autodiscovery detects services running on the local machine which
+// integrations can collect metrics from.
+package autodiscovery
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Sources of discovered services.
+const (
+	SourceListeningPort = "listening_port"
+	SourceSystemd       = "systemd"
+	SourceDocker        = "docker"
+)
+
+// DefaultConfig holds default values for Config.
+var DefaultConfig = Config{
+	RefreshInterval: time.Minute,
+	ListeningPorts:  true,
+	SystemdUnits:    true,
+	DockerHost:      "unix:///var/run/docker.sock",
+}
+
+// DefaultRules are the rules used to match services with integrations.
+// Rules from Config are used instead of the default rule for the same
+// integration.
+var DefaultRules = []Rule{
+	{
+		Integration:  "redis",
+		Ports:        []int{6379},
+		SystemdUnits: []string{"redis", "redis-server"},
+		Config:       `redis_addr: "{{ .Address }}"`,
+	},
+	{
+		Integration:  "mysql",
+		Ports:        []int{3306},
+		SystemdUnits: []string{"mysql", "mysqld", "mariadb"},
+		Config:       `data_source_name: "root@({{ .Address }})/"`,
+	},
+	{
+		Integration:  "postgres",
+		Ports:        []int{5432},
+		SystemdUnits: []string{"postgresql"},
+		Config:       `data_source_names: ["postgresql://postgres@{{ .Address }}/postgres?sslmode=disable"]`,
+	},
+	{
+		Integration:  "memcached",
+		Ports:        []int{11211},
+		SystemdUnits: []string{"memcached"},
+		Config:       `memcached_address: "{{ .Address }}"`,
+	},
+	{
+		Integration:  "mongodb",
+		Ports:        []int{27017},
+		SystemdUnits: []string{"mongod", "mongodb"},
+		Config:       `mongodb_uri: "mongodb://{{ .Address }}"`,
+	},
+	{
+		Integration:  "elasticsearch",
+		Ports:        []int{9200},
+		SystemdUnits: []string{"elasticsearch"},
+		Config:       `address: "http://{{ .Address }}"`,
+	},
+	{
+		Integration:  "consul",
+		Ports:        []int{8500},
+		SystemdUnits: []string{"consul"},
+		Config:       `server: "http://{{ .Address }}"`,
+	},
+}
+
+// Config controls how services are discovered.
+type Config struct {
+	// RefreshInterval is how often services are discovered.
+	RefreshInterval time.Duration `yaml:"refresh_interval,omitempty"`
+
+	// ListeningPorts discovers services from the TCP ports listened on.
+	ListeningPorts bool `yaml:"listening_ports"`
+	// SystemdUnits discovers services from running systemd units.
+	SystemdUnits bool `yaml:"systemd_units"`
+	// Docker discovers services from containers with the LabelIntegration
+	// label.
+	Docker     bool   `yaml:"docker"`
+	DockerHost string `yaml:"docker_host,omitempty"`
+
+	// Rules are added to DefaultRules, replacing the default rule of the
+	// same integration.
+	Rules []Rule `yaml:"rules,omitempty"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultConfig
+	type config Config
+	if err := unmarshal((*config)(c)); err != nil {
+		return err
+	}
+
+	if c.RefreshInterval <= 0 {
+		return fmt.Errorf("autodiscovery refresh_interval must be greater than 0s")
+	}
+	for i, r := range c.Rules {
+		if r.Integration == "" {
+			return fmt.Errorf("autodiscovery rule %d: integration must be set", i)
+		}
+		if _, err := template.New(r.Integration).Parse(r.Config); err != nil {
+			return fmt.Errorf("autodiscovery rule %q: invalid config template: %w", r.Integration, err)
+		}
+	}
+	return nil
+}
+
+// Rule matches services with an integration.
+type Rule struct {
+	// Integration is the name of the integration to create, such as "redis".
+	Integration string `yaml:"integration"`
+	// Ports are the ports the service listens on. The first port is used for
+	// services discovered from systemd units.
+	Ports []int `yaml:"ports,omitempty"`
+	// SystemdUnits are the names of the systemd units of the service, without
+	// the ".service" suffix.
+	SystemdUnits []string `yaml:"systemd_units,omitempty"`
+	// Config is a template of the YAML config of the integration. It is
+	// executed with the matched Service.
+	Config string `yaml:"config"`
+}
+
+// Service is a service running on the local machine.
+type Service struct {
+	// Source is the source the service was discovered from.
+	Source string
+	// Name is the name of the systemd unit or container of the service, if
+	// any.
+	Name string
+	Host string
+	Port int
+
+	// integration is the integration a container is labeled with.
+	integration string
+}
+
+// Address returns the host:port address of s.
+func (s Service) Address() string {
+	return net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+}
+
+// Match is a service matched with an integration.
+type Match struct {
+	Integration string
+	Service     Service
+	// Config is the YAML config of the integration.
+	Config string
+}
+
+// Discoverer discovers services and matches them with integrations.
+type Discoverer struct {
+	conf  Config
+	rules []Rule
+
+	// Sources of services, replaced in tests.
+	listeningPorts func() ([]Service, error)
+	systemdUnits   func(ctx context.Context) ([]string, error)
+	containers     func(ctx context.Context) ([]Service, error)
+}
+
+// New creates a Discoverer.
+func New(conf Config) *Discoverer {
+	return &Discoverer{
+		conf:           conf,
+		rules:          rules(conf.Rules),
+		listeningPorts: func() ([]Service, error) { return listeningPorts("/proc") },
+		systemdUnits:   runningSystemdUnits,
+		containers: func(ctx context.Context) ([]Service, error) {
+			return labeledContainers(ctx, conf.DockerHost)
+		},
+	}
+}
+
+// rules returns DefaultRules with the rules in custom added.
+func rules(custom []Rule) []Rule {
+	res := make([]Rule, 0, len(DefaultRules)+len(custom))
+	for _, r := range DefaultRules {
+		replaced := false
+		for _, c := range custom {
+			replaced = replaced || c.Integration == r.Integration
+		}
+		if !replaced {
+			res = append(res, r)
+		}
+	}
+	return append(res, custom...)
+}
+
+// Discover returns the discovered services matched with integrations. A
+// service is matched at most once per integration. Services are returned
+// even if some sources failed, in which case the first error is returned
+// too.
+func (d *Discoverer) Discover(ctx context.Context) ([]Match, error) {
+	var (
+		services []Service
+		firstErr error
+	)
+	saveErr := func(source string, err error) {
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("discovering %s services failed: %w", source, err)
+		}
+	}
+
+	if d.conf.ListeningPorts {
+		ports, err := d.listeningPorts()
+		saveErr(SourceListeningPort, err)
+		services = append(services, ports...)
+	}
+	if d.conf.SystemdUnits {
+		units, err := d.systemdUnits(ctx)
+		saveErr(SourceSystemd, err)
+		services = append(services, d.unitServices(units)...)
+	}
+	if d.conf.Docker {
+		containers, err := d.containers(ctx)
+		saveErr(SourceDocker, err)
+		services = append(services, containers...)
+	}
+
+	var (
+		matches []Match
+		seen    = make(map[string]struct{})
+	)
+	for _, s := range services {
+		for _, r := range d.rules {
+			if !r.matches(s) {
+				continue
+			}
+			key := r.Integration + "/" + s.Address()
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+
+			cfg, err := r.render(s)
+			if err != nil {
+				saveErr(s.Source, err)
+				continue
+			}
+			matches = append(matches, Match{Integration: r.Integration, Service: s, Config: cfg})
+		}
+	}
+	return matches, firstErr
+}
+
+// unitServices returns the services of units, which listen on the first port
+// of the rule matching their name.
+func (d *Discoverer) unitServices(units []string) []Service {
+	var services []Service
+	for _, unit := range units {
+		name := strings.TrimSuffix(unit, ".service")
+		for _, r := range d.rules {
+			if len(r.Ports) == 0 || !containsString(r.SystemdUnits, name) {
+				continue
+			}
+			services = append(services, Service{
+				Source: SourceSystemd,
+				Name:   unit,
+				Host:   "localhost",
+				Port:   r.Ports[0],
+			})
+		}
+	}
+	return services
+}
+
+func (r Rule) matches(s Service) bool {
+	switch s.Source {
+	case SourceSystemd:
+		return containsString(r.SystemdUnits, strings.TrimSuffix(s.Name, ".service"))
+	case SourceDocker:
+		// Containers are labeled with the integration to use
+		return s.integration == r.Integration
+	default:
+		for _, p := range r.Ports {
+			if p == s.Port {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func (r Rule) render(s Service) (string, error) {
+	tmpl, err := template.New(r.Integration).Parse(r.Config)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, struct {
+		Address string
+		Host    string
+		Port    int
+	}{s.Address(), s.Host, s.Port})
+	return buf.String(), err
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
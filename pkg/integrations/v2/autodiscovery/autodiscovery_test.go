@@ -0,0 +1,167 @@
+package autodiscovery
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/network"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestDiscoverer_Discover(t *testing.T) {
+	d := New(DefaultConfig)
+	d.conf.Docker = true
+	d.listeningPorts = func() ([]Service, error) {
+		return []Service{
+			{Source: SourceListeningPort, Host: "localhost", Port: 6379},
+			{Source: SourceListeningPort, Host: "localhost", Port: 22},
+		}, nil
+	}
+	d.systemdUnits = func(context.Context) ([]string, error) {
+		return []string{"redis-server.service", "mysql.service", "sshd.service"}, nil
+	}
+	d.containers = func(context.Context) ([]Service, error) {
+		return nil, errors.New("connection refused")
+	}
+
+	matches, err := d.Discover(context.Background())
+	require.EqualError(t, err, "discovering docker services failed: connection refused")
+	require.Equal(t, []Match{
+		{
+			Integration: "redis",
+			Service:     Service{Source: SourceListeningPort, Host: "localhost", Port: 6379},
+			Config:      `redis_addr: "localhost:6379"`,
+		},
+		{
+			Integration: "mysql",
+			Service:     Service{Source: SourceSystemd, Name: "mysql.service", Host: "localhost", Port: 3306},
+			Config:      `data_source_name: "root@(localhost:3306)/"`,
+		},
+	}, matches)
+}
+
+func TestDiscoverer_CustomRules(t *testing.T) {
+	var conf Config
+	require.NoError(t, yaml.UnmarshalStrict([]byte(`
+listening_ports: false
+rules:
+- integration: redis
+  systemd_units: [keydb]
+  ports: [6380]
+  config: 'redis_addr: "{{ .Host }}:{{ .Port }}"'
+`), &conf))
+
+	d := New(conf)
+	d.systemdUnits = func(context.Context) ([]string, error) {
+		return []string{"redis-server.service", "keydb.service"}, nil
+	}
+
+	matches, err := d.Discover(context.Background())
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	require.Equal(t, `redis_addr: "localhost:6380"`, matches[0].Config)
+}
+
+func TestConfig_Invalid(t *testing.T) {
+	var conf Config
+	err := yaml.Unmarshal([]byte(`
+rules:
+- integration: redis
+  config: '{{ .Address'
+`), &conf)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `autodiscovery rule "redis": invalid config template`)
+}
+
+func Test_listeningPorts(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "net"), 0755))
+
+	tcp := `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:18EB 00000000:0000 0A 00000000:00000000 00:00000000 00000000   999        0 1 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:0CEA 00000000:0000 0A 00000000:00000000 00:00000000 00000000   999        0 2 1 0000000000000000 100 0 0 10 0
+   2: 0A00000A:1F90 00000000:0000 0A 00000000:00000000 00:00000000 00000000   999        0 3 1 0000000000000000 100 0 0 10 0
+   3: 0100007F:18EB 0100007F:D431 01 00000000:00000000 00:00000000 00000000   999        0 4 1 0000000000000000 100 0 0 10 0
+`
+	tcp6 := `  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000000000000000000000000000:18EB 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000   999        0 5 1 0000000000000000 100 0 0 10 0
+   1: 000080FE00000000FF005450B6AC09FE:2382 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000   999        0 6 1 0000000000000000 100 0 0 10 0
+`
+	require.NoError(t, os.WriteFile(filepath.Join(root, "net", "tcp"), []byte(tcp), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "net", "tcp6"), []byte(tcp6), 0644))
+
+	services, err := listeningPorts(root)
+	require.NoError(t, err)
+	require.Equal(t, []Service{
+		{Source: SourceListeningPort, Host: "localhost", Port: 6379},
+		{Source: SourceListeningPort, Host: "localhost", Port: 3306},
+		{Source: SourceListeningPort, Host: "10.0.0.10", Port: 8080},
+		{Source: SourceListeningPort, Host: "fe80::5054:ff:fe09:acb6", Port: 9090},
+	}, services)
+}
+
+func Test_parseUnits(t *testing.T) {
+	out := "redis-server.service loaded active running Advanced key-value store\nssh.service loaded active running OpenBSD Secure Shell server\n"
+	require.Equal(t, []string{"redis-server.service", "ssh.service"}, parseUnits([]byte(out)))
+}
+
+func Test_containerService(t *testing.T) {
+	networks := &types.SummaryNetworkSettings{Networks: map[string]*network.EndpointSettings{
+		"bridge": {IPAddress: "172.17.0.2"},
+	}}
+
+	tt := []struct {
+		name      string
+		container types.Container
+		expect    Service
+		expectOK  bool
+	}{
+		{
+			name: "published port",
+			container: types.Container{
+				Names:  []string{"/cache"},
+				Labels: map[string]string{LabelIntegration: "redis"},
+				Ports: []types.Port{
+					{PrivatePort: 6379, Type: "tcp"},
+					{PrivatePort: 6379, PublicPort: 16379, Type: "tcp"},
+				},
+				NetworkSettings: networks,
+			},
+			expect:   Service{Source: SourceDocker, Name: "cache", Host: "localhost", Port: 16379, integration: "redis"},
+			expectOK: true,
+		},
+		{
+			name: "labeled port",
+			container: types.Container{
+				Names:           []string{"/db"},
+				Labels:          map[string]string{LabelIntegration: "mysql", LabelPort: "3307"},
+				Ports:           []types.Port{{PrivatePort: 3306, Type: "tcp"}},
+				NetworkSettings: networks,
+			},
+			expect:   Service{Source: SourceDocker, Name: "db", Host: "172.17.0.2", Port: 3307, integration: "mysql"},
+			expectOK: true,
+		},
+		{
+			name: "no port",
+			container: types.Container{
+				Labels:          map[string]string{LabelIntegration: "mysql"},
+				NetworkSettings: networks,
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s, ok := containerService(tc.container)
+			require.Equal(t, tc.expectOK, ok)
+			if tc.expectOK {
+				require.Equal(t, tc.expect, s)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package autodiscovery
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+)
+
+// Labels of containers discovered by autodiscovery.
+const (
+	// LabelIntegration is the name of the integration to create for the
+	// container, such as "redis".
+	LabelIntegration = "agent.grafana.com/integration"
+	// LabelPort is the port the service of the container listens on. The
+	// first port exposed by the container is used if it's not set.
+	LabelPort = "agent.grafana.com/port"
+)
+
+// labeledContainers returns a service for each running container with the
+// LabelIntegration label.
+func labeledContainers(ctx context.Context, host string) ([]Service, error) {
+	cli, err := client.NewClientWithOpts(client.WithHost(host), client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, err
+	}
+	defer cli.Close()
+
+	containers, err := cli.ContainerList(ctx, types.ContainerListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", LabelIntegration)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var services []Service
+	for _, c := range containers {
+		if s, ok := containerService(c); ok {
+			services = append(services, s)
+		}
+	}
+	return services, nil
+}
+
+// containerService returns the service of c. Ports published on the host
+// are reached through localhost, other ports through the IP of the
+// container.
+func containerService(c types.Container) (Service, bool) {
+	s := Service{
+		Source:      SourceDocker,
+		integration: c.Labels[LabelIntegration],
+	}
+	if len(c.Names) > 0 {
+		s.Name = strings.TrimPrefix(c.Names[0], "/")
+	}
+
+	var (
+		port, _ = strconv.Atoi(c.Labels[LabelPort])
+		found   *types.Port
+	)
+	for i, p := range c.Ports {
+		if p.Type != "tcp" || (port != 0 && int(p.PrivatePort) != port) {
+			continue
+		}
+		// Prefer published ports
+		if found == nil || (found.PublicPort == 0 && p.PublicPort != 0) {
+			found = &c.Ports[i]
+		}
+	}
+
+	switch {
+	case found != nil && found.PublicPort != 0:
+		s.Host, s.Port = "localhost", int(found.PublicPort)
+		return s, true
+	case found != nil:
+		port = int(found.PrivatePort)
+	case port == 0:
+		return s, false
+	}
+
+	if c.NetworkSettings == nil {
+		return s, false
+	}
+	for _, n := range c.NetworkSettings.Networks {
+		if n != nil && n.IPAddress != "" {
+			s.Host, s.Port = n.IPAddress, port
+			return s, true
+		}
+	}
+	return s, false
+}
@@ -0,0 +1,101 @@
+package autodiscovery
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// tcpListen is the state of listening sockets in /proc/net/tcp.
+const tcpListen = "0A"
+
+// listeningPorts returns a service for each TCP port listened on, read from
+// the net/tcp and net/tcp6 files of procRoot. Services listening on all
+// addresses or a loopback address have the "localhost" host.
+func listeningPorts(procRoot string) ([]Service, error) {
+	var (
+		services []Service
+		seen     = make(map[string]struct{})
+	)
+	for _, name := range []string{"tcp", "tcp6"} {
+		f, err := os.Open(filepath.Join(procRoot, "net", name))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		found, err := parseProcNetTCP(f)
+		_ = f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", f.Name(), err)
+		}
+		for _, s := range found {
+			if _, ok := seen[s.Address()]; ok {
+				continue
+			}
+			seen[s.Address()] = struct{}{}
+			services = append(services, s)
+		}
+	}
+	return services, nil
+}
+
+// parseProcNetTCP parses the listening sockets of a /proc/net/tcp or
+// /proc/net/tcp6 file.
+func parseProcNetTCP(f *os.File) ([]Service, error) {
+	var services []Service
+
+	sc := bufio.NewScanner(f)
+	sc.Scan() // Skip the header
+	for sc.Scan() {
+		// sl local_address rem_address st ...
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 4 || fields[3] != tcpListen {
+			continue
+		}
+		ip, port, err := parseProcNetAddr(fields[1])
+		if err != nil {
+			return nil, err
+		}
+
+		host := ip.String()
+		if ip.IsUnspecified() || ip.IsLoopback() {
+			host = "localhost"
+		}
+		services = append(services, Service{
+			Source: SourceListeningPort,
+			Host:   host,
+			Port:   port,
+		})
+	}
+	return services, sc.Err()
+}
+
+// parseProcNetAddr parses an address like "0100007F:1F90". The IP is made of
+// 32-bit words in host byte order, assumed to be little endian.
+func parseProcNetAddr(addr string) (net.IP, int, error) {
+	parts := strings.Split(addr, ":")
+	if len(parts) != 2 {
+		return nil, 0, fmt.Errorf("invalid address %q", addr)
+	}
+
+	ip, err := hex.DecodeString(parts[0])
+	if err != nil || (len(ip) != net.IPv4len && len(ip) != net.IPv6len) {
+		return nil, 0, fmt.Errorf("invalid address %q", addr)
+	}
+	for i := 0; i < len(ip); i += 4 {
+		ip[i], ip[i+1], ip[i+2], ip[i+3] = ip[i+3], ip[i+2], ip[i+1], ip[i]
+	}
+
+	port, err := strconv.ParseUint(parts[1], 16, 16)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid address %q", addr)
+	}
+	return net.IP(ip), int(port), nil
+}
@@ -0,0 +1,38 @@
+package autodiscovery
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+)
+
+// runningSystemdUnits returns the names of the running systemd services. No
+// units are returned if systemctl isn't installed.
+func runningSystemdUnits(ctx context.Context) ([]string, error) {
+	out, err := exec.CommandContext(ctx,
+		"systemctl", "list-units", "--type=service", "--state=running", "--no-legend", "--plain",
+	).Output()
+	if errors.Is(err, exec.ErrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return parseUnits(out), nil
+}
+
+// parseUnits parses the output of systemctl list-units, where each line
+// starts with the name of a unit.
+func parseUnits(out []byte) []string {
+	var units []string
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) > 0 {
+			units = append(units, fields[0])
+		}
+	}
+	return units
+}
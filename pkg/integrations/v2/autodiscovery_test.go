@@ -0,0 +1,67 @@
+package integrations
+
+import (
+	"testing"
+
+	"github.com/grafana/agent/pkg/integrations/v2/autodiscovery"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func Test_discoveredIntegrations(t *testing.T) {
+	setRegistered(t, map[Config]Type{
+		&testIntegrationA{}: TypeMultiplex,
+		&testIntegrationB{}: TypeMultiplex,
+	})
+
+	matches := []autodiscovery.Match{
+		{
+			Integration: "test",
+			Service:     autodiscovery.Service{Source: autodiscovery.SourceListeningPort, Host: "localhost", Port: 1234},
+			Config:      `text: localhost:1234`,
+		},
+		{
+			Integration: "shouldnotbefound",
+			Service:     autodiscovery.Service{Source: autodiscovery.SourceSystemd, Name: "b.service", Host: "localhost", Port: 5678},
+			Config:      `text: localhost:5678`,
+		},
+		{
+			Integration: "unregistered",
+			Service:     autodiscovery.Service{Source: autodiscovery.SourceListeningPort, Host: "localhost", Port: 9999},
+		},
+	}
+
+	t.Run("all discovered", func(t *testing.T) {
+		res, err := discoveredIntegrations(matches, nil)
+		require.EqualError(t, err, `integration "unregistered" not registered`)
+		require.Len(t, res, 2)
+
+		require.Equal(t, "test", res[0].Integration)
+		require.Equal(t, autodiscovery.SourceListeningPort, res[0].Source)
+		require.Equal(t, "localhost:1234", res[0].Address)
+		require.Equal(t, &testIntegrationA{Text: "localhost:1234", Truth: true}, res[0].cfg)
+
+		require.Equal(t, "b.service", res[1].Service)
+		require.Equal(t, &testIntegrationB{Text: "localhost:5678"}, res[1].cfg)
+	})
+
+	t.Run("explicit integrations take precedence", func(t *testing.T) {
+		res, err := discoveredIntegrations(matches[:2], Configs{&testIntegrationA{Text: "explicit"}})
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+		require.Equal(t, "shouldnotbefound", res[0].Integration)
+	})
+}
+
+func TestSubsystemOptions_Autodiscovery(t *testing.T) {
+	var so SubsystemOptions
+	require.NoError(t, yaml.UnmarshalStrict([]byte(`
+autodiscovery:
+  docker: true
+`), &so))
+	require.NotNil(t, so.Autodiscovery)
+
+	expect := autodiscovery.DefaultConfig
+	expect.Docker = true
+	require.Equal(t, expect, *so.Autodiscovery)
+}
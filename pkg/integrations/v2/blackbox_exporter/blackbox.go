@@ -0,0 +1,205 @@
+package blackbox_exporter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/gorilla/mux"
+	"github.com/grafana/agent/pkg/integrations/v2"
+	"github.com/grafana/agent/pkg/integrations/v2/autoscrape"
+	"github.com/grafana/agent/pkg/integrations/v2/metricsutils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/discovery"
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+)
+
+// defaultProbeTimeout is the timeout of probes when neither the module nor
+// the scrape sets one.
+const defaultProbeTimeout = 10 * time.Second
+
+type blackboxHandler struct {
+	cfg *Config
+	log log.Logger
+}
+
+func (bh *blackboxHandler) Targets(ep integrations.Endpoint) []*targetgroup.Group {
+	integrationNameValue := model.LabelValue("integrations/" + bh.cfg.Name())
+	key, _ := bh.cfg.InstanceKey("")
+
+	group := &targetgroup.Group{
+		Labels: model.LabelSet{
+			model.InstanceLabel: model.LabelValue(key),
+			model.JobLabel:      integrationNameValue,
+			"agent_hostname":    model.LabelValue(bh.cfg.globals.AgentIdentifier),
+
+			// Meta labels that can be used during SD.
+			"__meta_agent_integration_name":       model.LabelValue(bh.cfg.Name()),
+			"__meta_agent_integration_instance":   model.LabelValue(bh.cfg.Name()),
+			"__meta_agent_integration_autoscrape": model.LabelValue(metricsutils.BoolToString(*bh.cfg.Common.Autoscrape.Enable)),
+		},
+		Source: fmt.Sprintf("%s/%s", bh.cfg.Name(), bh.cfg.Name()),
+	}
+
+	for _, lbl := range bh.cfg.Common.ExtraLabels {
+		group.Labels[model.LabelName(lbl.Name)] = model.LabelValue(lbl.Value)
+	}
+
+	for _, t := range bh.cfg.BlackboxTargets {
+		group.Targets = append(group.Targets, model.LabelSet{
+			model.AddressLabel:     model.LabelValue(ep.Host),
+			model.MetricsPathLabel: model.LabelValue(path.Join(ep.Prefix, "metrics")),
+			"blackbox_target":      model.LabelValue(t.Name),
+			"__param_target":       model.LabelValue(t.Name),
+		})
+	}
+
+	return []*targetgroup.Group{group}
+}
+
+func (bh *blackboxHandler) ScrapeConfigs(sd discovery.Configs) []*autoscrape.ScrapeConfig {
+	if !*bh.cfg.Common.Autoscrape.Enable {
+		return nil
+	}
+	name := bh.cfg.Name()
+	cfg := config.DefaultScrapeConfig
+	cfg.JobName = fmt.Sprintf("%s/%s", name, name)
+	cfg.Scheme = bh.cfg.globals.AgentBaseURL.Scheme
+	cfg.ServiceDiscoveryConfigs = sd
+	cfg.ScrapeInterval = bh.cfg.Common.Autoscrape.ScrapeInterval
+	cfg.ScrapeTimeout = bh.cfg.Common.Autoscrape.ScrapeTimeout
+	cfg.RelabelConfigs = bh.cfg.Common.Autoscrape.RelabelConfigs
+	cfg.MetricRelabelConfigs = bh.cfg.Common.Autoscrape.MetricRelabelConfigs
+
+	return []*autoscrape.ScrapeConfig{{
+		Instance: bh.cfg.Common.Autoscrape.MetricsInstance,
+		Config:   cfg,
+	}}
+}
+
+func (bh *blackboxHandler) Handler(prefix string) (http.Handler, error) {
+	r := mux.NewRouter()
+	r.Handle(path.Join(prefix, "metrics"), bh)
+
+	return r, nil
+}
+
+// Static typecheck tests
+var (
+	_ integrations.Integration        = (*blackboxHandler)(nil)
+	_ integrations.HTTPIntegration    = (*blackboxHandler)(nil)
+	_ integrations.MetricsIntegration = (*blackboxHandler)(nil)
+)
+
+// RunIntegration implements integrations.Integration. Targets are probed when
+// scraped, so there's nothing to run in the background.
+func (bh *blackboxHandler) RunIntegration(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+// ServeHTTP probes the target given by the target parameter, which is either
+// the name of a configured target or an address to probe with the module
+// given by the module parameter.
+func (bh *blackboxHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	targetName := query.Get("target")
+	if len(query["target"]) != 1 || targetName == "" {
+		http.Error(w, "'target' parameter must be specified once", http.StatusBadRequest)
+		return
+	}
+	if len(query["module"]) > 1 {
+		http.Error(w, "'module' parameter must only be specified once", http.StatusBadRequest)
+		return
+	}
+
+	target, moduleName := targetName, DefaultModule
+	for _, t := range bh.cfg.BlackboxTargets {
+		if t.Name == targetName {
+			target, moduleName = t.Target, t.Module
+			break
+		}
+	}
+	if query.Has("module") {
+		moduleName = query.Get("module")
+	}
+
+	module, ok := bh.cfg.BlackboxConfig.Modules[moduleName]
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unknown module '%s'", moduleName), http.StatusBadRequest)
+		return
+	}
+
+	timeout, err := bh.probeTimeout(r, module)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	logger := log.With(bh.log, "module", moduleName, "target", target)
+	level.Debug(logger).Log("msg", "Starting probe")
+
+	var (
+		probeSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_success",
+			Help: "Displays whether or not the probe was a success.",
+		})
+		probeDuration = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_duration_seconds",
+			Help: "Returns how long the probe took to complete in seconds.",
+		})
+	)
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(probeSuccess, probeDuration)
+
+	start := time.Now()
+	success := probers[module.Prober](ctx, target, module, registry, logger)
+	duration := time.Since(start).Seconds()
+
+	probeDuration.Set(duration)
+	if success {
+		probeSuccess.Set(1)
+		level.Debug(logger).Log("msg", "Probe succeeded", "duration_seconds", duration)
+	} else {
+		level.Debug(logger).Log("msg", "Probe failed", "duration_seconds", duration)
+	}
+
+	h := promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+	h.ServeHTTP(w, r)
+}
+
+// probeTimeout returns the timeout of the probe of module requested by r. The
+// timeout of the module is used if it is shorter than the scrape timeout
+// minus the probe timeout offset.
+func (bh *blackboxHandler) probeTimeout(r *http.Request, module Module) (time.Duration, error) {
+	var timeout time.Duration
+	if v := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"); v != "" {
+		seconds, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse timeout from Prometheus header: %w", err)
+		}
+		timeout = time.Duration(seconds*float64(time.Second)) - bh.cfg.ProbeTimeoutOffset
+		if timeout <= 0 {
+			return 0, fmt.Errorf("scrape timeout %ss is shorter than probe_timeout_offset %s", v, bh.cfg.ProbeTimeoutOffset)
+		}
+	}
+
+	if module.Timeout > 0 && (timeout == 0 || module.Timeout < timeout) {
+		timeout = module.Timeout
+	}
+	if timeout == 0 {
+		timeout = defaultProbeTimeout
+	}
+	return timeout, nil
+}
@@ -0,0 +1,349 @@
+// Package blackbox_exporter probes endpoints over HTTP, TCP, ICMP, and DNS,
+// following the modules and metrics of
+// https://github.com/prometheus/blackbox_exporter.
+package blackbox_exporter
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/go-kit/log"
+	integrations_v2 "github.com/grafana/agent/pkg/integrations/v2"
+	"github.com/grafana/agent/pkg/integrations/v2/common"
+	"github.com/miekg/dns"
+	config_util "github.com/prometheus/common/config"
+)
+
+// DefaultModule is the module used to probe targets which don't set one.
+const DefaultModule = "http_2xx"
+
+// DefaultConfig holds the default settings for the blackbox integration.
+var DefaultConfig = Config{
+	ProbeTimeoutOffset: 500 * time.Millisecond,
+}
+
+var (
+	// DefaultModules are used when blackbox_config defines no modules.
+	DefaultModules = map[string]Module{
+		"http_2xx":    {Prober: "http", HTTP: DefaultHTTPProbe, TCP: DefaultTCPProbe, ICMP: DefaultICMPProbe, DNS: DefaultDNSProbe},
+		"tcp_connect": {Prober: "tcp", HTTP: DefaultHTTPProbe, TCP: DefaultTCPProbe, ICMP: DefaultICMPProbe, DNS: DefaultDNSProbe},
+		"icmp":        {Prober: "icmp", HTTP: DefaultHTTPProbe, TCP: DefaultTCPProbe, ICMP: DefaultICMPProbe, DNS: DefaultDNSProbe},
+	}
+
+	// DefaultIPProtocolConfig holds the default IP protocol settings of
+	// probes.
+	DefaultIPProtocolConfig = IPProtocolConfig{
+		PreferredIPProtocol: "ip6",
+		IPProtocolFallback:  true,
+	}
+
+	// DefaultHTTPProbe holds the default settings of HTTP probes.
+	DefaultHTTPProbe = HTTPProbe{
+		Method:           "GET",
+		IPProtocolConfig: DefaultIPProtocolConfig,
+	}
+
+	// DefaultTCPProbe holds the default settings of TCP probes.
+	DefaultTCPProbe = TCPProbe{
+		IPProtocolConfig: DefaultIPProtocolConfig,
+	}
+
+	// DefaultICMPProbe holds the default settings of ICMP probes.
+	DefaultICMPProbe = ICMPProbe{
+		IPProtocolConfig: DefaultIPProtocolConfig,
+	}
+
+	// DefaultDNSProbe holds the default settings of DNS probes.
+	DefaultDNSProbe = DNSProbe{
+		QueryType:         "ANY",
+		TransportProtocol: "udp",
+		ValidRcodes:       []string{"NOERROR"},
+		RecursionDesired:  true,
+		IPProtocolConfig:  DefaultIPProtocolConfig,
+	}
+)
+
+// Config configures the blackbox integration.
+type Config struct {
+	// BlackboxConfig holds the modules targets are probed with.
+	// DefaultModules are used when omitted.
+	BlackboxConfig  BlackboxConfig   `yaml:"blackbox_config,omitempty"`
+	BlackboxTargets []BlackboxTarget `yaml:"blackbox_targets"`
+	// ProbeTimeoutOffset is subtracted from the scrape timeout to leave time
+	// for the scrape to be served after the probe.
+	ProbeTimeoutOffset time.Duration        `yaml:"probe_timeout_offset,omitempty"`
+	Common             common.MetricsConfig `yaml:",inline"`
+
+	globals integrations_v2.Globals
+}
+
+// BlackboxConfig holds the probing modules.
+type BlackboxConfig struct {
+	Modules map[string]Module `yaml:"modules"`
+}
+
+// BlackboxTarget defines a target to be probed by the integration.
+type BlackboxTarget struct {
+	Name   string `yaml:"name"`
+	Target string `yaml:"address"`
+	Module string `yaml:"module,omitempty"`
+}
+
+// Module configures how targets are probed.
+type Module struct {
+	// Prober is one of http, tcp, icmp, or dns.
+	Prober string `yaml:"prober"`
+	// Timeout of probes. Defaults to the scrape timeout minus
+	// probe_timeout_offset.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	HTTP    HTTPProbe     `yaml:"http,omitempty"`
+	TCP     TCPProbe      `yaml:"tcp,omitempty"`
+	ICMP    ICMPProbe     `yaml:"icmp,omitempty"`
+	DNS     DNSProbe      `yaml:"dns,omitempty"`
+}
+
+// IPProtocolConfig selects the IP protocol used to reach targets given by
+// hostname.
+type IPProtocolConfig struct {
+	// PreferredIPProtocol is ip4 or ip6.
+	PreferredIPProtocol string `yaml:"preferred_ip_protocol,omitempty"`
+	// IPProtocolFallback allows the other protocol to be used when the
+	// hostname has no address of the preferred protocol.
+	IPProtocolFallback bool `yaml:"ip_protocol_fallback"`
+}
+
+// HTTPProbe configures HTTP probes. Targets are URLs, defaulting to the http
+// scheme.
+type HTTPProbe struct {
+	// ValidStatusCodes of responses. Defaults to 2xx.
+	ValidStatusCodes  []int             `yaml:"valid_status_codes,omitempty"`
+	Method            string            `yaml:"method,omitempty"`
+	Headers           map[string]string `yaml:"headers,omitempty"`
+	Body              string            `yaml:"body,omitempty"`
+	NoFollowRedirects bool              `yaml:"no_follow_redirects,omitempty"`
+	FailIfSSL         bool              `yaml:"fail_if_ssl,omitempty"`
+	FailIfNotSSL      bool              `yaml:"fail_if_not_ssl,omitempty"`
+	// Bodies matching any of the regular expressions fail the probe.
+	FailIfBodyMatchesRegexp []string `yaml:"fail_if_body_matches_regexp,omitempty"`
+	// Bodies not matching all of the regular expressions fail the probe.
+	FailIfBodyNotMatchesRegexp []string              `yaml:"fail_if_body_not_matches_regexp,omitempty"`
+	TLSConfig                  config_util.TLSConfig `yaml:"tls_config,omitempty"`
+	IPProtocolConfig           `yaml:",inline"`
+
+	failIfMatches    []*regexp.Regexp
+	failIfNotMatches []*regexp.Regexp
+}
+
+// TCPProbe configures TCP probes. Targets are host:port addresses.
+type TCPProbe struct {
+	// TLS starts a TLS handshake once connected.
+	TLS              bool                  `yaml:"tls,omitempty"`
+	TLSConfig        config_util.TLSConfig `yaml:"tls_config,omitempty"`
+	IPProtocolConfig `yaml:",inline"`
+}
+
+// ICMPProbe configures ICMP echo probes. Targets are hosts.
+type ICMPProbe struct {
+	IPProtocolConfig `yaml:",inline"`
+}
+
+// DNSProbe configures DNS probes. Targets are DNS servers, with port 53 used
+// when no port is given.
+type DNSProbe struct {
+	QueryName string `yaml:"query_name"`
+	QueryType string `yaml:"query_type,omitempty"`
+	// TransportProtocol is udp or tcp.
+	TransportProtocol string `yaml:"transport_protocol,omitempty"`
+	// ValidRcodes of responses, such as NOERROR or NXDOMAIN.
+	ValidRcodes      []string `yaml:"valid_rcodes,omitempty"`
+	RecursionDesired bool     `yaml:"recursion_desired"`
+	IPProtocolConfig `yaml:",inline"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler for Config.
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultConfig
+
+	type plain Config
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	if len(c.BlackboxConfig.Modules) == 0 {
+		c.BlackboxConfig.Modules = make(map[string]Module, len(DefaultModules))
+		for name, m := range DefaultModules {
+			c.BlackboxConfig.Modules[name] = m
+		}
+	}
+	if c.ProbeTimeoutOffset < 0 {
+		return fmt.Errorf("probe_timeout_offset must not be negative")
+	}
+
+	names := make(map[string]struct{}, len(c.BlackboxTargets))
+	for i := range c.BlackboxTargets {
+		t := &c.BlackboxTargets[i]
+		if t.Name == "" || t.Target == "" {
+			return fmt.Errorf("blackbox_targets[%d]: name and address must be set", i)
+		}
+		if _, exist := names[t.Name]; exist {
+			return fmt.Errorf("blackbox_targets[%d]: found multiple targets named %q", i, t.Name)
+		}
+		names[t.Name] = struct{}{}
+
+		if t.Module == "" {
+			t.Module = DefaultModule
+		}
+		if _, ok := c.BlackboxConfig.Modules[t.Module]; !ok {
+			return fmt.Errorf("blackbox_targets[%d]: unknown module %q", i, t.Module)
+		}
+	}
+	return nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler for Module.
+func (m *Module) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*m = Module{
+		HTTP: DefaultHTTPProbe,
+		TCP:  DefaultTCPProbe,
+		ICMP: DefaultICMPProbe,
+		DNS:  DefaultDNSProbe,
+	}
+
+	type plain Module
+	if err := unmarshal((*plain)(m)); err != nil {
+		return err
+	}
+
+	if _, ok := probers[m.Prober]; !ok {
+		return fmt.Errorf("unknown prober %q, must be one of http, tcp, icmp, dns", m.Prober)
+	}
+	if m.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+	if m.Prober == "dns" && m.DNS.QueryName == "" {
+		return fmt.Errorf("dns query_name must be set")
+	}
+	return nil
+}
+
+// validate validates c. IPProtocolConfig is inlined into the configs of
+// probes, which validate it when unmarshaled.
+func (c IPProtocolConfig) validate() error {
+	if c.PreferredIPProtocol != "ip4" && c.PreferredIPProtocol != "ip6" {
+		return fmt.Errorf("preferred_ip_protocol must be ip4 or ip6")
+	}
+	return nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler for HTTPProbe.
+func (c *HTTPProbe) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultHTTPProbe
+
+	type plain HTTPProbe
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	if err := c.IPProtocolConfig.validate(); err != nil {
+		return err
+	}
+	if c.FailIfSSL && c.FailIfNotSSL {
+		return fmt.Errorf("at most one of fail_if_ssl and fail_if_not_ssl may be set")
+	}
+	for _, expr := range c.FailIfBodyMatchesRegexp {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("invalid fail_if_body_matches_regexp %q: %w", expr, err)
+		}
+		c.failIfMatches = append(c.failIfMatches, re)
+	}
+	for _, expr := range c.FailIfBodyNotMatchesRegexp {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("invalid fail_if_body_not_matches_regexp %q: %w", expr, err)
+		}
+		c.failIfNotMatches = append(c.failIfNotMatches, re)
+	}
+	return nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler for TCPProbe.
+func (c *TCPProbe) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultTCPProbe
+
+	type plain TCPProbe
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+	return c.IPProtocolConfig.validate()
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler for ICMPProbe.
+func (c *ICMPProbe) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultICMPProbe
+
+	type plain ICMPProbe
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+	return c.IPProtocolConfig.validate()
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler for DNSProbe.
+func (c *DNSProbe) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultDNSProbe
+
+	type plain DNSProbe
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	if err := c.IPProtocolConfig.validate(); err != nil {
+		return err
+	}
+
+	if _, ok := dns.StringToType[c.QueryType]; !ok {
+		return fmt.Errorf("invalid dns query_type %q", c.QueryType)
+	}
+	if c.TransportProtocol != "udp" && c.TransportProtocol != "tcp" {
+		return fmt.Errorf("dns transport_protocol must be udp or tcp")
+	}
+	for _, rcode := range c.ValidRcodes {
+		if _, ok := dns.StringToRcode[rcode]; !ok {
+			return fmt.Errorf("invalid dns valid_rcodes entry %q", rcode)
+		}
+	}
+	return nil
+}
+
+// ApplyDefaults applies the integration's default configuration.
+func (c *Config) ApplyDefaults(globals integrations_v2.Globals) error {
+	c.Common.ApplyDefaults(globals.SubsystemOpts.Metrics.Autoscrape)
+	return nil
+}
+
+// Identifier returns a string that identifies the integration.
+func (c *Config) Identifier(globals integrations_v2.Globals) (string, error) {
+	return c.Name(), nil
+}
+
+// NewIntegration creates a new blackbox integration.
+func (c *Config) NewIntegration(log log.Logger, globals integrations_v2.Globals) (integrations_v2.Integration, error) {
+	c.globals = globals
+	return &blackboxHandler{cfg: c, log: log}, nil
+}
+
+// Name returns the name of the integration.
+func (c *Config) Name() string {
+	return "blackbox"
+}
+
+// InstanceKey returns the hostname:port of the agent.
+func (c *Config) InstanceKey(agentKey string) (string, error) {
+	return agentKey, nil
+}
+
+func init() {
+	integrations_v2.Register(&Config{}, integrations_v2.TypeSingleton)
+}
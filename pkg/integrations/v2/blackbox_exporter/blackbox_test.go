@@ -0,0 +1,247 @@
+package blackbox_exporter
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestConfig_Unmarshal(t *testing.T) {
+	t.Run("default modules", func(t *testing.T) {
+		var cfg Config
+		require.NoError(t, yaml.UnmarshalStrict([]byte("blackbox_targets: [{name: grafana, address: grafana.com}]"), &cfg))
+		require.Equal(t, DefaultModule, cfg.BlackboxTargets[0].Module)
+		require.Len(t, cfg.BlackboxConfig.Modules, len(DefaultModules))
+		require.Equal(t, "ip6", cfg.BlackboxConfig.Modules["icmp"].ICMP.PreferredIPProtocol)
+	})
+
+	t.Run("custom modules", func(t *testing.T) {
+		in := `
+blackbox_config:
+  modules:
+    dns_udp:
+      prober: dns
+      dns:
+        query_name: grafana.com
+        preferred_ip_protocol: ip4
+blackbox_targets:
+- name: resolver
+  address: 1.1.1.1
+  module: dns_udp
+`
+		var cfg Config
+		require.NoError(t, yaml.UnmarshalStrict([]byte(in), &cfg))
+		require.Len(t, cfg.BlackboxConfig.Modules, 1)
+
+		dnsProbe := cfg.BlackboxConfig.Modules["dns_udp"].DNS
+		require.Equal(t, "ANY", dnsProbe.QueryType)
+		require.Equal(t, "ip4", dnsProbe.PreferredIPProtocol)
+		require.True(t, dnsProbe.IPProtocolFallback)
+
+		// Modules of other probers keep their defaults.
+		require.Equal(t, "GET", cfg.BlackboxConfig.Modules["dns_udp"].HTTP.Method)
+		require.Len(t, DefaultModules, 3, "default modules must not be modified")
+	})
+
+	tt := []struct {
+		name        string
+		in          string
+		expectError string
+	}{
+		{
+			name:        "unknown module",
+			in:          "blackbox_targets: [{name: grafana, address: grafana.com, module: ftp}]",
+			expectError: `blackbox_targets[0]: unknown module "ftp"`,
+		},
+		{
+			name:        "duplicate target",
+			in:          "blackbox_targets: [{name: grafana, address: grafana.com}, {name: grafana, address: grafana.net}]",
+			expectError: `blackbox_targets[1]: found multiple targets named "grafana"`,
+		},
+		{
+			name:        "unknown prober",
+			in:          "blackbox_config: {modules: {ftp: {prober: ftp}}}",
+			expectError: `unknown prober "ftp", must be one of http, tcp, icmp, dns`,
+		},
+		{
+			name:        "invalid ip protocol",
+			in:          "blackbox_config: {modules: {tcp: {prober: tcp, tcp: {preferred_ip_protocol: ip5}}}}",
+			expectError: "preferred_ip_protocol must be ip4 or ip6",
+		},
+		{
+			name:        "missing dns query name",
+			in:          "blackbox_config: {modules: {dns: {prober: dns}}}",
+			expectError: "dns query_name must be set",
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var cfg Config
+			require.EqualError(t, yaml.UnmarshalStrict([]byte(tc.in), &cfg), tc.expectError)
+		})
+	}
+}
+
+func unmarshalModule(t *testing.T, in string) Module {
+	t.Helper()
+
+	var m Module
+	require.NoError(t, yaml.UnmarshalStrict([]byte(in), &m))
+	return m
+}
+
+func TestProbeHTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+		_, _ = w.Write([]byte("status: healthy"))
+	}))
+	defer srv.Close()
+
+	tt := []struct {
+		name   string
+		module string
+		target string
+		expect bool
+	}{
+		{name: "success", module: "prober: http", target: srv.URL, expect: true},
+		{name: "redirect", module: "prober: http", target: srv.URL + "/redirect", expect: true},
+		{name: "no follow redirects", module: "{prober: http, http: {no_follow_redirects: true}}", target: srv.URL + "/redirect", expect: false},
+		{name: "valid status code", module: "{prober: http, http: {no_follow_redirects: true, valid_status_codes: [302]}}", target: srv.URL + "/redirect", expect: true},
+		{name: "body matches", module: "{prober: http, http: {fail_if_body_not_matches_regexp: [healthy]}}", target: srv.URL, expect: true},
+		{name: "body doesn't match", module: "{prober: http, http: {fail_if_body_matches_regexp: [healthy]}}", target: srv.URL, expect: false},
+		{name: "not ssl", module: "{prober: http, http: {fail_if_not_ssl: true}}", target: srv.URL, expect: false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			registry := prometheus.NewRegistry()
+			module := unmarshalModule(t, tc.module)
+			require.Equal(t, tc.expect, probeHTTP(context.Background(), tc.target, module, registry, log.NewNopLogger()))
+		})
+	}
+
+	registry := prometheus.NewRegistry()
+	require.True(t, probeHTTP(context.Background(), srv.URL+"/redirect", unmarshalModule(t, "prober: http"), registry, log.NewNopLogger()))
+
+	expect := `
+# HELP probe_http_redirects The number of redirects
+# TYPE probe_http_redirects gauge
+probe_http_redirects 1
+# HELP probe_http_ssl Indicates if SSL was used for the final redirect
+# TYPE probe_http_ssl gauge
+probe_http_ssl 0
+# HELP probe_http_status_code Response HTTP status code
+# TYPE probe_http_status_code gauge
+probe_http_status_code 200
+# HELP probe_ip_protocol Specifies whether probe ip protocol is IP4 or IP6
+# TYPE probe_ip_protocol gauge
+probe_ip_protocol 4
+`
+	require.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expect),
+		"probe_http_redirects", "probe_http_ssl", "probe_http_status_code", "probe_ip_protocol"))
+}
+
+func TestProbeTCP(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := lis.Addr().String()
+
+	module := unmarshalModule(t, "prober: tcp")
+	require.True(t, probeTCP(context.Background(), addr, module, prometheus.NewRegistry(), log.NewNopLogger()))
+
+	require.NoError(t, lis.Close())
+	require.False(t, probeTCP(context.Background(), addr, module, prometheus.NewRegistry(), log.NewNopLogger()))
+}
+
+func TestProbeDNS(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		if r.Question[0].Name != "grafana.com." {
+			m.Rcode = dns.RcodeNameError
+		} else {
+			rr, _ := dns.NewRR("grafana.com. 300 IN A 10.0.0.1")
+			m.Answer = append(m.Answer, rr)
+		}
+		_ = w.WriteMsg(m)
+	})}
+	go func() { _ = srv.ActivateAndServe() }()
+	defer func() { _ = srv.Shutdown() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	registry := prometheus.NewRegistry()
+	module := unmarshalModule(t, "{prober: dns, dns: {query_name: grafana.com, query_type: A}}")
+	require.True(t, probeDNS(ctx, pc.LocalAddr().String(), module, registry, log.NewNopLogger()))
+
+	expect := `
+# HELP probe_dns_answer_rrs Returns number of entries in the answer resource record list
+# TYPE probe_dns_answer_rrs gauge
+probe_dns_answer_rrs 1
+`
+	require.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expect), "probe_dns_answer_rrs"))
+
+	module = unmarshalModule(t, "{prober: dns, dns: {query_name: grafana.net, query_type: A}}")
+	require.False(t, probeDNS(ctx, pc.LocalAddr().String(), module, prometheus.NewRegistry(), log.NewNopLogger()))
+}
+
+func TestHandler(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	var cfg Config
+	in := fmt.Sprintf("blackbox_targets: [{name: app, address: %q}]", srv.URL)
+	require.NoError(t, yaml.UnmarshalStrict([]byte(in), &cfg))
+	bh := &blackboxHandler{cfg: &cfg, log: log.NewNopLogger()}
+
+	t.Run("configured target", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		bh.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics?target=app", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Contains(t, rec.Body.String(), "probe_success 0")
+		require.Contains(t, rec.Body.String(), "probe_http_status_code 503")
+	})
+
+	t.Run("unknown module", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		bh.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics?target=app&module=ftp", nil))
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", "2")
+
+		timeout, err := bh.probeTimeout(req, Module{})
+		require.NoError(t, err)
+		require.Equal(t, 1500*time.Millisecond, timeout)
+
+		timeout, err = bh.probeTimeout(req, Module{Timeout: time.Second})
+		require.NoError(t, err)
+		require.Equal(t, time.Second, timeout)
+
+		req.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", "0.5")
+		_, err = bh.probeTimeout(req, Module{})
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,75 @@
+package blackbox_exporter
+
+import (
+	"context"
+	"net"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func probeDNS(ctx context.Context, target string, module Module, registry *prometheus.Registry, logger log.Logger) bool {
+	var (
+		cfg = module.DNS
+
+		probeDNSAnswerRRSGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_dns_answer_rrs",
+			Help: "Returns number of entries in the answer resource record list",
+		})
+		probeDNSAuthorityRRSGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_dns_authority_rrs",
+			Help: "Returns number of entries in the authority resource record list",
+		})
+		probeDNSAdditionalRRSGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_dns_additional_rrs",
+			Help: "Returns number of entries in the additional resource record list",
+		})
+		probeDNSQuerySucceeded = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_dns_query_succeeded",
+			Help: "Displays whether or not the query was executed successfully",
+		})
+	)
+	registry.MustRegister(probeDNSAnswerRRSGauge, probeDNSAuthorityRRSGauge, probeDNSAdditionalRRSGauge, probeDNSQuerySucceeded)
+
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		host, port = target, "53"
+	}
+
+	ip, err := resolveTarget(ctx, host, cfg.IPProtocolConfig, registry, logger)
+	if err != nil {
+		level.Error(logger).Log("msg", "Error resolving address", "err", err)
+		return false
+	}
+
+	client := &dns.Client{Net: cfg.TransportProtocol}
+	msg := new(dns.Msg)
+	msg.Id = dns.Id()
+	msg.RecursionDesired = cfg.RecursionDesired
+	msg.Question = []dns.Question{{
+		Name:   dns.Fqdn(cfg.QueryName),
+		Qtype:  dns.StringToType[cfg.QueryType],
+		Qclass: dns.ClassINET,
+	}}
+
+	resp, _, err := client.ExchangeContext(ctx, msg, net.JoinHostPort(ip.String(), port))
+	if err != nil {
+		level.Error(logger).Log("msg", "Error while sending a DNS query", "err", err)
+		return false
+	}
+	probeDNSQuerySucceeded.Set(1)
+
+	probeDNSAnswerRRSGauge.Set(float64(len(resp.Answer)))
+	probeDNSAuthorityRRSGauge.Set(float64(len(resp.Ns)))
+	probeDNSAdditionalRRSGauge.Set(float64(len(resp.Extra)))
+
+	for _, rcode := range cfg.ValidRcodes {
+		if dns.StringToRcode[rcode] == resp.Rcode {
+			return true
+		}
+	}
+	level.Info(logger).Log("msg", "Rcode not valid", "rcode", dns.RcodeToString[resp.Rcode])
+	return false
+}
@@ -0,0 +1,200 @@
+package blackbox_exporter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	config_util "github.com/prometheus/common/config"
+)
+
+// maxHTTPBodySize is the maximum size of response bodies read to be matched
+// against regular expressions.
+const maxHTTPBodySize = 10 << 20
+
+func probeHTTP(ctx context.Context, target string, module Module, registry *prometheus.Registry, logger log.Logger) bool {
+	var (
+		cfg = module.HTTP
+
+		probeHTTPStatusCode = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_http_status_code",
+			Help: "Response HTTP status code",
+		})
+		probeHTTPContentLength = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_http_content_length",
+			Help: "Length of http content response",
+		})
+		probeHTTPRedirects = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_http_redirects",
+			Help: "The number of redirects",
+		})
+		probeHTTPSSL = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_http_ssl",
+			Help: "Indicates if SSL was used for the final redirect",
+		})
+		probeHTTPVersion = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_http_version",
+			Help: "Returns the version of HTTP of the probe response",
+		})
+		probeFailedDueToRegex = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_failed_due_to_regex",
+			Help: "Indicates if probe failed due to regex",
+		})
+	)
+	registry.MustRegister(probeHTTPStatusCode, probeHTTPContentLength, probeHTTPRedirects, probeHTTPSSL, probeHTTPVersion, probeFailedDueToRegex)
+
+	if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+		target = "http://" + target
+	}
+	targetURL, err := url.Parse(target)
+	if err != nil {
+		level.Error(logger).Log("msg", "Could not parse target URL", "err", err)
+		return false
+	}
+	targetHost := targetURL.Hostname()
+
+	ip, err := resolveTarget(ctx, targetHost, cfg.IPProtocolConfig, registry, logger)
+	if err != nil {
+		level.Error(logger).Log("msg", "Error resolving address", "err", err)
+		return false
+	}
+
+	tlsConfig, err := config_util.NewTLSConfig(&cfg.TLSConfig)
+	if err != nil {
+		level.Error(logger).Log("msg", "Error creating TLS config", "err", err)
+		return false
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = targetHost
+	}
+
+	// The target is connected to through the resolved address, while
+	// redirects to other hosts are resolved as usual.
+	dialer := &net.Dialer{}
+	transport := &http.Transport{
+		Proxy:             http.ProxyFromEnvironment,
+		TLSClientConfig:   tlsConfig,
+		DisableKeepAlives: true,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			host, port, err := net.SplitHostPort(addr)
+			if err == nil && host == targetHost {
+				addr = net.JoinHostPort(ip.String(), port)
+			}
+			return dialer.DialContext(ctx, network, addr)
+		},
+	}
+	defer transport.CloseIdleConnections()
+
+	var redirects int
+	client := &http.Client{
+		Transport: transport,
+		CheckRedirect: func(_ *http.Request, via []*http.Request) error {
+			if cfg.NoFollowRedirects {
+				return http.ErrUseLastResponse
+			}
+			redirects = len(via)
+			if redirects > 10 {
+				return fmt.Errorf("stopped after 10 redirects")
+			}
+			return nil
+		},
+	}
+
+	var body io.Reader
+	if cfg.Body != "" {
+		body = strings.NewReader(cfg.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, cfg.Method, targetURL.String(), body)
+	if err != nil {
+		level.Error(logger).Log("msg", "Error creating request", "err", err)
+		return false
+	}
+	for name, value := range cfg.Headers {
+		if strings.EqualFold(name, "Host") {
+			req.Host = value
+			continue
+		}
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		level.Error(logger).Log("msg", "Error for HTTP request", "err", err)
+		return false
+	}
+	defer resp.Body.Close()
+	probeHTTPRedirects.Set(float64(redirects))
+
+	success := true
+	probeHTTPStatusCode.Set(float64(resp.StatusCode))
+	probeHTTPVersion.Set(float64(resp.ProtoMajor) + float64(resp.ProtoMinor)/10)
+	if !validStatusCode(cfg.ValidStatusCodes, resp.StatusCode) {
+		level.Info(logger).Log("msg", "Invalid HTTP response status code", "status_code", resp.StatusCode)
+		success = false
+	}
+
+	if len(cfg.failIfMatches) > 0 || len(cfg.failIfNotMatches) > 0 {
+		content, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPBodySize))
+		if err != nil {
+			level.Error(logger).Log("msg", "Error reading HTTP body", "err", err)
+			return false
+		}
+		if !matchBody(content, cfg, logger) {
+			probeFailedDueToRegex.Set(1)
+			success = false
+		}
+	}
+	probeHTTPContentLength.Set(float64(resp.ContentLength))
+
+	if resp.TLS != nil {
+		probeHTTPSSL.Set(1)
+		registerTLSMetrics(resp.TLS, registry)
+		if cfg.FailIfSSL {
+			level.Info(logger).Log("msg", "Final request was over SSL")
+			success = false
+		}
+	} else if cfg.FailIfNotSSL {
+		level.Info(logger).Log("msg", "Final request was not over SSL")
+		success = false
+	}
+	return success
+}
+
+// validStatusCode returns true if code is one of valid, or a 2xx code if
+// valid is empty.
+func validStatusCode(valid []int, code int) bool {
+	if len(valid) == 0 {
+		return code >= 200 && code < 300
+	}
+	for _, v := range valid {
+		if v == code {
+			return true
+		}
+	}
+	return false
+}
+
+// matchBody returns true if body matches none of the fail_if_body_matches_regexp
+// expressions of cfg, and all of its fail_if_body_not_matches_regexp ones.
+func matchBody(body []byte, cfg HTTPProbe, logger log.Logger) bool {
+	for _, re := range cfg.failIfMatches {
+		if re.Match(body) {
+			level.Info(logger).Log("msg", "Body matched regular expression", "regexp", re)
+			return false
+		}
+	}
+	for _, re := range cfg.failIfNotMatches {
+		if !re.Match(body) {
+			level.Info(logger).Log("msg", "Body did not match regular expression", "regexp", re)
+			return false
+		}
+	}
+	return true
+}
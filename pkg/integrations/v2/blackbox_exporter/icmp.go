@@ -0,0 +1,135 @@
+package blackbox_exporter
+
+import (
+	"context"
+	"net"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// icmpSequence is the sequence number of the last ICMP echo request, so that
+// concurrent probes of the same target don't read each other's replies.
+var icmpSequence uint32
+
+func probeICMP(ctx context.Context, target string, module Module, registry *prometheus.Registry, logger log.Logger) bool {
+	durationGaugeVec := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "probe_icmp_duration_seconds",
+		Help: "Duration of icmp request by phase",
+	}, []string{"phase"})
+	for _, phase := range []string{"resolve", "setup", "rtt"} {
+		durationGaugeVec.WithLabelValues(phase)
+	}
+	registry.MustRegister(durationGaugeVec)
+
+	start := time.Now()
+	ip, err := resolveTarget(ctx, target, module.ICMP.IPProtocolConfig, registry, logger)
+	durationGaugeVec.WithLabelValues("resolve").Add(time.Since(start).Seconds())
+	if err != nil {
+		level.Error(logger).Log("msg", "Error resolving address", "err", err)
+		return false
+	}
+
+	setupStart := time.Now()
+	var (
+		echoType, replyType icmp.Type
+		protocol            int
+		network, udpNetwork string
+		listenAddr          string
+	)
+	if ip.IP.To4() != nil {
+		echoType, replyType, protocol = ipv4.ICMPTypeEcho, ipv4.ICMPTypeEchoReply, 1
+		network, udpNetwork, listenAddr = "ip4:icmp", "udp4", "0.0.0.0"
+	} else {
+		echoType, replyType, protocol = ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply, 58
+		network, udpNetwork, listenAddr = "ip6:ipv6-icmp", "udp6", "::"
+	}
+
+	// Raw sockets require privileges, so unprivileged datagram sockets are
+	// used when they can't be opened. The kernel rewrites the ID of echo
+	// requests sent through datagram sockets.
+	var (
+		dst        net.Addr = ip
+		privileged          = true
+	)
+	conn, err := icmp.ListenPacket(network, listenAddr)
+	if err != nil {
+		level.Debug(logger).Log("msg", "Unable to open a raw ICMP socket, trying an unprivileged one", "err", err)
+		privileged = false
+		dst = &net.UDPAddr{IP: ip.IP, Zone: ip.Zone}
+		if conn, err = icmp.ListenPacket(udpNetwork, listenAddr); err != nil {
+			level.Error(logger).Log("msg", "Error listening to socket", "err", err)
+			return false
+		}
+	}
+	defer conn.Close()
+
+	var (
+		id  = os.Getpid() & 0xffff
+		seq = int(atomic.AddUint32(&icmpSequence, 1) & 0xffff)
+	)
+	req, err := (&icmp.Message{
+		Type: echoType,
+		Body: &icmp.Echo{ID: id, Seq: seq, Data: []byte("Grafana Agent Blackbox Probe")},
+	}).Marshal(nil)
+	if err != nil {
+		level.Error(logger).Log("msg", "Error marshalling packet", "err", err)
+		return false
+	}
+	durationGaugeVec.WithLabelValues("setup").Add(time.Since(setupStart).Seconds())
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			level.Error(logger).Log("msg", "Error setting socket deadline", "err", err)
+			return false
+		}
+	}
+
+	rttStart := time.Now()
+	if _, err := conn.WriteTo(req, dst); err != nil {
+		level.Warn(logger).Log("msg", "Error writing to socket", "err", err)
+		return false
+	}
+
+	buf := make([]byte, 1500)
+	for {
+		n, peer, err := conn.ReadFrom(buf)
+		if err != nil {
+			level.Warn(logger).Log("msg", "Error reading from socket", "err", err)
+			return false
+		}
+		if !peerIP(peer).Equal(ip.IP) {
+			continue
+		}
+
+		reply, err := icmp.ParseMessage(protocol, buf[:n])
+		if err != nil || reply.Type != replyType {
+			continue
+		}
+		echo, ok := reply.Body.(*icmp.Echo)
+		if !ok || echo.Seq != seq || (privileged && echo.ID != id) {
+			continue
+		}
+
+		durationGaugeVec.WithLabelValues("rtt").Add(time.Since(rttStart).Seconds())
+		return true
+	}
+}
+
+func peerIP(addr net.Addr) net.IP {
+	switch addr := addr.(type) {
+	case *net.IPAddr:
+		return addr.IP
+	case *net.UDPAddr:
+		return addr.IP
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,101 @@
+package blackbox_exporter
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// proberFunc probes target with module, registering the metrics of the probe
+// to registry. It returns true if the probe succeeded.
+type proberFunc func(ctx context.Context, target string, module Module, registry *prometheus.Registry, logger log.Logger) bool
+
+// probers holds the proberFunc of each prober of modules.
+var probers = map[string]proberFunc{
+	"http": probeHTTP,
+	"tcp":  probeTCP,
+	"icmp": probeICMP,
+	"dns":  probeDNS,
+}
+
+// resolveTarget resolves host to an IP address of the protocol preferred by
+// ipc, registering probe_ip_protocol and probe_dns_lookup_time_seconds.
+// Hosts which are IP addresses aren't resolved.
+func resolveTarget(ctx context.Context, host string, ipc IPProtocolConfig, registry *prometheus.Registry, logger log.Logger) (*net.IPAddr, error) {
+	var (
+		probeIPProtocol = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_ip_protocol",
+			Help: "Specifies whether probe ip protocol is IP4 or IP6",
+		})
+		probeDNSLookupTime = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_dns_lookup_time_seconds",
+			Help: "Returns the time taken for probe dns lookup in seconds",
+		})
+	)
+	registry.MustRegister(probeIPProtocol, probeDNSLookupTime)
+
+	if ip := net.ParseIP(host); ip != nil {
+		probeIPProtocol.Set(ipProtocol(ip))
+		return &net.IPAddr{IP: ip}, nil
+	}
+
+	start := time.Now()
+	defer func() { probeDNSLookupTime.Set(time.Since(start).Seconds()) }()
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("resolving target address failed: %w", err)
+	}
+
+	var fallback *net.IPAddr
+	for i := range addrs {
+		addr := &addrs[i]
+		isIP4 := addr.IP.To4() != nil
+		if isIP4 == (ipc.PreferredIPProtocol == "ip4") {
+			probeIPProtocol.Set(ipProtocol(addr.IP))
+			return addr, nil
+		}
+		if fallback == nil {
+			fallback = addr
+		}
+	}
+	if fallback == nil || !ipc.IPProtocolFallback {
+		return nil, fmt.Errorf("unable to find an %s address for %s", ipc.PreferredIPProtocol, host)
+	}
+	level.Debug(logger).Log("msg", "Falling back to the non-preferred IP protocol", "ip", fallback.String())
+	probeIPProtocol.Set(ipProtocol(fallback.IP))
+	return fallback, nil
+}
+
+func ipProtocol(ip net.IP) float64 {
+	if ip.To4() != nil {
+		return 4
+	}
+	return 6
+}
+
+// registerTLSMetrics registers the metrics of the TLS connection state to
+// registry.
+func registerTLSMetrics(state *tls.ConnectionState, registry *prometheus.Registry) {
+	probeSSLEarliestCertExpiry := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "probe_ssl_earliest_cert_expiry",
+		Help: "Returns earliest SSL cert expiry date",
+	})
+	registry.MustRegister(probeSSLEarliestCertExpiry)
+
+	var earliest time.Time
+	for _, cert := range state.PeerCertificates {
+		if earliest.IsZero() || cert.NotAfter.Before(earliest) {
+			earliest = cert.NotAfter
+		}
+	}
+	if !earliest.IsZero() {
+		probeSSLEarliestCertExpiry.Set(float64(earliest.Unix()))
+	}
+}
@@ -0,0 +1,58 @@
+package blackbox_exporter
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	config_util "github.com/prometheus/common/config"
+)
+
+func probeTCP(ctx context.Context, target string, module Module, registry *prometheus.Registry, logger log.Logger) bool {
+	cfg := module.TCP
+
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		level.Error(logger).Log("msg", "Error splitting target address and port", "err", err)
+		return false
+	}
+
+	ip, err := resolveTarget(ctx, host, cfg.IPProtocolConfig, registry, logger)
+	if err != nil {
+		level.Error(logger).Log("msg", "Error resolving address", "err", err)
+		return false
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(ip.String(), port))
+	if err != nil {
+		level.Error(logger).Log("msg", "Error dialing TCP", "err", err)
+		return false
+	}
+	defer conn.Close()
+
+	if !cfg.TLS {
+		return true
+	}
+
+	tlsConfig, err := config_util.NewTLSConfig(&cfg.TLSConfig)
+	if err != nil {
+		level.Error(logger).Log("msg", "Error creating TLS config", "err", err)
+		return false
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = host
+	}
+
+	tlsConn := tls.Client(conn, tlsConfig)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		level.Error(logger).Log("msg", "TLS handshake failed", "err", err)
+		return false
+	}
+	state := tlsConn.ConnectionState()
+	registerTLSMetrics(&state, registry)
+	return true
+}
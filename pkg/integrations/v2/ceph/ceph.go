@@ -0,0 +1,220 @@
+// Package ceph collects Ceph cluster metrics from the Prometheus module of
+// the Ceph manager daemons.
+package ceph
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	integrations_v2 "github.com/grafana/agent/pkg/integrations/v2"
+	"github.com/grafana/agent/pkg/integrations/v2/common"
+	"github.com/grafana/agent/pkg/integrations/v2/metricsutils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	config_util "github.com/prometheus/common/config"
+	"github.com/prometheus/common/expfmt"
+)
+
+// DefaultConfig holds the default settings for the ceph integration.
+var DefaultConfig = Config{
+	MgrEndpoints: []string{"http://localhost:9283"},
+	Timeout:      5 * time.Second,
+}
+
+// Config controls the ceph integration.
+type Config struct {
+	// MgrEndpoints are the addresses of the Prometheus module of the Ceph
+	// manager daemons. Only the active manager serves metrics, so endpoints
+	// are tried in order until one returns metrics.
+	MgrEndpoints []string              `yaml:"mgr_endpoints,omitempty"`
+	TLSConfig    config_util.TLSConfig `yaml:"tls_config,omitempty"`
+	Timeout      time.Duration         `yaml:"timeout,omitempty"`
+
+	Common common.MetricsConfig `yaml:",inline"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler for Config.
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultConfig
+
+	type plain Config
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	if len(c.MgrEndpoints) == 0 {
+		return fmt.Errorf("at least one mgr_endpoints address must be provided")
+	}
+	return nil
+}
+
+// ApplyDefaults applies the integration's default configuration.
+func (c *Config) ApplyDefaults(globals integrations_v2.Globals) error {
+	c.Common.ApplyDefaults(globals.SubsystemOpts.Metrics.Autoscrape)
+	if id, err := c.Identifier(globals); err == nil {
+		c.Common.InstanceKey = &id
+	}
+	return nil
+}
+
+// Identifier returns a string that identifies the integration.
+func (c *Config) Identifier(globals integrations_v2.Globals) (string, error) {
+	if c.Common.InstanceKey != nil {
+		return *c.Common.InstanceKey, nil
+	}
+	return c.InstanceKey(globals.AgentIdentifier)
+}
+
+// Name returns the name of the integration.
+func (c *Config) Name() string {
+	return "ceph"
+}
+
+// InstanceKey returns the hostname:port of the first manager endpoint.
+func (c *Config) InstanceKey(agentKey string) (string, error) {
+	if len(c.MgrEndpoints) == 0 {
+		return agentKey, nil
+	}
+	u, err := url.Parse(c.MgrEndpoints[0])
+	if err != nil {
+		return "", fmt.Errorf("could not parse url: %w", err)
+	}
+	return u.Host, nil
+}
+
+// NewIntegration creates a new ceph integration.
+func (c *Config) NewIntegration(l log.Logger, globals integrations_v2.Globals) (integrations_v2.Integration, error) {
+	g, err := newGatherer(l, c)
+	if err != nil {
+		return nil, err
+	}
+
+	h := promhttp.HandlerFor(g, promhttp.HandlerOpts{})
+	return metricsutils.NewMetricsHandlerIntegration(l, c, c.Common, globals, h)
+}
+
+func init() {
+	integrations_v2.Register(&Config{}, integrations_v2.TypeMultiplex)
+}
+
+// gatherer retrieves metrics from the active Ceph manager. The
+// ceph_integration_up metric reports whether the last retrieval succeeded.
+type gatherer struct {
+	log     log.Logger
+	client  *http.Client
+	urls    []string
+	timeout time.Duration
+}
+
+var _ prometheus.Gatherer = (*gatherer)(nil)
+
+func newGatherer(l log.Logger, c *Config) (*gatherer, error) {
+	urls := make([]string, 0, len(c.MgrEndpoints))
+	for _, endpoint := range c.MgrEndpoints {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse url: %w", err)
+		}
+		if u.Path == "" || u.Path == "/" {
+			u.Path = "/metrics"
+		}
+		urls = append(urls, u.String())
+	}
+
+	tlsConfig, err := config_util.NewTLSConfig(&c.TLSConfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tls_config: %w", err)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return &gatherer{
+		log:     l,
+		client:  &http.Client{Transport: transport},
+		urls:    urls,
+		timeout: c.Timeout,
+	}, nil
+}
+
+// Gather implements prometheus.Gatherer. Failing to reach an active manager
+// is reported through ceph_integration_up rather than as an error so the
+// scrape itself succeeds.
+func (g *gatherer) Gather() ([]*dto.MetricFamily, error) {
+	var (
+		families []*dto.MetricFamily
+		err      error
+	)
+	for _, u := range g.urls {
+		families, err = g.fetch(u)
+		if err == nil && len(families) > 0 {
+			break
+		}
+		if err == nil {
+			// Standby managers respond with an empty body by default.
+			err = fmt.Errorf("no metrics returned, the manager may be in standby")
+		}
+		level.Debug(g.log).Log("msg", "failed to retrieve ceph metrics from manager", "url", u, "err", err)
+	}
+	if err != nil {
+		level.Error(g.log).Log("msg", "failed to retrieve ceph metrics from any manager", "err", err)
+		families = nil
+	}
+
+	up := 1.0
+	if err != nil {
+		up = 0
+	}
+	families = append(families, &dto.MetricFamily{
+		Name: stringPtr("ceph_integration_up"),
+		Help: stringPtr("Whether the last retrieval of metrics from an active Ceph manager succeeded."),
+		Type: dto.MetricType_GAUGE.Enum(),
+		Metric: []*dto.Metric{{
+			Gauge: &dto.Gauge{Value: &up},
+		}},
+	})
+
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].GetName() < families[j].GetName()
+	})
+	return families, nil
+}
+
+func (g *gatherer) fetch(u string) ([]*dto.MetricFamily, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), g.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	var parser expfmt.TextParser
+	parsed, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("parsing metrics: %w", err)
+	}
+
+	families := make([]*dto.MetricFamily, 0, len(parsed))
+	for _, mf := range parsed {
+		families = append(families, mf)
+	}
+	return families, nil
+}
+
+func stringPtr(s string) *string { return &s }
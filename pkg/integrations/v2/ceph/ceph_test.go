@@ -0,0 +1,70 @@
+package ceph
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestGatherer(t *testing.T) {
+	// Standby managers return an empty response.
+	standby := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer standby.Close()
+
+	active := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/metrics", r.URL.Path)
+		_, _ = w.Write([]byte(`# HELP ceph_health_status Cluster health status
+# TYPE ceph_health_status untyped
+ceph_health_status 0.0
+`))
+	}))
+	defer active.Close()
+
+	g, err := newGatherer(log.NewNopLogger(), &Config{
+		MgrEndpoints: []string{standby.URL, active.URL},
+		Timeout:      time.Second,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, testutil.GatherAndCompare(g, strings.NewReader(`
+		# HELP ceph_health_status Cluster health status
+		# TYPE ceph_health_status untyped
+		ceph_health_status 0
+		# HELP ceph_integration_up Whether the last retrieval of metrics from an active Ceph manager succeeded.
+		# TYPE ceph_integration_up gauge
+		ceph_integration_up 1
+	`)))
+
+	// Without an active manager, only ceph_integration_up is reported.
+	g, err = newGatherer(log.NewNopLogger(), &Config{
+		MgrEndpoints: []string{standby.URL},
+		Timeout:      time.Second,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, testutil.GatherAndCompare(g, strings.NewReader(`
+		# HELP ceph_integration_up Whether the last retrieval of metrics from an active Ceph manager succeeded.
+		# TYPE ceph_integration_up gauge
+		ceph_integration_up 0
+	`)))
+}
+
+func TestConfig_UnmarshalYAML(t *testing.T) {
+	var cfg Config
+	require.NoError(t, yaml.Unmarshal([]byte(`timeout: 10s`), &cfg))
+	require.Equal(t, DefaultConfig.MgrEndpoints, cfg.MgrEndpoints)
+	require.Equal(t, 10*time.Second, cfg.Timeout)
+
+	key, err := cfg.InstanceKey("agent")
+	require.NoError(t, err)
+	require.Equal(t, "localhost:9283", key)
+
+	require.Error(t, yaml.Unmarshal([]byte(`mgr_endpoints: []`), &cfg))
+}
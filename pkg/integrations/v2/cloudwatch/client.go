@@ -0,0 +1,251 @@
+package cloudwatch
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi"
+)
+
+// maxQueriesPerRequest is the maximum number of queries of a GetMetricData
+// request.
+const maxQueriesPerRequest = 500
+
+// cloudwatchAPI is the subset of the CloudWatch API which is used.
+type cloudwatchAPI interface {
+	ListMetricsPagesWithContext(ctx aws.Context, input *cloudwatch.ListMetricsInput, fn func(*cloudwatch.ListMetricsOutput, bool) bool, opts ...request.Option) error
+	GetMetricDataPagesWithContext(ctx aws.Context, input *cloudwatch.GetMetricDataInput, fn func(*cloudwatch.GetMetricDataOutput, bool) bool, opts ...request.Option) error
+}
+
+// taggingAPI is the subset of the Resource Groups Tagging API which is used.
+type taggingAPI interface {
+	GetResourcesPagesWithContext(ctx aws.Context, input *resourcegroupstaggingapi.GetResourcesInput, fn func(*resourcegroupstaggingapi.GetResourcesOutput, bool) bool, opts ...request.Option) error
+}
+
+// regionClient collects metrics from the CloudWatch API of a single region.
+type regionClient struct {
+	region  string
+	cw      cloudwatchAPI
+	tagging taggingAPI
+}
+
+func newRegionClients(regions []string, roleARN string) ([]*regionClient, error) {
+	sess, err := session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AWS session: %w", err)
+	}
+
+	clients := make([]*regionClient, 0, len(regions))
+	for _, region := range regions {
+		cfg := aws.NewConfig().WithRegion(region)
+		if roleARN != "" {
+			cfg = cfg.WithCredentials(stscreds.NewCredentials(sess, roleARN))
+		}
+		clients = append(clients, &regionClient{
+			region:  region,
+			cw:      cloudwatch.New(sess, cfg),
+			tagging: resourcegroupstaggingapi.New(sess, cfg),
+		})
+	}
+	return clients, nil
+}
+
+// resource is an AWS resource discovered by its tags.
+type resource struct {
+	arn  string
+	tags map[string]string
+}
+
+// matches returns true if a dimension of m identifies r. Dimensions identify
+// resources by the end of their ARN, such as the instance ID of an EC2
+// instance or the name of an RDS database.
+func (r resource) matches(m *cloudwatch.Metric) bool {
+	for _, d := range m.Dimensions {
+		v := aws.StringValue(d.Value)
+		if v != "" && (strings.HasSuffix(r.arn, "/"+v) || strings.HasSuffix(r.arn, ":"+v)) {
+			return true
+		}
+	}
+	return false
+}
+
+// query is a statistic of a CloudWatch metric to collect.
+type query struct {
+	job      *JobConfig
+	metric   *cloudwatch.Metric
+	stat     string
+	resource *resource // nil if the job doesn't discover resources
+}
+
+// Poll collects the metrics selected by jobs over the period ending at end.
+func (c *regionClient) Poll(ctx context.Context, jobs []JobConfig, period time.Duration, end time.Time) ([]sample, error) {
+	var queries []query
+	for i := range jobs {
+		job := &jobs[i]
+		jq, err := c.jobQueries(ctx, job)
+		if err != nil {
+			return nil, fmt.Errorf("job %q: %w", job.Namespace, err)
+		}
+		queries = append(queries, jq...)
+	}
+
+	var samples []sample
+	for start := 0; start < len(queries); start += maxQueriesPerRequest {
+		batch := queries[start:]
+		if len(batch) > maxQueriesPerRequest {
+			batch = batch[:maxQueriesPerRequest]
+		}
+		bs, err := c.getMetricData(ctx, batch, period, end)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, bs...)
+	}
+	return samples, nil
+}
+
+// jobQueries lists the metrics of job and returns the queries collecting
+// their statistics.
+func (c *regionClient) jobQueries(ctx context.Context, job *JobConfig) ([]query, error) {
+	discover := len(job.SearchTags) > 0 || len(job.ResourceTypes) > 0
+
+	var resources []resource
+	if discover {
+		var err error
+		resources, err = c.discoverResources(ctx, job)
+		if err != nil {
+			return nil, fmt.Errorf("discovering resources failed: %w", err)
+		}
+		if len(resources) == 0 {
+			return nil, nil
+		}
+	}
+
+	var queries []query
+	for _, mc := range job.Metrics {
+		metrics, err := c.listMetrics(ctx, job.Namespace, mc.Name)
+		if err != nil {
+			return nil, fmt.Errorf("listing metric %q failed: %w", mc.Name, err)
+		}
+
+		for _, m := range metrics {
+			var res *resource
+			if discover {
+				if res = findResource(resources, m); res == nil {
+					continue
+				}
+			}
+			for _, stat := range mc.Statistics {
+				queries = append(queries, query{job: job, metric: m, stat: stat, resource: res})
+			}
+		}
+	}
+	return queries, nil
+}
+
+func findResource(resources []resource, m *cloudwatch.Metric) *resource {
+	for i := range resources {
+		if resources[i].matches(m) {
+			return &resources[i]
+		}
+	}
+	return nil
+}
+
+// discoverResources returns the resources matching the resource types and
+// search tags of job.
+func (c *regionClient) discoverResources(ctx context.Context, job *JobConfig) ([]resource, error) {
+	input := &resourcegroupstaggingapi.GetResourcesInput{}
+	if len(job.ResourceTypes) > 0 {
+		input.ResourceTypeFilters = aws.StringSlice(job.ResourceTypes)
+	}
+	for _, tag := range job.SearchTags {
+		// Values are regular expressions, which are matched below.
+		input.TagFilters = append(input.TagFilters, &resourcegroupstaggingapi.TagFilter{Key: aws.String(tag.Key)})
+	}
+
+	var res []resource
+	err := c.tagging.GetResourcesPagesWithContext(ctx, input, func(out *resourcegroupstaggingapi.GetResourcesOutput, _ bool) bool {
+	NextResource:
+		for _, mapping := range out.ResourceTagMappingList {
+			r := resource{arn: aws.StringValue(mapping.ResourceARN), tags: make(map[string]string, len(mapping.Tags))}
+			for _, t := range mapping.Tags {
+				r.tags[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+			}
+			for _, tag := range job.SearchTags {
+				v, ok := r.tags[tag.Key]
+				if !ok || !tag.re.MatchString(v) {
+					continue NextResource
+				}
+			}
+			res = append(res, r)
+		}
+		return true
+	})
+	return res, err
+}
+
+// listMetrics lists the recently active metrics name in namespace.
+func (c *regionClient) listMetrics(ctx context.Context, namespace, name string) ([]*cloudwatch.Metric, error) {
+	input := &cloudwatch.ListMetricsInput{
+		Namespace:  aws.String(namespace),
+		MetricName: aws.String(name),
+		// Metrics which haven't been published to recently have no
+		// statistics to collect.
+		RecentlyActive: aws.String(cloudwatch.RecentlyActivePt3h),
+	}
+
+	var res []*cloudwatch.Metric
+	err := c.cw.ListMetricsPagesWithContext(ctx, input, func(out *cloudwatch.ListMetricsOutput, _ bool) bool {
+		res = append(res, out.Metrics...)
+		return true
+	})
+	return res, err
+}
+
+// getMetricData collects the statistics of queries. Statistics without
+// datapoints in the period aren't returned.
+func (c *regionClient) getMetricData(ctx context.Context, queries []query, period time.Duration, end time.Time) ([]sample, error) {
+	input := &cloudwatch.GetMetricDataInput{
+		StartTime: aws.Time(end.Add(-period)),
+		EndTime:   aws.Time(end),
+		ScanBy:    aws.String(cloudwatch.ScanByTimestampDescending),
+	}
+	for i, q := range queries {
+		input.MetricDataQueries = append(input.MetricDataQueries, &cloudwatch.MetricDataQuery{
+			Id: aws.String(fmt.Sprintf("q%d", i)),
+			MetricStat: &cloudwatch.MetricStat{
+				Metric: q.metric,
+				Period: aws.Int64(int64(period.Seconds())),
+				Stat:   aws.String(q.stat),
+			},
+			ReturnData: aws.Bool(true),
+		})
+	}
+
+	var res []sample
+	err := c.cw.GetMetricDataPagesWithContext(ctx, input, func(out *cloudwatch.GetMetricDataOutput, _ bool) bool {
+		for _, r := range out.MetricDataResults {
+			var i int
+			if _, err := fmt.Sscanf(aws.StringValue(r.Id), "q%d", &i); err != nil || i < 0 || i >= len(queries) {
+				continue
+			}
+			// Values are sorted from the most recent one.
+			if len(r.Values) == 0 {
+				continue
+			}
+			res = append(res, newSample(c.region, queries[i], aws.Float64Value(r.Values[0])))
+		}
+		return true
+	})
+	return res, err
+}
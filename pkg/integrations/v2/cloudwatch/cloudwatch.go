@@ -0,0 +1,247 @@
+// Package cloudwatch collects metrics from AWS CloudWatch. Resources are
+// discovered by their tags, and the selected statistics of their metrics are
+// collected from multiple regions.
+package cloudwatch
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	integrations_v2 "github.com/grafana/agent/pkg/integrations/v2"
+	"github.com/grafana/agent/pkg/integrations/v2/common"
+	"github.com/grafana/agent/pkg/integrations/v2/metricsutils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Statistics which can be collected for a metric.
+var validStatistics = []string{"Average", "Sum", "Minimum", "Maximum", "SampleCount"}
+
+// DefaultConfig holds the default settings for the cloudwatch integration.
+var DefaultConfig = Config{
+	PollInterval: 5 * time.Minute,
+	Period:       5 * time.Minute,
+	Timeout:      2 * time.Minute,
+}
+
+// DefaultMetricConfig holds the default settings of a metric to collect.
+var DefaultMetricConfig = MetricConfig{
+	Statistics: []string{"Average"},
+}
+
+// Config controls the cloudwatch integration.
+type Config struct {
+	// Regions to collect metrics from.
+	Regions []string `yaml:"regions,omitempty"`
+	// RoleARN is the role assumed to access the AWS APIs. Credentials are
+	// otherwise read from the default credential chain.
+	RoleARN string `yaml:"role_arn,omitempty"`
+
+	// PollInterval is how often CloudWatch is polled. CloudWatch API requests
+	// are billed, so CloudWatch is polled in the background and scrapes are
+	// served the results of the last poll.
+	PollInterval time.Duration `yaml:"poll_interval,omitempty"`
+	// Period over which statistics are computed.
+	Period time.Duration `yaml:"period,omitempty"`
+	// Delay shifts the period back in time, to account for metrics which are
+	// published to CloudWatch late.
+	Delay time.Duration `yaml:"delay,omitempty"`
+	// Timeout is the maximum time polling a single region may take.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+
+	// Jobs select the metrics to collect.
+	Jobs []JobConfig `yaml:"jobs,omitempty"`
+
+	Common common.MetricsConfig `yaml:",inline"`
+}
+
+// JobConfig selects metrics to collect from a CloudWatch namespace.
+type JobConfig struct {
+	// Namespace of the metrics, such as AWS/EC2.
+	Namespace string `yaml:"namespace"`
+	// ResourceTypes restricts discovered resources to the given types, such as
+	// ec2:instance.
+	ResourceTypes []string `yaml:"resource_types,omitempty"`
+	// SearchTags restricts metrics to the resources having all of the tags.
+	// The tags are added to the labels of the metrics.
+	SearchTags []TagFilter `yaml:"search_tags,omitempty"`
+	// Metrics to collect.
+	Metrics []MetricConfig `yaml:"metrics"`
+}
+
+// TagFilter matches resources by tag.
+type TagFilter struct {
+	Key string `yaml:"key"`
+	// Value is a regular expression matching the whole value of the tag. All
+	// values match when empty.
+	Value string `yaml:"value,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// MetricConfig selects a metric and its statistics.
+type MetricConfig struct {
+	Name       string   `yaml:"name"`
+	Statistics []string `yaml:"statistics,omitempty"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler for Config.
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultConfig
+
+	type plain Config
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	if len(c.Regions) == 0 {
+		return fmt.Errorf("at least one region must be provided")
+	}
+	if len(c.Jobs) == 0 {
+		return fmt.Errorf("at least one jobs entry must be provided")
+	}
+	if c.PollInterval <= 0 {
+		return fmt.Errorf("poll_interval must be greater than 0")
+	}
+	if c.Period < time.Minute || c.Period%time.Minute != 0 {
+		return fmt.Errorf("period must be a multiple of 1m")
+	}
+	if c.Delay < 0 {
+		return fmt.Errorf("delay must not be negative")
+	}
+	return nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler for JobConfig.
+func (c *JobConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain JobConfig
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	if c.Namespace == "" {
+		return fmt.Errorf("job namespace must be set")
+	}
+	if len(c.Metrics) == 0 {
+		return fmt.Errorf("job %q: at least one metrics entry must be provided", c.Namespace)
+	}
+	return nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler for TagFilter.
+func (f *TagFilter) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain TagFilter
+	if err := unmarshal((*plain)(f)); err != nil {
+		return err
+	}
+
+	if f.Key == "" {
+		return fmt.Errorf("search tag key must be set")
+	}
+	re, err := regexp.Compile("^(?:" + f.Value + ")$")
+	if err != nil {
+		return fmt.Errorf("search tag %q: invalid value: %w", f.Key, err)
+	}
+	f.re = re
+	return nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler for MetricConfig.
+func (c *MetricConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultMetricConfig
+
+	type plain MetricConfig
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	if c.Name == "" {
+		return fmt.Errorf("metric name must be set")
+	}
+	for _, stat := range c.Statistics {
+		if !isValidStatistic(stat) {
+			return fmt.Errorf("metric %q: invalid statistic %q, must be one of %s", c.Name, stat, strings.Join(validStatistics, ", "))
+		}
+	}
+	return nil
+}
+
+func isValidStatistic(stat string) bool {
+	for _, s := range validStatistics {
+		if s == stat {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyDefaults applies the integration's default configuration.
+func (c *Config) ApplyDefaults(globals integrations_v2.Globals) error {
+	c.Common.ApplyDefaults(globals.SubsystemOpts.Metrics.Autoscrape)
+	if id, err := c.Identifier(globals); err == nil {
+		c.Common.InstanceKey = &id
+	}
+	return nil
+}
+
+// Identifier returns a string that identifies the integration.
+func (c *Config) Identifier(globals integrations_v2.Globals) (string, error) {
+	if c.Common.InstanceKey != nil {
+		return *c.Common.InstanceKey, nil
+	}
+	return c.InstanceKey(globals.AgentIdentifier)
+}
+
+// Name returns the name of the integration.
+func (c *Config) Name() string {
+	return "cloudwatch"
+}
+
+// InstanceKey returns the regions metrics are collected from.
+func (c *Config) InstanceKey(agentKey string) (string, error) {
+	if len(c.Regions) == 0 {
+		return agentKey, nil
+	}
+	return strings.Join(c.Regions, ","), nil
+}
+
+// NewIntegration creates a new cloudwatch integration.
+func (c *Config) NewIntegration(l log.Logger, globals integrations_v2.Globals) (integrations_v2.Integration, error) {
+	clients, err := newRegionClients(c.Regions, c.RoleARN)
+	if err != nil {
+		return nil, err
+	}
+	col := newCollector(l, c, clients)
+
+	reg := prometheus.NewRegistry()
+	if err := reg.Register(col); err != nil {
+		return nil, err
+	}
+
+	h := promhttp.HandlerFor(reg, promhttp.HandlerOpts{})
+	mi, err := metricsutils.NewMetricsHandlerIntegration(l, c, c.Common, globals, h)
+	if err != nil {
+		return nil, err
+	}
+	return &integration{MetricsIntegration: mi, collector: col}, nil
+}
+
+func init() {
+	integrations_v2.Register(&Config{}, integrations_v2.TypeMultiplex)
+}
+
+// integration polls CloudWatch in the background for as long as it runs.
+type integration struct {
+	integrations_v2.MetricsIntegration
+	collector *collector
+}
+
+// RunIntegration implements integrations_v2.Integration.
+func (i *integration) RunIntegration(ctx context.Context) error {
+	i.collector.Run(ctx)
+	return nil
+}
@@ -0,0 +1,237 @@
+package cloudwatch
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	upDesc       = prometheus.NewDesc("cloudwatch_up", "Whether the last poll of CloudWatch in the region succeeded.", []string{"region"}, nil)
+	lastPollDesc = prometheus.NewDesc("cloudwatch_last_poll_timestamp_seconds", "Time of the last poll of CloudWatch in the region, in seconds since the Unix epoch.", []string{"region"}, nil)
+)
+
+// sample is the value of a statistic of a CloudWatch metric.
+type sample struct {
+	name        string
+	help        string
+	labelNames  []string
+	labelValues []string
+	value       float64
+}
+
+// newSample creates the sample of a statistic collected by q. Samples are
+// named aws_<namespace>_<metric>_<statistic>, and labeled with the region,
+// the dimensions of the metric, and the search tags of the resource.
+func newSample(region string, q query, value float64) sample {
+	namespace := strings.TrimPrefix(aws.StringValue(q.metric.Namespace), "AWS/")
+	metric := aws.StringValue(q.metric.MetricName)
+
+	s := sample{
+		name:        "aws_" + toSnakeCase(namespace) + "_" + toSnakeCase(metric) + "_" + toSnakeCase(q.stat),
+		help:        fmt.Sprintf("%s statistic of the CloudWatch metric %s in namespace %s.", q.stat, metric, aws.StringValue(q.metric.Namespace)),
+		labelNames:  []string{"region"},
+		labelValues: []string{region},
+		value:       value,
+	}
+	for _, d := range q.metric.Dimensions {
+		s.labelNames = append(s.labelNames, "dimension_"+toSnakeCase(aws.StringValue(d.Name)))
+		s.labelValues = append(s.labelValues, aws.StringValue(d.Value))
+	}
+	if q.resource != nil {
+		for _, tag := range q.job.SearchTags {
+			s.labelNames = append(s.labelNames, "tag_"+toSnakeCase(tag.Key))
+			s.labelValues = append(s.labelValues, q.resource.tags[tag.Key])
+		}
+	}
+	return s
+}
+
+// key identifies the series of s.
+func (s sample) key() string {
+	return s.name + "\xff" + strings.Join(s.labelNames, "\xff") + "\xff" + strings.Join(s.labelValues, "\xff")
+}
+
+// toSnakeCase converts CloudWatch names, such as CPUUtilization, to snake
+// case, such as cpu_utilization. Characters which aren't letters or digits
+// are replaced with underscores.
+func toSnakeCase(s string) string {
+	var (
+		b     strings.Builder
+		runes = []rune(s)
+	)
+	writeUnderscore := func() {
+		if b.Len() > 0 && !strings.HasSuffix(b.String(), "_") {
+			b.WriteByte('_')
+		}
+	}
+
+	for i, r := range runes {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			writeUnderscore()
+			continue
+		}
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || (unicode.IsUpper(prev) && nextLower) {
+				writeUnderscore()
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return strings.TrimSuffix(b.String(), "_")
+}
+
+// collector polls CloudWatch in the background and exposes the results of
+// the last poll of each region.
+type collector struct {
+	log          log.Logger
+	clients      []*regionClient
+	jobs         []JobConfig
+	pollInterval time.Duration
+	period       time.Duration
+	delay        time.Duration
+	timeout      time.Duration
+
+	mut     sync.RWMutex
+	results map[string]pollResult // Last poll result by region
+}
+
+// pollResult is the outcome of polling a region.
+type pollResult struct {
+	time    time.Time
+	ok      bool
+	samples []sample
+}
+
+var _ prometheus.Collector = (*collector)(nil)
+
+func newCollector(l log.Logger, c *Config, clients []*regionClient) *collector {
+	return &collector{
+		log:          l,
+		clients:      clients,
+		jobs:         c.Jobs,
+		pollInterval: c.PollInterval,
+		period:       c.Period,
+		delay:        c.Delay,
+		timeout:      c.Timeout,
+		results:      make(map[string]pollResult, len(clients)),
+	}
+}
+
+// Run polls all regions immediately and then once every poll interval until
+// ctx is canceled.
+func (c *collector) Run(ctx context.Context) {
+	t := time.NewTicker(c.pollInterval)
+	defer t.Stop()
+
+	for {
+		c.poll(ctx, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// poll polls all regions concurrently and waits for all polls to finish.
+func (c *collector) poll(ctx context.Context, now time.Time) {
+	var wg sync.WaitGroup
+	for _, cli := range c.clients {
+		wg.Add(1)
+		go func(cli *regionClient) {
+			defer wg.Done()
+			c.pollRegion(ctx, cli, now)
+		}(cli)
+	}
+	wg.Wait()
+}
+
+func (c *collector) pollRegion(ctx context.Context, cli *regionClient, now time.Time) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	samples, err := cli.Poll(ctx, c.jobs, c.period, now.Add(-c.delay))
+	if err != nil {
+		if ctx.Err() != nil && ctx.Err() != context.DeadlineExceeded {
+			// The integration is shutting down.
+			return
+		}
+		level.Error(c.log).Log("msg", "failed to poll CloudWatch", "region", cli.region, "err", err)
+	}
+
+	res := pollResult{time: now, ok: err == nil}
+	if err == nil {
+		res.samples = dedupSamples(samples)
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.results[cli.region] = res
+}
+
+// dedupSamples removes samples of series collected by multiple jobs.
+func dedupSamples(samples []sample) []sample {
+	seen := make(map[string]struct{}, len(samples))
+	res := samples[:0]
+	for _, s := range samples {
+		key := s.key()
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		res = append(res, s)
+	}
+	return res
+}
+
+// Describe implements prometheus.Collector. The collector is unchecked, as
+// the metrics collected from CloudWatch aren't known in advance.
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {}
+
+// Collect implements prometheus.Collector. Regions which haven't been polled
+// yet aren't reported, and regions whose last poll failed only report
+// cloudwatch_up.
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+
+	regions := make([]string, 0, len(c.results))
+	for region := range c.results {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+
+	for _, region := range regions {
+		res := c.results[region]
+
+		up := 0.0
+		if res.ok {
+			up = 1
+		}
+		ch <- prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, up, region)
+		ch <- prometheus.MustNewConstMetric(lastPollDesc, prometheus.GaugeValue, float64(res.time.UnixNano())/1e9, region)
+
+		for _, s := range res.samples {
+			desc := prometheus.NewDesc(s.name, s.help, s.labelNames, nil)
+			m, err := prometheus.NewConstMetric(desc, prometheus.GaugeValue, s.value, s.labelValues...)
+			if err != nil {
+				level.Warn(c.log).Log("msg", "invalid CloudWatch metric", "metric", s.name, "err", err)
+				continue
+			}
+			ch <- m
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package cloudwatch
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi"
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+const testConfig = `
+regions: [us-east-1]
+jobs:
+- namespace: AWS/EC2
+  resource_types: [ec2:instance]
+  search_tags:
+  - key: Environment
+    value: prod|staging
+  metrics:
+  - name: CPUUtilization
+    statistics: [Average, Maximum]
+- namespace: AWS/S3
+  metrics:
+  - name: BucketSizeBytes
+`
+
+// fakeCloudWatch serves the metrics of two EC2 instances and an S3 bucket.
+// Statistics are the sum of the ID of the query and the length of the name of
+// the statistic.
+type fakeCloudWatch struct {
+	queries []*cloudwatch.MetricDataQuery
+}
+
+var testMetrics = map[string][]*cloudwatch.Metric{
+	"CPUUtilization": {
+		newMetric("AWS/EC2", "CPUUtilization", "InstanceId", "i-prod"),
+		newMetric("AWS/EC2", "CPUUtilization", "InstanceId", "i-dev"),
+	},
+	"BucketSizeBytes": {
+		newMetric("AWS/S3", "BucketSizeBytes", "BucketName", "logs", "StorageType", "StandardStorage"),
+	},
+}
+
+func newMetric(namespace, name string, dimensions ...string) *cloudwatch.Metric {
+	m := &cloudwatch.Metric{Namespace: aws.String(namespace), MetricName: aws.String(name)}
+	for i := 0; i < len(dimensions); i += 2 {
+		m.Dimensions = append(m.Dimensions, &cloudwatch.Dimension{Name: aws.String(dimensions[i]), Value: aws.String(dimensions[i+1])})
+	}
+	return m
+}
+
+func (f *fakeCloudWatch) ListMetricsPagesWithContext(_ aws.Context, input *cloudwatch.ListMetricsInput, fn func(*cloudwatch.ListMetricsOutput, bool) bool, _ ...request.Option) error {
+	fn(&cloudwatch.ListMetricsOutput{Metrics: testMetrics[aws.StringValue(input.MetricName)]}, true)
+	return nil
+}
+
+func (f *fakeCloudWatch) GetMetricDataPagesWithContext(_ aws.Context, input *cloudwatch.GetMetricDataInput, fn func(*cloudwatch.GetMetricDataOutput, bool) bool, _ ...request.Option) error {
+	f.queries = append(f.queries, input.MetricDataQueries...)
+
+	var out cloudwatch.GetMetricDataOutput
+	for _, q := range input.MetricDataQueries {
+		value := float64(len(aws.StringValue(q.Id)) + len(aws.StringValue(q.MetricStat.Stat)))
+		out.MetricDataResults = append(out.MetricDataResults, &cloudwatch.MetricDataResult{
+			Id:     q.Id,
+			Values: aws.Float64Slice([]float64{value, 0}),
+		})
+	}
+	fn(&out, true)
+	return nil
+}
+
+type fakeTagging struct{}
+
+func (fakeTagging) GetResourcesPagesWithContext(_ aws.Context, input *resourcegroupstaggingapi.GetResourcesInput, fn func(*resourcegroupstaggingapi.GetResourcesOutput, bool) bool, _ ...request.Option) error {
+	newMapping := func(arn, env string) *resourcegroupstaggingapi.ResourceTagMapping {
+		return &resourcegroupstaggingapi.ResourceTagMapping{
+			ResourceARN: aws.String(arn),
+			Tags:        []*resourcegroupstaggingapi.Tag{{Key: aws.String("Environment"), Value: aws.String(env)}},
+		}
+	}
+	fn(&resourcegroupstaggingapi.GetResourcesOutput{ResourceTagMappingList: []*resourcegroupstaggingapi.ResourceTagMapping{
+		newMapping("arn:aws:ec2:us-east-1:123456789012:instance/i-prod", "prod"),
+		newMapping("arn:aws:ec2:us-east-1:123456789012:instance/i-dev", "dev"),
+	}}, true)
+	return nil
+}
+
+func TestCollector(t *testing.T) {
+	var cfg Config
+	require.NoError(t, yaml.UnmarshalStrict([]byte(testConfig), &cfg))
+
+	cw := &fakeCloudWatch{}
+	col := newCollector(log.NewNopLogger(), &cfg, []*regionClient{{region: "us-east-1", cw: cw, tagging: fakeTagging{}}})
+
+	now := time.Unix(1650000000, 0)
+	col.poll(context.Background(), now)
+
+	// Only the production instance matches the search tags.
+	require.Len(t, cw.queries, 3)
+	require.Equal(t, int64(300), aws.Int64Value(cw.queries[0].MetricStat.Period))
+
+	expect := `
+# HELP aws_ec2_cpu_utilization_average Average statistic of the CloudWatch metric CPUUtilization in namespace AWS/EC2.
+# TYPE aws_ec2_cpu_utilization_average gauge
+aws_ec2_cpu_utilization_average{dimension_instance_id="i-prod",region="us-east-1",tag_environment="prod"} 9
+# HELP aws_ec2_cpu_utilization_maximum Maximum statistic of the CloudWatch metric CPUUtilization in namespace AWS/EC2.
+# TYPE aws_ec2_cpu_utilization_maximum gauge
+aws_ec2_cpu_utilization_maximum{dimension_instance_id="i-prod",region="us-east-1",tag_environment="prod"} 9
+# HELP aws_s3_bucket_size_bytes_average Average statistic of the CloudWatch metric BucketSizeBytes in namespace AWS/S3.
+# TYPE aws_s3_bucket_size_bytes_average gauge
+aws_s3_bucket_size_bytes_average{dimension_bucket_name="logs",dimension_storage_type="StandardStorage",region="us-east-1"} 9
+# HELP cloudwatch_up Whether the last poll of CloudWatch in the region succeeded.
+# TYPE cloudwatch_up gauge
+cloudwatch_up{region="us-east-1"} 1
+`
+	require.NoError(t, testutil.CollectAndCompare(col, strings.NewReader(expect),
+		"aws_ec2_cpu_utilization_average", "aws_ec2_cpu_utilization_maximum", "aws_s3_bucket_size_bytes_average", "cloudwatch_up"))
+}
+
+type failingTagging struct{}
+
+func (failingTagging) GetResourcesPagesWithContext(aws.Context, *resourcegroupstaggingapi.GetResourcesInput, func(*resourcegroupstaggingapi.GetResourcesOutput, bool) bool, ...request.Option) error {
+	return errors.New("access denied")
+}
+
+func TestCollector_PollFailed(t *testing.T) {
+	var cfg Config
+	require.NoError(t, yaml.UnmarshalStrict([]byte(testConfig), &cfg))
+
+	col := newCollector(log.NewNopLogger(), &cfg, []*regionClient{{region: "us-east-1", cw: &fakeCloudWatch{}, tagging: failingTagging{}}})
+	col.poll(context.Background(), time.Now())
+
+	expect := `
+# HELP cloudwatch_up Whether the last poll of CloudWatch in the region succeeded.
+# TYPE cloudwatch_up gauge
+cloudwatch_up{region="us-east-1"} 0
+`
+	require.NoError(t, testutil.CollectAndCompare(col, strings.NewReader(expect), "cloudwatch_up", "aws_s3_bucket_size_bytes_average"))
+}
+
+func TestConfig_Unmarshal(t *testing.T) {
+	var cfg Config
+	require.NoError(t, yaml.UnmarshalStrict([]byte(testConfig), &cfg))
+	require.Equal(t, []string{"Average"}, cfg.Jobs[1].Metrics[0].Statistics)
+	require.True(t, cfg.Jobs[0].SearchTags[0].re.MatchString("staging"))
+	require.False(t, cfg.Jobs[0].SearchTags[0].re.MatchString("prod-old"))
+
+	tt := []struct {
+		name        string
+		in          string
+		expectError string
+	}{
+		{
+			name:        "no regions",
+			in:          "jobs: [{namespace: AWS/EC2, metrics: [{name: CPUUtilization}]}]",
+			expectError: "at least one region must be provided",
+		},
+		{
+			name:        "invalid period",
+			in:          "regions: [us-east-1]\nperiod: 90s\njobs: [{namespace: AWS/EC2, metrics: [{name: CPUUtilization}]}]",
+			expectError: "period must be a multiple of 1m",
+		},
+		{
+			name:        "invalid statistic",
+			in:          "regions: [us-east-1]\njobs: [{namespace: AWS/EC2, metrics: [{name: CPUUtilization, statistics: [p99]}]}]",
+			expectError: `metric "CPUUtilization": invalid statistic "p99", must be one of Average, Sum, Minimum, Maximum, SampleCount`,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var cfg Config
+			require.EqualError(t, yaml.UnmarshalStrict([]byte(tc.in), &cfg), tc.expectError)
+		})
+	}
+}
+
+func Test_toSnakeCase(t *testing.T) {
+	tt := map[string]string{
+		"CPUUtilization":              "cpu_utilization",
+		"NetworkIn":                   "network_in",
+		"EC2":                         "ec2",
+		"ApplicationELB":              "application_elb",
+		"HTTPCode_Target_5XX_Count":   "http_code_target_5xx_count",
+		"aws:cloudformation:stack-id": "aws_cloudformation_stack_id",
+		"SampleCount":                 "sample_count",
+	}
+	for in, expect := range tt {
+		require.Equal(t, expect, toSnakeCase(in), in)
+	}
+}
@@ -72,6 +72,10 @@ type controlledIntegration struct {
 	i       Integration
 	c       Config // Config that generated i. Used for changing to see if a config changed.
 	running atomic.Bool
+
+	// stale is set when i must be updated even if its config didn't change,
+	// such as when secrets referenced by the config changed.
+	stale bool
 }
 
 func (ci *controlledIntegration) Running() bool {
@@ -150,7 +154,7 @@ NextConfig:
 			}
 
 			// If the configs haven't changed, then we don't need to do anything.
-			if CompareConfigs(ci.c, ic) {
+			if !ci.stale && CompareConfigs(ci.c, ic) {
 				integrations = append(integrations, ci)
 				continue NextConfig
 			}
@@ -164,6 +168,7 @@ NextConfig:
 				} else {
 					// Update succeeded; re-use the running one and go to the next
 					// integration to process.
+					ci.stale = false
 					integrations = append(integrations, ci)
 					continue NextConfig
 				}
@@ -196,6 +201,49 @@ NextConfig:
 	return nil
 }
 
+// Invalidate marks the integrations with the given IDs as stale, so that
+// they're updated by the next call to UpdateController even if their configs
+// compare as equal. Changes to secrets aren't visible when comparing configs,
+// as secrets are masked when marshaled.
+func (c *controller) Invalidate(ids ...integrationID) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	for _, ci := range c.integrations {
+		for _, id := range ids {
+			if ci.id == id {
+				ci.stale = true
+			}
+		}
+	}
+}
+
+// Integrations returns the integrations managed by the controller.
+func (c *controller) Integrations() []integrationStatus {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	res := make([]integrationStatus, 0, len(c.integrations))
+	for _, ci := range c.integrations {
+		res = append(res, integrationStatus{id: ci.id, running: ci.Running()})
+	}
+	return res
+}
+
+// Integration returns the running integration identified by name and
+// identifier.
+func (c *controller) Integration(name, identifier string) (Integration, bool) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	for _, ci := range c.integrations {
+		if ci.id.Name == name && ci.id.Identifier == identifier && ci.Running() {
+			return ci.i, true
+		}
+	}
+	return nil, false
+}
+
 // Handler returns an HTTP handler for the controller and its integrations.
 // Handler will pass through requests to other running integrations. Handler
 // always returns an http.Handler regardless of error.
@@ -0,0 +1,297 @@
+package integrations
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/grafana/agent/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/prometheus/scrape"
+)
+
+// IntegrationsHealthEndpoint is the API endpoint where the health of
+// integrations is exposed.
+const IntegrationsHealthEndpoint = "/-/integrations"
+
+// Health states of integrations.
+const (
+	// HealthUnknown is the health of integrations which haven't been scraped
+	// by autoscrape yet, or which aren't autoscraped.
+	HealthUnknown = "unknown"
+	// HealthUp is the health of integrations whose last scrapes succeeded.
+	HealthUp = "up"
+	// HealthDown is the health of integrations whose last scrape of a target
+	// failed.
+	HealthDown = "down"
+	// HealthStale is the health of integrations which haven't been scraped
+	// for more than twice their scrape interval.
+	HealthStale = "stale"
+)
+
+var (
+	integrationScrapeUp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "agent_integration_scrape_up",
+		Help: "1 if the last autoscrape of all the targets of an integration succeeded and isn't stale, 0 otherwise.",
+	}, []string{"integration_name", "instance"})
+	integrationLastScrape = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "agent_integration_last_scrape_timestamp_seconds",
+		Help: "Timestamp of the last autoscrape of an integration.",
+	}, []string{"integration_name", "instance"})
+	integrationLastSuccess = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "agent_integration_last_successful_scrape_timestamp_seconds",
+		Help: "Timestamp of the last successful autoscrape of an integration.",
+	}, []string{"integration_name", "instance"})
+	integrationConsecutiveFailures = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "agent_integration_consecutive_scrape_failures",
+		Help: "Number of consecutive failed autoscrapes of a target of an integration.",
+	}, []string{"integration_name", "instance"})
+)
+
+// healthCheckInterval is how often the health of integrations is updated.
+var healthCheckInterval = 5 * time.Second
+
+// IntegrationHealth is the health of an integration, derived from the
+// autoscrapes of its targets.
+type IntegrationHealth struct {
+	Name     string `json:"name"`
+	Instance string `json:"instance"`
+	// Running is false if the integration exited.
+	Running bool   `json:"running"`
+	Health  string `json:"health"`
+
+	LastScrape  *time.Time `json:"last_scrape,omitempty"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	// ConsecutiveFailures is the highest number of consecutive failed scrapes
+	// of the targets of the integration.
+	ConsecutiveFailures int `json:"consecutive_failures"`
+	// LastError is the error of the last failed scrape, if the last scrape of
+	// a target failed.
+	LastError string `json:"last_error,omitempty"`
+}
+
+// integrationStatus is an integration managed by the controller.
+type integrationStatus struct {
+	id      integrationID
+	running bool
+}
+
+// targetHealth tracks the scrapes of a target of an integration.
+type targetHealth struct {
+	lastScrape  time.Time
+	lastSuccess time.Time
+	failures    int
+	lastError   string
+	interval    time.Duration
+}
+
+// healthTracker derives the health of integrations from the autoscrapes of
+// their targets.
+type healthTracker struct {
+	mut     sync.RWMutex
+	targets map[integrationID]map[string]*targetHealth // Keyed by target URL
+	health  []IntegrationHealth
+}
+
+func newHealthTracker() *healthTracker {
+	return &healthTracker{targets: make(map[integrationID]map[string]*targetHealth)}
+}
+
+// Run updates the health of integrations until ctx is canceled.
+func (t *healthTracker) Run(ctx context.Context, integrations func() []integrationStatus, targets func() map[string]metrics.TargetSet) {
+	tick := time.NewTicker(healthCheckInterval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			t.update(time.Now(), nil, nil)
+			return
+		case now := <-tick.C:
+			t.update(now, integrations(), targets())
+		}
+	}
+}
+
+// Health returns the health of integrations, sorted by name and instance.
+func (t *healthTracker) Health() []IntegrationHealth {
+	t.mut.RLock()
+	defer t.mut.RUnlock()
+	return append([]IntegrationHealth{}, t.health...)
+}
+
+// update records the scrapes of targets and updates the health of
+// integrations.
+func (t *healthTracker) update(now time.Time, integrations []integrationStatus, targets map[string]metrics.TargetSet) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	seen := make(map[integrationID]map[string]*targetHealth, len(t.targets))
+	for _, set := range targets {
+		for _, group := range set {
+			for _, tgt := range group {
+				id, ok := targetIntegrationID(tgt)
+				if !ok {
+					continue
+				}
+				if seen[id] == nil {
+					seen[id] = make(map[string]*targetHealth)
+				}
+
+				url := tgt.URL().String()
+				th, ok := t.targets[id][url]
+				if !ok {
+					th = &targetHealth{}
+				}
+				th.observe(tgt)
+				seen[id][url] = th
+			}
+		}
+	}
+
+	health := make([]IntegrationHealth, 0, len(integrations))
+	for _, status := range integrations {
+		health = append(health, integrationHealth(now, status, seen[status.id]))
+	}
+	sort.Slice(health, func(i, j int) bool {
+		if health[i].Name != health[j].Name {
+			return health[i].Name < health[j].Name
+		}
+		return health[i].Instance < health[j].Instance
+	})
+
+	// Remove the metrics of integrations which went away.
+	for _, h := range t.health {
+		if !containsHealth(health, h.Name, h.Instance) {
+			deleteHealthMetrics(h.Name, h.Instance)
+		}
+	}
+	for _, h := range health {
+		setHealthMetrics(h)
+	}
+
+	t.targets, t.health = seen, health
+}
+
+// observe records the last scrape of tgt, if it is new.
+func (th *targetHealth) observe(tgt *scrape.Target) {
+	if interval, err := time.ParseDuration(tgt.GetValue("__scrape_interval__")); err == nil {
+		th.interval = interval
+	}
+
+	last := tgt.LastScrape()
+	if !last.After(th.lastScrape) {
+		return
+	}
+	th.lastScrape = last
+
+	if err := tgt.LastError(); err != nil {
+		th.failures++
+		th.lastError = err.Error()
+	} else {
+		th.failures = 0
+		th.lastSuccess = last
+		th.lastError = ""
+	}
+}
+
+func integrationHealth(now time.Time, status integrationStatus, targets map[string]*targetHealth) IntegrationHealth {
+	h := IntegrationHealth{
+		Name:     status.id.Name,
+		Instance: status.id.Identifier,
+		Running:  status.running,
+		Health:   HealthUnknown,
+	}
+
+	var (
+		scraped, down, stale bool
+		lastScrape, lastOK   time.Time
+	)
+	for _, th := range targets {
+		if th.lastScrape.IsZero() {
+			continue
+		}
+		scraped = true
+
+		if th.lastScrape.After(lastScrape) {
+			lastScrape = th.lastScrape
+		}
+		if th.lastSuccess.After(lastOK) {
+			lastOK = th.lastSuccess
+		}
+		if th.failures > h.ConsecutiveFailures {
+			h.ConsecutiveFailures = th.failures
+		}
+		if th.lastError != "" {
+			down = true
+			h.LastError = th.lastError
+		}
+		if th.interval > 0 && now.Sub(th.lastScrape) > 2*th.interval {
+			stale = true
+		}
+	}
+
+	switch {
+	case !scraped:
+		return h
+	case down:
+		h.Health = HealthDown
+	case stale:
+		h.Health = HealthStale
+	default:
+		h.Health = HealthUp
+	}
+	h.LastScrape = &lastScrape
+	if !lastOK.IsZero() {
+		h.LastSuccess = &lastOK
+	}
+	return h
+}
+
+// targetIntegrationID returns the integration tgt was discovered for.
+func targetIntegrationID(tgt *scrape.Target) (integrationID, bool) {
+	lbls := tgt.DiscoveredLabels()
+	id := integrationID{
+		Name:       lbls.Get("__meta_agent_integration_name"),
+		Identifier: lbls.Get("__meta_agent_integration_instance"),
+	}
+	return id, id.Name != "" && id.Identifier != ""
+}
+
+func containsHealth(health []IntegrationHealth, name, instance string) bool {
+	for _, h := range health {
+		if h.Name == name && h.Instance == instance {
+			return true
+		}
+	}
+	return false
+}
+
+func setHealthMetrics(h IntegrationHealth) {
+	if h.Health == HealthUnknown {
+		deleteHealthMetrics(h.Name, h.Instance)
+		return
+	}
+
+	up := 0.0
+	if h.Health == HealthUp {
+		up = 1
+	}
+	integrationScrapeUp.WithLabelValues(h.Name, h.Instance).Set(up)
+	integrationConsecutiveFailures.WithLabelValues(h.Name, h.Instance).Set(float64(h.ConsecutiveFailures))
+	if h.LastScrape != nil {
+		integrationLastScrape.WithLabelValues(h.Name, h.Instance).Set(float64(h.LastScrape.Unix()))
+	}
+	if h.LastSuccess != nil {
+		integrationLastSuccess.WithLabelValues(h.Name, h.Instance).Set(float64(h.LastSuccess.Unix()))
+	}
+}
+
+func deleteHealthMetrics(name, instance string) {
+	integrationScrapeUp.DeleteLabelValues(name, instance)
+	integrationLastScrape.DeleteLabelValues(name, instance)
+	integrationLastSuccess.DeleteLabelValues(name, instance)
+	integrationConsecutiveFailures.DeleteLabelValues(name, instance)
+}
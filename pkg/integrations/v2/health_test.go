@@ -0,0 +1,92 @@
+package integrations
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/scrape"
+	"github.com/stretchr/testify/require"
+)
+
+func newIntegrationTarget(id integrationID, path string) *scrape.Target {
+	return scrape.NewTarget(
+		labels.FromMap(map[string]string{
+			"__scheme__":          "http",
+			"__address__":         "localhost:12345",
+			"__metrics_path__":    path,
+			"__scrape_interval__": "15s",
+		}),
+		labels.FromMap(map[string]string{
+			"__meta_agent_integration_name":     id.Name,
+			"__meta_agent_integration_instance": id.Identifier,
+		}),
+		nil,
+	)
+}
+
+func Test_healthTracker(t *testing.T) {
+	var (
+		scraped   = integrationID{Name: "health", Identifier: "scraped"}
+		unscraped = integrationID{Name: "health", Identifier: "unscraped"}
+
+		metricsTarget = newIntegrationTarget(scraped, "/integrations/health/scraped/metrics")
+		otherTarget   = newIntegrationTarget(scraped, "/integrations/health/scraped/other")
+	)
+	integrations := []integrationStatus{{id: scraped, running: true}, {id: unscraped}}
+	targets := map[string]metrics.TargetSet{
+		"default": {"health/scraped": {metricsTarget, otherTarget}},
+	}
+
+	tracker := newHealthTracker()
+	defer tracker.update(time.Now(), nil, nil)
+
+	now := time.Now()
+	tracker.update(now, integrations, targets)
+	require.Equal(t, []IntegrationHealth{
+		{Name: "health", Instance: "scraped", Running: true, Health: HealthUnknown},
+		{Name: "health", Instance: "unscraped", Health: HealthUnknown},
+	}, tracker.Health())
+
+	// Two failed scrapes of a target.
+	start := now.Add(-time.Second)
+	otherTarget.Report(start, time.Millisecond, nil)
+	metricsTarget.Report(start, time.Millisecond, fmt.Errorf("connection refused"))
+	tracker.update(now, integrations, targets)
+	metricsTarget.Report(now, time.Millisecond, fmt.Errorf("connection refused"))
+	tracker.update(now, integrations, targets)
+
+	// Scrapes not observed again aren't counted twice.
+	tracker.update(now, integrations, targets)
+
+	health := tracker.Health()[0]
+	require.Equal(t, HealthDown, health.Health)
+	require.Equal(t, 2, health.ConsecutiveFailures)
+	require.Equal(t, "connection refused", health.LastError)
+	require.Equal(t, now, *health.LastScrape)
+	require.Equal(t, start, *health.LastSuccess)
+	require.Equal(t, 0.0, testutil.ToFloat64(integrationScrapeUp.WithLabelValues("health", "scraped")))
+	require.Equal(t, 2.0, testutil.ToFloat64(integrationConsecutiveFailures.WithLabelValues("health", "scraped")))
+
+	// A successful scrape resets failures.
+	metricsTarget.Report(now.Add(time.Second), time.Millisecond, nil)
+	tracker.update(now.Add(time.Second), integrations, targets)
+	health = tracker.Health()[0]
+	require.Equal(t, HealthUp, health.Health)
+	require.Zero(t, health.ConsecutiveFailures)
+	require.Empty(t, health.LastError)
+	require.Equal(t, 1.0, testutil.ToFloat64(integrationScrapeUp.WithLabelValues("health", "scraped")))
+
+	// Targets not scraped for more than twice their interval are stale.
+	tracker.update(now.Add(time.Minute), integrations, targets)
+	require.Equal(t, HealthStale, tracker.Health()[0].Health)
+	require.Equal(t, 0.0, testutil.ToFloat64(integrationScrapeUp.WithLabelValues("health", "scraped")))
+
+	// Metrics of removed integrations are deleted.
+	tracker.update(now, nil, nil)
+	require.Empty(t, tracker.Health())
+	require.Zero(t, testutil.CollectAndCount(integrationScrapeUp))
+}
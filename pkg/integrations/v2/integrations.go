@@ -144,6 +144,18 @@ type UpdateIntegration interface {
 	ApplyConfig(c Config, g Globals) error
 }
 
+// ControlIntegration is an integration which can be controlled at runtime
+// through the runtime API. Changes made through its handler aren't persisted
+// and are lost when the integration is recreated.
+type ControlIntegration interface {
+	Integration
+
+	// ControlHandler returns an http.Handler for requests to the
+	// <IntegrationsRuntimeEndpoint>/<name>/<instance>/control/ endpoint, with
+	// that prefix removed from the request path.
+	ControlHandler() (http.Handler, error)
+}
+
 // HTTPIntegration is an integration that exposes an HTTP handler.
 //
 // Integrations are given a unique base path prefix where HTTP requests will be
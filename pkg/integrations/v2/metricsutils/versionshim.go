@@ -133,7 +133,7 @@ func (s *configShim) NewIntegration(l log.Logger, g v2.Globals) (v2.Integration,
 	}
 
 	// Aggregate our converted settings into a v2 integration.
-	return &metricsHandlerIntegration{
+	mi := &metricsHandlerIntegration{
 		integrationName: s.Name(),
 		instanceID:      id,
 
@@ -143,5 +143,32 @@ func (s *configShim) NewIntegration(l log.Logger, g v2.Globals) (v2.Integration,
 		targets: targets,
 
 		runFunc: runFunc,
-	}, nil
+	}
+
+	// Original integrations which can be controlled at runtime keep being
+	// controllable once upgraded.
+	if ci, ok := v1Integration.(controlHandler); ok {
+		return &controlMetricsHandlerIntegration{metricsHandlerIntegration: mi, control: ci}, nil
+	}
+	return mi, nil
+}
+
+// controlHandler is implemented by original integrations which can be
+// controlled at runtime.
+type controlHandler interface {
+	ControlHandler() (http.Handler, error)
+}
+
+// controlMetricsHandlerIntegration is a metricsHandlerIntegration upgraded
+// from an original integration which can be controlled at runtime.
+type controlMetricsHandlerIntegration struct {
+	*metricsHandlerIntegration
+	control controlHandler
+}
+
+var _ v2.ControlIntegration = (*controlMetricsHandlerIntegration)(nil)
+
+// ControlHandler implements v2.ControlIntegration.
+func (i *controlMetricsHandlerIntegration) ControlHandler() (http.Handler, error) {
+	return i.control.ControlHandler()
 }
@@ -0,0 +1,199 @@
+// Package nomad collects metrics from the Prometheus endpoint of a Nomad
+// agent.
+package nomad
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	integrations_v2 "github.com/grafana/agent/pkg/integrations/v2"
+	"github.com/grafana/agent/pkg/integrations/v2/common"
+	"github.com/grafana/agent/pkg/integrations/v2/metricsutils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	config_util "github.com/prometheus/common/config"
+	"github.com/prometheus/common/expfmt"
+)
+
+// metricsPath is the path of the Nomad API returning agent metrics.
+const metricsPath = "/v1/metrics"
+
+// DefaultConfig holds the default settings for the nomad integration.
+var DefaultConfig = Config{
+	Server:  "http://localhost:4646",
+	Timeout: 5 * time.Second,
+}
+
+// Config controls the nomad integration.
+type Config struct {
+	// Server is the HTTP API address of a Nomad server or client agent.
+	Server string `yaml:"server,omitempty"`
+	// ACLToken is sent with every request to a Nomad cluster with ACLs
+	// enabled.
+	ACLToken  config_util.Secret    `yaml:"acl_token,omitempty"`
+	TLSConfig config_util.TLSConfig `yaml:"tls_config,omitempty"`
+	Timeout   time.Duration         `yaml:"timeout,omitempty"`
+
+	Common common.MetricsConfig `yaml:",inline"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler for Config.
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultConfig
+
+	type plain Config
+	return unmarshal((*plain)(c))
+}
+
+// ApplyDefaults applies the integration's default configuration.
+func (c *Config) ApplyDefaults(globals integrations_v2.Globals) error {
+	c.Common.ApplyDefaults(globals.SubsystemOpts.Metrics.Autoscrape)
+	if id, err := c.Identifier(globals); err == nil {
+		c.Common.InstanceKey = &id
+	}
+	return nil
+}
+
+// Identifier returns a string that identifies the integration.
+func (c *Config) Identifier(globals integrations_v2.Globals) (string, error) {
+	if c.Common.InstanceKey != nil {
+		return *c.Common.InstanceKey, nil
+	}
+	return c.InstanceKey(globals.AgentIdentifier)
+}
+
+// Name returns the name of the integration.
+func (c *Config) Name() string {
+	return "nomad"
+}
+
+// InstanceKey returns the hostname:port of the Nomad agent.
+func (c *Config) InstanceKey(agentKey string) (string, error) {
+	u, err := url.Parse(c.Server)
+	if err != nil {
+		return "", fmt.Errorf("could not parse url: %w", err)
+	}
+	return u.Host, nil
+}
+
+// NewIntegration creates a new nomad integration.
+func (c *Config) NewIntegration(l log.Logger, globals integrations_v2.Globals) (integrations_v2.Integration, error) {
+	g, err := newGatherer(l, c)
+	if err != nil {
+		return nil, err
+	}
+
+	h := promhttp.HandlerFor(g, promhttp.HandlerOpts{})
+	return metricsutils.NewMetricsHandlerIntegration(l, c, c.Common, globals, h)
+}
+
+func init() {
+	integrations_v2.Register(&Config{}, integrations_v2.TypeMultiplex)
+}
+
+// gatherer retrieves metrics from a Nomad agent. The nomad_up metric reports
+// whether the last retrieval succeeded.
+type gatherer struct {
+	log     log.Logger
+	client  *http.Client
+	url     string
+	token   string
+	timeout time.Duration
+}
+
+var _ prometheus.Gatherer = (*gatherer)(nil)
+
+func newGatherer(l log.Logger, c *Config) (*gatherer, error) {
+	u, err := url.Parse(c.Server)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse url: %w", err)
+	}
+	u.Path = metricsPath
+	u.RawQuery = url.Values{"format": []string{"prometheus"}}.Encode()
+
+	tlsConfig, err := config_util.NewTLSConfig(&c.TLSConfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tls_config: %w", err)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return &gatherer{
+		log:     l,
+		client:  &http.Client{Transport: transport},
+		url:     u.String(),
+		token:   string(c.ACLToken),
+		timeout: c.Timeout,
+	}, nil
+}
+
+// Gather implements prometheus.Gatherer. Failing to reach the Nomad agent is
+// reported through nomad_up rather than as an error so the scrape itself
+// succeeds.
+func (g *gatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.fetch()
+	if err != nil {
+		level.Error(g.log).Log("msg", "failed to retrieve nomad metrics", "err", err)
+	}
+
+	up := 1.0
+	if err != nil {
+		up = 0
+	}
+	families = append(families, &dto.MetricFamily{
+		Name: stringPtr("nomad_up"),
+		Help: stringPtr("Whether the last retrieval of metrics from the Nomad agent succeeded."),
+		Type: dto.MetricType_GAUGE.Enum(),
+		Metric: []*dto.Metric{{
+			Gauge: &dto.Gauge{Value: &up},
+		}},
+	})
+
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].GetName() < families[j].GetName()
+	})
+	return families, nil
+}
+
+func (g *gatherer) fetch() ([]*dto.MetricFamily, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), g.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if g.token != "" {
+		req.Header.Set("X-Nomad-Token", g.token)
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	var parser expfmt.TextParser
+	parsed, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("parsing metrics: %w", err)
+	}
+
+	families := make([]*dto.MetricFamily, 0, len(parsed))
+	for _, mf := range parsed {
+		families = append(families, mf)
+	}
+	return families, nil
+}
+
+func stringPtr(s string) *string { return &s }
@@ -0,0 +1,68 @@
+package nomad
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestGatherer(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, metricsPath, r.URL.Path)
+		require.Equal(t, "prometheus", r.URL.Query().Get("format"))
+		if r.Header.Get("X-Nomad-Token") != "token" {
+			http.Error(w, "Permission denied", http.StatusForbidden)
+			return
+		}
+
+		_, _ = w.Write([]byte(`# HELP nomad_client_allocs_running nomad_client_allocs_running
+# TYPE nomad_client_allocs_running gauge
+nomad_client_allocs_running{node_id="a"} 3
+`))
+	}))
+	defer srv.Close()
+
+	g, err := newGatherer(log.NewNopLogger(), &Config{Server: srv.URL, ACLToken: "token", Timeout: time.Second})
+	require.NoError(t, err)
+
+	require.NoError(t, testutil.GatherAndCompare(g, strings.NewReader(`
+		# HELP nomad_client_allocs_running nomad_client_allocs_running
+		# TYPE nomad_client_allocs_running gauge
+		nomad_client_allocs_running{node_id="a"} 3
+		# HELP nomad_up Whether the last retrieval of metrics from the Nomad agent succeeded.
+		# TYPE nomad_up gauge
+		nomad_up 1
+	`)))
+
+	// A missing token fails the retrieval, which is reported by nomad_up.
+	g, err = newGatherer(log.NewNopLogger(), &Config{Server: srv.URL, Timeout: time.Second})
+	require.NoError(t, err)
+
+	require.NoError(t, testutil.GatherAndCompare(g, strings.NewReader(`
+		# HELP nomad_up Whether the last retrieval of metrics from the Nomad agent succeeded.
+		# TYPE nomad_up gauge
+		nomad_up 0
+	`)))
+}
+
+func TestConfig_UnmarshalYAML(t *testing.T) {
+	var cfg Config
+	require.NoError(t, yaml.Unmarshal([]byte(`acl_token: secret`), &cfg))
+	require.Equal(t, DefaultConfig.Server, cfg.Server)
+	require.Equal(t, DefaultConfig.Timeout, cfg.Timeout)
+
+	key, err := cfg.InstanceKey("agent")
+	require.NoError(t, err)
+	require.Equal(t, "localhost:4646", key)
+
+	out, err := yaml.Marshal(cfg)
+	require.NoError(t, err)
+	require.NotContains(t, string(out), "secret\n")
+}
@@ -0,0 +1,220 @@
+package oracledb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	upDesc = prometheus.NewDesc(
+		"oracledb_up",
+		"Whether the database could be reached.",
+		nil, nil,
+	)
+	querySuccessDesc = prometheus.NewDesc(
+		"oracledb_query_success",
+		"Whether the query of the context succeeded.",
+		[]string{"context"}, nil,
+	)
+	queryDurationDesc = prometheus.NewDesc(
+		"oracledb_query_duration_seconds",
+		"Duration of the query of the context.",
+		[]string{"context"}, nil,
+	)
+)
+
+// collector runs queries against the database on every scrape.
+type collector struct {
+	log     log.Logger
+	db      *sql.DB
+	queries []*query
+	timeout time.Duration
+}
+
+// query is a Metric prepared for collection.
+type query struct {
+	Metric
+	labels  []string // Lowercase label columns
+	columns []string // Lowercase metric columns, sorted
+	descs   map[string]*prometheus.Desc
+	types   map[string]prometheus.ValueType
+}
+
+var _ prometheus.Collector = (*collector)(nil)
+
+func newCollector(l log.Logger, db *sql.DB, metrics []Metric, timeout time.Duration) (*collector, error) {
+	var (
+		queries  = make([]*query, 0, len(metrics))
+		contexts = make(map[string]struct{}, len(metrics))
+	)
+	for _, m := range metrics {
+		if _, exist := contexts[m.Context]; exist {
+			return nil, fmt.Errorf("found multiple queries with context %q", m.Context)
+		}
+		contexts[m.Context] = struct{}{}
+		queries = append(queries, newQuery(m))
+	}
+
+	return &collector{
+		log:     l,
+		db:      db,
+		queries: queries,
+		timeout: timeout,
+	}, nil
+}
+
+func newQuery(m Metric) *query {
+	q := &query{
+		Metric: m,
+		descs:  make(map[string]*prometheus.Desc, len(m.MetricsDesc)),
+		types:  make(map[string]prometheus.ValueType, len(m.MetricsDesc)),
+	}
+	for _, l := range m.Labels {
+		q.labels = append(q.labels, strings.ToLower(l))
+	}
+	for col, help := range m.MetricsDesc {
+		col = strings.ToLower(col)
+		q.columns = append(q.columns, col)
+		q.descs[col] = prometheus.NewDesc(m.metricName(col), help, q.labels, nil)
+		q.types[col] = prometheus.GaugeValue
+	}
+	for col, typ := range m.MetricsType {
+		if strings.EqualFold(typ, "counter") {
+			q.types[strings.ToLower(col)] = prometheus.CounterValue
+		}
+	}
+	sort.Strings(q.columns)
+	return q
+}
+
+// Describe implements prometheus.Collector.
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- upDesc
+	ch <- querySuccessDesc
+	ch <- queryDurationDesc
+	for _, q := range c.queries {
+		for _, desc := range q.descs {
+			ch <- desc
+		}
+	}
+}
+
+// Collect implements prometheus.Collector. Queries are run concurrently,
+// limited by the size of the connection pool.
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	err := c.db.PingContext(ctx)
+	cancel()
+	if err != nil {
+		level.Error(c.log).Log("msg", "failed to connect to database", "err", err)
+		ch <- prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, 0)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, 1)
+
+	var wg sync.WaitGroup
+	for _, q := range c.queries {
+		wg.Add(1)
+		go func(q *query) {
+			defer wg.Done()
+
+			start := time.Now()
+			err := c.runQuery(q, ch)
+			duration := time.Since(start).Seconds()
+
+			success := 1.0
+			if err != nil {
+				level.Error(c.log).Log("msg", "query failed", "context", q.Context, "err", err)
+				success = 0
+			}
+			ch <- prometheus.MustNewConstMetric(querySuccessDesc, prometheus.GaugeValue, success, q.Context)
+			ch <- prometheus.MustNewConstMetric(queryDurationDesc, prometheus.GaugeValue, duration, q.Context)
+		}(q)
+	}
+	wg.Wait()
+}
+
+// runQuery runs q and sends the metrics of its rows to ch. Metrics are only
+// sent once all rows have been read, so failed queries don't report partial
+// results.
+func (c *collector) runQuery(q *query, ch chan<- prometheus.Metric) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	rows, err := c.db.QueryContext(ctx, q.Request)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	index := make(map[string]int, len(columns))
+	for i, col := range columns {
+		index[strings.ToLower(col)] = i
+	}
+	for _, cols := range [][]string{q.labels, q.columns} {
+		for _, col := range cols {
+			if _, ok := index[col]; !ok {
+				return fmt.Errorf("column %q is missing from the result", col)
+			}
+		}
+	}
+
+	var (
+		metrics []prometheus.Metric
+		values  = make([]sql.NullString, len(columns))
+		dest    = make([]interface{}, len(columns))
+	)
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+
+		labelValues := make([]string, 0, len(q.labels))
+		for _, l := range q.labels {
+			labelValues = append(labelValues, values[index[l]].String)
+		}
+		for _, col := range q.columns {
+			v := values[index[col]]
+			if !v.Valid {
+				continue
+			}
+			f, err := strconv.ParseFloat(strings.TrimSpace(v.String), 64)
+			if err != nil {
+				return fmt.Errorf("column %q has non-numeric value %q", col, v.String)
+			}
+			m, err := prometheus.NewConstMetric(q.descs[col], q.types[col], f, labelValues...)
+			if err != nil {
+				return err
+			}
+			metrics = append(metrics, m)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(metrics) == 0 && !q.IgnoreZeroResult {
+		return fmt.Errorf("query returned no results")
+	}
+
+	for _, m := range metrics {
+		ch <- m
+	}
+	return nil
+}
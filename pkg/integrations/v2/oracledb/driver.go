@@ -0,0 +1,7 @@
+package oracledb
+
+import (
+	// Register the pure Go Oracle driver, which doesn't require the Oracle
+	// client libraries.
+	_ "github.com/sijms/go-ora/v2"
+)
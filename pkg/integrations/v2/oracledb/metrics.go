@@ -0,0 +1,181 @@
+package oracledb
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v2"
+)
+
+// Metric defines a query whose result rows are turned into metrics. Every
+// row of the result is a sample: the columns in Labels are used as labels,
+// and each column in MetricsDesc becomes the metric
+// oracledb_<context>_<column>.
+//
+// The fields match the format of the custom metrics of the standalone
+// oracledb_exporter, so existing queries can be reused.
+type Metric struct {
+	// Context prefixes the names of the metrics of the query. It must be
+	// unique across all queries.
+	Context string `yaml:"context"`
+	// Request is the SQL query to run.
+	Request string `yaml:"request"`
+	// Labels are the columns used as labels.
+	Labels []string `yaml:"labels,omitempty"`
+	// MetricsDesc maps the columns reported as metrics to their help text.
+	MetricsDesc map[string]string `yaml:"metrics_desc"`
+	// MetricsType maps columns to their metric type, either gauge or counter.
+	// Columns default to gauge.
+	MetricsType map[string]string `yaml:"metrics_type,omitempty"`
+	// IgnoreZeroResult allows the query to return no rows without it being
+	// reported as a failure.
+	IgnoreZeroResult bool `yaml:"ignore_zero_result,omitempty"`
+}
+
+// metricsFile is the format of the custom_metrics file.
+type metricsFile struct {
+	Metrics []Metric `yaml:"metrics"`
+}
+
+// LoadMetricsFile reads the queries defined in a custom_metrics file.
+func LoadMetricsFile(path string) ([]Metric, error) {
+	bb, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read custom metrics: %w", err)
+	}
+
+	var f metricsFile
+	if err := yaml.UnmarshalStrict(bb, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse custom metrics %s: %w", path, err)
+	}
+	for i, m := range f.Metrics {
+		if err := m.validate(); err != nil {
+			return nil, fmt.Errorf("custom metrics %s: metrics[%d]: %w", path, i, err)
+		}
+	}
+	return f.Metrics, nil
+}
+
+func (m *Metric) validate() error {
+	switch {
+	case m.Context == "":
+		return fmt.Errorf("context must be set")
+	case !model.IsValidMetricName(model.LabelValue(m.Context)):
+		return fmt.Errorf("context %q is not valid in metric names", m.Context)
+	case strings.TrimSpace(m.Request) == "":
+		return fmt.Errorf("request must be set")
+	case len(m.MetricsDesc) == 0:
+		return fmt.Errorf("metrics_desc must have at least one column")
+	}
+
+	labels := make(map[string]struct{}, len(m.Labels))
+	for _, l := range m.Labels {
+		if !model.LabelName(strings.ToLower(l)).IsValid() {
+			return fmt.Errorf("label %q is not a valid label name", l)
+		}
+		labels[strings.ToLower(l)] = struct{}{}
+	}
+	for col := range m.MetricsDesc {
+		if !model.IsValidMetricName(model.LabelValue(m.metricName(col))) {
+			return fmt.Errorf("column %q is not valid in metric names", col)
+		}
+		if _, ok := labels[strings.ToLower(col)]; ok {
+			return fmt.Errorf("column %q can't be used as both a label and a metric", col)
+		}
+	}
+	for col, typ := range m.MetricsType {
+		if _, ok := m.MetricsDesc[col]; !ok {
+			return fmt.Errorf("metrics_type: column %q is missing from metrics_desc", col)
+		}
+		if typ = strings.ToLower(typ); typ != "gauge" && typ != "counter" {
+			return fmt.Errorf("metrics_type: column %q has unsupported type %q", col, typ)
+		}
+	}
+	return nil
+}
+
+// metricName returns the name of the metric of a column.
+func (m *Metric) metricName(column string) string {
+	return "oracledb_" + m.Context + "_" + strings.ToLower(column)
+}
+
+// DefaultMetrics are the built-in queries used when default_metrics is
+// enabled.
+var DefaultMetrics = []Metric{
+	{
+		Context: "sessions",
+		Request: "SELECT status, type, COUNT(*) AS value FROM v$session GROUP BY status, type",
+		Labels:  []string{"status", "type"},
+		MetricsDesc: map[string]string{
+			"value": "Number of sessions by status and type.",
+		},
+	},
+	{
+		Context: "resource",
+		Request: `SELECT resource_name, current_utilization,
+			CASE WHEN TRIM(limit_value) LIKE 'UNLIMITED' THEN '-1' ELSE TRIM(limit_value) END AS limit_value
+			FROM v$resource_limit`,
+		Labels: []string{"resource_name"},
+		MetricsDesc: map[string]string{
+			"current_utilization": "Number of resources in use.",
+			"limit_value":         "Maximum number of the resource; -1 if unlimited.",
+		},
+	},
+	{
+		Context: "activity",
+		Request: `SELECT
+			SUM(CASE WHEN name = 'parse count (total)' THEN value END) AS parse_count_total,
+			SUM(CASE WHEN name = 'execute count' THEN value END) AS execute_count,
+			SUM(CASE WHEN name = 'user commits' THEN value END) AS user_commits,
+			SUM(CASE WHEN name = 'user rollbacks' THEN value END) AS user_rollbacks
+			FROM v$sysstat`,
+		MetricsDesc: map[string]string{
+			"parse_count_total": "Total number of parse calls.",
+			"execute_count":     "Total number of calls executing SQL statements.",
+			"user_commits":      "Total number of user commits.",
+			"user_rollbacks":    "Total number of user rollbacks.",
+		},
+		MetricsType: map[string]string{
+			"parse_count_total": "counter",
+			"execute_count":     "counter",
+			"user_commits":      "counter",
+			"user_rollbacks":    "counter",
+		},
+	},
+	{
+		Context: "process",
+		Request: "SELECT COUNT(*) AS count FROM v$process",
+		MetricsDesc: map[string]string{
+			"count": "Number of Oracle processes.",
+		},
+	},
+	{
+		Context: "wait_time",
+		Request: `SELECT n.wait_class, ROUND(m.time_waited / m.intsize_csec, 3) AS value
+			FROM v$waitclassmetric m, v$system_wait_class n
+			WHERE m.wait_class_id = n.wait_class_id AND n.wait_class != 'Idle'`,
+		Labels: []string{"wait_class"},
+		MetricsDesc: map[string]string{
+			"value": "Average number of sessions waiting in the wait class over the last minute.",
+		},
+	},
+	{
+		Context: "tablespace",
+		Request: `SELECT dt.tablespace_name AS tablespace, dt.contents AS type,
+			dt.block_size * dtum.used_space AS bytes,
+			dt.block_size * dtum.tablespace_size AS max_bytes,
+			dt.block_size * (dtum.tablespace_size - dtum.used_space) AS free,
+			dtum.used_percent
+			FROM dba_tablespace_usage_metrics dtum, dba_tablespaces dt
+			WHERE dtum.tablespace_name = dt.tablespace_name`,
+		Labels: []string{"tablespace", "type"},
+		MetricsDesc: map[string]string{
+			"bytes":        "Bytes used by the tablespace.",
+			"max_bytes":    "Maximum size of the tablespace in bytes.",
+			"free":         "Bytes free in the tablespace.",
+			"used_percent": "Percentage of the maximum size of the tablespace in use.",
+		},
+	},
+}
@@ -0,0 +1,316 @@
+// Package plugin runs integrations shipped as plugins, which are separate
+// binaries built with the sdk package.
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	integrations_v2 "github.com/grafana/agent/pkg/integrations/v2"
+	"github.com/grafana/agent/pkg/integrations/v2/autoscrape"
+	"github.com/grafana/agent/pkg/integrations/v2/common"
+	"github.com/grafana/agent/pkg/integrations/v2/metricsutils"
+	"github.com/grafana/agent/pkg/integrations/v2/plugin/sdk"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/hashicorp/go-hclog"
+	goplugin "github.com/hashicorp/go-plugin"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/procfs"
+	"github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/discovery"
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+)
+
+// DefaultConfig holds the default settings for the plugin integration.
+var DefaultConfig = Config{
+	StartTimeout:   time.Minute,
+	TargetsTimeout: 5 * time.Second,
+}
+
+// Config controls the plugin integration.
+type Config struct {
+	// PluginName identifies the plugin. It is used as the job of its metrics.
+	PluginName string `yaml:"name"`
+	// Command is the plugin binary, and Args its arguments.
+	Command string   `yaml:"command"`
+	Args    []string `yaml:"args,omitempty"`
+	// Config is passed to the plugin.
+	Config util.RawYAML `yaml:"config,omitempty"`
+
+	StartTimeout   time.Duration `yaml:"start_timeout,omitempty"`
+	TargetsTimeout time.Duration `yaml:"targets_timeout,omitempty"`
+
+	// Limits of the resources used by the plugin process.
+	Limits integrations_v2.ResourceLimits `yaml:"limits,omitempty"`
+
+	Common common.MetricsConfig `yaml:",inline"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler for Config.
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultConfig
+
+	type plain Config
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	if c.PluginName == "" {
+		return fmt.Errorf("plugin name must be set")
+	}
+	if c.Command == "" {
+		return fmt.Errorf("plugin %q: command must be set", c.PluginName)
+	}
+	return nil
+}
+
+// ApplyDefaults applies the integration's default configuration.
+func (c *Config) ApplyDefaults(globals integrations_v2.Globals) error {
+	c.Common.ApplyDefaults(globals.SubsystemOpts.Metrics.Autoscrape)
+	return nil
+}
+
+// Identifier returns a string that identifies the integration.
+func (c *Config) Identifier(globals integrations_v2.Globals) (string, error) {
+	if c.Common.InstanceKey != nil {
+		return *c.Common.InstanceKey, nil
+	}
+	return c.PluginName, nil
+}
+
+// Name returns the name of the integration this config is for.
+func (c *Config) Name() string {
+	return "plugin"
+}
+
+// NewIntegration creates an integration which runs the plugin.
+func (c *Config) NewIntegration(l log.Logger, globals integrations_v2.Globals) (integrations_v2.Integration, error) {
+	id, err := c.Identifier(globals)
+	if err != nil {
+		return nil, err
+	}
+	return &pluginIntegration{
+		log:        log.With(l, "plugin", c.PluginName),
+		cfg:        c,
+		globals:    globals,
+		instanceID: id,
+	}, nil
+}
+
+func init() {
+	integrations_v2.Register(&Config{}, integrations_v2.TypeMultiplex)
+}
+
+// pluginIntegration runs a plugin binary.
+type pluginIntegration struct {
+	log        log.Logger
+	cfg        *Config
+	globals    integrations_v2.Globals
+	instanceID string
+
+	mut  sync.RWMutex
+	impl sdk.Integration // nil while the plugin isn't running
+	pid  int             // 0 while the plugin isn't running
+}
+
+// Static typecheck tests
+var (
+	_ integrations_v2.Integration         = (*pluginIntegration)(nil)
+	_ integrations_v2.HTTPIntegration     = (*pluginIntegration)(nil)
+	_ integrations_v2.MetricsIntegration  = (*pluginIntegration)(nil)
+	_ integrations_v2.ResourceIntegration = (*pluginIntegration)(nil)
+)
+
+// RunIntegration starts the plugin and stops it when ctx is canceled. It
+// returns an error if the plugin exits before.
+func (i *pluginIntegration) RunIntegration(ctx context.Context) error {
+	client := goplugin.NewClient(&goplugin.ClientConfig{
+		HandshakeConfig:  sdk.Handshake,
+		Plugins:          sdk.Plugins(nil),
+		Cmd:              exec.Command(i.cfg.Command, i.cfg.Args...),
+		AllowedProtocols: []goplugin.Protocol{goplugin.ProtocolGRPC},
+		StartTimeout:     i.cfg.StartTimeout,
+		Logger: hclog.New(&hclog.LoggerOptions{
+			Name:        i.cfg.PluginName,
+			Level:       hclog.Info,
+			Output:      log.NewStdlibAdapter(i.log),
+			DisableTime: true,
+		}),
+	})
+	defer client.Kill()
+
+	rpcClient, err := client.Client()
+	if err != nil {
+		return fmt.Errorf("failed to start plugin: %w", err)
+	}
+	raw, err := rpcClient.Dispense(sdk.PluginName)
+	if err != nil {
+		return fmt.Errorf("failed to connect to plugin: %w", err)
+	}
+	impl := raw.(sdk.Integration)
+
+	globals := sdk.Globals{AgentIdentifier: i.globals.AgentIdentifier}
+	if err := impl.Configure(ctx, i.cfg.Config, globals); err != nil {
+		return fmt.Errorf("failed to configure plugin: %w", err)
+	}
+
+	var pid int
+	if rc := client.ReattachConfig(); rc != nil {
+		pid = rc.Pid
+	}
+	return i.run(ctx, impl, pid, client.Exited)
+}
+
+// run exposes impl, running in process pid, until ctx is canceled or exited
+// returns true.
+func (i *pluginIntegration) run(ctx context.Context, impl sdk.Integration, pid int, exited func() bool) error {
+	i.mut.Lock()
+	i.impl, i.pid = impl, pid
+	i.mut.Unlock()
+
+	defer func() {
+		i.mut.Lock()
+		i.impl, i.pid = nil, 0
+		i.mut.Unlock()
+	}()
+
+	t := time.NewTicker(time.Second)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+			if exited() {
+				return fmt.Errorf("plugin exited unexpectedly")
+			}
+		}
+	}
+}
+
+func (i *pluginIntegration) getImpl() sdk.Integration {
+	i.mut.RLock()
+	defer i.mut.RUnlock()
+	return i.impl
+}
+
+// ResourceUsage implements ResourceIntegration. The usage of the plugin
+// process is read from procfs.
+func (i *pluginIntegration) ResourceUsage() (integrations_v2.ResourceUsage, error) {
+	i.mut.RLock()
+	pid := i.pid
+	i.mut.RUnlock()
+	if pid == 0 {
+		return integrations_v2.ResourceUsage{}, fmt.Errorf("plugin is not running")
+	}
+
+	proc, err := procfs.NewProc(pid)
+	if err != nil {
+		return integrations_v2.ResourceUsage{}, err
+	}
+	stat, err := proc.Stat()
+	if err != nil {
+		return integrations_v2.ResourceUsage{}, err
+	}
+	return integrations_v2.ResourceUsage{
+		CPUSeconds:  stat.CPUTime(),
+		MemoryBytes: uint64(stat.ResidentMemory()),
+	}, nil
+}
+
+// ResourceLimits implements ResourceIntegration.
+func (i *pluginIntegration) ResourceLimits() integrations_v2.ResourceLimits {
+	return i.cfg.Limits
+}
+
+// Handler implements HTTPIntegration. Requests are sent to the plugin.
+func (i *pluginIntegration) Handler(prefix string) (http.Handler, error) {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		impl := i.getImpl()
+		if impl == nil {
+			http.Error(rw, "plugin is not running", http.StatusServiceUnavailable)
+			return
+		}
+
+		r = r.Clone(r.Context())
+		r.URL.Path = "/" + strings.TrimPrefix(r.URL.Path, prefix)
+		r.URL.RawPath = ""
+		impl.ServeHTTP(rw, r)
+	}), nil
+}
+
+// Targets implements MetricsIntegration.
+func (i *pluginIntegration) Targets(ep integrations_v2.Endpoint) []*targetgroup.Group {
+	impl := i.getImpl()
+	if impl == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), i.cfg.TargetsTimeout)
+	defer cancel()
+	targets, err := impl.Targets(ctx)
+	if err != nil {
+		level.Warn(i.log).Log("msg", "failed to get plugin targets", "err", err)
+		return nil
+	}
+
+	group := &targetgroup.Group{
+		Labels: model.LabelSet{
+			model.InstanceLabel: model.LabelValue(i.instanceID),
+			model.JobLabel:      model.LabelValue("integrations/" + i.cfg.PluginName),
+			"agent_hostname":    model.LabelValue(i.globals.AgentIdentifier),
+
+			// Meta labels that can be used during SD.
+			"__meta_agent_integration_name":       model.LabelValue(i.cfg.Name()),
+			"__meta_agent_integration_instance":   model.LabelValue(i.instanceID),
+			"__meta_agent_integration_plugin":     model.LabelValue(i.cfg.PluginName),
+			"__meta_agent_integration_autoscrape": model.LabelValue(metricsutils.BoolToString(*i.cfg.Common.Autoscrape.Enable)),
+		},
+		Source: fmt.Sprintf("%s/%s", i.cfg.Name(), i.instanceID),
+	}
+	for _, lbl := range i.cfg.Common.ExtraLabels {
+		group.Labels[model.LabelName(lbl.Name)] = model.LabelValue(lbl.Value)
+	}
+
+	for _, t := range targets {
+		target := model.LabelSet{
+			model.AddressLabel:     model.LabelValue(ep.Host),
+			model.MetricsPathLabel: model.LabelValue(path.Join(ep.Prefix, t.MetricsPath)),
+		}
+		for name, value := range t.Labels {
+			target[model.LabelName(name)] = model.LabelValue(value)
+		}
+		group.Targets = append(group.Targets, target)
+	}
+	return []*targetgroup.Group{group}
+}
+
+// ScrapeConfigs implements MetricsIntegration.
+func (i *pluginIntegration) ScrapeConfigs(sd discovery.Configs) []*autoscrape.ScrapeConfig {
+	if !*i.cfg.Common.Autoscrape.Enable {
+		return nil
+	}
+
+	cfg := config.DefaultScrapeConfig
+	cfg.JobName = fmt.Sprintf("%s/%s", i.cfg.Name(), i.instanceID)
+	cfg.Scheme = i.globals.AgentBaseURL.Scheme
+	cfg.ServiceDiscoveryConfigs = sd
+	cfg.ScrapeInterval = i.cfg.Common.Autoscrape.ScrapeInterval
+	cfg.ScrapeTimeout = i.cfg.Common.Autoscrape.ScrapeTimeout
+	cfg.RelabelConfigs = i.cfg.Common.Autoscrape.RelabelConfigs
+	cfg.MetricRelabelConfigs = i.cfg.Common.Autoscrape.MetricRelabelConfigs
+
+	return []*autoscrape.ScrapeConfig{{
+		Instance: i.cfg.Common.Autoscrape.MetricsInstance,
+		Config:   cfg,
+	}}
+}
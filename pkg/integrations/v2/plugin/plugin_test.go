@@ -0,0 +1,100 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	integrations_v2 "github.com/grafana/agent/pkg/integrations/v2"
+	"github.com/grafana/agent/pkg/integrations/v2/plugin/sdk"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+type fakeIntegration struct{}
+
+func (fakeIntegration) Configure(context.Context, []byte, sdk.Globals) error { return nil }
+
+func (fakeIntegration) Targets(context.Context) ([]sdk.Target, error) {
+	return []sdk.Target{{MetricsPath: "metrics", Labels: map[string]string{"database": "main"}}}, nil
+}
+
+func (fakeIntegration) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprint(w, r.URL.Path)
+}
+
+func TestConfig_Unmarshal(t *testing.T) {
+	var c Config
+	require.NoError(t, yaml.UnmarshalStrict([]byte(`
+name: my_exporter
+command: /usr/local/bin/my-exporter
+args: [--verbose]
+config:
+  address: localhost:1234
+`), &c))
+	require.Equal(t, "my_exporter", c.PluginName)
+	require.Equal(t, time.Minute, c.StartTimeout)
+	require.Equal(t, "address: localhost:1234\n", string(c.Config))
+
+	id, err := c.Identifier(integrations_v2.Globals{})
+	require.NoError(t, err)
+	require.Equal(t, "my_exporter", id)
+
+	err = yaml.UnmarshalStrict([]byte(`name: my_exporter`), &c)
+	require.EqualError(t, err, `plugin "my_exporter": command must be set`)
+}
+
+func TestPluginIntegration(t *testing.T) {
+	var c Config
+	require.NoError(t, yaml.UnmarshalStrict([]byte("name: my_exporter\ncommand: my-exporter"), &c))
+	require.NoError(t, c.ApplyDefaults(integrations_v2.Globals{
+		SubsystemOpts: integrations_v2.DefaultSubsystemOptions,
+	}))
+
+	raw, err := c.NewIntegration(log.NewNopLogger(), integrations_v2.Globals{AgentIdentifier: "agent-1"})
+	require.NoError(t, err)
+	i := raw.(*pluginIntegration)
+
+	handler, err := i.Handler("/integrations/plugin/my_exporter/")
+	require.NoError(t, err)
+	ep := integrations_v2.Endpoint{Host: "localhost:12345", Prefix: "/integrations/plugin/my_exporter/"}
+
+	// The plugin isn't running yet.
+	require.Empty(t, i.Targets(ep))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/integrations/plugin/my_exporter/metrics", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	exited := make(chan error)
+	go func() { exited <- i.run(ctx, fakeIntegration{}, os.Getpid(), func() bool { return false }) }()
+
+	require.Eventually(t, func() bool { return len(i.Targets(ep)) == 1 }, 5*time.Second, 10*time.Millisecond)
+	groups := i.Targets(ep)
+	require.Equal(t, model.LabelValue("integrations/my_exporter"), groups[0].Labels[model.JobLabel])
+	require.Equal(t, model.LabelValue("my_exporter"), groups[0].Labels[model.InstanceLabel])
+	require.Equal(t, []model.LabelSet{{
+		model.AddressLabel:     "localhost:12345",
+		model.MetricsPathLabel: "/integrations/plugin/my_exporter/metrics",
+		"database":             "main",
+	}}, groups[0].Targets)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/integrations/plugin/my_exporter/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "/metrics", rec.Body.String())
+
+	usage, err := i.ResourceUsage()
+	require.NoError(t, err)
+	require.NotZero(t, usage.MemoryBytes)
+
+	cancel()
+	require.NoError(t, <-exited)
+	require.Empty(t, i.Targets(ep))
+}
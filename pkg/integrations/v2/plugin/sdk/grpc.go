@@ -0,0 +1,149 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	empty "github.com/golang/protobuf/ptypes/empty"
+	"github.com/grafana/agent/pkg/integrations/v2/plugin/pluginproto"
+	goplugin "github.com/hashicorp/go-plugin"
+	"google.golang.org/grpc"
+)
+
+// grpcPlugin implements goplugin.GRPCPlugin for Integration.
+type grpcPlugin struct {
+	goplugin.NetRPCUnsupportedPlugin
+	impl Integration
+}
+
+var _ goplugin.GRPCPlugin = (*grpcPlugin)(nil)
+
+// GRPCServer implements goplugin.GRPCPlugin.
+func (p *grpcPlugin) GRPCServer(_ *goplugin.GRPCBroker, s *grpc.Server) error {
+	pluginproto.RegisterIntegrationServer(s, &grpcServer{impl: p.impl})
+	return nil
+}
+
+// GRPCClient implements goplugin.GRPCPlugin.
+func (p *grpcPlugin) GRPCClient(_ context.Context, _ *goplugin.GRPCBroker, cc *grpc.ClientConn) (interface{}, error) {
+	return &grpcClient{client: pluginproto.NewIntegrationClient(cc)}, nil
+}
+
+// grpcServer exposes an Integration to the agent.
+type grpcServer struct {
+	impl Integration
+}
+
+func (s *grpcServer) Configure(ctx context.Context, req *pluginproto.ConfigureRequest) (*empty.Empty, error) {
+	err := s.impl.Configure(ctx, req.Config, Globals{AgentIdentifier: req.AgentIdentifier})
+	return &empty.Empty{}, err
+}
+
+func (s *grpcServer) Targets(ctx context.Context, _ *empty.Empty) (*pluginproto.TargetsResponse, error) {
+	targets, err := s.impl.Targets(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &pluginproto.TargetsResponse{}
+	for _, t := range targets {
+		resp.Targets = append(resp.Targets, &pluginproto.Target{
+			MetricsPath: t.MetricsPath,
+			Labels:      t.Labels,
+		})
+	}
+	return resp, nil
+}
+
+func (s *grpcServer) ServeHTTP(ctx context.Context, req *pluginproto.HTTPRequest) (*pluginproto.HTTPResponse, error) {
+	url := req.Url
+	if !strings.HasPrefix(url, "/") {
+		url = "/" + url
+	}
+	r, err := http.NewRequestWithContext(ctx, req.Method, url, bytes.NewReader(req.Body))
+	if err != nil {
+		return nil, err
+	}
+	r.Header = fromProtoHeaders(req.Headers)
+
+	rec := httptest.NewRecorder()
+	s.impl.ServeHTTP(rec, r)
+
+	return &pluginproto.HTTPResponse{
+		StatusCode: int32(rec.Code),
+		Headers:    toProtoHeaders(rec.Header()),
+		Body:       rec.Body.Bytes(),
+	}, nil
+}
+
+// grpcClient is used by the agent to call an Integration served by a plugin.
+type grpcClient struct {
+	client pluginproto.IntegrationClient
+}
+
+func (c *grpcClient) Configure(ctx context.Context, config []byte, globals Globals) error {
+	_, err := c.client.Configure(ctx, &pluginproto.ConfigureRequest{
+		Config:          config,
+		AgentIdentifier: globals.AgentIdentifier,
+	})
+	return err
+}
+
+func (c *grpcClient) Targets(ctx context.Context) ([]Target, error) {
+	resp, err := c.client.Targets(ctx, &empty.Empty{})
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make([]Target, 0, len(resp.Targets))
+	for _, t := range resp.Targets {
+		targets = append(targets, Target{MetricsPath: t.MetricsPath, Labels: t.Labels})
+	}
+	return targets, nil
+}
+
+func (c *grpcClient) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to read request: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := c.client.ServeHTTP(r.Context(), &pluginproto.HTTPRequest{
+		Method:  r.Method,
+		Url:     r.URL.RequestURI(),
+		Headers: toProtoHeaders(r.Header),
+		Body:    body,
+	})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("plugin request failed: %s", err), http.StatusBadGateway)
+		return
+	}
+
+	for name, values := range fromProtoHeaders(resp.Headers) {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(int(resp.StatusCode))
+	_, _ = w.Write(resp.Body)
+}
+
+func toProtoHeaders(h http.Header) []*pluginproto.Header {
+	res := make([]*pluginproto.Header, 0, len(h))
+	for name, values := range h {
+		res = append(res, &pluginproto.Header{Name: name, Values: values})
+	}
+	return res
+}
+
+func fromProtoHeaders(headers []*pluginproto.Header) http.Header {
+	h := make(http.Header, len(headers))
+	for _, header := range headers {
+		h[header.Name] = header.Values
+	}
+	return h
+}
@@ -0,0 +1,80 @@
+// Package sdk is used to build integrations as plugins, which are separate
+// binaries started by the agent. The plugin config of the agent is used to
+// run a plugin:
+//
+//   integrations:
+//     plugin_configs:
+//     - name: my_exporter
+//       command: /usr/local/bin/my-exporter-plugin
+//       config:
+//         # Config of the plugin
+//
+// The main function of a plugin binary calls Serve with its Integration.
+// Plugins communicate with the agent over gRPC, with the protocol defined in
+// the pluginproto package.
+package sdk
+
+import (
+	"context"
+	"net/http"
+
+	goplugin "github.com/hashicorp/go-plugin"
+)
+
+// PluginName is the name under which plugins serve their Integration.
+const PluginName = "integration"
+
+// Handshake is used by the agent and plugins to make sure they're compatible.
+// The ProtocolVersion is increased when breaking changes are made to the
+// protocol.
+var Handshake = goplugin.HandshakeConfig{
+	ProtocolVersion:  1,
+	MagicCookieKey:   "GRAFANA_AGENT_INTEGRATION_PLUGIN",
+	MagicCookieValue: "5c1d3a97-6a0f-4b8d-9d16-3f5e0e6a2b1c",
+}
+
+// Integration is implemented by plugins.
+type Integration interface {
+	// Configure configures the integration from its YAML config. It is called
+	// once, before any other method.
+	Configure(ctx context.Context, config []byte, globals Globals) error
+
+	// Targets returns the metrics endpoints of the integration, which are
+	// scraped through ServeHTTP.
+	Targets(ctx context.Context) ([]Target, error)
+
+	// ServeHTTP handles HTTP requests sent to the integration. The request URL
+	// is relative to the integration.
+	http.Handler
+}
+
+// Globals holds settings of the agent running the plugin.
+type Globals struct {
+	// AgentIdentifier identifies the agent, such as its hostname.
+	AgentIdentifier string
+}
+
+// Target is a metrics endpoint of a plugin.
+type Target struct {
+	// MetricsPath is the path of the endpoint, relative to the integration,
+	// such as "metrics".
+	MetricsPath string
+	// Labels are extra labels of the target.
+	Labels map[string]string
+}
+
+// Plugins returns the plugins served by a plugin binary with impl, or the
+// plugins dispensed by the agent if impl is nil.
+func Plugins(impl Integration) goplugin.PluginSet {
+	return goplugin.PluginSet{PluginName: &grpcPlugin{impl: impl}}
+}
+
+// Serve serves impl to the agent. It is called from the main function of
+// plugin binaries and blocks until the agent stops the plugin.
+func Serve(impl Integration) {
+	goplugin.Serve(&goplugin.ServeConfig{
+		HandshakeConfig: Handshake,
+		Plugins:         Plugins(impl),
+		GRPCServer:      goplugin.DefaultGRPCServer,
+	})
+}
@@ -0,0 +1,67 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	goplugin "github.com/hashicorp/go-plugin"
+	"github.com/stretchr/testify/require"
+)
+
+type testIntegration struct {
+	config  []byte
+	globals Globals
+}
+
+func (i *testIntegration) Configure(_ context.Context, config []byte, globals Globals) error {
+	if strings.Contains(string(config), "invalid") {
+		return fmt.Errorf("invalid config")
+	}
+	i.config, i.globals = config, globals
+	return nil
+}
+
+func (i *testIntegration) Targets(context.Context) ([]Target, error) {
+	return []Target{{MetricsPath: "metrics", Labels: map[string]string{"config": string(i.config)}}}, nil
+}
+
+func (i *testIntegration) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	w.Header().Set("X-Test", r.Header.Get("X-Test"))
+	w.WriteHeader(http.StatusTeapot)
+	fmt.Fprintf(w, "%s %s %s", r.Method, r.URL, body)
+}
+
+func TestIntegration_GRPC(t *testing.T) {
+	impl := &testIntegration{}
+	client, server := goplugin.TestPluginGRPCConn(t, Plugins(impl))
+	defer client.Close()
+	defer server.Stop()
+
+	raw, err := client.Dispense(PluginName)
+	require.NoError(t, err)
+	i := raw.(Integration)
+
+	ctx := context.Background()
+	require.EqualError(t, i.Configure(ctx, []byte("invalid: true"), Globals{}), "rpc error: code = Unknown desc = invalid config")
+	require.NoError(t, i.Configure(ctx, []byte("foo: bar"), Globals{AgentIdentifier: "agent-1"}))
+	require.Equal(t, Globals{AgentIdentifier: "agent-1"}, impl.globals)
+
+	targets, err := i.Targets(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []Target{{MetricsPath: "metrics", Labels: map[string]string{"config": "foo: bar"}}}, targets)
+
+	req := httptest.NewRequest(http.MethodPost, "/metrics?debug=1", strings.NewReader("hello"))
+	req.Header.Set("X-Test", "header")
+	rec := httptest.NewRecorder()
+	i.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusTeapot, rec.Code)
+	require.Equal(t, "header", rec.Header().Get("X-Test"))
+	require.Equal(t, "POST /metrics?debug=1 hello", rec.Body.String())
+}
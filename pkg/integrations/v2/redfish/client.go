@@ -0,0 +1,190 @@
+package redfish
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Paths of the Redfish collections which are polled.
+const (
+	chassisPath = "/redfish/v1/Chassis"
+	systemsPath = "/redfish/v1/Systems"
+)
+
+// client retrieves resources from the Redfish API of a single BMC.
+type client struct {
+	http *http.Client
+	bmc  BMCConfig
+	base *url.URL
+}
+
+func newClient(hc *http.Client, bmc BMCConfig) (*client, error) {
+	base, err := url.Parse(bmc.Address)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse url: %w", err)
+	}
+	return &client{http: hc, bmc: bmc, base: base}, nil
+}
+
+// password returns the password of the BMC, reading it from password_file if
+// set.
+func (c *client) password() (string, error) {
+	if c.bmc.PasswordFile == "" {
+		return string(c.bmc.Password), nil
+	}
+	b, err := os.ReadFile(c.bmc.PasswordFile)
+	if err != nil {
+		return "", fmt.Errorf("reading password_file: %w", err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// get retrieves the resource at path and decodes it into v.
+func (c *client) get(ctx context.Context, password, path string, v interface{}) error {
+	u := c.base.ResolveReference(&url.URL{Path: path})
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.bmc.Username != "" {
+		req.SetBasicAuth(c.bmc.Username, password)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d for %s", resp.StatusCode, path)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decoding %s: %w", path, err)
+	}
+	return nil
+}
+
+// Poll retrieves the health of all systems and chassis managed by the BMC.
+func (c *client) Poll(ctx context.Context) (*hardware, error) {
+	password, err := c.password()
+	if err != nil {
+		return nil, err
+	}
+
+	var hw hardware
+
+	var systems collection
+	if err := c.get(ctx, password, systemsPath, &systems); err != nil {
+		return nil, err
+	}
+	for _, m := range systems.Members {
+		var s system
+		if err := c.get(ctx, password, m.ID, &s); err != nil {
+			return nil, err
+		}
+		hw.Systems = append(hw.Systems, s)
+	}
+
+	var chassis collection
+	if err := c.get(ctx, password, chassisPath, &chassis); err != nil {
+		return nil, err
+	}
+	for _, m := range chassis.Members {
+		ch := chassisHealth{}
+		if err := c.get(ctx, password, m.ID, &ch.Chassis); err != nil {
+			return nil, err
+		}
+		// Chassis without sensors, such as enclosures of a blade server, don't
+		// link to thermal or power resources.
+		if ch.Chassis.Thermal.ID != "" {
+			if err := c.get(ctx, password, ch.Chassis.Thermal.ID, &ch.Thermal); err != nil {
+				return nil, err
+			}
+		}
+		if ch.Chassis.Power.ID != "" {
+			if err := c.get(ctx, password, ch.Chassis.Power.ID, &ch.Power); err != nil {
+				return nil, err
+			}
+		}
+		hw.Chassis = append(hw.Chassis, ch)
+	}
+
+	return &hw, nil
+}
+
+// hardware is the health of the hardware managed by a BMC.
+type hardware struct {
+	Systems []system
+	Chassis []chassisHealth
+}
+
+type chassisHealth struct {
+	Chassis chassis
+	Thermal thermal
+	Power   power
+}
+
+// The types below hold the subset of the Redfish schema which is collected.
+// See https://www.dmtf.org/standards/redfish for the full schema.
+
+type link struct {
+	ID string `json:"@odata.id"`
+}
+
+type collection struct {
+	Members []link `json:"Members"`
+}
+
+type status struct {
+	// State is the state of the resource, such as Enabled or Absent.
+	State string `json:"State"`
+	// Health is one of OK, Warning, or Critical. It's empty for resources
+	// which don't report health, such as absent sensors.
+	Health string `json:"Health"`
+}
+
+type system struct {
+	ID         string `json:"Id"`
+	PowerState string `json:"PowerState"`
+	Status     status `json:"Status"`
+}
+
+type chassis struct {
+	ID      string `json:"Id"`
+	Status  status `json:"Status"`
+	Thermal link   `json:"Thermal"`
+	Power   link   `json:"Power"`
+}
+
+type thermal struct {
+	Temperatures []struct {
+		Name           string   `json:"Name"`
+		ReadingCelsius *float64 `json:"ReadingCelsius"`
+		Status         status   `json:"Status"`
+	} `json:"Temperatures"`
+	Fans []struct {
+		Name         string   `json:"Name"`
+		Reading      *float64 `json:"Reading"`
+		ReadingUnits string   `json:"ReadingUnits"`
+		Status       status   `json:"Status"`
+	} `json:"Fans"`
+}
+
+type power struct {
+	PowerControl []struct {
+		Name               string   `json:"Name"`
+		PowerConsumedWatts *float64 `json:"PowerConsumedWatts"`
+	} `json:"PowerControl"`
+	PowerSupplies []struct {
+		Name                 string   `json:"Name"`
+		LastPowerOutputWatts *float64 `json:"LastPowerOutputWatts"`
+		Status               status   `json:"Status"`
+	} `json:"PowerSupplies"`
+}
@@ -0,0 +1,224 @@
+package redfish
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	config_util "github.com/prometheus/common/config"
+)
+
+// healthStates are the health states a Redfish resource can be reported in.
+var healthStates = []string{"OK", "Warning", "Critical"}
+
+var (
+	upDesc       = newDesc("up", "Whether the last poll of the BMC succeeded.", []string{"bmc"})
+	lastPollDesc = newDesc("last_poll_timestamp_seconds", "Time of the last poll of the BMC, in seconds since the Unix epoch.", []string{"bmc"})
+
+	systemPowerOnDesc = newDesc("system_power_on", "Whether the system is powered on.", []string{"bmc", "system"})
+	systemHealthDesc  = newDesc("system_health", "Health state of the system; 1 for the current state.", []string{"bmc", "system", "state"})
+
+	chassisHealthDesc      = newDesc("chassis_health", "Health state of the chassis; 1 for the current state.", []string{"bmc", "chassis", "state"})
+	temperatureDesc        = newDesc("temperature_celsius", "Reading of the temperature sensor.", []string{"bmc", "chassis", "sensor"})
+	temperatureHealthDesc  = newDesc("temperature_health", "Health state of the temperature sensor; 1 for the current state.", []string{"bmc", "chassis", "sensor", "state"})
+	fanReadingDesc         = newDesc("fan_reading", "Speed of the fan, in the units of the units label.", []string{"bmc", "chassis", "fan", "units"})
+	fanHealthDesc          = newDesc("fan_health", "Health state of the fan; 1 for the current state.", []string{"bmc", "chassis", "fan", "state"})
+	powerConsumedDesc      = newDesc("power_consumed_watts", "Power consumed by the chassis.", []string{"bmc", "chassis", "control"})
+	powerSupplyOutputDesc  = newDesc("power_supply_output_watts", "Last output power of the power supply.", []string{"bmc", "chassis", "power_supply"})
+	powerSupplyHealthDesc  = newDesc("power_supply_health", "Health state of the power supply; 1 for the current state.", []string{"bmc", "chassis", "power_supply", "state"})
+	powerSupplyPresentDesc = newDesc("power_supply_present", "Whether the power supply is installed.", []string{"bmc", "chassis", "power_supply"})
+)
+
+func newDesc(name, help string, labels []string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName("redfish", "", name), help, labels, nil)
+}
+
+// collector polls BMCs in the background and exposes the results of the last
+// poll of each BMC.
+type collector struct {
+	log          log.Logger
+	clients      []*client
+	pollInterval time.Duration
+	timeout      time.Duration
+
+	mut     sync.RWMutex
+	results map[string]pollResult // Last poll result by BMC name
+}
+
+// pollResult is the outcome of polling a BMC.
+type pollResult struct {
+	time time.Time
+	hw   *hardware // nil if the poll failed
+}
+
+var _ prometheus.Collector = (*collector)(nil)
+
+func newCollector(l log.Logger, c *Config) (*collector, error) {
+	tlsConfig, err := config_util.NewTLSConfig(&c.TLSConfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tls_config: %w", err)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	hc := &http.Client{Transport: transport}
+
+	clients := make([]*client, 0, len(c.BMCs))
+	for _, bmc := range c.BMCs {
+		cli, err := newClient(hc, bmc)
+		if err != nil {
+			return nil, fmt.Errorf("bmc %s: %w", bmc.Name, err)
+		}
+		clients = append(clients, cli)
+	}
+
+	return &collector{
+		log:          l,
+		clients:      clients,
+		pollInterval: c.PollInterval,
+		timeout:      c.Timeout,
+		results:      make(map[string]pollResult, len(clients)),
+	}, nil
+}
+
+// Run polls all BMCs immediately and then once every poll interval until ctx
+// is canceled.
+func (c *collector) Run(ctx context.Context) {
+	t := time.NewTicker(c.pollInterval)
+	defer t.Stop()
+
+	for {
+		c.poll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// poll polls all BMCs concurrently and waits for all polls to finish.
+func (c *collector) poll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, cli := range c.clients {
+		wg.Add(1)
+		go func(cli *client) {
+			defer wg.Done()
+			c.pollBMC(ctx, cli)
+		}(cli)
+	}
+	wg.Wait()
+}
+
+func (c *collector) pollBMC(ctx context.Context, cli *client) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	hw, err := cli.Poll(ctx)
+	if err != nil {
+		if ctx.Err() != nil && ctx.Err() != context.DeadlineExceeded {
+			// The integration is shutting down.
+			return
+		}
+		level.Error(c.log).Log("msg", "failed to poll BMC", "bmc", cli.bmc.Name, "err", err)
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.results[cli.bmc.Name] = pollResult{time: time.Now(), hw: hw}
+}
+
+// Describe implements prometheus.Collector.
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		upDesc, lastPollDesc,
+		systemPowerOnDesc, systemHealthDesc,
+		chassisHealthDesc, temperatureDesc, temperatureHealthDesc, fanReadingDesc, fanHealthDesc,
+		powerConsumedDesc, powerSupplyOutputDesc, powerSupplyHealthDesc, powerSupplyPresentDesc,
+	} {
+		ch <- d
+	}
+}
+
+// Collect implements prometheus.Collector. BMCs which haven't been polled yet
+// aren't reported, and BMCs whose last poll failed only report redfish_up.
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+
+	for bmc, res := range c.results {
+		up := 0.0
+		if res.hw != nil {
+			up = 1
+		}
+		ch <- prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, up, bmc)
+		ch <- prometheus.MustNewConstMetric(lastPollDesc, prometheus.GaugeValue, float64(res.time.UnixNano())/1e9, bmc)
+
+		if res.hw != nil {
+			collectHardware(ch, bmc, res.hw)
+		}
+	}
+}
+
+func collectHardware(ch chan<- prometheus.Metric, bmc string, hw *hardware) {
+	for _, s := range hw.Systems {
+		ch <- prometheus.MustNewConstMetric(systemPowerOnDesc, prometheus.GaugeValue, boolToFloat(strings.EqualFold(s.PowerState, "On")), bmc, s.ID)
+		collectHealth(ch, systemHealthDesc, s.Status, bmc, s.ID)
+	}
+
+	for _, c := range hw.Chassis {
+		id := c.Chassis.ID
+		collectHealth(ch, chassisHealthDesc, c.Chassis.Status, bmc, id)
+
+		for _, t := range c.Thermal.Temperatures {
+			if t.ReadingCelsius != nil {
+				ch <- prometheus.MustNewConstMetric(temperatureDesc, prometheus.GaugeValue, *t.ReadingCelsius, bmc, id, t.Name)
+			}
+			collectHealth(ch, temperatureHealthDesc, t.Status, bmc, id, t.Name)
+		}
+		for _, f := range c.Thermal.Fans {
+			if f.Reading != nil {
+				ch <- prometheus.MustNewConstMetric(fanReadingDesc, prometheus.GaugeValue, *f.Reading, bmc, id, f.Name, f.ReadingUnits)
+			}
+			collectHealth(ch, fanHealthDesc, f.Status, bmc, id, f.Name)
+		}
+
+		for _, p := range c.Power.PowerControl {
+			if p.PowerConsumedWatts != nil {
+				ch <- prometheus.MustNewConstMetric(powerConsumedDesc, prometheus.GaugeValue, *p.PowerConsumedWatts, bmc, id, p.Name)
+			}
+		}
+		for _, p := range c.Power.PowerSupplies {
+			present := !strings.EqualFold(p.Status.State, "Absent")
+			ch <- prometheus.MustNewConstMetric(powerSupplyPresentDesc, prometheus.GaugeValue, boolToFloat(present), bmc, id, p.Name)
+			if p.LastPowerOutputWatts != nil {
+				ch <- prometheus.MustNewConstMetric(powerSupplyOutputDesc, prometheus.GaugeValue, *p.LastPowerOutputWatts, bmc, id, p.Name)
+			}
+			collectHealth(ch, powerSupplyHealthDesc, p.Status, bmc, id, p.Name)
+		}
+	}
+}
+
+// collectHealth reports the health of a resource as one series per health
+// state. Nothing is reported for resources which don't report their health.
+func collectHealth(ch chan<- prometheus.Metric, desc *prometheus.Desc, s status, labels ...string) {
+	if s.Health == "" {
+		return
+	}
+	for _, state := range healthStates {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, boolToFloat(s.Health == state), append(labels, state)...)
+	}
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,254 @@
+package redfish
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+// resources are the responses of a BMC managing a single server with one
+// temperature sensor, one fan, and two power supplies, one of which is
+// missing.
+var resources = map[string]string{
+	"/redfish/v1/Systems":   `{"Members": [{"@odata.id": "/redfish/v1/Systems/1"}]}`,
+	"/redfish/v1/Systems/1": `{"Id": "1", "PowerState": "On", "Status": {"State": "Enabled", "Health": "OK"}}`,
+	"/redfish/v1/Chassis":   `{"Members": [{"@odata.id": "/redfish/v1/Chassis/1"}]}`,
+	"/redfish/v1/Chassis/1": `{
+		"Id": "1",
+		"Status": {"State": "Enabled", "Health": "Warning"},
+		"Thermal": {"@odata.id": "/redfish/v1/Chassis/1/Thermal"},
+		"Power": {"@odata.id": "/redfish/v1/Chassis/1/Power"}
+	}`,
+	"/redfish/v1/Chassis/1/Thermal": `{
+		"Temperatures": [{"Name": "CPU1 Temp", "ReadingCelsius": 41, "Status": {"State": "Enabled", "Health": "OK"}}],
+		"Fans": [{"Name": "Fan1", "Reading": 5400, "ReadingUnits": "RPM", "Status": {"State": "Enabled", "Health": "OK"}}]
+	}`,
+	"/redfish/v1/Chassis/1/Power": `{
+		"PowerControl": [{"Name": "System Power Control", "PowerConsumedWatts": 212}],
+		"PowerSupplies": [
+			{"Name": "PSU1", "LastPowerOutputWatts": 215, "Status": {"State": "Enabled", "Health": "Critical"}},
+			{"Name": "PSU2", "Status": {"State": "Absent"}}
+		]
+	}`,
+}
+
+func newBMC(t *testing.T, username, password string) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, _ := r.BasicAuth(); u != username || p != password {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		resp, ok := resources[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(resp))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestCollector(t *testing.T) {
+	bmc := newBMC(t, "admin", "secret")
+
+	c, err := newCollector(log.NewNopLogger(), &Config{
+		BMCs:         []BMCConfig{{Name: "node1", Address: bmc.URL, Username: "admin", Password: "secret"}},
+		PollInterval: time.Minute,
+		Timeout:      time.Second,
+	})
+	require.NoError(t, err)
+
+	reg := prometheus.NewRegistry()
+	require.NoError(t, reg.Register(c))
+
+	// Nothing is reported before the first poll.
+	count, err := testutil.GatherAndCount(reg)
+	require.NoError(t, err)
+	require.Zero(t, count)
+
+	c.poll(context.Background())
+
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP redfish_chassis_health Health state of the chassis; 1 for the current state.
+		# TYPE redfish_chassis_health gauge
+		redfish_chassis_health{bmc="node1",chassis="1",state="Critical"} 0
+		redfish_chassis_health{bmc="node1",chassis="1",state="OK"} 0
+		redfish_chassis_health{bmc="node1",chassis="1",state="Warning"} 1
+		# HELP redfish_fan_health Health state of the fan; 1 for the current state.
+		# TYPE redfish_fan_health gauge
+		redfish_fan_health{bmc="node1",chassis="1",fan="Fan1",state="Critical"} 0
+		redfish_fan_health{bmc="node1",chassis="1",fan="Fan1",state="OK"} 1
+		redfish_fan_health{bmc="node1",chassis="1",fan="Fan1",state="Warning"} 0
+		# HELP redfish_fan_reading Speed of the fan, in the units of the units label.
+		# TYPE redfish_fan_reading gauge
+		redfish_fan_reading{bmc="node1",chassis="1",fan="Fan1",units="RPM"} 5400
+		# HELP redfish_power_consumed_watts Power consumed by the chassis.
+		# TYPE redfish_power_consumed_watts gauge
+		redfish_power_consumed_watts{bmc="node1",chassis="1",control="System Power Control"} 212
+		# HELP redfish_power_supply_health Health state of the power supply; 1 for the current state.
+		# TYPE redfish_power_supply_health gauge
+		redfish_power_supply_health{bmc="node1",chassis="1",power_supply="PSU1",state="Critical"} 1
+		redfish_power_supply_health{bmc="node1",chassis="1",power_supply="PSU1",state="OK"} 0
+		redfish_power_supply_health{bmc="node1",chassis="1",power_supply="PSU1",state="Warning"} 0
+		# HELP redfish_power_supply_output_watts Last output power of the power supply.
+		# TYPE redfish_power_supply_output_watts gauge
+		redfish_power_supply_output_watts{bmc="node1",chassis="1",power_supply="PSU1"} 215
+		# HELP redfish_power_supply_present Whether the power supply is installed.
+		# TYPE redfish_power_supply_present gauge
+		redfish_power_supply_present{bmc="node1",chassis="1",power_supply="PSU1"} 1
+		redfish_power_supply_present{bmc="node1",chassis="1",power_supply="PSU2"} 0
+		# HELP redfish_system_health Health state of the system; 1 for the current state.
+		# TYPE redfish_system_health gauge
+		redfish_system_health{bmc="node1",state="Critical",system="1"} 0
+		redfish_system_health{bmc="node1",state="OK",system="1"} 1
+		redfish_system_health{bmc="node1",state="Warning",system="1"} 0
+		# HELP redfish_system_power_on Whether the system is powered on.
+		# TYPE redfish_system_power_on gauge
+		redfish_system_power_on{bmc="node1",system="1"} 1
+		# HELP redfish_temperature_celsius Reading of the temperature sensor.
+		# TYPE redfish_temperature_celsius gauge
+		redfish_temperature_celsius{bmc="node1",chassis="1",sensor="CPU1 Temp"} 41
+		# HELP redfish_temperature_health Health state of the temperature sensor; 1 for the current state.
+		# TYPE redfish_temperature_health gauge
+		redfish_temperature_health{bmc="node1",chassis="1",sensor="CPU1 Temp",state="Critical"} 0
+		redfish_temperature_health{bmc="node1",chassis="1",sensor="CPU1 Temp",state="OK"} 1
+		redfish_temperature_health{bmc="node1",chassis="1",sensor="CPU1 Temp",state="Warning"} 0
+		# HELP redfish_up Whether the last poll of the BMC succeeded.
+		# TYPE redfish_up gauge
+		redfish_up{bmc="node1"} 1
+	`), "redfish_chassis_health", "redfish_fan_health", "redfish_fan_reading", "redfish_power_consumed_watts",
+		"redfish_power_supply_health", "redfish_power_supply_output_watts", "redfish_power_supply_present",
+		"redfish_system_health", "redfish_system_power_on", "redfish_temperature_celsius",
+		"redfish_temperature_health", "redfish_up",
+	))
+}
+
+func TestCollector_Credentials(t *testing.T) {
+	bmc := newBMC(t, "admin", "secret")
+
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(passwordFile, []byte("secret\n"), 0600))
+
+	c, err := newCollector(log.NewNopLogger(), &Config{
+		BMCs: []BMCConfig{
+			{Name: "node1", Address: bmc.URL, Username: "admin", PasswordFile: passwordFile},
+			{Name: "node2", Address: bmc.URL, Username: "admin", Password: "wrong"},
+		},
+		PollInterval: time.Minute,
+		Timeout:      time.Second,
+	})
+	require.NoError(t, err)
+
+	reg := prometheus.NewRegistry()
+	require.NoError(t, reg.Register(c))
+	c.poll(context.Background())
+
+	// BMCs whose poll failed only report redfish_up and the time of the poll.
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP redfish_system_power_on Whether the system is powered on.
+		# TYPE redfish_system_power_on gauge
+		redfish_system_power_on{bmc="node1",system="1"} 1
+		# HELP redfish_up Whether the last poll of the BMC succeeded.
+		# TYPE redfish_up gauge
+		redfish_up{bmc="node1"} 1
+		redfish_up{bmc="node2"} 0
+	`), "redfish_system_power_on", "redfish_up"))
+}
+
+func TestCollector_Run(t *testing.T) {
+	bmc := newBMC(t, "", "")
+
+	c, err := newCollector(log.NewNopLogger(), &Config{
+		BMCs:         []BMCConfig{{Name: "node1", Address: bmc.URL}},
+		PollInterval: 10 * time.Millisecond,
+		Timeout:      time.Second,
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.Run(ctx)
+	}()
+
+	lastPoll := func() time.Time {
+		c.mut.RLock()
+		defer c.mut.RUnlock()
+		return c.results["node1"].time
+	}
+
+	// Wait for at least two polls to make sure BMCs are polled periodically.
+	require.Eventually(t, func() bool { return !lastPoll().IsZero() }, 5*time.Second, 10*time.Millisecond)
+	first := lastPoll()
+	require.Eventually(t, func() bool { return lastPoll().After(first) }, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+}
+
+func TestConfig_UnmarshalYAML(t *testing.T) {
+	var cfg Config
+	require.NoError(t, yaml.Unmarshal([]byte(`
+bmcs:
+- address: https://10.0.0.1
+  username: admin
+  password: secret
+- name: node2
+  address: https://10.0.0.2
+`), &cfg))
+	require.Equal(t, DefaultConfig.PollInterval, cfg.PollInterval)
+	require.Equal(t, "10.0.0.1", cfg.BMCs[0].Name)
+	require.Equal(t, "node2", cfg.BMCs[1].Name)
+
+	key, err := cfg.InstanceKey("agent")
+	require.NoError(t, err)
+	require.Equal(t, "10.0.0.1", key)
+
+	tt := []struct {
+		name, cfg, err string
+	}{
+		{
+			name: "no bmcs",
+			cfg:  `bmcs: []`,
+			err:  "at least one bmcs entry must be provided",
+		},
+		{
+			name: "invalid address",
+			cfg:  `bmcs: [{address: 10.0.0.1}]`,
+			err:  "bmcs[0]: address must be a URL",
+		},
+		{
+			name: "password and password_file",
+			cfg:  `bmcs: [{address: "https://10.0.0.1", password: a, password_file: /b}]`,
+			err:  "bmcs[0]: at most one of password and password_file may be set",
+		},
+		{
+			name: "duplicate names",
+			cfg:  `bmcs: [{address: "https://10.0.0.1"}, {address: "https://10.0.0.1"}]`,
+			err:  `bmcs[1]: found multiple BMCs named "10.0.0.1"`,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var cfg Config
+			err := yaml.Unmarshal([]byte(tc.cfg), &cfg)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.err)
+		})
+	}
+}
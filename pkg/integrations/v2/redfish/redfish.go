@@ -0,0 +1,157 @@
+// Package redfish collects hardware health metrics, such as power state,
+// temperatures, fans, and power supplies, from the Redfish API of baseboard
+// management controllers (BMCs).
+package redfish
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/go-kit/log"
+	integrations_v2 "github.com/grafana/agent/pkg/integrations/v2"
+	"github.com/grafana/agent/pkg/integrations/v2/common"
+	"github.com/grafana/agent/pkg/integrations/v2/metricsutils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	config_util "github.com/prometheus/common/config"
+)
+
+// DefaultConfig holds the default settings for the redfish integration.
+var DefaultConfig = Config{
+	PollInterval: 5 * time.Minute,
+	Timeout:      30 * time.Second,
+}
+
+// Config controls the redfish integration.
+type Config struct {
+	// BMCs are the management controllers to collect hardware health from.
+	BMCs []BMCConfig `yaml:"bmcs,omitempty"`
+	// PollInterval is how often BMCs are polled. BMCs are often slow to
+	// respond and easily overloaded, so they're polled in the background and
+	// scrapes are served the results of the last poll.
+	PollInterval time.Duration         `yaml:"poll_interval,omitempty"`
+	TLSConfig    config_util.TLSConfig `yaml:"tls_config,omitempty"`
+	// Timeout is the maximum time polling a single BMC may take.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+
+	Common common.MetricsConfig `yaml:",inline"`
+}
+
+// BMCConfig configures a single BMC to poll.
+type BMCConfig struct {
+	// Name identifies the BMC in the bmc label. Defaults to the host of
+	// Address.
+	Name string `yaml:"name,omitempty"`
+	// Address is the base URL of the BMC, such as https://10.0.0.1.
+	Address  string             `yaml:"address,omitempty"`
+	Username string             `yaml:"username,omitempty"`
+	Password config_util.Secret `yaml:"password,omitempty"`
+	// PasswordFile is read on every poll so that rotated credentials, such as
+	// mounted Kubernetes secrets, are picked up without a reload.
+	PasswordFile string `yaml:"password_file,omitempty"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler for Config.
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultConfig
+
+	type plain Config
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	if len(c.BMCs) == 0 {
+		return fmt.Errorf("at least one bmcs entry must be provided")
+	}
+	if c.PollInterval <= 0 {
+		return fmt.Errorf("poll_interval must be greater than 0")
+	}
+
+	names := make(map[string]struct{}, len(c.BMCs))
+	for i := range c.BMCs {
+		bmc := &c.BMCs[i]
+		u, err := url.Parse(bmc.Address)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("bmcs[%d]: address must be a URL such as https://10.0.0.1", i)
+		}
+		if bmc.Password != "" && bmc.PasswordFile != "" {
+			return fmt.Errorf("bmcs[%d]: at most one of password and password_file may be set", i)
+		}
+		if bmc.Name == "" {
+			bmc.Name = u.Host
+		}
+		if _, exist := names[bmc.Name]; exist {
+			return fmt.Errorf("bmcs[%d]: found multiple BMCs named %q", i, bmc.Name)
+		}
+		names[bmc.Name] = struct{}{}
+	}
+	return nil
+}
+
+// ApplyDefaults applies the integration's default configuration.
+func (c *Config) ApplyDefaults(globals integrations_v2.Globals) error {
+	c.Common.ApplyDefaults(globals.SubsystemOpts.Metrics.Autoscrape)
+	if id, err := c.Identifier(globals); err == nil {
+		c.Common.InstanceKey = &id
+	}
+	return nil
+}
+
+// Identifier returns a string that identifies the integration.
+func (c *Config) Identifier(globals integrations_v2.Globals) (string, error) {
+	if c.Common.InstanceKey != nil {
+		return *c.Common.InstanceKey, nil
+	}
+	return c.InstanceKey(globals.AgentIdentifier)
+}
+
+// Name returns the name of the integration.
+func (c *Config) Name() string {
+	return "redfish"
+}
+
+// InstanceKey returns the name of the first BMC.
+func (c *Config) InstanceKey(agentKey string) (string, error) {
+	if len(c.BMCs) == 0 {
+		return agentKey, nil
+	}
+	return c.BMCs[0].Name, nil
+}
+
+// NewIntegration creates a new redfish integration.
+func (c *Config) NewIntegration(l log.Logger, globals integrations_v2.Globals) (integrations_v2.Integration, error) {
+	col, err := newCollector(l, c)
+	if err != nil {
+		return nil, err
+	}
+
+	reg := prometheus.NewRegistry()
+	if err := reg.Register(col); err != nil {
+		return nil, err
+	}
+
+	h := promhttp.HandlerFor(reg, promhttp.HandlerOpts{})
+	mi, err := metricsutils.NewMetricsHandlerIntegration(l, c, c.Common, globals, h)
+	if err != nil {
+		return nil, err
+	}
+	return &integration{MetricsIntegration: mi, collector: col}, nil
+}
+
+func init() {
+	integrations_v2.Register(&Config{}, integrations_v2.TypeMultiplex)
+}
+
+// integration polls BMCs in the background for as long as it runs.
+type integration struct {
+	integrations_v2.MetricsIntegration
+	collector *collector
+}
+
+// RunIntegration implements integrations_v2.Integration.
+func (i *integration) RunIntegration(ctx context.Context) error {
+	i.collector.Run(ctx)
+	return nil
+}
@@ -272,6 +272,17 @@ func MarshalYAML(v interface{}) (interface{}, error) {
 //
 //   https://github.com/prometheus/prometheus/blob/511511324adfc4f4178f064cc104c2deac3335de/discovery/registry.go#L111
 func UnmarshalYAML(out interface{}, unmarshal func(interface{}) error) error {
+	return unmarshalYAML(out, unmarshal, deferredConfigUnmarshal)
+}
+
+// configDecoder decodes the raw YAML of an integration into a Config. ref
+// must be either Config or v1.Config.
+type configDecoder func(raw util.RawYAML, ref interface{}) (Config, error)
+
+// unmarshalYAML implements UnmarshalYAML, decoding integrations with decode.
+// decode is called for each integration in the order they're appended to the
+// Configs field.
+func unmarshalYAML(out interface{}, unmarshal func(interface{}) error, decode configDecoder) error {
 	outVal := reflect.ValueOf(out)
 	if outVal.Kind() != reflect.Ptr {
 		return fmt.Errorf("integrations: can only unmarshal into a struct pointer, got %T", out)
@@ -349,7 +360,7 @@ func UnmarshalYAML(out interface{}, unmarshal func(interface{}) error) error {
 					continue
 				}
 				raw := field.Index(i).Interface().(*util.RawYAML)
-				c, err := deferredConfigUnmarshal(*raw, configReference)
+				c, err := decode(*raw, configReference)
 				if err != nil {
 					return err
 				}
@@ -362,7 +373,7 @@ func UnmarshalYAML(out interface{}, unmarshal func(interface{}) error) error {
 				return fmt.Errorf("integration %q not registered", configName)
 			}
 			raw := field.Interface().(*util.RawYAML)
-			c, err := deferredConfigUnmarshal(*raw, configReference)
+			c, err := decode(*raw, configReference)
 			if err != nil {
 				return err
 			}
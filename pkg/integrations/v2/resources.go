@@ -0,0 +1,205 @@
+package integrations
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Actions taken when an integration exceeds its resource limits.
+const (
+	// ResourceActionRestart restarts the integration.
+	ResourceActionRestart = "restart"
+	// ResourceActionMarkUnhealthy reports the integration as unhealthy
+	// through the agent_integration_healthy metric until its usage is under
+	// the limits again.
+	ResourceActionMarkUnhealthy = "mark_unhealthy"
+)
+
+var (
+	integrationCPUSeconds = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "agent_integration_cpu_seconds_total",
+		Help: "Total CPU time used by an integration running in its own process.",
+	}, []string{"integration_name", "instance"})
+	integrationMemoryBytes = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "agent_integration_memory_bytes",
+		Help: "Resident memory used by an integration running in its own process.",
+	}, []string{"integration_name", "instance"})
+	integrationLimitsExceeded = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "agent_integration_resource_limits_exceeded_total",
+		Help: "Total number of times an integration exceeded its resource limits.",
+	}, []string{"integration_name", "instance", "resource"})
+	integrationHealthy = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "agent_integration_healthy",
+		Help: "1 if an integration running in its own process is within its resource limits, 0 otherwise.",
+	}, []string{"integration_name", "instance"})
+	integrationPanics = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "agent_integration_panics_total",
+		Help: "Total number of times an integration panicked.",
+	}, []string{"integration_name", "instance"})
+)
+
+// resourceCheckInterval is how often the resource usage of integrations is
+// checked.
+var resourceCheckInterval = 15 * time.Second
+
+// ResourceUsage is the resources used by an integration.
+type ResourceUsage struct {
+	// CPUSeconds is the total CPU time used by the integration.
+	CPUSeconds float64
+	// MemoryBytes is the resident memory used by the integration.
+	MemoryBytes uint64
+}
+
+// ResourceLimits limits the resources used by an integration. Zero values
+// are unlimited.
+type ResourceLimits struct {
+	// CPU is the number of CPU cores the integration may use, averaged between
+	// checks.
+	CPU float64 `yaml:"cpu,omitempty"`
+	// MemoryBytes is the resident memory the integration may use.
+	MemoryBytes uint64 `yaml:"memory_bytes,omitempty"`
+	// Action is taken when the limits are exceeded.
+	Action string `yaml:"action,omitempty"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (l *ResourceLimits) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*l = ResourceLimits{Action: ResourceActionRestart}
+
+	type plain ResourceLimits
+	if err := unmarshal((*plain)(l)); err != nil {
+		return err
+	}
+
+	switch l.Action {
+	case ResourceActionRestart, ResourceActionMarkUnhealthy:
+	default:
+		return fmt.Errorf("invalid resource limits action %q, must be %q or %q", l.Action, ResourceActionRestart, ResourceActionMarkUnhealthy)
+	}
+	if l.CPU < 0 {
+		return fmt.Errorf("cpu limit must not be negative")
+	}
+	return nil
+}
+
+// ResourceIntegration is an Integration whose resource usage can be measured,
+// such as integrations running in their own process. Resource usage is
+// exposed as metrics and checked against the limits of the integration.
+//
+// Integrations running in the agent process share its resources, which can't
+// be accounted for per integration.
+type ResourceIntegration interface {
+	Integration
+
+	// ResourceUsage returns the resources currently used by the integration.
+	// It returns an error if the usage is unknown, such as when the integration
+	// isn't running yet.
+	ResourceUsage() (ResourceUsage, error)
+
+	// ResourceLimits returns the limits of the integration.
+	ResourceLimits() ResourceLimits
+}
+
+// resourceWatcher exposes the resource usage of an integration and enforces
+// its limits.
+type resourceWatcher struct {
+	log      log.Logger
+	ri       ResourceIntegration
+	labels   prometheus.Labels
+	lastCPU  float64
+	lastTime time.Time
+}
+
+func newResourceWatcher(l log.Logger, id integrationID, ri ResourceIntegration) *resourceWatcher {
+	return &resourceWatcher{
+		log:    l,
+		ri:     ri,
+		labels: prometheus.Labels{"integration_name": id.Name, "instance": id.Identifier},
+	}
+}
+
+// Run checks the resource usage of the integration until ctx is canceled.
+// restart is called when the integration must be restarted.
+func (w *resourceWatcher) Run(ctx context.Context, restart func()) {
+	t := time.NewTicker(resourceCheckInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			if w.check(now) {
+				restart()
+				return
+			}
+		}
+	}
+}
+
+// check updates the usage metrics and returns true if the integration must
+// be restarted.
+func (w *resourceWatcher) check(now time.Time) bool {
+	usage, err := w.ri.ResourceUsage()
+	if err != nil {
+		level.Debug(w.log).Log("msg", "failed to get integration resource usage", "err", err)
+		return false
+	}
+
+	// The CPU time starts from zero again when the integration restarts its
+	// process.
+	delta := usage.CPUSeconds - w.lastCPU
+	if delta < 0 {
+		delta = usage.CPUSeconds
+	}
+	integrationCPUSeconds.With(w.labels).Add(delta)
+	integrationMemoryBytes.With(w.labels).Set(float64(usage.MemoryBytes))
+
+	var exceeded []string
+	limits := w.ri.ResourceLimits()
+	if limits.CPU > 0 && !w.lastTime.IsZero() && delta/now.Sub(w.lastTime).Seconds() > limits.CPU {
+		exceeded = append(exceeded, "cpu")
+	}
+	if limits.MemoryBytes > 0 && usage.MemoryBytes > limits.MemoryBytes {
+		exceeded = append(exceeded, "memory")
+	}
+	w.lastCPU, w.lastTime = usage.CPUSeconds, now
+
+	if len(exceeded) == 0 {
+		integrationHealthy.With(w.labels).Set(1)
+		return false
+	}
+	for _, resource := range exceeded {
+		integrationLimitsExceeded.With(prometheus.Labels{
+			"integration_name": w.labels["integration_name"],
+			"instance":         w.labels["instance"],
+			"resource":         resource,
+		}).Inc()
+	}
+	level.Warn(w.log).Log("msg", "integration exceeded its resource limits", "resources", fmt.Sprint(exceeded), "action", limits.Action)
+
+	integrationHealthy.With(w.labels).Set(0)
+	return limits.Action == ResourceActionRestart
+}
+
+// deleteIntegrationMetrics removes the metrics of an integration which
+// stopped.
+func deleteIntegrationMetrics(id integrationID) {
+	labels := prometheus.Labels{"integration_name": id.Name, "instance": id.Identifier}
+	integrationCPUSeconds.Delete(labels)
+	integrationMemoryBytes.Delete(labels)
+	integrationHealthy.Delete(labels)
+	for _, resource := range []string{"cpu", "memory"} {
+		integrationLimitsExceeded.Delete(prometheus.Labels{
+			"integration_name": id.Name,
+			"instance":         id.Identifier,
+			"resource":         resource,
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package integrations
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/pkg/util"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+	"gopkg.in/yaml.v2"
+)
+
+type mockResourceIntegration struct {
+	Integration
+	usage  func() (ResourceUsage, error)
+	limits ResourceLimits
+}
+
+func (i mockResourceIntegration) ResourceUsage() (ResourceUsage, error) { return i.usage() }
+func (i mockResourceIntegration) ResourceLimits() ResourceLimits        { return i.limits }
+
+func TestResourceLimits_Unmarshal(t *testing.T) {
+	var l ResourceLimits
+	require.NoError(t, yaml.UnmarshalStrict([]byte("memory_bytes: 1024"), &l))
+	require.Equal(t, ResourceLimits{MemoryBytes: 1024, Action: ResourceActionRestart}, l)
+
+	err := yaml.UnmarshalStrict([]byte("action: kill"), &l)
+	require.EqualError(t, err, `invalid resource limits action "kill", must be "restart" or "mark_unhealthy"`)
+}
+
+func Test_resourceWatcher(t *testing.T) {
+	id := integrationID{Name: "resources", Identifier: t.Name()}
+	defer deleteIntegrationMetrics(id)
+
+	var usage ResourceUsage
+	ri := mockResourceIntegration{
+		Integration: NoOpIntegration,
+		usage:       func() (ResourceUsage, error) { return usage, nil },
+		limits:      ResourceLimits{CPU: 0.5, MemoryBytes: 1000, Action: ResourceActionMarkUnhealthy},
+	}
+	w := newResourceWatcher(util.TestLogger(t), id, ri)
+
+	now := time.Now()
+	usage = ResourceUsage{CPUSeconds: 10, MemoryBytes: 500}
+	require.False(t, w.check(now))
+	require.Equal(t, 10.0, testutil.ToFloat64(integrationCPUSeconds.WithLabelValues(id.Name, id.Identifier)))
+	require.Equal(t, 1.0, testutil.ToFloat64(integrationHealthy.WithLabelValues(id.Name, id.Identifier)))
+
+	// 6 CPU seconds in 10 seconds is over the limit of half a core.
+	usage = ResourceUsage{CPUSeconds: 16, MemoryBytes: 500}
+	require.False(t, w.check(now.Add(10*time.Second)))
+	require.Equal(t, 0.0, testutil.ToFloat64(integrationHealthy.WithLabelValues(id.Name, id.Identifier)))
+	require.Equal(t, 1.0, testutil.ToFloat64(integrationLimitsExceeded.WithLabelValues(id.Name, id.Identifier, "cpu")))
+
+	usage = ResourceUsage{CPUSeconds: 17, MemoryBytes: 500}
+	require.False(t, w.check(now.Add(20*time.Second)))
+	require.Equal(t, 1.0, testutil.ToFloat64(integrationHealthy.WithLabelValues(id.Name, id.Identifier)))
+
+	// Restarted integrations are restarted when they exceed their limits.
+	ri.limits.Action = ResourceActionRestart
+	w.ri = ri
+	usage = ResourceUsage{CPUSeconds: 17, MemoryBytes: 2000}
+	require.True(t, w.check(now.Add(30*time.Second)))
+	require.Equal(t, 1.0, testutil.ToFloat64(integrationLimitsExceeded.WithLabelValues(id.Name, id.Identifier, "memory")))
+	require.Equal(t, 17.0, testutil.ToFloat64(integrationCPUSeconds.WithLabelValues(id.Name, id.Identifier)))
+}
+
+func Test_workerPool_RestartsIntegrations(t *testing.T) {
+	defer func(interval time.Duration) { resourceCheckInterval = interval }(resourceCheckInterval)
+	resourceCheckInterval = 10 * time.Millisecond
+
+	var starts atomic.Int64
+	ri := mockResourceIntegration{
+		Integration: FuncIntegration(func(ctx context.Context) error {
+			starts.Inc()
+			<-ctx.Done()
+			return nil
+		}),
+		usage: func() (ResourceUsage, error) {
+			// Exceed the limits until the integration was restarted once.
+			if starts.Load() > 1 {
+				return ResourceUsage{MemoryBytes: 1}, nil
+			}
+			return ResourceUsage{MemoryBytes: 100}, nil
+		},
+		limits: ResourceLimits{MemoryBytes: 10, Action: ResourceActionRestart},
+	}
+
+	pool := newWorkerPool(context.Background(), util.TestLogger(t))
+	defer pool.Close()
+	pool.Reload([]*controlledIntegration{{id: integrationID{Name: "resources", Identifier: t.Name()}, i: ri}})
+
+	require.Eventually(t, func() bool { return starts.Load() == 2 }, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, int64(2), starts.Load())
+}
+
+func Test_workerPool_RecoversPanics(t *testing.T) {
+	ci := &controlledIntegration{
+		id: integrationID{Name: "panics", Identifier: t.Name()},
+		i: FuncIntegration(func(ctx context.Context) error {
+			panic(fmt.Sprintf("%s panicked", t.Name()))
+		}),
+	}
+
+	panics := integrationPanics.WithLabelValues(ci.id.Name, ci.id.Identifier)
+	before := testutil.ToFloat64(panics)
+
+	pool := newWorkerPool(context.Background(), util.TestLogger(t))
+	defer pool.Close()
+	pool.Reload([]*controlledIntegration{ci})
+
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(panics) == before+1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool { return !ci.Running() }, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,497 @@
+package integrations
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/gorilla/mux"
+	"github.com/grafana/agent/pkg/util"
+	config_util "github.com/prometheus/common/config"
+	"gopkg.in/yaml.v2"
+)
+
+// IntegrationsRuntimeEndpoint is the API endpoint where integrations managed
+// at runtime are created, updated, and deleted.
+const IntegrationsRuntimeEndpoint = "/agent/api/v1/integrations/instances"
+
+// maxRuntimeConfigSize is the maximum size of an integration config sent to
+// the runtime API.
+const maxRuntimeConfigSize = 1 << 20
+
+// RuntimeAPIConfig enables an HTTP API to manage integrations at runtime,
+// without reloading the config file.
+type RuntimeAPIConfig struct {
+	// DataPath is the file where integrations managed at runtime are persisted.
+	DataPath string `yaml:"data_path"`
+
+	// BearerToken or the contents of BearerTokenFile must be sent by clients
+	// of the API.
+	BearerToken     config_util.Secret `yaml:"bearer_token,omitempty"`
+	BearerTokenFile string             `yaml:"bearer_token_file,omitempty"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (c *RuntimeAPIConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = RuntimeAPIConfig{}
+
+	type plain RuntimeAPIConfig
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	if c.DataPath == "" {
+		return fmt.Errorf("runtime_api: data_path must be set")
+	}
+	if (c.BearerToken == "") == (c.BearerTokenFile == "") {
+		return fmt.Errorf("runtime_api: exactly one of bearer_token and bearer_token_file must be set")
+	}
+	return nil
+}
+
+// token returns the bearer token clients must send.
+func (c *RuntimeAPIConfig) token() (string, error) {
+	if c.BearerTokenFile == "" {
+		return string(c.BearerToken), nil
+	}
+	bb, err := os.ReadFile(c.BearerTokenFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read bearer token file: %w", err)
+	}
+	return strings.TrimSpace(string(bb)), nil
+}
+
+// RuntimeIntegration is an integration managed through the runtime API.
+type RuntimeIntegration struct {
+	Name     string `json:"name"`
+	Instance string `json:"instance"`
+	// Config is the YAML config of the integration. References to secrets
+	// are kept unresolved.
+	Config string `json:"config"`
+
+	cfg      Config
+	template *configTemplate // Set when the config references secrets
+}
+
+// parseRuntimeIntegration unmarshals the config of the integration name,
+// resolving its references to secrets with values. The identifier of the
+// resulting integration must be instance.
+func parseRuntimeIntegration(name, instance string, config []byte, globals Globals, values secretValues) (RuntimeIntegration, error) {
+	ref, ok := integrationByName[name]
+	if !ok {
+		return RuntimeIntegration{}, fmt.Errorf("integration %q not registered", name)
+	}
+	cfg, template, err := decodeConfig(util.RawYAML(config), ref, values)
+	if err != nil {
+		return RuntimeIntegration{}, fmt.Errorf("invalid config for integration %q: %w", name, err)
+	}
+
+	// Integrations which may only be defined once are already managed by the
+	// config file.
+	if ty, _ := RegisteredType(cfg); ty != TypeMultiplex {
+		return RuntimeIntegration{}, fmt.Errorf("integration %q may only be defined once and can't be managed at runtime", name)
+	}
+
+	id, err := cfg.Identifier(globals)
+	if err != nil {
+		return RuntimeIntegration{}, fmt.Errorf("could not build identifier for integration %q: %w", name, err)
+	}
+	if id != instance {
+		return RuntimeIntegration{}, fmt.Errorf("config of integration %q is for instance %q, expected %q; set the instance field of the config", name, id, instance)
+	}
+
+	// Normalize the config, which is persisted as YAML.
+	var normalized util.RawYAML
+	if err := yaml.Unmarshal(config, &normalized); err != nil {
+		return RuntimeIntegration{}, err
+	}
+	return RuntimeIntegration{Name: name, Instance: instance, Config: string(normalized), cfg: cfg, template: template}, nil
+}
+
+// runtimeFile is the format of the file where integrations managed at
+// runtime are persisted.
+type runtimeFile struct {
+	Integrations []runtimeFileEntry `yaml:"integrations"`
+}
+
+type runtimeFileEntry struct {
+	Name     string       `yaml:"name"`
+	Instance string       `yaml:"instance"`
+	Config   util.RawYAML `yaml:"config"`
+}
+
+// runtimeStore holds integrations managed at runtime, sorted by name and
+// instance.
+type runtimeStore struct {
+	path         string
+	integrations []RuntimeIntegration
+}
+
+// loadRuntimeStore loads the integrations persisted at path. A missing file
+// holds no integrations.
+func loadRuntimeStore(path string, globals Globals, values secretValues) (*runtimeStore, error) {
+	s := &runtimeStore{path: path}
+
+	bb, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read runtime integrations: %w", err)
+	}
+
+	var f runtimeFile
+	if err := yaml.UnmarshalStrict(bb, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse runtime integrations from %s: %w", path, err)
+	}
+	for _, e := range f.Integrations {
+		ri, err := parseRuntimeIntegration(e.Name, e.Instance, e.Config, globals, values)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load runtime integration %s/%s: %w", e.Name, e.Instance, err)
+		}
+		s.Put(ri)
+	}
+	return s, nil
+}
+
+// resolveSecrets decodes the integrations which reference the changed
+// secrets again with values. It returns the IDs of the updated integrations.
+func (s *runtimeStore) resolveSecrets(l log.Logger, changed map[string]struct{}, values secretValues) []integrationID {
+	var updated []integrationID
+	for i, ri := range s.integrations {
+		if ri.template == nil || !ri.template.references(changed) {
+			continue
+		}
+		cfg, _, err := decodeConfig(ri.template.raw, ri.template.ref, values)
+		if err != nil {
+			level.Warn(l).Log("msg", "failed to update runtime integration with changed secrets, keeping the previous config", "integration", ri.Name, "instance", ri.Instance, "err", err)
+			continue
+		}
+		s.integrations[i].cfg = cfg
+		updated = append(updated, integrationID{Name: ri.Name, Identifier: ri.Instance})
+	}
+	return updated
+}
+
+// Get returns the integration name/instance.
+func (s *runtimeStore) Get(name, instance string) (RuntimeIntegration, bool) {
+	i, found := s.find(name, instance)
+	if !found {
+		return RuntimeIntegration{}, false
+	}
+	return s.integrations[i], true
+}
+
+// List returns all integrations.
+func (s *runtimeStore) List() []RuntimeIntegration {
+	return append([]RuntimeIntegration{}, s.integrations...)
+}
+
+// Put creates or replaces ri. It returns the replaced integration, if any.
+func (s *runtimeStore) Put(ri RuntimeIntegration) (prev RuntimeIntegration, replaced bool) {
+	i, found := s.find(ri.Name, ri.Instance)
+	if found {
+		prev, s.integrations[i] = s.integrations[i], ri
+		return prev, true
+	}
+	s.integrations = append(s.integrations, RuntimeIntegration{})
+	copy(s.integrations[i+1:], s.integrations[i:])
+	s.integrations[i] = ri
+	return RuntimeIntegration{}, false
+}
+
+// Delete removes the integration name/instance. It returns the removed
+// integration, if any.
+func (s *runtimeStore) Delete(name, instance string) (RuntimeIntegration, bool) {
+	i, found := s.find(name, instance)
+	if !found {
+		return RuntimeIntegration{}, false
+	}
+	prev := s.integrations[i]
+	s.integrations = append(s.integrations[:i], s.integrations[i+1:]...)
+	return prev, true
+}
+
+// find returns the index of name/instance, or the index where it would be
+// inserted.
+func (s *runtimeStore) find(name, instance string) (int, bool) {
+	i := sort.Search(len(s.integrations), func(i int) bool {
+		ri := s.integrations[i]
+		if ri.Name != name {
+			return ri.Name >= name
+		}
+		return ri.Instance >= instance
+	})
+	found := i < len(s.integrations) && s.integrations[i].Name == name && s.integrations[i].Instance == instance
+	return i, found
+}
+
+// Save persists the integrations to the path of s.
+func (s *runtimeStore) Save() error {
+	var f runtimeFile
+	for _, ri := range s.integrations {
+		f.Integrations = append(f.Integrations, runtimeFileEntry{
+			Name:     ri.Name,
+			Instance: ri.Instance,
+			Config:   util.RawYAML(ri.Config),
+		})
+	}
+	bb, err := yaml.Marshal(f)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0750); err != nil {
+		return fmt.Errorf("failed to create directory for runtime integrations: %w", err)
+	}
+	// Write to a temporary file first so the file is never left partially
+	// written. Configs may hold secrets, so the file is only readable by the
+	// agent.
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, bb, 0600); err != nil {
+		return fmt.Errorf("failed to write runtime integrations: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to write runtime integrations: %w", err)
+	}
+	return nil
+}
+
+// runtimeConfigs returns the configs of the integrations managed at runtime
+// which don't conflict with the integrations in explicit. Integrations
+// defined in the config file take precedence.
+func runtimeConfigs(store *runtimeStore, explicit Configs, globals Globals) (Configs, []RuntimeIntegration) {
+	if store == nil {
+		return nil, nil
+	}
+
+	var (
+		res       Configs
+		conflicts []RuntimeIntegration
+	)
+	for _, ri := range store.List() {
+		if definesIntegration(explicit, ri.Name, ri.Instance, globals) {
+			conflicts = append(conflicts, ri)
+			continue
+		}
+		res = append(res, ri.cfg)
+	}
+	return res, conflicts
+}
+
+// definesIntegration returns true if configs defines the integration
+// name/instance.
+func definesIntegration(configs Configs, name, instance string, globals Globals) bool {
+	for _, c := range configs {
+		if c.Name() != name {
+			continue
+		}
+		if id, err := c.Identifier(globals); err == nil && id == instance {
+			return true
+		}
+	}
+	return false
+}
+
+// applyRuntimeAPIConfig loads the integrations managed at runtime when the
+// runtime API config changes. s.mut must be held.
+func (s *Subsystem) applyRuntimeAPIConfig(cfg *RuntimeAPIConfig, globals Globals) error {
+	if s.runtimeAPIConfig != nil && cfg != nil && s.runtimeAPIConfig.DataPath == cfg.DataPath {
+		s.runtimeAPIConfig = cfg
+		return nil
+	}
+	if cfg == nil {
+		s.runtimeAPIConfig, s.runtime = nil, nil
+		return nil
+	}
+
+	store, err := loadRuntimeStore(cfg.DataPath, globals, s.secretValues)
+	if err != nil {
+		return err
+	}
+	s.runtimeAPIConfig, s.runtime = cfg, store
+	return nil
+}
+
+// wireRuntimeAPI hooks up the runtime API to r.
+func (s *Subsystem) wireRuntimeAPI(r *mux.Router) {
+	r.Handle(IntegrationsRuntimeEndpoint, s.runtimeHandler(s.listRuntimeIntegrations)).Methods(http.MethodGet)
+
+	instancePath := IntegrationsRuntimeEndpoint + "/{name}/{instance}"
+	r.Handle(instancePath, s.runtimeHandler(s.getRuntimeIntegration)).Methods(http.MethodGet)
+	r.Handle(instancePath, s.runtimeHandler(s.putRuntimeIntegration)).Methods(http.MethodPut)
+	r.Handle(instancePath, s.runtimeHandler(s.deleteRuntimeIntegration)).Methods(http.MethodDelete)
+	r.PathPrefix(instancePath + "/control/").Handler(s.runtimeHandler(s.controlIntegration))
+}
+
+// runtimeHandler authenticates requests to the runtime API before passing
+// them to next. s.mut is held while next runs.
+func (s *Subsystem) runtimeHandler(next func(rw http.ResponseWriter, r *http.Request, store *runtimeStore)) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		s.mut.Lock()
+		defer s.mut.Unlock()
+
+		if s.runtimeAPIConfig == nil || s.runtime == nil {
+			http.Error(rw, "integrations runtime API is disabled", http.StatusNotFound)
+			return
+		}
+
+		token, err := s.runtimeAPIConfig.token()
+		if err != nil {
+			level.Error(s.logger).Log("msg", "failed to authenticate runtime API request", "err", err)
+			http.Error(rw, "failed to authenticate request", http.StatusInternalServerError)
+			return
+		}
+		if !validBearerToken(r, token) {
+			rw.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(rw, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next(rw, r, s.runtime)
+	})
+}
+
+// validBearerToken returns true if r is authenticated with token.
+func validBearerToken(r *http.Request, token string) bool {
+	const prefix = "Bearer "
+	auth := r.Header.Get("Authorization")
+	if token == "" || !strings.HasPrefix(auth, prefix) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, prefix)), []byte(token)) == 1
+}
+
+// controlIntegration passes requests to the control handler of a running
+// integration. Any integration may be controlled, including ones defined in
+// the config file.
+func (s *Subsystem) controlIntegration(rw http.ResponseWriter, r *http.Request, _ *runtimeStore) {
+	vars := mux.Vars(r)
+	i, ok := s.ctrl.Integration(vars["name"], vars["instance"])
+	if !ok {
+		http.Error(rw, "integration not found or not running", http.StatusNotFound)
+		return
+	}
+	ci, ok := i.(ControlIntegration)
+	if !ok {
+		http.Error(rw, fmt.Sprintf("integration %q can't be controlled at runtime", vars["name"]), http.StatusNotFound)
+		return
+	}
+
+	handler, err := ci.ControlHandler()
+	if err != nil {
+		level.Error(s.logger).Log("msg", "failed to generate integration control handler", "integration", vars["name"], "instance", vars["instance"], "err", err)
+		http.Error(rw, "failed to generate control handler", http.StatusInternalServerError)
+		return
+	}
+
+	prefix := path.Join(IntegrationsRuntimeEndpoint, vars["name"], vars["instance"], "control")
+	http.StripPrefix(prefix, handler).ServeHTTP(rw, r)
+}
+
+func (s *Subsystem) listRuntimeIntegrations(rw http.ResponseWriter, _ *http.Request, store *runtimeStore) {
+	writeRuntimeResponse(rw, http.StatusOK, store.List())
+}
+
+func (s *Subsystem) getRuntimeIntegration(rw http.ResponseWriter, r *http.Request, store *runtimeStore) {
+	vars := mux.Vars(r)
+	ri, ok := store.Get(vars["name"], vars["instance"])
+	if !ok {
+		http.Error(rw, "integration not found", http.StatusNotFound)
+		return
+	}
+	writeRuntimeResponse(rw, http.StatusOK, ri)
+}
+
+func (s *Subsystem) putRuntimeIntegration(rw http.ResponseWriter, r *http.Request, store *runtimeStore) {
+	vars := mux.Vars(r)
+	name, instance := vars["name"], vars["instance"]
+
+	config, err := io.ReadAll(http.MaxBytesReader(rw, r.Body, maxRuntimeConfigSize))
+	if err != nil {
+		http.Error(rw, fmt.Sprintf("failed to read config: %s", err), http.StatusBadRequest)
+		return
+	}
+	ri, err := parseRuntimeIntegration(name, instance, config, s.globals, s.secretValues)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if definesIntegration(s.globals.SubsystemOpts.Configs, name, instance, s.globals) {
+		http.Error(rw, fmt.Sprintf("integration %s/%s is defined in the config file", name, instance), http.StatusConflict)
+		return
+	}
+
+	prev, replaced := store.Put(ri)
+	revert := func() {
+		if replaced {
+			store.Put(prev)
+		} else {
+			store.Delete(name, instance)
+		}
+	}
+	if !s.applyRuntimeChange(rw, store, revert) {
+		return
+	}
+
+	level.Info(s.logger).Log("msg", "applied runtime integration", "integration", name, "instance", instance)
+	if replaced {
+		writeRuntimeResponse(rw, http.StatusOK, ri)
+	} else {
+		writeRuntimeResponse(rw, http.StatusCreated, ri)
+	}
+}
+
+func (s *Subsystem) deleteRuntimeIntegration(rw http.ResponseWriter, r *http.Request, store *runtimeStore) {
+	vars := mux.Vars(r)
+	name, instance := vars["name"], vars["instance"]
+
+	prev, ok := store.Delete(name, instance)
+	if !ok {
+		http.Error(rw, "integration not found", http.StatusNotFound)
+		return
+	}
+	if !s.applyRuntimeChange(rw, store, func() { store.Put(prev) }) {
+		return
+	}
+
+	level.Info(s.logger).Log("msg", "deleted runtime integration", "integration", name, "instance", instance)
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// applyRuntimeChange applies and persists a change to store. If either
+// fails, the change is reverted and an error is written to rw. s.mut must
+// be held.
+func (s *Subsystem) applyRuntimeChange(rw http.ResponseWriter, store *runtimeStore, revert func()) bool {
+	err := s.applyConfig(s.globals)
+	status := http.StatusBadRequest
+	if err == nil {
+		err = store.Save()
+		status = http.StatusInternalServerError
+	}
+	if err == nil {
+		return true
+	}
+
+	revert()
+	if err := s.applyConfig(s.globals); err != nil {
+		level.Error(s.logger).Log("msg", "failed to revert runtime integration change", "err", err)
+	}
+	http.Error(rw, err.Error(), status)
+	return false
+}
+
+func writeRuntimeResponse(rw http.ResponseWriter, status int, v interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	_ = json.NewEncoder(rw).Encode(v)
+}
@@ -0,0 +1,288 @@
+package integrations
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/gorilla/mux"
+	"github.com/grafana/agent/pkg/integrations/v2/autoscrape"
+	"github.com/grafana/agent/pkg/metrics/instance"
+	"github.com/grafana/agent/pkg/util"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestRuntimeAPIConfig_Unmarshal(t *testing.T) {
+	tt := []struct {
+		name        string
+		in          string
+		expectError string
+	}{
+		{name: "valid", in: "data_path: /tmp/integrations.yml\nbearer_token: secret"},
+		{name: "missing data_path", in: "bearer_token: secret", expectError: "runtime_api: data_path must be set"},
+		{name: "missing token", in: "data_path: /tmp/integrations.yml", expectError: "runtime_api: exactly one of bearer_token and bearer_token_file must be set"},
+		{name: "both tokens", in: "data_path: /tmp/integrations.yml\nbearer_token: secret\nbearer_token_file: /token", expectError: "runtime_api: exactly one of bearer_token and bearer_token_file must be set"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var c RuntimeAPIConfig
+			err := yaml.UnmarshalStrict([]byte(tc.in), &c)
+			if tc.expectError != "" {
+				require.EqualError(t, err, tc.expectError)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestRuntimeAPIConfig_TokenFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(path, []byte("secret\n"), 0600))
+
+	c := RuntimeAPIConfig{DataPath: "/tmp/integrations.yml", BearerTokenFile: path}
+	token, err := c.token()
+	require.NoError(t, err)
+	require.Equal(t, "secret", token)
+}
+
+func Test_runtimeStore(t *testing.T) {
+	setRegistered(t, map[Config]Type{
+		&testRuntimeIntegration{}: TypeMultiplex,
+		&testIntegrationA{}:       TypeSingleton,
+	})
+
+	path := filepath.Join(t.TempDir(), "integrations", "runtime.yml")
+	store, err := loadRuntimeStore(path, Globals{}, nil)
+	require.NoError(t, err)
+	require.Empty(t, store.List())
+
+	for _, instance := range []string{"b", "a", "c"} {
+		ri, err := parseRuntimeIntegration("runtime", instance, []byte("instance: "+instance), Globals{}, nil)
+		require.NoError(t, err)
+		store.Put(ri)
+	}
+	_, ok := store.Delete("runtime", "c")
+	require.True(t, ok)
+	require.NoError(t, store.Save())
+
+	loaded, err := loadRuntimeStore(path, Globals{}, nil)
+	require.NoError(t, err)
+	require.Equal(t, store.List(), loaded.List())
+	configs, _ := runtimeConfigs(loaded, nil, Globals{})
+	require.Equal(t, Configs{
+		&testRuntimeIntegration{Instance: "a"},
+		&testRuntimeIntegration{Instance: "b"},
+	}, configs)
+
+	t.Run("instance mismatch", func(t *testing.T) {
+		_, err := parseRuntimeIntegration("runtime", "a", []byte("instance: b"), Globals{}, nil)
+		require.EqualError(t, err, `config of integration "runtime" is for instance "b", expected "a"; set the instance field of the config`)
+	})
+
+	t.Run("singleton", func(t *testing.T) {
+		_, err := parseRuntimeIntegration("test", "integrationA", nil, Globals{}, nil)
+		require.EqualError(t, err, `integration "test" may only be defined once and can't be managed at runtime`)
+	})
+}
+
+func Test_runtimeConfigs(t *testing.T) {
+	store := &runtimeStore{}
+	store.Put(RuntimeIntegration{Name: "runtime", Instance: "a", cfg: &testRuntimeIntegration{Instance: "a"}})
+	store.Put(RuntimeIntegration{Name: "runtime", Instance: "b", cfg: &testRuntimeIntegration{Instance: "b"}})
+
+	configs, conflicts := runtimeConfigs(store, Configs{&testRuntimeIntegration{Instance: "b"}}, Globals{})
+	require.Equal(t, Configs{&testRuntimeIntegration{Instance: "a"}}, configs)
+	require.Len(t, conflicts, 1)
+	require.Equal(t, "b", conflicts[0].Instance)
+}
+
+func TestSubsystem_RuntimeAPI(t *testing.T) {
+	setRegistered(t, map[Config]Type{
+		&testRuntimeIntegration{}: TypeMultiplex,
+	})
+
+	path := filepath.Join(t.TempDir(), "runtime.yml")
+	s := newTestSubsystem(t, &RuntimeAPIConfig{DataPath: path, BearerToken: "secret"}, Configs{
+		&testRuntimeIntegration{Instance: "static"},
+	})
+
+	r := mux.NewRouter()
+	s.WireAPI(r)
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, IntegrationsRuntimeEndpoint+path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	require.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "", "", "").Code)
+	require.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "", "wrong", "").Code)
+
+	// Create and update an integration.
+	require.Equal(t, http.StatusCreated, do(http.MethodPut, "/runtime/db-1", "secret", "instance: db-1").Code)
+	require.Equal(t, http.StatusOK, do(http.MethodPut, "/runtime/db-1", "secret", "instance: db-1\ntext: updated").Code)
+	requireIntegrations(t, s, "runtime/db-1", "runtime/static")
+
+	rec := do(http.MethodGet, "/runtime/db-1", "secret", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"name":"runtime","instance":"db-1","config":"instance: db-1\ntext: updated\n"}`, rec.Body.String())
+
+	// Invalid and conflicting integrations are rejected.
+	require.Equal(t, http.StatusBadRequest, do(http.MethodPut, "/runtime/db-2", "secret", "instance: db-1").Code)
+	require.Equal(t, http.StatusBadRequest, do(http.MethodPut, "/runtime/db-2", "secret", "instance: db-2\nfail: true").Code)
+	require.Equal(t, http.StatusConflict, do(http.MethodPut, "/runtime/static", "secret", "instance: static").Code)
+	requireIntegrations(t, s, "runtime/db-1", "runtime/static")
+
+	// Integrations are persisted.
+	store, err := loadRuntimeStore(path, s.globals, nil)
+	require.NoError(t, err)
+	require.Len(t, store.List(), 1)
+
+	require.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/runtime/db-1", "secret", "").Code)
+	require.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/runtime/db-1", "secret", "").Code)
+	requireIntegrations(t, s, "runtime/static")
+
+	rec = do(http.MethodGet, "", "secret", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `[]`, rec.Body.String())
+}
+
+func TestSubsystem_RuntimeAPI_Control(t *testing.T) {
+	setRegistered(t, map[Config]Type{
+		&testRuntimeIntegration{}: TypeMultiplex,
+	})
+
+	s := newTestSubsystem(t, &RuntimeAPIConfig{DataPath: filepath.Join(t.TempDir(), "runtime.yml"), BearerToken: "secret"}, Configs{
+		&testRuntimeIntegration{Instance: "static", Control: true},
+		&testRuntimeIntegration{Instance: "uncontrolled"},
+	})
+
+	r := mux.NewRouter()
+	s.WireAPI(r)
+
+	do := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, IntegrationsRuntimeEndpoint+path, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	require.Eventually(t, func() bool {
+		return do("/runtime/static/control/collectors").Code == http.StatusOK
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, "/collectors", do("/runtime/static/control/collectors").Body.String())
+
+	require.Equal(t, http.StatusNotFound, do("/runtime/uncontrolled/control/collectors").Code)
+	require.Equal(t, http.StatusNotFound, do("/runtime/missing/control/collectors").Code)
+}
+
+func TestSubsystem_RuntimeAPI_Disabled(t *testing.T) {
+	s := newTestSubsystem(t, nil, nil)
+
+	r := mux.NewRouter()
+	s.WireAPI(r)
+
+	req := httptest.NewRequest(http.MethodGet, IntegrationsRuntimeEndpoint, nil)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+// newTestSubsystem creates a Subsystem which doesn't autoscrape integrations.
+func newTestSubsystem(t *testing.T, runtimeAPI *RuntimeAPIConfig, configs Configs) *Subsystem {
+	t.Helper()
+	return newTestSubsystemWithOptions(t, SubsystemOptions{RuntimeAPI: runtimeAPI, Configs: configs})
+}
+
+// newTestSubsystemWithOptions creates a Subsystem with opts which doesn't
+// autoscrape integrations.
+func newTestSubsystemWithOptions(t *testing.T, opts SubsystemOptions) *Subsystem {
+	t.Helper()
+
+	l := util.TestLogger(t)
+	globals := Globals{
+		AgentBaseURL:  &url.URL{Scheme: "http", Host: "localhost:12345"},
+		SubsystemOpts: opts,
+	}
+
+	ctrl, err := newController(l, nil, globals)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	exited := make(chan struct{})
+	go func() {
+		ctrl.run(ctx)
+		close(exited)
+	}()
+
+	healthExited := make(chan struct{})
+	close(healthExited)
+
+	s := &Subsystem{
+		logger:           l,
+		autoscraper:      autoscrape.NewScraper(l, instance.MockManager{}, nil),
+		ctrl:             ctrl,
+		stopController:   cancel,
+		controllerExited: exited,
+		health:           newHealthTracker(),
+		healthExited:     healthExited,
+	}
+	require.NoError(t, s.ApplyConfig(globals))
+	t.Cleanup(s.Stop)
+	return s
+}
+
+func requireIntegrations(t *testing.T, s *Subsystem, expect ...string) {
+	t.Helper()
+
+	var ids []string
+	for _, status := range s.ctrl.Integrations() {
+		ids = append(ids, status.id.String())
+	}
+	require.ElementsMatch(t, expect, ids)
+}
+
+type testRuntimeIntegration struct {
+	Instance string `yaml:"instance"`
+	Text     string `yaml:"text,omitempty"`
+	Fail     bool   `yaml:"fail,omitempty"`
+	Control  bool   `yaml:"control,omitempty"`
+}
+
+func (*testRuntimeIntegration) Name() string                         { return "runtime" }
+func (*testRuntimeIntegration) ApplyDefaults(Globals) error          { return nil }
+func (c *testRuntimeIntegration) Identifier(Globals) (string, error) { return c.Instance, nil }
+func (c *testRuntimeIntegration) NewIntegration(log.Logger, Globals) (Integration, error) {
+	if c.Fail {
+		return nil, fmt.Errorf("failed to create integration")
+	}
+	if c.Control {
+		return testControlIntegration{NoOpIntegration}, nil
+	}
+	return NoOpIntegration, nil
+}
+
+// testControlIntegration is a ControlIntegration whose control handler
+// responds with the path of requests.
+type testControlIntegration struct{ Integration }
+
+func (testControlIntegration) ControlHandler() (http.Handler, error) {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		_, _ = rw.Write([]byte(r.URL.Path))
+	}), nil
+}
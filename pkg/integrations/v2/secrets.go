@@ -0,0 +1,293 @@
+package integrations
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/agent/pkg/util"
+	config_util "github.com/prometheus/common/config"
+	"gopkg.in/yaml.v2"
+)
+
+// DefaultSecretsRefreshInterval is how often secrets read from files are
+// checked for changes by default.
+const DefaultSecretsRefreshInterval = time.Minute
+
+// secretRefKey is the key of the mappings which reference secrets in the
+// configs of integrations, such as {secret_ref: db_password}.
+const secretRefKey = "secret_ref"
+
+// SecretConfig defines a named secret. Integrations reference it with
+// {secret_ref: <name>} in place of the value instead of repeating it.
+type SecretConfig struct {
+	Name  string             `yaml:"name"`
+	Value config_util.Secret `yaml:"value,omitempty"`
+	// File holds the value of the secret. It's checked for changes every
+	// secrets_refresh_interval, and integrations referencing the secret are
+	// updated when it changes.
+	File string `yaml:"file,omitempty"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler for SecretConfig.
+func (c *SecretConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = SecretConfig{}
+
+	type plain SecretConfig
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	if c.Name == "" {
+		return fmt.Errorf("secret name must be set")
+	}
+	if (c.Value == "") == (c.File == "") {
+		return fmt.Errorf("secret %q: exactly one of value and file must be set", c.Name)
+	}
+	return nil
+}
+
+// secretValues maps the names of secrets to their values.
+type secretValues map[string]string
+
+// loadSecrets reads the values of secrets.
+func loadSecrets(secrets []SecretConfig) (secretValues, error) {
+	values := make(secretValues, len(secrets))
+	for _, sc := range secrets {
+		if _, exist := values[sc.Name]; exist {
+			return nil, fmt.Errorf("found multiple secrets named %q", sc.Name)
+		}
+
+		value := string(sc.Value)
+		if sc.File != "" {
+			bb, err := os.ReadFile(sc.File)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read secret %q: %w", sc.Name, err)
+			}
+			value = strings.TrimSpace(string(bb))
+		}
+		values[sc.Name] = value
+	}
+	return values, nil
+}
+
+// changed returns the names of the secrets whose values differ in v and
+// other.
+func (v secretValues) changed(other secretValues) map[string]struct{} {
+	res := make(map[string]struct{})
+	for name, value := range v {
+		if otherValue, ok := other[name]; !ok || otherValue != value {
+			res[name] = struct{}{}
+		}
+	}
+	for name := range other {
+		if _, ok := v[name]; !ok {
+			res[name] = struct{}{}
+		}
+	}
+	return res
+}
+
+// resolveSecretRefs replaces the references to secrets in raw with their
+// values. It returns the names of the referenced secrets, and raw unchanged
+// when it doesn't reference any.
+func resolveSecretRefs(raw util.RawYAML, values secretValues) (util.RawYAML, []string, error) {
+	var root interface{}
+	if err := yaml.Unmarshal(raw, &root); err != nil {
+		return nil, nil, err
+	}
+
+	var refs []string
+	resolved, err := resolveSecretValue(root, values, &refs)
+	if err != nil || len(refs) == 0 {
+		return raw, nil, err
+	}
+	out, err := yaml.Marshal(resolved)
+	return out, refs, err
+}
+
+func resolveSecretValue(v interface{}, values secretValues, refs *[]string) (interface{}, error) {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		if ref, ok := v[secretRefKey]; ok && len(v) == 1 {
+			name, ok := ref.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s must be the name of a secret", secretRefKey)
+			}
+			value, ok := values[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown secret %q", name)
+			}
+			*refs = append(*refs, name)
+			return value, nil
+		}
+		for key, elem := range v {
+			resolved, err := resolveSecretValue(elem, values, refs)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = resolved
+		}
+		return v, nil
+	case []interface{}:
+		for i, elem := range v {
+			resolved, err := resolveSecretValue(elem, values, refs)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = resolved
+		}
+		return v, nil
+	default:
+		return v, nil
+	}
+}
+
+// configTemplate is the YAML of an integration which references secrets. It's
+// kept to decode the integration again when the secrets change.
+type configTemplate struct {
+	raw     util.RawYAML
+	ref     interface{} // Config or v1.Config
+	secrets []string    // Names of the referenced secrets
+}
+
+// decodeConfig decodes raw into a Config after resolving its references to
+// secrets. The returned template is nil if raw doesn't reference secrets.
+func decodeConfig(raw util.RawYAML, ref interface{}, values secretValues) (Config, *configTemplate, error) {
+	resolved, refs, err := resolveSecretRefs(raw, values)
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg, err := deferredConfigUnmarshal(resolved, ref)
+	if err != nil || len(refs) == 0 {
+		return cfg, nil, err
+	}
+	return cfg, &configTemplate{raw: raw, ref: ref, secrets: refs}, nil
+}
+
+// references returns true if t references any of the secrets in names.
+func (t *configTemplate) references(names map[string]struct{}) bool {
+	for _, name := range t.secrets {
+		if _, ok := names[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// startSecretsWatcher stops the running secrets watcher, if any, and starts
+// watching the secrets of opts which are read from files for changes. s.mut
+// must be held.
+func (s *Subsystem) startSecretsWatcher(opts SubsystemOptions) {
+	if s.stopSecrets != nil {
+		s.stopSecrets()
+	}
+	s.secretsConfig, s.secretsRefreshInterval = opts.Secrets, opts.SecretsRefreshInterval
+
+	ctx, cancel := context.WithCancel(context.Background())
+	exited := make(chan struct{})
+	s.stopSecrets, s.secretsExited = cancel, exited
+
+	var watched bool
+	for _, sc := range opts.Secrets {
+		watched = watched || sc.File != ""
+	}
+	interval := opts.SecretsRefreshInterval
+	if interval <= 0 {
+		interval = DefaultSecretsRefreshInterval
+	}
+	if !watched {
+		close(exited)
+		return
+	}
+
+	l := log.With(s.logger, "subsystem", "secrets")
+	go func() {
+		defer close(exited)
+
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+
+			values, err := loadSecrets(opts.Secrets)
+			if err != nil {
+				level.Warn(l).Log("msg", "failed to reload secrets, keeping the previous values", "err", err)
+				continue
+			}
+
+			s.mut.Lock()
+			// The watcher may have been restarted while reading secrets.
+			if ctx.Err() == nil {
+				if err := s.applySecrets(values); err != nil {
+					level.Error(l).Log("msg", "failed to update integrations after secrets changed", "err", err)
+				}
+			}
+			s.mut.Unlock()
+		}
+	}()
+}
+
+// applySecrets updates the integrations which reference secrets whose values
+// differ from values. s.mut must be held.
+func (s *Subsystem) applySecrets(values secretValues) error {
+	changed := s.secretValues.changed(values)
+	if len(changed) == 0 {
+		return nil
+	}
+
+	globals := s.globals
+	configs := append(Configs{}, globals.SubsystemOpts.Configs...)
+	for i, t := range globals.SubsystemOpts.templates {
+		if i >= len(configs) || !t.references(changed) {
+			continue
+		}
+		cfg, _, err := decodeConfig(t.raw, t.ref, values)
+		if err != nil {
+			level.Warn(s.logger).Log("msg", "failed to update integration with changed secrets, keeping the previous config", "integration", configs[i].Name(), "err", err)
+			continue
+		}
+		configs[i] = cfg
+	}
+	globals.SubsystemOpts.Configs = configs
+
+	s.setSecretValues(values, globals)
+	return s.applyConfig(globals)
+}
+
+// setSecretValues sets the values of secrets. The integrations managed at
+// runtime which reference changed secrets are decoded again, and all
+// integrations referencing changed secrets are marked stale so that they're
+// updated by the next call to applyConfig. s.mut must be held.
+func (s *Subsystem) setSecretValues(values secretValues, globals Globals) {
+	changed := s.secretValues.changed(values)
+	s.secretValues = values
+	if len(changed) == 0 {
+		return
+	}
+
+	var (
+		configs = globals.SubsystemOpts.Configs
+		stale   []integrationID
+	)
+	for i, t := range globals.SubsystemOpts.templates {
+		if i >= len(configs) || !t.references(changed) {
+			continue
+		}
+		if id, err := configs[i].Identifier(globals); err == nil {
+			stale = append(stale, integrationID{Name: configs[i].Name(), Identifier: id})
+		}
+	}
+	if s.runtime != nil {
+		stale = append(stale, s.runtime.resolveSecrets(s.logger, changed, values)...)
+	}
+	s.ctrl.Invalidate(stale...)
+}
@@ -0,0 +1,198 @@
+package integrations
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/gorilla/mux"
+	config_util "github.com/prometheus/common/config"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestSubsystemOptions_Secrets(t *testing.T) {
+	setRegistered(t, map[Config]Type{
+		&testSecretIntegration{}: TypeMultiplex,
+	})
+
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(passwordFile, []byte("from-file\n"), 0600))
+
+	var so SubsystemOptions
+	require.NoError(t, yaml.UnmarshalStrict([]byte(`
+secrets:
+- name: db_password
+  file: `+passwordFile+`
+- name: api_token
+  value: inline
+secret_configs:
+- instance: db-1
+  password: {secret_ref: db_password}
+- instance: api
+  password: {secret_ref: api_token}
+  labels: [{secret_ref: db_password}]
+- instance: plain
+  password: plaintext
+`), &so))
+
+	require.Len(t, so.Configs, 3)
+	require.Equal(t, config_util.Secret("from-file"), so.Configs[0].(*testSecretIntegration).Password)
+	require.Equal(t, config_util.Secret("inline"), so.Configs[1].(*testSecretIntegration).Password)
+	require.Equal(t, []string{"from-file"}, so.Configs[1].(*testSecretIntegration).Labels)
+	require.Equal(t, config_util.Secret("plaintext"), so.Configs[2].(*testSecretIntegration).Password)
+
+	require.Len(t, so.templates, 2)
+	require.Equal(t, []string{"db_password"}, so.templates[0].secrets)
+	require.ElementsMatch(t, []string{"api_token", "db_password"}, so.templates[1].secrets)
+
+	tt := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{
+			name:   "unknown secret",
+			config: "secret_configs:\n- password: {secret_ref: missing}",
+			err:    `unknown secret "missing"`,
+		},
+		{
+			name:   "invalid reference",
+			config: "secret_configs:\n- password: {secret_ref: [a, b]}",
+			err:    "secret_ref must be the name of a secret",
+		},
+		{
+			name:   "value and file",
+			config: "secrets:\n- {name: a, value: a, file: a}",
+			err:    `secret "a": exactly one of value and file must be set`,
+		},
+		{
+			name:   "duplicate secret",
+			config: "secrets:\n- {name: a, value: a}\n- {name: a, value: b}",
+			err:    `found multiple secrets named "a"`,
+		},
+		{
+			name:   "missing file",
+			config: "secrets:\n- {name: a, file: " + filepath.Join(t.TempDir(), "missing") + "}",
+			err:    `failed to read secret "a"`,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var so SubsystemOptions
+			err := yaml.UnmarshalStrict([]byte(tc.config), &so)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.err)
+		})
+	}
+}
+
+func TestSubsystem_SecretsRotation(t *testing.T) {
+	setRegistered(t, map[Config]Type{
+		&testSecretIntegration{}: TypeMultiplex,
+	})
+	created := &createdIntegrations{}
+	createdSecretIntegrations = created
+	t.Cleanup(func() { createdSecretIntegrations = nil })
+
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(passwordFile, []byte("v1"), 0600))
+
+	var opts SubsystemOptions
+	require.NoError(t, yaml.UnmarshalStrict([]byte(`
+secrets:
+- name: db_password
+  file: `+passwordFile+`
+secrets_refresh_interval: 10ms
+runtime_api:
+  data_path: `+filepath.Join(t.TempDir(), "runtime.yml")+`
+  bearer_token: secret
+secret_configs:
+- instance: static
+  password: {secret_ref: db_password}
+- instance: plain
+  password: plaintext
+`), &opts))
+	s := newTestSubsystemWithOptions(t, opts)
+
+	// Integrations managed at runtime may reference secrets too, which are
+	// persisted unresolved.
+	r := mux.NewRouter()
+	s.WireAPI(r)
+	req := httptest.NewRequest(http.MethodPut, IntegrationsRuntimeEndpoint+"/secret/runtime", strings.NewReader("instance: runtime\npassword: {secret_ref: db_password}"))
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	require.Contains(t, rec.Body.String(), "secret_ref: db_password")
+
+	require.Equal(t, map[string]string{"static": "v1", "plain": "plaintext", "runtime": "v1"}, created.Passwords())
+
+	require.NoError(t, os.WriteFile(passwordFile, []byte("v2"), 0600))
+	require.Eventually(t, func() bool {
+		passwords := created.Passwords()
+		return passwords["static"] == "v2" && passwords["runtime"] == "v2"
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, 1, created.Count("plain"), "integrations not referencing the secret should be kept")
+}
+
+// createdSecretIntegrations records the integrations created by
+// testSecretIntegration.
+var createdSecretIntegrations *createdIntegrations
+
+type createdIntegrations struct {
+	mut       sync.Mutex
+	passwords map[string]string
+	counts    map[string]int
+}
+
+func (c *createdIntegrations) add(instance, password string) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if c.passwords == nil {
+		c.passwords, c.counts = make(map[string]string), make(map[string]int)
+	}
+	c.passwords[instance] = password
+	c.counts[instance]++
+}
+
+// Passwords returns the password of the last integration created for each
+// instance.
+func (c *createdIntegrations) Passwords() map[string]string {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	res := make(map[string]string, len(c.passwords))
+	for k, v := range c.passwords {
+		res[k] = v
+	}
+	return res
+}
+
+// Count returns the number of integrations created for instance.
+func (c *createdIntegrations) Count(instance string) int {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.counts[instance]
+}
+
+type testSecretIntegration struct {
+	Instance string             `yaml:"instance"`
+	Password config_util.Secret `yaml:"password,omitempty"`
+	Labels   []string           `yaml:"labels,omitempty"`
+}
+
+func (*testSecretIntegration) Name() string                         { return "secret" }
+func (*testSecretIntegration) ApplyDefaults(Globals) error          { return nil }
+func (c *testSecretIntegration) Identifier(Globals) (string, error) { return c.Instance, nil }
+func (c *testSecretIntegration) NewIntegration(log.Logger, Globals) (Integration, error) {
+	if createdSecretIntegrations != nil {
+		createdSecretIntegrations.add(c.Instance, string(c.Password))
+	}
+	return NoOpIntegration, nil
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
+	snmp_common "github.com/grafana/agent/pkg/integrations/snmp_exporter/common"
 	"github.com/grafana/agent/pkg/integrations/v2"
 	"github.com/grafana/agent/pkg/integrations/v2/autoscrape"
 	"github.com/grafana/agent/pkg/integrations/v2/metricsutils"
@@ -21,12 +22,12 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/prometheus/snmp_exporter/collector"
-	snmp_config "github.com/prometheus/snmp_exporter/config"
 )
 
 type snmpHandler struct {
 	cfg     *Config
-	modules *snmp_config.Config
+	modules *snmp_common.Modules
+	walks   snmp_common.WalkLimiter
 	log     log.Logger
 }
 
@@ -98,8 +99,9 @@ var (
 	_ integrations.MetricsIntegration = (*snmpHandler)(nil)
 )
 
+// RunIntegration reloads the SNMP config file when it changes.
 func (sh *snmpHandler) RunIntegration(ctx context.Context) error {
-	<-ctx.Done()
+	sh.modules.Watch(ctx)
 	return nil
 }
 
@@ -142,7 +144,7 @@ func (sh *snmpHandler) createHandler(targets []SNMPTarget) http.HandlerFunc {
 			moduleName = "if_mib"
 		}
 
-		module, ok := (*sh.modules)[moduleName]
+		module, ok := sh.modules.Get(moduleName)
 		if !ok {
 			http.Error(w, fmt.Sprintf("Unknown module '%s'", moduleName), 400)
 			return
@@ -186,6 +188,12 @@ func (sh *snmpHandler) createHandler(targets []SNMPTarget) http.HandlerFunc {
 		}
 		level.Debug(logger).Log("msg", "Starting scrape")
 
+		if err := sh.walks.Acquire(r.Context()); err != nil {
+			http.Error(w, "timed out waiting for other SNMP walks to finish", http.StatusServiceUnavailable)
+			return
+		}
+		defer sh.walks.Release()
+
 		start := time.Now()
 		registry := prometheus.NewRegistry()
 		c := collector.New(r.Context(), target, module, logger)
@@ -219,7 +227,7 @@ func (sh *snmpHandler) handler(w http.ResponseWriter, r *http.Request) {
 		moduleName = "if_mib"
 	}
 
-	module, ok := (*sh.modules)[moduleName]
+	module, ok := sh.modules.Get(moduleName)
 	if !ok {
 		http.Error(w, fmt.Sprintf("Unknown module '%s'", moduleName), 400)
 		return
@@ -258,6 +266,12 @@ func (sh *snmpHandler) handler(w http.ResponseWriter, r *http.Request) {
 	}
 	level.Debug(logger).Log("msg", "Starting scrape")
 
+	if err := sh.walks.Acquire(r.Context()); err != nil {
+		http.Error(w, "timed out waiting for other SNMP walks to finish", http.StatusServiceUnavailable)
+		return
+	}
+	defer sh.walks.Release()
+
 	start := time.Now()
 	registry := prometheus.NewRegistry()
 	c := collector.New(r.Context(), target, module, logger)
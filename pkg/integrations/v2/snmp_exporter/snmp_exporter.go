@@ -2,8 +2,6 @@
 package snmp_exporter
 
 import (
-	"fmt"
-
 	"github.com/go-kit/log"
 	snmp_common "github.com/grafana/agent/pkg/integrations/snmp_exporter/common"
 	integrations_v2 "github.com/grafana/agent/pkg/integrations/v2"
@@ -22,7 +20,10 @@ type Config struct {
 	WalkParams     map[string]snmp_config.WalkParams `yaml:"walk_params,omitempty"`
 	SnmpConfigFile string                            `yaml:"config_file,omitempty"`
 	SnmpTargets    []SNMPTarget                      `yaml:"snmp_targets"`
-	Common         common.MetricsConfig              `yaml:",inline"`
+	// MaxConcurrentWalks limits the number of targets walked at the same
+	// time. Walks aren't limited when zero.
+	MaxConcurrentWalks int                  `yaml:"max_concurrent_walks,omitempty"`
+	Common             common.MetricsConfig `yaml:",inline"`
 
 	globals integrations_v2.Globals
 }
@@ -48,23 +49,15 @@ func (c *Config) Identifier(globals integrations_v2.Globals) (string, error) {
 
 // NewIntegration creates a new SNMP integration.
 func (c *Config) NewIntegration(log log.Logger, globals integrations_v2.Globals) (integrations_v2.Integration, error) {
-	var modules *snmp_config.Config
-	var err error
-	if c.SnmpConfigFile != "" {
-		modules, err = snmp_config.LoadFile(c.SnmpConfigFile)
-		if err != nil {
-			return nil, fmt.Errorf("failed to load snmp config from file %v: %w", c.SnmpConfigFile, err)
-		}
-	} else {
-		modules, err = snmp_common.LoadEmbeddedConfig()
-		if err != nil {
-			return nil, fmt.Errorf("failed to load embedded snmp config: %w", err)
-		}
+	modules, err := snmp_common.LoadModules(log, c.SnmpConfigFile)
+	if err != nil {
+		return nil, err
 	}
 	c.globals = globals
 	sh := &snmpHandler{
 		cfg:     c,
 		modules: modules,
+		walks:   snmp_common.NewWalkLimiter(c.MaxConcurrentWalks),
 		log:     log,
 	}
 	return sh, nil
@@ -9,9 +9,12 @@ import (
 	"time"
 
 	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
 	"github.com/gorilla/mux"
+	"github.com/grafana/agent/pkg/integrations/v2/autodiscovery"
 	"github.com/grafana/agent/pkg/integrations/v2/autoscrape"
 	"github.com/grafana/agent/pkg/metrics"
+	"github.com/grafana/agent/pkg/util"
 	"github.com/prometheus/common/model"
 	http_sd "github.com/prometheus/prometheus/discovery/http"
 )
@@ -25,12 +28,17 @@ const (
 	// IntegrationsAutoscrapeTargetsEndpoint is the API endpoint where autoscrape
 	// integrations targets are exposed.
 	IntegrationsAutoscrapeTargetsEndpoint = "/agent/api/v1/metrics/integrations/targets"
+
+	// DefaultDataPath is the default directory where integrations store data.
+	DefaultDataPath = "data-agent/integrations/"
 )
 
 // DefaultSubsystemOptions holds the default settings for a Controller.
 var (
 	DefaultSubsystemOptions = SubsystemOptions{
-		Metrics: DefaultMetricsSubsystemOptions,
+		Metrics:                DefaultMetricsSubsystemOptions,
+		SecretsRefreshInterval: DefaultSecretsRefreshInterval,
+		DataPath:               DefaultDataPath,
 	}
 
 	DefaultMetricsSubsystemOptions = MetricsSubsystemOptions{
@@ -42,9 +50,32 @@ var (
 type SubsystemOptions struct {
 	Metrics MetricsSubsystemOptions `yaml:"metrics,omitempty"`
 
+	// Autodiscovery enables integrations for services discovered on the local
+	// machine. Autodiscovery is disabled when nil.
+	Autodiscovery *autodiscovery.Config `yaml:"autodiscovery,omitempty"`
+
+	// RuntimeAPI enables an HTTP API to manage integrations at runtime. The API
+	// is disabled when nil.
+	RuntimeAPI *RuntimeAPIConfig `yaml:"runtime_api,omitempty"`
+
+	// Secrets are named secrets which integrations reference with
+	// {secret_ref: <name>} instead of repeating their values.
+	Secrets []SecretConfig `yaml:"secrets,omitempty"`
+	// SecretsRefreshInterval is how often secrets read from files are checked
+	// for changes.
+	SecretsRefreshInterval time.Duration `yaml:"secrets_refresh_interval,omitempty"`
+
+	// DataPath is the directory where integrations store data. Each
+	// integration uses a subdirectory named after it.
+	DataPath string `yaml:"data_path,omitempty"`
+
 	// Configs are configurations of integration to create. Unmarshaled through
 	// the custom UnmarshalYAML method of Controller.
 	Configs Configs `yaml:"-"`
+
+	// templates are the configs which reference secrets by their index in
+	// Configs.
+	templates map[int]*configTemplate
 }
 
 // MetricsSubsystemOptions controls how metrics integrations behave.
@@ -74,7 +105,32 @@ func (o SubsystemOptions) MarshalYAML() (interface{}, error) {
 // integrations will be unmarshaled into o.Configs.
 func (o *SubsystemOptions) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	*o = DefaultSubsystemOptions
-	return UnmarshalYAML(o, unmarshal)
+
+	// Secrets are loaded first so that integrations can reference them.
+	var secrets struct {
+		Secrets []SecretConfig         `yaml:"secrets,omitempty"`
+		Rest    map[string]interface{} `yaml:",inline"`
+	}
+	if err := unmarshal(&secrets); err != nil {
+		return err
+	}
+	values, err := loadSecrets(secrets.Secrets)
+	if err != nil {
+		return err
+	}
+
+	var decoded int
+	return unmarshalYAML(o, unmarshal, func(raw util.RawYAML, ref interface{}) (Config, error) {
+		cfg, template, err := decodeConfig(raw, ref, values)
+		if template != nil {
+			if o.templates == nil {
+				o.templates = make(map[int]*configTemplate)
+			}
+			o.templates[decoded] = template
+		}
+		decoded++
+		return cfg, err
+	})
 }
 
 // Subsystem runs the integrations subsystem, managing a set of integrations.
@@ -89,6 +145,31 @@ type Subsystem struct {
 	ctrl             *controller
 	stopController   context.CancelFunc
 	controllerExited chan struct{}
+
+	health       *healthTracker
+	healthExited chan struct{}
+
+	// Services matched by autodiscovery, and the integrations enabled for
+	// them.
+	matches    []autodiscovery.Match
+	discovered []discoveredIntegration
+
+	autodiscoveryConfig *autodiscovery.Config
+	stopAutodiscovery   context.CancelFunc
+	autodiscoveryExited chan struct{}
+
+	// Integrations managed through the runtime API. runtime is nil when the
+	// API is disabled.
+	runtimeAPIConfig *RuntimeAPIConfig
+	runtime          *runtimeStore
+
+	// Secrets referenced by integrations, and the watcher updating the
+	// integrations when the secrets change.
+	secretsConfig          []SecretConfig
+	secretsRefreshInterval time.Duration
+	secretValues           secretValues
+	stopSecrets            context.CancelFunc
+	secretsExited          chan struct{}
 }
 
 // NewSubsystem creates and starts a new integrations Subsystem. Every field in
@@ -112,6 +193,13 @@ func NewSubsystem(l log.Logger, globals Globals) (*Subsystem, error) {
 		close(ctrlExited)
 	}()
 
+	health := newHealthTracker()
+	healthExited := make(chan struct{})
+	go func() {
+		health.Run(ctx, ctrl.Integrations, autoscraper.TargetsActive)
+		close(healthExited)
+	}()
+
 	s := &Subsystem{
 		logger: l,
 
@@ -121,10 +209,12 @@ func NewSubsystem(l log.Logger, globals Globals) (*Subsystem, error) {
 		ctrl:             ctrl,
 		stopController:   cancel,
 		controllerExited: ctrlExited,
+
+		health:       health,
+		healthExited: healthExited,
 	}
 	if err := s.ApplyConfig(globals); err != nil {
-		cancel()
-		autoscraper.Stop()
+		s.Stop()
 		return nil, err
 	}
 	return s, nil
@@ -132,15 +222,59 @@ func NewSubsystem(l log.Logger, globals Globals) (*Subsystem, error) {
 
 // ApplyConfig updates the configuration of the integrations subsystem.
 func (s *Subsystem) ApplyConfig(globals Globals) error {
-	const prefix = "/integrations/"
-
 	s.mut.Lock()
 	defer s.mut.Unlock()
 
-	if err := s.ctrl.UpdateController(controllerConfig(globals.SubsystemOpts.Configs), globals); err != nil {
-		return fmt.Errorf("error applying integrations: %w", err)
+	if !util.CompareYAML(s.autodiscoveryConfig, globals.SubsystemOpts.Autodiscovery) || s.stopAutodiscovery == nil {
+		s.startAutodiscovery(globals.SubsystemOpts.Autodiscovery)
+	}
+	values, err := loadSecrets(globals.SubsystemOpts.Secrets)
+	if err != nil {
+		return err
+	}
+	s.setSecretValues(values, globals)
+	if !util.CompareYAML(s.secretsConfig, globals.SubsystemOpts.Secrets) || s.secretsRefreshInterval != globals.SubsystemOpts.SecretsRefreshInterval || s.stopSecrets == nil {
+		s.startSecretsWatcher(globals.SubsystemOpts)
+	}
+	if err := s.applyRuntimeAPIConfig(globals.SubsystemOpts.RuntimeAPI, globals); err != nil {
+		return err
+	}
+	return s.applyConfig(globals)
+}
+
+// applyConfig applies globals along with the integrations managed through
+// the runtime API and enabled by autodiscovery. s.mut must be held.
+func (s *Subsystem) applyConfig(globals Globals) error {
+	const prefix = "/integrations/"
+
+	runtime, conflicts := runtimeConfigs(s.runtime, globals.SubsystemOpts.Configs, globals)
+	for _, ri := range conflicts {
+		level.Warn(s.logger).Log("msg", "ignoring runtime integration which is defined in the config file", "integration", ri.Name, "instance", ri.Instance)
+	}
+	explicit := append(append(Configs{}, globals.SubsystemOpts.Configs...), runtime...)
+
+	discovered, err := discoveredIntegrations(s.matches, explicit)
+	if err != nil {
+		level.Warn(s.logger).Log("msg", "ignoring some discovered integrations", "err", err)
+	}
+	configs := append(Configs{}, explicit...)
+	for _, d := range discovered {
+		configs = append(configs, d.cfg)
 	}
 
+	if err := s.ctrl.UpdateController(controllerConfig(configs), globals); err != nil {
+		if len(discovered) == 0 {
+			return fmt.Errorf("error applying integrations: %w", err)
+		}
+		level.Error(s.logger).Log("msg", "failed to apply discovered integrations, ignoring them", "err", err)
+
+		discovered = nil
+		if err := s.ctrl.UpdateController(controllerConfig(explicit), globals); err != nil {
+			return fmt.Errorf("error applying integrations: %w", err)
+		}
+	}
+	s.discovered = discovered
+
 	var firstErr error
 	saveFirstErr := func(err error) {
 		if firstErr == nil {
@@ -176,6 +310,43 @@ func (s *Subsystem) ApplyConfig(globals Globals) error {
 	return firstErr
 }
 
+// startAutodiscovery stops the running autodiscovery, if any, and starts
+// autodiscovery with cfg unless it's nil. s.mut must be held.
+func (s *Subsystem) startAutodiscovery(cfg *autodiscovery.Config) {
+	if s.stopAutodiscovery != nil {
+		s.stopAutodiscovery()
+	}
+	s.autodiscoveryConfig = cfg
+	s.matches = nil
+
+	ctx, cancel := context.WithCancel(context.Background())
+	exited := make(chan struct{})
+	s.stopAutodiscovery, s.autodiscoveryExited = cancel, exited
+
+	if cfg == nil {
+		close(exited)
+		return
+	}
+
+	l := log.With(s.logger, "subsystem", "autodiscovery")
+	go func() {
+		defer close(exited)
+		runAutodiscovery(ctx, l, *cfg, autodiscovery.New(*cfg).Discover, func(matches []autodiscovery.Match) {
+			s.mut.Lock()
+			defer s.mut.Unlock()
+
+			// Autodiscovery may have been restarted while discovering.
+			if ctx.Err() != nil || sameMatches(s.matches, matches) {
+				return
+			}
+			s.matches = matches
+			if err := s.applyConfig(s.globals); err != nil {
+				level.Error(l).Log("msg", "failed to apply discovered integrations", "err", err)
+			}
+		})
+	}()
+}
+
 // WireAPI hooks up integration endpoints to r.
 func (s *Subsystem) WireAPI(r *mux.Router) {
 	const prefix = "/integrations"
@@ -236,12 +407,47 @@ func (s *Subsystem) WireAPI(r *mux.Router) {
 		allTargets := s.autoscraper.TargetsActive()
 		metrics.ListTargetsHandler(allTargets).ServeHTTP(rw, r)
 	})
+
+	r.HandleFunc(IntegrationsHealthEndpoint, func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(rw).Encode(s.health.Health())
+	})
+
+	r.HandleFunc(IntegrationsAutodiscoveryEndpoint, func(rw http.ResponseWriter, r *http.Request) {
+		s.mut.RLock()
+		discovered := append([]discoveredIntegration{}, s.discovered...)
+		s.mut.RUnlock()
+
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(rw).Encode(discovered)
+	})
+
+	s.wireRuntimeAPI(r)
 }
 
 // Stop stops the manager and all running integrations. Blocks until all
 // running integrations exit.
 func (s *Subsystem) Stop() {
+	s.mut.Lock()
+	if s.stopAutodiscovery != nil {
+		s.stopAutodiscovery()
+	}
+	if s.stopSecrets != nil {
+		s.stopSecrets()
+	}
+	exited, secretsExited := s.autodiscoveryExited, s.secretsExited
+	s.mut.Unlock()
+	if exited != nil {
+		<-exited
+	}
+	if secretsExited != nil {
+		<-secretsExited
+	}
+
 	s.autoscraper.Stop()
 	s.stopController()
 	<-s.controllerExited
+	<-s.healthExited
 }
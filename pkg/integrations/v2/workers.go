@@ -2,10 +2,12 @@ package integrations
 
 import (
 	"context"
+	"fmt"
 	"sync"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	"go.uber.org/atomic"
 )
 
 type workerPool struct {
@@ -114,9 +116,43 @@ func (p *workerPool) scheduleWorker(ci *controlledIntegration) {
 			delete(p.workers, ci)
 		}()
 
-		err := ci.i.RunIntegration(ctx)
-		if err != nil {
-			level.Error(p.log).Log("msg", "integration exited with error", "id", ci.id, "err", err)
+		defer deleteIntegrationMetrics(ci.id)
+
+		for {
+			restarted, err := p.runIntegration(ctx, ci)
+			if err != nil {
+				level.Error(p.log).Log("msg", "integration exited with error", "id", ci.id, "err", err)
+			}
+			if !restarted || ctx.Err() != nil {
+				return
+			}
+			level.Info(p.log).Log("msg", "restarting integration which exceeded its resource limits", "id", ci.id)
+		}
+	}()
+}
+
+// runIntegration runs ci until ctx is canceled or it exits. Integrations
+// which panic exit with an error. restarted is true if the integration was
+// stopped because it exceeded its resource limits.
+func (p *workerPool) runIntegration(ctx context.Context, ci *controlledIntegration) (restarted bool, err error) {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var restart atomic.Bool
+	if ri, ok := ci.i.(ResourceIntegration); ok {
+		w := newResourceWatcher(log.With(p.log, "id", ci.id), ci.id, ri)
+		go w.Run(runCtx, func() {
+			restart.Store(true)
+			cancel()
+		})
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			integrationPanics.WithLabelValues(ci.id.Name, ci.id.Identifier).Inc()
+			err = fmt.Errorf("integration panicked: %v", r)
 		}
+		restarted = restart.Load()
 	}()
+	return false, ci.i.RunIntegration(runCtx)
 }
@@ -0,0 +1,224 @@
+package zfs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// poolStates are the health states a pool can be reported in by zpool.
+var poolStates = []string{"ONLINE", "DEGRADED", "FAULTED", "OFFLINE", "UNAVAIL", "REMOVED", "SUSPENDED"}
+
+// arcStats maps ARC kstat names to the metrics they're exposed as.
+var arcStats = map[string]struct {
+	desc      *prometheus.Desc
+	valueType prometheus.ValueType
+}{
+	"size":      {newDesc("arc_size_bytes", "Current size of the ARC.", nil), prometheus.GaugeValue},
+	"c":         {newDesc("arc_target_size_bytes", "Target size of the ARC.", nil), prometheus.GaugeValue},
+	"c_max":     {newDesc("arc_max_size_bytes", "Maximum size of the ARC.", nil), prometheus.GaugeValue},
+	"hits":      {newDesc("arc_hits_total", "Total number of ARC hits.", nil), prometheus.CounterValue},
+	"misses":    {newDesc("arc_misses_total", "Total number of ARC misses.", nil), prometheus.CounterValue},
+	"l2_size":   {newDesc("l2arc_size_bytes", "Current size of the L2ARC.", nil), prometheus.GaugeValue},
+	"l2_hits":   {newDesc("l2arc_hits_total", "Total number of L2ARC hits.", nil), prometheus.CounterValue},
+	"l2_misses": {newDesc("l2arc_misses_total", "Total number of L2ARC misses.", nil), prometheus.CounterValue},
+}
+
+var (
+	poolHealthDesc    = newDesc("pool_health", "Health state of the pool; 1 for the current state.", []string{"pool", "state"})
+	poolSizeDesc      = newDesc("pool_size_bytes", "Total size of the pool.", []string{"pool"})
+	poolAllocatedDesc = newDesc("pool_allocated_bytes", "Space allocated in the pool.", []string{"pool"})
+	poolFreeDesc      = newDesc("pool_free_bytes", "Free space in the pool.", []string{"pool"})
+	poolFragDesc      = newDesc("pool_fragmentation_ratio", "Fragmentation of the free space in the pool, from 0 to 1.", []string{"pool"})
+
+	scrapeSuccessDesc = newDesc("scrape_collector_success", "Whether collecting the metrics of a source succeeded.", []string{"collector"})
+)
+
+func newDesc(name, help string, labels []string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName("zfs", "", name), help, labels, nil)
+}
+
+type collector struct {
+	log          log.Logger
+	arcstatsPath string
+	timeout      time.Duration
+
+	// zpoolList runs zpool list and returns its output. Replaced in tests.
+	zpoolList func(ctx context.Context) ([]byte, error)
+}
+
+var _ prometheus.Collector = (*collector)(nil)
+
+func newCollector(l log.Logger, c *Config) *collector {
+	zpoolPath := c.ZpoolPath
+	return &collector{
+		log:          l,
+		arcstatsPath: c.ArcstatsPath,
+		timeout:      c.Timeout,
+
+		zpoolList: func(ctx context.Context) ([]byte, error) {
+			// -H removes headers and separates fields by tabs, -p prints exact
+			// numbers instead of human readable ones.
+			return exec.CommandContext(ctx, zpoolPath, "list", "-Hp", "-o", "name,health,size,allocated,free,fragmentation").Output()
+		},
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, s := range arcStats {
+		ch <- s.desc
+	}
+	ch <- poolHealthDesc
+	ch <- poolSizeDesc
+	ch <- poolAllocatedDesc
+	ch <- poolFreeDesc
+	ch <- poolFragDesc
+	ch <- scrapeSuccessDesc
+}
+
+// Collect implements prometheus.Collector.
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	c.collectSource(ch, "zpool", c.collectPools)
+	c.collectSource(ch, "arcstats", c.collectARC)
+}
+
+func (c *collector) collectSource(ch chan<- prometheus.Metric, name string, collect func(chan<- prometheus.Metric) error) {
+	success := 1.0
+	if err := collect(ch); err != nil {
+		level.Error(c.log).Log("msg", "failed to collect zfs metrics", "collector", name, "err", err)
+		success = 0
+	}
+	ch <- prometheus.MustNewConstMetric(scrapeSuccessDesc, prometheus.GaugeValue, success, name)
+}
+
+func (c *collector) collectPools(ch chan<- prometheus.Metric) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	out, err := c.zpoolList(ctx)
+	if err != nil {
+		return fmt.Errorf("running zpool list: %w", err)
+	}
+	pools, err := parseZpoolList(bytes.NewReader(out))
+	if err != nil {
+		return err
+	}
+
+	for _, p := range pools {
+		for _, state := range poolStates {
+			v := 0.0
+			if p.health == state {
+				v = 1
+			}
+			ch <- prometheus.MustNewConstMetric(poolHealthDesc, prometheus.GaugeValue, v, p.name, state)
+		}
+		ch <- prometheus.MustNewConstMetric(poolSizeDesc, prometheus.GaugeValue, p.size, p.name)
+		ch <- prometheus.MustNewConstMetric(poolAllocatedDesc, prometheus.GaugeValue, p.allocated, p.name)
+		ch <- prometheus.MustNewConstMetric(poolFreeDesc, prometheus.GaugeValue, p.free, p.name)
+		if p.fragmentation >= 0 {
+			ch <- prometheus.MustNewConstMetric(poolFragDesc, prometheus.GaugeValue, p.fragmentation, p.name)
+		}
+	}
+	return nil
+}
+
+func (c *collector) collectARC(ch chan<- prometheus.Metric) error {
+	f, err := os.Open(c.arcstatsPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	stats, err := parseArcstats(f)
+	if err != nil {
+		return err
+	}
+	for name, v := range stats {
+		if s, ok := arcStats[name]; ok {
+			ch <- prometheus.MustNewConstMetric(s.desc, s.valueType, v)
+		}
+	}
+	return nil
+}
+
+type pool struct {
+	name, health          string
+	size, allocated, free float64
+	// fragmentation is -1 when zpool doesn't report it.
+	fragmentation float64
+}
+
+// parseZpoolList parses the output of zpool list -Hp -o
+// name,health,size,allocated,free,fragmentation.
+func parseZpoolList(r io.Reader) ([]pool, error) {
+	var pools []pool
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		if strings.TrimSpace(s.Text()) == "" {
+			continue
+		}
+		fields := strings.Split(s.Text(), "\t")
+		if len(fields) != 6 {
+			return nil, fmt.Errorf("unexpected zpool list output %q", s.Text())
+		}
+
+		p := pool{name: fields[0], health: fields[1], fragmentation: -1}
+		for i, dst := range []*float64{&p.size, &p.allocated, &p.free} {
+			v, err := strconv.ParseFloat(fields[i+2], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q for pool %s: %w", fields[i+2], p.name, err)
+			}
+			*dst = v
+		}
+		// Fragmentation is reported as "-" when it isn't known.
+		if v, err := strconv.ParseFloat(strings.TrimSuffix(fields[5], "%"), 64); err == nil {
+			p.fragmentation = v / 100
+		}
+
+		pools = append(pools, p)
+	}
+	return pools, s.Err()
+}
+
+// parseArcstats parses a kstat file holding ARC statistics. The first line
+// is a kstat header and the second line names the columns, followed by one
+// statistic per line as name, type, and value.
+func parseArcstats(r io.Reader) (map[string]float64, error) {
+	stats := make(map[string]float64)
+
+	s := bufio.NewScanner(r)
+	for line := 0; s.Scan(); line++ {
+		if line < 2 {
+			continue
+		}
+		fields := strings.Fields(s.Text())
+		if len(fields) != 3 {
+			continue
+		}
+		v, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for arcstat %s: %w", fields[0], err)
+		}
+		stats[fields[0]] = v
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if len(stats) == 0 {
+		return nil, fmt.Errorf("no statistics found in arcstats")
+	}
+	return stats, nil
+}
@@ -0,0 +1,112 @@
+package zfs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+const testArcstats = `13 1 0x01 123 33456 1234567 89012345
+name                            type data
+hits                            4    1000
+misses                          4    50
+c                               4    2147483648
+c_max                           4    4294967296
+size                            4    1073741824
+l2_hits                         4    0
+`
+
+func TestCollector(t *testing.T) {
+	arcstatsPath := filepath.Join(t.TempDir(), "arcstats")
+	require.NoError(t, os.WriteFile(arcstatsPath, []byte(testArcstats), 0644))
+
+	c := newCollector(log.NewNopLogger(), &Config{ArcstatsPath: arcstatsPath, Timeout: DefaultConfig.Timeout})
+	c.zpoolList = func(context.Context) ([]byte, error) {
+		return []byte("tank\tDEGRADED\t1000\t600\t400\t12\nbackup\tONLINE\t2000\t100\t1900\t-\n"), nil
+	}
+
+	require.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(`
+		# HELP zfs_arc_hits_total Total number of ARC hits.
+		# TYPE zfs_arc_hits_total counter
+		zfs_arc_hits_total 1000
+		# HELP zfs_arc_max_size_bytes Maximum size of the ARC.
+		# TYPE zfs_arc_max_size_bytes gauge
+		zfs_arc_max_size_bytes 4.294967296e+09
+		# HELP zfs_arc_misses_total Total number of ARC misses.
+		# TYPE zfs_arc_misses_total counter
+		zfs_arc_misses_total 50
+		# HELP zfs_arc_size_bytes Current size of the ARC.
+		# TYPE zfs_arc_size_bytes gauge
+		zfs_arc_size_bytes 1.073741824e+09
+		# HELP zfs_arc_target_size_bytes Target size of the ARC.
+		# TYPE zfs_arc_target_size_bytes gauge
+		zfs_arc_target_size_bytes 2.147483648e+09
+		# HELP zfs_l2arc_hits_total Total number of L2ARC hits.
+		# TYPE zfs_l2arc_hits_total counter
+		zfs_l2arc_hits_total 0
+		# HELP zfs_pool_allocated_bytes Space allocated in the pool.
+		# TYPE zfs_pool_allocated_bytes gauge
+		zfs_pool_allocated_bytes{pool="backup"} 100
+		zfs_pool_allocated_bytes{pool="tank"} 600
+		# HELP zfs_pool_fragmentation_ratio Fragmentation of the free space in the pool, from 0 to 1.
+		# TYPE zfs_pool_fragmentation_ratio gauge
+		zfs_pool_fragmentation_ratio{pool="tank"} 0.12
+		# HELP zfs_pool_free_bytes Free space in the pool.
+		# TYPE zfs_pool_free_bytes gauge
+		zfs_pool_free_bytes{pool="backup"} 1900
+		zfs_pool_free_bytes{pool="tank"} 400
+		# HELP zfs_pool_size_bytes Total size of the pool.
+		# TYPE zfs_pool_size_bytes gauge
+		zfs_pool_size_bytes{pool="backup"} 2000
+		zfs_pool_size_bytes{pool="tank"} 1000
+		# HELP zfs_scrape_collector_success Whether collecting the metrics of a source succeeded.
+		# TYPE zfs_scrape_collector_success gauge
+		zfs_scrape_collector_success{collector="arcstats"} 1
+		zfs_scrape_collector_success{collector="zpool"} 1
+	`), "zfs_arc_hits_total", "zfs_arc_max_size_bytes", "zfs_arc_misses_total", "zfs_arc_size_bytes",
+		"zfs_arc_target_size_bytes", "zfs_l2arc_hits_total", "zfs_pool_allocated_bytes",
+		"zfs_pool_fragmentation_ratio", "zfs_pool_free_bytes", "zfs_pool_size_bytes", "zfs_scrape_collector_success"))
+
+	require.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(`
+		# HELP zfs_pool_health Health state of the pool; 1 for the current state.
+		# TYPE zfs_pool_health gauge
+		zfs_pool_health{pool="backup",state="DEGRADED"} 0
+		zfs_pool_health{pool="backup",state="FAULTED"} 0
+		zfs_pool_health{pool="backup",state="OFFLINE"} 0
+		zfs_pool_health{pool="backup",state="ONLINE"} 1
+		zfs_pool_health{pool="backup",state="REMOVED"} 0
+		zfs_pool_health{pool="backup",state="SUSPENDED"} 0
+		zfs_pool_health{pool="backup",state="UNAVAIL"} 0
+		zfs_pool_health{pool="tank",state="DEGRADED"} 1
+		zfs_pool_health{pool="tank",state="FAULTED"} 0
+		zfs_pool_health{pool="tank",state="OFFLINE"} 0
+		zfs_pool_health{pool="tank",state="ONLINE"} 0
+		zfs_pool_health{pool="tank",state="REMOVED"} 0
+		zfs_pool_health{pool="tank",state="SUSPENDED"} 0
+		zfs_pool_health{pool="tank",state="UNAVAIL"} 0
+	`), "zfs_pool_health"))
+}
+
+func TestCollector_Failures(t *testing.T) {
+	c := newCollector(log.NewNopLogger(), &Config{
+		ArcstatsPath: filepath.Join(t.TempDir(), "missing"),
+		Timeout:      DefaultConfig.Timeout,
+	})
+	c.zpoolList = func(context.Context) ([]byte, error) {
+		return nil, errors.New("zpool not found")
+	}
+
+	require.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(`
+		# HELP zfs_scrape_collector_success Whether collecting the metrics of a source succeeded.
+		# TYPE zfs_scrape_collector_success gauge
+		zfs_scrape_collector_success{collector="arcstats"} 0
+		zfs_scrape_collector_success{collector="zpool"} 0
+	`)))
+}
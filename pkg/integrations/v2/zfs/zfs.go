@@ -0,0 +1,81 @@
+// Package zfs collects health and capacity metrics of ZFS pools and
+// statistics of the ZFS Adaptive Replacement Cache (ARC).
+package zfs
+
+import (
+	"time"
+
+	"github.com/go-kit/log"
+	integrations_v2 "github.com/grafana/agent/pkg/integrations/v2"
+	"github.com/grafana/agent/pkg/integrations/v2/common"
+	"github.com/grafana/agent/pkg/integrations/v2/metricsutils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// DefaultConfig holds the default settings for the zfs integration.
+var DefaultConfig = Config{
+	ZpoolPath:    "zpool",
+	ArcstatsPath: "/proc/spl/kstat/zfs/arcstats",
+	Timeout:      5 * time.Second,
+}
+
+// Config controls the zfs integration.
+type Config struct {
+	// ZpoolPath is the path of the zpool command used to list pools.
+	ZpoolPath string `yaml:"zpool_path,omitempty"`
+	// ArcstatsPath is the path of the kstat file holding ARC statistics.
+	ArcstatsPath string `yaml:"arcstats_path,omitempty"`
+	// Timeout is the maximum time the zpool command may run for.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+
+	Common common.MetricsConfig `yaml:",inline"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler for Config.
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultConfig
+
+	type plain Config
+	return unmarshal((*plain)(c))
+}
+
+// ApplyDefaults applies the integration's default configuration.
+func (c *Config) ApplyDefaults(globals integrations_v2.Globals) error {
+	c.Common.ApplyDefaults(globals.SubsystemOpts.Metrics.Autoscrape)
+	return nil
+}
+
+// Identifier returns a string that identifies the integration.
+func (c *Config) Identifier(globals integrations_v2.Globals) (string, error) {
+	if c.Common.InstanceKey != nil {
+		return *c.Common.InstanceKey, nil
+	}
+	return c.InstanceKey(globals.AgentIdentifier)
+}
+
+// Name returns the name of the integration.
+func (c *Config) Name() string {
+	return "zfs"
+}
+
+// InstanceKey returns the identifier of the agent, since pools are local to
+// the host the agent runs on.
+func (c *Config) InstanceKey(agentKey string) (string, error) {
+	return agentKey, nil
+}
+
+// NewIntegration creates a new zfs integration.
+func (c *Config) NewIntegration(l log.Logger, globals integrations_v2.Globals) (integrations_v2.Integration, error) {
+	reg := prometheus.NewRegistry()
+	if err := reg.Register(newCollector(l, c)); err != nil {
+		return nil, err
+	}
+
+	h := promhttp.HandlerFor(reg, promhttp.HandlerOpts{})
+	return metricsutils.NewMetricsHandlerIntegration(l, c, c.Common, globals, h)
+}
+
+func init() {
+	integrations_v2.Register(&Config{}, integrations_v2.TypeSingleton)
+}
@@ -1,6 +1,9 @@
 package windows_exporter //nolint:golint
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/go-kit/log"
 	"github.com/grafana/agent/pkg/integrations"
 	integrations_v2 "github.com/grafana/agent/pkg/integrations/v2"
@@ -10,6 +13,7 @@ import (
 // DefaultConfig holds the default settings for the windows_exporter integration.
 var DefaultConfig = Config{
 	EnabledCollectors: "cpu,cs,logical_disk,net,os,service,system",
+	Timeout:           4 * time.Minute,
 
 	// NOTE(rfratto): there is an init function in config_windows.go that
 	// populates defaults for collectors based on the exporter defaults.
@@ -25,6 +29,14 @@ func init() {
 type Config struct {
 	EnabledCollectors string `yaml:"enabled_collectors"`
 
+	// Timeout is the maximum time a collector may take to collect metrics.
+	// Collectors which time out are reported by the
+	// windows_exporter_collector_timeout metric.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// CollectorTimeouts overrides Timeout for individual collectors, such as
+	// slow WMI-based collectors.
+	CollectorTimeouts map[string]time.Duration `yaml:"collector_timeouts,omitempty"`
+
 	Exchange    ExchangeConfig    `yaml:"exchange,omitempty"`
 	IIS         IISConfig         `yaml:"iis,omitempty"`
 	TextFile    TextFileConfig    `yaml:"text_file,omitempty"`
@@ -42,7 +54,59 @@ func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	*c = DefaultConfig
 
 	type plain Config
-	return unmarshal((*plain)(c))
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	if c.Timeout <= 0 {
+		return fmt.Errorf("timeout must be greater than 0")
+	}
+	for name, timeout := range c.CollectorTimeouts {
+		if timeout <= 0 {
+			return fmt.Errorf("collector_timeouts: timeout of collector %q must be greater than 0", name)
+		}
+	}
+	return nil
+}
+
+// CollectorTimeout returns the timeout of the collector name.
+func (c *Config) CollectorTimeout(name string) time.Duration {
+	if timeout, ok := c.CollectorTimeouts[name]; ok {
+		return timeout
+	}
+	if c.Timeout <= 0 {
+		return DefaultConfig.Timeout
+	}
+	return c.Timeout
+}
+
+// collectorConfig returns the settings of the collector name, or nil if the
+// collector has no settings.
+func (c *Config) collectorConfig(name string) interface{} {
+	switch name {
+	case "exchange":
+		return &c.Exchange
+	case "iis":
+		return &c.IIS
+	case "textfile":
+		return &c.TextFile
+	case "smtp":
+		return &c.SMTP
+	case "service":
+		return &c.Service
+	case "process":
+		return &c.Process
+	case "net":
+		return &c.Network
+	case "mssql":
+		return &c.MSSQL
+	case "msmq":
+		return &c.MSMQ
+	case "logical_disk":
+		return &c.LogicalDisk
+	default:
+		return nil
+	}
 }
 
 // Name returns the name used, "windows_explorer"
@@ -0,0 +1,232 @@
+package windows_exporter //nolint:golint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/grafana/agent/pkg/util"
+	"gopkg.in/yaml.v2"
+)
+
+// maxControlRequestSize is the maximum size of requests to the control API.
+const maxControlRequestSize = 1 << 20
+
+// collectorStatus is the state of a collector returned by the control API.
+type collectorStatus struct {
+	Name    string            `json:"name"`
+	Enabled bool              `json:"enabled"`
+	Timeout string            `json:"timeout"`
+	Config  map[string]string `json:"config,omitempty"`
+}
+
+// collectorUpdate is a change to a collector sent to the control API. Fields
+// which aren't set are left unchanged.
+type collectorUpdate struct {
+	Enabled *bool `yaml:"enabled,omitempty"`
+	// Timeout of the collector. The timeout of the integration is used when
+	// set to 0.
+	Timeout *time.Duration `yaml:"timeout,omitempty"`
+	// Config holds the settings to change, in the format of the block of the
+	// collector in the integration config.
+	Config util.RawYAML `yaml:"config,omitempty"`
+}
+
+// controlAPI toggles and configures collectors at runtime. Changes are made
+// to a copy of the integration config, which is applied by apply.
+type controlAPI struct {
+	available []string // Names of the collectors which can be enabled
+	apply     func(c *Config) error
+
+	mut sync.Mutex
+	cfg Config
+}
+
+func newControlAPI(cfg Config, available []string, apply func(c *Config) error) *controlAPI {
+	sort.Strings(available)
+	return &controlAPI{available: available, apply: apply, cfg: cfg.clone()}
+}
+
+// Handler returns the HTTP handler of the API.
+func (api *controlAPI) Handler() http.Handler {
+	r := mux.NewRouter()
+	r.HandleFunc("/collectors", api.listCollectors).Methods(http.MethodGet)
+	r.HandleFunc("/collectors/{name}", api.getCollector).Methods(http.MethodGet)
+	r.HandleFunc("/collectors/{name}", api.updateCollector).Methods(http.MethodPut)
+	return r
+}
+
+func (api *controlAPI) listCollectors(rw http.ResponseWriter, _ *http.Request) {
+	api.mut.Lock()
+	defer api.mut.Unlock()
+
+	res := make([]collectorStatus, 0, len(api.available))
+	for _, name := range api.available {
+		res = append(res, api.status(name))
+	}
+	writeControlResponse(rw, http.StatusOK, res)
+}
+
+func (api *controlAPI) getCollector(rw http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	if !api.isAvailable(name) {
+		http.Error(rw, fmt.Sprintf("unknown collector %q", name), http.StatusNotFound)
+		return
+	}
+
+	api.mut.Lock()
+	defer api.mut.Unlock()
+	writeControlResponse(rw, http.StatusOK, api.status(name))
+}
+
+func (api *controlAPI) updateCollector(rw http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	if !api.isAvailable(name) {
+		http.Error(rw, fmt.Sprintf("unknown collector %q", name), http.StatusNotFound)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxControlRequestSize+1))
+	if err != nil {
+		http.Error(rw, fmt.Sprintf("failed to read request: %s", err), http.StatusBadRequest)
+		return
+	} else if len(body) > maxControlRequestSize {
+		http.Error(rw, "request too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	var update collectorUpdate
+	if err := yaml.UnmarshalStrict(body, &update); err != nil {
+		http.Error(rw, fmt.Sprintf("invalid request: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	api.mut.Lock()
+	defer api.mut.Unlock()
+
+	cfg, err := update.applyTo(api.cfg, name)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := api.apply(&cfg); err != nil {
+		http.Error(rw, fmt.Sprintf("failed to apply change: %s", err), http.StatusBadRequest)
+		return
+	}
+	api.cfg = cfg
+	writeControlResponse(rw, http.StatusOK, api.status(name))
+}
+
+// applyTo returns a copy of cfg with the update of the collector name
+// applied.
+func (u collectorUpdate) applyTo(cfg Config, name string) (Config, error) {
+	cfg = cfg.clone()
+
+	if u.Enabled != nil {
+		names := collectorList(cfg.EnabledCollectors)
+		if *u.Enabled {
+			names = append(names, name)
+		} else {
+			for i, n := range names {
+				if n == name {
+					names = append(names[:i], names[i+1:]...)
+					break
+				}
+			}
+		}
+		cfg.EnabledCollectors = strings.Join(collectorList(strings.Join(names, ",")), ",")
+	}
+
+	if u.Timeout != nil {
+		switch {
+		case *u.Timeout < 0:
+			return cfg, fmt.Errorf("timeout must not be negative")
+		case *u.Timeout == 0:
+			delete(cfg.CollectorTimeouts, name)
+		default:
+			if cfg.CollectorTimeouts == nil {
+				cfg.CollectorTimeouts = make(map[string]time.Duration)
+			}
+			cfg.CollectorTimeouts[name] = *u.Timeout
+		}
+	}
+
+	if len(u.Config) > 0 {
+		settings := cfg.collectorConfig(name)
+		if settings == nil {
+			return cfg, fmt.Errorf("collector %q has no settings", name)
+		}
+		if err := yaml.UnmarshalStrict(u.Config, settings); err != nil {
+			return cfg, fmt.Errorf("invalid config for collector %q: %w", name, err)
+		}
+	}
+	return cfg, nil
+}
+
+// status returns the status of the collector name. api.mut must be held.
+func (api *controlAPI) status(name string) collectorStatus {
+	s := collectorStatus{
+		Name:    name,
+		Timeout: api.cfg.CollectorTimeout(name).String(),
+	}
+	for _, n := range collectorList(api.cfg.EnabledCollectors) {
+		if n == name {
+			s.Enabled = true
+			break
+		}
+	}
+
+	// Settings are reported by the names of their YAML fields.
+	if settings := api.cfg.collectorConfig(name); settings != nil {
+		if out, err := yaml.Marshal(settings); err == nil {
+			_ = yaml.Unmarshal(out, &s.Config)
+		}
+	}
+	return s
+}
+
+func (api *controlAPI) isAvailable(name string) bool {
+	i := sort.SearchStrings(api.available, name)
+	return i < len(api.available) && api.available[i] == name
+}
+
+func writeControlResponse(rw http.ResponseWriter, statusCode int, v interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(statusCode)
+	_ = json.NewEncoder(rw).Encode(v)
+}
+
+// clone returns a copy of c which can be modified without changing c.
+func (c *Config) clone() Config {
+	res := *c
+	if c.CollectorTimeouts != nil {
+		res.CollectorTimeouts = make(map[string]time.Duration, len(c.CollectorTimeouts))
+		for name, timeout := range c.CollectorTimeouts {
+			res.CollectorTimeouts[name] = timeout
+		}
+	}
+	return res
+}
+
+// collectorList splits a comma-separated list of collectors, removing blank
+// and duplicate names.
+func collectorList(input string) []string {
+	var (
+		result []string
+		seen   = map[string]struct{}{}
+	)
+	for _, s := range strings.Split(input, ",") {
+		s = strings.TrimSpace(s)
+		if _, ok := seen[s]; ok || s == "" {
+			continue
+		}
+		seen[s] = struct{}{}
+		result = append(result, s)
+	}
+	return result
+}
@@ -0,0 +1,92 @@
+package windows_exporter //nolint:golint
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestControlAPI(t *testing.T) {
+	var cfg Config
+	require.NoError(t, yaml.UnmarshalStrict([]byte("enabled_collectors: cpu,process\ncollector_timeouts: {process: 10s}\nprocess: {whitelist: agent.*}"), &cfg))
+
+	var applied *Config
+	api := newControlAPI(cfg, []string{"process", "cpu", "mssql", "hyperv"}, func(c *Config) error {
+		if strings.Contains(c.EnabledCollectors, "hyperv") {
+			return errors.New("hyperv is not installed")
+		}
+		applied = c
+		return nil
+	})
+	handler := api.Handler()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	t.Run("list", func(t *testing.T) {
+		rec := do(http.MethodGet, "/collectors", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var statuses []collectorStatus
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &statuses))
+		require.Len(t, statuses, 4)
+		require.Equal(t, collectorStatus{Name: "cpu", Enabled: true, Timeout: "4m0s"}, statuses[0])
+		require.Equal(t, "10s", statuses[3].Timeout)
+		require.Equal(t, "agent.*", statuses[3].Config["whitelist"], "settings should be reported by YAML name")
+	})
+
+	t.Run("enable and configure", func(t *testing.T) {
+		rec := do(http.MethodPut, "/collectors/mssql", `{"enabled": true, "timeout": "30s", "config": {"enabled_classes": "sqlstats"}}`)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		require.Equal(t, "cpu,process,mssql", applied.EnabledCollectors)
+		require.Equal(t, 30*time.Second, applied.CollectorTimeout("mssql"))
+		require.Equal(t, "sqlstats", applied.MSSQL.EnabledClasses)
+	})
+
+	t.Run("disable and reset timeout", func(t *testing.T) {
+		rec := do(http.MethodPut, "/collectors/process", "enabled: false\ntimeout: 0s")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		require.Equal(t, "cpu,mssql", applied.EnabledCollectors)
+		require.Equal(t, 4*time.Minute, applied.CollectorTimeout("process"))
+		require.Equal(t, "sqlstats", applied.MSSQL.EnabledClasses, "earlier changes should be kept")
+	})
+
+	t.Run("failed apply", func(t *testing.T) {
+		rec := do(http.MethodPut, "/collectors/hyperv", `{"enabled": true}`)
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+
+		rec = do(http.MethodGet, "/collectors/hyperv", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Contains(t, rec.Body.String(), `"enabled":false`)
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		require.Equal(t, http.StatusNotFound, do(http.MethodPut, "/collectors/ad", `{"enabled": true}`).Code)
+		require.Equal(t, http.StatusBadRequest, do(http.MethodPut, "/collectors/cpu", `{"config": {"whitelist": "x"}}`).Code)
+		require.Equal(t, http.StatusBadRequest, do(http.MethodPut, "/collectors/process", `{"config": {"allowlist": "x"}}`).Code)
+		require.Equal(t, http.StatusBadRequest, do(http.MethodPut, "/collectors/process", `{"timeout": "-1s"}`).Code)
+	})
+
+	require.Equal(t, "cpu,process", cfg.EnabledCollectors, "the original config should not be modified")
+	require.Len(t, cfg.CollectorTimeouts, 1)
+}
+
+func TestConfig_Timeouts(t *testing.T) {
+	var cfg Config
+	require.EqualError(t, yaml.UnmarshalStrict([]byte("collector_timeouts: {mssql: 0s}"), &cfg),
+		`collector_timeouts: timeout of collector "mssql" must be greater than 0`)
+
+	require.Equal(t, DefaultConfig.Timeout, (&Config{}).CollectorTimeout("cpu"))
+}
@@ -0,0 +1,94 @@
+package windows_exporter //nolint:golint
+
+import (
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	scrapeDurationDesc = prometheus.NewDesc(
+		"windows_exporter_collector_duration_seconds",
+		"windows_exporter: Duration of a collection.",
+		[]string{"collector"},
+		nil,
+	)
+	scrapeSuccessDesc = prometheus.NewDesc(
+		"windows_exporter_collector_success",
+		"windows_exporter: Whether the collector was successful.",
+		[]string{"collector"},
+		nil,
+	)
+	scrapeTimeoutDesc = prometheus.NewDesc(
+		"windows_exporter_collector_timeout",
+		"windows_exporter: Whether the collector timed out.",
+		[]string{"collector"},
+		nil,
+	)
+)
+
+// collectFunc collects the metrics of a single collector.
+type collectFunc func(ch chan<- prometheus.Metric) error
+
+// collectWithTimeout runs collect and sends its metrics to ch once it
+// finishes. The metrics of collectors which don't finish within timeout are
+// dropped, and the collectors are left to finish in the background.
+func collectWithTimeout(l log.Logger, name string, timeout time.Duration, collect collectFunc, ch chan<- prometheus.Metric) {
+	var (
+		start   = time.Now()
+		buf     = make(chan prometheus.Metric)
+		done    = make(chan error, 1)
+		metrics []prometheus.Metric
+	)
+	go func() {
+		err := collect(buf)
+		close(buf)
+		done <- err
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var (
+		recv = buf // Set to nil once buf is closed
+		err  error
+	)
+Collect:
+	for {
+		select {
+		case m, ok := <-recv:
+			if !ok {
+				recv = nil
+				continue
+			}
+			metrics = append(metrics, m)
+		case err = <-done:
+			break Collect
+		case <-timer.C:
+			// Drain the remaining metrics so that the collector can finish.
+			go func() {
+				for range buf {
+				}
+			}()
+			level.Warn(l).Log("msg", "collector timed out", "collector", name, "timeout", timeout)
+			ch <- prometheus.MustNewConstMetric(scrapeSuccessDesc, prometheus.GaugeValue, 0, name)
+			ch <- prometheus.MustNewConstMetric(scrapeTimeoutDesc, prometheus.GaugeValue, 1, name)
+			return
+		}
+	}
+
+	for _, m := range metrics {
+		ch <- m
+	}
+
+	success := 1.0
+	if err != nil {
+		level.Error(l).Log("msg", "collector failed", "collector", name, "duration_seconds", time.Since(start).Seconds(), "err", err)
+		success = 0
+	}
+	ch <- prometheus.MustNewConstMetric(scrapeDurationDesc, prometheus.GaugeValue, time.Since(start).Seconds(), name)
+	ch <- prometheus.MustNewConstMetric(scrapeSuccessDesc, prometheus.GaugeValue, success, name)
+	ch <- prometheus.MustNewConstMetric(scrapeTimeoutDesc, prometheus.GaugeValue, 0, name)
+}
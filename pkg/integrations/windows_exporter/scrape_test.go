@@ -0,0 +1,77 @@
+package windows_exporter //nolint:golint
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+)
+
+var testDesc = prometheus.NewDesc("windows_test_value", "Test value.", nil, nil)
+
+// collectAll runs collectWithTimeout and returns the collected metrics by
+// name.
+func collectAll(t *testing.T, timeout time.Duration, collect collectFunc) map[string]float64 {
+	t.Helper()
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		collectWithTimeout(log.NewNopLogger(), "test", timeout, collect, ch)
+		close(ch)
+	}()
+
+	res := make(map[string]float64)
+	for m := range ch {
+		var pb dto.Metric
+		require.NoError(t, m.Write(&pb))
+		res[m.Desc().String()] = pb.GetGauge().GetValue()
+	}
+	return res
+}
+
+func Test_collectWithTimeout(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		res := collectAll(t, time.Minute, func(ch chan<- prometheus.Metric) error {
+			ch <- prometheus.MustNewConstMetric(testDesc, prometheus.GaugeValue, 42)
+			return nil
+		})
+		require.Equal(t, 42.0, res[testDesc.String()])
+		require.Equal(t, 1.0, res[scrapeSuccessDesc.String()])
+		require.Equal(t, 0.0, res[scrapeTimeoutDesc.String()])
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		res := collectAll(t, time.Minute, func(ch chan<- prometheus.Metric) error {
+			return errors.New("WMI query failed")
+		})
+		require.Equal(t, 0.0, res[scrapeSuccessDesc.String()])
+		require.Equal(t, 0.0, res[scrapeTimeoutDesc.String()])
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		release := make(chan struct{})
+		finished := make(chan struct{})
+		res := collectAll(t, 10*time.Millisecond, func(ch chan<- prometheus.Metric) error {
+			defer close(finished)
+			ch <- prometheus.MustNewConstMetric(testDesc, prometheus.GaugeValue, 42)
+			<-release
+			ch <- prometheus.MustNewConstMetric(testDesc, prometheus.GaugeValue, 43)
+			return nil
+		})
+		require.NotContains(t, res, testDesc.String(), "metrics of collectors which time out should be dropped")
+		require.Equal(t, 0.0, res[scrapeSuccessDesc.String()])
+		require.Equal(t, 1.0, res[scrapeTimeoutDesc.String()])
+
+		// The collector is able to finish in the background.
+		close(release)
+		select {
+		case <-finished:
+		case <-time.After(5 * time.Second):
+			t.Fatal("collector did not finish")
+		}
+	})
+}
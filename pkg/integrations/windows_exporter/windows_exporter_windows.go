@@ -2,26 +2,57 @@ package windows_exporter //nolint:golint
 
 import (
 	"fmt"
+	"net/http"
 	"sort"
 	"strings"
-	"time"
+	"sync"
 
 	"github.com/go-kit/log"
 	"github.com/grafana/agent/pkg/integrations"
 	"github.com/prometheus-community/windows_exporter/collector"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/statsd_exporter/pkg/level"
 )
 
+// Integration is the windows_exporter integration. Its collectors can be
+// toggled and configured at runtime through its control handler.
+type Integration struct {
+	*integrations.CollectorIntegration
+	log     log.Logger
+	control *controlAPI
+
+	mut        sync.RWMutex
+	cfg        Config
+	collectors map[string]collector.Collector
+}
+
 // New creates a new windows_exporter integration.
 func New(log log.Logger, c *Config) (integrations.Integration, error) {
+	i := &Integration{log: log}
+	if err := i.apply(c); err != nil {
+		return nil, err
+	}
+
+	available := collector.AllConfigs()
+	availableNames := make([]string, 0, len(available))
+	for _, ac := range available {
+		availableNames = append(availableNames, ac.Name())
+	}
+	i.control = newControlAPI(*c, availableNames, i.apply)
+
+	i.CollectorIntegration = integrations.NewCollectorIntegration(c.Name(), integrations.WithCollectors(i))
+	return i, nil
+}
+
+// apply builds the collectors enabled by c, replacing the running ones.
+func (i *Integration) apply(c *Config) error {
 	// Get a list of collector configs and map our local config to it.
 	availableConfigs := collector.AllConfigs()
 	c.toExporterConfig(availableConfigs)
 
-	enabledCollectorNames := enabledCollectors(c.EnabledCollectors)
-	collectors, err := buildCollectors(enabledCollectorNames, availableConfigs)
+	collectors, err := buildCollectors(collectorList(c.EnabledCollectors), availableConfigs)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	collectorNames := make([]string, 0, len(collectors))
@@ -29,29 +60,57 @@ func New(log log.Logger, c *Config) (integrations.Integration, error) {
 		collectorNames = append(collectorNames, key)
 	}
 	sort.Strings(collectorNames)
-	level.Info(log).Log("msg", "enabled windows_exporter collectors", "collectors", strings.Join(collectorNames, ","))
+	level.Info(i.log).Log("msg", "enabled windows_exporter collectors", "collectors", strings.Join(collectorNames, ","))
+
+	i.mut.Lock()
+	defer i.mut.Unlock()
+	i.cfg, i.collectors = c.clone(), collectors
+	return nil
+}
 
-	return integrations.NewCollectorIntegration(c.Name(), integrations.WithCollectors(
-		// Hard-coded 4m timeout to represent the time a series goes stale.
-		// TODO: Make configurable if useful.
-		collector.NewPrometheus(4*time.Minute, collectors),
-	)), nil
+// ControlHandler returns the handler toggling and configuring collectors at
+// runtime.
+func (i *Integration) ControlHandler() (http.Handler, error) {
+	return i.control.Handler(), nil
 }
 
-func enabledCollectors(input string) []string {
-	separated := strings.Split(input, ",")
-	unique := map[string]struct{}{}
-	for _, s := range separated {
-		s = strings.TrimSpace(s)
-		if s != "" {
-			unique[s] = struct{}{}
+// Describe implements prometheus.Collector. The integration is unchecked, as
+// the enabled collectors may change at runtime.
+func (i *Integration) Describe(ch chan<- *prometheus.Desc) {}
+
+// Collect implements prometheus.Collector. Collectors are run concurrently,
+// each with its own timeout.
+func (i *Integration) Collect(ch chan<- prometheus.Metric) {
+	i.mut.RLock()
+	cfg, collectors := i.cfg, i.collectors
+	i.mut.RUnlock()
+
+	names := make([]string, 0, len(collectors))
+	for name := range collectors {
+		names = append(names, name)
+	}
+
+	scrapeContext, err := collector.PrepareScrapeContext(names)
+	if err != nil {
+		level.Error(i.log).Log("msg", "failed to prepare scrape", "err", err)
+		for _, name := range names {
+			ch <- prometheus.MustNewConstMetric(scrapeSuccessDesc, prometheus.GaugeValue, 0, name)
 		}
+		return
 	}
-	result := make([]string, 0, len(unique))
-	for s := range unique {
-		result = append(result, s)
+
+	var wg sync.WaitGroup
+	for name, c := range collectors {
+		wg.Add(1)
+		go func(name string, c collector.Collector) {
+			defer wg.Done()
+			collect := func(ch chan<- prometheus.Metric) error {
+				return c.Collect(scrapeContext, ch)
+			}
+			collectWithTimeout(i.log, name, cfg.CollectorTimeout(name), collect, ch)
+		}(name, c)
 	}
-	return result
+	wg.Wait()
 }
 
 func buildCollectors(enabled []string, available []collector.Config) (map[string]collector.Collector, error) {
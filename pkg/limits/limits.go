@@ -0,0 +1,279 @@
+// Package limits implements per-tenant usage limits shared by the metrics,
+// logs, and traces subsystems. Tenants are the names of metrics, logs, and
+// traces configs, and limits are evaluated where each subsystem ingests data.
+package limits
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+)
+
+// Policy is what happens to data over a limit.
+type Policy string
+
+// Supported values for Policy.
+const (
+	// PolicyDrop silently drops data over a limit.
+	PolicyDrop Policy = "drop"
+	// PolicyReject returns an error for data over a limit, so that senders
+	// are told their data wasn't accepted and may retry it later.
+	PolicyReject Policy = "reject"
+)
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (p *Policy) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+
+	switch policy := Policy(s); policy {
+	case "":
+		*p = PolicyDrop
+	case PolicyDrop, PolicyReject:
+		*p = policy
+	default:
+		return fmt.Errorf("unrecognized limits policy %q, expected drop or reject", s)
+	}
+	return nil
+}
+
+// Signal is a type of data limits apply to.
+type Signal string
+
+// Supported values for Signal.
+const (
+	SignalMetrics Signal = "metrics"
+	SignalLogs    Signal = "logs"
+	SignalTraces  Signal = "traces"
+)
+
+// DefaultConfig holds default options for Config.
+var DefaultConfig = Config{
+	Policy: PolicyDrop,
+}
+
+// Config configures the limits of tenants.
+type Config struct {
+	// Policy is what happens to data over a limit.
+	Policy Policy `yaml:"policy,omitempty"`
+	// Default holds the limits of tenants which aren't in Tenants.
+	Default TenantLimits `yaml:"default,omitempty"`
+	// Tenants holds the limits of individual tenants by name. They replace
+	// the default limits.
+	Tenants map[string]TenantLimits `yaml:"tenants,omitempty"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultConfig
+
+	type plain Config
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	if err := c.Default.validate(); err != nil {
+		return fmt.Errorf("default limits: %w", err)
+	}
+	for name, tl := range c.Tenants {
+		if err := tl.validate(); err != nil {
+			return fmt.Errorf("limits of tenant %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// TenantLimits are the limits of a tenant. A limit of 0 means unlimited.
+type TenantLimits struct {
+	// MaxActiveSeries is the maximum number of active series in the WAL of a
+	// metrics config. Samples of new series are refused once it's reached.
+	MaxActiveSeries int `yaml:"max_active_series,omitempty"`
+	// MaxLogBytesPerSecond is the maximum rate of log line bytes sent through
+	// a logs config.
+	MaxLogBytesPerSecond float64 `yaml:"max_log_bytes_per_second,omitempty"`
+	// MaxSpansPerSecond is the maximum rate of spans received by a traces
+	// config.
+	MaxSpansPerSecond float64 `yaml:"max_spans_per_second,omitempty"`
+}
+
+func (tl TenantLimits) validate() error {
+	if tl.MaxActiveSeries < 0 || tl.MaxLogBytesPerSecond < 0 || tl.MaxSpansPerSecond < 0 {
+		return errors.New("limits must not be negative")
+	}
+	return nil
+}
+
+// Error is returned for data refused by a Limiter.
+type Error struct {
+	Signal Signal
+	Tenant string
+	Limit  string
+	// Reject is true when the data was refused under PolicyReject.
+	Reject bool
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s limit %s of tenant %s reached", e.Signal, e.Limit, e.Tenant)
+}
+
+// Rejected returns true if err is an *Error for data refused under
+// PolicyReject. Callers drop refused data silently otherwise.
+func Rejected(err error) bool {
+	var le *Error
+	return errors.As(err, &le) && le.Reject
+}
+
+// timeNow is used to rate limit tenants. It's replaced in tests.
+var timeNow = time.Now
+
+// Limiter evaluates the limits of tenants. A nil *Limiter allows everything.
+type Limiter struct {
+	mut     sync.Mutex
+	cfg     Config
+	tenants map[string]*tenantState
+
+	refused *prometheus.CounterVec
+}
+
+type tenantState struct {
+	logBytes *rate.Limiter
+	spans    *rate.Limiter
+}
+
+// New creates a new Limiter.
+func New(reg prometheus.Registerer, cfg Config) *Limiter {
+	l := &Limiter{
+		tenants: make(map[string]*tenantState),
+
+		refused: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "agent_limits_refused_total",
+			Help: "Total number of samples, log entries, and spans refused because the limits of their tenant were reached.",
+		}, []string{"signal", "tenant"}),
+	}
+	if reg != nil {
+		reg.MustRegister(l.refused)
+	}
+	l.ApplyConfig(cfg)
+	return l
+}
+
+// ApplyConfig updates the limits of l.
+func (l *Limiter) ApplyConfig(cfg Config) {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	l.cfg = cfg
+	now := timeNow()
+	for name, ts := range l.tenants {
+		tl := l.tenantLimits(name)
+		ts.logBytes.SetLimitAt(now, rateLimit(tl.MaxLogBytesPerSecond))
+		ts.logBytes.SetBurstAt(now, rateBurst(tl.MaxLogBytesPerSecond))
+		ts.spans.SetLimitAt(now, rateLimit(tl.MaxSpansPerSecond))
+		ts.spans.SetBurstAt(now, rateBurst(tl.MaxSpansPerSecond))
+	}
+}
+
+// tenantLimits returns the limits of tenant. mut must be held when called.
+func (l *Limiter) tenantLimits(tenant string) TenantLimits {
+	if tl, ok := l.cfg.Tenants[tenant]; ok {
+		return tl
+	}
+	return l.cfg.Default
+}
+
+// tenant returns the state of tenant. mut must be held when called.
+func (l *Limiter) tenant(tenant string) *tenantState {
+	ts, ok := l.tenants[tenant]
+	if !ok {
+		tl := l.tenantLimits(tenant)
+		ts = &tenantState{
+			logBytes: rate.NewLimiter(rateLimit(tl.MaxLogBytesPerSecond), rateBurst(tl.MaxLogBytesPerSecond)),
+			spans:    rate.NewLimiter(rateLimit(tl.MaxSpansPerSecond), rateBurst(tl.MaxSpansPerSecond)),
+		}
+		l.tenants[tenant] = ts
+	}
+	return ts
+}
+
+// rateLimit returns the rate.Limit of a limit per second, where 0 means
+// unlimited.
+func rateLimit(perSecond float64) rate.Limit {
+	if perSecond == 0 {
+		return rate.Inf
+	}
+	return rate.Limit(perSecond)
+}
+
+// rateBurst returns the burst of a limit per second, which allows one second
+// worth of data at once. Larger requests are always refused.
+func rateBurst(perSecond float64) int {
+	return int(math.Max(1, math.Ceil(perSecond)))
+}
+
+// AdmitSeries returns an error if tenant can't create a new series, given
+// its number of active series.
+func (l *Limiter) AdmitSeries(tenant string, active int) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	max := l.tenantLimits(tenant).MaxActiveSeries
+	if max == 0 || active < max {
+		return nil
+	}
+	return l.refuse(SignalMetrics, tenant, "max_active_series", 1)
+}
+
+// AdmitLogBytes returns an error if an entry of n bytes of tenant exceeds
+// its log throughput.
+func (l *Limiter) AdmitLogBytes(tenant string, n int) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	if l.tenant(tenant).logBytes.AllowN(timeNow(), n) {
+		return nil
+	}
+	return l.refuse(SignalLogs, tenant, "max_log_bytes_per_second", 1)
+}
+
+// AdmitSpans returns an error if n spans of tenant exceed its span rate.
+func (l *Limiter) AdmitSpans(tenant string, n int) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	if l.tenant(tenant).spans.AllowN(timeNow(), n) {
+		return nil
+	}
+	return l.refuse(SignalTraces, tenant, "max_spans_per_second", n)
+}
+
+// refuse counts n refused items and returns the error describing the limit.
+// mut must be held when called.
+func (l *Limiter) refuse(signal Signal, tenant, limit string, n int) error {
+	l.refused.WithLabelValues(string(signal), tenant).Add(float64(n))
+	return &Error{
+		Signal: signal,
+		Tenant: tenant,
+		Limit:  limit,
+		Reject: l.cfg.Policy == PolicyReject,
+	}
+}
@@ -0,0 +1,110 @@
+package limits
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestConfig_UnmarshalYAML(t *testing.T) {
+	in := `
+policy: reject
+default:
+  max_active_series: 1000
+tenants:
+  big:
+    max_active_series: 5000
+    max_spans_per_second: 100
+`
+	var cfg Config
+	require.NoError(t, yaml.UnmarshalStrict([]byte(in), &cfg))
+	require.Equal(t, Config{
+		Policy:  PolicyReject,
+		Default: TenantLimits{MaxActiveSeries: 1000},
+		Tenants: map[string]TenantLimits{
+			"big": {MaxActiveSeries: 5000, MaxSpansPerSecond: 100},
+		},
+	}, cfg)
+
+	require.NoError(t, yaml.UnmarshalStrict([]byte(`{}`), &cfg))
+	require.Equal(t, PolicyDrop, cfg.Policy)
+
+	err := yaml.UnmarshalStrict([]byte(`policy: ignore`), &cfg)
+	require.EqualError(t, err, `unrecognized limits policy "ignore", expected drop or reject`)
+
+	err = yaml.UnmarshalStrict([]byte("tenants: {big: {max_log_bytes_per_second: -1}}"), &cfg)
+	require.EqualError(t, err, "limits of tenant big: limits must not be negative")
+}
+
+func TestLimiter_Nil(t *testing.T) {
+	var l *Limiter
+	require.NoError(t, l.AdmitSeries("test", 1e6))
+	require.NoError(t, l.AdmitLogBytes("test", 1e6))
+	require.NoError(t, l.AdmitSpans("test", 1e6))
+}
+
+func TestLimiter_AdmitSeries(t *testing.T) {
+	l := New(nil, Config{
+		Policy:  PolicyDrop,
+		Default: TenantLimits{MaxActiveSeries: 10},
+		Tenants: map[string]TenantLimits{"unlimited": {}},
+	})
+
+	require.NoError(t, l.AdmitSeries("test", 9))
+	err := l.AdmitSeries("test", 10)
+	require.EqualError(t, err, "metrics limit max_active_series of tenant test reached")
+	require.False(t, Rejected(err))
+
+	// Tenant limits replace the default limits.
+	require.NoError(t, l.AdmitSeries("unlimited", 10))
+}
+
+func TestLimiter_AdmitLogBytes(t *testing.T) {
+	now := time.Unix(0, 0)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	reg := prometheus.NewRegistry()
+	l := New(reg, Config{
+		Policy:  PolicyReject,
+		Default: TenantLimits{MaxLogBytesPerSecond: 100},
+	})
+
+	// One second worth of bytes may be sent at once.
+	require.NoError(t, l.AdmitLogBytes("test", 60))
+	require.NoError(t, l.AdmitLogBytes("test", 40))
+	err := l.AdmitLogBytes("test", 1)
+	require.Error(t, err)
+	require.True(t, Rejected(err))
+
+	now = now.Add(500 * time.Millisecond)
+	require.NoError(t, l.AdmitLogBytes("test", 50))
+
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP agent_limits_refused_total Total number of samples, log entries, and spans refused because the limits of their tenant were reached.
+		# TYPE agent_limits_refused_total counter
+		agent_limits_refused_total{signal="logs",tenant="test"} 1
+	`)))
+}
+
+func TestLimiter_ApplyConfig(t *testing.T) {
+	now := time.Unix(0, 0)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	l := New(nil, Config{Default: TenantLimits{MaxSpansPerSecond: 10}})
+	require.Error(t, l.AdmitSpans("test", 20))
+
+	// Existing tenants pick up new limits.
+	l.ApplyConfig(Config{Default: TenantLimits{MaxSpansPerSecond: 100}})
+	now = now.Add(time.Second)
+	require.NoError(t, l.AdmitSpans("test", 20))
+
+	l.ApplyConfig(Config{})
+	require.NoError(t, l.AdmitSpans("test", 1e6))
+}
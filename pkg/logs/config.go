@@ -9,11 +9,13 @@ import (
 	"github.com/grafana/loki/clients/pkg/promtail/positions"
 	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
 	"github.com/grafana/loki/clients/pkg/promtail/targets/file"
+	"gopkg.in/yaml.v2"
 )
 
 // Config controls the configuration of the Loki log scraper.
 type Config struct {
 	PositionsDirectory string            `yaml:"positions_directory,omitempty"`
+	WALDirectory       string            `yaml:"wal_directory,omitempty"`
 	Configs            []*InstanceConfig `yaml:"configs,omitempty"`
 }
 
@@ -37,15 +39,22 @@ func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
 //   3. No InstanceConfig may have an empty name.
 //   4. If InstanceConfig positions path is empty, shared PositionsDirectory
 //      must not be empty.
+//   5. No InstanceConfig may import positions from its own positions path.
+//   6. If InstanceConfig enables its WAL without a directory, shared
+//      WALDirectory must not be empty.
+//   7. No two InstanceConfigs may have the same WAL directory.
 //
 // Defaults:
 //
 //   1. If a positions config is empty, it will be generated based on
 //      the InstanceConfig name and Config.PositionsDirectory.
+//   2. If a WAL directory is empty, it will be generated based on the
+//      InstanceConfig name and Config.WALDirectory.
 func (c *Config) ApplyDefaults() error {
 	var (
 		names     = map[string]struct{}{}
 		positions = map[string]string{} // positions file name -> config using it
+		wals      = map[string]string{} // WAL directory -> config using it
 	)
 
 	for idx, ic := range c.Configs {
@@ -67,6 +76,24 @@ func (c *Config) ApplyDefaults() error {
 			return fmt.Errorf("Loki configs %s and %s must have different positions file paths", orig, ic.Name)
 		}
 		positions[ic.PositionsConfig.PositionsFile] = ic.Name
+
+		if ic.ImportPositionsFile != "" && filepath.Clean(ic.ImportPositionsFile) == filepath.Clean(ic.PositionsConfig.PositionsFile) {
+			return fmt.Errorf("Loki config %s must not import positions from its own positions file", ic.Name)
+		}
+
+		if !ic.WAL.Enabled {
+			continue
+		}
+		if ic.WAL.Dir == "" {
+			if c.WALDirectory == "" {
+				return fmt.Errorf("cannot generate Loki WAL directory for %s because wal_directory is not configured", ic.Name)
+			}
+			ic.WAL.Dir = filepath.Join(c.WALDirectory, ic.Name)
+		}
+		if orig, ok := wals[filepath.Clean(ic.WAL.Dir)]; ok {
+			return fmt.Errorf("Loki configs %s and %s must have different WAL directories", orig, ic.Name)
+		}
+		wals[filepath.Clean(ic.WAL.Dir)] = ic.Name
 	}
 
 	return nil
@@ -80,6 +107,20 @@ type InstanceConfig struct {
 	PositionsConfig positions.Config      `yaml:"positions,omitempty"`
 	ScrapeConfig    []scrapeconfig.Config `yaml:"scrape_configs,omitempty"`
 	TargetConfig    file.Config           `yaml:"target_config,omitempty"`
+
+	// ImportPositionsFile is the path to a promtail positions file whose
+	// positions are used when the instance's positions file doesn't exist
+	// yet.
+	ImportPositionsFile string `yaml:"import_positions_file,omitempty"`
+
+	// OutOfOrderWrites configures whether the Loki backends of the clients
+	// accept out-of-order writes. When they don't, entries sent by the agent
+	// itself are ordered per stream before being sent.
+	OutOfOrderWrites OutOfOrderMode `yaml:"out_of_order_writes,omitempty"`
+
+	// WAL configures buffering entries on disk before they're sent by the
+	// clients.
+	WAL WALConfig `yaml:"wal,omitempty"`
 }
 
 // UnmarshalYAML implements yaml.Unmarshaler.
@@ -92,7 +133,68 @@ func (c *InstanceConfig) UnmarshalYAML(unmarshal func(interface{}) error) error
 
 	// Blank out the positions file since we set our own default for that.
 	c.PositionsConfig.PositionsFile = ""
+	c.OutOfOrderWrites = OutOfOrderAuto
+	c.WAL = DefaultWALConfig
 
 	type instanceConfig InstanceConfig
-	return unmarshal((*instanceConfig)(c))
+
+	// Options of journal and windows_events blocks handled by the agent are
+	// unknown to Promtail, so they're removed before unmarshaling the rest of
+	// the config.
+	var raw map[string]interface{}
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+	journals, err := extractJournalConfigs(raw)
+	if err != nil {
+		return err
+	}
+	windowsEvents, err := extractWindowsEventsConfigs(raw)
+	if err != nil {
+		return err
+	}
+	if len(journals) == 0 && len(windowsEvents) == 0 {
+		return unmarshal((*instanceConfig)(c))
+	}
+
+	bb, err := yaml.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	if err := yaml.UnmarshalStrict(bb, (*instanceConfig)(c)); err != nil {
+		return err
+	}
+	applyJournalConfigs(c.ScrapeConfig, journals)
+	applyWindowsEventsConfigs(c.ScrapeConfig, windowsEvents)
+	return nil
+}
+
+// extractScrapeOptions removes keys from the block of the scrape configs in
+// raw, which is an unmarshaled InstanceConfig. It returns the removed options
+// marshaled to YAML by scrape config index, for scrape configs which had any
+// of keys.
+func extractScrapeOptions(raw map[string]interface{}, block string, keys []string) map[int][]byte {
+	scrapeConfigs, _ := raw["scrape_configs"].([]interface{})
+
+	res := make(map[int][]byte)
+	for i, sc := range scrapeConfigs {
+		scMap, _ := sc.(map[interface{}]interface{})
+		blockMap, _ := scMap[block].(map[interface{}]interface{})
+
+		extracted := make(map[interface{}]interface{})
+		for _, key := range keys {
+			if v, ok := blockMap[key]; ok {
+				extracted[key] = v
+				delete(blockMap, key)
+			}
+		}
+		if len(extracted) == 0 {
+			continue
+		}
+
+		// Marshaling values unmarshaled from YAML can't fail.
+		bb, _ := yaml.Marshal(extracted)
+		res[i] = bb
+	}
+	return res
 }
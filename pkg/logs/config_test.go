@@ -5,7 +5,16 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/go-kit/log"
+	"github.com/grafana/loki/clients/pkg/logentry/stages"
+	"github.com/grafana/loki/clients/pkg/promtail/api"
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/relabel"
 	"github.com/stretchr/testify/require"
 	"gopkg.in/yaml.v2"
 )
@@ -88,6 +97,43 @@ func TestConfig_ApplyDefaults_Validations(t *testing.T) {
 				- name: config-b
 		  `),
 		},
+		{
+			name: "importing own positions file",
+			err:  fmt.Errorf("Loki config config-a must not import positions from its own positions file"),
+			cfg: untab(`
+				positions_directory: /tmp
+				configs:
+				- name: config-a
+				  import_positions_file: /tmp/config-a.yml
+		  `),
+		},
+		{
+			name: "generated WAL directory without wal_directory",
+			err:  fmt.Errorf("cannot generate Loki WAL directory for config-a because wal_directory is not configured"),
+			cfg: untab(`
+				positions_directory: /tmp
+				configs:
+				- name: config-a
+				  wal:
+					  enabled: true
+		  `),
+		},
+		{
+			name: "re-used WAL directory",
+			err:  fmt.Errorf("Loki configs config-a and config-b must have different WAL directories"),
+			cfg: untab(`
+				positions_directory: /tmp
+				wal_directory: /tmp/wal
+				configs:
+				- name: config-a
+				  wal:
+					  enabled: true
+				- name: config-b
+				  wal:
+					  enabled: true
+					  dir: /tmp/wal/config-a/
+		  `),
+		},
 	}
 
 	for _, tc := range tt {
@@ -125,6 +171,228 @@ func TestConfig_ApplyDefaults_Defaults(t *testing.T) {
 	require.Equal(t, filepath.Join("/tmp", "config-b.yml"), pathB)
 }
 
+func TestConfig_ApplyDefaults_WAL(t *testing.T) {
+	cfgText := untab(`
+		positions_directory: /tmp
+		wal_directory: /tmp/wal
+		configs:
+		- name: config-a
+			wal:
+				enabled: true
+		- name: config-b
+			wal:
+				enabled: true
+				dir: /wal-b
+				max_size_bytes: 1048576
+		- name: config-c
+	`)
+	var cfg Config
+	require.NoError(t, yaml.UnmarshalStrict([]byte(cfgText), &cfg))
+
+	require.Equal(t, WALConfig{
+		Enabled:           true,
+		Dir:               filepath.Join("/tmp/wal", "config-a"),
+		TruncateFrequency: time.Minute,
+	}, cfg.Configs[0].WAL)
+	require.Equal(t, WALConfig{
+		Enabled:           true,
+		Dir:               "/wal-b",
+		MaxSizeBytes:      1048576,
+		TruncateFrequency: time.Minute,
+	}, cfg.Configs[1].WAL)
+	require.False(t, cfg.Configs[2].WAL.Enabled)
+}
+
+func TestInstanceConfig_Journal(t *testing.T) {
+	cfgText := untab(`
+		name: default
+		scrape_configs:
+		- job_name: journal
+			journal:
+				max_age: 12h
+				units: [docker.service, kubelet.service]
+				priority: warning
+				field_labels:
+					_SYSTEMD_UNIT: unit
+					_HOSTNAME: host
+			relabel_configs:
+			- action: labeldrop
+				regex: host
+	`)
+	var cfg InstanceConfig
+	require.NoError(t, yaml.UnmarshalStrict([]byte(cfgText), &cfg))
+
+	sc := cfg.ScrapeConfig[0]
+	require.NotNil(t, sc.JournalConfig)
+	require.Equal(t, "12h", sc.JournalConfig.MaxAge)
+	// Rules of the journal block run before the relabel_configs of the scrape
+	// config.
+	require.Len(t, sc.RelabelConfigs, 5)
+	require.Equal(t, relabel.LabelDrop, sc.RelabelConfigs[4].Action)
+
+	tt := []struct {
+		name   string
+		in     labels.Labels
+		expect labels.Labels
+	}{
+		{
+			name: "matching entry",
+			in: labels.FromStrings(
+				"__journal__systemd_unit", "docker.service",
+				"__journal_priority", "3",
+				"__journal__hostname", "node-a",
+			),
+			expect: labels.FromStrings(
+				"__journal__systemd_unit", "docker.service",
+				"__journal_priority", "3",
+				"__journal__hostname", "node-a",
+				"unit", "docker.service",
+			),
+		},
+		{
+			name: "other unit",
+			in: labels.FromStrings(
+				"__journal__systemd_unit", "sshd.service",
+				"__journal_priority", "3",
+			),
+		},
+		{
+			name: "lower priority",
+			in: labels.FromStrings(
+				"__journal__systemd_unit", "kubelet.service",
+				"__journal_priority", "6",
+			),
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expect, relabel.Process(tc.in, sc.RelabelConfigs...))
+		})
+	}
+}
+
+func TestInstanceConfig_Journal_Invalid(t *testing.T) {
+	tt := []struct {
+		name    string
+		journal string
+		err     string
+	}{
+		{
+			name:    "invalid priority",
+			journal: "priority: verbose",
+			err:     `scrape_configs[0]: invalid journal priority "verbose", expected a value between 0 and 7 or one of emerg, alert, crit, error, warning, notice, info, debug`,
+		},
+		{
+			name:    "invalid label",
+			journal: "field_labels: {_HOSTNAME: __host}",
+			err:     `scrape_configs[0]: journal field _HOSTNAME is mapped to invalid label name "__host"`,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			cfgText := "scrape_configs:\n- job_name: journal\n  journal:\n    " + tc.journal
+			var cfg InstanceConfig
+			require.EqualError(t, yaml.UnmarshalStrict([]byte(cfgText), &cfg), tc.err)
+		})
+	}
+}
+
+func TestInstanceConfig_WindowsEvents(t *testing.T) {
+	cfgText := untab(`
+		name: default
+		scrape_configs:
+		- job_name: windows
+			windows_events:
+				eventlog_name: Security
+				structured: true
+				structured_fields: [EventID, Level, Message]
+				event_data_fields: [TargetUserName, LogonType, Missing]
+			pipeline_stages:
+			- labels:
+					Level:
+	`)
+	var cfg InstanceConfig
+	require.NoError(t, yaml.UnmarshalStrict([]byte(cfgText), &cfg))
+
+	sc := cfg.ScrapeConfig[0]
+	require.Equal(t, "Security", sc.WindowsConfig.EventlogName)
+	// json, 3 regex, template and output stages run before the pipeline stages
+	// of the scrape config.
+	require.Len(t, sc.PipelineStages, 7)
+
+	pipeline, err := stages.NewPipeline(log.NewNopLogger(), sc.PipelineStages, &sc.JobName, prometheus.NewRegistry())
+	require.NoError(t, err)
+
+	tt := []struct {
+		name   string
+		line   string
+		expect string
+	}{
+		{
+			name:   "event",
+			line:   `{"source":"Microsoft-Windows-Security-Auditing","channel":"Security","event_id":4624,"levelText":"Information","message":"An account was successfully logged on.","event_data":"<Data Name='TargetUserName'>a&amp;b</Data><Data Name='LogonType'>2</Data>"}`,
+			expect: `{"EventData":{"LogonType":"2","TargetUserName":"a\u0026b"},"EventID":4624,"Level":"Information","Message":"An account was successfully logged on."}`,
+		},
+		{
+			name:   "event without data",
+			line:   `{"event_id":4625,"levelText":"Information"}`,
+			expect: `{"EventID":4625,"Level":"Information"}`,
+		},
+		{
+			name:   "not an event",
+			line:   `plain text`,
+			expect: `plain text`,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			in := make(chan stages.Entry, 1)
+			in <- stages.Entry{
+				Extracted: map[string]interface{}{},
+				Entry: api.Entry{
+					Labels: model.LabelSet{},
+					Entry:  logproto.Entry{Timestamp: time.Now(), Line: tc.line},
+				},
+			}
+			close(in)
+
+			out := <-pipeline.Run(in)
+			require.Equal(t, tc.expect, out.Line)
+		})
+	}
+}
+
+func TestInstanceConfig_WindowsEvents_Invalid(t *testing.T) {
+	tt := []struct {
+		name    string
+		windows string
+		err     string
+	}{
+		{
+			name:    "fields without structured",
+			windows: "event_data_fields: [LogonType]",
+			err:     "scrape_configs[0]: windows_events structured_fields and event_data_fields require structured to be true",
+		},
+		{
+			name:    "unknown field",
+			windows: "{structured: true, structured_fields: [Opcodes]}",
+			err:     `scrape_configs[0]: unknown windows_events structured field "Opcodes", expected one of EventID, EventRecordID, Provider, Channel, Computer, Level, Task, Opcode, Keywords, TimeCreated, Message`,
+		},
+		{
+			name:    "invalid event data field",
+			windows: "{structured: true, event_data_fields: [Logon-Type]}",
+			err:     `scrape_configs[0]: invalid windows_events event data field "Logon-Type"`,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			cfgText := "scrape_configs:\n- job_name: windows\n  windows_events:\n    " + tc.windows
+			var cfg InstanceConfig
+			require.EqualError(t, yaml.UnmarshalStrict([]byte(cfgText), &cfg), tc.err)
+		})
+	}
+}
+
 // untab is a utility function to make it easier to write YAML tests, where some editors
 // will insert tabs into strings by default.
 func untab(s string) string {
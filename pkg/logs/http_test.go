@@ -29,7 +29,7 @@ configs:
 	var cfg Config
 
 	logger := util.TestLogger(t)
-	l, err := New(prometheus.NewRegistry(), &cfg, logger)
+	l, err := New(prometheus.NewRegistry(), &cfg, logger, nil)
 	require.NoError(t, err)
 	defer l.Stop()
 
@@ -73,7 +73,7 @@ configs:
 	require.NoError(t, dec.Decode(&cfg))
 
 	logger := util.TestLogger(t)
-	l, err := New(prometheus.NewRegistry(), &cfg, logger)
+	l, err := New(prometheus.NewRegistry(), &cfg, logger, nil)
 	require.NoError(t, err)
 	defer l.Stop()
 
@@ -0,0 +1,146 @@
+package logs
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/relabel"
+	"gopkg.in/yaml.v2"
+)
+
+// journalPriorities are the names of journal priorities, indexed by their
+// value.
+var journalPriorities = []string{"emerg", "alert", "crit", "error", "warning", "notice", "info", "debug"}
+
+// JournalConfig holds the options of the journal block of scrape configs
+// which are handled by the agent rather than Promtail. They're applied as
+// relabel rules which run before the relabel_configs of the scrape config.
+type JournalConfig struct {
+	// Units only keeps entries of these systemd units.
+	Units []string `yaml:"units,omitempty"`
+	// Priority only keeps entries of this priority or more important ones.
+	Priority string `yaml:"priority,omitempty"`
+	// FieldLabels maps journal fields, such as _SYSTEMD_UNIT, to the labels
+	// their values are set to.
+	FieldLabels map[string]string `yaml:"field_labels,omitempty"`
+}
+
+// journalConfigKeys are the keys of the journal block handled by JournalConfig.
+var journalConfigKeys = []string{"units", "priority", "field_labels"}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (c *JournalConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain JournalConfig
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	for _, unit := range c.Units {
+		if unit == "" {
+			return fmt.Errorf("journal units must not be empty")
+		}
+	}
+	if c.Priority != "" {
+		if _, err := parseJournalPriority(c.Priority); err != nil {
+			return err
+		}
+	}
+	for field, label := range c.FieldLabels {
+		if field == "" {
+			return fmt.Errorf("journal field_labels must not have empty fields")
+		}
+		if !model.LabelName(label).IsValid() || strings.HasPrefix(label, model.ReservedLabelPrefix) {
+			return fmt.Errorf("journal field %s is mapped to invalid label name %q", field, label)
+		}
+	}
+	return nil
+}
+
+// parseJournalPriority parses the name or value of a journal priority.
+func parseJournalPriority(s string) (int, error) {
+	for value, name := range journalPriorities {
+		if s == name || s == strconv.Itoa(value) {
+			return value, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid journal priority %q, expected a value between 0 and 7 or one of %s", s, strings.Join(journalPriorities, ", "))
+}
+
+// relabelConfigs returns the relabel rules applying c.
+func (c *JournalConfig) relabelConfigs() []*relabel.Config {
+	var rules []*relabel.Config
+
+	if len(c.Units) > 0 {
+		units := make([]string, 0, len(c.Units))
+		for _, unit := range c.Units {
+			units = append(units, regexp.QuoteMeta(unit))
+		}
+		rules = append(rules, &relabel.Config{
+			SourceLabels: model.LabelNames{"__journal__systemd_unit"},
+			Separator:    relabel.DefaultRelabelConfig.Separator,
+			Regex:        relabel.MustNewRegexp(strings.Join(units, "|")),
+			Action:       relabel.Keep,
+		})
+	}
+
+	if c.Priority != "" {
+		// Validated when unmarshaling.
+		priority, _ := parseJournalPriority(c.Priority)
+		rules = append(rules, &relabel.Config{
+			SourceLabels: model.LabelNames{"__journal_priority"},
+			Separator:    relabel.DefaultRelabelConfig.Separator,
+			Regex:        relabel.MustNewRegexp(fmt.Sprintf("[0-%d]", priority)),
+			Action:       relabel.Keep,
+		})
+	}
+
+	fields := make([]string, 0, len(c.FieldLabels))
+	for field := range c.FieldLabels {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		rules = append(rules, &relabel.Config{
+			SourceLabels: model.LabelNames{model.LabelName("__journal_" + strings.ToLower(field))},
+			Separator:    relabel.DefaultRelabelConfig.Separator,
+			Regex:        relabel.DefaultRelabelConfig.Regex,
+			TargetLabel:  c.FieldLabels[field],
+			Replacement:  relabel.DefaultRelabelConfig.Replacement,
+			Action:       relabel.Replace,
+		})
+	}
+
+	return rules
+}
+
+// extractJournalConfigs removes the options handled by JournalConfig from
+// the journal blocks of the scrape configs in raw, which is an unmarshaled
+// InstanceConfig. It returns the JournalConfigs by scrape config index.
+func extractJournalConfigs(raw map[string]interface{}) (map[int]*JournalConfig, error) {
+	res := make(map[int]*JournalConfig)
+	for i, bb := range extractScrapeOptions(raw, "journal", journalConfigKeys) {
+		var jc JournalConfig
+		if err := yaml.UnmarshalStrict(bb, &jc); err != nil {
+			return nil, fmt.Errorf("scrape_configs[%d]: %w", i, err)
+		}
+		res[i] = &jc
+	}
+	return res, nil
+}
+
+// applyJournalConfigs prepends the relabel rules of journals to the relabel
+// configs of the scrape configs.
+func applyJournalConfigs(scrapeConfigs []scrapeconfig.Config, journals map[int]*JournalConfig) {
+	for i, jc := range journals {
+		if i >= len(scrapeConfigs) {
+			continue
+		}
+		sc := &scrapeConfigs[i]
+		sc.RelabelConfigs = append(jc.relabelConfigs(), sc.RelabelConfigs...)
+	}
+}
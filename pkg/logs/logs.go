@@ -2,23 +2,28 @@
 package logs
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 	_ "time/tzdata" // embed timezone data
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	"github.com/grafana/agent/pkg/limits"
 	"github.com/grafana/agent/pkg/util"
 	"github.com/grafana/loki/clients/pkg/promtail"
 	"github.com/grafana/loki/clients/pkg/promtail/api"
 	"github.com/grafana/loki/clients/pkg/promtail/client"
 	"github.com/grafana/loki/clients/pkg/promtail/config"
 	"github.com/grafana/loki/clients/pkg/promtail/server"
+	"github.com/grafana/loki/clients/pkg/promtail/targets/target"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/version"
+	"go.uber.org/atomic"
 )
 
 func init() {
@@ -32,15 +37,18 @@ type Logs struct {
 
 	reg       prometheus.Registerer
 	l         log.Logger
+	limiter   *limits.Limiter
 	instances map[string]*Instance
 }
 
-// New creates and starts Loki log collection.
-func New(reg prometheus.Registerer, c *Config, l log.Logger) (*Logs, error) {
+// New creates and starts Loki log collection. The log throughput of instances
+// is limited by limiter, which may be nil.
+func New(reg prometheus.Registerer, c *Config, l log.Logger, limiter *limits.Limiter) (*Logs, error) {
 	logs := &Logs{
 		instances: make(map[string]*Instance),
 		reg:       reg,
 		l:         log.With(l, "component", "logs"),
+		limiter:   limiter,
 	}
 	if err := logs.ApplyConfig(c); err != nil {
 		return nil, err
@@ -71,7 +79,7 @@ func (l *Logs) ApplyConfig(c *Config) error {
 			continue
 		}
 
-		inst, err := NewInstance(l.reg, ic, l.l)
+		inst, err := NewInstance(l.reg, ic, l.l, l.limiter)
 		if err != nil {
 			return fmt.Errorf("unable to apply config for %s: %w", ic.Name, err)
 		}
@@ -113,20 +121,45 @@ func (l *Logs) Instance(name string) *Instance {
 type Instance struct {
 	mut sync.Mutex
 
-	cfg *InstanceConfig
-	log log.Logger
-	reg *util.Unregisterer
+	cfg     *InstanceConfig
+	log     log.Logger
+	reg     *util.Unregisterer
+	limiter *limits.Limiter
+
+	promtail promtailer
+	// promtailReg holds the metrics of promtail.
+	promtailReg *util.Unregisterer
+	// filtered is true when entries of the targets of promtail are passed
+	// through admit.
+	filtered bool
+
+	// outOfOrder holds whether the clients accept out-of-order writes.
+	// Per-stream ordering is only enforced when they're rejected.
+	outOfOrder   atomic.Int32
+	orderer      *streamOrderer
+	cancelDetect context.CancelFunc
+
+	// admit returns true if an entry may be sent to the clients.
+	admit func(api.Entry) bool
+}
 
-	promtail *promtail.Promtail
+// promtailer runs the targets of an instance and sends their entries to the
+// clients. It's implemented by *promtail.Promtail and *agentPromtail.
+type promtailer interface {
+	Client() client.Client
+	ActiveTargets() map[string][]target.Target
+	Shutdown()
 }
 
-// NewInstance creates and starts a Logs instance.
-func NewInstance(reg prometheus.Registerer, c *InstanceConfig, l log.Logger) (*Instance, error) {
+// NewInstance creates and starts a Logs instance. Its log throughput is
+// limited by limiter, which may be nil.
+func NewInstance(reg prometheus.Registerer, c *InstanceConfig, l log.Logger, limiter *limits.Limiter) (*Instance, error) {
 	instReg := prometheus.WrapRegistererWith(prometheus.Labels{"logs_config": c.Name}, reg)
 
 	inst := Instance{
-		reg: util.WrapWithUnregisterer(instReg),
-		log: log.With(l, "logs_config", c.Name),
+		reg:     util.WrapWithUnregisterer(instReg),
+		log:     log.With(l, "logs_config", c.Name),
+		limiter: limiter,
 	}
 	if err := inst.ApplyConfig(c); err != nil {
 		return nil, err
@@ -154,10 +187,17 @@ func (i *Instance) ApplyConfig(c *InstanceConfig) error {
 		level.Warn(i.log).Log("msg", "failed to create the positions directory. logs may be unable to save their position", "path", positionsDir, "err", err)
 	}
 
+	if c.ImportPositionsFile != "" {
+		if err := importPositions(i.log, c.ImportPositionsFile, c.PositionsConfig.PositionsFile); err != nil {
+			return fmt.Errorf("failed to import promtail positions: %w", err)
+		}
+	}
+
 	if i.promtail != nil {
 		i.promtail.Shutdown()
 		i.promtail = nil
 	}
+	i.stopDetection()
 
 	// Unregister all existing metrics before trying to create a new instance.
 	if !i.reg.UnregisterAll() {
@@ -171,22 +211,177 @@ func (i *Instance) ApplyConfig(c *InstanceConfig) error {
 		return nil
 	}
 
-	clientMetrics := client.NewMetrics(i.reg, nil)
-	p, err := promtail.New(config.Config{
-		ServerConfig:    server.Config{Disable: true},
-		ClientConfigs:   c.ClientConfigs,
-		PositionsConfig: c.PositionsConfig,
-		ScrapeConfig:    c.ScrapeConfig,
-		TargetConfig:    c.TargetConfig,
-	}, clientMetrics, false, promtail.WithLogger(i.log), promtail.WithRegisterer(i.reg))
-	if err != nil {
+	i.configureOutOfOrder(c)
+	i.admit = i.admitFunc(c.Name)
+
+	if err := i.startPromtail(c); err != nil {
+		i.stopDetection()
 		return fmt.Errorf("unable to create logs instance: %w", err)
 	}
+	return nil
+}
+
+// startPromtail creates the Promtail of the instance for c. Entries of its
+// targets are only passed through admit when it can reject them, which is
+// when the instance has a limiter or per-stream ordering is enforced. mut
+// must be held when called.
+func (i *Instance) startPromtail(c *InstanceConfig) error {
+	var (
+		reg           = util.WrapWithUnregisterer(i.reg)
+		clientMetrics = client.NewMetrics(reg, nil)
+		filtered      = i.limiter != nil || i.outOfOrder.Load() == outOfOrderRejected
+
+		p   promtailer
+		err error
+	)
+	switch {
+	case filtered:
+		p, err = newAgentPromtail(reg, i.log, c, clientMetrics, i.admit)
+	case c.WAL.Enabled:
+		p, err = newAgentPromtail(reg, i.log, c, clientMetrics, nil)
+	default:
+		p, err = promtail.New(config.Config{
+			ServerConfig:    server.Config{Disable: true},
+			ClientConfigs:   c.ClientConfigs,
+			PositionsConfig: c.PositionsConfig,
+			ScrapeConfig:    c.ScrapeConfig,
+			TargetConfig:    c.TargetConfig,
+		}, clientMetrics, false, promtail.WithLogger(i.log), promtail.WithRegisterer(reg))
+	}
+	if err != nil {
+		reg.UnregisterAll()
+		return err
+	}
 
-	i.promtail = p
+	i.promtail, i.promtailReg, i.filtered = p, reg, filtered
 	return nil
 }
 
+// enforceOrdering restarts the Promtail of the instance once detection found
+// a client which rejects out-of-order writes, so entries of its targets are
+// passed through admit. Nothing is done if ctx was canceled because the
+// config changed or the instance stopped in the meantime.
+func (i *Instance) enforceOrdering(ctx context.Context, c *InstanceConfig) {
+	i.mut.Lock()
+	defer i.mut.Unlock()
+
+	if ctx.Err() != nil || i.promtail == nil || i.filtered {
+		return
+	}
+
+	i.promtail.Shutdown()
+	i.promtail = nil
+	i.promtailReg.UnregisterAll()
+	if err := i.startPromtail(c); err != nil {
+		level.Error(i.log).Log("msg", "failed to restart logs instance to enforce per-stream ordering", "err", err)
+	}
+}
+
+// configureOutOfOrder sets whether entries sent through the instance need to
+// be ordered per stream. mut must be held when called.
+func (i *Instance) configureOutOfOrder(c *InstanceConfig) {
+	i.orderer = newStreamOrderer(i.reg)
+	i.reg.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "logs_out_of_order_writes_accepted",
+		Help: "Whether all clients accept out-of-order writes (1), not (0), or if it's still being detected (-1).",
+	}, func() float64 {
+		switch i.outOfOrder.Load() {
+		case outOfOrderAccepted:
+			return 1
+		case outOfOrderRejected:
+			return 0
+		default:
+			return -1
+		}
+	}))
+
+	switch c.OutOfOrderWrites {
+	case OutOfOrderAccepted:
+		i.outOfOrder.Store(outOfOrderAccepted)
+	case OutOfOrderRejected:
+		i.outOfOrder.Store(outOfOrderRejected)
+	default:
+		i.outOfOrder.Store(outOfOrderUnknown)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		i.cancelDetect = cancel
+		go i.detectOutOfOrder(ctx, c)
+	}
+}
+
+// detectOutOfOrder queries every client of c for out-of-order write support
+// until all of them respond, retrying failed attempts.
+func (i *Instance) detectOutOfOrder(ctx context.Context, c *InstanceConfig) {
+	for {
+		accepted, versions, err := detectClients(ctx, c.ClientConfigs)
+		if err == nil {
+			level.Info(i.log).Log("msg", "detected out-of-order write support", "accepted", accepted, "enforce_ordering", !accepted, "loki_versions", strings.Join(versions, ","))
+			if accepted {
+				i.outOfOrder.Store(outOfOrderAccepted)
+				return
+			}
+			i.outOfOrder.Store(outOfOrderRejected)
+			i.enforceOrdering(ctx, c)
+			return
+		} else if ctx.Err() != nil {
+			return
+		}
+		level.Warn(i.log).Log("msg", "failed to detect out-of-order write support, sending entries without enforcing per-stream ordering", "err", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(detectRetryInterval):
+		}
+	}
+}
+
+// detectClients returns true if all clients accept out-of-order writes,
+// along with the versions of Loki reported by the clients queried.
+func detectClients(ctx context.Context, clients []client.Config) (bool, []string, error) {
+	versions := make([]string, 0, len(clients))
+	for _, cc := range clients {
+		reqCtx, cancel := context.WithTimeout(ctx, detectTimeout)
+		accepted, version, err := acceptsOutOfOrder(reqCtx, cc)
+		cancel()
+		if err != nil {
+			return false, nil, err
+		}
+		versions = append(versions, version)
+		if !accepted {
+			return false, versions, nil
+		}
+	}
+	return true, versions, nil
+}
+
+// admitFunc returns a function reporting whether an entry of the instance
+// named name may be sent to the clients. Entries over the log throughput
+// limit of the instance are rejected, as well as entries older than the
+// latest entry of their stream when the clients reject out-of-order writes.
+// mut must be held when called.
+func (i *Instance) admitFunc(name string) func(api.Entry) bool {
+	var (
+		limiter = i.limiter
+		orderer = i.orderer
+	)
+	return func(e api.Entry) bool {
+		if err := limiter.AdmitLogBytes(name, len(e.Line)); err != nil {
+			return false
+		}
+		return i.outOfOrder.Load() != outOfOrderRejected || orderer.Admit(e)
+	}
+}
+
+// stopDetection stops any running out-of-order detection. mut must be held
+// when called.
+func (i *Instance) stopDetection() {
+	if i.cancelDetect != nil {
+		i.cancelDetect()
+		i.cancelDetect = nil
+	}
+}
+
 // SendEntry passes an entry to the internal promtail client and returns true if successfully sent. It is
 // best effort and not guaranteed to succeed.
 func (i *Instance) SendEntry(entry api.Entry, dur time.Duration) bool {
@@ -195,6 +390,10 @@ func (i *Instance) SendEntry(entry api.Entry, dur time.Duration) bool {
 
 	// promtail is nil it has been stopped
 	if i.promtail != nil {
+		if !i.admit(entry) {
+			return false
+		}
+
 		// send non blocking so we don't block the mutex. this is best effort
 		select {
 		case i.promtail.Client().Chan() <- entry:
@@ -215,4 +414,5 @@ func (i *Instance) Stop() {
 		i.promtail.Shutdown()
 		i.promtail = nil
 	}
+	i.stopDetection()
 }
@@ -25,7 +25,7 @@ import (
 )
 
 func TestLogs_NilConfig(t *testing.T) {
-	l, err := New(prometheus.NewRegistry(), nil, util.TestLogger(t))
+	l, err := New(prometheus.NewRegistry(), nil, util.TestLogger(t), nil)
 	require.NoError(t, err)
 	require.NoError(t, l.ApplyConfig(nil))
 
@@ -60,6 +60,11 @@ func TestLogs(t *testing.T) {
 	})
 	go func() {
 		_ = http.Serve(lis, http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == buildInfoPath {
+				_, _ = rw.Write([]byte(`{"version": "2.5.0"}`))
+				return
+			}
+
 			req, err := push.ParseRequest(log.NewNopLogger(), "user_id", r, nil)
 			require.NoError(t, err)
 
@@ -94,7 +99,7 @@ configs:
 	require.NoError(t, dec.Decode(&cfg))
 
 	logger := log.NewSyncLogger(log.NewNopLogger())
-	l, err := New(prometheus.NewRegistry(), &cfg, logger)
+	l, err := New(prometheus.NewRegistry(), &cfg, logger, nil)
 	require.NoError(t, err)
 	defer l.Stop()
 
@@ -184,7 +189,7 @@ configs:
 	require.NoError(t, dec.Decode(&cfg))
 
 	logger := util.TestLogger(t)
-	l, err := New(prometheus.NewRegistry(), &cfg, logger)
+	l, err := New(prometheus.NewRegistry(), &cfg, logger, nil)
 	require.NoError(t, err)
 	defer l.Stop()
 
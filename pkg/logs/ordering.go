@@ -0,0 +1,185 @@
+package logs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/loki/clients/pkg/promtail/api"
+	"github.com/grafana/loki/clients/pkg/promtail/client"
+	"github.com/prometheus/client_golang/prometheus"
+	config_util "github.com/prometheus/common/config"
+)
+
+// OutOfOrderMode configures whether the Loki backends of an instance accept
+// out-of-order writes.
+type OutOfOrderMode string
+
+// Supported values for OutOfOrderMode.
+const (
+	// OutOfOrderAuto detects whether out-of-order writes are accepted by
+	// querying the build information of every client. Until detection
+	// succeeds, entries are sent without enforcing per-stream ordering.
+	OutOfOrderAuto OutOfOrderMode = "auto"
+	// OutOfOrderAccepted indicates that all clients accept out-of-order writes.
+	OutOfOrderAccepted OutOfOrderMode = "accepted"
+	// OutOfOrderRejected indicates that at least one client rejects
+	// out-of-order writes.
+	OutOfOrderRejected OutOfOrderMode = "rejected"
+)
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (m *OutOfOrderMode) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+
+	switch mode := OutOfOrderMode(s); mode {
+	case "":
+		*m = OutOfOrderAuto
+	case OutOfOrderAuto, OutOfOrderAccepted, OutOfOrderRejected:
+		*m = mode
+	default:
+		return fmt.Errorf("unrecognized out_of_order_writes value %q, expected auto, accepted, or rejected", s)
+	}
+	return nil
+}
+
+// Detected out-of-order write support of the clients of an instance.
+const (
+	outOfOrderUnknown int32 = iota
+	outOfOrderAccepted
+	outOfOrderRejected
+)
+
+const (
+	// pushPath is the Loki endpoint entries are pushed to.
+	pushPath = "/loki/api/v1/push"
+	// buildInfoPath is the Loki endpoint reporting the version of the server.
+	buildInfoPath = "/loki/api/v1/status/buildinfo"
+	// detectTimeout is the timeout for a single build information request.
+	detectTimeout = 10 * time.Second
+	// detectRetryInterval is how long to wait between failed detections.
+	detectRetryInterval = time.Minute
+	// streamIdleTimeout is how long a stream is tracked when enforcing
+	// ordering, measured from the timestamp of its latest entry to the newest
+	// timestamp of any entry.
+	streamIdleTimeout = time.Hour
+)
+
+// acceptsOutOfOrder returns whether the Loki server behind cfg accepts
+// out-of-order writes, along with the version it reports. Unordered writes
+// are enabled by default starting with Loki 2.4. Development and vendor
+// builds report versions which can't be parsed (such as main-a1b2c3); they
+// are assumed to accept out-of-order writes, since enforcing ordering would
+// drop entries Loki might have accepted.
+//
+// The build information is requested next to the push endpoint of cfg, so a
+// path prefix in front of the Loki API (such as one added by a reverse proxy)
+// is kept.
+func acceptsOutOfOrder(ctx context.Context, cfg client.Config) (accepted bool, version string, err error) {
+	if cfg.URL.URL == nil {
+		return false, "", fmt.Errorf("client has no URL")
+	}
+
+	cli, err := config_util.NewClientFromConfig(cfg.Client, "logs")
+	if err != nil {
+		return false, "", err
+	}
+
+	u := *cfg.URL.URL
+	u.Path = strings.TrimSuffix(strings.TrimSuffix(u.Path, pushPath), "/") + buildInfoPath
+	u.RawPath = ""
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return false, "", err
+	}
+	if cfg.TenantID != "" {
+		req.Header.Set("X-Scope-OrgID", cfg.TenantID)
+	}
+	req.Header.Set("User-Agent", client.UserAgent)
+
+	resp, err := cli.Do(req)
+	if err != nil {
+		return false, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return false, "", fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, u.String())
+	}
+
+	var info struct {
+		Version string `json:"version"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return false, "", fmt.Errorf("decoding build information: %w", err)
+	}
+
+	var major, minor int
+	if _, err := fmt.Sscanf(info.Version, "%d.%d", &major, &minor); err != nil {
+		return true, info.Version, nil
+	}
+	return major > 2 || (major == 2 && minor >= 4), info.Version, nil
+}
+
+// streamOrderer enforces per-stream ordering of entries for Loki backends
+// which reject out-of-order writes. Entries older than the latest entry sent
+// on the same stream are dropped rather than being rejected by Loki, which
+// would fail the rest of the batch.
+//
+// Idle streams are forgotten based on entry timestamps rather than the wall
+// clock, so streams of old entries (such as when backfilling) are still
+// tracked while their entries keep arriving.
+type streamOrderer struct {
+	mut    sync.Mutex
+	latest map[string]time.Time
+	// newest is the newest timestamp of any admitted entry.
+	newest    time.Time
+	lastSweep time.Time
+
+	dropped prometheus.Counter
+}
+
+func newStreamOrderer(reg prometheus.Registerer) *streamOrderer {
+	o := &streamOrderer{
+		latest: make(map[string]time.Time),
+		dropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "logs_out_of_order_entries_dropped_total",
+			Help: "Total number of entries dropped because they were older than the latest entry of their stream and the backend rejects out-of-order writes.",
+		}),
+	}
+	reg.MustRegister(o.dropped)
+	return o
+}
+
+// Admit returns true if e is not older than the latest entry admitted for
+// its stream.
+func (o *streamOrderer) Admit(e api.Entry) bool {
+	o.mut.Lock()
+	defer o.mut.Unlock()
+
+	stream := e.Labels.String()
+	if latest, ok := o.latest[stream]; ok && e.Timestamp.Before(latest) {
+		o.dropped.Inc()
+		return false
+	}
+	o.latest[stream] = e.Timestamp
+
+	if e.Timestamp.After(o.newest) {
+		o.newest = e.Timestamp
+	}
+	if o.newest.Sub(o.lastSweep) > streamIdleTimeout {
+		for stream, ts := range o.latest {
+			if o.newest.Sub(ts) > streamIdleTimeout {
+				delete(o.latest, stream)
+			}
+		}
+		o.lastSweep = o.newest
+	}
+	return true
+}
@@ -0,0 +1,161 @@
+package logs
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/grafana/dskit/flagext"
+	"github.com/grafana/loki/clients/pkg/promtail/api"
+	"github.com/grafana/loki/clients/pkg/promtail/client"
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestAcceptsOutOfOrder(t *testing.T) {
+	tt := []struct {
+		version  string
+		expected bool
+	}{
+		{"2.3.0", false},
+		{"2.4.0", true},
+		{"2.5.0", true},
+		{"3.0.0", true},
+		{"main-a1b2c3", true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.version, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, buildInfoPath, r.URL.Path)
+				require.Equal(t, "tenant", r.Header.Get("X-Scope-OrgID"))
+				_, _ = w.Write([]byte(`{"version": "` + tc.version + `"}`))
+			}))
+			defer srv.Close()
+
+			u, err := url.Parse(srv.URL + "/loki/api/v1/push")
+			require.NoError(t, err)
+
+			accepted, version, err := acceptsOutOfOrder(context.Background(), client.Config{
+				URL:      flagext.URLValue{URL: u},
+				TenantID: "tenant",
+			})
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, accepted)
+			require.Equal(t, tc.version, version)
+		})
+	}
+}
+
+func TestAcceptsOutOfOrder_PathPrefix(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/tenant-a"+buildInfoPath, r.URL.Path)
+		_, _ = w.Write([]byte(`{"version": "2.4.0"}`))
+	}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL + "/tenant-a/loki/api/v1/push")
+	require.NoError(t, err)
+
+	accepted, _, err := acceptsOutOfOrder(context.Background(), client.Config{URL: flagext.URLValue{URL: u}})
+	require.NoError(t, err)
+	require.True(t, accepted)
+}
+
+func TestAcceptsOutOfOrder_Error(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	_, _, err = acceptsOutOfOrder(context.Background(), client.Config{URL: flagext.URLValue{URL: u}})
+	require.Error(t, err)
+}
+
+func TestStreamOrderer(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	o := newStreamOrderer(reg)
+
+	now := time.Now()
+	entry := func(app string, ts time.Time) api.Entry {
+		return api.Entry{
+			Labels: model.LabelSet{"app": model.LabelValue(app)},
+			Entry:  logproto.Entry{Timestamp: ts, Line: "hello"},
+		}
+	}
+
+	require.True(t, o.Admit(entry("a", now)))
+	require.True(t, o.Admit(entry("a", now)))
+	require.True(t, o.Admit(entry("a", now.Add(time.Second))))
+	require.False(t, o.Admit(entry("a", now)))
+
+	// Ordering is only enforced within a stream.
+	require.True(t, o.Admit(entry("b", now)))
+
+	require.Equal(t, 1.0, testutil.ToFloat64(o.dropped))
+}
+
+func TestStreamOrderer_Sweep(t *testing.T) {
+	o := newStreamOrderer(prometheus.NewRegistry())
+
+	// Entries far older than the wall clock are still ordered.
+	old := time.Now().Add(-24 * time.Hour)
+	entry := func(app string, ts time.Time) api.Entry {
+		return api.Entry{
+			Labels: model.LabelSet{"app": model.LabelValue(app)},
+			Entry:  logproto.Entry{Timestamp: ts, Line: "hello"},
+		}
+	}
+
+	require.True(t, o.Admit(entry("a", old.Add(time.Second))))
+	require.True(t, o.Admit(entry("a", old.Add(2*time.Second))))
+	require.False(t, o.Admit(entry("a", old.Add(time.Second))))
+
+	// Streams are forgotten once newer entries are idle timeout ahead of them.
+	require.True(t, o.Admit(entry("b", old.Add(2*streamIdleTimeout))))
+	require.True(t, o.Admit(entry("a", old)))
+}
+
+func TestInstance_Admit(t *testing.T) {
+	now := time.Now()
+	entry := func(ts time.Time) api.Entry {
+		return api.Entry{
+			Labels: model.LabelSet{"app": "a"},
+			Entry:  logproto.Entry{Timestamp: ts, Line: "hello"},
+		}
+	}
+
+	i := &Instance{orderer: newStreamOrderer(prometheus.NewRegistry())}
+	admit := i.admitFunc("test")
+
+	// Entries are passed through until detection completes.
+	i.outOfOrder.Store(outOfOrderUnknown)
+	require.True(t, admit(entry(now)))
+	require.True(t, admit(entry(now.Add(-time.Second))))
+
+	i.outOfOrder.Store(outOfOrderRejected)
+	require.True(t, admit(entry(now)))
+	require.False(t, admit(entry(now.Add(-time.Second))))
+
+	i.outOfOrder.Store(outOfOrderAccepted)
+	require.True(t, admit(entry(now.Add(-time.Second))))
+}
+
+func TestOutOfOrderMode_UnmarshalYAML(t *testing.T) {
+	var cfg InstanceConfig
+	require.NoError(t, yaml.Unmarshal([]byte(`name: default`), &cfg))
+	require.Equal(t, OutOfOrderAuto, cfg.OutOfOrderWrites)
+
+	require.NoError(t, yaml.Unmarshal([]byte(`out_of_order_writes: accepted`), &cfg))
+	require.Equal(t, OutOfOrderAccepted, cfg.OutOfOrderWrites)
+
+	require.Error(t, yaml.Unmarshal([]byte(`out_of_order_writes: sometimes`), &cfg))
+}
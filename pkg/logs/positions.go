@@ -0,0 +1,57 @@
+package logs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/loki/clients/pkg/promtail/positions"
+	"gopkg.in/yaml.v2"
+)
+
+// importPositions seeds the positions file at path with the positions from
+// the promtail positions file at from. Nothing is imported if path already
+// exists, so positions are only imported on the first run of an instance.
+func importPositions(l log.Logger, from, path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("checking positions file %s: %w", path, err)
+	}
+
+	buf, err := os.ReadFile(from)
+	if errors.Is(err, fs.ErrNotExist) {
+		level.Warn(l).Log("msg", "promtail positions file to import doesn't exist, starting without positions", "path", from)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("reading promtail positions file %s: %w", from, err)
+	}
+
+	var f positions.File
+	if err := yaml.UnmarshalStrict(buf, &f); err != nil {
+		return fmt.Errorf("invalid promtail positions file %s: %w", from, err)
+	}
+
+	out, err := yaml.Marshal(positions.File{Positions: f.Positions})
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so a partially written positions file
+	// is never picked up, which would prevent retrying the import.
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".import")
+	if err := os.WriteFile(tmp, out, 0644); err != nil {
+		return fmt.Errorf("writing positions file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("writing positions file: %w", err)
+	}
+
+	level.Info(l).Log("msg", "imported promtail positions", "from", from, "to", path, "count", len(f.Positions))
+	return nil
+}
@@ -0,0 +1,61 @@
+package logs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/grafana/agent/pkg/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportPositions(t *testing.T) {
+	dir := t.TempDir()
+	var (
+		from = filepath.Join(dir, "promtail.yaml")
+		to   = filepath.Join(dir, "agent", "instance.yml")
+	)
+	require.NoError(t, os.MkdirAll(filepath.Dir(to), 0775))
+
+	require.NoError(t, os.WriteFile(from, []byte(`
+positions:
+  /var/log/syslog: "1024"
+  journal-default: "s=abc;i=1"
+`), 0644))
+
+	require.NoError(t, importPositions(util.TestLogger(t), from, to))
+	bb, err := os.ReadFile(to)
+	require.NoError(t, err)
+	require.YAMLEq(t, `
+positions:
+  /var/log/syslog: "1024"
+  journal-default: "s=abc;i=1"
+`, string(bb))
+
+	// Positions are only imported on the first run.
+	require.NoError(t, os.WriteFile(from, []byte("positions:\n  /var/log/syslog: \"2048\"\n"), 0644))
+	require.NoError(t, importPositions(util.TestLogger(t), from, to))
+	bb2, err := os.ReadFile(to)
+	require.NoError(t, err)
+	require.Equal(t, bb, bb2)
+}
+
+func TestImportPositions_Missing(t *testing.T) {
+	dir := t.TempDir()
+	to := filepath.Join(dir, "instance.yml")
+
+	require.NoError(t, importPositions(util.TestLogger(t), filepath.Join(dir, "missing.yaml"), to))
+	_, err := os.Stat(to)
+	require.True(t, os.IsNotExist(err), "positions file should not be created")
+}
+
+func TestImportPositions_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	from := filepath.Join(dir, "promtail.yaml")
+	to := filepath.Join(dir, "instance.yml")
+	require.NoError(t, os.WriteFile(from, []byte("not: [valid"), 0644))
+
+	require.Error(t, importPositions(util.TestLogger(t), from, to))
+	_, err := os.Stat(to)
+	require.True(t, os.IsNotExist(err), "positions file should not be created")
+}
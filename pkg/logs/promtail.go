@@ -0,0 +1,146 @@
+package logs
+
+import (
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/loki/clients/pkg/promtail/api"
+	"github.com/grafana/loki/clients/pkg/promtail/client"
+	"github.com/grafana/loki/clients/pkg/promtail/targets"
+	"github.com/grafana/loki/clients/pkg/promtail/targets/target"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// agentPromtail runs the targets of Promtail like promtail.Promtail, but
+// passes their entries through the clients of the agent before the clients
+// of Promtail: entries not admitted by the instance are dropped, and the
+// remaining entries are written to the WAL of the instance when it's enabled.
+type agentPromtail struct {
+	// targetsClient is the client entries of targets are sent to. client is
+	// the client entries are sent to once they've been admitted.
+	targetsClient  client.Client
+	client         client.Client
+	targetManagers *targets.TargetManagers
+
+	mut     sync.Mutex
+	stopped bool
+}
+
+// newAgentPromtail creates an agentPromtail for c. Entries of targets are
+// only sent when admit returns true for them; admit may be nil to send all
+// entries.
+func newAgentPromtail(reg prometheus.Registerer, l log.Logger, c *InstanceConfig, metrics *client.Metrics, admit func(api.Entry) bool) (*agentPromtail, error) {
+	next, err := client.NewMulti(metrics, nil, l, c.ClientConfigs...)
+	if err != nil {
+		return nil, err
+	}
+	if c.WAL.Enabled {
+		wc, err := newWALClient(reg, l, c.WAL, next)
+		if err != nil {
+			next.Stop()
+			return nil, err
+		}
+		next = wc
+	}
+
+	p := &agentPromtail{targetsClient: next, client: next}
+	if admit != nil {
+		p.targetsClient = newFilterClient(admit, next)
+	}
+
+	tms, err := targets.NewTargetManagers(p, reg, l, c.PositionsConfig, p.targetsClient, c.ScrapeConfig, &c.TargetConfig)
+	if err != nil {
+		p.targetsClient.Stop()
+		return nil, err
+	}
+	p.targetManagers = tms
+	return p, nil
+}
+
+// Client returns the client admitted entries are sent to.
+func (p *agentPromtail) Client() client.Client {
+	return p.client
+}
+
+// ActiveTargets returns the active targets by job.
+func (p *agentPromtail) ActiveTargets() map[string][]target.Target {
+	return p.targetManagers.ActiveTargets()
+}
+
+// Shutdown stops the targets and then the clients.
+func (p *agentPromtail) Shutdown() {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	if p.stopped {
+		return
+	}
+	p.stopped = true
+
+	if p.targetManagers != nil {
+		p.targetManagers.Stop()
+	}
+	p.targetsClient.Stop()
+}
+
+// filterClient is a client.Client which drops entries rejected by admit and
+// passes the other entries to the next client.
+type filterClient struct {
+	admit func(api.Entry) bool
+	next  client.Client
+
+	entries chan api.Entry
+	once    sync.Once
+	wg      sync.WaitGroup
+}
+
+var _ client.Client = (*filterClient)(nil)
+
+func newFilterClient(admit func(api.Entry) bool, next client.Client) *filterClient {
+	c := &filterClient{
+		admit:   admit,
+		next:    next,
+		entries: make(chan api.Entry),
+	}
+	c.wg.Add(1)
+	go c.run()
+	return c
+}
+
+func (c *filterClient) run() {
+	defer c.wg.Done()
+
+	for e := range c.entries {
+		if !c.admit(e) {
+			continue
+		}
+		c.next.Chan() <- e
+	}
+}
+
+// Chan implements client.Client.
+func (c *filterClient) Chan() chan<- api.Entry {
+	return c.entries
+}
+
+// Name implements client.Client.
+func (c *filterClient) Name() string {
+	return "filter"
+}
+
+// Stop implements client.Client.
+func (c *filterClient) Stop() {
+	c.stop()
+	c.next.Stop()
+}
+
+// StopNow implements client.Client.
+func (c *filterClient) StopNow() {
+	c.stop()
+	c.next.StopNow()
+}
+
+func (c *filterClient) stop() {
+	c.once.Do(func() { close(c.entries) })
+	c.wg.Wait()
+}
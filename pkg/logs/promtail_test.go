@@ -0,0 +1,32 @@
+package logs
+
+import (
+	"testing"
+
+	"github.com/grafana/agent/pkg/limits"
+	"github.com/grafana/loki/clients/pkg/promtail/api"
+	"github.com/grafana/loki/clients/pkg/promtail/client/fake"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestFilterClient(t *testing.T) {
+	limiter := limits.New(prometheus.NewRegistry(), limits.Config{
+		Tenants: map[string]limits.TenantLimits{
+			"test": {MaxLogBytesPerSecond: 20},
+		},
+	})
+	next := fake.New(func() {})
+
+	c := newFilterClient(func(e api.Entry) bool {
+		return limiter.AdmitLogBytes("test", len(e.Line)) == nil
+	}, next)
+	entries := testWALEntries(5)
+	for _, e := range entries {
+		c.Chan() <- e
+	}
+	c.Stop()
+
+	// Each entry is 7 bytes long, so only the first two fit in the burst of
+	// the tenant.
+	requireEntries(t, entries[:2], next.Received())
+}
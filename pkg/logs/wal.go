@@ -0,0 +1,484 @@
+package logs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/loki/clients/pkg/promtail/api"
+	"github.com/grafana/loki/clients/pkg/promtail/client"
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/tsdb/encoding"
+	"github.com/prometheus/prometheus/tsdb/wal"
+	"go.uber.org/atomic"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// walSegmentSize is the size of WAL segments. It's lower than the default
+	// of Prometheus so that delivered entries are truncated sooner.
+	walSegmentSize = 8 * 1024 * 1024
+	// walPositionFile is the name of the file in the WAL directory holding the
+	// position of the next entry to deliver.
+	walPositionFile = "delivered.yml"
+	// walPollInterval is how often the WAL is checked for new entries when
+	// the writer hasn't notified the reader.
+	walPollInterval = time.Second
+)
+
+// DefaultWALConfig holds default options for WALConfig.
+var DefaultWALConfig = WALConfig{
+	TruncateFrequency: time.Minute,
+}
+
+// WALConfig configures buffering the entries of an instance in an on-disk
+// write-ahead log before they're sent by the clients. Buffered entries survive
+// restarts of the agent and outages of Loki.
+type WALConfig struct {
+	Enabled bool `yaml:"enabled,omitempty"`
+	// Dir is the directory of the WAL. It's generated from the name of the
+	// instance and Config.WALDirectory when empty.
+	Dir string `yaml:"dir,omitempty"`
+	// MaxSizeBytes is the maximum size of the WAL on disk. New entries are
+	// dropped while it's exceeded. 0 means unlimited.
+	MaxSizeBytes int64 `yaml:"max_size_bytes,omitempty"`
+	// TruncateFrequency is how often delivered entries are removed from the
+	// WAL and the position of the next entry to deliver is saved.
+	TruncateFrequency time.Duration `yaml:"truncate_frequency,omitempty"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (c *WALConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultWALConfig
+
+	type plain WALConfig
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	if c.MaxSizeBytes < 0 {
+		return fmt.Errorf("wal max_size_bytes must not be negative")
+	}
+	if c.TruncateFrequency <= 0 {
+		return fmt.Errorf("wal truncate_frequency must be greater than 0")
+	}
+	return nil
+}
+
+// walPosition is the position of an entry in the WAL.
+type walPosition struct {
+	Segment int `yaml:"segment"`
+	// Entry is the number of entries before the entry in the segment.
+	Entry int `yaml:"entry"`
+}
+
+type walMetrics struct {
+	entriesWritten   prometheus.Counter
+	entriesDropped   prometheus.Counter
+	entriesDelivered prometheus.Counter
+	size             prometheus.Gauge
+	reader           *wal.LiveReaderMetrics
+}
+
+func newWALMetrics(reg prometheus.Registerer) *walMetrics {
+	m := &walMetrics{
+		entriesWritten: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "logs_wal_entries_written_total",
+			Help: "Total number of entries written to the WAL.",
+		}),
+		entriesDropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "logs_wal_entries_dropped_total",
+			Help: "Total number of entries dropped because the WAL exceeded its maximum size or couldn't be written.",
+		}),
+		entriesDelivered: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "logs_wal_entries_delivered_total",
+			Help: "Total number of entries read from the WAL and passed to the clients.",
+		}),
+		size: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "logs_wal_size_bytes",
+			Help: "Size of the WAL on disk as of the last truncation.",
+		}),
+		// Corruption errors are logged; the metric of Prometheus isn't
+		// registered since it would collide with the metrics WAL.
+		reader: wal.NewLiveReaderMetrics(nil),
+	}
+	reg.MustRegister(m.entriesWritten, m.entriesDropped, m.entriesDelivered, m.size)
+	return m
+}
+
+// walClient is a client.Client which writes entries to a WAL. Entries are
+// read back from the WAL and passed to the next client in the order they were
+// written.
+//
+// The next client blocks while it retries sending a batch, so entries are
+// buffered on disk while Loki is unavailable. The position of the next entry
+// to deliver is saved on every truncation and when stopping; entries
+// delivered since the last save are delivered again if the agent crashes.
+type walClient struct {
+	log     log.Logger
+	cfg     WALConfig
+	wal     *wal.WAL
+	next    client.Client
+	metrics *walMetrics
+
+	entries chan api.Entry
+	written chan struct{}
+	quit    chan struct{}
+	once    sync.Once
+
+	writerWg sync.WaitGroup
+	wg       sync.WaitGroup
+
+	mut sync.Mutex
+	pos walPosition
+
+	size atomic.Int64
+}
+
+var _ client.Client = (*walClient)(nil)
+
+// newWALClient opens the WAL in the directory of cfg and starts delivering
+// its undelivered entries to next.
+func newWALClient(reg prometheus.Registerer, l log.Logger, cfg WALConfig, next client.Client) (*walClient, error) {
+	if err := os.MkdirAll(cfg.Dir, 0775); err != nil {
+		return nil, fmt.Errorf("creating WAL directory %s: %w", cfg.Dir, err)
+	}
+	w, err := wal.NewSize(l, nil, cfg.Dir, walSegmentSize, true)
+	if err != nil {
+		return nil, fmt.Errorf("opening WAL %s: %w", cfg.Dir, err)
+	}
+
+	pos, err := readWALPosition(cfg.Dir)
+	if err != nil {
+		_ = w.Close()
+		return nil, err
+	}
+	// Segments before the first one have been truncated after all of their
+	// entries were delivered.
+	first, _, err := wal.Segments(cfg.Dir)
+	if err != nil {
+		_ = w.Close()
+		return nil, fmt.Errorf("listing WAL segments: %w", err)
+	}
+	if pos.Segment < first {
+		pos = walPosition{Segment: first}
+	}
+
+	c := &walClient{
+		log:     log.With(l, "component", "wal"),
+		cfg:     cfg,
+		wal:     w,
+		next:    next,
+		metrics: newWALMetrics(reg),
+
+		entries: make(chan api.Entry),
+		written: make(chan struct{}, 1),
+		quit:    make(chan struct{}),
+
+		pos: pos,
+	}
+	c.updateSize()
+
+	if pos != (walPosition{Segment: first}) {
+		level.Info(c.log).Log("msg", "replaying undelivered entries from WAL", "dir", cfg.Dir, "segment", pos.Segment, "entry", pos.Entry)
+	}
+
+	c.writerWg.Add(1)
+	go c.runWriter()
+	c.wg.Add(2)
+	go c.runReader()
+	go c.runTruncate()
+	return c, nil
+}
+
+// Chan implements client.Client.
+func (c *walClient) Chan() chan<- api.Entry {
+	return c.entries
+}
+
+// Name implements client.Client.
+func (c *walClient) Name() string {
+	return "wal"
+}
+
+// Stop implements client.Client. Entries which haven't been delivered stay
+// in the WAL and are delivered on the next start.
+func (c *walClient) Stop() {
+	c.stop()
+	c.next.Stop()
+}
+
+// StopNow implements client.Client.
+func (c *walClient) StopNow() {
+	c.stop()
+	c.next.StopNow()
+}
+
+func (c *walClient) stop() {
+	c.once.Do(func() {
+		close(c.entries)
+		c.writerWg.Wait()
+
+		close(c.quit)
+		c.wg.Wait()
+
+		c.truncate()
+		if err := c.wal.Close(); err != nil {
+			level.Warn(c.log).Log("msg", "failed to close WAL", "err", err)
+		}
+	})
+}
+
+func (c *walClient) runWriter() {
+	defer c.writerWg.Done()
+
+	for e := range c.entries {
+		c.write(e)
+	}
+}
+
+func (c *walClient) write(e api.Entry) {
+	rec := encodeWALEntry(e)
+	if max := c.cfg.MaxSizeBytes; max > 0 && c.size.Load()+int64(len(rec)) > max {
+		c.metrics.entriesDropped.Inc()
+		return
+	}
+	if err := c.wal.Log(rec); err != nil {
+		level.Error(c.log).Log("msg", "failed to write entry to WAL", "err", err)
+		c.metrics.entriesDropped.Inc()
+		return
+	}
+	c.size.Add(int64(len(rec)))
+	c.metrics.entriesWritten.Inc()
+
+	select {
+	case c.written <- struct{}{}:
+	default:
+	}
+}
+
+// errWALStopped is returned when reading the WAL stopped because the client
+// was stopped.
+var errWALStopped = errors.New("WAL client stopped")
+
+func (c *walClient) runReader() {
+	defer c.wg.Done()
+
+	pos := c.position()
+	for {
+		err := c.readSegment(pos)
+		if errors.Is(err, errWALStopped) {
+			return
+		} else if err != nil {
+			level.Warn(c.log).Log("msg", "skipping the rest of WAL segment", "segment", pos.Segment, "err", err)
+		}
+
+		pos = walPosition{Segment: pos.Segment + 1}
+		c.setPosition(pos)
+	}
+}
+
+// readSegment delivers the entries of the segment of pos, starting at pos,
+// until the segment is complete. A segment is complete once a later one
+// exists.
+func (c *walClient) readSegment(pos walPosition) error {
+	s, err := wal.OpenReadSegment(wal.SegmentName(c.wal.Dir(), pos.Segment))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	var (
+		r = wal.NewLiveReader(c.log, c.metrics.reader, s)
+		n int
+	)
+	for {
+		// Check for later segments before reading, so that all entries
+		// written to a complete segment are read before moving on.
+		_, last, err := wal.Segments(c.wal.Dir())
+		if err != nil {
+			return err
+		}
+		complete := last > pos.Segment
+
+		for r.Next() {
+			n++
+			if n <= pos.Entry {
+				continue
+			}
+
+			e, err := decodeWALEntry(r.Record())
+			if err != nil {
+				level.Warn(c.log).Log("msg", "dropping invalid entry from WAL", "segment", pos.Segment, "err", err)
+			} else if !c.deliver(e) {
+				return errWALStopped
+			}
+			c.setPosition(walPosition{Segment: pos.Segment, Entry: n})
+		}
+		if err := r.Err(); err != io.EOF {
+			return err
+		} else if complete {
+			return nil
+		}
+
+		select {
+		case <-c.quit:
+			return errWALStopped
+		case <-c.written:
+		case <-time.After(walPollInterval):
+		}
+	}
+}
+
+// deliver passes e to the next client, returning false if the client was
+// stopped first.
+func (c *walClient) deliver(e api.Entry) bool {
+	select {
+	case <-c.quit:
+		return false
+	case c.next.Chan() <- e:
+		c.metrics.entriesDelivered.Inc()
+		return true
+	}
+}
+
+func (c *walClient) position() walPosition {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.pos
+}
+
+func (c *walClient) setPosition(pos walPosition) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.pos = pos
+}
+
+func (c *walClient) runTruncate() {
+	defer c.wg.Done()
+
+	t := time.NewTicker(c.cfg.TruncateFrequency)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-c.quit:
+			return
+		case <-t.C:
+			c.truncate()
+		}
+	}
+}
+
+// truncate saves the position of the next entry to deliver and removes the
+// segments before it.
+func (c *walClient) truncate() {
+	pos := c.position()
+	if err := writeWALPosition(c.wal.Dir(), pos); err != nil {
+		level.Warn(c.log).Log("msg", "failed to save WAL position", "err", err)
+		return
+	}
+	if err := c.wal.Truncate(pos.Segment); err != nil {
+		level.Warn(c.log).Log("msg", "failed to truncate WAL", "err", err)
+	}
+
+	// When entries are read from the segment being written, a new segment is
+	// started so that the current one can be truncated once it's read.
+	// Otherwise, a WAL exceeding its maximum size would never shrink.
+	if _, last, err := wal.Segments(c.wal.Dir()); err == nil && pos.Segment >= last {
+		if _, offset, err := c.wal.LastSegmentAndOffset(); err == nil && offset > 0 {
+			if err := c.wal.NextSegment(); err != nil {
+				level.Warn(c.log).Log("msg", "failed to start new WAL segment", "err", err)
+			}
+		}
+	}
+
+	c.updateSize()
+}
+
+func (c *walClient) updateSize() {
+	size, err := c.wal.Size()
+	if err != nil {
+		level.Warn(c.log).Log("msg", "failed to compute WAL size", "err", err)
+		return
+	}
+	c.size.Store(size)
+	c.metrics.size.Set(float64(size))
+}
+
+// readWALPosition reads the position saved in dir. The zero position is
+// returned when none was saved yet.
+func readWALPosition(dir string) (walPosition, error) {
+	var pos walPosition
+
+	buf, err := os.ReadFile(filepath.Join(dir, walPositionFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return pos, nil
+	} else if err != nil {
+		return pos, fmt.Errorf("reading WAL position: %w", err)
+	}
+	if err := yaml.UnmarshalStrict(buf, &pos); err != nil {
+		return pos, fmt.Errorf("invalid WAL position file: %w", err)
+	}
+	return pos, nil
+}
+
+// writeWALPosition atomically saves pos in dir.
+func writeWALPosition(dir string, pos walPosition) error {
+	buf, err := yaml.Marshal(pos)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, walPositionFile)
+	if err := os.WriteFile(path+".tmp", buf, 0664); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func encodeWALEntry(e api.Entry) []byte {
+	var buf encoding.Encbuf
+	buf.PutUvarint(len(e.Labels))
+	for name, value := range e.Labels {
+		buf.PutUvarintStr(string(name))
+		buf.PutUvarintStr(string(value))
+	}
+	buf.PutVarint64(e.Timestamp.UnixNano())
+	buf.PutUvarintStr(e.Line)
+	return buf.Get()
+}
+
+func decodeWALEntry(rec []byte) (api.Entry, error) {
+	buf := encoding.Decbuf{B: rec}
+
+	n := buf.Uvarint()
+	labels := make(model.LabelSet, n)
+	for i := 0; i < n && buf.Err() == nil; i++ {
+		name := buf.UvarintStr()
+		labels[model.LabelName(name)] = model.LabelValue(buf.UvarintStr())
+	}
+	ts := buf.Varint64()
+	line := buf.UvarintStr()
+
+	if buf.Err() != nil {
+		return api.Entry{}, buf.Err()
+	} else if buf.Len() > 0 {
+		return api.Entry{}, fmt.Errorf("unexpected %d bytes after entry", buf.Len())
+	}
+	return api.Entry{
+		Labels: labels,
+		Entry:  logproto.Entry{Timestamp: time.Unix(0, ts), Line: line},
+	}, nil
+}
@@ -0,0 +1,175 @@
+package logs
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/pkg/util"
+	"github.com/grafana/loki/clients/pkg/promtail/api"
+	"github.com/grafana/loki/clients/pkg/promtail/client/fake"
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/tsdb/wal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWALClient(t *testing.T) {
+	cfg := WALConfig{Enabled: true, Dir: t.TempDir(), TruncateFrequency: time.Hour}
+	next := fake.New(func() {})
+
+	c, err := newWALClient(prometheus.NewRegistry(), util.TestLogger(t), cfg, next)
+	require.NoError(t, err)
+
+	entries := testWALEntries(10)
+	for _, e := range entries {
+		c.Chan() <- e
+	}
+
+	require.Eventually(t, func() bool {
+		return len(next.Received()) == len(entries)
+	}, 5*time.Second, 10*time.Millisecond)
+	c.Stop()
+
+	requireEntries(t, entries, next.Received())
+}
+
+func TestWALClient_Restart(t *testing.T) {
+	cfg := WALConfig{Enabled: true, Dir: t.TempDir(), TruncateFrequency: time.Hour}
+	entries := testWALEntries(10)
+
+	// Entries written while the next client is blocked, like when Loki is
+	// unavailable, are delivered after a restart.
+	c, err := newWALClient(prometheus.NewRegistry(), util.TestLogger(t), cfg, newBlockedClient())
+	require.NoError(t, err)
+	for _, e := range entries[:5] {
+		c.Chan() <- e
+	}
+	c.Stop()
+
+	next := fake.New(func() {})
+	c, err = newWALClient(prometheus.NewRegistry(), util.TestLogger(t), cfg, next)
+	require.NoError(t, err)
+	for _, e := range entries[5:] {
+		c.Chan() <- e
+	}
+	require.Eventually(t, func() bool {
+		return len(next.Received()) == len(entries)
+	}, 5*time.Second, 10*time.Millisecond)
+	c.Stop()
+
+	requireEntries(t, entries, next.Received())
+
+	// Delivered entries aren't delivered again.
+	next = fake.New(func() {})
+	c, err = newWALClient(prometheus.NewRegistry(), util.TestLogger(t), cfg, next)
+	require.NoError(t, err)
+	c.Stop()
+	require.Empty(t, next.Received())
+}
+
+func TestWALClient_MaxSize(t *testing.T) {
+	cfg := WALConfig{Enabled: true, Dir: t.TempDir(), MaxSizeBytes: 256, TruncateFrequency: time.Hour}
+
+	c, err := newWALClient(prometheus.NewRegistry(), util.TestLogger(t), cfg, newBlockedClient())
+	require.NoError(t, err)
+	defer c.Stop()
+
+	entries := testWALEntries(20)
+	for _, e := range entries {
+		c.Chan() <- e
+	}
+
+	require.Eventually(t, func() bool {
+		written := testutil.ToFloat64(c.metrics.entriesWritten)
+		dropped := testutil.ToFloat64(c.metrics.entriesDropped)
+		return written+dropped == float64(len(entries))
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Greater(t, testutil.ToFloat64(c.metrics.entriesWritten), float64(0))
+	require.Greater(t, testutil.ToFloat64(c.metrics.entriesDropped), float64(0))
+}
+
+func TestWALClient_Truncate(t *testing.T) {
+	cfg := WALConfig{Enabled: true, Dir: t.TempDir(), TruncateFrequency: time.Hour}
+	next := fake.New(func() {})
+
+	c, err := newWALClient(prometheus.NewRegistry(), util.TestLogger(t), cfg, next)
+	require.NoError(t, err)
+	defer c.Stop()
+
+	entries := testWALEntries(10)
+	for _, e := range entries {
+		c.Chan() <- e
+	}
+	require.Eventually(t, func() bool {
+		return len(next.Received()) == len(entries)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The first truncation starts a new segment since entries are read from
+	// the segment being written. Once the reader moves to it, the next
+	// truncation removes the delivered segment.
+	c.truncate()
+	require.Eventually(t, func() bool {
+		c.truncate()
+		first, _, err := wal.Segments(cfg.Dir)
+		return err == nil && first > 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	pos, err := readWALPosition(cfg.Dir)
+	require.NoError(t, err)
+	require.Equal(t, walPosition{Segment: 1}, pos)
+}
+
+func TestWALEntry_Encoding(t *testing.T) {
+	e := api.Entry{
+		Labels: model.LabelSet{"job": "test", "filename": "/var/log/test.log"},
+		Entry:  logproto.Entry{Timestamp: time.Unix(0, 1650000000123456789), Line: "hello, world"},
+	}
+
+	actual, err := decodeWALEntry(encodeWALEntry(e))
+	require.NoError(t, err)
+	require.Equal(t, e.Labels, actual.Labels)
+	require.True(t, e.Timestamp.Equal(actual.Timestamp))
+	require.Equal(t, e.Line, actual.Line)
+
+	_, err = decodeWALEntry(encodeWALEntry(e)[:10])
+	require.Error(t, err)
+}
+
+func testWALEntries(n int) []api.Entry {
+	entries := make([]api.Entry, 0, n)
+	for i := 0; i < n; i++ {
+		entries = append(entries, api.Entry{
+			Labels: model.LabelSet{"job": "test"},
+			Entry:  logproto.Entry{Timestamp: time.Unix(int64(i), 0), Line: fmt.Sprintf("entry %d", i)},
+		})
+	}
+	return entries
+}
+
+func requireEntries(t *testing.T, expect, actual []api.Entry) {
+	t.Helper()
+
+	require.Len(t, actual, len(expect))
+	for i := range expect {
+		require.Equal(t, expect[i].Labels, actual[i].Labels)
+		require.True(t, expect[i].Timestamp.Equal(actual[i].Timestamp))
+		require.Equal(t, expect[i].Line, actual[i].Line)
+	}
+}
+
+// blockedClient is a client.Client which never reads its entries.
+type blockedClient struct {
+	entries chan api.Entry
+}
+
+func newBlockedClient() *blockedClient {
+	return &blockedClient{entries: make(chan api.Entry)}
+}
+
+func (c *blockedClient) Chan() chan<- api.Entry { return c.entries }
+func (c *blockedClient) Stop()                  {}
+func (c *blockedClient) StopNow()               {}
+func (c *blockedClient) Name() string           { return "blocked" }
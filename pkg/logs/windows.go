@@ -0,0 +1,223 @@
+package logs
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/grafana/loki/clients/pkg/logentry/stages"
+	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
+	"gopkg.in/yaml.v2"
+)
+
+// windowsEventField is a field of the log lines of Promtail's windows_events
+// target which can be included in structured events.
+type windowsEventField struct {
+	// name of the field in structured events.
+	name string
+	// key of the field in lines written by Promtail.
+	key string
+	// number is true when the value of the field is a number.
+	number bool
+}
+
+// windowsEventFields are the fields which can be included in structured
+// events, in the order they're written.
+var windowsEventFields = []windowsEventField{
+	{name: "EventID", key: "event_id", number: true},
+	{name: "EventRecordID", key: "eventRecordID", number: true},
+	{name: "Provider", key: "source"},
+	{name: "Channel", key: "channel"},
+	{name: "Computer", key: "computer"},
+	{name: "Level", key: "levelText"},
+	{name: "Task", key: "taskText"},
+	{name: "Opcode", key: "opCodeText"},
+	{name: "Keywords", key: "keywords"},
+	{name: "TimeCreated", key: "timeCreated"},
+	{name: "Message", key: "message"},
+}
+
+// DefaultWindowsEventsStructuredFields are the fields of structured events
+// when none are configured.
+var DefaultWindowsEventsStructuredFields = []string{"EventID", "Provider", "Level", "Message"}
+
+// windowsEventsStructuredKey is the extracted value holding structured
+// events.
+const windowsEventsStructuredKey = "windows_event"
+
+// eventDataFieldRegexp matches the names of fields of event data which can be
+// included in structured events.
+var eventDataFieldRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// WindowsEventsConfig holds the options of the windows_events block of scrape
+// configs which are handled by the agent rather than Promtail. They're applied
+// as pipeline stages which run before the pipeline_stages of the scrape
+// config.
+type WindowsEventsConfig struct {
+	// Structured replaces the JSON lines of events, which hold the XML of
+	// event data, by JSON objects of StructuredFields and EventDataFields.
+	Structured bool `yaml:"structured,omitempty"`
+	// StructuredFields are the fields of events included in structured events.
+	StructuredFields []string `yaml:"structured_fields,omitempty"`
+	// EventDataFields are the names of the Data elements of event data
+	// included in structured events.
+	EventDataFields []string `yaml:"event_data_fields,omitempty"`
+}
+
+// windowsEventsConfigKeys are the keys of the windows_events block handled by
+// WindowsEventsConfig.
+var windowsEventsConfigKeys = []string{"structured", "structured_fields", "event_data_fields"}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (c *WindowsEventsConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain WindowsEventsConfig
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	if !c.Structured {
+		if len(c.StructuredFields) > 0 || len(c.EventDataFields) > 0 {
+			return fmt.Errorf("windows_events structured_fields and event_data_fields require structured to be true")
+		}
+		return nil
+	}
+
+	if len(c.StructuredFields) == 0 {
+		c.StructuredFields = DefaultWindowsEventsStructuredFields
+	}
+	for _, name := range c.StructuredFields {
+		if _, ok := findWindowsEventField(name); !ok {
+			names := make([]string, 0, len(windowsEventFields))
+			for _, f := range windowsEventFields {
+				names = append(names, f.name)
+			}
+			return fmt.Errorf("unknown windows_events structured field %q, expected one of %s", name, strings.Join(names, ", "))
+		}
+	}
+	for _, name := range c.EventDataFields {
+		if !eventDataFieldRegexp.MatchString(name) {
+			return fmt.Errorf("invalid windows_events event data field %q", name)
+		}
+	}
+	return nil
+}
+
+func findWindowsEventField(name string) (windowsEventField, bool) {
+	for _, f := range windowsEventFields {
+		if f.name == name {
+			return f, true
+		}
+	}
+	return windowsEventField{}, false
+}
+
+// pipelineStages returns the pipeline stages applying c.
+//
+// Fields are extracted from lines with a json stage, under their name in
+// structured events, and fields of event data with regex stages, under their
+// name prefixed by EventData_. A template stage then writes the structured
+// event, which an output stage sets as the line. Lines without any of the
+// fields are left untouched.
+func (c *WindowsEventsConfig) pipelineStages() stages.PipelineStages {
+	if !c.Structured {
+		return nil
+	}
+
+	var (
+		expressions = make(map[interface{}]interface{})
+		extracted   []string
+		tmpl        strings.Builder
+	)
+
+	tmpl.WriteString(`{{ $event := dict }}`)
+	for _, f := range windowsEventFields {
+		if !containsString(c.StructuredFields, f.name) {
+			continue
+		}
+		expressions[f.name] = f.key
+		extracted = append(extracted, "."+f.name)
+
+		value := "." + f.name
+		if f.number {
+			value = "(" + value + " | atoi)"
+		}
+		fmt.Fprintf(&tmpl, `{{ if .%[1]s }}{{ $_ := set $event %[1]q %[2]s }}{{ end }}`, f.name, value)
+	}
+
+	var dataStages stages.PipelineStages
+	if len(c.EventDataFields) > 0 {
+		expressions["EventData"] = "event_data"
+
+		tmpl.WriteString(`{{ $data := dict }}`)
+		for _, name := range c.EventDataFields {
+			key := "EventData_" + name
+			extracted = append(extracted, "."+key)
+
+			dataStages = append(dataStages, stages.PipelineStage{
+				stages.StageTypeRegex: map[interface{}]interface{}{
+					"source":     "EventData",
+					"expression": fmt.Sprintf(`<Data Name=['"]%s['"]>(?P<%s>[^<]*)</Data>`, name, key),
+				},
+			})
+			// Event data is XML, so its values are escaped.
+			fmt.Fprintf(&tmpl, `{{ if .%[1]s }}{{ $_ := set $data %[2]q (.%[1]s | replace "&lt;" "<" | replace "&gt;" ">" | replace "&quot;" "\"" | replace "&apos;" "'" | replace "&amp;" "&") }}{{ end }}`, key, name)
+		}
+		tmpl.WriteString(`{{ if $data }}{{ $_ := set $event "EventData" $data }}{{ end }}`)
+	}
+	tmpl.WriteString(`{{ toJson $event }}`)
+
+	res := stages.PipelineStages{
+		stages.PipelineStage{
+			stages.StageTypeJSON: map[interface{}]interface{}{"expressions": expressions},
+		},
+	}
+	res = append(res, dataStages...)
+	return append(res,
+		stages.PipelineStage{
+			stages.StageTypeTemplate: map[interface{}]interface{}{
+				"source":   windowsEventsStructuredKey,
+				"template": fmt.Sprintf(`{{ if or %s }}%s{{ end }}`, strings.Join(extracted, " "), tmpl.String()),
+			},
+		},
+		stages.PipelineStage{
+			stages.StageTypeOutput: map[interface{}]interface{}{"source": windowsEventsStructuredKey},
+		},
+	)
+}
+
+func containsString(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// extractWindowsEventsConfigs removes the options handled by
+// WindowsEventsConfig from the windows_events blocks of the scrape configs in
+// raw, which is an unmarshaled InstanceConfig. It returns the
+// WindowsEventsConfigs by scrape config index.
+func extractWindowsEventsConfigs(raw map[string]interface{}) (map[int]*WindowsEventsConfig, error) {
+	res := make(map[int]*WindowsEventsConfig)
+	for i, bb := range extractScrapeOptions(raw, "windows_events", windowsEventsConfigKeys) {
+		var wc WindowsEventsConfig
+		if err := yaml.UnmarshalStrict(bb, &wc); err != nil {
+			return nil, fmt.Errorf("scrape_configs[%d]: %w", i, err)
+		}
+		res[i] = &wc
+	}
+	return res, nil
+}
+
+// applyWindowsEventsConfigs prepends the pipeline stages of windowsEvents to
+// the pipeline stages of the scrape configs.
+func applyWindowsEventsConfigs(scrapeConfigs []scrapeconfig.Config, windowsEvents map[int]*WindowsEventsConfig) {
+	for i, wc := range windowsEvents {
+		if i >= len(scrapeConfigs) {
+			continue
+		}
+		sc := &scrapeConfigs[i]
+		sc.PipelineStages = append(wc.pipelineStages(), sc.PipelineStages...)
+	}
+}
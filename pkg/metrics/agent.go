@@ -16,6 +16,7 @@ import (
 	"go.uber.org/atomic"
 	"google.golang.org/grpc"
 
+	"github.com/grafana/agent/pkg/limits"
 	"github.com/grafana/agent/pkg/metrics/cluster"
 	"github.com/grafana/agent/pkg/metrics/cluster/client"
 	"github.com/grafana/agent/pkg/metrics/instance"
@@ -140,6 +141,10 @@ type Agent struct {
 
 	cluster *cluster.Cluster
 
+	// pushGroups tracks series pushed through the Pushgateway-compatible
+	// push endpoint.
+	pushGroups *pushGroups
+
 	stopped  bool
 	stopOnce sync.Once
 	actor    chan func()
@@ -147,9 +152,10 @@ type Agent struct {
 	initialBootDone atomic.Bool
 }
 
-// New creates and starts a new Agent.
-func New(reg prometheus.Registerer, cfg Config, logger log.Logger) (*Agent, error) {
-	return newAgent(reg, cfg, logger, defaultInstanceFactory)
+// New creates and starts a new Agent. New series of instances are admitted
+// by limiter, which may be nil.
+func New(reg prometheus.Registerer, cfg Config, logger log.Logger, limiter *limits.Limiter) (*Agent, error) {
+	return newAgent(reg, cfg, logger, defaultInstanceFactory(limiter))
 }
 
 func newAgent(reg prometheus.Registerer, cfg Config, logger log.Logger, fact instanceFactory) (*Agent, error) {
@@ -158,6 +164,7 @@ func newAgent(reg prometheus.Registerer, cfg Config, logger log.Logger, fact ins
 		instanceFactory: fact,
 		reg:             reg,
 		actor:           make(chan func(), 1),
+		pushGroups:      newPushGroups(),
 	}
 
 	a.bm = instance.NewBasicManager(instance.BasicManagerConfig{
@@ -367,6 +374,8 @@ func (a *Agent) Stop() {
 
 type instanceFactory = func(reg prometheus.Registerer, cfg instance.Config, walDir string, logger log.Logger) (instance.ManagedInstance, error)
 
-func defaultInstanceFactory(reg prometheus.Registerer, cfg instance.Config, walDir string, logger log.Logger) (instance.ManagedInstance, error) {
-	return instance.New(reg, cfg, walDir, logger)
+func defaultInstanceFactory(limiter *limits.Limiter) instanceFactory {
+	return func(reg prometheus.Registerer, cfg instance.Config, walDir string, logger log.Logger) (instance.ManagedInstance, error) {
+		return instance.New(reg, cfg, walDir, logger, limiter)
+	}
 }
@@ -0,0 +1,120 @@
+package cluster
+
+import (
+	"hash/fnv"
+	"math"
+	"sort"
+
+	"github.com/grafana/dskit/ring"
+)
+
+// ringToken is a token of an instance in the ring.
+type ringToken struct {
+	token uint32
+	addr  string
+}
+
+// assignKeys assigns keys to instances using consistent hashing with bounded
+// loads. Each key is owned by the first instance clockwise from the hash of
+// the key which owns fewer keys than the capacity of an instance: the
+// average number of keys per instance multiplied by loadFactor. A loadFactor
+// of 0 disables the bound, so that each key is owned by the instance of the
+// first token after its hash like a regular lookup in the ring.
+//
+// Keys are assigned in order of their hash, so every agent computes the same
+// assignment for the same keys and ring. Adding or removing an instance only
+// moves the keys it takes or gives up and the few keys which overflow to the
+// next instance, rather than reshuffling every config.
+//
+// The returned map holds the address of the owner of each key. It's empty
+// when there are no instances.
+func assignKeys(keys []string, instances []ring.InstanceDesc, loadFactor float64) map[string]string {
+	assignment := make(map[string]string, len(keys))
+
+	var tokens []ringToken
+	for _, inst := range instances {
+		for _, t := range inst.Tokens {
+			tokens = append(tokens, ringToken{token: t, addr: inst.Addr})
+		}
+	}
+	if len(tokens) == 0 {
+		return assignment
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		if tokens[i].token != tokens[j].token {
+			return tokens[i].token < tokens[j].token
+		}
+		return tokens[i].addr < tokens[j].addr
+	})
+
+	capacity := math.MaxInt32
+	if loadFactor > 0 {
+		capacity = int(math.Ceil(loadFactor * float64(len(keys)) / float64(len(instances))))
+		if capacity < 1 {
+			capacity = 1
+		}
+	}
+
+	sorted := make([]string, len(keys))
+	copy(sorted, keys)
+	sort.Slice(sorted, func(i, j int) bool {
+		hi, hj := keyHash(sorted[i]), keyHash(sorted[j])
+		if hi != hj {
+			return hi < hj
+		}
+		return sorted[i] < sorted[j]
+	})
+
+	load := make(map[string]int, len(instances))
+	for _, key := range sorted {
+		hash := keyHash(key)
+		start := sort.Search(len(tokens), func(i int) bool {
+			return tokens[i].token > hash
+		})
+
+		// The total capacity is always at least the number of keys, so walking
+		// the ring always finds an instance with room left.
+		for i := 0; i < len(tokens); i++ {
+			tok := tokens[(start+i)%len(tokens)]
+			if load[tok.addr] < capacity {
+				assignment[key] = tok.addr
+				load[tok.addr]++
+				break
+			}
+		}
+	}
+	return assignment
+}
+
+// assignmentLoads returns the number of keys owned by each instance of an
+// assignment. Instances which own no keys are included with a load of 0.
+func assignmentLoads(assignment map[string]string, instances []ring.InstanceDesc) map[string]int {
+	loads := make(map[string]int, len(instances))
+	for _, inst := range instances {
+		loads[inst.Addr] = 0
+	}
+	for _, addr := range assignment {
+		loads[addr]++
+	}
+	return loads
+}
+
+// instancesFingerprint hashes the addresses and tokens of instances, to
+// detect when an assignment computed for them is outdated.
+func instancesFingerprint(instances []ring.InstanceDesc) uint64 {
+	sorted := make([]ring.InstanceDesc, len(instances))
+	copy(sorted, instances)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Addr < sorted[j].Addr })
+
+	h := fnv.New64a()
+	buf := make([]byte, 4)
+	for _, inst := range sorted {
+		_, _ = h.Write([]byte(inst.Addr))
+		_, _ = h.Write([]byte{0})
+		for _, t := range inst.Tokens {
+			buf[0], buf[1], buf[2], buf[3] = byte(t>>24), byte(t>>16), byte(t>>8), byte(t)
+			_, _ = h.Write(buf)
+		}
+	}
+	return h.Sum64()
+}
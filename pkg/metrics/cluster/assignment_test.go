@@ -0,0 +1,163 @@
+package cluster
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"testing"
+
+	"github.com/grafana/dskit/ring"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_assignKeys(t *testing.T) {
+	keys := testKeys(1000)
+	instances := testInstances(t, 5, 128)
+
+	t.Run("bounded load", func(t *testing.T) {
+		assignment := assignKeys(keys, instances, 1.25)
+		require.Len(t, assignment, len(keys))
+
+		capacity := int(math.Ceil(1.25 * float64(len(keys)) / float64(len(instances))))
+		for addr, load := range assignmentLoads(assignment, instances) {
+			require.LessOrEqual(t, load, capacity, "instance %s is over capacity", addr)
+		}
+	})
+
+	t.Run("unbounded load matches ring lookups", func(t *testing.T) {
+		var tokens []uint32
+		owners := make(map[uint32]string)
+		for _, inst := range instances {
+			for _, tok := range inst.Tokens {
+				tokens = append(tokens, tok)
+				owners[tok] = inst.Addr
+			}
+		}
+		sort.Slice(tokens, func(i, j int) bool { return tokens[i] < tokens[j] })
+
+		assignment := assignKeys(keys, instances, 0)
+		for _, key := range keys {
+			i := sort.Search(len(tokens), func(i int) bool { return tokens[i] > keyHash(key) })
+			if i == len(tokens) {
+				i = 0
+			}
+			require.Equal(t, owners[tokens[i]], assignment[key], "unexpected owner for key %s", key)
+		}
+	})
+
+	t.Run("deterministic", func(t *testing.T) {
+		reversed := make([]string, len(keys))
+		for i, key := range keys {
+			reversed[len(keys)-1-i] = key
+		}
+		require.Equal(t, assignKeys(keys, instances, 1.25), assignKeys(reversed, instances, 1.25))
+	})
+
+	t.Run("no instances", func(t *testing.T) {
+		require.Empty(t, assignKeys(keys, nil, 1.25))
+	})
+}
+
+func Test_assignKeys_Churn(t *testing.T) {
+	keys := testKeys(1000)
+	instances := testInstances(t, 6, 128)
+
+	before := assignKeys(keys, instances[:5], 1.25)
+	after := assignKeys(keys, instances, 1.25)
+
+	// Adding a sixth instance should move roughly a sixth of the keys, and
+	// certainly far fewer than rehashing every key would.
+	var moved int
+	for _, key := range keys {
+		if before[key] != after[key] {
+			moved++
+		}
+	}
+	require.Greater(t, moved, 0)
+	require.Less(t, moved, len(keys)/3)
+}
+
+func Test_previewReassignment(t *testing.T) {
+	keys := testKeys(100)
+	instances := testInstances(t, 3, 128)
+
+	t.Run("no change", func(t *testing.T) {
+		resp, err := previewReassignment(keys, instances, 128, 1.25, reassignmentChange{LoadFactor: 1.25})
+		require.NoError(t, err)
+		require.Equal(t, 100, resp.Configs)
+		require.Empty(t, resp.Moved)
+		require.Len(t, resp.Agents, 3)
+		for _, a := range resp.Agents {
+			require.Equal(t, a.Current, a.Projected)
+		}
+	})
+
+	t.Run("remove agent", func(t *testing.T) {
+		resp, err := previewReassignment(keys, instances, 128, 1.25, reassignmentChange{
+			Remove:     []string{instances[0].Addr},
+			LoadFactor: 1.25,
+		})
+		require.NoError(t, err)
+
+		current := assignKeys(keys, instances, 1.25)
+		require.NotEmpty(t, resp.Moved)
+		for _, m := range resp.Moved {
+			require.Equal(t, current[m.Name], m.From)
+			require.NotEqual(t, instances[0].Addr, m.To)
+		}
+		for _, a := range resp.Agents {
+			if a.Addr == instances[0].Addr {
+				require.Equal(t, 0, a.Projected)
+			}
+		}
+	})
+
+	t.Run("add agent", func(t *testing.T) {
+		resp, err := previewReassignment(keys, instances, 128, 1.25, reassignmentChange{Add: 1, LoadFactor: 1.25})
+		require.NoError(t, err)
+		require.Len(t, resp.Agents, 4)
+		require.Equal(t, "new-agent-1", resp.Agents[3].Addr)
+		require.Equal(t, 0, resp.Agents[3].Current)
+		require.Greater(t, resp.Agents[3].Projected, 0)
+	})
+
+	t.Run("unknown agent", func(t *testing.T) {
+		_, err := previewReassignment(keys, instances, 128, 1.25, reassignmentChange{Remove: []string{"unknown:80"}})
+		require.Error(t, err)
+	})
+
+	t.Run("removing every agent", func(t *testing.T) {
+		var remove []string
+		for _, inst := range instances {
+			remove = append(remove, inst.Addr)
+		}
+		_, err := previewReassignment(keys, instances, 128, 1.25, reassignmentChange{Remove: remove})
+		require.Error(t, err)
+	})
+}
+
+func testKeys(n int) []string {
+	keys := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		keys = append(keys, fmt.Sprintf("config-%d", i))
+	}
+	return keys
+}
+
+func testInstances(t *testing.T, n, numTokens int) []ring.InstanceDesc {
+	t.Helper()
+
+	var (
+		instances []ring.InstanceDesc
+		taken     []uint32
+	)
+	for i := 0; i < n; i++ {
+		tokens := ring.GenerateTokens(numTokens, taken)
+		taken = append(taken, tokens...)
+		instances = append(instances, ring.InstanceDesc{
+			Addr:   fmt.Sprintf("agent-%d:12345", i),
+			Tokens: tokens,
+		})
+	}
+	return instances
+}
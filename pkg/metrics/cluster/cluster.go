@@ -78,7 +78,7 @@ func New(
 	c.storeAPI = configstore.NewAPI(l, c.store, c.storeValidate, cfg.APIEnableGetConfiguration)
 	reg.MustRegister(c.storeAPI)
 
-	c.watcher, err = newConfigWatcher(l, cfg, c.store, im, c.node.Owns, validate)
+	c.watcher, err = newConfigWatcher(l, cfg, c.store, im, c.node.Owns, c.node.SetKeys, validate)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize configwatcher: %w", err)
 	}
@@ -129,7 +129,7 @@ func (c *Cluster) ApplyConfig(cfg Config) error {
 		return fmt.Errorf("failed to apply config to node membership: %w", err)
 	}
 
-	if err := c.store.ApplyConfig(cfg.Lifecycler.RingConfig.KVStore, cfg.Enabled); err != nil {
+	if err := c.store.ApplyConfig(cfg.KVStore, cfg.Enabled); err != nil {
 		return fmt.Errorf("failed to apply config to config store: %w", err)
 	}
 
@@ -150,6 +150,8 @@ func (c *Cluster) ApplyConfig(cfg Config) error {
 func (c *Cluster) WireAPI(r *mux.Router) {
 	c.storeAPI.WireAPI(r)
 	c.node.WireAPI(r)
+
+	r.HandleFunc("/agent/api/v1/cluster/reassignment", c.PreviewReassignment).Methods("GET")
 }
 
 // WireGRPC injects gRPC server handlers into the provided gRPC server.
@@ -2,13 +2,14 @@ package cluster
 
 import (
 	"flag"
+	"fmt"
 	"strings"
 	"time"
 
 	util_log "github.com/cortexproject/cortex/pkg/util/log"
 	"github.com/grafana/agent/pkg/metrics/cluster/client"
+	"github.com/grafana/agent/pkg/metrics/instance/configstore"
 	flagutil "github.com/grafana/agent/pkg/util"
-	"github.com/grafana/dskit/kv"
 	"github.com/grafana/dskit/ring"
 )
 
@@ -17,12 +18,17 @@ var DefaultConfig = *flagutil.DefaultConfigFromFlags(&Config{}).(*Config)
 
 // Config describes how to instantiate a scraping service Server instance.
 type Config struct {
-	Enabled                    bool                  `yaml:"enabled"`
-	ReshardInterval            time.Duration         `yaml:"reshard_interval"`
-	ReshardTimeout             time.Duration         `yaml:"reshard_timeout"`
-	ClusterReshardEventTimeout time.Duration         `yaml:"cluster_reshard_event_timeout"`
-	KVStore                    kv.Config             `yaml:"kvstore"`
-	Lifecycler                 ring.LifecyclerConfig `yaml:"lifecycler"`
+	Enabled                    bool                     `yaml:"enabled"`
+	ReshardInterval            time.Duration            `yaml:"reshard_interval"`
+	ReshardTimeout             time.Duration            `yaml:"reshard_timeout"`
+	ClusterReshardEventTimeout time.Duration            `yaml:"cluster_reshard_event_timeout"`
+	KVStore                    configstore.RemoteConfig `yaml:"kvstore"`
+	Lifecycler                 ring.LifecyclerConfig    `yaml:"lifecycler"`
+
+	// AssignmentLoadFactor bounds the number of configs owned by an agent to
+	// the average number of configs per agent multiplied by the factor. 0
+	// disables the bound.
+	AssignmentLoadFactor float64 `yaml:"assignment_load_factor"`
 
 	DangerousAllowReadingFiles bool `yaml:"dangerous_allow_reading_files"`
 
@@ -41,6 +47,10 @@ func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		return err
 	}
 	c.Lifecycler.RingConfig.ReplicationFactor = 1
+
+	if c.AssignmentLoadFactor != 0 && c.AssignmentLoadFactor < 1 {
+		return fmt.Errorf("assignment_load_factor must be 0 or at least 1, got %v", c.AssignmentLoadFactor)
+	}
 	return nil
 }
 
@@ -57,6 +67,7 @@ func (c *Config) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
 	f.DurationVar(&c.ReshardInterval, prefix+"reshard-interval", time.Minute*1, "how often to manually refresh configuration")
 	f.DurationVar(&c.ReshardTimeout, prefix+"reshard-timeout", time.Second*30, "timeout for refreshing the configuration. Timeout of 0s disables timeout.")
 	f.DurationVar(&c.ClusterReshardEventTimeout, prefix+"cluster-reshard-event-timeout", time.Second*30, "timeout for the cluster reshard. Timeout of 0s disables timeout.")
+	f.Float64Var(&c.AssignmentLoadFactor, prefix+"assignment-load-factor", 1.25, "maximum number of configs owned by an agent relative to the average number of configs per agent. 0 disables the bound.")
 	c.KVStore.RegisterFlagsWithPrefix(prefix+"config-store.", "configurations/", f)
 	c.Lifecycler.RegisterFlagsWithPrefix(prefix, f, util_log.Logger)
 
@@ -22,6 +22,10 @@ var (
 	}, []string{"success"})
 )
 
+// claimTimeout is the timeout for claiming and releasing configs in stores
+// which support claims.
+const claimTimeout = 10 * time.Second
+
 // configWatcher connects to a configstore and will apply configs to an
 // instance.Manager.
 type configWatcher struct {
@@ -35,6 +39,7 @@ type configWatcher struct {
 	store    configstore.Store
 	im       instance.Manager
 	owns     OwnershipFunc
+	keys     KeysFunc
 	validate ValidationFunc
 
 	refreshCh   chan struct{}
@@ -45,12 +50,17 @@ type configWatcher struct {
 // OwnershipFunc should determine if a given keep is owned by the caller.
 type OwnershipFunc = func(key string) (bool, error)
 
+// KeysFunc is informed of the keys of all configs in the store before the
+// ownership of any of them is checked during a refresh.
+type KeysFunc = func(keys []string)
+
 // ValidationFunc should validate a config.
 type ValidationFunc = func(*instance.Config) error
 
 // newConfigWatcher watches store for changes and checks for each config against
-// owns. It will also poll the configstore at a configurable interval.
-func newConfigWatcher(log log.Logger, cfg Config, store configstore.Store, im instance.Manager, owns OwnershipFunc, validate ValidationFunc) (*configWatcher, error) {
+// owns. It will also poll the configstore at a configurable interval. keys may
+// be nil.
+func newConfigWatcher(log log.Logger, cfg Config, store configstore.Store, im instance.Manager, owns OwnershipFunc, keys KeysFunc, validate ValidationFunc) (*configWatcher, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	w := &configWatcher{
@@ -61,6 +71,7 @@ func newConfigWatcher(log log.Logger, cfg Config, store configstore.Store, im in
 		store:    store,
 		im:       im,
 		owns:     owns,
+		keys:     keys,
 		validate: validate,
 
 		refreshCh: make(chan struct{}, 1),
@@ -187,6 +198,16 @@ func (w *configWatcher) refresh(ctx context.Context) (err error) {
 		level.Error(w.log).Log("msg", "context deadline exceeded before calling store.all", "err", err)
 		return err
 	}
+	// Ownership of a config can depend on the other configs in the store, so
+	// the full list of keys is passed along first.
+	if w.keys != nil {
+		allKeys, err := w.store.List(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list configs from store: %w", err)
+		}
+		w.keys(allKeys)
+	}
+
 	deadline, _ := ctx.Deadline()
 	level.Debug(w.log).Log("msg", "deadline before store.all", "deadline", deadline)
 	configs, err := w.store.All(ctx, func(key string) bool {
@@ -274,6 +295,24 @@ func (w *configWatcher) handleEvent(ev configstore.WatchEvent) error {
 		isDeleted    = ev.Config == nil
 	)
 
+	// Stores which support claims make sure the previous owner of a config
+	// stopped running it before it's taken over.
+	claimer, hasClaims := w.store.(configstore.Claimer)
+	if hasClaims && owned && !isDeleted {
+		ctx, cancel := context.WithTimeout(context.Background(), claimTimeout)
+		claimed, err := claimer.Claim(ctx, ev.Key)
+		cancel()
+
+		switch {
+		case err != nil:
+			level.Error(w.log).Log("msg", "failed to claim config. instance will be deleted if it is running", "key", ev.Key, "err", err)
+			owned = false
+		case !claimed:
+			level.Info(w.log).Log("msg", "config is still claimed by another agent, waiting for it to be released", "key", ev.Key)
+			owned = false
+		}
+	}
+
 	switch {
 	// Two deletion scenarios:
 	// 1. A config we're running got moved to a new owner.
@@ -291,6 +330,14 @@ func (w *configWatcher) handleEvent(ev configstore.WatchEvent) error {
 			return fmt.Errorf("failed to delete: %w", err)
 		}
 
+		if hasClaims {
+			ctx, cancel := context.WithTimeout(context.Background(), claimTimeout)
+			defer cancel()
+			if err := claimer.Release(ctx, ev.Key); err != nil {
+				level.Warn(w.log).Log("msg", "failed to release claim of config, it will be released when the claim expires", "key", ev.Key, "err", err)
+			}
+		}
+
 	case !isDeleted && owned:
 		if err := w.validate(ev.Config); err != nil {
 			return fmt.Errorf(
@@ -31,7 +31,7 @@ func Test_configWatcher_Refresh(t *testing.T) {
 	cfg.Enabled = true
 	cfg.ReshardInterval = time.Hour
 
-	w, err := newConfigWatcher(log, cfg, &store, &im, owned, validate)
+	w, err := newConfigWatcher(log, cfg, &store, &im, owned, nil, validate)
 	require.NoError(t, err)
 	t.Cleanup(func() { _ = w.Stop() })
 
@@ -71,6 +71,58 @@ func Test_configWatcher_Refresh(t *testing.T) {
 	im.AssertCalled(t, "DeleteConfig", "hello")
 }
 
+func Test_configWatcher_RefreshKeys(t *testing.T) {
+	var (
+		log = util.TestLogger(t)
+
+		cfg   = DefaultConfig
+		store = configstore.Mock{
+			WatchFunc: func() <-chan configstore.WatchEvent {
+				return make(chan configstore.WatchEvent)
+			},
+			ListFunc: func(ctx context.Context) ([]string, error) {
+				return []string{"hello", "world"}, nil
+			},
+		}
+
+		im mockConfigManager
+
+		validate = func(*instance.Config) error { return nil }
+
+		listed []string
+		owned  = func(key string) (bool, error) {
+			// Keys must be known before ownership is checked.
+			return len(listed) > 0, nil
+		}
+		keys = func(keys []string) { listed = keys }
+	)
+	cfg.Enabled = true
+	cfg.ReshardInterval = time.Hour
+
+	w, err := newConfigWatcher(log, cfg, &store, &im, owned, keys, validate)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = w.Stop() })
+
+	im.On("ApplyConfig", mock.Anything).Return(nil)
+	im.On("DeleteConfig", mock.Anything).Return(nil)
+
+	store.AllFunc = func(ctx context.Context, keep func(key string) bool) (<-chan instance.Config, error) {
+		ch := make(chan instance.Config, 1)
+		go func() {
+			if keep("hello") {
+				ch <- instance.Config{Name: "hello"}
+			}
+			close(ch)
+		}()
+		return ch, nil
+	}
+
+	err = w.refresh(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{"hello", "world"}, listed)
+	im.AssertCalled(t, "ApplyConfig", instance.Config{Name: "hello"})
+}
+
 func Test_configWatcher_handleEvent(t *testing.T) {
 	var (
 		cfg   = DefaultConfig
@@ -93,7 +145,7 @@ func Test_configWatcher_handleEvent(t *testing.T) {
 			im  mockConfigManager
 		)
 
-		w, err := newConfigWatcher(log, cfg, &store, &im, owned, validate)
+		w, err := newConfigWatcher(log, cfg, &store, &im, owned, nil, validate)
 		require.NoError(t, err)
 		t.Cleanup(func() { _ = w.Stop() })
 
@@ -112,7 +164,7 @@ func Test_configWatcher_handleEvent(t *testing.T) {
 			im  mockConfigManager
 		)
 
-		w, err := newConfigWatcher(log, cfg, &store, &im, owned, validate)
+		w, err := newConfigWatcher(log, cfg, &store, &im, owned, nil, validate)
 		require.NoError(t, err)
 		t.Cleanup(func() { _ = w.Stop() })
 
@@ -135,7 +187,7 @@ func Test_configWatcher_handleEvent(t *testing.T) {
 			im  mockConfigManager
 		)
 
-		w, err := newConfigWatcher(log, cfg, &store, &im, unowned, validate)
+		w, err := newConfigWatcher(log, cfg, &store, &im, unowned, nil, validate)
 		require.NoError(t, err)
 		t.Cleanup(func() { _ = w.Stop() })
 
@@ -159,7 +211,7 @@ func Test_configWatcher_handleEvent(t *testing.T) {
 			owns    = func(key string) (bool, error) { return isOwned, nil }
 		)
 
-		w, err := newConfigWatcher(log, cfg, &store, &im, owns, validate)
+		w, err := newConfigWatcher(log, cfg, &store, &im, owns, nil, validate)
 		require.NoError(t, err)
 		t.Cleanup(func() { _ = w.Stop() })
 
@@ -187,7 +239,7 @@ func Test_configWatcher_handleEvent(t *testing.T) {
 			im mockConfigManager
 		)
 
-		w, err := newConfigWatcher(log, cfg, &store, &im, owned, validate)
+		w, err := newConfigWatcher(log, cfg, &store, &im, owned, nil, validate)
 		require.NoError(t, err)
 		t.Cleanup(func() { _ = w.Stop() })
 
@@ -206,6 +258,72 @@ func Test_configWatcher_handleEvent(t *testing.T) {
 	})
 }
 
+func Test_configWatcher_Claims(t *testing.T) {
+	var (
+		log = util.TestLogger(t)
+
+		cfg   = DefaultConfig
+		store = claimingStore{
+			Mock: configstore.Mock{
+				WatchFunc: func() <-chan configstore.WatchEvent {
+					return make(chan configstore.WatchEvent)
+				},
+			},
+			claims: map[string]string{"taken": "other"},
+		}
+
+		im mockConfigManager
+
+		validate = func(*instance.Config) error { return nil }
+		owned    = true
+		owns     = func(key string) (bool, error) { return owned, nil }
+	)
+	cfg.Enabled = true
+
+	w, err := newConfigWatcher(log, cfg, &store, &im, owns, nil, validate)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = w.Stop() })
+
+	im.On("ApplyConfig", mock.Anything).Return(nil)
+	im.On("DeleteConfig", mock.Anything).Return(nil)
+
+	// Configs claimed by another agent aren't run until they're released.
+	require.NoError(t, w.handleEvent(configstore.WatchEvent{Key: "taken", Config: &instance.Config{Name: "taken"}}))
+	im.AssertNotCalled(t, "ApplyConfig", instance.Config{Name: "taken"})
+
+	delete(store.claims, "taken")
+	require.NoError(t, w.handleEvent(configstore.WatchEvent{Key: "taken", Config: &instance.Config{Name: "taken"}}))
+	im.AssertCalled(t, "ApplyConfig", instance.Config{Name: "taken"})
+	require.Equal(t, "self", store.claims["taken"])
+
+	// Claims are released once the config moves away.
+	owned = false
+	require.NoError(t, w.handleEvent(configstore.WatchEvent{Key: "taken", Config: &instance.Config{Name: "taken"}}))
+	im.AssertCalled(t, "DeleteConfig", "taken")
+	require.NotContains(t, store.claims, "taken")
+}
+
+// claimingStore is a configstore.Mock which implements configstore.Claimer.
+type claimingStore struct {
+	configstore.Mock
+	claims map[string]string
+}
+
+func (s *claimingStore) Claim(_ context.Context, key string) (bool, error) {
+	if owner, ok := s.claims[key]; ok {
+		return owner == "self", nil
+	}
+	s.claims[key] = "self"
+	return true, nil
+}
+
+func (s *claimingStore) Release(_ context.Context, key string) error {
+	if s.claims[key] == "self" {
+		delete(s.claims, key)
+	}
+	return nil
+}
+
 func Test_configWatcher_nextReshard(t *testing.T) {
 	watcher := &configWatcher{
 		log: util.TestLogger(t),
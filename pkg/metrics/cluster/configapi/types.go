@@ -71,3 +71,33 @@ func WriteError(w http.ResponseWriter, statusCode int, err error) error {
 	w.Header().Set("Content-Type", "application/json")
 	return resp.WriteTo(w, statusCode)
 }
+
+// ReassignmentResponse is contained inside an APIResponse and previews how
+// configs would be reassigned after agents join or leave the cluster.
+// Returned by PreviewReassignment.
+type ReassignmentResponse struct {
+	// Configs is the number of configurations known to the KV store.
+	Configs int `json:"configs"`
+	// Moved is the list of configurations which would change owners.
+	Moved []ReassignedConfig `json:"moved"`
+	// Agents is the number of configurations owned by each agent, before and
+	// after the reassignment.
+	Agents []AgentAssignment `json:"agents"`
+}
+
+// ReassignedConfig is a configuration which would change owners in a
+// ReassignmentResponse.
+type ReassignedConfig struct {
+	Name string `json:"name"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// AgentAssignment is the number of configurations owned by an agent in a
+// ReassignmentResponse. Agents which would join the cluster are named
+// new-agent-N.
+type AgentAssignment struct {
+	Addr      string `json:"addr"`
+	Current   int    `json:"current"`
+	Projected int    `json:"projected"`
+}
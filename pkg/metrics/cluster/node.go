@@ -48,6 +48,15 @@ type node struct {
 
 	exited bool
 	reload chan struct{}
+
+	// assignMut protects the keys known to the node and the assignment of
+	// them computed by Owns, which is only recomputed when the keys, the
+	// instances in the ring, or the load factor change.
+	assignMut             sync.Mutex
+	keys                  map[string]struct{}
+	assignment            map[string]string
+	assignmentFingerprint uint64
+	assignmentLoadFactor  float64
 }
 
 // newNode creates a new node and registers it to the ring.
@@ -58,6 +67,7 @@ func newNode(reg prometheus.Registerer, log log.Logger, cfg Config, s pb.Scrapin
 		log: log,
 
 		reload: make(chan struct{}, 1),
+		keys:   make(map[string]struct{}),
 	}
 	if err := n.ApplyConfig(cfg); err != nil {
 		return nil, err
@@ -356,22 +366,76 @@ func (n *node) TransferOut(ctx context.Context) error {
 	return n.performClusterReshard(ctx, false)
 }
 
+// SetKeys sets the keys of all configs in the store, which are assigned to
+// the nodes in the ring by Owns.
+func (n *node) SetKeys(keys []string) {
+	n.assignMut.Lock()
+	defer n.assignMut.Unlock()
+
+	n.keys = make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		n.keys[key] = struct{}{}
+	}
+	n.assignment = nil
+}
+
 // Owns checks to see if a key is owned by this node. owns will return
 // an error if the ring is empty or if there aren't enough healthy nodes.
+//
+// Keys are assigned to nodes with assignKeys, bounding the number of keys
+// owned by a node. Keys which weren't passed to SetKeys, like configs
+// created since the last refresh, are added to the known keys.
 func (n *node) Owns(key string) (bool, error) {
 	n.mut.RLock()
 	defer n.mut.RUnlock()
 
-	rs, err := n.ring.Get(keyHash(key), ring.Write, nil, nil, nil)
+	if n.ring == nil || n.lc == nil {
+		return false, fmt.Errorf("node disabled")
+	}
+
+	rs, err := n.ring.GetAllHealthy(ring.Write)
 	if err != nil {
 		return false, err
+	} else if len(rs.Instances) == 0 {
+		return false, fmt.Errorf("no healthy instances in the ring")
+	}
+
+	n.assignMut.Lock()
+	defer n.assignMut.Unlock()
+
+	if _, known := n.keys[key]; !known {
+		n.keys[key] = struct{}{}
+		n.assignment = nil
 	}
-	for _, r := range rs.Instances {
-		if r.Addr == n.lc.Addr {
-			return true, nil
+
+	fingerprint := instancesFingerprint(rs.Instances)
+	if n.assignment == nil || n.assignmentFingerprint != fingerprint || n.assignmentLoadFactor != n.cfg.AssignmentLoadFactor {
+		keys := make([]string, 0, len(n.keys))
+		for k := range n.keys {
+			keys = append(keys, k)
 		}
+		n.assignment = assignKeys(keys, rs.Instances, n.cfg.AssignmentLoadFactor)
+		n.assignmentFingerprint = fingerprint
+		n.assignmentLoadFactor = n.cfg.AssignmentLoadFactor
+	}
+	return n.assignment[key] == n.lc.Addr, nil
+}
+
+// Instances returns the healthy instances in the ring, along with the
+// configured number of tokens per instance and assignment load factor.
+func (n *node) Instances() (instances []ring.InstanceDesc, numTokens int, loadFactor float64, err error) {
+	n.mut.RLock()
+	defer n.mut.RUnlock()
+
+	if n.ring == nil {
+		return nil, 0, 0, fmt.Errorf("node disabled")
+	}
+
+	rs, err := n.ring.GetAllHealthy(ring.Write)
+	if err != nil {
+		return nil, 0, 0, err
 	}
-	return false, nil
+	return rs.Instances, n.cfg.Lifecycler.NumTokens, n.cfg.AssignmentLoadFactor, nil
 }
 
 func keyHash(key string) uint32 {
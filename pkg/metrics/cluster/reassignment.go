@@ -0,0 +1,151 @@
+package cluster
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/agent/pkg/metrics/cluster/configapi"
+	"github.com/grafana/dskit/ring"
+)
+
+// reassignmentChange describes a scaling event previewed by
+// previewReassignment.
+type reassignmentChange struct {
+	// Add is the number of agents joining the cluster.
+	Add int
+	// Remove holds the addresses of agents leaving the cluster.
+	Remove []string
+	// LoadFactor is the assignment load factor to use after the change.
+	LoadFactor float64
+}
+
+// previewReassignment compares the assignment of keys to instances with
+// the assignment after change. Joining agents are given numTokens random
+// tokens, so the preview of adding agents is an estimate.
+func previewReassignment(keys []string, instances []ring.InstanceDesc, numTokens int, loadFactor float64, change reassignmentChange) (configapi.ReassignmentResponse, error) {
+	removed := make(map[string]struct{}, len(change.Remove))
+	for _, addr := range change.Remove {
+		removed[addr] = struct{}{}
+	}
+
+	var (
+		projected []ring.InstanceDesc
+		taken     []uint32
+	)
+	for _, inst := range instances {
+		taken = append(taken, inst.Tokens...)
+		if _, ok := removed[inst.Addr]; ok {
+			delete(removed, inst.Addr)
+			continue
+		}
+		projected = append(projected, inst)
+	}
+	for addr := range removed {
+		return configapi.ReassignmentResponse{}, fmt.Errorf("agent %s is not a healthy member of the cluster", addr)
+	}
+	for i := 1; i <= change.Add; i++ {
+		tokens := ring.GenerateTokens(numTokens, taken)
+		taken = append(taken, tokens...)
+		projected = append(projected, ring.InstanceDesc{
+			Addr:   fmt.Sprintf("new-agent-%d", i),
+			Tokens: tokens,
+		})
+	}
+	if len(projected) == 0 {
+		return configapi.ReassignmentResponse{}, fmt.Errorf("no agents would be left in the cluster")
+	}
+
+	var (
+		before = assignKeys(keys, instances, loadFactor)
+		after  = assignKeys(keys, projected, change.LoadFactor)
+
+		beforeLoads = assignmentLoads(before, instances)
+		afterLoads  = assignmentLoads(after, projected)
+
+		resp = configapi.ReassignmentResponse{
+			Configs: len(keys),
+			Moved:   []configapi.ReassignedConfig{},
+		}
+	)
+
+	for _, key := range keys {
+		if before[key] != after[key] {
+			resp.Moved = append(resp.Moved, configapi.ReassignedConfig{Name: key, From: before[key], To: after[key]})
+		}
+	}
+	sort.Slice(resp.Moved, func(i, j int) bool { return resp.Moved[i].Name < resp.Moved[j].Name })
+
+	addrs := make(map[string]struct{}, len(beforeLoads)+len(afterLoads))
+	for addr := range beforeLoads {
+		addrs[addr] = struct{}{}
+	}
+	for addr := range afterLoads {
+		addrs[addr] = struct{}{}
+	}
+	for addr := range addrs {
+		resp.Agents = append(resp.Agents, configapi.AgentAssignment{
+			Addr:      addr,
+			Current:   beforeLoads[addr],
+			Projected: afterLoads[addr],
+		})
+	}
+	sort.Slice(resp.Agents, func(i, j int) bool { return resp.Agents[i].Addr < resp.Agents[j].Addr })
+
+	return resp, nil
+}
+
+// PreviewReassignment previews how configs would be reassigned if agents
+// joined or left the cluster, or if the assignment load factor was changed.
+// The add query parameter sets the number of joining agents, the remove
+// query parameter may be repeated for the address of each leaving agent,
+// and the load_factor query parameter overrides the current load factor.
+func (c *Cluster) PreviewReassignment(rw http.ResponseWriter, r *http.Request) {
+	instances, numTokens, loadFactor, err := c.node.Instances()
+	if err != nil {
+		c.writeError(rw, http.StatusServiceUnavailable, fmt.Errorf("failed to get cluster members: %w", err))
+		return
+	}
+
+	change := reassignmentChange{
+		Remove:     r.URL.Query()["remove"],
+		LoadFactor: loadFactor,
+	}
+	if v := r.URL.Query().Get("add"); v != "" {
+		change.Add, err = strconv.Atoi(v)
+		if err != nil || change.Add < 0 {
+			c.writeError(rw, http.StatusBadRequest, fmt.Errorf("invalid add %q: must be a non-negative integer", v))
+			return
+		}
+	}
+	if v := r.URL.Query().Get("load_factor"); v != "" {
+		change.LoadFactor, err = strconv.ParseFloat(v, 64)
+		if err != nil || (change.LoadFactor != 0 && change.LoadFactor < 1) {
+			c.writeError(rw, http.StatusBadRequest, fmt.Errorf("invalid load_factor %q: must be 0 or at least 1", v))
+			return
+		}
+	}
+
+	keys, err := c.store.List(r.Context())
+	if err != nil {
+		c.writeError(rw, http.StatusInternalServerError, fmt.Errorf("failed to list configs: %w", err))
+		return
+	}
+
+	resp, err := previewReassignment(keys, instances, numTokens, loadFactor, change)
+	if err != nil {
+		c.writeError(rw, http.StatusBadRequest, err)
+		return
+	}
+	if err := configapi.WriteResponse(rw, http.StatusOK, resp); err != nil {
+		level.Error(c.log).Log("msg", "failed to write response", "err", err)
+	}
+}
+
+func (c *Cluster) writeError(rw http.ResponseWriter, statusCode int, writeErr error) {
+	if err := configapi.WriteError(rw, statusCode, writeErr); err != nil {
+		level.Error(c.log).Log("msg", "failed to write response", "err", err)
+	}
+}
@@ -1,7 +1,11 @@
 package metrics
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"sort"
@@ -10,6 +14,9 @@ import (
 	"github.com/go-kit/log/level"
 	"github.com/gorilla/mux"
 	"github.com/grafana/agent/pkg/metrics/cluster/configapi"
+	"github.com/grafana/agent/pkg/metrics/instance"
+	"github.com/grafana/agent/pkg/metrics/wal"
+	"github.com/prometheus/common/expfmt"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/scrape"
@@ -27,6 +34,13 @@ func (a *Agent) WireAPI(r *mux.Router) {
 	r.HandleFunc("/agent/api/v1/metrics/instances", a.ListInstancesHandler).Methods("GET")
 	r.HandleFunc("/agent/api/v1/metrics/targets", a.ListTargetsHandler).Methods("GET")
 	r.HandleFunc("/agent/api/v1/metrics/instance/{instance}/write", a.PushMetricsHandler).Methods("POST")
+	r.HandleFunc("/agent/api/v1/metrics/instance/{instance}/wal/corruptions", a.WALCorruptionsHandler).Methods("GET")
+	r.HandleFunc("/agent/api/v1/metrics/instance/{instance}/scrape_errors", a.ScrapeErrorsHandler).Methods("GET")
+	r.HandleFunc("/agent/api/v1/metrics/instance/{instance}/remote_write/shards", a.RemoteWriteShardsHandler).Methods("GET")
+	r.HandleFunc("/agent/api/v1/metrics/instance/{instance}/remote_write/{remote_name}/shards", a.SetShardOverrideHandler).Methods("PUT", "DELETE")
+
+	// Pushgateway clients append /metrics/job/<job>... to the push URL.
+	r.HandleFunc("/metrics/instance/{instance}/push/metrics/{grouping:.+}", a.PushgatewayHandler).Methods("PUT", "POST", "DELETE")
 }
 
 // ListInstancesHandler writes the set of currently running instances to the http.ResponseWriter.
@@ -155,8 +169,229 @@ func (a *Agent) PushMetricsHandler(w http.ResponseWriter, r *http.Request) {
 	handler.ServeHTTP(w, r)
 }
 
+// PushgatewayHandler accepts pushes using the Pushgateway API and appends the
+// pushed series into an instance's WAL. A PUT replaces all series of the
+// group, a POST replaces series of the pushed metric families and a DELETE
+// deletes the group. Replaced and deleted series are marked as stale.
+func (a *Agent) PushgatewayHandler(w http.ResponseWriter, r *http.Request) {
+	instanceName, err := getInstanceName(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	grouping, err := parseGroupingKey(mux.Vars(r)["grouping"])
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid grouping key: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	managedInstance, err := a.InstanceManager().GetInstance(instanceName)
+	if err != nil || managedInstance == nil {
+		http.Error(w, fmt.Sprintf("instance %s not found", instanceName), http.StatusNotFound)
+		return
+	}
+
+	// Drop the push groups of instances which were removed since the last push.
+	cfgs := a.mm.ListConfigs()
+	a.pushGroups.Prune(func(name string) bool {
+		_, ok := cfgs[name]
+		return ok
+	})
+
+	var (
+		now    = time.Now()
+		series []pushedSeries
+	)
+	if r.Method != http.MethodDelete {
+		if r.ContentLength > maxPushSize {
+			http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPushSize))
+		if err != nil {
+			// MaxBytesReader fails after reading the maximum size, which
+			// distinguishes oversized pushes from failed reads.
+			if int64(len(body)) >= maxPushSize {
+				http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, fmt.Sprintf("failed to read pushed metrics: %s", err), http.StatusBadRequest)
+			return
+		}
+
+		series, err = decodePush(bytes.NewReader(body), expfmt.ResponseFormat(r.Header), grouping, now)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to decode pushed metrics: %s", err), http.StatusBadRequest)
+			return
+		}
+		series = append(series, pushedSeries{
+			family: pushTimeMetric,
+			labels: labels.NewBuilder(grouping).Set(labels.MetricName, pushTimeMetric).Labels(),
+			value:  float64(now.UnixNano()) / 1e9,
+		})
+	}
+
+	replaceAll := r.Method != http.MethodPost
+	err = a.pushGroups.Push(managedInstance.Appender(r.Context()), instanceName, grouping.String(), series, replaceAll, now)
+	if err != nil {
+		level.Error(a.logger).Log("msg", "failed to append pushed metrics", "instance", instanceName, "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		w.WriteHeader(http.StatusAccepted)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// WALCorruptionsHandler writes the corrupt records found in the WAL of an
+// instance to the http.ResponseWriter.
+func (a *Agent) WALCorruptionsHandler(w http.ResponseWriter, r *http.Request) {
+	instanceName, err := getInstanceName(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	managedInstance, err := a.InstanceManager().GetInstance(instanceName)
+	if err != nil || managedInstance == nil {
+		http.Error(w, fmt.Sprintf("instance %s not found", instanceName), http.StatusNotFound)
+		return
+	}
+
+	reporter, ok := managedInstance.(interface{ WALCorruptions() []wal.Corruption })
+	if !ok {
+		http.Error(w, fmt.Sprintf("instance %s does not report WAL corruptions", instanceName), http.StatusBadRequest)
+		return
+	}
+
+	corruptions := reporter.WALCorruptions()
+	if corruptions == nil {
+		corruptions = []wal.Corruption{}
+	}
+	err = configapi.WriteResponse(w, http.StatusOK, corruptions)
+	if err != nil {
+		level.Error(a.logger).Log("msg", "failed to write response", "err", err)
+	}
+}
+
+// ScrapeErrorsHandler writes the most recent scrape errors of each target of
+// an instance to the http.ResponseWriter.
+func (a *Agent) ScrapeErrorsHandler(w http.ResponseWriter, r *http.Request) {
+	instanceName, err := getInstanceName(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	managedInstance, err := a.InstanceManager().GetInstance(instanceName)
+	if err != nil || managedInstance == nil {
+		http.Error(w, fmt.Sprintf("instance %s not found", instanceName), http.StatusNotFound)
+		return
+	}
+
+	reporter, ok := managedInstance.(interface {
+		ScrapeErrors() []instance.TargetScrapeErrors
+	})
+	if !ok {
+		http.Error(w, fmt.Sprintf("instance %s does not report scrape errors", instanceName), http.StatusBadRequest)
+		return
+	}
+
+	err = configapi.WriteResponse(w, http.StatusOK, reporter.ScrapeErrors())
+	if err != nil {
+		level.Error(a.logger).Log("msg", "failed to write response", "err", err)
+	}
+}
+
 // getInstanceName uses gorilla/mux's route variables to extract the
 // "instance" variable. If not found, getInstanceName will return an error.
+// RemoteWriteShardsHandler returns the sharding of the remote_write queues of
+// an instance.
+func (a *Agent) RemoteWriteShardsHandler(w http.ResponseWriter, r *http.Request) {
+	instanceName, err := getInstanceName(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	managedInstance, err := a.InstanceManager().GetInstance(instanceName)
+	if err != nil || managedInstance == nil {
+		http.Error(w, fmt.Sprintf("instance %s not found", instanceName), http.StatusNotFound)
+		return
+	}
+
+	reporter, ok := managedInstance.(interface {
+		RemoteWriteShards() []instance.RemoteWriteShards
+	})
+	if !ok {
+		http.Error(w, fmt.Sprintf("instance %s does not report remote_write shards", instanceName), http.StatusBadRequest)
+		return
+	}
+
+	err = configapi.WriteResponse(w, http.StatusOK, reporter.RemoteWriteShards())
+	if err != nil {
+		level.Error(a.logger).Log("msg", "failed to write response", "err", err)
+	}
+}
+
+// SetShardOverrideHandler overrides the shard bounds of a remote_write queue
+// of an instance with the ShardOverride in the request body on PUT, and
+// removes the override on DELETE.
+func (a *Agent) SetShardOverrideHandler(w http.ResponseWriter, r *http.Request) {
+	instanceName, err := getInstanceName(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	remoteName, err := url.PathUnescape(mux.Vars(r)["remote_name"])
+	if err != nil {
+		http.Error(w, fmt.Sprintf("could not decode remote_write name: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	managedInstance, err := a.InstanceManager().GetInstance(instanceName)
+	if err != nil || managedInstance == nil {
+		http.Error(w, fmt.Sprintf("instance %s not found", instanceName), http.StatusNotFound)
+		return
+	}
+
+	overrider, ok := managedInstance.(interface {
+		SetShardOverride(remoteName string, o *instance.ShardOverride) error
+	})
+	if !ok {
+		http.Error(w, fmt.Sprintf("instance %s does not support shard overrides", instanceName), http.StatusBadRequest)
+		return
+	}
+
+	var override *instance.ShardOverride
+	if r.Method == http.MethodPut {
+		override = &instance.ShardOverride{}
+		if err := json.NewDecoder(r.Body).Decode(override); err != nil {
+			http.Error(w, fmt.Sprintf("could not decode shard override: %s", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	err = overrider.SetShardOverride(remoteName, override)
+	switch {
+	case errors.Is(err, instance.ErrUnknownRemoteWrite):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = configapi.WriteResponse(w, http.StatusOK, nil)
+	if err != nil {
+		level.Error(a.logger).Log("msg", "failed to write response", "err", err)
+	}
+}
+
 func getInstanceName(r *http.Request) (string, error) {
 	vars := mux.Vars(r)
 	name := vars["instance"]
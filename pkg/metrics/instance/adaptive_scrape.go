@@ -0,0 +1,361 @@
+package instance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/procfs"
+	"github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/model/timestamp"
+)
+
+// DefaultAdaptiveScrapeConfig holds default settings for AdaptiveScrapeConfig.
+var DefaultAdaptiveScrapeConfig = AdaptiveScrapeConfig{
+	CheckInterval:       15 * time.Second,
+	MemoryHighWatermark: 0.8,
+	CPUHighWatermark:    0.9,
+	MaxRemoteWriteLag:   5 * time.Minute,
+	MaxIntervalFactor:   4,
+}
+
+// adaptiveScrapeRecoveryRatio is the fraction of a high watermark which
+// resource usage must fall below before scrape intervals are shortened
+// again. This prevents the interval from flapping when usage hovers around a
+// watermark.
+const adaptiveScrapeRecoveryRatio = 0.8
+
+// AdaptiveScrapeConfig configures temporarily lengthening the scrape
+// intervals of an instance while the agent is under resource pressure.
+type AdaptiveScrapeConfig struct {
+	// How often resource usage is checked.
+	CheckInterval time.Duration `yaml:"check_interval,omitempty"`
+
+	// Memory limit of the agent in bytes. Memory pressure isn't checked when
+	// 0.
+	MemoryLimitBytes uint64 `yaml:"memory_limit_bytes,omitempty"`
+	// Fraction of MemoryLimitBytes above which the agent is under memory
+	// pressure.
+	MemoryHighWatermark float64 `yaml:"memory_high_watermark,omitempty"`
+	// Fraction of the available CPUs above which the agent is under CPU
+	// pressure. CPU pressure isn't checked when 0.
+	CPUHighWatermark float64 `yaml:"cpu_high_watermark,omitempty"`
+	// How far remote_write may fall behind before the instance is under
+	// pressure. Remote write lag isn't checked when 0.
+	MaxRemoteWriteLag time.Duration `yaml:"max_remote_write_lag,omitempty"`
+
+	// Maximum factor that scrape intervals are lengthened by.
+	MaxIntervalFactor float64 `yaml:"max_interval_factor,omitempty"`
+	// Maximum scrape interval of individual jobs, capping the interval below
+	// what MaxIntervalFactor would allow.
+	JobMaxScrapeIntervals map[string]model.Duration `yaml:"job_max_scrape_intervals,omitempty"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (c *AdaptiveScrapeConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultAdaptiveScrapeConfig
+
+	type plain AdaptiveScrapeConfig
+	return unmarshal((*plain)(c))
+}
+
+// Validate returns an error if c is invalid for the instance config ic.
+func (c *AdaptiveScrapeConfig) Validate(ic *Config) error {
+	switch {
+	case c.CheckInterval <= 0:
+		return errors.New("check_interval must be greater than 0s")
+	case c.MemoryHighWatermark <= 0 || c.MemoryHighWatermark > 1:
+		return errors.New("memory_high_watermark must be greater than 0 and at most 1")
+	case c.CPUHighWatermark < 0 || c.CPUHighWatermark > 1:
+		return errors.New("cpu_high_watermark must be between 0 and 1")
+	case c.MaxRemoteWriteLag < 0:
+		return errors.New("max_remote_write_lag must not be negative")
+	case c.MaxIntervalFactor < 1:
+		return errors.New("max_interval_factor must be at least 1")
+	}
+
+	jobs := make(map[string]*config.ScrapeConfig, len(ic.ScrapeConfigs))
+	for _, sc := range ic.ScrapeConfigs {
+		jobs[sc.JobName] = sc
+	}
+	for job, max := range c.JobMaxScrapeIntervals {
+		sc, ok := jobs[job]
+		switch {
+		case !ok:
+			return fmt.Errorf("job_max_scrape_intervals references unknown job %q", job)
+		case max < sc.ScrapeInterval:
+			return fmt.Errorf("max scrape interval of job %q must not be less than its scrape interval", job)
+		case time.Duration(max) > ic.WALTruncateFrequency:
+			return fmt.Errorf("max scrape interval of job %q must not be greater than wal_truncate_frequency", job)
+		}
+	}
+	return nil
+}
+
+// maxScrapeInterval returns the longest scrape interval that sc may be
+// lengthened to. wal_truncate_frequency always bounds the interval.
+func (c *AdaptiveScrapeConfig) maxScrapeInterval(sc *config.ScrapeConfig, walTruncateFrequency time.Duration) time.Duration {
+	max := time.Duration(float64(sc.ScrapeInterval) * c.MaxIntervalFactor)
+	if jobMax, ok := c.JobMaxScrapeIntervals[sc.JobName]; ok {
+		max = time.Duration(jobMax)
+	}
+	if max > walTruncateFrequency {
+		max = walTruncateFrequency
+	}
+	if max < time.Duration(sc.ScrapeInterval) {
+		max = time.Duration(sc.ScrapeInterval)
+	}
+	return max
+}
+
+// adaptScrapeConfigs returns the scrape configs of cfg with their scrape
+// intervals multiplied by factor, bounded by the adaptive scrape config of
+// cfg. The scrape configs of cfg are returned as-is if factor is 1 or
+// adaptive scraping is disabled.
+func adaptScrapeConfigs(cfg *Config, factor float64) []*config.ScrapeConfig {
+	if cfg.AdaptiveScrape == nil || factor <= 1 {
+		return cfg.ScrapeConfigs
+	}
+
+	res := make([]*config.ScrapeConfig, 0, len(cfg.ScrapeConfigs))
+	for _, sc := range cfg.ScrapeConfigs {
+		interval := time.Duration(float64(sc.ScrapeInterval) * factor)
+		if max := cfg.AdaptiveScrape.maxScrapeInterval(sc, cfg.WALTruncateFrequency); interval > max {
+			interval = max
+		}
+
+		// Scrape configs are shallow copied; only the interval is modified.
+		adapted := *sc
+		adapted.ScrapeInterval = model.Duration(interval)
+		res = append(res, &adapted)
+	}
+	return res
+}
+
+// resourceUsage is the resource usage observed by the adaptive scrape
+// controller.
+type resourceUsage struct {
+	// MemoryBytes used by the process.
+	MemoryBytes uint64
+	// CPU is the fraction of available CPUs used by the process, or -1 if
+	// unknown.
+	CPU float64
+	// RemoteWriteLag is how far remote_write has fallen behind, or -1 if
+	// unknown.
+	RemoteWriteLag time.Duration
+}
+
+// pressureReasons returns the reasons the agent is under pressure according
+// to usage. An empty list is returned when there's no pressure. If recovery
+// is true, thresholds are lowered by adaptiveScrapeRecoveryRatio.
+func (c *AdaptiveScrapeConfig) pressureReasons(usage resourceUsage, recovery bool) []string {
+	ratio := 1.0
+	if recovery {
+		ratio = adaptiveScrapeRecoveryRatio
+	}
+
+	var reasons []string
+	if c.MemoryLimitBytes > 0 && float64(usage.MemoryBytes) >= float64(c.MemoryLimitBytes)*c.MemoryHighWatermark*ratio {
+		reasons = append(reasons, "memory")
+	}
+	if c.CPUHighWatermark > 0 && usage.CPU >= 0 && usage.CPU >= c.CPUHighWatermark*ratio {
+		reasons = append(reasons, "cpu")
+	}
+	if c.MaxRemoteWriteLag > 0 && usage.RemoteWriteLag >= 0 && float64(usage.RemoteWriteLag) >= float64(c.MaxRemoteWriteLag)*ratio {
+		reasons = append(reasons, "remote_write_lag")
+	}
+	return reasons
+}
+
+// nextFactor returns the scrape interval factor to use after observing
+// usage. The factor doubles while the agent is under pressure, up to
+// MaxIntervalFactor, and halves back towards 1 once usage falls below the
+// recovery thresholds.
+func (c *AdaptiveScrapeConfig) nextFactor(factor float64, usage resourceUsage) (next float64, reasons []string) {
+	if reasons := c.pressureReasons(usage, false); len(reasons) > 0 {
+		return math.Min(factor*2, c.MaxIntervalFactor), reasons
+	}
+	if len(c.pressureReasons(usage, true)) == 0 {
+		return math.Max(factor/2, 1), nil
+	}
+	return math.Min(factor, c.MaxIntervalFactor), nil
+}
+
+// resourceSampler samples the resource usage of the process.
+type resourceSampler struct {
+	proc     *procfs.Proc
+	lastCPU  float64
+	lastTime time.Time
+}
+
+func newResourceSampler() *resourceSampler {
+	var s resourceSampler
+	// procfs is only available on Linux; CPU usage is unknown elsewhere.
+	if proc, err := procfs.Self(); err == nil {
+		s.proc = &proc
+	}
+	return &s
+}
+
+// Sample returns the memory and CPU usage of the process. CPU usage is
+// averaged since the previous call to Sample and is unknown on the first
+// call.
+func (s *resourceSampler) Sample() resourceUsage {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	usage := resourceUsage{
+		MemoryBytes:    ms.Sys - ms.HeapReleased,
+		CPU:            -1,
+		RemoteWriteLag: -1,
+	}
+
+	if s.proc == nil {
+		return usage
+	}
+	stat, err := s.proc.Stat()
+	if err != nil {
+		return usage
+	}
+
+	now, cpu := time.Now(), stat.CPUTime()
+	if !s.lastTime.IsZero() {
+		elapsed := now.Sub(s.lastTime).Seconds() * float64(runtime.GOMAXPROCS(0))
+		if elapsed > 0 {
+			usage.CPU = (cpu - s.lastCPU) / elapsed
+		}
+	}
+	s.lastCPU, s.lastTime = cpu, now
+	return usage
+}
+
+type adaptiveScrapeMetrics struct {
+	factor      prometheus.Gauge
+	degraded    prometheus.Gauge
+	transitions *prometheus.CounterVec
+}
+
+func newAdaptiveScrapeMetrics(reg prometheus.Registerer) *adaptiveScrapeMetrics {
+	m := &adaptiveScrapeMetrics{
+		factor: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "agent_metrics_adaptive_scrape_interval_factor",
+			Help: "Factor scrape intervals are currently lengthened by because of resource pressure.",
+		}),
+		degraded: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "agent_metrics_adaptive_scrape_degraded",
+			Help: "Whether scrape intervals are currently lengthened because of resource pressure.",
+		}),
+		transitions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "agent_metrics_adaptive_scrape_transitions_total",
+			Help: "Total number of times degraded mode was engaged or disengaged.",
+		}, []string{"state"}),
+	}
+	m.factor.Set(1)
+
+	if reg != nil {
+		reg.MustRegister(m.factor, m.degraded, m.transitions)
+	}
+	return m
+}
+
+// adaptiveScrapeLoop periodically checks resource usage and lengthens the
+// scrape intervals of the instance while it's under pressure. It runs until
+// ctx is canceled.
+func (i *Instance) adaptiveScrapeLoop(ctx context.Context, reg prometheus.Registerer) {
+	var (
+		metrics = newAdaptiveScrapeMetrics(reg)
+		sampler = newResourceSampler()
+		logger  = log.With(i.logger, "component", "adaptive scrape")
+	)
+
+	for {
+		i.mut.Lock()
+		ac := i.cfg.AdaptiveScrape
+		i.mut.Unlock()
+
+		checkInterval := DefaultAdaptiveScrapeConfig.CheckInterval
+		if ac != nil {
+			checkInterval = ac.CheckInterval
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(checkInterval):
+		}
+
+		i.mut.Lock()
+		idle := i.cfg.AdaptiveScrape == nil && i.scrapeIntervalFactor <= 1
+		i.mut.Unlock()
+		if idle {
+			continue
+		}
+
+		usage := sampler.Sample()
+		if ts := i.getRemoteWriteTimestamp(); ts > 0 {
+			usage.RemoteWriteLag = time.Since(timestamp.Time(ts))
+		}
+
+		i.mut.Lock()
+		var (
+			prev    = i.scrapeIntervalFactor
+			next    = 1.0
+			reasons []string
+		)
+		// The adaptive scrape config may have been removed since it was last
+		// read, in which case intervals are restored.
+		if i.cfg.AdaptiveScrape != nil {
+			next, reasons = i.cfg.AdaptiveScrape.nextFactor(prev, usage)
+		}
+		var err error
+		if next != prev {
+			err = i.setScrapeIntervalFactor(next)
+		}
+		i.mut.Unlock()
+
+		if err != nil {
+			level.Error(logger).Log("msg", "failed to apply adapted scrape intervals", "factor", next, "err", err)
+			continue
+		}
+
+		metrics.factor.Set(next)
+		switch {
+		case prev <= 1 && next > 1:
+			metrics.degraded.Set(1)
+			metrics.transitions.WithLabelValues("degraded").Inc()
+			level.Warn(logger).Log("msg", "agent is under resource pressure, lengthening scrape intervals", "factor", next, "reasons", strings.Join(reasons, ","))
+		case prev > 1 && next <= 1:
+			metrics.degraded.Set(0)
+			metrics.transitions.WithLabelValues("recovered").Inc()
+			level.Info(logger).Log("msg", "resource pressure relieved, restored scrape intervals")
+		case next != prev:
+			level.Info(logger).Log("msg", "changed scrape interval factor", "factor", next, "reasons", strings.Join(reasons, ","))
+		}
+	}
+}
+
+// setScrapeIntervalFactor applies scrape configs lengthened by factor to the
+// scrape manager. i.mut must be held when called.
+func (i *Instance) setScrapeIntervalFactor(factor float64) error {
+	sm, err := i.readyScrapeManager.Get()
+	if err != nil {
+		return err
+	}
+	err = sm.ApplyConfig(&config.Config{
+		GlobalConfig:  i.cfg.global.Prometheus,
+		ScrapeConfigs: adaptScrapeConfigs(&i.cfg, factor),
+	})
+	if err != nil {
+		return err
+	}
+	i.scrapeIntervalFactor = factor
+	return nil
+}
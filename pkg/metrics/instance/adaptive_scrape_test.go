@@ -0,0 +1,137 @@
+package instance
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdaptiveScrapeConfig_Unmarshal(t *testing.T) {
+	cfgText := `name: test
+scrape_configs:
+  - job_name: node
+    scrape_interval: 15s
+    static_configs:
+      - targets: ['127.0.0.1:12345']
+  - job_name: slow
+    scrape_interval: 30s
+    static_configs:
+      - targets: ['127.0.0.1:12346']
+adaptive_scrape:
+  memory_limit_bytes: 1000
+  job_max_scrape_intervals:
+    node: 20s`
+
+	cfg, err := UnmarshalConfig(strings.NewReader(cfgText))
+	require.NoError(t, err)
+	require.NoError(t, cfg.ApplyDefaults(DefaultGlobalConfig))
+
+	expect := DefaultAdaptiveScrapeConfig
+	expect.MemoryLimitBytes = 1000
+	expect.JobMaxScrapeIntervals = map[string]model.Duration{"node": model.Duration(20 * time.Second)}
+	require.Equal(t, &expect, cfg.AdaptiveScrape)
+
+	// Scrape intervals are lengthened up to the bound of each job.
+	adapted := adaptScrapeConfigs(cfg, 2)
+	require.Equal(t, model.Duration(20*time.Second), adapted[0].ScrapeInterval)
+	require.Equal(t, model.Duration(time.Minute), adapted[1].ScrapeInterval)
+	adapted = adaptScrapeConfigs(cfg, 8)
+	require.Equal(t, model.Duration(20*time.Second), adapted[0].ScrapeInterval)
+	require.Equal(t, model.Duration(2*time.Minute), adapted[1].ScrapeInterval)
+
+	// The original scrape configs must not be modified.
+	require.Equal(t, model.Duration(15*time.Second), cfg.ScrapeConfigs[0].ScrapeInterval)
+	require.Equal(t, model.Duration(30*time.Second), cfg.ScrapeConfigs[1].ScrapeInterval)
+}
+
+func TestAdaptiveScrapeConfig_Validate(t *testing.T) {
+	tt := []struct {
+		name string
+		cfg  string
+		err  string
+	}{
+		{
+			name: "invalid watermark",
+			cfg:  `memory_high_watermark: 1.5`,
+			err:  "memory_high_watermark must be greater than 0 and at most 1",
+		},
+		{
+			name: "invalid factor",
+			cfg:  `max_interval_factor: 0.5`,
+			err:  "max_interval_factor must be at least 1",
+		},
+		{
+			name: "unknown job",
+			cfg:  `job_max_scrape_intervals: {other: 1m}`,
+			err:  `job_max_scrape_intervals references unknown job "other"`,
+		},
+		{
+			name: "bound below interval",
+			cfg:  `job_max_scrape_intervals: {node: 5s}`,
+			err:  `max scrape interval of job "node" must not be less than its scrape interval`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			cfgText := `name: test
+scrape_configs:
+  - job_name: node
+    scrape_interval: 15s
+    static_configs:
+      - targets: ['127.0.0.1:12345']
+adaptive_scrape: {` + tc.cfg + `}`
+
+			cfg, err := UnmarshalConfig(strings.NewReader(cfgText))
+			require.NoError(t, err)
+			err = cfg.ApplyDefaults(DefaultGlobalConfig)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.err)
+		})
+	}
+}
+
+func TestAdaptiveScrapeConfig_NextFactor(t *testing.T) {
+	cfg := DefaultAdaptiveScrapeConfig
+	cfg.MemoryLimitBytes = 1000
+
+	var (
+		pressure = resourceUsage{MemoryBytes: 900, CPU: 0.1, RemoteWriteLag: time.Second}
+		elevated = resourceUsage{MemoryBytes: 700, CPU: 0.1, RemoteWriteLag: time.Second}
+		relieved = resourceUsage{MemoryBytes: 100, CPU: 0.1, RemoteWriteLag: time.Second}
+	)
+
+	// The factor doubles under pressure up to max_interval_factor.
+	factor, reasons := cfg.nextFactor(1, pressure)
+	require.Equal(t, 2.0, factor)
+	require.Equal(t, []string{"memory"}, reasons)
+	factor, _ = cfg.nextFactor(factor, pressure)
+	require.Equal(t, 4.0, factor)
+	factor, _ = cfg.nextFactor(factor, pressure)
+	require.Equal(t, 4.0, factor)
+
+	// Usage between the recovery threshold and the watermark keeps the factor
+	// unchanged.
+	factor, reasons = cfg.nextFactor(factor, elevated)
+	require.Equal(t, 4.0, factor)
+	require.Empty(t, reasons)
+
+	// The factor halves back towards 1 once pressure is relieved.
+	factor, _ = cfg.nextFactor(factor, relieved)
+	require.Equal(t, 2.0, factor)
+	factor, _ = cfg.nextFactor(factor, relieved)
+	require.Equal(t, 1.0, factor)
+	factor, _ = cfg.nextFactor(factor, relieved)
+	require.Equal(t, 1.0, factor)
+
+	// Unknown usage never causes pressure.
+	_, reasons = cfg.nextFactor(1, resourceUsage{CPU: -1, RemoteWriteLag: -1})
+	require.Empty(t, reasons)
+
+	// Every signal is reported.
+	_, reasons = cfg.nextFactor(1, resourceUsage{MemoryBytes: 900, CPU: 0.95, RemoteWriteLag: time.Hour})
+	require.Equal(t, []string{"memory", "cpu", "remote_write_lag"}, reasons)
+}
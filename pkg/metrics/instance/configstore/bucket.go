@@ -0,0 +1,251 @@
+package configstore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/kv"
+	"github.com/grafana/dskit/kv/codec"
+	"github.com/prometheus/client_golang/prometheus"
+	config_util "github.com/prometheus/common/config"
+	"github.com/thanos-io/thanos/pkg/objstore"
+	"github.com/thanos-io/thanos/pkg/objstore/gcs"
+	"github.com/thanos-io/thanos/pkg/objstore/s3"
+)
+
+// Supported values for BucketConfig.Backend.
+const (
+	BucketS3  = "s3"
+	BucketGCS = "gcs"
+)
+
+// BucketConfig configures the object storage used by the "bucket" store.
+type BucketConfig struct {
+	// Backend is the object storage service to use.
+	Backend string    `yaml:"backend"`
+	S3      S3Config  `yaml:"s3"`
+	GCS     GCSConfig `yaml:"gcs"`
+}
+
+// S3Config configures an S3 bucket.
+type S3Config struct {
+	Endpoint        string             `yaml:"endpoint"`
+	BucketName      string             `yaml:"bucket_name"`
+	Region          string             `yaml:"region"`
+	AccessKeyID     string             `yaml:"access_key_id"`
+	SecretAccessKey config_util.Secret `yaml:"secret_access_key"`
+	Insecure        bool               `yaml:"insecure"`
+}
+
+// GCSConfig configures a GCS bucket.
+type GCSConfig struct {
+	BucketName string `yaml:"bucket_name"`
+	// ServiceAccount holds the JSON key of a service account. Application
+	// default credentials are used when empty.
+	ServiceAccount config_util.Secret `yaml:"service_account"`
+}
+
+// newBucket creates a bucket from cfg, registering its metrics to reg.
+func newBucket(ctx context.Context, l log.Logger, reg prometheus.Registerer, cfg BucketConfig) (objstore.Bucket, error) {
+	var (
+		bkt objstore.Bucket
+		err error
+	)
+
+	switch cfg.Backend {
+	case BucketS3:
+		// Options which aren't exposed use the defaults of Thanos.
+		s3Config := s3.DefaultConfig
+		s3Config.Bucket = cfg.S3.BucketName
+		s3Config.Endpoint = cfg.S3.Endpoint
+		s3Config.Region = cfg.S3.Region
+		s3Config.AccessKey = cfg.S3.AccessKeyID
+		s3Config.SecretKey = string(cfg.S3.SecretAccessKey)
+		s3Config.Insecure = cfg.S3.Insecure
+
+		bkt, err = s3.NewBucketWithConfig(l, s3Config, "agent")
+	case BucketGCS:
+		bkt, err = gcs.NewBucketWithConfig(ctx, l, gcs.Config{
+			Bucket:         cfg.GCS.BucketName,
+			ServiceAccount: string(cfg.GCS.ServiceAccount),
+		}, "agent")
+	default:
+		return nil, fmt.Errorf("unsupported bucket backend %q, expected %s or %s", cfg.Backend, BucketS3, BucketGCS)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return objstore.BucketWithMetrics(bkt.Name(), bkt, reg), nil
+}
+
+// bucketClient implements kv.Client for object storage. Each key is stored
+// as an object named after it under a prefix.
+//
+// Object storage has no transactions, so CAS is last-writer-wins, and no
+// change notifications, so watches poll the bucket.
+type bucketClient struct {
+	log          log.Logger
+	bkt          objstore.Bucket
+	codec        codec.Codec
+	prefix       string
+	pollInterval time.Duration
+}
+
+var _ kv.Client = (*bucketClient)(nil)
+
+func newBucketClient(l log.Logger, bkt objstore.Bucket, c codec.Codec, prefix string, pollInterval time.Duration) *bucketClient {
+	return &bucketClient{
+		log:          l,
+		bkt:          bkt,
+		codec:        c,
+		prefix:       prefix,
+		pollInterval: pollInterval,
+	}
+}
+
+// List implements kv.Client.
+func (c *bucketClient) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	err := c.bkt.Iter(ctx, c.prefix, func(name string) error {
+		key := strings.TrimPrefix(name, c.prefix)
+		if strings.HasPrefix(key, prefix) && !strings.HasSuffix(key, "/") {
+			keys = append(keys, key)
+		}
+		return nil
+	}, objstore.WithRecursiveIter)
+	return keys, err
+}
+
+// Get implements kv.Client. It returns nil if key doesn't exist.
+func (c *bucketClient) Get(ctx context.Context, key string) (interface{}, error) {
+	rc, err := c.bkt.Get(ctx, c.prefix+key)
+	if c.bkt.IsObjNotFoundErr(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	bb, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+	return c.codec.Decode(bb)
+}
+
+// Delete implements kv.Client.
+func (c *bucketClient) Delete(ctx context.Context, key string) error {
+	err := c.bkt.Delete(ctx, c.prefix+key)
+	if c.bkt.IsObjNotFoundErr(err) {
+		return nil
+	}
+	return err
+}
+
+// CAS implements kv.Client. Concurrent writers to the same key aren't
+// detected, and the last write wins.
+func (c *bucketClient) CAS(ctx context.Context, key string, f func(in interface{}) (out interface{}, retry bool, err error)) error {
+	in, err := c.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	out, _, err := f(in)
+	if err != nil || out == nil {
+		return err
+	}
+
+	bb, err := c.codec.Encode(out)
+	if err != nil {
+		return err
+	}
+	return c.bkt.Upload(ctx, c.prefix+key, bytes.NewReader(bb))
+}
+
+// WatchKey implements kv.Client.
+func (c *bucketClient) WatchKey(ctx context.Context, key string, f func(interface{}) bool) {
+	c.WatchPrefix(ctx, key, func(k string, v interface{}) bool {
+		if k != key {
+			return true
+		}
+		return f(v)
+	})
+}
+
+// WatchPrefix implements kv.Client. The bucket is polled for objects which
+// changed since the previous poll, starting from the objects which exist
+// when WatchPrefix is called.
+func (c *bucketClient) WatchPrefix(ctx context.Context, prefix string, f func(string, interface{}) bool) {
+	prev, err := c.poll(ctx, prefix)
+	if err != nil {
+		level.Warn(c.log).Log("msg", "failed to poll bucket for changes", "err", err)
+	}
+
+	ticker := time.NewTicker(c.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		next, err := c.poll(ctx, prefix)
+		if err != nil {
+			level.Warn(c.log).Log("msg", "failed to poll bucket for changes", "err", err)
+			continue
+		}
+
+		for key, attrs := range next {
+			if old, ok := prev[key]; ok && old.Size == attrs.Size && old.LastModified.Equal(attrs.LastModified) {
+				continue
+			}
+			v, err := c.Get(ctx, key)
+			if err != nil {
+				level.Warn(c.log).Log("msg", "failed to get changed object from bucket", "key", key, "err", err)
+				continue
+			}
+			if !f(key, v) {
+				return
+			}
+		}
+		for key := range prev {
+			if _, ok := next[key]; ok {
+				continue
+			}
+			if !f(key, nil) {
+				return
+			}
+		}
+
+		prev = next
+	}
+}
+
+// poll returns the attributes of the objects of keys starting with prefix.
+func (c *bucketClient) poll(ctx context.Context, prefix string) (map[string]objstore.ObjectAttributes, error) {
+	keys, err := c.List(ctx, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	res := make(map[string]objstore.ObjectAttributes, len(keys))
+	for _, key := range keys {
+		attrs, err := c.bkt.Attributes(ctx, c.prefix+key)
+		if c.bkt.IsObjNotFoundErr(err) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to get attributes of %s: %w", key, err)
+		}
+		res[key] = attrs
+	}
+	return res, nil
+}
@@ -0,0 +1,100 @@
+package configstore
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
+)
+
+func TestBucketClient(t *testing.T) {
+	var (
+		ctx = context.Background()
+		bkt = objstore.NewInMemBucket()
+		cli = newBucketClient(log.NewNopLogger(), bkt, GetCodec(), "configs/", time.Hour)
+	)
+
+	for _, key := range []string{"a", "b", "nested/c"} {
+		err := cli.CAS(ctx, key, func(in interface{}) (out interface{}, retry bool, err error) {
+			require.Nil(t, in)
+			return "name: " + key, false, nil
+		})
+		require.NoError(t, err)
+	}
+
+	// Objects outside of the prefix are ignored.
+	require.NoError(t, bkt.Upload(ctx, "other/d", strings.NewReader("")))
+
+	keys, err := cli.List(ctx, "")
+	require.NoError(t, err)
+	sort.Strings(keys)
+	require.Equal(t, []string{"a", "b", "nested/c"}, keys)
+
+	v, err := cli.Get(ctx, "nested/c")
+	require.NoError(t, err)
+	require.Equal(t, "name: nested/c", v)
+
+	require.NoError(t, cli.Delete(ctx, "b"))
+	v, err = cli.Get(ctx, "b")
+	require.NoError(t, err)
+	require.Nil(t, v)
+
+	// Deleting a missing key isn't an error.
+	require.NoError(t, cli.Delete(ctx, "b"))
+}
+
+func TestBucketClient_WatchPrefix(t *testing.T) {
+	var (
+		bkt = objstore.NewInMemBucket()
+		cli = newBucketClient(log.NewNopLogger(), bkt, GetCodec(), "configs/", 10*time.Millisecond)
+	)
+
+	put := func(key, value string) {
+		err := cli.CAS(context.Background(), key, func(interface{}) (interface{}, bool, error) {
+			return value, false, nil
+		})
+		require.NoError(t, err)
+	}
+	put("existing", "name: existing")
+
+	type event struct {
+		key   string
+		value interface{}
+	}
+	events := make(chan event, 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go cli.WatchPrefix(ctx, "", func(key string, value interface{}) bool {
+		events <- event{key: key, value: value}
+		return true
+	})
+
+	expect := func(key string, value interface{}) {
+		t.Helper()
+		select {
+		case ev := <-events:
+			require.Equal(t, event{key: key, value: value}, ev)
+		case <-time.After(3 * time.Second):
+			require.FailNow(t, "timed out waiting for event", "key %s", key)
+		}
+	}
+
+	// Objects which existed before watching started aren't reported, so give
+	// the watcher time to see them before making changes.
+	time.Sleep(100 * time.Millisecond)
+
+	put("new", "name: new")
+	expect("new", "name: new")
+
+	put("existing", "name: existing\nscrape_configs: []")
+	expect("existing", "name: existing\nscrape_configs: []")
+
+	require.NoError(t, cli.Delete(context.Background(), "new"))
+	expect("new", nil)
+}
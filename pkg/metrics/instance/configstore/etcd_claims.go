@@ -0,0 +1,157 @@
+package configstore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/kv/etcd"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+)
+
+// etcdClaims claims configs in etcd with keys attached to a lease. The lease
+// is kept alive for as long as the agent runs, so the claims of an agent
+// which crashes or gets partitioned from etcd expire after the lease TTL and
+// its configs can be picked up by other agents.
+type etcdClaims struct {
+	log    log.Logger
+	cli    *clientv3.Client
+	prefix string
+	ttl    time.Duration
+
+	mut     sync.Mutex
+	session *concurrency.Session
+}
+
+func newEtcdClaims(l log.Logger, cfg etcd.Config, prefix string, ttl time.Duration) (*etcdClaims, error) {
+	tlsConfig, err := cfg.GetTLS()
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialise TLS configuration for etcd: %w", err)
+	}
+	cli, err := clientv3.New(clientv3.Config{
+		Endpoints:   cfg.Endpoints,
+		DialTimeout: cfg.DialTimeout,
+		TLS:         tlsConfig,
+		Username:    cfg.UserName,
+		Password:    cfg.Password.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &etcdClaims{
+		log:    l,
+		cli:    cli,
+		prefix: prefix,
+		ttl:    ttl,
+	}, nil
+}
+
+// getSession returns the session holding the lease of claims, creating a new
+// one if the previous lease expired.
+func (c *etcdClaims) getSession() (*concurrency.Session, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if c.session != nil {
+		select {
+		case <-c.session.Done():
+			level.Warn(c.log).Log("msg", "etcd lease for config claims expired, claims will be retaken")
+			c.session = nil
+		default:
+			return c.session, nil
+		}
+	}
+
+	ttl := int(c.ttl.Seconds())
+	if ttl < 1 {
+		ttl = 1
+	}
+	session, err := concurrency.NewSession(c.cli, concurrency.WithTTL(ttl))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create etcd lease: %w", err)
+	}
+	c.session = session
+	return session, nil
+}
+
+// Claim claims key. It returns false if the key is claimed by another agent.
+func (c *etcdClaims) Claim(ctx context.Context, key string) (bool, error) {
+	session, err := c.getSession()
+	if err != nil {
+		return false, err
+	}
+
+	hostname, _ := os.Hostname()
+	claimKey := c.prefix + key
+
+	resp, err := c.cli.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(claimKey), "=", 0)).
+		Then(clientv3.OpPut(claimKey, hostname, clientv3.WithLease(session.Lease()))).
+		Else(clientv3.OpGet(claimKey)).
+		Commit()
+	if err != nil {
+		return false, err
+	} else if resp.Succeeded {
+		return true, nil
+	}
+
+	// The key was already claimed, possibly by us.
+	for _, kv := range resp.Responses[0].GetResponseRange().Kvs {
+		if clientv3.LeaseID(kv.Lease) == session.Lease() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Release releases a claim on key held by this agent.
+func (c *etcdClaims) Release(ctx context.Context, key string) error {
+	session, err := c.getSession()
+	if err != nil {
+		return err
+	}
+
+	claimKey := c.prefix + key
+	_, err = c.cli.Txn(ctx).
+		If(clientv3.Compare(clientv3.LeaseValue(claimKey), "=", session.Lease())).
+		Then(clientv3.OpDelete(claimKey)).
+		Commit()
+	return err
+}
+
+// WatchReleases calls f with the key of each claim which is released or
+// expires, until ctx is canceled.
+func (c *etcdClaims) WatchReleases(ctx context.Context, f func(key string)) {
+	for wresp := range c.cli.Watch(ctx, c.prefix, clientv3.WithPrefix(), clientv3.WithFilterPut()) {
+		if err := wresp.Err(); err != nil {
+			level.Warn(c.log).Log("msg", "error watching etcd config claims", "err", err)
+			continue
+		}
+		for _, ev := range wresp.Events {
+			f(strings.TrimPrefix(string(ev.Kv.Key), c.prefix))
+		}
+	}
+}
+
+// Close revokes the lease of the claims, releasing all of them.
+func (c *etcdClaims) Close() error {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	var firstErr error
+	if c.session != nil {
+		firstErr = c.session.Close()
+		c.session = nil
+	}
+	if err := c.cli.Close(); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	return firstErr
+}
@@ -3,10 +3,12 @@ package configstore
 import (
 	"context"
 	"errors"
+	"flag"
 	"fmt"
 	"net/http"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/weaveworks/common/instrument"
 
@@ -35,6 +37,39 @@ var consulRequestDuration = instrument.NewHistogramCollector(promauto.NewHistogr
 	Buckets: prometheus.DefBuckets,
 }, []string{"operation", "status_code"}))
 
+// Supported values for RemoteConfig.Store in addition to the stores of
+// kv.Config.
+const (
+	// StoreBucket stores configs in object storage.
+	StoreBucket = "bucket"
+)
+
+// RemoteConfig configures the store used by Remote. In addition to the stores
+// supported by kv.Config, configs can be stored in object storage.
+type RemoteConfig struct {
+	kv.Config `yaml:",inline"`
+
+	// Bucket configures the object storage used when Store is "bucket".
+	Bucket BucketConfig `yaml:"bucket"`
+	// BucketPollInterval is how often object storage is polled for changed
+	// configs when Store is "bucket".
+	BucketPollInterval time.Duration `yaml:"bucket_poll_interval"`
+
+	// EtcdLeaseTTL enables claiming configs with etcd leases when Store is
+	// "etcd". Agents only run configs they claimed, and the claims of an agent
+	// expire after EtcdLeaseTTL when it stops renewing its lease.
+	EtcdLeaseTTL time.Duration `yaml:"etcd_lease_ttl"`
+}
+
+// RegisterFlagsWithPrefix adds the flags required to config this to the given
+// FlagSet. See kv.Config.RegisterFlagsWithPrefix for the meaning of the
+// prefixes.
+func (c *RemoteConfig) RegisterFlagsWithPrefix(flagsPrefix, defaultPrefix string, f *flag.FlagSet) {
+	c.Config.RegisterFlagsWithPrefix(flagsPrefix, defaultPrefix, f)
+	f.DurationVar(&c.BucketPollInterval, flagsPrefix+"bucket.poll-interval", 30*time.Second, "How often to poll object storage for changed configs.")
+	f.DurationVar(&c.EtcdLeaseTTL, flagsPrefix+"etcd.lease-ttl", 0, "TTL of the etcd lease used to claim configs. 0 disables claims.")
+}
+
 // Remote loads instance files from a remote KV store. The KV store
 // can be swapped out in real time.
 type Remote struct {
@@ -57,14 +92,15 @@ type Remote struct {
 type agentRemoteClient struct {
 	kv.Client
 	consul *api.Client
-	config kv.Config
+	claims *etcdClaims
+	config RemoteConfig
 }
 
 // NewRemote creates a new Remote store that uses a Key-Value client to store
 // and retrieve configs. If enable is true, the store will be immediately
 // connected to. Otherwise, it can be lazily loaded by enabling later through
 // a call to Remote.ApplyConfig.
-func NewRemote(l log.Logger, reg prometheus.Registerer, cfg kv.Config, enable bool) (*Remote, error) {
+func NewRemote(l log.Logger, reg prometheus.Registerer, cfg RemoteConfig, enable bool) (*Remote, error) {
 	cancelCtx, cancelFunc := context.WithCancel(context.Background())
 
 	r := &Remote{
@@ -87,7 +123,7 @@ func NewRemote(l log.Logger, reg prometheus.Registerer, cfg kv.Config, enable bo
 }
 
 // ApplyConfig applies the config for a kv client.
-func (r *Remote) ApplyConfig(cfg kv.Config, enable bool) error {
+func (r *Remote) ApplyConfig(cfg RemoteConfig, enable bool) error {
 	r.kvMut.Lock()
 	defer r.kvMut.Unlock()
 
@@ -99,11 +135,20 @@ func (r *Remote) ApplyConfig(cfg kv.Config, enable bool) error {
 	r.reg.UnregisterAll()
 
 	if !enable {
-		r.setClient(nil, nil, kv.Config{})
+		r.setClient(nil, nil, nil, RemoteConfig{})
+		return nil
+	}
+
+	if cfg.Store == StoreBucket {
+		bkt, err := newBucket(context.Background(), r.log, r.reg, cfg.Bucket)
+		if err != nil {
+			return fmt.Errorf("failed to create bucket client: %w", err)
+		}
+		r.setClient(newBucketClient(r.log, bkt, GetCodec(), cfg.Prefix, cfg.BucketPollInterval), nil, nil, cfg)
 		return nil
 	}
 
-	cli, err := kv.NewClient(cfg, GetCodec(), kv.RegistererWithKVName(r.reg, "agent_configs"), r.log)
+	cli, err := kv.NewClient(cfg.Config, GetCodec(), kv.RegistererWithKVName(r.reg, "agent_configs"), r.log)
 	// This is a hack to get a consul client, the client above has it embedded but its not exposed
 	var consulClient *api.Client
 	if cfg.Store == "consul" {
@@ -126,19 +171,36 @@ func (r *Remote) ApplyConfig(cfg kv.Config, enable bool) error {
 		return fmt.Errorf("failed to create kv client: %w", err)
 	}
 
-	r.setClient(cli, consulClient, cfg)
+	// Claims are stored next to the configs rather than under their prefix
+	// so that they aren't listed as configs.
+	var claims *etcdClaims
+	if cfg.Store == "etcd" && cfg.EtcdLeaseTTL > 0 {
+		claims, err = newEtcdClaims(r.log, cfg.Etcd, "claims/"+cfg.Prefix, cfg.EtcdLeaseTTL)
+		if err != nil {
+			return fmt.Errorf("failed to create etcd claims client: %w", err)
+		}
+	}
+
+	r.setClient(cli, consulClient, claims, cfg)
 	return nil
 }
 
 // setClient sets the active client and notifies run to restart the
 // kv watcher.
-func (r *Remote) setClient(client kv.Client, consulClient *api.Client, config kv.Config) {
+func (r *Remote) setClient(client kv.Client, consulClient *api.Client, claims *etcdClaims, config RemoteConfig) {
+	if r.kv != nil && r.kv.claims != nil {
+		if err := r.kv.claims.Close(); err != nil {
+			level.Warn(r.log).Log("msg", "failed to release etcd config claims", "err", err)
+		}
+	}
+
 	if client == nil && consulClient == nil {
 		r.kv = nil
 	} else {
 		r.kv = &agentRemoteClient{
 			Client: client,
 			consul: consulClient,
+			claims: claims,
 			config: config,
 		}
 	}
@@ -166,6 +228,9 @@ Outer:
 			}
 			kvContext, kvCancel = context.WithCancel(r.cancelCtx)
 			go r.watchKV(kvContext, kv)
+			if kv != nil && kv.claims != nil {
+				go r.watchClaims(kvContext, kv)
+			}
 		}
 	}
 
@@ -206,6 +271,47 @@ func (r *Remote) watchKV(ctx context.Context, client *agentRemoteClient) {
 	})
 }
 
+// watchClaims informs watchers of configs whose claim was released, so that
+// an agent waiting for the claim can take it.
+func (r *Remote) watchClaims(ctx context.Context, client *agentRemoteClient) {
+	client.claims.WatchReleases(ctx, func(key string) {
+		cfg, err := r.Get(ctx, key)
+		if err != nil {
+			level.Debug(r.log).Log("msg", "not announcing released claim of config", "name", key, "err", err)
+			return
+		}
+
+		r.configsMut.Lock()
+		defer r.configsMut.Unlock()
+
+		select {
+		case <-ctx.Done():
+		case r.configsCh <- WatchEvent{Key: key, Config: &cfg}:
+		}
+	})
+}
+
+// Claim claims key for this agent, returning false if another agent holds
+// the claim. It always succeeds unless claims are enabled.
+func (r *Remote) Claim(ctx context.Context, key string) (bool, error) {
+	r.kvMut.RLock()
+	defer r.kvMut.RUnlock()
+	if r.kv == nil || r.kv.claims == nil {
+		return true, nil
+	}
+	return r.kv.claims.Claim(ctx, key)
+}
+
+// Release releases the claim of this agent on key.
+func (r *Remote) Release(ctx context.Context, key string) error {
+	r.kvMut.RLock()
+	defer r.kvMut.RUnlock()
+	if r.kv == nil || r.kv.claims == nil {
+		return nil
+	}
+	return r.kv.claims.Release(ctx, key)
+}
+
 // List returns the list of all configs in the KV store.
 func (r *Remote) List(ctx context.Context) ([]string, error) {
 	r.kvMut.RLock()
@@ -466,5 +572,11 @@ func (r *Remote) Close() error {
 	r.kvMut.Lock()
 	defer r.kvMut.Unlock()
 	r.cancelFunc()
+
+	// Revoking the lease of claims releases them right away rather than
+	// after the lease expires.
+	if r.kv != nil && r.kv.claims != nil {
+		return r.kv.claims.Close()
+	}
 	return nil
 }
@@ -17,10 +17,10 @@ import (
 )
 
 func TestRemote_List(t *testing.T) {
-	remote, err := NewRemote(log.NewNopLogger(), prometheus.NewRegistry(), kv.Config{
+	remote, err := NewRemote(log.NewNopLogger(), prometheus.NewRegistry(), RemoteConfig{Config: kv.Config{
 		Store:  "inmemory",
 		Prefix: "configs/",
-	}, true)
+	}}, true)
 	require.NoError(t, err)
 	t.Cleanup(func() {
 		err := remote.Close()
@@ -42,10 +42,10 @@ func TestRemote_List(t *testing.T) {
 }
 
 func TestRemote_Get(t *testing.T) {
-	remote, err := NewRemote(log.NewNopLogger(), prometheus.NewRegistry(), kv.Config{
+	remote, err := NewRemote(log.NewNopLogger(), prometheus.NewRegistry(), RemoteConfig{Config: kv.Config{
 		Store:  "inmemory",
 		Prefix: "configs/",
-	}, true)
+	}}, true)
 	require.NoError(t, err)
 	t.Cleanup(func() {
 		err := remote.Close()
@@ -66,10 +66,10 @@ func TestRemote_Get(t *testing.T) {
 }
 
 func TestRemote_Put(t *testing.T) {
-	remote, err := NewRemote(log.NewNopLogger(), prometheus.NewRegistry(), kv.Config{
+	remote, err := NewRemote(log.NewNopLogger(), prometheus.NewRegistry(), RemoteConfig{Config: kv.Config{
 		Store:  "inmemory",
 		Prefix: "configs/",
-	}, true)
+	}}, true)
 	require.NoError(t, err)
 	t.Cleanup(func() {
 		err := remote.Close()
@@ -119,10 +119,10 @@ scrape_configs:
 	conflictingBCfg, err := instance.UnmarshalConfig(strings.NewReader(conflictingB))
 	require.NoError(t, err)
 
-	remote, err := NewRemote(log.NewNopLogger(), prometheus.NewRegistry(), kv.Config{
+	remote, err := NewRemote(log.NewNopLogger(), prometheus.NewRegistry(), RemoteConfig{Config: kv.Config{
 		Store:  "inmemory",
 		Prefix: "configs/",
-	}, true)
+	}}, true)
 	require.NoError(t, err)
 	t.Cleanup(func() {
 		err := remote.Close()
@@ -138,10 +138,10 @@ scrape_configs:
 }
 
 func TestRemote_Delete(t *testing.T) {
-	remote, err := NewRemote(log.NewNopLogger(), prometheus.NewRegistry(), kv.Config{
+	remote, err := NewRemote(log.NewNopLogger(), prometheus.NewRegistry(), RemoteConfig{Config: kv.Config{
 		Store:  "inmemory",
 		Prefix: "configs/",
-	}, true)
+	}}, true)
 	require.NoError(t, err)
 	t.Cleanup(func() {
 		err := remote.Close()
@@ -166,10 +166,10 @@ func TestRemote_Delete(t *testing.T) {
 }
 
 func TestRemote_All(t *testing.T) {
-	remote, err := NewRemote(log.NewNopLogger(), prometheus.NewRegistry(), kv.Config{
+	remote, err := NewRemote(log.NewNopLogger(), prometheus.NewRegistry(), RemoteConfig{Config: kv.Config{
 		Store:  "inmemory",
 		Prefix: "all-configs/",
-	}, true)
+	}}, true)
 	require.NoError(t, err)
 	t.Cleanup(func() {
 		err := remote.Close()
@@ -197,10 +197,10 @@ func TestRemote_All(t *testing.T) {
 }
 
 func TestRemote_Watch(t *testing.T) {
-	remote, err := NewRemote(log.NewNopLogger(), prometheus.NewRegistry(), kv.Config{
+	remote, err := NewRemote(log.NewNopLogger(), prometheus.NewRegistry(), RemoteConfig{Config: kv.Config{
 		Store:  "inmemory",
 		Prefix: "watch-configs/",
-	}, true)
+	}}, true)
 	require.NoError(t, err)
 	t.Cleanup(func() {
 		err := remote.Close()
@@ -234,26 +234,26 @@ func TestRemote_Watch(t *testing.T) {
 }
 
 func TestRemote_ApplyConfig(t *testing.T) {
-	remote, err := NewRemote(log.NewNopLogger(), prometheus.NewRegistry(), kv.Config{
+	remote, err := NewRemote(log.NewNopLogger(), prometheus.NewRegistry(), RemoteConfig{Config: kv.Config{
 		Store:  "inmemory",
 		Prefix: "test-applyconfig/",
-	}, true)
+	}}, true)
 	require.NoError(t, err)
 	t.Cleanup(func() {
 		err := remote.Close()
 		require.NoError(t, err)
 	})
 
-	err = remote.ApplyConfig(kv.Config{
+	err = remote.ApplyConfig(RemoteConfig{Config: kv.Config{
 		Store:  "inmemory",
 		Prefix: "test-applyconfig2/",
-	}, true)
+	}}, true)
 	require.NoError(t, err, "failed to apply a new config")
 
-	err = remote.ApplyConfig(kv.Config{
+	err = remote.ApplyConfig(RemoteConfig{Config: kv.Config{
 		Store:  "inmemory",
 		Prefix: "test-applyconfig2/",
-	}, true)
+	}}, true)
 	require.NoError(t, err, "failed to re-apply the current config")
 
 	// Make sure watch still works
@@ -41,6 +41,18 @@ type Store interface {
 	Close() error
 }
 
+// Claimer is implemented by stores which keep track of the agent running
+// each config, so that a config isn't run by two agents at once while it
+// moves between them.
+type Claimer interface {
+	// Claim claims key for the caller. It returns false if key is claimed by
+	// someone else.
+	Claim(ctx context.Context, key string) (bool, error)
+
+	// Release releases the claim of the caller on key.
+	Release(ctx context.Context, key string) error
+}
+
 // WatchEvent is returned by Watch. The Key is the name of the config that was
 // added, updated, or deleted. If the Config was deleted, Config will be nil.
 type WatchEvent struct {
@@ -330,9 +330,11 @@ func groupConfigs(groupName string, grouped groupedConfigs) (Config, error) {
 	// Assign all remote_write configs in the group a consistent set of remote_names.
 	// If the grouped configs are coming from the scraping service, defaults will have
 	// been applied and the remote names will be prefixed with the old instance config name.
+	renamed := make(map[string]string, len(combined.RemoteWrite))
 	for _, rwc := range combined.RemoteWrite {
 		// Blank out the existing name before getting the hash so it is doesn't take into
 		// account any existing name.
+		oldName := rwc.Name
 		rwc.Name = ""
 
 		hash, err := getHash(rwc)
@@ -341,6 +343,22 @@ func groupConfigs(groupName string, grouped groupedConfigs) (Config, error) {
 		}
 
 		rwc.Name = groupName[:6] + "-" + hash[:6]
+		renamed[oldName] = rwc.Name
+	}
+
+	// remote_write_failover and remote_write_retry refer to remote_write
+	// configs by name, so they have to follow the renames.
+	if fc := combined.RemoteWriteFailover; fc != nil {
+		for i, name := range fc.Endpoints {
+			if newName, ok := renamed[name]; ok {
+				fc.Endpoints[i] = newName
+			}
+		}
+	}
+	for _, rc := range combined.RemoteWriteRetry {
+		if newName, ok := renamed[rc.Endpoint]; ok {
+			rc.Endpoint = newName
+		}
 	}
 
 	// Combine all the scrape configs. It's possible that two different ungrouped
@@ -196,6 +196,32 @@ remote_write:
 	require.NotEqual(t, "rw-cfg-a", cfg.RemoteWrite[0].Name)
 }
 
+func TestGroupManager_ApplyConfig_RemoteWriteFailover(t *testing.T) {
+	inner := newFakeManager()
+	gm := NewGroupManager(inner)
+	err := gm.ApplyConfig(testUnmarshalConfig(t, `
+name: configA
+scrape_configs: []
+remote_write:
+- name: primary
+  url: http://localhost:9009/api/prom/push
+- name: secondary
+  url: http://localhost:9010/api/prom/push
+remote_write_failover:
+  endpoints: [secondary, primary]
+remote_write_retry:
+  - endpoint: primary
+`))
+	require.NoError(t, err)
+
+	// The failover and retry endpoints should follow the remote_write configs
+	// being renamed.
+	cfg := inner.ListConfigs()[gm.groupLookup["configA"]]
+	require.Equal(t, []string{cfg.RemoteWrite[1].Name, cfg.RemoteWrite[0].Name}, cfg.RemoteWriteFailover.Endpoints)
+	require.Equal(t, cfg.RemoteWrite[0].Name, cfg.RemoteWriteRetry[0].Endpoint)
+	require.NotEqual(t, "secondary", cfg.RemoteWrite[1].Name)
+}
+
 func TestGroupManager_DeleteConfig(t *testing.T) {
 	t.Run("partial delete", func(t *testing.T) {
 		inner := newFakeManager()
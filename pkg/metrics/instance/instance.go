@@ -12,12 +12,14 @@ import (
 	"math"
 	"os"
 	"path/filepath"
+	"reflect"
 	"sync"
 	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/grafana/agent/pkg/build"
+	"github.com/grafana/agent/pkg/limits"
 	"github.com/grafana/agent/pkg/metrics/wal"
 	"github.com/grafana/agent/pkg/util"
 	"github.com/oklog/run"
@@ -63,6 +65,10 @@ type Config struct {
 	ScrapeConfigs            []*config.ScrapeConfig      `yaml:"scrape_configs,omitempty"`
 	RemoteWrite              []*config.RemoteWriteConfig `yaml:"remote_write,omitempty"`
 
+	// Extracts labels from metric names of scraped series before they are
+	// written to the WAL.
+	MetricNameExtractConfigs []*MetricNameExtractConfig `yaml:"metric_name_extract_configs,omitempty"`
+
 	// How frequently the WAL should be truncated.
 	WALTruncateFrequency time.Duration `yaml:"wal_truncate_frequency,omitempty"`
 
@@ -70,9 +76,30 @@ type Config struct {
 	MinWALTime time.Duration `yaml:"min_wal_time,omitempty"`
 	MaxWALTime time.Duration `yaml:"max_wal_time,omitempty"`
 
+	// Exports samples to local files before they are removed by WAL
+	// truncation.
+	WALExport *wal.ExportConfig `yaml:"wal_export,omitempty"`
+
+	// Periodically verifies the checksums of WAL records and reports where
+	// corrupt records were found.
+	WALVerify *wal.VerifyConfig `yaml:"wal_verify,omitempty"`
+
+	// Keeps samples in the WAL until they're sent by all remote_write
+	// endpoints, up to a maximum WAL size. max_wal_time is ignored when set.
+	GuaranteedDelivery *wal.DeliveryConfig `yaml:"guaranteed_delivery,omitempty"`
+
 	RemoteFlushDeadline  time.Duration `yaml:"remote_flush_deadline,omitempty"`
 	WriteStaleOnShutdown bool          `yaml:"write_stale_on_shutdown,omitempty"`
 
+	// Lengthens scrape intervals while the agent is under resource pressure.
+	AdaptiveScrape *AdaptiveScrapeConfig `yaml:"adaptive_scrape,omitempty"`
+
+	// Fails over between remote_write endpoints when they fall behind.
+	RemoteWriteFailover *RemoteWriteFailoverConfig `yaml:"remote_write_failover,omitempty"`
+
+	// Retries or drops failed requests of remote_write endpoints.
+	RemoteWriteRetry []*RemoteWriteRetryConfig `yaml:"remote_write_retry,omitempty"`
+
 	global GlobalConfig `yaml:"-"`
 }
 
@@ -153,6 +180,21 @@ func (c *Config) ApplyDefaults(global GlobalConfig) error {
 		jobNames[sc.JobName] = struct{}{}
 	}
 
+	for i, nc := range c.MetricNameExtractConfigs {
+		if nc == nil {
+			return fmt.Errorf("empty or null metric_name_extract_configs section")
+		}
+		if err := nc.Validate(); err != nil {
+			return fmt.Errorf("invalid metric_name_extract_configs entry %d: %w", i, err)
+		}
+	}
+
+	if c.AdaptiveScrape != nil {
+		if err := c.AdaptiveScrape.Validate(c); err != nil {
+			return fmt.Errorf("invalid adaptive_scrape config: %w", err)
+		}
+	}
+
 	rwNames := map[string]struct{}{}
 
 	// If the instance remote write is not filled in, then apply the prometheus write config
@@ -190,6 +232,15 @@ func (c *Config) ApplyDefaults(global GlobalConfig) error {
 		rwNames[cfg.Name] = struct{}{}
 	}
 
+	if c.RemoteWriteFailover != nil {
+		if err := c.RemoteWriteFailover.Validate(c); err != nil {
+			return fmt.Errorf("invalid remote_write_failover config: %w", err)
+		}
+	}
+	if err := validateRemoteWriteRetry(c); err != nil {
+		return fmt.Errorf("invalid remote_write_retry config: %w", err)
+	}
+
 	return nil
 }
 
@@ -234,32 +285,76 @@ type Instance struct {
 	readyScrapeManager *readyScrapeManager
 	remoteStore        *remote.Storage
 	storage            storage.Storage
+	nameExtract        *nameExtractAppendable
 
 	// ready is set to true after the initialization process finishes
 	ready atomic.Bool
 
 	hostFilter *HostFilter
 
+	// scrapeIntervalFactor is the factor which scrape intervals are currently
+	// lengthened by because of resource pressure.
+	scrapeIntervalFactor float64
+
+	// scrapeErrors records recent scrape errors of targets. It's kept across
+	// runs of the instance.
+	scrapeErrors *scrapeErrorLog
+
+	// rwProgress tracks how far remote_write endpoints have sent, and
+	// openCircuits holds the endpoints listed in remote_write_failover which
+	// fell behind.
+	rwProgress   *remoteWriteProgress
+	openCircuits map[string]bool
+
+	// rwRetry applies remote_write_retry policies.
+	rwRetry *remoteWriteRetry
+
+	// shardOverrides holds runtime overrides of the shard bounds of
+	// remote_write queues. It's kept across runs of the instance.
+	shardOverrides map[string]ShardOverride
+
 	logger log.Logger
 
 	reg    prometheus.Registerer
 	newWal walStorageFactory
 }
 
-// New creates a new Instance with a directory for storing the WAL. The instance
-// will not start until Run is called on the instance.
-func New(reg prometheus.Registerer, cfg Config, walDir string, logger log.Logger) (*Instance, error) {
+// New creates a new Instance with a directory for storing the WAL. New series
+// are admitted by limiter, which may be nil. The instance will not start until
+// Run is called on the instance.
+func New(reg prometheus.Registerer, cfg Config, walDir string, logger log.Logger, limiter *limits.Limiter) (*Instance, error) {
 	logger = log.With(logger, "instance", cfg.Name)
 
 	instWALDir := filepath.Join(walDir, cfg.Name)
 
 	newWal := func(reg prometheus.Registerer) (walStorage, error) {
-		return wal.NewStorage(logger, reg, instWALDir)
+		return wal.NewStorageWithOptions(logger, reg, instWALDir, wal.Options{
+			Export:   cfg.WALExport,
+			Verify:   cfg.WALVerify,
+			Delivery: cfg.GuaranteedDelivery,
+
+			AdmitSeries: admitSeries(limiter, cfg.Name),
+		})
 	}
 
 	return newInstance(cfg, reg, logger, newWal)
 }
 
+// admitSeries returns a hook for wal.Options.AdmitSeries enforcing the series
+// limit of tenant.
+func admitSeries(limiter *limits.Limiter, tenant string) func(active int) (bool, error) {
+	if limiter == nil {
+		return nil
+	}
+	return func(active int) (bool, error) {
+		err := limiter.AdmitSeries(tenant, active)
+		if limits.Rejected(err) {
+			return false, err
+		}
+		return err == nil, nil
+	}
+}
+
 func newInstance(cfg Config, reg prometheus.Registerer, logger log.Logger, newWal walStorageFactory) (*Instance, error) {
 	hostname, err := Hostname()
 	if err != nil {
@@ -271,6 +366,9 @@ func newInstance(cfg Config, reg prometheus.Registerer, logger log.Logger, newWa
 		logger:     logger,
 		hostFilter: NewHostFilter(hostname, cfg.HostFilterRelabelConfigs),
 
+		scrapeIntervalFactor: 1,
+		scrapeErrors:         newScrapeErrorLog(),
+
 		reg:    reg,
 		newWal: newWal,
 
@@ -338,6 +436,48 @@ func (i *Instance) Run(ctx context.Context) error {
 			},
 		)
 	}
+	if cfg.WALVerify != nil {
+		// WAL verification loop
+		ctx, contextCancel := context.WithCancel(context.Background())
+		defer contextCancel()
+		rg.Add(
+			func() error {
+				i.verifyLoop(ctx, i.wal, cfg.WALVerify)
+				return nil
+			},
+			func(err error) {
+				contextCancel()
+			},
+		)
+	}
+	{
+		// Adaptive scrape controller
+		ctx, contextCancel := context.WithCancel(context.Background())
+		defer contextCancel()
+		rg.Add(
+			func() error {
+				i.adaptiveScrapeLoop(ctx, trackingReg)
+				return nil
+			},
+			func(err error) {
+				contextCancel()
+			},
+		)
+	}
+	{
+		// Remote write failover
+		ctx, contextCancel := context.WithCancel(context.Background())
+		defer contextCancel()
+		rg.Add(
+			func() error {
+				i.remoteWriteFailoverLoop(ctx, trackingReg)
+				return nil
+			},
+			func(err error) {
+				contextCancel()
+			},
+		)
+	}
 	{
 		sm, err := i.readyScrapeManager.Get()
 		if err != nil {
@@ -413,23 +553,28 @@ func (i *Instance) initialize(ctx context.Context, reg prometheus.Registerer, cf
 
 	i.readyScrapeManager = &readyScrapeManager{}
 
-	// Setup the remote storage
+	// Setup the remote storage. Circuits start closed every time the instance
+	// runs.
 	remoteLogger := log.With(i.logger, "component", "remote")
-	i.remoteStore = remote.NewStorage(remoteLogger, reg, i.wal.StartTime, i.wal.Directory(), cfg.RemoteFlushDeadline, i.readyScrapeManager)
-	err = i.remoteStore.ApplyConfig(&config.Config{
-		GlobalConfig:       cfg.global.Prometheus,
-		RemoteWriteConfigs: cfg.RemoteWrite,
-	})
+	i.rwProgress = newRemoteWriteProgress(reg)
+	i.openCircuits = nil
+	i.rwRetry = newRemoteWriteRetry(remoteLogger, reg)
+	i.remoteStore = remote.NewStorage(remoteLogger, i.rwProgress, i.wal.StartTime, i.wal.Directory(), cfg.RemoteFlushDeadline, i.readyScrapeManager)
+	err = i.applyRemoteWrite(cfg)
 	if err != nil {
 		return fmt.Errorf("failed applying config to remote storage: %w", err)
 	}
 
 	i.storage = storage.NewFanout(i.logger, i.wal, i.remoteStore)
+	i.nameExtract = newNameExtractAppendable(i.storage, cfg.MetricNameExtractConfigs)
 
 	opts := &scrape.Options{
 		ExtraMetrics: cfg.global.ExtraMetrics,
 	}
-	scrapeManager := newScrapeManager(opts, log.With(i.logger, "component", "scrape manager"), i.storage)
+	scrapeLogger := i.scrapeErrors.Wrap(log.With(i.logger, "component", "scrape manager"))
+	scrapeManager := newScrapeManager(opts, scrapeLogger, i.nameExtract)
+	// Scrape intervals start unadapted every time the instance runs.
+	i.scrapeIntervalFactor = 1
 	err = scrapeManager.ApplyConfig(&config.Config{
 		GlobalConfig:  cfg.global.Prometheus,
 		ScrapeConfigs: cfg.ScrapeConfigs,
@@ -471,13 +616,19 @@ func (i *Instance) Update(c Config) (err error) {
 		err = errImmutableField{Field: "remote_flush_deadline"}
 	case i.cfg.WriteStaleOnShutdown != c.WriteStaleOnShutdown:
 		err = errImmutableField{Field: "write_stale_on_shutdown"}
+	case !reflect.DeepEqual(i.cfg.WALExport, c.WALExport):
+		err = errImmutableField{Field: "wal_export"}
+	case !reflect.DeepEqual(i.cfg.WALVerify, c.WALVerify):
+		err = errImmutableField{Field: "wal_verify"}
+	case !reflect.DeepEqual(i.cfg.GuaranteedDelivery, c.GuaranteedDelivery):
+		err = errImmutableField{Field: "guaranteed_delivery"}
 	}
 	if err != nil {
 		return ErrInvalidUpdate{Inner: err}
 	}
 
 	// Check to see if the components exist yet.
-	if i.discovery == nil || i.remoteStore == nil || i.readyScrapeManager == nil {
+	if i.discovery == nil || i.remoteStore == nil || i.readyScrapeManager == nil || i.nameExtract == nil {
 		return ErrInvalidUpdate{
 			Inner: fmt.Errorf("cannot dynamically update because instance is not running"),
 		}
@@ -508,21 +659,20 @@ func (i *Instance) Update(c Config) (err error) {
 		i.hostFilter.PatchSD(c.ScrapeConfigs)
 	}
 
-	err = i.remoteStore.ApplyConfig(&config.Config{
-		GlobalConfig:       c.global.Prometheus,
-		RemoteWriteConfigs: c.RemoteWrite,
-	})
+	err = i.applyRemoteWrite(&c)
 	if err != nil {
 		return fmt.Errorf("error applying new remote_write configs: %w", err)
 	}
 
+	i.nameExtract.SetConfigs(c.MetricNameExtractConfigs)
+
 	sm, err := i.readyScrapeManager.Get()
 	if err != nil {
 		return fmt.Errorf("couldn't get scrape manager to apply new scrape configs: %w", err)
 	}
 	err = sm.ApplyConfig(&config.Config{
 		GlobalConfig:  c.global.Prometheus,
-		ScrapeConfigs: c.ScrapeConfigs,
+		ScrapeConfigs: adaptScrapeConfigs(&c, i.scrapeIntervalFactor),
 	})
 	if err != nil {
 		return fmt.Errorf("error applying updated configs to scrape manager: %w", err)
@@ -566,6 +716,18 @@ func (i *Instance) StorageDirectory() string {
 	return i.wal.Directory()
 }
 
+// WALCorruptions returns the corrupt records found in the instance's WAL.
+// It always returns nil if wal_verify isn't configured.
+func (i *Instance) WALCorruptions() []wal.Corruption {
+	return i.wal.Corruptions()
+}
+
+// ScrapeErrors returns the most recent scrape errors of each target which
+// failed to be scraped within the last hour.
+func (i *Instance) ScrapeErrors() []TargetScrapeErrors {
+	return i.scrapeErrors.Errors()
+}
+
 // Appender returns a storage.Appender from the instance's WAL
 func (i *Instance) Appender(ctx context.Context) storage.Appender {
 	return i.wal.Appender(ctx)
@@ -644,6 +806,22 @@ func (i *Instance) newDiscoveryManager(ctx context.Context, cfg *Config) (*disco
 	}, nil
 }
 
+// verifyLoop verifies the segments of the WAL on an interval until ctx is
+// canceled.
+func (i *Instance) verifyLoop(ctx context.Context, wal walStorage, cfg *wal.VerifyConfig) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(cfg.Interval):
+			level.Debug(i.logger).Log("msg", "verifying the WAL")
+			if err := wal.Verify(); err != nil {
+				level.Warn(i.logger).Log("msg", "could not verify WAL", "err", err)
+			}
+		}
+	}
+}
+
 func (i *Instance) truncateLoop(ctx context.Context, wal walStorage, cfg *Config) {
 	// Track the last timestamp we truncated for to prevent segments from getting
 	// deleted until at least some new data has been sent.
@@ -670,7 +848,10 @@ func (i *Instance) truncateLoop(ctx context.Context, wal walStorage, cfg *Config
 			// changing. We don't want data in the WAL to grow forever, so we set a cap
 			// on the maximum age data can be. If our ts is older than this cutoff point,
 			// we'll shift it forward to start deleting very stale data.
-			if maxTS := timestamp.FromTime(time.Now().Add(-i.cfg.MaxWALTime)); ts < maxTS {
+			//
+			// With guaranteed delivery, the WAL keeps segments with samples newer
+			// than ts, and its size is bounded by the WAL instead.
+			if maxTS := timestamp.FromTime(time.Now().Add(-i.cfg.MaxWALTime)); ts < maxTS && cfg.GuaranteedDelivery == nil {
 				ts = maxTS
 			}
 
@@ -722,6 +903,9 @@ type walStorage interface {
 	Appender(context.Context) storage.Appender
 	Truncate(mint int64) error
 
+	Verify() error
+	Corruptions() []wal.Corruption
+
 	Close() error
 }
 
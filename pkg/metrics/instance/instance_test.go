@@ -14,6 +14,7 @@ import (
 
 	"github.com/cortexproject/cortex/pkg/util/test"
 	"github.com/go-kit/log"
+	"github.com/grafana/agent/pkg/metrics/wal"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/prometheus/common/model"
@@ -135,6 +136,18 @@ func TestConfig_ApplyDefaults_Validations(t *testing.T) {
 			},
 			fmt.Errorf("found duplicate remote write configs with name \"foo\""),
 		},
+		{
+			"empty metric name extract config",
+			func(c *Config) { c.MetricNameExtractConfigs = []*MetricNameExtractConfig{nil} },
+			fmt.Errorf("empty or null metric_name_extract_configs section"),
+		},
+		{
+			"metric name extract config without regex",
+			func(c *Config) {
+				c.MetricNameExtractConfigs = []*MetricNameExtractConfig{{Replacement: "foo"}}
+			},
+			fmt.Errorf("invalid metric_name_extract_configs entry 0: regex must be provided"),
+		},
 	}
 
 	for _, tc := range tt {
@@ -199,7 +212,7 @@ func TestInstance_Path(t *testing.T) {
 	cfg.RemoteFlushDeadline = time.Hour
 
 	logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
-	inst, err := New(prometheus.NewRegistry(), cfg, walDir, logger)
+	inst, err := New(prometheus.NewRegistry(), cfg, walDir, logger, nil)
 	require.NoError(t, err)
 	runInstance(t, inst)
 
@@ -263,7 +276,7 @@ func TestInstance_Recreate(t *testing.T) {
 	cfg.RemoteFlushDeadline = time.Hour
 
 	logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
-	inst, err := New(prometheus.NewRegistry(), cfg, walDir, logger)
+	inst, err := New(prometheus.NewRegistry(), cfg, walDir, logger, nil)
 	require.NoError(t, err)
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -283,7 +296,7 @@ func TestInstance_Recreate(t *testing.T) {
 
 	// Recreate the instance, no panic should happen.
 	require.NotPanics(t, func() {
-		inst, err := New(prometheus.NewRegistry(), cfg, walDir, logger)
+		inst, err := New(prometheus.NewRegistry(), cfg, walDir, logger, nil)
 		require.NoError(t, err)
 		runInstance(t, inst)
 
@@ -357,6 +370,8 @@ func (s *mockWalStorage) StartTime() (int64, error)                  { return 0,
 func (s *mockWalStorage) WriteStalenessMarkers(f func() int64) error { return nil }
 func (s *mockWalStorage) Close() error                               { return nil }
 func (s *mockWalStorage) Truncate(mint int64) error                  { return nil }
+func (s *mockWalStorage) Verify() error                              { return nil }
+func (s *mockWalStorage) Corruptions() []wal.Corruption              { return nil }
 
 func (s *mockWalStorage) Appender(context.Context) storage.Appender {
 	return &mockAppender{s: s}
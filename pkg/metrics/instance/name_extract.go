@@ -0,0 +1,125 @@
+package instance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/relabel"
+	"github.com/prometheus/prometheus/storage"
+)
+
+// MetricNameExtractConfig extracts labels from the names of scraped metrics.
+// It is intended for exporters which encode dimensions in metric names, such
+// as exposing api_v1_users_requests_total instead of
+// api_requests_total{version="v1",handler="users"}.
+type MetricNameExtractConfig struct {
+	// Regex is matched against the metric name. Only metrics whose name fully
+	// matches Regex are modified.
+	Regex relabel.Regexp `yaml:"regex"`
+	// Replacement is the new name of matching metrics. Capture groups of Regex
+	// may be referenced with $1 or ${name}. The metric name is left unchanged
+	// if Replacement is empty.
+	Replacement string `yaml:"replacement,omitempty"`
+	// Labels to add to matching metrics. Values may reference capture groups of
+	// Regex. Labels whose value expands to an empty string are removed.
+	Labels map[string]string `yaml:"labels,omitempty"`
+}
+
+// Validate returns an error if c is invalid.
+func (c *MetricNameExtractConfig) Validate() error {
+	if c.Regex.Regexp == nil {
+		return errors.New("regex must be provided")
+	}
+	if c.Replacement == "" && len(c.Labels) == 0 {
+		return errors.New("at least one of replacement or labels must be provided")
+	}
+	for name := range c.Labels {
+		if !model.LabelName(name).IsValid() || name == model.MetricNameLabel {
+			return fmt.Errorf("%q is not a valid label name", name)
+		}
+	}
+	return nil
+}
+
+// apply returns lset modified by c. ok is false if c doesn't apply to lset.
+func (c *MetricNameExtractConfig) apply(lset labels.Labels) (res labels.Labels, ok bool) {
+	name := lset.Get(model.MetricNameLabel)
+	match := c.Regex.FindStringSubmatchIndex(name)
+	if match == nil {
+		return lset, false
+	}
+
+	lb := labels.NewBuilder(lset)
+	if c.Replacement != "" {
+		newName := string(c.Regex.ExpandString(nil, c.Replacement, name, match))
+		if !model.IsValidMetricName(model.LabelValue(newName)) {
+			return lset, false
+		}
+		lb.Set(model.MetricNameLabel, newName)
+	}
+	for label, tmpl := range c.Labels {
+		lb.Set(label, string(c.Regex.ExpandString(nil, tmpl, name, match)))
+	}
+	return lb.Labels(), true
+}
+
+// nameExtractAppendable wraps a storage.Appendable and applies a set of
+// MetricNameExtractConfigs to series before they are appended. The first
+// config matching a series is applied.
+type nameExtractAppendable struct {
+	next storage.Appendable
+
+	mut     sync.RWMutex
+	configs []*MetricNameExtractConfig
+}
+
+func newNameExtractAppendable(next storage.Appendable, configs []*MetricNameExtractConfig) *nameExtractAppendable {
+	return &nameExtractAppendable{next: next, configs: configs}
+}
+
+// SetConfigs updates the set of configs used for future appenders.
+func (a *nameExtractAppendable) SetConfigs(configs []*MetricNameExtractConfig) {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	a.configs = configs
+}
+
+// Appender implements storage.Appendable.
+func (a *nameExtractAppendable) Appender(ctx context.Context) storage.Appender {
+	a.mut.RLock()
+	configs := a.configs
+	a.mut.RUnlock()
+
+	next := a.next.Appender(ctx)
+	if len(configs) == 0 {
+		return next
+	}
+	return &nameExtractAppender{Appender: next, configs: configs}
+}
+
+type nameExtractAppender struct {
+	storage.Appender
+	configs []*MetricNameExtractConfig
+}
+
+func (a *nameExtractAppender) Append(ref storage.SeriesRef, l labels.Labels, t int64, v float64) (storage.SeriesRef, error) {
+	return a.Appender.Append(ref, a.process(l), t, v)
+}
+
+func (a *nameExtractAppender) AppendExemplar(ref storage.SeriesRef, l labels.Labels, e exemplar.Exemplar) (storage.SeriesRef, error) {
+	return a.Appender.AppendExemplar(ref, a.process(l), e)
+}
+
+func (a *nameExtractAppender) process(l labels.Labels) labels.Labels {
+	for _, c := range a.configs {
+		if res, ok := c.apply(l); ok {
+			return res
+		}
+	}
+	return l
+}
@@ -0,0 +1,122 @@
+package instance
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestMetricNameExtractConfig_Unmarshal(t *testing.T) {
+	cfgText := `
+regex: api_(?P<version>v\d+)_(\w+)_requests_total
+replacement: api_requests_total
+labels:
+  version: ${version}
+  handler: $2
+`
+	var cfg MetricNameExtractConfig
+	require.NoError(t, yaml.UnmarshalStrict([]byte(cfgText), &cfg))
+	require.NoError(t, cfg.Validate())
+
+	res, ok := cfg.apply(labels.FromStrings("__name__", "api_v1_users_requests_total", "job", "api"))
+	require.True(t, ok)
+	require.Equal(t, labels.FromStrings(
+		"__name__", "api_requests_total",
+		"handler", "users",
+		"job", "api",
+		"version", "v1",
+	), res)
+
+	// The regex is anchored and doesn't match partial names.
+	_, ok = cfg.apply(labels.FromStrings("__name__", "legacy_api_v1_users_requests_total"))
+	require.False(t, ok)
+}
+
+func TestMetricNameExtractConfig_Validate(t *testing.T) {
+	tt := []struct {
+		name, cfg, err string
+	}{
+		{
+			name: "missing regex",
+			cfg:  `replacement: foo`,
+			err:  "regex must be provided",
+		},
+		{
+			name: "nothing to do",
+			cfg:  `regex: foo`,
+			err:  "at least one of replacement or labels must be provided",
+		},
+		{
+			name: "invalid label name",
+			cfg: `
+regex: foo_(.*)
+labels:
+  __name__: $1
+`,
+			err: `"__name__" is not a valid label name`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var cfg MetricNameExtractConfig
+			require.NoError(t, yaml.UnmarshalStrict([]byte(tc.cfg), &cfg))
+			require.EqualError(t, cfg.Validate(), tc.err)
+		})
+	}
+}
+
+func TestNameExtractAppendable(t *testing.T) {
+	var cfgs []*MetricNameExtractConfig
+	require.NoError(t, yaml.UnmarshalStrict([]byte(`
+- regex: node_cpu(\d+)_seconds_total
+  replacement: node_cpu_seconds_total
+  labels:
+    cpu: $1
+- regex: node_(.*)
+  labels:
+    subsystem: $1
+- regex: invalid_(.*)
+  replacement: $1
+  labels:
+    ignored: "true"
+`), &cfgs))
+
+	var appended []labels.Labels
+	next := appendableFunc(func(_ context.Context) storage.Appender {
+		return &collectingAppender{samples: &appended}
+	})
+
+	app := newNameExtractAppendable(next, cfgs).Appender(context.Background())
+	for _, name := range []string{"node_cpu0_seconds_total", "node_load1", "invalid_0", "up"} {
+		_, err := app.Append(0, labels.FromStrings("__name__", name), 0, 0)
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, []labels.Labels{
+		// Only the first matching config is applied.
+		labels.FromStrings("__name__", "node_cpu_seconds_total", "cpu", "0"),
+		labels.FromStrings("__name__", "node_load1", "subsystem", "load1"),
+		// Series are left unchanged if the replacement isn't a valid name.
+		labels.FromStrings("__name__", "invalid_0"),
+		labels.FromStrings("__name__", "up"),
+	}, appended)
+}
+
+type appendableFunc func(ctx context.Context) storage.Appender
+
+func (f appendableFunc) Appender(ctx context.Context) storage.Appender { return f(ctx) }
+
+type collectingAppender struct {
+	storage.Appender
+	samples *[]labels.Labels
+}
+
+func (a *collectingAppender) Append(ref storage.SeriesRef, l labels.Labels, _ int64, _ float64) (storage.SeriesRef, error) {
+	*a.samples = append(*a.samples, l)
+	return ref, nil
+}
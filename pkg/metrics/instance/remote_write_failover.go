@@ -0,0 +1,436 @@
+package instance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/prometheus/config"
+)
+
+// DefaultRemoteWriteFailoverConfig holds default settings for
+// RemoteWriteFailoverConfig.
+var DefaultRemoteWriteFailoverConfig = RemoteWriteFailoverConfig{
+	CheckInterval: 15 * time.Second,
+	OpenAfter:     5 * time.Minute,
+}
+
+// failoverRecoveryRatio is the fraction of open_after which an endpoint must
+// catch up to before its circuit closes again. This prevents failing over
+// back and forth while an endpoint hovers around open_after.
+const failoverRecoveryRatio = 0.5
+
+// RemoteWriteFailoverConfig configures failing over between remote_write
+// endpoints. Each listed endpoint has a circuit breaker which opens when the
+// endpoint falls behind by open_after. Endpoints after the first are only
+// sent to while the circuits of all endpoints before them are open.
+//
+// Endpoints with an open circuit keep sending, so they're backfilled from the
+// WAL once they recover.
+type RemoteWriteFailoverConfig struct {
+	// Names of remote_write endpoints in failover order.
+	Endpoints []string `yaml:"endpoints,omitempty"`
+
+	// How far an endpoint may fall behind the newest samples written to the
+	// WAL before its circuit opens.
+	OpenAfter time.Duration `yaml:"open_after,omitempty"`
+	// How often the circuits are checked.
+	CheckInterval time.Duration `yaml:"check_interval,omitempty"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (c *RemoteWriteFailoverConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultRemoteWriteFailoverConfig
+
+	type plain RemoteWriteFailoverConfig
+	return unmarshal((*plain)(c))
+}
+
+// Validate returns an error if c is invalid for the instance config ic.
+// Names must already be assigned to the remote_write configs of ic.
+func (c *RemoteWriteFailoverConfig) Validate(ic *Config) error {
+	switch {
+	case len(c.Endpoints) < 2:
+		return errors.New("at least two endpoints must be listed")
+	case c.OpenAfter <= 0:
+		return errors.New("open_after must be greater than 0s")
+	case c.CheckInterval <= 0:
+		return errors.New("check_interval must be greater than 0s")
+	}
+
+	names := make(map[string]struct{}, len(ic.RemoteWrite))
+	for _, rw := range ic.RemoteWrite {
+		names[rw.Name] = struct{}{}
+	}
+	seen := make(map[string]struct{}, len(c.Endpoints))
+	for _, name := range c.Endpoints {
+		if _, ok := names[name]; !ok {
+			return fmt.Errorf("endpoint %q does not match the name of a remote_write config", name)
+		}
+		if _, ok := seen[name]; ok {
+			return fmt.Errorf("endpoint %q is listed more than once", name)
+		}
+		seen[name] = struct{}{}
+	}
+	return nil
+}
+
+// activeEndpoints returns the listed endpoints which should currently be sent
+// to given the endpoints whose circuits are open.
+func (c *RemoteWriteFailoverConfig) activeEndpoints(open map[string]bool) map[string]bool {
+	active := make(map[string]bool, len(c.Endpoints))
+	for _, name := range c.Endpoints {
+		active[name] = true
+		if !open[name] {
+			break
+		}
+	}
+	return active
+}
+
+// activeRemoteWrite returns the remote_write configs of cfg which should
+// currently be sent to given the endpoints whose circuits are open.
+func activeRemoteWrite(cfg *Config, open map[string]bool) []*config.RemoteWriteConfig {
+	if cfg.RemoteWriteFailover == nil {
+		return cfg.RemoteWrite
+	}
+
+	var (
+		active = cfg.RemoteWriteFailover.activeEndpoints(open)
+		listed = make(map[string]struct{}, len(cfg.RemoteWriteFailover.Endpoints))
+	)
+	for _, name := range cfg.RemoteWriteFailover.Endpoints {
+		listed[name] = struct{}{}
+	}
+
+	res := make([]*config.RemoteWriteConfig, 0, len(cfg.RemoteWrite))
+	for _, rw := range cfg.RemoteWrite {
+		if _, ok := listed[rw.Name]; ok && !active[rw.Name] {
+			continue
+		}
+		res = append(res, rw)
+	}
+	return res
+}
+
+const (
+	highestInMetric     = "prometheus_remote_storage_highest_timestamp_in_seconds"
+	highestSentMetric   = "prometheus_remote_storage_queue_highest_sent_timestamp_seconds"
+	shardsMetric        = "prometheus_remote_storage_shards"
+	shardsDesiredMetric = "prometheus_remote_storage_shards_desired"
+)
+
+// queueGaugeMetrics are the per-queue gauges tracked by remoteWriteProgress.
+var queueGaugeMetrics = []string{
+	highestSentMetric,
+	shardsMetric,
+	shardsDesiredMetric,
+}
+
+// remoteWriteProgress is a prometheus.Registerer which keeps track of the
+// metrics remote storage registers for the newest sample written to it and
+// for the state of each queue, such as the newest sample it sent, so how far
+// each remote_write endpoint is behind can be read back.
+type remoteWriteProgress struct {
+	prometheus.Registerer
+
+	mut       sync.Mutex
+	highestIn prometheus.Collector
+	// queueGauges holds the registered per-queue gauges by metric name.
+	queueGauges map[string]map[prometheus.Collector]struct{}
+}
+
+func newRemoteWriteProgress(reg prometheus.Registerer) *remoteWriteProgress {
+	queueGauges := make(map[string]map[prometheus.Collector]struct{}, len(queueGaugeMetrics))
+	for _, name := range queueGaugeMetrics {
+		queueGauges[name] = make(map[prometheus.Collector]struct{})
+	}
+
+	return &remoteWriteProgress{
+		Registerer:  reg,
+		queueGauges: queueGauges,
+	}
+}
+
+// Register implements prometheus.Registerer.
+func (p *remoteWriteProgress) Register(c prometheus.Collector) error {
+	if err := p.Registerer.Register(c); err != nil {
+		return err
+	}
+
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	name := collectorName(c)
+	if name == highestInMetric {
+		p.highestIn = c
+	} else if gauges, ok := p.queueGauges[name]; ok {
+		gauges[c] = struct{}{}
+	}
+	return nil
+}
+
+// MustRegister implements prometheus.Registerer.
+func (p *remoteWriteProgress) MustRegister(cs ...prometheus.Collector) {
+	for _, c := range cs {
+		if err := p.Register(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Unregister implements prometheus.Registerer.
+func (p *remoteWriteProgress) Unregister(c prometheus.Collector) bool {
+	p.mut.Lock()
+	if p.highestIn == c {
+		p.highestIn = nil
+	}
+	for _, gauges := range p.queueGauges {
+		delete(gauges, c)
+	}
+	p.mut.Unlock()
+
+	return p.Registerer.Unregister(c)
+}
+
+// HighestIn returns the timestamp in seconds of the newest sample written to
+// remote storage, or 0 if nothing has been written yet.
+func (p *remoteWriteProgress) HighestIn() float64 {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	if p.highestIn == nil {
+		return 0
+	}
+	var highest float64
+	collectGauges(p.highestIn, func(_ string, v float64) {
+		if v > highest {
+			highest = v
+		}
+	})
+	return highest
+}
+
+// HighestSent returns the timestamp in seconds of the newest sample sent by
+// the queue of each remote_write endpoint.
+func (p *remoteWriteProgress) HighestSent() map[string]float64 {
+	return p.QueueGauge(highestSentMetric)
+}
+
+// QueueGauge returns the value of the per-queue gauge called name for the
+// queue of each remote_write endpoint.
+func (p *remoteWriteProgress) QueueGauge(name string) map[string]float64 {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	res := make(map[string]float64, len(p.queueGauges[name]))
+	for c := range p.queueGauges[name] {
+		collectGauges(c, func(remoteName string, v float64) {
+			res[remoteName] = v
+		})
+	}
+	return res
+}
+
+// collectorName returns the name of the first metric described by c.
+func collectorName(c prometheus.Collector) string {
+	ch := make(chan *prometheus.Desc, 1)
+	go func() {
+		c.Describe(ch)
+		close(ch)
+	}()
+
+	var name string
+	for desc := range ch {
+		// Desc doesn't expose its name other than through String.
+		s := desc.String()
+		if name == "" && strings.Contains(s, `fqName: "`) {
+			s = s[strings.Index(s, `fqName: "`)+len(`fqName: "`):]
+			name = s[:strings.Index(s, `"`)]
+		}
+	}
+	return name
+}
+
+// collectGauges calls f with the remote_name label and value of each gauge
+// collected from c.
+func collectGauges(c prometheus.Collector, f func(remoteName string, v float64)) {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+
+	for m := range ch {
+		var pb dto.Metric
+		if err := m.Write(&pb); err != nil || pb.Gauge == nil {
+			continue
+		}
+		var remoteName string
+		for _, lp := range pb.Label {
+			if lp.GetName() == "remote_name" {
+				remoteName = lp.GetValue()
+			}
+		}
+		f(remoteName, pb.Gauge.GetValue())
+	}
+}
+
+type remoteWriteFailoverMetrics struct {
+	circuitOpen *prometheus.GaugeVec
+	transitions *prometheus.CounterVec
+}
+
+func newRemoteWriteFailoverMetrics(reg prometheus.Registerer) *remoteWriteFailoverMetrics {
+	m := &remoteWriteFailoverMetrics{
+		circuitOpen: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "agent_metrics_remote_write_circuit_open",
+			Help: "Whether the circuit breaker of a remote_write endpoint is open because it fell behind.",
+		}, []string{"remote_name"}),
+		transitions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "agent_metrics_remote_write_circuit_transitions_total",
+			Help: "Total number of times the circuit breaker of a remote_write endpoint opened or closed.",
+		}, []string{"remote_name", "state"}),
+	}
+
+	if reg != nil {
+		reg.MustRegister(m.circuitOpen, m.transitions)
+	}
+	return m
+}
+
+// remoteWriteFailoverLoop periodically checks how far the remote_write
+// endpoints listed in remote_write_failover are behind, and opens or closes
+// their circuits. Fallback endpoints are started and stopped as circuits
+// change. It runs until ctx is canceled.
+func (i *Instance) remoteWriteFailoverLoop(ctx context.Context, reg prometheus.Registerer) {
+	var (
+		metrics = newRemoteWriteFailoverMetrics(reg)
+		logger  = log.With(i.logger, "component", "remote write failover")
+
+		// activeSince holds when each active endpoint started sending, so
+		// endpoints which never sent anything still fall behind.
+		activeSince = make(map[string]time.Time)
+	)
+
+	for {
+		i.mut.Lock()
+		fc := i.cfg.RemoteWriteFailover
+		i.mut.Unlock()
+
+		checkInterval := DefaultRemoteWriteFailoverConfig.CheckInterval
+		if fc != nil {
+			checkInterval = fc.CheckInterval
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(checkInterval):
+		}
+
+		i.mut.Lock()
+		if i.cfg.RemoteWriteFailover == nil && len(i.openCircuits) == 0 {
+			i.mut.Unlock()
+			continue
+		}
+
+		var (
+			now       = time.Now()
+			highestIn = i.rwProgress.HighestIn()
+			sent      = i.rwProgress.HighestSent()
+
+			prevOpen = i.openCircuits
+			nextOpen = make(map[string]bool)
+			active   = map[string]bool{}
+		)
+		// The failover config may have been removed since it was last read,
+		// in which case all circuits close.
+		if fc := i.cfg.RemoteWriteFailover; fc != nil {
+			active = fc.activeEndpoints(prevOpen)
+			for _, name := range fc.Endpoints {
+				if !active[name] {
+					delete(activeSince, name)
+					continue
+				}
+				if _, ok := activeSince[name]; !ok {
+					activeSince[name] = now
+				}
+
+				lag := failoverLag(highestIn, sent[name], activeSince[name])
+				switch {
+				case !prevOpen[name] && lag >= fc.OpenAfter:
+					nextOpen[name] = true
+				case prevOpen[name] && float64(lag) >= float64(fc.OpenAfter)*failoverRecoveryRatio:
+					nextOpen[name] = true
+				}
+			}
+		}
+
+		var err error
+		if !circuitsEqual(prevOpen, nextOpen) {
+			i.openCircuits = nextOpen
+			err = i.applyRemoteWrite(&i.cfg)
+			if err != nil {
+				i.openCircuits = prevOpen
+			}
+		}
+		i.mut.Unlock()
+
+		if err != nil {
+			level.Error(logger).Log("msg", "failed to apply remote_write configs after circuit change", "err", err)
+			continue
+		}
+
+		for name := range nextOpen {
+			if prevOpen[name] {
+				continue
+			}
+			metrics.circuitOpen.WithLabelValues(name).Set(1)
+			metrics.transitions.WithLabelValues(name, "open").Inc()
+			level.Warn(logger).Log("msg", "remote_write endpoint fell behind, opened its circuit and failing over", "remote_name", name)
+		}
+		for name := range prevOpen {
+			if nextOpen[name] {
+				continue
+			}
+			metrics.circuitOpen.WithLabelValues(name).Set(0)
+			metrics.transitions.WithLabelValues(name, "closed").Inc()
+			level.Info(logger).Log("msg", "remote_write endpoint caught up, closed its circuit", "remote_name", name)
+		}
+	}
+}
+
+// failoverLag returns how far an endpoint which has sent samples up to sent
+// is behind the newest sample written at highestIn, both in seconds. Samples
+// written before the endpoint became active at since aren't waited for.
+func failoverLag(highestIn, sent float64, since time.Time) time.Duration {
+	if highestIn <= 0 {
+		return 0
+	}
+	if sinceSeconds := float64(since.UnixNano()) / 1e9; sent < sinceSeconds {
+		sent = sinceSeconds
+	}
+	if sent >= highestIn {
+		return 0
+	}
+	return time.Duration((highestIn - sent) * float64(time.Second))
+}
+
+func circuitsEqual(a, b map[string]bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name := range a {
+		if !b[name] {
+			return false
+		}
+	}
+	return true
+}
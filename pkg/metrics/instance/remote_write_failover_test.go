@@ -0,0 +1,138 @@
+package instance
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+const failoverTestConfig = `name: test
+scrape_configs: []
+remote_write:
+  - name: primary
+    url: http://primary:9009/api/prom/push
+  - name: secondary
+    url: http://secondary:9009/api/prom/push
+  - name: tertiary
+    url: http://tertiary:9009/api/prom/push
+  - name: always
+    url: http://always:9009/api/prom/push
+`
+
+func TestRemoteWriteFailoverConfig_Unmarshal(t *testing.T) {
+	cfgText := failoverTestConfig + `remote_write_failover:
+  endpoints: [primary, secondary, tertiary]
+  open_after: 10m`
+
+	cfg, err := UnmarshalConfig(strings.NewReader(cfgText))
+	require.NoError(t, err)
+	require.NoError(t, cfg.ApplyDefaults(DefaultGlobalConfig))
+
+	expect := DefaultRemoteWriteFailoverConfig
+	expect.Endpoints = []string{"primary", "secondary", "tertiary"}
+	expect.OpenAfter = 10 * time.Minute
+	require.Equal(t, &expect, cfg.RemoteWriteFailover)
+
+	activeNames := func(open map[string]bool) []string {
+		var names []string
+		for _, rw := range activeRemoteWrite(cfg, open) {
+			names = append(names, rw.Name)
+		}
+		return names
+	}
+
+	// Fallbacks are only active while all endpoints before them are open.
+	// Endpoints which aren't listed are always active.
+	require.Equal(t, []string{"primary", "always"}, activeNames(nil))
+	require.Equal(t, []string{"primary", "secondary", "always"}, activeNames(map[string]bool{"primary": true}))
+	require.Equal(t, []string{"primary", "secondary", "tertiary", "always"}, activeNames(map[string]bool{"primary": true, "secondary": true}))
+	require.Equal(t, []string{"primary", "always"}, activeNames(map[string]bool{"secondary": true}))
+}
+
+func TestRemoteWriteFailoverConfig_Validate(t *testing.T) {
+	tt := []struct {
+		name string
+		cfg  string
+		err  string
+	}{
+		{
+			name: "single endpoint",
+			cfg:  `endpoints: [primary]`,
+			err:  "at least two endpoints must be listed",
+		},
+		{
+			name: "unknown endpoint",
+			cfg:  `endpoints: [primary, other]`,
+			err:  `endpoint "other" does not match the name of a remote_write config`,
+		},
+		{
+			name: "duplicate endpoint",
+			cfg:  `endpoints: [primary, secondary, primary]`,
+			err:  `endpoint "primary" is listed more than once`,
+		},
+		{
+			name: "invalid open_after",
+			cfg:  `endpoints: [primary, secondary], open_after: 0s`,
+			err:  "open_after must be greater than 0s",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			cfgText := failoverTestConfig + `remote_write_failover: {` + tc.cfg + `}`
+
+			cfg, err := UnmarshalConfig(strings.NewReader(cfgText))
+			require.NoError(t, err)
+			err = cfg.ApplyDefaults(DefaultGlobalConfig)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.err)
+		})
+	}
+}
+
+func Test_failoverLag(t *testing.T) {
+	since := time.Unix(1000, 0)
+
+	require.Equal(t, time.Duration(0), failoverLag(0, 0, since), "nothing written")
+	require.Equal(t, time.Duration(0), failoverLag(1500, 1500, since), "caught up")
+	require.Equal(t, 100*time.Second, failoverLag(1500, 1400, since))
+	require.Equal(t, 500*time.Second, failoverLag(1500, 0, since), "never sent")
+	require.Equal(t, 500*time.Second, failoverLag(1500, 900, since), "sent before becoming active")
+}
+
+func Test_remoteWriteProgress(t *testing.T) {
+	var (
+		reg      = prometheus.NewRegistry()
+		progress = newRemoteWriteProgress(reg)
+	)
+
+	newGauge := func(name, remoteName string) prometheus.Gauge {
+		opts := prometheus.GaugeOpts{Name: name}
+		if remoteName != "" {
+			opts.ConstLabels = prometheus.Labels{"remote_name": remoteName}
+		}
+		return prometheus.NewGauge(opts)
+	}
+
+	var (
+		in        = newGauge(highestInMetric, "")
+		primary   = newGauge(highestSentMetric, "primary")
+		secondary = newGauge(highestSentMetric, "secondary")
+		other     = newGauge("other_metric", "primary")
+	)
+	progress.MustRegister(in, primary, secondary, other)
+
+	in.Set(300)
+	primary.Set(100)
+	secondary.Set(200)
+	other.Set(1)
+
+	require.Equal(t, float64(300), progress.HighestIn())
+	require.Equal(t, map[string]float64{"primary": 100, "secondary": 200}, progress.HighestSent())
+
+	require.True(t, progress.Unregister(secondary))
+	require.Equal(t, map[string]float64{"primary": 100}, progress.HighestSent())
+}
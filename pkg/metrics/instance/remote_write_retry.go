@@ -0,0 +1,570 @@
+package instance
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/storage/remote"
+	"gopkg.in/yaml.v2"
+)
+
+// DefaultRetryBackoffConfig holds default settings for RetryBackoffConfig.
+var DefaultRetryBackoffConfig = RetryBackoffConfig{
+	Retry:           true,
+	HonorRetryAfter: true,
+	MinBackoff:      time.Second,
+	MaxBackoff:      5 * time.Minute,
+	Multiplier:      2,
+}
+
+// DefaultRemoteWriteRetryConfig holds default settings for
+// RemoteWriteRetryConfig.
+var DefaultRemoteWriteRetryConfig = RemoteWriteRetryConfig{
+	RateLimited:      DefaultRetryBackoffConfig,
+	ServerErrors:     DefaultRetryBackoffConfig,
+	LogDroppedSeries: 5,
+}
+
+const (
+	// maxTrackedBatches is the number of failed batches whose first attempt
+	// and number of retries are remembered per endpoint.
+	maxTrackedBatches = 4096
+	// maxRetryErrMsgLen is the maximum length of an error message read from
+	// a remote_write endpoint.
+	maxRetryErrMsgLen = 1024
+)
+
+// RetryBackoffConfig configures whether and how a class of failed
+// remote_write requests is retried. Delays between retries start at
+// min_backoff and are multiplied by multiplier after every retry, up to
+// max_backoff.
+type RetryBackoffConfig struct {
+	Retry bool `yaml:"retry"`
+	// Use the Retry-After header of responses as the delay when present.
+	HonorRetryAfter bool `yaml:"honor_retry_after"`
+
+	MinBackoff time.Duration `yaml:"min_backoff,omitempty"`
+	MaxBackoff time.Duration `yaml:"max_backoff,omitempty"`
+	Multiplier float64       `yaml:"multiplier,omitempty"`
+}
+
+// Validate returns an error if c is invalid.
+func (c *RetryBackoffConfig) Validate() error {
+	switch {
+	case c.MinBackoff <= 0:
+		return errors.New("min_backoff must be greater than 0s")
+	case c.MaxBackoff < c.MinBackoff:
+		return errors.New("max_backoff must not be less than min_backoff")
+	case c.Multiplier < 1:
+		return errors.New("multiplier must be at least 1")
+	}
+	return nil
+}
+
+// backoff returns the delay before the retry following the given number of
+// previous retries.
+func (c *RetryBackoffConfig) backoff(retries int) time.Duration {
+	d := float64(c.MinBackoff) * math.Pow(c.Multiplier, float64(retries))
+	if d > float64(c.MaxBackoff) {
+		return c.MaxBackoff
+	}
+	return time.Duration(d)
+}
+
+// RemoteWriteRetryConfig configures how failed requests of a remote_write
+// endpoint are retried and when their samples are dropped instead.
+//
+// Requests failing with a 429 status code follow rate_limited. Requests
+// failing with a 5xx status code, a status code in retry_status_codes, or a
+// network error follow server_errors. Requests failing with any other status
+// code are dropped.
+type RemoteWriteRetryConfig struct {
+	// Name of the remote_write endpoint the policy applies to.
+	Endpoint string `yaml:"endpoint"`
+
+	RateLimited  RetryBackoffConfig `yaml:"rate_limited,omitempty"`
+	ServerErrors RetryBackoffConfig `yaml:"server_errors,omitempty"`
+
+	// Additional 4xx status codes which are retried like server errors.
+	RetryStatusCodes []int `yaml:"retry_status_codes,omitempty"`
+
+	// How long a request is retried for before its samples are dropped. 0
+	// retries forever.
+	MaxRetryTime time.Duration `yaml:"max_retry_time,omitempty"`
+
+	// Number of series logged from every dropped request.
+	LogDroppedSeries int `yaml:"log_dropped_series,omitempty"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (c *RemoteWriteRetryConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultRemoteWriteRetryConfig
+
+	type plain RemoteWriteRetryConfig
+	return unmarshal((*plain)(c))
+}
+
+// Validate returns an error if c is invalid.
+func (c *RemoteWriteRetryConfig) Validate() error {
+	if c.Endpoint == "" {
+		return errors.New("endpoint must be set")
+	}
+	if err := c.RateLimited.Validate(); err != nil {
+		return fmt.Errorf("invalid rate_limited: %w", err)
+	}
+	if err := c.ServerErrors.Validate(); err != nil {
+		return fmt.Errorf("invalid server_errors: %w", err)
+	}
+	for _, code := range c.RetryStatusCodes {
+		if code < 400 || code > 499 || code == http.StatusTooManyRequests {
+			return fmt.Errorf("retry_status_codes must only hold 4xx status codes other than 429, got %d", code)
+		}
+	}
+	switch {
+	case c.MaxRetryTime < 0:
+		return errors.New("max_retry_time must not be negative")
+	case c.LogDroppedSeries < 0:
+		return errors.New("log_dropped_series must not be negative")
+	}
+	return nil
+}
+
+// validateRemoteWriteRetry returns an error if the retry policies of the
+// instance config ic are invalid. Names must already be assigned to the
+// remote_write configs of ic.
+func validateRemoteWriteRetry(ic *Config) error {
+	names := make(map[string]struct{}, len(ic.RemoteWrite))
+	for _, rw := range ic.RemoteWrite {
+		names[rw.Name] = struct{}{}
+	}
+	seen := make(map[string]struct{}, len(ic.RemoteWriteRetry))
+	for _, c := range ic.RemoteWriteRetry {
+		if c == nil {
+			return errors.New("empty policy")
+		}
+		if err := c.Validate(); err != nil {
+			return err
+		}
+		if _, ok := names[c.Endpoint]; !ok {
+			return fmt.Errorf("endpoint %q does not match the name of a remote_write config", c.Endpoint)
+		}
+		if _, ok := seen[c.Endpoint]; ok {
+			return fmt.Errorf("endpoint %q has more than one policy", c.Endpoint)
+		}
+		seen[c.Endpoint] = struct{}{}
+	}
+	return nil
+}
+
+type remoteWriteRetryMetrics struct {
+	responses *prometheus.CounterVec
+	retries   *prometheus.CounterVec
+	dropped   *prometheus.CounterVec
+}
+
+func newRemoteWriteRetryMetrics(reg prometheus.Registerer) *remoteWriteRetryMetrics {
+	m := &remoteWriteRetryMetrics{
+		responses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "agent_metrics_remote_write_responses_total",
+			Help: "Total number of responses from remote_write endpoints with a retry policy by status code class. Failed requests are counted with a status_class of error.",
+		}, []string{"remote_name", "status_class"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "agent_metrics_remote_write_retries_total",
+			Help: "Total number of requests to remote_write endpoints with a retry policy which are retried.",
+		}, []string{"remote_name", "reason"}),
+		dropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "agent_metrics_remote_write_dropped_requests_total",
+			Help: "Total number of requests to remote_write endpoints with a retry policy whose samples are dropped.",
+		}, []string{"remote_name", "reason"}),
+	}
+	reg.MustRegister(m.responses, m.retries, m.dropped)
+	return m
+}
+
+// remoteWriteRetry applies retry policies to remote_write endpoints.
+//
+// The remote_write client of Prometheus only retries 5xx status codes and,
+// if enabled, 429 status codes, forever. Remote storage has no hook to change
+// this, so the client of the queue of every endpoint with a policy is
+// replaced after remote storage is configured. The replacement client has the
+// same settings, with a round tripper which translates responses: requests to
+// retry are answered with a 429 status code and a Retry-After header holding
+// the delay of the policy, and requests to drop are answered with a 400
+// status code.
+type remoteWriteRetry struct {
+	logger  log.Logger
+	metrics *remoteWriteRetryMetrics
+
+	endpoints map[string]*endpointRetry
+}
+
+func newRemoteWriteRetry(logger log.Logger, reg prometheus.Registerer) *remoteWriteRetry {
+	return &remoteWriteRetry{
+		logger:    logger,
+		metrics:   newRemoteWriteRetryMetrics(reg),
+		endpoints: make(map[string]*endpointRetry),
+	}
+}
+
+// Install replaces the clients of the queues of s whose endpoint has a policy
+// in policies. rws must be the remote_write configs last applied to s. The
+// retries of an endpoint are tracked across calls as long as it keeps a
+// policy.
+//
+// Queues use the client created by s until Install is called, so requests
+// sent in between follow the default behavior.
+func (r *remoteWriteRetry) Install(s *remote.Storage, rws []*config.RemoteWriteConfig, policies []*RemoteWriteRetryConfig) error {
+	byName := make(map[string]*RemoteWriteRetryConfig, len(policies))
+	for _, p := range policies {
+		byName[p.Endpoint] = p
+	}
+
+	var queues map[string]*remote.QueueManager
+	used := make(map[string]struct{}, len(policies))
+	for _, rw := range rws {
+		p, ok := byName[rw.Name]
+		if !ok {
+			continue
+		}
+
+		if queues == nil {
+			var err error
+			if queues, err = writeQueues(s); err != nil {
+				return err
+			}
+		}
+		hash, err := remoteWriteHash(rw)
+		if err != nil {
+			return err
+		}
+		q, ok := queues[hash]
+		if !ok {
+			return fmt.Errorf("remote storage has no queue for remote_write endpoint %q", rw.Name)
+		}
+
+		e, ok := r.endpoints[rw.Name]
+		if !ok {
+			e, err = newEndpointRetry(rw.Name, r.logger, r.metrics)
+			if err != nil {
+				return fmt.Errorf("failed to create retry policy for remote_write endpoint %q: %w", rw.Name, err)
+			}
+			r.endpoints[rw.Name] = e
+		}
+		e.SetPolicy(p)
+
+		c, err := e.WriteClient(rw)
+		if err != nil {
+			return fmt.Errorf("failed to create client for remote_write endpoint %q: %w", rw.Name, err)
+		}
+		q.SetClient(c)
+		used[rw.Name] = struct{}{}
+	}
+
+	for name := range r.endpoints {
+		if _, ok := used[name]; !ok {
+			delete(r.endpoints, name)
+		}
+	}
+	return nil
+}
+
+// writeQueues returns the queues of the remote_write endpoints of s, keyed by
+// the hash of their config. Remote storage doesn't expose its queues, so they
+// are read from its unexported fields.
+func writeQueues(s *remote.Storage) (map[string]*remote.QueueManager, error) {
+	errUnsupported := errors.New("remote storage doesn't hold its queues where expected; remote_write_retry is unsupported by this version of Prometheus")
+
+	rws := reflect.ValueOf(s).Elem().FieldByName("rws")
+	if !rws.IsValid() || rws.Kind() != reflect.Ptr || rws.IsNil() {
+		return nil, errUnsupported
+	}
+	queues := rws.Elem().FieldByName("queues")
+	if !queues.IsValid() || queues.Type() != reflect.TypeOf(map[string]*remote.QueueManager(nil)) {
+		return nil, errUnsupported
+	}
+	return *(*map[string]*remote.QueueManager)(unsafe.Pointer(queues.UnsafeAddr())), nil
+}
+
+// remoteWriteHash returns the hash remote storage keys the queue of rw by.
+func remoteWriteHash(rw *config.RemoteWriteConfig) (string, error) {
+	bb, err := yaml.Marshal(rw)
+	if err != nil {
+		return "", err
+	}
+	hash := md5.Sum(bb)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// retryBatch tracks the retries of a failed request.
+type retryBatch struct {
+	firstAttempt time.Time
+	retries      int
+}
+
+// endpointRetry applies the retry policy of a remote_write endpoint. Requests
+// are retried by the queue with the same body, so retries are tracked by the
+// hash of the body.
+type endpointRetry struct {
+	name    string
+	logger  log.Logger
+	metrics *remoteWriteRetryMetrics
+	batches *lru.Cache
+	now     func() time.Time
+
+	mut    sync.RWMutex
+	policy RemoteWriteRetryConfig
+}
+
+func newEndpointRetry(name string, logger log.Logger, metrics *remoteWriteRetryMetrics) (*endpointRetry, error) {
+	batches, err := lru.New(maxTrackedBatches)
+	if err != nil {
+		return nil, err
+	}
+	return &endpointRetry{
+		name:    name,
+		logger:  log.With(logger, "component", "remote_write_retry", "remote_name", name),
+		metrics: metrics,
+		batches: batches,
+		now:     time.Now,
+	}, nil
+}
+
+// SetPolicy sets the policy to apply.
+func (e *endpointRetry) SetPolicy(p *RemoteWriteRetryConfig) {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+	e.policy = *p
+}
+
+// WriteClient returns a client for rw which applies the policy. The client
+// retries 429 status codes regardless of the queue_config of rw, since only
+// the round tripper of e responds with them.
+func (e *endpointRetry) WriteClient(rw *config.RemoteWriteConfig) (remote.WriteClient, error) {
+	c, err := remote.NewWriteClient(rw.Name, &remote.ClientConfig{
+		URL:              rw.URL,
+		Timeout:          rw.RemoteTimeout,
+		HTTPClientConfig: rw.HTTPClientConfig,
+		SigV4Config:      rw.SigV4Config,
+		Headers:          rw.Headers,
+		RetryOnRateLimit: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	rc, ok := c.(*remote.Client)
+	if !ok {
+		return nil, fmt.Errorf("unexpected remote_write client %T", c)
+	}
+	rc.Client.Transport = &retryRoundTripper{e: e, next: rc.Client.Transport}
+	return rc, nil
+}
+
+// retryRoundTripper sends requests through next, translating responses
+// according to the policy of e.
+type retryRoundTripper struct {
+	e    *endpointRetry
+	next http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (rt *retryRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	e := rt.e
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	e.mut.RLock()
+	policy := e.policy
+	e.mut.RUnlock()
+
+	out := req.Clone(req.Context())
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	out.ContentLength = int64(len(body))
+
+	var (
+		status     int
+		retryAfter string
+		msg        string
+	)
+	resp, err := rt.next.RoundTrip(out)
+	if err != nil {
+		// Requests canceled by the queue aren't failures of the endpoint.
+		if req.Context().Err() != nil {
+			return nil, err
+		}
+		msg = err.Error()
+		e.metrics.responses.WithLabelValues(e.name, "error").Inc()
+	} else {
+		status = resp.StatusCode
+		retryAfter = resp.Header.Get("Retry-After")
+		if status/100 != 2 {
+			b, _ := io.ReadAll(io.LimitReader(resp.Body, maxRetryErrMsgLen))
+			msg = strings.TrimSpace(string(b))
+		}
+		e.metrics.responses.WithLabelValues(e.name, fmt.Sprintf("%dxx", status/100)).Inc()
+	}
+
+	h := fnv.New64a()
+	_, _ = h.Write(body)
+	key := h.Sum64()
+
+	var backoff *RetryBackoffConfig
+	switch {
+	case err == nil && status/100 == 2:
+		e.batches.Remove(key)
+		return resp, nil
+	case status == http.StatusTooManyRequests:
+		backoff = &policy.RateLimited
+	case err != nil || status/100 == 5 || containsInt(policy.RetryStatusCodes, status):
+		backoff = &policy.ServerErrors
+	}
+	if resp != nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	reason := retryReason(status, err)
+	if backoff == nil || !backoff.Retry {
+		return e.drop(req, key, body, &policy, reason, status, msg), nil
+	}
+
+	batch := &retryBatch{firstAttempt: e.now()}
+	if v, ok := e.batches.Get(key); ok {
+		batch = v.(*retryBatch)
+	}
+	if policy.MaxRetryTime > 0 && e.now().Sub(batch.firstAttempt) >= policy.MaxRetryTime {
+		return e.drop(req, key, body, &policy, "max_retry_time", status, msg), nil
+	}
+
+	delay := backoff.backoff(batch.retries)
+	if backoff.HonorRetryAfter {
+		if d, ok := parseRetryAfter(retryAfter, e.now()); ok {
+			delay = d
+		}
+	}
+	batch.retries++
+	e.batches.Add(key, batch)
+	e.metrics.retries.WithLabelValues(e.name, reason).Inc()
+
+	// Retry-After only has a granularity of seconds.
+	res := newRetryResponse(req, http.StatusTooManyRequests, msg)
+	res.Header.Set("Retry-After", strconv.Itoa(int(math.Max(1, math.Ceil(delay.Seconds())))))
+	return res, nil
+}
+
+// newRetryResponse returns a response to req with the given status code and
+// body.
+func newRetryResponse(req *http.Request, status int, body string) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        make(http.Header),
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// drop returns the response to a request whose samples shouldn't be
+// retried, logging some of the series it holds.
+func (e *endpointRetry) drop(req *http.Request, key uint64, body []byte, p *RemoteWriteRetryConfig, reason string, status int, msg string) *http.Response {
+	e.batches.Remove(key)
+	e.metrics.dropped.WithLabelValues(e.name, reason).Inc()
+
+	logger := log.With(e.logger, "reason", reason, "err", msg)
+	if status != 0 {
+		logger = log.With(logger, "status_code", status)
+	}
+	if p.LogDroppedSeries > 0 {
+		if wr, err := remote.DecodeWriteRequest(bytes.NewReader(body)); err == nil {
+			n := len(wr.Timeseries)
+			if n > p.LogDroppedSeries {
+				n = p.LogDroppedSeries
+			}
+			series := make([]string, 0, n)
+			for _, ts := range wr.Timeseries[:n] {
+				pairs := make([]string, 0, len(ts.Labels))
+				for _, l := range ts.Labels {
+					pairs = append(pairs, fmt.Sprintf("%s=%q", l.Name, l.Value))
+				}
+				series = append(series, "{"+strings.Join(pairs, ", ")+"}")
+			}
+			logger = log.With(logger, "series", len(wr.Timeseries), "sample_series", strings.Join(series, " "))
+		}
+	}
+	level.Warn(logger).Log("msg", "dropping samples of failed remote_write request")
+
+	return newRetryResponse(req, http.StatusBadRequest, fmt.Sprintf("dropped by retry policy (%s): %s", reason, msg))
+}
+
+// retryReason returns the reason label of a failed request.
+func retryReason(status int, err error) string {
+	switch {
+	case err != nil:
+		return "error"
+	case status == http.StatusTooManyRequests:
+		return "rate_limited"
+	case status/100 == 5:
+		return "server_error"
+	default:
+		return "client_error"
+	}
+}
+
+// parseRetryAfter parses the value of a Retry-After header, which is either
+// a number of seconds or an HTTP date.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func containsInt(s []int, v int) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,256 @@
+package instance
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	config_util "github.com/prometheus/common/config"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/storage/remote"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoteWriteRetryConfig_Unmarshal(t *testing.T) {
+	cfgText := failoverTestConfig + `remote_write_retry:
+  - endpoint: primary
+    rate_limited:
+      retry: false
+    retry_status_codes: [408]
+    max_retry_time: 1h`
+
+	cfg, err := UnmarshalConfig(strings.NewReader(cfgText))
+	require.NoError(t, err)
+	require.NoError(t, cfg.ApplyDefaults(DefaultGlobalConfig))
+
+	expect := DefaultRemoteWriteRetryConfig
+	expect.Endpoint = "primary"
+	expect.RateLimited.Retry = false
+	expect.RetryStatusCodes = []int{408}
+	expect.MaxRetryTime = time.Hour
+	require.Equal(t, []*RemoteWriteRetryConfig{&expect}, cfg.RemoteWriteRetry)
+}
+
+func TestRemoteWriteRetryConfig_Validate(t *testing.T) {
+	tt := []struct {
+		name string
+		cfg  string
+		err  string
+	}{
+		{
+			name: "unknown endpoint",
+			cfg:  `[{endpoint: other}]`,
+			err:  `endpoint "other" does not match the name of a remote_write config`,
+		},
+		{
+			name: "duplicate endpoint",
+			cfg:  `[{endpoint: primary}, {endpoint: primary}]`,
+			err:  `endpoint "primary" has more than one policy`,
+		},
+		{
+			name: "invalid status code",
+			cfg:  `[{endpoint: primary, retry_status_codes: [429]}]`,
+			err:  "retry_status_codes must only hold 4xx status codes other than 429, got 429",
+		},
+		{
+			name: "invalid backoff",
+			cfg:  `[{endpoint: primary, server_errors: {min_backoff: 1m, max_backoff: 1s}}]`,
+			err:  "invalid server_errors: max_backoff must not be less than min_backoff",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			cfgText := failoverTestConfig + `remote_write_retry: ` + tc.cfg
+
+			cfg, err := UnmarshalConfig(strings.NewReader(cfgText))
+			require.NoError(t, err)
+			err = cfg.ApplyDefaults(DefaultGlobalConfig)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.err)
+		})
+	}
+}
+
+func TestRetryBackoffConfig_backoff(t *testing.T) {
+	c := RetryBackoffConfig{MinBackoff: time.Second, MaxBackoff: 10 * time.Second, Multiplier: 3}
+
+	require.Equal(t, time.Second, c.backoff(0))
+	require.Equal(t, 3*time.Second, c.backoff(1))
+	require.Equal(t, 9*time.Second, c.backoff(2))
+	require.Equal(t, 10*time.Second, c.backoff(3))
+}
+
+// retryTestUpstream is a remote_write endpoint which responds to requests
+// with the responses set on it.
+type retryTestUpstream struct {
+	mut        sync.Mutex
+	status     int
+	retryAfter string
+	auth       []string
+}
+
+func (u *retryTestUpstream) Respond(status int, retryAfter string) {
+	u.mut.Lock()
+	defer u.mut.Unlock()
+	u.status, u.retryAfter = status, retryAfter
+}
+
+func (u *retryTestUpstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u.mut.Lock()
+	defer u.mut.Unlock()
+	u.auth = append(u.auth, r.Header.Get("Authorization"))
+	if u.retryAfter != "" {
+		w.Header().Set("Retry-After", u.retryAfter)
+	}
+	w.WriteHeader(u.status)
+}
+
+func TestRemoteWriteRetry(t *testing.T) {
+	upstream := &retryTestUpstream{status: http.StatusNoContent}
+	srv := httptest.NewServer(upstream)
+	defer srv.Close()
+
+	srvURL, err := url.Parse(srv.URL + "/api/prom/push")
+	require.NoError(t, err)
+	rw := &config.RemoteWriteConfig{
+		Name:          "primary",
+		URL:           &config_util.URL{URL: srvURL},
+		RemoteTimeout: model.Duration(time.Minute),
+		HTTPClientConfig: config_util.HTTPClientConfig{
+			BearerToken: "secret",
+		},
+	}
+
+	policy := DefaultRemoteWriteRetryConfig
+	policy.Endpoint = "primary"
+	policy.RetryStatusCodes = []int{http.StatusRequestTimeout}
+	policy.MaxRetryTime = time.Hour
+
+	reg := prometheus.NewRegistry()
+	e, err := newEndpointRetry("primary", log.NewNopLogger(), newRemoteWriteRetryMetrics(reg))
+	require.NoError(t, err)
+	e.SetPolicy(&policy)
+
+	now := time.Now()
+	e.now = func() time.Time { return now }
+
+	c, err := e.WriteClient(rw)
+	require.NoError(t, err)
+	require.Equal(t, srvURL.String(), c.Endpoint(), "the configured URL should be kept")
+
+	send := func(body string) *http.Response {
+		resp, err := c.(*remote.Client).Client.Post(srvURL.String(), "application/x-protobuf", strings.NewReader(body))
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	resp := send("ok")
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	require.Equal(t, []string{"Bearer secret"}, upstream.auth, "client settings should be applied")
+
+	// Server errors are retried along the backoff curve.
+	upstream.Respond(http.StatusInternalServerError, "")
+	for _, expect := range []string{"1", "2", "4"} {
+		resp = send("batch")
+		require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		require.Equal(t, expect, resp.Header.Get("Retry-After"))
+	}
+
+	// Retry-After of the endpoint is honored.
+	upstream.Respond(http.StatusTooManyRequests, "30")
+	resp = send("batch")
+	require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	require.Equal(t, "30", resp.Header.Get("Retry-After"))
+
+	// Listed 4xx status codes are retried, others are dropped.
+	upstream.Respond(http.StatusRequestTimeout, "")
+	require.Equal(t, http.StatusTooManyRequests, send("timeout").StatusCode)
+	upstream.Respond(http.StatusBadRequest, "")
+	require.Equal(t, http.StatusBadRequest, send("invalid").StatusCode)
+
+	// Requests are dropped once they've been retried for max_retry_time.
+	upstream.Respond(http.StatusServiceUnavailable, "")
+	now = now.Add(time.Hour)
+	require.Equal(t, http.StatusBadRequest, send("batch").StatusCode)
+	require.Equal(t, http.StatusTooManyRequests, send("batch").StatusCode, "dropped requests should be forgotten")
+
+	expect := `
+# HELP agent_metrics_remote_write_dropped_requests_total Total number of requests to remote_write endpoints with a retry policy whose samples are dropped.
+# TYPE agent_metrics_remote_write_dropped_requests_total counter
+agent_metrics_remote_write_dropped_requests_total{reason="client_error",remote_name="primary"} 1
+agent_metrics_remote_write_dropped_requests_total{reason="max_retry_time",remote_name="primary"} 1
+# HELP agent_metrics_remote_write_responses_total Total number of responses from remote_write endpoints with a retry policy by status code class. Failed requests are counted with a status_class of error.
+# TYPE agent_metrics_remote_write_responses_total counter
+agent_metrics_remote_write_responses_total{remote_name="primary",status_class="2xx"} 1
+agent_metrics_remote_write_responses_total{remote_name="primary",status_class="4xx"} 3
+agent_metrics_remote_write_responses_total{remote_name="primary",status_class="5xx"} 5
+# HELP agent_metrics_remote_write_retries_total Total number of requests to remote_write endpoints with a retry policy which are retried.
+# TYPE agent_metrics_remote_write_retries_total counter
+agent_metrics_remote_write_retries_total{reason="client_error",remote_name="primary"} 1
+agent_metrics_remote_write_retries_total{reason="rate_limited",remote_name="primary"} 1
+agent_metrics_remote_write_retries_total{reason="server_error",remote_name="primary"} 4
+`
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expect)))
+}
+
+func TestRemoteWriteRetry_Install(t *testing.T) {
+	srvURL, err := url.Parse("http://localhost:9009/api/prom/push")
+	require.NoError(t, err)
+
+	rws := []*config.RemoteWriteConfig{
+		{Name: "primary", URL: &config_util.URL{URL: srvURL}, QueueConfig: config.DefaultQueueConfig},
+		{Name: "other", URL: &config_util.URL{URL: srvURL}, QueueConfig: config.DefaultQueueConfig},
+	}
+	policies := []*RemoteWriteRetryConfig{{Endpoint: "primary"}}
+
+	reg := prometheus.NewRegistry()
+	s := remote.NewStorage(log.NewNopLogger(), reg, nil, t.TempDir(), time.Second, &readyScrapeManager{})
+	defer s.Close()
+	require.NoError(t, s.ApplyConfig(&config.Config{RemoteWriteConfigs: rws}))
+
+	retry := newRemoteWriteRetry(log.NewNopLogger(), reg)
+	require.NoError(t, retry.Install(s, rws, policies))
+	require.Contains(t, retry.endpoints, "primary")
+
+	queues, err := writeQueues(s)
+	require.NoError(t, err)
+	require.Len(t, queues, 2)
+
+	wrapped := 0
+	for _, q := range queues {
+		c := queueClient(t, q)
+		require.Equal(t, srvURL.String(), c.Endpoint())
+		if _, ok := c.Client.Transport.(*retryRoundTripper); ok {
+			require.Equal(t, "primary", c.Name())
+			wrapped++
+		}
+	}
+	require.Equal(t, 1, wrapped, "only the endpoint with a policy should be wrapped")
+
+	// Endpoints which no longer have a policy are forgotten.
+	require.NoError(t, retry.Install(s, rws, nil))
+	require.Empty(t, retry.endpoints)
+}
+
+// queueClient returns the client of q.
+func queueClient(t *testing.T, q *remote.QueueManager) *remote.Client {
+	t.Helper()
+
+	v := reflect.ValueOf(q).Elem().FieldByName("storeClient")
+	require.True(t, v.IsValid())
+	c, ok := reflect.NewAt(v.Type(), unsafe.Pointer(v.UnsafeAddr())).Elem().Interface().(*remote.Client)
+	require.True(t, ok)
+	return c
+}
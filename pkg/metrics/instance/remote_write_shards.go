@@ -0,0 +1,182 @@
+package instance
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/prometheus/prometheus/config"
+)
+
+// ErrUnknownRemoteWrite is returned when referring to a remote_write endpoint
+// which isn't configured.
+var ErrUnknownRemoteWrite = errors.New("unknown remote_write endpoint")
+
+// ShardOverride pins the bounds of the number of shards of a remote_write
+// queue at runtime, overriding its queue_config. Zero values keep the
+// configured bound.
+type ShardOverride struct {
+	MinShards int `json:"min_shards,omitempty"`
+	MaxShards int `json:"max_shards,omitempty"`
+}
+
+// RemoteWriteShards describes the sharding of the queue of a remote_write
+// endpoint.
+type RemoteWriteShards struct {
+	RemoteName string `json:"remote_name"`
+	URL        string `json:"url"`
+
+	// Whether the endpoint currently has a queue. Fallback endpoints of
+	// remote_write_failover don't have one while they're unused.
+	Active bool `json:"active"`
+
+	// Number of shards currently running, and the number of shards the queue
+	// computed it needs to keep up.
+	Shards        int     `json:"shards"`
+	DesiredShards float64 `json:"desired_shards"`
+
+	// Bounds of the number of shards, including any override.
+	MinShards int            `json:"min_shards"`
+	MaxShards int            `json:"max_shards"`
+	Override  *ShardOverride `json:"override,omitempty"`
+}
+
+// applyShardOverrides returns rws with the shard bounds of queues replaced by
+// overrides.
+func applyShardOverrides(rws []*config.RemoteWriteConfig, overrides map[string]ShardOverride) []*config.RemoteWriteConfig {
+	if len(overrides) == 0 {
+		return rws
+	}
+
+	res := make([]*config.RemoteWriteConfig, 0, len(rws))
+	for _, rw := range rws {
+		o, ok := overrides[rw.Name]
+		if !ok {
+			res = append(res, rw)
+			continue
+		}
+
+		cp := *rw
+		if o.MinShards > 0 {
+			cp.QueueConfig.MinShards = o.MinShards
+		}
+		if o.MaxShards > 0 {
+			cp.QueueConfig.MaxShards = o.MaxShards
+		}
+		res = append(res, &cp)
+	}
+	return res
+}
+
+// remoteWriteConfigs returns the remote_write configs of cfg to apply to
+// remote storage, leaving out unused failover endpoints and applying shard
+// overrides. i.mut must be held when called.
+func (i *Instance) remoteWriteConfigs(cfg *Config) []*config.RemoteWriteConfig {
+	return applyShardOverrides(activeRemoteWrite(cfg, i.openCircuits), i.shardOverrides)
+}
+
+// applyRemoteWrite applies the remote_write configs of cfg to remote storage
+// and installs the retry policies of its endpoints. i.mut must be held when
+// called.
+func (i *Instance) applyRemoteWrite(cfg *Config) error {
+	rws := i.remoteWriteConfigs(cfg)
+	err := i.remoteStore.ApplyConfig(&config.Config{
+		GlobalConfig:       cfg.global.Prometheus,
+		RemoteWriteConfigs: rws,
+	})
+	if err != nil || i.rwRetry == nil {
+		return err
+	}
+	return i.rwRetry.Install(i.remoteStore, rws, cfg.RemoteWriteRetry)
+}
+
+// RemoteWriteShards returns the sharding of the queue of each remote_write
+// endpoint.
+func (i *Instance) RemoteWriteShards() []RemoteWriteShards {
+	i.mut.Lock()
+	defer i.mut.Unlock()
+
+	var (
+		shards  = map[string]float64{}
+		desired = map[string]float64{}
+		active  = map[string]bool{}
+	)
+	if i.rwProgress != nil {
+		shards = i.rwProgress.QueueGauge(shardsMetric)
+		desired = i.rwProgress.QueueGauge(shardsDesiredMetric)
+	}
+	for _, rw := range activeRemoteWrite(&i.cfg, i.openCircuits) {
+		active[rw.Name] = true
+	}
+
+	res := make([]RemoteWriteShards, 0, len(i.cfg.RemoteWrite))
+	for _, rw := range applyShardOverrides(i.cfg.RemoteWrite, i.shardOverrides) {
+		s := RemoteWriteShards{
+			RemoteName: rw.Name,
+			URL:        rw.URL.String(),
+			Active:     active[rw.Name] && i.remoteStore != nil,
+
+			Shards:        int(shards[rw.Name]),
+			DesiredShards: desired[rw.Name],
+			MinShards:     rw.QueueConfig.MinShards,
+			MaxShards:     rw.QueueConfig.MaxShards,
+		}
+		if o, ok := i.shardOverrides[rw.Name]; ok {
+			s.Override = &o
+		}
+		res = append(res, s)
+	}
+	return res
+}
+
+// SetShardOverride overrides the shard bounds of the queue of the remote_write
+// endpoint called remoteName, or removes its override if o is nil. Changing
+// the bounds restarts the queue. Overrides are kept until the agent restarts.
+func (i *Instance) SetShardOverride(remoteName string, o *ShardOverride) error {
+	i.mut.Lock()
+	defer i.mut.Unlock()
+
+	var rw *config.RemoteWriteConfig
+	for _, c := range i.cfg.RemoteWrite {
+		if c.Name == remoteName {
+			rw = c
+		}
+	}
+	if rw == nil {
+		return fmt.Errorf("%w %q", ErrUnknownRemoteWrite, remoteName)
+	}
+
+	next := make(map[string]ShardOverride, len(i.shardOverrides)+1)
+	for name, o := range i.shardOverrides {
+		next[name] = o
+	}
+	if o == nil {
+		delete(next, remoteName)
+	} else {
+		minShards, maxShards := rw.QueueConfig.MinShards, rw.QueueConfig.MaxShards
+		if o.MinShards > 0 {
+			minShards = o.MinShards
+		}
+		if o.MaxShards > 0 {
+			maxShards = o.MaxShards
+		}
+		switch {
+		case o.MinShards < 0 || o.MaxShards < 0:
+			return errors.New("min_shards and max_shards must not be negative")
+		case minShards > maxShards:
+			return fmt.Errorf("min_shards (%d) must not be greater than max_shards (%d)", minShards, maxShards)
+		}
+		next[remoteName] = *o
+	}
+
+	prev := i.shardOverrides
+	i.shardOverrides = next
+	if i.remoteStore == nil {
+		return nil
+	}
+
+	if err := i.applyRemoteWrite(&i.cfg); err != nil {
+		i.shardOverrides = prev
+		return fmt.Errorf("failed to apply remote_write configs: %w", err)
+	}
+	return nil
+}
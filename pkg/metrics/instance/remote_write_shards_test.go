@@ -0,0 +1,66 @@
+package instance
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_applyShardOverrides(t *testing.T) {
+	cfg, err := UnmarshalConfig(strings.NewReader(failoverTestConfig))
+	require.NoError(t, err)
+	require.NoError(t, cfg.ApplyDefaults(DefaultGlobalConfig))
+
+	var (
+		defaultMin = cfg.RemoteWrite[0].QueueConfig.MinShards
+		defaultMax = cfg.RemoteWrite[0].QueueConfig.MaxShards
+	)
+
+	res := applyShardOverrides(cfg.RemoteWrite, map[string]ShardOverride{
+		"primary":   {MinShards: 5, MaxShards: 10},
+		"secondary": {MinShards: 2},
+	})
+	require.Len(t, res, len(cfg.RemoteWrite))
+
+	require.Equal(t, 5, res[0].QueueConfig.MinShards)
+	require.Equal(t, 10, res[0].QueueConfig.MaxShards)
+	require.Equal(t, 2, res[1].QueueConfig.MinShards)
+	require.Equal(t, defaultMax, res[1].QueueConfig.MaxShards)
+	require.Same(t, cfg.RemoteWrite[2], res[2], "endpoints without overrides should be unchanged")
+
+	// The loaded config must not be modified.
+	require.Equal(t, defaultMin, cfg.RemoteWrite[0].QueueConfig.MinShards)
+	require.Equal(t, defaultMax, cfg.RemoteWrite[0].QueueConfig.MaxShards)
+}
+
+func TestInstance_SetShardOverride(t *testing.T) {
+	cfg, err := UnmarshalConfig(strings.NewReader(failoverTestConfig))
+	require.NoError(t, err)
+	require.NoError(t, cfg.ApplyDefaults(DefaultGlobalConfig))
+
+	inst := &Instance{cfg: *cfg}
+
+	err = inst.SetShardOverride("missing", &ShardOverride{MinShards: 2})
+	require.True(t, errors.Is(err, ErrUnknownRemoteWrite))
+
+	err = inst.SetShardOverride("primary", &ShardOverride{MinShards: 10, MaxShards: 5})
+	require.EqualError(t, err, "min_shards (10) must not be greater than max_shards (5)")
+
+	err = inst.SetShardOverride("primary", &ShardOverride{MinShards: -1})
+	require.EqualError(t, err, "min_shards and max_shards must not be negative")
+
+	require.NoError(t, inst.SetShardOverride("primary", &ShardOverride{MinShards: 4, MaxShards: 8}))
+
+	shards := inst.RemoteWriteShards()
+	require.Len(t, shards, len(cfg.RemoteWrite))
+	require.Equal(t, "primary", shards[0].RemoteName)
+	require.Equal(t, 4, shards[0].MinShards)
+	require.Equal(t, 8, shards[0].MaxShards)
+	require.Equal(t, &ShardOverride{MinShards: 4, MaxShards: 8}, shards[0].Override)
+	require.Nil(t, shards[1].Override)
+
+	require.NoError(t, inst.SetShardOverride("primary", nil))
+	require.Nil(t, inst.RemoteWriteShards()[0].Override)
+}
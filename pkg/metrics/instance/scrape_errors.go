@@ -0,0 +1,146 @@
+package instance
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+)
+
+const (
+	// scrapeErrorsPerTarget is the number of recent errors kept per target.
+	scrapeErrorsPerTarget = 10
+	// maxScrapeErrorLength is the maximum length of a recorded error message.
+	// Longer messages, such as ones embedding a response body, are truncated.
+	maxScrapeErrorLength = 1024
+	// scrapeErrorsRetention is how long errors of a target are kept after its
+	// most recent error, so targets which went away are forgotten.
+	scrapeErrorsRetention = time.Hour
+)
+
+// TargetScrapeErrors holds the most recent scrape errors of a target.
+type TargetScrapeErrors struct {
+	Job    string        `json:"job"`
+	Target string        `json:"target"`
+	Errors []ScrapeError `json:"errors"`
+}
+
+// ScrapeError is a failed scrape of a target.
+type ScrapeError struct {
+	Timestamp time.Time `json:"timestamp"`
+	Error     string    `json:"error"`
+}
+
+type scrapeErrorsKey struct {
+	job, target string
+}
+
+// scrapeErrorLog records the most recent scrape errors of each target. Errors
+// are collected from the log lines the scrape manager writes for failed
+// scrapes, so they're recorded even when debug logging is disabled.
+type scrapeErrorLog struct {
+	mut       sync.Mutex
+	targets   map[scrapeErrorsKey][]ScrapeError
+	lastPrune time.Time
+
+	now func() time.Time
+}
+
+func newScrapeErrorLog() *scrapeErrorLog {
+	return &scrapeErrorLog{
+		targets: make(map[scrapeErrorsKey][]ScrapeError),
+		now:     time.Now,
+	}
+}
+
+// Wrap returns a logger which records scrape errors logged to it before
+// passing log lines to next.
+func (l *scrapeErrorLog) Wrap(next log.Logger) log.Logger {
+	return log.LoggerFunc(func(keyvals ...interface{}) error {
+		l.observe(keyvals)
+		return next.Log(keyvals...)
+	})
+}
+
+// observe records the error from keyvals if they're from a failed scrape.
+func (l *scrapeErrorLog) observe(keyvals []interface{}) {
+	var (
+		msg, job, target string
+		err              interface{}
+	)
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		switch keyvals[i] {
+		case "msg":
+			msg = fmt.Sprint(keyvals[i+1])
+		case "scrape_pool":
+			job = fmt.Sprint(keyvals[i+1])
+		case "target":
+			target = fmt.Sprint(keyvals[i+1])
+		case "err":
+			err = keyvals[i+1]
+		}
+	}
+	if err == nil || target == "" || (msg != "Scrape failed" && msg != "Append failed") {
+		return
+	}
+	l.record(job, target, fmt.Sprint(err))
+}
+
+func (l *scrapeErrorLog) record(job, target, msg string) {
+	if len(msg) > maxScrapeErrorLength {
+		msg = msg[:maxScrapeErrorLength] + "... (truncated)"
+	}
+
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	now := l.now()
+	l.prune(now)
+
+	key := scrapeErrorsKey{job: job, target: target}
+	errs := append(l.targets[key], ScrapeError{Timestamp: now, Error: msg})
+	if len(errs) > scrapeErrorsPerTarget {
+		errs = errs[len(errs)-scrapeErrorsPerTarget:]
+	}
+	l.targets[key] = errs
+}
+
+// prune forgets targets without errors in the retention period. l.mut must be
+// held when calling prune.
+func (l *scrapeErrorLog) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < scrapeErrorsRetention {
+		return
+	}
+	l.lastPrune = now
+
+	for key, errs := range l.targets {
+		if now.Sub(errs[len(errs)-1].Timestamp) > scrapeErrorsRetention {
+			delete(l.targets, key)
+		}
+	}
+}
+
+// Errors returns the recorded errors of each target, ordered by job and
+// target. Errors of a target are ordered from oldest to newest.
+func (l *scrapeErrorLog) Errors() []TargetScrapeErrors {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	res := make([]TargetScrapeErrors, 0, len(l.targets))
+	for key, errs := range l.targets {
+		res = append(res, TargetScrapeErrors{
+			Job:    key.job,
+			Target: key.target,
+			Errors: append([]ScrapeError(nil), errs...),
+		})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Job != res[j].Job {
+			return res[i].Job < res[j].Job
+		}
+		return res[i].Target < res[j].Target
+	})
+	return res
+}
@@ -0,0 +1,65 @@
+package instance
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScrapeErrorLog(t *testing.T) {
+	now := time.Unix(0, 0)
+
+	el := newScrapeErrorLog()
+	el.now = func() time.Time { return now }
+
+	// Filter out debug lines like the agent does by default; errors should
+	// be recorded anyway.
+	logger := el.Wrap(level.NewFilter(log.NewNopLogger(), level.AllowInfo()))
+	targetLogger := func(job, target string) log.Logger {
+		return log.With(log.With(logger, "scrape_pool", job), "target", target)
+	}
+
+	var (
+		a = targetLogger("job", "http://a:80/metrics")
+		b = targetLogger("job", "http://b:80/metrics")
+	)
+
+	for i := 0; i < scrapeErrorsPerTarget+2; i++ {
+		now = now.Add(time.Second)
+		level.Debug(a).Log("msg", "Scrape failed", "err", fmt.Errorf("error %d", i))
+	}
+	level.Warn(b).Log("msg", "Append failed", "err", errors.New(strings.Repeat("x", maxScrapeErrorLength+1)))
+
+	// Other log lines are ignored.
+	level.Debug(b).Log("msg", "Scrape succeeded")
+	level.Info(logger).Log("msg", "Scrape failed", "err", errors.New("no target"))
+
+	res := el.Errors()
+	require.Len(t, res, 2)
+
+	require.Equal(t, "job", res[0].Job)
+	require.Equal(t, "http://a:80/metrics", res[0].Target)
+	require.Len(t, res[0].Errors, scrapeErrorsPerTarget)
+	require.Equal(t, "error 2", res[0].Errors[0].Error)
+	require.Equal(t, fmt.Sprintf("error %d", scrapeErrorsPerTarget+1), res[0].Errors[scrapeErrorsPerTarget-1].Error)
+
+	require.Equal(t, "http://b:80/metrics", res[1].Target)
+	require.Len(t, res[1].Errors, 1)
+	require.Equal(t, strings.Repeat("x", maxScrapeErrorLength)+"... (truncated)", res[1].Errors[0].Error)
+
+	// Targets without recent errors are forgotten.
+	now = now.Add(scrapeErrorsRetention + time.Minute)
+	level.Debug(b).Log("msg", "Scrape failed", "err", errors.New("still failing"))
+
+	res = el.Errors()
+	require.Len(t, res, 1)
+	require.Equal(t, "http://b:80/metrics", res[0].Target)
+	require.Len(t, res[0].Errors, 1)
+	require.Equal(t, "still failing", res[0].Errors[0].Error)
+}
@@ -0,0 +1,247 @@
+package metrics
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/storage"
+)
+
+// pushTimeMetric is added to every push, like the Pushgateway does, so the
+// last successful push of a group can be alerted on.
+const pushTimeMetric = "push_time_seconds"
+
+// pushedSeries is a series pushed to a group along with the name of the
+// metric family it belongs to.
+type pushedSeries struct {
+	family string
+	labels labels.Labels
+	value  float64
+}
+
+// maxPushSize is the maximum size of the body of a push.
+const maxPushSize = 16 << 20
+
+// pushGroups tracks the series last pushed to each group of every instance
+// so series which are replaced or deleted can be marked as stale in the WAL,
+// emulating the Pushgateway forgetting them. Like the Pushgateway, groups are
+// kept until they're deleted, so memory grows with the number of groups
+// pushed to. Groups of instances which no longer exist are dropped by Prune.
+type pushGroups struct {
+	mut       sync.Mutex
+	instances map[string]*instancePushGroups
+}
+
+// instancePushGroups are the groups pushed to a single instance. Pushes to
+// the same instance are serialized while pushes to different instances
+// aren't.
+type instancePushGroups struct {
+	mut sync.Mutex
+	// groups maps the key of a group to the series pushed to it by metric
+	// family and series hash.
+	groups map[string]map[string]map[uint64]labels.Labels
+}
+
+func newPushGroups() *pushGroups {
+	return &pushGroups{
+		instances: make(map[string]*instancePushGroups),
+	}
+}
+
+// instance returns the groups of the named instance, creating them if they
+// don't exist yet.
+func (g *pushGroups) instance(name string) *instancePushGroups {
+	g.mut.Lock()
+	defer g.mut.Unlock()
+
+	ig, ok := g.instances[name]
+	if !ok {
+		ig = &instancePushGroups{
+			groups: make(map[string]map[string]map[uint64]labels.Labels),
+		}
+		g.instances[name] = ig
+	}
+	return ig
+}
+
+// Prune drops the groups of instances for which exists returns false.
+func (g *pushGroups) Prune(exists func(instance string) bool) {
+	g.mut.Lock()
+	defer g.mut.Unlock()
+
+	for name := range g.instances {
+		if !exists(name) {
+			delete(g.instances, name)
+		}
+	}
+}
+
+// Push appends series to app at ts and commits them. If replaceAll is true,
+// series previously pushed to the group of instanceName identified by key
+// which aren't part of the push are marked as stale. Otherwise, only series
+// of the metric families being pushed are replaced. Pushing no series with
+// replaceAll deletes the group.
+func (g *pushGroups) Push(app storage.Appender, instanceName, key string, series []pushedSeries, replaceAll bool, ts time.Time) error {
+	ig := g.instance(instanceName)
+	ig.mut.Lock()
+	defer ig.mut.Unlock()
+
+	var (
+		prev = ig.groups[key]
+		next = make(map[string]map[uint64]labels.Labels, len(prev))
+	)
+	if !replaceAll {
+		pushedFamilies := make(map[string]struct{}, len(series))
+		for _, s := range series {
+			pushedFamilies[s.family] = struct{}{}
+		}
+		for family, fs := range prev {
+			if _, ok := pushedFamilies[family]; !ok {
+				next[family] = fs
+			}
+		}
+	}
+
+	millis := timestamp(ts)
+	for _, s := range series {
+		if next[s.family] == nil {
+			next[s.family] = make(map[uint64]labels.Labels)
+		}
+		next[s.family][s.labels.Hash()] = s.labels
+
+		if _, err := app.Append(0, s.labels, millis, s.value); err != nil {
+			_ = app.Rollback()
+			return fmt.Errorf("failed to append series %s: %w", s.labels, err)
+		}
+	}
+
+	for family, fs := range prev {
+		for hash, lset := range fs {
+			if _, ok := next[family][hash]; ok {
+				continue
+			}
+			if _, err := app.Append(0, lset, millis, math.Float64frombits(value.StaleNaN)); err != nil {
+				_ = app.Rollback()
+				return fmt.Errorf("failed to append stale marker for series %s: %w", lset, err)
+			}
+		}
+	}
+
+	if err := app.Commit(); err != nil {
+		return err
+	}
+
+	if len(next) == 0 {
+		delete(ig.groups, key)
+	} else {
+		ig.groups[key] = next
+	}
+	return nil
+}
+
+func timestamp(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// parseGroupingKey parses the grouping labels of a push from the path of its
+// URL after /metrics, which must be of the form
+// /job/<job>{/<label>/<value>}. Names ending in @base64 have their value
+// encoded with URL-safe base64, which allows values with slashes.
+func parseGroupingKey(path string) (labels.Labels, error) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts)%2 != 0 {
+		return nil, errors.New("grouping key must consist of label name and value pairs")
+	}
+
+	b := labels.NewBuilder(nil)
+	seen := make(map[string]struct{}, len(parts)/2)
+	for i := 0; i < len(parts); i += 2 {
+		name, val := parts[i], parts[i+1]
+		if strings.HasSuffix(name, "@base64") {
+			name = strings.TrimSuffix(name, "@base64")
+			decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(val, "="))
+			if err != nil {
+				return nil, fmt.Errorf("invalid base64 value for label %q: %w", name, err)
+			}
+			val = string(decoded)
+		}
+
+		switch {
+		case i == 0 && name != model.JobLabel:
+			return nil, errors.New("grouping key must start with the job label")
+		case !model.LabelName(name).IsValid() || strings.HasPrefix(name, model.ReservedLabelPrefix):
+			return nil, fmt.Errorf("invalid label name %q", name)
+		case name == model.JobLabel && val == "":
+			return nil, errors.New("job must not be empty")
+		}
+		if _, ok := seen[name]; ok {
+			return nil, fmt.Errorf("label %q is repeated", name)
+		}
+		seen[name] = struct{}{}
+
+		if val != "" {
+			b.Set(name, val)
+		}
+	}
+	return b.Labels(), nil
+}
+
+// decodePush decodes the metric families in a push in the Prometheus text or
+// protobuf format into series. Grouping labels are added to every series,
+// replacing labels of the same name.
+func decodePush(body io.Reader, format expfmt.Format, grouping labels.Labels, ts time.Time) ([]pushedSeries, error) {
+	var (
+		dec  = expfmt.NewDecoder(body, format)
+		opts = &expfmt.DecodeOptions{Timestamp: model.TimeFromUnixNano(ts.UnixNano())}
+		res  []pushedSeries
+	)
+	for {
+		var mf dto.MetricFamily
+		if err := dec.Decode(&mf); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		for _, m := range mf.GetMetric() {
+			if m.TimestampMs != nil {
+				return nil, fmt.Errorf("pushed metric %s must not have a timestamp", mf.GetName())
+			}
+		}
+		if mf.GetName() == pushTimeMetric {
+			return nil, fmt.Errorf("pushed metrics must not include %s", pushTimeMetric)
+		}
+
+		samples, err := expfmt.ExtractSamples(opts, &mf)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range samples {
+			b := labels.NewBuilder(nil)
+			for name, val := range s.Metric {
+				b.Set(string(name), string(val))
+			}
+			for _, l := range grouping {
+				b.Set(l.Name, l.Value)
+			}
+			res = append(res, pushedSeries{
+				family: mf.GetName(),
+				labels: b.Labels(),
+				value:  float64(s.Value),
+			})
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,245 @@
+package metrics
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/gorilla/mux"
+	"github.com/grafana/agent/pkg/metrics/instance"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseGroupingKey(t *testing.T) {
+	tt := []struct {
+		name   string
+		path   string
+		expect labels.Labels
+		err    string
+	}{
+		{
+			name:   "job only",
+			path:   "/job/backup",
+			expect: labels.FromStrings("job", "backup"),
+		},
+		{
+			name:   "extra labels",
+			path:   "/job/backup/instance/db-1/zone/a",
+			expect: labels.FromStrings("job", "backup", "instance", "db-1", "zone", "a"),
+		},
+		{
+			name:   "base64 values",
+			path:   "/job@base64/" + base64.RawURLEncoding.EncodeToString([]byte("a/b")) + "/instance@base64/=",
+			expect: labels.FromStrings("job", "a/b"),
+		},
+		{name: "missing job", path: "/instance/db-1", err: "grouping key must start with the job label"},
+		{name: "odd parts", path: "/job/backup/instance", err: "grouping key must consist of label name and value pairs"},
+		{name: "empty job", path: "/job@base64/=", err: "job must not be empty"},
+		{name: "reserved label", path: "/job/backup/__name__/x", err: `invalid label name "__name__"`},
+		{name: "repeated label", path: "/job/backup/job/other", err: `label "job" is repeated`},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := parseGroupingKey(tc.path)
+			if tc.err != "" {
+				require.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expect, actual)
+		})
+	}
+}
+
+func TestAgent_PushgatewayHandler(t *testing.T) {
+	fact := newFakeInstanceFactory()
+	a, err := newAgent(prometheus.NewRegistry(), Config{
+		WALDir: "/tmp/agent",
+	}, log.NewNopLogger(), fact.factory)
+	require.NoError(t, err)
+	defer a.Stop()
+
+	app := &recordingAppender{}
+	mockManager := &instance.MockManager{
+		GetInstanceFunc: func(name string) (instance.ManagedInstance, error) {
+			return &mockInstanceAppender{app: app}, nil
+		},
+		ListConfigsFunc: func() map[string]instance.Config {
+			return map[string]instance.Config{"test": {Name: "test"}}
+		},
+		StopFunc: func() {},
+	}
+	a.mm, err = instance.NewModalManager(prometheus.NewRegistry(), a.logger, mockManager, instance.ModeDistinct)
+	require.NoError(t, err)
+
+	r := mux.NewRouter()
+	a.WireAPI(r)
+
+	push := func(method, body string) int {
+		req := httptest.NewRequest(method, "/metrics/instance/test/push/metrics/job/backup/instance/db-1", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	t.Run("put", func(t *testing.T) {
+		app.reset()
+		require.Equal(t, http.StatusOK, push("PUT", "last_success 100\nrows{table=\"a\"} 10\nrows{table=\"b\"} 20\n"))
+		require.Equal(t, map[string]float64{
+			`{__name__="last_success", instance="db-1", job="backup"}`:    100,
+			`{__name__="rows", instance="db-1", job="backup", table="a"}`: 10,
+			`{__name__="rows", instance="db-1", job="backup", table="b"}`: 20,
+		}, app.values(false))
+		require.Contains(t, app.values(true), `{__name__="push_time_seconds", instance="db-1", job="backup"}`)
+		require.True(t, app.committed)
+	})
+
+	t.Run("post replaces pushed families", func(t *testing.T) {
+		app.reset()
+		require.Equal(t, http.StatusOK, push("POST", "rows{table=\"a\"} 11\n"))
+		require.Equal(t, map[string]float64{
+			`{__name__="rows", instance="db-1", job="backup", table="a"}`: 11,
+		}, app.values(false))
+		require.Equal(t, []string{`{__name__="rows", instance="db-1", job="backup", table="b"}`}, app.stale())
+	})
+
+	t.Run("put replaces everything", func(t *testing.T) {
+		app.reset()
+		require.Equal(t, http.StatusOK, push("PUT", "rows{table=\"c\"} 30\n"))
+		require.ElementsMatch(t, []string{
+			`{__name__="last_success", instance="db-1", job="backup"}`,
+			`{__name__="rows", instance="db-1", job="backup", table="a"}`,
+		}, app.stale())
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		app.reset()
+		require.Equal(t, http.StatusAccepted, push("DELETE", ""))
+		require.ElementsMatch(t, []string{
+			`{__name__="push_time_seconds", instance="db-1", job="backup"}`,
+			`{__name__="rows", instance="db-1", job="backup", table="c"}`,
+		}, app.stale())
+	})
+
+	t.Run("timestamps are rejected", func(t *testing.T) {
+		app.reset()
+		require.Equal(t, http.StatusBadRequest, push("PUT", "rows{table=\"a\"} 10 1000\n"))
+		require.Empty(t, app.samples)
+	})
+
+	t.Run("oversized pushes are rejected", func(t *testing.T) {
+		app.reset()
+		body := strings.Repeat("# padding\n", maxPushSize/10+1)
+		require.Equal(t, http.StatusRequestEntityTooLarge, push("PUT", body))
+
+		// Bodies without a content length are limited while they're read.
+		req := httptest.NewRequest("PUT", "/metrics/instance/test/push/metrics/job/backup", io.MultiReader(strings.NewReader(body)))
+		req.ContentLength = -1
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+		require.Empty(t, app.samples)
+	})
+}
+
+func TestPushGroups_Prune(t *testing.T) {
+	var (
+		g      = newPushGroups()
+		app    = &recordingAppender{}
+		series = []pushedSeries{{
+			family: "rows",
+			labels: labels.FromStrings(labels.MetricName, "rows", "job", "backup"),
+			value:  1,
+		}}
+	)
+	require.NoError(t, g.Push(app, "a", `{job="backup"}`, series, true, time.Now()))
+	require.NoError(t, g.Push(app, "b", `{job="backup"}`, series, true, time.Now()))
+
+	g.Prune(func(name string) bool { return name == "a" })
+	require.Contains(t, g.instances, "a")
+	require.NotContains(t, g.instances, "b")
+
+	// Pushing again to a pruned instance doesn't mark the forgotten series as
+	// stale.
+	app.reset()
+	require.NoError(t, g.Push(app, "b", `{job="backup"}`, nil, true, time.Now()))
+	require.Empty(t, app.stale())
+}
+
+type mockInstanceAppender struct {
+	instance.NoOpInstance
+	app storage.Appender
+}
+
+func (i *mockInstanceAppender) Appender(_ context.Context) storage.Appender {
+	return i.app
+}
+
+type recordedSample struct {
+	labels labels.Labels
+	value  float64
+}
+
+type recordingAppender struct {
+	samples   []recordedSample
+	committed bool
+}
+
+func (a *recordingAppender) reset() {
+	a.samples = nil
+	a.committed = false
+}
+
+// values returns the non-stale samples by series, leaving out push_time_seconds
+// unless withPushTime is true.
+func (a *recordingAppender) values(withPushTime bool) map[string]float64 {
+	res := make(map[string]float64)
+	for _, s := range a.samples {
+		if value.IsStaleNaN(s.value) || (!withPushTime && s.labels.Get(labels.MetricName) == pushTimeMetric) {
+			continue
+		}
+		res[s.labels.String()] = s.value
+	}
+	return res
+}
+
+func (a *recordingAppender) stale() []string {
+	var res []string
+	for _, s := range a.samples {
+		if value.IsStaleNaN(s.value) {
+			res = append(res, s.labels.String())
+		}
+	}
+	return res
+}
+
+func (a *recordingAppender) Append(_ storage.SeriesRef, l labels.Labels, _ int64, v float64) (storage.SeriesRef, error) {
+	a.samples = append(a.samples, recordedSample{labels: l, value: v})
+	return 0, nil
+}
+
+func (a *recordingAppender) AppendExemplar(_ storage.SeriesRef, _ labels.Labels, _ exemplar.Exemplar) (storage.SeriesRef, error) {
+	return 0, nil
+}
+
+func (a *recordingAppender) Commit() error {
+	a.committed = true
+	return nil
+}
+
+func (a *recordingAppender) Rollback() error {
+	a.samples = nil
+	return nil
+}
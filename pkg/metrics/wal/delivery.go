@@ -0,0 +1,175 @@
+package wal
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/tsdb/wal"
+)
+
+// DefaultDeliveryConfig holds the default settings for DeliveryConfig.
+var DefaultDeliveryConfig = DeliveryConfig{
+	MaxWALSizeBytes: 10 << 30, // 10GiB
+}
+
+// DeliveryConfig configures guaranteed delivery. With guaranteed delivery,
+// Truncate doesn't discard segments holding samples which are newer than the
+// timestamp it is given, which callers derive from the samples acknowledged
+// by remote_write. Segments are only discarded regardless once the WAL grows
+// larger than MaxWALSizeBytes, bounding the disk space used while remote
+// endpoints are unavailable.
+type DeliveryConfig struct {
+	// The size of the WAL directory above which segments are truncated even
+	// if they hold unacknowledged samples.
+	MaxWALSizeBytes int64 `yaml:"max_wal_size_bytes,omitempty"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (c *DeliveryConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultDeliveryConfig
+
+	type plain DeliveryConfig
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+	return c.Validate()
+}
+
+// Validate returns an error if c is invalid.
+func (c *DeliveryConfig) Validate() error {
+	if c.MaxWALSizeBytes <= 0 {
+		return errors.New("max_wal_size_bytes must be greater than 0")
+	}
+	return nil
+}
+
+type deliveryMetrics struct {
+	r prometheus.Registerer
+
+	unacknowledgedSegments prometheus.Gauge
+	forcedTruncations      prometheus.Counter
+}
+
+func newDeliveryMetrics(r prometheus.Registerer) *deliveryMetrics {
+	m := deliveryMetrics{r: r}
+	m.unacknowledgedSegments = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "agent_wal_unacknowledged_segments",
+		Help: "Number of WAL segments holding samples which were not acknowledged as of the most recent truncation",
+	})
+
+	m.forcedTruncations = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "agent_wal_unacknowledged_truncations_total",
+		Help: "Total number of truncations which discarded unacknowledged samples because the WAL exceeded its maximum size",
+	})
+
+	if r != nil {
+		r.MustRegister(
+			m.unacknowledgedSegments,
+			m.forcedTruncations,
+		)
+	}
+
+	return &m
+}
+
+func (m *deliveryMetrics) Unregister() {
+	if m.r == nil {
+		return
+	}
+	cs := []prometheus.Collector{
+		m.unacknowledgedSegments,
+		m.forcedTruncations,
+	}
+	for _, c := range cs {
+		m.r.Unregister(c)
+	}
+}
+
+// segmentTimes tracks the newest timestamp of the samples and exemplars
+// written to each segment.
+//
+// Only segments written since the WAL was opened are tracked. Samples in
+// segments written before then aren't sent by remote_write after a restart,
+// so there is nothing to wait for.
+type segmentTimes struct {
+	mut  sync.Mutex
+	maxT map[int]int64
+}
+
+// observe records that data up to maxT was written to the segments from
+// through to.
+func (st *segmentTimes) observe(from, to int, maxT int64) {
+	st.mut.Lock()
+	defer st.mut.Unlock()
+
+	if st.maxT == nil {
+		st.maxT = make(map[int]int64)
+	}
+	for seg := from; seg <= to; seg++ {
+		if cur, ok := st.maxT[seg]; !ok || maxT > cur {
+			st.maxT[seg] = maxT
+		}
+	}
+}
+
+// firstNewer returns the first segment from through to with data at or after
+// mint, and the number of such segments.
+func (st *segmentTimes) firstNewer(from, to int, mint int64) (first, count int) {
+	st.mut.Lock()
+	defer st.mut.Unlock()
+
+	first = -1
+	for seg := from; seg <= to; seg++ {
+		if maxT, ok := st.maxT[seg]; ok && maxT >= mint {
+			if first == -1 {
+				first = seg
+			}
+			count++
+		}
+	}
+	return first, count
+}
+
+// drop stops tracking segments before i.
+func (st *segmentTimes) drop(i int) {
+	st.mut.Lock()
+	defer st.mut.Unlock()
+
+	for seg := range st.maxT {
+		if seg < i {
+			delete(st.maxT, seg)
+		}
+	}
+}
+
+// limitToAcknowledged returns the last segment from first through last which
+// may be checkpointed without discarding samples at or after mint. If the
+// WAL is larger than its maximum size, last is returned as is.
+func (w *Storage) limitToAcknowledged(first, last int, mint int64) int {
+	_, lastSegment, err := wal.Segments(w.wal.Dir())
+	if err != nil {
+		level.Warn(w.logger).Log("msg", "could not get WAL segments", "err", err)
+		lastSegment = last
+	}
+	unacked, count := w.segmentTimes.firstNewer(first, lastSegment, mint)
+	w.deliveryMetrics.unacknowledgedSegments.Set(float64(count))
+	if unacked == -1 || unacked > last {
+		return last
+	}
+
+	size, err := w.wal.Size()
+	if err != nil {
+		level.Warn(w.logger).Log("msg", "could not get WAL size, keeping unacknowledged segments", "err", err)
+		return unacked - 1
+	}
+	if size > w.delivery.MaxWALSizeBytes {
+		level.Warn(w.logger).Log("msg", "WAL exceeds its maximum size, truncating unacknowledged segments", "size", size, "max_size", w.delivery.MaxWALSizeBytes, "first_unacknowledged", unacked)
+		w.deliveryMetrics.forcedTruncations.Inc()
+		return last
+	}
+
+	level.Debug(w.logger).Log("msg", "keeping unacknowledged WAL segments", "first_unacknowledged", unacked)
+	return unacked - 1
+}
@@ -0,0 +1,75 @@
+package wal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/tsdb/wal"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestDeliveryConfig_Unmarshal(t *testing.T) {
+	var cfg DeliveryConfig
+	require.NoError(t, yaml.UnmarshalStrict([]byte(`{}`), &cfg))
+	require.Equal(t, DefaultDeliveryConfig, cfg)
+
+	err := yaml.UnmarshalStrict([]byte(`max_wal_size_bytes: -1`), &cfg)
+	require.EqualError(t, err, "max_wal_size_bytes must be greater than 0")
+}
+
+func TestStorage_TruncateDelivery(t *testing.T) {
+	// newStorage creates a WAL with a sample at t=100 in segment 0, a sample at
+	// t=200 in segment 1, and enough empty segments after it to be truncated.
+	newStorage := func(t *testing.T, cfg DeliveryConfig) *Storage {
+		s, err := NewStorageWithOptions(log.NewNopLogger(), nil, t.TempDir(), Options{Delivery: &cfg})
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			require.NoError(t, s.Close())
+		})
+
+		for _, ts := range []int64{100, 200} {
+			app := s.Appender(context.Background())
+			_, err := app.Append(0, labels.FromStrings("__name__", "foo"), ts, 1)
+			require.NoError(t, err)
+			require.NoError(t, app.Commit())
+			require.NoError(t, s.wal.NextSegment())
+		}
+		for i := 0; i < 4; i++ {
+			require.NoError(t, s.wal.NextSegment())
+		}
+		return s
+	}
+	firstSegment := func(t *testing.T, s *Storage) int {
+		first, _, err := wal.Segments(s.wal.Dir())
+		require.NoError(t, err)
+		return first
+	}
+
+	t.Run("unacknowledged segments are kept", func(t *testing.T) {
+		s := newStorage(t, DefaultDeliveryConfig)
+
+		// Segment 1 holds a sample newer than mint, so only segment 0 could be
+		// truncated, which isn't worth checkpointing.
+		require.NoError(t, s.Truncate(150))
+		require.Equal(t, 0, firstSegment(t, s))
+		require.Equal(t, 1.0, testutil.ToFloat64(s.deliveryMetrics.unacknowledgedSegments))
+
+		// Truncate starts a new segment each time, so more segments are
+		// truncated the second time around.
+		require.NoError(t, s.Truncate(250))
+		require.Equal(t, 5, firstSegment(t, s))
+		require.Equal(t, 0.0, testutil.ToFloat64(s.deliveryMetrics.unacknowledgedSegments))
+	})
+
+	t.Run("unacknowledged segments are truncated above the maximum size", func(t *testing.T) {
+		s := newStorage(t, DeliveryConfig{MaxWALSizeBytes: 1})
+
+		require.NoError(t, s.Truncate(150))
+		require.Equal(t, 4, firstSegment(t, s))
+		require.Equal(t, 1.0, testutil.ToFloat64(s.deliveryMetrics.forcedTruncations))
+	})
+}
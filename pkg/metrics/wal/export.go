@@ -0,0 +1,229 @@
+package wal
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/tsdb/chunks"
+	"github.com/prometheus/prometheus/tsdb/record"
+	"github.com/prometheus/prometheus/tsdb/wal"
+)
+
+// DefaultExportConfig holds the default settings for ExportConfig.
+var DefaultExportConfig = ExportConfig{
+	Retention: 7 * 24 * time.Hour,
+}
+
+// ExportConfig configures exporting samples to local files before they are
+// discarded by WAL truncation. Exported samples are written as CSV files with
+// one sample per row, which can be loaded by most analytics tools.
+type ExportConfig struct {
+	// Directory to write exported files to.
+	Directory string `yaml:"directory"`
+	// Exported files older than Retention are deleted.
+	Retention time.Duration `yaml:"retention,omitempty"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (c *ExportConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultExportConfig
+
+	type plain ExportConfig
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+	return c.Validate()
+}
+
+// Validate returns an error if c is invalid.
+func (c *ExportConfig) Validate() error {
+	if c.Directory == "" {
+		return errors.New("export directory must be set")
+	}
+	if c.Retention <= 0 {
+		return errors.New("export retention must be greater than 0s")
+	}
+	return nil
+}
+
+const (
+	exportFilePrefix = "samples-"
+	exportFileSuffix = ".csv"
+)
+
+// exportTruncated exports samples which are about to be discarded by
+// checkpointing segments from through to, and deletes exported files older
+// than the retention period.
+//
+// A sample is discarded if it's older than mint or if its series isn't kept.
+// Samples are read from the last checkpoint as well as the segments, since
+// samples that were previously kept in the checkpoint may now be discarded.
+func (w *Storage) exportTruncated(from, to int, keep func(id chunks.HeadSeriesRef) bool, mint int64) error {
+	now := w.clock.Now()
+	if err := os.MkdirAll(w.export.Directory, 0o750); err != nil {
+		return fmt.Errorf("create export directory: %w", err)
+	}
+
+	var ranges []wal.SegmentRange
+	dir, idx, err := wal.LastCheckpoint(w.wal.Dir())
+	if err != nil && err != record.ErrNotFound {
+		return fmt.Errorf("find last checkpoint: %w", err)
+	} else if err == nil {
+		ranges = append(ranges, wal.SegmentRange{Dir: dir, Last: math.MaxInt32})
+		from = idx + 1
+	}
+	ranges = append(ranges, wal.SegmentRange{Dir: w.wal.Dir(), First: from, Last: to})
+
+	sr, err := wal.NewSegmentsRangeReader(ranges...)
+	if err != nil {
+		return fmt.Errorf("create segment reader: %w", err)
+	}
+	defer sr.Close()
+
+	name := filepath.Join(w.export.Directory, fmt.Sprintf("%s%d-%d%s", exportFilePrefix, now.UnixMilli(), to, exportFileSuffix))
+	exported, err := writeExportFile(name, wal.NewReader(sr), keep, mint)
+	if err != nil {
+		return err
+	}
+	w.metrics.totalExportedSamples.Add(float64(exported))
+	level.Info(w.logger).Log("msg", "exported truncated samples", "file", name, "samples", exported)
+
+	return pruneExports(w.export.Directory, now.Add(-w.export.Retention))
+}
+
+// writeExportFile writes discarded samples read from r into a new file at
+// path. The file is only created if at least one sample is exported.
+func writeExportFile(path string, r *wal.Reader, keep func(id chunks.HeadSeriesRef) bool, mint int64) (exported int, err error) {
+	var (
+		dec     record.Decoder
+		series  = map[chunks.HeadSeriesRef]labels.Labels{}
+		samples []record.RefSample
+
+		tmpPath = path + ".tmp"
+		f       *os.File
+		cw      *csv.Writer
+	)
+	defer func() {
+		if f != nil {
+			f.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	for r.Next() {
+		rec := r.Record()
+		switch dec.Type(rec) {
+		case record.Series:
+			refSeries, err := dec.Series(rec, nil)
+			if err != nil {
+				return 0, fmt.Errorf("decode series: %w", err)
+			}
+			for _, s := range refSeries {
+				series[s.Ref] = s.Labels
+			}
+
+		case record.Samples:
+			samples, err = dec.Samples(rec, samples[:0])
+			if err != nil {
+				return 0, fmt.Errorf("decode samples: %w", err)
+			}
+			for _, s := range samples {
+				if (s.T >= mint && keep(s.Ref)) || value.IsStaleNaN(s.V) {
+					continue
+				}
+				lset, ok := series[s.Ref]
+				if !ok {
+					continue
+				}
+
+				if f == nil {
+					if f, err = os.Create(tmpPath); err != nil {
+						return 0, fmt.Errorf("create export file: %w", err)
+					}
+					cw = csv.NewWriter(f)
+					if err := cw.Write([]string{"series", "timestamp", "value"}); err != nil {
+						return 0, err
+					}
+				}
+
+				row := []string{lset.String(), strconv.FormatInt(s.T, 10), strconv.FormatFloat(s.V, 'g', -1, 64)}
+				if err := cw.Write(row); err != nil {
+					return 0, fmt.Errorf("write export file: %w", err)
+				}
+				exported++
+			}
+		}
+	}
+	if err := r.Err(); err != nil && err != io.EOF {
+		return 0, fmt.Errorf("read segments: %w", err)
+	}
+	if f == nil {
+		return 0, nil
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return 0, fmt.Errorf("write export file: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return 0, fmt.Errorf("sync export file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return 0, fmt.Errorf("close export file: %w", err)
+	}
+	f = nil
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return 0, fmt.Errorf("rename export file: %w", err)
+	}
+	return exported, nil
+}
+
+// pruneExports deletes exported files in dir which were created before
+// cutoff, based on the creation time stored in their file names.
+func pruneExports(dir string, cutoff time.Time) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("read export directory: %w", err)
+	}
+	for _, e := range entries {
+		created, ok := exportFileTime(e.Name())
+		if e.IsDir() || !ok || !created.Before(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, e.Name())); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("delete expired export file: %w", err)
+		}
+	}
+	return nil
+}
+
+// exportFileTime returns the creation time of an exported file from its name.
+// ok is false if name isn't the name of an exported file.
+func exportFileTime(name string) (created time.Time, ok bool) {
+	if !strings.HasPrefix(name, exportFilePrefix) || !strings.HasSuffix(name, exportFileSuffix) {
+		return time.Time{}, false
+	}
+	name = strings.TrimSuffix(strings.TrimPrefix(name, exportFilePrefix), exportFileSuffix)
+
+	ms, _, found := strings.Cut(name, "-")
+	if !found {
+		return time.Time{}, false
+	}
+	unixMs, err := strconv.ParseInt(ms, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(unixMs), true
+}
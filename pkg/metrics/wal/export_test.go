@@ -0,0 +1,81 @@
+package wal
+
+import (
+	"context"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func TestExportConfig_Unmarshal(t *testing.T) {
+	var cfg ExportConfig
+	require.NoError(t, yaml.UnmarshalStrict([]byte(`directory: /tmp/export`), &cfg))
+	require.Equal(t, ExportConfig{Directory: "/tmp/export", Retention: 7 * 24 * time.Hour}, cfg)
+
+	err := yaml.UnmarshalStrict([]byte(`retention: 1h`), &cfg)
+	require.EqualError(t, err, "export directory must be set")
+}
+
+func TestStorage_TruncateExport(t *testing.T) {
+	walDir := t.TempDir()
+	exportDir := t.TempDir()
+
+	now := time.Date(2022, time.June, 1, 0, 0, 0, 0, time.UTC)
+
+	// Pre-create an expired export file which should be deleted.
+	expiredFile := filepath.Join(exportDir, "samples-1000-5.csv")
+	require.NoError(t, os.WriteFile(expiredFile, nil, 0o644))
+
+	s, err := NewStorageWithOptions(log.NewNopLogger(), nil, walDir, Options{
+		Clock:  testingclock.NewFakeClock(now),
+		Export: &ExportConfig{Directory: exportDir, Retention: time.Hour},
+	})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, s.Close())
+	}()
+
+	app := s.Appender(context.Background())
+	payload := buildSeries([]string{"foo", "bar", "baz", "blerg"})
+	for _, metric := range payload {
+		metric.Write(t, app)
+	}
+	require.NoError(t, app.Commit())
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, s.wal.NextSegment())
+	}
+
+	// Truncate the first sample of each series, which should get exported.
+	keepTs := payload[len(payload)-1].samples[0].ts + 1
+	require.NoError(t, s.Truncate(keepTs))
+
+	_, err = os.Stat(expiredFile)
+	require.True(t, os.IsNotExist(err), "expired export file should be deleted")
+
+	files, err := filepath.Glob(filepath.Join(exportDir, "samples-*.csv"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.Contains(t, files[0], "samples-1654041600000-")
+
+	f, err := os.Open(files[0])
+	require.NoError(t, err)
+	defer f.Close()
+
+	rows, err := csv.NewReader(f).ReadAll()
+	require.NoError(t, err)
+	require.Equal(t, [][]string{
+		{"series", "timestamp", "value"},
+		{`{__name__="foo"}`, "1", "10"},
+		{`{__name__="bar"}`, "2", "20"},
+		{`{__name__="baz"}`, "3", "30"},
+		{`{__name__="blerg"}`, "4", "40"},
+	}, rows)
+}
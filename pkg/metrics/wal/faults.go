@@ -0,0 +1,78 @@
+package wal
+
+import (
+	"fmt"
+	"sync"
+	"syscall"
+	"time"
+
+	"k8s.io/utils/clock"
+)
+
+// Clock is the source of time used by Storage. It is satisfied by
+// k8s.io/utils/clock.Clock, so clock.RealClock and the fake clocks from
+// k8s.io/utils/clock/testing can be used directly.
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	After(d time.Duration) <-chan time.Time
+	Sleep(d time.Duration)
+}
+
+var _ Clock = clock.RealClock{}
+
+// Faults holds optional fault injection points for a Storage. Faults are
+// intended for tests which need to deterministically exercise failure
+// handling of Storage and the components built on top of it.
+//
+// Any nil field is ignored.
+type Faults struct {
+	// BeforeLog is invoked before every record is written to the underlying
+	// WAL. Returning a non-nil error aborts the write and the error is
+	// returned by the appender's Commit.
+	//
+	// The underlying WAL fsyncs segments synchronously from within its Log
+	// call, so delaying BeforeLog can be used to simulate slow fsyncs.
+	BeforeLog func(rec []byte) error
+
+	// BeforeCheckpoint is invoked by Truncate before a checkpoint is created.
+	// Returning a non-nil error aborts the truncation.
+	BeforeCheckpoint func() error
+
+	// BeforeSegmentTruncate is invoked by Truncate before old segments are
+	// deleted. Returning a non-nil error skips deleting segments, which
+	// matches how Truncate handles a real deletion failure.
+	BeforeSegmentTruncate func() error
+}
+
+// ErrDiskFull is an injectable error which simulates a full disk. It wraps
+// syscall.ENOSPC so it can be detected with errors.Is.
+var ErrDiskFull = fmt.Errorf("injected fault: %w", syscall.ENOSPC)
+
+// FailLogAfter returns a Faults.BeforeLog function which allows n records to
+// be written successfully and fails every write afterwards with err.
+func FailLogAfter(n int, err error) func(rec []byte) error {
+	var (
+		mut     sync.Mutex
+		written int
+	)
+	return func(_ []byte) error {
+		mut.Lock()
+		defer mut.Unlock()
+
+		if written >= n {
+			return err
+		}
+		written++
+		return nil
+	}
+}
+
+// DelayLog returns a Faults.BeforeLog function which sleeps for d using c
+// before every record is written.
+func DelayLog(c Clock, d time.Duration) func(rec []byte) error {
+	return func(_ []byte) error {
+		c.Sleep(d)
+		return nil
+	}
+}
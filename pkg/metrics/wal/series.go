@@ -227,6 +227,26 @@ func (s *stripeSeries) set(hash uint64, series *memSeries) {
 	s.locks[i].Unlock()
 }
 
+// relabel changes the labels of series to lset, moving it to the hash of its
+// new label set.
+func (s *stripeSeries) relabel(series *memSeries, lset labels.Labels) {
+	oldHash := series.lset.Hash()
+	i := oldHash & uint64(s.size-1)
+	s.locks[i].Lock()
+	s.hashes[i].del(oldHash, series.ref)
+	s.locks[i].Unlock()
+
+	series.Lock()
+	series.lset = lset
+	series.Unlock()
+
+	newHash := lset.Hash()
+	i = newHash & uint64(s.size-1)
+	s.locks[i].Lock()
+	s.hashes[i].set(newHash, series)
+	s.locks[i].Unlock()
+}
+
 func (s *stripeSeries) getLatestExemplar(id chunks.HeadSeriesRef) *exemplar.Exemplar {
 	i := id & chunks.HeadSeriesRef(s.size-1)
 
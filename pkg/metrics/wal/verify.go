@@ -0,0 +1,243 @@
+package wal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/tsdb/wal"
+)
+
+// DefaultVerifyConfig holds the default settings for VerifyConfig.
+var DefaultVerifyConfig = VerifyConfig{
+	Interval: time.Hour,
+}
+
+// VerifyConfig configures verifying the checksums of WAL records. Records
+// are always verified while the WAL is replayed; with verification enabled,
+// the location of corrupt records found during replay is retained, and
+// segments are periodically re-read to detect corruption while the WAL is
+// running.
+type VerifyConfig struct {
+	// How often segments are verified while the WAL is running.
+	Interval time.Duration `yaml:"interval,omitempty"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (c *VerifyConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultVerifyConfig
+
+	type plain VerifyConfig
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+	return c.Validate()
+}
+
+// Validate returns an error if c is invalid.
+func (c *VerifyConfig) Validate() error {
+	if c.Interval <= 0 {
+		return errors.New("verify interval must be greater than 0s")
+	}
+	return nil
+}
+
+// Corruption describes the first corrupt record found in a WAL segment.
+type Corruption struct {
+	// Segment is the index of the corrupt segment.
+	Segment int `json:"segment"`
+	// Offset is the byte offset in the segment at which the first corrupt
+	// record was detected.
+	Offset int64 `json:"offset"`
+	// Error describes the corruption.
+	Error string `json:"error"`
+	// DetectedAt is the time the corruption was found.
+	DetectedAt time.Time `json:"detected_at"`
+	// Repaired is true if the corruption was found while replaying the WAL.
+	// Replay repairs the WAL by dropping the corrupt record and everything
+	// written after it.
+	Repaired bool `json:"repaired"`
+}
+
+type verifyMetrics struct {
+	r prometheus.Registerer
+
+	totalVerifications prometheus.Counter
+	corruptSegments    prometheus.Gauge
+	corruptionOffset   *prometheus.GaugeVec
+}
+
+func newVerifyMetrics(r prometheus.Registerer) *verifyMetrics {
+	m := verifyMetrics{r: r}
+	m.totalVerifications = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "agent_wal_verifications_total",
+		Help: "Total number of times the segments of the WAL were verified",
+	})
+
+	m.corruptSegments = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "agent_wal_corrupt_segments",
+		Help: "Number of WAL segments with corrupt records found by the most recent verification or replay",
+	})
+
+	m.corruptionOffset = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "agent_wal_corruption_offset_bytes",
+		Help: "Byte offset at which the first corrupt record of each corrupt WAL segment was detected",
+	}, []string{"segment"})
+
+	if r != nil {
+		r.MustRegister(
+			m.totalVerifications,
+			m.corruptSegments,
+			m.corruptionOffset,
+		)
+	}
+
+	return &m
+}
+
+func (m *verifyMetrics) Unregister() {
+	if m.r == nil {
+		return
+	}
+	cs := []prometheus.Collector{
+		m.totalVerifications,
+		m.corruptSegments,
+		m.corruptionOffset,
+	}
+	for _, c := range cs {
+		m.r.Unregister(c)
+	}
+}
+
+// corruptionTracker records corruptions found during replay and by the most
+// recent verification.
+type corruptionTracker struct {
+	mut      sync.Mutex
+	replay   []Corruption
+	verified []Corruption
+}
+
+// Verify reads every segment of the WAL which is no longer being written to
+// and checks the checksums of its records. The first corrupt record of each
+// segment is reported through metrics and Corruptions.
+//
+// Verify does nothing if verification isn't enabled.
+func (w *Storage) Verify() error {
+	if w.verify == nil {
+		return nil
+	}
+
+	w.walMtx.RLock()
+	defer w.walMtx.RUnlock()
+
+	if w.walClosed {
+		return ErrWALClosed
+	}
+
+	first, last, err := wal.Segments(w.wal.Dir())
+	if err != nil {
+		return fmt.Errorf("get segment range: %w", err)
+	}
+
+	// The last segment is still being written to, so its final record may
+	// be incomplete. It's verified the next time the WAL is replayed.
+	var found []Corruption
+	for i := first; i >= 0 && i < last; i++ {
+		c, err := verifySegment(w.wal.Dir(), i)
+		if errors.Is(err, os.ErrNotExist) {
+			// The segment was removed by a concurrent truncation.
+			continue
+		} else if err != nil {
+			return fmt.Errorf("verify segment %d: %w", i, err)
+		}
+		if c != nil {
+			c.DetectedAt = w.clock.Now()
+			level.Error(w.logger).Log("msg", "found corrupt record in WAL segment", "segment", c.Segment, "offset", c.Offset, "err", c.Error)
+			found = append(found, *c)
+		}
+	}
+
+	w.corruptions.mut.Lock()
+	w.corruptions.verified = found
+	w.corruptions.mut.Unlock()
+
+	w.verifyMetrics.totalVerifications.Inc()
+	w.updateCorruptionMetrics()
+	return nil
+}
+
+// verifySegment reads all records of segment i in dir, returning the first
+// corrupt record. A nil Corruption is returned if no records are corrupt.
+func verifySegment(dir string, i int) (*Corruption, error) {
+	s, err := wal.OpenReadSegment(wal.SegmentName(dir, i))
+	if err != nil {
+		return nil, err
+	}
+	sr := wal.NewSegmentBufReader(s)
+	defer sr.Close()
+
+	r := wal.NewReader(sr)
+	for r.Next() {
+	}
+
+	var ce *wal.CorruptionErr
+	if errors.As(r.Err(), &ce) {
+		return &Corruption{Segment: ce.Segment, Offset: ce.Offset, Error: ce.Err.Error()}, nil
+	}
+	return nil, r.Err()
+}
+
+// recordReplayCorruption records a corruption found while replaying the WAL,
+// which is about to be repaired.
+func (w *Storage) recordReplayCorruption(ce *wal.CorruptionErr) {
+	if w.verify == nil || ce.Dir != w.wal.Dir() {
+		return
+	}
+
+	w.corruptions.mut.Lock()
+	w.corruptions.replay = append(w.corruptions.replay, Corruption{
+		Segment:    ce.Segment,
+		Offset:     ce.Offset,
+		Error:      ce.Err.Error(),
+		DetectedAt: w.clock.Now(),
+		Repaired:   true,
+	})
+	w.corruptions.mut.Unlock()
+
+	w.updateCorruptionMetrics()
+}
+
+// Corruptions returns the corruptions found while replaying the WAL and by
+// the most recent call to Verify, ordered by segment. Corruptions always
+// returns nil if verification isn't enabled.
+func (w *Storage) Corruptions() []Corruption {
+	w.corruptions.mut.Lock()
+	defer w.corruptions.mut.Unlock()
+
+	var res []Corruption
+	res = append(res, w.corruptions.replay...)
+	res = append(res, w.corruptions.verified...)
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Segment < res[j].Segment })
+	return res
+}
+
+func (w *Storage) updateCorruptionMetrics() {
+	corruptions := w.Corruptions()
+
+	segments := make(map[int]struct{}, len(corruptions))
+	w.verifyMetrics.corruptionOffset.Reset()
+	for _, c := range corruptions {
+		if _, seen := segments[c.Segment]; seen {
+			continue
+		}
+		segments[c.Segment] = struct{}{}
+		w.verifyMetrics.corruptionOffset.WithLabelValues(strconv.Itoa(c.Segment)).Set(float64(c.Offset))
+	}
+	w.verifyMetrics.corruptSegments.Set(float64(len(segments)))
+}
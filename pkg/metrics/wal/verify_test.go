@@ -0,0 +1,106 @@
+package wal
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/tsdb/wal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage_Verify(t *testing.T) {
+	walDir := t.TempDir()
+
+	reg := prometheus.NewRegistry()
+	s, err := NewStorageWithOptions(log.NewNopLogger(), reg, walDir, Options{Verify: &DefaultVerifyConfig})
+	require.NoError(t, err)
+	defer s.Close()
+
+	writeVerifySeries(t, s, "foo")
+	require.NoError(t, s.wal.NextSegment())
+	writeVerifySeries(t, s, "bar")
+	require.NoError(t, s.wal.NextSegment())
+
+	// Intact segments report no corruption.
+	require.NoError(t, s.Verify())
+	require.Empty(t, s.Corruptions())
+
+	// Corrupt the record written to the second segment.
+	corruptSegment(t, s.wal.Dir(), 1)
+
+	require.NoError(t, s.Verify())
+	corruptions := s.Corruptions()
+	require.Len(t, corruptions, 1)
+	require.Equal(t, 1, corruptions[0].Segment)
+	require.False(t, corruptions[0].Repaired)
+
+	require.Equal(t, 2.0, testutil.ToFloat64(s.verifyMetrics.totalVerifications))
+	require.Equal(t, 1.0, testutil.ToFloat64(s.verifyMetrics.corruptSegments))
+	require.Equal(t, float64(corruptions[0].Offset), testutil.ToFloat64(s.verifyMetrics.corruptionOffset.WithLabelValues("1")))
+}
+
+func TestStorage_VerifyReplay(t *testing.T) {
+	walDir := t.TempDir()
+
+	s, err := NewStorage(log.NewNopLogger(), nil, walDir)
+	require.NoError(t, err)
+	writeVerifySeries(t, s, "foo")
+	require.NoError(t, s.wal.NextSegment())
+	writeVerifySeries(t, s, "bar")
+	require.NoError(t, s.Close())
+
+	corruptSegment(t, s.wal.Dir(), 1)
+
+	// Replay repairs the WAL and reports where the corruption was found.
+	s, err = NewStorageWithOptions(log.NewNopLogger(), nil, walDir, Options{Verify: &DefaultVerifyConfig})
+	require.NoError(t, err)
+	defer s.Close()
+
+	corruptions := s.Corruptions()
+	require.Len(t, corruptions, 1)
+	require.Equal(t, 1, corruptions[0].Segment)
+	require.True(t, corruptions[0].Repaired)
+}
+
+func TestStorage_VerifyDisabled(t *testing.T) {
+	s, err := NewStorage(log.NewNopLogger(), nil, t.TempDir())
+	require.NoError(t, err)
+	defer s.Close()
+
+	writeVerifySeries(t, s, "foo")
+	require.NoError(t, s.wal.NextSegment())
+	corruptSegment(t, s.wal.Dir(), 0)
+
+	require.NoError(t, s.Verify())
+	require.Nil(t, s.Corruptions())
+}
+
+func writeVerifySeries(t *testing.T, s *Storage, name string) {
+	t.Helper()
+
+	app := s.Appender(context.Background())
+	for _, metric := range buildSeries([]string{name}) {
+		metric.Write(t, app)
+	}
+	require.NoError(t, app.Commit())
+}
+
+// corruptSegment flips a byte of the first record of segment i in dir.
+func corruptSegment(t *testing.T, dir string, i int) {
+	t.Helper()
+
+	f, err := os.OpenFile(wal.SegmentName(dir, i), os.O_RDWR, 0)
+	require.NoError(t, err)
+	defer f.Close()
+
+	buf := make([]byte, 1)
+	_, err = f.ReadAt(buf, 10)
+	require.NoError(t, err)
+	buf[0] ^= 0xff
+	_, err = f.WriteAt(buf, 10)
+	require.NoError(t, err)
+}
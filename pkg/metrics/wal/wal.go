@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"sort"
 	"sync"
 	"time"
 	"unicode/utf8"
@@ -22,6 +23,7 @@ import (
 	"github.com/prometheus/prometheus/tsdb/record"
 	"github.com/prometheus/prometheus/tsdb/wal"
 	"go.uber.org/atomic"
+	"k8s.io/utils/clock"
 )
 
 func init() {
@@ -41,6 +43,7 @@ type storageMetrics struct {
 	totalRemovedSeries     prometheus.Counter
 	totalAppendedSamples   prometheus.Counter
 	totalAppendedExemplars prometheus.Counter
+	totalExportedSamples   prometheus.Counter
 }
 
 func newStorageMetrics(r prometheus.Registerer) *storageMetrics {
@@ -75,6 +78,11 @@ func newStorageMetrics(r prometheus.Registerer) *storageMetrics {
 		Help: "Total number of exemplars appended to the WAL",
 	})
 
+	m.totalExportedSamples = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "agent_wal_exported_samples_total",
+		Help: "Total number of truncated samples exported to local files",
+	})
+
 	if r != nil {
 		r.MustRegister(
 			m.numActiveSeries,
@@ -83,6 +91,7 @@ func newStorageMetrics(r prometheus.Registerer) *storageMetrics {
 			m.totalRemovedSeries,
 			m.totalAppendedSamples,
 			m.totalAppendedExemplars,
+			m.totalExportedSamples,
 		)
 	}
 
@@ -100,6 +109,7 @@ func (m *storageMetrics) Unregister() {
 		m.totalRemovedSeries,
 		m.totalAppendedSamples,
 		m.totalAppendedExemplars,
+		m.totalExportedSamples,
 	}
 	for _, c := range cs {
 		m.r.Unregister(c)
@@ -137,10 +147,80 @@ type Storage struct {
 	metrics *storageMetrics
 
 	ref *atomic.Uint64
+
+	clock         Clock
+	faults        Faults
+	replayRelabel func(labels.Labels) labels.Labels
+	export        *ExportConfig
+
+	verify        *VerifyConfig
+	verifyMetrics *verifyMetrics
+	corruptions   corruptionTracker
+
+	delivery        *DeliveryConfig
+	deliveryMetrics *deliveryMetrics
+	segmentTimes    segmentTimes
+
+	admitSeries  func(active int) (bool, error)
+	activeSeries atomic.Int64
+}
+
+// Options holds optional settings for creating a Storage.
+type Options struct {
+	// RefIDSource is used to generate series ref IDs. If nil, the Storage uses
+	// its own source starting from 0.
+	RefIDSource *atomic.Uint64
+
+	// Clock is used for all time-related operations. Defaults to the wall
+	// clock if nil.
+	Clock Clock
+
+	// Faults holds optional fault injection points for testing.
+	Faults Faults
+
+	// ReplayRelabel, if set, is invoked with the labels of every series read
+	// while replaying the WAL, and the returned labels are used in their
+	// place. This allows repairing series written with the wrong labels
+	// without discarding the WAL.
+	//
+	// Series whose labels were changed are written back to the WAL after
+	// replay, so the rewritten labels are carried forward into checkpoints
+	// and seen by readers of the WAL such as remote_write.
+	ReplayRelabel func(labels.Labels) labels.Labels
+
+	// Export, if set, exports samples to local files before they are
+	// discarded by Truncate.
+	Export *ExportConfig
+
+	// Verify, if set, enables reporting corrupt records found while replaying
+	// the WAL and by Verify.
+	Verify *VerifyConfig
+
+	// Delivery, if set, prevents Truncate from discarding segments holding
+	// samples newer than the timestamp it is given.
+	Delivery *DeliveryConfig
+
+	// AdmitSeries, if set, is invoked with the number of active series before
+	// a series is created. When it returns false, the sample creating the
+	// series is dropped, and the error it returns, if any, is returned by
+	// Append.
+	AdmitSeries func(active int) (bool, error)
 }
 
 // NewStorageWithRefIDSource uses a global refid source instead of local ones
 func NewStorageWithRefIDSource(logger log.Logger, registerer prometheus.Registerer, path string, ref *atomic.Uint64) (*Storage, error) {
+	return NewStorageWithOptions(logger, registerer, path, Options{RefIDSource: ref})
+}
+
+// NewStorageWithOptions makes a new Storage with the provided Options.
+func NewStorageWithOptions(logger log.Logger, registerer prometheus.Registerer, path string, opts Options) (*Storage, error) {
+	if opts.RefIDSource == nil {
+		opts.RefIDSource = atomic.NewUint64(0)
+	}
+	if opts.Clock == nil {
+		opts.Clock = clock.RealClock{}
+	}
+
 	w, err := wal.NewSize(logger, registerer, SubDirectory(path), wal.DefaultSegmentSize, true)
 	if err != nil {
 		return nil, err
@@ -153,7 +233,21 @@ func NewStorageWithRefIDSource(logger log.Logger, registerer prometheus.Register
 		deleted: map[chunks.HeadSeriesRef]int{},
 		series:  newStripeSeries(),
 		metrics: newStorageMetrics(registerer),
-		ref:     ref,
+		ref:     opts.RefIDSource,
+		clock:   opts.Clock,
+		faults:  opts.Faults,
+
+		replayRelabel: opts.ReplayRelabel,
+		export:        opts.Export,
+		verify:        opts.Verify,
+		delivery:      opts.Delivery,
+		admitSeries:   opts.AdmitSeries,
+	}
+	if opts.Verify != nil {
+		storage.verifyMetrics = newVerifyMetrics(registerer)
+	}
+	if opts.Delivery != nil {
+		storage.deliveryMetrics = newDeliveryMetrics(registerer)
 	}
 
 	storage.bufPool.New = func() interface{} {
@@ -177,6 +271,7 @@ func NewStorageWithRefIDSource(logger log.Logger, registerer prometheus.Register
 		if ok := errors.As(err, &ce); !ok {
 			return nil, err
 		}
+		storage.recordReplayCorruption(ce)
 		if err := w.Repair(ce); err != nil {
 			return nil, fmt.Errorf("repair corrupted WAL: %w", err)
 		}
@@ -187,7 +282,7 @@ func NewStorageWithRefIDSource(logger log.Logger, registerer prometheus.Register
 
 // NewStorage makes a new Storage.
 func NewStorage(logger log.Logger, registerer prometheus.Registerer, path string) (*Storage, error) {
-	return NewStorageWithRefIDSource(logger, registerer, path, atomic.NewUint64(0))
+	return NewStorageWithOptions(logger, registerer, path, Options{})
 }
 
 func (w *Storage) replayWAL() error {
@@ -198,6 +293,10 @@ func (w *Storage) replayWAL() error {
 		return ErrWALClosed
 	}
 
+	// relabeled tracks series whose labels were rewritten by replayRelabel and
+	// haven't been written back to the WAL yet.
+	relabeled := map[chunks.HeadSeriesRef]labels.Labels{}
+
 	level.Info(w.logger).Log("msg", "replaying WAL, this may take a while", "dir", w.wal.Dir())
 	dir, startFrom, err := wal.LastCheckpoint(w.wal.Dir())
 	if err != nil && err != record.ErrNotFound {
@@ -217,7 +316,7 @@ func (w *Storage) replayWAL() error {
 
 		// A corrupted checkpoint is a hard error for now and requires user
 		// intervention. There's likely little data that can be recovered anyway.
-		if err := w.loadWAL(wal.NewReader(sr)); err != nil {
+		if err := w.loadWAL(wal.NewReader(sr), relabeled); err != nil {
 			return fmt.Errorf("backfill checkpoint: %w", err)
 		}
 		startFrom++
@@ -238,7 +337,7 @@ func (w *Storage) replayWAL() error {
 		}
 
 		sr := wal.NewSegmentBufReader(s)
-		err = w.loadWAL(wal.NewReader(sr))
+		err = w.loadWAL(wal.NewReader(sr), relabeled)
 		if err := sr.Close(); err != nil {
 			level.Warn(w.logger).Log("msg", "error while closing the wal segments reader", "err", err)
 		}
@@ -248,10 +347,34 @@ func (w *Storage) replayWAL() error {
 		level.Info(w.logger).Log("msg", "WAL segment loaded", "segment", i, "maxSegment", last)
 	}
 
+	return w.logRelabeled(relabeled)
+}
+
+// logRelabeled writes series records for series which had their labels
+// rewritten during replay.
+func (w *Storage) logRelabeled(relabeled map[chunks.HeadSeriesRef]labels.Labels) error {
+	if len(relabeled) == 0 {
+		return nil
+	}
+
+	series := make([]record.RefSeries, 0, len(relabeled))
+	for ref, lset := range relabeled {
+		series = append(series, record.RefSeries{Ref: ref, Labels: lset})
+	}
+	sort.Slice(series, func(i, j int) bool { return series[i].Ref < series[j].Ref })
+
+	var encoder record.Encoder
+	if err := w.log(encoder.Series(series, nil)); err != nil {
+		return fmt.Errorf("write relabeled series: %w", err)
+	}
+
+	level.Info(w.logger).Log("msg", "rewrote labels of series during WAL replay", "count", len(series))
 	return nil
 }
 
-func (w *Storage) loadWAL(r *wal.Reader) (err error) {
+// loadWAL loads all records from r. Series which had their labels rewritten
+// by replayRelabel are stored in relabeled.
+func (w *Storage) loadWAL(r *wal.Reader, relabeled map[chunks.HeadSeriesRef]labels.Labels) (err error) {
 	var (
 		dec record.Decoder
 	)
@@ -321,15 +444,34 @@ func (w *Storage) loadWAL(r *wal.Reader) (err error) {
 		switch v := d.(type) {
 		case []record.RefSeries:
 			for _, s := range v {
+				lset := s.Labels
+				if w.replayRelabel != nil {
+					if rewritten := w.replayRelabel(lset); !labels.Equal(rewritten, lset) {
+						lset = rewritten
+						relabeled[s.Ref] = lset
+					} else if prev, ok := relabeled[s.Ref]; ok && labels.Equal(prev, lset) {
+						// The rewritten labels were already written back to the WAL
+						// by a previous replay.
+						delete(relabeled, s.Ref)
+					}
+				}
+
 				// If this is a new series, create it in memory without a timestamp.
 				// If we read in a sample for it, we'll use the timestamp of the latest
 				// sample. Otherwise, the series is stale and will be deleted once
 				// the truncation is performed.
-				if w.series.getByID(s.Ref) == nil {
-					series := &memSeries{ref: s.Ref, lset: s.Labels, lastTs: 0}
-					w.series.set(s.Labels.Hash(), series)
+				if existing := w.series.getByID(s.Ref); existing != nil {
+					// A later series record for an existing ref holds labels which
+					// were rewritten during a previous replay.
+					if !labels.Equal(existing.lset, lset) {
+						w.series.relabel(existing, lset)
+					}
+				} else {
+					series := &memSeries{ref: s.Ref, lset: lset, lastTs: 0}
+					w.series.set(lset.Hash(), series)
 
 					w.metrics.numActiveSeries.Inc()
+					w.activeSeries.Inc()
 					w.metrics.totalCreatedSeries.Inc()
 
 					if biggestRef <= uint64(s.Ref) {
@@ -404,11 +546,11 @@ func (w *Storage) Truncate(mint int64) error {
 		return ErrWALClosed
 	}
 
-	start := time.Now()
+	start := w.clock.Now()
 
 	// Garbage collect series that haven't received an update since mint.
 	w.gc(mint)
-	level.Info(w.logger).Log("msg", "series GC completed", "duration", time.Since(start))
+	level.Info(w.logger).Log("msg", "series GC completed", "duration", w.clock.Since(start))
 
 	first, last, err := wal.Segments(w.wal.Dir())
 	if err != nil {
@@ -430,6 +572,9 @@ func (w *Storage) Truncate(mint int64) error {
 	// The lower two thirds of segments should contain mostly obsolete samples.
 	// If we have less than two segments, it's not worth checkpointing yet.
 	last = first + (last-first)*2/3
+	if w.delivery != nil {
+		last = w.limitToAcknowledged(first, last, mint)
+	}
 	if last <= first {
 		return nil
 	}
@@ -444,14 +589,28 @@ func (w *Storage) Truncate(mint int64) error {
 		w.deletedMtx.Unlock()
 		return ok
 	}
+	if w.faults.BeforeCheckpoint != nil {
+		if err := w.faults.BeforeCheckpoint(); err != nil {
+			return fmt.Errorf("create checkpoint: %w", err)
+		}
+	}
+	if w.export != nil {
+		// Failing to export shouldn't prevent the WAL from being truncated, as
+		// that would let it grow forever.
+		if err := w.exportTruncated(first, last, keep, mint); err != nil {
+			level.Error(w.logger).Log("msg", "failed to export truncated samples", "err", err)
+		}
+	}
 	if _, err = wal.Checkpoint(w.logger, w.wal, first, last, keep, mint); err != nil {
 		return fmt.Errorf("create checkpoint: %w", err)
 	}
-	if err := w.wal.Truncate(last + 1); err != nil {
+	if err := w.truncateSegments(last + 1); err != nil {
 		// If truncating fails, we'll just try again at the next checkpoint.
 		// Leftover segments will just be ignored in the future if there's a checkpoint
 		// that supersedes them.
 		level.Error(w.logger).Log("msg", "truncating segments failed", "err", err)
+	} else {
+		w.segmentTimes.drop(last + 1)
 	}
 
 	// The checkpoint is written and segments before it is truncated, so we no
@@ -474,14 +633,36 @@ func (w *Storage) Truncate(mint int64) error {
 	}
 
 	level.Info(w.logger).Log("msg", "WAL checkpoint complete",
-		"first", first, "last", last, "duration", time.Since(start))
+		"first", first, "last", last, "duration", w.clock.Since(start))
 	return nil
 }
 
+// truncateSegments drops all segments before i, first consulting the
+// BeforeSegmentTruncate fault.
+func (w *Storage) truncateSegments(i int) error {
+	if w.faults.BeforeSegmentTruncate != nil {
+		if err := w.faults.BeforeSegmentTruncate(); err != nil {
+			return err
+		}
+	}
+	return w.wal.Truncate(i)
+}
+
+// log writes rec to the WAL, first consulting the BeforeLog fault.
+func (w *Storage) log(rec []byte) error {
+	if w.faults.BeforeLog != nil {
+		if err := w.faults.BeforeLog(rec); err != nil {
+			return err
+		}
+	}
+	return w.wal.Log(rec)
+}
+
 // gc removes data before the minimum timestamp from the head.
 func (w *Storage) gc(mint int64) {
 	deleted := w.series.gc(mint)
 	w.metrics.numActiveSeries.Sub(float64(len(deleted)))
+	w.activeSeries.Sub(int64(len(deleted)))
 
 	_, last, _ := wal.Segments(w.wal.Dir())
 	w.deletedMtx.Lock()
@@ -515,7 +696,7 @@ func (w *Storage) WriteStalenessMarkers(remoteTsFunc func() int64) error {
 			lset = series.lset
 		)
 
-		ts := timestamp.FromTime(time.Now())
+		ts := timestamp.FromTime(w.clock.Now())
 		_, err := app.Append(storage.SeriesRef(ref), lset, ts, math.Float64frombits(value.StaleNaN))
 		if err != nil {
 			lastErr = err
@@ -534,8 +715,8 @@ func (w *Storage) WriteStalenessMarkers(remoteTsFunc func() int64) error {
 		// Wait for remote write to write the lastTs, but give up after 1m
 		level.Info(w.logger).Log("msg", "waiting for remote write to write staleness markers...")
 
-		stopCh := time.After(1 * time.Minute)
-		start := time.Now()
+		stopCh := w.clock.After(1 * time.Minute)
+		start := w.clock.Now()
 
 	Outer:
 		for {
@@ -546,7 +727,7 @@ func (w *Storage) WriteStalenessMarkers(remoteTsFunc func() int64) error {
 			default:
 				writtenTs := remoteTsFunc()
 				if writtenTs >= lastTs {
-					duration := time.Since(start)
+					duration := w.clock.Since(start)
 					level.Info(w.logger).Log("msg", "remote write wrote staleness markers", "duration", duration)
 					break Outer
 				}
@@ -554,7 +735,7 @@ func (w *Storage) WriteStalenessMarkers(remoteTsFunc func() int64) error {
 				level.Info(w.logger).Log("msg", "remote write hasn't written staleness markers yet", "remoteTs", writtenTs, "lastTs", lastTs)
 
 				// Wait a bit before reading again
-				time.Sleep(5 * time.Second)
+				w.clock.Sleep(5 * time.Second)
 			}
 		}
 	}
@@ -575,6 +756,12 @@ func (w *Storage) Close() error {
 	if w.metrics != nil {
 		w.metrics.Unregister()
 	}
+	if w.verifyMetrics != nil {
+		w.verifyMetrics.Unregister()
+	}
+	if w.deliveryMetrics != nil {
+		w.deliveryMetrics.Unregister()
+	}
 	return w.wal.Close()
 }
 
@@ -599,8 +786,14 @@ func (a *appender) Append(ref storage.SeriesRef, l labels.Labels, t int64, v flo
 			return 0, fmt.Errorf("label name %q is not unique: %w", lbl, tsdb.ErrInvalidSample)
 		}
 
-		var created bool
-		series, created = a.getOrCreate(l)
+		var (
+			created bool
+			err     error
+		)
+		series, created, err = a.getOrCreate(l)
+		if series == nil {
+			return 0, err
+		}
 		if created {
 			a.series = append(a.series, record.RefSeries{
 				Ref:    series.ref,
@@ -608,6 +801,7 @@ func (a *appender) Append(ref storage.SeriesRef, l labels.Labels, t int64, v flo
 			})
 
 			a.w.metrics.numActiveSeries.Inc()
+			a.w.activeSeries.Inc()
 			a.w.metrics.totalCreatedSeries.Inc()
 		}
 	}
@@ -629,18 +823,26 @@ func (a *appender) Append(ref storage.SeriesRef, l labels.Labels, t int64, v flo
 	return storage.SeriesRef(series.ref), nil
 }
 
-func (a *appender) getOrCreate(l labels.Labels) (series *memSeries, created bool) {
+// getOrCreate returns the series of l, creating it if it doesn't exist. A nil
+// series is returned if the series wasn't admitted.
+func (a *appender) getOrCreate(l labels.Labels) (series *memSeries, created bool, err error) {
 	hash := l.Hash()
 
 	series = a.w.series.getByHash(hash, l)
 	if series != nil {
-		return series, false
+		return series, false, nil
+	}
+
+	if a.w.admitSeries != nil {
+		if ok, err := a.w.admitSeries(int(a.w.activeSeries.Load())); !ok {
+			return nil, false, err
+		}
 	}
 
 	ref := chunks.HeadSeriesRef(a.w.ref.Inc())
 	series = &memSeries{ref: ref, lset: l}
 	a.w.series.set(l.Hash(), series)
-	return series, true
+	return series, true, nil
 }
 
 func (a *appender) AppendExemplar(ref storage.SeriesRef, _ labels.Labels, e exemplar.Exemplar) (storage.SeriesRef, error) {
@@ -702,9 +904,20 @@ func (a *appender) Commit() error {
 	var encoder record.Encoder
 	buf := a.w.bufPool.Get().([]byte)
 
+	// Records may be split across segments, so the segments written to are
+	// found before and after logging.
+	trackSegments := a.w.delivery != nil && (len(a.samples) > 0 || len(a.exemplars) > 0)
+	var fromSegment int
+	if trackSegments {
+		var err error
+		if fromSegment, _, err = a.w.wal.LastSegmentAndOffset(); err != nil {
+			return err
+		}
+	}
+
 	if len(a.series) > 0 {
 		buf = encoder.Series(a.series, buf)
-		if err := a.w.wal.Log(buf); err != nil {
+		if err := a.w.log(buf); err != nil {
 			return err
 		}
 		buf = buf[:0]
@@ -712,7 +925,7 @@ func (a *appender) Commit() error {
 
 	if len(a.samples) > 0 {
 		buf = encoder.Samples(a.samples, buf)
-		if err := a.w.wal.Log(buf); err != nil {
+		if err := a.w.log(buf); err != nil {
 			return err
 		}
 		buf = buf[:0]
@@ -720,7 +933,7 @@ func (a *appender) Commit() error {
 
 	if len(a.exemplars) > 0 {
 		buf = encoder.Exemplars(a.exemplars, buf)
-		if err := a.w.wal.Log(buf); err != nil {
+		if err := a.w.log(buf); err != nil {
 			return err
 		}
 		buf = buf[:0]
@@ -729,6 +942,14 @@ func (a *appender) Commit() error {
 	//nolint:staticcheck
 	a.w.bufPool.Put(buf)
 
+	if trackSegments {
+		toSegment, _, err := a.w.wal.LastSegmentAndOffset()
+		if err != nil {
+			return err
+		}
+		a.w.segmentTimes.observe(fromSegment, toSegment, a.maxTimestamp())
+	}
+
 	for _, sample := range a.samples {
 		series := a.w.series.getByID(sample.Ref)
 		if series != nil {
@@ -741,6 +962,23 @@ func (a *appender) Commit() error {
 	return a.Rollback()
 }
 
+// maxTimestamp returns the newest timestamp of the pending samples and
+// exemplars.
+func (a *appender) maxTimestamp() int64 {
+	maxT := int64(math.MinInt64)
+	for _, s := range a.samples {
+		if s.T > maxT {
+			maxT = s.T
+		}
+	}
+	for _, e := range a.exemplars {
+		if e.T > maxT {
+			maxT = e.T
+		}
+	}
+	return maxT
+}
+
 func (a *appender) Rollback() error {
 	a.series = a.series[:0]
 	a.samples = a.samples[:0]
@@ -2,10 +2,12 @@ package wal
 
 import (
 	"context"
+	"errors"
 	"io/ioutil"
 	"math"
 	"os"
 	"sort"
+	"syscall"
 	"testing"
 	"time"
 
@@ -13,12 +15,14 @@ import (
 	"github.com/grafana/agent/pkg/util"
 	"github.com/prometheus/prometheus/model/exemplar"
 	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/timestamp"
 	"github.com/prometheus/prometheus/model/value"
 	"github.com/prometheus/prometheus/storage"
 	"github.com/prometheus/prometheus/tsdb"
 	"github.com/prometheus/prometheus/tsdb/chunks"
 	"github.com/prometheus/prometheus/tsdb/record"
 	"github.com/stretchr/testify/require"
+	testingclock "k8s.io/utils/clock/testing"
 )
 
 func TestStorage_InvalidSeries(t *testing.T) {
@@ -242,6 +246,117 @@ func TestStorage_ExistingWAL_RefID(t *testing.T) {
 	require.Equal(t, uint64(len(payload)), s.ref.Load(), "cached ref ID should be equal to the number of series written")
 }
 
+func TestStorage_ReplayRelabel(t *testing.T) {
+	walDir := t.TempDir()
+
+	s, err := NewStorage(log.NewNopLogger(), nil, walDir)
+	require.NoError(t, err)
+
+	app := s.Appender(context.Background())
+	payload := buildSeries([]string{"foo", "bar"})
+	for _, metric := range payload {
+		metric.Write(t, app)
+	}
+	require.NoError(t, app.Commit())
+	require.NoError(t, s.Close())
+
+	// Rename foo to fixed_foo when replaying.
+	var calls int
+	relabel := func(lset labels.Labels) labels.Labels {
+		calls++
+		if lset.Get("__name__") != "foo" {
+			return lset
+		}
+		return labels.NewBuilder(lset).Set("__name__", "fixed_foo").Labels()
+	}
+
+	s, err = NewStorageWithOptions(log.NewNopLogger(), nil, walDir, Options{ReplayRelabel: relabel})
+	require.NoError(t, err)
+	require.Equal(t, 2, calls)
+
+	fixed := labels.FromStrings("__name__", "fixed_foo")
+	series := s.series.getByHash(fixed.Hash(), fixed)
+	require.NotNil(t, series, "series should be registered with rewritten labels")
+	require.Equal(t, chunks.HeadSeriesRef(*payload[0].ref), series.ref)
+
+	original := labels.FromStrings("__name__", "foo")
+	require.Nil(t, s.series.getByHash(original.Hash(), original))
+	require.NoError(t, s.Close())
+
+	// Replaying again with the hook should not write the rewritten series back
+	// a second time.
+	s, err = NewStorageWithOptions(log.NewNopLogger(), nil, walDir, Options{ReplayRelabel: relabel})
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+
+	// The rewritten labels must have been written back to the WAL, so they're
+	// kept even when replaying without the hook.
+	s, err = NewStorage(log.NewNopLogger(), nil, walDir)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, s.Close())
+	}()
+
+	series = s.series.getByHash(fixed.Hash(), fixed)
+	require.NotNil(t, series)
+	require.Equal(t, chunks.HeadSeriesRef(*payload[0].ref), series.ref)
+	require.Nil(t, s.series.getByHash(original.Hash(), original))
+
+	collector := walDataCollector{}
+	replayer := walReplayer{w: &collector}
+	require.NoError(t, replayer.Replay(s.wal.Dir()))
+
+	var names []string
+	for _, series := range collector.series {
+		names = append(names, series.Labels.Get("__name__"))
+	}
+	require.Equal(t, []string{"foo", "bar", "fixed_foo"}, names)
+}
+
+func TestStorage_AdmitSeries(t *testing.T) {
+	errLimited := errors.New("limited")
+
+	var reject bool
+	admit := func(active int) (bool, error) {
+		if active < 2 {
+			return true, nil
+		} else if reject {
+			return false, errLimited
+		}
+		return false, nil
+	}
+
+	s, err := NewStorageWithOptions(log.NewNopLogger(), nil, t.TempDir(), Options{AdmitSeries: admit})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, s.Close())
+	}()
+
+	app := s.Appender(context.Background())
+	for _, name := range []string{"a", "b"} {
+		ref, err := app.Append(0, labels.FromStrings("__name__", name), 0, 0)
+		require.NoError(t, err)
+		require.NotZero(t, ref)
+	}
+
+	// Samples of existing series are still appended.
+	_, err = app.Append(0, labels.FromStrings("__name__", "a"), 1, 1)
+	require.NoError(t, err)
+
+	// Samples of new series are dropped, or fail with the error of the hook.
+	ref, err := app.Append(0, labels.FromStrings("__name__", "c"), 0, 0)
+	require.NoError(t, err)
+	require.Zero(t, ref)
+
+	reject = true
+	_, err = app.Append(0, labels.FromStrings("__name__", "c"), 0, 0)
+	require.ErrorIs(t, err, errLimited)
+	require.NoError(t, app.Commit())
+
+	c := labels.FromStrings("__name__", "c")
+	require.Nil(t, s.series.getByHash(c.Hash(), c))
+}
+
 func TestStorage_Truncate(t *testing.T) {
 	// Same as before but now do the following:
 	// after writing all the data, forcefully create 4 more segments,
@@ -373,6 +488,92 @@ func TestStorage_TruncateAfterClose(t *testing.T) {
 	require.Error(t, ErrWALClosed, s.Truncate(0))
 }
 
+func TestStorage_FaultLogDiskFull(t *testing.T) {
+	walDir := t.TempDir()
+
+	s, err := NewStorageWithOptions(log.NewNopLogger(), nil, walDir, Options{
+		// Allow the series record to be written but fail on the samples.
+		Faults: Faults{BeforeLog: FailLogAfter(1, ErrDiskFull)},
+	})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, s.Close())
+	}()
+
+	app := s.Appender(context.Background())
+	payload := buildSeries([]string{"foo"})
+	payload[0].Write(t, app)
+
+	err = app.Commit()
+	require.ErrorIs(t, err, syscall.ENOSPC)
+
+	// Only the series record should have made it to the WAL.
+	collector := walDataCollector{}
+	replayer := walReplayer{w: &collector}
+	require.NoError(t, replayer.Replay(s.wal.Dir()))
+	require.Len(t, collector.series, 1)
+	require.Empty(t, collector.samples)
+}
+
+func TestStorage_FaultCheckpoint(t *testing.T) {
+	walDir := t.TempDir()
+
+	injected := errors.New("checkpoint failed")
+	s, err := NewStorageWithOptions(log.NewNopLogger(), nil, walDir, Options{
+		Faults: Faults{BeforeCheckpoint: func() error { return injected }},
+	})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, s.Close())
+	}()
+
+	app := s.Appender(context.Background())
+	for _, metric := range buildSeries([]string{"foo", "bar"}) {
+		metric.Write(t, app)
+	}
+	require.NoError(t, app.Commit())
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, s.wal.NextSegment())
+	}
+
+	require.ErrorIs(t, s.Truncate(0), injected)
+}
+
+func TestStorage_WriteStalenessMarkers_Clock(t *testing.T) {
+	walDir := t.TempDir()
+
+	now := time.Date(2022, time.June, 1, 0, 0, 0, 0, time.UTC)
+	s, err := NewStorageWithOptions(log.NewNopLogger(), nil, walDir, Options{
+		Clock: testingclock.NewFakeClock(now),
+	})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, s.Close())
+	}()
+
+	app := s.Appender(context.Background())
+	payload := buildSeries([]string{"foo"})
+	payload[0].Write(t, app)
+	require.NoError(t, app.Commit())
+
+	require.NoError(t, s.WriteStalenessMarkers(func() int64 {
+		return math.MaxInt64
+	}))
+
+	collector := walDataCollector{}
+	replayer := walReplayer{w: &collector}
+	require.NoError(t, replayer.Replay(s.wal.Dir()))
+
+	var staleTs []int64
+	for _, sample := range collector.samples {
+		if value.IsStaleNaN(sample.V) {
+			staleTs = append(staleTs, sample.T)
+		}
+	}
+	require.Equal(t, []int64{timestamp.FromTime(now)}, staleTs)
+}
+
 func TestGlobalReferenceID_Normal(t *testing.T) {
 	walDir, _ := ioutil.TempDir(os.TempDir(), "wal")
 	defer os.RemoveAll(walDir)
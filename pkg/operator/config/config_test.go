@@ -223,6 +223,113 @@ metrics:
 	}
 }
 
+func TestAdditionalScrapeConfigsMetrics_Sharded(t *testing.T) {
+	var store = make(assets.SecretStore)
+
+	additionalSelector := &v1.SecretKeySelector{
+		LocalObjectReference: v1.LocalObjectReference{Name: "configs"},
+		Key:                  "configs",
+	}
+
+	input := gragent.Deployment{
+		Agent: &gragent.GrafanaAgent{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Namespace: "operator",
+				Name:      "agent",
+			},
+			Spec: gragent.GrafanaAgentSpec{
+				Image:              pointer.String("grafana/agent:latest"),
+				ServiceAccountName: "agent",
+				Metrics: gragent.MetricsSubsystemSpec{
+					InstanceSelector: &meta_v1.LabelSelector{
+						MatchLabels: map[string]string{"agent": "agent"},
+					},
+					Shards: pointer.Int32(2),
+				},
+			},
+		},
+		Metrics: []gragent.MetricsDeployment{{
+			Instance: &gragent.MetricsInstance{
+				ObjectMeta: meta_v1.ObjectMeta{
+					Namespace: "operator",
+					Name:      "primary",
+				},
+				Spec: gragent.MetricsInstanceSpec{
+					RemoteWrite: []gragent.RemoteWriteSpec{{
+						URL: "http://cortex:80/api/prom/push",
+					}},
+					AdditionalScrapeConfigs: additionalSelector,
+				},
+			},
+		}},
+
+		Secrets: store,
+	}
+
+	// The second job already shards its targets and is left alone.
+	store[assets.KeyForSecret("operator", additionalSelector)] = util.Untab(`
+	- job_name: job
+		kubernetes_sd_configs:
+		- role: node
+	- job_name: custom
+		kubernetes_sd_configs:
+		- role: node
+		relabel_configs:
+		- source_labels: [__meta_kubernetes_node_name]
+		  target_label: __tmp_hash
+		  modulus: 2
+		  action: hashmod
+		- source_labels: [__tmp_hash]
+		  regex: $(SHARD)
+		  action: keep
+	`)
+
+	expect := util.Untab(`
+server: {}
+
+metrics:
+  wal_directory: /var/lib/grafana-agent/data
+  global:
+    external_labels:
+      __replica__: replica-$(STATEFULSET_ORDINAL_NUMBER)
+      cluster: operator/agent
+  configs:
+  - name: operator/primary
+    remote_write:
+    - url: http://cortex:80/api/prom/push
+    scrape_configs:
+    - job_name: job
+      kubernetes_sd_configs:
+      - role: node
+      relabel_configs:
+      - source_labels: [__address__]
+        target_label: __tmp_hash
+        modulus: 2
+        action: hashmod
+      - source_labels: [__tmp_hash]
+        regex: $(SHARD)
+        action: keep
+    - job_name: custom
+      kubernetes_sd_configs:
+      - role: node
+      relabel_configs:
+      - source_labels: [__meta_kubernetes_node_name]
+        target_label: __tmp_hash
+        modulus: 2
+        action: hashmod
+      - source_labels: [__tmp_hash]
+        regex: $(SHARD)
+        action: keep
+	`)
+
+	result, err := BuildConfig(&input, MetricsType)
+	require.NoError(t, err)
+
+	if !assert.YAMLEq(t, expect, result) {
+		fmt.Println(result)
+	}
+}
+
 func TestBuildConfigLogs(t *testing.T) {
 	var store = make(assets.SecretStore)
 
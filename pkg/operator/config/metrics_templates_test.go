@@ -399,6 +399,13 @@ func TestProbe(t *testing.T) {
 					target_label: instance
 				- replacement: ""
 					target_label: __address__
+				- source_labels: [__param_target]
+					target_label: __tmp_hash
+					action: hashmod
+					modulus: 1
+				- source_labels: [__tmp_hash]
+					action: keep
+					regex: $(SHARD)
 			`),
 		},
 	}
@@ -617,6 +624,7 @@ func TestRemoteWrite(t *testing.T) {
 						BatchSendDeadline: "5m",
 						MinBackoff:        "1m",
 						MaxBackoff:        "5m",
+						RetryOnRateLimit:  true,
 					},
 				},
 			},
@@ -630,6 +638,7 @@ func TestRemoteWrite(t *testing.T) {
 					batch_send_deadline: 5m
 					min_backoff: 1m
 					max_backoff: 5m
+					retry_on_http_429: true
 			`),
 		},
 		{
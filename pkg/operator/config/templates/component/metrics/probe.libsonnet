@@ -4,6 +4,7 @@ local k8s = import 'utils/k8s.libsonnet';
 
 local new_kube_sd_config = import './kube_sd_config.libsonnet';
 local new_relabel_config = import './relabel_config.libsonnet';
+local new_shard_relabel_configs = import './shard_relabel_configs.libsonnet';
 local new_tls_config = import './tls_config.libsonnet';
 
 // Genrates a scrape_config from a Probe.
@@ -211,6 +212,10 @@ function(
         target_label: enforcedNamespaceLabel,
         replacement: probe.ObjectMeta.Namespace,
       },
-    ])
+    ]) +
+
+    // __address__ is always the prober by now, so shard by the probed target
+    // instead.
+    new_shard_relabel_configs(shards, '__param_target')
   ),
 }
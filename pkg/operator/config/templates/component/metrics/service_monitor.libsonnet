@@ -4,6 +4,7 @@ local k8s = import 'utils/k8s.libsonnet';
 
 local new_kube_sd_config = import './kube_sd_config.libsonnet';
 local new_relabel_config = import './relabel_config.libsonnet';
+local new_shard_relabel_configs = import './shard_relabel_configs.libsonnet';
 local new_tls_config = import './tls_config.libsonnet';
 
 // Genrates a scrape_config from a ServiceMonitor.
@@ -237,20 +238,9 @@ function(
         target_label: enforcedNamespaceLabel,
         replacement: monitor.ObjectMeta.Namespace,
       },
+    ]) +
 
-      // Shard rules
-      {
-        source_labels: ['__address__'],
-        target_label: '__tmp_hash',
-        modulus: shards,
-        action: 'hashmod',
-      },
-      {
-        source_labels: ['__tmp_hash'],
-        regex: '$(SHARD)',
-        action: 'keep',
-      },
-    ])
+    new_shard_relabel_configs(shards)
   ),
 
   metric_relabel_configs: if endpoint.MetricRelabelConfigs != null then optionals.array(
@@ -0,0 +1,20 @@
+// Generates the relabel_configs which distribute targets across shards. Each
+// shard of a GrafanaAgent runs with a SHARD environment variable holding its
+// index, which is expanded when the config is loaded, so only targets hashing
+// to that index are kept.
+//
+// @param {number} shards
+// @param {string} sourceLabel - label to hash targets by.
+function(shards, sourceLabel='__address__') [
+  {
+    source_labels: [sourceLabel],
+    target_label: '__tmp_hash',
+    modulus: shards,
+    action: 'hashmod',
+  },
+  {
+    source_labels: ['__tmp_hash'],
+    regex: '$(SHARD)',
+    action: 'keep',
+  },
+]
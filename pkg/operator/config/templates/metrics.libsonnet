@@ -7,6 +7,22 @@ local new_pod_monitor = import 'component/metrics/pod_monitor.libsonnet';
 local new_probe = import 'component/metrics/probe.libsonnet';
 local new_remote_write = import 'component/metrics/remote_write.libsonnet';
 local new_service_monitor = import 'component/metrics/service_monitor.libsonnet';
+local new_shard_relabel_configs = import 'component/metrics/shard_relabel_configs.libsonnet';
+
+// Appends the shard relabel_configs to a user-provided scrape_config, unless
+// it already shards its targets with a hashmod into __tmp_hash.
+//
+// @param {object} job - scrape_config to shard.
+// @param {number} shards
+local shard_scrape_config(job, shards) =
+  local relabels = if std.objectHas(job, 'relabel_configs') && job.relabel_configs != null then job.relabel_configs else [];
+  local sharded = std.length(std.filter(
+    function(r) std.objectHas(r, 'action') && r.action == 'hashmod' &&
+                std.objectHas(r, 'target_label') && r.target_label == '__tmp_hash',
+    relabels,
+  )) > 0;
+  if sharded then job
+  else job { relabel_configs: relabels + new_shard_relabel_configs(shards) };
 
 // Generates a metrics_instance.
 //
@@ -122,11 +138,14 @@ function(
 
     // Finally, if the user specified additional scrape configs, we need to
     // extract their value from the secret and then unmarshal them into the
-    // array.
+    // array. When running more than one shard, the additional scrape configs
+    // are sharded too so each target is only scraped by one shard.
     k8s.array(
       if spec.AdditionalScrapeConfigs != null then (
         local rawYAML = secrets.valueForSecret(namespace, spec.AdditionalScrapeConfigs);
-        marshal.fromYAML(rawYAML)
+        local jobs = marshal.fromYAML(rawYAML);
+        if shards > 1 then std.map(function(job) shard_scrape_config(job, shards), jobs)
+        else jobs
       )
     ),
   ),
@@ -1,9 +1,12 @@
 package server
 
 import (
+	"io"
+	"strings"
 	"sync"
 
 	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
 	"github.com/weaveworks/common/logging"
 
 	cortex_log "github.com/cortexproject/cortex/pkg/util/log"
@@ -18,6 +21,11 @@ type Logger struct {
 	mut sync.RWMutex
 	l   log.Logger
 
+	// recent holds the most recent lines logged at the configured level, and
+	// recentLogger writes to it.
+	recent       *logBuffer
+	recentLogger log.Logger
+
 	// makeLogger will default to defaultLogger. It's a struct
 	// member to make testing work properly.
 	makeLogger func(*Config) (log.Logger, error)
@@ -34,13 +42,16 @@ func NewLoggerFromLevel(lvl logging.Level, fmt logging.Format) *Logger {
 	if err != nil {
 		panic(err)
 	}
+	recent := newLogBuffer(recentLogLines)
 	return &Logger{
-		l: logger,
+		l:            logger,
+		recent:       recent,
+		recentLogger: makeRecentLogger(lvl, recent),
 	}
 }
 
 func newLogger(cfg *Config, ctor func(*Config) (log.Logger, error)) *Logger {
-	l := Logger{makeLogger: ctor}
+	l := Logger{makeLogger: ctor, recent: newLogBuffer(recentLogLines)}
 	if err := l.ApplyConfig(cfg); err != nil {
 		panic(err)
 	}
@@ -58,6 +69,7 @@ func (l *Logger) ApplyConfig(cfg *Config) error {
 	}
 
 	l.l = newLogger
+	l.recentLogger = makeRecentLogger(cfg.LogLevel, l.recent)
 	return nil
 }
 
@@ -81,9 +93,67 @@ func makeDefaultLogger(lvl logging.Level, fmt logging.Format) (log.Logger, error
 func (l *Logger) Log(kvps ...interface{}) error {
 	l.mut.RLock()
 	defer l.mut.RUnlock()
+	if l.recentLogger != nil {
+		_ = l.recentLogger.Log(kvps...)
+	}
 	return l.l.Log(kvps...)
 }
 
+// WriteRecentLogs writes the most recent lines logged by l in the logfmt
+// format, regardless of the configured log format.
+func (l *Logger) WriteRecentLogs(w io.Writer) error {
+	return l.recent.writeLines(w)
+}
+
+// recentLogLines is the number of lines kept by a Logger for
+// WriteRecentLogs.
+const recentLogLines = 1000
+
+func makeRecentLogger(lvl logging.Level, recent *logBuffer) log.Logger {
+	l := level.NewFilter(log.NewLogfmtLogger(recent), lvl.Gokit)
+	// Same call depth as makeDefaultLogger.
+	return log.With(l, "ts", log.DefaultTimestampUTC, "caller", log.Caller(5))
+}
+
+// logBuffer is an io.Writer which keeps the last lines written to it. Each
+// call to Write is expected to write a single line.
+type logBuffer struct {
+	mut   sync.Mutex
+	lines []string
+	next  int
+	full  bool
+}
+
+func newLogBuffer(size int) *logBuffer {
+	return &logBuffer{lines: make([]string, size)}
+}
+
+// Write implements io.Writer.
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	b.lines[b.next] = string(p)
+	b.next = (b.next + 1) % len(b.lines)
+	if b.next == 0 {
+		b.full = true
+	}
+	return len(p), nil
+}
+
+// writeLines writes the lines in b to w, oldest first.
+func (b *logBuffer) writeLines(w io.Writer) error {
+	b.mut.Lock()
+	lines := append([]string(nil), b.lines[:b.next]...)
+	if b.full {
+		lines = append(append([]string(nil), b.lines[b.next:]...), lines...)
+	}
+	b.mut.Unlock()
+
+	_, err := io.WriteString(w, strings.Join(lines, ""))
+	return err
+}
+
 // GoKitLogger creates a logging.Interface from a log.Logger.
 func GoKitLogger(l log.Logger) logging.Interface {
 	return logging.GoKit(l)
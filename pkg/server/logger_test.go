@@ -2,6 +2,8 @@ package server
 
 import (
 	"bytes"
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/go-kit/log"
@@ -55,3 +57,28 @@ log_format: json`
 		"msg":"this should appear"
 	}`, buf.String())
 }
+
+func TestLogger_WriteRecentLogs(t *testing.T) {
+	makeLogger := func(cfg *Config) (log.Logger, error) {
+		return log.NewNopLogger(), nil
+	}
+
+	var cfg Config
+	require.NoError(t, yaml.Unmarshal([]byte(`log_level: info`), &cfg))
+
+	l := newLogger(&cfg, makeLogger)
+	level.Debug(l).Log("msg", "filtered")
+	for i := 0; i < recentLogLines+10; i++ {
+		level.Info(l).Log("msg", "line", "n", i)
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, l.WriteRecentLogs(&buf))
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, recentLogLines)
+	require.Contains(t, lines[0], "n=10")
+	require.Contains(t, lines[len(lines)-1], fmt.Sprintf("n=%d", recentLogLines+9))
+	require.Contains(t, lines[0], "caller=logger_test.go")
+	require.NotContains(t, buf.String(), "filtered")
+}
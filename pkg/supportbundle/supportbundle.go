@@ -0,0 +1,207 @@
+// Package supportbundle builds support bundles: zip archives holding the
+// state of a running agent, to be attached to issues and support requests.
+package supportbundle
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/prometheus/tsdb/wal"
+)
+
+// Source writes a file of a bundle.
+type Source struct {
+	// Name is the path of the file in the bundle.
+	Name string
+	// Write writes the contents of the file to w.
+	Write func(ctx context.Context, w io.Writer) error
+}
+
+// Write writes a bundle of sources to w. A source which fails is recorded in
+// the bundle as a file named after it with an .error suffix, so that a
+// bundle is produced even when parts of the agent are unhealthy.
+func Write(ctx context.Context, w io.Writer, sources []Source) error {
+	zw := zip.NewWriter(w)
+
+	for _, src := range sources {
+		if err := writeSource(ctx, zw, src); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func writeSource(ctx context.Context, zw *zip.Writer, src Source) error {
+	// Sources are buffered so that a source failing halfway through doesn't
+	// leave a truncated file in the bundle.
+	var buf strings.Builder
+	err := src.Write(ctx, &buf)
+
+	name, contents := src.Name, buf.String()
+	if err != nil {
+		name, contents = src.Name+".error", err.Error()+"\n"
+	}
+
+	fw, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(fw, contents)
+	return err
+}
+
+// Handler returns an http.Handler which serves a bundle of the sources
+// returned by sources at the time of the request.
+func Handler(l log.Logger, sources func() []Source) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filename := fmt.Sprintf("agent-support-bundle-%s.zip", time.Now().UTC().Format("20060102T150405Z"))
+
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+		if err := Write(r.Context(), w, sources()); err != nil {
+			level.Error(l).Log("msg", "failed to write support bundle", "err", err)
+		}
+	})
+}
+
+// JSON returns a source which writes v as indented JSON. v is only invoked
+// when the bundle is written.
+func JSON(name string, v func() (interface{}, error)) Source {
+	return Source{
+		Name: name,
+		Write: func(_ context.Context, w io.Writer) error {
+			obj, err := v()
+			if err != nil {
+				return err
+			}
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(obj)
+		},
+	}
+}
+
+// Profile returns a source which writes the runtime/pprof profile with the
+// given name. debug is passed to pprof.Profile.WriteTo.
+func Profile(name, profile string, debug int) Source {
+	return Source{
+		Name: name,
+		Write: func(_ context.Context, w io.Writer) error {
+			p := pprof.Lookup(profile)
+			if p == nil {
+				return fmt.Errorf("unknown profile %q", profile)
+			}
+			return p.WriteTo(w, debug)
+		},
+	}
+}
+
+// Metrics returns a source which writes the metrics of g in the Prometheus
+// text format.
+func Metrics(name string, g prometheus.Gatherer) Source {
+	return Source{
+		Name: name,
+		Write: func(_ context.Context, w io.Writer) error {
+			mfs, err := g.Gather()
+			if err != nil {
+				return err
+			}
+			for _, mf := range mfs {
+				if _, err := expfmt.MetricFamilyToText(w, mf); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// WALStats describes the files of a WAL directory.
+type WALStats struct {
+	Dir string `json:"dir"`
+	// Error is set when the directory can't be read.
+	Error string `json:"error,omitempty"`
+
+	SizeBytes    int64 `json:"size_bytes"`
+	FirstSegment int   `json:"first_segment"`
+	LastSegment  int   `json:"last_segment"`
+	Segments     int   `json:"segments"`
+	Checkpoints  int   `json:"checkpoints"`
+	// LastModified is the Unix time of the most recently modified file.
+	LastModified int64 `json:"last_modified,omitempty"`
+}
+
+// WAL returns a source which writes the WALStats of the WAL directories in
+// dirs, keyed by the name of their owner.
+func WAL(name string, dirs func() map[string]string) Source {
+	return JSON(name, func() (interface{}, error) {
+		ds := dirs()
+
+		stats := make(map[string]WALStats, len(ds))
+		for owner, dir := range ds {
+			stats[owner] = walStats(dir)
+		}
+		return stats, nil
+	})
+}
+
+// walStats computes the WALStats of dir. Segments are looked up in dir and in
+// a wal subdirectory, which is where the metrics WAL keeps them.
+func walStats(dir string) WALStats {
+	stats := WALStats{Dir: dir}
+
+	segmentsDir := dir
+	if fi, err := os.Stat(filepath.Join(dir, "wal")); err == nil && fi.IsDir() {
+		segmentsDir = filepath.Join(dir, "wal")
+	}
+
+	first, last, err := wal.Segments(segmentsDir)
+	if err != nil {
+		stats.Error = err.Error()
+		return stats
+	}
+	if last >= 0 {
+		stats.FirstSegment, stats.LastSegment = first, last
+		stats.Segments = last - first + 1
+	}
+
+	err = filepath.Walk(dir, func(_ string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			if strings.HasPrefix(fi.Name(), "checkpoint.") {
+				stats.Checkpoints++
+			}
+			return nil
+		}
+
+		stats.SizeBytes += fi.Size()
+		if mod := fi.ModTime().Unix(); mod > stats.LastModified {
+			stats.LastModified = mod
+		}
+		return nil
+	})
+	if err != nil {
+		stats.Error = err.Error()
+	}
+	return stats
+}
@@ -0,0 +1,114 @@
+package supportbundle
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/tsdb/wal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "test_total",
+		Help: "Test counter.",
+	}))
+
+	sources := []Source{
+		{Name: "hello.txt", Write: func(_ context.Context, w io.Writer) error {
+			_, err := io.WriteString(w, "hello")
+			return err
+		}},
+		{Name: "broken.txt", Write: func(_ context.Context, w io.Writer) error {
+			_, _ = io.WriteString(w, "partial")
+			return errors.New("source is broken")
+		}},
+		JSON("data.json", func() (interface{}, error) {
+			return map[string]int{"answer": 42}, nil
+		}),
+		Metrics("metrics.txt", reg),
+		Profile("goroutine.txt", "goroutine", 1),
+	}
+
+	srv := httptest.NewServer(Handler(log.NewNopLogger(), func() []Source { return sources }))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "application/zip", resp.Header.Get("Content-Type"))
+
+	files := readBundle(t, resp.Body)
+	require.Equal(t, "hello", files["hello.txt"])
+	require.Equal(t, "source is broken\n", files["broken.txt.error"])
+	require.NotContains(t, files, "broken.txt")
+	require.JSONEq(t, `{"answer": 42}`, files["data.json"])
+	require.Contains(t, files["metrics.txt"], "test_total 0")
+	require.Contains(t, files["goroutine.txt"], "goroutine profile:")
+}
+
+func TestWAL(t *testing.T) {
+	dir := t.TempDir()
+
+	// The metrics WAL keeps segments in a wal subdirectory.
+	metricsDir := filepath.Join(dir, "metrics")
+	w, err := wal.New(nil, nil, filepath.Join(metricsDir, "wal"), false)
+	require.NoError(t, err)
+	require.NoError(t, w.Log([]byte("record")))
+	require.NoError(t, w.NextSegment())
+	require.NoError(t, w.Close())
+	require.NoError(t, os.Mkdir(filepath.Join(metricsDir, "wal", "checkpoint.00000000"), 0755))
+
+	src := WAL("wal.json", func() map[string]string {
+		return map[string]string{
+			"metrics/test": metricsDir,
+			"logs/missing": filepath.Join(dir, "missing"),
+		}
+	})
+
+	var buf bytes.Buffer
+	require.NoError(t, src.Write(context.Background(), &buf))
+
+	stats := walStats(metricsDir)
+	require.Empty(t, stats.Error)
+	require.Equal(t, 2, stats.Segments)
+	require.Equal(t, 0, stats.FirstSegment)
+	require.Equal(t, 1, stats.LastSegment)
+	require.Equal(t, 1, stats.Checkpoints)
+	require.Greater(t, stats.SizeBytes, int64(0))
+
+	require.NotEmpty(t, walStats(filepath.Join(dir, "missing")).Error)
+	require.Contains(t, buf.String(), `"metrics/test"`)
+	require.Contains(t, buf.String(), `"logs/missing"`)
+}
+
+func readBundle(t *testing.T, r io.Reader) map[string]string {
+	t.Helper()
+
+	bb, err := io.ReadAll(r)
+	require.NoError(t, err)
+	zr, err := zip.NewReader(bytes.NewReader(bb), int64(len(bb)))
+	require.NoError(t, err)
+
+	files := make(map[string]string, len(zr.File))
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		contents, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		rc.Close()
+		files[f.Name] = string(contents)
+	}
+	return files
+}
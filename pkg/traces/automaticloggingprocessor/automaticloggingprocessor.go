@@ -45,7 +45,7 @@ type automaticLoggingProcessor struct {
 
 	cfg          *AutomaticLoggingConfig
 	logToStdout  bool
-	logsInstance *logs.Instance
+	LogsInstance *logs.Instance
 	done         atomic.Bool
 
 	labels map[string]struct{}
@@ -188,8 +188,8 @@ func (p *automaticLoggingProcessor) Start(ctx context.Context, _ component.Host)
 		if !ok {
 			return fmt.Errorf("key does not contain a logs instance")
 		}
-		p.logsInstance = logs.Instance(p.cfg.LogsName)
-		if p.logsInstance == nil {
+		p.LogsInstance = logs.Instance(p.cfg.LogsName)
+		if p.LogsInstance == nil {
 			return fmt.Errorf("logs instance %s not found", p.cfg.LogsName)
 		}
 	}
@@ -270,7 +270,7 @@ func (p *automaticLoggingProcessor) exportToLogsInstance(kind string, traceID st
 	// Add logs instance label
 	labels[model.LabelName(p.cfg.Overrides.LogsTag)] = model.LabelValue(kind)
 
-	sent := p.logsInstance.SendEntry(api.Entry{
+	sent := p.LogsInstance.SendEntry(api.Entry{
 		Labels: labels,
 		Entry: logproto.Entry{
 			Timestamp: time.Now(),
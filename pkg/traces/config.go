@@ -36,17 +36,27 @@ import (
 
 	"github.com/grafana/agent/pkg/logs"
 	"github.com/grafana/agent/pkg/traces/automaticloggingprocessor"
+	"github.com/grafana/agent/pkg/traces/limitsprocessor"
+	"github.com/grafana/agent/pkg/traces/migrationexporter"
 	"github.com/grafana/agent/pkg/traces/noopreceiver"
 	"github.com/grafana/agent/pkg/traces/promsdprocessor"
 	"github.com/grafana/agent/pkg/traces/pushreceiver"
 	"github.com/grafana/agent/pkg/traces/remotewriteexporter"
 	"github.com/grafana/agent/pkg/traces/servicegraphprocessor"
+	"github.com/grafana/agent/pkg/traces/spanlimitsprocessor"
+	"github.com/grafana/agent/pkg/traces/spanmetricsexemplars"
+	"github.com/grafana/agent/pkg/traces/tenantsamplingprocessor"
+	"github.com/grafana/agent/pkg/traces/traceratelimitprocessor"
 	"github.com/grafana/agent/pkg/util"
 )
 
 const (
 	spanMetricsPipelineName = "metrics/spanmetrics"
 
+	// migrationPipelineName is the pipeline which builds the exporters of a
+	// backend migration. It never receives spans itself.
+	migrationPipelineName = "traces/migration"
+
 	// defaultDecisionWait is the default time to wait for a trace before making a sampling decision
 	defaultDecisionWait = time.Second * 5
 
@@ -137,6 +147,22 @@ type InstanceConfig struct {
 
 	// ServiceGraphs
 	ServiceGraphs *serviceGraphsConfig `yaml:"service_graphs,omitempty"`
+
+	// TraceRateLimiting drops whole traces when the incoming span rate is
+	// above the limit, keeping admitted traces complete
+	TraceRateLimiting *traceRateLimitingConfig `yaml:"trace_rate_limiting,omitempty"`
+
+	// SpanLimits drops attributes and events of spans over the limits and
+	// truncates long attribute values
+	SpanLimits *spanLimitsConfig `yaml:"span_limits,omitempty"`
+
+	// Migration sends spans to a new backend while migrating away from the
+	// backends in RemoteWrite
+	Migration *migrationConfig `yaml:"migration,omitempty"`
+
+	// limits applies the agent-wide span rate limit of the instance. It's set
+	// by the traces subsystem when it's given a limiter.
+	limits bool
 }
 
 // ReceiverMap stores a set of receivers. Because receivers may be configured
@@ -295,6 +321,17 @@ type SpanMetricsConfig struct {
 	MetricsInstance string `yaml:"metrics_instance"`
 	// HandlerEndpoint is the address where a prometheus exporter will be exposed
 	HandlerEndpoint string `yaml:"handler_endpoint"`
+	// Exemplars configures span attributes added to the exemplars of the latency histogram.
+	Exemplars *SpanMetricsExemplarsConfig `yaml:"exemplars,omitempty"`
+}
+
+// SpanMetricsExemplarsConfig controls which span attributes are added as labels to
+// the exemplars of span metrics, along with the trace ID.
+type SpanMetricsExemplarsConfig struct {
+	// Attributes are the span attributes added to exemplars.
+	Attributes []string `yaml:"attributes,omitempty"`
+	// MaxValueLength is the length attribute values are truncated to.
+	MaxValueLength int `yaml:"max_value_length,omitempty"`
 }
 
 // tailSamplingConfig is the configuration for tail-based sampling
@@ -304,6 +341,20 @@ type tailSamplingConfig struct {
 	Policies []map[string]interface{} `yaml:"policies"`
 	// DecisionWait defines the time to wait for a complete trace before making a decision
 	DecisionWait time.Duration `yaml:"decision_wait,omitempty"`
+	// TenantAttribute is the resource attribute holding the tenant of spans
+	TenantAttribute string `yaml:"tenant_attribute,omitempty"`
+	// TenantHeader is the request header holding the tenant of spans
+	TenantHeader string `yaml:"tenant_header,omitempty"`
+	// Tenants defines sampling strategies for individual tenants. Traces of other tenants are sampled with Policies
+	Tenants []tenantSamplingConfig `yaml:"tenants,omitempty"`
+}
+
+// tenantSamplingConfig is the configuration for tail-based sampling of the traces of a tenant
+type tenantSamplingConfig struct {
+	// Name is the tenant, as found in the tenant attribute or header
+	Name         string                   `yaml:"name"`
+	Policies     []map[string]interface{} `yaml:"policies"`
+	DecisionWait time.Duration            `yaml:"decision_wait,omitempty"`
 }
 
 // loadBalancingConfig defines the configuration for load balancing spans between agent instances
@@ -328,6 +379,25 @@ type serviceGraphsConfig struct {
 	Enabled  bool          `yaml:"enabled,omitempty"`
 	Wait     time.Duration `yaml:"wait,omitempty"`
 	MaxItems int           `yaml:"max_items,omitempty"`
+	// MetricsInstance is the Agent's metrics instance that will be used to push metrics
+	MetricsInstance string `yaml:"metrics_instance,omitempty"`
+}
+
+type traceRateLimitingConfig struct {
+	SpansPerSecond float64 `yaml:"spans_per_second,omitempty"`
+}
+
+type spanLimitsConfig struct {
+	MaxAttributes           int `yaml:"max_attributes,omitempty"`
+	MaxEvents               int `yaml:"max_events,omitempty"`
+	MaxAttributeValueLength int `yaml:"max_attribute_value_length,omitempty"`
+}
+
+// migrationConfig configures the backend spans are migrated to
+type migrationConfig struct {
+	RemoteWrite     RemoteWriteConfig `yaml:"remote_write"`
+	Mode            string            `yaml:"mode,omitempty"`
+	SplitPercentage float64           `yaml:"split_percentage,omitempty"`
 }
 
 // exporter builds an OTel exporter from RemoteWriteConfig
@@ -433,10 +503,21 @@ func getExporterName(index int, protocol string, format string) (string, error)
 	}
 }
 
+// remoteWriteConfigs returns the configs of all backends spans are sent to.
+// The backend of a migration is always last.
+func (c *InstanceConfig) remoteWriteConfigs() []RemoteWriteConfig {
+	if c.Migration == nil {
+		return c.RemoteWrite
+	}
+	configs := make([]RemoteWriteConfig, 0, len(c.RemoteWrite)+1)
+	configs = append(configs, c.RemoteWrite...)
+	return append(configs, c.Migration.RemoteWrite)
+}
+
 // exporters builds one or multiple exporters from a remote_write block.
 func (c *InstanceConfig) exporters() (map[string]interface{}, error) {
 	exporters := map[string]interface{}{}
-	for i, remoteWriteConfig := range c.RemoteWrite {
+	for i, remoteWriteConfig := range c.remoteWriteConfigs() {
 		exporter, err := exporter(remoteWriteConfig)
 		if err != nil {
 			return nil, err
@@ -460,7 +541,7 @@ func getAuthExtensionName(exporterName string) string {
 // builds oauth2clientauth extensions required to support RemoteWriteConfigurations.
 func (c *InstanceConfig) extensions() (map[string]interface{}, error) {
 	extensions := map[string]interface{}{}
-	for i, remoteWriteConfig := range c.RemoteWrite {
+	for i, remoteWriteConfig := range c.remoteWriteConfigs() {
 		if remoteWriteConfig.Oauth2 == nil {
 			continue
 		}
@@ -477,6 +558,48 @@ func (c *InstanceConfig) extensions() (map[string]interface{}, error) {
 	return extensions, nil
 }
 
+// migrationExporter builds the exporter which sends spans to the exporters of
+// the old and new backends of a migration.
+func (c *InstanceConfig) migrationExporter() (map[string]interface{}, error) {
+	if len(c.RemoteWrite) == 0 {
+		return nil, errors.New("migration requires at least one remote_write backend to migrate from")
+	}
+
+	mode := c.Migration.Mode
+	if mode == "" {
+		mode = migrationexporter.ModeDuplicate
+	}
+	switch mode {
+	case migrationexporter.ModeDuplicate:
+	case migrationexporter.ModeSplit:
+		if c.Migration.SplitPercentage < 0 || c.Migration.SplitPercentage > 100 {
+			return nil, fmt.Errorf("migration.split_percentage must be between 0 and 100")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported migration mode '%s', expected '%s' or '%s'", mode, migrationexporter.ModeDuplicate, migrationexporter.ModeSplit)
+	}
+
+	var oldExporters, newExporters []string
+	for i, remoteWriteConfig := range c.remoteWriteConfigs() {
+		exporterName, err := getExporterName(i, remoteWriteConfig.Protocol, remoteWriteConfig.Format)
+		if err != nil {
+			return nil, err
+		}
+		if i < len(c.RemoteWrite) {
+			oldExporters = append(oldExporters, exporterName)
+		} else {
+			newExporters = append(newExporters, exporterName)
+		}
+	}
+
+	return map[string]interface{}{
+		"mode":             mode,
+		"split_percentage": c.Migration.SplitPercentage,
+		"old_exporters":    oldExporters,
+		"new_exporters":    newExporters,
+	}, nil
+}
+
 func resolver(config map[string]interface{}) (map[string]interface{}, error) {
 	if len(config) == 0 {
 		return nil, fmt.Errorf("must configure one resolver (dns or static)")
@@ -546,6 +669,49 @@ func formatPolicies(cfg []map[string]interface{}) ([]map[string]interface{}, err
 	return policies, nil
 }
 
+// tenantSampling builds the config of the processor applying the tail
+// sampling policies of tenants. policies and wait apply to the traces of
+// other tenants.
+func (c *InstanceConfig) tenantSampling(policies []map[string]interface{}, wait time.Duration) (map[string]interface{}, error) {
+	ts := c.TailSampling
+	if (ts.TenantAttribute == "") == (ts.TenantHeader == "") {
+		return nil, errors.New("tail_sampling tenants require exactly one of tenant_attribute and tenant_header")
+	}
+
+	names := make(map[string]struct{}, len(ts.Tenants))
+	tenants := make([]map[string]interface{}, 0, len(ts.Tenants))
+	for _, tenant := range ts.Tenants {
+		if tenant.Name == "" {
+			return nil, errors.New("tail_sampling tenant is missing a name")
+		}
+		if _, exist := names[tenant.Name]; exist {
+			return nil, fmt.Errorf("found multiple tail_sampling configs for tenant %s", tenant.Name)
+		}
+		names[tenant.Name] = struct{}{}
+
+		if len(tenant.Policies) == 0 {
+			return nil, fmt.Errorf("tail_sampling tenant %s must have at least one policy", tenant.Name)
+		}
+		tenantPolicies, err := formatPolicies(tenant.Policies)
+		if err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, map[string]interface{}{
+			"name":          tenant.Name,
+			"policies":      tenantPolicies,
+			"decision_wait": tenant.DecisionWait,
+		})
+	}
+
+	return map[string]interface{}{
+		"tenant_attribute": ts.TenantAttribute,
+		"tenant_header":    ts.TenantHeader,
+		"policies":         policies,
+		"decision_wait":    wait,
+		"tenants":          tenants,
+	}, nil
+}
+
 func (c *InstanceConfig) otelConfig() (*config.Config, error) {
 	otelMapStructure := map[string]interface{}{}
 
@@ -637,11 +803,28 @@ func (c *InstanceConfig) otelConfig() (*config.Config, error) {
 		}
 
 		processorNames = append(processorNames, "spanmetrics")
-		processors["spanmetrics"] = map[string]interface{}{
+		spanMetrics := map[string]interface{}{
 			"metrics_exporter":          exporterName,
 			"latency_histogram_buckets": c.SpanMetrics.LatencyHistogramBuckets,
 			"dimensions":                c.SpanMetrics.Dimensions,
 		}
+		if c.SpanMetrics.Exemplars != nil {
+			if len(c.SpanMetrics.Exemplars.Attributes) > spanmetricsexemplars.MaxAttributes {
+				return nil, fmt.Errorf("at most %d spanmetrics exemplar attributes may be configured", spanmetricsexemplars.MaxAttributes)
+			}
+			if c.SpanMetrics.Exemplars.MaxValueLength < 0 {
+				return nil, fmt.Errorf("spanmetrics exemplar max_value_length must not be negative")
+			}
+
+			exemplars := map[string]interface{}{
+				"attributes": c.SpanMetrics.Exemplars.Attributes,
+			}
+			if c.SpanMetrics.Exemplars.MaxValueLength != 0 {
+				exemplars["max_value_length"] = c.SpanMetrics.Exemplars.MaxValueLength
+			}
+			spanMetrics["exemplars"] = exemplars
+		}
+		processors["spanmetrics"] = spanMetrics
 
 		pipelines[spanMetricsPipelineName] = map[string]interface{}{
 			"receivers": []string{noopreceiver.TypeStr},
@@ -668,10 +851,19 @@ func (c *InstanceConfig) otelConfig() (*config.Config, error) {
 
 		// tail_sampling should be executed before the batch processor
 		// TODO(mario.rodriguez): put attributes processor before tail_sampling. Maybe we want to sample on mutated spans
-		processorNames = append([]string{"tail_sampling"}, processorNames...)
-		processors["tail_sampling"] = map[string]interface{}{
-			"policies":      policies,
-			"decision_wait": wait,
+		if len(c.TailSampling.Tenants) == 0 {
+			processorNames = append([]string{"tail_sampling"}, processorNames...)
+			processors["tail_sampling"] = map[string]interface{}{
+				"policies":      policies,
+				"decision_wait": wait,
+			}
+		} else {
+			tenantSampling, err := c.tenantSampling(policies, wait)
+			if err != nil {
+				return nil, err
+			}
+			processorNames = append([]string{tenantsamplingprocessor.TypeStr}, processorNames...)
+			processors[tenantsamplingprocessor.TypeStr] = tenantSampling
 		}
 	}
 
@@ -697,12 +889,63 @@ func (c *InstanceConfig) otelConfig() (*config.Config, error) {
 
 	if c.ServiceGraphs != nil && c.ServiceGraphs.Enabled {
 		processors[servicegraphprocessor.TypeStr] = map[string]interface{}{
-			"wait":      c.ServiceGraphs.Wait,
-			"max_items": c.ServiceGraphs.MaxItems,
+			"wait":             c.ServiceGraphs.Wait,
+			"max_items":        c.ServiceGraphs.MaxItems,
+			"metrics_instance": c.ServiceGraphs.MetricsInstance,
 		}
 		processorNames = append(processorNames, servicegraphprocessor.TypeStr)
 	}
 
+	if c.limits {
+		processors[limitsprocessor.TypeStr] = map[string]interface{}{
+			"tenant": c.Name,
+		}
+		processorNames = append(processorNames, limitsprocessor.TypeStr)
+	}
+
+	if c.TraceRateLimiting != nil {
+		if c.TraceRateLimiting.SpansPerSecond <= 0 {
+			return nil, fmt.Errorf("trace_rate_limiting.spans_per_second must be greater than 0")
+		}
+		processors[traceratelimitprocessor.TypeStr] = map[string]interface{}{
+			"spans_per_second": c.TraceRateLimiting.SpansPerSecond,
+		}
+		processorNames = append(processorNames, traceratelimitprocessor.TypeStr)
+	}
+
+	if c.SpanLimits != nil {
+		l := c.SpanLimits
+		if l.MaxAttributes < 0 || l.MaxEvents < 0 || l.MaxAttributeValueLength < 0 {
+			return nil, fmt.Errorf("span_limits must not be negative")
+		}
+		if l.MaxAttributes == 0 && l.MaxEvents == 0 && l.MaxAttributeValueLength == 0 {
+			return nil, fmt.Errorf("span_limits must set at least one limit")
+		}
+		processors[spanlimitsprocessor.TypeStr] = map[string]interface{}{
+			"max_attributes":             l.MaxAttributes,
+			"max_events":                 l.MaxEvents,
+			"max_attribute_value_length": l.MaxAttributeValueLength,
+		}
+		processorNames = append(processorNames, spanlimitsprocessor.TypeStr)
+	}
+
+	// Spans are sent to the exporters of a migration through the migration
+	// exporter. The exporters are built by a separate pipeline.
+	tracesExportersNames := exportersNames
+	if c.Migration != nil {
+		migrationExporter, err := c.migrationExporter()
+		if err != nil {
+			return nil, err
+		}
+		exporters[migrationexporter.TypeStr] = migrationExporter
+		tracesExportersNames = []string{migrationexporter.TypeStr}
+
+		pipelines[migrationPipelineName] = map[string]interface{}{
+			"receivers": []string{noopreceiver.TypeStr},
+			"exporters": exportersNames,
+		}
+	}
+
 	// Build Pipelines
 	splitPipeline := c.LoadBalancing != nil
 	orderedSplitProcessors := orderProcessors(processorNames, splitPipeline)
@@ -715,20 +958,20 @@ func (c *InstanceConfig) otelConfig() (*config.Config, error) {
 		}
 		// processing pipeline
 		pipelines["traces/1"] = map[string]interface{}{
-			"exporters":  exportersNames,
+			"exporters":  tracesExportersNames,
 			"processors": orderedSplitProcessors[1],
 			"receivers":  []string{"otlp/lb"},
 		}
 	} else {
 		pipelines["traces"] = map[string]interface{}{
-			"exporters":  exportersNames,
+			"exporters":  tracesExportersNames,
 			"processors": orderedSplitProcessors[0],
 			"receivers":  receiverNames,
 		}
 	}
 
-	if c.SpanMetrics != nil {
-		// Insert a noop receiver in the metrics pipeline.
+	if c.SpanMetrics != nil || c.Migration != nil {
+		// Insert a noop receiver in the metrics or migration pipeline.
 		// Added to pass validation requiring at least one receiver in a pipeline.
 		c.Receivers[noopreceiver.TypeStr] = nil
 	}
@@ -797,6 +1040,7 @@ func tracingFactories() (component.Factories, error) {
 		loadbalancingexporter.NewFactory(),
 		prometheusexporter.NewFactory(),
 		remotewriteexporter.NewFactory(),
+		migrationexporter.NewFactory(),
 	)
 	if err != nil {
 		return component.Factories{}, err
@@ -806,10 +1050,14 @@ func tracingFactories() (component.Factories, error) {
 		batchprocessor.NewFactory(),
 		attributesprocessor.NewFactory(),
 		promsdprocessor.NewFactory(),
-		spanmetricsprocessor.NewFactory(),
+		spanmetricsexemplars.NewFactory(),
 		automaticloggingprocessor.NewFactory(),
 		tailsamplingprocessor.NewFactory(),
+		tenantsamplingprocessor.NewFactory(),
 		servicegraphprocessor.NewFactory(),
+		traceratelimitprocessor.NewFactory(),
+		spanlimitsprocessor.NewFactory(),
+		limitsprocessor.NewFactory(),
 	)
 	if err != nil {
 		return component.Factories{}, err
@@ -828,12 +1076,16 @@ func tracingFactories() (component.Factories, error) {
 // sets: before and after load balancing
 func orderProcessors(processors []string, splitPipelines bool) [][]string {
 	order := map[string]int{
-		"attributes":        0,
-		"spanmetrics":       1,
-		"service_graphs":    2,
-		"tail_sampling":     3,
-		"automatic_logging": 4,
-		"batch":             5,
+		"limits":               -3,
+		"trace_rate_limit":     -2,
+		"span_limits":          -1,
+		"attributes":           0,
+		"spanmetrics":          1,
+		"service_graphs":       2,
+		"tail_sampling":        3,
+		"tenant_tail_sampling": 3,
+		"automatic_logging":    4,
+		"batch":                5,
 	}
 
 	sort.Slice(processors, func(i, j int) bool {
@@ -855,6 +1107,7 @@ func orderProcessors(processors []string, splitPipelines bool) [][]string {
 	for i, processor := range processors {
 		if processor == "batch" ||
 			processor == "tail_sampling" ||
+			processor == "tenant_tail_sampling" ||
 			processor == "automatic_logging" ||
 			processor == "service_graphs" {
 
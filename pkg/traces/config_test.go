@@ -610,6 +610,70 @@ remote_write:
 spanmetrics:
   handler_endpoint: "0.0.0.0:8889"
   metrics_instance: traces
+`,
+			expectedError: true,
+		},
+		{
+			name: "span metrics exemplar attributes",
+			cfg: `
+receivers:
+  jaeger:
+    protocols:
+      grpc:
+remote_write:
+  - endpoint: example.com:12345
+spanmetrics:
+  metrics_instance: traces
+  exemplars:
+    attributes: [http.route, http.status_code]
+    max_value_length: 64
+`,
+			expectedConfig: `
+receivers:
+  push_receiver: {}
+  noop:
+  jaeger:
+    protocols:
+      grpc:
+exporters:
+  otlp/0:
+    endpoint: example.com:12345
+    compression: gzip
+    retry_on_failure:
+      max_elapsed_time: 60s
+  remote_write:
+    namespace: traces_spanmetrics
+    metrics_instance: traces
+processors:
+  spanmetrics:
+    metrics_exporter: remote_write
+    exemplars:
+      attributes: [http.route, http.status_code]
+      max_value_length: 64
+service:
+  pipelines:
+    traces:
+      exporters: ["otlp/0"]
+      processors: ["spanmetrics"]
+      receivers: ["push_receiver", "jaeger"]
+    metrics/spanmetrics:
+      exporters: ["remote_write"]
+      receivers: ["noop"]
+`,
+		},
+		{
+			name: "span metrics too many exemplar attributes",
+			cfg: `
+receivers:
+  jaeger:
+    protocols:
+      grpc:
+remote_write:
+  - endpoint: example.com:12345
+spanmetrics:
+  metrics_instance: traces
+  exemplars:
+    attributes: [a, b, c, d, e, f]
 `,
 			expectedError: true,
 		},
@@ -782,6 +846,120 @@ service:
       receivers: ["otlp/lb"]
 `,
 		},
+		{
+			name: "tail sampling config with tenants",
+			cfg: `
+receivers:
+  jaeger:
+    protocols:
+      grpc:
+remote_write:
+  - endpoint: example.com:12345
+tail_sampling:
+  policies:
+    - probabilistic:
+        sampling_percentage: 10
+  tenant_header: X-Scope-OrgID
+  tenants:
+    - name: team-a
+      decision_wait: 10s
+      policies:
+        - latency:
+            threshold_ms: 5000
+        - rate_limiting:
+            spans_per_second: 100
+    - name: team-b
+      policies:
+        - status_code:
+            status_codes:
+              - ERROR
+`,
+			expectedConfig: `
+receivers:
+  push_receiver: {}
+  jaeger:
+    protocols:
+      grpc:
+exporters:
+  otlp/0:
+    endpoint: example.com:12345
+    compression: gzip
+    retry_on_failure:
+      max_elapsed_time: 60s
+processors:
+  tenant_tail_sampling:
+    tenant_header: X-Scope-OrgID
+    decision_wait: 5s
+    policies:
+      - name: probabilistic/0
+        type: probabilistic
+        probabilistic:
+          sampling_percentage: 10
+    tenants:
+      - name: team-a
+        decision_wait: 10s
+        policies:
+          - name: latency/0
+            type: latency
+            latency:
+              threshold_ms: 5000
+          - name: rate_limiting/1
+            type: rate_limiting
+            rate_limiting:
+              spans_per_second: 100
+      - name: team-b
+        policies:
+          - name: status_code/0
+            type: status_code
+            status_code:
+              status_codes:
+                - ERROR
+service:
+  pipelines:
+    traces:
+      exporters: ["otlp/0"]
+      processors: ["tenant_tail_sampling"]
+      receivers: ["push_receiver", "jaeger"]
+`,
+		},
+		{
+			name: "tail sampling tenants without tenant source",
+			cfg: `
+receivers:
+  jaeger:
+    protocols:
+      grpc:
+remote_write:
+  - endpoint: example.com:12345
+tail_sampling:
+  tenants:
+    - name: team-a
+      policies:
+        - always_sample:
+`,
+			expectedError: true,
+		},
+		{
+			name: "tail sampling duplicate tenants",
+			cfg: `
+receivers:
+  jaeger:
+    protocols:
+      grpc:
+remote_write:
+  - endpoint: example.com:12345
+tail_sampling:
+  tenant_attribute: tenant
+  tenants:
+    - name: team-a
+      policies:
+        - always_sample:
+    - name: team-a
+      policies:
+        - always_sample:
+`,
+			expectedError: true,
+		},
 		{
 			name: "automatic logging : default",
 			cfg: `
@@ -967,6 +1145,42 @@ service:
       exporters: ["otlp/0"]
       processors: ["service_graphs"]
       receivers: ["push_receiver", "jaeger"]
+`,
+		},
+		{
+			name: "service graphs with metrics instance",
+			cfg: `
+receivers:
+  jaeger:
+    protocols:
+      grpc:
+remote_write:
+  - endpoint: example.com:12345
+service_graphs:
+  enabled: true
+  metrics_instance: traces
+`,
+			expectedConfig: `
+receivers:
+  push_receiver: {}
+  jaeger:
+    protocols:
+      grpc:
+exporters:
+  otlp/0:
+    endpoint: example.com:12345
+    compression: gzip
+    retry_on_failure:
+      max_elapsed_time: 60s
+processors:
+  service_graphs:
+    metrics_instance: traces
+service:
+  pipelines:
+    traces:
+      exporters: ["otlp/0"]
+      processors: ["service_graphs"]
+      receivers: ["push_receiver", "jaeger"]
 `,
 		},
 		{
@@ -1381,6 +1595,84 @@ service:
       receivers: ["push_receiver", "otlp/0", "otlp/1"]
 `,
 		},
+		{
+			name: "migration",
+			cfg: `
+receivers:
+  jaeger:
+    protocols:
+      grpc:
+remote_write:
+  - endpoint: example.com:12345
+migration:
+  mode: split
+  split_percentage: 25
+  remote_write:
+    endpoint: new.example.com:12345
+`,
+			expectedConfig: `
+receivers:
+  push_receiver: {}
+  noop:
+  jaeger:
+    protocols:
+      grpc:
+exporters:
+  otlp/0:
+    endpoint: example.com:12345
+    compression: gzip
+    retry_on_failure:
+      max_elapsed_time: 60s
+  otlp/1:
+    endpoint: new.example.com:12345
+    compression: gzip
+    retry_on_failure:
+      max_elapsed_time: 60s
+  migration:
+    mode: split
+    split_percentage: 25
+    old_exporters: ["otlp/0"]
+    new_exporters: ["otlp/1"]
+service:
+  pipelines:
+    traces:
+      exporters: ["migration"]
+      processors: []
+      receivers: ["push_receiver", "jaeger"]
+    traces/migration:
+      exporters: ["otlp/0", "otlp/1"]
+      receivers: ["noop"]
+`,
+		},
+		{
+			name: "migration without remote_write",
+			cfg: `
+receivers:
+  jaeger:
+    protocols:
+      grpc:
+migration:
+  remote_write:
+    endpoint: new.example.com:12345
+`,
+			expectedError: true,
+		},
+		{
+			name: "migration with invalid mode",
+			cfg: `
+receivers:
+  jaeger:
+    protocols:
+      grpc:
+remote_write:
+  - endpoint: example.com:12345
+migration:
+  mode: mirror
+  remote_write:
+    endpoint: new.example.com:12345
+`,
+			expectedError: true,
+		},
 	}
 
 	for _, tc := range tt {
@@ -1603,6 +1895,70 @@ load_balancing:
 				spanMetricsPipelineName: nil,
 			},
 		},
+		{
+			name: "trace rate limiting with load balancing",
+			cfg: `
+receivers:
+  jaeger:
+    protocols:
+      grpc:
+remote_write:
+  - endpoint: example.com:12345
+attributes:
+  actions:
+  - key: montgomery
+    value: forever
+    action: update
+batch:
+  timeout: 5s
+load_balancing:
+  exporter:
+    tls:
+      insecure: true
+  resolver:
+    dns:
+      hostname: agent
+      port: 4318
+trace_rate_limiting:
+  spans_per_second: 1000
+`,
+			expectedProcessors: map[string][]config.ComponentID{
+				"traces/0": {
+					config.NewComponentID("trace_rate_limit"),
+					config.NewComponentID("attributes"),
+				},
+				"traces/1": {
+					config.NewComponentID("batch"),
+				},
+			},
+		},
+		{
+			name: "span limits with trace rate limiting",
+			cfg: `
+receivers:
+  jaeger:
+    protocols:
+      grpc:
+remote_write:
+  - endpoint: example.com:12345
+attributes:
+  actions:
+  - key: montgomery
+    value: forever
+    action: update
+span_limits:
+  max_events: 128
+trace_rate_limiting:
+  spans_per_second: 1000
+`,
+			expectedProcessors: map[string][]config.ComponentID{
+				"traces": {
+					config.NewComponentID("trace_rate_limit"),
+					config.NewComponentID("span_limits"),
+					config.NewComponentID("attributes"),
+				},
+			},
+		},
 	}
 
 	for _, tc := range tt {
@@ -1737,6 +2093,27 @@ func TestOrderProcessors(t *testing.T) {
 				{},
 			},
 		},
+		{
+			processors: []string{
+				"batch",
+				"trace_rate_limit",
+				"attributes",
+				"limits",
+				"tail_sampling",
+			},
+			splitPipelines: true,
+			expected: [][]string{
+				{
+					"limits",
+					"trace_rate_limit",
+					"attributes",
+				},
+				{
+					"tail_sampling",
+					"batch",
+				},
+			},
+		},
 	}
 
 	for _, tc := range tests {
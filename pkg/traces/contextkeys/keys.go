@@ -11,4 +11,7 @@ const (
 
 	// PrometheusRegisterer is used to pass prometheus.Registerer through the context
 	PrometheusRegisterer
+
+	// Limiter is used to pass *limits.Limiter through the context
+	Limiter
 )
@@ -17,6 +17,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/grafana/agent/pkg/build"
+	"github.com/grafana/agent/pkg/limits"
 	"github.com/grafana/agent/pkg/logs"
 	"github.com/grafana/agent/pkg/metrics/instance"
 	"github.com/grafana/agent/pkg/traces/automaticloggingprocessor"
@@ -36,14 +37,17 @@ type Instance struct {
 	pipelines  builder.BuiltPipelines
 	receivers  builder.Receivers
 	factories  component.Factories
+
+	limiter *limits.Limiter
 }
 
 // NewInstance creates and starts an instance of tracing pipelines.
-func NewInstance(logsSubsystem *logs.Logs, reg prometheus.Registerer, cfg InstanceConfig, logger *zap.Logger, promInstanceManager instance.Manager) (*Instance, error) {
+func NewInstance(logsSubsystem *logs.Logs, reg prometheus.Registerer, cfg InstanceConfig, logger *zap.Logger, promInstanceManager instance.Manager, limiter *limits.Limiter) (*Instance, error) {
 	var err error
 
 	instance := &Instance{}
 	instance.logger = logger
+	instance.limiter = limiter
 	instance.metricViews, err = newMetricViews(reg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create metric views: %w", err)
@@ -178,10 +182,14 @@ func (i *Instance) buildAndStartPipeline(ctx context.Context, cfg InstanceConfig
 		ctx = context.WithValue(ctx, contextkeys.Logs, logs)
 	}
 
-	if cfg.ServiceGraphs != nil {
-		ctx = context.WithValue(ctx, contextkeys.PrometheusRegisterer, reg)
+	if cfg.limits {
+		ctx = context.WithValue(ctx, contextkeys.Limiter, i.limiter)
 	}
 
+	// Several processors register their own metrics, so the registerer is
+	// always passed through.
+	ctx = context.WithValue(ctx, contextkeys.PrometheusRegisterer, reg)
+
 	factories, err := tracingFactories()
 	if err != nil {
 		return fmt.Errorf("failed to load tracing factories: %w", err)
@@ -0,0 +1,46 @@
+package limitsprocessor
+
+import (
+	"context"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config"
+	"go.opentelemetry.io/collector/consumer"
+)
+
+// TypeStr is the unique identifier for the limits processor.
+const TypeStr = "limits"
+
+// Config holds the configuration for the limits processor.
+type Config struct {
+	config.ProcessorSettings `mapstructure:",squash"`
+
+	// Tenant is the name of the tenant whose limits are applied.
+	Tenant string `mapstructure:"tenant"`
+}
+
+// NewFactory returns a new factory for the limits processor.
+func NewFactory() component.ProcessorFactory {
+	return component.NewProcessorFactory(
+		TypeStr,
+		createDefaultConfig,
+		component.WithTracesProcessor(createTracesProcessor),
+	)
+}
+
+func createDefaultConfig() config.Processor {
+	return &Config{
+		ProcessorSettings: config.NewProcessorSettings(config.NewComponentIDWithName(TypeStr, TypeStr)),
+	}
+}
+
+func createTracesProcessor(
+	_ context.Context,
+	_ component.ProcessorCreateSettings,
+	cfg config.Processor,
+	nextConsumer consumer.Traces,
+) (component.TracesProcessor, error) {
+
+	eCfg := cfg.(*Config)
+	return newProcessor(nextConsumer, eCfg), nil
+}
@@ -0,0 +1,57 @@
+// Package limitsprocessor applies the span rate limit of a tenant, as
+// configured in the agent-wide limits block, to a traces pipeline.
+package limitsprocessor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/grafana/agent/pkg/limits"
+	"github.com/grafana/agent/pkg/traces/contextkeys"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/model/pdata"
+)
+
+type processor struct {
+	nextConsumer consumer.Traces
+	tenant       string
+	limiter      *limits.Limiter
+}
+
+func newProcessor(nextConsumer consumer.Traces, cfg *Config) *processor {
+	return &processor{
+		nextConsumer: nextConsumer,
+		tenant:       cfg.Tenant,
+	}
+}
+
+func (p *processor) Start(ctx context.Context, _ component.Host) error {
+	limiter, ok := ctx.Value(contextkeys.Limiter).(*limits.Limiter)
+	if !ok || limiter == nil {
+		return fmt.Errorf("key does not contain a limiter")
+	}
+	p.limiter = limiter
+	return nil
+}
+
+func (p *processor) Shutdown(context.Context) error {
+	return nil
+}
+
+func (p *processor) Capabilities() consumer.Capabilities {
+	return consumer.Capabilities{MutatesData: false}
+}
+
+// ConsumeTraces passes td to the next consumer if it's within the span rate
+// of the tenant. Batches over the limit are dropped whole, and an error is
+// returned to the receiver under the reject policy.
+func (p *processor) ConsumeTraces(ctx context.Context, td pdata.Traces) error {
+	if err := p.limiter.AdmitSpans(p.tenant, td.SpanCount()); err != nil {
+		if limits.Rejected(err) {
+			return err
+		}
+		return nil
+	}
+	return p.nextConsumer.ConsumeTraces(ctx, td)
+}
@@ -0,0 +1,74 @@
+package limitsprocessor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/grafana/agent/pkg/limits"
+	"github.com/grafana/agent/pkg/traces/contextkeys"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/model/pdata"
+)
+
+func TestConsumeTraces(t *testing.T) {
+	tt := []struct {
+		name   string
+		policy limits.Policy
+	}{
+		{name: "drop", policy: limits.PolicyDrop},
+		{name: "reject", policy: limits.PolicyReject},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			limiter := limits.New(prometheus.NewRegistry(), limits.Config{
+				Policy:  tc.policy,
+				Tenants: map[string]limits.TenantLimits{"test": {MaxSpansPerSecond: 10}},
+			})
+
+			next := &mockConsumer{}
+			p := newProcessor(next, &Config{Tenant: "test"})
+			ctx := context.WithValue(context.Background(), contextkeys.Limiter, limiter)
+			require.NoError(t, p.Start(ctx, nil))
+
+			require.NoError(t, p.ConsumeTraces(context.Background(), generateTraces(10)))
+			require.Equal(t, 10, next.spans)
+
+			// The burst of the tenant is used up, so the next batch is refused.
+			err := p.ConsumeTraces(context.Background(), generateTraces(10))
+			if tc.policy == limits.PolicyReject {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, 10, next.spans)
+		})
+	}
+}
+
+func TestStart_NoLimiter(t *testing.T) {
+	p := newProcessor(&mockConsumer{}, &Config{Tenant: "test"})
+	require.Error(t, p.Start(context.Background(), nil))
+}
+
+func generateTraces(numSpans int) pdata.Traces {
+	td := pdata.NewTraces()
+	spans := td.ResourceSpans().AppendEmpty().InstrumentationLibrarySpans().AppendEmpty().Spans()
+	for i := 0; i < numSpans; i++ {
+		spans.AppendEmpty()
+	}
+	return td
+}
+
+type mockConsumer struct {
+	spans int
+}
+
+func (m *mockConsumer) Capabilities() consumer.Capabilities { return consumer.Capabilities{} }
+
+func (m *mockConsumer) ConsumeTraces(_ context.Context, td pdata.Traces) error {
+	m.spans += td.SpanCount()
+	return nil
+}
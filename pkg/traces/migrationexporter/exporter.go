@@ -0,0 +1,279 @@
+// Package migrationexporter sends spans to two sets of backends while
+// migrating from an old tracing backend to a new one. Spans are either
+// duplicated to both sets of backends, or a percentage of traces is sent to
+// the new backends and the rest to the old ones.
+//
+// The exporter doesn't send spans itself; it forwards them to other traces
+// exporters configured in the same instance, which it looks up when started.
+package migrationexporter
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+
+	util "github.com/cortexproject/cortex/pkg/util/log"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/agent/pkg/traces/contextkeys"
+	"github.com/hashicorp/go-multierror"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/model/pdata"
+)
+
+const (
+	backendOld = "old"
+	backendNew = "new"
+)
+
+type backendExporter struct {
+	id       string
+	backend  string
+	exporter consumer.Traces
+}
+
+type exporter struct {
+	cfg    *Config
+	logger log.Logger
+
+	// newThreshold is the trace ID hash below which traces are sent to the new
+	// backends in ModeSplit.
+	newThreshold uint64
+
+	oldExporters []backendExporter
+	newExporters []backendExporter
+
+	reg         prometheus.Registerer
+	spansSent   *prometheus.CounterVec
+	spansFailed *prometheus.CounterVec
+}
+
+func newExporter(cfg *Config) (*exporter, error) {
+	switch cfg.Mode {
+	case ModeDuplicate:
+	case ModeSplit:
+		if cfg.SplitPercentage < 0 || cfg.SplitPercentage > 100 {
+			return nil, fmt.Errorf("split_percentage must be between 0 and 100")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported mode %q, expected %q or %q", cfg.Mode, ModeDuplicate, ModeSplit)
+	}
+	if len(cfg.OldExporters) == 0 || len(cfg.NewExporters) == 0 {
+		return nil, fmt.Errorf("at least one old and one new exporter must be configured")
+	}
+
+	return &exporter{
+		cfg:          cfg,
+		logger:       log.With(util.Logger, "component", "traces migration exporter"),
+		newThreshold: splitThreshold(cfg.SplitPercentage),
+	}, nil
+}
+
+// splitThreshold returns the trace ID hash below which traces are sent to the
+// new backends.
+func splitThreshold(percentage float64) uint64 {
+	if percentage >= 100 {
+		return math.MaxUint64
+	}
+	return uint64(percentage / 100 * math.MaxUint64)
+}
+
+func (e *exporter) Start(ctx context.Context, host component.Host) error {
+	reg, ok := ctx.Value(contextkeys.PrometheusRegisterer).(prometheus.Registerer)
+	if !ok || reg == nil {
+		return fmt.Errorf("key does not contain a prometheus registerer")
+	}
+	e.reg = reg
+	if err := e.registerMetrics(); err != nil {
+		return err
+	}
+
+	// The exporters are only looked up and not started; they're part of their
+	// own pipeline and are started by the instance.
+	available := host.GetExporters()[config.TracesDataType]
+
+	var err error
+	if e.oldExporters, err = lookupExporters(available, backendOld, e.cfg.OldExporters); err != nil {
+		return err
+	}
+	e.newExporters, err = lookupExporters(available, backendNew, e.cfg.NewExporters)
+	return err
+}
+
+func lookupExporters(available map[config.ComponentID]component.Exporter, backend string, ids []string) ([]backendExporter, error) {
+	res := make([]backendExporter, 0, len(ids))
+	for _, id := range ids {
+		var found component.Exporter
+		for availableID, exp := range available {
+			if availableID.String() == id {
+				found = exp
+				break
+			}
+		}
+		if found == nil {
+			return nil, fmt.Errorf("failed to find traces exporter %q for the %s backend", id, backend)
+		}
+
+		tracesExporter, ok := found.(component.TracesExporter)
+		if !ok {
+			return nil, fmt.Errorf("exporter %q for the %s backend is not a traces exporter", id, backend)
+		}
+		res = append(res, backendExporter{id: id, backend: backend, exporter: tracesExporter})
+	}
+	return res, nil
+}
+
+func (e *exporter) registerMetrics() error {
+	e.spansSent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "traces",
+		Name:      "migration_spans_sent_total",
+		Help:      "Total count of spans successfully passed to the exporters of a backend during a migration",
+	}, []string{"backend", "exporter"})
+	e.spansFailed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "traces",
+		Name:      "migration_spans_failed_total",
+		Help:      "Total count of spans which failed to be passed to the exporters of a backend during a migration",
+	}, []string{"backend", "exporter"})
+
+	cs := []prometheus.Collector{
+		e.spansSent,
+		e.spansFailed,
+	}
+
+	for _, c := range cs {
+		if err := e.reg.Register(c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *exporter) Shutdown(context.Context) error {
+	if e.reg == nil {
+		return nil
+	}
+	e.reg.Unregister(e.spansSent)
+	e.reg.Unregister(e.spansFailed)
+	return nil
+}
+
+func (e *exporter) Capabilities() consumer.Capabilities {
+	return consumer.Capabilities{MutatesData: false}
+}
+
+func (e *exporter) ConsumeTraces(ctx context.Context, td pdata.Traces) error {
+	switch e.cfg.Mode {
+	case ModeSplit:
+		oldTraces, newTraces := splitTraces(td, func(id pdata.TraceID) bool {
+			return traceIDHash(id) < e.newThreshold
+		})
+
+		// Split traces only go to one backend, so failures of either backend
+		// are reported.
+		var errs error
+		if err := e.send(ctx, e.oldExporters, oldTraces); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+		if err := e.send(ctx, e.newExporters, newTraces); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+		return errs
+
+	default:
+		// Failures of the new backends are only recorded, so the new backends
+		// can never affect the delivery of spans to the old backends.
+		if err := e.send(ctx, e.newExporters, td); err != nil {
+			level.Debug(e.logger).Log("msg", "failed to send spans to new backend", "err", err)
+		}
+		return e.send(ctx, e.oldExporters, td)
+	}
+}
+
+func (e *exporter) send(ctx context.Context, exporters []backendExporter, td pdata.Traces) error {
+	spans := td.SpanCount()
+	if spans == 0 {
+		return nil
+	}
+
+	var errs error
+	for _, be := range exporters {
+		if err := be.exporter.ConsumeTraces(ctx, td); err != nil {
+			e.spansFailed.WithLabelValues(be.backend, be.id).Add(float64(spans))
+			errs = multierror.Append(errs, fmt.Errorf("exporter %s: %w", be.id, err))
+			continue
+		}
+		e.spansSent.WithLabelValues(be.backend, be.id).Add(float64(spans))
+	}
+	return errs
+}
+
+// splitTraces copies the spans of td into two new traces, depending on
+// whether toNew returns true for their trace ID.
+func splitTraces(td pdata.Traces, toNew func(id pdata.TraceID) bool) (oldTraces, newTraces pdata.Traces) {
+	oldTraces, newTraces = pdata.NewTraces(), pdata.NewTraces()
+
+	rss := td.ResourceSpans()
+	for i := 0; i < rss.Len(); i++ {
+		rs := rss.At(i)
+		var oldRS, newRS pdata.ResourceSpans
+
+		ilss := rs.InstrumentationLibrarySpans()
+		for j := 0; j < ilss.Len(); j++ {
+			ils := ilss.At(j)
+			var oldILS, newILS pdata.InstrumentationLibrarySpans
+
+			spans := ils.Spans()
+			for k := 0; k < spans.Len(); k++ {
+				span := spans.At(k)
+
+				if toNew(span.TraceID()) {
+					if newILS == (pdata.InstrumentationLibrarySpans{}) {
+						newRS, newILS = appendContainers(newTraces, newRS, rs, ils)
+					}
+					span.CopyTo(newILS.Spans().AppendEmpty())
+				} else {
+					if oldILS == (pdata.InstrumentationLibrarySpans{}) {
+						oldRS, oldILS = appendContainers(oldTraces, oldRS, rs, ils)
+					}
+					span.CopyTo(oldILS.Spans().AppendEmpty())
+				}
+			}
+		}
+	}
+	return oldTraces, newTraces
+}
+
+// appendContainers appends a copy of ils to dstRS, creating dstRS as a copy of
+// rs in dst if it doesn't exist yet. Spans aren't copied.
+func appendContainers(
+	dst pdata.Traces,
+	dstRS pdata.ResourceSpans,
+	rs pdata.ResourceSpans,
+	ils pdata.InstrumentationLibrarySpans,
+) (pdata.ResourceSpans, pdata.InstrumentationLibrarySpans) {
+
+	if dstRS == (pdata.ResourceSpans{}) {
+		dstRS = dst.ResourceSpans().AppendEmpty()
+		rs.Resource().CopyTo(dstRS.Resource())
+		dstRS.SetSchemaUrl(rs.SchemaUrl())
+	}
+
+	dstILS := dstRS.InstrumentationLibrarySpans().AppendEmpty()
+	ils.InstrumentationLibrary().CopyTo(dstILS.InstrumentationLibrary())
+	dstILS.SetSchemaUrl(ils.SchemaUrl())
+	return dstRS, dstILS
+}
+
+// traceIDHash returns a hash of id, so all spans of a trace are sent to the
+// same backend.
+func traceIDHash(id pdata.TraceID) uint64 {
+	bytes := id.Bytes()
+	h := fnv.New64a()
+	_, _ = h.Write(bytes[:])
+	return h.Sum64()
+}
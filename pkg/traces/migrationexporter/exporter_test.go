@@ -0,0 +1,198 @@
+package migrationexporter
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/grafana/agent/pkg/traces/contextkeys"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/model/pdata"
+)
+
+func TestDuplicate(t *testing.T) {
+	oldExp, newExp := &testExporter{}, &testExporter{err: errors.New("unavailable")}
+	exp, reg := startExporter(t, &Config{Mode: ModeDuplicate, OldExporters: []string{"otlp/0"}, NewExporters: []string{"otlp/1"}}, map[string]*testExporter{
+		"otlp/0": oldExp,
+		"otlp/1": newExp,
+	})
+
+	// Failures of the new backend must not be reported.
+	require.NoError(t, exp.ConsumeTraces(context.Background(), newTestTraces(10)))
+	require.Equal(t, 10, oldExp.SpanCount())
+
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP traces_migration_spans_failed_total Total count of spans which failed to be passed to the exporters of a backend during a migration
+		# TYPE traces_migration_spans_failed_total counter
+		traces_migration_spans_failed_total{backend="new",exporter="otlp/1"} 10
+		# HELP traces_migration_spans_sent_total Total count of spans successfully passed to the exporters of a backend during a migration
+		# TYPE traces_migration_spans_sent_total counter
+		traces_migration_spans_sent_total{backend="old",exporter="otlp/0"} 10
+	`)))
+
+	oldExp.err = errors.New("unavailable")
+	require.Error(t, exp.ConsumeTraces(context.Background(), newTestTraces(1)))
+}
+
+func TestSplit(t *testing.T) {
+	tt := []struct {
+		name       string
+		percentage float64
+		expectNew  int
+	}{
+		{name: "none", percentage: 0, expectNew: 0},
+		{name: "all", percentage: 100, expectNew: 100},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			oldExp, newExp := &testExporter{}, &testExporter{}
+			exp, _ := startExporter(t, &Config{
+				Mode:            ModeSplit,
+				SplitPercentage: tc.percentage,
+				OldExporters:    []string{"otlp/0"},
+				NewExporters:    []string{"otlp/1"},
+			}, map[string]*testExporter{
+				"otlp/0": oldExp,
+				"otlp/1": newExp,
+			})
+
+			require.NoError(t, exp.ConsumeTraces(context.Background(), newTestTraces(100)))
+			require.Equal(t, tc.expectNew, newExp.SpanCount())
+			require.Equal(t, 100-tc.expectNew, oldExp.SpanCount())
+		})
+	}
+
+	t.Run("partial", func(t *testing.T) {
+		oldExp, newExp := &testExporter{}, &testExporter{}
+		exp, _ := startExporter(t, &Config{
+			Mode:            ModeSplit,
+			SplitPercentage: 50,
+			OldExporters:    []string{"otlp/0"},
+			NewExporters:    []string{"otlp/1"},
+		}, map[string]*testExporter{
+			"otlp/0": oldExp,
+			"otlp/1": newExp,
+		})
+
+		// Every trace has two spans in different resources.
+		td := newTestTraces(500)
+		newTestTraces(500).ResourceSpans().MoveAndAppendTo(td.ResourceSpans())
+
+		require.NoError(t, exp.ConsumeTraces(context.Background(), td))
+		require.Equal(t, 1000, oldExp.SpanCount()+newExp.SpanCount())
+		require.InDelta(t, 500, newExp.SpanCount(), 100)
+
+		// All spans of a trace must be sent to the same backend.
+		require.Zero(t, newExp.SpanCount()%2)
+		for _, td := range newExp.AllTraces() {
+			require.Equal(t, 2, td.ResourceSpans().Len())
+		}
+	})
+}
+
+func TestStart_MissingExporter(t *testing.T) {
+	exp, err := newExporter(&Config{Mode: ModeDuplicate, OldExporters: []string{"otlp/0"}, NewExporters: []string{"otlp/1"}})
+	require.NoError(t, err)
+
+	ctx := context.WithValue(context.Background(), contextkeys.PrometheusRegisterer, prometheus.NewRegistry())
+	err = exp.Start(ctx, &testHost{Host: componenttest.NewNopHost(), exporters: map[string]*testExporter{"otlp/0": {}}})
+	require.EqualError(t, err, `failed to find traces exporter "otlp/1" for the new backend`)
+}
+
+func TestNewExporter_Invalid(t *testing.T) {
+	tt := []struct {
+		name string
+		cfg  Config
+		err  string
+	}{
+		{
+			name: "unknown mode",
+			cfg:  Config{Mode: "mirror", OldExporters: []string{"otlp/0"}, NewExporters: []string{"otlp/1"}},
+			err:  `unsupported mode "mirror", expected "duplicate" or "split"`,
+		},
+		{
+			name: "invalid percentage",
+			cfg:  Config{Mode: ModeSplit, SplitPercentage: 101, OldExporters: []string{"otlp/0"}, NewExporters: []string{"otlp/1"}},
+			err:  "split_percentage must be between 0 and 100",
+		},
+		{
+			name: "missing exporters",
+			cfg:  Config{Mode: ModeDuplicate, OldExporters: []string{"otlp/0"}},
+			err:  "at least one old and one new exporter must be configured",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := newExporter(&tc.cfg)
+			require.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+func startExporter(t *testing.T, cfg *Config, exporters map[string]*testExporter) (*exporter, *prometheus.Registry) {
+	t.Helper()
+
+	exp, err := newExporter(cfg)
+	require.NoError(t, err)
+
+	reg := prometheus.NewRegistry()
+	ctx := context.WithValue(context.Background(), contextkeys.PrometheusRegisterer, reg)
+	require.NoError(t, exp.Start(ctx, &testHost{Host: componenttest.NewNopHost(), exporters: exporters}))
+	t.Cleanup(func() { require.NoError(t, exp.Shutdown(context.Background())) })
+	return exp, reg
+}
+
+// newTestTraces returns traces with n spans, each of them in its own trace.
+func newTestTraces(n int) pdata.Traces {
+	td := pdata.NewTraces()
+	spans := td.ResourceSpans().AppendEmpty().InstrumentationLibrarySpans().AppendEmpty().Spans()
+	for i := 0; i < n; i++ {
+		span := spans.AppendEmpty()
+		span.SetTraceID(pdata.NewTraceID([16]byte{byte(i), byte(i >> 8), 1}))
+	}
+	return td
+}
+
+type testHost struct {
+	component.Host
+	exporters map[string]*testExporter
+}
+
+func (h *testHost) GetExporters() map[config.DataType]map[config.ComponentID]component.Exporter {
+	exporters := make(map[config.ComponentID]component.Exporter, len(h.exporters))
+	for id, exp := range h.exporters {
+		componentID, err := config.NewComponentIDFromString(id)
+		if err != nil {
+			panic(err)
+		}
+		exporters[componentID] = exp
+	}
+	return map[config.DataType]map[config.ComponentID]component.Exporter{
+		config.TracesDataType: exporters,
+	}
+}
+
+// testExporter stores the spans it receives, or returns err if set.
+type testExporter struct {
+	consumertest.TracesSink
+	err error
+}
+
+func (e *testExporter) Start(context.Context, component.Host) error { return nil }
+func (e *testExporter) Shutdown(context.Context) error              { return nil }
+
+func (e *testExporter) ConsumeTraces(ctx context.Context, td pdata.Traces) error {
+	if e.err != nil {
+		return e.err
+	}
+	return e.TracesSink.ConsumeTraces(ctx, td)
+}
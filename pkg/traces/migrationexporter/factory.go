@@ -0,0 +1,63 @@
+package migrationexporter
+
+import (
+	"context"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config"
+)
+
+const (
+	// TypeStr is the unique identifier for the migration exporter.
+	TypeStr = "migration"
+
+	// ModeDuplicate sends all spans to both the old and the new backends.
+	ModeDuplicate = "duplicate"
+	// ModeSplit sends a percentage of traces to the new backend and the rest
+	// to the old backends.
+	ModeSplit = "split"
+)
+
+var _ config.Exporter = (*Config)(nil)
+
+// Config holds the configuration for the migration exporter.
+type Config struct {
+	config.ExporterSettings `mapstructure:",squash"`
+
+	// Mode is either ModeDuplicate or ModeSplit.
+	Mode string `mapstructure:"mode"`
+	// SplitPercentage is the percentage of traces sent to the new backends in
+	// ModeSplit.
+	SplitPercentage float64 `mapstructure:"split_percentage"`
+
+	// OldExporters and NewExporters are the IDs of the traces exporters which
+	// send spans to the old and new backends.
+	OldExporters []string `mapstructure:"old_exporters"`
+	NewExporters []string `mapstructure:"new_exporters"`
+}
+
+// NewFactory returns a new factory for the migration exporter.
+func NewFactory() component.ExporterFactory {
+	return component.NewExporterFactory(
+		TypeStr,
+		createDefaultConfig,
+		component.WithTracesExporter(createTracesExporter),
+	)
+}
+
+func createDefaultConfig() config.Exporter {
+	return &Config{
+		ExporterSettings: config.NewExporterSettings(config.NewComponentIDWithName(TypeStr, TypeStr)),
+		Mode:             ModeDuplicate,
+	}
+}
+
+func createTracesExporter(
+	_ context.Context,
+	_ component.ExporterCreateSettings,
+	cfg config.Exporter,
+) (component.TracesExporter, error) {
+
+	eCfg := cfg.(*Config)
+	return newExporter(eCfg)
+}
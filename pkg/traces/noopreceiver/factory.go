@@ -19,6 +19,7 @@ func NewFactory() component.ReceiverFactory {
 		TypeStr,
 		createDefaultConfig,
 		component.WithMetricsReceiver(createMetricsReceiver),
+		component.WithTracesReceiver(createTracesReceiver),
 	)
 }
 
@@ -43,3 +44,15 @@ func createMetricsReceiver(
 
 	return newNoopReceiver(nil, nil, nil), nil
 }
+
+// noop receiver is used in the pipeline which builds the traces exporters
+// of a backend migration, so we need to implement a traces receiver.
+func createTracesReceiver(
+	_ context.Context,
+	_ component.ReceiverCreateSettings,
+	_ config.Receiver,
+	_ consumer.Traces,
+) (component.TracesReceiver, error) {
+
+	return newNoopReceiver(nil, nil, nil), nil
+}
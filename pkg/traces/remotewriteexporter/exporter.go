@@ -3,19 +3,24 @@ package remotewriteexporter
 import (
 	"context"
 	"fmt"
+	"math"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	util "github.com/cortexproject/cortex/pkg/util/log"
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/grafana/agent/pkg/metrics/instance"
 	"github.com/grafana/agent/pkg/traces/contextkeys"
+	"github.com/prometheus/prometheus/model/exemplar"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/model/timestamp"
 	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/util/strutil"
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/consumer"
 	"go.opentelemetry.io/collector/model/pdata"
@@ -30,6 +35,11 @@ const (
 	leStr        = "le"
 	infBucket    = "+Inf"
 	noSuffix     = ""
+
+	// traceIDAttribute is the exemplar attribute holding the trace ID, which
+	// is renamed to traceIDLabel.
+	traceIDAttribute = "trace_id"
+	traceIDLabel     = "traceID"
 )
 
 type remoteWriteExporter struct {
@@ -184,7 +194,10 @@ func (e *remoteWriteExporter) handleHistogramDataPoints(app storage.Appender, na
 			return err
 		}
 
-		var cumulativeCount uint64
+		var (
+			cumulativeCount uint64
+			buckets         []bucketSeries
+		)
 		for ix, eb := range dataPoint.ExplicitBounds() {
 			if ix >= len(dataPoint.BucketCounts()) {
 				break
@@ -192,20 +205,126 @@ func (e *remoteWriteExporter) handleHistogramDataPoints(app storage.Appender, na
 			cumulativeCount += dataPoint.BucketCounts()[ix]
 			boundStr := strconv.FormatFloat(eb, 'f', -1, 64)
 			bucketLabels := e.createLabelSet(name, bucketSuffix, dataPoint.Attributes(), labels.Labels{{Name: leStr, Value: boundStr}})
-			if _, err := app.Append(0, bucketLabels, ts, float64(cumulativeCount)); err != nil {
+			ref, err := app.Append(0, bucketLabels, ts, float64(cumulativeCount))
+			if err != nil {
 				return err
 			}
+			buckets = append(buckets, bucketSeries{bound: eb, ref: ref, labels: bucketLabels})
 		}
 		// add le=+Inf bucket
 		cumulativeCount += dataPoint.BucketCounts()[len(dataPoint.BucketCounts())-1]
 		infBucketLabels := e.createLabelSet(name, bucketSuffix, dataPoint.Attributes(), labels.Labels{{Name: leStr, Value: infBucket}})
-		if _, err := app.Append(0, infBucketLabels, ts, float64(cumulativeCount)); err != nil {
+		ref, err := app.Append(0, infBucketLabels, ts, float64(cumulativeCount))
+		if err != nil {
 			return err
 		}
+		buckets = append(buckets, bucketSeries{bound: math.Inf(1), ref: ref, labels: infBucketLabels})
+
+		e.appendExemplars(app, dataPoint.Exemplars(), buckets)
 	}
 	return nil
 }
 
+// bucketSeries is an appended bucket series of a histogram.
+type bucketSeries struct {
+	bound  float64
+	ref    storage.SeriesRef
+	labels labels.Labels
+}
+
+// appendExemplars appends the exemplars to the series of the smallest bucket
+// containing their value. buckets must be sorted by bound. Exemplars of a
+// data point share its timestamp, and receivers reject exemplars of a series
+// which aren't newer than the last one, so only the last exemplar of each
+// bucket is appended. Exemplars which fail to append are dropped.
+func (e *remoteWriteExporter) appendExemplars(app storage.Appender, exemplars pdata.ExemplarSlice, buckets []bucketSeries) {
+	latest := make(map[int]exemplar.Exemplar, len(buckets))
+	for ix := 0; ix < exemplars.Len(); ix++ {
+		ex := exemplars.At(ix)
+
+		var val float64
+		switch ex.ValueType() {
+		case pdata.MetricValueTypeDouble:
+			val = ex.DoubleVal()
+		case pdata.MetricValueTypeInt:
+			val = float64(ex.IntVal())
+		default:
+			continue
+		}
+
+		i := sort.Search(len(buckets), func(i int) bool { return val <= buckets[i].bound })
+		if i == len(buckets) {
+			continue
+		}
+
+		latest[i] = exemplar.Exemplar{
+			Labels: exemplarLabels(ex.FilteredAttributes()),
+			Value:  val,
+			Ts:     convertTimeStamp(ex.Timestamp().AsTime()),
+			HasTs:  true,
+		}
+	}
+
+	for i, bucket := range buckets {
+		promExemplar, ok := latest[i]
+		if !ok {
+			continue
+		}
+		if _, err := app.AppendExemplar(bucket.ref, bucket.labels, promExemplar); err != nil {
+			level.Debug(e.logger).Log("msg", "failed to append exemplar", "err", err)
+		}
+	}
+}
+
+// exemplarLabels converts the attributes of an exemplar into labels. The
+// trace ID is always kept, while other attributes are dropped once the
+// labels would exceed the maximum length of exemplar labels. attrs is only
+// read; it belongs to the span and must not be reordered.
+func exemplarLabels(attrs pdata.AttributeMap) labels.Labels {
+	var (
+		ls     = make(labels.Labels, 0, attrs.Len())
+		other  = make(labels.Labels, 0, attrs.Len())
+		length int
+	)
+	if traceID, ok := attrs.Get(traceIDAttribute); ok {
+		ls = append(ls, labels.Label{Name: traceIDLabel, Value: traceID.AsString()})
+		length += utf8.RuneCountInString(traceIDLabel) + utf8.RuneCountInString(traceID.AsString())
+	}
+
+	attrs.Range(func(k string, v pdata.AttributeValue) bool {
+		if k == traceIDAttribute {
+			return true
+		}
+		other = append(other, labels.Label{Name: sanitizeLabelName(k), Value: v.AsString()})
+		return true
+	})
+	// Sort by the sanitized name so that the attributes kept within the
+	// length limit don't depend on the order of the span's attributes.
+	sort.SliceStable(other, func(i, j int) bool { return other[i].Name < other[j].Name })
+
+	for i, l := range other {
+		if l.Name == traceIDLabel || (i > 0 && other[i-1].Name == l.Name) {
+			continue
+		}
+		if length+utf8.RuneCountInString(l.Name)+utf8.RuneCountInString(l.Value) > exemplar.ExemplarMaxLabelSetLength {
+			continue
+		}
+		length += utf8.RuneCountInString(l.Name) + utf8.RuneCountInString(l.Value)
+		ls = append(ls, l)
+	}
+	sort.Sort(ls)
+	return ls
+}
+
+// sanitizeLabelName turns an attribute key into a valid label name.
+func sanitizeLabelName(name string) string {
+	name = strutil.SanitizeLabelName(name)
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
+
 func (e *remoteWriteExporter) createLabelSet(name, suffix string, labelMap pdata.AttributeMap, customLabels labels.Labels) labels.Labels {
 	ls := make(labels.Labels, 0, labelMap.Len()+1+len(e.constLabels)+len(customLabels))
 	// Labels from spanmetrics processor
@@ -3,6 +3,8 @@ package remotewriteexporter
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
@@ -103,6 +105,106 @@ func TestRemoteWriteExporter_ConsumeMetrics(t *testing.T) {
 	}
 }
 
+func TestRemoteWriteExporter_Exemplars(t *testing.T) {
+	ts := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	manager := &mockManager{}
+	exp := remoteWriteExporter{
+		manager:      manager,
+		namespace:    "traces",
+		promInstance: "traces",
+	}
+
+	metrics := pdata.NewMetrics()
+	ilm := metrics.ResourceMetrics().AppendEmpty().InstrumentationLibraryMetrics().AppendEmpty()
+
+	hm := ilm.Metrics().AppendEmpty()
+	hm.SetDataType(pdata.MetricDataTypeHistogram)
+	hm.SetName("spanmetrics_latency")
+	hm.Histogram().SetAggregationTemporality(pdata.MetricAggregationTemporalityCumulative)
+
+	hdp := hm.Histogram().DataPoints().AppendEmpty()
+	hdp.SetTimestamp(pdata.NewTimestampFromTime(ts))
+	hdp.SetBucketCounts([]uint64{1, 1, 1})
+	hdp.SetExplicitBounds([]float64{1, 5})
+	hdp.SetCount(3)
+	hdp.SetSum(10)
+
+	// Exemplar in the le=5 bucket replaced by the next one.
+	e := hdp.Exemplars().AppendEmpty()
+	e.SetDoubleVal(3)
+	e.SetTimestamp(pdata.NewTimestampFromTime(ts))
+	e.FilteredAttributes().InsertString("trace_id", "00000000000000000000000000000000")
+
+	// Exemplar in the le=5 bucket with a span attribute.
+	e = hdp.Exemplars().AppendEmpty()
+	e.SetDoubleVal(2)
+	e.SetTimestamp(pdata.NewTimestampFromTime(ts))
+	e.FilteredAttributes().InsertString("trace_id", "0123456789abcdef0123456789abcdef")
+	e.FilteredAttributes().InsertString("http.route", "/users/{id}")
+
+	// Exemplar in the le=+Inf bucket whose attribute would exceed the maximum
+	// length of exemplar labels.
+	e = hdp.Exemplars().AppendEmpty()
+	e.SetDoubleVal(100)
+	e.SetTimestamp(pdata.NewTimestampFromTime(ts))
+	e.FilteredAttributes().InsertString("trace_id", "fedcba9876543210fedcba9876543210")
+	e.FilteredAttributes().InsertString("http.url", strings.Repeat("x", 100))
+
+	require.NoError(t, exp.ConsumeMetrics(context.Background(), metrics))
+
+	exemplars := manager.instance.appender.appendedExemplars
+	require.Len(t, exemplars, 2)
+
+	require.Equal(t, "5", exemplars[0].l.Get(leStr))
+	require.Equal(t, exemplar.Exemplar{
+		Labels: labels.Labels{
+			{Name: "http_route", Value: "/users/{id}"},
+			{Name: "traceID", Value: "0123456789abcdef0123456789abcdef"},
+		},
+		Value: 2,
+		Ts:    ts.UnixMilli(),
+		HasTs: true,
+	}, exemplars[0].e)
+
+	require.Equal(t, infBucket, exemplars[1].l.Get(leStr))
+	require.Equal(t, labels.Labels{
+		{Name: "traceID", Value: "fedcba9876543210fedcba9876543210"},
+	}, exemplars[1].e.Labels)
+}
+
+func TestExemplarLabels(t *testing.T) {
+	attrs := pdata.NewAttributeMap()
+	attrs.InsertString("trace_id", "0123456789abcdef0123456789abcdef")
+	attrs.InsertString("a-c", "c")
+	attrs.InsertString("a.z", "z")
+	attrs.InsertString("a_b", "b")
+	attrs.InsertString("1st", "1")
+
+	keys := func() []string {
+		var ks []string
+		attrs.Range(func(k string, _ pdata.AttributeValue) bool {
+			ks = append(ks, k)
+			return true
+		})
+		return ks
+	}
+	before := keys()
+
+	ls := exemplarLabels(attrs)
+	require.Equal(t, labels.Labels{
+		{Name: "_1st", Value: "1"},
+		{Name: "a_b", Value: "b"},
+		{Name: "a_c", Value: "c"},
+		{Name: "a_z", Value: "z"},
+		{Name: "traceID", Value: "0123456789abcdef0123456789abcdef"},
+	}, ls)
+	require.True(t, sort.IsSorted(ls))
+
+	// The span's attributes must be left untouched.
+	require.Equal(t, before, keys())
+}
+
 type mockManager struct {
 	instance *mockInstance
 }
@@ -146,8 +248,14 @@ type metric struct {
 	v float64
 }
 
+type appendedExemplar struct {
+	l labels.Labels
+	e exemplar.Exemplar
+}
+
 type mockAppender struct {
-	appendedMetrics []metric
+	appendedMetrics   []metric
+	appendedExemplars []appendedExemplar
 }
 
 func (a *mockAppender) GetAppended(n string) []metric {
@@ -169,6 +277,7 @@ func (a *mockAppender) Commit() error { return nil }
 
 func (a *mockAppender) Rollback() error { return nil }
 
-func (a *mockAppender) AppendExemplar(_ storage.SeriesRef, _ labels.Labels, _ exemplar.Exemplar) (storage.SeriesRef, error) {
+func (a *mockAppender) AppendExemplar(_ storage.SeriesRef, l labels.Labels, e exemplar.Exemplar) (storage.SeriesRef, error) {
+	a.appendedExemplars = append(a.appendedExemplars, appendedExemplar{l: l, e: e})
 	return 0, nil
 }
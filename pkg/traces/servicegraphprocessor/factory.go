@@ -19,6 +19,8 @@ const (
 	DefaultMaxItems = 10_000
 	// DefaultWorkers is the default amount of workers that will be used to process the edges
 	DefaultWorkers = 10
+	// DefaultMetricsFlushInterval is how often metrics are written to the metrics instance
+	DefaultMetricsFlushInterval = time.Second * 15
 )
 
 // Config holds the configuration for the Prometheus service graph processor.
@@ -31,6 +33,10 @@ type Config struct {
 	Workers int `mapstructure:"workers"`
 
 	SuccessCodes *successCodes `mapstructure:"success_codes"`
+
+	// MetricsInstance is the metrics instance metrics are written to. Metrics
+	// are registered to the agent's registry when unset.
+	MetricsInstance string `mapstructure:"metrics_instance"`
 }
 
 type successCodes struct {
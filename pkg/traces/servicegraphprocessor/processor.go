@@ -9,6 +9,7 @@ import (
 	util "github.com/cortexproject/cortex/pkg/util/log"
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	"github.com/grafana/agent/pkg/metrics/instance"
 	"github.com/grafana/agent/pkg/traces/contextkeys"
 	"github.com/prometheus/client_golang/prometheus"
 	"go.opentelemetry.io/collector/component"
@@ -83,6 +84,12 @@ type processor struct {
 	httpSuccessCodeMap map[int]struct{}
 	grpcSuccessCodeMap map[int]struct{}
 
+	// metricsInstance is the metrics instance metrics are written to every
+	// flushInterval, if set.
+	metricsInstance string
+	flushInterval   time.Duration
+	flushDone       chan struct{}
+
 	logger  log.Logger
 	closeCh chan struct{}
 }
@@ -124,6 +131,9 @@ func newProcessor(nextConsumer consumer.Traces, cfg *Config) *processor {
 
 		collectCh: make(chan string, cfg.Workers),
 
+		metricsInstance: cfg.MetricsInstance,
+		flushInterval:   DefaultMetricsFlushInterval,
+
 		closeCh: make(chan struct{}, 1),
 	}
 
@@ -148,6 +158,21 @@ func (p *processor) Start(ctx context.Context, _ component.Host) error {
 	// initialize store
 	p.store = newStore(p.wait, p.maxItems, p.collectEdge)
 
+	if p.metricsInstance != "" {
+		manager, ok := ctx.Value(contextkeys.Metrics).(instance.Manager)
+		if !ok || manager == nil {
+			return fmt.Errorf("key does not contain a InstanceManager instance")
+		}
+		reg := prometheus.NewRegistry()
+		p.reg = reg
+		if err := p.registerMetrics(); err != nil {
+			return err
+		}
+		p.flushDone = make(chan struct{})
+		go p.runFlush(manager, reg)
+		return nil
+	}
+
 	reg, ok := ctx.Value(contextkeys.PrometheusRegisterer).(prometheus.Registerer)
 	if !ok || reg == nil {
 		return fmt.Errorf("key does not contain a prometheus registerer")
@@ -210,6 +235,9 @@ func (p *processor) registerMetrics() error {
 
 func (p *processor) Shutdown(context.Context) error {
 	close(p.closeCh)
+	if p.flushDone != nil {
+		<-p.flushDone
+	}
 	p.unregisterMetrics()
 	return nil
 }
@@ -221,6 +249,7 @@ func (p *processor) unregisterMetrics() {
 		p.serviceGraphRequestServerHistogram,
 		p.serviceGraphRequestClientHistogram,
 		p.serviceGraphUnpairedSpansTotal,
+		p.serviceGraphDroppedSpansTotal,
 	}
 
 	for _, c := range cs {
@@ -7,9 +7,12 @@ import (
 	"testing"
 	"time"
 
+	"github.com/grafana/agent/pkg/metrics/instance"
 	"github.com/grafana/agent/pkg/traces/contextkeys"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/collector/consumer"
@@ -97,6 +100,36 @@ func TestConsumeMetrics(t *testing.T) {
 	}
 }
 
+func TestConsumeMetrics_MetricsInstance(t *testing.T) {
+	p := newProcessor(&mockConsumer{}, &Config{
+		Wait:            time.Hour,
+		MetricsInstance: "traces",
+	})
+	p.flushInterval = time.Hour // Only flush on shutdown.
+
+	manager := &mockManager{}
+	ctx := context.WithValue(context.Background(), contextkeys.Metrics, manager)
+	require.NoError(t, p.Start(ctx, nil))
+
+	require.NoError(t, p.ConsumeTraces(context.Background(), traceSamples(t, traceSamplePath)))
+	collectMetrics(p)
+	require.NoError(t, p.Shutdown(context.Background()))
+
+	app := manager.instance.appender
+	require.Equal(t, 3.0, app.value(labels.FromStrings(
+		labels.MetricName, "traces_service_graph_request_total", "client", "app", "server", "db",
+	)))
+	require.Equal(t, 3.0, app.value(labels.FromStrings(
+		labels.MetricName, "traces_service_graph_request_server_seconds_count", "client", "lb", "server", "app",
+	)))
+	require.Equal(t, 3.0, app.value(labels.FromStrings(
+		labels.MetricName, "traces_service_graph_request_client_seconds_bucket", "client", "app", "server", "db", "le", "+Inf",
+	)))
+	require.Equal(t, 2.0, app.value(labels.FromStrings(
+		labels.MetricName, "traces_service_graph_request_client_seconds_bucket", "client", "app", "server", "db", "le", "1.28",
+	)))
+}
+
 func traceSamples(t *testing.T, path string) pdata.Traces {
 	b, err := ioutil.ReadFile(path)
 	require.NoError(t, err)
@@ -120,6 +153,58 @@ func collectMetrics(p *processor) {
 	}
 }
 
+type mockManager struct {
+	instance.Manager
+	instance *mockInstance
+}
+
+func (m *mockManager) GetInstance(string) (instance.ManagedInstance, error) {
+	if m.instance == nil {
+		m.instance = &mockInstance{appender: &mockAppender{}}
+	}
+	return m.instance, nil
+}
+
+type mockInstance struct {
+	instance.NoOpInstance
+	appender *mockAppender
+}
+
+func (m *mockInstance) Appender(context.Context) storage.Appender { return m.appender }
+
+// mockAppender records the last committed value of each series.
+type mockAppender struct {
+	storage.Appender
+	pending   map[string]float64
+	committed map[string]float64
+}
+
+func (a *mockAppender) Append(_ storage.SeriesRef, l labels.Labels, _ int64, v float64) (storage.SeriesRef, error) {
+	if a.pending == nil {
+		a.pending = make(map[string]float64)
+	}
+	a.pending[l.String()] = v
+	return 0, nil
+}
+
+func (a *mockAppender) Commit() error {
+	if a.committed == nil {
+		a.committed = make(map[string]float64)
+	}
+	for k, v := range a.pending {
+		a.committed[k] = v
+	}
+	a.pending = nil
+	return nil
+}
+
+func (a *mockAppender) Rollback() error {
+	a.pending = nil
+	return nil
+}
+
+func (a *mockAppender) value(l labels.Labels) float64 { return a.committed[l.String()] }
+
 type mockConsumer struct{}
 
 func (m *mockConsumer) Capabilities() consumer.Capabilities { return consumer.Capabilities{} }
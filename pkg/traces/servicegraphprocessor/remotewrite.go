@@ -0,0 +1,109 @@
+package servicegraphprocessor
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/agent/pkg/metrics/instance"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/timestamp"
+	"github.com/prometheus/prometheus/storage"
+)
+
+// runFlush writes the metrics gathered from reg to the metrics instance every
+// flushInterval, and once more when the processor shuts down.
+func (p *processor) runFlush(manager instance.Manager, reg prometheus.Gatherer) {
+	defer close(p.flushDone)
+
+	t := time.NewTicker(p.flushInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-p.closeCh:
+			p.flush(manager, reg)
+			return
+		}
+		p.flush(manager, reg)
+	}
+}
+
+// flush writes the metrics gathered from reg to the metrics instance.
+// Failures are logged since metrics are written again on the next flush.
+func (p *processor) flush(manager instance.Manager, reg prometheus.Gatherer) {
+	families, err := reg.Gather()
+	if err != nil {
+		level.Warn(p.logger).Log("msg", "failed to gather service graph metrics", "err", err)
+		return
+	}
+
+	prom, err := manager.GetInstance(p.metricsInstance)
+	if err != nil {
+		level.Warn(p.logger).Log("msg", "failed to get prom instance", "err", err)
+		return
+	}
+
+	app := prom.Appender(context.Background())
+	if err := appendFamilies(app, families, timestamp.FromTime(time.Now())); err != nil {
+		level.Warn(p.logger).Log("msg", "failed to write service graph metrics", "err", err)
+		_ = app.Rollback()
+		return
+	}
+	if err := app.Commit(); err != nil {
+		level.Warn(p.logger).Log("msg", "failed to write service graph metrics", "err", err)
+	}
+}
+
+// appendFamilies appends the samples of counters and histograms in families
+// to app at ts.
+func appendFamilies(app storage.Appender, families []*dto.MetricFamily, ts int64) error {
+	for _, mf := range families {
+		name := mf.GetName()
+		for _, m := range mf.GetMetric() {
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				if _, err := app.Append(0, seriesLabels(name, m), ts, m.GetCounter().GetValue()); err != nil {
+					return err
+				}
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				for _, b := range h.GetBucket() {
+					le := labels.Label{Name: labels.BucketLabel, Value: strconv.FormatFloat(b.GetUpperBound(), 'f', -1, 64)}
+					if _, err := app.Append(0, seriesLabels(name+"_bucket", m, le), ts, float64(b.GetCumulativeCount())); err != nil {
+						return err
+					}
+				}
+				inf := labels.Label{Name: labels.BucketLabel, Value: strconv.FormatFloat(math.Inf(1), 'f', -1, 64)}
+				if _, err := app.Append(0, seriesLabels(name+"_bucket", m, inf), ts, float64(h.GetSampleCount())); err != nil {
+					return err
+				}
+				if _, err := app.Append(0, seriesLabels(name+"_sum", m), ts, h.GetSampleSum()); err != nil {
+					return err
+				}
+				if _, err := app.Append(0, seriesLabels(name+"_count", m), ts, float64(h.GetSampleCount())); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// seriesLabels returns the labels of the series name of m, along with extra
+// labels.
+func seriesLabels(name string, m *dto.Metric, extra ...labels.Label) labels.Labels {
+	b := labels.NewBuilder(nil)
+	b.Set(labels.MetricName, name)
+	for _, lp := range m.GetLabel() {
+		b.Set(lp.GetName(), lp.GetValue())
+	}
+	for _, l := range extra {
+		b.Set(l.Name, l.Value)
+	}
+	return b.Labels()
+}
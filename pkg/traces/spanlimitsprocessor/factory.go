@@ -0,0 +1,55 @@
+package spanlimitsprocessor
+
+import (
+	"context"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config"
+	"go.opentelemetry.io/collector/consumer"
+)
+
+// TypeStr is the unique identifier for the span limits processor.
+const TypeStr = "span_limits"
+
+// Config holds the configuration for the span limits processor. A limit of 0
+// disables that limit.
+type Config struct {
+	config.ProcessorSettings `mapstructure:",squash"`
+
+	// MaxAttributes is the maximum number of attributes kept on a span or
+	// span event.
+	MaxAttributes int `mapstructure:"max_attributes"`
+
+	// MaxEvents is the maximum number of events kept on a span.
+	MaxEvents int `mapstructure:"max_events"`
+
+	// MaxAttributeValueLength is the maximum number of characters of a string
+	// attribute value. Longer values are truncated.
+	MaxAttributeValueLength int `mapstructure:"max_attribute_value_length"`
+}
+
+// NewFactory returns a new factory for the span limits processor.
+func NewFactory() component.ProcessorFactory {
+	return component.NewProcessorFactory(
+		TypeStr,
+		createDefaultConfig,
+		component.WithTracesProcessor(createTracesProcessor),
+	)
+}
+
+func createDefaultConfig() config.Processor {
+	return &Config{
+		ProcessorSettings: config.NewProcessorSettings(config.NewComponentIDWithName(TypeStr, TypeStr)),
+	}
+}
+
+func createTracesProcessor(
+	_ context.Context,
+	_ component.ProcessorCreateSettings,
+	cfg config.Processor,
+	nextConsumer consumer.Traces,
+) (component.TracesProcessor, error) {
+
+	eCfg := cfg.(*Config)
+	return newProcessor(nextConsumer, eCfg), nil
+}
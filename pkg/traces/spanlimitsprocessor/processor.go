@@ -0,0 +1,240 @@
+// Package spanlimitsprocessor enforces limits on the size of spans flowing
+// through a traces pipeline. Attributes and events over the configured limits
+// are dropped, and long string attribute values are truncated, protecting
+// downstream backends from pathological instrumentation.
+package spanlimitsprocessor
+
+import (
+	"context"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/grafana/agent/pkg/traces/contextkeys"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/model/pdata"
+)
+
+// TruncationMarker is appended to string attribute values which were
+// truncated.
+const TruncationMarker = "...[truncated]"
+
+type processor struct {
+	nextConsumer consumer.Traces
+
+	maxAttributes  int
+	maxEvents      int
+	maxValueLength int
+
+	reg               prometheus.Registerer
+	spansScrubbed     prometheus.Counter
+	attributesDropped prometheus.Counter
+	eventsDropped     prometheus.Counter
+	valuesTruncated   prometheus.Counter
+}
+
+func newProcessor(nextConsumer consumer.Traces, cfg *Config) *processor {
+	return &processor{
+		nextConsumer: nextConsumer,
+
+		maxAttributes:  cfg.MaxAttributes,
+		maxEvents:      cfg.MaxEvents,
+		maxValueLength: cfg.MaxAttributeValueLength,
+	}
+}
+
+func (p *processor) Start(ctx context.Context, _ component.Host) error {
+	if p.maxAttributes < 0 || p.maxEvents < 0 || p.maxValueLength < 0 {
+		return fmt.Errorf("span limits must not be negative")
+	}
+
+	reg, ok := ctx.Value(contextkeys.PrometheusRegisterer).(prometheus.Registerer)
+	if !ok || reg == nil {
+		return fmt.Errorf("key does not contain a prometheus registerer")
+	}
+	p.reg = reg
+	return p.registerMetrics()
+}
+
+func (p *processor) registerMetrics() error {
+	p.spansScrubbed = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "traces",
+		Name:      "span_limits_spans_scrubbed_total",
+		Help:      "Total count of spans modified for exceeding span limits",
+	})
+	p.attributesDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "traces",
+		Name:      "span_limits_attributes_dropped_total",
+		Help:      "Total count of span and span event attributes dropped for exceeding span limits",
+	})
+	p.eventsDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "traces",
+		Name:      "span_limits_events_dropped_total",
+		Help:      "Total count of span events dropped for exceeding span limits",
+	})
+	p.valuesTruncated = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "traces",
+		Name:      "span_limits_attribute_values_truncated_total",
+		Help:      "Total count of attribute values truncated for exceeding span limits",
+	})
+
+	cs := []prometheus.Collector{
+		p.spansScrubbed,
+		p.attributesDropped,
+		p.eventsDropped,
+		p.valuesTruncated,
+	}
+
+	for _, c := range cs {
+		if err := p.reg.Register(c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *processor) Shutdown(context.Context) error {
+	if p.reg == nil {
+		return nil
+	}
+	p.reg.Unregister(p.spansScrubbed)
+	p.reg.Unregister(p.attributesDropped)
+	p.reg.Unregister(p.eventsDropped)
+	p.reg.Unregister(p.valuesTruncated)
+	return nil
+}
+
+func (p *processor) Capabilities() consumer.Capabilities {
+	return consumer.Capabilities{MutatesData: true}
+}
+
+func (p *processor) ConsumeTraces(ctx context.Context, td pdata.Traces) error {
+	rss := td.ResourceSpans()
+	for i := 0; i < rss.Len(); i++ {
+		ilss := rss.At(i).InstrumentationLibrarySpans()
+		for j := 0; j < ilss.Len(); j++ {
+			spans := ilss.At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				p.scrubSpan(spans.At(k))
+			}
+		}
+	}
+	return p.nextConsumer.ConsumeTraces(ctx, td)
+}
+
+// scrubSpan applies the limits to span. Dropped attributes and events are
+// added to the dropped counts of the span so backends can tell the span was
+// modified.
+func (p *processor) scrubSpan(span pdata.Span) {
+	var stats scrubStats
+
+	if dropped := p.limitAttributes(span.Attributes(), &stats); dropped > 0 {
+		span.SetDroppedAttributesCount(span.DroppedAttributesCount() + dropped)
+	}
+
+	events := span.Events()
+	if p.maxEvents > 0 && events.Len() > p.maxEvents {
+		// Keep the earliest events: instrumentation producing an unbounded
+		// number of events usually does so in a loop, and the first events
+		// show how it started.
+		dropped := events.Len() - p.maxEvents
+		idx := 0
+		events.RemoveIf(func(pdata.SpanEvent) bool {
+			idx++
+			return idx > p.maxEvents
+		})
+		span.SetDroppedEventsCount(span.DroppedEventsCount() + uint32(dropped))
+		stats.events += dropped
+	}
+
+	for i := 0; i < events.Len(); i++ {
+		event := events.At(i)
+		if dropped := p.limitAttributes(event.Attributes(), &stats); dropped > 0 {
+			event.SetDroppedAttributesCount(event.DroppedAttributesCount() + dropped)
+		}
+	}
+
+	if stats == (scrubStats{}) {
+		return
+	}
+	p.spansScrubbed.Inc()
+	p.attributesDropped.Add(float64(stats.attributes))
+	p.eventsDropped.Add(float64(stats.events))
+	p.valuesTruncated.Add(float64(stats.truncated))
+}
+
+type scrubStats struct {
+	attributes int
+	events     int
+	truncated  int
+}
+
+// limitAttributes drops attributes of am past the first maxAttributes and
+// truncates the remaining values. It returns the number of attributes
+// dropped.
+func (p *processor) limitAttributes(am pdata.AttributeMap, stats *scrubStats) uint32 {
+	var (
+		idx  int
+		drop []string
+	)
+	am.Range(func(k string, v pdata.AttributeValue) bool {
+		idx++
+		if p.maxAttributes > 0 && idx > p.maxAttributes {
+			drop = append(drop, k)
+			return true
+		}
+		p.truncateValue(v, stats)
+		return true
+	})
+
+	for _, k := range drop {
+		am.Delete(k)
+	}
+	stats.attributes += len(drop)
+	return uint32(len(drop))
+}
+
+// truncateValue truncates v if it's a string longer than maxValueLength,
+// including strings nested in arrays and maps.
+func (p *processor) truncateValue(v pdata.AttributeValue, stats *scrubStats) {
+	if p.maxValueLength == 0 {
+		return
+	}
+
+	switch v.Type() {
+	case pdata.AttributeValueTypeString:
+		if s, ok := truncate(v.StringVal(), p.maxValueLength); ok {
+			v.SetStringVal(s)
+			stats.truncated++
+		}
+	case pdata.AttributeValueTypeArray:
+		vals := v.SliceVal()
+		for i := 0; i < vals.Len(); i++ {
+			p.truncateValue(vals.At(i), stats)
+		}
+	case pdata.AttributeValueTypeMap:
+		v.MapVal().Range(func(_ string, v pdata.AttributeValue) bool {
+			p.truncateValue(v, stats)
+			return true
+		})
+	}
+}
+
+// truncate shortens s to max characters followed by TruncationMarker. It
+// returns false if s was already short enough.
+func truncate(s string, max int) (string, bool) {
+	if len(s) <= max || utf8.RuneCountInString(s) <= max {
+		return s, false
+	}
+
+	var chars int
+	for i := range s {
+		if chars == max {
+			return s[:i] + TruncationMarker, true
+		}
+		chars++
+	}
+	return s, false
+}
@@ -0,0 +1,111 @@
+package spanlimitsprocessor
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/grafana/agent/pkg/traces/contextkeys"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/model/pdata"
+)
+
+func TestConsumeTraces(t *testing.T) {
+	next := new(consumertest.TracesSink)
+	p := newProcessor(next, &Config{
+		MaxAttributes:           2,
+		MaxEvents:               1,
+		MaxAttributeValueLength: 4,
+	})
+
+	reg := prometheus.NewRegistry()
+	ctx := context.WithValue(context.Background(), contextkeys.PrometheusRegisterer, reg)
+	require.NoError(t, p.Start(ctx, nil))
+
+	td := pdata.NewTraces()
+	spans := td.ResourceSpans().AppendEmpty().InstrumentationLibrarySpans().AppendEmpty().Spans()
+
+	big := spans.AppendEmpty()
+	big.Attributes().InsertString("a", "héllo world")
+	big.Attributes().InsertString("b", "ok")
+	big.Attributes().InsertString("c", "dropped")
+	big.SetDroppedAttributesCount(1)
+	for i := 0; i < 3; i++ {
+		event := big.Events().AppendEmpty()
+		event.SetName("event")
+		event.Attributes().InsertString("x", "1")
+		event.Attributes().InsertString("y", "2")
+		event.Attributes().InsertString("z", "3")
+	}
+
+	small := spans.AppendEmpty()
+	small.Attributes().InsertString("a", "ok")
+
+	require.NoError(t, p.ConsumeTraces(context.Background(), td))
+	require.Len(t, next.AllTraces(), 1)
+
+	out := next.AllTraces()[0].ResourceSpans().At(0).InstrumentationLibrarySpans().At(0).Spans()
+
+	big = out.At(0)
+	require.Equal(t, map[string]interface{}{
+		"a": "héll" + TruncationMarker,
+		"b": "ok",
+	}, big.Attributes().AsRaw())
+	require.Equal(t, uint32(2), big.DroppedAttributesCount())
+	require.Equal(t, 1, big.Events().Len())
+	require.Equal(t, uint32(2), big.DroppedEventsCount())
+	require.Equal(t, 2, big.Events().At(0).Attributes().Len())
+	require.Equal(t, uint32(1), big.Events().At(0).DroppedAttributesCount())
+
+	small = out.At(1)
+	require.Equal(t, map[string]interface{}{"a": "ok"}, small.Attributes().AsRaw())
+	require.Zero(t, small.DroppedAttributesCount())
+
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP traces_span_limits_attribute_values_truncated_total Total count of attribute values truncated for exceeding span limits
+		# TYPE traces_span_limits_attribute_values_truncated_total counter
+		traces_span_limits_attribute_values_truncated_total 1
+		# HELP traces_span_limits_attributes_dropped_total Total count of span and span event attributes dropped for exceeding span limits
+		# TYPE traces_span_limits_attributes_dropped_total counter
+		traces_span_limits_attributes_dropped_total 2
+		# HELP traces_span_limits_events_dropped_total Total count of span events dropped for exceeding span limits
+		# TYPE traces_span_limits_events_dropped_total counter
+		traces_span_limits_events_dropped_total 2
+		# HELP traces_span_limits_spans_scrubbed_total Total count of spans modified for exceeding span limits
+		# TYPE traces_span_limits_spans_scrubbed_total counter
+		traces_span_limits_spans_scrubbed_total 1
+	`)))
+
+	require.NoError(t, p.Shutdown(context.Background()))
+}
+
+func TestTruncate_Nested(t *testing.T) {
+	p := newProcessor(nil, &Config{MaxAttributeValueLength: 3})
+
+	am := pdata.NewAttributeMap()
+	arr := pdata.NewAttributeValueArray()
+	arr.SliceVal().AppendEmpty().SetStringVal("abcdef")
+	am.Insert("arr", arr)
+	m := pdata.NewAttributeValueMap()
+	m.MapVal().InsertString("key", "ghijkl")
+	am.Insert("map", m)
+	am.InsertInt("int", 123456)
+
+	var stats scrubStats
+	require.Zero(t, p.limitAttributes(am, &stats))
+	require.Equal(t, 2, stats.truncated)
+	require.Equal(t, map[string]interface{}{
+		"arr": []interface{}{"abc" + TruncationMarker},
+		"map": map[string]interface{}{"key": "ghi" + TruncationMarker},
+		"int": int64(123456),
+	}, am.AsRaw())
+}
+
+func TestStart_InvalidLimit(t *testing.T) {
+	p := newProcessor(new(consumertest.TracesSink), &Config{MaxEvents: -1})
+	ctx := context.WithValue(context.Background(), contextkeys.PrometheusRegisterer, prometheus.NewRegistry())
+	require.Error(t, p.Start(ctx, nil))
+}
@@ -0,0 +1,92 @@
+package spanmetricsexemplars
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/spanmetricsprocessor"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config"
+	"go.opentelemetry.io/collector/consumer"
+)
+
+const (
+	// TypeStr is the unique identifier for the processor. It replaces the
+	// upstream spanmetrics processor.
+	TypeStr = "spanmetrics"
+
+	// MaxAttributes is the maximum number of span attributes which can be
+	// added to exemplars.
+	MaxAttributes = 5
+	// DefaultMaxValueLength is the default length, in characters, values of
+	// span attributes are truncated to before being added to exemplars.
+	DefaultMaxValueLength = 32
+)
+
+// Config holds the configuration for the upstream spanmetrics processor,
+// along with the span attributes to add to its exemplars.
+type Config struct {
+	spanmetricsprocessor.Config `mapstructure:",squash"`
+
+	Exemplars ExemplarsConfig `mapstructure:"exemplars"`
+}
+
+// ExemplarsConfig configures which span attributes are added to the
+// exemplars of the latency histogram. Exemplars always include the trace ID.
+type ExemplarsConfig struct {
+	// Attributes to add to exemplars. Attributes of the span are preferred
+	// over attributes of its resource. Attributes which aren't set are
+	// omitted.
+	Attributes []string `mapstructure:"attributes"`
+	// MaxValueLength is the length values of attributes are truncated to.
+	MaxValueLength int `mapstructure:"max_value_length"`
+}
+
+// Validate implements config.Processor.
+func (c *Config) Validate() error {
+	if len(c.Exemplars.Attributes) > MaxAttributes {
+		return fmt.Errorf("at most %d exemplar attributes may be configured, got %d", MaxAttributes, len(c.Exemplars.Attributes))
+	}
+	if c.Exemplars.MaxValueLength <= 0 {
+		return fmt.Errorf("exemplar max_value_length must be greater than 0")
+	}
+	return c.Config.Validate()
+}
+
+var upstreamFactory = spanmetricsprocessor.NewFactory()
+
+// NewFactory returns a new factory for the spanmetrics processor.
+func NewFactory() component.ProcessorFactory {
+	return component.NewProcessorFactory(
+		TypeStr,
+		createDefaultConfig,
+		component.WithTracesProcessor(createTracesProcessor),
+	)
+}
+
+func createDefaultConfig() config.Processor {
+	return &Config{
+		Config: *upstreamFactory.CreateDefaultConfig().(*spanmetricsprocessor.Config),
+		Exemplars: ExemplarsConfig{
+			MaxValueLength: DefaultMaxValueLength,
+		},
+	}
+}
+
+func createTracesProcessor(
+	ctx context.Context,
+	params component.ProcessorCreateSettings,
+	cfg config.Processor,
+	nextConsumer consumer.Traces,
+) (component.TracesProcessor, error) {
+
+	pCfg := cfg.(*Config)
+	upstream, err := upstreamFactory.CreateTracesProcessor(ctx, params, &pCfg.Config, nextConsumer)
+	if err != nil {
+		return nil, err
+	}
+	if len(pCfg.Exemplars.Attributes) == 0 {
+		return upstream, nil
+	}
+	return newProcessor(upstream, pCfg), nil
+}
@@ -0,0 +1,235 @@
+package spanmetricsexemplars
+
+import (
+	"context"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/model/pdata"
+)
+
+// traceIDKey is the exemplar attribute holding the trace ID, set by the
+// upstream processor.
+const traceIDKey = "trace_id"
+
+// exemplarKey identifies the exemplar recorded for a span. The upstream
+// processor doesn't record span IDs in exemplars, so spans are identified
+// by their trace ID and latency.
+type exemplarKey struct {
+	traceID string
+	latency float64
+}
+
+type attribute struct {
+	key, value string
+}
+
+type pendingExemplar struct {
+	attributes []attribute
+	refs       int
+}
+
+// processor wraps the upstream spanmetrics processor. Before traces are
+// passed to the upstream processor, the configured attributes of each span
+// are recorded. The upstream processor synchronously sends the metrics
+// built from the traces to its exporter, which is wrapped to add the
+// recorded attributes to the exemplars of the spans.
+type processor struct {
+	component.TracesProcessor
+
+	cfg             ExemplarsConfig
+	metricsExporter string
+
+	mut     sync.Mutex
+	pending map[exemplarKey]*pendingExemplar
+}
+
+var _ component.TracesProcessor = (*processor)(nil)
+
+func newProcessor(upstream component.TracesProcessor, cfg *Config) *processor {
+	return &processor{
+		TracesProcessor: upstream,
+		cfg:             cfg.Exemplars,
+		metricsExporter: cfg.MetricsExporter,
+		pending:         make(map[exemplarKey]*pendingExemplar),
+	}
+}
+
+// Start implements component.Component.
+func (p *processor) Start(ctx context.Context, host component.Host) error {
+	return p.TracesProcessor.Start(ctx, &wrappedHost{Host: host, p: p})
+}
+
+// ConsumeTraces implements consumer.Traces.
+func (p *processor) ConsumeTraces(ctx context.Context, td pdata.Traces) error {
+	keys := p.record(td)
+	defer p.release(keys)
+
+	return p.TracesProcessor.ConsumeTraces(ctx, td)
+}
+
+// record stores the attributes of all spans in td, returning the keys they
+// were stored by.
+func (p *processor) record(td pdata.Traces) []exemplarKey {
+	var keys []exemplarKey
+
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	rss := td.ResourceSpans()
+	for i := 0; i < rss.Len(); i++ {
+		rs := rss.At(i)
+		ilss := rs.InstrumentationLibrarySpans()
+		for j := 0; j < ilss.Len(); j++ {
+			spans := ilss.At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				span := spans.At(k)
+
+				key := exemplarKey{
+					traceID: span.TraceID().HexString(),
+					latency: float64(span.EndTimestamp()-span.StartTimestamp()) / float64(time.Millisecond.Nanoseconds()),
+				}
+				keys = append(keys, key)
+
+				if pe, ok := p.pending[key]; ok {
+					pe.refs++
+					continue
+				}
+				p.pending[key] = &pendingExemplar{
+					attributes: p.spanAttributes(span.Attributes(), rs.Resource().Attributes()),
+					refs:       1,
+				}
+			}
+		}
+	}
+	return keys
+}
+
+// spanAttributes returns the configured attributes, looking them up in
+// spanAttrs first and resourceAttrs second.
+func (p *processor) spanAttributes(spanAttrs, resourceAttrs pdata.AttributeMap) []attribute {
+	attrs := make([]attribute, 0, len(p.cfg.Attributes))
+	for _, name := range p.cfg.Attributes {
+		v, ok := spanAttrs.Get(name)
+		if !ok {
+			v, ok = resourceAttrs.Get(name)
+		}
+		if !ok {
+			continue
+		}
+		attrs = append(attrs, attribute{key: name, value: truncate(v.AsString(), p.cfg.MaxValueLength)})
+	}
+	return attrs
+}
+
+func (p *processor) release(keys []exemplarKey) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	for _, key := range keys {
+		pe, ok := p.pending[key]
+		if !ok {
+			continue
+		}
+		if pe.refs--; pe.refs == 0 {
+			delete(p.pending, key)
+		}
+	}
+}
+
+// addAttributes adds the recorded span attributes to the exemplars of the
+// histograms in md.
+func (p *processor) addAttributes(md pdata.Metrics) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	rms := md.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		ilms := rms.At(i).InstrumentationLibraryMetrics()
+		for j := 0; j < ilms.Len(); j++ {
+			metrics := ilms.At(j).Metrics()
+			for k := 0; k < metrics.Len(); k++ {
+				metric := metrics.At(k)
+				if metric.DataType() != pdata.MetricDataTypeHistogram {
+					continue
+				}
+				dps := metric.Histogram().DataPoints()
+				for l := 0; l < dps.Len(); l++ {
+					exemplars := dps.At(l).Exemplars()
+					for m := 0; m < exemplars.Len(); m++ {
+						p.addExemplarAttributes(exemplars.At(m))
+					}
+				}
+			}
+		}
+	}
+}
+
+func (p *processor) addExemplarAttributes(e pdata.Exemplar) {
+	traceID, ok := e.FilteredAttributes().Get(traceIDKey)
+	if !ok {
+		return
+	}
+	pe, ok := p.pending[exemplarKey{traceID: traceID.StringVal(), latency: e.DoubleVal()}]
+	if !ok {
+		return
+	}
+	for _, attr := range pe.attributes {
+		e.FilteredAttributes().UpsertString(attr.key, attr.value)
+	}
+}
+
+// truncate shortens s to at most n characters.
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// wrappedHost wraps the exporter used by the upstream processor.
+type wrappedHost struct {
+	component.Host
+	p *processor
+}
+
+// GetExporters implements component.Host.
+func (h *wrappedHost) GetExporters() map[config.DataType]map[config.ComponentID]component.Exporter {
+	exporters := h.Host.GetExporters()
+
+	res := make(map[config.DataType]map[config.ComponentID]component.Exporter, len(exporters))
+	for dt, exps := range exporters {
+		res[dt] = exps
+	}
+
+	metricsExporters := make(map[config.ComponentID]component.Exporter, len(exporters[config.MetricsDataType]))
+	for id, exp := range exporters[config.MetricsDataType] {
+		if me, ok := exp.(component.MetricsExporter); ok && id.String() == h.p.metricsExporter {
+			exp = &wrappedExporter{MetricsExporter: me, p: h.p}
+		}
+		metricsExporters[id] = exp
+	}
+	res[config.MetricsDataType] = metricsExporters
+	return res
+}
+
+// wrappedExporter adds span attributes to exemplars before exporting metrics.
+type wrappedExporter struct {
+	component.MetricsExporter
+	p *processor
+}
+
+// ConsumeMetrics implements consumer.Metrics.
+func (e *wrappedExporter) ConsumeMetrics(ctx context.Context, md pdata.Metrics) error {
+	e.p.addAttributes(md)
+	return e.MetricsExporter.ConsumeMetrics(ctx, md)
+}
+
+// Capabilities implements consumer.Metrics.
+func (e *wrappedExporter) Capabilities() consumer.Capabilities {
+	return consumer.Capabilities{MutatesData: true}
+}
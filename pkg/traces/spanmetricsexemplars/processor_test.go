@@ -0,0 +1,107 @@
+package spanmetricsexemplars
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/model/pdata"
+)
+
+func TestConsumeTraces(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.MetricsExporter = "sink"
+	cfg.Exemplars.Attributes = []string{"http.route", "service.version", "missing"}
+	cfg.Exemplars.MaxValueLength = 8
+
+	next := new(consumertest.TracesSink)
+	p, err := createTracesProcessor(context.Background(), componenttest.NewNopProcessorCreateSettings(), cfg, next)
+	require.NoError(t, err)
+
+	sink := &metricsSink{}
+	host := &mockHost{
+		Host: componenttest.NewNopHost(),
+		exporters: map[config.DataType]map[config.ComponentID]component.Exporter{
+			config.MetricsDataType: {config.NewComponentID("sink"): sink},
+		},
+	}
+	require.NoError(t, p.Start(context.Background(), host))
+
+	td := pdata.NewTraces()
+	rs := td.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().InsertString("service.name", "api")
+	rs.Resource().Attributes().InsertString("service.version", "v1.2.3")
+
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	span := rs.InstrumentationLibrarySpans().AppendEmpty().Spans().AppendEmpty()
+	span.SetName("GET /users")
+	span.SetTraceID(pdata.NewTraceID([16]byte{1}))
+	span.SetSpanID(pdata.NewSpanID([8]byte{1}))
+	span.SetStartTimestamp(pdata.NewTimestampFromTime(start))
+	span.SetEndTimestamp(pdata.NewTimestampFromTime(start.Add(42 * time.Millisecond)))
+	span.Attributes().InsertString("http.route", "/users/{id}")
+
+	require.NoError(t, p.ConsumeTraces(context.Background(), td))
+	require.Len(t, next.AllTraces(), 1)
+	require.Len(t, sink.AllMetrics(), 1)
+
+	var exemplars pdata.ExemplarSlice
+	metrics := sink.AllMetrics()[0].ResourceMetrics().At(0).InstrumentationLibraryMetrics().At(0).Metrics()
+	for i := 0; i < metrics.Len(); i++ {
+		if metrics.At(i).DataType() == pdata.MetricDataTypeHistogram {
+			exemplars = metrics.At(i).Histogram().DataPoints().At(0).Exemplars()
+		}
+	}
+	require.Equal(t, 1, exemplars.Len())
+	require.Equal(t, map[string]interface{}{
+		"trace_id":        span.TraceID().HexString(),
+		"http.route":      "/users/{",
+		"service.version": "v1.2.3",
+	}, exemplars.At(0).FilteredAttributes().AsRaw())
+
+	// Attributes of consumed spans are released.
+	require.Empty(t, p.(*processor).pending)
+}
+
+func TestCreateTracesProcessor_NoAttributes(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.MetricsExporter = "sink"
+
+	p, err := createTracesProcessor(context.Background(), componenttest.NewNopProcessorCreateSettings(), cfg, consumertest.NewNop())
+	require.NoError(t, err)
+
+	_, wrapped := p.(*processor)
+	require.False(t, wrapped, "upstream processor should be used as is without exemplar attributes")
+}
+
+func TestConfig_Validate(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	require.NoError(t, cfg.Validate())
+
+	cfg.Exemplars.Attributes = []string{"a", "b", "c", "d", "e", "f"}
+	require.Error(t, cfg.Validate())
+
+	cfg.Exemplars.Attributes = nil
+	cfg.Exemplars.MaxValueLength = 0
+	require.Error(t, cfg.Validate())
+}
+
+type mockHost struct {
+	component.Host
+	exporters map[config.DataType]map[config.ComponentID]component.Exporter
+}
+
+func (h *mockHost) GetExporters() map[config.DataType]map[config.ComponentID]component.Exporter {
+	return h.exporters
+}
+
+type metricsSink struct {
+	component.StartFunc
+	component.ShutdownFunc
+	consumertest.MetricsSink
+}
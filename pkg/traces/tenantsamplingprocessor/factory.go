@@ -0,0 +1,66 @@
+package tenantsamplingprocessor
+
+import (
+	"context"
+	"time"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/tailsamplingprocessor"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config"
+	"go.opentelemetry.io/collector/consumer"
+)
+
+// TypeStr is the unique identifier for the tenant sampling processor.
+const TypeStr = "tenant_tail_sampling"
+
+// Config holds the configuration for the tenant sampling processor.
+type Config struct {
+	config.ProcessorSettings `mapstructure:",squash"`
+
+	// TenantAttribute is the resource attribute holding the tenant of spans.
+	TenantAttribute string `mapstructure:"tenant_attribute"`
+	// TenantHeader is the request header holding the tenant of spans.
+	TenantHeader string `mapstructure:"tenant_header"`
+
+	// DecisionWait and Policies sample the traces of tenants without their
+	// own policies. Traces of these tenants are dropped when Policies is
+	// empty.
+	DecisionWait time.Duration                     `mapstructure:"decision_wait"`
+	Policies     []tailsamplingprocessor.PolicyCfg `mapstructure:"policies"`
+
+	// Tenants holds the sampling policies of individual tenants.
+	Tenants []TenantConfig `mapstructure:"tenants"`
+}
+
+// TenantConfig configures the tail sampling of the traces of a tenant.
+type TenantConfig struct {
+	Name         string                            `mapstructure:"name"`
+	DecisionWait time.Duration                     `mapstructure:"decision_wait"`
+	Policies     []tailsamplingprocessor.PolicyCfg `mapstructure:"policies"`
+}
+
+// NewFactory returns a new factory for the tenant sampling processor.
+func NewFactory() component.ProcessorFactory {
+	return component.NewProcessorFactory(
+		TypeStr,
+		createDefaultConfig,
+		component.WithTracesProcessor(createTracesProcessor),
+	)
+}
+
+func createDefaultConfig() config.Processor {
+	return &Config{
+		ProcessorSettings: config.NewProcessorSettings(config.NewComponentIDWithName(TypeStr, TypeStr)),
+	}
+}
+
+func createTracesProcessor(
+	_ context.Context,
+	set component.ProcessorCreateSettings,
+	cfg config.Processor,
+	nextConsumer consumer.Traces,
+) (component.TracesProcessor, error) {
+
+	eCfg := cfg.(*Config)
+	return newProcessor(set, nextConsumer, eCfg)
+}
@@ -0,0 +1,185 @@
+// Package tenantsamplingprocessor applies tail-based sampling policies per
+// tenant. Spans are routed by tenant to separate tail sampling processors, so
+// the policies, decision wait and rate budgets of tenants are independent of
+// each other.
+package tenantsamplingprocessor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/tailsamplingprocessor"
+	"go.opentelemetry.io/collector/client"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/model/pdata"
+	"go.uber.org/multierr"
+)
+
+type processor struct {
+	tenantAttribute string
+	tenantHeader    string
+
+	tenants map[string]component.TracesProcessor
+	// fallback samples the traces of tenants without their own policies. It's
+	// nil when these traces are dropped.
+	fallback component.TracesProcessor
+}
+
+func newProcessor(set component.ProcessorCreateSettings, nextConsumer consumer.Traces, cfg *Config) (*processor, error) {
+	if (cfg.TenantAttribute == "") == (cfg.TenantHeader == "") {
+		return nil, fmt.Errorf("exactly one of tenant_attribute and tenant_header must be set")
+	}
+
+	p := &processor{
+		tenantAttribute: cfg.TenantAttribute,
+		tenantHeader:    cfg.TenantHeader,
+		tenants:         make(map[string]component.TracesProcessor, len(cfg.Tenants)),
+	}
+
+	for _, tc := range cfg.Tenants {
+		if tc.Name == "" {
+			return nil, fmt.Errorf("tenant name must be set")
+		}
+		if _, exist := p.tenants[tc.Name]; exist {
+			return nil, fmt.Errorf("found multiple sampling configs for tenant %q", tc.Name)
+		}
+		if len(tc.Policies) == 0 {
+			return nil, fmt.Errorf("tenant %q must have at least one sampling policy", tc.Name)
+		}
+
+		wait := tc.DecisionWait
+		if wait == 0 {
+			wait = cfg.DecisionWait
+		}
+		tsp, err := newTailSampling(set, nextConsumer, tc.Name, wait, tc.Policies)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create tail sampling for tenant %q: %w", tc.Name, err)
+		}
+		p.tenants[tc.Name] = tsp
+	}
+
+	if len(cfg.Policies) > 0 {
+		tsp, err := newTailSampling(set, nextConsumer, "", cfg.DecisionWait, cfg.Policies)
+		if err != nil {
+			return nil, err
+		}
+		p.fallback = tsp
+	}
+
+	return p, nil
+}
+
+// newTailSampling creates a tail sampling processor for the traces of tenant.
+// The names of policies are prefixed with the tenant to tell their metrics
+// apart.
+func newTailSampling(set component.ProcessorCreateSettings, nextConsumer consumer.Traces, tenant string, wait time.Duration, policies []tailsamplingprocessor.PolicyCfg) (component.TracesProcessor, error) {
+	factory := tailsamplingprocessor.NewFactory()
+	cfg := factory.CreateDefaultConfig().(*tailsamplingprocessor.Config)
+	cfg.ProcessorSettings = config.NewProcessorSettings(config.NewComponentIDWithName(factory.Type(), tenant))
+	if wait != 0 {
+		cfg.DecisionWait = wait
+	}
+
+	cfg.PolicyCfgs = make([]tailsamplingprocessor.PolicyCfg, 0, len(policies))
+	for _, policy := range policies {
+		if tenant != "" {
+			policy.Name = tenant + "/" + policy.Name
+		}
+		cfg.PolicyCfgs = append(cfg.PolicyCfgs, policy)
+	}
+
+	return factory.CreateTracesProcessor(context.Background(), set, cfg, nextConsumer)
+}
+
+// processors returns all tail sampling processors.
+func (p *processor) processors() []component.TracesProcessor {
+	res := make([]component.TracesProcessor, 0, len(p.tenants)+1)
+	for _, tsp := range p.tenants {
+		res = append(res, tsp)
+	}
+	if p.fallback != nil {
+		res = append(res, p.fallback)
+	}
+	return res
+}
+
+func (p *processor) Start(ctx context.Context, host component.Host) error {
+	for _, tsp := range p.processors() {
+		if err := tsp.Start(ctx, host); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *processor) Shutdown(ctx context.Context) error {
+	var errs error
+	for _, tsp := range p.processors() {
+		errs = multierr.Append(errs, tsp.Shutdown(ctx))
+	}
+	return errs
+}
+
+func (p *processor) Capabilities() consumer.Capabilities {
+	return consumer.Capabilities{MutatesData: false}
+}
+
+func (p *processor) ConsumeTraces(ctx context.Context, td pdata.Traces) error {
+	if p.tenantHeader != "" {
+		return p.consumeTenant(ctx, headerTenant(ctx, p.tenantHeader), td)
+	}
+
+	// Resources of different tenants may be sent in the same request, so
+	// they're split by tenant.
+	byTenant := make(map[string]pdata.Traces)
+	rss := td.ResourceSpans()
+	for i := 0; i < rss.Len(); i++ {
+		rs := rss.At(i)
+
+		var tenant string
+		if v, ok := rs.Resource().Attributes().Get(p.tenantAttribute); ok {
+			tenant = v.StringVal()
+		}
+		tenantTraces, ok := byTenant[tenant]
+		if !ok {
+			tenantTraces = pdata.NewTraces()
+			byTenant[tenant] = tenantTraces
+		}
+		rs.CopyTo(tenantTraces.ResourceSpans().AppendEmpty())
+	}
+
+	var errs error
+	for tenant, tenantTraces := range byTenant {
+		errs = multierr.Append(errs, p.consumeTenant(ctx, tenant, tenantTraces))
+	}
+	return errs
+}
+
+func (p *processor) consumeTenant(ctx context.Context, tenant string, td pdata.Traces) error {
+	tsp, ok := p.tenants[tenant]
+	if !ok {
+		tsp = p.fallback
+	}
+	if tsp == nil {
+		return nil
+	}
+	return tsp.ConsumeTraces(ctx, td)
+}
+
+// headerTenant returns the tenant from the header of the request spans were
+// received with. Header names are case-insensitive, but the case in which
+// receivers store them varies.
+func headerTenant(ctx context.Context, header string) string {
+	md := client.FromContext(ctx).Metadata
+	for _, key := range []string{header, strings.ToLower(header), http.CanonicalHeaderKey(header)} {
+		if values := md.Get(key); len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
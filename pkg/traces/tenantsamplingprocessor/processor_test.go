@@ -0,0 +1,177 @@
+package tenantsamplingprocessor
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/tailsamplingprocessor"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/client"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/model/pdata"
+)
+
+var (
+	alwaysSample = tailsamplingprocessor.PolicyCfg{Name: "always_sample/0", Type: tailsamplingprocessor.AlwaysSample}
+	errorsOnly   = tailsamplingprocessor.PolicyCfg{
+		Name:          "status_code/0",
+		Type:          tailsamplingprocessor.StatusCode,
+		StatusCodeCfg: tailsamplingprocessor.StatusCodeCfg{StatusCodes: []string{"ERROR"}},
+	}
+)
+
+func TestConsumeTraces_TenantAttribute(t *testing.T) {
+	next := &mockConsumer{}
+	p := newTestProcessor(t, next, &Config{
+		TenantAttribute: "tenant",
+		DecisionWait:    time.Second,
+		Policies:        []tailsamplingprocessor.PolicyCfg{alwaysSample},
+		Tenants: []TenantConfig{
+			{Name: "team-a", Policies: []tailsamplingprocessor.PolicyCfg{errorsOnly}},
+		},
+	})
+
+	// Resources of several tenants sent together are sampled separately. Only
+	// the failed trace of team-a is kept, while the default policies keep all
+	// traces of other tenants.
+	td := pdata.NewTraces()
+	appendTrace(td, "team-a", 1, pdata.StatusCodeError)
+	appendTrace(td, "team-a", 2, pdata.StatusCodeOk)
+	appendTrace(td, "team-b", 3, pdata.StatusCodeOk)
+	appendTrace(td, "", 4, pdata.StatusCodeOk)
+	require.NoError(t, p.ConsumeTraces(context.Background(), td))
+
+	require.Eventually(t, func() bool {
+		return len(next.Traces()) == 3
+	}, 5*time.Second, 50*time.Millisecond)
+	require.ElementsMatch(t, []byte{1, 3, 4}, next.Traces())
+}
+
+func TestConsumeTraces_TenantHeader(t *testing.T) {
+	next := &mockConsumer{}
+	p := newTestProcessor(t, next, &Config{
+		TenantHeader: "X-Scope-OrgID",
+		DecisionWait: time.Second,
+		Tenants: []TenantConfig{
+			{Name: "team-a", Policies: []tailsamplingprocessor.PolicyCfg{errorsOnly}},
+			{Name: "team-b", Policies: []tailsamplingprocessor.PolicyCfg{alwaysSample}},
+		},
+	})
+
+	consume := func(tenant string, id byte, code pdata.StatusCode) {
+		ctx := client.NewContext(context.Background(), client.Info{
+			Metadata: client.NewMetadata(map[string][]string{"x-scope-orgid": {tenant}}),
+		})
+		td := pdata.NewTraces()
+		appendTrace(td, "", id, code)
+		require.NoError(t, p.ConsumeTraces(ctx, td))
+	}
+	consume("team-a", 1, pdata.StatusCodeError)
+	consume("team-a", 2, pdata.StatusCodeOk)
+	consume("team-b", 3, pdata.StatusCodeOk)
+	// Traces of tenants without policies are dropped without default
+	// policies.
+	consume("team-c", 4, pdata.StatusCodeError)
+
+	require.Eventually(t, func() bool {
+		return len(next.Traces()) == 2
+	}, 5*time.Second, 50*time.Millisecond)
+	require.ElementsMatch(t, []byte{1, 3}, next.Traces())
+}
+
+func TestNewProcessor_Invalid(t *testing.T) {
+	tt := []struct {
+		name string
+		cfg  Config
+		err  string
+	}{
+		{
+			name: "no tenant source",
+			cfg:  Config{},
+			err:  "exactly one of tenant_attribute and tenant_header must be set",
+		},
+		{
+			name: "duplicate tenant",
+			cfg: Config{
+				TenantAttribute: "tenant",
+				Tenants: []TenantConfig{
+					{Name: "team-a", Policies: []tailsamplingprocessor.PolicyCfg{alwaysSample}},
+					{Name: "team-a", Policies: []tailsamplingprocessor.PolicyCfg{alwaysSample}},
+				},
+			},
+			err: `found multiple sampling configs for tenant "team-a"`,
+		},
+		{
+			name: "tenant without policies",
+			cfg: Config{
+				TenantAttribute: "tenant",
+				Tenants:         []TenantConfig{{Name: "team-a"}},
+			},
+			err: `tenant "team-a" must have at least one sampling policy`,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := newProcessor(componenttest.NewNopProcessorCreateSettings(), &mockConsumer{}, &tc.cfg)
+			require.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+func newTestProcessor(t *testing.T, next consumer.Traces, cfg *Config) *processor {
+	t.Helper()
+
+	p, err := newProcessor(componenttest.NewNopProcessorCreateSettings(), next, cfg)
+	require.NoError(t, err)
+	require.NoError(t, p.Start(context.Background(), componenttest.NewNopHost()))
+	t.Cleanup(func() { require.NoError(t, p.Shutdown(context.Background())) })
+	return p
+}
+
+// appendTrace appends a trace made of a single span to td. The first byte of
+// the trace ID is id.
+func appendTrace(td pdata.Traces, tenant string, id byte, code pdata.StatusCode) {
+	rs := td.ResourceSpans().AppendEmpty()
+	if tenant != "" {
+		rs.Resource().Attributes().InsertString("tenant", tenant)
+	}
+	span := rs.InstrumentationLibrarySpans().AppendEmpty().Spans().AppendEmpty()
+	span.SetTraceID(pdata.NewTraceID([16]byte{id}))
+	span.SetSpanID(pdata.NewSpanID([8]byte{1}))
+	span.Status().SetCode(code)
+}
+
+type mockConsumer struct {
+	mut    sync.Mutex
+	traces []byte
+}
+
+func (m *mockConsumer) Capabilities() consumer.Capabilities { return consumer.Capabilities{} }
+
+func (m *mockConsumer) ConsumeTraces(_ context.Context, td pdata.Traces) error {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	rss := td.ResourceSpans()
+	for i := 0; i < rss.Len(); i++ {
+		ilss := rss.At(i).InstrumentationLibrarySpans()
+		for j := 0; j < ilss.Len(); j++ {
+			spans := ilss.At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				id := spans.At(k).TraceID().Bytes()
+				m.traces = append(m.traces, id[0])
+			}
+		}
+	}
+	return nil
+}
+
+// Traces returns the first byte of the IDs of the traces consumed.
+func (m *mockConsumer) Traces() []byte {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	return append([]byte{}, m.traces...)
+}
@@ -0,0 +1,47 @@
+package traceratelimitprocessor
+
+import (
+	"context"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config"
+	"go.opentelemetry.io/collector/consumer"
+)
+
+// TypeStr is the unique identifier for the trace rate limit processor.
+const TypeStr = "trace_rate_limit"
+
+// Config holds the configuration for the trace rate limit processor.
+type Config struct {
+	config.ProcessorSettings `mapstructure:",squash"`
+
+	// SpansPerSecond is the rate of spans above which whole traces start
+	// being dropped.
+	SpansPerSecond float64 `mapstructure:"spans_per_second"`
+}
+
+// NewFactory returns a new factory for the trace rate limit processor.
+func NewFactory() component.ProcessorFactory {
+	return component.NewProcessorFactory(
+		TypeStr,
+		createDefaultConfig,
+		component.WithTracesProcessor(createTracesProcessor),
+	)
+}
+
+func createDefaultConfig() config.Processor {
+	return &Config{
+		ProcessorSettings: config.NewProcessorSettings(config.NewComponentIDWithName(TypeStr, TypeStr)),
+	}
+}
+
+func createTracesProcessor(
+	_ context.Context,
+	_ component.ProcessorCreateSettings,
+	cfg config.Processor,
+	nextConsumer consumer.Traces,
+) (component.TracesProcessor, error) {
+
+	eCfg := cfg.(*Config)
+	return newProcessor(nextConsumer, eCfg), nil
+}
@@ -0,0 +1,200 @@
+// Package traceratelimitprocessor limits the rate of spans flowing through a
+// traces pipeline. When overloaded, it admits or drops whole traces based on
+// a hash of their trace ID, so traces which are kept remain complete instead
+// of losing a random subset of their spans.
+package traceratelimitprocessor
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sync"
+	"time"
+
+	util "github.com/cortexproject/cortex/pkg/util/log"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/agent/pkg/traces/contextkeys"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/model/pdata"
+)
+
+// window is the interval over which the incoming span rate is measured.
+const window = time.Second
+
+type processor struct {
+	nextConsumer consumer.Traces
+	logger       log.Logger
+	now          func() time.Time
+
+	spansPerSecond float64
+
+	mut         sync.Mutex
+	windowStart time.Time
+	windowSpans int
+	// ratio is the fraction of the trace ID space admitted, computed from the
+	// span rate of the last completed window.
+	ratio float64
+
+	reg              prometheus.Registerer
+	spansAdmitted    prometheus.Counter
+	spansDropped     prometheus.Counter
+	tracesAdmitRatio prometheus.Gauge
+}
+
+func newProcessor(nextConsumer consumer.Traces, cfg *Config) *processor {
+	logger := log.With(util.Logger, "component", "trace rate limit")
+
+	return &processor{
+		nextConsumer: nextConsumer,
+		logger:       logger,
+		now:          time.Now,
+
+		spansPerSecond: cfg.SpansPerSecond,
+		ratio:          1,
+	}
+}
+
+func (p *processor) Start(ctx context.Context, _ component.Host) error {
+	if p.spansPerSecond <= 0 {
+		return fmt.Errorf("spans_per_second must be greater than 0")
+	}
+
+	reg, ok := ctx.Value(contextkeys.PrometheusRegisterer).(prometheus.Registerer)
+	if !ok || reg == nil {
+		return fmt.Errorf("key does not contain a prometheus registerer")
+	}
+	p.reg = reg
+	return p.registerMetrics()
+}
+
+func (p *processor) registerMetrics() error {
+	p.spansAdmitted = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "traces",
+		Name:      "rate_limit_spans_admitted_total",
+		Help:      "Total count of spans admitted by the trace rate limiter",
+	})
+	p.spansDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "traces",
+		Name:      "rate_limit_spans_dropped_total",
+		Help:      "Total count of spans dropped by the trace rate limiter",
+	})
+	p.tracesAdmitRatio = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "traces",
+		Name:      "rate_limit_admit_ratio",
+		Help:      "Fraction of traces currently admitted by the trace rate limiter",
+	})
+	p.tracesAdmitRatio.Set(1)
+
+	cs := []prometheus.Collector{
+		p.spansAdmitted,
+		p.spansDropped,
+		p.tracesAdmitRatio,
+	}
+
+	for _, c := range cs {
+		if err := p.reg.Register(c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *processor) Shutdown(context.Context) error {
+	if p.reg == nil {
+		return nil
+	}
+	p.reg.Unregister(p.spansAdmitted)
+	p.reg.Unregister(p.spansDropped)
+	p.reg.Unregister(p.tracesAdmitRatio)
+	return nil
+}
+
+func (p *processor) Capabilities() consumer.Capabilities {
+	return consumer.Capabilities{MutatesData: true}
+}
+
+func (p *processor) ConsumeTraces(ctx context.Context, td pdata.Traces) error {
+	incoming := td.SpanCount()
+	ratio := p.observe(incoming)
+
+	if ratio < 1 {
+		threshold := uint64(ratio * math.MaxUint64)
+		filterTraces(td, func(id pdata.TraceID) bool {
+			return traceIDHash(id) < threshold
+		})
+	}
+
+	admitted := td.SpanCount()
+	p.spansAdmitted.Add(float64(admitted))
+	if dropped := incoming - admitted; dropped > 0 {
+		p.spansDropped.Add(float64(dropped))
+	}
+
+	if admitted == 0 {
+		return nil
+	}
+	return p.nextConsumer.ConsumeTraces(ctx, td)
+}
+
+// observe records spans arriving in the current window and returns the
+// admit ratio to apply to them. The ratio is only recomputed when a window
+// completes so that every batch belonging to the same trace is given the
+// same decision for the duration of a window.
+func (p *processor) observe(spans int) float64 {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	now := p.now()
+	if p.windowStart.IsZero() {
+		p.windowStart = now
+	}
+
+	if elapsed := now.Sub(p.windowStart); elapsed >= window {
+		rate := float64(p.windowSpans) / elapsed.Seconds()
+
+		ratio := 1.0
+		if rate > p.spansPerSecond {
+			ratio = p.spansPerSecond / rate
+		}
+		if ratio != p.ratio {
+			level.Debug(p.logger).Log("msg", "updated trace admit ratio", "rate", rate, "ratio", ratio)
+		}
+		p.ratio = ratio
+		p.tracesAdmitRatio.Set(ratio)
+
+		p.windowStart = now
+		p.windowSpans = 0
+	}
+
+	p.windowSpans += spans
+	return p.ratio
+}
+
+// filterTraces removes all spans whose trace ID is not accepted by keep.
+// Resource and instrumentation library entries left empty are removed too.
+func filterTraces(td pdata.Traces, keep func(pdata.TraceID) bool) {
+	td.ResourceSpans().RemoveIf(func(rs pdata.ResourceSpans) bool {
+		rs.InstrumentationLibrarySpans().RemoveIf(func(ils pdata.InstrumentationLibrarySpans) bool {
+			ils.Spans().RemoveIf(func(span pdata.Span) bool {
+				return !keep(span.TraceID())
+			})
+			return ils.Spans().Len() == 0
+		})
+		return rs.InstrumentationLibrarySpans().Len() == 0
+	})
+}
+
+// traceIDHash maps a trace ID onto a uniformly distributed uint64. Trace IDs
+// are hashed rather than used directly since not every client generates them
+// randomly.
+func traceIDHash(id pdata.TraceID) uint64 {
+	b := id.Bytes()
+	h := fnv.New64a()
+	_, _ = h.Write(b[:])
+	return h.Sum64()
+}
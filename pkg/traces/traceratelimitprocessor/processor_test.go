@@ -0,0 +1,138 @@
+package traceratelimitprocessor
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/pkg/traces/contextkeys"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/model/pdata"
+)
+
+func TestConsumeTraces(t *testing.T) {
+	next := &mockConsumer{}
+	p := newProcessor(next, &Config{SpansPerSecond: 100})
+
+	now := time.Unix(0, 0)
+	p.now = func() time.Time { return now }
+
+	reg := prometheus.NewRegistry()
+	ctx := context.WithValue(context.Background(), contextkeys.PrometheusRegisterer, reg)
+	require.NoError(t, p.Start(ctx, nil))
+
+	// The first window is admitted in full while the rate is being measured.
+	// 400 spans per second is 4x over the limit.
+	require.NoError(t, p.ConsumeTraces(context.Background(), generateTraces(100, 4)))
+	require.Equal(t, 400, next.spans)
+
+	// After the window completes, only ~1/4 of traces are admitted.
+	now = now.Add(time.Second)
+	next.reset()
+	require.NoError(t, p.ConsumeTraces(context.Background(), generateTraces(100, 4)))
+	require.InDelta(t, 100, next.spans, 40)
+
+	// Traces are admitted or dropped whole.
+	for id, count := range next.traces {
+		require.Equal(t, 4, count, "trace %s was fragmented", id)
+	}
+
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP traces_rate_limit_admit_ratio Fraction of traces currently admitted by the trace rate limiter
+		# TYPE traces_rate_limit_admit_ratio gauge
+		traces_rate_limit_admit_ratio 0.25
+	`), "traces_rate_limit_admit_ratio"))
+
+	// Once traffic drops below the limit everything is admitted again.
+	now = now.Add(10 * time.Second)
+	next.reset()
+	require.NoError(t, p.ConsumeTraces(context.Background(), generateTraces(10, 4)))
+	require.Equal(t, 40, next.spans)
+
+	require.NoError(t, p.Shutdown(context.Background()))
+}
+
+func TestConsumeTraces_SameDecisionAcrossBatches(t *testing.T) {
+	next := &mockConsumer{}
+	p := newProcessor(next, &Config{SpansPerSecond: 10})
+
+	now := time.Unix(0, 0)
+	p.now = func() time.Time { return now }
+
+	ctx := context.WithValue(context.Background(), contextkeys.PrometheusRegisterer, prometheus.NewRegistry())
+	require.NoError(t, p.Start(ctx, nil))
+
+	require.NoError(t, p.ConsumeTraces(context.Background(), generateTraces(50, 1)))
+	now = now.Add(time.Second)
+
+	// Spans of the same traces arriving in separate batches within a window
+	// get the same decision.
+	next.reset()
+	require.NoError(t, p.ConsumeTraces(context.Background(), generateTraces(50, 1)))
+	first := next.traces
+	require.NotEmpty(t, first)
+	require.Less(t, len(first), 50)
+
+	next.reset()
+	require.NoError(t, p.ConsumeTraces(context.Background(), generateTraces(50, 1)))
+	require.Equal(t, first, next.traces)
+}
+
+func TestStart_InvalidLimit(t *testing.T) {
+	p := newProcessor(&mockConsumer{}, &Config{})
+	ctx := context.WithValue(context.Background(), contextkeys.PrometheusRegisterer, prometheus.NewRegistry())
+	require.Error(t, p.Start(ctx, nil))
+}
+
+// generateTraces returns numTraces traces with deterministic IDs, each made
+// of spansPerTrace spans.
+func generateTraces(numTraces, spansPerTrace int) pdata.Traces {
+	td := pdata.NewTraces()
+	spans := td.ResourceSpans().AppendEmpty().InstrumentationLibrarySpans().AppendEmpty().Spans()
+
+	for i := 0; i < numTraces; i++ {
+		var id [16]byte
+		id[0], id[1] = byte(i>>8), byte(i)
+		for j := 0; j < spansPerTrace; j++ {
+			span := spans.AppendEmpty()
+			span.SetTraceID(pdata.NewTraceID(id))
+			span.SetSpanID(pdata.NewSpanID([8]byte{byte(j + 1)}))
+		}
+	}
+	return td
+}
+
+type mockConsumer struct {
+	spans  int
+	traces map[string]int
+}
+
+func (m *mockConsumer) reset() {
+	m.spans = 0
+	m.traces = nil
+}
+
+func (m *mockConsumer) Capabilities() consumer.Capabilities { return consumer.Capabilities{} }
+
+func (m *mockConsumer) ConsumeTraces(_ context.Context, td pdata.Traces) error {
+	if m.traces == nil {
+		m.traces = make(map[string]int)
+	}
+	m.spans += td.SpanCount()
+
+	rss := td.ResourceSpans()
+	for i := 0; i < rss.Len(); i++ {
+		ilss := rss.At(i).InstrumentationLibrarySpans()
+		for j := 0; j < ilss.Len(); j++ {
+			spans := ilss.At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				m.traces[spans.At(k).TraceID().HexString()]++
+			}
+		}
+	}
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"contrib.go.opencensus.io/exporter/prometheus"
+	"github.com/grafana/agent/pkg/limits"
 	"github.com/grafana/agent/pkg/logs"
 	"github.com/grafana/agent/pkg/metrics/instance"
 	zaplogfmt "github.com/jsternberg/zap-logfmt"
@@ -31,10 +32,11 @@ type Traces struct {
 	reg      prom_client.Registerer
 
 	promInstanceManager instance.Manager
+	limiter             *limits.Limiter
 }
 
 // New creates and starts trace collection.
-func New(logsSubsystem *logs.Logs, promInstanceManager instance.Manager, reg prom_client.Registerer, cfg Config, level logrus.Level, fmt logging.Format) (*Traces, error) {
+func New(logsSubsystem *logs.Logs, promInstanceManager instance.Manager, limiter *limits.Limiter, reg prom_client.Registerer, cfg Config, level logrus.Level, fmt logging.Format) (*Traces, error) {
 	var leveller logLeveller
 
 	traces := &Traces{
@@ -43,6 +45,7 @@ func New(logsSubsystem *logs.Logs, promInstanceManager instance.Manager, reg pro
 		logger:              newLogger(&leveller, fmt),
 		reg:                 reg,
 		promInstanceManager: promInstanceManager,
+		limiter:             limiter,
 	}
 	if err := traces.ApplyConfig(logsSubsystem, promInstanceManager, cfg, level); err != nil {
 		return nil, err
@@ -69,6 +72,8 @@ func (t *Traces) ApplyConfig(logsSubsystem *logs.Logs, promInstanceManager insta
 	newInstances := make(map[string]*Instance, len(cfg.Configs))
 
 	for _, c := range cfg.Configs {
+		c.limits = t.limiter != nil
+
 		var (
 			instReg = prom_client.WrapRegistererWith(prom_client.Labels{"traces_config": c.Name}, t.reg)
 		)
@@ -88,7 +93,7 @@ func (t *Traces) ApplyConfig(logsSubsystem *logs.Logs, promInstanceManager insta
 			instLogger = t.logger.With(zap.String("traces_config", c.Name))
 		)
 
-		inst, err := NewInstance(logsSubsystem, instReg, c, instLogger, t.promInstanceManager)
+		inst, err := NewInstance(logsSubsystem, instReg, c, instLogger, t.promInstanceManager, t.limiter)
 		if err != nil {
 			return fmt.Errorf("failed to create tracing instance %s: %w", c.Name, err)
 		}
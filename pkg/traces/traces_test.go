@@ -48,7 +48,7 @@ configs:
 	var loggingLevel logging.Level
 	require.NoError(t, loggingLevel.Set("debug"))
 
-	traces, err := New(nil, nil, prometheus.NewRegistry(), cfg, logrus.InfoLevel, logging.Format{})
+	traces, err := New(nil, nil, nil, prometheus.NewRegistry(), cfg, logrus.InfoLevel, logging.Format{})
 	require.NoError(t, err)
 	t.Cleanup(traces.Stop)
 
@@ -93,11 +93,93 @@ configs:
 	var loggingLevel logging.Level
 	require.NoError(t, loggingLevel.Set("debug"))
 
-	traces, err := New(nil, nil, prometheus.NewRegistry(), cfg, logrus.InfoLevel, logging.Format{})
+	traces, err := New(nil, nil, nil, prometheus.NewRegistry(), cfg, logrus.InfoLevel, logging.Format{})
 	require.NoError(t, err)
 	t.Cleanup(traces.Stop)
 }
 
+func TestTraceWithProcessorMetrics(t *testing.T) {
+	// trace_rate_limiting and span_limits register metrics without
+	// service_graphs being enabled.
+	tracesCfgText := util.Untab(`
+configs:
+- name: test
+  receivers:
+    zipkin:
+      endpoint: 0.0.0.0:9999
+  remote_write:
+    - endpoint: 0.0.0.0:5555
+      insecure: true
+  trace_rate_limiting:
+    spans_per_second: 100
+  span_limits:
+    max_attributes: 10
+	`)
+
+	var cfg Config
+	dec := yaml.NewDecoder(strings.NewReader(tracesCfgText))
+	dec.SetStrict(true)
+	err := dec.Decode(&cfg)
+	require.NoError(t, err)
+
+	traces, err := New(nil, nil, nil, prometheus.NewRegistry(), cfg, logrus.InfoLevel, logging.Format{})
+	require.NoError(t, err)
+	t.Cleanup(traces.Stop)
+}
+
+func TestTraceWithMigration(t *testing.T) {
+	oldCh, newCh := make(chan pdata.Traces), make(chan pdata.Traces)
+	oldAddr := traceutils.NewTestServer(t, func(t pdata.Traces) {
+		oldCh <- t
+	})
+	newAddr := traceutils.NewTestServer(t, func(t pdata.Traces) {
+		newCh <- t
+	})
+
+	tracesCfgText := util.Untab(fmt.Sprintf(`
+configs:
+- name: default
+  receivers:
+    jaeger:
+      protocols:
+        thrift_compact:
+  remote_write:
+    - endpoint: %s
+      insecure: true
+  migration:
+    mode: duplicate
+    remote_write:
+      endpoint: %s
+      insecure: true
+  batch:
+    timeout: 100ms
+    send_batch_size: 1
+	`, oldAddr, newAddr))
+
+	var cfg Config
+	dec := yaml.NewDecoder(strings.NewReader(tracesCfgText))
+	dec.SetStrict(true)
+	err := dec.Decode(&cfg)
+	require.NoError(t, err)
+
+	traces, err := New(nil, nil, nil, prometheus.NewRegistry(), cfg, logrus.InfoLevel, logging.Format{})
+	require.NoError(t, err)
+	t.Cleanup(traces.Stop)
+
+	tr := testJaegerTracer(t)
+	span := tr.StartSpan("test-span")
+	span.Finish()
+
+	for _, ch := range []chan pdata.Traces{oldCh, newCh} {
+		select {
+		case <-time.After(30 * time.Second):
+			require.Fail(t, "failed to receive a span after 30 seconds")
+		case tr := <-ch:
+			require.Equal(t, 1, tr.SpanCount())
+		}
+	}
+}
+
 func TestTrace_ApplyConfig(t *testing.T) {
 	tracesCh := make(chan pdata.Traces)
 	tracesAddr := traceutils.NewTestServer(t, func(t pdata.Traces) {
@@ -127,7 +209,7 @@ configs:
 	err := dec.Decode(&cfg)
 	require.NoError(t, err)
 
-	traces, err := New(nil, nil, prometheus.NewRegistry(), cfg, logrus.DebugLevel, logging.Format{})
+	traces, err := New(nil, nil, nil, prometheus.NewRegistry(), cfg, logrus.DebugLevel, logging.Format{})
 	require.NoError(t, err)
 	t.Cleanup(traces.Stop)
 